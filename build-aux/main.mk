@@ -134,9 +134,18 @@ else
 	sdkroot=
 endif
 
+# The platforms that go-fuseftp publishes binaries for. Binaries for other platforms, e.g. linux/arm and
+# linux/riscv64, are built without the embedded fuseftp server, and report ftp-mounts as unavailable.
+FUSEFTP_PLATFORMS = darwin-amd64 darwin-arm64 linux-amd64 linux-arm64 windows-amd64
+
 ifeq ($(DOCKER_BUILD),1)
 build-deps:
+BUILD_TAGS = docker
+else ifeq ($(filter $(GOOS)-$(GOARCH),$(FUSEFTP_PLATFORMS)),)
+build-deps:
+BUILD_TAGS = nofuseftp
 else
+BUILD_TAGS =
 FUSEFTP_VERSION=$(shell go list -m -f {{.Version}} github.com/datawire/go-fuseftp/rpc)
 
 $(BUILDDIR)/fuseftp-$(GOOS)-$(GOARCH)$(BEXE): go.mod
@@ -167,10 +176,10 @@ $(TELEPRESENCE): build-deps $(BINDIR)/wintun.dll FORCE
 endif
 	mkdir -p $(@D)
 ifeq ($(DOCKER_BUILD),1)
-	CGO_ENABLED=$(CGO_ENABLED) $(sdkroot) go build -tags docker -trimpath -ldflags=-X=$(PKG_VERSION).Version=$(TELEPRESENCE_VERSION) -o $@ ./cmd/telepresence
+	CGO_ENABLED=$(CGO_ENABLED) $(sdkroot) go build -tags '$(BUILD_TAGS)' -trimpath -ldflags=-X=$(PKG_VERSION).Version=$(TELEPRESENCE_VERSION) -o $@ ./cmd/telepresence
 else
 # -buildmode=pie addresses https://github.com/datawire/telepresence2-proprietary/issues/315
-	CGO_ENABLED=$(CGO_ENABLED) $(sdkroot) go build -buildmode=pie -trimpath -ldflags=-X=$(PKG_VERSION).Version=$(TELEPRESENCE_VERSION) -o $@ ./cmd/telepresence
+	CGO_ENABLED=$(CGO_ENABLED) $(sdkroot) go build -tags '$(BUILD_TAGS)' -buildmode=pie -trimpath -ldflags=-X=$(PKG_VERSION).Version=$(TELEPRESENCE_VERSION) -o $@ ./cmd/telepresence
 endif
 
 ifeq ($(GOOS),windows)
@@ -61,6 +61,14 @@ app: traffic-manager
 telepresence: manager
 {{- end }}
 
+{{- /*
+Broker selector labels
+*/}}
+{{- define "telepresence.brokerSelectorLabels" -}}
+app: traffic-broker
+telepresence: broker
+{{- end }}
+
 {{- /*
 Client RBAC name suffix
 */}}
@@ -202,6 +202,7 @@ func Main(ctx context.Context, args ...string) error {
 		if err := state.WaitForSftpPort(ctx, sftpPortCh); err != nil {
 			return err
 		}
+		info.SftpPort = int32(state.SftpPort())
 
 		// Manage the forwarders
 		for _, cn := range ac.Containers {
@@ -14,7 +14,9 @@ import (
 	dns2 "github.com/miekg/dns"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	empty "google.golang.org/protobuf/types/known/emptypb"
 
 	"github.com/datawire/dlib/dcontext"
@@ -141,6 +143,15 @@ func TalkToManager(ctx context.Context, address string, info *rpc.AgentInfo, sta
 		return tunnel.DialWaitLoop(ctx, manager, dialerStream, session.SessionId)
 	})
 
+	// Deal with requests to sample the traffic to the intercepted ports
+	sampleStream, err := manager.WatchSampleRequests(ctx, session)
+	if err != nil {
+		return err
+	}
+	wg.Go("sampleWait", func(ctx context.Context) error {
+		return sampleWaitLoop(ctx, manager, session, state, sampleStream)
+	})
+
 	// Deal with log-level changes
 	logLevelStream, err := manager.WatchLogLevel(ctx, &empty.Empty{})
 	if err != nil {
@@ -166,6 +177,9 @@ func TalkToManager(ctx context.Context, address string, info *rpc.AgentInfo, sta
 	wg.Go("remain", func(ctx context.Context) error {
 		return remainLoop(ctx, manager, session)
 	})
+	wg.Go("metrics", func(ctx context.Context) error {
+		return metricsLoop(ctx, manager, session, state)
+	})
 
 	file, err := dos.OpenFile(ctx, "/tmp/agent/ready", os.O_CREATE|os.O_WRONLY, 0o666)
 	if err != nil {
@@ -192,6 +206,41 @@ func remainLoop(ctx context.Context, manager rpc.ManagerClient, session *rpc.Ses
 	}
 }
 
+// metricsLoop periodically reports the metrics of the intercepts that this agent serves. An empty report is
+// sent once after the last intercept ends, so that the manager forgets this agent's metrics.
+func metricsLoop(ctx context.Context, manager rpc.ManagerClient, session *rpc.SessionInfo, state State) error {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	reported := false
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		var ms []*rpc.InterceptMetrics
+		for _, is := range state.InterceptStates() {
+			if m := is.InterceptMetrics(); m != nil {
+				ms = append(ms, m)
+			}
+		}
+		if len(ms) == 0 && !reported {
+			continue
+		}
+		if _, err := manager.ReportInterceptMetrics(ctx, &rpc.InterceptMetricsReport{Session: session, Metrics: ms}); err != nil {
+			if status.Code(err) == codes.Unimplemented {
+				dlog.Debug(ctx, "traffic-manager doesn't collect intercept metrics")
+				return nil
+			}
+			// Metrics are informational. Failing to report them is no reason to end the session.
+			dlog.Errorf(ctx, "report intercept metrics: %v", err)
+			continue
+		}
+		reported = len(ms) > 0
+	}
+}
+
 func handleInterceptLoop(ctx context.Context, snapshots <-chan *rpc.InterceptInfoSnapshot, state State, manager rpc.ManagerClient, session *rpc.SessionInfo) error {
 	for {
 		select {
@@ -54,6 +54,18 @@ func (fs *fwdState) InterceptInfo(ctx context.Context, callerID, path string, co
 	return &restapi.InterceptInfo{Intercepted: false}, nil
 }
 
+func (fs *fwdState) InterceptMetrics() *manager.InterceptMetrics {
+	return fs.forwarder.InterceptMetrics()
+}
+
+func (fs *fwdState) AddSampler(s *forwarder.Sampler) {
+	fs.forwarder.AddSampler(s)
+}
+
+func (fs *fwdState) RemoveSampler(s *forwarder.Sampler) {
+	fs.forwarder.RemoveSampler(s)
+}
+
 func (fs *fwdState) HandleIntercepts(ctx context.Context, cepts []*manager.InterceptInfo) []*manager.ReviewInterceptRequest {
 	var myChoice, activeIntercept *manager.InterceptInfo
 
@@ -0,0 +1,77 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+	"github.com/telepresenceio/telepresence/v2/pkg/forwarder"
+)
+
+func sampleWaitLoop(ctx context.Context, manager rpc.ManagerClient, session *rpc.SessionInfo, state State, stream rpc.Manager_WatchSampleRequestsClient) error {
+	for ctx.Err() == nil {
+		sr, err := stream.Recv()
+		if err != nil {
+			if status.Code(err) == codes.Unimplemented {
+				dlog.Debug(ctx, "traffic-manager doesn't send sample requests")
+				return nil
+			}
+			if ctx.Err() == nil && !errors.Is(err, io.EOF) {
+				return fmt.Errorf("sample request stream recv: %w", err)
+			}
+			return nil
+		}
+		go sampleAndRespond(ctx, manager, session, state, sr)
+	}
+	return nil
+}
+
+// sampleAndRespond counts the requests to the ports that the given request targets during the requested duration,
+// and sends the result to the manager.
+func sampleAndRespond(ctx context.Context, manager rpc.ManagerClient, session *rpc.SessionInfo, state State, sr *rpc.SampleRequest) {
+	duration := sr.Duration.AsDuration()
+	s, err := forwarder.NewSampler(sr.Filter, duration)
+	if err != nil {
+		dlog.Errorf(ctx, "unable to sample requests: %v", err)
+		return
+	}
+	dlog.Debugf(ctx, "Sampling requests to port %q for %s", sr.ServicePortIdentifier, duration)
+	for _, is := range state.InterceptStates() {
+		if samplesPort(is, sr.ServicePortIdentifier) {
+			is.AddSampler(s)
+			defer is.RemoveSampler(s)
+		}
+	}
+	select {
+	case <-ctx.Done():
+		return
+	case <-time.After(duration):
+	}
+	if _, err := manager.AgentSampleResult(ctx, &rpc.SampleAgentResult{Session: session, Id: sr.Id, Result: s.Result()}); err != nil {
+		if ctx.Err() == nil {
+			dlog.Errorf(ctx, "AgentSampleResult: %v", err)
+		}
+	}
+}
+
+// samplesPort returns true if the given intercept state serves the port with the given identifier. All ports are
+// sampled when the identifier is empty.
+func samplesPort(is InterceptState, portIdentifier string) bool {
+	if portIdentifier == "" {
+		return true
+	}
+	for _, ic := range is.InterceptConfigs() {
+		if agentconfig.IsInterceptFor(agentconfig.PortIdentifier(portIdentifier), ic) {
+			return true
+		}
+	}
+	return false
+}
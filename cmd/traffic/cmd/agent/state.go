@@ -3,13 +3,16 @@ package agent
 import (
 	"context"
 	"net/http"
+	"sync"
 
 	"github.com/blang/semver"
 
 	"github.com/datawire/dlib/dlog"
 	"github.com/telepresenceio/telepresence/rpc/v2/manager"
 	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+	"github.com/telepresenceio/telepresence/v2/pkg/forwarder"
 	"github.com/telepresenceio/telepresence/v2/pkg/restapi"
+	"github.com/telepresenceio/telepresence/v2/pkg/slice"
 )
 
 // State reflects the current state of the agent.
@@ -35,6 +38,13 @@ type InterceptState interface {
 	InterceptConfigs() []*agentconfig.Intercept
 	InterceptInfo(ctx context.Context, callerID, path string, containerPort uint16, headers http.Header) (*restapi.InterceptInfo, error)
 	HandleIntercepts(ctx context.Context, cepts []*manager.InterceptInfo) []*manager.ReviewInterceptRequest
+
+	// InterceptMetrics returns the metrics of the intercept that is served, or nil when nothing is intercepted.
+	InterceptMetrics() *manager.InterceptMetrics
+
+	// AddSampler and RemoveSampler control the samplers that inspect the requests to the intercepted port.
+	AddSampler(*forwarder.Sampler)
+	RemoveSampler(*forwarder.Sampler)
 }
 
 // State of the Traffic Agent.
@@ -50,6 +60,11 @@ type state struct {
 	mgrVer      semver.Version
 
 	interceptStates []InterceptState
+
+	// headerNames are the names of the headers that the intercepts of the agent match. They are read by the
+	// API server.
+	headerNamesLock sync.Mutex
+	headerNames     []string
 }
 
 type simpleState struct {
@@ -89,7 +104,26 @@ func (s *state) InterceptStates() []InterceptState {
 	return s.interceptStates
 }
 
+// PropagatedHeaderNames implements restapi.HeaderPropagator.
+func (s *state) PropagatedHeaderNames(context.Context) []string {
+	s.headerNamesLock.Lock()
+	defer s.headerNamesLock.Unlock()
+	return s.headerNames
+}
+
 func (s *state) HandleIntercepts(ctx context.Context, iis []*manager.InterceptInfo) []*manager.ReviewInterceptRequest {
+	var names []string
+	for _, ii := range iis {
+		for n := range ii.Headers {
+			if !slice.Contains(names, n) {
+				names = append(names, n)
+			}
+		}
+	}
+	s.headerNamesLock.Lock()
+	s.headerNames = names
+	s.headerNamesLock.Unlock()
+
 	var rs []*manager.ReviewInterceptRequest
 	for _, ist := range s.interceptStates {
 		ms := make([]*manager.InterceptInfo, 0, len(iis))
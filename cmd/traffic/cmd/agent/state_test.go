@@ -15,6 +15,7 @@ import (
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/agent"
 	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
 	"github.com/telepresenceio/telepresence/v2/pkg/forwarder"
+	"github.com/telepresenceio/telepresence/v2/pkg/restapi"
 )
 
 const (
@@ -150,3 +151,30 @@ func TestState_HandleIntercepts(t *testing.T) {
 	a.Len(reviews, 0)
 	a.Equal("", f.InterceptId())
 }
+
+func TestState_PropagatedHeaderNames(t *testing.T) {
+	ctx := testContext(t, nil)
+	_, s := makeFS(t, ctx)
+	hp, ok := s.AgentState().(restapi.HeaderPropagator)
+	require.True(t, ok)
+	assert.Empty(t, hp.PropagatedHeaderNames(ctx))
+
+	spec := &rpc.InterceptSpec{
+		Name:                  "cept1Name",
+		Client:                "user@host1",
+		Agent:                 "agentName",
+		Mechanism:             "tcp",
+		Namespace:             namespace,
+		ServiceName:           serviceName,
+		ServicePortIdentifier: "http",
+		TargetPort:            8080,
+	}
+	s.HandleIntercepts(ctx, []*rpc.InterceptInfo{
+		{Spec: spec, Id: "intercept-01", Disposition: rpc.InterceptDispositionType_WAITING, Headers: map[string]string{"x-dev-user": "bob"}},
+		{Spec: spec, Id: "intercept-02", Disposition: rpc.InterceptDispositionType_WAITING, Headers: map[string]string{"x-dev-user": "alice"}},
+	})
+	assert.Equal(t, []string{"x-dev-user"}, hp.PropagatedHeaderNames(ctx))
+
+	s.HandleIntercepts(ctx, nil)
+	assert.Empty(t, hp.PropagatedHeaderNames(ctx))
+}
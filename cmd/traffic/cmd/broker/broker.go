@@ -0,0 +1,99 @@
+// Package broker implements the traffic-broker, an optional deployment that multiplexes the tunnels of many
+// developers through one authenticated external endpoint. Each developer is identified by a personal token,
+// and gets their own rate limit and connection cap, so that one developer can't starve the others. All calls
+// are forwarded verbatim to the traffic-manager, which means that clients connect to the broker in the same
+// way that they connect to the external endpoint of the traffic-manager.
+package broker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/datawire/dlib/dgroup"
+	"github.com/datawire/dlib/dhttp"
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/version"
+)
+
+// Env is the traffic-broker's environment. Like the traffic-manager's environment, it has no defaults other
+// than the ones that the Helm chart declares.
+type Env struct {
+	LogLevel       string `env:"LOG_LEVEL,              parser=logLevel"`
+	ServerHost     string `env:"SERVER_HOST,            parser=string,          default="`
+	ServerPort     uint16 `env:"BROKER_PORT,            parser=port-number"`
+	ManagerAddress string `env:"MANAGER_ADDRESS,        parser=nonempty-string"`
+	TokensDir      string `env:"BROKER_TOKENS_DIR,      parser=nonempty-string"`
+	RateLimit      int    `env:"BROKER_RATE_LIMIT,      parser=strconv.ParseInt, default=0"`
+	MaxConnections int    `env:"BROKER_MAX_CONNECTIONS, parser=strconv.ParseInt, default=0"`
+}
+
+// Main starts up the traffic-broker and blocks until it ends.
+func Main(ctx context.Context, _ ...string) error {
+	ev, err := managerutil.LoadEnvInto(Env{}, os.LookupEnv)
+	if err != nil {
+		return fmt.Errorf("failed to LoadEnv: %w", err)
+	}
+	env := ev.(*Env)
+	dlog.Infof(ctx, "Traffic Broker %s [uid:%d,gid:%d]", version.Version, os.Getuid(), os.Getgid())
+
+	users, err := loadUsers(env.TokensDir)
+	if err != nil {
+		return err
+	}
+	conn, err := grpc.DialContext(ctx, env.ManagerAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("unable to dial the traffic-manager at %s: %w", env.ManagerAddress, err)
+	}
+	defer conn.Close()
+
+	b := &broker{
+		users:  users,
+		limits: newLimits(env.RateLimit, env.MaxConnections),
+		conn:   conn,
+	}
+	g := dgroup.NewGroup(ctx, dgroup.GroupConfig{
+		EnableSignalHandling: true,
+	})
+	g.Go("broker", func(ctx context.Context) error {
+		return b.serve(ctx, fmt.Sprintf("%s:%d", env.ServerHost, env.ServerPort))
+	})
+	return g.Wait()
+}
+
+// broker forwards the calls of authenticated users to the traffic-manager.
+type broker struct {
+	users  users
+	limits *limits
+	conn   *grpc.ClientConn
+}
+
+// grpcServer returns a server that forwards all calls using the broker.
+func (b *broker) grpcServer() *grpc.Server {
+	return grpc.NewServer(
+		grpc.ForceServerCodec(rawCodec{}),
+		grpc.UnknownServiceHandler(b.forward),
+	)
+}
+
+func (b *broker) serve(ctx context.Context, addr string) error {
+	grpcHandler := b.grpcServer()
+	sc := &dhttp.ServerConfig{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+				grpcHandler.ServeHTTP(w, r)
+			} else {
+				http.NotFound(w, r)
+			}
+		}),
+	}
+	dlog.Infof(ctx, "Traffic Broker started on %s, forwarding to %s for %d users", addr, b.conn.Target(), len(b.users))
+	defer dlog.Info(ctx, "Traffic Broker stopped")
+	return sc.ListenAndServe(ctx, addr)
+}
@@ -0,0 +1,142 @@
+package broker
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+type testManager struct {
+	rpc.UnimplementedManagerServer
+}
+
+func (testManager) Version(ctx context.Context, _ *emptypb.Empty) (*rpc.VersionInfo2, error) {
+	// The token must not reach the traffic-manager.
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("authorization")) > 0 {
+		return nil, status.Error(codes.InvalidArgument, "unexpected authorization")
+	}
+	return &rpc.VersionInfo2{Name: "test-manager", Version: "v2.0.0"}, nil
+}
+
+func (testManager) WatchAgents(_ *rpc.SessionInfo, stream rpc.Manager_WatchAgentsServer) error {
+	if err := stream.Send(&rpc.AgentInfoSnapshot{Agents: []*rpc.AgentInfo{{Name: "echo"}}}); err != nil {
+		return err
+	}
+	<-stream.Context().Done()
+	return nil
+}
+
+func serveGRPC(t *testing.T, srv *grpc.Server) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = srv.Serve(l)
+	}()
+	t.Cleanup(srv.Stop)
+	return l.Addr().String()
+}
+
+func withToken(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+func TestBroker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mgrServer := grpc.NewServer()
+	rpc.RegisterManagerServer(mgrServer, testManager{})
+	mgrConn, err := grpc.DialContext(ctx, serveGRPC(t, mgrServer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer mgrConn.Close()
+
+	b := &broker{
+		users:  users{"alice": "alice-token", "bob": "bob-token"},
+		limits: newLimits(1, 1),
+		conn:   mgrConn,
+	}
+	conn, err := grpc.DialContext(ctx, serveGRPC(t, b.grpcServer()), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := rpc.NewManagerClient(conn)
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		_, err := client.Version(ctx, &emptypb.Empty{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		_, err = client.Version(withToken(ctx, "wrong"), &emptypb.Empty{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("unary", func(t *testing.T) {
+		vi, err := client.Version(withToken(ctx, "alice-token"), &emptypb.Empty{})
+		require.NoError(t, err)
+		assert.Equal(t, "test-manager", vi.Name)
+	})
+
+	t.Run("unimplemented", func(t *testing.T) {
+		_, err := client.GetLicense(withToken(ctx, "bob-token"), &emptypb.Empty{})
+		assert.Equal(t, codes.Unimplemented, status.Code(err))
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		sCtx, sCancel := context.WithCancel(withToken(ctx, "bob-token"))
+		defer sCancel()
+		stream, err := client.WatchAgents(sCtx, &rpc.SessionInfo{})
+		require.NoError(t, err)
+		snapshot, err := stream.Recv()
+		require.NoError(t, err)
+		require.Len(t, snapshot.Agents, 1)
+		assert.Equal(t, "echo", snapshot.Agents[0].Name)
+	})
+}
+
+func TestLimits(t *testing.T) {
+	l := newLimits(1, 1)
+
+	// The burst is twice the rate.
+	release, err := l.acquire("alice", true)
+	require.NoError(t, err)
+	_, err = l.acquire("alice", true)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), "connection cap")
+	release()
+	_, err = l.acquire("alice", false)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), "rate limit")
+
+	// Users have separate limits.
+	_, err = l.acquire("bob", true)
+	assert.NoError(t, err)
+
+	// No limits
+	l = newLimits(0, 0)
+	for i := 0; i < 100; i++ {
+		_, err = l.acquire("alice", true)
+		require.NoError(t, err)
+	}
+}
+
+func TestLoadUsers(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "alice"), []byte("alice-token\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bob"), []byte(""), 0o600))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "..data"), 0o700))
+
+	us, err := loadUsers(dir)
+	require.NoError(t, err)
+	assert.Equal(t, users{"alice": "alice-token"}, us)
+
+	_, err = loadUsers(t.TempDir())
+	assert.ErrorContains(t, err, "no user tokens found")
+}
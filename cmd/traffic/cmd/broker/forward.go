@@ -0,0 +1,156 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+// streamMethods are the full names of the methods of the traffic-manager that are streams.
+var streamMethods = func() map[string]struct{} { //nolint:gochecknoglobals // constant
+	sd := &rpc.Manager_ServiceDesc
+	ms := make(map[string]struct{}, len(sd.Streams))
+	for _, s := range sd.Streams {
+		ms[fmt.Sprintf("/%s/%s", sd.ServiceName, s.StreamName)] = struct{}{}
+	}
+	return ms
+}()
+
+// frame is a message that is forwarded without being decoded.
+type frame struct {
+	payload []byte
+}
+
+// rawCodec passes frames through as they are. It is named "proto" so that it replaces the default codec.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	f, ok := v.(*frame)
+	if !ok {
+		return nil, fmt.Errorf("unable to marshal %T", v)
+	}
+	return f.payload, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	f, ok := v.(*frame)
+	if !ok {
+		return fmt.Errorf("unable to unmarshal into %T", v)
+	}
+	f.payload = append(f.payload[:0], data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+// forward authenticates the caller, applies the caller's limits, and then forwards the call to the
+// traffic-manager.
+func (b *broker) forward(_ any, ss grpc.ServerStream) error {
+	method, ok := grpc.MethodFromServerStream(ss)
+	if !ok {
+		return status.Error(codes.Internal, "unable to determine the method of the call")
+	}
+	ctx := ss.Context()
+	user, err := b.users.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+	_, stream := streamMethods[method]
+	release, err := b.limits.acquire(user, stream)
+	if err != nil {
+		dlog.Debugf(ctx, "%s denied for user %s: %v", method, user, err)
+		return err
+	}
+	defer release()
+
+	// The token is the broker's concern, so it isn't passed on to the traffic-manager.
+	md, _ := metadata.FromIncomingContext(ctx)
+	md = md.Copy()
+	delete(md, "authorization")
+	ctx, cancel := context.WithCancel(metadata.NewOutgoingContext(ctx, md))
+	defer cancel()
+	cs, err := b.conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, method, grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		return err
+	}
+
+	toManager := make(chan error, 1)
+	go func() {
+		toManager <- forwardToManager(ss, cs)
+	}()
+	toClient := make(chan error, 1)
+	go func() {
+		toClient <- forwardToClient(cs, ss)
+	}()
+	for {
+		select {
+		case err := <-toManager:
+			if !errors.Is(err, io.EOF) {
+				// The client is gone.
+				return status.Errorf(codes.Canceled, "failed to forward to the traffic-manager: %v", err)
+			}
+			// The client is done sending. Continue until the traffic-manager is done too.
+		case err := <-toClient:
+			ss.SetTrailer(cs.Trailer())
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// forwardToManager forwards the messages from the client to the traffic-manager until the client closes its
+// end of the stream, in which case io.EOF is returned.
+func forwardToManager(ss grpc.ServerStream, cs grpc.ClientStream) error {
+	f := &frame{}
+	for {
+		if err := ss.RecvMsg(f); err != nil {
+			if errors.Is(err, io.EOF) {
+				if err := cs.CloseSend(); err != nil {
+					return err
+				}
+			}
+			return err
+		}
+		if err := cs.SendMsg(f); err != nil {
+			return err
+		}
+	}
+}
+
+// forwardToClient forwards the header and the messages from the traffic-manager to the client until the
+// traffic-manager ends the call. The error is io.EOF when the call ends successfully, and otherwise the
+// traffic-manager's status.
+func forwardToClient(cs grpc.ClientStream, ss grpc.ServerStream) error {
+	f := &frame{}
+	for first := true; ; first = false {
+		if err := cs.RecvMsg(f); err != nil {
+			return err
+		}
+		if first {
+			// The header is available once the first message has arrived.
+			md, err := cs.Header()
+			if err != nil {
+				return err
+			}
+			if err := ss.SendHeader(md); err != nil {
+				return err
+			}
+		}
+		if err := ss.SendMsg(f); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package broker
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// users maps the name of a user to the token that the user presents as a bearer token.
+type users map[string]string
+
+// loadUsers loads the users from the given directory, which is typically a mounted secret. Each file in the
+// directory is named after a user and contains that user's token.
+func loadUsers(dir string) (users, error) {
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the user tokens: %w", err)
+	}
+	us := make(users, len(des))
+	for _, de := range des {
+		// Mounted secrets have hidden entries for their data and timestamp directories.
+		name := de.Name()
+		if strings.HasPrefix(name, ".") || de.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the token of user %s: %w", name, err)
+		}
+		if token := strings.TrimSpace(string(data)); token != "" {
+			us[name] = token
+		}
+	}
+	if len(us) == 0 {
+		return nil, fmt.Errorf("no user tokens found in %s", dir)
+	}
+	return us, nil
+}
+
+// authenticate returns the name of the user that presents its token as a bearer token in the given context.
+func (us users) authenticate(ctx context.Context) (string, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, a := range md.Get("authorization") {
+			if !strings.HasPrefix(a, "Bearer ") {
+				continue
+			}
+			token := []byte(a[7:])
+			for name, t := range us {
+				if subtle.ConstantTimeCompare(token, []byte(t)) == 1 {
+					return name, nil
+				}
+			}
+		}
+	}
+	return "", status.Error(codes.Unauthenticated, "a valid bearer token is required")
+}
+
+// limits enforces the per-user rate limit on calls, and the per-user cap on concurrent connections. Each
+// stream, i.e. each tunneled connection and each watcher, counts as a connection.
+type limits struct {
+	limit          rate.Limit
+	burst          int
+	maxConnections int
+
+	lock  sync.Mutex
+	users map[string]*userLimits
+}
+
+type userLimits struct {
+	*rate.Limiter
+	connections int
+}
+
+// newLimits returns limits that allow each user the given number of calls per second, and the given number
+// of concurrent connections. Zero or a negative number means no limit.
+func newLimits(maxRate, maxConnections int) *limits {
+	l := &limits{
+		limit:          rate.Inf,
+		maxConnections: maxConnections,
+		users:          make(map[string]*userLimits),
+	}
+	if maxRate > 0 {
+		l.limit = rate.Limit(maxRate)
+		l.burst = 2 * maxRate
+	}
+	return l
+}
+
+// acquire returns a ResourceExhausted error if the given user has exceeded its rate limit, or has reached its
+// connection cap and the call is a stream. The returned function must be called when the call ends.
+func (l *limits) acquire(user string, stream bool) (func(), error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	ul, ok := l.users[user]
+	if !ok {
+		ul = &userLimits{Limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.users[user] = ul
+	}
+	if !ul.Allow() {
+		return nil, status.Errorf(codes.ResourceExhausted, "too many requests, the limit is %d requests per second", int(l.limit))
+	}
+	if !stream {
+		return func() {}, nil
+	}
+	if l.maxConnections > 0 && ul.connections >= l.maxConnections {
+		return nil, status.Errorf(codes.ResourceExhausted, "too many connections, the limit is %d concurrent connections", l.maxConnections)
+	}
+	ul.connections++
+	return func() {
+		l.lock.Lock()
+		ul.connections--
+		l.lock.Unlock()
+	}, nil
+}
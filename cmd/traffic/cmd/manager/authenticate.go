@@ -0,0 +1,39 @@
+package manager
+
+import (
+	"context"
+
+	auth "k8s.io/api/authentication/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/slice"
+)
+
+// authenticate returns the Kubernetes user that the given token belongs to, as reported by a TokenReview. Only
+// tokens that are bound to the client.ManagerTokenAudience are accepted, so a client's own credentials, which a
+// client never sends, would not be authenticated. Nil is returned when the token is empty or can't be authenticated.
+func authenticate(ctx context.Context, token string) *rpc.UserIdentity {
+	if token == "" {
+		return nil
+	}
+	tr, err := k8sapi.GetK8sInterface(ctx).AuthenticationV1().TokenReviews().Create(ctx, &auth.TokenReview{
+		Spec: auth.TokenReviewSpec{Token: token, Audiences: []string{client.ManagerTokenAudience}},
+	}, meta.CreateOptions{})
+	if err != nil {
+		dlog.Errorf(ctx, "unable to review the token of a client: %v", err)
+		return nil
+	}
+	if !tr.Status.Authenticated {
+		dlog.Infof(ctx, "the token of a client was not authenticated: %s", tr.Status.Error)
+		return nil
+	}
+	if !slice.Contains(tr.Status.Audiences, client.ManagerTokenAudience) {
+		dlog.Infof(ctx, "the token of a client is not bound to the %s audience", client.ManagerTokenAudience)
+		return nil
+	}
+	return &rpc.UserIdentity{Username: tr.Status.User.Username, Groups: tr.Status.User.Groups}
+}
@@ -0,0 +1,54 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	auth "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+)
+
+func TestAuthenticate(t *testing.T) {
+	ki := fake.NewSimpleClientset()
+	ki.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		tr := action.(k8stesting.CreateAction).GetObject().(*auth.TokenReview)
+		assert.Equal(t, []string{client.ManagerTokenAudience}, tr.Spec.Audiences)
+		switch tr.Spec.Token {
+		case "valid":
+			tr.Status = auth.TokenReviewStatus{
+				Authenticated: true,
+				User:          auth.UserInfo{Username: "alice", Groups: []string{"developers", "system:authenticated"}},
+				Audiences:     []string{client.ManagerTokenAudience},
+			}
+		case "unbound":
+			// An authenticator that doesn't support audiences, given the user's own credentials.
+			tr.Status = auth.TokenReviewStatus{
+				Authenticated: true,
+				User:          auth.UserInfo{Username: "alice"},
+			}
+		case "broken":
+			return true, nil, errors.New("forbidden")
+		default:
+			tr.Status = auth.TokenReviewStatus{Error: "invalid bearer token"}
+		}
+		return true, tr, nil
+	})
+	ctx := k8sapi.WithK8sInterface(dlog.NewTestContext(t, false), ki)
+
+	id := authenticate(ctx, "valid")
+	if assert.NotNil(t, id) {
+		assert.Equal(t, "alice", id.Username)
+		assert.Equal(t, []string{"developers", "system:authenticated"}, id.Groups)
+	}
+	assert.Nil(t, authenticate(ctx, "unbound"))
+	assert.Nil(t, authenticate(ctx, "forged"))
+	assert.Nil(t, authenticate(ctx, "broken"))
+	assert.Nil(t, authenticate(ctx, ""))
+}
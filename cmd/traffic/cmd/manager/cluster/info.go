@@ -4,12 +4,14 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"os"
 	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/blang/semver"
+	"google.golang.org/protobuf/proto"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/informers"
@@ -41,6 +43,11 @@ type Info interface {
 	// GetTrafficAgentPods acquires all pods that have a `traffic-agent`
 	// container in their spec
 	GetTrafficAgentPods(context.Context, string) ([]*corev1.Pod, error)
+
+	// SetFederation declares the subnets and DNS suffixes of the clusters that the traffic-manager
+	// federates with, so that clients route them to the traffic-manager too. Subnets that overlap
+	// with the subnets of this cluster are ignored.
+	SetFederation(ctx context.Context, subnets []*net.IPNet, suffixes []string)
 }
 
 type subnetRetriever interface {
@@ -49,9 +56,14 @@ type subnetRetriever interface {
 }
 
 type info struct {
+	sync.Mutex
 	rpc.ClusterInfo
 	ciSubs *clusterInfoSubscribers
 
+	// federatedSubnets and federatedSuffixes are the subnets and the DNS suffixes of federated clusters.
+	federatedSubnets  []*net.IPNet
+	federatedSuffixes []string
+
 	// clusterID is the UID of the default namespace
 	clusterID string
 }
@@ -220,7 +232,17 @@ func NewInfo(ctx context.Context) Info {
 	return &oi
 }
 
+// resolvConf is the resolver configuration that kubelet generates for the traffic-manager's pod.
+const resolvConf = "/etc/resolv.conf"
+
 func getClusterDomain(ctx context.Context, svcIp net.IP, env *managerutil.Env) string {
+	if data, err := os.ReadFile(resolvConf); err == nil {
+		if domain := clusterDomainFromResolvConf(data, env.ManagerNamespace); domain != "" {
+			dlog.Infof(ctx, "Cluster domain derived from search path in %s", resolvConf)
+			return domain
+		}
+	}
+
 	desiredMatch := env.AgentInjectorName + "." + env.ManagerNamespace + ".svc."
 	addr := svcIp.String()
 
@@ -244,6 +266,28 @@ func getClusterDomain(ctx context.Context, svcIp net.IP, env *managerutil.Env) s
 	return "cluster.local."
 }
 
+// clusterDomainFromResolvConf returns the cluster domain, ending with a dot, that is found in the search path
+// of the given resolv.conf content. Kubelet adds "<namespace>.svc.<cluster domain>" to the search path of pods
+// that use the ClusterFirst DNS policy, so the domain is found without depending on the cluster's DNS server.
+// An empty string is returned if no such entry exists.
+func clusterDomainFromResolvConf(data []byte, namespace string) string {
+	prefix := namespace + ".svc."
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "search" {
+			continue
+		}
+		for _, sp := range fields[1:] {
+			if domain, ok := strings.CutPrefix(sp, prefix); ok {
+				if domain = strings.TrimSuffix(domain, "."); domain != "" {
+					return domain + "."
+				}
+			}
+		}
+	}
+	return ""
+}
+
 func (oi *info) watchNodeSubnets(ctx context.Context, mustSucceed bool) bool {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -363,16 +407,59 @@ func (oi *info) clusterInfo() *rpc.ClusterInfo {
 		ClusterDomain:   oi.Dns.ClusterDomain,
 	}
 	copy(ci.PodSubnets, oi.PodSubnets)
+	for _, sn := range oi.federatedSubnets {
+		if oi.overlappingLocalSubnet(sn) == nil {
+			ci.PodSubnets = append(ci.PodSubnets, iputil.IPNetToRPC(sn))
+		}
+	}
+	if len(oi.federatedSuffixes) > 0 {
+		dns := proto.Clone(oi.Dns).(*rpc.DNS)
+		dns.IncludeSuffixes = append(dns.IncludeSuffixes, oi.federatedSuffixes...)
+		ci.Dns = dns
+	}
 	return ci
 }
 
 func (oi *info) watchSubnets(ctx context.Context, retriever subnetRetriever) {
 	retriever.changeNotifier(ctx, func(subnets subnet.Set) {
+		oi.Lock()
 		oi.PodSubnets = subnetSetToRPC(subnets)
-		oi.ciSubs.notify(ctx, oi.clusterInfo())
+		ci := oi.clusterInfo()
+		oi.Unlock()
+		oi.ciSubs.notify(ctx, ci)
 	})
 }
 
+func (oi *info) SetFederation(ctx context.Context, subnets []*net.IPNet, suffixes []string) {
+	oi.Lock()
+	for _, sn := range subnets {
+		if lsn := oi.overlappingLocalSubnet(sn); lsn != nil {
+			dlog.Errorf(ctx, "federated subnet %s overlaps with subnet %s of this cluster and will not be routed", sn, lsn)
+		}
+	}
+	oi.federatedSubnets = subnets
+	oi.federatedSuffixes = suffixes
+	ci := oi.clusterInfo()
+	oi.Unlock()
+	oi.ciSubs.notify(ctx, ci)
+}
+
+// overlappingLocalSubnet returns the service subnet or pod subnet of this cluster that overlaps with the
+// given subnet, or nil if no such subnet exists.
+func (oi *info) overlappingLocalSubnet(sn *net.IPNet) *net.IPNet {
+	if oi.ServiceSubnet != nil {
+		if lsn := iputil.IPNetFromRPC(oi.ServiceSubnet); subnet.Overlaps(sn, lsn) {
+			return lsn
+		}
+	}
+	for _, rsn := range oi.PodSubnets {
+		if lsn := iputil.IPNetFromRPC(rsn); subnet.Overlaps(sn, lsn) {
+			return lsn
+		}
+	}
+	return nil
+}
+
 func subnetSetToRPC(cidrMap subnet.Set) []*rpc.IPNet {
 	return subnetsToRPC(cidrMap.AppendSortedTo(nil))
 }
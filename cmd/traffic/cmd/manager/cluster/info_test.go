@@ -2,6 +2,7 @@ package cluster
 
 import (
 	"context"
+	"net"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -11,8 +12,11 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/fake"
 
+	"github.com/datawire/dlib/dlog"
 	"github.com/datawire/k8sapi/pkg/k8sapi"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
 )
 
 func TestNewInfo_GetClusterID(t *testing.T) {
@@ -71,3 +75,61 @@ func TestNewInfo_GetClusterID(t *testing.T) {
 		require.Equal(t, info.ID(), testUID)
 	})
 }
+
+func Test_clusterDomainFromResolvConf(t *testing.T) {
+	tests := []struct {
+		name string
+		conf string
+		want string
+	}{
+		{
+			"default",
+			"search ambassador.svc.cluster.local svc.cluster.local cluster.local\nnameserver 10.96.0.10\noptions ndots:5\n",
+			"cluster.local.",
+		},
+		{
+			"custom",
+			"nameserver 10.0.0.10\nsearch ambassador.svc.corp.internal. svc.corp.internal corp.internal ec2.internal\n",
+			"corp.internal.",
+		},
+		{
+			"other namespace",
+			"search default.svc.cluster.local svc.cluster.local cluster.local\n",
+			"",
+		},
+		{
+			"no search",
+			"nameserver 8.8.8.8\n",
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, clusterDomainFromResolvConf([]byte(tt.conf), "ambassador"))
+		})
+	}
+}
+
+func TestInfo_SetFederation(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	ipNet := func(s string) *net.IPNet {
+		_, sn, err := net.ParseCIDR(s)
+		require.NoError(t, err)
+		return sn
+	}
+	oi := &info{}
+	oi.ServiceSubnet = iputil.IPNetToRPC(ipNet("10.96.0.0/12"))
+	oi.PodSubnets = []*rpc.IPNet{iputil.IPNetToRPC(ipNet("10.244.0.0/16"))}
+	oi.Dns = &rpc.DNS{IncludeSuffixes: []string{".corp"}, ClusterDomain: "cluster.local."}
+	oi.ciSubs = newClusterInfoSubscribers(oi.clusterInfo())
+
+	oi.SetFederation(ctx, []*net.IPNet{ipNet("10.8.0.0/16"), ipNet("10.244.128.0/17")}, []string{".eu"})
+	ci := oi.clusterInfo()
+	require.Len(t, ci.PodSubnets, 2)
+	require.Equal(t, ipNet("10.244.0.0/16"), iputil.IPNetFromRPC(ci.PodSubnets[0]))
+	require.Equal(t, ipNet("10.8.0.0/16"), iputil.IPNetFromRPC(ci.PodSubnets[1]))
+	require.Equal(t, []string{".corp", ".eu"}, ci.Dns.IncludeSuffixes)
+
+	// The DNS config of the cluster itself is unaffected.
+	require.Equal(t, []string{".corp"}, oi.Dns.IncludeSuffixes)
+}
@@ -95,9 +95,15 @@ func clusterInfoEqual(a, b *rpc.ClusterInfo) bool {
 	if len(a.PodSubnets) != len(b.PodSubnets) ||
 		a.ServiceSubnet != b.ServiceSubnet ||
 		a.Dns.ClusterDomain != b.Dns.ClusterDomain ||
-		!net.IP(a.Dns.KubeIp).Equal(b.Dns.KubeIp) {
+		!net.IP(a.Dns.KubeIp).Equal(b.Dns.KubeIp) ||
+		len(a.Dns.IncludeSuffixes) != len(b.Dns.IncludeSuffixes) {
 		return false
 	}
+	for i, ais := range a.Dns.IncludeSuffixes {
+		if ais != b.Dns.IncludeSuffixes[i] {
+			return false
+		}
+	}
 	for i, aps := range a.PodSubnets {
 		bps := b.PodSubnets[i]
 		if !net.IP(aps.Ip).Equal(bps.Ip) || aps.Mask != bps.Mask {
@@ -13,12 +13,15 @@ import (
 	"github.com/datawire/dlib/dlog"
 	"github.com/datawire/k8sapi/pkg/k8sapi"
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/policy"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
 )
 
 const (
 	clientConfigFileName         = "client.yaml"
 	trafficManagerConfigFileName = "traffic-manager.yaml"
+	interceptPolicyFileName      = "intercept-policy.yaml"
+	execPolicyFileName           = "exec-policy.yaml"
 	cfgConfigMapName             = "traffic-manager"
 )
 
@@ -28,6 +31,14 @@ type Watcher interface {
 	Run(ctx context.Context) error
 	GetClientConfigYaml() []byte
 	GetTrafficManagerConfigYaml() []byte
+
+	// GetInterceptPolicy returns the intercept policy, or an error if the configured policy is
+	// invalid. A nil policy permits all intercepts.
+	GetInterceptPolicy() (*policy.Policy, error)
+
+	// GetExecPolicy returns the exec policy, or an error if the configured policy is invalid. A nil
+	// policy permits no commands.
+	GetExecPolicy() (*policy.Policy, error)
 }
 
 type config struct {
@@ -36,6 +47,10 @@ type config struct {
 
 	clientYAML         []byte
 	trafficManagerYAML []byte
+	interceptPolicy    *policy.Policy
+	interceptPolicyErr error
+	execPolicy         *policy.Policy
+	execPolicyErr      error
 }
 
 func NewWatcher(namespace string) Watcher {
@@ -119,6 +134,30 @@ func (c *config) refreshFile(ctx context.Context, data map[string]string) {
 		c.trafficManagerYAML = nil
 		dlog.Debugf(ctx, "Cleared traffic-manager config")
 	}
+
+	if yml, ok := data[interceptPolicyFileName]; ok {
+		c.interceptPolicy, c.interceptPolicyErr = policy.Parse([]byte(yml))
+		if c.interceptPolicyErr != nil {
+			dlog.Errorf(ctx, "all intercepts will be refused: %v", c.interceptPolicyErr)
+		} else {
+			dlog.Debugf(ctx, "Refreshed intercept policy: %s", yml)
+		}
+	} else {
+		c.interceptPolicy, c.interceptPolicyErr = nil, nil
+		dlog.Debugf(ctx, "Cleared intercept policy")
+	}
+
+	if yml, ok := data[execPolicyFileName]; ok {
+		c.execPolicy, c.execPolicyErr = policy.Parse([]byte(yml))
+		if c.execPolicyErr != nil {
+			dlog.Errorf(ctx, "all exec requests will be refused: %v", c.execPolicyErr)
+		} else {
+			dlog.Debugf(ctx, "Refreshed exec policy: %s", yml)
+		}
+	} else {
+		c.execPolicy, c.execPolicyErr = nil, nil
+		dlog.Debugf(ctx, "Cleared exec policy")
+	}
 	c.Unlock()
 }
 
@@ -135,3 +174,15 @@ func (c *config) GetTrafficManagerConfigYaml() (ret []byte) {
 	c.RUnlock()
 	return
 }
+
+func (c *config) GetInterceptPolicy() (*policy.Policy, error) {
+	c.RLock()
+	defer c.RUnlock()
+	return c.interceptPolicy, c.interceptPolicyErr
+}
+
+func (c *config) GetExecPolicy() (*policy.Policy, error) {
+	c.RLock()
+	defer c.RUnlock()
+	return c.execPolicy, c.execPolicyErr
+}
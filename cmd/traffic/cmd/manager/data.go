@@ -7,6 +7,7 @@ import (
 	"github.com/blang/semver"
 
 	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/matcher"
 )
 
 func validateClient(client *rpc.ClientInfo) string {
@@ -76,6 +77,35 @@ func validateIntercept(spec *rpc.InterceptSpec) string {
 		return "namespace must not be empty"
 	case spec.Mechanism == "":
 		return "mechanism must not be empty"
+	case spec.MaxDuration != nil && spec.MaxDuration.AsDuration() <= 0:
+		return "max duration must be positive"
+	}
+
+	if spec.FaultsOnly && len(spec.FaultRules) == 0 {
+		return "faults only requires fault rules"
+	}
+	for idx, rule := range spec.FaultRules {
+		if msg := validateFaultRule(rule); msg != "" {
+			return fmt.Sprintf("fault rule %d: %s", idx+1, msg)
+		}
+	}
+
+	return ""
+}
+
+func validateFaultRule(rule *rpc.FaultRule) string {
+	switch {
+	case rule.Percent <= 0 || rule.Percent > 100:
+		return "percent must be greater than 0 and at most 100"
+	case rule.Delay != nil && rule.Delay.AsDuration() < 0:
+		return "delay must not be negative"
+	case rule.AbortStatus != 0 && (rule.AbortStatus < 200 || rule.AbortStatus > 599):
+		return "abort status must be an HTTP status code between 200 and 599"
+	case rule.Delay.AsDuration() == 0 && rule.AbortStatus == 0 && len(rule.ResponseHeaders) == 0:
+		return "a delay, an abort status, or response headers must be given"
+	}
+	if _, err := matcher.NewRequestFromMap(rule.Match); err != nil {
+		return err.Error()
 	}
 
 	return ""
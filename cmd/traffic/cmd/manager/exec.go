@@ -0,0 +1,357 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	auth "k8s.io/api/authorization/v1"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/util/exec"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/policy"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+)
+
+// Exec runs a command in the app container of a workload on behalf of a client. The command is executed
+// using the traffic-manager's service account, but only when both the exec policy and the Kubernetes RBAC
+// of the client's authenticated user permit it.
+func (s *service) Exec(stream rpc.Manager_ExecServer) error {
+	ctx := stream.Context()
+	rq, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	ctx = managerutil.WithSessionInfo(ctx, rq.GetSession())
+	dlog.Debug(ctx, "Exec called")
+	if !managerutil.GetEnv(ctx).ExecEnabled {
+		return status.Error(codes.FailedPrecondition,
+			"exec is not enabled in the traffic-manager. Ask your cluster administrator to set the exec.enabled Helm value")
+	}
+	sessionID := rq.GetSession().GetSessionId()
+	client := s.state.GetClient(sessionID)
+	if client == nil {
+		return status.Errorf(codes.NotFound, "Client session %q not found", sessionID)
+	}
+	if client.Observe {
+		return status.Errorf(codes.PermissionDenied, "Client session %q is observing and cannot exec", sessionID)
+	}
+	if len(rq.Command) == 0 {
+		return status.Error(codes.InvalidArgument, "no command given")
+	}
+	maxDuration, err := s.checkExecPolicy(ctx, client, rq)
+	if err != nil {
+		return err
+	}
+	pod, err := s.findAgentPod(ctx, rq.Name, rq.Namespace)
+	if err != nil {
+		return err
+	}
+	container, err := execContainer(pod, rq.Container)
+	if err != nil {
+		return err
+	}
+	if err = authorizeExec(ctx, client.GetIdentity(), pod, container); err != nil {
+		return err
+	}
+
+	// The start and end of each session are always logged, for audits.
+	user := policy.UserName(client)
+	ctx = dlog.WithField(ctx, "exec", fmt.Sprintf("%s/%s/%s", pod.Namespace, pod.Name, container))
+	dlog.Infof(ctx, "User %q started %q", user, strings.Join(rq.Command, " "))
+	start := time.Now()
+	code, err := s.exec(ctx, stream, rq, pod, container, maxDuration)
+	if err != nil {
+		dlog.Infof(ctx, "User %q ended after %s: %v", user, time.Since(start).Truncate(time.Second), err)
+		return err
+	}
+	dlog.Infof(ctx, "User %q ended after %s with exit code %d", user, time.Since(start).Truncate(time.Second), code)
+	return stream.Send(&rpc.ExecResponse{Done: true, ExitCode: int32(code)})
+}
+
+// checkExecPolicy returns the maximum duration that the exec policy permits for the given request, or a
+// PermissionDenied error when the policy refuses it. Zero means unlimited.
+func (s *service) checkExecPolicy(ctx context.Context, client *rpc.ClientInfo, rq *rpc.ExecRequest) (time.Duration, error) {
+	p, err := s.configWatcher.GetExecPolicy()
+	if err != nil {
+		return 0, status.Errorf(codes.FailedPrecondition,
+			"The exec policy of the traffic-manager is invalid: %v. Ask your cluster administrator to correct it", err)
+	}
+	d, err := p.CheckExec(client.GetIdentity(), rq.Namespace, rq.Name)
+	if err != nil {
+		dlog.Infof(ctx, "Exec in %s.%s refused by policy: %v", rq.Name, rq.Namespace, err)
+		return 0, status.Error(codes.PermissionDenied, err.Error())
+	}
+	return d, nil
+}
+
+// authorizeExec uses a SubjectAccessReview to check that the given user may exec into the container of the
+// pod. The traffic-manager's service account may exec into any pod, so it must never do so on behalf of a
+// user that Kubernetes wouldn't permit to.
+func authorizeExec(ctx context.Context, user *rpc.UserIdentity, pod *core.Pod, container string) error {
+	if user == nil {
+		return status.Error(codes.PermissionDenied, "exec requires a user that has been authenticated by the Kubernetes API server")
+	}
+	sar, err := k8sapi.GetK8sInterface(ctx).AuthorizationV1().SubjectAccessReviews().Create(ctx, &auth.SubjectAccessReview{
+		Spec: auth.SubjectAccessReviewSpec{
+			User:   user.Username,
+			Groups: user.Groups,
+			ResourceAttributes: &auth.ResourceAttributes{
+				Namespace:   pod.Namespace,
+				Verb:        "create",
+				Resource:    "pods",
+				Subresource: "exec",
+				Name:        pod.Name,
+			},
+		},
+	}, meta.CreateOptions{})
+	if err != nil {
+		return status.Errorf(codes.Internal, "unable to review the permissions of user %q: %v", user.Username, err)
+	}
+	if !sar.Status.Allowed {
+		dlog.Infof(ctx, "Exec in %s/%s/%s refused for user %q: %s", pod.Namespace, pod.Name, container, user.Username, sar.Status.Reason)
+		return status.Errorf(codes.PermissionDenied, "user %q may not exec into pod %s.%s", user.Username, pod.Name, pod.Namespace)
+	}
+	return nil
+}
+
+// findAgentPod returns a running pod of the given workload that has a traffic-agent.
+func (s *service) findAgentPod(ctx context.Context, name, namespace string) (*core.Pod, error) {
+	wCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	snapshot, ok := <-s.state.WatchAgents(wCtx, func(_ string, a *rpc.AgentInfo) bool {
+		return a.Name == name && a.Namespace == namespace
+	})
+	if !ok {
+		return nil, ctx.Err()
+	}
+	pods := k8sapi.GetK8sInterface(ctx).CoreV1().Pods(namespace)
+	for _, a := range snapshot.State {
+		pl, err := pods.List(ctx, meta.ListOptions{FieldSelector: "status.podIP=" + a.PodIp})
+		if err != nil {
+			return nil, err
+		}
+		for i := range pl.Items {
+			if pod := &pl.Items[i]; pod.Status.Phase == core.PodRunning {
+				return pod, nil
+			}
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "no running pod of %s.%s has a traffic-agent", name, namespace)
+}
+
+// execContainer returns the name of the given container of the pod, or of the first container that isn't the
+// traffic-agent when no container is given.
+func execContainer(pod *core.Pod, name string) (string, error) {
+	for _, c := range pod.Spec.Containers {
+		if name == "" && c.Name != agentconfig.ContainerName || c.Name == name {
+			return c.Name, nil
+		}
+	}
+	if name == "" {
+		return "", status.Errorf(codes.NotFound, "pod %s.%s has no app container", pod.Name, pod.Namespace)
+	}
+	return "", status.Errorf(codes.NotFound, "pod %s.%s has no container named %q", pod.Name, pod.Namespace, name)
+}
+
+// exec runs the command of the request in the given container, and streams its input and output. It returns
+// the exit code of the command.
+func (s *service) exec(
+	ctx context.Context,
+	stream rpc.Manager_ExecServer,
+	rq *rpc.ExecRequest,
+	pod *core.Pod,
+	container string,
+	maxDuration time.Duration,
+) (int, error) {
+	var cancel context.CancelFunc
+	if maxDuration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, maxDuration)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	req := k8sapi.GetK8sInterface(ctx).CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(pod.Name).
+		Namespace(pod.Namespace).
+		SubResource("exec").
+		VersionedParams(&core.PodExecOptions{
+			Container: container,
+			Command:   rq.Command,
+			Stdin:     true,
+			Stdout:    true,
+			Stderr:    !rq.Tty,
+			TTY:       rq.Tty,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(managerutil.GetRestConfig(ctx), "POST", req.URL())
+	if err != nil {
+		return 0, status.Errorf(codes.Internal, "unable to create executor: %v", err)
+	}
+
+	record := managerutil.GetEnv(ctx).ExecRecord
+	sender := &execSender{stream: stream}
+	var stdout, stderr io.Writer = sender.writer(false), sender.writer(true)
+	stdinR, stdinW := io.Pipe()
+	var stdin io.Writer = stdinW
+	if record {
+		outRec, errRec, inRec := newRecorder(ctx, "stdout"), newRecorder(ctx, "stderr"), newRecorder(ctx, "stdin")
+		defer func() {
+			outRec.flush()
+			errRec.flush()
+			inRec.flush()
+		}()
+		stdout = io.MultiWriter(stdout, outRec)
+		stderr = io.MultiWriter(stderr, errRec)
+		stdin = io.MultiWriter(stdin, inRec)
+	}
+
+	sizes := make(chan *remotecommand.TerminalSize, 1)
+	if rz := rq.Resize; rz != nil {
+		sizes <- &remotecommand.TerminalSize{Width: uint16(rz.Width), Height: uint16(rz.Height)}
+	}
+	go func() {
+		defer close(sizes)
+		for {
+			rq, err := stream.Recv()
+			if err != nil {
+				// The client is gone, or has closed its end of the stream.
+				_ = stdinW.CloseWithError(err)
+				if !errors.Is(err, io.EOF) {
+					cancel()
+				}
+				return
+			}
+			if len(rq.Stdin) > 0 {
+				if _, err = stdin.Write(rq.Stdin); err != nil {
+					return
+				}
+			}
+			if rq.StdinClosed {
+				_ = stdinW.Close()
+			}
+			if rz := rq.Resize; rz != nil {
+				select {
+				case sizes <- &remotecommand.TerminalSize{Width: uint16(rz.Width), Height: uint16(rz.Height)}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	opts := remotecommand.StreamOptions{
+		Stdin:  stdinR,
+		Stdout: stdout,
+		Tty:    rq.Tty,
+	}
+	if rq.Tty {
+		opts.TerminalSizeQueue = sizeQueue(sizes)
+	} else {
+		opts.Stderr = stderr
+	}
+	err = executor.StreamWithContext(ctx, opts)
+	_ = stdinR.Close()
+	if err != nil {
+		var ce exec.CodeExitError
+		if errors.As(err, &ce) {
+			return ce.ExitStatus(), nil
+		}
+		if ctx.Err() == context.DeadlineExceeded {
+			return 0, status.Errorf(codes.DeadlineExceeded, "the exec policy limits the session to %s", maxDuration)
+		}
+		return 0, status.Errorf(codes.Internal, "exec failed: %v", err)
+	}
+	return 0, nil
+}
+
+// sizeQueue is a remotecommand.TerminalSizeQueue that reads from a channel.
+type sizeQueue chan *remotecommand.TerminalSize
+
+func (q sizeQueue) Next() *remotecommand.TerminalSize {
+	return <-q
+}
+
+// execSender sends the output of a command to the client. Sends are serialized, because the stdout and
+// stderr of the command are written concurrently.
+type execSender struct {
+	sync.Mutex
+	stream rpc.Manager_ExecServer
+}
+
+type execWriter struct {
+	*execSender
+	stderr bool
+}
+
+func (s *execSender) writer(stderr bool) io.Writer {
+	return &execWriter{execSender: s, stderr: stderr}
+}
+
+func (w *execWriter) Write(data []byte) (int, error) {
+	r := &rpc.ExecResponse{}
+	if w.stderr {
+		r.Stderr = append([]byte(nil), data...)
+	} else {
+		r.Stdout = append([]byte(nil), data...)
+	}
+	w.Lock()
+	defer w.Unlock()
+	if err := w.stream.Send(r); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// recorder writes the lines of a stream of an exec session to the traffic-manager log.
+type recorder struct {
+	sync.Mutex
+	ctx  context.Context
+	name string
+	buf  []byte
+}
+
+func newRecorder(ctx context.Context, name string) *recorder {
+	return &recorder{ctx: ctx, name: name}
+}
+
+func (r *recorder) Write(data []byte) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+	r.buf = append(r.buf, data...)
+	for {
+		i := bytes.IndexAny(r.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		if i > 0 {
+			dlog.Infof(r.ctx, "%s: %q", r.name, r.buf[:i])
+		}
+		r.buf = r.buf[i+1:]
+	}
+	return len(data), nil
+}
+
+func (r *recorder) flush() {
+	r.Lock()
+	defer r.Unlock()
+	if len(r.buf) > 0 {
+		dlog.Infof(r.ctx, "%s: %q", r.name, r.buf)
+		r.buf = nil
+	}
+}
@@ -0,0 +1,81 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	auth "k8s.io/api/authorization/v1"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+)
+
+func TestExecContainer(t *testing.T) {
+	pod := &core.Pod{
+		ObjectMeta: meta.ObjectMeta{Name: "echo-abc", Namespace: "default"},
+		Spec: core.PodSpec{Containers: []core.Container{
+			{Name: agentconfig.ContainerName},
+			{Name: "echo"},
+			{Name: "logger"},
+		}},
+	}
+	name, err := execContainer(pod, "")
+	require.NoError(t, err)
+	assert.Equal(t, "echo", name)
+
+	name, err = execContainer(pod, "logger")
+	require.NoError(t, err)
+	assert.Equal(t, "logger", name)
+
+	_, err = execContainer(pod, "nope")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	pod.Spec.Containers = pod.Spec.Containers[:1]
+	_, err = execContainer(pod, "")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestAuthorizeExec(t *testing.T) {
+	ki := fake.NewSimpleClientset()
+	var reviewed *auth.SubjectAccessReview
+	ki.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		reviewed = action.(k8stesting.CreateAction).GetObject().(*auth.SubjectAccessReview)
+		sar := reviewed.DeepCopy()
+		for _, g := range sar.Spec.Groups {
+			if g == "developers" {
+				sar.Status.Allowed = true
+			}
+		}
+		return true, sar, nil
+	})
+	ctx := k8sapi.WithK8sInterface(dlog.NewTestContext(t, false), ki)
+	pod := &core.Pod{ObjectMeta: meta.ObjectMeta{Name: "echo-abc", Namespace: "dev"}}
+
+	require.NoError(t, authorizeExec(ctx, &rpc.UserIdentity{Username: "alice", Groups: []string{"developers"}}, pod, "echo"))
+	assert.Equal(t, "alice", reviewed.Spec.User)
+	assert.Equal(t, &auth.ResourceAttributes{
+		Namespace:   "dev",
+		Verb:        "create",
+		Resource:    "pods",
+		Subresource: "exec",
+		Name:        "echo-abc",
+	}, reviewed.Spec.ResourceAttributes)
+
+	err := authorizeExec(ctx, &rpc.UserIdentity{Username: "bob", Groups: []string{"testers"}}, pod, "echo")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	reviewed = nil
+	err = authorizeExec(ctx, nil, pod, "echo")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Nil(t, reviewed, "an unauthenticated user must not be reviewed")
+}
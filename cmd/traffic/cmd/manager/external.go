@@ -0,0 +1,99 @@
+package manager
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/datawire/dlib/dhttp"
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+)
+
+// serveExternal serves gRPC on the external port, which is exposed by a LoadBalancer or an Ingress, so
+// that clients can connect without a port-forward through the Kubernetes API server. TLS is terminated
+// by the LoadBalancer or Ingress. The clients must present the configured token as a bearer token. The
+// endpoint refuses to start when no token has been configured.
+func (s *service) serveExternal(ctx context.Context) error {
+	env := managerutil.GetEnv(ctx)
+	if env.ExternalPort == 0 {
+		return nil
+	}
+	if env.ExternalToken == "" {
+		return errors.New("the external endpoint requires a token, but EXTERNAL_TOKEN is not set")
+	}
+	auth := tokenAuth(env.ExternalToken)
+	opts := append(grpcServerOptions(env),
+		grpc.ChainUnaryInterceptor(auth.unaryInterceptor),
+		grpc.ChainStreamInterceptor(auth.streamInterceptor),
+	)
+	grpcHandler := grpc.NewServer(opts...)
+	sc := &dhttp.ServerConfig{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+				atomic.AddInt32(&s.activeGrpcRequests, 1)
+				grpcHandler.ServeHTTP(w, r)
+				atomic.AddInt32(&s.activeGrpcRequests, -1)
+			} else {
+				http.NotFound(w, r)
+			}
+		}),
+	}
+	s.self.RegisterServers(grpcHandler)
+	dlog.Infof(ctx, "External endpoint started on port: %d", env.ExternalPort)
+	defer dlog.Info(ctx, "External endpoint stopped")
+	return sc.ListenAndServe(ctx, fmt.Sprintf("%s:%d", env.ServerHost, env.ExternalPort))
+}
+
+// tokenAuth rejects calls that don't present the token as a bearer token. All calls are rejected when
+// the token is empty.
+type tokenAuth string
+
+func (t tokenAuth) authorize(ctx context.Context) error {
+	if md, ok := metadata.FromIncomingContext(ctx); ok && t != "" {
+		for _, a := range md.Get("authorization") {
+			if strings.HasPrefix(a, "Bearer ") && subtle.ConstantTimeCompare([]byte(a[7:]), []byte(t)) == 1 {
+				return nil
+			}
+		}
+	}
+	return status.Error(codes.Unauthenticated, "a valid bearer token is required")
+}
+
+func (t tokenAuth) unaryInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := t.authorize(ctx); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (t tokenAuth) streamInterceptor(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := t.authorize(ss.Context()); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// grpcServerOptions returns the options that are common to the gRPC servers of the traffic-manager.
+func grpcServerOptions(env *managerutil.Env) []grpc.ServerOption {
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(otelgrpc.UnaryServerInterceptor()),
+		grpc.StreamInterceptor(otelgrpc.StreamServerInterceptor()),
+		client.KeepAliveEnforcement(),
+	}
+	if mz, ok := env.MaxReceiveSize.AsInt64(); ok {
+		opts = append(opts, grpc.MaxRecvMsgSize(int(mz)))
+	}
+	return opts
+}
@@ -0,0 +1,36 @@
+package manager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+)
+
+func TestTokenAuth(t *testing.T) {
+	withAuth := func(v string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", v))
+	}
+
+	auth := tokenAuth("s3cret")
+	assert.NoError(t, auth.authorize(withAuth("Bearer s3cret")))
+	assert.Equal(t, codes.Unauthenticated, status.Code(auth.authorize(withAuth("Bearer wrong"))))
+	assert.Equal(t, codes.Unauthenticated, status.Code(auth.authorize(withAuth("s3cret"))))
+	assert.Equal(t, codes.Unauthenticated, status.Code(auth.authorize(context.Background())))
+
+	// No token means that all calls are rejected
+	assert.Equal(t, codes.Unauthenticated, status.Code(tokenAuth("").authorize(withAuth("Bearer "))))
+	assert.Equal(t, codes.Unauthenticated, status.Code(tokenAuth("").authorize(context.Background())))
+}
+
+func TestServeExternal_requiresToken(t *testing.T) {
+	ctx := managerutil.WithEnv(dlog.NewTestContext(t, false), &managerutil.Env{ExternalPort: 8083})
+	s := &service{}
+	assert.ErrorContains(t, s.serveExternal(ctx), "requires a token")
+}
@@ -0,0 +1,364 @@
+// Package federation lets a traffic-manager federate with traffic-managers in other clusters. The traffic-manager
+// connects to the external endpoint of each peer as an observing client, and then forwards the DNS lookups and
+// tunnels of its own clients that target a peer. A client connected to this traffic-manager will therefore see
+// the subnets and DNS suffixes of all peers as if they were part of one cluster. A client that intercepts a
+// workload in the cluster of a peer does so through a session that the traffic-manager creates with the peer
+// on the client's behalf, so that the peer enforces its own ownership rules and intercept policy.
+package federation
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"github.com/datawire/dlib/dcontext"
+	"github.com/datawire/dlib/dgroup"
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/state"
+	"github.com/telepresenceio/telepresence/v2/pkg/dnsproxy"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
+	"github.com/telepresenceio/telepresence/v2/pkg/version"
+)
+
+const (
+	// remainInterval is the interval between the calls that keep the session with a peer alive.
+	remainInterval = 5 * time.Second
+
+	// retryInterval is the delay before a lost connection to a peer is reestablished.
+	retryInterval = 5 * time.Second
+)
+
+// Networks is called with the subnets of all connected peers, and the DNS suffixes of all peers, whenever
+// the subnets change.
+type Networks func(ctx context.Context, subnets []*net.IPNet, suffixes []string)
+
+// Federation is the set of peers that a traffic-manager federates with.
+type Federation struct {
+	ctx      context.Context
+	state    state.State
+	peers    []*peer
+	networks Networks
+	clientID string
+	tunnels  int32
+
+	// remotes are the sessions that clients of this traffic-manager have with peers, keyed by the
+	// session ID of the client and then by the name of the peer.
+	remotesLock sync.Mutex
+	remotes     map[string]map[string]*remoteSession
+	changed     chan struct{}
+}
+
+type peer struct {
+	managerutil.FederationPeer
+
+	// suffix is the DNS suffix of the peer, with a leading and a trailing dot.
+	suffix string
+
+	sync.RWMutex
+	client  rpc.ManagerClient
+	session *rpc.SessionInfo
+	subnets []*net.IPNet
+}
+
+// New creates the Federation for the peers that are declared in the environment of the traffic-manager, or
+// returns nil when no peers are declared. The given clusterID identifies this traffic-manager to the peers.
+func New(ctx context.Context, clusterID string, st state.State, networks Networks) *Federation {
+	peers := managerutil.GetEnv(ctx).FederationPeers
+	if len(peers) == 0 {
+		return nil
+	}
+	f := &Federation{
+		ctx:      ctx,
+		state:    st,
+		peers:    make([]*peer, len(peers)),
+		networks: networks,
+		clientID: clusterID,
+		remotes:  make(map[string]map[string]*remoteSession),
+		changed:  make(chan struct{}),
+	}
+	for i, p := range peers {
+		f.peers[i] = &peer{FederationPeer: p, suffix: "." + strings.Trim(p.DNSSuffix, ".") + "."}
+	}
+	networks(ctx, nil, f.suffixes())
+	return f
+}
+
+// Run maintains the connections to all peers until the context is cancelled.
+func (f *Federation) Run(ctx context.Context) error {
+	g := dgroup.NewGroup(ctx, dgroup.GroupConfig{})
+	for _, p := range f.peers {
+		p := p
+		g.Go(p.Name, func(ctx context.Context) error {
+			for {
+				if err := f.connect(ctx, p); err != nil && ctx.Err() == nil {
+					dlog.Errorf(ctx, "connection to federation peer %s at %s lost: %v", p.Name, p.Address, err)
+				}
+				f.setPeer(ctx, p, nil, nil)
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(retryInterval):
+				}
+			}
+		})
+	}
+	return g.Wait()
+}
+
+// peerForName returns the peer that is responsible for the given fully qualified name, and the name that
+// the peer knows it by, i.e. the given name without the peer's DNS suffix.
+func (f *Federation) peerForName(name string) (*peer, string) {
+	if f == nil {
+		return nil, ""
+	}
+	lc := strings.ToLower(name)
+	for _, p := range f.peers {
+		if strings.HasSuffix(lc, p.suffix) && len(name) > len(p.suffix) {
+			return p, name[:len(name)-len(p.suffix)+1]
+		}
+	}
+	return nil, ""
+}
+
+// peerForIP returns the peer with a subnet that contains the given IP, or nil if no such peer exists.
+func (f *Federation) peerForIP(ip net.IP) *peer {
+	if f == nil {
+		return nil
+	}
+	for _, p := range f.peers {
+		p.RLock()
+		subnets := p.subnets
+		p.RUnlock()
+		for _, sn := range subnets {
+			if sn.Contains(ip) {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+// LookupDNS forwards the given request to the peer that is responsible for the requested name. The bool is
+// false when no peer is responsible, in which case the request must be answered locally.
+func (f *Federation) LookupDNS(ctx context.Context, request *rpc.DNSRequest) (*rpc.DNSResponse, bool, error) {
+	p, name := f.peerForName(request.Name)
+	if p == nil {
+		return nil, false, nil
+	}
+	client, session := p.current()
+	if client == nil {
+		return nil, true, status.Errorf(codes.Unavailable, "federation peer %s is not connected", p.Name)
+	}
+	rsp, err := client.LookupDNS(ctx, &rpc.DNSRequest{Session: session, Name: name, Type: request.Type})
+	if err != nil {
+		return nil, true, err
+	}
+	rrs, rCode, err := dnsproxy.FromRPC(rsp)
+	if err != nil {
+		return nil, true, err
+	}
+	for _, rr := range rrs {
+		if hdr := rr.Header(); strings.EqualFold(hdr.Name, name) {
+			hdr.Name = request.Name
+		}
+	}
+	dlog.Debugf(ctx, "LookupDNS on federation peer %s: %s %s -> %s", p.Name, name, dns.TypeToString[uint16(request.Type)], rrs)
+	rsp, err = dnsproxy.ToRPC(rrs, rCode)
+	return rsp, true, err
+}
+
+// Tunnel extends the given stream to the peer with a subnet that contains the stream's destination. The bool
+// is false when no such peer exists, in which case the stream must be handled locally.
+func (f *Federation) Tunnel(ctx context.Context, stream tunnel.Stream) (bool, error) {
+	p := f.peerForIP(stream.ID().Destination())
+	if p == nil {
+		return false, nil
+	}
+	client, session := p.current()
+	if client == nil {
+		return true, status.Errorf(codes.Unavailable, "federation peer %s is not connected", p.Name)
+	}
+	ts, err := client.Tunnel(ctx)
+	if err != nil {
+		return true, err
+	}
+	ps, err := tunnel.NewClientStream(ctx, ts, stream.ID(), session.SessionId, stream.RoundtripLatency(), stream.DialTimeout())
+	if err != nil {
+		return true, err
+	}
+	pipe := tunnel.NewBidiPipe(stream, ps, p.Name, &f.tunnels, nil)
+	pipe.Start(ctx)
+	<-pipe.Done()
+	return true, nil
+}
+
+func (f *Federation) suffixes() []string {
+	suffixes := make([]string, len(f.peers))
+	for i, p := range f.peers {
+		suffixes[i] = strings.TrimSuffix(p.suffix, ".")
+	}
+	return suffixes
+}
+
+func (f *Federation) subnets() []*net.IPNet {
+	var subnets []*net.IPNet
+	for _, p := range f.peers {
+		p.RLock()
+		subnets = append(subnets, p.subnets...)
+		p.RUnlock()
+	}
+	sort.Slice(subnets, func(i, j int) bool { return subnets[i].String() < subnets[j].String() })
+	return subnets
+}
+
+func (p *peer) current() (rpc.ManagerClient, *rpc.SessionInfo) {
+	p.RLock()
+	defer p.RUnlock()
+	return p.client, p.session
+}
+
+func (f *Federation) setPeer(ctx context.Context, p *peer, client rpc.ManagerClient, session *rpc.SessionInfo) {
+	p.Lock()
+	p.client = client
+	p.session = session
+	p.subnets = nil
+	p.Unlock()
+	if client == nil {
+		f.networks(ctx, f.subnets(), f.suffixes())
+	}
+}
+
+func (f *Federation) setSubnets(ctx context.Context, p *peer, subnets []*net.IPNet) {
+	p.Lock()
+	p.subnets = subnets
+	p.Unlock()
+	f.networks(ctx, f.subnets(), f.suffixes())
+}
+
+// connect connects to the given peer, arrives as a client, and then keeps the session alive and watches
+// the peer's cluster info until the connection is lost or the context is cancelled.
+func (f *Federation) connect(ctx context.Context, p *peer) error {
+	conn, err := dial(ctx, &p.FederationPeer)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	client := rpc.NewManagerClient(conn)
+	session, err := client.ArriveAsClient(ctx, &rpc.ClientInfo{
+		Name:      "traffic-manager@" + f.clientID,
+		InstallId: f.clientID,
+		Product:   "telepresence",
+		Version:   version.Version,
+		Observe:   true,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = client.Depart(dcontext.WithoutCancel(ctx), session)
+	}()
+	dlog.Infof(ctx, "Federating with peer %s at %s", p.Name, p.Address)
+	f.setPeer(ctx, p, client, session)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ciStream, err := client.WatchClusterInfo(ctx, session)
+	if err != nil {
+		return err
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		defer cancel()
+		for {
+			ci, err := ciStream.Recv()
+			if err != nil {
+				errCh <- err
+				return
+			}
+			f.setSubnets(ctx, p, clusterSubnets(ci))
+		}
+	}()
+
+	ticker := time.NewTicker(remainInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			select {
+			case err = <-errCh:
+				return err
+			default:
+				return nil
+			}
+		case <-ticker.C:
+			if _, err = client.Remain(ctx, &rpc.RemainRequest{Session: session}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// clusterSubnets returns the service subnet and the pod subnets of the given cluster info.
+func clusterSubnets(ci *rpc.ClusterInfo) []*net.IPNet {
+	var subnets []*net.IPNet
+	if ci.ServiceSubnet != nil {
+		subnets = append(subnets, iputil.IPNetFromRPC(ci.ServiceSubnet))
+	}
+	for _, sn := range ci.PodSubnets {
+		subnets = append(subnets, iputil.IPNetFromRPC(sn))
+	}
+	return subnets
+}
+
+// dial dials the external endpoint of the given peer.
+func dial(ctx context.Context, p *managerutil.FederationPeer) (*grpc.ClientConn, error) {
+	opts := []grpc.DialOption{
+		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor()),
+	}
+	if p.Insecure {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})))
+	}
+	if p.TokenFile != "" {
+		if p.Insecure {
+			return nil, fmt.Errorf("the token of peer %s cannot be sent over an insecure connection", p.Name)
+		}
+		token, err := os.ReadFile(p.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.WithPerRPCCredentials(bearerToken(strings.TrimSpace(string(token)))))
+	}
+	return grpc.DialContext(ctx, p.Address, opts...)
+}
+
+// bearerToken is a credentials.PerRPCCredentials that sends a token in the authorization header.
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t bearerToken) RequireTransportSecurity() bool {
+	return true
+}
@@ -0,0 +1,74 @@
+package federation
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+)
+
+func mustParseCIDR(t *testing.T, s string) *net.IPNet {
+	_, sn, err := net.ParseCIDR(s)
+	require.NoError(t, err)
+	return sn
+}
+
+func TestFederation(t *testing.T) {
+	var suffixes []string
+	var subnets []*net.IPNet
+	ctx := managerutil.WithEnv(context.Background(), &managerutil.Env{FederationPeers: []managerutil.FederationPeer{
+		{Name: "eu", Address: "tm.eu.example.com:443", DNSSuffix: "eu"},
+		{Name: "us", Address: "tm.us.example.com:443", DNSSuffix: ".us.example."},
+	}})
+	f := New(ctx, "cluster-id", nil, func(_ context.Context, sns []*net.IPNet, sfs []string) {
+		subnets = sns
+		suffixes = sfs
+	})
+	require.NotNil(t, f)
+	assert.Equal(t, []string{".eu", ".us.example"}, suffixes)
+	assert.Empty(t, subnets)
+
+	p, name := f.peerForName("echo.default.eu.")
+	require.NotNil(t, p)
+	assert.Equal(t, "eu", p.Name)
+	assert.Equal(t, "echo.default.", name)
+
+	p, name = f.peerForName("Echo.Default.US.Example.")
+	require.NotNil(t, p)
+	assert.Equal(t, "us", p.Name)
+	assert.Equal(t, "Echo.Default.", name)
+
+	p, _ = f.peerForName("echo.default.svc.cluster.local.")
+	assert.Nil(t, p)
+	p, _ = f.peerForName("eu.")
+	assert.Nil(t, p)
+	p, _ = f.peerForName("echo.neu.")
+	assert.Nil(t, p)
+
+	eu := f.peers[0]
+	f.setSubnets(ctx, eu, []*net.IPNet{mustParseCIDR(t, "10.8.0.0/16"), mustParseCIDR(t, "10.4.0.0/16")})
+	assert.Equal(t, []*net.IPNet{mustParseCIDR(t, "10.4.0.0/16"), mustParseCIDR(t, "10.8.0.0/16")}, subnets)
+	assert.Equal(t, eu, f.peerForIP(net.ParseIP("10.8.1.2")))
+	assert.Nil(t, f.peerForIP(net.ParseIP("10.9.1.2")))
+
+	// A peer that is disconnected no longer contributes subnets, but retains its suffix.
+	f.setPeer(ctx, eu, nil, nil)
+	assert.Empty(t, subnets)
+	assert.Equal(t, []string{".eu", ".us.example"}, suffixes)
+	assert.Nil(t, f.peerForIP(net.ParseIP("10.8.1.2")))
+}
+
+func TestNoFederation(t *testing.T) {
+	ctx := managerutil.WithEnv(context.Background(), &managerutil.Env{})
+	f := New(ctx, "cluster-id", nil, func(context.Context, []*net.IPNet, []string) {
+		t.Fatal("networks must not be called when there are no peers")
+	})
+	assert.Nil(t, f)
+	p, _ := f.peerForName("echo.default.eu.")
+	assert.Nil(t, p)
+	assert.Nil(t, f.peerForIP(net.ParseIP("10.8.1.2")))
+}
@@ -0,0 +1,321 @@
+package federation
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/datawire/dlib/dcontext"
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
+)
+
+// remoteSession is a session that the traffic-manager has with a peer on behalf of one of its own clients, so
+// that the client can intercept workloads in the peer's cluster. The peer knows the session by the identity of
+// the client, and enforces its own ownership rules and intercept policy.
+type remoteSession struct {
+	peer            *peer
+	clientSessionID string
+	client          rpc.ManagerClient
+	session         *rpc.SessionInfo
+	cancel          context.CancelFunc
+
+	// intercepts is the last snapshot of the intercepts of this session, as seen by the client.
+	intercepts []*rpc.InterceptInfo
+}
+
+func errNoPeer(cluster string) error {
+	return status.Errorf(codes.NotFound, "the traffic-manager doesn't federate with a cluster named %q", cluster)
+}
+
+func (f *Federation) peerByName(name string) *peer {
+	for _, p := range f.peers {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// remoteSession returns the session that the given client has with the peer of the given cluster. The
+// session is created if it doesn't exist.
+func (f *Federation) remoteSession(ctx context.Context, clientSessionID, cluster string) (*remoteSession, error) {
+	if f == nil {
+		return nil, errNoPeer(cluster)
+	}
+	p := f.peerByName(cluster)
+	if p == nil {
+		return nil, errNoPeer(cluster)
+	}
+
+	f.remotesLock.Lock()
+	defer f.remotesLock.Unlock()
+	if rs, ok := f.remotes[clientSessionID][cluster]; ok {
+		return rs, nil
+	}
+	client, _ := p.current()
+	if client == nil {
+		return nil, status.Errorf(codes.Unavailable, "federation peer %s is not connected", p.Name)
+	}
+	ci := f.state.GetClient(clientSessionID)
+	if ci == nil {
+		return nil, status.Errorf(codes.NotFound, "Client session %q not found", clientSessionID)
+	}
+	sessionDone, err := f.state.SessionDone(clientSessionID)
+	if err != nil {
+		return nil, err
+	}
+	session, err := client.ArriveAsClient(ctx, proto.Clone(ci).(*rpc.ClientInfo))
+	if err != nil {
+		return nil, err
+	}
+	dlog.Infof(ctx, "Client session %s arrived at federation peer %s as session %s", clientSessionID, p.Name, session.SessionId)
+	rs := &remoteSession{
+		peer:            p,
+		clientSessionID: clientSessionID,
+		client:          client,
+		session:         session,
+	}
+	rctx, cancel := context.WithCancel(f.ctx)
+	rs.cancel = cancel
+	sessions, ok := f.remotes[clientSessionID]
+	if !ok {
+		sessions = make(map[string]*remoteSession)
+		f.remotes[clientSessionID] = sessions
+	}
+	sessions[cluster] = rs
+	go f.runRemoteSession(rctx, rs, sessionDone)
+	return rs, nil
+}
+
+// runRemoteSession keeps the given session alive, extends the dials that the peer requests to the client, and
+// tracks the intercepts of the session, until the client's session ends or the peer is lost.
+func (f *Federation) runRemoteSession(ctx context.Context, rs *remoteSession, clientDone <-chan struct{}) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		f.removeRemoteSession(rs)
+		dctx, dcancel := context.WithTimeout(dcontext.WithoutCancel(ctx), 5*time.Second)
+		defer dcancel()
+		if _, err := rs.client.Depart(dctx, rs.session); err != nil {
+			dlog.Debugf(ctx, "failed to depart from federation peer %s: %v", rs.peer.Name, err)
+		}
+	}()
+
+	go func() {
+		defer cancel()
+		if err := f.remoteDials(ctx, rs); err != nil && ctx.Err() == nil {
+			dlog.Errorf(ctx, "dial requests from federation peer %s failed: %v", rs.peer.Name, err)
+		}
+	}()
+	go func() {
+		defer cancel()
+		if err := f.remoteIntercepts(ctx, rs); err != nil && ctx.Err() == nil {
+			dlog.Errorf(ctx, "intercepts from federation peer %s failed: %v", rs.peer.Name, err)
+		}
+	}()
+
+	ticker := time.NewTicker(remainInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-clientDone:
+			return
+		case <-ticker.C:
+			if _, err := rs.client.Remain(ctx, &rpc.RemainRequest{Session: rs.session}); err != nil {
+				if ctx.Err() == nil {
+					dlog.Errorf(ctx, "session with federation peer %s lost: %v", rs.peer.Name, err)
+				}
+				return
+			}
+		}
+	}
+}
+
+// remoteDials responds to the dial requests that the peer sends when one of its traffic-agents wants to reach
+// the client, by extending a tunnel to the peer to the client.
+func (f *Federation) remoteDials(ctx context.Context, rs *remoteSession) error {
+	dials, err := rs.client.WatchDial(ctx, rs.session)
+	if err != nil {
+		return err
+	}
+	for {
+		dr, err := dials.Recv()
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := f.dialClient(ctx, rs, dr); err != nil {
+				dlog.Errorf(ctx, "!! CONN %s, unable to extend tunnel from federation peer %s: %v", tunnel.ConnID(dr.ConnId), rs.peer.Name, err)
+			}
+		}()
+	}
+}
+
+func (f *Federation) dialClient(ctx context.Context, rs *remoteSession, dr *rpc.DialRequest) error {
+	ss := f.state.GetSession(rs.clientSessionID)
+	if ss == nil {
+		return status.Errorf(codes.NotFound, "Client session %q not found", rs.clientSessionID)
+	}
+	ts, err := rs.client.Tunnel(ctx)
+	if err != nil {
+		return err
+	}
+	ps, err := tunnel.NewClientStream(ctx, ts, tunnel.ConnID(dr.ConnId), rs.session.SessionId,
+		time.Duration(dr.RoundtripLatency), time.Duration(dr.DialTimeout))
+	if err != nil {
+		return err
+	}
+	ep, err := ss.EstablishBidiPipe(ctx, ps)
+	if err != nil {
+		return err
+	}
+	<-ep.Done()
+	return nil
+}
+
+// remoteIntercepts tracks the intercepts of the given session.
+func (f *Federation) remoteIntercepts(ctx context.Context, rs *remoteSession) error {
+	snapshots, err := rs.client.WatchIntercepts(ctx, rs.session)
+	if err != nil {
+		return err
+	}
+	for {
+		snapshot, err := snapshots.Recv()
+		if err != nil {
+			return err
+		}
+		iis := make([]*rpc.InterceptInfo, len(snapshot.Intercepts))
+		for i, ii := range snapshot.Intercepts {
+			iis[i] = rs.localInfo(ii)
+		}
+		f.remotesLock.Lock()
+		rs.intercepts = iis
+		f.notifyLocked()
+		f.remotesLock.Unlock()
+	}
+}
+
+func (f *Federation) removeRemoteSession(rs *remoteSession) {
+	f.remotesLock.Lock()
+	defer f.remotesLock.Unlock()
+	if sessions, ok := f.remotes[rs.clientSessionID]; ok && sessions[rs.peer.Name] == rs {
+		delete(sessions, rs.peer.Name)
+		if len(sessions) == 0 {
+			delete(f.remotes, rs.clientSessionID)
+		}
+		if len(rs.intercepts) > 0 {
+			f.notifyLocked()
+		}
+	}
+}
+
+func (f *Federation) notifyLocked() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// localInfo returns the given intercept of the peer as it's presented to the client.
+func (rs *remoteSession) localInfo(ii *rpc.InterceptInfo) *rpc.InterceptInfo {
+	ii.ClientSession = &rpc.SessionInfo{SessionId: rs.clientSessionID}
+	ii.Spec.Cluster = rs.peer.Name
+	return ii
+}
+
+// remoteRequest returns the given request as it's presented to the peer.
+func (rs *remoteSession) remoteRequest(rq *rpc.CreateInterceptRequest) *rpc.CreateInterceptRequest {
+	rq = proto.Clone(rq).(*rpc.CreateInterceptRequest)
+	rq.Session = rs.session
+	rq.InterceptSpec.Cluster = ""
+	return rq
+}
+
+// InterceptsChanged returns a channel that is closed when the intercepts that the clients of this
+// traffic-manager have in federated clusters change.
+func (f *Federation) InterceptsChanged() <-chan struct{} {
+	if f == nil {
+		return nil
+	}
+	f.remotesLock.Lock()
+	defer f.remotesLock.Unlock()
+	return f.changed
+}
+
+// Intercepts returns the intercepts that the given client has in federated clusters.
+func (f *Federation) Intercepts(clientSessionID string) []*rpc.InterceptInfo {
+	if f == nil {
+		return nil
+	}
+	f.remotesLock.Lock()
+	defer f.remotesLock.Unlock()
+	var iis []*rpc.InterceptInfo
+	for _, rs := range f.remotes[clientSessionID] {
+		iis = append(iis, rs.intercepts...)
+	}
+	sort.Slice(iis, func(i, j int) bool { return iis[i].Id < iis[j].Id })
+	return iis
+}
+
+// GetIntercept returns the intercept with the given name that the given client has in a federated cluster,
+// or nil if no such intercept exists.
+func (f *Federation) GetIntercept(clientSessionID, name string) *rpc.InterceptInfo {
+	for _, ii := range f.Intercepts(clientSessionID) {
+		if ii.Spec.Name == name {
+			return ii
+		}
+	}
+	return nil
+}
+
+// PrepareIntercept prepares the given intercept using the peer of the cluster that the intercept targets.
+func (f *Federation) PrepareIntercept(ctx context.Context, rq *rpc.CreateInterceptRequest) (*rpc.PreparedIntercept, error) {
+	rs, err := f.remoteSession(ctx, rq.GetSession().GetSessionId(), rq.InterceptSpec.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	return rs.client.PrepareIntercept(ctx, rs.remoteRequest(rq))
+}
+
+// CreateIntercept creates the given intercept using the peer of the cluster that the intercept targets.
+func (f *Federation) CreateIntercept(ctx context.Context, rq *rpc.CreateInterceptRequest) (*rpc.InterceptInfo, error) {
+	rs, err := f.remoteSession(ctx, rq.GetSession().GetSessionId(), rq.InterceptSpec.Cluster)
+	if err != nil {
+		return nil, err
+	}
+	ii, err := rs.client.CreateIntercept(ctx, rs.remoteRequest(rq))
+	if err != nil {
+		return nil, err
+	}
+	return rs.localInfo(ii), nil
+}
+
+// RemoveIntercept removes the intercept with the given name that the given client has in a federated cluster.
+// The bool is false when no such intercept exists.
+func (f *Federation) RemoveIntercept(ctx context.Context, clientSessionID, name string) (bool, error) {
+	if f == nil {
+		return false, nil
+	}
+	f.remotesLock.Lock()
+	rss := make([]*remoteSession, 0, len(f.remotes[clientSessionID]))
+	for _, rs := range f.remotes[clientSessionID] {
+		rss = append(rss, rs)
+	}
+	f.remotesLock.Unlock()
+	for _, rs := range rss {
+		_, err := rs.client.RemoveIntercept(ctx, &rpc.RemoveInterceptRequest2{Session: rs.session, Name: name})
+		if err == nil {
+			return true, nil
+		}
+		if status.Code(err) != codes.NotFound {
+			return true, err
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,233 @@
+package manager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/datawire/dlib/dgroup"
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/dlib/dtime"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/state"
+)
+
+var interceptGVR = schema.GroupVersionResource{ //nolint:gochecknoglobals // constant
+	Group:    "telepresence.io",
+	Version:  "v1alpha1",
+	Resource: "intercepts",
+}
+
+const (
+	interceptIDAnnotation = "telepresence.io/intercept-id"
+	managedByLabel        = "app.kubernetes.io/managed-by"
+	managedByValue        = "traffic-manager"
+)
+
+// interceptResources mirrors the intercepts of the state as Intercept resources, and removes the intercepts
+// whose resources are deleted by someone else.
+type interceptResources struct {
+	dyn        dynamic.Interface
+	state      state.State
+	namespaces []string
+
+	mu sync.Mutex
+	// current are the resources that were last created or updated, keyed by intercept ID.
+	current map[string]*unstructured.Unstructured
+}
+
+// runInterceptResources keeps Intercept resources in sync with the intercepts of the given state. The resources
+// are created in the namespace of each intercept. The given namespaces are the namespaces that the traffic-manager
+// manages, or empty when it manages all namespaces.
+func runInterceptResources(ctx context.Context, dyn dynamic.Interface, st state.State, namespaces []string) error {
+	if len(namespaces) == 0 {
+		namespaces = []string{meta.NamespaceAll}
+	}
+	ir := &interceptResources{
+		dyn:        dyn,
+		state:      st,
+		namespaces: namespaces,
+		current:    make(map[string]*unstructured.Unstructured),
+	}
+	ir.loadExisting(ctx)
+	g := dgroup.NewGroup(ctx, dgroup.GroupConfig{})
+	g.Go("sync", ir.syncLoop)
+	for _, ns := range namespaces {
+		ns := ns
+		g.Go("watch-"+ns, func(ctx context.Context) error {
+			ir.watchLoop(ctx, ns)
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// loadExisting loads the resources that were created by a previous traffic-manager, so that resources without a
+// corresponding intercept are deleted by the first sync.
+func (ir *interceptResources) loadExisting(ctx context.Context) {
+	for _, ns := range ir.namespaces {
+		ul, err := ir.dyn.Resource(interceptGVR).Namespace(ns).List(ctx, meta.ListOptions{LabelSelector: managedByLabel + "=" + managedByValue})
+		if err != nil {
+			dlog.Errorf(ctx, "unable to list Intercept resources: %v", err)
+			continue
+		}
+		for i := range ul.Items {
+			u := &ul.Items[i]
+			if id := u.GetAnnotations()[interceptIDAnnotation]; id != "" {
+				ir.current[id] = u
+			}
+		}
+	}
+}
+
+func (ir *interceptResources) syncLoop(ctx context.Context) error {
+	for snapshot := range ir.state.WatchIntercepts(ctx, nil) {
+		ir.sync(ctx, snapshot.State)
+	}
+	return nil
+}
+
+// sync creates, updates, and deletes Intercept resources so that they match the given intercepts.
+func (ir *interceptResources) sync(ctx context.Context, intercepts map[string]*rpc.InterceptInfo) {
+	for id, ii := range intercepts {
+		desired := interceptResource(ii)
+		ir.mu.Lock()
+		cur, ok := ir.current[id]
+		ir.mu.Unlock()
+		var err error
+		api := ir.dyn.Resource(interceptGVR).Namespace(desired.GetNamespace())
+		switch {
+		case !ok:
+			cur, err = api.Create(ctx, desired, meta.CreateOptions{})
+		case !reflect.DeepEqual(cur.Object["spec"], desired.Object["spec"]) || !reflect.DeepEqual(cur.Object["status"], desired.Object["status"]):
+			desired.SetResourceVersion(cur.GetResourceVersion())
+			cur, err = api.Update(ctx, desired, meta.UpdateOptions{})
+		default:
+			continue
+		}
+		if err != nil {
+			dlog.Errorf(ctx, "unable to apply Intercept resource %s.%s: %v", desired.GetName(), desired.GetNamespace(), err)
+			continue
+		}
+		ir.mu.Lock()
+		ir.current[id] = cur
+		ir.mu.Unlock()
+	}
+
+	ir.mu.Lock()
+	var gone []*unstructured.Unstructured
+	for id, cur := range ir.current {
+		if _, ok := intercepts[id]; !ok {
+			// Removed before the resource is deleted, so that the watcher ignores the deletion.
+			delete(ir.current, id)
+			gone = append(gone, cur)
+		}
+	}
+	ir.mu.Unlock()
+	for _, u := range gone {
+		err := ir.dyn.Resource(interceptGVR).Namespace(u.GetNamespace()).Delete(ctx, u.GetName(), meta.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			dlog.Errorf(ctx, "unable to delete Intercept resource %s.%s: %v", u.GetName(), u.GetNamespace(), err)
+		}
+	}
+}
+
+// watchLoop watches the Intercept resources in the given namespace and removes the intercepts of those
+// that are deleted by someone other than this traffic-manager.
+func (ir *interceptResources) watchLoop(ctx context.Context, namespace string) {
+	backoff := time.Second
+	for ctx.Err() == nil {
+		w, err := ir.dyn.Resource(interceptGVR).Namespace(namespace).Watch(ctx, meta.ListOptions{LabelSelector: managedByLabel + "=" + managedByValue})
+		if err != nil {
+			dlog.Errorf(ctx, "unable to watch Intercept resources: %v", err)
+			dtime.SleepWithContext(ctx, backoff)
+			if backoff *= 2; backoff > time.Minute {
+				backoff = time.Minute
+			}
+			continue
+		}
+		backoff = time.Second
+		for ev := range w.ResultChan() {
+			if u, ok := ev.Object.(*unstructured.Unstructured); ok && ev.Type == watch.Deleted {
+				ir.deleted(ctx, u)
+			}
+		}
+		w.Stop()
+	}
+}
+
+// deleted removes the intercept of a resource that was deleted by someone other than this traffic-manager.
+func (ir *interceptResources) deleted(ctx context.Context, u *unstructured.Unstructured) {
+	id := u.GetAnnotations()[interceptIDAnnotation]
+	ir.mu.Lock()
+	cur, ok := ir.current[id]
+	ok = ok && cur.GetUID() == u.GetUID()
+	if ok {
+		delete(ir.current, id)
+	}
+	ir.mu.Unlock()
+	if ok && ir.state.RemoveIntercept(id) {
+		dlog.Infof(ctx, "Intercept %s removed. Its Intercept resource %s.%s was deleted", id, u.GetName(), u.GetNamespace())
+	}
+}
+
+// interceptResource returns the Intercept resource that represents the given intercept.
+func interceptResource(ii *rpc.InterceptInfo) *unstructured.Unstructured {
+	spec := ii.Spec
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": interceptGVR.GroupVersion().String(),
+		"kind":       "Intercept",
+		"metadata": map[string]any{
+			"name":        interceptResourceName(ii),
+			"namespace":   spec.Namespace,
+			"labels":      map[string]any{managedByLabel: managedByValue},
+			"annotations": map[string]any{interceptIDAnnotation: ii.Id},
+		},
+		"spec": map[string]any{
+			"id":             ii.Id,
+			"name":           spec.Name,
+			"workload":       spec.Agent,
+			"workloadKind":   spec.WorkloadKind,
+			"client":         spec.Client,
+			"sessionID":      ii.ClientSession.GetSessionId(),
+			"mechanism":      spec.Mechanism,
+			"portIdentifier": spec.ServicePortIdentifier,
+			"targetHost":     spec.TargetHost,
+			"targetPort":     int64(spec.TargetPort),
+		},
+		"status": map[string]any{
+			"disposition": ii.Disposition.String(),
+			"message":     ii.Message,
+			"podIP":       ii.PodIp,
+		},
+	}}
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`) //nolint:gochecknoglobals // constant
+
+// interceptResourceName returns a valid resource name that is derived from the intercept name and unique for
+// the intercept ID. Intercept IDs contain a colon, so they can't be used as is.
+func interceptResourceName(ii *rpc.InterceptInfo) string {
+	name := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(ii.Spec.Name), "-"), "-")
+	if len(name) > 53 {
+		name = strings.TrimRight(name[:53], "-")
+	}
+	sum := sha256.Sum256([]byte(ii.Id))
+	suffix := hex.EncodeToString(sum[:])[:8]
+	if name == "" {
+		return "intercept-" + suffix
+	}
+	return name + "-" + suffix
+}
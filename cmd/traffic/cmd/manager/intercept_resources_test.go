@@ -0,0 +1,83 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/state"
+)
+
+func TestInterceptResources(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	st := state.NewState(ctx)
+	clientID := st.AddClient(&rpc.ClientInfo{Name: "my-client", Namespace: "default", InstallId: "1234"}, time.Now())
+	cept, err := st.AddIntercept(clientID, "cluster-1", st.GetClient(clientID), &rpc.CreateInterceptRequest{
+		InterceptSpec: &rpc.InterceptSpec{Name: "Hello_World", Agent: "hello", Namespace: "default", Mechanism: "tcp", TargetPort: 8080},
+	})
+	require.NoError(t, err)
+
+	dyn := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		interceptGVR: "InterceptList",
+	})
+	ir := &interceptResources{
+		dyn:        dyn,
+		state:      st,
+		namespaces: []string{meta.NamespaceAll},
+		current:    make(map[string]*unstructured.Unstructured),
+	}
+	api := dyn.Resource(interceptGVR).Namespace("default")
+
+	// Creates the resource
+	ir.sync(ctx, map[string]*rpc.InterceptInfo{cept.Id: cept})
+	ul, err := api.List(ctx, meta.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, ul.Items, 1)
+	u := ul.Items[0]
+	assert.Regexp(t, `^hello-world-[0-9a-f]{8}$`, u.GetName())
+	assert.Equal(t, cept.Id, u.GetAnnotations()[interceptIDAnnotation])
+	port, _, _ := unstructured.NestedInt64(u.Object, "spec", "targetPort")
+	assert.Equal(t, int64(8080), port)
+	disposition, _, _ := unstructured.NestedString(u.Object, "status", "disposition")
+	assert.Equal(t, cept.Disposition.String(), disposition)
+
+	// Updates the resource
+	cept.Disposition = rpc.InterceptDispositionType_ACTIVE
+	ir.sync(ctx, map[string]*rpc.InterceptInfo{cept.Id: cept})
+	got, err := api.Get(ctx, u.GetName(), meta.GetOptions{})
+	require.NoError(t, err)
+	disposition, _, _ = unstructured.NestedString(got.Object, "status", "disposition")
+	assert.Equal(t, rpc.InterceptDispositionType_ACTIVE.String(), disposition)
+
+	// A deleted resource removes the intercept
+	ir.deleted(ctx, got)
+	_, ok := st.GetIntercept(cept.Id)
+	assert.False(t, ok)
+
+	// Deletes the resource of an intercept that is gone
+	ir.current[cept.Id] = got
+	ir.sync(ctx, nil)
+	ul, err = api.List(ctx, meta.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, ul.Items)
+	assert.Empty(t, ir.current)
+}
+
+func TestInterceptResourceName(t *testing.T) {
+	name := func(n string) string {
+		return interceptResourceName(&rpc.InterceptInfo{Id: "session:" + n, Spec: &rpc.InterceptSpec{Name: n}})
+	}
+	assert.Regexp(t, `^my-intercept-[0-9a-f]{8}$`, name("My.Intercept"))
+	assert.Regexp(t, `^intercept-[0-9a-f]{8}$`, name("--"))
+	assert.LessOrEqual(t, len(name("a-very-long-intercept-name-that-exceeds-the-limit-of-resource-names")), 63)
+	assert.NotEqual(t, name("a"), name("b"))
+}
@@ -12,10 +12,10 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
@@ -78,11 +78,22 @@ func MainWithEnv(ctx context.Context) error {
 		return fmt.Errorf("unable to create the Kubernetes Interface from InClusterConfig: %w", err)
 	}
 	ctx = k8sapi.WithK8sInterface(ctx, ki)
+	ctx = managerutil.WithRestConfig(ctx, cfg)
 
 	mgr, ctx, err := NewServiceFunc(ctx)
 	if err != nil {
 		return fmt.Errorf("unable to initialize traffic manager: %w", err)
 	}
+
+	// Sessions must be restored before the gRPC server starts, or clients that call Remain would be
+	// told that their sessions are gone.
+	store, err := newSessionStore(ctx, env.SessionStore)
+	if err != nil {
+		return err
+	}
+	if store != nil {
+		restoreSessions(ctx, mgr.State(), store)
+	}
 	ctx, imgRetErr := WithAgentImageRetrieverFunc(ctx, mutator.RegenerateAgentMaps)
 
 	g := dgroup.NewGroup(ctx, dgroup.GroupConfig{
@@ -95,8 +106,16 @@ func MainWithEnv(ctx context.Context) error {
 	// Serve HTTP (including gRPC)
 	g.Go("httpd", mgr.serveHTTP)
 
+	if env.ExternalPort != 0 {
+		g.Go("external", mgr.serveExternal)
+	}
+
 	g.Go("prometheus", mgr.servePrometheus)
 
+	if len(env.FederationPeers) > 0 {
+		g.Go("federation", mgr.runFederation)
+	}
+
 	if imgRetErr != nil {
 		dlog.Errorf(ctx, "unable to initialize agent injector: %v", imgRetErr)
 	} else {
@@ -105,6 +124,26 @@ func MainWithEnv(ctx context.Context) error {
 
 	g.Go("session-gc", mgr.runSessionGCLoop)
 
+	if store != nil {
+		g.Go("session-store", func(ctx context.Context) error {
+			return runSessionStoreLoop(ctx, mgr.State(), store)
+		})
+	}
+
+	if env.EphemeralNamespacesEnabled {
+		g.Go("namespace-ttl", runNamespaceTTLLoop)
+	}
+
+	if env.InterceptResourcesEnabled {
+		dyn, err := dynamic.NewForConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("unable to create the Kubernetes dynamic Interface from InClusterConfig: %w", err)
+		}
+		g.Go("intercept-resources", func(ctx context.Context) error {
+			return runInterceptResources(ctx, dyn, mgr.State(), env.ManagedNamespaces)
+		})
+	}
+
 	if tracer != nil {
 		g.Go("tracer-grpc", func(c context.Context) error {
 			return tracer.ServeGrpc(c, env.TracingGrpcPort)
@@ -154,15 +193,7 @@ func (s *service) serveHTTP(ctx context.Context) error {
 	env := managerutil.GetEnv(ctx)
 	host := env.ServerHost
 	port := env.ServerPort
-	opts := []grpc.ServerOption{
-		grpc.UnaryInterceptor(otelgrpc.UnaryServerInterceptor()),
-		grpc.StreamInterceptor(otelgrpc.StreamServerInterceptor()),
-	}
-	if mz, ok := env.MaxReceiveSize.AsInt64(); ok {
-		opts = append(opts, grpc.MaxRecvMsgSize(int(mz)))
-	}
-
-	grpcHandler := grpc.NewServer(opts...)
+	grpcHandler := grpc.NewServer(grpcServerOptions(env)...)
 	httpHandler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "Hello World from: %s\n", r.URL.Path)
 	}))
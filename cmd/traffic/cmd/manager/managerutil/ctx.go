@@ -3,6 +3,8 @@ package managerutil
 import (
 	"context"
 
+	"k8s.io/client-go/rest"
+
 	"github.com/datawire/dlib/dlog"
 	"github.com/telepresenceio/telepresence/rpc/v2/manager"
 )
@@ -29,3 +31,16 @@ func GetSessionID(ctx context.Context) string {
 }
 
 type sessionContextKey struct{}
+
+type restConfigKey struct{}
+
+// WithRestConfig returns a context that carries the given Kubernetes client configuration.
+func WithRestConfig(ctx context.Context, cfg *rest.Config) context.Context {
+	return context.WithValue(ctx, restConfigKey{}, cfg)
+}
+
+// GetRestConfig returns the Kubernetes client configuration of the context, or nil if it has none.
+func GetRestConfig(ctx context.Context) *rest.Config {
+	cfg, _ := ctx.Value(restConfigKey{}).(*rest.Config)
+	return cfg
+}
@@ -3,6 +3,7 @@ package managerutil
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net"
 	"reflect"
 	"strconv"
@@ -43,21 +44,28 @@ type Env struct {
 	TracingGrpcPort uint16            `env:"TRACING_GRPC_PORT,     parser=port-number,default=0"`
 	MaxReceiveSize  resource.Quantity `env:"GRPC_MAX_RECEIVE_SIZE, parser=quantity"`
 
+	ExternalPort  uint16 `env:"EXTERNAL_PORT,  parser=port-number, default=0"`
+	ExternalToken string `env:"EXTERNAL_TOKEN, parser=string,      default="`
+
 	PodCIDRStrategy string       `env:"POD_CIDR_STRATEGY, parser=nonempty-string"`
 	PodCIDRs        []*net.IPNet `env:"POD_CIDRS,         parser=split-ipnet, default="`
 	PodIP           net.IP       `env:"POD_IP,            parser=ip"`
 
-	AgentRegistry            string                      `env:"AGENT_REGISTRY,           parser=nonempty-string"`
-	AgentImage               string                      `env:"AGENT_IMAGE,              parser=string,         default="`
-	AgentImagePullPolicy     string                      `env:"AGENT_IMAGE_PULL_POLICY,  parser=string,         default="`
-	AgentImagePullSecrets    []core.LocalObjectReference `env:"AGENT_IMAGE_PULL_SECRETS, parser=json-local-refs,default="`
-	AgentInjectPolicy        agentconfig.InjectPolicy    `env:"AGENT_INJECT_POLICY,      parser=enable-policy"`
-	AgentAppProtocolStrategy k8sapi.AppProtocolStrategy  `env:"AGENT_APP_PROTO_STRATEGY, parser=app-proto-strategy"`
-	AgentLogLevel            string                      `env:"AGENT_LOG_LEVEL,          parser=logLevel,       defaultFrom=LogLevel"`
-	AgentPort                uint16                      `env:"AGENT_PORT,               parser=port-number"`
-	AgentResources           *core.ResourceRequirements  `env:"AGENT_RESOURCES,          parser=json-resources, default="`
-	AgentInitResources       *core.ResourceRequirements  `env:"AGENT_INIT_RESOURCES,     parser=json-resources, default="`
-	AgentInjectorName        string                      `env:"AGENT_INJECTOR_NAME,      parser=string"`
+	AgentRegistry             string                      `env:"AGENT_REGISTRY,           parser=nonempty-string"`
+	AgentImage                string                      `env:"AGENT_IMAGE,              parser=string,         default="`
+	AgentImageDigest          string                      `env:"AGENT_IMAGE_DIGEST,       parser=string,         default="`
+	AgentImageVerificationKey string                      `env:"AGENT_IMAGE_VERIFICATION_KEY, parser=string,     default="`
+	AgentImagePullPolicy      string                      `env:"AGENT_IMAGE_PULL_POLICY,  parser=string,         default="`
+	AgentImagePullSecrets     []core.LocalObjectReference `env:"AGENT_IMAGE_PULL_SECRETS, parser=json-local-refs,default="`
+	AgentInjectPolicy         agentconfig.InjectPolicy    `env:"AGENT_INJECT_POLICY,      parser=enable-policy"`
+	AgentAppProtocolStrategy  k8sapi.AppProtocolStrategy  `env:"AGENT_APP_PROTO_STRATEGY, parser=app-proto-strategy"`
+	AgentLogLevel             string                      `env:"AGENT_LOG_LEVEL,          parser=logLevel,       defaultFrom=LogLevel"`
+	AgentPort                 uint16                      `env:"AGENT_PORT,               parser=port-number"`
+	AgentResources            *core.ResourceRequirements  `env:"AGENT_RESOURCES,          parser=json-resources, default="`
+	AgentInitResources        *core.ResourceRequirements  `env:"AGENT_INIT_RESOURCES,     parser=json-resources, default="`
+	AgentInjectorName         string                      `env:"AGENT_INJECTOR_NAME,      parser=string"`
+	AgentInjectorServiceMesh  agentconfig.ServiceMesh     `env:"AGENT_INJECTOR_SERVICE_MESH, parser=service-mesh, default="`
+	AgentCompliance           agentconfig.ComplianceMode  `env:"AGENT_COMPLIANCE,         parser=compliance,     default="`
 
 	ClientRoutingAlsoProxySubnets        []*net.IPNet  `env:"CLIENT_ROUTING_ALSO_PROXY_SUBNETS,  		parser=split-ipnet, default="`
 	ClientRoutingNeverProxySubnets       []*net.IPNet  `env:"CLIENT_ROUTING_NEVER_PROXY_SUBNETS, 		parser=split-ipnet, default="`
@@ -65,6 +73,26 @@ type Env struct {
 	ClientDnsExcludeSuffixes             []string      `env:"CLIENT_DNS_EXCLUDE_SUFFIXES,        		parser=split-trim"`
 	ClientDnsIncludeSuffixes             []string      `env:"CLIENT_DNS_INCLUDE_SUFFIXES,       		parser=split-trim,  default="`
 	ClientConnectionTTL                  time.Duration `env:"CLIENT_CONNECTION_TTL,              		parser=time.ParseDuration"`
+
+	EphemeralNamespacesEnabled bool `env:"EPHEMERAL_NAMESPACES_ENABLED, parser=bool, default=false"`
+
+	SessionStore              string `env:"SESSION_STORE,               parser=string, default="`
+	InterceptResourcesEnabled bool   `env:"INTERCEPT_RESOURCES_ENABLED, parser=bool,   default=false"`
+
+	ExecEnabled bool `env:"EXEC_ENABLED, parser=bool, default=false"`
+	ExecRecord  bool `env:"EXEC_RECORD,  parser=bool, default=false"`
+
+	FederationPeers []FederationPeer `env:"FEDERATION_PEERS, parser=json-federation-peers, default="`
+}
+
+// FederationPeer is a traffic-manager in another cluster that this traffic-manager federates with. Clients
+// of this traffic-manager reach the services of the peer using names that end with the DNSSuffix.
+type FederationPeer struct {
+	Name      string `json:"name"`
+	Address   string `json:"address"`
+	DNSSuffix string `json:"dnsSuffix"`
+	Insecure  bool   `json:"insecure,omitempty"`
+	TokenFile string `json:"tokenFile,omitempty"`
 }
 
 func (e *Env) GeneratorConfig(qualifiedAgentImage string) (agentmap.GeneratorConfig, error) {
@@ -80,6 +108,7 @@ func (e *Env) GeneratorConfig(qualifiedAgentImage string) (agentmap.GeneratorCon
 		Resources:           e.AgentResources,
 		PullPolicy:          e.AgentImagePullPolicy,
 		PullSecrets:         e.AgentImagePullSecrets,
+		Compliance:          e.AgentCompliance,
 	}, nil
 }
 
@@ -88,7 +117,11 @@ func (e *Env) QualifiedAgentImage() string {
 	if img == "" {
 		return ""
 	}
-	return e.AgentRegistry + "/" + img
+	img = e.AgentRegistry + "/" + img
+	if e.AgentImageDigest != "" {
+		img += "@" + e.AgentImageDigest
+	}
+	return img
 }
 
 func fieldTypeHandlers() map[reflect.Type]envconfig.FieldTypeHandler {
@@ -123,6 +156,26 @@ func fieldTypeHandlers() map[reflect.Type]envconfig.FieldTypeHandler {
 		},
 		Setter: func(dst reflect.Value, src interface{}) { dst.SetInt(int64(src.(agentconfig.InjectPolicy))) },
 	}
+	fhs[reflect.TypeOf(agentconfig.ServiceMesh(0))] = envconfig.FieldTypeHandler{
+		Parsers: map[string]func(string) (any, error){
+			"service-mesh": func(str string) (any, error) {
+				var sm agentconfig.ServiceMesh
+				err := sm.EnvDecode(str)
+				return sm, err
+			},
+		},
+		Setter: func(dst reflect.Value, src interface{}) { dst.SetInt(int64(src.(agentconfig.ServiceMesh))) },
+	}
+	fhs[reflect.TypeOf(agentconfig.ComplianceMode(0))] = envconfig.FieldTypeHandler{
+		Parsers: map[string]func(string) (any, error){
+			"compliance": func(str string) (any, error) {
+				var cm agentconfig.ComplianceMode
+				err := cm.EnvDecode(str)
+				return cm, err
+			},
+		},
+		Setter: func(dst reflect.Value, src interface{}) { dst.SetInt(int64(src.(agentconfig.ComplianceMode))) },
+	}
 	fhs[reflect.TypeOf(resource.Quantity{})] = envconfig.FieldTypeHandler{
 		Parsers: map[string]func(string) (any, error){
 			"quantity": func(str string) (any, error) {
@@ -188,6 +241,26 @@ func fieldTypeHandlers() map[reflect.Type]envconfig.FieldTypeHandler {
 		},
 		Setter: func(dst reflect.Value, src interface{}) { dst.Set(reflect.ValueOf(src.([]core.LocalObjectReference))) },
 	}
+	fhs[reflect.TypeOf([]FederationPeer{})] = envconfig.FieldTypeHandler{
+		Parsers: map[string]func(string) (any, error){
+			"json-federation-peers": func(js string) (any, error) {
+				if js == "" {
+					return nil, nil
+				}
+				var ps []FederationPeer
+				if err := json.Unmarshal([]byte(js), &ps); err != nil {
+					return nil, err
+				}
+				for _, p := range ps {
+					if p.Name == "" || p.Address == "" || strings.Trim(p.DNSSuffix, ".") == "" {
+						return nil, fmt.Errorf("federation peer %q must have a name, an address, and a dnsSuffix", p.Name)
+					}
+				}
+				return ps, nil
+			},
+		},
+		Setter: func(dst reflect.Value, src interface{}) { dst.Set(reflect.ValueOf(src.([]FederationPeer))) },
+	}
 	fhs[reflect.TypeOf(&core.ResourceRequirements{})] = envconfig.FieldTypeHandler{
 		Parsers: map[string]func(string) (any, error){
 			"json-resources": func(js string) (any, error) {
@@ -79,6 +79,19 @@ func TestEnvconfig(t *testing.T) {
 				e.ClientRoutingNeverProxySubnets = []*net.IPNet{a, b}
 			},
 		},
+		"federation": {
+			Input: map[string]string{
+				"FEDERATION_PEERS": `[{"name":"eu","address":"tm.eu.example.com:443","dnsSuffix":"eu","tokenFile":"/var/run/secrets/federation/eu/token"}]`,
+			},
+			Output: func(e *managerutil.Env) {
+				e.FederationPeers = []managerutil.FederationPeer{{
+					Name:      "eu",
+					Address:   "tm.eu.example.com:443",
+					DNSSuffix: "eu",
+					TokenFile: "/var/run/secrets/federation/eu/token",
+				}}
+			},
+		},
 	}
 
 	for tcName, tc := range testcases {
@@ -25,6 +25,7 @@ import (
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
 	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
 	"github.com/telepresenceio/telepresence/v2/pkg/agentmap"
+	"github.com/telepresenceio/telepresence/v2/pkg/imageverify"
 	"github.com/telepresenceio/telepresence/v2/pkg/install"
 	"github.com/telepresenceio/telepresence/v2/pkg/maps"
 	"github.com/telepresenceio/telepresence/v2/pkg/tracing"
@@ -36,6 +37,9 @@ type agentInjector struct {
 	sync.Mutex
 	agentConfigs Map
 	terminating  int64
+
+	// verifier, when not nil, verifies the signature of the agent image before it is injected.
+	verifier *imageverify.Verifier
 }
 
 func getPod(req *admission.AdmissionRequest, isDelete bool) (*core.Pod, error) {
@@ -180,27 +184,65 @@ func (a *agentInjector) inject(ctx context.Context, req *admission.AdmissionRequ
 	// Create patch operations to add the traffic-agent sidecar
 	dlog.Infof(ctx, "Injecting %s into pod %s.%s", agentconfig.ContainerName, pod.Name, pod.Namespace)
 
-	var patches patchOps
+	mesh, err := resolveServiceMesh(ctx, env.AgentInjectorServiceMesh, pod)
+	if err != nil {
+		return nil, err
+	}
 	config := scx.AgentConfig()
-	patches = addInitContainer(pod, config, patches)
+	if a.verifier != nil {
+		if config, err = a.verifiedAgentConfig(ctx, config); err != nil {
+			return nil, err
+		}
+	}
+	patches := podPatches(ctx, pod, config, mesh)
+	if len(patches) > 0 {
+		dlog.Infof(ctx, "Injecting %d patches into pod %s.%s", len(patches), pod.Name, pod.Namespace)
+		span.SetAttributes(attribute.Stringer("tel2.patches", patches))
+	}
+	return patches, nil
+}
+
+// verifiedAgentConfig verifies the signature of the agent image of the given config, and returns a copy of
+// the config where the image is pinned to the verified digest, so that a tag that is moved after the
+// verification cannot make the kubelet pull an unverified image.
+func (a *agentInjector) verifiedAgentConfig(ctx context.Context, config *agentconfig.Sidecar) (*agentconfig.Sidecar, error) {
+	ref, err := a.verifier.Verify(ctx, config.AgentImage)
+	if err != nil {
+		return nil, err
+	}
+	vc := *config
+	vc.AgentImage = ref.String()
+	return &vc, nil
+}
+
+// podPatches returns the patch operations that inject the traffic-agent described by the given config into the
+// given pod.
+func podPatches(ctx context.Context, pod *core.Pod, config *agentconfig.Sidecar, mesh agentconfig.ServiceMesh) patchOps {
+	var patches patchOps
+	patches = addInitContainer(pod, config, mesh, patches)
 	patches = addAgentContainer(ctx, pod, config, patches)
 	patches = addPullSecrets(pod, config, patches)
 	patches = addAgentVolumes(pod, config, patches)
 	patches = hidePorts(pod, config, patches)
-	patches = addPodAnnotations(ctx, pod, patches)
+	patches = addPodAnnotations(ctx, pod, config, mesh, patches)
 
+	tpEnv := make(map[string]string)
 	if config.APIPort != 0 {
-		tpEnv := make(map[string]string)
 		tpEnv[agentconfig.EnvAPIPort] = strconv.Itoa(int(config.APIPort))
-		patches = addTPEnv(pod, config, tpEnv, patches)
+		tpEnv[agentconfig.EnvAPIURL] = agentconfig.APIURL(int(config.APIPort))
+	}
+	var debugEnv map[string]string
+	if da, ok := pod.Annotations[agentconfig.DebugAnnotation]; ok {
+		if runtime, port, err := agentconfig.ParseDebugAnnotation(da); err != nil {
+			dlog.Errorf(ctx, "pod %s.%s: %v", pod.Name, pod.Namespace, err)
+		} else {
+			debugEnv = agentconfig.DebugEnv(runtime, port)
+		}
 	}
-
-	// Create patch operations to add the traffic-agent sidecar
-	if len(patches) > 0 {
-		dlog.Infof(ctx, "Injecting %d patches into pod %s.%s", len(patches), pod.Name, pod.Namespace)
-		span.SetAttributes(attribute.Stringer("tel2.patches", patches))
+	if len(tpEnv) > 0 || len(debugEnv) > 0 {
+		patches = addTPEnv(pod, config, tpEnv, debugEnv, patches)
 	}
-	return patches, nil
+	return patches
 }
 
 // uninstall ensures that no more webhook injections is made and that all the workloads of currently injected
@@ -216,6 +258,9 @@ func (a *agentInjector) upgradeLegacy(ctx context.Context) {
 }
 
 func needInitContainer(config *agentconfig.Sidecar) bool {
+	if config.Compliance == agentconfig.ComplianceRestricted {
+		return false
+	}
 	for _, cc := range config.Containers {
 		for _, ic := range cc.Intercepts {
 			if ic.Headless || ic.TargetPortNumeric {
@@ -226,7 +271,7 @@ func needInitContainer(config *agentconfig.Sidecar) bool {
 	return false
 }
 
-func addInitContainer(pod *core.Pod, config *agentconfig.Sidecar, patches patchOps) patchOps {
+func addInitContainer(pod *core.Pod, config *agentconfig.Sidecar, mesh agentconfig.ServiceMesh, patches patchOps) patchOps {
 	if !needInitContainer(config) {
 		for i, oc := range pod.Spec.InitContainers {
 			if agentconfig.InitContainerName == oc.Name {
@@ -252,6 +297,19 @@ func addInitContainer(pod *core.Pod, config *agentconfig.Sidecar, patches patchO
 	for i := range pis {
 		oc := &pis[i]
 		if ic.Name == oc.Name {
+			if meshInitAfter(pis, i, mesh) {
+				// The mesh's iptables rules must be in place before ours, so move our init container last.
+				return append(patches,
+					patchOperation{
+						Op:   "remove",
+						Path: fmt.Sprintf("/spec/initContainers/%d", i),
+					},
+					patchOperation{
+						Op:    "add",
+						Path:  "/spec/initContainers/-",
+						Value: ic,
+					})
+			}
 			if ic.Image == oc.Image &&
 				slices.Equal(ic.Args, oc.Args) &&
 				compareVolumeMounts(ic.VolumeMounts, oc.VolumeMounts) &&
@@ -437,14 +495,56 @@ func addPullSecrets(
 	return patches
 }
 
-// addTPEnv adds telepresence specific environment variables to all interceptable app containers.
-func addTPEnv(pod *core.Pod, config *agentconfig.Sidecar, env map[string]string, patches patchOps) patchOps {
+// addTPEnv adds telepresence specific environment variables to all interceptable app containers. The values
+// of the debugEnv are appended to the values of variables that the container already declares.
+func addTPEnv(pod *core.Pod, config *agentconfig.Sidecar, env, debugEnv map[string]string, patches patchOps) patchOps {
 	agentconfig.EachContainer(pod, config, func(app *core.Container, cc *agentconfig.Container) {
-		patches = addContainerTPEnv(pod, app, env, patches)
+		cEnv := make(map[string]string, len(env)+len(debugEnv))
+		for k, v := range env {
+			cEnv[k] = v
+		}
+		patches = addContainerDebugEnv(pod, app, debugEnv, cEnv, patches)
+		patches = addContainerTPEnv(pod, app, cEnv, patches)
 	})
 	return patches
 }
 
+// addContainerDebugEnv appends the values of the debugEnv to the variables that the app container declares,
+// and adds the remaining ones to env.
+func addContainerDebugEnv(pod *core.Pod, cn *core.Container, debugEnv, env map[string]string, patches patchOps) patchOps {
+	for k, v := range debugEnv {
+		found := false
+		for i, e := range cn.Env {
+			if e.Name == k && e.ValueFrom == nil {
+				found = true
+				if !strings.Contains(e.Value, v) {
+					patches = append(patches, patchOperation{
+						Op:    "replace",
+						Path:  fmt.Sprintf("%s/env/%d/value", containerPath(pod, cn), i),
+						Value: strings.TrimSpace(e.Value + " " + v),
+					})
+				}
+				break
+			}
+		}
+		if !found {
+			env[k] = v
+		}
+	}
+	return patches
+}
+
+// containerPath returns the JSON patch path of the given container of the pod.
+func containerPath(pod *core.Pod, cn *core.Container) string {
+	cns := pod.Spec.Containers
+	for i := range cns {
+		if &cns[i] == cn {
+			return fmt.Sprintf("/spec/containers/%d", i)
+		}
+	}
+	return ""
+}
+
 // addContainerTPEnv adds telepresence specific environment variables to the app container.
 func addContainerTPEnv(pod *core.Pod, cn *core.Container, env map[string]string, patches patchOps) patchOps {
 	if l := len(cn.Env); l > 0 {
@@ -457,14 +557,7 @@ func addContainerTPEnv(pod *core.Pod, cn *core.Container, env map[string]string,
 	if len(env) == 0 {
 		return patches
 	}
-	cns := pod.Spec.Containers
-	var containerPath string
-	for i := range cns {
-		if &cns[i] == cn {
-			containerPath = fmt.Sprintf("/spec/containers/%d", i)
-			break
-		}
-	}
+	containerPath := containerPath(pod, cn)
 	keys := make([]string, len(env))
 	i := 0
 	for k := range env {
@@ -553,7 +646,7 @@ func hideContainerPorts(pod *core.Pod, app *core.Container, portName string, pat
 	return patches
 }
 
-func addPodAnnotations(_ context.Context, pod *core.Pod, patches patchOps) patchOps {
+func addPodAnnotations(_ context.Context, pod *core.Pod, config *agentconfig.Sidecar, mesh agentconfig.ServiceMesh, patches patchOps) patchOps {
 	op := "replace"
 	changed := false
 	am := pod.Annotations
@@ -569,6 +662,13 @@ func addPodAnnotations(_ context.Context, pod *core.Pod, patches patchOps) patch
 		am[agentconfig.InjectAnnotation] = "enabled"
 	}
 
+	for k, v := range mesh.MeshAnnotations(pod, config) {
+		if pod.Annotations[k] != v {
+			changed = true
+			am[k] = v
+		}
+	}
+
 	if changed {
 		patches = append(patches, patchOperation{
 			Op:    op,
@@ -156,6 +156,38 @@ func TestTrafficAgentConfigGenerator(t *testing.T) {
 		},
 	}
 
+	podHeadless := core.Pod{
+		ObjectMeta: podObjectMeta("headless", "app"),
+		Spec: core.PodSpec{
+			Containers: []core.Container{
+				{
+					Name: "db",
+					Ports: []core.ContainerPort{
+						{
+							Name: "postgres", ContainerPort: 5432,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	podManualEndpoints := core.Pod{
+		ObjectMeta: podObjectMeta("manual-endpoints", "app"),
+		Spec: core.PodSpec{
+			Containers: []core.Container{
+				{
+					Name: "some-container",
+					Ports: []core.ContainerPort{
+						{
+							Name: "http", ContainerPort: 8080,
+						},
+					},
+				},
+			},
+		},
+	}
+
 	podNamedAndNumericPort := core.Pod{
 		ObjectMeta: meta.ObjectMeta{
 			Name:            podName("named-and-numeric"),
@@ -323,8 +355,61 @@ func TestTrafficAgentConfigGenerator(t *testing.T) {
 	numericPortUID := makeUID()
 	unnamedNumericPortUID := makeUID()
 	multiPortUID := makeUID()
+	headlessUID := makeUID()
+	manualEndpointsUID := makeUID()
 
 	clientset := fake.NewSimpleClientset(
+		&core.Service{
+			TypeMeta: meta.TypeMeta{
+				Kind:       "Service",
+				APIVersion: "v1",
+			},
+			ObjectMeta: meta.ObjectMeta{
+				Name:      "manual-endpoints",
+				Namespace: "some-ns",
+				UID:       manualEndpointsUID,
+			},
+			Spec: core.ServiceSpec{
+				Ports: []core.ServicePort{{
+					Protocol:   "TCP",
+					Name:       "http",
+					Port:       80,
+					TargetPort: intstr.FromString("http"),
+				}},
+			},
+		},
+		&core.Endpoints{
+			ObjectMeta: meta.ObjectMeta{
+				Name:      "manual-endpoints",
+				Namespace: "some-ns",
+			},
+			Subsets: []core.EndpointSubset{{
+				Addresses: []core.EndpointAddress{{
+					IP:        "10.1.2.3",
+					TargetRef: &core.ObjectReference{Kind: "Pod", Name: podName("manual-endpoints"), Namespace: "some-ns"},
+				}},
+				Ports: []core.EndpointPort{{Name: "http", Port: 8080, Protocol: core.ProtocolTCP}},
+			}},
+		},
+		&podManualEndpoints,
+		deployment(&podManualEndpoints),
+		&core.Service{
+			TypeMeta: meta.TypeMeta{
+				Kind:       "Service",
+				APIVersion: "v1",
+			},
+			ObjectMeta: meta.ObjectMeta{
+				Name:      "headless",
+				Namespace: "some-ns",
+				UID:       headlessUID,
+			},
+			Spec: core.ServiceSpec{
+				ClusterIP: core.ClusterIPNone,
+				Selector: map[string]string{
+					"app": "headless",
+				},
+			},
+		},
 		&core.Service{
 			TypeMeta: meta.TypeMeta{
 				Kind:       "Service",
@@ -432,6 +517,7 @@ func TestTrafficAgentConfigGenerator(t *testing.T) {
 		deployment(&podNamedAndNumericPort),
 		deployment(&podMultiPort),
 		deployment(&podMultiSplitPort),
+		deployment(&podHeadless),
 	)
 	tests := []struct {
 		name           string
@@ -439,6 +525,87 @@ func TestTrafficAgentConfigGenerator(t *testing.T) {
 		expectedConfig *agentconfig.Sidecar
 		expectedError  string
 	}{
+		{
+			"Service without selector",
+			&podManualEndpoints,
+			&agentconfig.Sidecar{
+				AgentName:    "manual-endpoints",
+				AgentImage:   "docker.io/datawire/tel2:2.13.3",
+				Namespace:    "some-ns",
+				WorkloadName: "manual-endpoints",
+				WorkloadKind: "Deployment",
+				ManagerHost:  "traffic-manager.default",
+				ManagerPort:  8081,
+				Containers: []*agentconfig.Container{
+					{
+						Name: "some-container",
+						Intercepts: []*agentconfig.Intercept{
+							{
+								ContainerPortName: "http",
+								ServiceName:       "manual-endpoints",
+								ServiceUID:        manualEndpointsUID,
+								ServicePortName:   "http",
+								ServicePort:       80,
+								Protocol:          core.ProtocolTCP,
+								AgentPort:         9900,
+								ContainerPort:     8080,
+							},
+						},
+						EnvPrefix:  "A_",
+						MountPoint: "/tel_app_mounts/some-container",
+					},
+				},
+			},
+			"",
+		},
+		{
+			"Headless service without ports",
+			&podHeadless,
+			&agentconfig.Sidecar{
+				AgentName:    "headless",
+				AgentImage:   "docker.io/datawire/tel2:2.13.3",
+				Namespace:    "some-ns",
+				WorkloadName: "headless",
+				WorkloadKind: "Deployment",
+				ManagerHost:  "traffic-manager.default",
+				ManagerPort:  8081,
+				Containers: []*agentconfig.Container{
+					{
+						Name: "db",
+						Intercepts: []*agentconfig.Intercept{
+							{
+								ContainerPortName: "postgres",
+								ServiceName:       "headless",
+								ServiceUID:        headlessUID,
+								ServicePortName:   "postgres",
+								ServicePort:       5432,
+								TargetPortNumeric: true,
+								Headless:          true,
+								Protocol:          core.ProtocolTCP,
+								AgentPort:         9900,
+								ContainerPort:     5432,
+							},
+						},
+						EnvPrefix:  "A_",
+						MountPoint: "/tel_app_mounts/db",
+					},
+				},
+			},
+			"",
+		},
+		{
+			"Error Precondition: Headless service without ports",
+			&core.Pod{
+				ObjectMeta: podObjectMeta("headless", "app"),
+				Spec: core.PodSpec{
+					Containers: []core.Container{
+						{Name: "db"},
+					},
+				},
+			},
+			nil,
+			"A headless service that declares no ports can only be used when the pod's containers declare the ports",
+		},
 		{
 			"Error Precondition: No port specified",
 			&core.Pod{
@@ -1116,6 +1283,128 @@ func TestTrafficAgentInjector(t *testing.T) {
   value:
     name: TELEPRESENCE_API_PORT
     value: "9981"
+- op: add
+  path: /spec/containers/0/env/-
+  value:
+    name: TELEPRESENCE_API_URL
+    value: http://localhost:9981
+`,
+			"",
+			&managerutil.Env{
+				APIPort: 9981,
+			},
+		},
+		{
+			"Apply Patch: Debug annotation",
+			&core.Pod{
+				ObjectMeta: meta.ObjectMeta{
+					Name:      podName("named-port"),
+					Namespace: "some-ns",
+					Labels:    map[string]string{"service": "named-port"},
+					Annotations: map[string]string{
+						install.InjectAnnotation:    "enabled",
+						agentconfig.DebugAnnotation: "jvm:5005",
+					},
+					OwnerReferences: podOwner("named-port"),
+				},
+				Spec: core.PodSpec{
+					Containers: []core.Container{
+						{
+							Name:  "some-container",
+							Image: "some-app-image",
+							Env: []core.EnvVar{
+								{
+									Name:  "JAVA_TOOL_OPTIONS",
+									Value: "-Xmx512m",
+								},
+							},
+							Ports: []core.ContainerPort{
+								{
+									Name: "http", ContainerPort: 8888,
+								},
+							},
+						},
+					},
+				},
+			},
+			true,
+			`- op: add
+  path: /spec/containers/-
+  value:
+    args:
+    - agent
+    env:
+    - name: _TEL_APP_A_JAVA_TOOL_OPTIONS
+      value: -Xmx512m
+    - name: TELEPRESENCE_API_PORT
+      value: "9981"
+    - name: _TEL_AGENT_POD_IP
+      valueFrom:
+        fieldRef:
+          apiVersion: v1
+          fieldPath: status.podIP
+    - name: _TEL_AGENT_NAME
+      valueFrom:
+        fieldRef:
+          apiVersion: v1
+          fieldPath: metadata.name
+    image: docker.io/datawire/tel2:2.13.3
+    name: traffic-agent
+    ports:
+    - containerPort: 9900
+      name: http
+      protocol: TCP
+    readinessProbe:
+      exec:
+        command:
+        - /bin/stat
+        - /tmp/agent/ready
+    resources: {}
+    volumeMounts:
+    - mountPath: /tel_pod_info
+      name: traffic-annotations
+    - mountPath: /etc/traffic-agent
+      name: traffic-config
+    - mountPath: /tel_app_exports
+      name: export-volume
+    - mountPath: /tmp
+      name: tel-agent-tmp
+- op: replace
+  path: /spec/volumes
+  value:
+  - downwardAPI:
+      items:
+      - fieldRef:
+          apiVersion: v1
+          fieldPath: metadata.annotations
+        path: annotations
+    name: traffic-annotations
+  - configMap:
+      items:
+      - key: named-port
+        path: config.yaml
+      name: telepresence-agents
+    name: traffic-config
+  - emptyDir: {}
+    name: export-volume
+  - emptyDir: {}
+    name: tel-agent-tmp
+- op: replace
+  path: /spec/containers/0/ports/0/name
+  value: tm-http
+- op: replace
+  path: /spec/containers/0/env/0/value
+  value: -Xmx512m -agentlib:jdwp=transport=dt_socket,server=y,suspend=n,address=*:5005
+- op: add
+  path: /spec/containers/0/env/-
+  value:
+    name: TELEPRESENCE_API_PORT
+    value: "9981"
+- op: add
+  path: /spec/containers/0/env/-
+  value:
+    name: TELEPRESENCE_API_URL
+    value: http://localhost:9981
 `,
 			"",
 			&managerutil.Env{
@@ -0,0 +1,118 @@
+package mutator
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	admission "k8s.io/api/admission/v1"
+	core "k8s.io/api/core/v1"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
+)
+
+// maxClaimSkew is the largest difference between the time that an ephemeral namespace is claimed, and the time
+// that the API server records as its creation time. A claim that is copied to a namespace that is created later
+// is therefore invalid, even when that namespace has the same name.
+const maxClaimSkew = time.Minute
+
+// NamespaceClaims claims the ephemeral namespaces that "telepresence namespace create" creates, when the API server
+// calls the webhook at their creation, and verifies those claims. A claim is a signature of the namespace's name,
+// creator, and expiry, with a key that only the traffic-manager knows, so adding the ephemeral label and
+// annotations to an existing namespace doesn't make the traffic-manager delete it.
+type NamespaceClaims struct {
+	key []byte
+}
+
+// LoadNamespaceClaims returns the NamespaceClaims that use a key derived from the private key of the webhook.
+func LoadNamespaceClaims() (*NamespaceClaims, error) {
+	tlsKey, err := os.ReadFile(filepath.Join(tlsDir, tlsKeyFile))
+	if err != nil {
+		return nil, err
+	}
+	return NewNamespaceClaims(tlsKey), nil
+}
+
+// NewNamespaceClaims returns the NamespaceClaims that use a key derived from the given secret.
+func NewNamespaceClaims(secret []byte) *NamespaceClaims {
+	h := sha256.New()
+	h.Write([]byte("telepresence ephemeral namespace claim\x00"))
+	h.Write(secret)
+	return &NamespaceClaims{key: h.Sum(nil)}
+}
+
+func (c *NamespaceClaims) sign(name, creator, expiresAt, claimedAt string) string {
+	h := hmac.New(sha256.New, c.key)
+	h.Write([]byte(strings.Join([]string{name, creator, expiresAt, claimedAt}, "\x00")))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// claim is the mutatorFunc that claims an ephemeral namespace when it's created.
+func (c *NamespaceClaims) claim(ctx context.Context, req *admission.AdmissionRequest) (patchOps, error) {
+	if req.Operation != admission.Create || req.Kind.Kind != "Namespace" {
+		return nil, nil
+	}
+	var ns core.Namespace
+	if err := json.Unmarshal(req.Object.Raw, &ns); err != nil {
+		return nil, fmt.Errorf("unable to decode namespace: %w", err)
+	}
+	expiresAt := ns.Annotations[install.ExpiresAtAnnotation]
+	if ns.Labels[install.EphemeralNamespaceLabel] != "true" || expiresAt == "" {
+		return nil, nil
+	}
+	if ns.Name == "" {
+		// A generated name isn't known yet, so the claim can't name the namespace.
+		dlog.Infof(ctx, "not claiming an ephemeral namespace with a generated name, it will not expire")
+		return nil, nil
+	}
+	creator := req.UserInfo.Username
+	dlog.Infof(ctx, "Claiming ephemeral namespace %s created by %q", ns.Name, creator)
+	as := c.Annotations(ns.Name, creator, expiresAt, time.Now())
+	var ops patchOps
+	for _, k := range []string{install.CreatedByAnnotation, install.EphemeralClaimAnnotation} {
+		// An add replaces an annotation that the creator has set.
+		ops = append(ops, patchOperation{Op: "add", Path: annotationPath(k), Value: as[k]})
+	}
+	return ops, nil
+}
+
+// Annotations returns the annotations that claim the ephemeral namespace with the given name, creator, and value
+// of the install.ExpiresAtAnnotation, at the given time.
+func (c *NamespaceClaims) Annotations(name, creator, expiresAt string, claimedAt time.Time) map[string]string {
+	ca := claimedAt.UTC().Format(time.RFC3339)
+	return map[string]string{
+		install.CreatedByAnnotation:      creator,
+		install.EphemeralClaimAnnotation: ca + "/" + c.sign(name, creator, expiresAt, ca),
+	}
+}
+
+// Verify returns true when the given namespace has a claim that the webhook made when it was created.
+func (c *NamespaceClaims) Verify(ns *core.Namespace) bool {
+	as := ns.Annotations
+	claimedAt, sig, ok := strings.Cut(as[install.EphemeralClaimAnnotation], "/")
+	if !ok {
+		return false
+	}
+	ca, err := time.Parse(time.RFC3339, claimedAt)
+	if err != nil {
+		return false
+	}
+	if skew := ns.CreationTimestamp.Sub(ca); skew > maxClaimSkew || skew < -maxClaimSkew {
+		return false
+	}
+	want := c.sign(ns.Name, as[install.CreatedByAnnotation], as[install.ExpiresAtAnnotation], claimedAt)
+	return hmac.Equal([]byte(sig), []byte(want))
+}
+
+// annotationPath returns the JSON pointer of the given annotation.
+func annotationPath(key string) string {
+	return "/metadata/annotations/" + strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,80 @@
+package mutator
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admission "k8s.io/api/admission/v1"
+	auth "k8s.io/api/authentication/v1"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
+)
+
+func TestNamespaceClaims(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	nc := NewNamespaceClaims([]byte("secret"))
+	expiresAt := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+
+	create := func(ns *core.Namespace) *core.Namespace {
+		raw, err := json.Marshal(ns)
+		require.NoError(t, err)
+		ops, err := nc.claim(ctx, &admission.AdmissionRequest{
+			Kind:      meta.GroupVersionKind{Version: "v1", Kind: "Namespace"},
+			Operation: admission.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+			UserInfo:  auth.UserInfo{Username: "alice"},
+		})
+		require.NoError(t, err)
+		if len(ops) > 0 {
+			pb, err := json.Marshal(ops)
+			require.NoError(t, err)
+			patch, err := jsonpatch.DecodePatch(pb)
+			require.NoError(t, err)
+			raw, err = patch.Apply(raw)
+			require.NoError(t, err)
+		}
+		created := &core.Namespace{}
+		require.NoError(t, json.Unmarshal(raw, created))
+		created.CreationTimestamp = meta.Now()
+		return created
+	}
+	ephemeral := func(name string, annotations map[string]string) *core.Namespace {
+		as := map[string]string{install.ExpiresAtAnnotation: expiresAt}
+		for k, v := range annotations {
+			as[k] = v
+		}
+		return &core.Namespace{ObjectMeta: meta.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{install.EphemeralNamespaceLabel: "true"},
+			Annotations: as,
+		}}
+	}
+
+	ns := create(ephemeral("pr-1", nil))
+	assert.Equal(t, "alice", ns.Annotations[install.CreatedByAnnotation])
+	assert.True(t, nc.Verify(ns))
+
+	// The creator can't choose the creator or the claim.
+	ns = create(ephemeral("pr-2", map[string]string{
+		install.CreatedByAnnotation:      "admin",
+		install.EphemeralClaimAnnotation: "forged",
+	}))
+	assert.Equal(t, "alice", ns.Annotations[install.CreatedByAnnotation])
+	assert.True(t, nc.Verify(ns))
+
+	// A claim made with another key is invalid.
+	assert.False(t, NewNamespaceClaims([]byte("other")).Verify(ns))
+
+	// Namespaces that aren't ephemeral aren't claimed.
+	plain := ephemeral("plain", nil)
+	plain.Labels = nil
+	assert.False(t, nc.Verify(create(plain)))
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/datawire/dlib/dlog"
 	"github.com/datawire/dlib/dtime"
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/imageverify"
 )
 
 const (
@@ -88,6 +89,25 @@ func ServeMutator(ctx context.Context) error {
 			dlog.Errorf(ctx, "could not write response: %v", err)
 		}
 	})
+	if managerutil.GetEnv(ctx).EphemeralNamespacesEnabled {
+		nc, err := LoadNamespaceClaims()
+		if err != nil {
+			return err
+		}
+		mux.HandleFunc("/ephemeral-namespace", func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			dlog.Debug(ctx, "Received ephemeral namespace request...")
+			bytes, statusCode, err := serveMutatingFunc(ctx, r, nc.claim)
+			if err != nil {
+				dlog.Errorf(ctx, "error handling ephemeral namespace request: %v", err)
+				w.WriteHeader(statusCode)
+				bytes = []byte(err.Error())
+			}
+			if _, err = w.Write(bytes); err != nil {
+				dlog.Errorf(ctx, "could not write response: %v", err)
+			}
+		})
+	}
 	mux.HandleFunc("/uninstall", func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
 		dlog.Debug(ctx, "Received uninstall request...")
@@ -124,6 +144,11 @@ func ServeMutator(ctx context.Context) error {
 		return err
 	}
 	ai = &agentInjector{agentConfigs: cw}
+	if key := managerutil.GetEnv(ctx).AgentImageVerificationKey; key != "" {
+		if ai.verifier, err = imageverify.NewVerifier([]byte(key), nil); err != nil {
+			return fmt.Errorf("invalid image verification key: %w", err)
+		}
+	}
 	dgroup.ParentGroup(ctx).Go("agent-configs", func(ctx context.Context) error {
 		dtime.SleepWithContext(ctx, time.Second) // Give the server some time to start
 		return cw.Run(ctx)
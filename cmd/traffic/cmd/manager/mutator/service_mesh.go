@@ -0,0 +1,53 @@
+package mutator
+
+import (
+	"context"
+	"fmt"
+
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+)
+
+// resolveServiceMesh returns the service mesh that the injection into the given pod must adjust for. The
+// agentconfig.ServiceMeshAnnotation of the pod takes precedence over the given default, and the auto mode
+// is resolved by looking at the pod and its namespace.
+func resolveServiceMesh(ctx context.Context, dflt agentconfig.ServiceMesh, pod *core.Pod) (agentconfig.ServiceMesh, error) {
+	mesh := dflt
+	if a, ok := pod.Annotations[agentconfig.ServiceMeshAnnotation]; ok {
+		var err error
+		if mesh, err = agentconfig.NewServiceMesh(a); err != nil {
+			return 0, fmt.Errorf("invalid value %q for annotation %s", a, agentconfig.ServiceMeshAnnotation)
+		}
+	}
+	if mesh != agentconfig.ServiceMeshAuto {
+		return mesh, nil
+	}
+	var nsMeta *meta.ObjectMeta
+	if ns, err := k8sapi.GetK8sInterface(ctx).CoreV1().Namespaces().Get(ctx, pod.Namespace, meta.GetOptions{}); err == nil {
+		nsMeta = &ns.ObjectMeta
+	} else {
+		dlog.Debugf(ctx, "unable to get namespace %s for service mesh detection: %v", pod.Namespace, err)
+	}
+	mesh = agentconfig.DetectServiceMesh(pod, nsMeta)
+	dlog.Debugf(ctx, "Detected service mesh %s for pod %s.%s", mesh, pod.Name, pod.Namespace)
+	return mesh, nil
+}
+
+// meshInitAfter returns true if the init container of the given mesh is found after the init container at
+// the given index.
+func meshInitAfter(ics []core.Container, i int, mesh agentconfig.ServiceMesh) bool {
+	mn := mesh.InitContainerName()
+	if mn == "" {
+		return false
+	}
+	for _, ic := range ics[i+1:] {
+		if ic.Name == mn {
+			return true
+		}
+	}
+	return false
+}
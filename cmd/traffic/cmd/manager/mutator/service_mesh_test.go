@@ -0,0 +1,123 @@
+package mutator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+)
+
+func meshTestConfig() *agentconfig.Sidecar {
+	return &agentconfig.Sidecar{
+		AgentName:   "echo",
+		AgentImage:  "docker.io/datawire/tel2:2.13.0",
+		Namespace:   "default",
+		ManagerPort: 8081,
+		Containers: []*agentconfig.Container{{
+			Name: "echo",
+			Intercepts: []*agentconfig.Intercept{
+				{ServicePortName: "http", Protocol: core.ProtocolTCP, AppProtocol: "http", AgentPort: 9900, TargetPortNumeric: true},
+				{ServicePortName: "db", Protocol: core.ProtocolTCP, AgentPort: 9901, TargetPortNumeric: true},
+			},
+		}},
+	}
+}
+
+func TestResolveServiceMesh(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	ctx = k8sapi.WithK8sInterface(ctx, fake.NewSimpleClientset(&core.Namespace{
+		ObjectMeta: meta.ObjectMeta{Name: "meshed", Labels: map[string]string{agentconfig.IstioInjectionLabel: "enabled"}},
+	}))
+	pod := func(ns string, annotations map[string]string) *core.Pod {
+		return &core.Pod{ObjectMeta: meta.ObjectMeta{Name: "echo", Namespace: ns, Annotations: annotations}}
+	}
+	tests := []struct {
+		name    string
+		dflt    agentconfig.ServiceMesh
+		pod     *core.Pod
+		want    agentconfig.ServiceMesh
+		wantErr bool
+	}{
+		{"default none", agentconfig.ServiceMeshNone, pod("meshed", nil), agentconfig.ServiceMeshNone, false},
+		{"auto from namespace", agentconfig.ServiceMeshAuto, pod("meshed", nil), agentconfig.ServiceMeshIstio, false},
+		{"auto without mesh", agentconfig.ServiceMeshAuto, pod("default", nil), agentconfig.ServiceMeshNone, false},
+		{
+			"auto from pod annotation", agentconfig.ServiceMeshAuto,
+			pod("default", map[string]string{agentconfig.LinkerdInjectAnnotation: "enabled"}), agentconfig.ServiceMeshLinkerd, false,
+		},
+		{
+			"annotation overrides default", agentconfig.ServiceMeshNone,
+			pod("default", map[string]string{agentconfig.ServiceMeshAnnotation: "Linkerd"}), agentconfig.ServiceMeshLinkerd, false,
+		},
+		{
+			"invalid annotation", agentconfig.ServiceMeshNone,
+			pod("default", map[string]string{agentconfig.ServiceMeshAnnotation: "consul"}), agentconfig.ServiceMeshNone, true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mesh, err := resolveServiceMesh(ctx, test.dflt, test.pod)
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, mesh)
+		})
+	}
+}
+
+func TestServiceMeshAnnotations(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	config := meshTestConfig()
+	pod := &core.Pod{ObjectMeta: meta.ObjectMeta{
+		Name:        "echo",
+		Namespace:   "default",
+		Annotations: map[string]string{agentconfig.LinkerdSkipOutboundPortsAnnotation: "4567,8000-8090"},
+	}}
+
+	patches := addPodAnnotations(ctx, pod, config, agentconfig.ServiceMeshLinkerd, nil)
+	require.Len(t, patches, 1)
+	am := patches[0].Value.(map[string]string)
+	assert.Equal(t, "4567,8000-8090,8081", am[agentconfig.LinkerdSkipOutboundPortsAnnotation])
+	assert.Equal(t, "9901", am[agentconfig.LinkerdOpaquePortsAnnotation])
+
+	pod.Annotations = am
+	assert.Empty(t, agentconfig.ServiceMeshProblems(pod, config))
+
+	patches = addPodAnnotations(ctx, pod, config, agentconfig.ServiceMeshIstio, nil)
+	require.Len(t, patches, 1)
+	am = patches[0].Value.(map[string]string)
+	assert.Equal(t, "8081", am[agentconfig.IstioExcludeOutboundPortsAnnotation])
+}
+
+func TestServiceMeshInitContainerOrder(t *testing.T) {
+	config := meshTestConfig()
+	ic := agentconfig.InitContainer(config)
+	pod := &core.Pod{Spec: core.PodSpec{
+		InitContainers: []core.Container{*ic, {Name: agentconfig.IstioInitContainerName}},
+		Containers:     []core.Container{{Name: agentconfig.IstioProxyContainerName}},
+	}}
+	assert.NotEmpty(t, agentconfig.ServiceMeshProblems(pod, nil))
+
+	// Without mesh compatibility, an existing init container is left alone.
+	assert.Empty(t, addInitContainer(pod, config, agentconfig.ServiceMeshNone, nil))
+
+	patches := addInitContainer(pod, config, agentconfig.ServiceMeshIstio, nil)
+	require.Len(t, patches, 2)
+	assert.Equal(t, "remove", patches[0].Op)
+	assert.Equal(t, "/spec/initContainers/0", patches[0].Path)
+	assert.Equal(t, "add", patches[1].Op)
+	assert.Equal(t, "/spec/initContainers/-", patches[1].Path)
+
+	pod.Spec.InitContainers = []core.Container{{Name: agentconfig.IstioInitContainerName}, *ic}
+	assert.Empty(t, agentconfig.ServiceMeshProblems(pod, nil))
+	assert.Empty(t, addInitContainer(pod, config, agentconfig.ServiceMeshIstio, nil))
+}
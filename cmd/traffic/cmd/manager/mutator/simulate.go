@@ -0,0 +1,47 @@
+package mutator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	core "k8s.io/api/core/v1"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+)
+
+// Simulate returns a copy of the given pod with the same patches applied that the agent injector would
+// apply when the pod is admitted, given the agent config and the service mesh that the injector is
+// configured with. Nothing in the cluster is modified, but the pod's namespace is read when the mesh is
+// agentconfig.ServiceMeshAuto.
+func Simulate(ctx context.Context, pod *core.Pod, config *agentconfig.Sidecar, dfltMesh agentconfig.ServiceMesh) (*core.Pod, error) {
+	mesh, err := resolveServiceMesh(ctx, dfltMesh, pod)
+	if err != nil {
+		return nil, err
+	}
+	patches := podPatches(ctx, pod, config, mesh)
+	if len(patches) == 0 {
+		return pod.DeepCopy(), nil
+	}
+	pj, err := json.Marshal(patches)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := jsonpatch.DecodePatch(pj)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := json.Marshal(pod)
+	if err != nil {
+		return nil, err
+	}
+	if doc, err = patch.Apply(doc); err != nil {
+		return nil, fmt.Errorf("unable to apply patches to pod %s.%s: %w", pod.Name, pod.Namespace, err)
+	}
+	var patched core.Pod
+	if err = json.Unmarshal(doc, &patched); err != nil {
+		return nil, err
+	}
+	return &patched, nil
+}
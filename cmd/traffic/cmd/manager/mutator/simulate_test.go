@@ -0,0 +1,151 @@
+package mutator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentmap"
+)
+
+func TestSimulate(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	pod := &core.Pod{
+		ObjectMeta: meta.ObjectMeta{Name: "echo", Namespace: "some-ns"},
+		Spec: core.PodSpec{
+			Containers: []core.Container{{
+				Name:  "echo",
+				Image: "jmalloc/echo-server",
+				Ports: []core.ContainerPort{{Name: "http", ContainerPort: 8080}},
+			}},
+		},
+	}
+	config := &agentconfig.Sidecar{
+		AgentName:    "echo",
+		AgentImage:   "docker.io/datawire/tel2:2.13.3",
+		Namespace:    "some-ns",
+		WorkloadName: "echo",
+		WorkloadKind: "Deployment",
+		ManagerHost:  "traffic-manager.default",
+		ManagerPort:  8081,
+		Containers: []*agentconfig.Container{{
+			Name: "echo",
+			Intercepts: []*agentconfig.Intercept{{
+				ContainerPortName: "http",
+				ServiceName:       "echo",
+				ServicePortName:   "http",
+				ServicePort:       80,
+				Protocol:          core.ProtocolTCP,
+				AgentPort:         9900,
+				ContainerPort:     8080,
+			}},
+			EnvPrefix:  "A_",
+			MountPoint: "/tel_app_mounts/echo",
+		}},
+	}
+
+	patched, err := Simulate(ctx, pod, config, agentconfig.ServiceMeshNone)
+	require.NoError(t, err)
+
+	// The given pod is unmodified
+	require.Len(t, pod.Spec.Containers, 1)
+	assert.Equal(t, "http", pod.Spec.Containers[0].Ports[0].Name)
+
+	require.Len(t, patched.Spec.Containers, 2)
+	assert.Equal(t, agentconfig.ContainerName, patched.Spec.Containers[1].Name)
+	assert.Equal(t, "tm-http", patched.Spec.Containers[0].Ports[0].Name, "intercepted port must be hidden")
+	assert.NotEmpty(t, patched.Spec.Volumes)
+	assert.Equal(t, "enabled", patched.Annotations[agentconfig.InjectAnnotation])
+}
+
+func TestSimulateRestricted(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	labels := map[string]string{"app": "echo"}
+	dep := &apps.Deployment{
+		TypeMeta:   meta.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+		ObjectMeta: meta.ObjectMeta{Name: "echo", Namespace: "some-ns"},
+		Spec: apps.DeploymentSpec{
+			Selector: &meta.LabelSelector{MatchLabels: labels},
+			Template: core.PodTemplateSpec{
+				ObjectMeta: meta.ObjectMeta{
+					Labels:      labels,
+					Annotations: map[string]string{agentconfig.ComplianceAnnotation: "restricted"},
+				},
+				Spec: core.PodSpec{
+					Containers: []core.Container{{
+						Name:  "echo",
+						Image: "jmalloc/echo-server",
+						Ports: []core.ContainerPort{
+							{Name: "http", ContainerPort: 8080},
+							{ContainerPort: 8081},
+						},
+					}},
+				},
+			},
+		},
+	}
+	svc := func(name string, targetPort intstr.IntOrString) *core.Service {
+		return &core.Service{
+			ObjectMeta: meta.ObjectMeta{Name: name, Namespace: "some-ns"},
+			Spec: core.ServiceSpec{
+				Selector: labels,
+				Ports:    []core.ServicePort{{Name: name, Port: 80, TargetPort: targetPort, Protocol: core.ProtocolTCP}},
+			},
+		}
+	}
+	ctx = k8sapi.WithK8sInterface(ctx, fake.NewSimpleClientset(
+		dep,
+		svc("named", intstr.FromString("http")),
+		svc("numeric", intstr.FromInt(8081)),
+	))
+
+	gc := &agentmap.BasicGeneratorConfig{
+		AgentPort:           9900,
+		ManagerPort:         8081,
+		QualifiedAgentImage: "docker.io/datawire/tel2:2.13.3",
+		ManagerNamespace:    "ambassador",
+	}
+	wl, err := k8sapi.WrapWorkload(dep)
+	require.NoError(t, err)
+	scx, err := gc.Generate(ctx, wl)
+	require.NoError(t, err)
+	config := scx.AgentConfig()
+	assert.Equal(t, agentconfig.ComplianceRestricted, config.Compliance)
+	require.Len(t, config.Containers, 1)
+	require.Len(t, config.Containers[0].Intercepts, 1, "the numeric target port cannot be intercepted")
+	assert.Equal(t, "named", config.Containers[0].Intercepts[0].ServiceName)
+
+	pod := &core.Pod{
+		ObjectMeta: *dep.Spec.Template.ObjectMeta.DeepCopy(),
+		Spec:       *dep.Spec.Template.Spec.DeepCopy(),
+	}
+	pod.Name = "echo"
+	pod.Namespace = "some-ns"
+	patched, err := Simulate(ctx, pod, config, agentconfig.ServiceMeshNone)
+	require.NoError(t, err)
+	assert.Empty(t, patched.Spec.InitContainers)
+	require.Len(t, patched.Spec.Containers, 2)
+	sc := patched.Spec.Containers[1].SecurityContext
+	require.NotNil(t, sc)
+	assert.True(t, *sc.RunAsNonRoot)
+	assert.Equal(t, int64(agentconfig.RestrictedUID), *sc.RunAsUser)
+	assert.True(t, *sc.ReadOnlyRootFilesystem)
+	assert.False(t, *sc.AllowPrivilegeEscalation)
+	assert.Equal(t, []core.Capability{"ALL"}, sc.Capabilities.Drop)
+	assert.Equal(t, core.SeccompProfileTypeRuntimeDefault, sc.SeccompProfile.Type)
+
+	// Only numeric target ports
+	dep.Spec.Template.Spec.Containers[0].Ports = dep.Spec.Template.Spec.Containers[0].Ports[1:]
+	_, err = gc.Generate(ctx, wl)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "restricted compliance mode")
+}
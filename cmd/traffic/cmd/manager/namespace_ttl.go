@@ -0,0 +1,74 @@
+package manager
+
+import (
+	"context"
+	"time"
+
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/mutator"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
+)
+
+// runNamespaceTTLLoop deletes the ephemeral namespaces created by "telepresence namespace create"
+// once their time to live has expired. Only namespaces that the webhook claimed when they were created
+// are deleted, so the loop doesn't run when the webhook has no key.
+func runNamespaceTTLLoop(ctx context.Context) error {
+	nc, err := mutator.LoadNamespaceClaims()
+	if err != nil {
+		dlog.Errorf(ctx, "ephemeral namespaces will not be deleted: %v", err)
+		return nil
+	}
+	ki := k8sapi.GetK8sInterface(ctx)
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		deleteExpiredNamespaces(ctx, ki, nc, time.Now())
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// deleteExpiredNamespaces deletes all claimed ephemeral namespaces that expire before the given time.
+func deleteExpiredNamespaces(ctx context.Context, ki kubernetes.Interface, nc *mutator.NamespaceClaims, now time.Time) {
+	api := ki.CoreV1().Namespaces()
+	nl, err := api.List(ctx, meta.ListOptions{LabelSelector: install.EphemeralNamespaceLabel + "=true"})
+	if err != nil {
+		dlog.Errorf(ctx, "unable to list ephemeral namespaces: %v", err)
+		return
+	}
+	for i := range nl.Items {
+		ns := &nl.Items[i]
+		if ns.Status.Phase == core.NamespaceTerminating {
+			continue
+		}
+		ea, ok := ns.Annotations[install.ExpiresAtAnnotation]
+		if !ok {
+			continue
+		}
+		expiresAt, err := time.Parse(time.RFC3339, ea)
+		if err != nil {
+			dlog.Errorf(ctx, "namespace %s has an invalid %s annotation: %v", ns.Name, install.ExpiresAtAnnotation, err)
+			continue
+		}
+		if now.Before(expiresAt) {
+			continue
+		}
+		if !nc.Verify(ns) {
+			dlog.Infof(ctx, "not deleting namespace %s, because it wasn't claimed when it was created", ns.Name)
+			continue
+		}
+		dlog.Infof(ctx, "Deleting ephemeral namespace %s created by %q that expired at %s", ns.Name, ns.Annotations[install.CreatedByAnnotation], ea)
+		if err = api.Delete(ctx, ns.Name, meta.DeleteOptions{}); err != nil {
+			dlog.Errorf(ctx, "unable to delete namespace %s: %v", ns.Name, err)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/mutator"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
+)
+
+func TestDeleteExpiredNamespaces(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	created := now.Add(-time.Hour)
+	nc := mutator.NewNamespaceClaims([]byte("secret"))
+	ns := func(name string, ephemeral bool, expiresAt time.Time) *core.Namespace {
+		ea := expiresAt.Format(time.RFC3339)
+		ns := &core.Namespace{ObjectMeta: meta.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: meta.NewTime(created),
+			Annotations:       nc.Annotations(name, "alice", ea, created),
+		}}
+		ns.Annotations[install.ExpiresAtAnnotation] = ea
+		if ephemeral {
+			ns.Labels = map[string]string{install.EphemeralNamespaceLabel: "true"}
+		}
+		return ns
+	}
+
+	// A namespace that was labeled and annotated after its creation, by someone who can't make claims.
+	labeled := ns("labeled", true, now.Add(-time.Minute))
+	delete(labeled.Annotations, install.EphemeralClaimAnnotation)
+
+	// A claim that was copied from a namespace that was created earlier.
+	copied := ns("copied", true, now.Add(-time.Minute))
+	copied.CreationTimestamp = meta.NewTime(now.Add(-10 * time.Minute))
+
+	// A claim that was copied from another namespace.
+	renamed := ns("renamed", true, now.Add(-time.Minute))
+	renamed.Annotations = ns("expired", true, now.Add(-time.Minute)).Annotations
+
+	// An expiry that was changed after the claim.
+	extended := ns("extended", true, now.Add(-time.Minute))
+	extended.Annotations[install.ExpiresAtAnnotation] = now.Add(-time.Second).Format(time.RFC3339)
+
+	ki := fake.NewSimpleClientset(
+		ns("expired", true, now.Add(-time.Minute)),
+		ns("alive", true, now.Add(time.Minute)),
+		ns("permanent", false, now.Add(-time.Minute)),
+		labeled,
+		copied,
+		renamed,
+		extended,
+	)
+	deleteExpiredNamespaces(ctx, ki, nc, now)
+
+	nl, err := ki.CoreV1().Namespaces().List(ctx, meta.ListOptions{})
+	require.NoError(t, err)
+	var names []string
+	for _, ns := range nl.Items {
+		names = append(names, ns.Name)
+	}
+	assert.ElementsMatch(t, []string{"alive", "permanent", "labeled", "copied", "renamed", "extended"}, names)
+}
@@ -0,0 +1,288 @@
+// Package policy contains the intercept and exec policies of the traffic-manager. The intercept
+// policy is configured using the intercept.policy Helm value and decides which users may intercept
+// which workloads, for how long, and with what header filters. The exec policy is configured using
+// the exec.policy Helm value, and decides which users may run commands in which workloads, and for
+// how long.
+package policy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+// httpHeaderFlag is the mechanism argument that declares an HTTP header filter.
+const httpHeaderFlag = "--http-header="
+
+// Rule permits intercepts by some users of some workloads. An empty list of users and groups
+// matches all users, and an empty list of namespaces or workloads matches all namespaces or
+// workloads. Namespaces and workloads may contain path.Match patterns, e.g. "dev-*". Users and
+// groups only match users that have been authenticated by the Kubernetes API server. A group
+// matches the users that the policy declares for it, and the users that are members of the
+// Kubernetes group with that name.
+type Rule struct {
+	Users      []string `yaml:"users,omitempty"`
+	Groups     []string `yaml:"groups,omitempty"`
+	Namespaces []string `yaml:"namespaces,omitempty"`
+	Workloads  []string `yaml:"workloads,omitempty"`
+
+	// MaxDuration limits the lifetime of intercepts that are permitted by this rule. Zero means
+	// unlimited.
+	MaxDuration time.Duration `yaml:"-"`
+
+	// RequiredHeaders are the names of the HTTP headers that the intercept must filter on.
+	RequiredHeaders []string `yaml:"requiredHeaders,omitempty"`
+}
+
+// Policy is a set of rules. An intercept is permitted when at least one rule permits it. An empty
+// policy permits all intercepts.
+type Policy struct {
+	// Groups maps group names to the names of their users.
+	Groups map[string][]string `yaml:"groups,omitempty"`
+	Rules  []*Rule             `yaml:"rules,omitempty"`
+}
+
+// Violation is returned when a policy refuses an intercept. The Remedy tells the user what to
+// do about it.
+type Violation struct {
+	Reason string
+	Remedy string
+}
+
+func (v *Violation) Error() string {
+	return v.Reason + ". " + v.Remedy
+}
+
+// UnmarshalYAML parses the maxDuration of the rule.
+func (r *Rule) UnmarshalYAML(node *yaml.Node) error {
+	type plain Rule
+	var pr struct {
+		plain       `yaml:",inline"`
+		MaxDuration string `yaml:"maxDuration,omitempty"`
+	}
+	if err := node.Decode(&pr); err != nil {
+		return err
+	}
+	*r = Rule(pr.plain)
+	if pr.MaxDuration != "" {
+		d, err := time.ParseDuration(pr.MaxDuration)
+		if err != nil {
+			return fmt.Errorf("invalid maxDuration %q: %w", pr.MaxDuration, err)
+		}
+		if d < 0 {
+			return fmt.Errorf("invalid maxDuration %q: must not be negative", pr.MaxDuration)
+		}
+		r.MaxDuration = d
+	}
+	return nil
+}
+
+// Parse returns the policy declared by the given YAML. An empty YAML yields a nil policy.
+func Parse(data []byte) (*Policy, error) {
+	var p *Policy
+	if err := yaml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("unable to parse policy: %w", err)
+	}
+	for i, r := range p.rulesOrNil() {
+		if r == nil {
+			return nil, fmt.Errorf("unable to parse policy: rule %d is empty", i)
+		}
+	}
+	return p, nil
+}
+
+func (p *Policy) rulesOrNil() []*Rule {
+	if p == nil {
+		return nil
+	}
+	return p.Rules
+}
+
+// Check returns the longest duration that the policy permits for an intercept by the given user
+// with the given spec, or a *Violation when no rule permits it. A zero duration means unlimited.
+// A nil user is a user that couldn't be authenticated.
+func (p *Policy) Check(user *rpc.UserIdentity, spec *rpc.InterceptSpec) (time.Duration, error) {
+	rules := p.rulesOrNil()
+	if len(rules) == 0 {
+		return 0, nil
+	}
+	var missing []string
+	scoped := false
+	maxDuration := time.Duration(-1)
+	for _, r := range rules {
+		if !(p.userMatches(r, user) && matchesAny(r.Namespaces, spec.Namespace) && matchesAny(r.Workloads, spec.Agent)) {
+			continue
+		}
+		scoped = true
+		if m := missingHeaders(r.RequiredHeaders, spec.MechanismArgs); len(m) > 0 {
+			missing = append(missing, m...)
+			continue
+		}
+		if r.MaxDuration == 0 {
+			return 0, nil
+		}
+		if r.MaxDuration > maxDuration {
+			maxDuration = r.MaxDuration
+		}
+	}
+	if maxDuration > 0 {
+		return maxDuration, nil
+	}
+	if !scoped {
+		return 0, &Violation{
+			Reason: fmt.Sprintf("%s may not intercept workload %s in namespace %s", describeUser(user), spec.Agent, spec.Namespace),
+			Remedy: remedy(user, "intercept.policy.rules"),
+		}
+	}
+	return 0, &Violation{
+		Reason: fmt.Sprintf("intercepts of workload %s in namespace %s must filter on the HTTP header(s) %s",
+			spec.Agent, spec.Namespace, strings.Join(dedup(missing), ", ")),
+		Remedy: "Add a header filter for each of them, e.g. --http-header=" + missing[0] + "=<value>",
+	}
+}
+
+// CheckExec returns the longest duration that the policy permits for a command that the given user
+// runs in the given workload, or a *Violation when no rule permits it. A zero duration means
+// unlimited. The required headers of the rules don't apply to commands. Unlike intercepts, commands
+// are refused when the policy is empty, and when the user couldn't be authenticated, i.e. is nil.
+func (p *Policy) CheckExec(user *rpc.UserIdentity, namespace, workload string) (time.Duration, error) {
+	rules := p.rulesOrNil()
+	if len(rules) == 0 {
+		return 0, &Violation{
+			Reason: "the exec policy has no rules, so no one may run commands",
+			Remedy: "Ask your cluster administrator to add rules to the exec.policy of the traffic-manager Helm chart",
+		}
+	}
+	if user == nil {
+		return 0, &Violation{
+			Reason: fmt.Sprintf("%s may not run commands", describeUser(user)),
+			Remedy: remedy(user, "exec.policy.rules"),
+		}
+	}
+	maxDuration := time.Duration(-1)
+	for _, r := range rules {
+		if !(p.userMatches(r, user) && matchesAny(r.Namespaces, namespace) && matchesAny(r.Workloads, workload)) {
+			continue
+		}
+		if r.MaxDuration == 0 {
+			return 0, nil
+		}
+		if r.MaxDuration > maxDuration {
+			maxDuration = r.MaxDuration
+		}
+	}
+	if maxDuration > 0 {
+		return maxDuration, nil
+	}
+	return 0, &Violation{
+		Reason: fmt.Sprintf("%s may not run commands in workload %s in namespace %s", describeUser(user), workload, namespace),
+		Remedy: remedy(user, "exec.policy.rules"),
+	}
+}
+
+func describeUser(user *rpc.UserIdentity) string {
+	if user == nil {
+		return "an unauthenticated user"
+	}
+	return fmt.Sprintf("user %q", user.Username)
+}
+
+func remedy(user *rpc.UserIdentity, rules string) string {
+	if user == nil {
+		return "Connect as a service account that may create tokens for itself, and not over an insecure connection to the traffic-manager, so that the traffic-manager can authenticate you"
+	}
+	return "Ask your cluster administrator to add a rule that permits it to the " + rules + " of the traffic-manager Helm chart"
+}
+
+func (p *Policy) userMatches(r *Rule, user *rpc.UserIdentity) bool {
+	if len(r.Users) == 0 && len(r.Groups) == 0 {
+		return true
+	}
+	if user == nil {
+		return false
+	}
+	if contains(r.Users, user.Username) {
+		return true
+	}
+	for _, g := range r.Groups {
+		if contains(p.Groups[g], user.Username) {
+			return true
+		}
+		for _, ug := range user.Groups {
+			if ug == g {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == "*" || n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// missingHeaders returns the required headers that aren't filtered on by the given mechanism args.
+func missingHeaders(required, mechanismArgs []string) []string {
+	var missing []string
+	for _, h := range required {
+		found := false
+		for _, a := range mechanismArgs {
+			if nv := strings.TrimPrefix(a, httpHeaderFlag); len(nv) < len(a) {
+				if n, _, _ := strings.Cut(nv, "="); strings.EqualFold(n, h) {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			missing = append(missing, h)
+		}
+	}
+	return missing
+}
+
+func dedup(ss []string) []string {
+	seen := make(map[string]struct{}, len(ss))
+	var r []string
+	for _, s := range ss {
+		if _, ok := seen[s]; !ok {
+			seen[s] = struct{}{}
+			r = append(r, s)
+		}
+	}
+	return r
+}
+
+// UserName returns the name of the authenticated Kubernetes user of a client. When the user isn't
+// authenticated, the user part of the name that the client reports, which is of the form user@host,
+// is returned with an "unauthenticated:" prefix. It's meant for logs and reports, and must never be
+// used to authorize anything.
+func UserName(client *rpc.ClientInfo) string {
+	if id := client.GetIdentity(); id != nil {
+		return id.Username
+	}
+	name, _, _ := strings.Cut(client.GetName(), "@")
+	return "unauthenticated:" + name
+}
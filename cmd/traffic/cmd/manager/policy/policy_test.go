@@ -0,0 +1,184 @@
+package policy_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/policy"
+)
+
+const testPolicy = `
+groups:
+  developers: [alice, bob]
+rules:
+  - groups: [developers]
+    namespaces: ["dev-*"]
+    maxDuration: 2h
+    requiredHeaders: [x-dev-user]
+  - users: [carol]
+    workloads: [echo]
+  - users: [alice]
+    namespaces: [dev-alice]
+    maxDuration: 8h
+`
+
+func spec(ns, wl string, args ...string) *rpc.InterceptSpec {
+	return &rpc.InterceptSpec{Name: wl, Namespace: ns, Agent: wl, MechanismArgs: args}
+}
+
+func user(name string, groups ...string) *rpc.UserIdentity {
+	return &rpc.UserIdentity{Username: name, Groups: groups}
+}
+
+func TestCheck(t *testing.T) {
+	p, err := policy.Parse([]byte(testPolicy))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		user     *rpc.UserIdentity
+		spec     *rpc.InterceptSpec
+		duration time.Duration
+		err      string
+	}{
+		{
+			name:     "group member with header",
+			user:     user("bob"),
+			spec:     spec("dev-1", "echo", "--http-header=X-Dev-User=bob"),
+			duration: 2 * time.Hour,
+		},
+		{
+			name: "group member without header",
+			user: user("bob"),
+			spec: spec("dev-1", "echo"),
+			err:  "must filter on the HTTP header(s) x-dev-user",
+		},
+		{
+			name:     "longest duration wins",
+			user:     user("alice"),
+			spec:     spec("dev-alice", "echo", "--http-header=x-dev-user=alice"),
+			duration: 8 * time.Hour,
+		},
+		{
+			name:     "header not required by other rule",
+			user:     user("alice"),
+			spec:     spec("dev-alice", "echo"),
+			duration: 8 * time.Hour,
+		},
+		{
+			name: "unlimited in any namespace",
+			user: user("carol"),
+			spec: spec("prod", "echo"),
+		},
+		{
+			name: "wrong workload",
+			user: user("carol"),
+			spec: spec("prod", "db"),
+			err:  `user "carol" may not intercept workload db in namespace prod`,
+		},
+		{
+			name:     "kubernetes group member",
+			user:     user("dave", "developers"),
+			spec:     spec("dev-1", "echo", "--http-header=x-dev-user=dave"),
+			duration: 2 * time.Hour,
+		},
+		{
+			name: "unauthenticated user",
+			spec: spec("dev-1", "echo", "--http-header=x-dev-user=bob"),
+			err:  "an unauthenticated user may not intercept",
+		},
+		{
+			name: "unknown user",
+			user: user("mallory"),
+			spec: spec("dev-1", "echo", "--http-header=x-dev-user=mallory"),
+			err:  `user "mallory" may not intercept`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := p.Check(tt.user, tt.spec)
+			if tt.err != "" {
+				require.Error(t, err)
+				var v *policy.Violation
+				require.True(t, errors.As(err, &v))
+				assert.Contains(t, err.Error(), tt.err)
+				assert.NotEmpty(t, v.Remedy)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.duration, d)
+		})
+	}
+}
+
+func TestCheckExec(t *testing.T) {
+	p, err := policy.Parse([]byte(testPolicy))
+	require.NoError(t, err)
+
+	// Required headers don't apply to exec.
+	d, err := p.CheckExec(user("bob"), "dev-1", "echo")
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Hour, d)
+
+	d, err = p.CheckExec(user("alice"), "dev-alice", "echo")
+	require.NoError(t, err)
+	assert.Equal(t, 8*time.Hour, d)
+
+	d, err = p.CheckExec(user("carol"), "prod", "echo")
+	require.NoError(t, err)
+	assert.Zero(t, d)
+
+	_, err = p.CheckExec(user("carol"), "prod", "web")
+	var v *policy.Violation
+	require.True(t, errors.As(err, &v))
+	assert.Contains(t, v.Reason, `user "carol" may not run commands in workload web in namespace prod`)
+	assert.Contains(t, v.Remedy, "exec.policy.rules")
+
+	_, err = p.CheckExec(nil, "prod", "echo")
+	require.True(t, errors.As(err, &v))
+	assert.Contains(t, v.Reason, "an unauthenticated user may not run commands")
+	assert.Contains(t, v.Remedy, "service account")
+
+	// Unlike intercepts, commands require a policy.
+	var empty *policy.Policy
+	_, err = empty.CheckExec(user("anyone"), "default", "echo")
+	require.True(t, errors.As(err, &v))
+	assert.Contains(t, v.Reason, "the exec policy has no rules")
+
+	// and an authenticated user, even when the rule permits all users.
+	p, err = policy.Parse([]byte("rules:\n  - namespaces: [default]\n"))
+	require.NoError(t, err)
+	_, err = p.CheckExec(user("anyone"), "default", "echo")
+	require.NoError(t, err)
+	_, err = p.CheckExec(nil, "default", "echo")
+	require.True(t, errors.As(err, &v))
+	assert.Contains(t, v.Reason, "an unauthenticated user may not run commands")
+}
+
+func TestEmptyPolicy(t *testing.T) {
+	p, err := policy.Parse(nil)
+	require.NoError(t, err)
+	d, err := p.Check(nil, spec("default", "echo"))
+	require.NoError(t, err)
+	assert.Zero(t, d)
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := policy.Parse([]byte("rules:\n  - maxDuration: forever\n"))
+	assert.ErrorContains(t, err, "invalid maxDuration")
+	_, err = policy.Parse([]byte("rules:\n  - maxDuration: -1h\n"))
+	assert.ErrorContains(t, err, "must not be negative")
+	_, err = policy.Parse([]byte("rules:\n  -\n"))
+	assert.ErrorContains(t, err, "rule 0 is empty")
+}
+
+func TestUserName(t *testing.T) {
+	assert.Equal(t, "alice", policy.UserName(&rpc.ClientInfo{Name: "mallory@laptop", Identity: user("alice")}))
+	assert.Equal(t, "unauthenticated:alice", policy.UserName(&rpc.ClientInfo{Name: "alice@laptop"}))
+	assert.Equal(t, "unauthenticated:bob", policy.UserName(&rpc.ClientInfo{Name: "bob"}))
+}
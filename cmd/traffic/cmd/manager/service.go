@@ -1,7 +1,9 @@
 package manager
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"sort"
 	"strings"
 	"time"
@@ -13,6 +15,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	empty "google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"github.com/datawire/dlib/dlog"
@@ -20,19 +23,20 @@ import (
 	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/cluster"
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/config"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/federation"
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/state"
 	"github.com/telepresenceio/telepresence/v2/pkg/dnsproxy"
 	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/matcher"
+	"github.com/telepresenceio/telepresence/v2/pkg/pprof"
 	"github.com/telepresenceio/telepresence/v2/pkg/tracing"
 	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
 	"github.com/telepresenceio/telepresence/v2/pkg/version"
 )
 
 // Clock is the mechanism used by the Manager state to get the current time.
-type Clock interface {
-	Now() time.Time
-}
+type Clock = state.Clock
 
 type Service interface {
 	rpc.ManagerServer
@@ -45,7 +49,9 @@ type Service interface {
 
 	// unexported methods.
 	runConfigWatcher(context.Context) error
+	runFederation(context.Context) error
 	runSessionGCLoop(context.Context) error
+	serveExternal(context.Context) error
 	serveHTTP(context.Context) error
 	servePrometheus(context.Context) error
 }
@@ -57,8 +63,10 @@ type service struct {
 	state              state.State
 	clusterInfo        cluster.Info
 	configWatcher      config.Watcher
+	federation         *federation.Federation
 	activeHttpRequests int32
 	activeGrpcRequests int32
+	takeOvers          takeOvers
 
 	// Possibly extended version of the service. Use when calling interface methods.
 	self Service
@@ -84,6 +92,8 @@ func NewService(ctx context.Context) (Service, context.Context, error) {
 	// These are context dependent so build them once the pool is up
 	ret.clusterInfo = cluster.NewInfo(ctx)
 	ret.state = state.NewStateFunc(ctx)
+	ret.state.SetClock(ret.clock)
+	ret.federation = federation.New(ctx, ret.clusterInfo.ID(), ret.state, ret.clusterInfo.SetFederation)
 	ret.self = ret
 	return ret, ctx, nil
 }
@@ -147,11 +157,27 @@ func (s *service) ArriveAsClient(ctx context.Context, client *rpc.ClientInfo) (*
 		return nil, status.Errorf(codes.InvalidArgument, val)
 	}
 
+	// The identity is established here, never by the client, and the token must not be kept.
+	client.Identity = authenticate(ctx, client.KubeToken)
+	client.KubeToken = ""
+
 	installId := client.GetInstallId()
+	var sessionID, token string
+	if rs := client.GetResume(); rs != nil {
+		if err := s.state.ResumeClient(client, s.clock.Now()); err != nil {
+			return nil, err
+		}
+		sessionID, token = rs.SessionId, rs.ResumptionToken
+		dlog.Infof(ctx, "Client session %s resumed", sessionID)
+	} else {
+		sessionID = s.state.AddClient(client, s.clock.Now())
+		token = s.state.NewResumptionToken(sessionID)
+	}
 	return &rpc.SessionInfo{
-		SessionId: s.state.AddClient(client, s.clock.Now()),
-		ClusterId: s.clusterInfo.ID(),
-		InstallId: &installId,
+		SessionId:       sessionID,
+		ClusterId:       s.clusterInfo.ID(),
+		InstallId:       &installId,
+		ResumptionToken: token,
 	}, nil
 }
 
@@ -403,6 +429,12 @@ func (s *service) WatchIntercepts(session *rpc.SessionInfo, stream rpc.Manager_W
 		}
 	}
 
+	// Intercepts that a client has in federated clusters are merged with its local intercepts.
+	var remoteChanged <-chan struct{}
+	if sessionID != "" && s.state.GetClient(sessionID) != nil {
+		remoteChanged = s.federation.InterceptsChanged()
+	}
+	var local map[string]*rpc.InterceptInfo
 	snapshotCh := s.state.WatchIntercepts(ctx, filter)
 	for {
 		select {
@@ -411,20 +443,12 @@ func (s *service) WatchIntercepts(session *rpc.SessionInfo, stream rpc.Manager_W
 				dlog.Debugf(ctx, "WatchIntercepts request cancelled")
 				return nil
 			}
-			dlog.Debugf(ctx, "WatchIntercepts sending update")
-			intercepts := make([]*rpc.InterceptInfo, 0, len(snapshot.State))
-			for _, intercept := range snapshot.State {
-				intercepts = append(intercepts, intercept)
-			}
-			resp := &rpc.InterceptInfoSnapshot{
-				Intercepts: intercepts,
-			}
-			sort.Slice(intercepts, func(i, j int) bool {
-				return intercepts[i].Id < intercepts[j].Id
-			})
-			if err := stream.Send(resp); err != nil {
-				dlog.Debugf(ctx, "WatchIntercepts encountered a write error: %v", err)
-				return err
+			local = snapshot.State
+		case <-remoteChanged:
+			remoteChanged = s.federation.InterceptsChanged()
+			if local == nil {
+				// Wait for the initial snapshot.
+				continue
 			}
 		case <-ctx.Done():
 			dlog.Debugf(ctx, "WatchIntercepts context cancelled")
@@ -433,6 +457,24 @@ func (s *service) WatchIntercepts(session *rpc.SessionInfo, stream rpc.Manager_W
 			dlog.Debugf(ctx, "WatchIntercepts session cancelled")
 			return nil
 		}
+		dlog.Debugf(ctx, "WatchIntercepts sending update")
+		intercepts := make([]*rpc.InterceptInfo, 0, len(local))
+		for _, intercept := range local {
+			intercepts = append(intercepts, intercept)
+		}
+		if remoteChanged != nil {
+			intercepts = append(intercepts, s.federation.Intercepts(sessionID)...)
+		}
+		resp := &rpc.InterceptInfoSnapshot{
+			Intercepts: intercepts,
+		}
+		sort.Slice(intercepts, func(i, j int) bool {
+			return intercepts[i].Id < intercepts[j].Id
+		})
+		if err := stream.Send(resp); err != nil {
+			dlog.Debugf(ctx, "WatchIntercepts encountered a write error: %v", err)
+			return err
+		}
 	}
 }
 
@@ -443,6 +485,18 @@ func (s *service) PrepareIntercept(ctx context.Context, request *rpc.CreateInter
 	span := trace.SpanFromContext(ctx)
 	tracing.RecordInterceptSpec(span, request.InterceptSpec)
 
+	if client := s.state.GetClient(request.GetSession().GetSessionId()); client != nil {
+		if client.Observe {
+			return nil, errObserving(request.GetSession().GetSessionId())
+		}
+		if request.InterceptSpec.GetCluster() != "" {
+			// The traffic-manager of the federated cluster enforces its own policy.
+			return s.federation.PrepareIntercept(ctx, request)
+		}
+		if _, err := s.checkInterceptPolicy(ctx, client, request.InterceptSpec); err != nil {
+			return nil, err
+		}
+	}
 	return s.state.PrepareIntercept(ctx, request)
 }
 
@@ -460,21 +514,62 @@ func (s *service) CreateIntercept(ctx context.Context, ciReq *rpc.CreateIntercep
 	if client == nil {
 		return nil, status.Errorf(codes.NotFound, "Client session %q not found", sessionID)
 	}
+	if client.Observe {
+		return nil, errObserving(sessionID)
+	}
+	if spec.GetCluster() != "" {
+		// The traffic-manager of the federated cluster enforces its own ownership rules and policy.
+		return s.federation.CreateIntercept(ctx, ciReq)
+	}
 
 	if val := validateIntercept(spec); val != "" {
 		return nil, status.Errorf(codes.InvalidArgument, val)
 	}
+	maxDuration, err := s.checkInterceptPolicy(ctx, client, spec)
+	if err != nil {
+		return nil, err
+	}
+	if md := spec.MaxDuration; md != nil && (maxDuration == 0 || md.AsDuration() < maxDuration) {
+		maxDuration = md.AsDuration()
+	}
 
 	interceptInfo, err := s.state.AddIntercept(sessionID, s.clusterInfo.ID(), client, ciReq)
 	if err != nil {
 		return nil, err
 	}
+	if interceptInfo != nil && maxDuration > 0 {
+		expiresAt := timestamppb.New(s.clock.Now().Add(maxDuration))
+		interceptInfo = s.state.UpdateIntercept(interceptInfo.Id, func(ii *rpc.InterceptInfo) {
+			ii.ExpiresAt = expiresAt
+		})
+	}
 	if interceptInfo != nil {
 		tracing.RecordInterceptInfo(span, interceptInfo)
 	}
 	return interceptInfo, nil
 }
 
+// errObserving is returned when a client that connected in observer mode attempts to intercept.
+func errObserving(sessionID string) error {
+	return status.Errorf(codes.PermissionDenied, "Client session %q is observing and cannot intercept", sessionID)
+}
+
+// checkInterceptPolicy returns the maximum duration that the intercept policy permits for the given
+// intercept, or a PermissionDenied error when the policy refuses it. Zero means unlimited.
+func (s *service) checkInterceptPolicy(ctx context.Context, client *rpc.ClientInfo, spec *rpc.InterceptSpec) (time.Duration, error) {
+	p, err := s.configWatcher.GetInterceptPolicy()
+	if err != nil {
+		return 0, status.Errorf(codes.FailedPrecondition,
+			"The intercept policy of the traffic-manager is invalid: %v. Ask your cluster administrator to correct it", err)
+	}
+	d, err := p.Check(client.GetIdentity(), spec)
+	if err != nil {
+		dlog.Infof(ctx, "Intercept %s refused by policy: %v", spec.Name, err)
+		return 0, status.Error(codes.PermissionDenied, err.Error())
+	}
+	return d, nil
+}
+
 func (s *service) MakeInterceptID(_ context.Context, sessionID string, name string) (string, error) {
 	// When something without a session ID (e.g. System A) calls this function,
 	// it is sending the intercept ID as the name, so we use that.
@@ -510,6 +605,9 @@ func (s *service) RemoveIntercept(ctx context.Context, riReq *rpc.RemoveIntercep
 	}
 
 	if !s.state.RemoveIntercept(sessionID + ":" + name) {
+		if found, err := s.federation.RemoveIntercept(ctx, sessionID, name); found {
+			return &empty.Empty{}, err
+		}
 		return nil, status.Errorf(codes.NotFound, "Intercept named %q not found", name)
 	}
 
@@ -524,11 +622,91 @@ func (s *service) GetIntercept(ctx context.Context, request *rpc.GetInterceptReq
 	}
 	if intercept, ok := s.state.GetIntercept(interceptID); ok {
 		return intercept, nil
+	} else if intercept = s.federation.GetIntercept(request.GetSession().GetSessionId(), request.GetName()); intercept != nil {
+		return intercept, nil
 	} else {
 		return nil, status.Errorf(codes.NotFound, "Intercept named %q not found", request.Name)
 	}
 }
 
+// ReportInterceptMetrics lets an agent report the metrics of the intercepts that it serves.
+func (s *service) ReportInterceptMetrics(ctx context.Context, report *rpc.InterceptMetricsReport) (*empty.Empty, error) {
+	sessionID := report.GetSession().GetSessionId()
+	if s.state.GetAgent(sessionID) == nil {
+		return nil, status.Errorf(codes.NotFound, "Agent session %q not found", sessionID)
+	}
+	s.state.ReportInterceptMetrics(sessionID, report.Metrics)
+	return &empty.Empty{}, nil
+}
+
+// GetInterceptMetrics returns the metrics of an intercept, summed over all agents that serve it.
+func (s *service) GetInterceptMetrics(ctx context.Context, request *rpc.GetInterceptRequest) (*rpc.InterceptMetrics, error) {
+	interceptID, err := s.MakeInterceptID(ctx, request.GetSession().GetSessionId(), request.GetName())
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := s.state.GetIntercept(interceptID); !ok {
+		return nil, status.Errorf(codes.NotFound, "Intercept named %q not found", request.Name)
+	}
+	return s.state.InterceptMetrics(interceptID), nil
+}
+
+// maxSampleDuration limits the duration of a SampleIntercept.
+const maxSampleDuration = 10 * time.Minute
+
+// SampleIntercept asks the agents of a workload to count the requests that they receive, and how many of them
+// match the proposed filter, without redirecting anything.
+func (s *service) SampleIntercept(ctx context.Context, request *rpc.SampleInterceptRequest) (*rpc.SampleResult, error) {
+	ctx = managerutil.WithSessionInfo(ctx, request.GetSession())
+	dlog.Debugf(ctx, "SampleIntercept called: %s.%s", request.AgentName, request.Namespace)
+
+	if s.state.GetClient(request.GetSession().GetSessionId()) == nil {
+		return nil, status.Errorf(codes.NotFound, "Client session %q not found", request.GetSession().GetSessionId())
+	}
+	d := request.Duration.AsDuration()
+	if d <= 0 || d > maxSampleDuration {
+		return nil, status.Errorf(codes.InvalidArgument, "sample duration must be positive and at most %s", maxSampleDuration)
+	}
+	if _, err := matcher.NewRequestFromMap(request.Filter); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid filter: %v", err)
+	}
+	result := s.state.SampleAgents(ctx, request)
+	if result.Agents == 0 {
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"no traffic-agent of %s.%s answered. The workload has no traffic-agent, or its traffic-agent is too old to sample requests",
+			request.AgentName, request.Namespace)
+	}
+	return result, nil
+}
+
+// AgentSampleResult lets an agent report the result of a sample request.
+func (s *service) AgentSampleResult(ctx context.Context, result *rpc.SampleAgentResult) (*empty.Empty, error) {
+	ctx = managerutil.WithSessionInfo(ctx, result.GetSession())
+	dlog.Debugf(ctx, "AgentSampleResult called %s", result.Id)
+	s.state.PostSampleResult(result)
+	return &empty.Empty{}, nil
+}
+
+func (s *service) WatchSampleRequests(session *rpc.SessionInfo, stream rpc.Manager_WatchSampleRequestsServer) error {
+	ctx := managerutil.WithSessionInfo(stream.Context(), session)
+	dlog.Debugf(ctx, "WatchSampleRequests called")
+	rqCh := s.state.WatchSampleRequests(session.SessionId)
+	for {
+		select {
+		case <-s.ctx.Done():
+			return nil
+		case rq := <-rqCh:
+			if rq == nil {
+				return nil
+			}
+			if err := stream.Send(rq); err != nil {
+				dlog.Errorf(ctx, "WatchSampleRequests.Send() failed: %v", err)
+				return nil
+			}
+		}
+	}
+}
+
 // ReviewIntercept lets an agent approve or reject an intercept.
 func (s *service) ReviewIntercept(ctx context.Context, rIReq *rpc.ReviewInterceptRequest) (*empty.Empty, error) {
 	ctx = managerutil.WithSessionInfo(ctx, rIReq.GetSession())
@@ -589,14 +767,35 @@ func (s *service) removeExcludedEnvVars(ctx context.Context, envVars map[string]
 }
 
 func (s *service) Tunnel(server rpc.Manager_TunnelServer) error {
-	ctx := server.Context()
+	return tunnel.AcceptStreams(server.Context(), server, s.tunnel)
+}
+
+// tunnel serves one stream of a tunnel. A tunnel that multiplexes many streams calls it once for each stream.
+func (s *service) tunnel(ctx context.Context, server tunnel.GRPCStream) error {
 	stream, err := tunnel.NewServerStream(ctx, server)
 	if err != nil {
 		return status.Errorf(codes.FailedPrecondition, "failed to connect stream: %v", err)
 	}
+	if s.state.GetClient(stream.SessionID()) != nil {
+		// A stream that responds to a dial request is awaited by a traffic-agent, or by a federation peer,
+		// and must never be forwarded.
+		if ss := s.state.GetSession(stream.SessionID()); ss != nil && ss.AwaitingBidiMapOwnerSessionID(stream) == "" {
+			if forwarded, err := s.federation.Tunnel(ctx, stream); forwarded {
+				return err
+			}
+		}
+	}
 	return s.state.Tunnel(ctx, stream)
 }
 
+// runFederation maintains the connections to the traffic-managers that this traffic-manager federates with.
+func (s *service) runFederation(ctx context.Context) error {
+	if s.federation == nil {
+		return nil
+	}
+	return s.federation.Run(ctx)
+}
+
 func (s *service) WatchDial(session *rpc.SessionInfo, stream rpc.Manager_WatchDialServer) error {
 	ctx := managerutil.WithSessionInfo(stream.Context(), session)
 	dlog.Debugf(ctx, "WatchDial called")
@@ -720,6 +919,13 @@ func (s *service) LookupDNS(ctx context.Context, request *rpc.DNSRequest) (*rpc.
 	qtn := dns2.TypeToString[qType]
 	dlog.Debugf(ctx, "LookupDNS %s %s", request.Name, qtn)
 
+	if rsp, forwarded, err := s.federation.LookupDNS(ctx, request); forwarded {
+		if err != nil {
+			dlog.Errorf(ctx, "LookupDNS on federation peer %s %s: %v", request.Name, qtn, err)
+		}
+		return rsp, err
+	}
+
 	rrs, rCode, err := s.state.AgentsLookupDNS(ctx, request.GetSession().GetSessionId(), request)
 	if err != nil {
 		dlog.Errorf(ctx, "AgentsLookupDNS %s %s: %v", request.Name, qtn, err)
@@ -799,10 +1005,50 @@ func (s *service) WatchClusterInfo(session *rpc.SessionInfo, stream rpc.Manager_
 	return s.clusterInfo.Watch(ctx, stream)
 }
 
+// GetUsageReport returns the usage per user since the given time.
+func (s *service) GetUsageReport(ctx context.Context, request *rpc.UsageRequest) (*rpc.UsageReport, error) {
+	dlog.Debugf(ctx, "GetUsageReport called")
+	var since time.Time
+	if request.Since != nil {
+		since = request.Since.AsTime()
+	}
+	return s.state.UsageReport(since, s.clock.Now()), nil
+}
+
+const (
+	// defaultProfileDuration is the time that a "cpu" or "trace" profile is collected unless the request says otherwise.
+	defaultProfileDuration = 30 * time.Second
+
+	// maxProfileDuration is the longest time that a "cpu" or "trace" profile may be collected.
+	maxProfileDuration = 5 * time.Minute
+)
+
+// GetProfile returns a pprof profile of the traffic-manager.
+func (s *service) GetProfile(ctx context.Context, request *rpc.ProfileRequest) (*rpc.ProfileResponse, error) {
+	dlog.Debugf(ctx, "GetProfile %s called", request.Profile)
+	d := request.Duration.AsDuration()
+	switch {
+	case d <= 0:
+		d = defaultProfileDuration
+	case d > maxProfileDuration:
+		return nil, status.Errorf(codes.InvalidArgument, "a profile can't be collected for longer than %s", maxProfileDuration)
+	}
+	bf := bytes.Buffer{}
+	if err := pprof.WriteProfile(ctx, &bf, request.Profile, d, int(request.Debug)); err != nil {
+		code := codes.Internal
+		if errors.Is(err, pprof.ErrUnknownProfile) {
+			code = codes.InvalidArgument
+		}
+		return nil, status.Error(code, err.Error())
+	}
+	return &rpc.ProfileResponse{Data: bf.Bytes()}, nil
+}
+
 const agentSessionTTL = 15 * time.Second
 
 // expire removes stale sessions.
 func (s *service) expire(ctx context.Context) {
 	now := s.clock.Now()
 	s.state.ExpireSessions(ctx, now.Add(-managerutil.GetEnv(ctx).ClientConnectionTTL), now.Add(-agentSessionTTL))
+	s.state.ExpireIntercepts(ctx, now)
 }
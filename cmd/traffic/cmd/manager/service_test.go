@@ -5,12 +5,16 @@ import (
 	"encoding/json"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
 	empty "google.golang.org/protobuf/types/known/emptypb"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
@@ -288,6 +292,268 @@ func TestConnect(t *testing.T) {
 	a.NoError(err)
 }
 
+func TestObserverCannotIntercept(t *testing.T) {
+	dlog.SetFallbackLogger(dlog.WrapTB(t, false))
+	ctx := dlog.NewTestContext(t, true)
+	a := assert.New(t)
+
+	testClients := testdata.GetTestClients(t)
+	testAgents := testdata.GetTestAgents(t)
+
+	conn := getTestClientConn(ctx, t)
+	defer conn.Close()
+	client := rpc.NewManagerClient(conn)
+
+	observer := proto.Clone(testClients["alice"]).(*rpc.ClientInfo)
+	observer.Observe = true
+	sess, err := client.ArriveAsClient(ctx, observer)
+	a.NoError(err)
+	helloSess, err := client.ArriveAsAgent(ctx, testAgents["hello"])
+	a.NoError(err)
+
+	ir := &rpc.CreateInterceptRequest{
+		Session: sess,
+		InterceptSpec: &rpc.InterceptSpec{
+			Name:       "observed",
+			Namespace:  "default",
+			Client:     observer.Name,
+			Agent:      testAgents["hello"].Name,
+			Mechanism:  "tcp",
+			TargetHost: "asdf",
+			TargetPort: 9876,
+		},
+	}
+	_, err = client.PrepareIntercept(ctx, ir)
+	a.Equal(codes.PermissionDenied, status.Code(err))
+	_, err = client.CreateIntercept(ctx, ir)
+	a.Equal(codes.PermissionDenied, status.Code(err))
+
+	_, err = client.Depart(ctx, sess)
+	a.NoError(err)
+	_, err = client.Depart(ctx, helloSess)
+	a.NoError(err)
+}
+
+func TestInterceptDuration(t *testing.T) {
+	dlog.SetFallbackLogger(dlog.WrapTB(t, false))
+	ctx := dlog.NewTestContext(t, true)
+	a := assert.New(t)
+
+	testClients := testdata.GetTestClients(t)
+	testAgents := testdata.GetTestAgents(t)
+
+	conn := getTestClientConn(ctx, t)
+	defer conn.Close()
+	client := rpc.NewManagerClient(conn)
+
+	sess, err := client.ArriveAsClient(ctx, testClients["alice"])
+	a.NoError(err)
+	helloSess, err := client.ArriveAsAgent(ctx, testAgents["hello"])
+	a.NoError(err)
+
+	spec := &rpc.InterceptSpec{
+		Name:        "temporary",
+		Namespace:   "default",
+		Client:      testClients["alice"].Name,
+		Agent:       testAgents["hello"].Name,
+		Mechanism:   "tcp",
+		TargetHost:  "asdf",
+		TargetPort:  9876,
+		MaxDuration: durationpb.New(-time.Minute),
+	}
+	_, err = client.CreateIntercept(ctx, &rpc.CreateInterceptRequest{Session: sess, InterceptSpec: spec})
+	a.Equal(codes.InvalidArgument, status.Code(err))
+
+	spec.MaxDuration = durationpb.New(45 * time.Minute)
+	before := time.Now()
+	ii, err := client.CreateIntercept(ctx, &rpc.CreateInterceptRequest{Session: sess, InterceptSpec: spec})
+	a.NoError(err)
+	if a.NotNil(ii.ExpiresAt) {
+		a.WithinDuration(before.Add(45*time.Minute), ii.ExpiresAt.AsTime(), 5*time.Second)
+	}
+
+	_, err = client.Depart(ctx, sess)
+	a.NoError(err)
+	_, err = client.Depart(ctx, helloSess)
+	a.NoError(err)
+}
+
+func TestInterceptFaultRules(t *testing.T) {
+	dlog.SetFallbackLogger(dlog.WrapTB(t, false))
+	ctx := dlog.NewTestContext(t, true)
+	a := assert.New(t)
+
+	testClients := testdata.GetTestClients(t)
+	testAgents := testdata.GetTestAgents(t)
+
+	conn := getTestClientConn(ctx, t)
+	defer conn.Close()
+	client := rpc.NewManagerClient(conn)
+
+	sess, err := client.ArriveAsClient(ctx, testClients["alice"])
+	a.NoError(err)
+	helloSess, err := client.ArriveAsAgent(ctx, testAgents["hello"])
+	a.NoError(err)
+
+	spec := &rpc.InterceptSpec{
+		Name:       "faulty",
+		Namespace:  "default",
+		Client:     testClients["alice"].Name,
+		Agent:      testAgents["hello"].Name,
+		Mechanism:  "tcp",
+		TargetHost: "asdf",
+		TargetPort: 9876,
+	}
+	for _, rule := range []*rpc.FaultRule{
+		{Percent: 0, AbortStatus: 503},
+		{Percent: 101, AbortStatus: 503},
+		{Percent: 50, AbortStatus: 99},
+		{Percent: 50, Delay: durationpb.New(-time.Second)},
+		{Percent: 50},
+		{Percent: 50, AbortStatus: 503, Match: map[string]string{":path-regex:": "("}},
+	} {
+		spec.FaultRules = []*rpc.FaultRule{rule}
+		_, err = client.CreateIntercept(ctx, &rpc.CreateInterceptRequest{Session: sess, InterceptSpec: spec})
+		a.Equal(codes.InvalidArgument, status.Code(err), "rule %v", rule)
+	}
+
+	spec.FaultRules = nil
+	spec.FaultsOnly = true
+	_, err = client.CreateIntercept(ctx, &rpc.CreateInterceptRequest{Session: sess, InterceptSpec: spec})
+	a.Equal(codes.InvalidArgument, status.Code(err))
+
+	spec.FaultRules = []*rpc.FaultRule{{
+		Match:           map[string]string{":path-prefix:": "/api"},
+		Percent:         10,
+		Delay:           durationpb.New(2 * time.Second),
+		AbortStatus:     503,
+		ResponseHeaders: map[string]string{"Retry-After": "30"},
+	}}
+	ii, err := client.CreateIntercept(ctx, &rpc.CreateInterceptRequest{Session: sess, InterceptSpec: spec})
+	a.NoError(err)
+	if a.Len(ii.Spec.FaultRules, 1) {
+		a.Equal(int32(503), ii.Spec.FaultRules[0].AbortStatus)
+	}
+	a.True(ii.Spec.FaultsOnly)
+
+	_, err = client.Depart(ctx, sess)
+	a.NoError(err)
+	_, err = client.Depart(ctx, helloSess)
+	a.NoError(err)
+}
+
+func TestTakeOverIntercept(t *testing.T) {
+	dlog.SetFallbackLogger(dlog.WrapTB(t, false))
+	ctx := dlog.NewTestContext(t, true)
+	a := assert.New(t)
+
+	testClients := testdata.GetTestClients(t)
+	testAgents := testdata.GetTestAgents(t)
+
+	conn := getTestClientConn(ctx, t)
+	defer conn.Close()
+	client := rpc.NewManagerClient(conn)
+
+	aliceSess, err := client.ArriveAsClient(ctx, testClients["alice"])
+	a.NoError(err)
+	bobSess, err := client.ArriveAsClient(ctx, testClients["bob"])
+	a.NoError(err)
+	helloSess, err := client.ArriveAsAgent(ctx, testAgents["hello"])
+	a.NoError(err)
+
+	spec := func(c *rpc.ClientInfo) *rpc.InterceptSpec {
+		return &rpc.InterceptSpec{
+			Name:       "hello",
+			Namespace:  "default",
+			Client:     c.Name,
+			Agent:      testAgents["hello"].Name,
+			Mechanism:  "tcp",
+			TargetHost: "asdf",
+			TargetPort: 9876,
+		}
+	}
+	aliceIntercepts := func() int {
+		ii, err := client.GetIntercept(ctx, &rpc.GetInterceptRequest{Session: aliceSess, Name: "hello"})
+		if status.Code(err) == codes.NotFound {
+			return 0
+		}
+		a.NoError(err)
+		a.NotNil(ii)
+		return 1
+	}
+	takeOver := func() *rpc.TakeOverResult {
+		r, err := client.TakeOverIntercept(ctx, &rpc.TakeOverRequest{Session: bobSess, InterceptSpec: spec(testClients["bob"])})
+		a.NoError(err)
+		return r
+	}
+
+	// Intercepts of other ports don't conflict.
+	otherPort := spec(testClients["alice"])
+	otherPort.Name = "hello-8081"
+	otherPort.ServicePortIdentifier = "8081"
+	otherPort.ServicePort = 8081
+	_, err = client.CreateIntercept(ctx, &rpc.CreateInterceptRequest{Session: aliceSess, InterceptSpec: otherPort})
+	a.NoError(err)
+	tr, err := client.TakeOverIntercept(ctx, &rpc.TakeOverRequest{Session: bobSess, InterceptSpec: &rpc.InterceptSpec{
+		Name:                  "hello",
+		Namespace:             "default",
+		Client:                testClients["bob"].Name,
+		Agent:                 testAgents["hello"].Name,
+		Mechanism:             "tcp",
+		ServicePortIdentifier: "8080",
+		ServicePort:           8080,
+	}})
+	a.NoError(err)
+	a.True(tr.Accepted)
+	_, err = client.RemoveIntercept(ctx, &rpc.RemoveInterceptRequest2{Session: aliceSess, Name: otherPort.Name})
+	a.NoError(err)
+
+	// Alice is present but can't be asked, so her intercept isn't handed over.
+	_, err = client.CreateIntercept(ctx, &rpc.CreateInterceptRequest{Session: aliceSess, InterceptSpec: spec(testClients["alice"])})
+	a.NoError(err)
+	tr = takeOver()
+	a.False(tr.Accepted)
+	a.Contains(tr.Message, "cannot be asked")
+	a.Equal(1, aliceIntercepts())
+
+	// Alice is asked and refuses.
+	wCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.WatchTakeOverRequests(wCtx, aliceSess)
+	a.NoError(err)
+	_, err = stream.Header()
+	a.NoError(err)
+
+	answer := func(accept bool) {
+		rq, err := stream.Recv()
+		if a.NoError(err) {
+			a.Equal(testClients["bob"].Name, rq.Claimant)
+			a.Equal(aliceSess.SessionId+":hello", rq.InterceptId)
+			_, err = client.AnswerTakeOver(ctx, &rpc.TakeOverAnswer{Session: aliceSess, Id: rq.Id, Accept: accept})
+			a.NoError(err)
+		}
+	}
+	go answer(false)
+	tr = takeOver()
+	a.False(tr.Accepted)
+	a.Contains(tr.Message, "refused")
+	a.Equal(1, aliceIntercepts())
+
+	// Alice is asked and accepts.
+	go answer(true)
+	a.True(takeOver().Accepted)
+	a.Equal(0, aliceIntercepts())
+
+	// Answers to unknown requests are refused.
+	_, err = client.AnswerTakeOver(ctx, &rpc.TakeOverAnswer{Session: aliceSess, Id: "nonexistent", Accept: true})
+	a.Equal(codes.NotFound, status.Code(err))
+
+	for _, sess := range []*rpc.SessionInfo{aliceSess, bobSess, helloSess} {
+		_, err = client.Depart(ctx, sess)
+		a.NoError(err)
+	}
+}
+
 func getTestClientConn(ctx context.Context, t *testing.T) *grpc.ClientConn {
 	const bufsize = 64 * 1024
 	var cancel func()
@@ -0,0 +1,84 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/datawire/dlib/dcontext"
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/state"
+)
+
+// sessionStoreInterval is how often the state is saved. Saves of an unchanged state are skipped.
+const sessionStoreInterval = time.Second
+
+// newSessionStore returns the store of the given type, or nil when the state is kept in memory only.
+func newSessionStore(ctx context.Context, storeType string) (state.Store, error) {
+	switch storeType {
+	case "", state.StoreTypeMemory:
+		return nil, nil
+	case state.StoreTypeConfigMap:
+		// The host name of a container is the name of its pod.
+		owner, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		env := managerutil.GetEnv(ctx)
+		return state.NewConfigMapStore(k8sapi.GetK8sInterface(ctx), env.ManagerNamespace, state.StoreConfigMapName, owner), nil
+	default:
+		return nil, fmt.Errorf("invalid SESSION_STORE %q. Valid values are %q and %q", storeType, state.StoreTypeMemory, state.StoreTypeConfigMap)
+	}
+}
+
+// restoreSessions restores the sessions and intercepts that were saved by a previous traffic-manager.
+func restoreSessions(ctx context.Context, st state.State, store state.Store) {
+	snap, err := store.Load(ctx)
+	if err != nil {
+		dlog.Errorf(ctx, "unable to restore sessions: %v", err)
+		return
+	}
+	if snap != nil {
+		st.Restore(ctx, snap, time.Now())
+	}
+}
+
+// runSessionStoreLoop saves the state periodically, and a final time when the traffic-manager shuts down.
+// Saving stops when another traffic-manager claims the store.
+func runSessionStoreLoop(ctx context.Context, st state.State, store state.Store) error {
+	ticker := time.NewTicker(sessionStoreInterval)
+	defer ticker.Stop()
+	lastErr := ""
+	save := func(ctx context.Context) bool {
+		err := store.Save(ctx, st.Snapshot())
+		switch {
+		case err == nil:
+			lastErr = ""
+		case errors.Is(err, state.ErrNotOwner):
+			dlog.Warnf(ctx, "%v. Sessions are no longer saved by this traffic-manager", err)
+			return false
+		case err.Error() != lastErr:
+			// The same error is likely to be repeated every interval, so it's only logged once.
+			lastErr = err.Error()
+			dlog.Error(ctx, err)
+		}
+		return true
+	}
+	for {
+		select {
+		case <-ticker.C:
+			if !save(ctx) {
+				return nil
+			}
+		case <-ctx.Done():
+			ctx, cancel := context.WithTimeout(dcontext.WithoutCancel(ctx), 3*time.Second)
+			defer cancel()
+			save(ctx)
+			return nil
+		}
+	}
+}
@@ -243,6 +243,9 @@ func (s *state) loadAgentConfig(
 		if sce, err = gc.Generate(ctx, wl); err != nil {
 			return nil, err
 		}
+		if err = checkResourceQuotas(ctx, wl, sce.AgentConfig().Resources); err != nil {
+			return nil, err
+		}
 		if err = update(sce); err != nil {
 			return nil, err
 		}
@@ -404,8 +407,9 @@ func (s *state) waitForAgent(ctx context.Context, name, namespace string, failed
 						continue
 					}
 					msg = fmt.Sprintf(
-						"%s\nHint: if the error mentions resource quota, the traffic-agent's requested resources can be configured by providing values to telepresence helm install",
-						msg)
+						"%s\nHint: if the error mentions resource quota, the traffic-agent's requested resources can be configured by providing values to telepresence helm install, "+
+							"or per workload using the %s and %s annotations",
+						msg, agentmap.AgentResourcesAnnotation, agentmap.AgentInitResourcesAnnotation)
 				default:
 					// Something went wrong, but it might not be fatal. There are several events logged that are just
 					// warnings where the action will be retried and eventually succeed.
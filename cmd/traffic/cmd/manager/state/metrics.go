@@ -0,0 +1,127 @@
+package state
+
+import (
+	"sort"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+// interceptMetricsStore retains the latest intercept metrics that each agent has reported.
+type interceptMetricsStore struct {
+	sync.Mutex
+	byAgent map[string][]*rpc.InterceptMetrics // keyed by agent session ID
+}
+
+func newInterceptMetricsStore() *interceptMetricsStore {
+	return &interceptMetricsStore{byAgent: make(map[string][]*rpc.InterceptMetrics)}
+}
+
+func (ms *interceptMetricsStore) report(sessionID string, metrics []*rpc.InterceptMetrics) {
+	ms.Lock()
+	if len(metrics) == 0 {
+		delete(ms.byAgent, sessionID)
+	} else {
+		ms.byAgent[sessionID] = metrics
+	}
+	ms.Unlock()
+}
+
+func (ms *interceptMetricsStore) agentGone(sessionID string) {
+	ms.Lock()
+	delete(ms.byAgent, sessionID)
+	ms.Unlock()
+}
+
+func (ms *interceptMetricsStore) interceptGone(interceptID string) {
+	ms.Lock()
+	for sessionID, metrics := range ms.byAgent {
+		kept := metrics[:0]
+		for _, m := range metrics {
+			if m.InterceptId != interceptID {
+				kept = append(kept, m)
+			}
+		}
+		if len(kept) == 0 {
+			delete(ms.byAgent, sessionID)
+		} else {
+			ms.byAgent[sessionID] = kept
+		}
+	}
+	ms.Unlock()
+}
+
+// sum returns the metrics of the given intercept, summed over all agents that reported them. The agents are
+// visited in session ID order, so that the choice of latency histogram is stable when their bounds differ.
+func (ms *interceptMetricsStore) sum(interceptID string) *rpc.InterceptMetrics {
+	total := &rpc.InterceptMetrics{InterceptId: interceptID}
+	ms.Lock()
+	defer ms.Unlock()
+	sessionIDs := make([]string, 0, len(ms.byAgent))
+	for sessionID := range ms.byAgent {
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	sort.Strings(sessionIDs)
+	for _, sessionID := range sessionIDs {
+		contributed := false
+		for _, m := range ms.byAgent[sessionID] {
+			if m.InterceptId == interceptID {
+				addInterceptMetrics(total, m)
+				contributed = true
+			}
+		}
+		if contributed {
+			total.Agents++
+		}
+	}
+	return total
+}
+
+// addInterceptMetrics adds the metrics m to total. The latency histogram of m is ignored when its bounds
+// differ from the ones of total, which can happen when agents of different versions serve the intercept.
+func addInterceptMetrics(total, m *rpc.InterceptMetrics) {
+	total.Requests += m.Requests
+	total.Errors += m.Errors
+	if lr := m.LastRequest; lr != nil && (total.LastRequest == nil || total.LastRequest.AsTime().Before(lr.AsTime())) {
+		total.LastRequest = lr
+	}
+	if len(m.LatencyCounts) != len(m.LatencyBounds)+1 {
+		return
+	}
+	if total.LatencyCounts == nil {
+		total.LatencyBounds = m.LatencyBounds
+		total.LatencyCounts = make([]uint64, len(m.LatencyCounts))
+		total.LatencySum = durationpb.New(0)
+	} else if !sameBounds(total.LatencyBounds, m.LatencyBounds) {
+		return
+	}
+	for i, c := range m.LatencyCounts {
+		total.LatencyCounts[i] += c
+	}
+	total.LatencySum = durationpb.New(total.LatencySum.AsDuration() + m.LatencySum.AsDuration())
+}
+
+func sameBounds(a, b []*durationpb.Duration) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !proto.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReportInterceptMetrics replaces the intercept metrics of the agent with the given session ID.
+func (s *state) ReportInterceptMetrics(sessionID string, metrics []*rpc.InterceptMetrics) {
+	s.metrics.report(sessionID, metrics)
+}
+
+// InterceptMetrics returns the metrics of the given intercept, summed over all agents that serve it.
+func (s *state) InterceptMetrics(interceptID string) *rpc.InterceptMetrics {
+	return s.metrics.sum(interceptID)
+}
@@ -0,0 +1,60 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+func TestInterceptMetricsStore(t *testing.T) {
+	t0 := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+	bounds := []*durationpb.Duration{durationpb.New(10 * time.Millisecond), durationpb.New(time.Second)}
+	metrics := func(id string, requests, errors uint64, counts []uint64, lastRequest time.Time) *rpc.InterceptMetrics {
+		return &rpc.InterceptMetrics{
+			InterceptId:   id,
+			Requests:      requests,
+			Errors:        errors,
+			LatencyBounds: bounds,
+			LatencyCounts: counts,
+			LatencySum:    durationpb.New(time.Duration(requests) * time.Millisecond),
+			LastRequest:   timestamppb.New(lastRequest),
+		}
+	}
+
+	ms := newInterceptMetricsStore()
+	ms.report("agent-1", []*rpc.InterceptMetrics{
+		metrics("c:echo", 10, 1, []uint64{5, 3, 1}, t0),
+		metrics("c:hello", 4, 0, []uint64{4, 0, 0}, t0),
+	})
+	ms.report("agent-2", []*rpc.InterceptMetrics{metrics("c:echo", 6, 2, []uint64{1, 2, 1}, t0.Add(time.Minute))})
+
+	total := ms.sum("c:echo")
+	assert.Equal(t, int32(2), total.Agents)
+	assert.Equal(t, uint64(16), total.Requests)
+	assert.Equal(t, uint64(3), total.Errors)
+	assert.Equal(t, []uint64{6, 5, 2}, total.LatencyCounts)
+	assert.Equal(t, 16*time.Millisecond, total.LatencySum.AsDuration())
+	assert.Equal(t, t0.Add(time.Minute), total.LastRequest.AsTime())
+
+	// Histograms with other bounds are not added, but their counters are.
+	other := metrics("c:echo", 1, 0, []uint64{1, 0}, t0)
+	other.LatencyBounds = bounds[:1]
+	ms.report("agent-3", []*rpc.InterceptMetrics{other})
+	total = ms.sum("c:echo")
+	assert.Equal(t, int32(3), total.Agents)
+	assert.Equal(t, uint64(17), total.Requests)
+	assert.Equal(t, []uint64{6, 5, 2}, total.LatencyCounts)
+
+	// An empty report, a departed agent, or a removed intercept, drops the metrics.
+	ms.report("agent-3", nil)
+	ms.agentGone("agent-2")
+	assert.Equal(t, uint64(10), ms.sum("c:echo").Requests)
+	ms.interceptGone("c:echo")
+	assert.Equal(t, int32(0), ms.sum("c:echo").Agents)
+	assert.Equal(t, uint64(4), ms.sum("c:hello").Requests)
+}
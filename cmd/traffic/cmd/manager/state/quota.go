@@ -0,0 +1,130 @@
+package state
+
+import (
+	"context"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentmap"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+// quotaResources maps the compute resources that a ResourceQuota can limit to the resource name and
+// a function that returns the requests or limits of the container.
+var quotaResources = map[core.ResourceName]struct { //nolint:gochecknoglobals // constant
+	name core.ResourceName
+	list func(*core.ResourceRequirements) core.ResourceList
+}{
+	core.ResourceCPU:            {core.ResourceCPU, requests},
+	core.ResourceMemory:         {core.ResourceMemory, requests},
+	core.ResourceRequestsCPU:    {core.ResourceCPU, requests},
+	core.ResourceRequestsMemory: {core.ResourceMemory, requests},
+	core.ResourceLimitsCPU:      {core.ResourceCPU, limits},
+	core.ResourceLimitsMemory:   {core.ResourceMemory, limits},
+}
+
+func requests(rr *core.ResourceRequirements) core.ResourceList { return rr.Requests }
+func limits(rr *core.ResourceRequirements) core.ResourceList   { return rr.Limits }
+
+// checkResourceQuotas returns an error when adding a traffic-agent container with the given resources to
+// the pods of the given workload would exceed a ResourceQuota in the workload's namespace, so that the
+// user is told why instead of waiting for pods that will never be scheduled. Quotas with scopes are
+// ignored, because they might not apply to the workload's pods.
+func checkResourceQuotas(ctx context.Context, wl k8sapi.Workload, rr *core.ResourceRequirements) error {
+	replicas := wl.Replicas()
+	if replicas == 0 {
+		return nil
+	}
+	ns := wl.GetNamespace()
+	api := k8sapi.GetK8sInterface(ctx).CoreV1()
+	qs, err := api.ResourceQuotas(ns).List(ctx, meta.ListOptions{})
+	if err != nil {
+		// Not fatal. The traffic-manager might lack the permission to list quotas.
+		dlog.Debugf(ctx, "unable to list resource quotas in namespace %s: %v", ns, err)
+		return nil
+	}
+	if len(qs.Items) == 0 {
+		return nil
+	}
+	var lrs []core.LimitRange
+	if ll, err := api.LimitRanges(ns).List(ctx, meta.ListOptions{}); err == nil {
+		lrs = ll.Items
+	} else {
+		dlog.Debugf(ctx, "unable to list limit ranges in namespace %s: %v", ns, err)
+	}
+	rr = withLimitRangeDefaults(rr, lrs)
+
+	for i := range qs.Items {
+		q := &qs.Items[i]
+		if len(q.Spec.Scopes) > 0 || q.Spec.ScopeSelector != nil {
+			continue
+		}
+		for hn, hard := range q.Status.Hard {
+			qr, ok := quotaResources[hn]
+			if !ok {
+				continue
+			}
+			need, ok := qr.list(rr)[qr.name]
+			if !ok {
+				return errcat.User.Newf(
+					"the pods of %s %s.%s cannot get a traffic-agent, because ResourceQuota %s requires that %s is specified, and it isn't. "+
+						"Specify it using the agent.resources Helm value or the %s annotation",
+					wl.GetKind(), wl.GetName(), ns, q.Name, hn, agentmap.AgentResourcesAnnotation)
+			}
+			need = multiply(need, replicas)
+			total := q.Status.Used[hn]
+			total.Add(need)
+			if total.Cmp(hard) > 0 {
+				used := q.Status.Used[hn]
+				return errcat.User.Newf(
+					"the pods of %s %s.%s cannot get a traffic-agent, because it would exceed ResourceQuota %s: %s used %s + traffic-agents %s > hard %s. "+
+						"Lower the traffic-agent's resources using the agent.resources Helm value or the %s annotation",
+					wl.GetKind(), wl.GetName(), ns, q.Name, hn, used.String(), need.String(), hard.String(), agentmap.AgentResourcesAnnotation)
+			}
+		}
+	}
+	return nil
+}
+
+// withLimitRangeDefaults returns the given resource requirements, which may be nil, with the defaults that
+// the given limit ranges would assign to the container. Like the API server, a missing request defaults to the
+// limit of the same resource.
+func withLimitRangeDefaults(rr *core.ResourceRequirements, lrs []core.LimitRange) *core.ResourceRequirements {
+	if rr == nil {
+		rr = &core.ResourceRequirements{}
+	} else {
+		rr = rr.DeepCopy()
+	}
+	setDefaults := func(dst *core.ResourceList, src core.ResourceList) {
+		for n, q := range src {
+			if _, ok := (*dst)[n]; !ok {
+				if *dst == nil {
+					*dst = make(core.ResourceList)
+				}
+				(*dst)[n] = q
+			}
+		}
+	}
+	for _, lr := range lrs {
+		for _, li := range lr.Spec.Limits {
+			if li.Type == core.LimitTypeContainer {
+				setDefaults(&rr.Limits, li.Default)
+				setDefaults(&rr.Requests, li.DefaultRequest)
+			}
+		}
+	}
+	setDefaults(&rr.Requests, rr.Limits)
+	return rr
+}
+
+func multiply(q resource.Quantity, n int) resource.Quantity {
+	m := q.DeepCopy()
+	for i := 1; i < n; i++ {
+		m.Add(q)
+	}
+	return m
+}
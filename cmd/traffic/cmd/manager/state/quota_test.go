@@ -0,0 +1,105 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+)
+
+func TestCheckResourceQuotas(t *testing.T) {
+	dep := &apps.Deployment{
+		TypeMeta:   meta.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+		ObjectMeta: meta.ObjectMeta{Name: "echo", Namespace: "some-ns"},
+		Status:     apps.DeploymentStatus{Replicas: 2},
+	}
+	wl, err := k8sapi.WrapWorkload(dep)
+	require.NoError(t, err)
+
+	quota := func(hard, used core.ResourceList) *core.ResourceQuota {
+		return &core.ResourceQuota{
+			ObjectMeta: meta.ObjectMeta{Name: "compute", Namespace: "some-ns"},
+			Status:     core.ResourceQuotaStatus{Hard: hard, Used: used},
+		}
+	}
+	agentResources := &core.ResourceRequirements{
+		Requests: core.ResourceList{core.ResourceMemory: resource.MustParse("64Mi")},
+	}
+
+	tests := []struct {
+		name    string
+		objects []runtime.Object
+		rr      *core.ResourceRequirements
+		wantErr string
+	}{
+		{
+			name: "no quota",
+			rr:   agentResources,
+		},
+		{
+			name: "within quota",
+			objects: []runtime.Object{quota(
+				core.ResourceList{core.ResourceRequestsMemory: resource.MustParse("1Gi")},
+				core.ResourceList{core.ResourceRequestsMemory: resource.MustParse("512Mi")},
+			)},
+			rr: agentResources,
+		},
+		{
+			name: "exceeds quota",
+			objects: []runtime.Object{quota(
+				core.ResourceList{core.ResourceRequestsMemory: resource.MustParse("1Gi")},
+				core.ResourceList{core.ResourceRequestsMemory: resource.MustParse("960Mi")},
+			)},
+			rr:      agentResources,
+			wantErr: "would exceed ResourceQuota compute",
+		},
+		{
+			name: "unspecified resource",
+			objects: []runtime.Object{quota(
+				core.ResourceList{core.ResourceLimitsCPU: resource.MustParse("2")},
+				nil,
+			)},
+			rr:      agentResources,
+			wantErr: "requires that limits.cpu is specified",
+		},
+		{
+			name: "limit range default",
+			objects: []runtime.Object{
+				quota(
+					core.ResourceList{core.ResourceLimitsCPU: resource.MustParse("2")},
+					core.ResourceList{core.ResourceLimitsCPU: resource.MustParse("1")},
+				),
+				&core.LimitRange{
+					ObjectMeta: meta.ObjectMeta{Name: "defaults", Namespace: "some-ns"},
+					Spec: core.LimitRangeSpec{Limits: []core.LimitRangeItem{{
+						Type:    core.LimitTypeContainer,
+						Default: core.ResourceList{core.ResourceCPU: resource.MustParse("500m")},
+					}}},
+				},
+			},
+			rr: agentResources,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := k8sapi.WithK8sInterface(dlog.NewTestContext(t, false), fake.NewSimpleClientset(tt.objects...))
+			err := checkResourceQuotas(ctx, wl, tt.rr)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
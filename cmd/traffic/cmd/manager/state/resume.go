@@ -0,0 +1,74 @@
+package state
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+// NewResumptionToken creates a token that the client session with the given ID can be resumed with. Only the
+// hash of the token is retained, so each call replaces the token of the session. An empty string is returned
+// when there's no such client session.
+func (s *state) NewResumptionToken(sessionID string) string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	css, ok := s.sessions[sessionID].(*clientSessionState)
+	if !ok {
+		return ""
+	}
+	css.tokenHash = hashToken(token)
+	return token
+}
+
+// ResumeClient resumes the session given by the Resume field of the given ClientInfo. A session that is known
+// is resumed when the token matches. A session that isn't known, because it has expired or because it belongs
+// to another traffic-manager instance, is recreated under the same ID.
+func (s *state) ResumeClient(client *rpc.ClientInfo, now time.Time) error {
+	rs := client.GetResume()
+	sessionID := rs.GetSessionId()
+	if sessionID == "" || rs.GetResumptionToken() == "" {
+		return status.Error(codes.InvalidArgument, "a resumed session must have a session ID and a resumption token")
+	}
+	tokenHash := hashToken(rs.ResumptionToken)
+	client = proto.Clone(client).(*rpc.ClientInfo)
+	client.Resume = nil
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess, ok := s.sessions[sessionID]; ok {
+		css, ok := sess.(*clientSessionState)
+		if !ok || subtle.ConstantTimeCompare([]byte(css.tokenHash), []byte(tokenHash)) != 1 {
+			return status.Errorf(codes.PermissionDenied, "session %q cannot be resumed with the given token", sessionID)
+		}
+		css.SetLastMarked(now)
+		s.clients.Store(sessionID, client)
+		return nil
+	}
+
+	s.clients.Store(sessionID, client)
+	css := newClientSessionState(s.ctx, now)
+	css.tokenHash = tokenHash
+	s.sessions[sessionID] = css
+	s.usage.connected(client, now)
+	return nil
+}
+
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,47 @@
+package state
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+func (s *suiteState) TestResumeClient() {
+	// given
+	t := s.T()
+	now := time.Now()
+	client := &rpc.ClientInfo{Name: "my-client", Namespace: "default", InstallId: "1234"}
+	sessionID := s.state.AddClient(client, now)
+	token := s.state.NewResumptionToken(sessionID)
+	require.NotEmpty(t, token)
+	resume := func(sessionID, token string) error {
+		return s.state.ResumeClient(&rpc.ClientInfo{
+			Name:      "my-client",
+			Namespace: "default",
+			InstallId: "1234",
+			Resume:    &rpc.SessionInfo{SessionId: sessionID, ResumptionToken: token},
+		}, now.Add(time.Minute))
+	}
+
+	// then
+	assert.NoError(t, resume(sessionID, token))
+	assert.Equal(t, now.Add(time.Minute), s.state.GetSession(sessionID).LastMarked())
+	assert.Nil(t, s.state.GetClient(sessionID).Resume, "the token must not be retained")
+	assert.Equal(t, codes.PermissionDenied, status.Code(resume(sessionID, "wrong")))
+	assert.Equal(t, codes.InvalidArgument, status.Code(resume(sessionID, "")))
+
+	// when the session has expired
+	s.state.ExpireSessions(s.ctx, now.Add(2*time.Minute), now.Add(2*time.Minute))
+	require.Nil(t, s.state.GetSession(sessionID))
+
+	// then it's recreated with the same ID, and can be resumed with the same token again.
+	require.NoError(t, resume(sessionID, token))
+	assert.Equal(t, "my-client", s.state.GetClient(sessionID).Name)
+	assert.NoError(t, resume(sessionID, token))
+	assert.Equal(t, codes.PermissionDenied, status.Code(resume(sessionID, "wrong")))
+}
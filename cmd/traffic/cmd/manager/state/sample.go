@@ -0,0 +1,137 @@
+package state
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+// sampleResultGrace is how long the manager waits for the sample results of the agents after the sample duration
+// has passed.
+const sampleResultGrace = 5 * time.Second
+
+// SampleAgents asks the agents of the workload in the given request to sample the requests that they receive, and
+// returns the sum of their results. The result has zero agents when no agent responded in time.
+func (s *state) SampleAgents(ctx context.Context, request *rpc.SampleInterceptRequest) *rpc.SampleResult {
+	agents := s.agents.LoadAllMatching(func(_ string, ai *rpc.AgentInfo) bool {
+		return ai.Name == request.AgentName && ai.Namespace == request.Namespace
+	})
+	sr := &rpc.SampleRequest{
+		Id:                    uuid.New().String(),
+		ServicePortIdentifier: request.ServicePortIdentifier,
+		Filter:                request.Filter,
+		Duration:              request.Duration,
+	}
+	timeout, cancel := context.WithTimeout(ctx, request.Duration.AsDuration()+sampleResultGrace)
+	defer cancel()
+
+	rsCh := make(chan *rpc.SampleResult, len(agents))
+	wg := sync.WaitGroup{}
+	wg.Add(len(agents))
+	for aID := range agents {
+		go func(aID string) {
+			defer func() {
+				s.endSample(aID, sr.Id)
+				wg.Done()
+			}()
+			rch := s.startSample(timeout, aID, sr)
+			if rch == nil {
+				return
+			}
+			select {
+			case <-timeout.Done():
+			case rs, ok := <-rch:
+				if ok {
+					rsCh <- rs
+				}
+			}
+		}(aID)
+	}
+	wg.Wait()
+	close(rsCh)
+
+	total := &rpc.SampleResult{Duration: request.Duration}
+	for rs := range rsCh {
+		total.Requests += rs.Requests
+		total.Matching += rs.Matching
+		total.UninspectedConnections += rs.UninspectedConnections
+		total.Agents++
+	}
+	return total
+}
+
+// PostSampleResult receives a sample result from an agent and places it in the channel that corresponds to the
+// sample request.
+func (s *state) PostSampleResult(result *rpc.SampleAgentResult) {
+	s.mu.RLock()
+	as, ok := s.sessions[result.GetSession().GetSessionId()].(*agentSessionState)
+	if ok {
+		var rch chan<- *rpc.SampleResult
+		if rch, ok = as.sampleResults[result.Id]; ok {
+			select {
+			case rch <- result.GetResult():
+			default:
+				ok = false
+			}
+		}
+	}
+	s.mu.RUnlock()
+	if !ok {
+		dlog.Debugf(s.ctx, "attempted to post sample result failed because there was no recipient. ID=%s", result.Id)
+	}
+}
+
+func (s *state) WatchSampleRequests(agentSessionID string) <-chan *rpc.SampleRequest {
+	s.mu.RLock()
+	ss, ok := s.sessions[agentSessionID]
+	s.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return ss.(*agentSessionState).sampleRequests
+}
+
+func (s *state) startSample(ctx context.Context, agentSessionID string, request *rpc.SampleRequest) <-chan *rpc.SampleResult {
+	var (
+		rch chan *rpc.SampleResult
+		as  *agentSessionState
+		ok  bool
+	)
+	s.mu.Lock()
+	if as, ok = s.sessions[agentSessionID].(*agentSessionState); ok {
+		rch = make(chan *rpc.SampleResult, 1)
+		as.sampleResults[request.Id] = rch
+	}
+	s.mu.Unlock()
+	if as == nil {
+		return nil
+	}
+	// The as.sampleRequests channel is closed when the session ends, so guard for panic. The result
+	// is nil when that happens.
+	defer func() {
+		_ = recover()
+	}()
+	select {
+	case <-ctx.Done():
+		// Agents that don't watch sample requests never receive it.
+		return nil
+	case as.sampleRequests <- request:
+	}
+	return rch
+}
+
+func (s *state) endSample(agentSessionID, id string) {
+	s.mu.Lock()
+	if as, ok := s.sessions[agentSessionID].(*agentSessionState); ok {
+		if rch, ok := as.sampleResults[id]; ok {
+			delete(as.sampleResults, id)
+			close(rch)
+		}
+	}
+	s.mu.Unlock()
+}
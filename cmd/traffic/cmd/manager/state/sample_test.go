@@ -0,0 +1,38 @@
+package state
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+func (s *suiteState) TestSampleAgents() {
+	t := s.T()
+	now := time.Now()
+	respond := func(agentID string, requests, matching uint64) {
+		rq := <-s.state.WatchSampleRequests(agentID)
+		assert.Equal(t, map[string]string{"x-user": "alice"}, rq.Filter)
+		s.state.PostSampleResult(&rpc.SampleAgentResult{
+			Session: &rpc.SessionInfo{SessionId: agentID},
+			Id:      rq.Id,
+			Result:  &rpc.SampleResult{Requests: requests, Matching: matching, Duration: rq.Duration},
+		})
+	}
+	go respond(s.state.AddAgent(&rpc.AgentInfo{Name: "hello", Namespace: "default", PodIp: "10.1.0.5"}, now), 10, 1)
+	go respond(s.state.AddAgent(&rpc.AgentInfo{Name: "hello", Namespace: "default", PodIp: "10.1.0.6"}, now), 20, 2)
+	otherID := s.state.AddAgent(&rpc.AgentInfo{Name: "hello", Namespace: "other", PodIp: "10.1.0.7"}, now)
+
+	r := s.state.SampleAgents(s.ctx, &rpc.SampleInterceptRequest{
+		Namespace: "default",
+		AgentName: "hello",
+		Filter:    map[string]string{"x-user": "alice"},
+		Duration:  durationpb.New(100 * time.Millisecond),
+	})
+	assert.Equal(t, int32(2), r.Agents)
+	assert.Equal(t, uint64(30), r.Requests)
+	assert.Equal(t, uint64(3), r.Matching)
+	assert.Len(t, s.state.WatchSampleRequests(otherID), 0)
+}
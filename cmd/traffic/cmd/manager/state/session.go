@@ -173,6 +173,9 @@ type clientSessionState struct {
 	sessionState
 	pool *tunnel.Pool
 
+	// tokenHash is the hash of the token that the session can be resumed with.
+	tokenHash string
+
 	consumptionMetrics *SessionConsumptionMetrics
 }
 
@@ -191,15 +194,19 @@ func newClientSessionState(ctx context.Context, ts time.Time) *clientSessionStat
 
 type agentSessionState struct {
 	sessionState
-	dnsRequests  chan *rpc.DNSRequest
-	dnsResponses map[string]chan *rpc.DNSResponse
+	dnsRequests    chan *rpc.DNSRequest
+	dnsResponses   map[string]chan *rpc.DNSResponse
+	sampleRequests chan *rpc.SampleRequest
+	sampleResults  map[string]chan *rpc.SampleResult
 }
 
 func newAgentSessionState(ctx context.Context, ts time.Time) *agentSessionState {
 	return &agentSessionState{
-		sessionState: newSessionState(ctx, ts),
-		dnsRequests:  make(chan *rpc.DNSRequest),
-		dnsResponses: make(map[string]chan *rpc.DNSResponse),
+		sessionState:   newSessionState(ctx, ts),
+		dnsRequests:    make(chan *rpc.DNSRequest),
+		dnsResponses:   make(map[string]chan *rpc.DNSResponse),
+		sampleRequests: make(chan *rpc.SampleRequest),
+		sampleResults:  make(map[string]chan *rpc.SampleResult),
 	}
 }
 
@@ -209,5 +216,10 @@ func (ss *agentSessionState) Cancel() {
 		delete(ss.dnsResponses, k)
 		close(lr)
 	}
+	close(ss.sampleRequests)
+	for k, sr := range ss.sampleResults {
+		delete(ss.sampleResults, k)
+		close(sr)
+	}
 	ss.sessionState.Cancel()
 }
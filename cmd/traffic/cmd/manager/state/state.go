@@ -27,9 +27,22 @@ import (
 	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
 )
 
+// Clock is the mechanism used by the state to get the current time.
+type Clock interface {
+	Now() time.Time
+}
+
+type wallClock struct{}
+
+func (wallClock) Now() time.Time {
+	return time.Now()
+}
+
 type State interface {
 	AddAgent(*rpc.AgentInfo, time.Time) string
 	AddClient(*rpc.ClientInfo, time.Time) string
+	NewResumptionToken(string) string
+	ResumeClient(*rpc.ClientInfo, time.Time) error
 	AddIntercept(string, string, *rpc.ClientInfo, *rpc.CreateInterceptRequest) (*rpc.InterceptInfo, error)
 	AddInterceptFinalizer(string, InterceptFinalizer) error
 	AgentsLookupDNS(context.Context, string, *rpc.DNSRequest) (dnsproxy.RRs, int, error)
@@ -39,6 +52,7 @@ type State interface {
 	CountSessions() int
 	CountTunnels() int
 	ExpireSessions(context.Context, time.Time, time.Time)
+	ExpireIntercepts(context.Context, time.Time)
 	GetAgent(string) *rpc.AgentInfo
 	GetAllClients() map[string]*rpc.ClientInfo
 	GetClient(string) *rpc.ClientInfo
@@ -46,14 +60,23 @@ type State interface {
 	GetSessionConsumptionMetrics(string) *SessionConsumptionMetrics
 	GetAllSessionConsumptionMetrics() map[string]*SessionConsumptionMetrics
 	GetIntercept(string) (*rpc.InterceptInfo, bool)
+	InterceptMetrics(string) *rpc.InterceptMetrics
+	LoadMatchingIntercepts(func(string, *rpc.InterceptInfo) bool) map[string]*rpc.InterceptInfo
+	UsageReport(since, now time.Time) *rpc.UsageReport
 	MarkSession(*rpc.RemainRequest, time.Time) bool
 	NewInterceptInfo(string, *rpc.SessionInfo, *rpc.CreateInterceptRequest) *rpc.InterceptInfo
 	PostLookupDNSResponse(*rpc.DNSAgentResponse)
+	PostSampleResult(*rpc.SampleAgentResult)
 	PrepareIntercept(context.Context, *rpc.CreateInterceptRequest) (*rpc.PreparedIntercept, error)
 	RemoveIntercept(string) bool
 	RemoveSession(context.Context, string)
+	ReportInterceptMetrics(string, []*rpc.InterceptMetrics)
+	Restore(context.Context, *Snapshot, time.Time)
+	SampleAgents(context.Context, *rpc.SampleInterceptRequest) *rpc.SampleResult
 	SessionDone(string) (<-chan struct{}, error)
+	SetClock(Clock)
 	SetTempLogLevel(context.Context, *rpc.LogLevelRequest)
+	Snapshot() *Snapshot
 	Tunnel(context.Context, tunnel.Stream) error
 	UpdateIntercept(string, func(*rpc.InterceptInfo)) *rpc.InterceptInfo
 	UpdateClient(sessionID string, apply func(*rpc.ClientInfo)) *rpc.ClientInfo
@@ -64,6 +87,7 @@ type State interface {
 	WatchDial(sessionID string) <-chan *rpc.DialRequest
 	WatchIntercepts(context.Context, func(sessionID string, intercept *rpc.InterceptInfo) bool) <-chan watchable.Snapshot[*rpc.InterceptInfo]
 	WatchLookupDNS(string) <-chan *rpc.DNSRequest
+	WatchSampleRequests(string) <-chan *rpc.SampleRequest
 }
 
 // state is the total state of the Traffic Manager.  A zero state is invalid; you must call
@@ -97,6 +121,9 @@ type state struct {
 	llSubs          *loglevelSubscribers
 	cfgMapLocks     map[string]*sync.Mutex
 	tunnelCounter   int32
+	usage           *usageLedger
+	metrics         *interceptMetricsStore
+	clock           Clock
 
 	// Possibly extended version of the state. Use when calling interface methods.
 	self State
@@ -106,6 +133,7 @@ var NewStateFunc = NewState //nolint:gochecknoglobals // extension point
 
 func NewState(ctx context.Context) State {
 	loglevel := os.Getenv("LOG_LEVEL")
+	clock := wallClock{}
 	s := &state{
 		ctx:             ctx,
 		sessions:        make(map[string]SessionState),
@@ -114,6 +142,9 @@ func NewState(ctx context.Context) State {
 		interceptStates: make(map[string]*interceptState),
 		timedLogLevel:   log.NewTimedLevel(loglevel, log.SetLevel),
 		llSubs:          newLoglevelSubscribers(),
+		usage:           newUsageLedger(clock.Now()),
+		metrics:         newInterceptMetricsStore(),
+		clock:           clock,
 	}
 	s.self = s
 	return s
@@ -123,6 +154,13 @@ func (s *state) SetSelf(self State) {
 	s.self = self
 }
 
+// SetClock sets the clock that the state uses to timestamp the usage of intercepts. It must be called
+// before the state is used, because it also restarts the collection of usage.
+func (s *state) SetClock(clock Clock) {
+	s.clock = clock
+	s.usage = newUsageLedger(clock.Now())
+}
+
 // unlockedCheckAgentsForIntercept (1) assumes that s.mu is already locked, and (2) checks the
 // status of all agents that would be relevant to the given intercept spec, and returns whether the
 // state of those agents would require transitioning to an error state.  If everything looks good,
@@ -263,6 +301,7 @@ func (s *state) unlockedRemoveSession(sessionID string) {
 			}
 			// remove the session
 			s.agents.Delete(sessionID)
+			s.metrics.agentGone(sessionID)
 		} else {
 			s.clients.Delete(sessionID)
 		}
@@ -291,6 +330,19 @@ func (s *state) ExpireSessions(ctx context.Context, clientMoment, agentMoment ti
 	}
 }
 
+// ExpireIntercepts removes the intercepts that expire before the given moment.
+func (s *state) ExpireIntercepts(ctx context.Context, moment time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, cept := range s.intercepts.LoadAll() {
+		if ea := cept.ExpiresAt; ea != nil && ea.AsTime().Before(moment) {
+			dlog.Infof(ctx, "Intercept %s removed. Its maximum duration has expired", id)
+			s.unlockedRemoveIntercept(id)
+		}
+	}
+}
+
 // SessionDone returns a channel that is closed when the session with the given ID terminates.  If
 // there is no such currently-live session, then an already-closed channel is returned.
 func (s *state) SessionDone(id string) (<-chan struct{}, error) {
@@ -304,6 +356,70 @@ func (s *state) SessionDone(id string) (<-chan struct{}, error) {
 	return sess.Done(), nil
 }
 
+// Snapshot returns a copy of the clients, agents, and intercepts of this state.
+func (s *state) Snapshot() *Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var tokenHashes map[string]string
+	for id, sess := range s.sessions {
+		if css, ok := sess.(*clientSessionState); ok && css.tokenHash != "" {
+			if tokenHashes == nil {
+				tokenHashes = make(map[string]string)
+			}
+			tokenHashes[id] = css.tokenHash
+		}
+	}
+	return &Snapshot{
+		Clients:     s.clients.LoadAll(),
+		Agents:      s.agents.LoadAll(),
+		Intercepts:  s.intercepts.LoadAll(),
+		TokenHashes: tokenHashes,
+	}
+}
+
+// Restore adds the sessions and intercepts of the given Snapshot, typically saved by a previous instance
+// of the traffic-manager. The restored sessions are marked at the given time, so they expire unless their
+// clients and agents call Remain. Intercepts of clients that aren't in the Snapshot are dropped.
+func (s *state) Restore(ctx context.Context, snap *Snapshot, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for sessionID, client := range snap.Clients {
+		if _, ok := s.sessions[sessionID]; ok {
+			continue
+		}
+		s.clients.Store(sessionID, client)
+		css := newClientSessionState(s.ctx, now)
+		css.tokenHash = snap.TokenHashes[sessionID]
+		s.sessions[sessionID] = css
+	}
+	for sessionID, agent := range snap.Agents {
+		if _, ok := s.sessions[sessionID]; ok {
+			continue
+		}
+		s.agents.Store(sessionID, agent)
+		if s.agentsByName[agent.Name] == nil {
+			s.agentsByName[agent.Name] = make(map[string]*rpc.AgentInfo)
+		}
+		s.agentsByName[agent.Name][sessionID] = agent
+		s.sessions[sessionID] = newAgentSessionState(s.ctx, now)
+	}
+	for interceptID, cept := range snap.Intercepts {
+		client, ok := s.clients.Load(cept.ClientSession.GetSessionId())
+		if !ok {
+			dlog.Debugf(ctx, "Intercept %s not restored. Its client session is gone", interceptID)
+			continue
+		}
+		if _, hasConflict := s.intercepts.LoadOrStore(interceptID, cept); hasConflict {
+			continue
+		}
+		s.interceptStates[interceptID] = newInterceptState(interceptID)
+		s.usage.interceptStarted(interceptID, client, cept.Spec, now)
+	}
+	dlog.Infof(ctx, "Restored %d client sessions, %d agent sessions, and %d intercepts",
+		len(snap.Clients), len(snap.Agents), s.intercepts.CountAll())
+}
+
 // Sessions: Clients ///////////////////////////////////////////////////////////////////////////////
 
 func (s *state) AddClient(client *rpc.ClientInfo, now time.Time) string {
@@ -325,6 +441,7 @@ func (s *state) addClient(sessionID string, client *rpc.ClientInfo, now time.Tim
 	}
 
 	s.sessions[sessionID] = newClientSessionState(s.ctx, now)
+	s.usage.connected(client, now)
 	return sessionID
 }
 
@@ -468,6 +585,7 @@ func (s *state) AddIntercept(sessionID, clusterID string, client *rpc.ClientInfo
 
 	state := newInterceptState(cept.Id)
 	s.interceptStates[interceptID] = state
+	s.usage.interceptStarted(interceptID, client, spec, s.clock.Now())
 
 	return cept, nil
 }
@@ -578,6 +696,10 @@ func (s *state) unlockedRemoveIntercept(interceptID string) bool {
 		delete(s.interceptStates, interceptID)
 		state.terminate(s.ctx, intercept)
 	}
+	if didDelete {
+		s.usage.interceptEnded(interceptID, s.clock.Now())
+		s.metrics.interceptGone(interceptID)
+	}
 
 	return didDelete
 }
@@ -586,6 +708,11 @@ func (s *state) GetIntercept(interceptID string) (*rpc.InterceptInfo, bool) {
 	return s.intercepts.Load(interceptID)
 }
 
+// LoadMatchingIntercepts returns the intercepts for which the given filter returns true.
+func (s *state) LoadMatchingIntercepts(filter func(string, *rpc.InterceptInfo) bool) map[string]*rpc.InterceptInfo {
+	return s.intercepts.LoadAllMatching(filter)
+}
+
 func (s *state) WatchIntercepts(
 	ctx context.Context,
 	filter func(sessionID string, intercept *rpc.InterceptInfo) bool,
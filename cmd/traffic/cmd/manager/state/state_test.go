@@ -8,6 +8,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	"github.com/datawire/dlib/dlog"
 	"github.com/telepresenceio/telepresence/rpc/v2/manager"
@@ -33,6 +34,9 @@ func (s *suiteState) SetupTest() {
 		interceptStates: make(map[string]*interceptState),
 		timedLogLevel:   log.NewTimedLevel("debug", log.SetLevel),
 		llSubs:          newLoglevelSubscribers(),
+		usage:           newUsageLedger(time.Now()),
+		metrics:         newInterceptMetricsStore(),
+		clock:           wallClock{},
 	}
 }
 
@@ -187,6 +191,22 @@ func (s *suiteState) TestRemoveSession() {
 	assert.Len(s.T(), s.state.sessions, 0)
 }
 
+func (s *suiteState) TestExpireIntercepts() {
+	// given
+	now := time.Now()
+	s.state.intercepts.Store("expired", &rpc.InterceptInfo{Id: "expired", ExpiresAt: timestamppb.New(now.Add(-time.Second))})
+	s.state.intercepts.Store("active", &rpc.InterceptInfo{Id: "active", ExpiresAt: timestamppb.New(now.Add(time.Hour))})
+	s.state.intercepts.Store("unlimited", &rpc.InterceptInfo{Id: "unlimited"})
+
+	// when
+	s.state.ExpireIntercepts(s.ctx, now)
+
+	// then
+	_, ok := s.state.intercepts.Load("expired")
+	assert.False(s.T(), ok)
+	assert.Equal(s.T(), 2, s.state.intercepts.CountAll())
+}
+
 func TestSuiteState(testing *testing.T) {
 	suite.Run(testing, new(suiteState))
 }
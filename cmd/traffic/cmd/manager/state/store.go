@@ -0,0 +1,269 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	core "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+// Snapshot is the part of the state that a Store persists, so that the sessions of clients and agents,
+// and the intercepts that they have, survive a restart of the traffic-manager.
+type Snapshot struct {
+	Clients    map[string]*rpc.ClientInfo
+	Agents     map[string]*rpc.AgentInfo
+	Intercepts map[string]*rpc.InterceptInfo
+
+	// TokenHashes are the hashes of the resumption tokens of the client sessions.
+	TokenHashes map[string]string
+}
+
+// Store persists a Snapshot outside the traffic-manager pod.
+type Store interface {
+	// Load returns the last saved Snapshot, or nil when nothing has been saved. The store is claimed
+	// by the caller, so that a traffic-manager that saved the Snapshot can no longer overwrite it.
+	Load(context.Context) (*Snapshot, error)
+
+	// Save saves the given Snapshot. ErrNotOwner is returned when another traffic-manager has claimed
+	// the store.
+	Save(context.Context, *Snapshot) error
+}
+
+// ErrNotOwner is returned by Store.Save when another traffic-manager has claimed the store.
+var ErrNotOwner = errors.New("the session store has been claimed by another traffic-manager")
+
+const (
+	StoreTypeMemory    = "memory"
+	StoreTypeConfigMap = "configmap"
+
+	// StoreConfigMapName is the name of the ConfigMap that the configmap store uses.
+	StoreConfigMapName = "traffic-manager-state"
+
+	// StoreOwnerAnnotation is the annotation of the ConfigMap that names the traffic-manager that owns it.
+	StoreOwnerAnnotation = "telepresence.io/session-store-owner"
+
+	// maxStoreSize is the maximum size of the data in the ConfigMap. The API server rejects ConfigMaps
+	// that are larger than 1 MiB, and some room is left for the keys and the object's metadata.
+	maxStoreSize = 1000 * 1024
+)
+
+const (
+	clientsKey    = "clients"
+	agentsKey     = "agents"
+	interceptsKey = "intercepts"
+	tokensKey     = "tokenHashes"
+)
+
+type configMapStore struct {
+	ki        kubernetes.Interface
+	namespace string
+	name      string
+	owner     string
+
+	// resourceVersion is the version of the ConfigMap that was last loaded or saved, or empty when the
+	// ConfigMap doesn't exist. Updates use it, so that they fail rather than overwrite a change that
+	// another traffic-manager made.
+	resourceVersion string
+
+	// data is what was last loaded or saved. Saves of an unchanged Snapshot are skipped.
+	data map[string]string
+}
+
+// NewConfigMapStore returns a Store that persists the Snapshot in a ConfigMap with the given name in the
+// given namespace. The ConfigMap is created when it doesn't exist. The owner, typically the name of the
+// traffic-manager pod, identifies the traffic-manager that may save to the ConfigMap.
+func NewConfigMapStore(ki kubernetes.Interface, namespace, name, owner string) Store {
+	return &configMapStore{ki: ki, namespace: namespace, name: name, owner: owner}
+}
+
+func (c *configMapStore) Load(ctx context.Context) (snap *Snapshot, err error) {
+	api := c.ki.CoreV1().ConfigMaps(c.namespace)
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := api.Get(ctx, c.name, meta.GetOptions{})
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				c.resourceVersion = ""
+				return nil
+			}
+			return err
+		}
+		if cm.Annotations[StoreOwnerAnnotation] != c.owner {
+			// Claim the ConfigMap, so that the traffic-manager that saved it stops doing so.
+			if cm.Annotations == nil {
+				cm.Annotations = make(map[string]string, 1)
+			}
+			cm.Annotations[StoreOwnerAnnotation] = c.owner
+			if cm, err = api.Update(ctx, cm, meta.UpdateOptions{}); err != nil {
+				return err
+			}
+		}
+		c.resourceVersion = cm.ResourceVersion
+		c.data = cm.Data
+		snap, err = c.decode(cm)
+		return err
+	})
+	return snap, err
+}
+
+func (c *configMapStore) decode(cm *core.ConfigMap) (*Snapshot, error) {
+	if len(cm.Data) == 0 {
+		return nil, nil
+	}
+	var err error
+	snap := &Snapshot{}
+	if snap.Clients, err = decodeMessages[*rpc.ClientInfo](cm.Data[clientsKey]); err != nil {
+		return nil, fmt.Errorf("unable to decode %s in ConfigMap %s.%s: %w", clientsKey, c.name, c.namespace, err)
+	}
+	if snap.Agents, err = decodeMessages[*rpc.AgentInfo](cm.Data[agentsKey]); err != nil {
+		return nil, fmt.Errorf("unable to decode %s in ConfigMap %s.%s: %w", agentsKey, c.name, c.namespace, err)
+	}
+	if snap.Intercepts, err = decodeMessages[*rpc.InterceptInfo](cm.Data[interceptsKey]); err != nil {
+		return nil, fmt.Errorf("unable to decode %s in ConfigMap %s.%s: %w", interceptsKey, c.name, c.namespace, err)
+	}
+	if th := cm.Data[tokensKey]; th != "" {
+		if err = json.Unmarshal([]byte(th), &snap.TokenHashes); err != nil {
+			return nil, fmt.Errorf("unable to decode %s in ConfigMap %s.%s: %w", tokensKey, c.name, c.namespace, err)
+		}
+	}
+	return snap, nil
+}
+
+func (c *configMapStore) Save(ctx context.Context, snap *Snapshot) error {
+	data := make(map[string]string, 4)
+	var err error
+	if data[clientsKey], err = encodeMessages(snap.Clients); err != nil {
+		return err
+	}
+	agents := make(map[string]*rpc.AgentInfo, len(snap.Agents))
+	for id, agent := range snap.Agents {
+		if len(agent.Environment) > 0 {
+			// Only old agents send their environment, and it's large, so it isn't saved.
+			agent = proto.Clone(agent).(*rpc.AgentInfo)
+			agent.Environment = nil
+		}
+		agents[id] = agent
+	}
+	if data[agentsKey], err = encodeMessages(agents); err != nil {
+		return err
+	}
+	if data[interceptsKey], err = encodeMessages(snap.Intercepts); err != nil {
+		return err
+	}
+	if len(snap.TokenHashes) > 0 {
+		th, err := json.Marshal(snap.TokenHashes)
+		if err != nil {
+			return err
+		}
+		data[tokensKey] = string(th)
+	}
+	if reflect.DeepEqual(data, c.data) {
+		return nil
+	}
+	size := 0
+	for k, v := range data {
+		size += len(k) + len(v)
+	}
+	if size > maxStoreSize {
+		return fmt.Errorf("unable to save state in ConfigMap %s.%s: its size, %d bytes, exceeds the limit of %d bytes",
+			c.name, c.namespace, size, maxStoreSize)
+	}
+
+	api := c.ki.CoreV1().ConfigMaps(c.namespace)
+	retryable := func(err error) bool {
+		return k8serrors.IsConflict(err) || k8serrors.IsAlreadyExists(err) || k8serrors.IsNotFound(err)
+	}
+	err = retry.OnError(retry.DefaultRetry, retryable, func() error {
+		cm := &core.ConfigMap{
+			ObjectMeta: meta.ObjectMeta{
+				Name:            c.name,
+				Namespace:       c.namespace,
+				ResourceVersion: c.resourceVersion,
+				Annotations:     map[string]string{StoreOwnerAnnotation: c.owner},
+			},
+			Data: data,
+		}
+		var err error
+		if c.resourceVersion == "" {
+			cm, err = api.Create(ctx, cm, meta.CreateOptions{})
+		} else {
+			cm, err = api.Update(ctx, cm, meta.UpdateOptions{})
+		}
+		if err == nil {
+			c.resourceVersion = cm.ResourceVersion
+			return nil
+		}
+		if !retryable(err) {
+			return err
+		}
+		// The ConfigMap was changed, created, or deleted by someone else. Retry, unless another
+		// traffic-manager has claimed it.
+		cm, gErr := api.Get(ctx, c.name, meta.GetOptions{})
+		switch {
+		case k8serrors.IsNotFound(gErr):
+			c.resourceVersion = ""
+		case gErr != nil:
+			return gErr
+		case cm.Annotations[StoreOwnerAnnotation] != c.owner:
+			return ErrNotOwner
+		default:
+			c.resourceVersion = cm.ResourceVersion
+		}
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, ErrNotOwner) {
+			return err
+		}
+		return fmt.Errorf("unable to save state in ConfigMap %s.%s: %w", c.name, c.namespace, err)
+	}
+	c.data = data
+	return nil
+}
+
+// encodeMessages encodes the given map as a JSON object with the protojson encoding of each message.
+func encodeMessages[T proto.Message](m map[string]T) (string, error) {
+	rm := make(map[string]json.RawMessage, len(m))
+	for k, v := range m {
+		data, err := protojson.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		rm[k] = data
+	}
+	data, err := json.Marshal(rm)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func decodeMessages[T proto.Message](data string) (map[string]T, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var rm map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &rm); err != nil {
+		return nil, err
+	}
+	m := make(map[string]T, len(rm))
+	for k, v := range rm {
+		var msg T
+		msg = msg.ProtoReflect().Type().New().Interface().(T)
+		if err := protojson.Unmarshal(v, msg); err != nil {
+			return nil, err
+		}
+		m[k] = msg
+	}
+	return m, nil
+}
@@ -0,0 +1,154 @@
+package state
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+// versionedClientset returns a fake clientset that maintains the resource version of ConfigMaps, and rejects
+// updates of outdated versions, like the API server does.
+func versionedClientset() *fake.Clientset {
+	cs := fake.NewSimpleClientset()
+	gvr := core.SchemeGroupVersion.WithResource("configmaps")
+	version := 0
+	cs.PrependReactor("*", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		switch action.GetVerb() {
+		case "create":
+			cm := action.(k8stesting.CreateAction).GetObject().(*core.ConfigMap).DeepCopy()
+			version++
+			cm.ResourceVersion = strconv.Itoa(version)
+			return true, cm, cs.Tracker().Create(gvr, cm, cm.Namespace)
+		case "update":
+			cm := action.(k8stesting.UpdateAction).GetObject().(*core.ConfigMap).DeepCopy()
+			cur, err := cs.Tracker().Get(gvr, cm.Namespace, cm.Name)
+			if err != nil {
+				return true, nil, err
+			}
+			if cur.(*core.ConfigMap).ResourceVersion != cm.ResourceVersion {
+				return true, nil, k8serrors.NewConflict(gvr.GroupResource(), cm.Name, errors.New("the object has been modified"))
+			}
+			version++
+			cm.ResourceVersion = strconv.Itoa(version)
+			return true, cm, cs.Tracker().Update(gvr, cm, cm.Namespace)
+		}
+		return false, nil, nil
+	})
+	return cs
+}
+
+func (s *suiteState) TestSaveAndRestore() {
+	// given
+	t := s.T()
+	now := time.Now()
+	s.state.self = s.state
+	clientID := s.state.AddClient(&rpc.ClientInfo{Name: "my-client", Namespace: "default", InstallId: "1234"}, now)
+	token := s.state.NewResumptionToken(clientID)
+	agentID := s.state.AddAgent(&rpc.AgentInfo{Name: "hello", Namespace: "default", PodIp: "10.1.0.5", Environment: map[string]string{"A": "B"}}, now)
+	cept, err := s.state.AddIntercept(clientID, "cluster-1", s.state.GetClient(clientID), &rpc.CreateInterceptRequest{
+		InterceptSpec: &rpc.InterceptSpec{Name: "hello", Agent: "hello", Namespace: "default", Mechanism: "tcp"},
+	})
+	require.NoError(t, err)
+	s.state.intercepts.Store("orphan", &rpc.InterceptInfo{
+		Id:            "orphan",
+		Spec:          &rpc.InterceptSpec{Name: "orphan", Namespace: "default"},
+		ClientSession: &rpc.SessionInfo{SessionId: "gone"},
+	})
+
+	ki := versionedClientset()
+	store := NewConfigMapStore(ki, "ambassador", StoreConfigMapName, "tm-1")
+
+	// when
+	require.NoError(t, store.Save(s.ctx, s.state.Snapshot()))
+
+	// then
+	cm, err := ki.CoreV1().ConfigMaps("ambassador").Get(s.ctx, StoreConfigMapName, meta.GetOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, cm.Data[clientsKey], "my-client")
+	assert.Equal(t, "tm-1", cm.Annotations[StoreOwnerAnnotation])
+	assert.Equal(t, "B", s.state.GetAgent(agentID).Environment["A"], "the state must not be modified")
+
+	// when
+	restored := NewState(s.ctx).(*state)
+	snap, err := NewConfigMapStore(ki, "ambassador", StoreConfigMapName, "tm-2").Load(s.ctx)
+	require.NoError(t, err)
+	require.NotNil(t, snap)
+	later := now.Add(time.Minute)
+	restored.Restore(s.ctx, snap, later)
+
+	// then
+	assert.True(t, restored.MarkSession(&rpc.RemainRequest{Session: &rpc.SessionInfo{SessionId: clientID}}, later))
+	assert.Equal(t, "my-client", restored.GetClient(clientID).Name)
+	assert.Equal(t, "10.1.0.5", restored.GetAgent(agentID).PodIp)
+	assert.Empty(t, restored.GetAgent(agentID).Environment, "the environment of agents isn't saved")
+	assert.Len(t, restored.agentsByName["hello"], 1)
+	rc, ok := restored.GetIntercept(cept.Id)
+	require.True(t, ok)
+	assert.Equal(t, cept.Spec.Name, rc.Spec.Name)
+	_, ok = restored.GetIntercept("orphan")
+	assert.False(t, ok, "intercepts without a client session must not be restored")
+	assert.Equal(t, later, restored.GetSession(clientID).LastMarked())
+	resume := &rpc.SessionInfo{SessionId: clientID, ResumptionToken: token}
+	assert.NoError(t, restored.ResumeClient(&rpc.ClientInfo{Name: "my-client", Resume: resume}, later),
+		"the resumption token must be restored")
+}
+
+func (s *suiteState) TestLoadEmptyStore() {
+	snap, err := NewConfigMapStore(fake.NewSimpleClientset(), "ambassador", StoreConfigMapName, "tm-1").Load(s.ctx)
+	assert.NoError(s.T(), err)
+	assert.Nil(s.T(), snap)
+}
+
+func (s *suiteState) TestStoreOwnership() {
+	t := s.T()
+	ki := versionedClientset()
+	snap := func(name string) *Snapshot {
+		return &Snapshot{Clients: map[string]*rpc.ClientInfo{"c1": {Name: name}}}
+	}
+	oldStore := NewConfigMapStore(ki, "ambassador", StoreConfigMapName, "tm-1")
+	require.NoError(t, oldStore.Save(s.ctx, snap("one")))
+	require.NoError(t, oldStore.Save(s.ctx, snap("two")))
+
+	// A change that doesn't claim the ConfigMap doesn't prevent saving.
+	api := ki.CoreV1().ConfigMaps("ambassador")
+	cm, err := api.Get(s.ctx, StoreConfigMapName, meta.GetOptions{})
+	require.NoError(t, err)
+	cm.Labels = map[string]string{"edited": "true"}
+	_, err = api.Update(s.ctx, cm, meta.UpdateOptions{})
+	require.NoError(t, err)
+	require.NoError(t, oldStore.Save(s.ctx, snap("three")))
+
+	// A new traffic-manager claims the ConfigMap when it loads it.
+	newStore := NewConfigMapStore(ki, "ambassador", StoreConfigMapName, "tm-2")
+	loaded, err := newStore.Load(s.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "three", loaded.Clients["c1"].Name)
+
+	// so the old one can no longer overwrite it.
+	assert.ErrorIs(t, oldStore.Save(s.ctx, snap("four")), ErrNotOwner)
+	require.NoError(t, newStore.Save(s.ctx, snap("five")))
+
+	cm, err = api.Get(s.ctx, StoreConfigMapName, meta.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "tm-2", cm.Annotations[StoreOwnerAnnotation])
+	assert.Contains(t, cm.Data[clientsKey], "five")
+}
+
+func (s *suiteState) TestStoreSizeLimit() {
+	store := NewConfigMapStore(versionedClientset(), "ambassador", StoreConfigMapName, "tm-1")
+	err := store.Save(s.ctx, &Snapshot{Clients: map[string]*rpc.ClientInfo{"c1": {Name: strings.Repeat("x", maxStoreSize)}}})
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "exceeds the limit")
+}
@@ -0,0 +1,157 @@
+package state
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/policy"
+)
+
+// usageRetention is how long the usage ledger retains connects and intercepts that have ended.
+const usageRetention = 90 * 24 * time.Hour
+
+type connectUsage struct {
+	user      string
+	namespace string
+	at        time.Time
+}
+
+type interceptUsage struct {
+	user      string
+	namespace string
+	start     time.Time
+	end       time.Time // zero while the intercept is active
+}
+
+// usageLedger records the connects and intercepts of clients, so that the traffic-manager can
+// report usage per user. The ledger is kept in memory only. It isn't part of the Snapshot, because
+// 90 days of usage wouldn't fit in the ConfigMap of the configmap Store, so usage that was collected
+// before a restart of the traffic-manager is lost. Reports tell when collection started.
+type usageLedger struct {
+	sync.Mutex
+	collectedSince time.Time
+	connects       []connectUsage
+	intercepts     []*interceptUsage
+	active         map[string]*interceptUsage // keyed by intercept ID
+}
+
+func newUsageLedger(now time.Time) *usageLedger {
+	return &usageLedger{collectedSince: now, active: make(map[string]*interceptUsage)}
+}
+
+func (u *usageLedger) connected(client *rpc.ClientInfo, now time.Time) {
+	u.Lock()
+	u.prune(now)
+	u.connects = append(u.connects, connectUsage{user: policy.UserName(client), namespace: client.Namespace, at: now})
+	u.Unlock()
+}
+
+func (u *usageLedger) interceptStarted(interceptID string, client *rpc.ClientInfo, spec *rpc.InterceptSpec, now time.Time) {
+	iu := &interceptUsage{user: policy.UserName(client), namespace: spec.Namespace, start: now}
+	u.Lock()
+	u.prune(now)
+	u.intercepts = append(u.intercepts, iu)
+	u.active[interceptID] = iu
+	u.Unlock()
+}
+
+func (u *usageLedger) interceptEnded(interceptID string, now time.Time) {
+	u.Lock()
+	if iu, ok := u.active[interceptID]; ok {
+		iu.end = now
+		delete(u.active, interceptID)
+	}
+	u.Unlock()
+}
+
+// prune removes entries that ended before the retention period. Must be called with the lock held.
+func (u *usageLedger) prune(now time.Time) {
+	cutoff := now.Add(-usageRetention)
+	i := 0
+	for i < len(u.connects) && u.connects[i].at.Before(cutoff) {
+		i++
+	}
+	u.connects = u.connects[i:]
+
+	ics := u.intercepts[:0]
+	for _, iu := range u.intercepts {
+		if iu.end.IsZero() || !iu.end.Before(cutoff) {
+			ics = append(ics, iu)
+		}
+	}
+	u.intercepts = ics
+	if cutoff.After(u.collectedSince) {
+		u.collectedSince = cutoff
+	}
+}
+
+// report returns the usage per user between since and now.
+func (u *usageLedger) report(since, now time.Time) *rpc.UsageReport {
+	u.Lock()
+	defer u.Unlock()
+	u.prune(now)
+	if since.Before(u.collectedSince) {
+		since = u.collectedSince
+	}
+
+	users := make(map[string]*rpc.UserUsage)
+	namespaces := make(map[string]map[string]struct{})
+	get := func(user, namespace string) *rpc.UserUsage {
+		uu, ok := users[user]
+		if !ok {
+			uu = &rpc.UserUsage{User: user, InterceptDuration: durationpb.New(0)}
+			users[user] = uu
+			namespaces[user] = make(map[string]struct{})
+		}
+		if namespace != "" {
+			namespaces[user][namespace] = struct{}{}
+		}
+		return uu
+	}
+	for _, cu := range u.connects {
+		if !cu.at.Before(since) {
+			get(cu.user, cu.namespace).Connects++
+		}
+	}
+	for _, iu := range u.intercepts {
+		start, end := iu.start, iu.end
+		if end.IsZero() {
+			end = now
+		}
+		if end.Before(since) {
+			continue
+		}
+		if start.Before(since) {
+			start = since
+		}
+		uu := get(iu.user, iu.namespace)
+		uu.Intercepts++
+		uu.InterceptDuration = durationpb.New(uu.InterceptDuration.AsDuration() + end.Sub(start))
+	}
+
+	r := &rpc.UsageReport{
+		Since:          timestamppb.New(since),
+		Until:          timestamppb.New(now),
+		CollectedSince: timestamppb.New(u.collectedSince),
+		Users:          make([]*rpc.UserUsage, 0, len(users)),
+	}
+	for user, uu := range users {
+		for ns := range namespaces[user] {
+			uu.Namespaces = append(uu.Namespaces, ns)
+		}
+		sort.Strings(uu.Namespaces)
+		r.Users = append(r.Users, uu)
+	}
+	sort.Slice(r.Users, func(i, j int) bool { return r.Users[i].User < r.Users[j].User })
+	return r
+}
+
+// UsageReport returns the usage per user between since and now.
+func (s *state) UsageReport(since, now time.Time) *rpc.UsageReport {
+	return s.usage.report(since, now)
+}
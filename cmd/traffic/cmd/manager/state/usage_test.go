@@ -0,0 +1,80 @@
+package state
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+func TestUsageReport(t *testing.T) {
+	t0 := time.Date(2023, time.March, 1, 0, 0, 0, 0, time.UTC)
+	u := newUsageLedger(t0)
+	alice := &rpc.ClientInfo{Name: "alice@laptop", Namespace: "dev-alice", Identity: &rpc.UserIdentity{Username: "alice"}}
+	bob := &rpc.ClientInfo{Name: "bob@desktop", Namespace: "default"}
+
+	u.connected(alice, t0.Add(time.Hour))
+	u.connected(bob, t0.Add(2*time.Hour))
+	u.interceptStarted("a:echo", alice, &rpc.InterceptSpec{Namespace: "echo-ns"}, t0.Add(2*time.Hour))
+	u.interceptEnded("a:echo", t0.Add(5*time.Hour))
+	u.connected(alice, t0.Add(6*time.Hour))
+	u.interceptStarted("a:hello", alice, &rpc.InterceptSpec{Namespace: "dev-alice"}, t0.Add(7*time.Hour))
+
+	// The whole period, with one intercept still active.
+	r := u.report(time.Time{}, t0.Add(8*time.Hour))
+	assert.Equal(t, t0, r.Since.AsTime())
+	assert.Equal(t, t0, r.CollectedSince.AsTime())
+	require.Len(t, r.Users, 2)
+	a := r.Users[0]
+	assert.Equal(t, "alice", a.User)
+	assert.Equal(t, int32(2), a.Connects)
+	assert.Equal(t, int32(2), a.Intercepts)
+	assert.Equal(t, 4*time.Hour, a.InterceptDuration.AsDuration())
+	assert.Equal(t, []string{"dev-alice", "echo-ns"}, a.Namespaces)
+	b := r.Users[1]
+	assert.Equal(t, "unauthenticated:bob", b.User)
+	assert.Equal(t, int32(1), b.Connects)
+	assert.Zero(t, b.InterceptDuration.AsDuration())
+
+	// Intercepts that overlap the start of the period count only partially.
+	r = u.report(t0.Add(4*time.Hour), t0.Add(8*time.Hour))
+	require.Len(t, r.Users, 1)
+	a = r.Users[0]
+	assert.Equal(t, int32(1), a.Connects)
+	assert.Equal(t, int32(2), a.Intercepts)
+	assert.Equal(t, 2*time.Hour, a.InterceptDuration.AsDuration())
+
+	// Entries older than the retention period are pruned.
+	r = u.report(time.Time{}, t0.Add(usageRetention+6*time.Hour))
+	assert.Equal(t, t0.Add(6*time.Hour), r.CollectedSince.AsTime())
+	require.Len(t, r.Users, 1)
+	assert.Equal(t, int32(1), r.Users[0].Connects)
+	assert.Equal(t, int32(1), r.Users[0].Intercepts)
+}
+
+func TestUsageReport_clock(t *testing.T) {
+	ctx := context.Background()
+	clock := &FakeClock{}
+	s := NewState(ctx)
+	s.SetClock(clock)
+	alice := &rpc.ClientInfo{Name: "alice@laptop", Namespace: "default", Identity: &rpc.UserIdentity{Username: "alice"}}
+	sessionID := s.AddClient(alice, clock.Now())
+
+	ii, err := s.AddIntercept(sessionID, "cluster", alice, &rpc.CreateInterceptRequest{
+		Session:       &rpc.SessionInfo{SessionId: sessionID},
+		InterceptSpec: &rpc.InterceptSpec{Name: "echo", Namespace: "default", Client: alice.Name, Agent: "echo"},
+	})
+	require.NoError(t, err)
+	clock.When = 90
+	require.True(t, s.RemoveIntercept(ii.Id))
+
+	r := s.UsageReport(time.Time{}, clock.Now())
+	assert.Equal(t, (&FakeClock{}).Now(), r.CollectedSince.AsTime())
+	require.Len(t, r.Users, 1)
+	assert.Equal(t, int32(1), r.Users[0].Intercepts)
+	assert.Equal(t, 90*time.Second, r.Users[0].InterceptDuration.AsDuration())
+}
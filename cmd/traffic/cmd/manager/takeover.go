@@ -0,0 +1,239 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	core "k8s.io/api/core/v1"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/managerutil"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+)
+
+const (
+	// takeOverTimeout is how long the owner of an intercept has to answer a request to take it over.
+	takeOverTimeout = 2 * time.Minute
+
+	// takeOverAbsentAfter is how long a client may go without calling Remain before it's considered
+	// absent. Intercepts of absent clients are taken over without asking. Clients call Remain every
+	// five seconds.
+	takeOverAbsentAfter = 15 * time.Second
+)
+
+// takeOvers keeps track of the clients that watch for take-over requests and of the requests that
+// await an answer.
+type takeOvers struct {
+	sync.Mutex
+	watchers map[string]chan *rpc.TakeOverRequest
+	pending  map[string]*pendingTakeOver
+}
+
+type pendingTakeOver struct {
+	owner  string
+	answer chan bool
+}
+
+func (t *takeOvers) watch(sessionID string) chan *rpc.TakeOverRequest {
+	t.Lock()
+	defer t.Unlock()
+	if t.watchers == nil {
+		t.watchers = make(map[string]chan *rpc.TakeOverRequest)
+	}
+	ch := make(chan *rpc.TakeOverRequest, 4)
+	t.watchers[sessionID] = ch
+	return ch
+}
+
+func (t *takeOvers) unwatch(sessionID string, ch chan *rpc.TakeOverRequest) {
+	t.Lock()
+	if t.watchers[sessionID] == ch {
+		delete(t.watchers, sessionID)
+	}
+	t.Unlock()
+}
+
+// ask sends the request to the owner and returns the channel that will receive the answer, or nil
+// when the owner doesn't watch for take-over requests.
+func (t *takeOvers) ask(owner string, rq *rpc.TakeOverRequest) chan bool {
+	t.Lock()
+	defer t.Unlock()
+	ch, ok := t.watchers[owner]
+	if !ok {
+		return nil
+	}
+	pt := &pendingTakeOver{owner: owner, answer: make(chan bool, 1)}
+	select {
+	case ch <- rq:
+	default:
+		return nil
+	}
+	if t.pending == nil {
+		t.pending = make(map[string]*pendingTakeOver)
+	}
+	t.pending[rq.Id] = pt
+	return pt.answer
+}
+
+func (t *takeOvers) forget(id string) {
+	t.Lock()
+	delete(t.pending, id)
+	t.Unlock()
+}
+
+func (t *takeOvers) answer(owner, id string, accept bool) bool {
+	t.Lock()
+	defer t.Unlock()
+	pt, ok := t.pending[id]
+	if !ok || pt.owner != owner {
+		return false
+	}
+	delete(t.pending, id)
+	pt.answer <- accept
+	return true
+}
+
+// isAbsent returns true if the client with the given session hasn't been heard from recently.
+func (s *service) isAbsent(sessionID string) bool {
+	ss := s.state.GetSession(sessionID)
+	return ss == nil || s.clock.Now().Sub(ss.LastMarked()) > takeOverAbsentAfter
+}
+
+// TakeOverIntercept asks the owners of the intercepts that conflict with the given intercept spec
+// to hand them over, and removes the intercepts of those who accept. Intercepts of absent owners
+// are removed without asking. The take-over is refused when an owner that is present cannot be asked.
+func (s *service) TakeOverIntercept(ctx context.Context, rq *rpc.TakeOverRequest) (*rpc.TakeOverResult, error) {
+	ctx = managerutil.WithSessionInfo(ctx, rq.GetSession())
+	sessionID := rq.GetSession().GetSessionId()
+	spec := rq.GetInterceptSpec()
+	dlog.Debug(ctx, "TakeOverIntercept called")
+
+	client := s.state.GetClient(sessionID)
+	if client == nil {
+		return nil, status.Errorf(codes.NotFound, "Client session %q not found", sessionID)
+	}
+	if client.Observe {
+		return nil, errObserving(sessionID)
+	}
+	if spec == nil {
+		return nil, status.Error(codes.InvalidArgument, "intercept spec is required")
+	}
+	if _, err := s.checkInterceptPolicy(ctx, client, spec); err != nil {
+		return nil, err
+	}
+
+	conflicts := s.state.LoadMatchingIntercepts(func(_ string, ii *rpc.InterceptInfo) bool {
+		return ii.ClientSession.SessionId != sessionID && interceptsConflict(spec, ii.Spec)
+	})
+	for id, ii := range conflicts {
+		owner := ii.ClientSession.SessionId
+		if !s.isAbsent(owner) {
+			orq := &rpc.TakeOverRequest{
+				Session:       rq.Session,
+				InterceptSpec: spec,
+				Id:            uuid.New().String(),
+				Claimant:      client.Name,
+				InterceptId:   id,
+				ExpiresAt:     timestamppb.New(s.clock.Now().Add(takeOverTimeout)),
+			}
+			ach := s.takeOvers.ask(owner, orq)
+			if ach == nil {
+				dlog.Infof(ctx, "Owner %s of intercept %s cannot be asked to hand it over", ii.Spec.Client, id)
+				return &rpc.TakeOverResult{Message: fmt.Sprintf("%s cannot be asked to hand over intercept %s", ii.Spec.Client, ii.Spec.Name)}, nil
+			}
+			dlog.Infof(ctx, "Asking %s to hand over intercept %s", ii.Spec.Client, id)
+			accepted, err := awaitTakeOver(ctx, ach)
+			s.takeOvers.forget(orq.Id)
+			if err != nil {
+				return nil, err
+			}
+			if !accepted {
+				return &rpc.TakeOverResult{Message: fmt.Sprintf("%s refused to hand over intercept %s", ii.Spec.Client, ii.Spec.Name)}, nil
+			}
+		} else {
+			dlog.Infof(ctx, "Owner %s of intercept %s is absent", ii.Spec.Client, id)
+		}
+		// The owner removes the intercept when it accepts, so it may already be gone.
+		s.state.RemoveIntercept(id)
+		dlog.Infof(ctx, "Intercept %s handed over to %s", id, client.Name)
+	}
+	return &rpc.TakeOverResult{Accepted: true}, nil
+}
+
+// interceptsConflict returns true if the traffic-agent would serve both intercepts using the same
+// port, i.e. if it would refuse one of them as conflicting with the other.
+func interceptsConflict(spec, other *rpc.InterceptSpec) bool {
+	return spec.Agent == other.Agent && spec.Namespace == other.Namespace &&
+		agentconfig.SpecMatchesIntercept(spec, &agentconfig.Intercept{
+			ServiceName:     other.ServiceName,
+			ServicePortName: other.ServicePortName,
+			ServicePort:     uint16(other.ServicePort),
+			Protocol:        core.Protocol(other.Protocol),
+		})
+}
+
+func awaitTakeOver(ctx context.Context, ach <-chan bool) (bool, error) {
+	timer := time.NewTimer(takeOverTimeout)
+	defer timer.Stop()
+	select {
+	case accepted := <-ach:
+		return accepted, nil
+	case <-timer.C:
+		return false, nil
+	case <-ctx.Done():
+		return false, status.Error(codes.Canceled, ctx.Err().Error())
+	}
+}
+
+// WatchTakeOverRequests notifies a client of requests from other clients to take over its intercepts.
+func (s *service) WatchTakeOverRequests(session *rpc.SessionInfo, stream rpc.Manager_WatchTakeOverRequestsServer) error {
+	ctx := managerutil.WithSessionInfo(stream.Context(), session)
+	sessionID := session.GetSessionId()
+	dlog.Debug(ctx, "WatchTakeOverRequests called")
+
+	sessionDone, err := s.state.SessionDone(sessionID)
+	if err != nil {
+		return err
+	}
+	ch := s.takeOvers.watch(sessionID)
+	defer s.takeOvers.unwatch(sessionID, ch)
+
+	// Let the client know that requests will reach it from now on.
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return nil
+	}
+	for {
+		select {
+		case <-s.ctx.Done():
+			return nil
+		case <-ctx.Done():
+			return nil
+		case <-sessionDone:
+			return nil
+		case rq := <-ch:
+			if err := stream.Send(rq); err != nil {
+				dlog.Errorf(ctx, "WatchTakeOverRequests.Send() failed: %v", err)
+				return nil
+			}
+		}
+	}
+}
+
+// AnswerTakeOver lets a client accept or reject a request to take over its intercept.
+func (s *service) AnswerTakeOver(ctx context.Context, answer *rpc.TakeOverAnswer) (*empty.Empty, error) {
+	ctx = managerutil.WithSessionInfo(ctx, answer.GetSession())
+	dlog.Debugf(ctx, "AnswerTakeOver called, accept = %t", answer.Accept)
+	if !s.takeOvers.answer(answer.GetSession().GetSessionId(), answer.Id, answer.Accept) {
+		return nil, status.Errorf(codes.NotFound, "take-over request %q not found or expired", answer.Id)
+	}
+	return &empty.Empty{}, nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/datawire/dlib/dlog"
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/agent"
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/agentinit"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/broker"
 	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager"
 	"github.com/telepresenceio/telepresence/v2/pkg/log"
 )
@@ -18,6 +19,7 @@ func main() {
 	cmds := map[string]func(ctx context.Context, args ...string) error{
 		"agent":      agent.Main,
 		"agent-init": agentinit.Main,
+		"broker":     broker.Main,
 		"manager":    manager.Main,
 	}
 
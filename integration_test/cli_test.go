@@ -102,6 +102,13 @@ func (s *cliSuite) Test_Status() {
 	s.Contains(stdout, "User Daemon: Not running")
 }
 
+func (s *cliSuite) Test_StatusExitCode() {
+	itest.TelepresenceQuitOk(s.Context())
+	_, stderr, err := itest.Telepresence(s.Context(), "status", "--exit-code")
+	s.Empty(stderr)
+	s.ErrorContains(err, "exit code 2")
+}
+
 func (s *cliSuite) Test_StatusWithJSONFlag() {
 	itest.TelepresenceQuitOk(s.Context())
 	stdout, stderr, err := itest.Telepresence(s.Context(), "status", "--json")
@@ -0,0 +1,94 @@
+package agentconfig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+)
+
+// ComplianceMode determines what security requirements the injected traffic-agent must comply with.
+type ComplianceMode int
+
+var cmNames = [...]string{"default", "restricted"} //nolint:gochecknoglobals // constant names
+
+const (
+	// ComplianceDefault gives the traffic-agent the security context of the intercepted container, and
+	// adds an init container with the NET_ADMIN capability when iptables redirection is needed.
+	ComplianceDefault ComplianceMode = iota
+
+	// ComplianceRestricted makes the traffic-agent comply with the "restricted" Pod Security Standard. The
+	// agent runs as a non-root user with a read-only root filesystem, no capabilities, and the runtime's
+	// default seccomp profile. No init container is injected, so service ports that would need iptables
+	// redirection (numeric target ports and headless services) cannot be intercepted.
+	ComplianceRestricted
+)
+
+// ComplianceAnnotation is a workload annotation that overrides the compliance mode configured for the
+// agent injector.
+const ComplianceAnnotation = DomainPrefix + "inject-compliance"
+
+// RestrictedUID is the user ID that the traffic-agent runs as in restricted mode, unless the intercepted
+// container declares a non-root user.
+const RestrictedUID = 7777
+
+func (cm ComplianceMode) String() string {
+	return cmNames[cm]
+}
+
+func NewComplianceMode(s string) (ComplianceMode, error) {
+	for i, n := range cmNames {
+		if strings.EqualFold(s, n) {
+			return ComplianceMode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid ComplianceMode: %q", s)
+}
+
+func (cm ComplianceMode) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(cm.String())), nil
+}
+
+func (cm *ComplianceMode) EnvDecode(val string) (err error) {
+	var m ComplianceMode
+	if val == "" {
+		m = ComplianceDefault
+	} else if m, err = NewComplianceMode(val); err != nil {
+		return err
+	}
+	*cm = m
+	return nil
+}
+
+func (cm *ComplianceMode) UnmarshalJSON(value []byte) error {
+	s, err := strconv.Unquote(string(value))
+	if err != nil {
+		s = string(value)
+	}
+	return cm.EnvDecode(s)
+}
+
+// RestrictedSecurityContext returns a copy of the given security context, which may be nil, with the
+// settings required by the "restricted" Pod Security Standard.
+func RestrictedSecurityContext(sc *core.SecurityContext) *core.SecurityContext {
+	if sc == nil {
+		sc = &core.SecurityContext{}
+	} else {
+		sc = sc.DeepCopy()
+	}
+	if sc.RunAsUser == nil || *sc.RunAsUser == 0 {
+		uid := int64(RestrictedUID)
+		sc.RunAsUser = &uid
+	}
+	yes, no := true, false
+	sc.RunAsNonRoot = &yes
+	sc.ReadOnlyRootFilesystem = &yes
+	sc.AllowPrivilegeEscalation = &no
+	sc.Privileged = nil
+	sc.Capabilities = &core.Capabilities{Drop: []core.Capability{"ALL"}}
+	if sc.SeccompProfile == nil || sc.SeccompProfile.Type == core.SeccompProfileTypeUnconfined {
+		sc.SeccompProfile = &core.SeccompProfile{Type: core.SeccompProfileTypeRuntimeDefault}
+	}
+	return sc
+}
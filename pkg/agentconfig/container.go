@@ -68,9 +68,9 @@ func AgentContainer(
 
 	mounts := make([]core.VolumeMount, 0, len(config.Containers)*3)
 	var agentVersion semver.Version
-	if sep := strings.LastIndexByte(config.AgentImage, ':'); sep > 0 {
+	if tag := ImageTag(config.AgentImage); tag != "" {
 		var err error
-		if agentVersion, err = semver.Parse(config.AgentImage[sep+1:]); err != nil {
+		if agentVersion, err = semver.Parse(tag); err != nil {
 			dlog.Errorf(ctx, "unable to parse agent version from image name %s", config.AgentImage)
 		}
 	}
@@ -160,6 +160,9 @@ outerLoop:
 			}
 		}
 	}
+	if config.Compliance == ComplianceRestricted {
+		ac.SecurityContext = RestrictedSecurityContext(ac.SecurityContext)
+	}
 
 	// Replace all occurrences of "$(ENV" with "$(PFX_ENV"
 	aj, err := json.Marshal(&ac)
@@ -345,3 +348,15 @@ func getIgnoredVolumeMounts(annotations map[string]string) map[string]struct{} {
 	}
 	return ivms
 }
+
+// ImageTag returns the tag of the given image reference, or an empty string if it has no tag. A digest
+// that pins the image, as in "docker.io/datawire/tel2:2.14.0@sha256:...", is ignored.
+func ImageTag(image string) string {
+	if i := strings.IndexByte(image, '@'); i >= 0 {
+		image = image[:i]
+	}
+	if i := strings.LastIndexByte(image, ':'); i > strings.LastIndexByte(image, '/') {
+		return image[i+1:]
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package agentconfig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DebugAnnotation is a pod template annotation that makes the agent injector add environment variables that
+// start a debug server in the app containers of the pod. The value is "<runtime>:<port>", e.g. "jvm:5005".
+const DebugAnnotation = DomainPrefix + "inject-debug"
+
+// EnvDebugListen is set in the app containers when the runtime is DebugDelve. Delve can't be enabled using
+// the environment, so the container's entrypoint must pass it to "dlv exec --headless --listen".
+const EnvDebugListen = "TELEPRESENCE_DEBUG_LISTEN"
+
+// The runtimes that the DebugAnnotation can enable a debug server for.
+const (
+	DebugJVM   = "jvm"
+	DebugNode  = "node"
+	DebugDelve = "delve"
+)
+
+// DebugRuntimes are the valid runtimes of the DebugAnnotation.
+var DebugRuntimes = []string{DebugJVM, DebugNode, DebugDelve} //nolint:gochecknoglobals // constant
+
+// DebugAnnotationValue returns the value of a DebugAnnotation for the given runtime and port.
+func DebugAnnotationValue(runtime string, port uint16) string {
+	return runtime + ":" + strconv.Itoa(int(port))
+}
+
+// ParseDebugAnnotation returns the runtime and port of the given DebugAnnotation value.
+func ParseDebugAnnotation(value string) (string, uint16, error) {
+	runtime, ps, ok := strings.Cut(value, ":")
+	if ok {
+		if port, err := strconv.ParseUint(ps, 10, 16); err == nil && port > 0 {
+			for _, r := range DebugRuntimes {
+				if r == runtime {
+					return runtime, uint16(port), nil
+				}
+			}
+		}
+	}
+	return "", 0, fmt.Errorf("invalid value %q for annotation %s. Expected <%s>:<port>", value, DebugAnnotation, strings.Join(DebugRuntimes, "|"))
+}
+
+// DebugEnv returns the environment variables that make the given runtime start a debug server that listens to
+// the given port on all interfaces. Options for the JVM and Node are appended to those that the container
+// already declares in the same variables.
+func DebugEnv(runtime string, port uint16) map[string]string {
+	switch runtime {
+	case DebugJVM:
+		return map[string]string{"JAVA_TOOL_OPTIONS": fmt.Sprintf("-agentlib:jdwp=transport=dt_socket,server=y,suspend=n,address=*:%d", port)}
+	case DebugNode:
+		return map[string]string{"NODE_OPTIONS": fmt.Sprintf("--inspect=0.0.0.0:%d", port)}
+	case DebugDelve:
+		return map[string]string{EnvDebugListen: fmt.Sprintf(":%d", port)}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,276 @@
+package agentconfig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServiceMesh identifies a service mesh that the agent injector cooperates with when it injects a
+// traffic-agent into a pod that also has a mesh proxy.
+type ServiceMesh int
+
+var smNames = [...]string{"none", "auto", "istio", "linkerd"} //nolint:gochecknoglobals // constant names
+
+const (
+	// ServiceMeshNone tells the injector to not make any mesh specific adjustments. This is the default.
+	ServiceMeshNone ServiceMesh = iota
+
+	// ServiceMeshAuto tells the injector to detect the service mesh from the annotations, labels, and
+	// containers of the pod and its namespace.
+	ServiceMeshAuto
+
+	// ServiceMeshIstio tells the injector that the pod is part of an Istio mesh.
+	ServiceMeshIstio
+
+	// ServiceMeshLinkerd tells the injector that the pod is part of a Linkerd mesh.
+	ServiceMeshLinkerd
+)
+
+// ServiceMeshAnnotation is a workload annotation that overrides the service mesh mode configured
+// for the agent injector.
+const ServiceMeshAnnotation = DomainPrefix + "inject-service-mesh"
+
+// Annotations and container names used by the supported service meshes.
+const (
+	IstioInjectAnnotation               = "sidecar.istio.io/inject"
+	IstioStatusAnnotation               = "sidecar.istio.io/status"
+	IstioExcludeOutboundPortsAnnotation = "traffic.sidecar.istio.io/excludeOutboundPorts"
+	IstioInjectionLabel                 = "istio-injection"
+	IstioInitContainerName              = "istio-init"
+	IstioProxyContainerName             = "istio-proxy"
+
+	LinkerdInjectAnnotation            = "linkerd.io/inject"
+	LinkerdProxyVersionAnnotation      = "linkerd.io/proxy-version"
+	LinkerdSkipOutboundPortsAnnotation = "config.linkerd.io/skip-outbound-ports"
+	LinkerdOpaquePortsAnnotation       = "config.linkerd.io/opaque-ports"
+	LinkerdInitContainerName           = "linkerd-init"
+	LinkerdProxyContainerName          = "linkerd-proxy"
+)
+
+func (sm ServiceMesh) String() string {
+	return smNames[sm]
+}
+
+func NewServiceMesh(s string) (ServiceMesh, error) {
+	for i, n := range smNames {
+		if strings.EqualFold(s, n) {
+			return ServiceMesh(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid ServiceMesh: %q", s)
+}
+
+func (sm ServiceMesh) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(sm.String())), nil
+}
+
+func (sm *ServiceMesh) EnvDecode(val string) (err error) {
+	var m ServiceMesh
+	if val == "" {
+		m = ServiceMeshNone
+	} else if m, err = NewServiceMesh(val); err != nil {
+		return err
+	}
+	*sm = m
+	return nil
+}
+
+func (sm *ServiceMesh) UnmarshalJSON(value []byte) error {
+	s, err := strconv.Unquote(string(value))
+	if err != nil {
+		s = string(value)
+	}
+	return sm.EnvDecode(s)
+}
+
+// InitContainerName returns the name of the init container that the mesh uses to set up its
+// iptables rules, or an empty string if the mesh is unknown.
+func (sm ServiceMesh) InitContainerName() string {
+	switch sm {
+	case ServiceMeshIstio:
+		return IstioInitContainerName
+	case ServiceMeshLinkerd:
+		return LinkerdInitContainerName
+	default:
+		return ""
+	}
+}
+
+// ProxyContainerName returns the name of the mesh proxy container, or an empty string if the mesh is unknown.
+func (sm ServiceMesh) ProxyContainerName() string {
+	switch sm {
+	case ServiceMeshIstio:
+		return IstioProxyContainerName
+	case ServiceMeshLinkerd:
+		return LinkerdProxyContainerName
+	default:
+		return ""
+	}
+}
+
+// DetectServiceMesh returns the service mesh that the given pod is, or will be, a part of. The
+// namespace metadata is optional. It's used to detect namespace wide mesh injection.
+func DetectServiceMesh(pod *core.Pod, ns *meta.ObjectMeta) ServiceMesh {
+	hasContainer := func(name string) bool {
+		for i := range pod.Spec.Containers {
+			if pod.Spec.Containers[i].Name == name {
+				return true
+			}
+		}
+		for i := range pod.Spec.InitContainers {
+			if pod.Spec.InitContainers[i].Name == name {
+				return true
+			}
+		}
+		return false
+	}
+	pa := pod.Annotations
+	switch {
+	case pa[IstioInjectAnnotation] == "false" || pod.Labels[IstioInjectAnnotation] == "false":
+	case pa[IstioInjectAnnotation] == "true" || pod.Labels[IstioInjectAnnotation] == "true",
+		pa[IstioStatusAnnotation] != "",
+		hasContainer(IstioProxyContainerName),
+		ns != nil && ns.Labels[IstioInjectionLabel] == "enabled":
+		return ServiceMeshIstio
+	}
+	switch {
+	case pa[LinkerdInjectAnnotation] == "disabled":
+	case pa[LinkerdInjectAnnotation] == "enabled" || pa[LinkerdInjectAnnotation] == "ingress",
+		pa[LinkerdProxyVersionAnnotation] != "",
+		hasContainer(LinkerdProxyContainerName),
+		ns != nil && ns.Annotations[LinkerdInjectAnnotation] == "enabled":
+		return ServiceMeshLinkerd
+	}
+	return ServiceMeshNone
+}
+
+// MeshAnnotations returns the pod annotations that make the given mesh cooperate with the traffic-agent
+// described by the given config. Values that the pod already has are retained and merged with the values
+// needed by the traffic-agent.
+//
+// The traffic-agent's connection to the traffic-manager is excluded from the mesh's outbound capture, so
+// that the agent can reach the traffic-manager regardless of the mesh's mTLS policy. Intercepted ports
+// that don't carry HTTP are declared opaque to Linkerd, so that the mTLS encrypted TCP stream is passed
+// through to the traffic-agent without protocol detection. Istio passes such streams through without
+// further configuration.
+func (sm ServiceMesh) MeshAnnotations(pod *core.Pod, config *Sidecar) map[string]string {
+	ams := make(map[string]string)
+	managerPort := []string{strconv.Itoa(int(config.ManagerPort))}
+	switch sm {
+	case ServiceMeshIstio:
+		ams[IstioExcludeOutboundPortsAnnotation] = mergePortList(pod.Annotations[IstioExcludeOutboundPortsAnnotation], managerPort)
+	case ServiceMeshLinkerd:
+		ams[LinkerdSkipOutboundPortsAnnotation] = mergePortList(pod.Annotations[LinkerdSkipOutboundPortsAnnotation], managerPort)
+		var opaque []string
+		for _, cc := range config.Containers {
+			for _, ic := range PortUniqueIntercepts(cc) {
+				if ic.Protocol == core.ProtocolTCP && !isHTTPAppProtocol(ic.AppProtocol) {
+					opaque = append(opaque, strconv.Itoa(int(ic.AgentPort)))
+				}
+			}
+		}
+		if len(opaque) > 0 {
+			ams[LinkerdOpaquePortsAnnotation] = mergePortList(pod.Annotations[LinkerdOpaquePortsAnnotation], opaque)
+		}
+	}
+	return ams
+}
+
+func isHTTPAppProtocol(p string) bool {
+	switch strings.ToLower(p) {
+	case "http", "http2", "h2c", "grpc", "kubernetes.io/h2c":
+		return true
+	}
+	return false
+}
+
+// mergePortList adds the given ports to a comma separated list of ports, unless they are already present.
+func mergePortList(list string, ports []string) string {
+	var entries []string
+	seen := make(map[string]struct{})
+	for _, p := range strings.Split(list, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			entries = append(entries, p)
+			seen[p] = struct{}{}
+		}
+	}
+	for _, p := range ports {
+		if _, ok := seen[p]; !ok {
+			entries = append(entries, p)
+			seen[p] = struct{}{}
+		}
+	}
+	return strings.Join(entries, ",")
+}
+
+func portListContains(list string, port string) bool {
+	for _, p := range strings.Split(list, ",") {
+		p = strings.TrimSpace(p)
+		if p == port {
+			return true
+		}
+		if lo, hi, ok := strings.Cut(p, "-"); ok {
+			pn, _ := strconv.Atoi(port)
+			l, _ := strconv.Atoi(lo)
+			h, _ := strconv.Atoi(hi)
+			if l <= pn && pn <= h {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ServiceMeshProblems returns a description of each misconfiguration found in a pod that has both a
+// traffic-agent and a service mesh proxy. The config is optional. Checks that require it are skipped
+// when it's nil.
+func ServiceMeshProblems(pod *core.Pod, config *Sidecar) []string {
+	sm := DetectServiceMesh(pod, nil)
+	if sm == ServiceMeshNone {
+		return nil
+	}
+	var problems []string
+	initIndex := func(name string) int {
+		for i := range pod.Spec.InitContainers {
+			if pod.Spec.InitContainers[i].Name == name {
+				return i
+			}
+		}
+		return -1
+	}
+	if ti, mi := initIndex(InitContainerName), initIndex(sm.InitContainerName()); ti >= 0 && ti < mi {
+		problems = append(problems, fmt.Sprintf(
+			"init container %s runs before %s, so the %s iptables rules may bypass the traffic-agent",
+			InitContainerName, sm.InitContainerName(), sm))
+	}
+	if config != nil && config.ManagerPort != 0 {
+		port := strconv.Itoa(int(config.ManagerPort))
+		var an string
+		switch sm {
+		case ServiceMeshIstio:
+			an = IstioExcludeOutboundPortsAnnotation
+		case ServiceMeshLinkerd:
+			an = LinkerdSkipOutboundPortsAnnotation
+		}
+		if !portListContains(pod.Annotations[an], port) {
+			problems = append(problems, fmt.Sprintf(
+				"the traffic-manager port %s is not excluded from %s outbound capture (annotation %s)", port, sm, an))
+		}
+		if sm == ServiceMeshLinkerd {
+			for _, cc := range config.Containers {
+				for _, ic := range PortUniqueIntercepts(cc) {
+					p := strconv.Itoa(int(ic.AgentPort))
+					if ic.Protocol == core.ProtocolTCP && !isHTTPAppProtocol(ic.AppProtocol) && !portListContains(pod.Annotations[LinkerdOpaquePortsAnnotation], p) {
+						problems = append(problems, fmt.Sprintf(
+							"the non-HTTP traffic-agent port %s is not declared opaque (annotation %s)", p, LinkerdOpaquePortsAnnotation))
+					}
+				}
+			}
+		}
+	}
+	return problems
+}
@@ -2,6 +2,7 @@ package agentconfig
 
 import (
 	"reflect"
+	"strconv"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
@@ -43,12 +44,21 @@ const (
 	// EnvAPIPort is the port number of the Telepresence API server, when it is enabled.
 	EnvAPIPort = "TELEPRESENCE_API_PORT"
 
+	// EnvAPIURL is the URL of the Telepresence API server, when it is enabled. Applications use it to fetch the
+	// headers that they must propagate.
+	EnvAPIURL = "TELEPRESENCE_API_URL"
+
 	DomainPrefix                   = "telepresence.getambassador.io/"
 	InjectAnnotation               = DomainPrefix + "inject-" + ContainerName
 	TerminatingTLSSecretAnnotation = DomainPrefix + "inject-terminating-tls-secret"
 	OriginatingTLSSecretAnnotation = DomainPrefix + "inject-originating-tls-secret"
 )
 
+// APIURL returns the URL of the Telepresence API server that listens to the given port of the pod.
+func APIURL(port int) string {
+	return "http://localhost:" + strconv.Itoa(port)
+}
+
 // Intercept describes the mapping between a service port and an intercepted container port.
 type Intercept struct {
 	// The name of the intercepted container port
@@ -153,6 +163,9 @@ type Sidecar struct {
 	// InitResources is the resource requirements for the initContainer sidecar
 	InitResources *core.ResourceRequirements `json:"initResources,omitempty"`
 
+	// Compliance is the security compliance mode of the sidecar
+	Compliance ComplianceMode `json:"compliance,omitempty"`
+
 	// The intercepts managed by the agent
 	Containers []*Container `json:"containers,omitempty"`
 }
@@ -7,7 +7,9 @@ import (
 
 	core "k8s.io/api/core/v1"
 	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
 	"github.com/datawire/k8sapi/pkg/k8sapi"
@@ -82,6 +84,77 @@ func findServicesSelecting(c context.Context, namespace string, lbs labels.Label
 	return ms, nil
 }
 
+// findServicesTargetingWorkload returns the services that lack a selector but have manually maintained
+// Endpoints that target pods owned by the given workload.
+func findServicesTargetingWorkload(ctx context.Context, wl k8sapi.Workload) ([]k8sapi.Object, error) {
+	ss, err := k8sapi.Services(ctx, wl.GetNamespace(), nil)
+	if err != nil {
+		return nil, err
+	}
+	wlCache := make(map[string]k8sapi.Workload)
+	var ms []k8sapi.Object
+	for _, s := range ss {
+		svc, ok := k8sapi.ServiceImpl(s)
+		if !ok || len(svc.Spec.Selector) > 0 || svc.Spec.Type == core.ServiceTypeExternalName {
+			continue
+		}
+		ep, err := k8sapi.GetK8sInterface(ctx).CoreV1().Endpoints(svc.Namespace).Get(ctx, svc.Name, meta.GetOptions{})
+		if err != nil {
+			continue
+		}
+		wls, err := EndpointsWorkloads(ctx, wlCache, ep)
+		if err != nil {
+			return nil, err
+		}
+		for _, ewl := range wls {
+			if ewl.GetUID() == wl.GetUID() {
+				ms = append(ms, s)
+				break
+			}
+		}
+	}
+	return ms, nil
+}
+
+// EndpointsWorkloads returns the workloads that own the pods targeted by the given Endpoints. Endpoints
+// that target pods are typically maintained manually for services that lack a selector.
+func EndpointsWorkloads(ctx context.Context, workloadCache map[string]k8sapi.Workload, ep *core.Endpoints) ([]k8sapi.Workload, error) {
+	var wls []k8sapi.Workload
+	seenPods := make(map[string]struct{})
+	seenWls := make(map[types.UID]struct{})
+	for _, ss := range ep.Subsets {
+		for _, as := range [][]core.EndpointAddress{ss.Addresses, ss.NotReadyAddresses} {
+			for _, a := range as {
+				tr := a.TargetRef
+				if tr == nil || tr.Kind != "Pod" {
+					continue
+				}
+				if _, ok := seenPods[tr.Name]; ok {
+					continue
+				}
+				seenPods[tr.Name] = struct{}{}
+				pod, err := k8sapi.GetPod(ctx, tr.Name, ep.Namespace)
+				if err != nil {
+					if k8sErrors.IsNotFound(err) {
+						continue
+					}
+					return nil, err
+				}
+				wl, err := FindOwnerWorkload(ctx, workloadCache, pod)
+				if err != nil {
+					// The pod has no owner that can be intercepted.
+					continue
+				}
+				if _, ok := seenWls[wl.GetUID()]; !ok {
+					seenWls[wl.GetUID()] = struct{}{}
+					wls = append(wls, wl)
+				}
+			}
+		}
+	}
+	return wls, nil
+}
+
 // findContainerMatchingPort finds the container that matches the given ServicePort. The match is
 // made using Protocol, and the Name or the ContainerPort field of each port in each container
 // depending on if  the service port is symbolic or numeric. The first container with a matching
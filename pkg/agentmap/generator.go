@@ -7,7 +7,9 @@ import (
 	"go.opentelemetry.io/otel"
 	core "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
 
+	"github.com/datawire/dlib/dlog"
 	"github.com/datawire/k8sapi/pkg/k8sapi"
 	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
 	"github.com/telepresenceio/telepresence/v2/pkg/install"
@@ -15,9 +17,11 @@ import (
 )
 
 const (
-	ServicePortAnnotation = agentconfig.DomainPrefix + "inject-service-port"
-	ServiceNameAnnotation = agentconfig.DomainPrefix + "inject-service-name"
-	ManagerAppName        = "traffic-manager"
+	ServicePortAnnotation        = agentconfig.DomainPrefix + "inject-service-port"
+	ServiceNameAnnotation        = agentconfig.DomainPrefix + "inject-service-name"
+	AgentResourcesAnnotation     = agentconfig.DomainPrefix + "inject-agent-resources"
+	AgentInitResourcesAnnotation = agentconfig.DomainPrefix + "inject-agent-init-resources"
+	ManagerAppName               = "traffic-manager"
 )
 
 type GeneratorConfig interface {
@@ -38,6 +42,7 @@ type BasicGeneratorConfig struct {
 	Resources           *core.ResourceRequirements
 	PullPolicy          string
 	PullSecrets         []core.LocalObjectReference
+	Compliance          agentconfig.ComplianceMode
 }
 
 func (cfg *BasicGeneratorConfig) Generate(ctx context.Context, wl k8sapi.Workload) (sc agentconfig.SidecarExt, err error) {
@@ -62,11 +67,36 @@ func (cfg *BasicGeneratorConfig) Generate(ctx context.Context, wl k8sapi.Workloa
 		}
 	}
 
-	svcs, err := findServicesForPod(ctx, pod, pod.Annotations[ServiceNameAnnotation])
+	compliance := cfg.Compliance
+	if a, ok := pod.Annotations[agentconfig.ComplianceAnnotation]; ok {
+		if compliance, err = agentconfig.NewComplianceMode(a); err != nil {
+			return nil, fmt.Errorf("invalid value %q for annotation %s", a, agentconfig.ComplianceAnnotation)
+		}
+	}
+
+	resources, err := annotatedResources(pod, AgentResourcesAnnotation, cfg.Resources)
+	if err != nil {
+		return nil, err
+	}
+	initResources, err := annotatedResources(pod, AgentInitResourcesAnnotation, cfg.InitResources)
 	if err != nil {
 		return nil, err
 	}
 
+	svcName := pod.Annotations[ServiceNameAnnotation]
+	svcs, err := findServicesForPod(ctx, pod, svcName)
+	if err != nil {
+		if svcName != "" {
+			return nil, err
+		}
+		// Services without a selector can still target the workload's pods using manually maintained Endpoints.
+		var ess []k8sapi.Object
+		if ess, _ = findServicesTargetingWorkload(ctx, wl); len(ess) == 0 {
+			return nil, err
+		}
+		svcs = ess
+	}
+
 	var ccs []*agentconfig.Container
 	pns := make(map[int32]uint16)
 	portNumber := func(cnPort int32) uint16 {
@@ -79,13 +109,30 @@ func (cfg *BasicGeneratorConfig) Generate(ctx context.Context, wl k8sapi.Workloa
 		return p
 	}
 
+	portlessHeadless := false
 	for _, svc := range svcs {
 		svcImpl, _ := k8sapi.ServiceImpl(svc)
+		if isHeadless(svcImpl) && len(svcImpl.Spec.Ports) == 0 {
+			portlessHeadless = true
+		}
 		if ccs, err = appendAgentContainerConfigs(svcImpl, pod, portNumber, ccs); err != nil {
 			return nil, err
 		}
 	}
+	if compliance == agentconfig.ComplianceRestricted && len(ccs) > 0 {
+		if ccs = dropRedirectedIntercepts(ctx, ccs); len(ccs) == 0 {
+			return nil, fmt.Errorf(
+				"found no service port that can be intercepted in pod %s.%s using the %s compliance mode. Service ports "+
+					"with a numeric targetPort, and ports of headless services, require the %s init container",
+				pod.Name, pod.Namespace, compliance, agentconfig.InitContainerName)
+		}
+	}
 	if len(ccs) == 0 {
+		if portlessHeadless {
+			return nil, fmt.Errorf(
+				"found no service with a port that matches a container in pod %s.%s. A headless service that declares no ports "+
+					"can only be used when the pod's containers declare the ports that they listen to", pod.Name, pod.Namespace)
+		}
 		return nil, fmt.Errorf("found no service with a port that matches a container in pod %s.%s", pod.Name, pod.Namespace)
 	}
 
@@ -101,17 +148,104 @@ func (cfg *BasicGeneratorConfig) Generate(ctx context.Context, wl k8sapi.Workloa
 		APIPort:       cfg.APIPort,
 		TracingPort:   cfg.TracingPort,
 		Containers:    ccs,
-		InitResources: cfg.InitResources,
-		Resources:     cfg.Resources,
+		InitResources: initResources,
+		Resources:     resources,
 		PullPolicy:    cfg.PullPolicy,
 		PullSecrets:   cfg.PullSecrets,
+		Compliance:    compliance,
 	}
 	ag.RecordInSpan(span)
 	return ag, nil
 }
 
+func isHeadless(svc *core.Service) bool {
+	return svc.Spec.ClusterIP == core.ClusterIPNone
+}
+
+// containerServicePorts returns a numeric service port for each port declared by the containers of the given pod. A
+// headless service that declares no ports is addressed using these ports, because clients connect directly to the pods.
+func containerServicePorts(pod *core.PodTemplateSpec) []core.ServicePort {
+	var ports []core.ServicePort
+	for _, cn := range pod.Spec.Containers {
+		if cn.Name == agentconfig.ContainerName {
+			continue
+		}
+		for _, p := range cn.Ports {
+			proto := p.Protocol
+			if proto == "" {
+				proto = core.ProtocolTCP
+			}
+			ports = append(ports, core.ServicePort{
+				Name:       p.Name,
+				Protocol:   proto,
+				Port:       p.ContainerPort,
+				TargetPort: intstr.FromInt(int(p.ContainerPort)),
+			})
+		}
+	}
+	return ports
+}
+
+// annotatedResources returns the given resource requirements, overridden by the requests and limits that
+// the given annotation of the pod declares. The annotation value is a JSON or YAML encoded ResourceRequirements.
+func annotatedResources(pod *core.PodTemplateSpec, annotation string, dflt *core.ResourceRequirements) (*core.ResourceRequirements, error) {
+	a, ok := pod.Annotations[annotation]
+	if !ok {
+		return dflt, nil
+	}
+	var ar core.ResourceRequirements
+	if err := yaml.Unmarshal([]byte(a), &ar); err != nil {
+		return nil, fmt.Errorf("invalid value %q for annotation %s: %w", a, annotation, err)
+	}
+	if dflt == nil {
+		return &ar, nil
+	}
+	rr := dflt.DeepCopy()
+	merge := func(dst *core.ResourceList, src core.ResourceList) {
+		if len(src) == 0 {
+			return
+		}
+		if *dst == nil {
+			*dst = make(core.ResourceList, len(src))
+		}
+		for n, q := range src {
+			(*dst)[n] = q
+		}
+	}
+	merge(&rr.Requests, ar.Requests)
+	merge(&rr.Limits, ar.Limits)
+	return rr, nil
+}
+
+// dropRedirectedIntercepts returns the given container configs without the intercepts that require
+// iptables redirection by the init container. Containers that end up without intercepts are dropped.
+func dropRedirectedIntercepts(ctx context.Context, ccs []*agentconfig.Container) []*agentconfig.Container {
+	kept := ccs[:0]
+	for _, cc := range ccs {
+		ics := cc.Intercepts[:0]
+		for _, ic := range cc.Intercepts {
+			if ic.Headless || ic.TargetPortNumeric {
+				dlog.Warnf(ctx, "port %d of service %s cannot be intercepted in container %s without the %s init container",
+					ic.ServicePort, ic.ServiceName, cc.Name, agentconfig.InitContainerName)
+				continue
+			}
+			ics = append(ics, ic)
+		}
+		if len(ics) > 0 {
+			cc.Intercepts = ics
+			kept = append(kept, cc)
+		}
+	}
+	return kept
+}
+
 func appendAgentContainerConfigs(svc *core.Service, pod *core.PodTemplateSpec, portNumber func(int32) uint16, ccs []*agentconfig.Container) ([]*agentconfig.Container, error) {
 	portNameOrNumber := pod.Annotations[ServicePortAnnotation]
+	headless := isHeadless(svc)
+	if headless && len(svc.Spec.Ports) == 0 {
+		svc = svc.DeepCopy()
+		svc.Spec.Ports = containerServicePorts(pod)
+	}
 	ports, err := install.FilterServicePorts(svc, portNameOrNumber)
 	if err != nil {
 		return nil, err
@@ -143,6 +277,7 @@ nextSvcPort:
 			ServicePortName:   port.Name,
 			ServicePort:       uint16(port.Port),
 			TargetPortNumeric: port.TargetPort.Type == intstr.Int,
+			Headless:          headless,
 			Protocol:          port.Protocol,
 			AppProtocol:       appProto,
 			AgentPort:         portNumber(appPort.ContainerPort),
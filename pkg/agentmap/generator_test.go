@@ -0,0 +1,41 @@
+package agentmap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAnnotatedResources(t *testing.T) {
+	dflt := &core.ResourceRequirements{
+		Requests: core.ResourceList{
+			core.ResourceCPU:    resource.MustParse("100m"),
+			core.ResourceMemory: resource.MustParse("64Mi"),
+		},
+	}
+	pod := func(a string) *core.PodTemplateSpec {
+		return &core.PodTemplateSpec{ObjectMeta: meta.ObjectMeta{Annotations: map[string]string{AgentResourcesAnnotation: a}}}
+	}
+
+	rr, err := annotatedResources(&core.PodTemplateSpec{}, AgentResourcesAnnotation, dflt)
+	require.NoError(t, err)
+	assert.Same(t, dflt, rr)
+
+	rr, err = annotatedResources(pod(`{"requests":{"memory":"128Mi"},"limits":{"memory":"256Mi"}}`), AgentResourcesAnnotation, dflt)
+	require.NoError(t, err)
+	assert.Equal(t, resource.MustParse("100m"), rr.Requests[core.ResourceCPU])
+	assert.Equal(t, resource.MustParse("128Mi"), rr.Requests[core.ResourceMemory])
+	assert.Equal(t, resource.MustParse("256Mi"), rr.Limits[core.ResourceMemory])
+	assert.Equal(t, resource.MustParse("64Mi"), dflt.Requests[core.ResourceMemory], "default must not be modified")
+
+	rr, err = annotatedResources(pod("limits:\n  cpu: 200m\n"), AgentResourcesAnnotation, nil)
+	require.NoError(t, err)
+	assert.Equal(t, resource.MustParse("200m"), rr.Limits[core.ResourceCPU])
+
+	_, err = annotatedResources(pod("limits: 12"), AgentResourcesAnnotation, dflt)
+	assert.Error(t, err)
+}
@@ -0,0 +1,70 @@
+// Package atrest protects secrets that Telepresence stores on disk. Files such as session identifiers and
+// kubeconfig snapshots are encrypted with a key that is kept in the OS keychain when one is available, and
+// tokens are stored in the keychain itself.
+package atrest
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// encryptedHeader starts all data that is encrypted by this package. It is also used as additional
+// authenticated data, so that a change of the header invalidates the content.
+var encryptedHeader = []byte("telepresence-encrypted:v1\n")
+
+// IsEncrypted returns true if the given data was produced by Encrypt.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedHeader)
+}
+
+// Encrypt encrypts the given data with AES-256-GCM using the key returned by DataKey.
+func Encrypt(ctx context.Context, data []byte) ([]byte, error) {
+	aead, err := newAEAD(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(encryptedHeader)+len(nonce)+len(data)+aead.Overhead())
+	out = append(append(out, encryptedHeader...), nonce...)
+	return aead.Seal(out, nonce, data, encryptedHeader), nil
+}
+
+// Decrypt decrypts data that was produced by Encrypt. Data that isn't encrypted is returned unchanged.
+func Decrypt(ctx context.Context, data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	aead, err := newAEAD(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data = data[len(encryptedHeader):]
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("encrypted data is truncated")
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], encryptedHeader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt: %w", err)
+	}
+	return plain, nil
+}
+
+func newAEAD(ctx context.Context) (cipher.AEAD, error) {
+	key, err := DataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,97 @@
+package atrest
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+func resetKey() {
+	keyLock.Lock()
+	cachedKey = nil
+	keyLock.Unlock()
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	key, err := newKey()
+	require.NoError(t, err)
+	t.Setenv(KeyEnv, encodeKey(key))
+	resetKey()
+	defer resetKey()
+
+	plain := []byte(`{"session":"secret"}`)
+	enc, err := Encrypt(ctx, plain)
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(enc))
+	assert.NotContains(t, string(enc), "secret")
+
+	dec, err := Decrypt(ctx, enc)
+	require.NoError(t, err)
+	assert.Equal(t, plain, dec)
+
+	// Plaintext passes through unchanged.
+	dec, err = Decrypt(ctx, plain)
+	require.NoError(t, err)
+	assert.Equal(t, plain, dec)
+
+	// Tampering is detected.
+	enc[len(enc)-1] ^= 1
+	_, err = Decrypt(ctx, enc)
+	assert.Error(t, err)
+
+	// A different key can't decrypt.
+	enc[len(enc)-1] ^= 1
+	other, err := newKey()
+	require.NoError(t, err)
+	t.Setenv(KeyEnv, encodeKey(other))
+	resetKey()
+	_, err = Decrypt(ctx, enc)
+	assert.Error(t, err)
+}
+
+func TestFileKey(t *testing.T) {
+	dir := t.TempDir()
+	ctx := filelocation.WithAppUserConfigDir(dlog.NewTestContext(t, false), dir)
+	key, err := fileKey(ctx)
+	require.NoError(t, err)
+	assert.Len(t, key, keySize)
+
+	st, err := os.Stat(filepath.Join(dir, keyFile))
+	require.NoError(t, err)
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0o600), st.Mode().Perm())
+	}
+
+	again, err := fileKey(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, key, again)
+}
+
+func TestReadDataKey(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	t.Setenv(KeyEnv, "")
+	resetKey()
+	defer resetKey()
+
+	key, err := newKey()
+	require.NoError(t, err)
+	require.NoError(t, ReadDataKey(strings.NewReader(encodeKey(key)+"\n")))
+	got, err := DataKey(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, key, got)
+
+	// EOF terminates the key as well.
+	require.NoError(t, ReadDataKey(strings.NewReader(encodeKey(key))))
+
+	assert.Error(t, ReadDataKey(strings.NewReader("")))
+	assert.Error(t, ReadDataKey(strings.NewReader("c2hvcnQ=\n")))
+}
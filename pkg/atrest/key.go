@@ -0,0 +1,157 @@
+package atrest
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+const (
+	// KeyEnv is the environment variable that, when set, contains the base64 encoded key used to encrypt
+	// secret cache files. It takes precedence over the keychain and the key file.
+	KeyEnv = "TELEPRESENCE_CACHE_KEY"
+
+	// keyService is the service of all secrets that are stored in the keychain, and keyLabel their label.
+	keyService = "telepresence"
+	keyLabel   = "Telepresence"
+	keyAccount = "cache-key"
+
+	// keyFile is the file in the user's config directory that holds the key when no keychain is available.
+	keyFile = "cache.key"
+
+	keySize = 32
+)
+
+// errKeyNotFound is returned by keychainGet when the keychain has no secret for the account.
+var errKeyNotFound = errors.New("key not found")
+
+var (
+	keyLock   sync.Mutex
+	cachedKey []byte
+)
+
+// DataKey returns the key used to encrypt secret cache files. The key is taken from the KeyEnv environment
+// variable when it is set. Otherwise, it is read from the OS keychain, or from a file in the user's config
+// directory when no keychain is available. A new key is created when none exists.
+func DataKey(ctx context.Context) ([]byte, error) {
+	keyLock.Lock()
+	defer keyLock.Unlock()
+	if cachedKey != nil {
+		return cachedKey, nil
+	}
+	var key []byte
+	var err error
+	if ek := os.Getenv(KeyEnv); ek != "" {
+		if key, err = decodeKey(ek); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", KeyEnv, err)
+		}
+	} else if key, err = keychainKey(ctx); err != nil {
+		dlog.Debugf(ctx, "unable to use the keychain for the cache key, using a key file instead: %v", err)
+		if key, err = fileKey(ctx); err != nil {
+			return nil, err
+		}
+	}
+	cachedKey = key
+	return key, nil
+}
+
+// ReadDataKey reads a base64 encoded key, terminated by newline or EOF, from the given reader and makes it the
+// key returned by DataKey. It is used by daemons that run in a container, where the keychain of the host isn't
+// available, and that receive the key on stdin so that it never appears in the container's environment.
+func ReadDataKey(r io.Reader) error {
+	ek, err := bufio.NewReader(io.LimitReader(r, 1024)).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("unable to read the cache key: %w", err)
+	}
+	key, err := decodeKey(ek)
+	if err != nil {
+		return fmt.Errorf("invalid cache key: %w", err)
+	}
+	keyLock.Lock()
+	cachedKey = key
+	keyLock.Unlock()
+	return nil
+}
+
+// EncodedDataKey returns the base64 encoded DataKey, suitable as input to ReadDataKey.
+func EncodedDataKey(ctx context.Context) (string, error) {
+	key, err := DataKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	return encodeKey(key), nil
+}
+
+func keychainKey(ctx context.Context) ([]byte, error) {
+	ek, err := keychainGet(ctx, keyAccount)
+	switch {
+	case err == nil:
+		return decodeKey(ek)
+	case !errors.Is(err, errKeyNotFound):
+		return nil, err
+	}
+	key, err := newKey()
+	if err != nil {
+		return nil, err
+	}
+	if err = keychainSet(ctx, keyAccount, encodeKey(key)); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func fileKey(ctx context.Context) ([]byte, error) {
+	path := filepath.Join(filelocation.AppUserConfigDir(ctx), keyFile)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return decodeKey(string(data))
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	key, err := newKey()
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	if err = os.WriteFile(path, []byte(encodeKey(key)), 0o600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func newKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func encodeKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func decodeKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", keySize, len(key))
+	}
+	return key, nil
+}
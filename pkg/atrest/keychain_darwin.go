@@ -0,0 +1,55 @@
+package atrest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/datawire/dlib/dexec"
+)
+
+// errSecItemNotFound is the exit code of the security command when the item doesn't exist.
+const errSecItemNotFound = 44
+
+// keychainGet reads the secret of the given account from the login keychain using the security command.
+func keychainGet(ctx context.Context, account string) (string, error) {
+	cmd := dexec.CommandContext(ctx, "security", "find-generic-password", "-s", keyService, "-a", account, "-w")
+	cmd.DisableLogging = true
+	out, err := cmd.Output()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && ee.ExitCode() == errSecItemNotFound {
+			return "", errKeyNotFound
+		}
+		return "", fmt.Errorf("security find-generic-password: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// keychainSet stores the secret of the given account in the login keychain. The command is passed on stdin
+// so that the secret doesn't show up in the process list.
+func keychainSet(ctx context.Context, account, secret string) error {
+	cmd := dexec.CommandContext(ctx, "security", "-i")
+	cmd.DisableLogging = true
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %q -l %q -w %q\n", keyService, account, keyLabel, secret))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("security add-generic-password: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// keychainDelete removes the secret of the given account from the login keychain.
+func keychainDelete(ctx context.Context, account string) error {
+	cmd := dexec.CommandContext(ctx, "security", "delete-generic-password", "-s", keyService, "-a", account)
+	cmd.DisableLogging = true
+	if out, err := cmd.CombinedOutput(); err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && ee.ExitCode() == errSecItemNotFound {
+			return nil
+		}
+		return fmt.Errorf("security delete-generic-password: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+//go:build !darwin && !windows
+// +build !darwin,!windows
+
+package atrest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/datawire/dlib/dexec"
+)
+
+// keychainGet reads the secret of the given account from the Secret Service (GNOME Keyring, KWallet) using
+// secret-tool.
+func keychainGet(ctx context.Context, account string) (string, error) {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return "", err
+	}
+	cmd := dexec.CommandContext(ctx, "secret-tool", "lookup", "service", keyService, "account", account)
+	cmd.DisableLogging = true
+	stderr := bytes.Buffer{}
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) && len(out) == 0 && stderr.Len() == 0 {
+			// secret-tool exits with 1 and prints nothing when the secret doesn't exist.
+			return "", errKeyNotFound
+		}
+		return "", fmt.Errorf("secret-tool lookup: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// keychainSet stores the secret of the given account in the Secret Service. The secret is passed on stdin so
+// that it doesn't show up in the process list.
+func keychainSet(ctx context.Context, account, secret string) error {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return err
+	}
+	cmd := dexec.CommandContext(ctx, "secret-tool", "store", "--label", keyLabel, "service", keyService, "account", account)
+	cmd.DisableLogging = true
+	cmd.Stdin = strings.NewReader(secret)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool store: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// keychainDelete removes the secret of the given account from the Secret Service.
+func keychainDelete(ctx context.Context, account string) error {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return err
+	}
+	cmd := dexec.CommandContext(ctx, "secret-tool", "clear", "service", keyService, "account", account)
+	cmd.DisableLogging = true
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool clear: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
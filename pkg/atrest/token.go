@@ -0,0 +1,84 @@
+package atrest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+// tokensDir is the directory in the user's config directory that holds encrypted tokens when no keychain is
+// available, which is common in CI environments.
+const tokensDir = "tokens"
+
+func tokenAccount(name string) string {
+	return "token:" + name
+}
+
+func tokenFile(ctx context.Context, name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return filepath.Join(filelocation.AppUserConfigDir(ctx), tokensDir, hex.EncodeToString(sum[:]))
+}
+
+// StoreToken stores the given token under the given name in the OS keychain, or in an encrypted file in the
+// user's config directory when no keychain is available. It returns true if the keychain was used.
+func StoreToken(ctx context.Context, name, token string) (bool, error) {
+	err := keychainSet(ctx, tokenAccount(name), token)
+	if err == nil {
+		// Remove a file that was stored when the keychain wasn't available.
+		if err = os.Remove(tokenFile(ctx, name)); err != nil && !os.IsNotExist(err) {
+			dlog.Warn(ctx, err)
+		}
+		return true, nil
+	}
+	dlog.Debugf(ctx, "unable to store token %s in the keychain, using a file instead: %v", name, err)
+	data, err := Encrypt(ctx, []byte(token))
+	if err != nil {
+		return false, err
+	}
+	path := tokenFile(ctx, name)
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return false, err
+	}
+	return false, os.WriteFile(path, data, 0o600)
+}
+
+// LoadToken returns the token stored under the given name, or an empty string when no such token exists.
+func LoadToken(ctx context.Context, name string) (string, error) {
+	token, err := keychainGet(ctx, tokenAccount(name))
+	switch {
+	case err == nil:
+		return token, nil
+	case !errors.Is(err, errKeyNotFound):
+		dlog.Debugf(ctx, "unable to read token %s from the keychain: %v", name, err)
+	}
+	data, err := os.ReadFile(tokenFile(ctx, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return "", err
+	}
+	if data, err = Decrypt(ctx, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// DeleteToken removes the token stored under the given name from the keychain and from the user's config
+// directory. Removing a token that doesn't exist is not an error.
+func DeleteToken(ctx context.Context, name string) error {
+	if err := keychainDelete(ctx, tokenAccount(name)); err != nil {
+		dlog.Debugf(ctx, "unable to delete token %s from the keychain: %v", name, err)
+	}
+	if err := os.Remove(tokenFile(ctx, name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
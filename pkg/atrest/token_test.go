@@ -0,0 +1,48 @@
+package atrest
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+func TestTokenFileFallback(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the keychain can only be made unavailable on linux")
+	}
+	// Without secret-tool in the PATH, there's no keychain.
+	t.Setenv("PATH", "")
+	key, err := newKey()
+	require.NoError(t, err)
+	t.Setenv(KeyEnv, encodeKey(key))
+	resetKey()
+	defer resetKey()
+	ctx := filelocation.WithAppUserConfigDir(dlog.NewTestContext(t, false), t.TempDir())
+
+	token, err := LoadToken(ctx, "manager:tm.example.com:443")
+	require.NoError(t, err)
+	assert.Empty(t, token)
+
+	inKeychain, err := StoreToken(ctx, "manager:tm.example.com:443", "s3cr3t")
+	require.NoError(t, err)
+	assert.False(t, inKeychain)
+
+	data, err := os.ReadFile(tokenFile(ctx, "manager:tm.example.com:443"))
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(data))
+
+	token, err = LoadToken(ctx, "manager:tm.example.com:443")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cr3t", token)
+
+	require.NoError(t, DeleteToken(ctx, "manager:tm.example.com:443"))
+	token, err = LoadToken(ctx, "manager:tm.example.com:443")
+	require.NoError(t, err)
+	assert.Empty(t, token)
+}
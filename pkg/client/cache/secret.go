@@ -0,0 +1,67 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/atrest"
+	"github.com/telepresenceio/telepresence/v2/pkg/dos"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+// WriteSecretFile encrypts the given data and writes it to the given file.
+func WriteSecretFile(ctx context.Context, path string, data []byte, perm fs.FileMode) error {
+	data, err := atrest.Encrypt(ctx, data)
+	if err != nil {
+		return err
+	}
+	return dos.WriteFile(ctx, path, data, perm)
+}
+
+// ReadSecretFile reads the given file and decrypts its content. A file that isn't encrypted, because it
+// was written by an older version, is encrypted in place, and its content is returned.
+func ReadSecretFile(ctx context.Context, path string) ([]byte, error) {
+	data, err := dos.ReadFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if !atrest.IsEncrypted(data) {
+		if err := WriteSecretFile(ctx, path, data, 0o600); err != nil {
+			dlog.Warnf(ctx, "unable to encrypt %s: %v", path, err)
+		}
+		return data, nil
+	}
+	return atrest.Decrypt(ctx, data)
+}
+
+// SaveSecretToUserCache is like SaveToUserCache, but the file is encrypted.
+func SaveSecretToUserCache(ctx context.Context, object any, file string) error {
+	ctx = dos.WithLockedFs(ctx)
+	jsonContent, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+	fullFilePath := filepath.Join(filelocation.AppUserCacheDir(ctx), file)
+	if err := dos.MkdirAll(ctx, filepath.Dir(fullFilePath), 0o700); err != nil {
+		return err
+	}
+	return WriteSecretFile(ctx, fullFilePath, jsonContent, 0o600)
+}
+
+// LoadSecretFromUserCache is like LoadFromUserCache, but a file that isn't encrypted is encrypted in place.
+func LoadSecretFromUserCache(ctx context.Context, dest any, file string) error {
+	ctx = dos.WithLockedFs(ctx)
+	path := filepath.Join(filelocation.AppUserCacheDir(ctx), file)
+	jsonContent, err := ReadSecretFile(ctx, path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(jsonContent, &dest); err != nil {
+		return fmt.Errorf("failed to parse JSON from file %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/atrest"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+func TestLoadSecretFromUserCache(t *testing.T) {
+	t.Setenv(atrest.KeyEnv, "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	dir := t.TempDir()
+	ctx := filelocation.WithAppUserCacheDir(dlog.NewTestContext(t, false), dir)
+
+	type secret struct {
+		Token string `json:"token"`
+	}
+
+	// A plaintext file written by an older version is migrated when loaded.
+	path := filepath.Join(dir, "sessions", "old.json")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, []byte(`{"token":"t1"}`), 0o600))
+	var s secret
+	require.NoError(t, LoadSecretFromUserCache(ctx, &s, filepath.Join("sessions", "old.json")))
+	assert.Equal(t, "t1", s.Token)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, atrest.IsEncrypted(data))
+
+	require.NoError(t, LoadSecretFromUserCache(ctx, &s, filepath.Join("sessions", "old.json")))
+	assert.Equal(t, "t1", s.Token)
+
+	require.NoError(t, SaveSecretToUserCache(ctx, &secret{Token: "t2"}, filepath.Join("sessions", "new.json")))
+	data, err = os.ReadFile(filepath.Join(dir, "sessions", "new.json"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "t2")
+	require.NoError(t, LoadSecretFromUserCache(ctx, &s, filepath.Join("sessions", "new.json")))
+	assert.Equal(t, "t2", s.Token)
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+func admin() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Administer the traffic-manager of the connected cluster",
+		Args:  OnlySubcommands,
+		RunE:  RunSubcommands,
+	}
+	cmd.AddCommand(adminManager(), adminUsage())
+	return cmd
+}
+
+type usageInfo struct {
+	User             string   `json:"user" yaml:"user"`
+	Connects         int32    `json:"connects" yaml:"connects"`
+	Intercepts       int32    `json:"intercepts" yaml:"intercepts"`
+	InterceptSeconds int64    `json:"intercept_seconds" yaml:"intercept_seconds"`
+	Namespaces       []string `json:"namespaces" yaml:"namespaces"`
+}
+
+type usageReport struct {
+	Since          time.Time   `json:"since" yaml:"since"`
+	Until          time.Time   `json:"until" yaml:"until"`
+	CollectedSince time.Time   `json:"collected_since" yaml:"collected_since"`
+	Users          []usageInfo `json:"users" yaml:"users"`
+}
+
+func adminUsage() *cobra.Command {
+	var since string
+	var asCSV bool
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Args:  cobra.NoArgs,
+		Short: "Report the connects, intercept durations, and namespaces touched, per user",
+		Long: `Report the connects, intercept durations, and namespaces touched, per user.
+
+The report is collected by the traffic-manager, which retains it for 90 days, but not across
+restarts. Use --output=json or --csv to export it.`,
+		Annotations: map[string]string{
+			ann.Session: ann.Required,
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			d, err := parseSince(since)
+			if err != nil {
+				return errcat.User.New(err)
+			}
+			if err := connect.InitCommand(cmd); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			r, err := daemon.GetUserClient(ctx).GetUsageReport(ctx, &manager.UsageRequest{
+				Since: timestamppb.New(time.Now().Add(-d)),
+			})
+			if err != nil {
+				return err
+			}
+			report := newUsageReport(r)
+			switch {
+			case output.WantsFormatted(cmd):
+				output.Object(ctx, report, false)
+			case asCSV:
+				return report.writeCSV(cmd.OutOrStdout())
+			default:
+				report.print(cmd.OutOrStdout())
+			}
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&since, "since", "30d", "Report the usage during this period, e.g. 12h or 30d")
+	flags.BoolVar(&asCSV, "csv", false, "Write the report in CSV format")
+	return cmd
+}
+
+// parseSince parses a duration that, in addition to the units understood by time.ParseDuration,
+// may be given as a number of days, e.g. "30d".
+func parseSince(s string) (time.Duration, error) {
+	var d time.Duration
+	var err error
+	if n := strings.TrimSuffix(s, "d"); len(n) < len(s) {
+		var days int
+		if days, err = strconv.Atoi(n); err == nil {
+			d = time.Duration(days) * 24 * time.Hour
+		}
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid --since %q: must be a positive duration, e.g. 12h or 30d", s)
+	}
+	return d, nil
+}
+
+func newUsageReport(r *manager.UsageReport) *usageReport {
+	ur := &usageReport{
+		Since:          r.Since.AsTime().Local(),
+		Until:          r.Until.AsTime().Local(),
+		CollectedSince: r.CollectedSince.AsTime().Local(),
+		Users:          make([]usageInfo, len(r.Users)),
+	}
+	for i, u := range r.Users {
+		ur.Users[i] = usageInfo{
+			User:             u.User,
+			Connects:         u.Connects,
+			Intercepts:       u.Intercepts,
+			InterceptSeconds: int64(u.InterceptDuration.AsDuration().Seconds()),
+			Namespaces:       u.Namespaces,
+		}
+	}
+	return ur
+}
+
+func (r *usageReport) writeCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+	_ = w.Write([]string{"user", "connects", "intercepts", "intercept_seconds", "namespaces"})
+	for _, u := range r.Users {
+		_ = w.Write([]string{
+			u.User,
+			strconv.Itoa(int(u.Connects)),
+			strconv.Itoa(int(u.Intercepts)),
+			strconv.FormatInt(u.InterceptSeconds, 10),
+			strings.Join(u.Namespaces, ";"),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (r *usageReport) print(out io.Writer) {
+	fmt.Fprintf(out, "Usage from %s until %s\n", r.Since.Format(time.RFC1123), r.Until.Format(time.RFC1123))
+	if !r.Since.After(r.CollectedSince) {
+		fmt.Fprintln(out, "The traffic-manager has not collected usage before that, e.g. because it was restarted")
+	}
+	if len(r.Users) == 0 {
+		fmt.Fprintln(out, "No usage was reported")
+		return
+	}
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "USER\tCONNECTS\tINTERCEPTS\tINTERCEPT TIME\tNAMESPACES")
+	for _, u := range r.Users {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\n", u.User, u.Connects, u.Intercepts,
+			time.Duration(u.InterceptSeconds)*time.Second, strings.Join(u.Namespaces, ","))
+	}
+	_ = tw.Flush()
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+// managerProfiles are the profiles that the traffic-manager can produce.
+var managerProfiles = []string{"cpu", "trace", "heap", "allocs", "goroutine", "block", "mutex", "threadcreate"} //nolint:gochecknoglobals // constant
+
+func adminManager() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "manager",
+		Short: "Control the log-level and profiling of the traffic-manager",
+		Args:  OnlySubcommands,
+		RunE:  RunSubcommands,
+	}
+	cmd.AddCommand(adminManagerLogLevel(), adminManagerPprof())
+	return cmd
+}
+
+func adminManagerLogLevel() *cobra.Command {
+	lvStrs := logLevelNames()
+	var duration time.Duration
+	cmd := &cobra.Command{
+		Use:   fmt.Sprintf("loglevel <%s>", strings.Join(lvStrs, ",")),
+		Args:  logLevelArg,
+		Short: "Temporarily change the log-level of the traffic-manager and its traffic-agents",
+		Long: `Temporarily change the log-level of the traffic-manager and its traffic-agents.
+
+The log-level is changed by the traffic-manager itself, so no kubectl access to the traffic-manager's
+namespace is needed. The configured log-level is restored when the duration has passed.`,
+		Example:   "  telepresence admin manager loglevel debug --duration 15m",
+		ValidArgs: lvStrs,
+		Annotations: map[string]string{
+			ann.Session: ann.Required,
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := connect.InitCommand(cmd); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			_, err := daemon.GetUserClient(ctx).SetLogLevel(ctx, &connector.LogLevelRequest{
+				LogLevel: args[0],
+				Duration: durationpb.New(duration),
+				Scope:    connector.LogLevelRequest_REMOTE_ONLY,
+			})
+			if err != nil {
+				return err
+			}
+			if duration > 0 {
+				fmt.Fprintf(output.Info(ctx), "The log-level of the traffic-manager is %s for %s\n", args[0], duration)
+			} else {
+				fmt.Fprintf(output.Info(ctx), "The log-level of the traffic-manager is %s\n", args[0])
+			}
+			return nil
+		},
+	}
+	cmd.Flags().DurationVarP(&duration, "duration", "d", defaultDuration, "The time that the log-level will be in effect (0s means indefinitely)")
+	return cmd
+}
+
+type managerPprofCommand struct {
+	profile  string
+	duration time.Duration
+	debug    int
+	file     string
+}
+
+func adminManagerPprof() *cobra.Command {
+	pc := &managerPprofCommand{}
+	cmd := &cobra.Command{
+		Use:   "pprof",
+		Args:  cobra.NoArgs,
+		Short: "Fetch a pprof profile of the traffic-manager",
+		Long: `Fetch a pprof profile of the traffic-manager.
+
+The profile is produced by the traffic-manager itself, so neither kubectl exec nor a port-forward
+is needed. A cpu or trace profile is collected during the given duration. Other profiles are
+snapshots, written in the format that "go tool pprof" reads unless --debug is given.`,
+		Example: `  telepresence admin manager pprof --profile heap
+  telepresence admin manager pprof --profile cpu --duration 1m --file cpu.pprof`,
+		Annotations: map[string]string{
+			ann.Session: ann.Required,
+		},
+		RunE: pc.run,
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&pc.profile, "profile", "heap", fmt.Sprintf("The profile to fetch, one of %s", strings.Join(managerProfiles, ", ")))
+	flags.DurationVar(&pc.duration, "duration", 30*time.Second, "The time that a cpu or trace profile is collected")
+	flags.IntVar(&pc.debug, "debug", 0, "Write other profiles than cpu and trace in a text format. See runtime/pprof.Profile.WriteTo")
+	flags.StringVarP(&pc.file, "file", "f", "", `The file to write the profile to. Defaults to traffic-manager-<profile>.pprof, and "-" means stdout`)
+	_ = cmd.RegisterFlagCompletionFunc("profile", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return managerProfiles, cobra.ShellCompDirectiveNoFileComp
+	})
+	return cmd
+}
+
+func (pc *managerPprofCommand) defaultFile() string {
+	switch {
+	case pc.profile == "trace":
+		return "traffic-manager.trace"
+	case pc.debug > 0 && pc.profile != "cpu":
+		return "traffic-manager-" + pc.profile + ".txt"
+	default:
+		return "traffic-manager-" + pc.profile + ".pprof"
+	}
+}
+
+func (pc *managerPprofCommand) run(cmd *cobra.Command, _ []string) error {
+	known := false
+	for _, p := range managerProfiles {
+		if p == pc.profile {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return errcat.User.Newf("unknown profile %q, must be one of %s", pc.profile, strings.Join(managerProfiles, ", "))
+	}
+	if pc.duration <= 0 {
+		return errcat.User.New("the duration must be positive")
+	}
+	if err := connect.InitCommand(cmd); err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	if pc.profile == "cpu" || pc.profile == "trace" {
+		fmt.Fprintf(output.Info(ctx), "Collecting a %s profile of the traffic-manager for %s\n", pc.profile, pc.duration)
+	}
+	r, err := daemon.GetUserClient(ctx).GetManagerProfile(ctx, &manager.ProfileRequest{
+		Profile:  pc.profile,
+		Duration: durationpb.New(pc.duration),
+		Debug:    int32(pc.debug),
+	})
+	if err != nil {
+		return err
+	}
+	file := pc.file
+	if file == "-" {
+		_, err = cmd.OutOrStdout().Write(r.Data)
+		return err
+	}
+	if file == "" {
+		file = pc.defaultFile()
+	}
+	if err = os.WriteFile(file, r.Data, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(output.Info(ctx), "Wrote the %s profile of the traffic-manager to %s\n", pc.profile, file)
+	return nil
+}
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSince(t *testing.T) {
+	d, err := parseSince("30d")
+	require.NoError(t, err)
+	assert.Equal(t, 30*24*time.Hour, d)
+
+	d, err = parseSince("90m")
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Minute, d)
+
+	for _, s := range []string{"", "d", "-1d", "0s", "forever"} {
+		_, err = parseSince(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestUsageReportCSV(t *testing.T) {
+	r := &usageReport{Users: []usageInfo{
+		{User: "alice", Connects: 3, Intercepts: 2, InterceptSeconds: 5400, Namespaces: []string{"dev", "echo"}},
+		{User: "bob", Connects: 1},
+	}}
+	out := &strings.Builder{}
+	require.NoError(t, r.writeCSV(out))
+	assert.Equal(t, `user,connects,intercepts,intercept_seconds,namespaces
+alice,3,2,5400,dev;echo
+bob,1,0,0,
+`, out.String())
+}
+
+func TestManagerPprofDefaultFile(t *testing.T) {
+	assert.Equal(t, "traffic-manager-heap.pprof", (&managerPprofCommand{profile: "heap"}).defaultFile())
+	assert.Equal(t, "traffic-manager-goroutine.txt", (&managerPprofCommand{profile: "goroutine", debug: 2}).defaultFile())
+	assert.Equal(t, "traffic-manager-cpu.pprof", (&managerPprofCommand{profile: "cpu", debug: 1}).defaultFile())
+	assert.Equal(t, "traffic-manager.trace", (&managerPprofCommand{profile: "trace"}).defaultFile())
+}
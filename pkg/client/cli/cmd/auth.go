@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/atrest"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+func auth() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "auth",
+		Short: "Manage stored credentials",
+		Args:  OnlySubcommands,
+		RunE:  RunSubcommands,
+	}
+	cmd.AddCommand(authToken())
+	return cmd
+}
+
+func authToken() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: "Manage the bearer token sent to a traffic-manager endpoint",
+		Long: `Manage the bearer token sent to a traffic-manager that is dialed directly, using the manager
+address of the kubeconfig extension or of the client configuration, instead of through a port-forward.
+
+The token is stored in the OS keychain (the login keychain on macOS, the Credential Manager on Windows,
+and the Secret Service on Linux). When no keychain is available, which is common in CI environments,
+the token is stored encrypted in the user's config directory. A configured token file takes precedence
+over a stored token.`,
+		Args: OnlySubcommands,
+		RunE: RunSubcommands,
+	}
+	cmd.AddCommand(authTokenSet(), authTokenDelete())
+	return cmd
+}
+
+type authTokenCommand struct {
+	address string
+	file    string
+}
+
+func (ac *authTokenCommand) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&ac.address, "address", "", "The traffic-manager address. Defaults to the manager address of the current context")
+}
+
+// endpoint returns the traffic-manager endpoint that the token is for.
+func (ac *authTokenCommand) endpoint(cmd *cobra.Command) (*client.ManagerEndpoint, error) {
+	if ac.address != "" {
+		return &client.ManagerEndpoint{Address: ac.address}, nil
+	}
+	ctx, err := daemon.WithDefaultRequest(cmd.Context(), cmd)
+	if err != nil {
+		return nil, err
+	}
+	rq := daemon.GetRequest(ctx)
+	kc, err := client.NewKubeconfig(ctx, rq.KubeFlags, rq.ManagerNamespace)
+	if err != nil {
+		return nil, err
+	}
+	ep := kc.GetManagerEndpoint(ctx)
+	if ep == nil {
+		return nil, errcat.User.New("no manager address is configured for the current context, use --address")
+	}
+	return ep, nil
+}
+
+func authTokenSet() *cobra.Command {
+	ac := &authTokenCommand{}
+	cmd := &cobra.Command{
+		Use:   "set",
+		Args:  cobra.NoArgs,
+		Short: "Store the token sent to a traffic-manager endpoint",
+		Long: `Store the token sent to a traffic-manager endpoint. The token is read from standard input, or
+from the file given with --from-file, so that it doesn't end up in the shell history, e.g.
+
+  echo "$MANAGER_TOKEN" | telepresence auth token set`,
+		RunE: ac.runSet,
+	}
+	ac.addFlags(cmd)
+	cmd.Flags().StringVar(&ac.file, "from-file", "", "Read the token from this file instead of from standard input")
+	return cmd
+}
+
+func (ac *authTokenCommand) runSet(cmd *cobra.Command, _ []string) error {
+	ep, err := ac.endpoint(cmd)
+	if err != nil {
+		return err
+	}
+	var in io.Reader
+	if ac.file != "" {
+		f, err := os.Open(ac.file)
+		if err != nil {
+			return errcat.User.New(err)
+		}
+		defer f.Close()
+		in = f
+	} else {
+		if f, ok := cmd.InOrStdin().(*os.File); ok {
+			if st, err := f.Stat(); err == nil && st.Mode()&os.ModeCharDevice != 0 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Token for %s: ", ep.Address)
+			}
+		}
+		in = cmd.InOrStdin()
+	}
+	token, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if token = strings.TrimSpace(token); token == "" {
+		return errcat.User.New("the token is empty")
+	}
+	ctx := cmd.Context()
+	inKeychain, err := atrest.StoreToken(ctx, ep.TokenName(), token)
+	if err != nil {
+		return err
+	}
+	where := "the keychain"
+	if !inKeychain {
+		where = "an encrypted file, because no keychain is available"
+	}
+	fmt.Fprintf(output.Out(ctx), "Token for %s stored in %s\n", ep.Address, where)
+	return nil
+}
+
+func authTokenDelete() *cobra.Command {
+	ac := &authTokenCommand{}
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Args:  cobra.NoArgs,
+		Short: "Delete the stored token of a traffic-manager endpoint",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ep, err := ac.endpoint(cmd)
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			if err = atrest.DeleteToken(ctx, ep.TokenName()); err != nil {
+				return err
+			}
+			fmt.Fprintf(output.Out(ctx), "Token for %s deleted\n", ep.Address)
+			return nil
+		},
+	}
+	ac.addFlags(cmd)
+	return cmd
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	daemonRpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/pcap"
+)
+
+type captureArgs struct {
+	subnets  []string
+	out      string
+	filter   string
+	snapLen  int
+	count    int
+	maxSize  string
+	duration time.Duration
+}
+
+func capture() *cobra.Command {
+	ca := captureArgs{}
+	cmd := &cobra.Command{
+		Use:   "capture --out <file> [filter expression]",
+		Args:  cobra.ArbitraryArgs,
+		Short: "Capture the packets that traverse the TUN-device to a pcap file",
+		Long: `Capture the packets that traverse the TUN-device of the root daemon to a file in pcap format,
+which can be analyzed using tools like Wireshark or tcpdump.
+
+The capture continues until it's interrupted, or until one of the --count, --max-size, or
+--duration limits is reached. The filter expression uses a subset of the tcpdump filter
+syntax. The primitives ip, ip6, tcp, udp, icmp, icmp6, [src|dst] host <address>,
+[src|dst] net <cidr>, [src|dst] port <port>, [src|dst] portrange <port>-<port>, less <length>,
+and greater <length> can be combined using and, or, not, and parentheses.`,
+		Example: `# Capture all HTTP traffic to pods in 10.1.0.0/16 for one minute
+telepresence capture --subnet 10.1.0.0/16 --out http.pcap --duration 1m tcp port 80
+
+# Show the captured packets live in Wireshark
+telepresence capture --out - | wireshark -k -i -`,
+		Annotations: map[string]string{
+			ann.Session: ann.Required,
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				if ca.filter != "" {
+					return errcat.User.New("the filter expression must be given either using the --filter flag or as arguments, not both")
+				}
+				ca.filter = strings.Join(args, " ")
+			}
+			return ca.run(cmd)
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVarP(&ca.out, "out", "o", "", `The file to write the captured packets to, or "-" to write them to stdout`)
+	flags.StringSliceVar(&ca.subnets, "subnet", nil,
+		"Only capture packets with a source or destination in this subnet. Can be repeated. Default is all packets")
+	flags.StringVar(&ca.filter, "filter", "", "Only capture packets that match this filter expression")
+	flags.IntVar(&ca.snapLen, "snap-len", 0, "The maximum number of bytes to capture from each packet. Default is the whole packet")
+	flags.IntVarP(&ca.count, "count", "c", 0, "Stop after capturing this number of packets")
+	flags.StringVar(&ca.maxSize, "max-size", "", `Stop before the file grows beyond this size, e.g. "10Mi"`)
+	flags.DurationVar(&ca.duration, "duration", 0, "Stop after capturing for this long")
+	_ = cmd.MarkFlagRequired("out")
+	return cmd
+}
+
+func (ca *captureArgs) run(cmd *cobra.Command) error {
+	// Validate the filter before anything is created, so that errors are reported in the same way
+	// as other argument errors.
+	if _, err := pcap.ParseFilter(ca.filter); err != nil {
+		return errcat.User.New(err)
+	}
+	rq := &daemonRpc.CaptureRequest{
+		Filter:  ca.filter,
+		SnapLen: int32(ca.snapLen),
+	}
+	for _, s := range ca.subnets {
+		_, sn, err := net.ParseCIDR(s)
+		if err != nil {
+			return errcat.User.Newf("invalid --subnet %q: %w", s, err)
+		}
+		rq.Subnets = append(rq.Subnets, iputil.IPNetToRPC(sn))
+	}
+	var maxSize int64
+	if ca.maxSize != "" {
+		q, err := resource.ParseQuantity(ca.maxSize)
+		if err != nil {
+			return errcat.User.Newf("invalid --max-size %q: %w", ca.maxSize, err)
+		}
+		maxSize = q.Value()
+	}
+	if err := connect.InitCommand(cmd); err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if ca.duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, ca.duration)
+		defer cancel()
+	}
+
+	var out io.Writer
+	if ca.out == "-" {
+		out = cmd.OutOrStdout()
+	} else {
+		f, err := os.Create(ca.out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	bw := bufio.NewWriter(out)
+	pw, err := pcap.NewWriter(bw, ca.snapLen)
+	if err != nil {
+		return err
+	}
+
+	stream, err := daemon.GetUserClient(ctx).Capture(ctx, rq)
+	if err != nil {
+		return err
+	}
+
+	info := cmd.ErrOrStderr()
+	fmt.Fprintln(info, "Capturing packets. Press Ctrl-C to stop")
+	captured, dropped := 0, uint64(0)
+	err = func() error {
+		for ca.count <= 0 || captured < ca.count {
+			cp, err := stream.Recv()
+			if err != nil {
+				if errors.Is(err, io.EOF) || ctx.Err() != nil {
+					return nil
+				}
+				if st, ok := status.FromError(err); ok && st.Code() == codes.InvalidArgument {
+					return errcat.User.New(st.Message())
+				}
+				return err
+			}
+			dropped += uint64(cp.Dropped)
+			if maxSize > 0 && pw.Written()+int64(pcap.PacketHeaderLen+len(cp.Data)) > maxSize {
+				return nil
+			}
+			if err = pw.WritePacket(cp.Timestamp.AsTime(), cp.Data, int(cp.OrigLen)); err != nil {
+				return err
+			}
+			captured++
+			if ca.out == "-" {
+				// Live consumers, such as Wireshark, want each packet as soon as it arrives.
+				if err = bw.Flush(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}()
+	if ferr := bw.Flush(); err == nil {
+		err = ferr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(info, "Captured %d packets", captured)
+	if dropped > 0 {
+		fmt.Fprintf(info, ", %d packets were dropped", dropped)
+	}
+	if ca.out != "-" {
+		fmt.Fprintf(info, " to %s", ca.out)
+	}
+	fmt.Fprintln(info)
+	return nil
+}
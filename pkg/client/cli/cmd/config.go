@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"encoding/json"
+	"fmt"
 	"path/filepath"
 
 	"github.com/spf13/cobra"
@@ -20,10 +21,37 @@ func config() *cobra.Command {
 	cmd := &cobra.Command{
 		Use: "config",
 	}
-	cmd.AddCommand(configView())
+	cmd.AddCommand(configView(), configLintKubeconfig())
 	return cmd
 }
 
+func configLintKubeconfig() *cobra.Command {
+	return &cobra.Command{
+		Use:   "lint-kubeconfig",
+		Args:  cobra.NoArgs,
+		Short: "Validate the telepresence.io extension of the current kubeconfig cluster",
+		Long: `Validate the telepresence.io extension of the cluster of the current kubeconfig context, or of
+the context given with --context, without connecting to the cluster. Unknown fields and invalid values
+are reported with their line and column in the kubeconfig file.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, err := daemon.WithDefaultRequest(cmd.Context(), cmd)
+			if err != nil {
+				return err
+			}
+			clusterName, found, err := client.LintKubeconfig(daemon.GetRequest(ctx).KubeFlags)
+			if err != nil {
+				return err
+			}
+			if found {
+				fmt.Fprintf(output.Out(ctx), "The telepresence.io extension of cluster %q is valid\n", clusterName)
+			} else {
+				fmt.Fprintf(output.Out(ctx), "Cluster %q has no telepresence.io extension\n", clusterName)
+			}
+			return nil
+		},
+	}
+}
+
 func configView() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:               "view",
@@ -52,6 +80,7 @@ func runConfigView(cmd *cobra.Command, _ []string) error {
 		ctx := cmd.Context()
 		uc := daemon.GetUserClient(ctx)
 		cfg.Config = client.GetConfig(ctx)
+		cfg.ActiveTimeouts = cfg.Config.Timeouts().Active()
 		cfg.ClientFile = filepath.Join(filelocation.AppUserConfigDir(ctx), client.ConfigFile)
 		var kc *client.Kubeconfig
 		var err error
@@ -79,6 +108,7 @@ func runConfigView(cmd *cobra.Command, _ []string) error {
 			cfg.DNS.LookupTimeout = dns.LookupTimeout.Duration
 			cfg.DNS.LocalIP = dns.LocalIP.IP()
 			cfg.DNS.RemoteIP = dns.RemoteIP.IP()
+			cfg.DNS.ClusterDomain = dns.ClusterDomain
 		}
 		if mgr := kc.Manager; mgr != nil {
 			cfg.ManagerNamespace = mgr.Namespace
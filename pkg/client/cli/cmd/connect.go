@@ -1,30 +1,66 @@
 package cmd
 
 import (
+	"path/filepath"
+
 	"github.com/spf13/cobra"
 
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
 )
 
 func connectCmd() *cobra.Command {
 	var request *daemon.Request
+	var interactive bool
+	var profile string
 
 	cmd := &cobra.Command{
 		Use:   "connect [flags] [-- <command to run while connected>]",
 		Args:  cobra.ArbitraryArgs,
 		Short: "Connect to a cluster",
 		Annotations: map[string]string{
-			ann.Session: ann.Required,
+			ann.Session:    ann.Required,
+			ann.RootDaemon: ann.Required,
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if profile != "" {
+				p, err := daemon.LoadProfile(cmd.Context(), profile)
+				if err != nil {
+					return err
+				}
+				if err = daemon.ApplyProfile(cmd, p); err != nil {
+					return err
+				}
+			}
 			if err := request.CommitFlags(cmd); err != nil {
 				return err
 			}
+			if request.EnvJson != "" {
+				if request.Docker {
+					return errcat.User.New("--env-json cannot be used with --docker")
+				}
+				// The file is written by the user daemon, which doesn't share our working directory.
+				var err error
+				if request.EnvJson, err = filepath.Abs(request.EnvJson); err != nil {
+					return errcat.User.New(err)
+				}
+			}
+			if interactive {
+				return runConnectWizard(cmd, request, args)
+			}
 			return connect.RunConnect(cmd, args)
 		},
 	}
 	request = daemon.InitRequest(cmd)
+	cmd.Flags().StringVar(&request.EnvJson, "env-json", "", ``+
+		`Write the environment of the connection (manager namespace, mapped namespaces, subnets, API port) `+
+		`as a JSON blob to this file, and keep it updated until the connection ends`)
+	cmd.Flags().BoolVarP(&interactive, "interactive", "i", false, ``+
+		`Walk through the choice of context, namespace, and traffic-manager, install the traffic-manager if needed, `+
+		`verify the connection, and save the settings to a profile`)
+	cmd.Flags().StringVar(&profile, "profile", "", ``+
+		`Connect using the settings of a profile saved by --interactive. Flags given on the command line take precedence`)
 	return cmd
 }
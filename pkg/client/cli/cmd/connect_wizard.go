@@ -0,0 +1,291 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/global"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/slice"
+)
+
+// wizardCheckTarget is the service that the connect wizard resolves and dials to verify the connection.
+const wizardCheckTarget = "kubernetes.default"
+
+// wizard asks questions on a terminal.
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask asks the given question and returns the answer, or the given default when the answer is empty.
+func (w *wizard) ask(question, dflt string) (string, error) {
+	if dflt != "" {
+		fmt.Fprintf(w.out, "%s [%s]: ", question, dflt)
+	} else {
+		fmt.Fprintf(w.out, "%s: ", question)
+	}
+	answer, err := w.in.ReadString('\n')
+	if err != nil && (err != io.EOF || answer == "") {
+		return "", err
+	}
+	if answer = strings.TrimSpace(answer); answer == "" {
+		answer = dflt
+	}
+	return answer, nil
+}
+
+// confirm asks the given yes/no question.
+func (w *wizard) confirm(question string, dflt bool) (bool, error) {
+	d := "y/N"
+	if dflt {
+		d = "Y/n"
+	}
+	for {
+		answer, err := w.ask(question+" ("+d+")", "")
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			return dflt, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+	}
+}
+
+// choose lists the given options and asks for one of them, either by its number or by its name.
+func (w *wizard) choose(question string, options []string, dflt string) (string, error) {
+	for i, o := range options {
+		fmt.Fprintf(w.out, "%3d. %s\n", i+1, o)
+	}
+	for {
+		answer, err := w.ask(question, dflt)
+		if err != nil {
+			return "", err
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n > 0 && n <= len(options) {
+			return options[n-1], nil
+		}
+		for _, o := range options {
+			if o == answer {
+				return o, nil
+			}
+		}
+		fmt.Fprintf(w.out, "Please enter a number between 1 and %d, or a name from the list\n", len(options))
+	}
+}
+
+// runConnectWizard walks the user through the choice of context, namespace, and traffic-manager, installs the
+// traffic-manager when none is found, connects, verifies the connection, and offers to save the settings as a
+// profile.
+func runConnectWizard(cmd *cobra.Command, request *daemon.Request, args []string) error {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return errcat.User.New("--interactive requires a terminal")
+	}
+	w := &wizard{in: bufio.NewReader(cmd.InOrStdin()), out: cmd.OutOrStdout()}
+	ctx := cmd.Context()
+
+	// Context
+	cfg, err := request.GetConfig(cmd)
+	if err != nil {
+		return err
+	}
+	ctxNames := make([]string, 0, len(cfg.Contexts))
+	for name := range cfg.Contexts {
+		ctxNames = append(ctxNames, name)
+	}
+	if len(ctxNames) == 0 {
+		return errcat.Config.New("the kubeconfig has no contexts")
+	}
+	sort.Strings(ctxNames)
+	ctxName := request.KubeFlags[global.FlagContext]
+	if ctxName == "" {
+		ctxName = cfg.CurrentContext
+	}
+	if ctxName, err = w.choose("Kubernetes context", ctxNames, ctxName); err != nil {
+		return err
+	}
+	request.SetContext(ctxName)
+
+	// Namespace
+	ns := request.KubeFlags["namespace"]
+	if ns == "" {
+		if ns = cfg.Contexts[ctxName].Namespace; ns == "" {
+			ns = "default"
+		}
+	}
+	if nss, err := request.ClusterNamespaces(ctx); err == nil && len(nss) > 0 {
+		sort.Strings(nss)
+		ns, err = w.choose("Namespace", nss, ns)
+	} else {
+		ns, err = w.ask("Namespace", ns)
+	}
+	if err != nil {
+		return err
+	}
+	request.SetNamespace(ns)
+
+	// Traffic-manager
+	install, err := wizardManagerNamespace(ctx, w, request)
+	if err != nil {
+		return err
+	}
+	if install {
+		if err = wizardInstall(cmd, request); err != nil {
+			return err
+		}
+	}
+
+	if err = connect.InitCommand(cmd); err != nil {
+		return err
+	}
+	ctx = cmd.Context()
+	if !request.NoRouting {
+		wizardVerify(ctx, w.out)
+	}
+
+	save, err := w.confirm("Save these settings to a profile?", true)
+	if err != nil {
+		return err
+	}
+	if save {
+		name, err := w.ask("Profile name", ctxName)
+		if err != nil {
+			return err
+		}
+		err = daemon.SaveProfile(ctx, name, &daemon.Profile{
+			Context:          ctxName,
+			Namespace:        ns,
+			ManagerNamespace: request.ManagerNamespace,
+			MappedNamespaces: request.MappedNamespaces,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w.out, "Use \"telepresence connect --profile %s\" to connect with these settings\n", name)
+	}
+	return connect.RunConnect(cmd, args)
+}
+
+// wizardManagerNamespace detects the namespace of the traffic-manager and assigns it to the request. It returns
+// true when no traffic-manager was found, and the user wants one to be installed.
+func wizardManagerNamespace(ctx context.Context, w *wizard, request *daemon.Request) (bool, error) {
+	dflt := request.ManagerNamespace
+	if dflt == "" {
+		if dflt = client.GetConfig(ctx).Cluster().DefaultManagerNamespace; dflt == "" {
+			dflt = "ambassador"
+		}
+	}
+	nss, err := detectManagerNamespaces(ctx, request)
+	if err != nil {
+		// Probably not allowed to list services in all namespaces. Assume that the traffic-manager is installed.
+		fmt.Fprintf(w.out, "Unable to detect the traffic-manager: %v\n", err)
+		request.ManagerNamespace, err = w.ask("Namespace of the traffic-manager", dflt)
+		return false, err
+	}
+	switch len(nss) {
+	case 0:
+		fmt.Fprintln(w.out, "No traffic-manager was found in the cluster")
+		if request.ManagerNamespace, err = w.ask("Namespace to install the traffic-manager in", dflt); err != nil {
+			return false, err
+		}
+		install, err := w.confirm(fmt.Sprintf("Install the traffic-manager in namespace %s?", request.ManagerNamespace), true)
+		if err == nil && !install {
+			err = errcat.User.New("a traffic-manager is required to connect")
+		}
+		return install, err
+	case 1:
+		fmt.Fprintf(w.out, "Found a traffic-manager in namespace %s\n", nss[0])
+		request.ManagerNamespace = nss[0]
+	default:
+		if !slice.Contains(nss, dflt) {
+			dflt = nss[0]
+		}
+		request.ManagerNamespace, err = w.choose("Namespace of the traffic-manager", nss, dflt)
+	}
+	return false, err
+}
+
+// detectManagerNamespaces returns the namespaces that have a traffic-manager in the cluster of the request.
+func detectManagerNamespaces(ctx context.Context, request *daemon.Request) ([]string, error) {
+	rc, err := request.RESTClientGetter().ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	ki, err := kubernetes.NewForConfig(rc)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	nss, err := trafficManagerNamespaces(ctx, ki)
+	sort.Strings(nss)
+	return nss, err
+}
+
+// wizardInstall installs the traffic-manager in the manager namespace of the request.
+func wizardInstall(cmd *cobra.Command, request *daemon.Request) error {
+	// The installation needs the user daemon but no session, so the command's annotations are changed
+	// while it's initialized, and then restored so that the session is established later.
+	as := cmd.Annotations
+	cmd.Annotations = map[string]string{ann.UserDaemon: ann.Required, ann.VersionCheck: ann.Required}
+	err := connect.InitCommand(cmd)
+	cmd.Annotations = as
+	if err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	fmt.Fprintf(cmd.OutOrStdout(), "Installing the traffic-manager in namespace %s...\n", request.ManagerNamespace)
+	resp, err := daemon.GetUserClient(ctx).Helm(ctx, &connector.HelmRequest{
+		Type:           connector.HelmRequest_INSTALL,
+		ConnectRequest: &request.ConnectRequest,
+	})
+	if err == nil {
+		err = errcat.FromResult(resp)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), "Traffic Manager installed successfully")
+	return nil
+}
+
+// wizardVerify resolves and dials the wizardCheckTarget, and reports the outcome.
+func wizardVerify(ctx context.Context, out io.Writer) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, wizardCheckTarget)
+	if err != nil {
+		fmt.Fprintf(out, "DNS lookup of %s: failed: %v\n", wizardCheckTarget, err)
+		return
+	}
+	fmt.Fprintf(out, "DNS lookup of %s: %s\n", wizardCheckTarget, strings.Join(addrs, ", "))
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", net.JoinHostPort(wizardCheckTarget, "443"))
+	if err != nil {
+		fmt.Fprintf(out, "Connection to %s:443: failed: %v\n", wizardCheckTarget, err)
+		return
+	}
+	conn.Close()
+	fmt.Fprintf(out, "Connection to %s:443: OK\n", wizardCheckTarget)
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWizard(t *testing.T) {
+	out := &bytes.Buffer{}
+	w := &wizard{in: bufio.NewReader(strings.NewReader("\n7\nbeta\n2\nmaybe\nno\n\n")), out: out}
+	options := []string{"alpha", "beta", "gamma"}
+
+	a, err := w.choose("Pick", options, "gamma")
+	require.NoError(t, err)
+	assert.Equal(t, "gamma", a, "empty answer gives the default")
+
+	a, err = w.choose("Pick", options, "")
+	require.NoError(t, err)
+	assert.Equal(t, "beta", a, "out of range number is rejected, and a name is accepted")
+	assert.Contains(t, out.String(), "Please enter a number between 1 and 3")
+
+	a, err = w.choose("Pick", options, "")
+	require.NoError(t, err)
+	assert.Equal(t, "beta", a)
+
+	ok, err := w.confirm("Sure?", true)
+	require.NoError(t, err)
+	assert.False(t, ok, "invalid answer is asked again")
+
+	ok, err = w.confirm("Sure?", true)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = w.ask("More?", "")
+	assert.Error(t, err, "no more input")
+}
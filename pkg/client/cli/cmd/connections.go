@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+)
+
+func connections() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "connections",
+		Short: "Manage the cache of known daemon connections",
+		Args:  OnlySubcommands,
+		RunE:  RunSubcommands,
+	}
+	cmd.AddCommand(connectionsPrune())
+	return cmd
+}
+
+func connectionsPrune() *cobra.Command {
+	return &cobra.Command{
+		Use:   "prune",
+		Args:  cobra.NoArgs,
+		Short: "Remove stale, corrupt, or unresponsive entries from the daemon cache",
+		Long: `Remove stale, corrupt, or unresponsive entries from the daemon cache.
+
+Daemons that crash may leave entries in the cache that prevent the --use <match> flag from
+uniquely identifying a running daemon. Such entries are normally removed automatically, but
+this command will also probe each remaining daemon and remove the entries of those that don't
+respond.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := cmd.Context()
+			pruned, err := daemon.PruneInfos(ctx)
+			if err != nil {
+				return err
+			}
+			if output.WantsFormatted(cmd) {
+				if pruned == nil {
+					pruned = []string{}
+				}
+				output.Object(ctx, pruned, false)
+				return nil
+			}
+			out := output.Out(ctx)
+			if len(pruned) == 0 {
+				fmt.Fprintln(out, "No entries were pruned")
+				return nil
+			}
+			for _, name := range pruned {
+				fmt.Fprintf(out, "Pruned %s\n", name)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/intercept"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/filesync"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+type cpCommand struct {
+	container string
+	namespace string
+	recursive bool
+	maxSize   string
+}
+
+func cpCmd() *cobra.Command {
+	cc := &cpCommand{}
+	cmd := &cobra.Command{
+		Use:   "cp <workload>:<remote path> <local path>",
+		Args:  cobra.ExactArgs(2),
+		Short: "Copy files from the volumes of a workload",
+		Long: `Copy files from the volumes of a workload.
+
+The files are copied from one of the workload's pods by its traffic-agent, so no intercept or mount is
+needed. The remote path must be on a volume that is mounted by the container, because the traffic-agent
+has no access to other parts of the container's file system. The base name of the remote path may be a
+pattern (see path.Match), e.g. /etc/config/*.yaml, and several files are then copied into the local
+directory.`,
+		Example: `  telepresence cp echo:/etc/config ./config -r
+  telepresence cp echo:/etc/config/*.yaml .`,
+		Annotations: map[string]string{
+			ann.Session: ann.Required,
+		},
+		RunE:              cc.run,
+		ValidArgsFunction: intercept.CompleteWorkloads(connector.ListRequest_INSTALLED_AGENTS),
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&cc.container, "container", "", "The name of the container. Defaults to the container that mounts the remote path")
+	flags.StringVarP(&cc.namespace, "namespace", "n", "", "The namespace of the workload")
+	flags.BoolVarP(&cc.recursive, "recursive", "r", false, "Copy directories and their content")
+	flags.StringVar(&cc.maxSize, "max-size", "100Mi", `Don't copy anything when the files are larger than this in total, e.g. "1Gi". Use 0 for no limit`)
+	return cmd
+}
+
+func (cc *cpCommand) run(cmd *cobra.Command, args []string) error {
+	workload, remote, ok := strings.Cut(args[0], ":")
+	if !ok || workload == "" {
+		return errcat.User.Newf("%q is not in the form <workload>:<remote path>", args[0])
+	}
+	if !path.IsAbs(remote) {
+		return errcat.User.New("the remote path must be absolute")
+	}
+	q, err := resource.ParseQuantity(cc.maxSize)
+	if err != nil {
+		return errcat.User.Newf("invalid --max-size %q: %w", cc.maxSize, err)
+	}
+	if err = connect.InitCommand(cmd); err != nil {
+		return err
+	}
+	ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	ud := daemon.GetUserClient(ctx)
+	if ud.Remote() {
+		return errcat.User.New("cp is not supported when the daemon runs in a container")
+	}
+	wi, err := findAgentWorkload(ctx, ud, workload, cc.namespace)
+	if err != nil {
+		return err
+	}
+	pattern, err := agentPath(wi, cc.container, remote)
+	if err != nil {
+		return err
+	}
+	for _, ai := range wi.AgentInfos {
+		if ai.SftpPort == 0 {
+			continue
+		}
+		src, err := filesync.DialSFTP(ctx, net.JoinHostPort(ai.PodIp, strconv.Itoa(int(ai.SftpPort))))
+		if err != nil {
+			return errcat.NoDaemonLogs.Newf("unable to connect to the traffic-agent at %s: %v", ai.PodIp, err)
+		}
+		defer src.Close()
+		r, err := filesync.Download(ctx, src, pattern, args[1], cc.recursive, q.Value())
+		if err != nil {
+			var tl *filesync.TooLargeError
+			switch {
+			case ctx.Err() != nil:
+				return nil
+			case errors.As(err, &tl):
+				return errcat.User.Newf("%v. Use --max-size to raise the limit", err)
+			case errors.Is(err, filesync.ErrIsDir):
+				return errcat.User.Newf("%s is a directory. Use --recursive to copy it", remote)
+			case errors.Is(err, os.ErrNotExist):
+				return errcat.User.Newf("%s: no such file or directory", remote)
+			}
+			return err
+		}
+		fmt.Fprintf(output.Info(ctx), "%d file(s), %d bytes, copied from %s\n", r.Files, r.Bytes, ai.PodIp)
+		return nil
+	}
+	return errcat.User.Newf("no traffic-agent of %s.%s accepts file transfers", wi.Name, wi.Namespace)
+}
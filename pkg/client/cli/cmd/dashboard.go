@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/dashboard"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+type dashboardCommand struct {
+	interval time.Duration
+	port     uint16
+}
+
+func dashboardCmd() *cobra.Command {
+	dc := dashboardCommand{}
+	cmd := &cobra.Command{
+		Use:   "dashboard",
+		Args:  cobra.NoArgs,
+		Short: "Show a live view of the connection, workloads, intercepts, and tunnel traffic",
+		Long: `Show a live view of the connection, workloads, intercepts, and tunnel traffic.
+
+The dashboard lists the workloads in the connected namespace and lets you intercept the selected
+workload with "i" and leave its intercept with "l". Intercepts created from the dashboard forward
+to the local port given by --port and don't mount the remote file system. Use the intercept
+command when more options are needed.`,
+		Annotations: map[string]string{
+			ann.Session: ann.Required,
+		},
+		RunE: dc.run,
+	}
+	flags := cmd.Flags()
+	flags.DurationVar(&dc.interval, "interval", 2*time.Second, "How often the dashboard is refreshed")
+	flags.Uint16VarP(&dc.port, "port", "p", 0, "Local port that intercepts forward to (default is intercept.defaultPort from the config)")
+	return cmd
+}
+
+func (dc *dashboardCommand) run(cmd *cobra.Command, _ []string) error {
+	if err := connect.InitCommand(cmd); err != nil {
+		return err
+	}
+	if dc.interval <= 0 {
+		return errcat.User.New("--interval must be a positive duration")
+	}
+	ctx := cmd.Context()
+	if dc.port == 0 {
+		dc.port = uint16(client.GetConfig(ctx).Intercept().DefaultPort)
+	}
+	return dashboard.Run(ctx, dashboard.NewSource(ctx), dc.interval, dc.port)
+}
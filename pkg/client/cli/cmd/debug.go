@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/datawire/dlib/dcontext"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/intercept"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/forwarder"
+)
+
+// debugRolloutTimeout is how long the debug command waits for a pod with the injected debug options.
+const debugRolloutTimeout = 2 * time.Minute
+
+type debugCommand struct {
+	port      uint16
+	localPort uint16
+	inject    string
+	namespace string
+}
+
+func debugCmd() *cobra.Command {
+	dc := &debugCommand{}
+	cmd := &cobra.Command{
+		Use:   "debug <workload> --port <port>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Forward a local port to the debug port of a workload's pod",
+		Long: `Forward a local port to the debug port of a workload's pod, so that a debugger can attach to it.
+
+The workload must have a traffic-agent. The forward lasts until the command is interrupted.
+
+Use --inject to make the traffic-agent injector start the debug server of the app containers, which
+rolls out the workload. The options are removed, and the workload rolled out again, when the command
+exits. The "jvm" runtime uses JAVA_TOOL_OPTIONS and the "node" runtime uses NODE_OPTIONS. Delve can't
+be started using the environment, so the "delve" runtime sets ` + agentconfig.EnvDebugListen + `, and the
+container must start the program using:
+
+  dlv exec --headless --accept-multiclient --continue --listen=$` + agentconfig.EnvDebugListen + ` <program>`,
+		Annotations: map[string]string{
+			ann.Session: ann.Required,
+		},
+		RunE:              dc.run,
+		ValidArgsFunction: intercept.CompleteWorkloads(connector.ListRequest_INSTALLED_AGENTS),
+	}
+	flags := cmd.Flags()
+	flags.Uint16Var(&dc.port, "port", 0, "The debug port of the container")
+	flags.Uint16Var(&dc.localPort, "local-port", 0, "The local port that the debugger attaches to. Defaults to --port")
+	flags.StringVar(&dc.inject, "inject", "",
+		fmt.Sprintf("Start the debug server of the given runtime (one of %s)", strings.Join(agentconfig.DebugRuntimes, ", ")))
+	flags.StringVarP(&dc.namespace, "namespace", "n", "", "The namespace of the workload")
+	_ = cmd.RegisterFlagCompletionFunc("inject", func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		return agentconfig.DebugRuntimes, cobra.ShellCompDirectiveNoFileComp
+	})
+	return cmd
+}
+
+func (dc *debugCommand) run(cmd *cobra.Command, args []string) error {
+	if dc.port == 0 {
+		return errcat.User.New("--port is required")
+	}
+	if dc.inject != "" {
+		if _, _, err := agentconfig.ParseDebugAnnotation(agentconfig.DebugAnnotationValue(dc.inject, dc.port)); err != nil {
+			return errcat.User.Newf("invalid --inject %q. Valid values are %s", dc.inject, strings.Join(agentconfig.DebugRuntimes, ", "))
+		}
+	}
+	if dc.localPort == 0 {
+		dc.localPort = dc.port
+	}
+	if err := connect.InitCommand(cmd); err != nil {
+		return err
+	}
+	ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	ud := daemon.GetUserClient(ctx)
+	if ud.Remote() {
+		return errcat.User.New("debug is not supported when the daemon runs in a container")
+	}
+	wi, err := findAgentWorkload(ctx, ud, args[0], dc.namespace)
+	if err != nil {
+		return err
+	}
+
+	var podIP string
+	if dc.inject != "" {
+		restore, err := dc.injectDebug(ctx, ud, wi)
+		if err != nil {
+			return err
+		}
+		if restore != nil {
+			defer restore()
+			if podIP, err = dc.waitForNewAgent(ctx, ud, wi); err != nil || podIP == "" {
+				return err
+			}
+		}
+		if dc.inject == agentconfig.DebugDelve {
+			fmt.Fprintf(output.Info(ctx), "The container must start the program using dlv exec --headless --listen=$%s\n", agentconfig.EnvDebugListen)
+		}
+	}
+	if podIP == "" {
+		for _, ai := range wi.AgentInfos {
+			if ai.PodIp != "" {
+				podIP = ai.PodIp
+				break
+			}
+		}
+		if podIP == "" {
+			return errcat.User.Newf("no pod of %s.%s has a traffic-agent", wi.Name, wi.Namespace)
+		}
+	}
+
+	fwd := forwarder.NewInterceptor(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: int(dc.localPort)}, podIP, dc.port)
+	initCh := make(chan net.Addr)
+	go func() {
+		if addr, ok := <-initCh; ok {
+			fmt.Fprintf(output.Info(ctx), "Forwarding %s to %s:%d, attach the debugger to %s. Press Ctrl-C to stop\n",
+				addr, podIP, dc.port, addr)
+		}
+	}()
+	return fwd.Serve(ctx, initCh)
+}
+
+// injectDebug adds the agentconfig.DebugAnnotation to the pod template of the workload, which makes the workload
+// roll out. It returns a function that restores the annotation, or nil if the annotation already had the
+// desired value.
+func (dc *debugCommand) injectDebug(ctx context.Context, ud *daemon.UserClient, wi *connector.WorkloadInfo) (func(), error) {
+	ci, err := ud.Status(ctx, &empty.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	kc, err := client.NewKubeconfig(ctx, map[string]string{"context": ci.ClusterContext}, "")
+	if err != nil {
+		return nil, err
+	}
+	ki, err := kubernetes.NewForConfig(kc.RestConfig)
+	if err != nil {
+		return nil, err
+	}
+	ctx = k8sapi.WithK8sInterface(ctx, ki)
+	wl, err := k8sapi.GetWorkload(ctx, wi.Name, wi.Namespace, wi.WorkloadResourceType)
+	if err != nil {
+		return nil, err
+	}
+	value := agentconfig.DebugAnnotationValue(dc.inject, dc.port)
+	old, hadOld := wl.GetPodTemplate().Annotations[agentconfig.DebugAnnotation]
+	if hadOld && old == value {
+		return nil, nil
+	}
+	if err = patchDebugAnnotation(ctx, wl, &value); err != nil {
+		return nil, err
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(dcontext.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		var v *string
+		if hadOld {
+			v = &old
+		}
+		if err := patchDebugAnnotation(ctx, wl, v); err != nil {
+			fmt.Fprintf(output.Err(ctx), "Unable to remove the debug options from %s.%s: %v\n", wi.Name, wi.Namespace, err)
+		} else {
+			fmt.Fprintf(output.Info(ctx), "Removed the debug options from %s.%s\n", wi.Name, wi.Namespace)
+		}
+	}, nil
+}
+
+// patchDebugAnnotation sets the agentconfig.DebugAnnotation of the workload's pod template to the given
+// value, or removes it when the value is nil.
+func patchDebugAnnotation(ctx context.Context, wl k8sapi.Workload, value *string) error {
+	patch, err := json.Marshal(map[string]any{
+		"spec": map[string]any{
+			"template": map[string]any{
+				"metadata": map[string]any{
+					"annotations": map[string]any{agentconfig.DebugAnnotation: value},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return wl.Patch(ctx, types.StrategicMergePatchType, patch)
+}
+
+// waitForNewAgent waits until a traffic-agent arrives from a pod that didn't exist when the workload info was
+// retrieved, and returns the IP of that pod. An empty string is returned if the context is cancelled.
+func (dc *debugCommand) waitForNewAgent(ctx context.Context, ud *daemon.UserClient, wi *connector.WorkloadInfo) (string, error) {
+	oldIPs := make(map[string]struct{}, len(wi.AgentInfos))
+	for _, ai := range wi.AgentInfos {
+		oldIPs[ai.PodIp] = struct{}{}
+	}
+	fmt.Fprintf(output.Info(ctx), "Waiting for %s.%s to roll out with the debug options\n", wi.Name, wi.Namespace)
+	tCtx, cancel := context.WithTimeout(ctx, debugRolloutTimeout)
+	defer cancel()
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-tCtx.Done():
+			if ctx.Err() != nil {
+				return "", nil
+			}
+			return "", errcat.User.Newf("timeout while waiting for %s.%s to roll out with the debug options", wi.Name, wi.Namespace)
+		case <-ticker.C:
+		}
+		nwi, err := findAgentWorkload(tCtx, ud, wi.Name, wi.Namespace)
+		if err != nil {
+			if tCtx.Err() != nil {
+				continue
+			}
+			return "", err
+		}
+		for _, ai := range nwi.AgentInfos {
+			if _, ok := oldIPs[ai.PodIp]; !ok && ai.PodIp != "" {
+				return ai.PodIp, nil
+			}
+		}
+	}
+}
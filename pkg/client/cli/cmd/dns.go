@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	daemonRpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/ioutil"
+)
+
+func dnsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dns",
+		Short: "Inspect the local DNS resolver",
+		Args:  OnlySubcommands,
+		RunE:  RunSubcommands,
+	}
+	cmd.AddCommand(dnsInfo())
+	return cmd
+}
+
+type recursionCheckInfo struct {
+	Skip     bool          `json:"skip" yaml:"skip"`
+	Target   string        `json:"target" yaml:"target"`
+	Timeout  time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Outcome  string        `json:"outcome" yaml:"outcome"`
+	Attempts int32         `json:"attempts" yaml:"attempts"`
+	Duration time.Duration `json:"duration" yaml:"duration"`
+	Error    string        `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+type dnsInfoResult struct {
+	Error          string             `json:"error,omitempty" yaml:"error,omitempty"`
+	Resolvers      []string           `json:"resolvers" yaml:"resolvers"`
+	RecursionCheck recursionCheckInfo `json:"recursion_check" yaml:"recursion_check"`
+}
+
+func dnsInfo() *cobra.Command {
+	return &cobra.Command{
+		Use:   "info",
+		Args:  cobra.NoArgs,
+		Short: "Show the backends and the outcome of the probes of the DNS resolver",
+		Long: `Show the backends and the outcome of the probes of the DNS resolver.
+
+Names are resolved using the backends in the dns.resolvers entry of the telepresence extension in
+the kubeconfig, or using the cluster when there is no such entry.
+
+The resolver probes if the cluster's DNS resolver calls it recursively. The probe is configured
+using the dns.recursion-check entry of the telepresence extension in the kubeconfig:
+
+  recursion-check:
+    skip: true                            # don't probe, assume that there is no recursion
+    target: tel2-recursion-check.default  # probe this name instead
+    timeout: 2s                           # wait this long for each attempt`,
+		Annotations: map[string]string{
+			ann.Session: ann.Required,
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := connect.InitCommand(cmd); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			status, err := daemon.GetUserClient(ctx).Status(ctx, &empty.Empty{})
+			if err != nil {
+				return err
+			}
+			ds := status.DaemonStatus
+			if ds == nil || ds.OutboundConfig == nil || ds.OutboundConfig.Dns == nil {
+				return errcat.User.New("the root daemon reports no DNS configuration")
+			}
+			info := newDNSInfoResult(ds.OutboundConfig.Dns)
+			if output.WantsFormatted(cmd) {
+				output.Object(ctx, info, false)
+				return nil
+			}
+			kvf := ioutil.DefaultKeyValueFormatter()
+			if info.Error != "" {
+				kvf.Add("Error", info.Error)
+			}
+			rc := &info.RecursionCheck
+			kvf.Add("Resolvers", strings.Join(info.Resolvers, ", "))
+			kvf.Add("Recursion check", rc.Outcome)
+			kvf.Add("Target", rc.Target)
+			if !rc.Skip {
+				if rc.Timeout > 0 {
+					kvf.Add("Timeout", rc.Timeout.String())
+				}
+				kvf.Add("Attempts", fmt.Sprintf("%d", rc.Attempts))
+				kvf.Add("Duration", rc.Duration.String())
+			}
+			if rc.Error != "" {
+				kvf.Add("Last error", rc.Error)
+			}
+			kvf.Println(cmd.OutOrStdout())
+			return nil
+		},
+	}
+}
+
+func newDNSInfoResult(dns *daemonRpc.DNSConfig) *dnsInfoResult {
+	info := &dnsInfoResult{
+		Error: dns.Error,
+		RecursionCheck: recursionCheckInfo{
+			Skip:    dns.SkipRecursionCheck,
+			Target:  dns.RecursionCheckTarget,
+			Timeout: dns.RecursionCheckTimeout.AsDuration(),
+			Outcome: strings.ToLower(daemonRpc.RecursionCheckResult_PENDING.String()),
+		},
+	}
+	for _, r := range dns.Resolvers {
+		desc := strings.ReplaceAll(strings.ToLower(r.Type.String()), "_", "-")
+		switch r.Type {
+		case daemonRpc.DNSResolver_HOSTS_FILE:
+			desc += " " + r.HostsFile
+		case daemonRpc.DNSResolver_PLUGIN:
+			desc += " " + r.Socket
+		}
+		info.Resolvers = append(info.Resolvers, desc)
+	}
+	if len(info.Resolvers) == 0 {
+		info.Resolvers = []string{"cluster"}
+	}
+	if r := dns.RecursionCheckResult; r != nil {
+		rc := &info.RecursionCheck
+		rc.Outcome = strings.ToLower(r.Outcome.String())
+		rc.Target = strings.TrimSuffix(r.Target, ".")
+		rc.Attempts = r.Attempts
+		rc.Duration = r.Duration.AsDuration()
+		rc.Error = r.Error
+	}
+	return info
+}
@@ -0,0 +1,293 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/docker"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/rootd"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/socket"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+	"github.com/telepresenceio/telepresence/v2/pkg/proc"
+)
+
+// doctorCheck is a check that the doctor command performs on each pod that has a traffic-agent.
+type doctorCheck struct {
+	// name is a short name of the check, shown along with each problem that it finds.
+	name string
+
+	// check returns a description of each problem found in the given pod. The config is nil when the
+	// agent configuration of the pod couldn't be found.
+	check func(pod *core.Pod, config *agentconfig.Sidecar) []string
+
+	// hint describes how the problems found by the check can be remedied.
+	hint string
+}
+
+//nolint:gochecknoglobals // extension point
+var doctorChecks = []doctorCheck{
+	{
+		name:  "service mesh",
+		check: agentconfig.ServiceMeshProblems,
+		hint: fmt.Sprintf("Set the Helm value agentInjector.serviceMesh, or the workload annotation %s, to auto, istio, "+
+			"or linkerd and restart the workload so that the traffic-agent is injected in service mesh compatibility mode.",
+			agentconfig.ServiceMeshAnnotation),
+	},
+}
+
+// doctorProblem is a problem found by the doctor command.
+type doctorProblem struct {
+	Pod     string `json:"pod"`
+	Check   string `json:"check"`
+	Problem string `json:"problem"`
+	Hint    string `json:"hint"`
+}
+
+func doctor() *cobra.Command {
+	kubeConfig := genericclioptions.NewConfigFlags(false)
+	var repair, dockerEngine bool
+	cmd := &cobra.Command{
+		Use:  "doctor",
+		Args: cobra.NoArgs,
+
+		Short: "Check the pods of a namespace for problems that prevent intercepts from working",
+		Long: `Check the pods of a namespace for problems that prevent intercepts from working.
+
+Each pod that has a traffic-agent is checked for misconfigurations, such as a service mesh that
+captures the traffic-agent's traffic before it reaches the traffic-agent. The command exits with
+status 1 when problems are found.
+
+Use --repair to revert the changes to the network configuration that a root daemon that didn't
+terminate gracefully left behind, such as routes, DNS resolver files, firewall rules, and TUN
+devices. The root daemon records each such change in a journal, and the changes that remain in the
+journal are also reverted when the root daemon starts.
+
+Use --docker-engine to check the docker engine used by "telepresence connect --docker" instead. The
+check reports the VM that the engine runs in, such as Docker Desktop, Colima, Rancher Desktop, or
+WSL2, and problems caused by it, such as directories that the VM doesn't share with the engine.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if repair {
+				return repairLeftovers(cmd)
+			}
+			if dockerEngine {
+				return checkDockerEngine(cmd)
+			}
+			restConfig, err := kubeConfig.ToRESTConfig()
+			if err != nil {
+				return err
+			}
+			ns, _, err := kubeConfig.ToRawKubeConfigLoader().Namespace()
+			if err != nil {
+				return err
+			}
+			ki, err := kubernetes.NewForConfig(restConfig)
+			if err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			problems, err := runDoctorChecks(ctx, ki, ns)
+			if err != nil {
+				return err
+			}
+			if output.WantsFormatted(cmd) {
+				if problems == nil {
+					problems = []doctorProblem{}
+				}
+				output.Object(ctx, problems, false)
+			} else {
+				out := output.Out(ctx)
+				if len(problems) == 0 {
+					fmt.Fprintf(out, "No problems found in namespace %s\n", ns)
+					return nil
+				}
+				hints := make(map[string]struct{})
+				for _, p := range problems {
+					fmt.Fprintf(out, "%s: %s: %s\n", p.Pod, p.Check, p.Problem)
+				}
+				for _, p := range problems {
+					if _, ok := hints[p.Hint]; !ok {
+						hints[p.Hint] = struct{}{}
+						fmt.Fprintf(out, "\n%s\n", p.Hint)
+					}
+				}
+			}
+			if len(problems) > 0 {
+				return errcat.ExitCode(1)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&repair, "repair", false,
+		"Revert the network changes left behind by a root daemon that crashed instead of checking the pods")
+	cmd.Flags().BoolVar(&repair, "fix-firewall", false, "")
+	_ = cmd.Flags().MarkDeprecated("fix-firewall", "use --repair")
+	cmd.Flags().BoolVar(&dockerEngine, "docker-engine", false,
+		"Check the docker engine used by docker mode instead of checking the pods")
+	kubeFlags := pflag.NewFlagSet("Kubernetes flags", 0)
+	kubeConfig.AddFlags(kubeFlags)
+	cmd.Flags().AddFlagSet(kubeFlags)
+	return cmd
+}
+
+// repairLeftovers reverts the changes that a root daemon left behind. The changes of a running root
+// daemon are in use, so the command refuses to run while one is running.
+func repairLeftovers(cmd *cobra.Command) error {
+	ctx := cmd.Context()
+	if running, _ := socket.IsRunning(ctx, socket.RootDaemonPath(ctx)); running {
+		return errcat.User.New("the root daemon is running. Run \"telepresence quit -s\" first")
+	}
+	if !proc.IsAdmin() {
+		return errcat.User.New("reverting network changes requires administrator privileges. Run the command again using sudo")
+	}
+	reverted, err := rootd.RepairLeftovers(ctx)
+	if output.WantsFormatted(cmd) {
+		if reverted == nil {
+			reverted = []string{}
+		}
+		output.Object(ctx, reverted, false)
+		return err
+	}
+	out := output.Out(ctx)
+	if len(reverted) == 0 && err == nil {
+		fmt.Fprintln(out, "No network changes left behind by the root daemon were found")
+	}
+	for _, r := range reverted {
+		fmt.Fprintf(out, "Reverted %s\n", r)
+	}
+	return err
+}
+
+// dockerEngineReport is the result of checking the docker engine.
+type dockerEngineReport struct {
+	VM          string   `json:"vm"`
+	Host        string   `json:"host"`
+	OS          string   `json:"os"`
+	Kernel      string   `json:"kernel"`
+	HostGateway string   `json:"hostGateway"`
+	Problems    []string `json:"problems"`
+}
+
+// checkDockerEngine reports the docker engine that the daemon container would be started in and the
+// problems that the VM of that engine would cause.
+func checkDockerEngine(cmd *cobra.Command) error {
+	ctx := cmd.Context()
+	engine, err := docker.GetEngine(docker.EnableClient(ctx))
+	if err != nil {
+		return errcat.User.Newf("unable to reach the docker engine: %w", err)
+	}
+	r := dockerEngineReport{
+		VM:          string(engine.VM),
+		Host:        engine.Host,
+		OS:          engine.OperatingSystem,
+		Kernel:      engine.KernelVersion,
+		HostGateway: engine.HostGateway(),
+		Problems:    []string{},
+	}
+	if r.VM == "" {
+		r.VM = "none"
+	}
+	for _, dir := range []string{filelocation.AppUserConfigDir(ctx), filelocation.AppUserCacheDir(ctx), filelocation.AppUserLogDir(ctx)} {
+		if p := engine.MountProblem(dir); p != "" {
+			r.Problems = append(r.Problems, p)
+		}
+	}
+	if output.WantsFormatted(cmd) {
+		output.Object(ctx, &r, false)
+	} else {
+		out := output.Out(ctx)
+		fmt.Fprintf(out, "Docker engine: %s\n", engine)
+		if r.HostGateway != "" {
+			fmt.Fprintf(out, "host.docker.internal: %s\n", r.HostGateway)
+		} else {
+			fmt.Fprintln(out, "host.docker.internal: provided by the engine")
+		}
+		if len(r.Problems) == 0 {
+			fmt.Fprintln(out, "No problems found")
+		}
+		for _, p := range r.Problems {
+			fmt.Fprintf(out, "\n%s\n", p)
+		}
+	}
+	if len(r.Problems) > 0 {
+		return errcat.ExitCode(1)
+	}
+	return nil
+}
+
+// runDoctorChecks runs all doctorChecks on each pod in the given namespace that has a traffic-agent.
+func runDoctorChecks(ctx context.Context, ki kubernetes.Interface, ns string) ([]doctorProblem, error) {
+	pl, err := ki.CoreV1().Pods(ns).List(ctx, meta.ListOptions{})
+	if err != nil {
+		return nil, errcat.User.Newf("unable to list pods in namespace %s: %w", ns, err)
+	}
+	var cm *core.ConfigMap
+	if cm, err = ki.CoreV1().ConfigMaps(ns).Get(ctx, agentconfig.ConfigMap, meta.GetOptions{}); err != nil {
+		dlog.Debugf(ctx, "unable to get configmap %s.%s: %v", agentconfig.ConfigMap, ns, err)
+		cm = nil
+	}
+	var problems []doctorProblem
+	for i := range pl.Items {
+		pod := &pl.Items[i]
+		if !hasAgentContainer(pod) {
+			continue
+		}
+		config := podAgentConfig(ctx, pod, cm)
+		for _, dc := range doctorChecks {
+			for _, p := range dc.check(pod, config) {
+				problems = append(problems, doctorProblem{
+					Pod:     pod.Name + "." + pod.Namespace,
+					Check:   dc.name,
+					Problem: p,
+					Hint:    dc.hint,
+				})
+			}
+		}
+	}
+	return problems, nil
+}
+
+func hasAgentContainer(pod *core.Pod) bool {
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == agentconfig.ContainerName {
+			return true
+		}
+	}
+	return false
+}
+
+// podAgentConfig returns the agent configuration that the traffic-agent of the given pod reads from
+// the given configmap, or nil if it can't be found.
+func podAgentConfig(ctx context.Context, pod *core.Pod, cm *core.ConfigMap) *agentconfig.Sidecar {
+	if cm == nil {
+		return nil
+	}
+	for _, v := range pod.Spec.Volumes {
+		if v.Name != agentconfig.ConfigVolumeName || v.ConfigMap == nil {
+			continue
+		}
+		for _, item := range v.ConfigMap.Items {
+			data, ok := cm.Data[item.Key]
+			if !ok {
+				continue
+			}
+			scx, err := agentconfig.UnmarshalYAML([]byte(data))
+			if err != nil {
+				dlog.Debugf(ctx, "unable to parse agent config %s: %v", item.Key, err)
+				return nil
+			}
+			return scx.AgentConfig()
+		}
+	}
+	return nil
+}
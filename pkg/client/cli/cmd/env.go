@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/intercept"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/slice"
+)
+
+// envDiffIgnored are variables that always differ between a container and a workstation, and that are therefore
+// not compared unless --all is used.
+var envDiffIgnored = []string{"HOME", "HOSTNAME", "OLDPWD", "PATH", "PWD", "SHLVL", "TERM", "_"} //nolint:gochecknoglobals // constant
+
+func envCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "env",
+		Short: "Inspect the environment of workloads",
+		Args:  OnlySubcommands,
+		RunE:  RunSubcommands,
+	}
+	cmd.AddCommand(envDiff())
+	return cmd
+}
+
+type envDiffCommand struct {
+	container string
+	namespace string
+	envFile   string
+	ignore    []string
+	all       bool
+}
+
+// envDifference is a variable that is missing locally, or that has a different value locally.
+type envDifference struct {
+	Name   string  `json:"name"             yaml:"name"`
+	Remote *string `json:"remote,omitempty" yaml:"remote,omitempty"`
+	Local  *string `json:"local,omitempty"  yaml:"local,omitempty"`
+}
+
+func (d *envDifference) kind() string {
+	switch {
+	case d.Local == nil:
+		return "missing"
+	case d.Remote == nil:
+		return "local only"
+	default:
+		return "conflict"
+	}
+}
+
+func envDiff() *cobra.Command {
+	ec := &envDiffCommand{}
+	cmd := &cobra.Command{
+		Use:   "diff <workload>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Compare the environment of a workload's container to the local environment",
+		Long: `Compare the environment of a workload's container to the local environment.
+
+The environment of the container is obtained by running "env" in it, in the same way as "telepresence ssh"
+does, so the workload must have a traffic-agent. It's compared to the environment of this shell, or to the
+given env file, e.g. the one that a Docker Compose service uses. Variables that are missing locally, and
+variables with conflicting values, are listed. Variables that only exist locally are listed when --all is used.`,
+		Annotations: map[string]string{
+			ann.Session: ann.Required,
+		},
+		RunE:              ec.run,
+		ValidArgsFunction: intercept.CompleteWorkloads(connector.ListRequest_INSTALLED_AGENTS),
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&ec.container, "container", "", "The name of the container. Defaults to the first container that isn't the traffic-agent")
+	flags.StringVarP(&ec.namespace, "namespace", "n", "", "The namespace of the workload")
+	flags.StringVarP(&ec.envFile, "env-file", "e", "", "Compare to the variables of this env file instead of the environment of this shell")
+	flags.StringSliceVar(&ec.ignore, "ignore", nil, "Comma separated list of additional variables to ignore")
+	flags.BoolVar(&ec.all, "all", false, "Also list variables that only exist locally, and compare "+strings.Join(envDiffIgnored, ", "))
+	return cmd
+}
+
+func (ec *envDiffCommand) run(cmd *cobra.Command, args []string) error {
+	var local map[string]string
+	if ec.envFile != "" {
+		f, err := os.Open(ec.envFile)
+		if err != nil {
+			return errcat.User.New(err)
+		}
+		local, err = parseEnv(f)
+		f.Close()
+		if err != nil {
+			return errcat.User.Newf("failed to parse %s: %w", ec.envFile, err)
+		}
+	} else {
+		local = envMap(os.Environ())
+	}
+
+	if err := connect.InitCommand(cmd); err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	remote, err := ec.remoteEnv(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	diffs := ec.diff(remote, local)
+
+	if output.WantsFormatted(cmd) {
+		output.Object(ctx, diffs, false)
+		return nil
+	}
+	out := cmd.OutOrStdout()
+	if len(diffs) == 0 {
+		fmt.Fprintln(out, "No differences")
+		return nil
+	}
+	for _, d := range diffs {
+		switch d.kind() {
+		case "missing":
+			fmt.Fprintf(out, "- %s=%s (missing locally)\n", d.Name, *d.Remote)
+		case "local only":
+			fmt.Fprintf(out, "+ %s=%s (local only)\n", d.Name, *d.Local)
+		default:
+			fmt.Fprintf(out, "~ %s: remote %q, local %q\n", d.Name, *d.Remote, *d.Local)
+		}
+	}
+	return nil
+}
+
+// remoteEnv runs "env" in the container of the given workload and returns the result.
+func (ec *envDiffCommand) remoteEnv(ctx context.Context, name string) (map[string]string, error) {
+	ud := daemon.GetUserClient(ctx)
+	wi, err := findAgentWorkload(ctx, ud, name, ec.namespace)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := ud.Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = stream.Send(&manager.ExecRequest{
+		Name:      wi.Name,
+		Namespace: wi.Namespace,
+		Container: ec.container,
+		Command:   []string{"env"},
+	})
+	if err == nil {
+		err = stream.Send(&manager.ExecRequest{StdinClosed: true})
+	}
+	if err != nil {
+		return nil, execError(ctx, err)
+	}
+	var stdout, stderr bytes.Buffer
+	for {
+		rs, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, errors.New("the connection was closed before env ended")
+			}
+			return nil, execError(ctx, err)
+		}
+		stdout.Write(rs.Stdout)
+		stderr.Write(rs.Stderr)
+		if rs.Done {
+			if rs.ExitCode != 0 {
+				return nil, errcat.User.Newf("env exited with code %d: %s", rs.ExitCode, strings.TrimSpace(stderr.String()))
+			}
+			return parseEnv(&stdout)
+		}
+	}
+}
+
+// diff returns the differences between the given remote and local environments, sorted by name.
+func (ec *envDiffCommand) diff(remote, local map[string]string) []*envDifference {
+	ignored := func(k string) bool {
+		return slice.Contains(ec.ignore, k) || !ec.all && slice.Contains(envDiffIgnored, k)
+	}
+	var diffs []*envDifference
+	for k, rv := range remote {
+		if ignored(k) {
+			continue
+		}
+		rv := rv
+		if lv, ok := local[k]; !ok {
+			diffs = append(diffs, &envDifference{Name: k, Remote: &rv})
+		} else if lv != rv {
+			diffs = append(diffs, &envDifference{Name: k, Remote: &rv, Local: &lv})
+		}
+	}
+	if ec.all {
+		for k, lv := range local {
+			if _, ok := remote[k]; !ok && !ignored(k) {
+				lv := lv
+				diffs = append(diffs, &envDifference{Name: k, Local: &lv})
+			}
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Name < diffs[j].Name })
+	return diffs
+}
+
+// parseEnv parses the output of "env", or an env file. Blank lines and comments are skipped, and other lines
+// that aren't assignments continue the value of the previous variable. Values in env files may be quoted.
+func parseEnv(r io.Reader) (map[string]string, error) {
+	env := make(map[string]string)
+	prev := ""
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if s := strings.TrimSpace(line); s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok || k == "" || strings.ContainsAny(k, " \t") {
+			if prev == "" {
+				return nil, fmt.Errorf("invalid line %q", line)
+			}
+			env[prev] += "\n" + line
+			continue
+		}
+		if l := len(v); l >= 2 && (v[0] == '"' || v[0] == '\'') && v[l-1] == v[0] {
+			v = v[1 : l-1]
+		}
+		env[k] = v
+		prev = k
+	}
+	return env, sc.Err()
+}
+
+func envMap(environ []string) map[string]string {
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	return env
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEnv(t *testing.T) {
+	env, err := parseEnv(strings.NewReader(`# comment
+DB_HOST=db.example.com
+
+export DB_USER="app"
+CERT=-----BEGIN-----
+abc
+-----END-----
+EMPTY=
+`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"DB_HOST": "db.example.com",
+		"DB_USER": "app",
+		"CERT":    "-----BEGIN-----\nabc\n-----END-----",
+		"EMPTY":   "",
+	}, env)
+
+	_, err = parseEnv(strings.NewReader("not an assignment\n"))
+	assert.Error(t, err)
+}
+
+func TestEnvDiff(t *testing.T) {
+	remote := map[string]string{"DB_HOST": "db", "DB_PORT": "5432", "HOSTNAME": "pod-1", "SECRET": "x"}
+	local := map[string]string{"DB_HOST": "localhost", "HOSTNAME": "laptop", "EDITOR": "vi"}
+
+	ec := &envDiffCommand{ignore: []string{"SECRET"}}
+	var names, kinds []string
+	for _, d := range ec.diff(remote, local) {
+		names = append(names, d.Name)
+		kinds = append(kinds, d.kind())
+	}
+	assert.Equal(t, []string{"DB_HOST", "DB_PORT"}, names)
+	assert.Equal(t, []string{"conflict", "missing"}, kinds)
+
+	ec = &envDiffCommand{all: true}
+	names, kinds = nil, nil
+	for _, d := range ec.diff(remote, local) {
+		names = append(names, d.Name)
+		kinds = append(kinds, d.kind())
+	}
+	assert.Equal(t, []string{"DB_HOST", "DB_PORT", "EDITOR", "HOSTNAME", "SECRET"}, names)
+	assert.Equal(t, []string{"conflict", "missing", "local only", "conflict", "missing"}, kinds)
+}
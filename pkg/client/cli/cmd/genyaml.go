@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/pmezard/go-difflib/difflib"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	apps "k8s.io/api/apps/v1"
@@ -19,6 +20,7 @@ import (
 	"sigs.k8s.io/yaml"
 
 	"github.com/datawire/k8sapi/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/v2/cmd/traffic/cmd/manager/mutator"
 	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
 	"github.com/telepresenceio/telepresence/v2/pkg/agentmap"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
@@ -49,9 +51,10 @@ volume into the workload, and a corresponding configmap entry into the "telelepr
 configmap; you can do this by running "genyaml config", "genyaml container", and "genyaml volume".
 
 NOTE: It is recommended that you not do this unless strictly necessary. Instead, we suggest letting
-telepresence's webhook injector configure the traffic agents on demand.`,
+telepresence's webhook injector configure the traffic agents on demand. Use "genyaml simulate" to see
+how the webhook injector would modify the pods of a workload.`,
 		RunE: func(_ *cobra.Command, _ []string) error {
-			return errcat.User.New("please run genyaml as \"genyaml config\", \"genyaml container\", \"genyaml initcontainer\", or \"genyaml volume\"")
+			return errcat.User.New("please run genyaml as \"genyaml config\", \"genyaml container\", \"genyaml initcontainer\", \"genyaml simulate\", or \"genyaml volume\"")
 		},
 	}
 	flags := cmd.PersistentFlags()
@@ -61,6 +64,7 @@ telepresence's webhook injector configure the traffic agents on demand.`,
 		genConfigMapSubCommand(&info),
 		genContainerSubCommand(&info),
 		genInitContainerSubCommand(&info),
+		genSimulateSubCommand(&info),
 		genVolumeSubCommand(&info),
 	)
 	return cmd
@@ -174,13 +178,16 @@ func (i *genYAMLCommand) writeObjToOutput(obj any) error {
 	if err != nil {
 		return errcat.User.Newf("unable to marshal agent container: %w", err)
 	}
+	return i.writeToOutput(doc)
+}
+
+func (i *genYAMLCommand) writeToOutput(doc []byte) error {
 	w, err := i.getOutputWriter()
 	if err != nil {
 		return err
 	}
 	defer w.Close()
-	_, err = w.Write(doc)
-	if err != nil {
+	if _, err = w.Write(doc); err != nil {
 		return errcat.User.Newf("unable to write to output %s: %w", i.outputFile, err)
 	}
 	return nil
@@ -235,6 +242,7 @@ func (i *genYAMLCommand) withK8sInterface(ctx context.Context, flagMap map[strin
 type genConfigMap struct {
 	agentmap.BasicGeneratorConfig
 	*genYAMLCommand
+	compliance string
 }
 
 func allKubeFlags() *pflag.FlagSet {
@@ -272,11 +280,17 @@ func genConfigMapSubCommand(yamlInfo *genYAMLCommand) *cobra.Command {
 		`The traffic-manager namespace`)
 	flags.StringVar(&info.LogLevel, "loglevel", "info",
 		`The loglevel for the generated traffic-agent sidecar`)
+	flags.StringVar(&info.compliance, "compliance", agentconfig.ComplianceDefault.String(),
+		`The security compliance mode of the generated traffic-agent sidecar; one of "default" or "restricted"`)
 	flags.AddFlagSet(kubeFlags)
 	return cmd
 }
 
 func (i *genConfigMap) generateConfigMap(ctx context.Context, wl k8sapi.Workload) (*agentconfig.Sidecar, error) {
+	var err error
+	if i.Compliance, err = agentconfig.NewComplianceMode(i.compliance); err != nil {
+		return nil, errcat.User.New(err)
+	}
 	ac, err := i.BasicGeneratorConfig.Generate(ctx, wl)
 	if err != nil {
 		return nil, errcat.NoDaemonLogs.New(err)
@@ -487,3 +501,124 @@ func (g *genVolumeInfo) run(cmd *cobra.Command, kubeFlags map[string]string) err
 
 	return g.writeObjToOutput(&volumes)
 }
+
+type genSimulateInfo struct {
+	genConfigMap
+	serviceMesh string
+}
+
+func genSimulateSubCommand(yamlInfo *genYAMLCommand) *cobra.Command {
+	kubeFlags := allKubeFlags()
+	info := genSimulateInfo{genConfigMap: genConfigMap{genYAMLCommand: yamlInfo}}
+	cmd := &cobra.Command{
+		Use:   "simulate [flags] <workload>",
+		Args:  cobra.MaximumNArgs(1),
+		Short: "Show how the agent injector would modify the pods of a workload.",
+		Long: `Show how the agent injector would modify the pods of a workload.
+The same mutations that the traffic-manager's webhook applies when a pod is admitted are applied locally to the
+workload's pod template, and the difference between the original and the modified pod is printed as a unified
+diff. Nothing in the cluster is modified.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 0 {
+				info.workloadName = args[0]
+			}
+			return info.run(cmd, flags.Map(kubeFlags))
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVarP(&info.inputFile, "input", "i", "",
+		"Optional path to the yaml containing the workload definition (i.e. Deployment, StatefulSet, etc). Pass '-' for stdin. Loaded from cluster by default")
+	flags.StringVarP(&info.configFile, "config", "c", "",
+		"Optional path to the yaml containing a configmap entry. Generated from the workload by default")
+	flags.Uint16Var(&info.AgentPort, "agent-port", 9900,
+		"The port number you wish the agent to listen on.")
+	flags.StringVar(&info.QualifiedAgentImage, "agent-image", "docker.io/datawire/tel2:"+strings.TrimPrefix(client.Version(), "v"),
+		`The qualified name of the agent image`)
+	flags.Uint16Var(&info.ManagerPort, "manager-port", 8081,
+		`The traffic-manager API port`)
+	flags.StringVar(&info.ManagerNamespace, "manager-namespace", "ambassador",
+		`The traffic-manager namespace`)
+	flags.StringVar(&info.LogLevel, "loglevel", "info",
+		`The loglevel for the generated traffic-agent sidecar`)
+	flags.StringVar(&info.compliance, "compliance", agentconfig.ComplianceDefault.String(),
+		`The security compliance mode of the generated traffic-agent sidecar; one of "default" or "restricted"`)
+	flags.StringVar(&info.serviceMesh, "service-mesh", agentconfig.ServiceMeshNone.String(),
+		`The service mesh that the agent injector is configured with; one of "none", "auto", "istio", or "linkerd"`)
+	flags.AddFlagSet(kubeFlags)
+	return cmd
+}
+
+func (g *genSimulateInfo) run(cmd *cobra.Command, kubeFlags map[string]string) error {
+	if g.workloadName == "" && g.inputFile == "" {
+		return errcat.User.New("either a workload name or --input must be provided")
+	}
+	mesh, err := agentconfig.NewServiceMesh(g.serviceMesh)
+	if err != nil {
+		return errcat.User.New(err)
+	}
+	ctx, err := g.withK8sInterface(cmd.Context(), kubeFlags)
+	if err != nil {
+		return err
+	}
+
+	wl, err := g.loadWorkload(ctx)
+	if err != nil {
+		return err
+	}
+
+	var cfg *agentconfig.Sidecar
+	if g.configFile != "" {
+		if cfg, err = g.loadConfigMapEntry(ctx); err != nil {
+			return err
+		}
+	} else if cfg, err = g.generateConfigMap(ctx, wl); err != nil {
+		return err
+	}
+
+	podTpl := wl.GetPodTemplate()
+	pod := &core.Pod{
+		TypeMeta: meta.TypeMeta{
+			Kind:       "Pod",
+			APIVersion: "v1",
+		},
+		ObjectMeta: *podTpl.ObjectMeta.DeepCopy(),
+		Spec:       *podTpl.Spec.DeepCopy(),
+	}
+	if pod.Name == "" {
+		pod.Name = wl.GetName()
+	}
+	if pod.Namespace == "" {
+		pod.Namespace = wl.GetNamespace()
+	}
+	injected, err := mutator.Simulate(ctx, pod, cfg, mesh)
+	if err != nil {
+		return errcat.NoDaemonLogs.New(err)
+	}
+	diff, err := podDiff(pod, injected)
+	if err != nil {
+		return err
+	}
+	if diff == "" {
+		diff = "# the agent injector would not modify the pod\n"
+	}
+	return g.writeToOutput([]byte(diff))
+}
+
+// podDiff returns a unified diff between the YAML of the original and the injected pod.
+func podDiff(original, injected *core.Pod) (string, error) {
+	a, err := yaml.Marshal(original)
+	if err != nil {
+		return "", errcat.User.Newf("unable to marshal pod: %w", err)
+	}
+	b, err := yaml.Marshal(injected)
+	if err != nil {
+		return "", errcat.User.Newf("unable to marshal pod: %w", err)
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(a)),
+		B:        difflib.SplitLines(string(b)),
+		FromFile: "original",
+		ToFile:   "injected",
+		Context:  3,
+	})
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/intercept"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+func handoff() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "handoff",
+		Short: "Manage requests from other users to take over your intercepts",
+		Long: `Manage requests from other users to take over your intercepts.
+
+Another user who runs "telepresence intercept --take-over" on a workload that you intercept asks
+you to hand over your intercept. The request must be accepted or rejected within two minutes. It
+is rejected when it expires, unless your workstation is offline, in which case the intercept is
+handed over without asking.`,
+		Args: OnlySubcommands,
+		RunE: RunSubcommands,
+	}
+	cmd.AddCommand(handoffList(), handoffAnswer(true), handoffAnswer(false))
+	return cmd
+}
+
+func handoffList() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Args:  cobra.NoArgs,
+		Short: "List the pending requests to take over your intercepts",
+		Annotations: map[string]string{
+			ann.Session: ann.Required,
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := connect.InitCommand(cmd); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			rqs, err := takeOverRequests(ctx)
+			if err != nil {
+				return err
+			}
+			if output.WantsFormatted(cmd) {
+				output.Object(ctx, rqs, false)
+				return nil
+			}
+			out := output.Out(ctx)
+			if len(rqs) == 0 {
+				fmt.Fprintln(out, "No pending requests to take over intercepts")
+				return nil
+			}
+			for _, rq := range rqs {
+				fmt.Fprintf(out, "%s asks to take over intercept %s, expires at %s\n",
+					rq.Claimant, takeOverInterceptName(rq), rq.ExpiresAt.AsTime().Local().Format(time.Kitchen))
+			}
+			return nil
+		},
+	}
+}
+
+func handoffAnswer(accept bool) *cobra.Command {
+	var from string
+	use, short := "reject", "Refuse to hand over an intercept"
+	if accept {
+		use, short = "accept", "Hand over an intercept to the user who asked for it"
+	}
+	cmd := &cobra.Command{
+		Use:   use + " [flags] <intercept_name>",
+		Args:  cobra.ExactArgs(1),
+		Short: short,
+		Annotations: map[string]string{
+			ann.Session: ann.Required,
+		},
+		ValidArgsFunction: intercept.CompleteIntercepts,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := connect.InitCommand(cmd); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			rq, err := findTakeOverRequest(ctx, strings.TrimSpace(args[0]), from)
+			if err != nil {
+				return err
+			}
+			_, err = daemon.GetUserClient(ctx).AnswerTakeOver(ctx, &connector.TakeOverAnswer{Id: rq.Id, Accept: accept})
+			if err != nil {
+				return err
+			}
+			if accept {
+				fmt.Fprintf(output.Out(ctx), "Intercept %s handed over to %s\n", takeOverInterceptName(rq), rq.Claimant)
+			} else {
+				fmt.Fprintf(output.Out(ctx), "Refused to hand over intercept %s to %s\n", takeOverInterceptName(rq), rq.Claimant)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&from, "from", "", "The user@host that asked, required when several users ask for the same intercept")
+	return cmd
+}
+
+func takeOverRequests(ctx context.Context) ([]*manager.TakeOverRequest, error) {
+	rl, err := daemon.GetUserClient(ctx).ListTakeOverRequests(ctx, &emptypb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	return rl.Requests, nil
+}
+
+// takeOverInterceptName returns the name of the intercept that the request asks for. The intercept
+// ID is of the form <session ID>:<name>.
+func takeOverInterceptName(rq *manager.TakeOverRequest) string {
+	id := rq.InterceptId
+	return id[strings.IndexByte(id, ':')+1:]
+}
+
+func findTakeOverRequest(ctx context.Context, name, from string) (*manager.TakeOverRequest, error) {
+	rqs, err := takeOverRequests(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var found []*manager.TakeOverRequest
+	for _, rq := range rqs {
+		if takeOverInterceptName(rq) == name && (from == "" || rq.Claimant == from) {
+			found = append(found, rq)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, errcat.User.Newf("there is no pending request to take over intercept %q", name)
+	case 1:
+		return found[0], nil
+	default:
+		claimants := make([]string, len(found))
+		for i, rq := range found {
+			claimants[i] = rq.Claimant
+		}
+		return nil, errcat.User.Newf("several users ask to take over intercept %q (%s). Use --from to pick one",
+			name, strings.Join(claimants, ", "))
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
 	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	telhelm "github.com/telepresenceio/telepresence/v2/pkg/install/helm"
 	"github.com/telepresenceio/telepresence/v2/pkg/ioutil"
 )
 
@@ -36,6 +37,7 @@ type HelmCommand struct {
 	ReuseValues bool
 	ResetValues bool
 	CRDs        bool
+	Preview     bool
 }
 
 var (
@@ -100,6 +102,8 @@ func helmUpgrade() *cobra.Command {
 	flags.BoolVarP(&ha.ResetValues, "reset-values", "", false, "when upgrading, reset the values to the ones built into the chart")
 	flags.BoolVarP(&ha.ReuseValues, "reuse-values", "", false,
 		"when upgrading, reuse the last release's values and merge in any overrides from the command line via --set and -f")
+	flags.BoolVarP(&ha.Preview, "preview", "", false,
+		"print the difference between the manifest of the installed release and the one that the upgrade would produce, without upgrading")
 	ha.Request = daemon.InitRequest(cmd)
 	return cmd
 }
@@ -177,6 +181,12 @@ func (ha *HelmCommand) run(cmd *cobra.Command, _ []string) error {
 		}
 	}
 
+	if ha.RequestType != connector.HelmRequest_UNINSTALL {
+		if err = telhelm.ValidateValues(ha.AllValues, ha.CRDs); err != nil {
+			return err
+		}
+	}
+
 	valuesJSON, err := json.Marshal(ha.AllValues)
 	if err != nil {
 		return err
@@ -190,6 +200,7 @@ func (ha *HelmCommand) run(cmd *cobra.Command, _ []string) error {
 		ConnectRequest: &ha.Request.ConnectRequest,
 		Crds:           ha.CRDs,
 		NoHooks:        ha.NoHooks,
+		Preview:        ha.Preview,
 	}
 	ud := daemon.GetUserClient(ctx)
 	resp, err := ud.Helm(ctx, request)
@@ -199,6 +210,14 @@ func (ha *HelmCommand) run(cmd *cobra.Command, _ []string) error {
 	if err = errcat.FromResult(resp); err != nil {
 		return err
 	}
+	if ha.Preview {
+		if len(resp.Data) == 0 {
+			ioutil.Println(cmd.OutOrStdout(), "The upgrade doesn't change the installed release")
+		} else {
+			_, _ = cmd.OutOrStdout().Write(resp.Data)
+		}
+		return nil
+	}
 
 	var msg string
 	switch ha.RequestType {
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"text/template"
+
+	"github.com/spf13/cobra"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+// The prompt segment shows "(tp:<context>/<namespace>)" when connected, with ":<count>" appended when
+// there are active intercepts. All scripts use "status --porcelain" which reads a local cache and
+// never contacts the daemons, so they don't slow down the prompt noticeably.
+//
+//nolint:gochecknoglobals // constant
+var hookScripts = map[string]string{
+	"bash": `__telepresence_prompt() {
+  local line state name kctx ns icepts
+  line=$(command {{.Exe}} status --porcelain 2>/dev/null) || return
+  IFS=$'\t' read -r state name kctx ns icepts <<< "${line%%$'\n'*}"
+  [ "$state" = connected ] || return
+  if [ "${icepts:-0}" -gt 0 ]; then
+    printf '(tp:%s/%s:%s) ' "$kctx" "$ns" "$icepts"
+  else
+    printf '(tp:%s/%s) ' "$kctx" "$ns"
+  fi
+}
+case "$PS1" in
+  *__telepresence_prompt*) ;;
+  *) PS1='$(__telepresence_prompt)'"$PS1" ;;
+esac
+`,
+	"zsh": `__telepresence_prompt() {
+  local line state name kctx ns icepts
+  line=$(command {{.Exe}} status --porcelain 2>/dev/null) || return
+  IFS=$'\t' read -r state name kctx ns icepts <<< "${line%%$'\n'*}"
+  [[ "$state" == connected ]] || return
+  if (( ${icepts:-0} > 0 )); then
+    printf '(tp:%s/%s:%s) ' "$kctx" "$ns" "$icepts"
+  else
+    printf '(tp:%s/%s) ' "$kctx" "$ns"
+  fi
+}
+setopt PROMPT_SUBST
+[[ "$PROMPT" == *__telepresence_prompt* ]] || PROMPT='$(__telepresence_prompt)'"$PROMPT"
+`,
+	"fish": `function __telepresence_prompt
+    set -l line (command {{.Exe}} status --porcelain 2>/dev/null)[1]
+    set -l fields (string split \t -- $line)
+    test "$fields[1]" = connected; or return
+    if test "$fields[5]" -gt 0
+        printf '(tp:%s/%s:%s) ' $fields[3] $fields[4] $fields[5]
+    else
+        printf '(tp:%s/%s) ' $fields[3] $fields[4]
+    end
+end
+if not functions -q __telepresence_original_prompt
+    functions -c fish_prompt __telepresence_original_prompt
+    function fish_prompt
+        __telepresence_prompt
+        __telepresence_original_prompt
+    end
+end
+`,
+	"powershell": `function global:__TelepresencePrompt {
+    $line = & {{.Exe}} status --porcelain 2>$null | Select-Object -First 1
+    $f = "$line" -split "` + "`" + `t"
+    if ($f[0] -ne 'connected') { return '' }
+    if ([int]$f[4] -gt 0) { return "(tp:$($f[2])/$($f[3]):$($f[4])) " }
+    "(tp:$($f[2])/$($f[3])) "
+}
+if (-not (Test-Path function:global:__TelepresenceOriginalPrompt)) {
+    $function:global:__TelepresenceOriginalPrompt = $function:prompt
+    function global:prompt { "$(__TelepresencePrompt)$(__TelepresenceOriginalPrompt)" }
+}
+`,
+}
+
+func hook() *cobra.Command {
+	return &cobra.Command{
+		Use:       "hook {bash|zsh|fish|powershell}",
+		Short:     "Generate a script that adds the connection status to the shell prompt",
+		ValidArgs: []string{"bash", "zsh", "fish", "powershell"},
+		Args:      cobra.ExactArgs(1),
+		Long: `Generate a script that adds the connection status to the shell prompt.
+
+The prompt segment shows the Kubernetes context and namespace of the current connection, and the
+number of active intercepts. It is read from a local cache, so no daemon is contacted when the
+prompt is rendered.
+
+Bash:
+
+  $ eval "$(telepresence hook bash)"      # add to ~/.bashrc
+
+Zsh:
+
+  $ eval "$(telepresence hook zsh)"       # add to ~/.zshrc
+
+fish:
+
+  $ telepresence hook fish | source       # add to ~/.config/fish/config.fish
+
+PowerShell:
+
+  PS> telepresence hook powershell | Out-String | Invoke-Expression  # add to $PROFILE
+`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			shell := args[0]
+			if shell == "ps" {
+				shell = "powershell"
+			}
+			script, ok := hookScripts[shell]
+			if !ok {
+				return errcat.User.Newf("unsupported shell %q", args[0])
+			}
+			t, err := template.New(shell).Parse(script)
+			if err != nil {
+				return fmt.Errorf("invalid %s hook script: %w", shell, err)
+			}
+			return t.Execute(cmd.OutOrStdout(), map[string]string{"Exe": cmd.Root().Name()})
+		},
+	}
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHook(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+		t.Run(shell, func(t *testing.T) {
+			root := &cobra.Command{Use: "telepresence"}
+			root.AddCommand(hook())
+			out := &bytes.Buffer{}
+			root.SetOut(out)
+			root.SetArgs([]string{"hook", shell})
+			require.NoError(t, root.Execute())
+			assert.Contains(t, out.String(), "telepresence status --porcelain")
+		})
+	}
+	root := &cobra.Command{Use: "telepresence", SilenceErrors: true, SilenceUsage: true}
+	root.AddCommand(hook())
+	root.SetArgs([]string{"hook", "csh"})
+	assert.Error(t, root.Execute())
+}
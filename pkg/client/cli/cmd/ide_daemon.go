@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ide"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+func ideDaemon() *cobra.Command {
+	var interval time.Duration
+	cmd := &cobra.Command{
+		Use:   "ide-daemon",
+		Args:  cobra.NoArgs,
+		Short: "Serve the connector API to an IDE using JSON-RPC over stdio",
+		Long: `Serve the connector API to an IDE plugin using JSON-RPC 2.0 over stdin and stdout.
+
+Messages are framed with Content-Length headers, like in the Language Server Protocol. Every unary
+method of the connector API is available as "connector/<Method>", e.g. "connector/Connect" or
+"connector/CreateIntercept", with parameters and results that use the protobuf JSON mapping. The
+"initialize" method returns the available methods and notifications. Changes to the connection and its
+intercepts are pushed as "telepresence/status", "telepresence/intercepts", and
+"telepresence/disconnected" notifications. The command exits when stdin is closed or when the "exit"
+notification is received.`,
+		Annotations: map[string]string{
+			ann.UserDaemon: ann.Required,
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := connect.InitCommand(cmd); err != nil {
+				return err
+			}
+			if interval <= 0 {
+				return errcat.User.New("--interval must be a positive duration")
+			}
+			ctx := cmd.Context()
+			s := ide.NewServer(daemon.GetUserClient(ctx).Conn, cmd.OutOrStdout(), interval)
+			return s.Serve(ctx, cmd.InOrStdin())
+		},
+	}
+	cmd.Flags().DurationVar(&interval, "interval", time.Second, "How often the connection status is checked for changes")
+	return cmd
+}
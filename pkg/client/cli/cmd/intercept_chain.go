@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/spf13/cobra"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cache"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/intercept"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+const (
+	chainsDir = "chains"
+
+	// chainHeader is the header of the filter that the intercepts of a chain share when no --http-header is given.
+	chainHeader = "x-telepresence-chain"
+)
+
+// interceptChain is a set of intercepts that were created together by "telepresence intercept-chain", and that
+// "telepresence leave <chain name>" removes together. It's recorded in the user cache.
+type interceptChain struct {
+	Name       string   `json:"name"`
+	Intercepts []string `json:"intercepts"`
+}
+
+var chainNameRx = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+func chainFile(name string) string {
+	return chainsDir + "/" + name + ".json"
+}
+
+type interceptChainCommand struct {
+	name    string
+	ports   []string
+	headers []string
+	mount   string
+}
+
+func interceptChainCmd() *cobra.Command {
+	ic := &interceptChainCommand{}
+	cmd := &cobra.Command{
+		Use:   "intercept-chain [flags] <workload>,<workload>[,<workload>...]",
+		Args:  cobra.ExactArgs(1),
+		Short: "Intercept a path of services with one shared header filter",
+		Long: `Intercept a path of services with one shared header filter.
+
+An intercept is created for each of the given workloads, all matching the same HTTP headers, so that a request
+that carries those headers, and that the services propagate, is routed through the local handlers of all of
+them. The intercepts are named <chain name>-<workload>, and "telepresence leave <chain name>" removes all of them.
+If one of the intercepts can't be created, the ones that were created are removed again.`,
+		Annotations: map[string]string{
+			ann.Session:           ann.Required,
+			ann.RootDaemon:        ann.Required,
+			ann.UpdateCheckFormat: ann.Tel2,
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE:          ic.run,
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&ic.name, "name", "", `Name of the chain. Defaults to the name of the first workload followed by "-chain"`)
+	flags.StringSliceVarP(&ic.ports, "port", "p", nil, ``+
+		`Comma separated list of local ports to forward to, one for each workload and in the same order. Each port `+
+		`can use the same <local port>:<svcPortIdentifier> and "auto" syntax as the --port of "telepresence intercept"`)
+	flags.StringArrayVar(&ic.headers, "http-header", nil, ``+
+		`Header filter in the form name=value that all intercepts of the chain share. Can be repeated. `+
+		`Defaults to `+chainHeader+`=<chain name>`)
+	flags.StringVar(&ic.mount, "mount", "false", ``+
+		`The absolute path for the root directory where volumes will be mounted. Use "true" to have Telepresence `+
+		`pick a random mount point for each intercept. Volumes are not mounted by default`)
+	return cmd
+}
+
+func (ic *interceptChainCommand) validate(args []string) ([]string, error) {
+	var workloads []string
+	for _, wl := range strings.Split(args[0], ",") {
+		if wl = strings.TrimSpace(wl); wl != "" {
+			workloads = append(workloads, wl)
+		}
+	}
+	if len(workloads) < 2 {
+		return nil, errcat.User.New("an intercept chain needs at least two workloads")
+	}
+	if len(ic.ports) != len(workloads) {
+		return nil, errcat.User.Newf("--port must list one port for each of the %d workloads", len(workloads))
+	}
+	if ic.name == "" {
+		ic.name = workloads[0] + "-chain"
+	}
+	if !chainNameRx.MatchString(ic.name) {
+		return nil, errcat.User.Newf("invalid chain name %q. A name consists of letters, digits, '.', '_' and '-'", ic.name)
+	}
+	if len(ic.headers) == 0 {
+		ic.headers = []string{chainHeader + "=" + ic.name}
+	}
+	for _, h := range ic.headers {
+		if n, _, ok := strings.Cut(h, "="); !ok || n == "" {
+			return nil, errcat.User.Newf("--http-header %q is not in the form name=value", h)
+		}
+	}
+	return workloads, nil
+}
+
+func (ic *interceptChainCommand) run(cmd *cobra.Command, args []string) error {
+	workloads, err := ic.validate(args)
+	if err != nil {
+		return err
+	}
+	if err = connect.InitCommand(cmd); err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	if _, err = loadChain(ctx, ic.name); err == nil {
+		return errcat.User.Newf("intercept chain %q already exists", ic.name)
+	}
+
+	mechArgs := make([]string, len(ic.headers))
+	for i, h := range ic.headers {
+		mechArgs[i] = "--http-header=" + h
+	}
+	chain := &interceptChain{Name: ic.name}
+	for i, wl := range workloads {
+		name := ic.name + "-" + wl
+		err = intercept.NewState(cmd, &intercept.Command{
+			Name:          name,
+			AgentName:     wl,
+			Port:          ic.ports[i],
+			Address:       "127.0.0.1",
+			Mount:         ic.mount,
+			Mechanism:     "http",
+			MechanismArgs: mechArgs,
+		}).Run(ctx)
+		if err != nil {
+			err = fmt.Errorf("unable to intercept %s: %w", wl, err)
+			if rerr := removeChain(ctx, chain); rerr != nil {
+				dlog.Error(ctx, rerr)
+			}
+			return err
+		}
+		// The chain is saved after each intercept, so that "telepresence leave" finds the intercepts that were
+		// created even if this command is interrupted.
+		chain.Intercepts = append(chain.Intercepts, name)
+		if err = cache.SaveToUserCache(ctx, chain, chainFile(ic.name)); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Use \"telepresence leave %s\" to remove the %d intercepts of the chain\n", ic.name, len(workloads))
+	return nil
+}
+
+func loadChain(ctx context.Context, name string) (*interceptChain, error) {
+	var chain interceptChain
+	if err := cache.LoadFromUserCache(ctx, &chain, chainFile(name)); err != nil {
+		return nil, err
+	}
+	return &chain, nil
+}
+
+// removeChain removes all intercepts of the given chain, and then the chain itself. Intercepts that are already
+// gone are ignored.
+func removeChain(ctx context.Context, chain *interceptChain) error {
+	var result *multierror.Error
+	for _, name := range chain.Intercepts {
+		if err := removeIntercept(ctx, name); err != nil && errcat.GetCategory(err) != errcat.User {
+			result = multierror.Append(result, err)
+		}
+	}
+	if err := cache.DeleteFromUserCache(ctx, chainFile(chain.Name)); err != nil {
+		result = multierror.Append(result, err)
+	}
+	return result.ErrorOrNil()
+}
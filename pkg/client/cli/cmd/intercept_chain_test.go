@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterceptChainValidate(t *testing.T) {
+	ic := &interceptChainCommand{ports: []string{"8080", "8081"}}
+	wls, err := ic.validate([]string{"svc-a, svc-b"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"svc-a", "svc-b"}, wls)
+	assert.Equal(t, "svc-a-chain", ic.name)
+	assert.Equal(t, []string{"x-telepresence-chain=svc-a-chain"}, ic.headers)
+
+	ic = &interceptChainCommand{name: "checkout", ports: []string{"8080", "8081"}, headers: []string{"x-user=jane"}}
+	_, err = ic.validate([]string{"svc-a,svc-b"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"x-user=jane"}, ic.headers)
+
+	tests := []struct {
+		name string
+		ic   *interceptChainCommand
+		arg  string
+		err  string
+	}{
+		{"one workload", &interceptChainCommand{ports: []string{"8080"}}, "svc-a", "at least two workloads"},
+		{"too few ports", &interceptChainCommand{ports: []string{"8080"}}, "svc-a,svc-b", "one port for each of the 2 workloads"},
+		{"bad name", &interceptChainCommand{name: "../x", ports: []string{"8080", "8081"}}, "svc-a,svc-b", "invalid chain name"},
+		{"bad header", &interceptChainCommand{ports: []string{"8080", "8081"}, headers: []string{"x-user"}}, "svc-a,svc-b", "not in the form name=value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.ic.validate([]string{tt.arg})
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/datawire/dlib/dlog"
-	"github.com/telepresenceio/telepresence/rpc/v2/connector"
 	"github.com/telepresenceio/telepresence/rpc/v2/manager"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
@@ -25,7 +24,7 @@ func leave() *cobra.Command {
 		Use:  "leave [flags] <intercept_name>",
 		Args: cobra.ExactArgs(1),
 
-		Short: "Remove existing intercept",
+		Short: "Remove existing intercept, or all intercepts of an intercept chain",
 		Annotations: map[string]string{
 			ann.Session: ann.Required,
 		},
@@ -33,37 +32,14 @@ func leave() *cobra.Command {
 			if err := connect.InitCommand(cmd); err != nil {
 				return err
 			}
-			return removeIntercept(cmd.Context(), strings.TrimSpace(args[0]))
-		},
-		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-			shellCompDir := cobra.ShellCompDirectiveNoFileComp
-			if len(args) != 0 {
-				return nil, shellCompDir
-			}
-			if err := connect.InitCommand(cmd); err != nil {
-				return nil, shellCompDir | cobra.ShellCompDirectiveError
-			}
 			ctx := cmd.Context()
-			userD := daemon.GetUserClient(ctx)
-			resp, err := userD.List(ctx, &connector.ListRequest{Filter: connector.ListRequest_INTERCEPTS})
-			if err != nil {
-				return nil, shellCompDir | cobra.ShellCompDirectiveError
-			}
-			if len(resp.Workloads) == 0 {
-				return nil, shellCompDir
-			}
-
-			var completions []string
-			for _, intercept := range resp.Workloads {
-				for _, ii := range intercept.InterceptInfos {
-					name := ii.Spec.Name
-					if strings.HasPrefix(name, toComplete) {
-						completions = append(completions, name)
-					}
-				}
+			name := strings.TrimSpace(args[0])
+			if chain, err := loadChain(ctx, name); err == nil {
+				return removeChain(ctx, chain)
 			}
-			return completions, shellCompDir
+			return removeIntercept(ctx, name)
 		},
+		ValidArgsFunction: intercept.CompleteIntercepts,
 	}
 }
 
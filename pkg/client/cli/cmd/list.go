@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
@@ -26,6 +27,7 @@ type listCommand struct {
 	onlyAgents        bool
 	onlyInterceptable bool
 	debug             bool
+	detail            bool
 	namespace         string
 	watch             bool
 }
@@ -52,6 +54,7 @@ func list() *cobra.Command {
 	flags.BoolVarP(&s.onlyAgents, "agents", "a", false, "with installed agents only")
 	flags.BoolVarP(&s.onlyInterceptable, "only-interceptable", "o", true, "interceptable workloads only")
 	flags.BoolVar(&s.debug, "debug", false, "include debugging information")
+	flags.BoolVar(&s.detail, "detail", false, "include the external URLs of the Ingress and HTTPRoute routes that lead to each workload")
 	flags.StringVarP(&s.namespace, "namespace", "n", "", "If present, the namespace scope for this CLI request")
 
 	flags.BoolVarP(&s.watch, "watch", "w", false, "watch a namespace. --agents and --intercepts are disabled if this flag is set")
@@ -116,7 +119,7 @@ func (s *listCommand) list(cmd *cobra.Command, _ []string) error {
 
 	formattedOutput := output.WantsFormatted(cmd)
 	if !output.WantsStream(cmd) {
-		r, err := userD.List(ctx, &connector.ListRequest{Filter: filter, Namespace: s.namespace}, grpc.MaxCallRecvMsgSize(int(maxRecSize)))
+		r, err := userD.List(ctx, &connector.ListRequest{Filter: filter, Namespace: s.namespace, IncludeRoutes: s.detail}, grpc.MaxCallRecvMsgSize(int(maxRecSize)))
 		if err != nil {
 			return err
 		}
@@ -124,7 +127,7 @@ func (s *listCommand) list(cmd *cobra.Command, _ []string) error {
 		return nil
 	}
 
-	stream, streamErr := userD.WatchWorkloads(ctx, &connector.WatchWorkloadsRequest{Namespaces: []string{s.namespace}}, grpc.MaxCallRecvMsgSize(int(maxRecSize)))
+	stream, streamErr := userD.WatchWorkloads(ctx, &connector.WatchWorkloadsRequest{Namespaces: []string{s.namespace}, Incremental: true}, grpc.MaxCallRecvMsgSize(int(maxRecSize)))
 	if streamErr != nil {
 		return streamErr
 	}
@@ -144,6 +147,8 @@ func (s *listCommand) list(cmd *cobra.Command, _ []string) error {
 		}
 	}()
 
+	// The first snapshot is complete. The ones that follow only contain what has changed.
+	workloads := make(map[string]*connector.WorkloadInfo)
 	for {
 		select {
 		case r, ok := <-ch:
@@ -153,7 +158,18 @@ func (s *listCommand) list(cmd *cobra.Command, _ []string) error {
 			if r.err != nil {
 				return errcat.NoDaemonLogs.Newf("%v", r.err)
 			}
-			s.printList(ctx, r.workloadInfoSnapshot.Workloads, stdout, formattedOutput)
+			for _, wi := range r.workloadInfoSnapshot.Workloads {
+				workloads[wi.Uid] = wi
+			}
+			for _, uid := range r.workloadInfoSnapshot.RemovedUids {
+				delete(workloads, uid)
+			}
+			wis := make([]*connector.WorkloadInfo, 0, len(workloads))
+			for _, wi := range workloads {
+				wis = append(wis, wi)
+			}
+			sort.Slice(wis, func(i, j int) bool { return wis[i].Name < wis[j].Name })
+			s.printList(ctx, wis, stdout, formattedOutput)
 		case <-ctx.Done():
 			return nil
 		}
@@ -244,7 +260,24 @@ func (s *listCommand) printList(ctx context.Context, workloads []*connector.Work
 					n += "." + workload.Namespace
 				}
 				fmt.Fprintf(stdout, "%-*s: %s\n", nameLen, n, state(workload))
+				if s.detail {
+					printRoutes(stdout, workload, nameLen+2)
+				}
 			}
 		}
 	}
 }
+
+// printRoutes prints the external URLs of the routes that lead to the services of the given workload.
+func printRoutes(out io.Writer, workload *connector.WorkloadInfo, indent int) {
+	svcs := make([]*connector.WorkloadInfo_ServiceReference, 0, len(workload.Services))
+	for _, svc := range workload.Services {
+		svcs = append(svcs, svc)
+	}
+	sort.Slice(svcs, func(i, j int) bool { return svcs[i].Name < svcs[j].Name })
+	for _, svc := range svcs {
+		for _, r := range svc.Routes {
+			fmt.Fprintf(out, "%*s%s (%s %s -> service %s)\n", indent, "", r.Url, r.Kind, r.Name, svc.Name)
+		}
+	}
+}
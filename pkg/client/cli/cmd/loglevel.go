@@ -40,12 +40,18 @@ func logLevelArg(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func loglevel() *cobra.Command {
+// logLevelNames returns the names of the log-levels that can be set temporarily.
+func logLevelNames() []string {
 	lvs := logrus.AllLevels[2:] // Don't include `panic` and `fatal`
 	lvStrs := make([]string, len(lvs))
 	for i, lv := range lvs {
 		lvStrs[i] = lv.String()
 	}
+	return lvStrs
+}
+
+func loglevel() *cobra.Command {
+	lvStrs := logLevelNames()
 	lls := logLevelCommand{}
 	cmd := &cobra.Command{
 		Use:       fmt.Sprintf("loglevel <%s>", strings.Join(lvStrs, ",")),
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/userd/trafficmgr"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+func logs() *cobra.Command {
+	rq := connector.StreamLogsRequest{}
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Args:  cobra.NoArgs,
+		Short: "Show the logs of the daemons, the traffic-manager, and the traffic-agents",
+		Long: `Show the logs of the root daemon, the user daemon, the traffic-manager, and the
+traffic-agents. The logs of the traffic-manager and the traffic-agents are read using
+the connection that the user daemon has to the cluster, so they require an active session.
+Each line is prefixed with the name of its source when more than one source is shown,
+and the lines of a traffic-agent are always prefixed with its <pod>.<namespace>/<container>.`,
+		Example: `# Follow the logs of the root daemon
+telepresence logs -f --daemon rootd
+
+# Show the last 100 lines of the logs of the traffic-agents in pods that have "echo" in the name
+telepresence logs --daemon agent --agents echo --tail 100
+
+# Show what all components logged during the current session
+telepresence logs --session`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runLogs(cmd, &rq)
+		},
+		Annotations: map[string]string{
+			ann.UserDaemon: ann.Required,
+			ann.Session:    ann.Optional,
+		},
+	}
+	flags := cmd.Flags()
+	flags.BoolVarP(&rq.Follow, "follow", "f", false, "Continue to show new log entries as they are written")
+	flags.StringSliceVar(&rq.Sources, "daemon", nil,
+		"The sources to show logs from: rootd, connector, manager, agent. Default is all available sources")
+	flags.StringVar(&rq.Agents, "agents", "", "Only show logs from traffic-agents in pods that have this substring in their name")
+	flags.BoolVar(&rq.CurrentSession, "session", false, "Only show log entries that belong to the current session")
+	flags.Int32Var(&rq.Tail, "tail", -1, "Number of lines to show from the end of each log. Default is all lines")
+	return cmd
+}
+
+func runLogs(cmd *cobra.Command, rq *connector.StreamLogsRequest) error {
+	if err := connect.InitCommand(cmd); err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	stream, err := daemon.GetUserClient(ctx).StreamLogs(ctx, rq)
+	if err != nil {
+		return err
+	}
+	// The logs of several traffic-agents are interleaved, so they are prefixed even when they are the only source.
+	prefix := len(rq.Sources) != 1 || rq.Sources[0] == trafficmgr.LogSourceAgent
+	out := cmd.OutOrStdout()
+	for {
+		le, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			if st, ok := status.FromError(err); ok && st.Code() == codes.InvalidArgument {
+				return errcat.User.New(st.Message())
+			}
+			return err
+		}
+		if prefix {
+			fmt.Fprintf(out, "%s: %s\n", le.Source, le.Text)
+		} else {
+			fmt.Fprintln(out, le.Text)
+		}
+	}
+}
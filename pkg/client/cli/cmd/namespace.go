@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	core "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	k8smeta "k8s.io/apimachinery/pkg/api/meta"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
+)
+
+func namespaceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "namespace",
+		Aliases: []string{"namespaces"},
+		Short:   "Manage namespaces",
+		Args:    OnlySubcommands,
+		RunE:    RunSubcommands,
+	}
+	cmd.AddCommand(namespaceCreate(), namespaceMap(false), namespaceMap(true))
+	return cmd
+}
+
+func namespaceMap(unmap bool) *cobra.Command {
+	use, short := "map", "Add namespaces to the mapped namespaces of the current connection"
+	if unmap {
+		use, short = "unmap", "Remove namespaces from the mapped namespaces of the current connection"
+	}
+	return &cobra.Command{
+		Use:   use + " <namespace> [<namespace>...]",
+		Args:  cobra.MinimumNArgs(1),
+		Short: short,
+		Long: short + `.
+
+DNS and routing of the connection are updated without a reconnect. The namespaces that are
+mapped after the update are printed. An empty set of mapped namespaces means all namespaces, so
+at least one namespace must remain mapped.`,
+		Annotations: map[string]string{
+			ann.Session: ann.Required,
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := connect.InitCommand(cmd); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			r, err := daemon.GetUserClient(ctx).MapNamespaces(ctx, &connector.MapNamespacesRequest{Namespaces: args, Unmap: unmap})
+			if err != nil {
+				return err
+			}
+			if output.WantsFormatted(cmd) {
+				output.Object(ctx, r.Namespaces, false)
+			} else {
+				fmt.Fprintf(output.Out(ctx), "Mapped namespaces: %s\n", strings.Join(r.Namespaces, ", "))
+			}
+			return nil
+		},
+	}
+}
+
+type namespaceCreateCommand struct {
+	rq       *daemon.Request
+	ttl      time.Duration
+	template string
+}
+
+func namespaceCreate() *cobra.Command {
+	nc := &namespaceCreateCommand{}
+	cmd := &cobra.Command{
+		Use:   "create [flags] [<name>] [-- <command to run while connected>]",
+		Args:  cobra.ArbitraryArgs,
+		Short: "Create an ephemeral namespace and connect to it",
+		Long: `Create an ephemeral namespace and connect to it.
+
+The namespace is labeled as ephemeral and deleted by the traffic-manager when its time to live
+has expired, provided that the traffic-manager is installed with ephemeralNamespaces.enabled and
+was running when the namespace was created. A name is generated unless one is given. The resources of an optional template
+manifest are created in the namespace before the connection is established.`,
+		Annotations: map[string]string{
+			ann.Session:    ann.Required,
+			ann.RootDaemon: ann.Required,
+		},
+		RunE: nc.run,
+	}
+	flags := cmd.Flags()
+	flags.DurationVar(&nc.ttl, "ttl", 4*time.Hour, "Time to live for the namespace")
+	flags.StringVar(&nc.template, "template", "", "Manifest with namespaced resources to create in the namespace")
+	nc.rq = daemon.InitRequest(cmd)
+	return cmd
+}
+
+func (nc *namespaceCreateCommand) run(cmd *cobra.Command, args []string) error {
+	if nc.ttl <= 0 {
+		return errcat.User.New("--ttl must be a positive duration")
+	}
+	nameArgs := args
+	if dl := cmd.ArgsLenAtDash(); dl >= 0 {
+		nameArgs, args = args[:dl], args[dl:]
+	} else {
+		args = nil
+	}
+	name := ""
+	switch len(nameArgs) {
+	case 0:
+	case 1:
+		name = nameArgs[0]
+	default:
+		return errcat.User.New("at most one namespace name can be given")
+	}
+	if err := nc.rq.CommitFlags(cmd); err != nil {
+		return err
+	}
+	var objs []*unstructured.Unstructured
+	if nc.template != "" {
+		data, err := os.ReadFile(nc.template)
+		if err != nil {
+			return errcat.User.New(err)
+		}
+		if objs, err = parseManifest(data); err != nil {
+			return errcat.User.Newf("unable to parse template %s: %w", nc.template, err)
+		}
+	}
+	ctx := cmd.Context()
+	restConfig, err := nc.rq.RESTClientGetter().ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	ki, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().Add(nc.ttl).UTC().Truncate(time.Second)
+	// The name is generated here rather than by the API server, because the traffic-manager's claim of the
+	// namespace, which it requires before it deletes the namespace, includes the name.
+	generated := name == ""
+	var ns *core.Namespace
+	for attempt := 0; ; attempt++ {
+		if generated {
+			name = "telepresence-" + utilrand.String(5)
+		}
+		ns, err = ki.CoreV1().Namespaces().Create(ctx, &core.Namespace{ObjectMeta: meta.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{install.EphemeralNamespaceLabel: "true"},
+			Annotations: map[string]string{install.ExpiresAtAnnotation: expiresAt.Format(time.RFC3339)},
+		}}, meta.CreateOptions{})
+		if err == nil {
+			break
+		}
+		if !(generated && k8serrors.IsAlreadyExists(err) && attempt < 3) {
+			return errcat.User.Newf("unable to create namespace: %w", err)
+		}
+	}
+	if len(objs) > 0 {
+		if err = nc.applyTemplate(ctx, ns.Name, objs); err != nil {
+			if derr := ki.CoreV1().Namespaces().Delete(ctx, ns.Name, meta.DeleteOptions{}); derr != nil {
+				dlog.Errorf(ctx, "unable to delete namespace %s: %v", ns.Name, derr)
+			}
+			return err
+		}
+	}
+	fmt.Fprintf(output.Info(ctx), "Namespace %s created, expires at %s\n", ns.Name, expiresAt.Local().Format(time.RFC3339))
+	nc.rq.SetNamespace(ns.Name)
+	return connect.RunConnect(cmd, args)
+}
+
+// parseManifest returns the objects of a YAML or JSON manifest that may contain several documents.
+func parseManifest(data []byte) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	dec := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := dec.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objs, nil
+			}
+			return nil, err
+		}
+		if len(obj.Object) > 0 {
+			objs = append(objs, obj)
+		}
+	}
+}
+
+// applyTemplate creates the given resources in the given namespace. Cluster scoped resources are
+// rejected, because they would outlive the namespace.
+func (nc *namespaceCreateCommand) applyTemplate(ctx context.Context, ns string, objs []*unstructured.Unstructured) error {
+	rg := nc.rq.RESTClientGetter()
+	restConfig, err := rg.ToRESTConfig()
+	if err != nil {
+		return err
+	}
+	mapper, err := rg.ToRESTMapper()
+	if err != nil {
+		return err
+	}
+	dc, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		gvk := obj.GroupVersionKind()
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return errcat.User.Newf("template %s: %w", nc.template, err)
+		}
+		if mapping.Scope.Name() != k8smeta.RESTScopeNameNamespace {
+			return errcat.User.Newf("template %s: %s %s is not namespaced", nc.template, gvk.Kind, obj.GetName())
+		}
+		obj.SetNamespace(ns)
+		if _, err = dc.Resource(mapping.Resource).Namespace(ns).Create(ctx, obj, meta.CreateOptions{}); err != nil {
+			return errcat.User.Newf("unable to create %s %s: %w", gvk.Kind, obj.GetName(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+const (
+	// pluginPrefix is the prefix of executables in the PATH that become subcommands.
+	pluginPrefix = "telepresence-"
+
+	// pluginManifestFile is the file in the user's config directory that declares plugins.
+	pluginManifestFile = "plugins.yml"
+
+	// pluginAPIVersion is the version of the environment that plugins are started with. It is passed
+	// in the TELEPRESENCE_PLUGIN_API environment variable.
+	pluginAPIVersion = "1"
+
+	pluginSessionNone = "none"
+)
+
+// pluginSpec describes a plugin, either declared in the plugin manifest or found in the PATH.
+type pluginSpec struct {
+	// Name is the name of the subcommand.
+	Name string `yaml:"name"`
+
+	// Command is the executable. A relative path is relative to the config directory, and a
+	// plain name is looked up in the PATH.
+	Command string `yaml:"command"`
+
+	// Args are passed to the executable before the arguments of the subcommand.
+	Args []string `yaml:"args,omitempty"`
+
+	// Short is the description shown in the help.
+	Short string `yaml:"short,omitempty"`
+
+	// Session is "required" when the plugin needs a connection, which is then established before the
+	// plugin starts, "optional" (the default) when it uses a connection if one exists, or "none".
+	Session string `yaml:"session,omitempty"`
+}
+
+type pluginManifest struct {
+	Plugins []*pluginSpec `yaml:"plugins"`
+}
+
+// addPlugin adds a subcommand for the plugin named by the first of the given arguments when that argument
+// isn't the name of an existing subcommand. Like kubectl, plugins are only looked up when they are invoked,
+// so that other commands don't pay for a scan of the PATH.
+func addPlugin(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		return
+	}
+	name := args[0]
+	if strings.HasPrefix(name, "-") || strings.HasPrefix(name, "__") || strings.ContainsAny(name, " \t/\\") {
+		// A flag, one of cobra's hidden completion commands, or not a valid plugin name.
+		return
+	}
+	for _, c := range cmd.Commands() {
+		if c.Name() == name || c.HasAlias(name) {
+			return
+		}
+	}
+	ctx := cmd.Context()
+	p := lookupPlugin(ctx, name)
+	if p == nil {
+		return
+	}
+	pc := p.command()
+	pc.SetContext(ctx)
+	cmd.AddCommand(pc)
+}
+
+// lookupPlugin returns the plugin with the given name, or nil if no such plugin exists. A plugin declared in
+// the plugin manifest takes precedence over an executable named telepresence-<name> in the PATH.
+func lookupPlugin(ctx context.Context, name string) *pluginSpec {
+	plugins, err := loadPluginManifest(ctx)
+	if err != nil {
+		dlog.Warn(ctx, err)
+	}
+	for _, p := range plugins {
+		if p.Name == name {
+			return p
+		}
+	}
+	path, err := exec.LookPath(pluginPrefix + name)
+	if err != nil {
+		return nil
+	}
+	return &pluginSpec{
+		Name:    name,
+		Command: path,
+		Short:   fmt.Sprintf("Run the %s plugin", filepath.Base(path)),
+		Session: ann.Optional,
+	}
+}
+
+func loadPluginManifest(ctx context.Context) ([]*pluginSpec, error) {
+	dir := filelocation.AppUserConfigDir(ctx)
+	file := filepath.Join(dir, pluginManifestFile)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	var m pluginManifest
+	if err = yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", file, err)
+	}
+	plugins := make([]*pluginSpec, 0, len(m.Plugins))
+	for i, p := range m.Plugins {
+		switch {
+		case p.Name == "" || strings.ContainsAny(p.Name, " \t/\\"):
+			return nil, fmt.Errorf("%s: plugin %d has an invalid name %q", file, i, p.Name)
+		case p.Command == "":
+			return nil, fmt.Errorf("%s: plugin %s has no command", file, p.Name)
+		}
+		switch p.Session {
+		case "":
+			p.Session = ann.Optional
+		case ann.Optional, ann.Required, pluginSessionNone:
+		default:
+			return nil, fmt.Errorf("%s: plugin %s has an invalid session %q, must be one of %s, %s, or %s",
+				file, p.Name, p.Session, ann.Required, ann.Optional, pluginSessionNone)
+		}
+		if strings.ContainsRune(p.Command, filepath.Separator) && !filepath.IsAbs(p.Command) {
+			p.Command = filepath.Join(dir, p.Command)
+		}
+		plugins = append(plugins, p)
+	}
+	return plugins, nil
+}
+
+func (p *pluginSpec) command() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:                p.Name,
+		Short:              p.Short,
+		DisableFlagParsing: true,
+		RunE:               p.run,
+		Annotations:        map[string]string{},
+	}
+	if cmd.Short == "" {
+		cmd.Short = fmt.Sprintf("Run the %s plugin", p.Name)
+	}
+	if p.Session != pluginSessionNone {
+		cmd.Annotations[ann.Session] = p.Session
+	}
+	return cmd
+}
+
+// run performs the handshake with the plugin, i.e. it establishes the connection that the plugin declared
+// that it needs, and starts the plugin with an environment that tells it how to use that connection.
+func (p *pluginSpec) run(cmd *cobra.Command, args []string) error {
+	if p.Session != pluginSessionNone {
+		if err := connect.InitCommand(cmd); err != nil {
+			return err
+		}
+	}
+	ctx := cmd.Context()
+	pc := exec.CommandContext(ctx, p.Command, append(append([]string{}, p.Args...), args...)...)
+	pc.Env = append(os.Environ(), pluginEnv(ctx, p.Name)...)
+	pc.Stdin = cmd.InOrStdin()
+	pc.Stdout = cmd.OutOrStdout()
+	pc.Stderr = cmd.ErrOrStderr()
+	if err := pc.Run(); err != nil {
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			// The plugin has reported its error.
+			return errcat.ExitCode(ee.ExitCode())
+		}
+		return errcat.User.Newf("unable to run plugin %s: %w", p.Name, err)
+	}
+	return nil
+}
+
+// pluginEnv returns the environment that tells a plugin how to reach the user daemon, which serves the
+// connector gRPC API, and describes the current connection, if any.
+func pluginEnv(ctx context.Context, name string) []string {
+	env := []string{
+		"TELEPRESENCE_PLUGIN_API=" + pluginAPIVersion,
+		"TELEPRESENCE_PLUGIN_NAME=" + name,
+		"TELEPRESENCE_EXECUTABLE=" + client.GetExe(),
+		"TELEPRESENCE_CONFIG_DIR=" + filelocation.AppUserConfigDir(ctx),
+	}
+	if ud := daemon.GetUserClient(ctx); ud != nil {
+		env = append(env, "TELEPRESENCE_CONNECTOR_ADDRESS="+ud.Conn.Target())
+	}
+	if s := daemon.GetSession(ctx); s != nil && s.Info != nil {
+		env = append(env,
+			"TELEPRESENCE_CONTEXT="+s.Info.ClusterContext,
+			"TELEPRESENCE_NAMESPACE="+s.Info.Namespace,
+			"TELEPRESENCE_MANAGER_NAMESPACE="+s.Info.ManagerNamespace,
+		)
+	}
+	return env
+}
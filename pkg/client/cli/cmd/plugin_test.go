@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+func TestLookupPlugin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins on Windows are found by extension rather than by the executable bit")
+	}
+	configDir := t.TempDir()
+	pathA := t.TempDir()
+	pathB := t.TempDir()
+	writeExe := func(dir, name string, mode os.FileMode) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), mode))
+	}
+	writeExe(pathA, "telepresence-deploy", 0o755)
+	writeExe(pathA, "telepresence-notexec", 0o644)
+	writeExe(pathA, "other-tool", 0o755)
+	writeExe(pathB, "telepresence-deploy", 0o755)
+	writeExe(pathB, "telepresence-status", 0o755)
+	writeExe(pathB, "telepresence-preview", 0o755)
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, pluginManifestFile), []byte(`
+plugins:
+  - name: preview
+    command: bin/preview
+    args: [--verbose]
+    short: Create a preview URL
+    session: required
+  - name: lint
+    command: /usr/local/bin/lint
+    session: none
+`), 0o644))
+
+	ctx := dlog.NewTestContext(t, false)
+	ctx = filelocation.WithAppUserConfigDir(ctx, configDir)
+	t.Setenv("PATH", pathA+string(os.PathListSeparator)+pathB)
+
+	p := lookupPlugin(ctx, "preview")
+	require.NotNil(t, p)
+	assert.Equal(t, filepath.Join(configDir, "bin", "preview"), p.Command)
+	assert.Equal(t, []string{"--verbose"}, p.Args)
+	assert.Equal(t, ann.Required, p.Session)
+
+	p = lookupPlugin(ctx, "lint")
+	require.NotNil(t, p)
+	assert.Equal(t, pluginSessionNone, p.Session)
+
+	p = lookupPlugin(ctx, "deploy")
+	require.NotNil(t, p)
+	assert.Equal(t, filepath.Join(pathA, "telepresence-deploy"), p.Command)
+	assert.Equal(t, ann.Optional, p.Session)
+
+	assert.Nil(t, lookupPlugin(ctx, "notexec"))
+	assert.Nil(t, lookupPlugin(ctx, "other-tool"))
+
+	newRoot := func() *cobra.Command {
+		root := &cobra.Command{Use: "telepresence"}
+		root.SetContext(ctx)
+		root.AddCommand(&cobra.Command{Use: "status"})
+		return root
+	}
+	names := func(root *cobra.Command) []string {
+		var names []string
+		for _, c := range root.Commands() {
+			names = append(names, c.Name())
+		}
+		return names
+	}
+
+	// Only the invoked plugin is added, and only when it isn't an existing command.
+	for _, args := range [][]string{nil, {"status"}, {"--help"}, {"../telepresence-deploy"}, {"missing"}} {
+		root := newRoot()
+		addPlugin(root, args)
+		assert.Equal(t, []string{"status"}, names(root), "args %v", args)
+	}
+	root := newRoot()
+	addPlugin(root, []string{"lint", "--fix"})
+	assert.ElementsMatch(t, []string{"lint", "status"}, names(root))
+	for _, c := range root.Commands() {
+		if c.Name() == "lint" {
+			assert.NotContains(t, c.Annotations, ann.Session)
+		}
+	}
+}
+
+func TestLoadPluginManifestErrors(t *testing.T) {
+	for name, manifest := range map[string]string{
+		"no name":     "plugins:\n  - command: foo\n",
+		"no command":  "plugins:\n  - name: foo\n",
+		"bad session": "plugins:\n  - name: foo\n    command: foo\n    session: always\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, pluginManifestFile), []byte(manifest), 0o644))
+			_, err := loadPluginManifest(filelocation.WithAppUserConfigDir(context.Background(), dir))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -1,23 +1,31 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 
+	"github.com/datawire/dlib/dlog"
 	daemon2 "github.com/telepresenceio/telepresence/rpc/v2/daemon"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/socket"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
 )
 
 func quit() *cobra.Command {
 	quitDaemons := false
 	quitRootDaemon := false
 	quitUserDaemon := false
+	force := false
 	cmd := &cobra.Command{
 		Use:  "quit",
 		Args: cobra.NoArgs,
@@ -44,11 +52,15 @@ func quit() *cobra.Command {
 					_, _ = daemon2.NewDaemonClient(conn).Quit(ctx, &emptypb.Empty{})
 				}
 			}
+			if err := checkLeases(ctx, force); err != nil {
+				return err
+			}
 			return connect.Disconnect(cmd.Context(), quitDaemons)
 		},
 	}
 	flags := cmd.Flags()
 	flags.BoolVarP(&quitDaemons, "stop-daemons", "s", false, "stop the traffic-manager and network daemons")
+	flags.BoolVarP(&force, "force", "f", false, "quit even when other processes use the connection")
 	flags.BoolVarP(&quitRootDaemon, "root-daemon", "r", false, "stop daemons")
 	flags.BoolVarP(&quitUserDaemon, "user-daemon", "u", false, "stop daemons")
 
@@ -57,3 +69,35 @@ func quit() *cobra.Command {
 	flags.Lookup("user-daemon").Hidden = true
 	return cmd
 }
+
+// checkLeases returns an error when processes other than the shell that runs this command hold a
+// lease on the connection, unless force is true, in which case a warning is printed.
+func checkLeases(ctx context.Context, force bool) error {
+	ud := daemon.GetUserClient(ctx)
+	if ud == nil {
+		return nil
+	}
+	ll, err := ud.ListLeases(ctx, &emptypb.Empty{})
+	if err != nil {
+		if status.Code(err) != codes.Unimplemented {
+			dlog.Debugf(ctx, "unable to list leases: %v", err)
+		}
+		return nil
+	}
+	self := int32(os.Getppid())
+	var others []string
+	for _, l := range ll.Leases {
+		if l.Pid != self {
+			others = append(others, fmt.Sprintf("%s (pid %d)", l.Holder, l.Pid))
+		}
+	}
+	if len(others) == 0 {
+		return nil
+	}
+	holders := strings.Join(others, ", ")
+	if !force {
+		return errcat.User.Newf("the connection is also used by %s; use --force to quit anyway", holders)
+	}
+	fmt.Fprintf(output.Info(ctx), "Warning: the connection is also used by %s\n", holders)
+	return nil
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/spf13/cobra"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	daemonRpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+)
+
+func routeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "route",
+		Short: "Inspect the routes that the root daemon maintains",
+		Args:  OnlySubcommands,
+		RunE:  RunSubcommands,
+	}
+	cmd.AddCommand(routeList(), routeCheck())
+	return cmd
+}
+
+type routeInfo struct {
+	Subnet    string `json:"subnet" yaml:"subnet"`
+	Source    string `json:"source" yaml:"source"`
+	Interface string `json:"interface,omitempty" yaml:"interface,omitempty"`
+	Gateway   string `json:"gateway,omitempty" yaml:"gateway,omitempty"`
+	Installed bool   `json:"installed" yaml:"installed"`
+}
+
+func newRouteInfo(r *daemonRpc.Route) *routeInfo {
+	ri := &routeInfo{
+		Subnet:    iputil.IPNetFromRPC(r.Subnet).String(),
+		Source:    strings.ReplaceAll(strings.ToLower(r.Source.String()), "_", "-"),
+		Interface: r.Interface,
+		Installed: r.Installed,
+	}
+	if len(r.Gateway) > 0 {
+		ri.Gateway = net.IP(r.Gateway).String()
+	}
+	return ri
+}
+
+func routeList() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Args:  cobra.NoArgs,
+		Short: "List the routes that the root daemon maintains",
+		Long: `List the routes that the root daemon maintains.
+
+Each route shows its subnet, the reason why it exists, and the network interface that it directs
+traffic to. The source is one of:
+
+  cluster      a pod or service subnet of the cluster
+  also-proxy   a subnet from the also-proxy configuration
+  resolved     a single address that was resolved using the cluster's DNS
+  never-proxy  a subnet from the never-proxy configuration. Only installed when it overlaps
+               a routed subnet`,
+		Annotations: map[string]string{
+			ann.Session: ann.Required,
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if err := connect.InitCommand(cmd); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			rl, err := daemon.GetUserClient(ctx).GetRoutes(ctx, &empty.Empty{})
+			if err != nil {
+				return err
+			}
+			routes := make([]*routeInfo, len(rl.Routes))
+			for i, r := range rl.Routes {
+				routes[i] = newRouteInfo(r)
+			}
+			if output.WantsFormatted(cmd) {
+				output.Object(ctx, routes, false)
+				return nil
+			}
+			out := cmd.OutOrStdout()
+			if len(routes) == 0 {
+				fmt.Fprintln(out, "No routes")
+				return nil
+			}
+			snLen := len("SUBNET")
+			for _, r := range routes {
+				if l := len(r.Subnet); l > snLen {
+					snLen = l
+				}
+			}
+			fmt.Fprintf(out, "%-*s  %-11s  %s\n", snLen, "SUBNET", "SOURCE", "INTERFACE")
+			for _, r := range routes {
+				iface := r.Interface
+				if r.Gateway != "" {
+					iface += " via " + r.Gateway
+				}
+				if !r.Installed {
+					iface += " (not installed)"
+				}
+				fmt.Fprintf(out, "%-*s  %-11s  %s\n", snLen, r.Subnet, r.Source, iface)
+			}
+			return nil
+		},
+	}
+}
+
+type routeCheckResult struct {
+	IP          string     `json:"ip" yaml:"ip"`
+	Route       *routeInfo `json:"route,omitempty" yaml:"route,omitempty"`
+	OSInterface string     `json:"os_interface,omitempty" yaml:"os_interface,omitempty"`
+	OSGateway   string     `json:"os_gateway,omitempty" yaml:"os_gateway,omitempty"`
+	ToCluster   bool       `json:"to_cluster" yaml:"to_cluster"`
+	Explanation string     `json:"explanation" yaml:"explanation"`
+}
+
+func routeCheck() *cobra.Command {
+	return &cobra.Command{
+		Use:   "check <address or host name>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Explain how traffic to an address is routed",
+		Long: `Explain how traffic to an address is routed.
+
+A host name is resolved first, using the DNS resolver of the workstation, so that names in
+the cluster are resolved by Telepresence.`,
+		Annotations: map[string]string{
+			ann.Session: ann.Required,
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := connect.InitCommand(cmd); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			ips := []net.IP{net.ParseIP(args[0])}
+			if ips[0] == nil {
+				var err error
+				if ips, err = net.DefaultResolver.LookupIP(ctx, "ip", args[0]); err != nil {
+					return errcat.User.Newf("%q is neither an IP address nor a resolvable host name: %w", args[0], err)
+				}
+			}
+			results := make([]*routeCheckResult, len(ips))
+			for i, ip := range ips {
+				if ip4 := ip.To4(); ip4 != nil {
+					ip = ip4
+				}
+				rsp, err := daemon.GetUserClient(ctx).CheckRoute(ctx, &daemonRpc.CheckRouteRequest{Ip: ip})
+				if err != nil {
+					return err
+				}
+				r := &routeCheckResult{
+					IP:          ip.String(),
+					OSInterface: rsp.OsInterface,
+					ToCluster:   rsp.ToCluster,
+					Explanation: rsp.Explanation,
+				}
+				if rsp.Route != nil {
+					r.Route = newRouteInfo(rsp.Route)
+				}
+				if len(rsp.OsGateway) > 0 {
+					r.OSGateway = net.IP(rsp.OsGateway).String()
+				}
+				results[i] = r
+			}
+			if output.WantsFormatted(cmd) {
+				output.Object(ctx, results, false)
+				return nil
+			}
+			for _, r := range results {
+				fmt.Fprintln(cmd.OutOrStdout(), r.Explanation)
+			}
+			return nil
+		},
+	}
+}
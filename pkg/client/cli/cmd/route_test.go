@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	daemonRpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+)
+
+func TestNewRouteInfo(t *testing.T) {
+	_, sn, err := net.ParseCIDR("10.244.5.0/24")
+	require.NoError(t, err)
+	ri := newRouteInfo(&daemonRpc.Route{
+		Subnet:    iputil.IPNetToRPC(sn),
+		Source:    daemonRpc.Route_NEVER_PROXY,
+		Interface: "eth0",
+		Gateway:   net.IP{10, 0, 0, 1},
+	})
+	data, err := json.Marshal(ri)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"subnet":"10.244.5.0/24","source":"never-proxy","interface":"eth0","gateway":"10.0.0.1","installed":false}`, string(data))
+
+	ri = newRouteInfo(&daemonRpc.Route{Subnet: iputil.IPNetToRPC(sn), Source: daemonRpc.Route_ALSO_PROXY, Installed: true})
+	data, err = json.Marshal(ri)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"subnet":"10.244.5.0/24","source":"also-proxy","installed":true}`, string(data))
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/intercept"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+type sshCommand struct {
+	container string
+	namespace string
+}
+
+func sshCmd() *cobra.Command {
+	sc := &sshCommand{}
+	cmd := &cobra.Command{
+		Use:   "ssh <workload> [flags] [-- <command> [args...]]",
+		Args:  cobra.MinimumNArgs(1),
+		Short: "Open a shell in a container of a workload",
+		Long: `Open a shell in a container of a workload, or run the given command there.
+
+The workload must have a traffic-agent. The command runs in one of the workload's pods, and is
+executed by the traffic-manager. It must be enabled using the exec.enabled Helm value, the
+exec.policy Helm value must permit it, and your Kubernetes user must be permitted to exec into
+the pod.
+
+The exit code of the command becomes the exit code of telepresence.`,
+		Annotations: map[string]string{
+			ann.Session: ann.Required,
+		},
+		RunE:              sc.run,
+		ValidArgsFunction: intercept.CompleteWorkloads(connector.ListRequest_INSTALLED_AGENTS),
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&sc.container, "container", "", "The name of the container. Defaults to the first container that isn't the traffic-agent")
+	flags.StringVarP(&sc.namespace, "namespace", "n", "", "The namespace of the workload")
+	return cmd
+}
+
+func (sc *sshCommand) run(cmd *cobra.Command, args []string) error {
+	if err := connect.InitCommand(cmd); err != nil {
+		return err
+	}
+	ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	command := args[1:]
+	if len(command) == 0 {
+		command = []string{"sh"}
+	}
+	ud := daemon.GetUserClient(ctx)
+	wi, err := findAgentWorkload(ctx, ud, args[0], sc.namespace)
+	if err != nil {
+		return err
+	}
+
+	stdinFd := int(os.Stdin.Fd())
+	tty := term.IsTerminal(stdinFd) && term.IsTerminal(int(os.Stdout.Fd()))
+	rq := &manager.ExecRequest{
+		Name:      wi.Name,
+		Namespace: wi.Namespace,
+		Container: sc.container,
+		Command:   command,
+		Tty:       tty,
+	}
+	if tty {
+		rq.Resize = terminalSize(stdinFd)
+		oldState, err := term.MakeRaw(stdinFd)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = term.Restore(stdinFd, oldState)
+		}()
+	}
+
+	stream, err := ud.Exec(ctx)
+	if err != nil {
+		return err
+	}
+	sender := &execSender{stream: stream}
+	if err = sender.send(rq); err != nil {
+		return execError(ctx, err)
+	}
+	go sender.sendStdin(os.Stdin)
+	if tty {
+		go func() {
+			for range notifyResize(ctx) {
+				if err := sender.send(&manager.ExecRequest{Resize: terminalSize(stdinFd)}); err != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	for {
+		rs, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return execError(ctx, err)
+		}
+		if len(rs.Stdout) > 0 {
+			_, _ = os.Stdout.Write(rs.Stdout)
+		}
+		if len(rs.Stderr) > 0 {
+			_, _ = os.Stderr.Write(rs.Stderr)
+		}
+		if rs.Done {
+			if rs.ExitCode != 0 {
+				return errcat.ExitCode(int(rs.ExitCode))
+			}
+			return nil
+		}
+	}
+}
+
+// execError turns errors that are caused by the request or by the traffic-manager's configuration into
+// user errors.
+func execError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	if st, ok := status.FromError(err); ok {
+		switch st.Code() {
+		case codes.InvalidArgument, codes.NotFound, codes.PermissionDenied, codes.FailedPrecondition, codes.DeadlineExceeded:
+			return errcat.User.New(st.Message())
+		}
+	}
+	return err
+}
+
+func terminalSize(fd int) *manager.TerminalSize {
+	w, h, err := term.GetSize(fd)
+	if err != nil {
+		return nil
+	}
+	return &manager.TerminalSize{Width: uint32(w), Height: uint32(h)}
+}
+
+// execSender serializes the requests that are sent on behalf of stdin and terminal resizes.
+type execSender struct {
+	sync.Mutex
+	stream connector.Connector_ExecClient
+}
+
+func (s *execSender) send(rq *manager.ExecRequest) error {
+	s.Lock()
+	defer s.Unlock()
+	return s.stream.Send(rq)
+}
+
+// sendStdin sends what's read from the given reader until it's exhausted.
+func (s *execSender) sendStdin(r io.Reader) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if s.send(&manager.ExecRequest{Stdin: append([]byte(nil), buf[:n]...)}) != nil {
+				return
+			}
+		}
+		if err != nil {
+			_ = s.send(&manager.ExecRequest{StdinClosed: true})
+			return
+		}
+	}
+}
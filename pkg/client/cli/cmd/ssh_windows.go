@@ -0,0 +1,12 @@
+package cmd
+
+import (
+	"context"
+	"os"
+)
+
+// notifyResize returns nil, because Windows has no signal that tells when the size of the console changes.
+// The remote terminal keeps its initial size.
+func notifyResize(context.Context) <-chan os.Signal {
+	return nil
+}
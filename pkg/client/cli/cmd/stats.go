@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/dashboard"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+func statsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show statistics for the traffic that passes through the tunnel and the intercepts",
+		Args:  OnlySubcommands,
+		RunE:  RunSubcommands,
+	}
+	cmd.AddCommand(statsTop(), statsIntercept())
+	return cmd
+}
+
+const (
+	sortByBytes       = "bytes"
+	sortByRate        = "rate"
+	sortByConnections = "connections"
+)
+
+type statsTopCommand struct {
+	limit    int
+	sortBy   string
+	interval time.Duration
+	once     bool
+}
+
+// destinationInfo is the formatted output of one destination.
+type destinationInfo struct {
+	Protocol    string    `json:"protocol" yaml:"protocol"`
+	Address     string    `json:"address" yaml:"address"`
+	Name        string    `json:"name,omitempty" yaml:"name,omitempty"`
+	Connections uint64    `json:"connections" yaml:"connections"`
+	Egress      uint64    `json:"egress_bytes" yaml:"egress_bytes"`
+	Ingress     uint64    `json:"ingress_bytes" yaml:"ingress_bytes"`
+	LastActive  time.Time `json:"last_active" yaml:"last_active"`
+	rate        float64
+}
+
+func statsTop() *cobra.Command {
+	sc := statsTopCommand{}
+	cmd := &cobra.Command{
+		Use:   "top",
+		Args:  cobra.NoArgs,
+		Short: "Show the destinations in the cluster that receive the most traffic through the tunnel",
+		Long: `Show the destinations in the cluster that receive the most traffic through the tunnel.
+
+Each destination is a protocol, address, and port, along with the name that the address was
+resolved from when Telepresence's DNS resolver knows it. The list is refreshed until the command
+is interrupted when the output is a terminal, and printed once otherwise.`,
+		Annotations: map[string]string{
+			ann.Session: ann.Required,
+		},
+		RunE: sc.run,
+	}
+	flags := cmd.Flags()
+	flags.IntVarP(&sc.limit, "limit", "n", 10, "Maximum number of destinations to show. Zero shows all")
+	flags.StringVar(&sc.sortBy, "sort", sortByBytes,
+		fmt.Sprintf("Sort order: %q for total bytes, %q for bytes per second, or %q", sortByBytes, sortByRate, sortByConnections))
+	flags.DurationVar(&sc.interval, "interval", 2*time.Second, "How often the list is refreshed")
+	flags.BoolVar(&sc.once, "once", false, "Print the list once instead of refreshing it")
+	return cmd
+}
+
+func (sc *statsTopCommand) run(cmd *cobra.Command, _ []string) error {
+	switch sc.sortBy {
+	case sortByBytes, sortByRate, sortByConnections:
+	default:
+		return errcat.User.Newf("invalid --sort %q. Valid values are %q, %q, or %q", sc.sortBy, sortByBytes, sortByRate, sortByConnections)
+	}
+	if sc.interval <= 0 {
+		return errcat.User.New("--interval must be a positive duration")
+	}
+	if err := connect.InitCommand(cmd); err != nil {
+		return err
+	}
+	ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	out := cmd.OutOrStdout()
+	refresh := !(sc.once || output.WantsFormatted(cmd))
+	if refresh {
+		f, ok := out.(*os.File)
+		refresh = ok && term.IsTerminal(int(f.Fd()))
+	}
+
+	var prev map[string]*destinationInfo
+	var prevTime time.Time
+	ticker := time.NewTicker(sc.interval)
+	defer ticker.Stop()
+	for {
+		dis, now, err := getDestinationInfos(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if prev != nil {
+			secs := now.Sub(prevTime).Seconds()
+			for key, di := range dis {
+				bytes := di.Egress + di.Ingress
+				if p, ok := prev[key]; ok {
+					if pb := p.Egress + p.Ingress; pb <= bytes {
+						bytes -= pb
+					}
+				}
+				di.rate = float64(bytes) / secs
+			}
+		}
+		top := sc.sortAndLimit(dis)
+		if !refresh {
+			if output.WantsFormatted(cmd) {
+				output.Object(ctx, top, false)
+			} else {
+				printDestinations(out, top, false)
+			}
+			return nil
+		}
+		// Clear the screen and move the cursor to its top left corner.
+		fmt.Fprint(out, "\033[H\033[2J")
+		printDestinations(out, top, prev != nil)
+		prev, prevTime = dis, now
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func getDestinationInfos(ctx context.Context) (map[string]*destinationInfo, time.Time, error) {
+	fs, err := daemon.GetUserClient(ctx).GetFlowStats(ctx, &empty.Empty{})
+	now := time.Now()
+	if err != nil {
+		return nil, now, err
+	}
+	dis := make(map[string]*destinationInfo, len(fs.Destinations))
+	for _, ds := range fs.Destinations {
+		di := &destinationInfo{
+			Protocol:    ds.Protocol,
+			Address:     net.JoinHostPort(net.IP(ds.Ip).String(), strconv.Itoa(int(ds.Port))),
+			Name:        ds.Name,
+			Connections: ds.Connections,
+			Egress:      ds.EgressBytes,
+			Ingress:     ds.IngressBytes,
+			LastActive:  ds.LastActive.AsTime(),
+		}
+		dis[di.Protocol+"/"+di.Address] = di
+	}
+	return dis, now, nil
+}
+
+func (sc *statsTopCommand) sortAndLimit(dis map[string]*destinationInfo) []*destinationInfo {
+	top := make([]*destinationInfo, 0, len(dis))
+	for _, di := range dis {
+		top = append(top, di)
+	}
+	sort.Slice(top, func(i, j int) bool {
+		a, b := top[i], top[j]
+		switch sc.sortBy {
+		case sortByRate:
+			if a.rate != b.rate {
+				return a.rate > b.rate
+			}
+		case sortByConnections:
+			if a.Connections != b.Connections {
+				return a.Connections > b.Connections
+			}
+		}
+		if ab, bb := a.Egress+a.Ingress, b.Egress+b.Ingress; ab != bb {
+			return ab > bb
+		}
+		return a.Address < b.Address
+	})
+	if sc.limit > 0 && len(top) > sc.limit {
+		top = top[:sc.limit]
+	}
+	return top
+}
+
+func printDestinations(out io.Writer, dis []*destinationInfo, withRate bool) {
+	if len(dis) == 0 {
+		fmt.Fprintln(out, "No traffic has passed through the tunnel")
+		return
+	}
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprint(tw, "DESTINATION\tNAME\tCONNECTIONS\tSENT\tRECEIVED\t")
+	if withRate {
+		fmt.Fprint(tw, "RATE\t")
+	}
+	fmt.Fprintln(tw, "LAST ACTIVE")
+	now := time.Now()
+	for _, di := range dis {
+		fmt.Fprintf(tw, "%s/%s\t%s\t%d\t%s\t%s\t", di.Protocol, di.Address, di.Name, di.Connections,
+			dashboard.FormatBytes(float64(di.Egress)), dashboard.FormatBytes(float64(di.Ingress)))
+		if withRate {
+			fmt.Fprintf(tw, "%s/s\t", dashboard.FormatBytes(di.rate))
+		}
+		fmt.Fprintf(tw, "%s ago\n", now.Sub(di.LastActive).Truncate(time.Second))
+	}
+	_ = tw.Flush()
+}
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/intercept"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/ioutil"
+)
+
+// latencyBarWidth is the width of the bar that represents the largest bucket of a latency histogram.
+const latencyBarWidth = 40
+
+// latencyBucket is the formatted output of one bucket of a latency histogram. The bound is zero for the
+// last bucket, which counts the latencies that exceed all bounds.
+type latencyBucket struct {
+	Bound time.Duration `json:"le,omitempty" yaml:"le,omitempty"`
+	Count uint64        `json:"count" yaml:"count"`
+}
+
+// interceptStats is the formatted output of the metrics of an intercept.
+type interceptStats struct {
+	Name        string          `json:"name" yaml:"name"`
+	Agents      int32           `json:"agents" yaml:"agents"`
+	Requests    uint64          `json:"requests" yaml:"requests"`
+	Errors      uint64          `json:"errors" yaml:"errors"`
+	LastRequest *time.Time      `json:"last_request,omitempty" yaml:"last_request,omitempty"`
+	LatencySum  time.Duration   `json:"latency_sum" yaml:"latency_sum"`
+	Latencies   []latencyBucket `json:"latencies,omitempty" yaml:"latencies,omitempty"`
+}
+
+func statsIntercept() *cobra.Command {
+	return &cobra.Command{
+		Use:   "intercept <name>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Show the requests, errors, and latencies of an intercept",
+		Long: `Show the requests, errors, and latencies of an intercept, as recorded by the traffic-agents that serve it.
+
+Each connection that a traffic-agent routes to this workstation counts as one request. A request is an error
+when it can't be routed to the workstation, or when it's closed before the workstation responds. The latency
+is the time from the first byte of the request until the first byte of the response, so it includes the
+roundtrip between the cluster and the workstation. Latencies aren't recorded for UDP.`,
+		Annotations: map[string]string{
+			ann.Session: ann.Required,
+		},
+		ValidArgsFunction: intercept.CompleteIntercepts,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := connect.InitCommand(cmd); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			m, err := daemon.GetUserClient(ctx).GetInterceptMetrics(ctx, &manager.GetInterceptRequest{Name: args[0]})
+			if err != nil {
+				return err
+			}
+			is := newInterceptStats(args[0], m)
+			if output.WantsFormatted(cmd) {
+				output.Object(ctx, is, false)
+			} else {
+				is.print(cmd.OutOrStdout(), time.Now())
+			}
+			return nil
+		},
+	}
+}
+
+func newInterceptStats(name string, m *manager.InterceptMetrics) *interceptStats {
+	is := &interceptStats{
+		Name:       name,
+		Agents:     m.Agents,
+		Requests:   m.Requests,
+		Errors:     m.Errors,
+		LatencySum: m.LatencySum.AsDuration(),
+	}
+	if m.LastRequest != nil {
+		lr := m.LastRequest.AsTime()
+		is.LastRequest = &lr
+	}
+	if len(m.LatencyCounts) == len(m.LatencyBounds)+1 {
+		is.Latencies = make([]latencyBucket, len(m.LatencyCounts))
+		for i, c := range m.LatencyCounts {
+			is.Latencies[i].Count = c
+			if i < len(m.LatencyBounds) {
+				is.Latencies[i].Bound = m.LatencyBounds[i].AsDuration()
+			}
+		}
+	}
+	return is
+}
+
+// latencyCount returns the number of latencies in the histogram.
+func (is *interceptStats) latencyCount() uint64 {
+	n := uint64(0)
+	for _, b := range is.Latencies {
+		n += b.Count
+	}
+	return n
+}
+
+// percentile returns the bucket that the given percentile of the latencies falls into. The histogram
+// must not be empty.
+func (is *interceptStats) percentile(p float64) latencyBucket {
+	rank := uint64(math.Ceil(p * float64(is.latencyCount()) / 100))
+	seen := uint64(0)
+	for _, b := range is.Latencies {
+		seen += b.Count
+		if seen >= rank {
+			return b
+		}
+	}
+	return is.Latencies[len(is.Latencies)-1]
+}
+
+// bucketLabel returns "<= bound" for a bucket, or "> bound" for the last bucket, where the bound is the one of
+// the previous bucket.
+func (is *interceptStats) bucketLabel(b latencyBucket) string {
+	if b.Bound > 0 {
+		return "<= " + b.Bound.String()
+	}
+	if l := len(is.Latencies); l > 1 {
+		return "> " + is.Latencies[l-2].Bound.String()
+	}
+	return "any"
+}
+
+func (is *interceptStats) print(out io.Writer, now time.Time) {
+	kvf := ioutil.DefaultKeyValueFormatter()
+	kvf.Add("Intercept", is.Name)
+	kvf.Add("Agents", fmt.Sprintf("%d", is.Agents))
+	kvf.Add("Requests", fmt.Sprintf("%d", is.Requests))
+	errs := fmt.Sprintf("%d", is.Errors)
+	if is.Requests > 0 {
+		errs += fmt.Sprintf(" (%.1f%%)", 100*float64(is.Errors)/float64(is.Requests))
+	}
+	kvf.Add("Errors", errs)
+	if is.LastRequest != nil {
+		kvf.Add("Last request", fmt.Sprintf("%s ago", now.Sub(*is.LastRequest).Truncate(time.Second)))
+	} else {
+		kvf.Add("Last request", "never")
+	}
+	n := is.latencyCount()
+	if n > 0 {
+		lat := fmt.Sprintf("mean %s", (is.LatencySum / time.Duration(n)).Round(time.Microsecond))
+		for _, p := range []float64{50, 90, 99} {
+			lat += fmt.Sprintf(", p%g %s", p, is.bucketLabel(is.percentile(p)))
+		}
+		kvf.Add("Latency", lat)
+	}
+	kvf.Println(out)
+	if n == 0 {
+		return
+	}
+
+	largest := uint64(0)
+	for _, b := range is.Latencies {
+		if b.Count > largest {
+			largest = b.Count
+		}
+	}
+	fmt.Fprintln(out)
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "LATENCY\tREQUESTS\t")
+	for _, b := range is.Latencies {
+		bar := strings.Repeat("#", int(b.Count*latencyBarWidth/largest))
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", is.bucketLabel(b), b.Count, bar)
+	}
+	_ = tw.Flush()
+}
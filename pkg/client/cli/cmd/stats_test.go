@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+func TestStatsTop_sortAndLimit(t *testing.T) {
+	dis := map[string]*destinationInfo{
+		"a": {Address: "10.0.0.1:80", Connections: 1, Egress: 100, Ingress: 900, rate: 10},
+		"b": {Address: "10.0.0.2:80", Connections: 5, Egress: 10, Ingress: 10, rate: 500},
+		"c": {Address: "10.0.0.3:80", Connections: 2, Egress: 300, Ingress: 300, rate: 0},
+	}
+	addresses := func(top []*destinationInfo) []string {
+		as := make([]string, len(top))
+		for i, di := range top {
+			as[i] = di.Address
+		}
+		return as
+	}
+
+	sc := statsTopCommand{sortBy: sortByBytes}
+	assert.Equal(t, []string{"10.0.0.1:80", "10.0.0.3:80", "10.0.0.2:80"}, addresses(sc.sortAndLimit(dis)))
+
+	sc.sortBy = sortByRate
+	assert.Equal(t, []string{"10.0.0.2:80", "10.0.0.1:80", "10.0.0.3:80"}, addresses(sc.sortAndLimit(dis)))
+
+	sc.sortBy = sortByConnections
+	sc.limit = 2
+	assert.Equal(t, []string{"10.0.0.2:80", "10.0.0.3:80"}, addresses(sc.sortAndLimit(dis)))
+}
+
+func TestStatsTop_printDestinations(t *testing.T) {
+	out := &bytes.Buffer{}
+	printDestinations(out, nil, false)
+	assert.Equal(t, "No traffic has passed through the tunnel\n", out.String())
+
+	out.Reset()
+	printDestinations(out, []*destinationInfo{{Protocol: "tcp", Address: "10.0.0.1:80", Name: "echo.default", Connections: 3, Egress: 2048}}, true)
+	assert.Contains(t, out.String(), "RATE")
+	assert.Contains(t, out.String(), "tcp/10.0.0.1:80  echo.default  3")
+	assert.Contains(t, out.String(), "2.0 KiB")
+}
+
+func TestStatsIntercept_print(t *testing.T) {
+	now := time.Now()
+	lastRequest := now.Add(-3 * time.Second)
+	is := newInterceptStats("echo", &manager.InterceptMetrics{
+		Agents:        1,
+		Requests:      20,
+		Errors:        1,
+		LatencyBounds: []*durationpb.Duration{durationpb.New(10 * time.Millisecond), durationpb.New(100 * time.Millisecond)},
+		LatencyCounts: []uint64{10, 8, 1},
+		LatencySum:    durationpb.New(950 * time.Millisecond),
+		LastRequest:   timestamppb.New(lastRequest),
+	})
+	assert.Equal(t, 10*time.Millisecond, is.percentile(50).Bound)
+	assert.Equal(t, 100*time.Millisecond, is.percentile(90).Bound)
+	assert.Equal(t, time.Duration(0), is.percentile(99).Bound)
+
+	out := &bytes.Buffer{}
+	is.print(out, now)
+	assert.Contains(t, out.String(), "Errors      : 1 (5.0%)")
+	assert.Contains(t, out.String(), "Last request: 3s ago")
+	assert.Contains(t, out.String(), "Latency     : mean 50ms, p50 <= 10ms, p90 <= 100ms, p99 > 100ms")
+	assert.Contains(t, out.String(), "<= 10ms   10        ########################################")
+	assert.Contains(t, out.String(), "> 100ms   1         ####")
+
+	out.Reset()
+	is = newInterceptStats("echo", &manager.InterceptMetrics{})
+	is.print(out, now)
+	assert.Contains(t, out.String(), "Last request: never")
+	assert.NotContains(t, out.String(), "LATENCY")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -17,18 +18,90 @@ import (
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/global"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/scout"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
 	"github.com/telepresenceio/telepresence/v2/pkg/ioutil"
 	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
 )
 
 type StatusInfo struct {
-	RootDaemon io.WriterTo `json:"root_daemon" yaml:"root_daemon"`
-	UserDaemon io.WriterTo `json:"user_daemon" yaml:"user_daemon"`
+	RootDaemon io.WriterTo   `json:"root_daemon" yaml:"root_daemon"`
+	UserDaemon io.WriterTo   `json:"user_daemon" yaml:"user_daemon"`
+	Health     *StatusHealth `json:"health,omitempty" yaml:"health,omitempty"`
 }
 
 type StatusInfoEmbedded struct {
-	RootDaemon io.WriterTo `json:"root_daemon" yaml:"root_daemon"`
-	UserDaemon io.WriterTo `json:"user_daemon" yaml:"user_daemon"`
+	RootDaemon io.WriterTo   `json:"root_daemon" yaml:"root_daemon"`
+	UserDaemon io.WriterTo   `json:"user_daemon" yaml:"user_daemon"`
+	Health     *StatusHealth `json:"health,omitempty" yaml:"health,omitempty"`
+}
+
+// HealthStatus is the overall health of the connection.
+type HealthStatus string
+
+const (
+	HealthOK       HealthStatus = "OK"
+	HealthDegraded HealthStatus = "DEGRADED"
+	HealthError    HealthStatus = "ERROR"
+)
+
+// HealthClass is the class of failure that caused a status other than HealthOK. Each class has its
+// own exit code when the status command is used with --exit-code.
+type HealthClass string
+
+const (
+	HealthClassNone       HealthClass = ""
+	HealthClassNoDaemon   HealthClass = "no_daemon"
+	HealthClassNoSession  HealthClass = "no_session"
+	HealthClassDNSBroken  HealthClass = "dns_broken"
+	HealthClassTunnelDown HealthClass = "tunnel_down"
+)
+
+// ExitCode returns the exit code used for the class. Exit code 1 is reserved for errors that prevent
+// the status from being retrieved at all.
+func (c HealthClass) ExitCode() int {
+	switch c {
+	case HealthClassNone:
+		return 0
+	case HealthClassNoDaemon:
+		return 2
+	case HealthClassNoSession:
+		return 3
+	case HealthClassDNSBroken:
+		return 4
+	case HealthClassTunnelDown:
+		return 5
+	default:
+		return 1
+	}
+}
+
+type StatusHealth struct {
+	Status HealthStatus `json:"status" yaml:"status"`
+	Class  HealthClass  `json:"class,omitempty" yaml:"class,omitempty"`
+	Reason string       `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+func (h *StatusHealth) WriteTo(out io.Writer) (int64, error) {
+	var n int
+	if h.Reason != "" {
+		n = ioutil.Printf(out, "Health: %s (%s)\n", h.Status, h.Reason)
+	} else {
+		n = ioutil.Printf(out, "Health: %s\n", h.Status)
+	}
+	return int64(n), nil
+}
+
+// healthReporter is implemented by status info that carries a health assessment.
+type healthReporter interface {
+	GetHealth() *StatusHealth
+}
+
+func (s *StatusInfo) GetHealth() *StatusHealth {
+	return s.Health
+}
+
+func (s *StatusInfoEmbedded) GetHealth() *StatusHealth {
+	return s.Health
 }
 
 type rootDaemonStatus struct {
@@ -36,26 +109,52 @@ type rootDaemonStatus struct {
 	Name                 string           `json:"name,omitempty" yaml:"name,omitempty"`
 	Version              string           `json:"version,omitempty" yaml:"version,omitempty"`
 	APIVersion           int32            `json:"api_version,omitempty" yaml:"api_version,omitempty"`
+	Platform             string           `json:"platform,omitempty" yaml:"platform,omitempty"`
 	DNS                  *client.DNSSnake `json:"dns,omitempty" yaml:"dns,omitempty"`
 	*client.RoutingSnake `yaml:",inline"`
 }
 
 type userDaemonStatus struct {
-	Running           bool                     `json:"running,omitempty" yaml:"running,omitempty"`
-	Name              string                   `json:"name,omitempty" yaml:"name,omitempty"`
-	Version           string                   `json:"version,omitempty" yaml:"version,omitempty"`
-	APIVersion        int32                    `json:"api_version,omitempty" yaml:"api_version,omitempty"`
-	Executable        string                   `json:"executable,omitempty" yaml:"executable,omitempty"`
-	InstallID         string                   `json:"install_id,omitempty" yaml:"install_id,omitempty"`
-	Status            string                   `json:"status,omitempty" yaml:"status,omitempty"`
-	Error             string                   `json:"error,omitempty" yaml:"error,omitempty"`
-	KubernetesServer  string                   `json:"kubernetes_server,omitempty" yaml:"kubernetes_server,omitempty"`
-	KubernetesContext string                   `json:"kubernetes_context,omitempty" yaml:"kubernetes_context,omitempty"`
-	ConnectionName    string                   `json:"connection_name,omitempty" yaml:"connection_name,omitempty"`
-	Namespace         string                   `json:"namespace,omitempty" yaml:"namespace,omitempty"`
-	ManagerNamespace  string                   `json:"manager_namespace,omitempty" yaml:"manager_namespace,omitempty"`
-	MappedNamespaces  []string                 `json:"mapped_namespaces,omitempty" yaml:"mapped_namespaces,omitempty"`
-	Intercepts        []connectStatusIntercept `json:"intercepts,omitempty" yaml:"intercepts,omitempty"`
+	Running                 bool                     `json:"running,omitempty" yaml:"running,omitempty"`
+	Name                    string                   `json:"name,omitempty" yaml:"name,omitempty"`
+	Version                 string                   `json:"version,omitempty" yaml:"version,omitempty"`
+	APIVersion              int32                    `json:"api_version,omitempty" yaml:"api_version,omitempty"`
+	Executable              string                   `json:"executable,omitempty" yaml:"executable,omitempty"`
+	Platform                string                   `json:"platform,omitempty" yaml:"platform,omitempty"`
+	UnavailableFeatures     map[string]string        `json:"unavailable_features,omitempty" yaml:"unavailable_features,omitempty"`
+	Paths                   *daemonPaths             `json:"paths,omitempty" yaml:"paths,omitempty"`
+	InstallID               string                   `json:"install_id,omitempty" yaml:"install_id,omitempty"`
+	Status                  string                   `json:"status,omitempty" yaml:"status,omitempty"`
+	Error                   string                   `json:"error,omitempty" yaml:"error,omitempty"`
+	KubernetesServer        string                   `json:"kubernetes_server,omitempty" yaml:"kubernetes_server,omitempty"`
+	KubernetesContext       string                   `json:"kubernetes_context,omitempty" yaml:"kubernetes_context,omitempty"`
+	ConnectionName          string                   `json:"connection_name,omitempty" yaml:"connection_name,omitempty"`
+	Namespace               string                   `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	ManagerNamespace        string                   `json:"manager_namespace,omitempty" yaml:"manager_namespace,omitempty"`
+	MappedNamespaces        []string                 `json:"mapped_namespaces,omitempty" yaml:"mapped_namespaces,omitempty"`
+	MappedNamespaceSelector string                   `json:"mapped_namespace_selector,omitempty" yaml:"mapped_namespace_selector,omitempty"`
+	Observe                 bool                     `json:"observe,omitempty" yaml:"observe,omitempty"`
+	NoRouting               bool                     `json:"no_routing,omitempty" yaml:"no_routing,omitempty"`
+	Intercepts              []connectStatusIntercept `json:"intercepts,omitempty" yaml:"intercepts,omitempty"`
+	TakeOverRequests        []connectStatusIntercept `json:"take_over_requests,omitempty" yaml:"take_over_requests,omitempty"`
+	ResourceWarnings        []string                 `json:"resource_warnings,omitempty" yaml:"resource_warnings,omitempty"`
+	RetryCounters           []retryCounters          `json:"retry_counters,omitempty" yaml:"retry_counters,omitempty"`
+}
+
+type retryCounters struct {
+	Operation string `json:"operation" yaml:"operation"`
+	Calls     uint64 `json:"calls" yaml:"calls"`
+	Retries   uint64 `json:"retries" yaml:"retries"`
+	Failures  uint64 `json:"failures" yaml:"failures"`
+	LastError string `json:"last_error,omitempty" yaml:"last_error,omitempty"`
+}
+
+type daemonPaths struct {
+	ConfigDir        string `json:"config_dir,omitempty" yaml:"config_dir,omitempty"`
+	CacheDir         string `json:"cache_dir,omitempty" yaml:"cache_dir,omitempty"`
+	LogDir           string `json:"log_dir,omitempty" yaml:"log_dir,omitempty"`
+	UserDaemonSocket string `json:"user_daemon_socket,omitempty" yaml:"user_daemon_socket,omitempty"`
+	RootDaemonSocket string `json:"root_daemon_socket,omitempty" yaml:"root_daemon_socket,omitempty"`
 }
 
 type connectStatusIntercept struct {
@@ -79,6 +178,10 @@ func statusCmd() *cobra.Command {
 	flags := cmd.Flags()
 	flags.BoolP("json", "j", false, "output as json object")
 	flags.Lookup("json").Hidden = true
+	flags.Bool("porcelain", false, "print a stable, tab separated, summary of each connection that is read from a local cache "+
+		"instead of from the daemons. Intended for shell prompts and other scripts")
+	flags.Bool("exit-code", false, "exit with a code that reflects the health: "+
+		"0 = OK, 2 = no daemon, 3 = no session, 4 = DNS broken, 5 = tunnel down")
 	return cmd
 }
 
@@ -99,6 +202,9 @@ func fixFlag(cmd *cobra.Command, _ []string) error {
 
 // status will retrieve connectivity status from the daemon and print it on stdout.
 func run(cmd *cobra.Command, _ []string) error {
+	if porcelain, _ := cmd.Flags().GetBool("porcelain"); porcelain {
+		return printPorcelain(cmd)
+	}
 	if err := connect.InitCommand(cmd); err != nil {
 		return err
 	}
@@ -114,9 +220,63 @@ func run(cmd *cobra.Command, _ []string) error {
 	} else {
 		_, _ = ioutil.WriteAllTo(cmd.OutOrStdout(), si.WriterTos()...)
 	}
+	if exitCode, _ := cmd.Flags().GetBool("exit-code"); exitCode {
+		if hr, ok := si.(healthReporter); ok {
+			if h := hr.GetHealth(); h != nil && h.Class != HealthClassNone {
+				return errcat.ExitCode(h.Class.ExitCode())
+			}
+		}
+	}
+	return nil
+}
+
+// printPorcelain prints one line per connection in the format:
+//
+//	connected<TAB>name<TAB>kube-context<TAB>namespace<TAB>intercept-count
+//
+// or the single word "disconnected" when there are no connections. The information is read from a
+// cache that the user daemon keeps up to date, so no daemon is contacted.
+func printPorcelain(cmd *cobra.Command) error {
+	pis, err := daemon.LoadPromptInfos(cmd.Context())
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+	if len(pis) == 0 {
+		fmt.Fprintln(out, "disconnected")
+		return nil
+	}
+	for _, pi := range pis {
+		fmt.Fprintf(out, "connected\t%s\t%s\t%s\t%d\n", pi.Name, pi.KubeContext, pi.Namespace, pi.Intercepts)
+	}
 	return nil
 }
 
+// assessHealth determines the health of the connection based on the status of the daemons. The root daemon
+// and its network aren't assessed when the session has no routing of its own, i.e. when the daemon runs in a
+// container, or when the session was established with --no-routing.
+func assessHealth(rs *rootDaemonStatus, us *userDaemonStatus, remote bool, ci connector.ConnectInfo_ErrType) *StatusHealth {
+	switch {
+	case !us.Running:
+		return &StatusHealth{Status: HealthError, Class: HealthClassNoDaemon, Reason: "daemon is not running"}
+	case ci == connector.ConnectInfo_MUST_RESTART:
+		return &StatusHealth{Status: HealthDegraded, Class: HealthClassNoSession, Reason: "session must be restarted"}
+	case ci != connector.ConnectInfo_UNSPECIFIED && ci != connector.ConnectInfo_ALREADY_CONNECTED:
+		h := &StatusHealth{Status: HealthError, Class: HealthClassNoSession, Reason: strings.ToLower(us.Status)}
+		if us.Error != "" {
+			h.Reason += ": " + us.Error
+		}
+		return h
+	case !remote && !us.NoRouting && !rs.Running:
+		return &StatusHealth{Status: HealthError, Class: HealthClassTunnelDown, Reason: "root daemon is not running"}
+	case rs.Running && rs.DNS == nil:
+		return &StatusHealth{Status: HealthError, Class: HealthClassTunnelDown, Reason: "network is not configured"}
+	case rs.DNS != nil && rs.DNS.Error != "":
+		return &StatusHealth{Status: HealthDegraded, Class: HealthClassDNSBroken, Reason: "DNS: " + rs.DNS.Error}
+	}
+	return &StatusHealth{Status: HealthOK}
+}
+
 // GetStatusInfo may return an extended struct, based on the one returned by the BasicGetStatusInfo.
 var GetStatusInfo = BasicGetStatusInfo //nolint:gochecknoglobals // extension point
 
@@ -128,21 +288,25 @@ func BasicGetStatusInfo(ctx context.Context) (ioutil.WriterTos, error) {
 		return &StatusInfo{
 			RootDaemon: &rs,
 			UserDaemon: &us,
+			Health:     assessHealth(&rs, &us, false, connector.ConnectInfo_DISCONNECTED),
 		}, nil
 	}
 	var wt ioutil.WriterTos
+	var health **StatusHealth
 	if userD.Remote() {
 		sie := StatusInfoEmbedded{
 			RootDaemon: &rs,
 			UserDaemon: &us,
 		}
 		wt = &sie
+		health = &sie.Health
 	} else {
 		si := StatusInfo{
 			RootDaemon: &rs,
 			UserDaemon: &us,
 		}
 		wt = &si
+		health = &si.Health
 	}
 	ctx = scout.NewReporter(ctx, "cli")
 	us.InstallID = scout.InstallID(ctx)
@@ -162,6 +326,19 @@ func BasicGetStatusInfo(ctx context.Context) (ioutil.WriterTos, error) {
 	us.Version = version.Version
 	us.APIVersion = version.ApiVersion
 	us.Executable = version.Executable
+	us.Platform = version.Platform
+	us.UnavailableFeatures = version.UnavailableFeatures
+
+	// Daemons that predate the Paths call don't advertise their paths.
+	if ps, err := userD.Paths(ctx, &empty.Empty{}); err == nil {
+		us.Paths = &daemonPaths{
+			ConfigDir:        ps.ConfigDir,
+			CacheDir:         ps.CacheDir,
+			LogDir:           ps.LogDir,
+			UserDaemonSocket: ps.UserDaemonSocket,
+			RootDaemonSocket: ps.RootDaemonSocket,
+		}
+	}
 
 	status, err := userD.Status(ctx, &empty.Empty{})
 	if err != nil {
@@ -182,6 +359,25 @@ func BasicGetStatusInfo(ctx context.Context) (ioutil.WriterTos, error) {
 		us.Namespace = status.Namespace
 		us.ManagerNamespace = status.ManagerNamespace
 		us.MappedNamespaces = status.MappedNamespaces
+		us.MappedNamespaceSelector = status.MappedNamespaceSelector
+		us.Observe = status.Observe
+		us.NoRouting = status.NoRouting
+		for _, rq := range status.TakeOverRequests {
+			us.TakeOverRequests = append(us.TakeOverRequests, connectStatusIntercept{
+				Name:   takeOverInterceptName(rq),
+				Client: rq.Claimant,
+			})
+		}
+		us.ResourceWarnings = status.ResourceWarnings
+		for _, rc := range status.RetryCounters {
+			us.RetryCounters = append(us.RetryCounters, retryCounters{
+				Operation: rc.Operation,
+				Calls:     rc.Calls,
+				Retries:   rc.Retries,
+				Failures:  rc.Failures,
+				LastError: rc.LastError,
+			})
+		}
 	case connector.ConnectInfo_MUST_RESTART:
 		us.Status = "Connected, but must restart"
 	case connector.ConnectInfo_DISCONNECTED:
@@ -203,6 +399,7 @@ func BasicGetStatusInfo(ctx context.Context) (ioutil.WriterTos, error) {
 		}
 		rs.Version = rStatus.Version.Version
 		rs.APIVersion = rStatus.Version.ApiVersion
+		rs.Platform = rStatus.Version.Platform
 		if obc := rStatus.OutboundConfig; obc != nil {
 			rs.DNS = &client.DNSSnake{}
 			dns := obc.Dns
@@ -217,6 +414,7 @@ func BasicGetStatusInfo(ctx context.Context) (ioutil.WriterTos, error) {
 			rs.DNS.Excludes = dns.Excludes
 			rs.DNS.Mappings.FromRPC(dns.Mappings)
 			rs.DNS.LookupTimeout = dns.LookupTimeout.AsDuration()
+			rs.DNS.ClusterDomain = dns.ClusterDomain
 			rs.RoutingSnake = &client.RoutingSnake{}
 			for _, subnet := range obc.AlsoProxySubnets {
 				rs.RoutingSnake.AlsoProxy = append(rs.RoutingSnake.AlsoProxy, (*iputil.Subnet)(iputil.IPNetFromRPC(subnet)))
@@ -226,14 +424,21 @@ func BasicGetStatusInfo(ctx context.Context) (ioutil.WriterTos, error) {
 			}
 		}
 	}
+	*health = assessHealth(&rs, &us, userD.Remote(), status.Error)
 	return wt, nil
 }
 
 func (s *StatusInfo) WriterTos() []io.WriterTo {
+	if s.Health != nil {
+		return []io.WriterTo{s.UserDaemon, s.RootDaemon, s.Health}
+	}
 	return []io.WriterTo{s.UserDaemon, s.RootDaemon}
 }
 
 func (s *StatusInfoEmbedded) WriterTos() []io.WriterTo {
+	if s.Health != nil {
+		return []io.WriterTo{s, s.Health}
+	}
 	return []io.WriterTo{s}
 }
 
@@ -274,6 +479,9 @@ func (ds *rootDaemonStatus) WriteTo(out io.Writer) (int64, error) {
 
 func (ds *rootDaemonStatus) printNetwork(kvf *ioutil.KeyValueFormatter) {
 	kvf.Add("Version", ds.Version)
+	if ds.Platform != "" {
+		kvf.Add("Platform", ds.Platform)
+	}
 	if ds.DNS != nil {
 		printDNS(kvf, ds.DNS)
 	}
@@ -294,6 +502,9 @@ func printDNS(kvf *ioutil.KeyValueFormatter, d *client.DNSSnake) {
 	if len(d.RemoteIP) > 0 {
 		dnsKvf.Add("Remote IP", d.RemoteIP.String())
 	}
+	if d.ClusterDomain != "" {
+		dnsKvf.Add("Cluster domain", d.ClusterDomain)
+	}
 	dnsKvf.Add("Exclude suffixes", fmt.Sprintf("%v", d.ExcludeSuffixes))
 	dnsKvf.Add("Include suffixes", fmt.Sprintf("%v", d.IncludeSuffixes))
 	if len(d.Excludes) > 0 {
@@ -341,6 +552,36 @@ func (cs *userDaemonStatus) WriteTo(out io.Writer) (int64, error) {
 func (cs *userDaemonStatus) print(kvf *ioutil.KeyValueFormatter) {
 	kvf.Add("Version", cs.Version)
 	kvf.Add("Executable", cs.Executable)
+	if cs.Platform != "" {
+		kvf.Add("Platform", cs.Platform)
+	}
+	if len(cs.UnavailableFeatures) > 0 {
+		features := make([]string, 0, len(cs.UnavailableFeatures))
+		for f := range cs.UnavailableFeatures {
+			features = append(features, f)
+		}
+		sort.Strings(features)
+		subKvf := ioutil.DefaultKeyValueFormatter()
+		subKvf.Indent = "  "
+		for _, f := range features {
+			subKvf.Add(f, cs.UnavailableFeatures[f])
+		}
+		out := &strings.Builder{}
+		subKvf.Println(out)
+		kvf.Add("Unavailable features", out.String())
+	}
+	if ps := cs.Paths; ps != nil {
+		subKvf := ioutil.DefaultKeyValueFormatter()
+		subKvf.Indent = "  "
+		subKvf.Add("Config directory", ps.ConfigDir)
+		subKvf.Add("Cache directory", ps.CacheDir)
+		subKvf.Add("Log directory", ps.LogDir)
+		subKvf.Add("User daemon socket", ps.UserDaemonSocket)
+		subKvf.Add("Root daemon socket", ps.RootDaemonSocket)
+		out := &strings.Builder{}
+		subKvf.Println(out)
+		kvf.Add("Paths", out.String())
+	}
 	kvf.Add("Install ID", cs.InstallID)
 	kvf.Add("Status", cs.Status)
 	if cs.Error != "" {
@@ -354,6 +595,15 @@ func (cs *userDaemonStatus) print(kvf *ioutil.KeyValueFormatter) {
 	if len(cs.MappedNamespaces) > 0 {
 		kvf.Add("Mapped namespaces", fmt.Sprintf("%v", cs.MappedNamespaces))
 	}
+	if cs.MappedNamespaceSelector != "" {
+		kvf.Add("Mapped namespace selector", cs.MappedNamespaceSelector)
+	}
+	if cs.Observe {
+		kvf.Add("Mode", "observe (intercepts are refused)")
+	}
+	if cs.NoRouting {
+		kvf.Add("Routing", "none (connected with --no-routing)")
+	}
 	out := &strings.Builder{}
 	fmt.Fprintf(out, "%d total\n", len(cs.Intercepts))
 	if len(cs.Intercepts) > 0 {
@@ -365,4 +615,40 @@ func (cs *userDaemonStatus) print(kvf *ioutil.KeyValueFormatter) {
 		subKvf.Println(out)
 	}
 	kvf.Add("Intercepts", out.String())
+	if len(cs.TakeOverRequests) > 0 {
+		subKvf := ioutil.DefaultKeyValueFormatter()
+		subKvf.Indent = "  "
+		for _, rq := range cs.TakeOverRequests {
+			subKvf.Add(rq.Name, rq.Client)
+		}
+		out.Reset()
+		subKvf.Println(out)
+		kvf.Add("Take over requests", out.String())
+	}
+	if len(cs.ResourceWarnings) > 0 {
+		out.Reset()
+		for _, w := range cs.ResourceWarnings {
+			fmt.Fprintln(out, w)
+		}
+		kvf.Add("Resource warnings", out.String())
+	}
+	// Only operations that didn't succeed at once are worth mentioning in the human-readable output.
+	subKvf := ioutil.DefaultKeyValueFormatter()
+	subKvf.Indent = "  "
+	retried := false
+	for _, rc := range cs.RetryCounters {
+		if rc.Retries > 0 || rc.Failures > 0 {
+			retried = true
+			v := fmt.Sprintf("%d calls, %d retries, %d failures", rc.Calls, rc.Retries, rc.Failures)
+			if rc.LastError != "" {
+				v += ", last error: " + rc.LastError
+			}
+			subKvf.Add(rc.Operation, v)
+		}
+	}
+	if retried {
+		out.Reset()
+		subKvf.Println(out)
+		kvf.Add("Retries", out.String())
+	}
 }
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+)
+
+func TestAssessHealth(t *testing.T) {
+	connected := &userDaemonStatus{Running: true, Status: "Connected"}
+	tests := []struct {
+		name   string
+		rs     *rootDaemonStatus
+		us     *userDaemonStatus
+		remote bool
+		ci     connector.ConnectInfo_ErrType
+		status HealthStatus
+		class  HealthClass
+	}{
+		{
+			name:   "no daemon",
+			rs:     &rootDaemonStatus{},
+			us:     &userDaemonStatus{},
+			ci:     connector.ConnectInfo_DISCONNECTED,
+			status: HealthError,
+			class:  HealthClassNoDaemon,
+		},
+		{
+			name:   "not connected",
+			rs:     &rootDaemonStatus{},
+			us:     &userDaemonStatus{Running: true, Status: "Not connected"},
+			ci:     connector.ConnectInfo_DISCONNECTED,
+			status: HealthError,
+			class:  HealthClassNoSession,
+		},
+		{
+			name:   "no root daemon",
+			rs:     &rootDaemonStatus{},
+			us:     connected,
+			status: HealthError,
+			class:  HealthClassTunnelDown,
+		},
+		{
+			name:   "remote without root daemon",
+			rs:     &rootDaemonStatus{},
+			us:     connected,
+			remote: true,
+			status: HealthOK,
+		},
+		{
+			name:   "no routing",
+			rs:     &rootDaemonStatus{},
+			us:     &userDaemonStatus{Running: true, Status: "Connected", NoRouting: true},
+			status: HealthOK,
+		},
+		{
+			name:   "no routing, not connected",
+			rs:     &rootDaemonStatus{},
+			us:     &userDaemonStatus{Running: true, Status: "Not connected", NoRouting: true},
+			ci:     connector.ConnectInfo_DISCONNECTED,
+			status: HealthError,
+			class:  HealthClassNoSession,
+		},
+		{
+			name:   "network not configured",
+			rs:     &rootDaemonStatus{Running: true},
+			us:     connected,
+			status: HealthError,
+			class:  HealthClassTunnelDown,
+		},
+		{
+			name:   "DNS broken",
+			rs:     &rootDaemonStatus{Running: true, DNS: &client.DNSSnake{Error: "no route"}},
+			us:     connected,
+			status: HealthDegraded,
+			class:  HealthClassDNSBroken,
+		},
+		{
+			name:   "OK",
+			rs:     &rootDaemonStatus{Running: true, DNS: &client.DNSSnake{}},
+			us:     connected,
+			status: HealthOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := assessHealth(tt.rs, tt.us, tt.remote, tt.ci)
+			assert.Equal(t, tt.status, h.Status)
+			assert.Equal(t, tt.class, h.Class)
+		})
+	}
+	assert.Equal(t, 0, HealthClassNone.ExitCode())
+	assert.Equal(t, 4, HealthClassDNSBroken.ExitCode())
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/intercept"
+)
+
+func switchCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "switch <intercept_name> <handler>",
+		Args:  cobra.ExactArgs(2),
+		Short: "Route the traffic of an intercept to another one of its local handlers",
+		Long: `Route the traffic of an intercept to another one of the local handlers that it was created with, using
+"telepresence intercept --handler <name>=<port> --handler <name>=<port>". The switch is instant. Connections to the
+previously active handler are closed, so that their clients reconnect to the new one.`,
+		Annotations: map[string]string{
+			ann.Session: ann.Required,
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := connect.InitCommand(cmd); err != nil {
+				return err
+			}
+			ctx := cmd.Context()
+			name := strings.TrimSpace(args[0])
+			handler := strings.TrimSpace(args[1])
+			_, err := daemon.GetUserClient(ctx).SwitchInterceptHandler(ctx, &connector.SwitchInterceptHandlerRequest{
+				Name:    name,
+				Handler: handler,
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Intercept %s now routes to handler %s\n", name, handler)
+			return nil
+		},
+		ValidArgsFunction: intercept.CompleteIntercepts,
+	}
+}
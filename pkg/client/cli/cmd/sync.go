@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/intercept"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/filesync"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+// syncQuietPeriod is the time that the local directory must remain unchanged before a sync starts.
+const syncQuietPeriod = 200 * time.Millisecond
+
+type syncCommand struct {
+	local     string
+	remote    string
+	container string
+	namespace string
+	exclude   []string
+	delete    bool
+	once      bool
+}
+
+func syncCmd() *cobra.Command {
+	sc := &syncCommand{}
+	cmd := &cobra.Command{
+		Use:   "sync <workload> --local <dir> --remote <dir>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Copy local files into the containers of a workload as they change",
+		Long: `Copy local files into the containers of a workload as they change.
+
+The content of the local directory is copied to the remote directory of each pod of the workload,
+and then every change to the local directory is copied until the command is interrupted. The
+workload must have a traffic-agent, but no intercept is needed. The remote directory must be on
+a volume that is mounted by the container, because the traffic-agent has no access to other
+parts of the container's file system.
+
+Files that are removed locally while the command runs are removed from the remote directory.
+Files that are present in the remote directory when the command starts but absent locally are
+kept unless --delete is used.`,
+		Annotations: map[string]string{
+			ann.Session: ann.Required,
+		},
+		RunE:              sc.run,
+		ValidArgsFunction: intercept.CompleteWorkloads(connector.ListRequest_INSTALLED_AGENTS),
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&sc.local, "local", ".", "The local directory to copy from")
+	flags.StringVar(&sc.remote, "remote", "", "The absolute path of the directory in the container to copy to")
+	flags.StringVar(&sc.container, "container", "", "The name of the container. Defaults to the container that mounts the remote directory")
+	flags.StringVarP(&sc.namespace, "namespace", "n", "", "The namespace of the workload")
+	flags.StringSliceVar(&sc.exclude, "exclude", nil, "Patterns (see path.Match) for files and directories that are not copied. The .git directory is always excluded")
+	flags.BoolVar(&sc.delete, "delete", false, "Remove remote files that are absent from the local directory when the command starts")
+	flags.BoolVar(&sc.once, "once", false, "Copy once and exit instead of copying changes")
+	return cmd
+}
+
+func (sc *syncCommand) run(cmd *cobra.Command, args []string) error {
+	if !path.IsAbs(sc.remote) {
+		return errcat.User.New("--remote must be an absolute path")
+	}
+	fi, err := os.Stat(sc.local)
+	if err != nil {
+		return errcat.User.New(err)
+	}
+	if !fi.IsDir() {
+		return errcat.User.Newf("--local %s is not a directory", sc.local)
+	}
+	if sc.local, err = filepath.Abs(sc.local); err != nil {
+		return err
+	}
+	if err = connect.InitCommand(cmd); err != nil {
+		return err
+	}
+	ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	ud := daemon.GetUserClient(ctx)
+	if ud.Remote() {
+		return errcat.User.New("sync is not supported when the daemon runs in a container")
+	}
+	wi, err := findAgentWorkload(ctx, ud, args[0], sc.namespace)
+	if err != nil {
+		return err
+	}
+	remoteDir, err := agentPath(wi, sc.container, sc.remote)
+	if err != nil {
+		return err
+	}
+
+	type podSync struct {
+		podIP  string
+		target *filesync.SFTPTarget
+		syncer *filesync.Syncer
+	}
+	var pss []*podSync
+	defer func() {
+		for _, ps := range pss {
+			_ = ps.target.Close()
+		}
+	}()
+	for _, ai := range wi.AgentInfos {
+		if ai.SftpPort == 0 {
+			continue
+		}
+		target, err := filesync.DialSFTP(ctx, net.JoinHostPort(ai.PodIp, strconv.Itoa(int(ai.SftpPort))))
+		if err != nil {
+			return errcat.NoDaemonLogs.Newf("unable to connect to the traffic-agent at %s: %v", ai.PodIp, err)
+		}
+		pss = append(pss, &podSync{
+			podIP:  ai.PodIp,
+			target: target,
+			syncer: filesync.NewSyncer(sc.local, remoteDir, target, sc.exclude, sc.delete),
+		})
+	}
+	if len(pss) == 0 {
+		return errcat.User.Newf("no traffic-agent of %s.%s accepts file transfers", wi.Name, wi.Namespace)
+	}
+
+	syncAll := func() error {
+		for _, ps := range pss {
+			r, err := ps.syncer.Sync(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return fmt.Errorf("sync to %s failed: %w", ps.podIP, err)
+			}
+			if r.Uploaded > 0 || r.Removed > 0 {
+				fmt.Fprintf(output.Info(ctx), "%s: %d file(s) copied, %d removed\n", ps.podIP, r.Uploaded, r.Removed)
+			}
+		}
+		return nil
+	}
+
+	var changes <-chan struct{}
+	if !sc.once {
+		if changes, err = filesync.WatchDir(ctx, sc.local, syncQuietPeriod); err != nil {
+			return err
+		}
+	}
+	if err = syncAll(); err != nil {
+		return err
+	}
+	if sc.once {
+		return nil
+	}
+	fmt.Fprintf(output.Info(ctx), "Copying changes in %s to %s, press Ctrl-C to stop\n", sc.local, sc.remote)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changes:
+			// A failure is reported, and the next change gets a new chance.
+			if err = syncAll(); err != nil {
+				fmt.Fprintln(output.Err(ctx), err)
+			}
+		}
+	}
+}
+
+// findAgentWorkload returns the workload with the given name in the given namespace. The workload must have
+// a traffic-agent.
+func findAgentWorkload(ctx context.Context, ud *daemon.UserClient, name, namespace string) (*connector.WorkloadInfo, error) {
+	r, err := ud.List(ctx, &connector.ListRequest{Filter: connector.ListRequest_INSTALLED_AGENTS, Namespace: namespace})
+	if err != nil {
+		return nil, err
+	}
+	for _, wi := range r.Workloads {
+		if wi.Name == name {
+			return wi, nil
+		}
+	}
+	return nil, errcat.User.Newf("workload %q has no traffic-agent. Use telepresence intercept to install one", name)
+}
+
+// agentPath returns the path that the traffic-agent uses for the given path in a container of the workload. It's
+// the path below the agent's mount point of the container volume that contains the remote path.
+func agentPath(wi *connector.WorkloadInfo, container, remote string) (string, error) {
+	if wi.Sidecar == nil {
+		return "", errcat.User.Newf("workload %q has no traffic-agent", wi.Name)
+	}
+	var sidecar agentconfig.Sidecar
+	if err := json.Unmarshal(wi.Sidecar.Json, &sidecar); err != nil {
+		return "", err
+	}
+	remote = path.Clean(remote)
+	var found *agentconfig.Container
+	var mount string
+	for _, cn := range sidecar.Containers {
+		if container != "" && cn.Name != container {
+			continue
+		}
+		for _, m := range cn.Mounts {
+			if (remote == m || strings.HasPrefix(remote, strings.TrimSuffix(m, "/")+"/")) && len(m) > len(mount) {
+				found, mount = cn, m
+			}
+		}
+	}
+	if found == nil {
+		if container != "" {
+			return "", errcat.User.Newf("%s is not on a volume of container %q in workload %q", remote, container, wi.Name)
+		}
+		return "", errcat.User.Newf("%s is not on a volume of any container in workload %q", remote, wi.Name)
+	}
+	return path.Join(found.MountPoint, remote), nil
+}
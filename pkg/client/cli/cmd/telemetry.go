@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/datawire/metriton-go-client/metriton"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/scout"
+)
+
+func telemetry() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "telemetry",
+		Short: "Inspect the anonymous usage reports that telepresence sends",
+		Long: `Inspect the anonymous usage reports that telepresence sends.
+
+Reports are controlled by the telemetry section of the config.yml file:
+
+  telemetry:
+    enabled: false             # don't send any reports
+    endpoint: https://...      # send the reports to this URL instead
+    redact: [cluster_id, ...]  # replace the values of these fields with "<redacted>"
+
+Reports that can't be sent because the endpoint is unreachable are retained, and sent when it
+becomes reachable again.`,
+		Args: OnlySubcommands,
+		RunE: RunSubcommands,
+	}
+	cmd.AddCommand(telemetryShow())
+	return cmd
+}
+
+type telemetryInfo struct {
+	Enabled  bool               `json:"enabled" yaml:"enabled"`
+	Endpoint string             `json:"endpoint" yaml:"endpoint"`
+	Redact   []string           `json:"redact,omitempty" yaml:"redact,omitempty"`
+	Example  *metriton.Report   `json:"example,omitempty" yaml:"example,omitempty"`
+	Spooled  []*metriton.Report `json:"spooled,omitempty" yaml:"spooled,omitempty"`
+}
+
+func telemetryShow() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show",
+		Args:  cobra.NoArgs,
+		Short: "Show exactly what a report contains, and the reports that await sending",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := scout.NewReporter(cmd.Context(), "cli")
+			cfg := client.GetConfig(ctx).Telemetry()
+			ti := telemetryInfo{
+				Endpoint: cfg.Endpoint,
+				Redact:   cfg.Redact,
+				Example:  scout.Preview(ctx, "telemetry_show"),
+			}
+			if ti.Endpoint == "" {
+				ti.Endpoint = metriton.DefaultEndpoint
+			}
+			// No example is created when reports are disabled by the config or by SCOUT_DISABLE.
+			ti.Enabled = ti.Example != nil
+			var err error
+			if ti.Spooled, err = scout.Spooled(ctx); err != nil {
+				return err
+			}
+			if output.WantsFormatted(cmd) {
+				output.Object(ctx, &ti, false)
+				return nil
+			}
+
+			out := cmd.OutOrStdout()
+			if !ti.Enabled {
+				fmt.Fprintln(out, "Telemetry is disabled. No reports are sent.")
+				return nil
+			}
+			fmt.Fprintf(out, "Reports are sent to %s\n", ti.Endpoint)
+			if len(ti.Redact) > 0 {
+				fmt.Fprintf(out, "Redacted fields: %v\n", ti.Redact)
+			}
+			fmt.Fprintln(out, "\nThis is the report that would be sent by this command. Other commands add fields that describe what they do:")
+			if err = printReport(cmd, ti.Example); err != nil {
+				return err
+			}
+			if len(ti.Spooled) > 0 {
+				fmt.Fprintf(out, "\n%d report(s) await sending:\n", len(ti.Spooled))
+				for _, r := range ti.Spooled {
+					if err = printReport(cmd, r); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func printReport(cmd *cobra.Command, r *metriton.Report) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), string(data))
+	return nil
+}
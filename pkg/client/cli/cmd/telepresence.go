@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -14,9 +15,12 @@ import (
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/global"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/docker/kubeauth"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/logging"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/paths"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/rootd"
 	userDaemon "github.com/telepresenceio/telepresence/v2/pkg/client/userd/daemon"
 	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+	"github.com/telepresenceio/telepresence/v2/pkg/i18n"
 	"github.com/telepresenceio/telepresence/v2/pkg/maps"
 )
 
@@ -28,10 +32,15 @@ func Telepresence(ctx context.Context) *cobra.Command {
 		os.Exit(1)
 	}
 	ctx = client.WithConfig(ctx, cfg)
+	ctx = paths.WithOverrides(ctx)
 	if ctx, err = logging.InitContext(ctx, "cli", logging.RotateDaily, false); err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
+	if err = i18n.LoadDir(filepath.Join(filelocation.AppUserConfigDir(ctx), "locales")); err != nil {
+		dlog.Warn(ctx, err)
+	}
+	ctx = i18n.WithLocale(ctx, i18n.SelectLocale(cfg.CLI().Locale))
 	rootCmd := &cobra.Command{
 		Use:  "telepresence",
 		Args: perhapsLegacy,
@@ -45,6 +54,7 @@ func Telepresence(ctx context.Context) *cobra.Command {
 	}
 	rootCmd.SetContext(ctx)
 	AddSubCommands(rootCmd)
+	addPlugin(rootCmd, os.Args[1:])
 	rootCmd.SetFlagErrorFunc(func(_ *cobra.Command, err error) error {
 		return errcat.User.New(err)
 	})
@@ -134,8 +144,8 @@ func OnlySubcommands(cmd *cobra.Command, args []string) error {
 
 func WithSubCommands(ctx context.Context) context.Context {
 	return MergeSubCommands(ctx,
-		config(), connectCmd(), currentClusterId(), gatherLogs(), gatherTraces(), genYAML(), helm(), interceptCmd(), leave(),
-		list(), loglevel(), quit(), statusCmd(), testVPN(), uninstall(), uploadTraces(), version(), listNamespaces(), listContexts(),
+		admin(), auth(), capture(), config(), connectCmd(), connections(), cpCmd(), currentClusterId(), dashboardCmd(), debugCmd(), dnsCmd(), doctor(), envCmd(), gatherLogs(), gatherTraces(), genYAML(), handoff(), helm(), hook(), ideDaemon(), interceptCmd(), interceptChainCmd(), leave(),
+		list(), loglevel(), logs(), namespaceCmd(), quit(), routeCmd(), sshCmd(), statsCmd(), statusCmd(), switchCmd(), syncCmd(), telemetry(), testVPN(), uninstall(), upgrade(), uploadTraces(), version(), listNamespaces(), listContexts(),
 	)
 }
 
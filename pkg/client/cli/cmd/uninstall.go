@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"errors"
-	"fmt"
 
 	"github.com/spf13/cobra"
 
@@ -17,16 +16,26 @@ type uninstallCommand struct {
 	agent      bool
 	allAgents  bool
 	everything bool
+
+	managerNamespace string
 }
 
 func uninstall() *cobra.Command {
 	ui := &uninstallCommand{}
 	cmd := &cobra.Command{
-		Use:  "uninstall [flags] { --agent <agents...> | --all-agents }",
+		Use:  "uninstall [flags] { --agent <agents...> | --all-agents | --everything }",
 		Args: ui.args,
 
-		Short: "Uninstall telepresence agents",
-		RunE:  ui.run,
+		Short: "Uninstall telepresence agents, or everything that Telepresence installed in the cluster",
+		Long: `Uninstall telepresence agents, or everything that Telepresence installed in the cluster.
+
+With --everything, the traffic-manager and the Telepresence CRDs are uninstalled, and the resources
+that a failed or partial uninstall may have left behind are removed. This includes the agent-injector
+webhook, RBAC, agent configs, traffic-agents, and the Telepresence labels of namespaces. The CRDs and
+the labels of namespaces are kept when other traffic-managers remain in the cluster. The traffic-manager
+is found where it's installed, unless --manager-namespace is given. The cluster is then verified, and a
+report of what was removed and what remains is printed.`,
+		RunE: ui.run,
 		Annotations: map[string]string{
 			ann.Session: ann.Required,
 		},
@@ -35,27 +44,29 @@ func uninstall() *cobra.Command {
 
 	flags.BoolVarP(&ui.agent, "agent", "d", false, "uninstall intercept agent on specific deployments")
 	flags.BoolVarP(&ui.allAgents, "all-agents", "a", false, "uninstall intercept agent on all deployments")
-
-	// Hidden from help but will yield a deprecation warning if used
-	flags.BoolVarP(&ui.everything, "everything", "e", false, "uninstall agents and the traffic manager")
-	flags.Lookup("everything").Hidden = true
+	flags.BoolVarP(&ui.everything, "everything", "e", false, "uninstall the traffic manager, the agents, and everything else that Telepresence installed in the cluster")
+	flags.StringVar(&ui.managerNamespace, "manager-namespace", "", "the namespace of the traffic manager to uninstall with --everything")
 	return cmd
 }
 
 func (u *uninstallCommand) args(cmd *cobra.Command, args []string) error {
 	if u.everything {
-		ha := &HelmCommand{
-			RequestType: connector.HelmRequest_UNINSTALL,
-			Request:     daemon.InitRequest(cmd),
+		if u.agent || u.allAgents {
+			return errors.New("--everything cannot be combined with --agent or --all-agents")
 		}
-		fmt.Fprintln(cmd.OutOrStderr(), "--everything is deprecated. Please use telepresence helm uninstall")
-		return ha.run(cmd, args)
+		if len(args) != 0 {
+			return errors.New("unexpected argument(s)")
+		}
+		return nil
+	}
+	if u.managerNamespace != "" {
+		return errors.New("--manager-namespace can only be used with --everything")
 	}
 	if u.agent && u.allAgents {
 		return errors.New("--agent and --all-agents are mutually exclusive")
 	}
 	if !(u.agent || u.allAgents) {
-		return errors.New("please specify --agent or --all-agents")
+		return errors.New("please specify --agent, --all-agents, or --everything")
 	}
 	switch {
 	case u.agent && len(args) == 0:
@@ -68,6 +79,9 @@ func (u *uninstallCommand) args(cmd *cobra.Command, args []string) error {
 
 // uninstall.
 func (u *uninstallCommand) run(cmd *cobra.Command, args []string) error {
+	if u.everything {
+		return u.uninstallEverything(cmd)
+	}
 	if err := connect.InitCommand(cmd); err != nil {
 		return err
 	}
@@ -78,8 +92,6 @@ func (u *uninstallCommand) run(cmd *cobra.Command, args []string) error {
 	case u.agent:
 		ur.UninstallType = connector.UninstallRequest_NAMED_AGENTS
 		ur.Agents = args
-	case u.everything:
-		return nil
 	default:
 		ur.UninstallType = connector.UninstallRequest_ALL_AGENTS
 	}
@@ -0,0 +1,581 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	admission "k8s.io/api/admissionregistration/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentmap"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
+	"github.com/telepresenceio/telepresence/v2/pkg/slice"
+)
+
+const (
+	trafficManagerRelease = "traffic-manager"
+	crdGroup              = "telepresence.io"
+)
+
+var crdResource = schema.GroupVersionResource{ //nolint:gochecknoglobals // constant
+	Group:    "apiextensions.k8s.io",
+	Version:  "v1",
+	Resource: "customresourcedefinitions",
+}
+
+// cleanupReport is the outcome of "telepresence uninstall --everything".
+type cleanupReport struct {
+	ManagerNamespace string   `json:"manager_namespace" yaml:"manager_namespace"`
+	Removed          []string `json:"removed" yaml:"removed"`
+	Restarted        []string `json:"restarted,omitempty" yaml:"restarted,omitempty"`
+	Kept             []string `json:"kept,omitempty" yaml:"kept,omitempty"`
+	Remaining        []string `json:"remaining" yaml:"remaining"`
+	Errors           []string `json:"errors,omitempty" yaml:"errors,omitempty"`
+}
+
+func (r *cleanupReport) addError(err error) {
+	r.Errors = append(r.Errors, err.Error())
+}
+
+func (r *cleanupReport) print(out io.Writer) {
+	fmt.Fprintf(out, "Traffic-manager namespace: %s\n", r.ManagerNamespace)
+	printList := func(title string, items []string) {
+		fmt.Fprintf(out, "%s:", title)
+		if len(items) == 0 {
+			fmt.Fprintln(out, " none")
+			return
+		}
+		fmt.Fprintln(out)
+		for _, item := range items {
+			fmt.Fprintf(out, "  %s\n", item)
+		}
+	}
+	printList("Removed", r.Removed)
+	if len(r.Restarted) > 0 {
+		printList("Restarted to remove the traffic-agent", r.Restarted)
+	}
+	if len(r.Kept) > 0 {
+		printList("Kept for other traffic-managers", r.Kept)
+	}
+	printList("Remaining", r.Remaining)
+	if len(r.Errors) > 0 {
+		printList("Errors", r.Errors)
+	}
+}
+
+// uninstallEverything uninstalls the traffic-manager, removes everything that the uninstall left behind in the
+// cluster, and then verifies that nothing remains. The Telepresence CRDs and the Telepresence labels of
+// namespaces are shared by all traffic-managers, so they are only removed when no other traffic-manager remains.
+func (u *uninstallCommand) uninstallEverything(cmd *cobra.Command) error {
+	// No session is needed, and the traffic-manager that a session would connect to may be broken or gone.
+	delete(cmd.Annotations, ann.Session)
+	cmd.Annotations[ann.UserDaemon] = ann.Required
+	if err := connect.InitCommand(cmd); err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	rq := daemon.GetRequest(ctx)
+	kc, err := client.NewKubeconfig(ctx, rq.KubeFlags, u.managerNamespace)
+	if err != nil {
+		return err
+	}
+	ki, err := kubernetes.NewForConfig(kc.RestConfig)
+	if err != nil {
+		return err
+	}
+	dc, err := dynamic.NewForConfig(kc.RestConfig)
+	if err != nil {
+		return err
+	}
+	ctx = k8sapi.WithK8sInterface(ctx, ki)
+	ns := kc.GetManagerNamespace()
+	if u.managerNamespace == "" {
+		// The configured namespace is only a default. The traffic-manager is found where it's installed.
+		if ns, err = findManagerNamespace(ctx, ki, ns); err != nil {
+			return err
+		}
+	}
+	nss, err := managerNamespaces(ctx, ki)
+	if err != nil {
+		return err
+	}
+	var others []string
+	for _, mns := range nss {
+		if mns != ns {
+			others = append(others, mns)
+		}
+	}
+
+	report := &cleanupReport{ManagerNamespace: ns}
+	if len(others) > 0 {
+		sort.Strings(others)
+		report.Kept = append(report.Kept, fmt.Sprintf("Telepresence CRDs and namespace labels (traffic-managers in %s)", strings.Join(others, ", ")))
+	}
+	ud := daemon.GetUserClient(ctx)
+	rq.ManagerNamespace = ns
+	for _, crds := range []bool{false, true} {
+		if crds && len(others) > 0 {
+			break
+		}
+		r, err := ud.Helm(ctx, &connector.HelmRequest{
+			Type:           connector.HelmRequest_UNINSTALL,
+			ConnectRequest: &rq.ConnectRequest,
+			Crds:           crds,
+		})
+		if err == nil {
+			err = errcat.FromResult(r)
+		}
+		if err != nil {
+			report.addError(err)
+		}
+	}
+
+	cc := &clusterCleanup{ki: ki, dc: dc, namespace: ns, removeShared: len(others) == 0}
+	cc.sweep(ctx, report)
+	cc.verify(ctx, report)
+	if output.WantsFormatted(cmd) {
+		output.Object(ctx, report, false)
+	} else {
+		report.print(cmd.OutOrStdout())
+	}
+	if len(report.Remaining) > 0 || len(report.Errors) > 0 {
+		return errcat.User.New("the cluster still contains Telepresence resources")
+	}
+	return nil
+}
+
+// findManagerNamespace returns the namespace of the traffic-manager, or of the agent-injector that a
+// partial uninstall left behind. The given default namespace is returned when neither is found.
+func findManagerNamespace(ctx context.Context, ki kubernetes.Interface, defaultNamespace string) (string, error) {
+	nss, err := managerNamespaces(ctx, ki)
+	if err != nil {
+		return "", err
+	}
+	switch len(nss) {
+	case 0:
+		return defaultNamespace, nil
+	case 1:
+		return nss[0], nil
+	default:
+		sort.Strings(nss)
+		return "", errcat.User.Newf("found traffic-managers in the namespaces %s, use --manager-namespace to choose one", strings.Join(nss, ", "))
+	}
+}
+
+// managerNamespaces returns the namespaces that have a traffic-manager, or an agent-injector webhook.
+func managerNamespaces(ctx context.Context, ki kubernetes.Interface) ([]string, error) {
+	nss, err := trafficManagerNamespaces(ctx, ki)
+	if err != nil {
+		return nil, err
+	}
+	whs, err := ki.AdmissionregistrationV1().MutatingWebhookConfigurations().List(ctx, meta.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range whs.Items {
+		for _, wh := range whs.Items[i].Webhooks {
+			if svc := wh.ClientConfig.Service; svc != nil && svc.Name == install.AgentInjectorName {
+				nss = slice.AppendUnique(nss, svc.Namespace)
+			}
+		}
+	}
+	return nss, nil
+}
+
+// trafficManagerNamespaces returns the namespaces that have a traffic-manager service.
+func trafficManagerNamespaces(ctx context.Context, ki kubernetes.Interface) ([]string, error) {
+	var nss []string
+	svcs, err := ki.CoreV1().Services("").List(ctx, meta.ListOptions{LabelSelector: "app=traffic-manager,telepresence=manager"})
+	if err != nil {
+		return nil, err
+	}
+	for i := range svcs.Items {
+		nss = slice.AppendUnique(nss, svcs.Items[i].Namespace)
+	}
+	return nss, nil
+}
+
+// clusterCleanup finds and removes the resources that belong to the traffic-manager in a namespace.
+type clusterCleanup struct {
+	ki        kubernetes.Interface
+	dc        dynamic.Interface
+	namespace string
+
+	// removeShared is true when no other traffic-manager remains, so that the resources that all
+	// traffic-managers share, i.e. the CRDs and the Telepresence labels of namespaces, can be removed.
+	removeShared bool
+
+	// restarted are the workloads that were restarted to get rid of an injected traffic-agent.
+	restarted []k8sapi.Workload
+}
+
+// leftover is a resource that the cleanup removes.
+type leftover struct {
+	kind   string
+	object meta.Object
+	remove func(context.Context) error
+}
+
+func (l *leftover) String() string {
+	if ns := l.object.GetNamespace(); ns != "" {
+		return fmt.Sprintf("%s %s.%s", l.kind, l.object.GetName(), ns)
+	}
+	return fmt.Sprintf("%s %s", l.kind, l.object.GetName())
+}
+
+// sweep removes the leftovers and restarts the workloads that have pods with an injected traffic-agent. The
+// webhooks are removed first, so that the restarted pods don't get a new traffic-agent.
+func (cc *clusterCleanup) sweep(ctx context.Context, report *cleanupReport) {
+	// The agent configs are among the leftovers, so the namespaces of the agents must be found first.
+	agentNss, err := cc.agentNamespaces(ctx)
+	if err != nil {
+		report.addError(err)
+	}
+	los, err := cc.leftovers(ctx)
+	if err != nil {
+		report.addError(err)
+	}
+	for _, lo := range los {
+		if err := lo.remove(ctx); err != nil && !errors.IsNotFound(err) {
+			report.addError(fmt.Errorf("unable to remove %s: %w", lo, err))
+		} else {
+			report.Removed = append(report.Removed, lo.String())
+		}
+	}
+	if err := cc.restartAgents(ctx, report, agentNss); err != nil {
+		report.addError(err)
+	}
+}
+
+// verify adds the leftovers that still exist, and the workloads with a traffic-agent that can't be removed by a
+// restart, to the remaining resources of the report.
+func (cc *clusterCleanup) verify(ctx context.Context, report *cleanupReport) {
+	los, err := cc.leftovers(ctx)
+	if err != nil {
+		report.addError(err)
+	}
+	for _, lo := range los {
+		report.Remaining = append(report.Remaining, lo.String())
+	}
+	for _, wl := range cc.restarted {
+		if err := wl.Refresh(ctx); err != nil {
+			if !errors.IsNotFound(err) {
+				report.addError(err)
+			}
+			continue
+		}
+		if hasAgentContainer(&core.Pod{Spec: wl.GetPodTemplate().Spec}) {
+			report.Remaining = append(report.Remaining, fmt.Sprintf("%s %s.%s (traffic-agent in pod template)", wl.GetKind(), wl.GetName(), wl.GetNamespace()))
+		}
+	}
+}
+
+// ownedByRelease returns true if the given object was created by the traffic-manager's Helm release.
+func (cc *clusterCleanup) ownedByRelease(obj meta.Object) bool {
+	as := obj.GetAnnotations()
+	return as["meta.helm.sh/release-name"] == trafficManagerRelease && as["meta.helm.sh/release-namespace"] == cc.namespace
+}
+
+// leftovers returns the resources that belong to the traffic-manager.
+func (cc *clusterCleanup) leftovers(ctx context.Context) ([]*leftover, error) {
+	var los []*leftover
+	add := func(kind string, obj meta.Object, remove func(context.Context) error) {
+		los = append(los, &leftover{kind: kind, object: obj, remove: remove})
+	}
+	delOpts := meta.DeleteOptions{}
+	listOpts := meta.ListOptions{}
+
+	whi := cc.ki.AdmissionregistrationV1().MutatingWebhookConfigurations()
+	whs, err := whi.List(ctx, listOpts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range whs.Items {
+		wh := &whs.Items[i]
+		if cc.ownedByRelease(wh) || cc.isAgentInjectorWebhook(wh.Webhooks) {
+			add("MutatingWebhookConfiguration", wh, func(ctx context.Context) error { return whi.Delete(ctx, wh.Name, delOpts) })
+		}
+	}
+
+	// RBAC that the traffic-manager was granted, or that its chart granted to clients.
+	clusterRBACName := fmt.Sprintf("%s-%s", install.ManagerAppName, cc.namespace)
+	cri := cc.ki.RbacV1().ClusterRoles()
+	crs, err := cri.List(ctx, listOpts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range crs.Items {
+		cr := &crs.Items[i]
+		if cc.ownedByRelease(cr) || cr.Name == clusterRBACName {
+			add("ClusterRole", cr, func(ctx context.Context) error { return cri.Delete(ctx, cr.Name, delOpts) })
+		}
+	}
+	crbi := cc.ki.RbacV1().ClusterRoleBindings()
+	crbs, err := crbi.List(ctx, listOpts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range crbs.Items {
+		crb := &crbs.Items[i]
+		if cc.ownedByRelease(crb) || crb.Name == clusterRBACName {
+			add("ClusterRoleBinding", crb, func(ctx context.Context) error { return crbi.Delete(ctx, crb.Name, delOpts) })
+		}
+	}
+	rs, err := cc.ki.RbacV1().Roles("").List(ctx, listOpts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rs.Items {
+		r := &rs.Items[i]
+		if cc.ownedByRelease(r) {
+			add("Role", r, func(ctx context.Context) error { return cc.ki.RbacV1().Roles(r.Namespace).Delete(ctx, r.Name, delOpts) })
+		}
+	}
+	rbs, err := cc.ki.RbacV1().RoleBindings("").List(ctx, listOpts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rbs.Items {
+		rb := &rbs.Items[i]
+		if cc.ownedByRelease(rb) {
+			add("RoleBinding", rb, func(ctx context.Context) error {
+				return cc.ki.RbacV1().RoleBindings(rb.Namespace).Delete(ctx, rb.Name, delOpts)
+			})
+		}
+	}
+
+	// The traffic-manager itself.
+	dpi := cc.ki.AppsV1().Deployments(cc.namespace)
+	dps, err := dpi.List(ctx, listOpts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range dps.Items {
+		dp := &dps.Items[i]
+		if cc.ownedByRelease(dp) {
+			add("Deployment", dp, func(ctx context.Context) error { return dpi.Delete(ctx, dp.Name, delOpts) })
+		}
+	}
+	svi := cc.ki.CoreV1().Services(cc.namespace)
+	svs, err := svi.List(ctx, listOpts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range svs.Items {
+		sv := &svs.Items[i]
+		if cc.ownedByRelease(sv) {
+			add("Service", sv, func(ctx context.Context) error { return svi.Delete(ctx, sv.Name, delOpts) })
+		}
+	}
+	sai := cc.ki.CoreV1().ServiceAccounts(cc.namespace)
+	sas, err := sai.List(ctx, listOpts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sas.Items {
+		sa := &sas.Items[i]
+		if cc.ownedByRelease(sa) {
+			add("ServiceAccount", sa, func(ctx context.Context) error { return sai.Delete(ctx, sa.Name, delOpts) })
+		}
+	}
+	sci := cc.ki.CoreV1().Secrets(cc.namespace)
+	scs, err := sci.List(ctx, listOpts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range scs.Items {
+		sc := &scs.Items[i]
+		if cc.ownedByRelease(sc) || sc.Name == install.MutatorWebhookTLSName {
+			add("Secret", sc, func(ctx context.Context) error { return sci.Delete(ctx, sc.Name, delOpts) })
+		}
+	}
+
+	// Configmaps in the traffic-manager's namespace, and the agent configs in the namespaces that it manages.
+	cms, err := cc.ki.CoreV1().ConfigMaps(cc.namespace).List(ctx, listOpts)
+	if err != nil {
+		return nil, err
+	}
+	acms, err := cc.ki.CoreV1().ConfigMaps("").List(ctx, meta.ListOptions{FieldSelector: "metadata.name=" + agentconfig.ConfigMap})
+	if err != nil {
+		return nil, err
+	}
+	cms.Items = append(cms.Items, acms.Items...)
+	for i := range cms.Items {
+		cm := &cms.Items[i]
+		if cm.Name == agentconfig.ConfigMap && cc.isManagedAgentConfig(cm) || cm.Name != agentconfig.ConfigMap && cc.ownedByRelease(cm) {
+			add("ConfigMap", cm, func(ctx context.Context) error {
+				return cc.ki.CoreV1().ConfigMaps(cm.Namespace).Delete(ctx, cm.Name, delOpts)
+			})
+		}
+	}
+
+	if !cc.removeShared {
+		return los, nil
+	}
+
+	crdi := cc.dc.Resource(crdResource)
+	crds, err := crdi.List(ctx, listOpts)
+	if err != nil {
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+	} else {
+		for i := range crds.Items {
+			crd := &crds.Items[i]
+			if group, _, _ := unstructured.NestedString(crd.Object, "spec", "group"); group == crdGroup {
+				add("CustomResourceDefinition", crd, func(ctx context.Context) error { return crdi.Delete(ctx, crd.GetName(), delOpts) })
+			}
+		}
+	}
+
+	nsi := cc.ki.CoreV1().Namespaces()
+	nss, err := nsi.List(ctx, listOpts)
+	if err != nil {
+		return nil, err
+	}
+	for i := range nss.Items {
+		ns := &nss.Items[i]
+		if patch := telepresenceMetadataPatch(ns.Labels, ns.Annotations); patch != nil {
+			add("Telepresence labels of Namespace", ns, func(ctx context.Context) error {
+				_, err := nsi.Patch(ctx, ns.Name, types.MergePatchType, patch, meta.PatchOptions{})
+				return err
+			})
+		}
+	}
+	return los, nil
+}
+
+// isAgentInjectorWebhook returns true if one of the given webhooks calls the agent-injector of the traffic-manager.
+func (cc *clusterCleanup) isAgentInjectorWebhook(whs []admission.MutatingWebhook) bool {
+	for i := range whs {
+		if svc := whs[i].ClientConfig.Service; svc != nil && svc.Name == install.AgentInjectorName && svc.Namespace == cc.namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// isManagedAgentConfig returns true if the given agent configmap has configs for agents that connect to the
+// traffic-manager, or configs that can't be parsed.
+func (cc *clusterCleanup) isManagedAgentConfig(cm *core.ConfigMap) bool {
+	managerHost := install.ManagerAppName + "." + cc.namespace
+	for _, data := range cm.Data {
+		sce, err := agentconfig.UnmarshalYAML([]byte(data))
+		if err != nil {
+			return true
+		}
+		if mh := sce.AgentConfig().ManagerHost; mh == managerHost || strings.HasPrefix(mh, managerHost+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// telepresenceMetadataPatch returns a JSON merge patch that removes the labels and annotations that Telepresence
+// added to a namespace, or nil when there are none.
+func telepresenceMetadataPatch(labels, annotations map[string]string) []byte {
+	removals := func(m map[string]string) map[string]any {
+		var rm map[string]any
+		for k := range m {
+			if strings.HasPrefix(k, install.DomainPrefix) {
+				if rm == nil {
+					rm = make(map[string]any)
+				}
+				rm[k] = nil
+			}
+		}
+		return rm
+	}
+	md := make(map[string]any)
+	if rm := removals(labels); rm != nil {
+		md["labels"] = rm
+	}
+	if rm := removals(annotations); rm != nil {
+		md["annotations"] = rm
+	}
+	if len(md) == 0 {
+		return nil
+	}
+	patch, _ := json.Marshal(map[string]any{"metadata": md})
+	return patch
+}
+
+// agentNamespaces returns the namespaces that have agent configs for the traffic-manager.
+func (cc *clusterCleanup) agentNamespaces(ctx context.Context) (map[string]struct{}, error) {
+	cms, err := cc.ki.CoreV1().ConfigMaps("").List(ctx, meta.ListOptions{FieldSelector: "metadata.name=" + agentconfig.ConfigMap})
+	if err != nil {
+		return nil, err
+	}
+	nss := make(map[string]struct{})
+	for i := range cms.Items {
+		if cm := &cms.Items[i]; cm.Name == agentconfig.ConfigMap && cc.isManagedAgentConfig(cm) {
+			nss[cm.Namespace] = struct{}{}
+		}
+	}
+	return nss, nil
+}
+
+// restartAgents restarts the workloads of the pods in the given namespaces that have a traffic-agent that was
+// injected by the agent-injector. The workloads of pods with a manually injected traffic-agent are not restarted,
+// but they are verified along with the restarted ones.
+func (cc *clusterCleanup) restartAgents(ctx context.Context, report *cleanupReport, namespaces map[string]struct{}) error {
+	if len(namespaces) == 0 {
+		return nil
+	}
+	pods, err := cc.ki.CoreV1().Pods("").List(ctx, meta.ListOptions{})
+	if err != nil {
+		return err
+	}
+	cache := make(map[string]k8sapi.Workload)
+	done := make(map[string]struct{})
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if _, ok := namespaces[pod.Namespace]; !ok || !hasAgentContainer(pod) {
+			continue
+		}
+		wl, err := agentmap.FindOwnerWorkload(ctx, cache, k8sapi.Pod(pod))
+		if err != nil {
+			report.Remaining = append(report.Remaining, fmt.Sprintf("Pod %s.%s (no workload to restart)", pod.Name, pod.Namespace))
+			continue
+		}
+		key := fmt.Sprintf("%s %s.%s", wl.GetKind(), wl.GetName(), wl.GetNamespace())
+		if _, ok := done[key]; ok {
+			continue
+		}
+		done[key] = struct{}{}
+		cc.restarted = append(cc.restarted, wl)
+		if pod.Annotations[install.ManualInjectAnnotation] == "true" {
+			continue
+		}
+		patch := fmt.Sprintf(`{"spec": {"template": {"metadata": {"annotations": {"%srestartedAt": "%s"}}}}}`,
+			install.DomainPrefix, time.Now().Format(time.RFC3339))
+		if err := wl.Patch(ctx, types.StrategicMergePatchType, []byte(patch)); err != nil {
+			report.addError(fmt.Errorf("unable to restart %s: %w", key, err))
+			continue
+		}
+		report.Restarted = append(report.Restarted, key)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admission "k8s.io/api/admissionregistration/v1"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicFake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
+)
+
+func injectorWebhook(name, namespace string) *admission.MutatingWebhookConfiguration {
+	return &admission.MutatingWebhookConfiguration{
+		ObjectMeta: meta.ObjectMeta{Name: name},
+		Webhooks: []admission.MutatingWebhook{{
+			Name: "agent-injector.getambassador.io",
+			ClientConfig: admission.WebhookClientConfig{
+				Service: &admission.ServiceReference{Name: install.AgentInjectorName, Namespace: namespace},
+			},
+		}},
+	}
+}
+
+func crd(name, group string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "apiextensions.k8s.io/v1",
+		"kind":       "CustomResourceDefinition",
+		"metadata":   map[string]any{"name": name},
+		"spec":       map[string]any{"group": group},
+	}}
+}
+
+func TestClusterCleanup(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	released := map[string]string{
+		"meta.helm.sh/release-name":      "traffic-manager",
+		"meta.helm.sh/release-namespace": "ambassador",
+	}
+	sc, err := (&agentconfig.Sidecar{AgentName: "echo", ManagerHost: "traffic-manager.ambassador"}).Marshal()
+	require.NoError(t, err)
+	otherSc, err := (&agentconfig.Sidecar{AgentName: "hello", ManagerHost: "traffic-manager.other"}).Marshal()
+	require.NoError(t, err)
+
+	yes := true
+	dep := &apps.Deployment{
+		TypeMeta:   meta.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+		ObjectMeta: meta.ObjectMeta{Name: "echo", Namespace: "echo"},
+	}
+	rs := &apps.ReplicaSet{
+		TypeMeta: meta.TypeMeta{Kind: "ReplicaSet", APIVersion: "apps/v1"},
+		ObjectMeta: meta.ObjectMeta{Name: "echo-7f9c", Namespace: "echo", OwnerReferences: []meta.OwnerReference{
+			{APIVersion: "apps/v1", Kind: "Deployment", Name: "echo", Controller: &yes},
+		}},
+	}
+	agentPod := func(name, namespace, rsName string) *core.Pod {
+		return &core.Pod{
+			ObjectMeta: meta.ObjectMeta{Name: name, Namespace: namespace, OwnerReferences: []meta.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: rsName, Controller: &yes},
+			}},
+			Spec: core.PodSpec{Containers: []core.Container{{Name: "echo"}, {Name: install.AgentContainerName}}},
+		}
+	}
+
+	ki := fake.NewSimpleClientset(
+		injectorWebhook("agent-injector-webhook-ambassador", "ambassador"),
+		injectorWebhook("agent-injector-webhook-other", "other"),
+		&rbac.ClusterRole{ObjectMeta: meta.ObjectMeta{Name: "traffic-manager-ambassador"}},
+		&rbac.ClusterRole{ObjectMeta: meta.ObjectMeta{Name: "traffic-manager-other"}},
+		&rbac.ClusterRoleBinding{ObjectMeta: meta.ObjectMeta{Name: "traffic-manager-ambassador"}},
+		&rbac.Role{ObjectMeta: meta.ObjectMeta{Name: "traffic-manager-connect", Namespace: "echo", Annotations: released}},
+		&core.Secret{ObjectMeta: meta.ObjectMeta{Name: install.MutatorWebhookTLSName, Namespace: "ambassador"}},
+		&core.ConfigMap{ObjectMeta: meta.ObjectMeta{Name: agentconfig.ConfigMap, Namespace: "echo"}, Data: map[string]string{"echo": string(sc)}},
+		&core.ConfigMap{ObjectMeta: meta.ObjectMeta{Name: agentconfig.ConfigMap, Namespace: "hello"}, Data: map[string]string{"hello": string(otherSc)}},
+		&core.Namespace{ObjectMeta: meta.ObjectMeta{Name: "echo", Labels: map[string]string{
+			install.EphemeralNamespaceLabel: "true",
+			"team":                          "payments",
+		}}},
+		&core.Namespace{ObjectMeta: meta.ObjectMeta{Name: "hello"}},
+		dep, rs,
+		agentPod("echo-7f9c-abcde", "echo", "echo-7f9c"),
+		agentPod("hello-5d4b-fghij", "hello", "hello-5d4b"),
+	)
+	dc := dynamicFake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{crdResource: "CustomResourceDefinitionList"},
+		crd("interceptions.telepresence.io", "telepresence.io"),
+		crd("mappings.getambassador.io", "getambassador.io"),
+	)
+	ctx = k8sapi.WithK8sInterface(ctx, ki)
+
+	cc := &clusterCleanup{ki: ki, dc: dc, namespace: "ambassador", removeShared: true}
+	report := &cleanupReport{ManagerNamespace: "ambassador"}
+	cc.sweep(ctx, report)
+	cc.verify(ctx, report)
+	assert.Empty(t, report.Errors)
+	assert.Empty(t, report.Remaining)
+	assert.ElementsMatch(t, []string{
+		"MutatingWebhookConfiguration agent-injector-webhook-ambassador",
+		"ClusterRole traffic-manager-ambassador",
+		"ClusterRoleBinding traffic-manager-ambassador",
+		"Role traffic-manager-connect.echo",
+		"Secret mutator-webhook-tls.ambassador",
+		"ConfigMap telepresence-agents.echo",
+		"CustomResourceDefinition interceptions.telepresence.io",
+		"Telepresence labels of Namespace echo",
+	}, report.Removed)
+	assert.Equal(t, []string{"Deployment echo.echo"}, report.Restarted)
+
+	// Everything that belongs to another traffic-manager, or to someone else, is kept.
+	_, err = ki.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, "agent-injector-webhook-other", meta.GetOptions{})
+	assert.NoError(t, err)
+	_, err = ki.RbacV1().ClusterRoles().Get(ctx, "traffic-manager-other", meta.GetOptions{})
+	assert.NoError(t, err)
+	_, err = ki.CoreV1().ConfigMaps("hello").Get(ctx, agentconfig.ConfigMap, meta.GetOptions{})
+	assert.NoError(t, err)
+	_, err = dc.Resource(crdResource).Get(ctx, "mappings.getambassador.io", meta.GetOptions{})
+	assert.NoError(t, err)
+	ns, err := ki.CoreV1().Namespaces().Get(ctx, "echo", meta.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "payments"}, ns.Labels)
+	d, err := ki.AppsV1().Deployments("echo").Get(ctx, "echo", meta.GetOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, d.Spec.Template.Annotations, install.DomainPrefix+"restartedAt")
+}
+
+func TestClusterCleanupKeepsShared(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	ki := fake.NewSimpleClientset(
+		injectorWebhook("agent-injector-webhook-ambassador", "ambassador"),
+		&core.Namespace{ObjectMeta: meta.ObjectMeta{Name: "pr-1", Labels: map[string]string{install.EphemeralNamespaceLabel: "true"}}},
+	)
+	dc := dynamicFake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{crdResource: "CustomResourceDefinitionList"},
+		crd("interceptions.telepresence.io", "telepresence.io"),
+	)
+	ctx = k8sapi.WithK8sInterface(ctx, ki)
+
+	// Another traffic-manager remains, so the CRDs and namespace labels that it uses are kept.
+	cc := &clusterCleanup{ki: ki, dc: dc, namespace: "ambassador"}
+	report := &cleanupReport{ManagerNamespace: "ambassador"}
+	cc.sweep(ctx, report)
+	cc.verify(ctx, report)
+	assert.Empty(t, report.Errors)
+	assert.Empty(t, report.Remaining)
+	assert.Equal(t, []string{"MutatingWebhookConfiguration agent-injector-webhook-ambassador"}, report.Removed)
+
+	_, err := dc.Resource(crdResource).Get(ctx, "interceptions.telepresence.io", meta.GetOptions{})
+	assert.NoError(t, err)
+	ns, err := ki.CoreV1().Namespaces().Get(ctx, "pr-1", meta.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "true", ns.Labels[install.EphemeralNamespaceLabel])
+}
+
+func TestClusterCleanupManualAgent(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	sc, err := (&agentconfig.Sidecar{AgentName: "echo", ManagerHost: "traffic-manager.ambassador"}).Marshal()
+	require.NoError(t, err)
+	yes := true
+	tpl := core.PodTemplateSpec{
+		ObjectMeta: meta.ObjectMeta{Annotations: map[string]string{install.ManualInjectAnnotation: "true"}},
+		Spec:       core.PodSpec{Containers: []core.Container{{Name: "echo"}, {Name: install.AgentContainerName}}},
+	}
+	ki := fake.NewSimpleClientset(
+		&core.ConfigMap{ObjectMeta: meta.ObjectMeta{Name: agentconfig.ConfigMap, Namespace: "echo"}, Data: map[string]string{"echo": string(sc)}},
+		&apps.StatefulSet{
+			TypeMeta:   meta.TypeMeta{Kind: "StatefulSet", APIVersion: "apps/v1"},
+			ObjectMeta: meta.ObjectMeta{Name: "echo", Namespace: "echo"},
+			Spec:       apps.StatefulSetSpec{Template: tpl},
+		},
+		&core.Pod{
+			ObjectMeta: meta.ObjectMeta{
+				Name:            "echo-0",
+				Namespace:       "echo",
+				Annotations:     tpl.Annotations,
+				OwnerReferences: []meta.OwnerReference{{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "echo", Controller: &yes}},
+			},
+			Spec: tpl.Spec,
+		},
+	)
+	dc := dynamicFake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{crdResource: "CustomResourceDefinitionList"})
+	ctx = k8sapi.WithK8sInterface(ctx, ki)
+
+	cc := &clusterCleanup{ki: ki, dc: dc, namespace: "ambassador"}
+	report := &cleanupReport{ManagerNamespace: "ambassador"}
+	cc.sweep(ctx, report)
+	cc.verify(ctx, report)
+	assert.Empty(t, report.Errors)
+	assert.Empty(t, report.Restarted)
+	assert.Equal(t, []string{"StatefulSet echo.echo (traffic-agent in pod template)"}, report.Remaining)
+}
+
+func TestFindManagerNamespace(t *testing.T) {
+	ctx := context.Background()
+	ns, err := findManagerNamespace(ctx, fake.NewSimpleClientset(), "tel-default")
+	require.NoError(t, err)
+	assert.Equal(t, "tel-default", ns)
+
+	// A partial uninstall that left the webhook behind.
+	ns, err = findManagerNamespace(ctx, fake.NewSimpleClientset(injectorWebhook("agent-injector-webhook-tel", "tel")), "tel-default")
+	require.NoError(t, err)
+	assert.Equal(t, "tel", ns)
+
+	_, err = findManagerNamespace(ctx, fake.NewSimpleClientset(
+		injectorWebhook("agent-injector-webhook-tel", "tel"),
+		&core.Service{ObjectMeta: meta.ObjectMeta{
+			Name:      "traffic-manager",
+			Namespace: "ambassador",
+			Labels:    map[string]string{"app": "traffic-manager", "telepresence": "manager"},
+		}},
+	), "tel-default")
+	assert.ErrorContains(t, err, "ambassador, tel")
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/spf13/cobra"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/selfupdate"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+func upgrade() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Upgrade telepresence",
+		Args:  OnlySubcommands,
+		RunE:  RunSubcommands,
+	}
+	cmd.AddCommand(upgradeSelf())
+	return cmd
+}
+
+type upgradeSelfCommand struct {
+	channel     string
+	version     string
+	check       bool
+	keepDaemons bool
+}
+
+func upgradeSelf() *cobra.Command {
+	us := upgradeSelfCommand{}
+	cmd := &cobra.Command{
+		Use:   "self",
+		Args:  cobra.NoArgs,
+		Short: "Replace this telepresence binary with the latest release from a release channel",
+		Long: `Replace this telepresence binary with the latest release from a release channel.
+
+The channel is "stable", "latest", or the URL of a mirror that uses the same layout as the official
+download site. The default is taken from the upgrade.channel setting in the client configuration.
+The downloaded binary is verified against the release's checksum file, and when upgrade.publicKey
+is configured, also against the signature of that file. A mirror that doesn't use https requires
+upgrade.publicKey. Running daemons are stopped after the
+binary has been replaced, so that the new version is used the next time they start.`,
+		Annotations: map[string]string{
+			ann.RootDaemon: ann.Optional,
+			ann.UserDaemon: ann.Optional,
+		},
+		RunE: us.run,
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&us.channel, "channel", "", `Release channel to use: "stable", "latest", or the URL of a mirror`)
+	flags.StringVar(&us.version, "version", "", "Install this version instead of the latest version in the channel")
+	flags.BoolVar(&us.check, "check", false, "Only check if a newer version is available")
+	flags.BoolVar(&us.keepDaemons, "keep-daemons", false, "Don't stop running daemons after the upgrade")
+	return cmd
+}
+
+func (us *upgradeSelfCommand) run(cmd *cobra.Command, _ []string) error {
+	if err := connect.InitCommand(cmd); err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	u, err := selfupdate.NewUpdater(ctx, us.channel)
+	if err != nil {
+		return err
+	}
+	var target semver.Version
+	if us.version != "" {
+		if target, err = semver.Parse(strings.TrimPrefix(us.version, "v")); err != nil {
+			return errcat.User.Newf("invalid version %q: %v", us.version, err)
+		}
+	} else if target, err = u.Latest(ctx); err != nil {
+		return fmt.Errorf("unable to determine the latest version: %w", err)
+	}
+
+	out := output.Out(ctx)
+	current := client.Semver()
+	if us.version == "" && !target.GT(current) {
+		fmt.Fprintf(out, "%s is up to date\n", client.DisplayName)
+		return nil
+	}
+	if us.check {
+		fmt.Fprintf(out, "Version %s is available (current version is %s)\n", target, current)
+		return nil
+	}
+
+	exe, err := selfupdate.Executable()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(output.Info(ctx), "Upgrading %s from %s to %s\n", exe, current, target)
+	if err = u.Install(ctx, target, exe); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s upgraded to %s\n", client.DisplayName, target)
+
+	if !us.keepDaemons && daemon.GetUserClient(ctx) != nil {
+		if err = connect.Disconnect(ctx, true); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "Telepresence Daemons have been stopped and will use the new version when they are started again")
+	}
+	return nil
+}
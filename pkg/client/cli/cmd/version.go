@@ -2,15 +2,23 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
 
+	"github.com/blang/semver"
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	empty "google.golang.org/protobuf/types/known/emptypb"
 
 	"github.com/telepresenceio/telepresence/rpc/v2/common"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
 	daemonRpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/ann"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
@@ -41,6 +49,12 @@ func printVersion(cmd *cobra.Command, _ []string) error {
 	kvf := ioutil.DefaultKeyValueFormatter()
 	kvf.Add(client.DisplayName, client.Version())
 	ctx := cmd.Context()
+	cvs := []client.ComponentVersion{{Component: client.ComponentClient, Version: client.Semver()}}
+	addVersion := func(c client.Component, name, v string) {
+		if sv, err := semver.Parse(strings.TrimPrefix(v, "v")); err == nil {
+			cvs = append(cvs, client.ComponentVersion{Component: c, Name: name, Version: sv})
+		}
+	}
 
 	remote := false
 	userD := daemon.GetUserClient(ctx)
@@ -53,6 +67,7 @@ func printVersion(cmd *cobra.Command, _ []string) error {
 		switch {
 		case err == nil:
 			kvf.Add(version.Name, version.Version)
+			addVersion(client.ComponentRootDaemon, "", version.Version)
 		case err == connect.ErrNoRootDaemon:
 			kvf.Add("Root Daemon", "not running")
 		default:
@@ -64,10 +79,21 @@ func printVersion(cmd *cobra.Command, _ []string) error {
 		version, err := userD.Version(ctx, &empty.Empty{})
 		if err == nil {
 			kvf.Add(version.Name, version.Version)
+			addVersion(client.ComponentUserDaemon, "", version.Version)
 			version, err = managerVersion(ctx)
 			switch {
 			case err == nil:
 				kvf.Add(version.Name, version.Version)
+				addVersion(client.ComponentTrafficManager, "", version.Version)
+				avs := agentVersions(ctx)
+				names := make([]string, 0, len(avs))
+				for name := range avs {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					addVersion(client.ComponentTrafficAgent, name, avs[name])
+				}
 			case status.Code(err) == codes.Unavailable:
 				kvf.Add("Traffic Manager", "not connected")
 			default:
@@ -80,9 +106,70 @@ func printVersion(cmd *cobra.Command, _ []string) error {
 		kvf.Add("User Daemon", "not running")
 	}
 	kvf.Println(cmd.OutOrStdout())
+	printSkewAdvice(cmd.OutOrStdout(), client.EvaluateSkew(cvs))
 	return nil
 }
 
+// printSkewAdvice prints a compatibility matrix followed by warnings and suggested remedies for
+// the components that violate the compatibility policy.
+func printSkewAdvice(out io.Writer, advice []client.SkewAdvice) {
+	if len(advice) == 0 {
+		return
+	}
+	fmt.Fprintln(out, "\nCompatibility:")
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "  COMPONENT\tVERSION\tCOMPARED TO\tSTATUS")
+	warnings := 0
+	for i := range advice {
+		a := &advice[i]
+		name := string(a.Component)
+		if a.Name != "" {
+			name += " " + a.Name
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s %s\t%s\n", name, a.Version, a.ComparedTo, a.Reference, a.Status)
+		if a.Status != client.SkewOK {
+			warnings++
+		}
+	}
+	_ = tw.Flush()
+	if warnings == 0 {
+		return
+	}
+	fmt.Fprintln(out, "\nWarnings:")
+	for i := range advice {
+		if a := &advice[i]; a.Status != client.SkewOK {
+			fmt.Fprintf(out, "  %s\n    %s\n", a.Warning(), a.Remedy)
+		}
+	}
+}
+
+// agentVersions returns the versions of the traffic agents in the connected namespace, keyed by workload name.
+// The version is taken from the tag of the agent image.
+func agentVersions(ctx context.Context) map[string]string {
+	userD := daemon.GetUserClient(ctx)
+	if userD == nil {
+		return nil
+	}
+	r, err := userD.List(ctx, &connector.ListRequest{Filter: connector.ListRequest_INSTALLED_AGENTS})
+	if err != nil {
+		return nil
+	}
+	vs := make(map[string]string, len(r.Workloads))
+	for _, wl := range r.Workloads {
+		if wl.Sidecar == nil {
+			continue
+		}
+		var sc agentconfig.Sidecar
+		if err = json.Unmarshal(wl.Sidecar.Json, &sc); err != nil {
+			continue
+		}
+		if tag := agentconfig.ImageTag(sc.AgentImage); tag != "" {
+			vs[wl.Name] = tag
+		}
+	}
+	return vs
+}
+
 func daemonVersion(ctx context.Context) (*common.VersionInfo, error) {
 	if conn, err := socket.Dial(ctx, socket.RootDaemonPath(ctx)); err == nil {
 		defer conn.Close()
@@ -30,9 +30,9 @@ import (
 )
 
 var (
-	ErrNoUserDaemon     = errors.New("telepresence user daemon is not running")
-	ErrNoRootDaemon     = errors.New("telepresence root daemon is not running")
-	ErrNoTrafficManager = errors.New("telepresence traffic manager is not connected")
+	ErrNoUserDaemon     = errcat.WithCode(errors.New("telepresence user daemon is not running"), errcat.CodeNoUserDaemon, nil)
+	ErrNoRootDaemon     = errcat.WithCode(errors.New("telepresence root daemon is not running"), errcat.CodeNoRootDaemon, nil)
+	ErrNoTrafficManager = errcat.WithCode(errors.New("telepresence traffic manager is not connected"), errcat.CodeNoTrafficManager, nil)
 )
 
 func UserDaemonDisconnect(ctx context.Context, quitDaemons bool) (err error) {
@@ -77,10 +77,17 @@ func RunConnect(cmd *cobra.Command, args []string) error {
 	if err := InitCommand(cmd); err != nil {
 		return err
 	}
+	ctx := cmd.Context()
 	if len(args) == 0 {
+		// The connection is used by the shell that ran this command.
+		acquireLease(ctx, os.Getppid(), "shell")
 		return nil
 	}
-	ctx := cmd.Context()
+	if acquireLease(ctx, os.Getpid(), "connect -- "+args[0]) {
+		defer func() {
+			_, _ = daemon.GetUserClient(ctx).ReleaseLease(ctx, &connector.Lease{Pid: int32(os.Getpid())})
+		}()
+	}
 	if daemon.GetSession(ctx).Started {
 		defer func() {
 			_ = Disconnect(ctx, false)
@@ -89,16 +96,34 @@ func RunConnect(cmd *cobra.Command, args []string) error {
 	return proc.Run(dos.WithStdio(ctx, cmd), nil, args[0], args[1:]...)
 }
 
+// acquireLease tells the user daemon that the process with the given pid uses the connection. The
+// lease is skipped when the daemon runs in a container, because it can't see the processes of the
+// host, and it's not an error if the daemon doesn't support leases.
+func acquireLease(ctx context.Context, pid int, holder string) bool {
+	ud := daemon.GetUserClient(ctx)
+	if ud == nil || ud.Remote() || daemon.GetSession(ctx) == nil {
+		return false
+	}
+	_, err := ud.AcquireLease(ctx, &connector.Lease{Pid: int32(pid), Holder: holder})
+	if err != nil {
+		if status.Code(err) != codes.Unimplemented {
+			dlog.Debugf(ctx, "unable to acquire lease: %v", err)
+		}
+		return false
+	}
+	return true
+}
+
 func launchConnectorDaemon(ctx context.Context, connectorDaemon string, required bool) (context.Context, *daemon.UserClient, error) {
 	cr := daemon.GetRequest(ctx)
 
 	// Try dialing the host daemon using the well known socket.
-	conn, err := socket.Dial(ctx, socket.UserDaemonPath(ctx))
+	conn, err := socket.Dial(ctx, socket.UserDaemonPath(ctx), client.GetConfig(ctx).Grpc().DialOptions(client.ChannelConnector)...)
 	if err == nil {
 		if cr.Docker {
 			return ctx, nil, errcat.User.New("option --docker cannot be used as long as a daemon is running on the host. Try telepresence quit -s")
 		}
-		return ctx, newUserDaemon(conn, nil), nil
+		return ctx, newUserDaemon(ctx, conn, nil), nil
 	}
 	if !errors.Is(err, os.ErrNotExist) {
 		return ctx, nil, errcat.NoDaemonLogs.New(err)
@@ -108,7 +133,10 @@ func launchConnectorDaemon(ctx context.Context, connectorDaemon string, required
 	ctx = docker.EnableClient(ctx)
 	conn, daemonID, err := docker.DiscoverDaemon(ctx, cr.Use)
 	if err == nil {
-		return ctx, newUserDaemon(conn, daemonID), nil
+		if len(cr.Expose) > 0 {
+			fmt.Fprintln(output.Info(ctx), "The daemon container is already running, so --expose has no effect. Try telepresence quit first")
+		}
+		return ctx, newUserDaemon(ctx, conn, daemonID), nil
 	}
 	var infoMatchErr daemon.InfoMatchError
 	if errors.As(err, &infoMatchErr) {
@@ -131,7 +159,7 @@ func launchConnectorDaemon(ctx context.Context, connectorDaemon string, required
 		if err != nil {
 			return ctx, nil, errcat.NoDaemonLogs.New(err)
 		}
-		return ctx, newUserDaemon(conn, daemonID), nil
+		return ctx, newUserDaemon(ctx, conn, daemonID), nil
 	}
 
 	fmt.Fprintln(output.Info(ctx), "Launching Telepresence User Daemon")
@@ -151,16 +179,16 @@ func launchConnectorDaemon(ctx context.Context, connectorDaemon string, required
 	if err = socket.WaitUntilAppears("connector", socket.UserDaemonPath(ctx), 10*time.Second); err != nil {
 		return ctx, nil, errcat.NoDaemonLogs.Newf("connector service did not start: %w", err)
 	}
-	conn, err = socket.Dial(ctx, socket.UserDaemonPath(ctx))
+	conn, err = socket.Dial(ctx, socket.UserDaemonPath(ctx), client.GetConfig(ctx).Grpc().DialOptions(client.ChannelConnector)...)
 	if err != nil {
 		return ctx, nil, err
 	}
-	return ctx, newUserDaemon(conn, nil), nil
+	return ctx, newUserDaemon(ctx, conn, nil), nil
 }
 
-func newUserDaemon(conn *grpc.ClientConn, daemonID *daemon.Identifier) *daemon.UserClient {
+func newUserDaemon(ctx context.Context, conn *grpc.ClientConn, daemonID *daemon.Identifier) *daemon.UserClient {
 	return &daemon.UserClient{
-		ConnectorClient: connector.NewConnectorClient(conn),
+		ConnectorClient: daemon.NewLockingClient(ctx, connector.NewConnectorClient(conn), daemonID),
 		Conn:            conn,
 		DaemonID:        daemonID,
 	}
@@ -174,15 +202,16 @@ func ensureUserDaemon(ctx context.Context, required bool) (context.Context, erro
 	if addr := client.GetEnv(ctx).UserDaemonAddress; addr != "" {
 		// Assume that the user daemon is running and connect to it using the given address instead of using a socket.
 		// NOTE: The UserDaemonAddress does not imply that the daemon runs in Docker
-		conn, err := grpc.DialContext(ctx, addr,
+		conn, err := grpc.DialContext(ctx, addr, append([]grpc.DialOption{
 			grpc.WithTransportCredentials(insecure.NewCredentials()),
 			grpc.WithNoProxy(),
 			grpc.WithBlock(),
-			grpc.FailOnNonTempDialError(true))
+			grpc.FailOnNonTempDialError(true),
+		}, client.GetConfig(ctx).Grpc().DialOptions(client.ChannelConnector)...)...)
 		if err != nil {
 			return ctx, err
 		}
-		ud = newUserDaemon(conn, nil)
+		ud = newUserDaemon(ctx, conn, nil)
 	} else {
 		var err error
 		ctx, ud, err = launchConnectorDaemon(ctx, client.GetExe(), required)
@@ -256,7 +285,7 @@ func connectSession(cmd *cobra.Command, userD *daemon.UserClient, request *daemo
 				cat = errcat.Category(ci.ErrorCategory)
 			}
 		}
-		return nil, cat.Newf("connector.Connect: %s", msg)
+		return nil, errcat.WithCode(cat.Newf("connector.Connect: %s", msg), errcat.Code("CONNECT_"+ci.Error.String()), nil)
 	}
 
 	if request.Implicit {
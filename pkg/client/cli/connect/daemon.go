@@ -6,17 +6,24 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/blang/semver"
 	"google.golang.org/grpc"
 	empty "google.golang.org/protobuf/types/known/emptypb"
 
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
 	rpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/paths"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/socket"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/wsl"
 	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
 	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
 	"github.com/telepresenceio/telepresence/v2/pkg/proc"
@@ -47,8 +54,18 @@ func launchDaemon(ctx context.Context, cr *daemon.Request) error {
 	if cr != nil && cr.RootDaemonProfilingPort > 0 {
 		args = append(args, "--pprof", strconv.Itoa(int(cr.RootDaemonProfilingPort)))
 	}
+	args = append(args, paths.RootDaemonFlags(ctx)...)
 	args = append(args, logDir, filelocation.AppUserConfigDir(ctx))
-	return proc.StartInBackgroundAsRoot(ctx, args...)
+	if err := proc.StartInBackgroundAsRoot(ctx, args...); err != nil {
+		return errcat.WithCode(err, errcat.CodeRootDaemonPermission, &RootDaemonDetails{Command: args})
+	}
+	return nil
+}
+
+// RootDaemonDetails are the details of an error with code errcat.CodeRootDaemonPermission.
+type RootDaemonDetails struct {
+	// Command is the command that starts the root daemon. It must be run with elevated privileges.
+	Command []string `json:"command"`
 }
 
 // ensureRootDaemonRunning ensures that the daemon is running.
@@ -66,8 +83,136 @@ func ensureRootDaemonRunning(ctx context.Context) error {
 		// Always assume that root daemon is running when a user daemon address is provided
 		return nil
 	}
+	if wsl.Bridged(ctx) {
+		// The root daemon on the Windows host is used. It's started on Windows.
+		return nil
+	}
 	running, err := socket.IsRunning(ctx, socket.RootDaemonPath(ctx))
-	if err != nil || running {
+	if err != nil {
+		return err
+	}
+	if running {
+		return upgradeRootDaemon(ctx, cr)
+	}
+	if err = launchDaemon(ctx, cr); err != nil {
+		return fmt.Errorf("failed to launch the daemon service: %w", err)
+	}
+	if err = socket.WaitUntilRunning(ctx, "daemon", socket.RootDaemonPath(ctx), 10*time.Second); err != nil {
+		return fmt.Errorf("daemon service did not start: %w", err)
+	}
+	return nil
+}
+
+// ensureRootDaemonForRouting ensures that the root daemon is running when it's needed for outbound routing. A
+// command that uses a session only needs it when it's about to establish a new session, and the connect request
+// doesn't say --no-routing.
+func ensureRootDaemonForRouting(ctx context.Context, sessionCmd bool) error {
+	if sessionCmd {
+		if cr := daemon.GetRequest(ctx); cr != nil && cr.NoRouting {
+			return nil
+		}
+		ci, err := daemon.GetUserClient(ctx).Status(ctx, &empty.Empty{})
+		if err != nil {
+			return err
+		}
+		if ci.Error != connector.ConnectInfo_DISCONNECTED {
+			// Routing was decided when the current session was established.
+			return nil
+		}
+	}
+	return ensureRootDaemonRunning(ctx)
+}
+
+// rootDaemonVersion returns the version of the running root daemon.
+func rootDaemonVersion(ctx context.Context) (string, error) {
+	conn, err := socket.Dial(ctx, socket.RootDaemonPath(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	vi, err := rpc.NewDaemonClient(conn).Version(ctx, &empty.Empty{})
+	if err != nil {
+		return "", err
+	}
+	return vi.Version, nil
+}
+
+// rootDaemonUpgrade is the way that a running root daemon is replaced with the root daemon of this client.
+type rootDaemonUpgrade int
+
+const (
+	// upgradeNone means that the running root daemon is kept. The version check reports a mismatch.
+	upgradeNone rootDaemonUpgrade = iota
+
+	// upgradeHandover means that a new root daemon takes over the session of the running root daemon.
+	upgradeHandover
+
+	// upgradeRestart means that the running root daemon is quit before a new root daemon is started.
+	upgradeRestart
+)
+
+// rootDaemonUpgradeFor returns how a running root daemon of the given version is upgraded.
+func rootDaemonUpgradeFor(rdVersion string) rootDaemonUpgrade {
+	if rdVersion == client.Version() {
+		return upgradeNone
+	}
+	rv, err := semver.Parse(strings.TrimPrefix(rdVersion, "v"))
+	if err != nil || client.CheckHandoverCompatibility(rv, client.Semver()) != nil {
+		return upgradeNone
+	}
+	if !client.SupportsHandover(rv) {
+		return upgradeRestart
+	}
+	return upgradeHandover
+}
+
+// upgradeRootDaemon launches a new root daemon when the running root daemon is older than this client. The
+// new daemon takes over the session of the running daemon, so the user isn't forced to reconnect. A running
+// daemon that doesn't accept handover requests, or that fails to hand over its session, is restarted. Nothing
+// happens if the versions are equal or if the running daemon is newer, in which case the version check will
+// report the mismatch.
+func upgradeRootDaemon(ctx context.Context, cr *daemon.Request) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	rdVersion, err := rootDaemonVersion(ctx)
+	if err != nil {
+		return err
+	}
+	switch rootDaemonUpgradeFor(rdVersion) {
+	case upgradeNone:
+		return nil
+	case upgradeRestart:
+		fmt.Fprintf(output.Info(ctx), "Restarting Telepresence Root Daemon %s as %s\n", rdVersion, client.Version())
+		return restartRootDaemon(ctx, cr)
+	}
+	fmt.Fprintf(output.Info(ctx), "Upgrading Telepresence Root Daemon from %s to %s\n", rdVersion, client.Version())
+	if err = launchDaemon(ctx, cr); err != nil {
+		return fmt.Errorf("failed to launch the daemon service: %w", err)
+	}
+	giveUp := time.Now().Add(15 * time.Second)
+	for giveUp.After(time.Now()) {
+		time.Sleep(250 * time.Millisecond)
+		if rdVersion, err = rootDaemonVersion(ctx); err == nil && rdVersion == client.Version() {
+			return nil
+		}
+	}
+	dlog.Errorf(ctx, "timeout while waiting for the upgraded daemon service to take over, restarting it instead")
+	return restartRootDaemon(ctx, cr)
+}
+
+// restartRootDaemon quits the running root daemon and launches a new one.
+func restartRootDaemon(ctx context.Context, cr *daemon.Request) error {
+	conn, err := socket.Dial(ctx, socket.RootDaemonPath(ctx))
+	if err != nil {
+		return err
+	}
+	_, err = rpc.NewDaemonClient(conn).Quit(ctx, &empty.Empty{})
+	conn.Close()
+	if err != nil {
+		return fmt.Errorf("error when quitting root daemon: %w", err)
+	}
+	if err = socket.WaitUntilVanishes("root daemon", socket.RootDaemonPath(ctx), 5*time.Second); err != nil {
 		return err
 	}
 	if err = launchDaemon(ctx, cr); err != nil {
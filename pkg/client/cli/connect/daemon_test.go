@@ -0,0 +1,34 @@
+package connect
+
+import (
+	"testing"
+
+	"github.com/blang/semver"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/version"
+)
+
+func TestRootDaemonUpgradeFor(t *testing.T) {
+	defer func(v string, sv semver.Version) {
+		version.Version, version.Structured = v, sv
+	}(version.Version, version.Structured)
+	version.Version, version.Structured = "v2.16.1", semver.MustParse("2.16.1")
+
+	tests := []struct {
+		running string
+		want    rootDaemonUpgrade
+	}{
+		{"v2.16.1", upgradeNone},
+		{"v2.17.0", upgradeNone},
+		{"v1.14.0", upgradeNone},
+		{"garbage", upgradeNone},
+		{"v2.15.1", upgradeRestart},
+		{"v2.16.0", upgradeHandover},
+	}
+	for _, tt := range tests {
+		t.Run(tt.running, func(t *testing.T) {
+			assert.Equal(t, tt.want, rootDaemonUpgradeFor(tt.running))
+		})
+	}
+}
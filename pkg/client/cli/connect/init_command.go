@@ -32,7 +32,6 @@ func CommandInitializer(cmd *cobra.Command) (err error) {
 
 	if v, ok := as[ann.Session]; ok {
 		as[ann.UserDaemon] = v
-		as[ann.RootDaemon] = v
 		as[ann.VersionCheck] = ann.Required
 	}
 	if v := as[ann.UserDaemon]; v == ann.Optional || v == ann.Required {
@@ -52,10 +51,11 @@ func CommandInitializer(cmd *cobra.Command) (err error) {
 		}
 		cmd.SetContext(ctx)
 
-		// RootDaemon == Optional means that the RootDaemon must be started if
-		// the UserClient was started
-		if _, ok := as[ann.RootDaemon]; ok {
-			if err = ensureRootDaemonRunning(ctx); err != nil {
+		// RootDaemon == Required means that the RootDaemon must be started, although only when a
+		// session is about to be established if the command uses a session. RootDaemon == Optional
+		// means that the command uses the RootDaemon if it is running, but never starts it.
+		if as[ann.RootDaemon] == ann.Required {
+			if err = ensureRootDaemonForRouting(ctx, as[ann.Session] != ""); err != nil {
 				return err
 			}
 		}
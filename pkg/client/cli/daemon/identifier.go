@@ -35,6 +35,10 @@ func (id *Identifier) InfoFileName() string {
 	return id.String() + ".json"
 }
 
+func (id *Identifier) LockFileName() string {
+	return id.String() + ".lock"
+}
+
 func (id *Identifier) ContainerName() string {
 	return "tp-" + id.String()
 }
@@ -2,8 +2,14 @@ package daemon
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
+	"net"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -14,6 +20,7 @@ import (
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cache"
 	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
 	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+	"github.com/telepresenceio/telepresence/v2/pkg/proc"
 )
 
 type Info struct {
@@ -23,6 +30,90 @@ type Info struct {
 	KubeContext string            `json:"kube_context,omitempty"`
 	Namespace   string            `json:"namespace,omitempty"`
 	DaemonPort  int               `json:"daemon_port,omitempty"`
+
+	// DockerHost is the host of the remote docker engine that runs the daemon container. The daemon's
+	// port is then published on the remote host, and not on this machine.
+	DockerHost string `json:"docker_host,omitempty"`
+
+	// Checksum is a sha256 of the JSON representation of the Info with an empty checksum. It is
+	// used to detect files that were partially written by a daemon that crashed. Files written by
+	// older versions will not have a checksum, and are not verified.
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// CorruptInfoError is returned when an Info file cannot be parsed or when its checksum doesn't match
+// its content.
+type CorruptInfoError struct {
+	file string
+	err  error
+}
+
+func (e *CorruptInfoError) Error() string {
+	return fmt.Sprintf("daemon info %s is corrupt: %v", e.file, e.err)
+}
+
+func (e *CorruptInfoError) Unwrap() error {
+	return e.err
+}
+
+func (info *Info) computeChecksum() (string, error) {
+	ic := *info
+	ic.Checksum = ""
+	data, err := json.Marshal(&ic)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (info *Info) verify() error {
+	if info.Checksum == "" {
+		return nil
+	}
+	cs, err := info.computeChecksum()
+	if err != nil {
+		return err
+	}
+	if cs != info.Checksum {
+		return errors.New("checksum mismatch")
+	}
+	return nil
+}
+
+// Address returns the address that a client should use when dialing the daemon described by this Info.
+func (info *Info) Address(daemonID *Identifier) string {
+	if proc.RunningInContainer() {
+		// Containers use the daemon container DNS name
+		return fmt.Sprintf("%s:%d", daemonID.ContainerName(), info.DaemonPort)
+	}
+	// The host relies on that the daemon has exposed a port to localhost
+	return fmt.Sprintf(":%d", info.DaemonPort)
+}
+
+// IsAlive probes the daemon described by this Info by attempting to establish a connection to its
+// port. A daemon that doesn't respond within the given timeout is considered dead.
+func (info *Info) IsAlive(ctx context.Context, timeout time.Duration) bool {
+	if info.DaemonPort <= 0 {
+		// Nothing to probe. Rely on the keep-alive timestamp.
+		return true
+	}
+	if info.DockerHost != "" {
+		// Probing the port on the remote host requires an ssh round trip. Rely on the keep-alive timestamp.
+		return true
+	}
+	daemonID, err := NewIdentifier(info.Name, info.KubeContext, info.Namespace)
+	if err != nil {
+		return false
+	}
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", info.Address(daemonID))
+	if err != nil {
+		dlog.Debugf(ctx, "daemon %s is not responding: %v", info.Name, err)
+		return false
+	}
+	_ = conn.Close()
+	return true
 }
 
 // SafeContainerName returns a string that can safely be used as an argument
@@ -49,17 +140,31 @@ func SafeContainerName(name string) string {
 const (
 	daemonsDirName    = "daemons"
 	keepAliveInterval = 5 * time.Second
+	staleAge          = keepAliveInterval + 600*time.Millisecond
+	aliveProbeTimeout = 250 * time.Millisecond
 )
 
 func LoadInfo(ctx context.Context, file string) (*Info, error) {
 	var di Info
 	if err := cache.LoadFromUserCache(ctx, &di, filepath.Join(daemonsDirName, file)); err != nil {
+		var se *json.SyntaxError
+		if errors.As(err, &se) || errors.Is(err, io.ErrUnexpectedEOF) {
+			err = &CorruptInfoError{file: file, err: err}
+		}
 		return nil, err
 	}
+	if err := di.verify(); err != nil {
+		return nil, &CorruptInfoError{file: file, err: err}
+	}
 	return &di, nil
 }
 
 func SaveInfo(ctx context.Context, object *Info, file string) error {
+	cs, err := object.computeChecksum()
+	if err != nil {
+		return err
+	}
+	object.Checksum = cs
 	return cache.SaveToUserCache(ctx, object, filepath.Join(daemonsDirName, file))
 }
 
@@ -81,15 +186,87 @@ func LoadInfos(ctx context.Context) ([]*Info, error) {
 		return nil, err
 	}
 
-	DaemonInfos := make([]*Info, len(files))
-	for i, file := range files {
-		if err = cache.LoadFromUserCache(ctx, &DaemonInfos[i], filepath.Join(daemonsDirName, file.Name())); err != nil {
+	DaemonInfos := make([]*Info, 0, len(files))
+	for _, file := range files {
+		di, err := loadOrDeleteInfo(ctx, file.Name())
+		if err != nil {
 			return nil, err
 		}
+		if di != nil {
+			DaemonInfos = append(DaemonInfos, di)
+		}
 	}
 	return DaemonInfos, nil
 }
 
+// loadOrDeleteInfo loads the given Info file. If the file turns out to be corrupt, it is deleted
+// and a nil Info is returned.
+func loadOrDeleteInfo(ctx context.Context, file string) (*Info, error) {
+	di, err := LoadInfo(ctx, file)
+	if err != nil {
+		var ce *CorruptInfoError
+		if errors.As(err, &ce) {
+			dlog.Debugf(ctx, "Deleting %v", err)
+			return nil, DeleteInfo(ctx, file)
+		}
+		if os.IsNotExist(err) {
+			// Removed by someone else after the directory was read
+			err = nil
+		}
+		return nil, err
+	}
+	return di, nil
+}
+
+// PruneInfos removes all Info files that are stale, corrupt, or that describe a daemon that
+// doesn't respond. The names of the removed files are returned.
+func PruneInfos(ctx context.Context) ([]string, error) {
+	files, err := os.ReadDir(filepath.Join(filelocation.AppUserCacheDir(ctx), daemonsDirName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	var pruned []string
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		fi, err := file.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return pruned, err
+		}
+		if age := time.Since(fi.ModTime()); age <= staleAge {
+			di, err := LoadInfo(ctx, name)
+			if err == nil && di.IsAlive(ctx, aliveProbeTimeout) {
+				continue
+			}
+			if err != nil {
+				var ce *CorruptInfoError
+				if !errors.As(err, &ce) {
+					if os.IsNotExist(err) {
+						continue
+					}
+					return pruned, err
+				}
+			}
+		}
+		dlog.Debugf(ctx, "Pruning daemon info %s", name)
+		if err = DeleteInfo(ctx, name); err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, name)
+	}
+	return pruned, nil
+}
+
+// infoFiles returns the Info files that have been kept alive by their daemon. Files that have
+// gone stale or that are corrupt are deleted.
 func infoFiles(ctx context.Context) ([]fs.DirEntry, error) {
 	files, err := os.ReadDir(filepath.Join(filelocation.AppUserCacheDir(ctx), daemonsDirName))
 	if err != nil {
@@ -102,10 +279,13 @@ func infoFiles(ctx context.Context) ([]fs.DirEntry, error) {
 	for _, file := range files {
 		fi, err := file.Info()
 		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
 			return nil, err
 		}
 		age := time.Since(fi.ModTime())
-		if age > keepAliveInterval+600*time.Millisecond {
+		if age > staleAge {
 			// File has gone stale
 			dlog.Debugf(ctx, "Deleting stale info %s with age = %s", file.Name(), age)
 			if err = cache.DeleteFromUserCache(ctx, filepath.Join(daemonsDirName, file.Name())); err != nil {
@@ -129,7 +309,7 @@ func LoadMatchingInfo(ctx context.Context, match *regexp.Regexp) (*Info, error)
 	if err != nil {
 		return nil, err
 	}
-	var found string
+	var found []*Info
 	for _, file := range files {
 		name := file.Name()
 		if !strings.HasSuffix(name, ".json") {
@@ -137,20 +317,37 @@ func LoadMatchingInfo(ctx context.Context, match *regexp.Regexp) (*Info, error)
 		}
 		// If a match is given, then strip ".json" and apply it.
 		if match == nil || match.MatchString(name[:len(name)-5]) {
-			if found != "" {
-				if match == nil {
-					return nil, errcat.User.New(InfoMatchError("multiple daemons are running, please select one using the --use <match> flag"))
-				}
-				return nil, errcat.User.New(
-					InfoMatchError(fmt.Sprintf("the expression %q does not uniquely identify a running daemon", match.String())))
+			di, err := loadOrDeleteInfo(ctx, name)
+			if err != nil {
+				return nil, err
+			}
+			if di != nil {
+				found = append(found, di)
 			}
-			found = name
 		}
 	}
-	if found == "" {
+	if len(found) > 1 {
+		// Entries left behind by daemons that crashed shouldn't make the match ambiguous, so
+		// disregard the ones that don't respond.
+		alive := found[:0]
+		for _, di := range found {
+			if di.IsAlive(ctx, aliveProbeTimeout) {
+				alive = append(alive, di)
+			}
+		}
+		found = alive
+	}
+	switch len(found) {
+	case 0:
 		return nil, os.ErrNotExist
+	case 1:
+		return found[0], nil
+	}
+	if match == nil {
+		return nil, errcat.User.New(InfoMatchError("multiple daemons are running, please select one using the --use <match> flag"))
 	}
-	return LoadInfo(ctx, found)
+	return nil, errcat.User.New(
+		InfoMatchError(fmt.Sprintf("the expression %q does not uniquely identify a running daemon", match.String())))
 }
 
 // KeepInfoAlive updates the access and modification times of the given Info
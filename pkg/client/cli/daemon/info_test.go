@@ -0,0 +1,74 @@
+package daemon_test
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+func TestInfoChecksum(t *testing.T) {
+	ctx := filelocation.WithAppUserCacheDir(dlog.NewTestContext(t, false), t.TempDir())
+	info := &daemon.Info{Name: "the-cure", KubeContext: "the-cure", Namespace: "ns1"}
+	require.NoError(t, daemon.SaveInfo(ctx, info, "the-cure-ns1.json"))
+	assert.NotEmpty(t, info.Checksum)
+
+	loaded, err := daemon.LoadInfo(ctx, "the-cure-ns1.json")
+	require.NoError(t, err)
+	assert.Equal(t, info, loaded)
+
+	// Tamper with the file without updating the checksum
+	path := filepath.Join(filelocation.AppUserCacheDir(ctx), "daemons", "the-cure-ns1.json")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	data = regexp.MustCompile(`"ns1"`).ReplaceAll(data, []byte(`"ns2"`))
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	_, err = daemon.LoadInfo(ctx, "the-cure-ns1.json")
+	var ce *daemon.CorruptInfoError
+	assert.ErrorAs(t, err, &ce)
+}
+
+func TestLoadMatchingInfoRemovesCorrupt(t *testing.T) {
+	ctx := filelocation.WithAppUserCacheDir(dlog.NewTestContext(t, false), t.TempDir())
+	require.NoError(t, daemon.SaveInfo(ctx, &daemon.Info{Name: "a", Namespace: "ns1"}, "a-ns1.json"))
+
+	dir := filepath.Join(filelocation.AppUserCacheDir(ctx), "daemons")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a-ns2.json"), []byte(`{"name":"a","nam`), 0o600))
+
+	info, err := daemon.LoadMatchingInfo(ctx, regexp.MustCompile(`^a-`))
+	require.NoError(t, err)
+	assert.Equal(t, "ns1", info.Namespace)
+
+	exists, err := daemon.InfoExists(ctx, "a-ns2.json")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestPruneInfos(t *testing.T) {
+	ctx := filelocation.WithAppUserCacheDir(dlog.NewTestContext(t, false), t.TempDir())
+	require.NoError(t, daemon.SaveInfo(ctx, &daemon.Info{Name: "alive", Namespace: "ns1"}, "alive-ns1.json"))
+	require.NoError(t, daemon.SaveInfo(ctx, &daemon.Info{Name: "stale", Namespace: "ns1"}, "stale-ns1.json"))
+
+	old := time.Now().Add(-time.Minute)
+	dir := filepath.Join(filelocation.AppUserCacheDir(ctx), "daemons")
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "stale-ns1.json"), old, old))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "corrupt-ns1.json"), []byte(`{`), 0o600))
+
+	pruned, err := daemon.PruneInfos(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"corrupt-ns1.json", "stale-ns1.json"}, pruned)
+
+	infos, err := daemon.LoadInfos(ctx)
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, "alive", infos[0].Name)
+}
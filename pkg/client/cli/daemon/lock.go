@@ -0,0 +1,133 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rogpeppe/go-internal/lockedfile"
+	"google.golang.org/grpc"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+const (
+	locksDirName = "locks"
+
+	// hostDaemonLockFileName is the name of the lock file of the user daemon that runs on the host.
+	hostDaemonLockFileName = "connector.lock"
+
+	// lockWaitNotice is how long a command waits for the lock before it tells the user that it's waiting.
+	lockWaitNotice = 200 * time.Millisecond
+)
+
+// lockingClient is a ConnectorClient that holds an advisory lock, one per connection, during the calls that
+// change the state of the connection, so that the calls of simultaneous telepresence commands, e.g. from scripts
+// that intercept and leave in parallel, don't interleave. The lock is released when the call returns, so a
+// command that goes on to run a process or a handler doesn't block the commands of other shells. A command that
+// has to wait for the lock says so. Disconnect and Quit don't take the lock, so that a stuck command can always
+// be ended.
+type lockingClient struct {
+	connector.ConnectorClient
+	lockFile string
+}
+
+// NewLockingClient returns a ConnectorClient that serializes the calls that change the state of the connection to
+// the user daemon with the given identifier, or to the user daemon on the host when the identifier is nil.
+func NewLockingClient(ctx context.Context, cc connector.ConnectorClient, daemonID *Identifier) connector.ConnectorClient {
+	name := hostDaemonLockFileName
+	if daemonID != nil {
+		name = daemonID.LockFileName()
+	}
+	return &lockingClient{
+		ConnectorClient: cc,
+		lockFile:        filepath.Join(filelocation.AppUserCacheDir(ctx), locksDirName, name),
+	}
+}
+
+// lock acquires the lock. It waits until the lock is released by the command that holds it, or until the context
+// is cancelled. A lock that can't be created is logged and ignored, because it's only advisory.
+func (c *lockingClient) lock(ctx context.Context) (func(), error) {
+	noop := func() {}
+	if err := os.MkdirAll(filepath.Dir(c.lockFile), 0o700); err != nil {
+		dlog.Debugf(ctx, "unable to create lock %s: %v", c.lockFile, err)
+		return noop, nil
+	}
+	type lockResult struct {
+		unlock func()
+		err    error
+	}
+	ch := make(chan lockResult, 1)
+	go func() {
+		unlock, err := lockedfile.MutexAt(c.lockFile).Lock()
+		ch <- lockResult{unlock: unlock, err: err}
+	}()
+	result := func(r lockResult) (func(), error) {
+		if r.err != nil {
+			dlog.Debugf(ctx, "unable to acquire lock %s: %v", c.lockFile, r.err)
+			return noop, nil
+		}
+		return r.unlock, nil
+	}
+	select {
+	case r := <-ch:
+		return result(r)
+	case <-time.After(lockWaitNotice):
+		fmt.Fprintln(output.Info(ctx), "Waiting for other telepresence command to finish...")
+	case <-ctx.Done():
+	}
+	select {
+	case r := <-ch:
+		return result(r)
+	case <-ctx.Done():
+		// Release the lock when the goroutine eventually acquires it.
+		go func() {
+			if r := <-ch; r.err == nil {
+				r.unlock()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+func (c *lockingClient) Connect(ctx context.Context, in *connector.ConnectRequest, opts ...grpc.CallOption) (*connector.ConnectInfo, error) {
+	unlock, err := c.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return c.ConnectorClient.Connect(ctx, in, opts...)
+}
+
+func (c *lockingClient) CreateIntercept(ctx context.Context, in *connector.CreateInterceptRequest, opts ...grpc.CallOption) (*connector.InterceptResult, error) {
+	unlock, err := c.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return c.ConnectorClient.CreateIntercept(ctx, in, opts...)
+}
+
+func (c *lockingClient) RemoveIntercept(ctx context.Context, in *manager.RemoveInterceptRequest2, opts ...grpc.CallOption) (*connector.InterceptResult, error) {
+	unlock, err := c.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return c.ConnectorClient.RemoveIntercept(ctx, in, opts...)
+}
+
+func (c *lockingClient) UpdateIntercept(ctx context.Context, in *manager.UpdateInterceptRequest, opts ...grpc.CallOption) (*manager.InterceptInfo, error) {
+	unlock, err := c.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return c.ConnectorClient.UpdateIntercept(ctx, in, opts...)
+}
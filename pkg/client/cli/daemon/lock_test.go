@@ -0,0 +1,137 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/dos"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+// leaveClient is a ConnectorClient that only implements CreateIntercept, RemoveIntercept, and Quit.
+type leaveClient struct {
+	connector.ConnectorClient
+	called chan string
+}
+
+func (c *leaveClient) CreateIntercept(_ context.Context, in *connector.CreateInterceptRequest, _ ...grpc.CallOption) (*connector.InterceptResult, error) {
+	c.called <- in.Spec.Name
+	return &connector.InterceptResult{}, nil
+}
+
+func (c *leaveClient) Quit(context.Context, *empty.Empty, ...grpc.CallOption) (*empty.Empty, error) {
+	c.called <- "quit"
+	return &empty.Empty{}, nil
+}
+
+func (c *leaveClient) RemoveIntercept(_ context.Context, in *manager.RemoveInterceptRequest2, _ ...grpc.CallOption) (*connector.InterceptResult, error) {
+	c.called <- in.Name
+	return &connector.InterceptResult{}, nil
+}
+
+// syncBuffer is a bytes.Buffer that can be written and read concurrently.
+type syncBuffer struct {
+	lock sync.Mutex
+	buf  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buf.String()
+}
+
+func TestLockingClient(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	ctx = filelocation.WithAppUserCacheDir(ctx, t.TempDir())
+	out := &syncBuffer{}
+	ctx = dos.WithStdout(ctx, out)
+
+	lc := &leaveClient{called: make(chan string, 1)}
+	holder := NewLockingClient(ctx, lc, nil).(*lockingClient)
+	waiter := NewLockingClient(ctx, lc, nil)
+
+	unlock, err := holder.lock(ctx)
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := waiter.RemoveIntercept(ctx, &manager.RemoveInterceptRequest2{Name: "echo"})
+		done <- err
+	}()
+	assert.Eventually(t, func() bool { return out.String() != "" }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "Waiting for other telepresence command to finish...\n", out.String())
+	select {
+	case <-lc.called:
+		t.Fatal("call was made while the lock was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Quit doesn't wait for the lock.
+	_, err = waiter.Quit(ctx, &empty.Empty{})
+	require.NoError(t, err)
+	assert.Equal(t, "quit", <-lc.called)
+
+	unlock()
+	require.NoError(t, <-done)
+	assert.Equal(t, "echo", <-lc.called)
+
+	// The lock is released when the call returns, so a command that intercepts and then runs a long-running
+	// handler doesn't block a leave from another shell.
+	_, err = holder.CreateIntercept(ctx, &connector.CreateInterceptRequest{Spec: &manager.InterceptSpec{Name: "echo"}})
+	require.NoError(t, err)
+	assert.Equal(t, "echo", <-lc.called)
+	handlerDone := make(chan struct{})
+	defer close(handlerDone)
+	go func() {
+		// The long-running handler of the intercepting command.
+		<-handlerDone
+	}()
+	cctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	_, err = waiter.RemoveIntercept(cctx, &manager.RemoveInterceptRequest2{Name: "echo"})
+	require.NoError(t, err)
+	assert.Equal(t, "echo", <-lc.called)
+
+	// Daemons in containers have their own locks.
+	id := &Identifier{Name: "kind-default"}
+	other := NewLockingClient(ctx, lc, id).(*lockingClient)
+	assert.NotEqual(t, holder.lockFile, other.lockFile)
+}
+
+func TestLockingClient_cancel(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	ctx = filelocation.WithAppUserCacheDir(ctx, t.TempDir())
+	ctx = dos.WithStdout(ctx, &syncBuffer{})
+	holder := NewLockingClient(ctx, nil, nil).(*lockingClient)
+	unlock, err := holder.lock(ctx)
+	require.NoError(t, err)
+
+	cctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = holder.lock(cctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The lock that the cancelled waiter eventually acquires is released.
+	unlock()
+	unlock, err = holder.lock(ctx)
+	require.NoError(t, err)
+	unlock()
+}
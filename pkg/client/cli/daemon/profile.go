@@ -0,0 +1,101 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/global"
+	"github.com/telepresenceio/telepresence/v2/pkg/dos"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+	"github.com/telepresenceio/telepresence/v2/pkg/slice"
+)
+
+const profilesDirName = "profiles"
+
+// Profile is a named set of connect settings, written by "telepresence connect --interactive", that
+// "telepresence connect --profile <name>" connects with.
+type Profile struct {
+	Context          string   `yaml:"context,omitempty"`
+	Namespace        string   `yaml:"namespace,omitempty"`
+	ManagerNamespace string   `yaml:"managerNamespace,omitempty"`
+	MappedNamespaces []string `yaml:"mappedNamespaces,omitempty"`
+}
+
+var profileNameRx = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+func profileFile(ctx context.Context, name string) (string, error) {
+	if !profileNameRx.MatchString(name) {
+		return "", errcat.User.Newf("invalid profile name %q. A name consists of letters, digits, '.', '_' and '-'", name)
+	}
+	return filepath.Join(filelocation.AppUserConfigDir(ctx), profilesDirName, name+".yml"), nil
+}
+
+// SaveProfile saves the given profile under the given name in the user's configuration directory.
+func SaveProfile(ctx context.Context, name string, p *Profile) error {
+	file, err := profileFile(ctx, name)
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err = dos.MkdirAll(ctx, filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	return dos.WriteFile(ctx, file, data, 0o644)
+}
+
+// LoadProfile loads the profile with the given name from the user's configuration directory.
+func LoadProfile(ctx context.Context, name string) (*Profile, error) {
+	file, err := profileFile(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := dos.ReadFile(ctx, file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, errcat.User.Newf("profile %q not found", name)
+		}
+		return nil, err
+	}
+	var p Profile
+	if err = yaml.Unmarshal(data, &p); err != nil {
+		return nil, errcat.Config.Newf("failed to parse profile %s: %w", file, err)
+	}
+	return &p, nil
+}
+
+// ApplyProfile sets the flags of the given command that correspond to the settings of the profile. Flags
+// that were given on the command line take precedence. It must be called before CommitFlags.
+func ApplyProfile(cmd *cobra.Command, p *Profile) error {
+	set := func(name, value string) error {
+		flag := cmd.Flag(name)
+		if value == "" || flag == nil || flag.Changed {
+			return nil
+		}
+		if err := flag.Value.Set(value); err != nil {
+			return fmt.Errorf("profile: %s: %w", name, err)
+		}
+		flag.Changed = true
+		return nil
+	}
+	if err := set(global.FlagContext, p.Context); err != nil {
+		return err
+	}
+	if err := set("namespace", p.Namespace); err != nil {
+		return err
+	}
+	if err := set("manager-namespace", p.ManagerNamespace); err != nil {
+		return err
+	}
+	return set("mapped-namespaces", slice.AsCSV(p.MappedNamespaces))
+}
@@ -0,0 +1,43 @@
+package daemon_test
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+func TestProfiles(t *testing.T) {
+	ctx := filelocation.WithAppUserConfigDir(dlog.NewTestContext(t, false), t.TempDir())
+	_, err := daemon.LoadProfile(ctx, "dev")
+	assert.Equal(t, errcat.User, errcat.GetCategory(err))
+	assert.Error(t, daemon.SaveProfile(ctx, "../dev", &daemon.Profile{}))
+
+	p := &daemon.Profile{Context: "dev-ctx", Namespace: "blue", ManagerNamespace: "tp", MappedNamespaces: []string{"blue", "green"}}
+	require.NoError(t, daemon.SaveProfile(ctx, "dev", p))
+	lp, err := daemon.LoadProfile(ctx, "dev")
+	require.NoError(t, err)
+	assert.Equal(t, p, lp)
+
+	cmd := &cobra.Command{}
+	flags := cmd.Flags()
+	flags.String("context", "", "")
+	flags.String("namespace", "", "")
+	flags.String("manager-namespace", "", "")
+	flags.StringSlice("mapped-namespaces", nil, "")
+	require.NoError(t, flags.Parse([]string{"--namespace", "red"}))
+	require.NoError(t, daemon.ApplyProfile(cmd, lp))
+	assert.Equal(t, "dev-ctx", cmd.Flag("context").Value.String())
+	assert.Equal(t, "red", cmd.Flag("namespace").Value.String(), "flags on the command line take precedence")
+	assert.Equal(t, "tp", cmd.Flag("manager-namespace").Value.String())
+	ms, err := flags.GetStringSlice("mapped-namespaces")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"blue", "green"}, ms)
+	assert.True(t, cmd.Flag("manager-namespace").Changed)
+}
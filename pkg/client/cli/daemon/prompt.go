@@ -0,0 +1,82 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cache"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+	"github.com/telepresenceio/telepresence/v2/pkg/proc"
+)
+
+const promptsDirName = "prompts"
+
+// PromptInfo is a summary of a connection that is kept up to date by the user daemon. It is stored
+// in the user cache so that shell prompts can show the connection status without making RPC calls.
+type PromptInfo struct {
+	Name        string `json:"name"`
+	KubeContext string `json:"kube_context"`
+	Namespace   string `json:"namespace"`
+	Intercepts  int    `json:"intercepts"`
+
+	// PID is the process ID of the user daemon. It's used to detect files left behind by a daemon that crashed.
+	PID int `json:"pid,omitempty"`
+
+	// InDocker is true when the user daemon runs in a container, in which case the PID is meaningless on the host.
+	// The liveness of the daemon is then determined by the existence of its daemon Info.
+	InDocker bool `json:"in_docker,omitempty"`
+}
+
+// isStale returns true if the daemon that saved the PromptInfo in the given file is no longer running.
+func (pi *PromptInfo) isStale(ctx context.Context, file string) bool {
+	if pi.InDocker {
+		exists, err := InfoExists(ctx, file)
+		return err == nil && !exists
+	}
+	return pi.PID <= 0 || !proc.IsAlive(pi.PID)
+}
+
+func SavePromptInfo(ctx context.Context, id *Identifier, pi *PromptInfo) error {
+	return cache.SaveToUserCache(ctx, pi, filepath.Join(promptsDirName, id.InfoFileName()))
+}
+
+func DeletePromptInfo(ctx context.Context, id *Identifier) error {
+	return cache.DeleteFromUserCache(ctx, filepath.Join(promptsDirName, id.InfoFileName()))
+}
+
+// LoadPromptInfos returns the PromptInfo of all current connections, sorted by name. Files left behind by
+// daemons that are no longer running are deleted.
+func LoadPromptInfos(ctx context.Context) ([]*PromptInfo, error) {
+	files, err := os.ReadDir(filepath.Join(filelocation.AppUserCacheDir(ctx), promptsDirName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+		return nil, err
+	}
+	pis := make([]*PromptInfo, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		var pi PromptInfo
+		path := filepath.Join(promptsDirName, file.Name())
+		if err = cache.LoadFromUserCache(ctx, &pi, path); err != nil {
+			continue
+		}
+		if pi.isStale(ctx, file.Name()) {
+			dlog.Debugf(ctx, "Deleting stale prompt info %s", file.Name())
+			_ = cache.DeleteFromUserCache(ctx, path)
+			continue
+		}
+		pis = append(pis, &pi)
+	}
+	sort.Slice(pis, func(i, j int) bool { return pis[i].Name < pis[j].Name })
+	return pis, nil
+}
@@ -0,0 +1,70 @@
+package daemon_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+func TestPromptInfos(t *testing.T) {
+	ctx := filelocation.WithAppUserCacheDir(dlog.NewTestContext(t, false), t.TempDir())
+	pis, err := daemon.LoadPromptInfos(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, pis)
+
+	b, err := daemon.NewIdentifier("", "b-ctx", "ns")
+	require.NoError(t, err)
+	a, err := daemon.NewIdentifier("", "a-ctx", "ns")
+	require.NoError(t, err)
+	pid := os.Getpid()
+	require.NoError(t, daemon.SavePromptInfo(ctx, b, &daemon.PromptInfo{Name: b.Name, KubeContext: b.KubeContext, Namespace: b.Namespace, PID: pid}))
+	require.NoError(t, daemon.SavePromptInfo(ctx, a, &daemon.PromptInfo{Name: a.Name, KubeContext: a.KubeContext, Namespace: a.Namespace, Intercepts: 2, PID: pid}))
+
+	pis, err = daemon.LoadPromptInfos(ctx)
+	require.NoError(t, err)
+	require.Len(t, pis, 2)
+	assert.Equal(t, "a-ctx", pis[0].KubeContext)
+	assert.Equal(t, 2, pis[0].Intercepts)
+	assert.Equal(t, "b-ctx", pis[1].KubeContext)
+
+	require.NoError(t, daemon.DeletePromptInfo(ctx, a))
+	pis, err = daemon.LoadPromptInfos(ctx)
+	require.NoError(t, err)
+	require.Len(t, pis, 1)
+	assert.Equal(t, "b-ctx", pis[0].KubeContext)
+}
+
+func TestPromptInfosStale(t *testing.T) {
+	ctx := filelocation.WithAppUserCacheDir(dlog.NewTestContext(t, false), t.TempDir())
+	live, err := daemon.NewIdentifier("", "live-ctx", "ns")
+	require.NoError(t, err)
+	crashed, err := daemon.NewIdentifier("", "crashed-ctx", "ns")
+	require.NoError(t, err)
+	docker, err := daemon.NewIdentifier("", "docker-ctx", "ns")
+	require.NoError(t, err)
+	require.NoError(t, daemon.SavePromptInfo(ctx, live, &daemon.PromptInfo{Name: live.Name, PID: os.Getpid()}))
+	require.NoError(t, daemon.SavePromptInfo(ctx, crashed, &daemon.PromptInfo{Name: crashed.Name, PID: 1 << 30}))
+	require.NoError(t, daemon.SavePromptInfo(ctx, docker, &daemon.PromptInfo{Name: docker.Name, InDocker: true}))
+
+	pis, err := daemon.LoadPromptInfos(ctx)
+	require.NoError(t, err)
+	require.Len(t, pis, 1)
+	assert.Equal(t, live.Name, pis[0].Name)
+
+	files, err := os.ReadDir(filepath.Join(filelocation.AppUserCacheDir(ctx), "prompts"))
+	require.NoError(t, err)
+	assert.Len(t, files, 1, "the stale files are deleted")
+
+	require.NoError(t, daemon.SavePromptInfo(ctx, docker, &daemon.PromptInfo{Name: docker.Name, InDocker: true}))
+	require.NoError(t, daemon.SaveInfo(ctx, &daemon.Info{Name: docker.Name, InDocker: true}, docker.InfoFileName()))
+	pis, err = daemon.LoadPromptInfos(ctx)
+	require.NoError(t, err)
+	assert.Len(t, pis, 2)
+}
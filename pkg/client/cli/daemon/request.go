@@ -2,12 +2,16 @@ package daemon
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"regexp"
 	"strconv"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"google.golang.org/protobuf/types/known/emptypb"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/kubernetes"
@@ -16,7 +20,9 @@ import (
 
 	"github.com/datawire/dlib/dlog"
 	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/global"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/socket"
 	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
 	"github.com/telepresenceio/telepresence/v2/pkg/slice"
 )
@@ -27,6 +33,9 @@ type Request struct {
 	// If set, then use a containerized daemon for the connection.
 	Docker bool
 
+	// Ports of the daemon container to publish on the host when the container is started. Only valid with Docker.
+	Expose []string
+
 	// Match expression to use when finding an existing connection by name
 	Use *regexp.Regexp
 
@@ -53,6 +62,10 @@ func InitRequest(cmd *cobra.Command) *Request {
 		"mapped-namespaces", nil, ``+
 			`Comma separated list of namespaces considered by DNS resolver and NAT for outbound connections. `+
 			`Defaults to all namespaces`)
+	nwFlags.StringVar(&cr.MappedNamespaceSelector,
+		"mapped-namespace-selector", "", ``+
+			`Label selector, e.g. team=payments. Namespaces that match it are mapped, in addition to the --mapped-namespaces, `+
+			`as they are created, and unmapped when they are deleted`)
 	nwFlags.StringSliceVar(&cr.AlsoProxy,
 		"also-proxy", nil, ``+
 			`Additional comma separated list of CIDR to proxy`)
@@ -62,7 +75,17 @@ func InitRequest(cmd *cobra.Command) *Request {
 			`Comma separated list of CIDR to never proxy`)
 	nwFlags.StringVar(&cr.ManagerNamespace, "manager-namespace", "", `The namespace where the traffic manager is to be found. `+
 		`Overrides any other manager namespace set in config`)
+	nwFlags.StringToStringVar(&cr.Timeouts, "timeout", nil, ``+
+		`Override a configured timeout for this connection, e.g. --timeout trafficManagerConnect=2m. Can be repeated`)
+	nwFlags.BoolVar(&cr.NoRouting, "no-routing", false, ``+
+		`Connect without outbound routing to the cluster. The root daemon is not started, so no elevated privileges are needed`)
+	nwFlags.BoolVar(&cr.Observe, "observe", false, ``+
+		`Connect as an observer. DNS and outbound access to the cluster work, but the traffic-manager refuses all intercepts`)
 	nwFlags.Bool(global.FlagDocker, false, "Start, or connect to, daemon in a docker container")
+	nwFlags.StringArrayVar(&cr.Expose, "expose", nil, ``+
+		`Publish a port of the daemon container on the host when the container is started, using `+
+		`[<host-ip>:][<host-port>:]<container-port>[/tcp|/udp]. The host IP defaults to 127.0.0.1, and the container port `+
+		`"api" is the port of the connector's API. Requires --docker. Can be repeated`)
 	flags.AddFlagSet(nwFlags)
 
 	dbgFlags := pflag.NewFlagSet("Debug and Profiling flags", 0)
@@ -98,10 +121,16 @@ func (cr *Request) CommitFlags(cmd *cobra.Command) error {
 			cr.KubeFlags[flag.Name] = v
 		}
 	})
+	if err := (&client.Timeouts{}).Override(cr.Timeouts); err != nil {
+		return errcat.User.Newf("--timeout: %w", err)
+	}
 	cr.addKubeconfigEnv()
 	if err := cr.setGlobalConnectFlags(cmd); err != nil {
 		return err
 	}
+	if len(cr.Expose) > 0 && !cr.Docker {
+		return errcat.User.New("--expose can only be used together with --docker")
+	}
 	cmd.SetContext(context.WithValue(cmd.Context(), requestKey{}, cr))
 	return nil
 }
@@ -176,6 +205,23 @@ func WithDefaultRequest(ctx context.Context, cmd *cobra.Command) (context.Contex
 	return context.WithValue(ctx, requestKey{}, &cr), nil
 }
 
+// RESTClientGetter returns the Kubernetes flags of the request in a form that can produce Kubernetes clients.
+func (cr *Request) RESTClientGetter() genericclioptions.RESTClientGetter {
+	return cr.kubeConfig
+}
+
+// SetNamespace makes the request connect to the given namespace, regardless of the --namespace flag.
+func (cr *Request) SetNamespace(ns string) {
+	*cr.kubeConfig.Namespace = ns
+	cr.KubeFlags["namespace"] = ns
+}
+
+// SetContext makes the request connect using the given Kubernetes context, regardless of the --context flag.
+func (cr *Request) SetContext(name string) {
+	cr.kubeConfig.Context = &name
+	cr.KubeFlags[global.FlagContext] = name
+}
+
 func GetKubeStartingConfig(cmd *cobra.Command) (*api.Config, error) {
 	pathOpts := clientcmd.NewDefaultPathOptions()
 	if kcFlag := cmd.Flag("kubeconfig"); kcFlag != nil && kcFlag.Changed {
@@ -188,6 +234,11 @@ func (cr *Request) GetAllNamespaces(cmd *cobra.Command) ([]string, error) {
 	if err := cr.CommitFlags(cmd); err != nil {
 		return nil, err
 	}
+	return cr.ClusterNamespaces(cmd.Context())
+}
+
+// ClusterNamespaces lists the namespaces using a direct call to the Kubernetes API.
+func (cr *Request) ClusterNamespaces(ctx context.Context) ([]string, error) {
 	rs, err := cr.kubeConfig.ToRESTConfig()
 	if err != nil {
 		return nil, errcat.NoDaemonLogs.Newf("ToRESTConfig: %v", err)
@@ -196,7 +247,7 @@ func (cr *Request) GetAllNamespaces(cmd *cobra.Command) ([]string, error) {
 	if err != nil {
 		return nil, errcat.NoDaemonLogs.Newf("NewForConfig: %v", err)
 	}
-	nsl, err := cs.CoreV1().Namespaces().List(cmd.Context(), v1.ListOptions{})
+	nsl, err := cs.CoreV1().Namespaces().List(ctx, v1.ListOptions{})
 	if err != nil {
 		return nil, errcat.NoDaemonLogs.Newf("Namespaces.List: %v", err)
 	}
@@ -208,13 +259,43 @@ func (cr *Request) GetAllNamespaces(cmd *cobra.Command) ([]string, error) {
 	return nss, nil
 }
 
-func (cr *Request) autocompleteNamespace(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	ctx := cmd.Context()
-	nss, err := cr.GetAllNamespaces(cmd)
+// completionTimeout is the maximum time that shell completion waits for the user daemon or the cluster.
+const completionTimeout = 3 * time.Second
+
+// daemonNamespaces returns the namespaces that are cached by a running user daemon, provided that the daemon
+// is connected using the same Kubernetes flags as this request. An error is returned if no such daemon exists.
+func (cr *Request) daemonNamespaces(ctx context.Context, prefix string) ([]string, error) {
+	conn, err := socket.Dial(ctx, socket.UserDaemonPath(ctx))
 	if err != nil {
-		dlog.Error(ctx, err)
+		return nil, err
+	}
+	defer conn.Close()
+	uc := connector.NewConnectorClient(conn)
+	ci, err := uc.Status(ctx, &emptypb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	if !(ci.Error == connector.ConnectInfo_UNSPECIFIED || ci.Error == connector.ConnectInfo_ALREADY_CONNECTED) {
+		return nil, errors.New("user daemon is not connected")
+	}
+	for k, v := range cr.KubeFlags {
+		if k != "namespace" && ci.KubeFlags[k] != v {
+			return nil, fmt.Errorf("user daemon is connected using a different --%s", k)
+		}
+	}
+	r, err := uc.GetNamespaces(ctx, &connector.GetNamespacesRequest{Prefix: prefix})
+	if err != nil {
+		return nil, err
+	}
+	return r.Namespaces, nil
+}
+
+func (cr *Request) autocompleteNamespace(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if err := cr.CommitFlags(cmd); err != nil {
 		return nil, cobra.ShellCompDirectiveError
 	}
+	ctx, cancel := context.WithTimeout(cmd.Context(), completionTimeout)
+	defer cancel()
 
 	var ctName string
 	if cp := cr.kubeConfig.Context; cp != nil {
@@ -222,6 +303,17 @@ func (cr *Request) autocompleteNamespace(cmd *cobra.Command, _ []string, toCompl
 	}
 	dlog.Debugf(ctx, "namespace completion: context %q, %q", ctName, toComplete)
 
+	// Prefer the namespaces cached by the user daemon. They are instantly available and
+	// obtaining them doesn't require a call to the Kubernetes API.
+	nss, err := cr.daemonNamespaces(ctx, toComplete)
+	if err == nil {
+		return nss, cobra.ShellCompDirectiveNoFileComp
+	}
+	dlog.Debugf(ctx, "namespace completion: using cluster, because the daemon cache is unavailable: %v", err)
+	if nss, err = cr.ClusterNamespaces(ctx); err != nil {
+		dlog.Error(ctx, err)
+		return nil, cobra.ShellCompDirectiveError
+	}
 	return nss, cobra.ShellCompDirectiveNoFileComp
 }
 
@@ -0,0 +1,21 @@
+// Package dashboard contains a terminal UI that shows the state of the current connection and lets
+// the user create and remove intercepts.
+package dashboard
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Run runs the dashboard until the user quits or the context is cancelled.
+func Run(ctx context.Context, source Source, interval time.Duration, port uint16) error {
+	p := tea.NewProgram(NewModel(ctx, source, interval, port), tea.WithContext(ctx), tea.WithAltScreen())
+	_, err := p.Run()
+	if errors.Is(err, tea.ErrProgramKilled) && ctx.Err() != nil {
+		err = nil
+	}
+	return err
+}
@@ -0,0 +1,370 @@
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	daemonRpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+const helpLine = "↑/k ↓/j select • i intercept • l leave • r refresh • q quit"
+
+// refreshMsg carries the result of one poll of the Source.
+type refreshMsg struct {
+	at        time.Time
+	status    *connector.ConnectInfo
+	workloads []*connector.WorkloadInfo
+	stats     *daemonRpc.SessionStats
+	err       error
+
+	// scheduled is true for the poll that is triggered by the tick chain. Only such polls schedule the next
+	// tick, so that manual refreshes don't start additional chains.
+	scheduled bool
+}
+
+// tickMsg triggers a new poll.
+type tickMsg struct{}
+
+// actionMsg carries the result of an intercept or leave action.
+type actionMsg struct {
+	text string
+	err  error
+}
+
+// Model is the bubbletea model of the dashboard.
+type Model struct {
+	ctx      context.Context
+	source   Source
+	interval time.Duration
+	port     uint16
+
+	status    *connector.ConnectInfo
+	workloads []*connector.WorkloadInfo
+	stats     *daemonRpc.SessionStats
+	statsAt   time.Time
+	ingress   float64 // bytes per second
+	egress    float64 // bytes per second
+	cursor    int
+	busy      bool
+	message   string
+	err       error // error from the last intercept or leave action
+
+	refreshErr error // error from the last poll of the Source
+}
+
+// NewModel returns a dashboard model that polls the given source at the given interval, and that
+// creates intercepts that forward to the given local port.
+func NewModel(ctx context.Context, source Source, interval time.Duration, port uint16) *Model {
+	return &Model{
+		ctx:      ctx,
+		source:   source,
+		interval: interval,
+		port:     port,
+	}
+}
+
+func (m *Model) Init() tea.Cmd {
+	return m.poll
+}
+
+// poll refreshes the model and then schedules the next tick.
+func (m *Model) poll() tea.Msg {
+	r := m.refresh().(refreshMsg)
+	r.scheduled = true
+	return r
+}
+
+func (m *Model) refresh() tea.Msg {
+	r := refreshMsg{at: time.Now()}
+	if r.status, r.err = m.source.Status(m.ctx); r.err != nil {
+		return r
+	}
+	if r.status.Error == connector.ConnectInfo_UNSPECIFIED || r.status.Error == connector.ConnectInfo_ALREADY_CONNECTED {
+		if r.workloads, r.err = m.source.Workloads(m.ctx); r.err != nil {
+			return r
+		}
+		sort.Slice(r.workloads, func(i, j int) bool { return r.workloads[i].Name < r.workloads[j].Name })
+	}
+	r.stats, r.err = m.source.Stats(m.ctx)
+	return r
+}
+
+func (m *Model) tick() tea.Cmd {
+	return tea.Tick(m.interval, func(time.Time) tea.Msg { return tickMsg{} })
+}
+
+func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		return m, m.handleKey(msg)
+	case tickMsg:
+		return m, m.poll
+	case refreshMsg:
+		m.applyRefresh(msg)
+		if msg.scheduled {
+			return m, m.tick()
+		}
+		return m, nil
+	case actionMsg:
+		m.busy = false
+		m.message = msg.text
+		m.err = msg.err
+		return m, m.refresh
+	}
+	return m, nil
+}
+
+func (m *Model) handleKey(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "q", "ctrl+c", "esc":
+		return tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.workloads)-1 {
+			m.cursor++
+		}
+	case "r":
+		return m.refresh
+	case "i", "enter":
+		return m.interceptSelected()
+	case "l":
+		return m.leaveSelected()
+	}
+	return nil
+}
+
+func (m *Model) selected() *connector.WorkloadInfo {
+	if m.cursor < len(m.workloads) {
+		return m.workloads[m.cursor]
+	}
+	return nil
+}
+
+// ownIntercept returns this client's intercept of the given workload, or nil if there is none.
+func (m *Model) ownIntercept(wl *connector.WorkloadInfo) *manager.InterceptInfo {
+	for _, ii := range m.status.GetIntercepts().GetIntercepts() {
+		if ii.Spec.Agent == wl.Name && ii.Spec.Namespace == wl.Namespace {
+			return ii
+		}
+	}
+	return nil
+}
+
+func (m *Model) interceptSelected() tea.Cmd {
+	wl := m.selected()
+	switch {
+	case wl == nil || m.busy:
+		return nil
+	case wl.NotInterceptableReason != "":
+		m.message, m.err = "", fmt.Errorf("%s cannot be intercepted: %s", wl.Name, wl.NotInterceptableReason)
+		return nil
+	case m.ownIntercept(wl) != nil:
+		m.message, m.err = fmt.Sprintf("%s is already intercepted", wl.Name), nil
+		return nil
+	}
+	m.busy = true
+	m.message, m.err = fmt.Sprintf("Intercepting %s...", wl.Name), nil
+	name, port := wl.Name, m.port
+	return func() tea.Msg {
+		if err := m.source.Intercept(m.ctx, name, port); err != nil {
+			return actionMsg{err: fmt.Errorf("intercept %s failed: %w", name, err)}
+		}
+		return actionMsg{text: fmt.Sprintf("Intercepted %s, forwarding to 127.0.0.1:%d", name, port)}
+	}
+}
+
+func (m *Model) leaveSelected() tea.Cmd {
+	wl := m.selected()
+	if wl == nil || m.busy {
+		return nil
+	}
+	ii := m.ownIntercept(wl)
+	if ii == nil {
+		m.message, m.err = fmt.Sprintf("%s is not intercepted by this client", wl.Name), nil
+		return nil
+	}
+	m.busy = true
+	m.message, m.err = fmt.Sprintf("Leaving %s...", ii.Spec.Name), nil
+	name := ii.Spec.Name
+	return func() tea.Msg {
+		if err := m.source.Leave(m.ctx, name); err != nil {
+			return actionMsg{err: fmt.Errorf("leave %s failed: %w", name, err)}
+		}
+		return actionMsg{text: fmt.Sprintf("Left %s", name)}
+	}
+}
+
+func (m *Model) applyRefresh(r refreshMsg) {
+	m.refreshErr = r.err
+	if r.err != nil {
+		return
+	}
+	m.status = r.status
+	m.workloads = r.workloads
+	if m.cursor >= len(m.workloads) {
+		m.cursor = len(m.workloads) - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+	if r.stats != nil && m.stats != nil {
+		if secs := r.at.Sub(m.statsAt).Seconds(); secs > 0 {
+			m.ingress = rate(m.stats.IngressBytes, r.stats.IngressBytes, secs)
+			m.egress = rate(m.stats.EgressBytes, r.stats.EgressBytes, secs)
+		}
+	}
+	m.stats = r.stats
+	m.statsAt = r.at
+}
+
+func rate(prev, cur uint64, secs float64) float64 {
+	if cur < prev {
+		// counters were reset, i.e. the session was restarted
+		return 0
+	}
+	return float64(cur-prev) / secs
+}
+
+func (m *Model) View() string {
+	sb := &strings.Builder{}
+	sb.WriteString("Telepresence Dashboard\n\n")
+	m.viewConnection(sb)
+	if m.connected() {
+		sb.WriteByte('\n')
+		m.viewWorkloads(sb)
+		sb.WriteByte('\n')
+		m.viewIntercepts(sb)
+	}
+	sb.WriteByte('\n')
+	m.viewStats(sb)
+	sb.WriteByte('\n')
+	switch {
+	case m.refreshErr != nil:
+		fmt.Fprintf(sb, "Error: %v\n", m.refreshErr)
+	case m.err != nil:
+		fmt.Fprintf(sb, "Error: %v\n", m.err)
+	case m.message != "":
+		sb.WriteString(m.message + "\n")
+	default:
+		sb.WriteByte('\n')
+	}
+	sb.WriteString(helpLine + "\n")
+	return sb.String()
+}
+
+func (m *Model) connected() bool {
+	if m.status == nil {
+		return false
+	}
+	return m.status.Error == connector.ConnectInfo_UNSPECIFIED || m.status.Error == connector.ConnectInfo_ALREADY_CONNECTED
+}
+
+func (m *Model) viewConnection(sb *strings.Builder) {
+	sb.WriteString("Connection\n")
+	if !m.connected() {
+		sb.WriteString("  Not connected\n")
+		return
+	}
+	s := m.status
+	tw := tabwriter.NewWriter(sb, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "  Name\t: %s\n", s.ConnectionName)
+	fmt.Fprintf(tw, "  Context\t: %s (%s)\n", s.ClusterContext, s.ClusterServer)
+	fmt.Fprintf(tw, "  Namespace\t: %s\n", s.Namespace)
+	fmt.Fprintf(tw, "  Manager namespace\t: %s\n", s.ManagerNamespace)
+	_ = tw.Flush()
+}
+
+func (m *Model) viewWorkloads(sb *strings.Builder) {
+	fmt.Fprintf(sb, "Workloads in %s\n", m.status.Namespace)
+	if len(m.workloads) == 0 {
+		sb.WriteString("  No workloads found\n")
+		return
+	}
+	tw := tabwriter.NewWriter(sb, 0, 4, 2, ' ', 0)
+	for i, wl := range m.workloads {
+		marker := " "
+		if i == m.cursor {
+			marker = ">"
+		}
+		fmt.Fprintf(tw, "%s %s\t%s\t%s\n", marker, wl.Name, wl.WorkloadResourceType, m.workloadState(wl))
+	}
+	_ = tw.Flush()
+}
+
+func (m *Model) workloadState(wl *connector.WorkloadInfo) string {
+	switch {
+	case m.ownIntercept(wl) != nil:
+		return "intercepted by this client"
+	case len(wl.InterceptInfos) > 0:
+		clients := make([]string, len(wl.InterceptInfos))
+		for i, ii := range wl.InterceptInfos {
+			clients[i] = ii.Spec.Client
+		}
+		return "intercepted by " + strings.Join(clients, ", ")
+	case wl.NotInterceptableReason != "":
+		return "not interceptable: " + wl.NotInterceptableReason
+	case wl.Sidecar != nil:
+		return "ready to intercept (traffic-agent already installed)"
+	default:
+		return "ready to intercept (traffic-agent not yet installed)"
+	}
+}
+
+func (m *Model) viewIntercepts(sb *strings.Builder) {
+	sb.WriteString("Intercepts\n")
+	iis := m.status.GetIntercepts().GetIntercepts()
+	if len(iis) == 0 {
+		sb.WriteString("  No active intercepts\n")
+		return
+	}
+	tw := tabwriter.NewWriter(sb, 0, 4, 2, ' ', 0)
+	for _, ii := range iis {
+		fmt.Fprintf(tw, "  %s\t%s\t-> %s:%d\n", ii.Spec.Name, ii.Disposition, ii.Spec.TargetHost, ii.Spec.TargetPort)
+	}
+	_ = tw.Flush()
+}
+
+func (m *Model) viewStats(sb *strings.Builder) {
+	sb.WriteString("Tunnel & DNS\n")
+	if m.stats == nil {
+		sb.WriteString("  Statistics are not available\n")
+		return
+	}
+	s := m.stats
+	tw := tabwriter.NewWriter(sb, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "  Ingress\t: %s/s\t(%s total)\n", FormatBytes(m.ingress), FormatBytes(float64(s.IngressBytes)))
+	fmt.Fprintf(tw, "  Egress\t: %s/s\t(%s total)\n", FormatBytes(m.egress), FormatBytes(float64(s.EgressBytes)))
+	fmt.Fprintf(tw, "  Connections\t: %d\t\n", s.Connections)
+	if d := s.Dns; d != nil {
+		fmt.Fprintf(tw, "  DNS requests\t: %d\t(%d cache hits, %d failures)\n", d.Requests, d.CacheHits, d.Failures)
+	}
+	if v := s.Vif; v != nil {
+		fmt.Fprintf(tw, "  MTU\t: %d\t(%d fragments)\n", v.Mtu, v.Fragments)
+	}
+	_ = tw.Flush()
+}
+
+// FormatBytes formats a number of bytes using binary units, e.g. "1.5 MiB".
+func FormatBytes(n float64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%.0f B", n)
+	}
+	div, exp := float64(unit), 0
+	for n/div >= unit && exp < 4 {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", n/div, "KMGTP"[exp])
+}
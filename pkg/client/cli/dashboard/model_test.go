@@ -0,0 +1,139 @@
+package dashboard
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	daemonRpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+type fakeSource struct {
+	statusErr  error
+	intercepts []*manager.InterceptInfo
+	ingress    uint64
+	created    []string
+	left       []string
+}
+
+func (f *fakeSource) Status(context.Context) (*connector.ConnectInfo, error) {
+	if f.statusErr != nil {
+		return nil, f.statusErr
+	}
+	return &connector.ConnectInfo{
+		ConnectionName: "dev",
+		ClusterContext: "kind",
+		Namespace:      "default",
+		Intercepts:     &manager.InterceptInfoSnapshot{Intercepts: f.intercepts},
+	}, nil
+}
+
+func (f *fakeSource) Workloads(context.Context) ([]*connector.WorkloadInfo, error) {
+	return []*connector.WorkloadInfo{
+		{Name: "web", Namespace: "default", WorkloadResourceType: "Deployment"},
+		{Name: "api", Namespace: "default", WorkloadResourceType: "Deployment"},
+	}, nil
+}
+
+func (f *fakeSource) Stats(context.Context) (*daemonRpc.SessionStats, error) {
+	f.ingress += 4096
+	return &daemonRpc.SessionStats{IngressBytes: f.ingress, Dns: &daemonRpc.DNSStats{Requests: 3}, Vif: &daemonRpc.VIFStats{Mtu: 1400}}, nil
+}
+
+func (f *fakeSource) Intercept(_ context.Context, workload string, port uint16) error {
+	f.created = append(f.created, workload)
+	f.intercepts = append(f.intercepts, &manager.InterceptInfo{
+		Spec: &manager.InterceptSpec{Name: workload, Agent: workload, Namespace: "default", TargetHost: "127.0.0.1", TargetPort: int32(port)},
+	})
+	return nil
+}
+
+func (f *fakeSource) Leave(_ context.Context, name string) error {
+	f.left = append(f.left, name)
+	f.intercepts = nil
+	return nil
+}
+
+// run feeds msg to the model and then executes the returned commands until the model has been
+// refreshed, so that the command that schedules the next tick is never executed.
+func run(m tea.Model, msg tea.Msg) tea.Model {
+	for msg != nil {
+		var cmd tea.Cmd
+		m, cmd = m.Update(msg)
+		if _, ok := msg.(refreshMsg); ok || cmd == nil {
+			break
+		}
+		msg = cmd()
+	}
+	return m
+}
+
+func key(s string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func TestModel(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	src := &fakeSource{}
+	var m tea.Model = NewModel(ctx, src, time.Hour, 8080)
+	m = run(m, m.Init()())
+
+	view := m.View()
+	assert.Contains(t, view, "Context            : kind")
+	assert.Contains(t, view, "> api")
+	assert.Contains(t, view, "No active intercepts")
+	assert.Contains(t, view, "DNS requests")
+	assert.Contains(t, view, "MTU")
+
+	m = run(m, key("j"))
+	m = run(m, key("i"))
+	require.Equal(t, []string{"web"}, src.created)
+	view = m.View()
+	assert.Contains(t, view, "Intercepted web, forwarding to 127.0.0.1:8080")
+	assert.Contains(t, view, "intercepted by this client")
+
+	m = run(m, key("i"))
+	assert.Len(t, src.created, 1)
+	assert.Contains(t, m.View(), "web is already intercepted")
+
+	m = run(m, key("l"))
+	assert.Equal(t, []string{"web"}, src.left)
+	assert.Contains(t, m.View(), "Left web")
+
+	_, cmd := m.Update(key("q"))
+	require.NotNil(t, cmd)
+	assert.IsType(t, tea.QuitMsg{}, cmd())
+}
+
+func TestModelRefresh(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	src := &fakeSource{statusErr: errors.New("daemon unavailable")}
+	m := NewModel(ctx, src, time.Hour, 8080)
+
+	// The initial poll schedules the next tick, even when it fails.
+	_, cmd := m.Update(m.Init()())
+	assert.NotNil(t, cmd)
+	assert.Contains(t, m.View(), "Error: daemon unavailable")
+
+	// A manual refresh doesn't start another tick chain, and a successful refresh clears the error.
+	src.statusErr = nil
+	_, cmd = m.Update(key("r"))
+	require.NotNil(t, cmd)
+	_, cmd = m.Update(cmd())
+	assert.Nil(t, cmd)
+	assert.NotContains(t, m.View(), "Error:")
+
+	// A tick polls and schedules the next tick.
+	_, cmd = m.Update(tickMsg{})
+	require.NotNil(t, cmd)
+	_, cmd = m.Update(cmd())
+	assert.NotNil(t, cmd)
+}
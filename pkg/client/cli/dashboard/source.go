@@ -0,0 +1,82 @@
+package dashboard
+
+import (
+	"context"
+
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	daemonRpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/intercept"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/socket"
+)
+
+// Source provides the data shown by the dashboard and performs the actions that it offers.
+type Source interface {
+	// Status returns the status of the current connection.
+	Status(ctx context.Context) (*connector.ConnectInfo, error)
+
+	// Workloads returns the workloads in the connected namespace.
+	Workloads(ctx context.Context) ([]*connector.WorkloadInfo, error)
+
+	// Stats returns the tunnel and DNS statistics of the root daemon, or nil when they are unavailable.
+	Stats(ctx context.Context) (*daemonRpc.SessionStats, error)
+
+	// Intercept creates an intercept of the given workload that forwards to the given local port.
+	Intercept(ctx context.Context, workload string, port uint16) error
+
+	// Leave removes the intercept with the given name.
+	Leave(ctx context.Context, name string) error
+}
+
+type daemonSource struct {
+	userD *daemon.UserClient
+}
+
+// NewSource returns a Source that talks to the daemons that the command is connected to.
+func NewSource(ctx context.Context) Source {
+	return &daemonSource{userD: daemon.GetUserClient(ctx)}
+}
+
+func (s *daemonSource) Status(ctx context.Context) (*connector.ConnectInfo, error) {
+	return s.userD.Status(ctx, &empty.Empty{})
+}
+
+func (s *daemonSource) Workloads(ctx context.Context) ([]*connector.WorkloadInfo, error) {
+	r, err := s.userD.List(ctx, &connector.ListRequest{Filter: connector.ListRequest_EVERYTHING})
+	if err != nil {
+		return nil, err
+	}
+	return r.Workloads, nil
+}
+
+func (s *daemonSource) Stats(ctx context.Context) (*daemonRpc.SessionStats, error) {
+	if s.userD.Remote() {
+		// The root daemon runs in-process in the containerized daemon and cannot be reached.
+		return nil, nil
+	}
+	conn, err := socket.Dial(ctx, socket.RootDaemonPath(ctx))
+	if err != nil {
+		return nil, nil
+	}
+	defer conn.Close()
+	return daemonRpc.NewDaemonClient(conn).GetStats(ctx, &empty.Empty{})
+}
+
+func (s *daemonSource) Intercept(ctx context.Context, workload string, port uint16) error {
+	return intercept.Result(s.userD.CreateIntercept(ctx, &connector.CreateInterceptRequest{
+		Spec: &manager.InterceptSpec{
+			Name:       workload,
+			Agent:      workload,
+			Mechanism:  "tcp",
+			TargetHost: "127.0.0.1",
+			TargetPort: int32(port),
+		},
+	}))
+}
+
+func (s *daemonSource) Leave(ctx context.Context, name string) error {
+	return intercept.Result(s.userD.RemoveIntercept(ctx, &manager.RemoveInterceptRequest2{Name: name}))
+}
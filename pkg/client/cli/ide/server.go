@@ -0,0 +1,316 @@
+// Package ide implements a JSON-RPC 2.0 bridge between IDE plugins and the user daemon. Messages are framed
+// using Content-Length headers, the same way as in the Language Server Protocol, so that IDEs can reuse
+// their LSP client libraries. All unary methods of the connector gRPC API are available as
+// "connector/<Method>", with the request and the result encoded using the protobuf JSON mapping, and
+// changes to the connection and its intercepts are pushed as notifications so that plugins don't need to
+// poll.
+package ide
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+)
+
+// APIVersion is the version of the bridge protocol that is returned by the initialize method.
+const APIVersion = "1"
+
+const (
+	connectorPrefix = "connector/"
+
+	methodInitialize = "initialize"
+	methodShutdown   = "shutdown"
+	methodExit       = "exit"
+	methodCancel     = "$/cancelRequest"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+
+	// codeServerError is used for errors returned by the user daemon. The gRPC status code is in the data.
+	codeServerError = -32000
+
+	// codeRequestCancelled is the LSP code for requests cancelled using $/cancelRequest.
+	codeRequestCancelled = -32800
+)
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// InitializeResult is the result of the initialize method.
+type InitializeResult struct {
+	APIVersion    string   `json:"apiVersion"`
+	Methods       []string `json:"methods"`
+	Notifications []string `json:"notifications"`
+}
+
+// Server serves JSON-RPC requests by forwarding them to the connector gRPC API of the user daemon.
+type Server struct {
+	conn     grpc.ClientConnInterface
+	service  protoreflect.ServiceDescriptor
+	interval time.Duration
+
+	outLock sync.Mutex
+	out     io.Writer
+
+	cancelLock sync.Mutex
+	cancels    map[string]context.CancelFunc
+}
+
+// NewServer returns a Server that forwards requests to the connector API using the given connection, and
+// that writes responses and notifications to the given writer. The connection status is checked at the given
+// interval and a notification is pushed when it changes.
+func NewServer(conn grpc.ClientConnInterface, out io.Writer, interval time.Duration) *Server {
+	return &Server{
+		conn:     conn,
+		service:  connector.File_connector_connector_proto.Services().ByName("Connector"),
+		interval: interval,
+		out:      out,
+		cancels:  make(map[string]context.CancelFunc),
+	}
+}
+
+// Serve reads requests from the given reader until it is closed, the exit notification is received, or
+// the context is cancelled.
+func (s *Server) Serve(ctx context.Context, in io.Reader) error {
+	ctx, cancel := context.WithCancel(ctx)
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.watch(ctx)
+	}()
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	return s.read(ctx, bufio.NewReader(in))
+}
+
+func (s *Server) read(ctx context.Context, in *bufio.Reader) error {
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+	for ctx.Err() == nil {
+		data, err := readMessage(in)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		var msg message
+		if err = json.Unmarshal(data, &msg); err != nil {
+			s.send(&message{ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: err.Error()}})
+			continue
+		}
+		switch msg.Method {
+		case methodExit:
+			return nil
+		case methodCancel:
+			s.cancel(msg.Params)
+			continue
+		}
+		if len(msg.ID) == 0 {
+			// Notifications other than the ones above are ignored.
+			dlog.Debugf(ctx, "ignoring notification %s", msg.Method)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handle(ctx, &msg)
+		}()
+	}
+	return nil
+}
+
+func (s *Server) handle(ctx context.Context, msg *message) {
+	ctx, cancel := context.WithCancel(ctx)
+	id := string(msg.ID)
+	s.cancelLock.Lock()
+	s.cancels[id] = cancel
+	s.cancelLock.Unlock()
+	defer func() {
+		s.cancelLock.Lock()
+		delete(s.cancels, id)
+		s.cancelLock.Unlock()
+		cancel()
+	}()
+
+	result, err := s.call(ctx, msg)
+	rsp := &message{ID: msg.ID}
+	if err != nil {
+		var re *rpcError
+		if !errors.As(err, &re) {
+			re = &rpcError{Code: codeInternalError, Message: err.Error()}
+		}
+		rsp.Error = re
+	} else {
+		rsp.Result = result
+	}
+	s.send(rsp)
+}
+
+func (s *Server) call(ctx context.Context, msg *message) (json.RawMessage, error) {
+	if msg.JSONRPC != "2.0" || msg.Method == "" {
+		return nil, &rpcError{Code: codeInvalidRequest, Message: "not a JSON-RPC 2.0 request"}
+	}
+	switch msg.Method {
+	case methodInitialize:
+		return json.Marshal(&InitializeResult{
+			APIVersion:    APIVersion,
+			Methods:       s.methods(),
+			Notifications: []string{NotifyStatus, NotifyIntercepts, NotifyDisconnected},
+		})
+	case methodShutdown:
+		return json.RawMessage("null"), nil
+	}
+	var md protoreflect.MethodDescriptor
+	if strings.HasPrefix(msg.Method, connectorPrefix) {
+		md = s.service.Methods().ByName(protoreflect.Name(strings.TrimPrefix(msg.Method, connectorPrefix)))
+	}
+	if md == nil || md.IsStreamingClient() || md.IsStreamingServer() {
+		return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not found", msg.Method)}
+	}
+	in, err := newMessage(md.Input())
+	if err != nil {
+		return nil, err
+	}
+	if len(msg.Params) > 0 && string(msg.Params) != "null" {
+		if err = protojson.Unmarshal(msg.Params, in); err != nil {
+			return nil, &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+	}
+	out, err := newMessage(md.Output())
+	if err != nil {
+		return nil, err
+	}
+	if err = s.conn.Invoke(ctx, fmt.Sprintf("/%s/%s", s.service.FullName(), md.Name()), in, out); err != nil {
+		if ctx.Err() != nil {
+			return nil, &rpcError{Code: codeRequestCancelled, Message: "request cancelled"}
+		}
+		st := status.Convert(err)
+		return nil, &rpcError{Code: codeServerError, Message: st.Message(), Data: map[string]string{"grpcCode": st.Code().String()}}
+	}
+	return protojson.Marshal(out)
+}
+
+// methods returns the names of the methods that the server provides, sorted alphabetically.
+func (s *Server) methods() []string {
+	mds := s.service.Methods()
+	names := []string{methodInitialize, methodShutdown}
+	for i := 0; i < mds.Len(); i++ {
+		md := mds.Get(i)
+		if !(md.IsStreamingClient() || md.IsStreamingServer()) {
+			names = append(names, connectorPrefix+string(md.Name()))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Server) cancel(params json.RawMessage) {
+	var p struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if json.Unmarshal(params, &p) != nil {
+		return
+	}
+	s.cancelLock.Lock()
+	if cancel, ok := s.cancels[string(p.ID)]; ok {
+		cancel()
+	}
+	s.cancelLock.Unlock()
+}
+
+// notify pushes a notification with the given protobuf message as its parameters.
+func (s *Server) notify(method string, params proto.Message) {
+	data, err := protojson.Marshal(params)
+	if err != nil {
+		return
+	}
+	s.send(&message{Method: method, Params: data})
+}
+
+func (s *Server) send(msg *message) {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	s.outLock.Lock()
+	defer s.outLock.Unlock()
+	_, _ = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+func newMessage(md protoreflect.MessageDescriptor) (proto.Message, error) {
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(md.FullName())
+	if err != nil {
+		return nil, err
+	}
+	return mt.New().Interface(), nil
+}
+
+// readMessage reads the headers and the content of one message.
+func readMessage(in *bufio.Reader) ([]byte, error) {
+	hdr, err := textproto.NewReader(in).ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(hdr) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("unable to read message header: %w", err)
+	}
+	cl := hdr.Get("Content-Length")
+	if cl == "" {
+		return nil, errors.New("message header has no Content-Length")
+	}
+	n, err := strconv.Atoi(cl)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", cl)
+	}
+	data := make([]byte, n)
+	if _, err = io.ReadFull(in, data); err != nil {
+		return nil, fmt.Errorf("unable to read message content: %w", err)
+	}
+	return data, nil
+}
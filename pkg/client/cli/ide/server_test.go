@@ -0,0 +1,173 @@
+package ide
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/common"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+type fakeConnector struct {
+	connector.UnimplementedConnectorServer
+	sync.Mutex
+	status *connector.ConnectInfo
+}
+
+func (f *fakeConnector) Version(context.Context, *empty.Empty) (*common.VersionInfo, error) {
+	return &common.VersionInfo{ApiVersion: 3, Version: "v2.0.0", Name: "fake"}, nil
+}
+
+func (f *fakeConnector) Status(context.Context, *empty.Empty) (*connector.ConnectInfo, error) {
+	f.Lock()
+	defer f.Unlock()
+	return f.status, nil
+}
+
+func (f *fakeConnector) GetNamespaces(_ context.Context, rq *connector.GetNamespacesRequest) (*connector.GetNamespacesResponse, error) {
+	if rq.Prefix == "bad" {
+		return nil, status.Error(codes.InvalidArgument, "bad prefix")
+	}
+	return &connector.GetNamespacesResponse{Namespaces: []string{rq.Prefix + "-a", rq.Prefix + "-b"}}, nil
+}
+
+func (f *fakeConnector) setStatus(ci *connector.ConnectInfo) {
+	f.Lock()
+	f.status = ci
+	f.Unlock()
+}
+
+type client struct {
+	t   *testing.T
+	in  io.Writer
+	out *bufio.Reader
+}
+
+func (c *client) send(v any) {
+	data, err := json.Marshal(v)
+	require.NoError(c.t, err)
+	_, err = fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	require.NoError(c.t, err)
+}
+
+func (c *client) receive() map[string]any {
+	data, err := readMessage(c.out)
+	require.NoError(c.t, err)
+	var m map[string]any
+	require.NoError(c.t, json.Unmarshal(data, &m))
+	return m
+}
+
+// receiveWith returns the next message that has the given key/value, discarding other messages.
+func (c *client) receiveWith(key string, value any) map[string]any {
+	for {
+		if m := c.receive(); m[key] == value {
+			return m
+		}
+	}
+}
+
+func TestServer(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	fc := &fakeConnector{status: &connector.ConnectInfo{Error: connector.ConnectInfo_DISCONNECTED}}
+	lis := bufconn.Listen(1024 * 1024)
+	gs := grpc.NewServer()
+	connector.RegisterConnectorServer(gs, fc)
+	go func() { _ = gs.Serve(lis) }()
+	defer gs.Stop()
+
+	conn, err := grpc.DialContext(ctx, "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- NewServer(conn, outW, 10*time.Millisecond).Serve(ctx, inR)
+		_ = outW.Close()
+	}()
+	c := &client{t: t, in: inW, out: bufio.NewReader(outR)}
+
+	// The initial status is pushed right away.
+	m := c.receiveWith("method", NotifyStatus)
+	assert.Equal(t, "DISCONNECTED", m["params"].(map[string]any)["error"])
+
+	c.send(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "initialize"})
+	m = c.receiveWith("id", 1.0)
+	result := m["result"].(map[string]any)
+	assert.Equal(t, APIVersion, result["apiVersion"])
+	assert.Contains(t, result["methods"], "connector/Status")
+	assert.NotContains(t, result["methods"], "connector/StreamLogs")
+
+	c.send(map[string]any{"jsonrpc": "2.0", "id": 2, "method": "connector/Version"})
+	m = c.receiveWith("id", 2.0)
+	assert.Equal(t, "v2.0.0", m["result"].(map[string]any)["version"])
+
+	c.send(map[string]any{"jsonrpc": "2.0", "id": 3, "method": "connector/GetNamespaces", "params": map[string]any{"prefix": "x"}})
+	m = c.receiveWith("id", 3.0)
+	assert.Equal(t, []any{"x-a", "x-b"}, m["result"].(map[string]any)["namespaces"])
+
+	c.send(map[string]any{"jsonrpc": "2.0", "id": 4, "method": "connector/GetNamespaces", "params": map[string]any{"prefix": "bad"}})
+	m = c.receiveWith("id", 4.0)
+	rpcErr := m["error"].(map[string]any)
+	assert.Equal(t, float64(codeServerError), rpcErr["code"])
+	assert.Equal(t, "bad prefix", rpcErr["message"])
+	assert.Equal(t, "InvalidArgument", rpcErr["data"].(map[string]any)["grpcCode"])
+
+	c.send(map[string]any{"jsonrpc": "2.0", "id": 5, "method": "connector/StreamLogs"})
+	m = c.receiveWith("id", 5.0)
+	assert.Equal(t, float64(codeMethodNotFound), m["error"].(map[string]any)["code"])
+
+	c.send(map[string]any{"jsonrpc": "2.0", "id": 6, "method": "connector/GetNamespaces", "params": map[string]any{"prefix": 7}})
+	m = c.receiveWith("id", 6.0)
+	assert.Equal(t, float64(codeInvalidParams), m["error"].(map[string]any)["code"])
+
+	// Connecting and intercepting are pushed as notifications.
+	fc.setStatus(&connector.ConnectInfo{
+		ClusterContext: "test",
+		Namespace:      "default",
+		Intercepts: &manager.InterceptInfoSnapshot{Intercepts: []*manager.InterceptInfo{{
+			Id:          "abc:echo",
+			Disposition: manager.InterceptDispositionType_ACTIVE,
+			Spec:        &manager.InterceptSpec{Name: "echo"},
+		}}},
+	})
+	m = c.receiveWith("method", NotifyStatus)
+	assert.Equal(t, "test", m["params"].(map[string]any)["clusterContext"])
+	assert.NotContains(t, m["params"], "intercepts")
+	m = c.receiveWith("method", NotifyIntercepts)
+	assert.Len(t, m["params"].(map[string]any)["intercepts"], 1)
+
+	fc.setStatus(&connector.ConnectInfo{Error: connector.ConnectInfo_DISCONNECTED})
+	c.receiveWith("method", NotifyDisconnected)
+
+	c.send(map[string]any{"jsonrpc": "2.0", "method": "exit"})
+	go func() { _, _ = io.Copy(io.Discard, outR) }()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server didn't exit")
+	}
+}
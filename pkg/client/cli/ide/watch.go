@@ -0,0 +1,101 @@
+package ide
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+// Notifications pushed by the server.
+const (
+	// NotifyStatus is pushed with the ConnectInfo when the connection changes, e.g. when a connection is
+	// established, or when the namespace or the mapped namespaces change. It is also pushed once when the
+	// server starts.
+	NotifyStatus = "telepresence/status"
+
+	// NotifyIntercepts is pushed with the InterceptInfoSnapshot when an intercept is added or removed,
+	// or when the state of an intercept changes.
+	NotifyIntercepts = "telepresence/intercepts"
+
+	// NotifyDisconnected is pushed with the ConnectInfo when the connection ends, and with a ConnectInfo
+	// that has the error DAEMON_FAILED when the user daemon can no longer be reached.
+	NotifyDisconnected = "telepresence/disconnected"
+)
+
+// watch checks the status of the connection at the server's interval and pushes notifications when it
+// changes. The connector API has no stream of status changes, so the polling is done here, once, instead
+// of in every IDE plugin.
+func (s *Server) watch(ctx context.Context) {
+	client := connector.NewConnectorClient(s.conn)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	var prev *connector.ConnectInfo
+	for {
+		ci, err := client.Status(ctx, &empty.Empty{})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			dlog.Debugf(ctx, "unable to get status: %v", err)
+			ci = &connector.ConnectInfo{Error: connector.ConnectInfo_DAEMON_FAILED, ErrorText: err.Error()}
+		}
+		s.pushChanges(prev, ci)
+		prev = ci
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pushChanges pushes the notifications that describe the difference between the previous and the current
+// status. The previous status is nil when the current status is the first one.
+func (s *Server) pushChanges(prev, ci *connector.ConnectInfo) {
+	if prev == nil {
+		s.notify(NotifyStatus, withoutIntercepts(ci))
+		if connected(ci) {
+			s.notify(NotifyIntercepts, intercepts(ci))
+		}
+		return
+	}
+	if !proto.Equal(withoutIntercepts(prev), withoutIntercepts(ci)) {
+		s.notify(NotifyStatus, withoutIntercepts(ci))
+		if connected(prev) && !connected(ci) {
+			s.notify(NotifyDisconnected, withoutIntercepts(ci))
+		}
+	}
+	if !proto.Equal(intercepts(prev), intercepts(ci)) {
+		s.notify(NotifyIntercepts, intercepts(ci))
+	}
+}
+
+func connected(ci *connector.ConnectInfo) bool {
+	switch ci.Error {
+	case connector.ConnectInfo_UNSPECIFIED, connector.ConnectInfo_ALREADY_CONNECTED:
+		return true
+	default:
+		return false
+	}
+}
+
+func intercepts(ci *connector.ConnectInfo) *manager.InterceptInfoSnapshot {
+	if is := ci.GetIntercepts(); is != nil {
+		return is
+	}
+	return &manager.InterceptInfoSnapshot{}
+}
+
+// withoutIntercepts returns a copy of the given status without the intercepts, which are pushed separately.
+func withoutIntercepts(ci *connector.ConnectInfo) *connector.ConnectInfo {
+	ci = proto.Clone(ci).(*connector.ConnectInfo)
+	ci.Intercepts = nil
+	return ci
+}
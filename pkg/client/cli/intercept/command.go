@@ -1,18 +1,23 @@
 package intercept
 
 import (
+	"net"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
-	"github.com/datawire/dlib/dlog"
 	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
-	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/flags"
 	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/slice"
 )
 
 type Command struct {
@@ -24,6 +29,11 @@ type Command struct {
 	LocalOnly      bool   // --local-only
 	LocalMountPort uint16 // --local-mount-port
 
+	NodePortAddress string // --expose-node-ports
+
+	Handlers      []string // --handler name=[host:]port // only valid if !localOnly
+	ActiveHandler string   // --active
+
 	EnvFile  string   // --env-file
 	EnvJSON  string   // --env-json
 	Mount    string   // --mount // "true", "false", or desired mount point // only valid if !localOnly
@@ -33,20 +43,41 @@ type Command struct {
 	DockerRun          bool     // --docker-run
 	DockerBuild        string   // --docker-build DIR | URL // Optional docker build context
 	DockerBuildOptions []string // --docker-build-opt key=value, // Optional flag to docker build can be repeated (but not comma separated)
+	DockerWatch        bool     // --docker-watch // rebuild and restart the container when the --docker-build context changes
 	DockerMount        string   // --docker-mount // where to mount in a docker container. Defaults to mount unless mount is "true" or "false".
 	Cmdline            []string // Command[1:]
 
-	Mechanism      string // --mechanism tcp
+	Duration time.Duration // --duration
+	TakeOver bool          // --take-over
+	Cluster  string        // --cluster
+
+	Mechanism      string   // --mechanism tcp
+	HTTPHeaders    []string // --http-header name=value
 	MechanismArgs  []string
 	ExtendedInfo   []byte
 	DetailedOutput bool
+
+	GeneratePropagation string // --generate-propagation go|java|node
+
+	Sample time.Duration // --sample
+
+	Faults     []string // --fault key=value,...
+	FaultsOnly bool     // --faults-only
+
+	ToSpec    string // --to-spec
+	FromSpec  string // --from-spec
+	Namespace string // namespace from --from-spec
+
+	portDefaulted bool              // Port was not given, so the default port from the config is used
+	handlers      map[string]string // the parsed --handler flags, name -> host:port
+	faultRules    []*manager.FaultRule
 }
 
 func (a *Command) AddFlags(cmd *cobra.Command) {
 	flagSet := cmd.Flags()
 	flagSet.StringVarP(&a.AgentName, "workload", "w", "", "Name of workload (Deployment, ReplicaSet) to intercept, if different from <name>")
 	flagSet.StringVarP(&a.Port, "port", "p", "", ``+
-		`Local port to forward to. If intercepting a service with multiple ports, `+
+		`Local port to forward to. Use "auto" to let Telepresence pick a free port. If intercepting a service with multiple ports, `+
 		`use <local port>:<svcPortIdentifier>, where the identifier is the port name or port number. `+
 		`With --docker-run and a daemon that doesn't run in docker', use <local port>:<container port> or `+
 		`<local port>:<container port>:<svcPortIdentifier>.`,
@@ -57,6 +88,14 @@ func (a *Command) AddFlags(cmd *cobra.Command) {
 		`e.g. '--address 10.0.0.2'`,
 	)
 
+	flagSet.StringArrayVar(&a.Handlers, "handler", nil, ``+
+		`A named local handler in the form name=[host:]port, e.g. --handler blue=8080. Can be repeated. The intercepted `+
+		`traffic is routed to the active handler, and "telepresence switch" routes it to another one instantly. The host `+
+		`defaults to --address, and the local port of --port is not used`)
+
+	flagSet.StringVar(&a.ActiveHandler, "active", "", ``+
+		`Name of the handler that receives the intercepted traffic when the intercept is created. Defaults to the first --handler`)
+
 	flagSet.StringVar(&a.ServiceName, "service", "", "Name of service to intercept. If not provided, we will try to auto-detect one")
 
 	flagSet.BoolVarP(&a.LocalOnly, "local-only", "l", false, ``+
@@ -64,9 +103,12 @@ func (a *Command) AddFlags(cmd *cobra.Command) {
 
 	flagSet.StringVarP(&a.EnvFile, "env-file", "e", "", ``+
 		`Also emit the remote environment to an env file in Docker Compose format. `+
-		`See https://docs.docker.com/compose/env-file/ for more information on the limitations of this format.`)
+		`See https://docs.docker.com/compose/env-file/ for more information on the limitations of this format. `+
+		`Variables that are taken from ConfigMaps and Secrets are updated while the intercept is active.`)
 
-	flagSet.StringVarP(&a.EnvJSON, "env-json", "j", "", `Also emit the remote environment to a file as a JSON blob.`)
+	flagSet.StringVarP(&a.EnvJSON, "env-json", "j", "", ``+
+		`Also emit the remote environment to a file as a JSON blob. `+
+		`Variables that are taken from ConfigMaps and Secrets are updated while the intercept is active.`)
 
 	flagSet.StringVar(&a.Mount, "mount", "true", ``+
 		`The absolute path for the root directory where volumes will be mounted, $TELEPRESENCE_ROOT. Use "true" to `+
@@ -88,6 +130,10 @@ func (a *Command) AddFlags(cmd *cobra.Command) {
 	flagSet.StringArrayVar(&a.DockerBuildOptions, "docker-build-opt", nil,
 		`Option to docker-build in the form key=value, e.g. --docker-build-opt tag=mytag. Can be repeated`)
 
+	flagSet.BoolVar(&a.DockerWatch, "docker-watch", false, ``+
+		`Watch the directory given to --docker-build, and rebuild the image and restart the container when its files `+
+		`change. The intercept stays active while the container restarts`)
+
 	flagSet.StringVar(&a.DockerMount, "docker-mount", "", ``+
 		`The volume mount point in docker. Defaults to same as "--mount"`)
 
@@ -95,12 +141,64 @@ func (a *Command) AddFlags(cmd *cobra.Command) {
 
 	flagSet.StringVar(&a.Mechanism, "mechanism", "tcp", "Which extension `mechanism` to use")
 
+	flagSet.StringArrayVar(&a.HTTPHeaders, "http-header", nil, ``+
+		`Only intercept HTTP requests that have this header, in the form name=value. Can be repeated, and a request `+
+		`must then have all the headers. Implies --mechanism http`)
+
+	flagSet.DurationVar(&a.Sample, "sample", 0, ``+
+		`Don't create the intercept. Instead, let the traffic-agents of the workload count the requests that they `+
+		`receive during this duration, e.g. 60s, and report how many of them match the filter given by --http-header`)
+
+	flagSet.StringArrayVar(&a.Faults, "fault", nil, ``+
+		`Inject a fault into a percentage of the intercepted HTTP/1.x requests, in the form of comma separated `+
+		`key=value pairs, e.g. percent=10,delay=2s,status=503,header=Retry-After:30. The keys are percent (default 100), `+
+		`delay (hold the request), status (respond with this status instead of routing the request), header `+
+		`(name:value to set in the response, an empty value removes it), and the filters match (name:value of a `+
+		`request header), path-equal, path-prefix, and path-regex. Can be repeated, and the first matching fault applies. `+
+		`Only HTTP/1.x is affected. Connections that use TLS, HTTP/2, or another protocol are passed through unchanged`)
+
+	flagSet.BoolVar(&a.FaultsOnly, "faults-only", false, ``+
+		`Don't route the requests to this workstation. Instead, let the traffic-agent keep routing them to the `+
+		`workload's container and only inject the faults given with --fault`)
+
 	flagSet.BoolVar(&a.DetailedOutput, "detailed-output", false,
 		`Provide very detailed info about the intercept when used together with --output=json or --output=yaml'`)
 
 	flagSet.Uint16Var(&a.LocalMountPort, "local-mount-port", 0,
 		`Do not mount remote directories. Instead, expose this port on localhost to an external mounter`)
 
+	flagSet.StringVar(&a.NodePortAddress, "expose-node-ports", "", ``+
+		`Also expose the local handler on the NodePort and LoadBalancer port numbers of the intercepted service, bound `+
+		`to this loopback alias, so that clients that are configured with those ports work unchanged. The address `+
+		`defaults to 127.0.0.2 when the flag is given without a value. On macOS, the alias must be added first, e.g. `+
+		`using "sudo ifconfig lo0 alias 127.0.0.2"`)
+	flagSet.Lookup("expose-node-ports").NoOptDefVal = "127.0.0.2"
+
+	flagSet.DurationVar(&a.Duration, "duration", 0, ``+
+		`Remove the intercept automatically when this duration, e.g. 45m, has passed. The traffic-manager `+
+		`removes it also when this workstation is offline`)
+
+	flagSet.BoolVar(&a.TakeOver, "take-over", false, ``+
+		`Take over conflicting intercepts of the same workload from other users. Their owners are asked to hand `+
+		`them over using "telepresence handoff accept", unless they are offline`)
+
+	flagSet.StringVar(&a.Cluster, "cluster", "", ``+
+		`Name of the federated cluster that the workload is in. The intercept is routed through the federated `+
+		`traffic-manager, and the traffic-manager of that cluster enforces its ownership rules and intercept policy`)
+
+	flagSet.StringVar(&a.GeneratePropagation, "generate-propagation", "", ``+
+		`Print code in the given language (go, java, or node) that propagates the headers that the intercept `+
+		`matches from incoming to outgoing requests, so that calls made by the intercepted service are intercepted too`)
+
+	flagSet.StringVar(&a.ToSpec, "to-spec", "", ``+
+		`Write the resolved intercept to this file, so that it can be re-created later using --from-spec`)
+
+	flagSet.StringVar(&a.FromSpec, "from-spec", "", ``+
+		`Create the intercept described in this file, as written by --to-spec. Flags given on the command line `+
+		`take precedence over the values in the file, and the intercept name is optional`)
+
+	_ = cmd.RegisterFlagCompletionFunc("workload", CompleteWorkloadFlag(connector.ListRequest_INTERCEPTABLE))
+
 	// Hide these flags. They are still functional but deprecated. Using them will yield a deprecation message.
 	flagSet.Lookup("local-only").Hidden = true
 	flagSet.Lookup("namespace").Hidden = true
@@ -109,11 +207,68 @@ func (a *Command) AddFlags(cmd *cobra.Command) {
 func (a *Command) Validate(cmd *cobra.Command, positional []string) error {
 	flags.DeprecationIfChanged(cmd, "local-only", "use telepresence connect to set the namespace")
 	flags.DeprecationIfChanged(cmd, "namespace", "use telepresence connect to set the namespace")
-	if len(positional) > 1 && cmd.Flags().ArgsLenAtDash() != 1 {
+	var spec *Spec
+	nameFromSpec := false
+	if a.FromSpec != "" {
+		var err error
+		if spec, err = LoadSpec(a.FromSpec); err != nil {
+			return err
+		}
+		if err = spec.apply(cmd, a); err != nil {
+			return err
+		}
+		nameFromSpec = len(positional) == 0 || cmd.Flags().ArgsLenAtDash() == 0
+	}
+	switch {
+	case nameFromSpec:
+		a.Name = spec.Name
+		a.Cmdline = positional
+	case len(positional) == 0:
+		return errcat.User.New("the name of the intercept is required unless --from-spec is used")
+	case len(positional) > 1 && cmd.Flags().ArgsLenAtDash() != 1:
 		return errcat.User.New("commands to be run with intercept must come after options")
+	default:
+		a.Name = positional[0]
+		a.Cmdline = positional[1:]
+	}
+	if len(a.Cmdline) == 0 && spec != nil {
+		a.Cmdline = spec.Command
+	}
+	if a.Duration < 0 {
+		return errcat.User.New("--duration must not be negative")
+	}
+	if a.Sample < 0 {
+		return errcat.User.New("--sample must not be negative")
+	}
+	if a.Sample > 0 && (len(a.Cmdline) > 0 || a.DockerRun || a.DockerBuild != "") {
+		return errcat.User.New("--sample doesn't create the intercept, so it cannot run a command")
+	}
+	if a.Sample > 0 && len(a.Faults) > 0 {
+		return errcat.User.New("--sample doesn't create the intercept, so it cannot inject faults")
+	}
+	if a.FaultsOnly {
+		if len(a.Faults) == 0 {
+			return errcat.User.New("--faults-only must be used together with --fault")
+		}
+		if len(a.Cmdline) > 0 || a.DockerRun || a.DockerBuild != "" || len(a.Handlers) > 0 {
+			return errcat.User.New("--faults-only doesn't route any requests to this workstation, so it cannot have a handler")
+		}
+	}
+	if len(a.HTTPHeaders) > 0 {
+		if cmd.Flag("mechanism").Changed && a.Mechanism != "http" {
+			return errcat.User.Newf("--http-header cannot be used with --mechanism %s", a.Mechanism)
+		}
+		for _, h := range a.HTTPHeaders {
+			if n, _, ok := strings.Cut(h, "="); !ok || n == "" {
+				return errcat.User.Newf("--http-header %q is not in the form name=value", h)
+			}
+			a.MechanismArgs = append(a.MechanismArgs, httpHeaderArg+h)
+		}
+		a.Mechanism = "http"
+	}
+	if a.GeneratePropagation != "" && !slice.Contains(PropagationLanguages, a.GeneratePropagation) {
+		return errcat.User.Newf("--generate-propagation must be one of %s", strings.Join(PropagationLanguages, ", "))
 	}
-	a.Name = positional[0]
-	a.Cmdline = positional[1:]
 	if a.LocalOnly {
 		// Not actually intercepting anything -- check that the flags make sense for that
 		if a.AgentName != "" {
@@ -130,9 +285,36 @@ func (a *Command) Validate(cmd *cobra.Command, positional []string) error {
 				return errcat.User.New("a local-only intercept cannot have mounts")
 			}
 		}
+		if a.NodePortAddress != "" {
+			return errcat.User.New("a local-only intercept cannot expose node ports")
+		}
+		if a.Cluster != "" {
+			return errcat.User.New("a local-only intercept cannot target a federated cluster")
+		}
+		if a.GeneratePropagation != "" {
+			return errcat.User.New("a local-only intercept has no headers to propagate")
+		}
+		if a.Sample > 0 {
+			return errcat.User.New("a local-only intercept has no traffic to sample")
+		}
+		if len(a.HTTPHeaders) > 0 {
+			return errcat.User.New("a local-only intercept has no traffic to filter")
+		}
+		if len(a.Handlers) > 0 {
+			return errcat.User.New("a local-only intercept cannot have handlers")
+		}
+		if len(a.Faults) > 0 {
+			return errcat.User.New("a local-only intercept has no traffic to inject faults into")
+		}
 		return nil
 	}
 
+	if a.NodePortAddress != "" {
+		if ip := iputil.Parse(a.NodePortAddress); ip == nil || !ip.IsLoopback() {
+			return errcat.User.Newf("--expose-node-ports %s is not a loopback address", a.NodePortAddress)
+		}
+	}
+
 	if a.LocalMountPort > 0 && client.GetConfig(cmd.Context()).Intercept().UseFtp {
 		return errcat.User.New("only SFTP can be used with --local-mount-port. Client is configured to perform remote mounts using FTP")
 	}
@@ -143,8 +325,32 @@ func (a *Command) Validate(cmd *cobra.Command, positional []string) error {
 	}
 	if a.Port == "" {
 		a.Port = strconv.Itoa(client.GetConfig(cmd.Context()).Intercept().DefaultPort)
+		a.portDefaulted = true
 	}
 	a.MountSet = cmd.Flag("mount").Changed
+	if a.FaultsOnly && !a.MountSet {
+		// Nothing runs locally, so there's nothing that needs the remote file system.
+		a.Mount = "false"
+		a.MountSet = true
+	}
+	if err := a.parseHandlers(); err != nil {
+		return err
+	}
+	for _, f := range a.Faults {
+		rule, err := parseFaultRule(f)
+		if err != nil {
+			return err
+		}
+		a.faultRules = append(a.faultRules, rule)
+	}
+	if a.DockerWatch {
+		if a.DockerBuild == "" {
+			return errcat.User.New("--docker-watch must be used together with --docker-build")
+		}
+		if st, err := os.Stat(a.DockerBuild); err != nil || !st.IsDir() {
+			return errcat.User.Newf("--docker-watch requires that --docker-build is a local directory, %q is not", a.DockerBuild)
+		}
+	}
 	if a.DockerBuild != "" {
 		a.DockerRun = true
 	}
@@ -163,9 +369,49 @@ func (a *Command) Run(cmd *cobra.Command, positional []string) error {
 	if err := connect.InitCommand(cmd); err != nil {
 		return err
 	}
+	if a.Sample > 0 {
+		return a.runSample(cmd)
+	}
 	return NewState(cmd, a).Run(cmd.Context())
 }
 
+// parseHandlers parses the --handler flags and validates the --active flag.
+func (a *Command) parseHandlers() error {
+	if len(a.Handlers) == 0 {
+		if a.ActiveHandler != "" {
+			return errcat.User.New("--active must be used together with --handler")
+		}
+		return nil
+	}
+	if a.DockerRun || a.DockerBuild != "" {
+		return errcat.User.New("--handler cannot be used with --docker-run or --docker-build")
+	}
+	a.handlers = make(map[string]string, len(a.Handlers))
+	for _, h := range a.Handlers {
+		name, addr, ok := strings.Cut(h, "=")
+		if !ok || name == "" || addr == "" {
+			return errcat.User.Newf("--handler %q is not in the form name=[host:]port", h)
+		}
+		if _, dup := a.handlers[name]; dup {
+			return errcat.User.Newf("--handler %s is given more than once", name)
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			host, port = a.Address, addr
+		}
+		if _, err = agentconfig.ParseNumericPort(port); err != nil {
+			return errcat.User.Newf("--handler %q is not in the form name=[host:]port", h)
+		}
+		a.handlers[name] = net.JoinHostPort(host, port)
+	}
+	if a.ActiveHandler == "" {
+		a.ActiveHandler, _, _ = strings.Cut(a.Handlers[0], "=")
+	} else if _, ok := a.handlers[a.ActiveHandler]; !ok {
+		return errcat.User.Newf("--active %s is not the name of a --handler", a.ActiveHandler)
+	}
+	return nil
+}
+
 func (a *Command) ValidateDockerArgs() error {
 	for _, arg := range a.Cmdline {
 		if arg == "-d" || arg == "--detach" {
@@ -176,43 +422,11 @@ func (a *Command) ValidateDockerArgs() error {
 }
 
 func (a *Command) ValidArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	if len(args) > 0 {
-		// Not completing the name of the workload
-		return nil, cobra.ShellCompDirectiveNoFileComp
+	list, shellCompDir := CompleteWorkloads(connector.ListRequest_INTERCEPTABLE)(cmd, args, toComplete)
+	if len(args) == 0 && shellCompDir&cobra.ShellCompDirectiveError == 0 {
+		shellCompDir |= cobra.ShellCompDirectiveNoSpace
 	}
-	if err := connect.InitCommand(cmd); err != nil {
-		return nil, cobra.ShellCompDirectiveError
-	}
-	req := connector.ListRequest{
-		Filter: connector.ListRequest_INTERCEPTABLE,
-	}
-	nf := cmd.Flag("namespace")
-	if nf.Changed {
-		req.Namespace = nf.Value.String()
-	}
-	ctx := cmd.Context()
-
-	// Trace level is used here, because we generally don't want to log expansion attempts
-	// in the cli.log
-	dlog.Tracef(ctx, "ns = %s, toComplete = %s, args = %v", req.Namespace, toComplete, args)
-	r, err := daemon.GetUserClient(ctx).List(ctx, &req)
-	if err != nil {
-		dlog.Debugf(ctx, "unable to get list of interceptable workloads: %v", err)
-		return nil, cobra.ShellCompDirectiveError
-	}
-
-	list := make([]string, 0)
-	for _, w := range r.Workloads {
-		// only suggest strings that start with the string were autocompleting
-		if strings.HasPrefix(w.Name, toComplete) {
-			list = append(list, w.Name)
-		}
-	}
-
-	// TODO(raphaelreyna): This list can be quite large (in the double digits of MB).
-	// There probably exists a number that would be a good cutoff limit.
-
-	return list, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+	return list, shellCompDir
 }
 
 // GetMountPoint returns a boolean indicating if mounts are enabled or not, and path
@@ -0,0 +1,117 @@
+package intercept
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/connect"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+)
+
+// CompletionFunc is the signature of cobra's functions for dynamic completion of arguments and flags.
+type CompletionFunc func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+// CompleteWorkloads returns a function that completes the first argument with the names of the workloads
+// that the given filter selects.
+func CompleteWorkloads(filter connector.ListRequest_Filter) CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			// Not completing the name of the workload
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeWorkloads(cmd, filter, toComplete)
+	}
+}
+
+// CompleteWorkloadFlag returns a function that completes a flag value with the names of the workloads that
+// the given filter selects.
+func CompleteWorkloadFlag(filter connector.ListRequest_Filter) CompletionFunc {
+	return func(cmd *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return completeWorkloads(cmd, filter, toComplete)
+	}
+}
+
+// completeWorkloads lists the workloads using the user daemon, which serves them from its cache of the
+// cluster's workloads. The list is scoped to the namespace given by the command's --namespace flag, or to
+// the connected namespace when that flag isn't set.
+func completeWorkloads(cmd *cobra.Command, filter connector.ListRequest_Filter, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if err := connect.InitCommand(cmd); err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	req := connector.ListRequest{
+		Filter: filter,
+	}
+	if nf := cmd.Flag("namespace"); nf != nil && nf.Changed {
+		req.Namespace = nf.Value.String()
+	}
+	ctx := cmd.Context()
+
+	// Trace level is used here, because we generally don't want to log expansion attempts
+	// in the cli.log
+	dlog.Tracef(ctx, "ns = %s, toComplete = %s", req.Namespace, toComplete)
+	r, err := daemon.GetUserClient(ctx).List(ctx, &req)
+	if err != nil {
+		dlog.Debugf(ctx, "unable to get list of workloads: %v", err)
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	// TODO(raphaelreyna): This list can be quite large (in the double digits of MB).
+	// There probably exists a number that would be a good cutoff limit.
+
+	return workloadCompletions(r.Workloads, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// workloadCompletions returns the names of the given workloads that start with toComplete.
+func workloadCompletions(wls []*connector.WorkloadInfo, toComplete string) []string {
+	list := make([]string, 0)
+	for _, w := range wls {
+		// only suggest strings that start with the string were autocompleting
+		if strings.HasPrefix(w.Name, toComplete) {
+			list = append(list, w.Name)
+		}
+	}
+	return list
+}
+
+// CompleteIntercepts completes the first argument with the names of the active intercepts. Only intercepts
+// in the namespace given by the command's --namespace flag are completed when that flag is set.
+func CompleteIntercepts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	shellCompDir := cobra.ShellCompDirectiveNoFileComp
+	if len(args) != 0 {
+		return nil, shellCompDir
+	}
+	if err := connect.InitCommand(cmd); err != nil {
+		return nil, shellCompDir | cobra.ShellCompDirectiveError
+	}
+	var ns string
+	if nf := cmd.Flag("namespace"); nf != nil && nf.Changed {
+		ns = nf.Value.String()
+	}
+	ctx := cmd.Context()
+	resp, err := daemon.GetUserClient(ctx).List(ctx, &connector.ListRequest{Filter: connector.ListRequest_INTERCEPTS})
+	if err != nil {
+		dlog.Debugf(ctx, "unable to get list of intercepts: %v", err)
+		return nil, shellCompDir | cobra.ShellCompDirectiveError
+	}
+	return interceptCompletions(resp.Workloads, ns, toComplete), shellCompDir
+}
+
+// interceptCompletions returns the names of the intercepts of the given workloads that start with toComplete.
+// Only intercepts in the given namespace are included, unless it is empty.
+func interceptCompletions(wls []*connector.WorkloadInfo, ns, toComplete string) []string {
+	var completions []string
+	for _, wl := range wls {
+		for _, ii := range wl.InterceptInfos {
+			if ns != "" && ii.Spec.Namespace != ns {
+				continue
+			}
+			if name := ii.Spec.Name; strings.HasPrefix(name, toComplete) {
+				completions = append(completions, name)
+			}
+		}
+	}
+	return completions
+}
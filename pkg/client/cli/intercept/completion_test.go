@@ -0,0 +1,31 @@
+package intercept
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+func TestWorkloadCompletions(t *testing.T) {
+	wls := []*connector.WorkloadInfo{{Name: "echo"}, {Name: "echo-easy"}, {Name: "web"}}
+	assert.Equal(t, []string{"echo", "echo-easy"}, workloadCompletions(wls, "ec"))
+	assert.Equal(t, []string{"echo", "echo-easy", "web"}, workloadCompletions(wls, ""))
+	assert.Empty(t, workloadCompletions(wls, "x"))
+}
+
+func TestInterceptCompletions(t *testing.T) {
+	ii := func(name, ns string) *manager.InterceptInfo {
+		return &manager.InterceptInfo{Spec: &manager.InterceptSpec{Name: name, Namespace: ns}}
+	}
+	wls := []*connector.WorkloadInfo{
+		{Name: "echo", InterceptInfos: []*manager.InterceptInfo{ii("echo", "default"), ii("echo-8080", "default")}},
+		{Name: "echo", InterceptInfos: []*manager.InterceptInfo{ii("echo-staging", "staging")}},
+		{Name: "web", InterceptInfos: []*manager.InterceptInfo{ii("web", "staging")}},
+	}
+	assert.Equal(t, []string{"echo", "echo-8080", "echo-staging"}, interceptCompletions(wls, "", "echo"))
+	assert.Equal(t, []string{"echo-staging", "web"}, interceptCompletions(wls, "staging", ""))
+	assert.Empty(t, interceptCompletions(wls, "other", ""))
+}
@@ -40,20 +40,29 @@ func (s *state) prepareDockerRun(ctx context.Context) error {
 			`the string "IMAGE", acting as a placeholder for image ID, must be included after "--" when using "--docker-build", so ` +
 			`that flags intended for docker run can be distinguished from the command and arguments intended for the container.`)
 	}
+	if idx < 0 {
+		s.Cmdline = []string{"IMAGE"}
+		idx = 0
+	}
+	s.imageIdx = idx
+	_, err := s.buildImage(ctx)
+	return err
+}
+
+// buildImage builds the image of the --docker-build context and injects its ID into the Cmdline. It
+// returns true if the ID differs from the one that was injected previously.
+func (s *state) buildImage(ctx context.Context) (bool, error) {
 	opts := make([]string, len(s.DockerBuildOptions))
 	for i, opt := range s.DockerBuildOptions {
 		opts[i] = "--" + opt
 	}
 	imageID, err := docker.BuildImage(ctx, s.DockerBuild, opts)
 	if err != nil {
-		return err
-	}
-	if idx < 0 {
-		s.Cmdline = []string{imageID}
-	} else {
-		s.Cmdline[idx] = imageID
+		return false, err
 	}
-	return nil
+	changed := s.Cmdline[s.imageIdx] != imageID
+	s.Cmdline[s.imageIdx] = imageID
+	return changed, nil
 }
 
 var dockerBoolFlags = map[string]bool{ //nolint:gochecknoglobals // this is a constant
@@ -184,12 +193,11 @@ func (s *state) startInDocker(ctx context.Context, daemonID *daemon.Identifier,
 		if dockerMount != "" {
 			ourArgs = append(ourArgs, "-v", fmt.Sprintf("%s:%s", s.mountPoint, dockerMount))
 		}
-	} else {
-		daemonName := daemonID.ContainerName()
-		ourArgs = append(ourArgs, "--network", "container:"+daemonName)
+	}
 
-		// "--rm" is mandatory when using --docker-run against a docker daemon, because without it, the volumes
-		// cannot be removed.
+	// "--rm" is mandatory when using --docker-run against a docker daemon, because without it, the volumes
+	// cannot be removed. It's also mandatory with --docker-watch, because the restarted container reuses the name.
+	if daemonID != nil || s.DockerWatch {
 		_, set, err := flags.GetUnparsedBoolean(args, "--rm")
 		if err != nil {
 			dr.err = err
@@ -198,6 +206,12 @@ func (s *state) startInDocker(ctx context.Context, daemonID *daemon.Identifier,
 		if !set {
 			ourArgs = append(ourArgs, "--rm")
 		}
+	}
+
+	if daemonID != nil {
+		daemonName := daemonID.ContainerName()
+		ourArgs = append(ourArgs, "--network", "container:"+daemonName)
+
 		if !(s.mountDisabled || s.info == nil) {
 			m := s.info.Mount
 			if m != nil {
@@ -0,0 +1,66 @@
+package intercept
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/filesync"
+)
+
+// watchQuietPeriod is the time that the build context must remain unchanged before a rebuild starts. Editors
+// and tools like git tend to write several files in quick succession.
+const watchQuietPeriod = 300 * time.Millisecond
+
+// runWatchedDocker runs the container of a --docker-build, and rebuilds the image and restarts the container
+// each time the files of the build context change. The intercept remains active throughout. A failed build
+// leaves the running container in place, so that the next change can fix the build. The function returns
+// when the container exits by itself, or when the given procCtx is cancelled.
+func (s *state) runWatchedDocker(ctx, procCtx context.Context, daemonID *daemon.Identifier, envFile string) error {
+	changes, err := filesync.WatchDir(procCtx, s.DockerBuild, watchQuietPeriod)
+	if err != nil {
+		return err
+	}
+	for {
+		runCtx, cancel := context.WithCancel(procCtx)
+		dr := s.startInDocker(ctx, daemonID, envFile, s.Cmdline)
+		if dr.err == nil {
+			dr.err = s.addInterceptorToDaemon(ctx, dr.cmd, dr.name)
+		}
+		done := make(chan error, 1)
+		go func() {
+			done <- dr.wait(runCtx)
+		}()
+
+		for restart := false; !restart; {
+			select {
+			case err = <-done:
+				cancel()
+				return err
+			case <-changes:
+				fmt.Fprintf(output.Info(ctx), "Changes detected in %s, rebuilding\n", s.DockerBuild)
+				changed, buildErr := s.buildImage(ctx)
+				switch {
+				case buildErr != nil:
+					fmt.Fprintf(output.Err(ctx), "Rebuild failed, the container was not restarted: %v\n", buildErr)
+				case !changed:
+					fmt.Fprintln(output.Info(ctx), "The image is unchanged, the container was not restarted")
+				default:
+					restart = true
+				}
+			}
+		}
+
+		// Stop the container and wait until it's gone, so that its name can be reused.
+		cancel()
+		if err = <-done; err != nil {
+			return err
+		}
+		if procCtx.Err() != nil {
+			return nil
+		}
+		fmt.Fprintf(output.Info(ctx), "Restarting container %s\n", dr.name)
+	}
+}
@@ -0,0 +1,86 @@
+package intercept
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+// faultMatchKeys maps the keys of a --fault that filter on the path of a request to the keys that the
+// traffic-agent uses for them.
+//
+//nolint:gochecknoglobals // constant
+var faultMatchKeys = map[string]string{
+	"path-equal":  ":path-equal:",
+	"path-prefix": ":path-prefix:",
+	"path-regex":  ":path-regex:",
+}
+
+// parseFaultRule parses a --fault flag, which is a comma separated list of key=value pairs, e.g.
+// "percent=10,delay=2s,status=503,header=Retry-After:30,match=x-user:alice,path-prefix=/api".
+func parseFaultRule(s string) (*manager.FaultRule, error) {
+	rule := &manager.FaultRule{Percent: 100}
+	bad := func(format string, args ...any) error {
+		return errcat.User.Newf("--fault %q: "+format, append([]any{s}, args...)...)
+	}
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || v == "" {
+			return nil, bad("%q is not in the form key=value", kv)
+		}
+		switch k {
+		case "percent":
+			p, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+			if err != nil || p <= 0 || p > 100 {
+				return nil, bad("percent must be a number greater than 0 and at most 100")
+			}
+			rule.Percent = p
+		case "delay":
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				return nil, bad("delay must be a positive duration, e.g. 2s")
+			}
+			rule.Delay = durationpb.New(d)
+		case "status":
+			c, err := strconv.ParseInt(v, 10, 32)
+			if err != nil || c < 200 || c > 599 {
+				return nil, bad("status must be an HTTP status code between 200 and 599")
+			}
+			rule.AbortStatus = int32(c)
+		case "header", "match":
+			n, hv, ok := strings.Cut(v, ":")
+			if !ok || n == "" {
+				return nil, bad("%s %q is not in the form name:value", k, v)
+			}
+			if k == "header" {
+				if rule.ResponseHeaders == nil {
+					rule.ResponseHeaders = make(map[string]string)
+				}
+				rule.ResponseHeaders[n] = hv
+			} else {
+				if rule.Match == nil {
+					rule.Match = make(map[string]string)
+				}
+				rule.Match[n] = hv
+			}
+		default:
+			mk, ok := faultMatchKeys[k]
+			if !ok {
+				return nil, bad("unknown key %q", k)
+			}
+			if rule.Match == nil {
+				rule.Match = make(map[string]string)
+			}
+			rule.Match[mk] = v
+		}
+	}
+	if rule.Delay == nil && rule.AbortStatus == 0 && len(rule.ResponseHeaders) == 0 {
+		return nil, bad("a delay, status, or header must be given")
+	}
+	return rule, nil
+}
@@ -0,0 +1,39 @@
+package intercept
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFaultRule(t *testing.T) {
+	rule, err := parseFaultRule("percent=10,delay=2s,status=503,header=Retry-After:30,header=Server:,match=x-user:alice,path-prefix=/api")
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, rule.Percent)
+	assert.Equal(t, 2*time.Second, rule.Delay.AsDuration())
+	assert.Equal(t, int32(503), rule.AbortStatus)
+	assert.Equal(t, map[string]string{"Retry-After": "30", "Server": ""}, rule.ResponseHeaders)
+	assert.Equal(t, map[string]string{"x-user": "alice", ":path-prefix:": "/api"}, rule.Match)
+
+	rule, err = parseFaultRule("delay=500ms")
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, rule.Percent)
+	assert.Empty(t, rule.Match)
+
+	for _, bad := range []string{
+		"",
+		"percent=10",
+		"percent=0,status=500",
+		"percent=101,status=500",
+		"delay=-1s",
+		"status=99",
+		"status=503,header=Retry-After",
+		"status=503,match=:alice",
+		"status=503,method=GET",
+	} {
+		_, err = parseFaultRule(bad)
+		assert.Error(t, err, bad)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net"
 	"strings"
+	"time"
 
 	"github.com/telepresenceio/telepresence/rpc/v2/manager"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
@@ -34,6 +35,7 @@ type Info struct {
 	Disposition   string            `json:"disposition,omitempty"     yaml:"disposition,omitempty"`
 	Message       string            `json:"message,omitempty"         yaml:"message,omitempty"`
 	WorkloadKind  string            `json:"workload_kind,omitempty"   yaml:"workload_kind,omitempty"`
+	Cluster       string            `json:"cluster,omitempty"         yaml:"cluster,omitempty"`
 	TargetHost    string            `json:"target_host,omitempty"     yaml:"target_host,omitempty"`
 	TargetPort    int32             `json:"target_port,omitempty"     yaml:"target_port,omitempty"`
 	ServicePortID string            `json:"service_port_id,omitempty" yaml:"service_port_id,omitempty"`
@@ -45,6 +47,8 @@ type Info struct {
 	Global        bool              `json:"global,omitempty"          yaml:"global,omitempty"`
 	PreviewURL    string            `json:"preview_url,omitempty"     yaml:"preview_url,omitempty"`
 	Ingress       *Ingress          `json:"ingress,omitempty"         yaml:"ingress,omitempty"`
+	ExpiresAt     *time.Time        `json:"expires_at,omitempty"      yaml:"expires_at,omitempty"`
+	Propagation   string            `json:"propagation,omitempty"     yaml:"propagation,omitempty"`
 	debug         bool
 }
 
@@ -100,12 +104,18 @@ func NewMount(ctx context.Context, ii *manager.InterceptInfo, mountError string)
 
 func NewInfo(ctx context.Context, ii *manager.InterceptInfo, mountError string) *Info {
 	spec := ii.Spec
+	var expiresAt *time.Time
+	if ea := ii.ExpiresAt; ea != nil {
+		t := ea.AsTime().Local()
+		expiresAt = &t
+	}
 	return &Info{
 		ID:            ii.Id,
 		Name:          spec.Name,
 		Disposition:   ii.Disposition.String(),
 		Message:       ii.Message,
 		WorkloadKind:  spec.WorkloadKind,
+		Cluster:       spec.Cluster,
 		TargetHost:    spec.TargetHost,
 		TargetPort:    spec.TargetPort,
 		Mount:         NewMount(ctx, ii, mountError),
@@ -117,6 +127,7 @@ func NewInfo(ctx context.Context, ii *manager.InterceptInfo, mountError string)
 		Global:        spec.Mechanism == "tcp",
 		PreviewURL:    PreviewURL(ii.PreviewDomain),
 		Ingress:       NewIngress(ii.PreviewSpec),
+		ExpiresAt:     expiresAt,
 	}
 }
 
@@ -136,6 +147,9 @@ func (ii *Info) WriteTo(w io.Writer) (int64, error) {
 		return msg
 	}())
 	kvf.Add("Workload kind", ii.WorkloadKind)
+	if ii.Cluster != "" {
+		kvf.Add("Cluster", ii.Cluster)
+	}
 
 	if ii.debug {
 		kvf.Add("ID", ii.ID)
@@ -189,5 +203,8 @@ func (ii *Info) WriteTo(w io.Writer) (int64, error) {
 	if in := ii.Ingress; in != nil {
 		kvf.Add("Layer 5 Hostname", in.L5Host)
 	}
+	if ii.ExpiresAt != nil {
+		kvf.Add("Expires at", ii.ExpiresAt.Format(time.RFC3339))
+	}
 	return kvf.WriteTo(w)
 }
@@ -0,0 +1,74 @@
+package intercept
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+// autoPort is the <local port> that tells Telepresence to pick a free port.
+const autoPort = "auto"
+
+// resolveLocalPort ensures that the local port of the intercept can be bound by the process that will
+// listen to it. A free port is picked when the port is "auto". A port that is already in use is
+// replaced with a free port when it was defaulted, and reported as an error when it was given
+// explicitly. The check is only made when Telepresence starts the intercept handler, because otherwise
+// the port is expected to be in use by a handler that is already running.
+func (s *state) resolveLocalPort(ctx context.Context, remote bool) error {
+	host := s.Address
+	if s.DockerRun {
+		if remote && !s.autoPort {
+			// The handler will use the network of the daemon's container, so the port can't be checked here.
+			return nil
+		}
+		// The docker publish flag binds all interfaces. When the handler uses the network of the daemon's
+		// container, a port that is free here is picked, because that network only has the daemon's ports.
+		host = ""
+	}
+	if s.autoPort {
+		port, err := freeLocalPort(host)
+		if err != nil {
+			return err
+		}
+		s.localPort = port
+		fmt.Fprintf(output.Info(ctx), "Using local port %d\n", port)
+		return nil
+	}
+	if !s.RunAndLeave() || localPortAvailable(host, s.localPort) {
+		return nil
+	}
+	if !s.portDefaulted {
+		return errcat.User.Newf("local port %d is already in use. Use --port %s to let Telepresence pick a free port", s.localPort, autoPort)
+	}
+	port, err := freeLocalPort(host)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(output.Info(ctx), "Local port %d is already in use, using %d instead\n", s.localPort, port)
+	s.localPort = port
+	return nil
+}
+
+// localPortAvailable returns true if the given TCP port can be bound on the given host.
+func localPortAvailable(host string, port uint16) bool {
+	l, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(int(port))))
+	if err != nil {
+		return false
+	}
+	_ = l.Close()
+	return true
+}
+
+// freeLocalPort returns a TCP port that is free on the given host.
+func freeLocalPort(host string) (uint16, error) {
+	l, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		return 0, errcat.NoDaemonLogs.Newf("unable to find a free local port: %w", err)
+	}
+	defer l.Close()
+	return uint16(l.Addr().(*net.TCPAddr).Port), nil
+}
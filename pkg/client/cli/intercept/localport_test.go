@@ -0,0 +1,51 @@
+package intercept
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+)
+
+func TestParseAutoPort(t *testing.T) {
+	local, docker, svcPortID, err := parsePort("auto:http", false, false)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(0), local)
+	assert.Equal(t, uint16(0), docker)
+	assert.Equal(t, "http", svcPortID)
+
+	local, docker, _, err = parsePort("auto:8080", true, false)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(0), local)
+	assert.Equal(t, uint16(8080), docker)
+
+	_, _, _, err = parsePort("auto", true, false)
+	assert.Error(t, err)
+}
+
+func TestLocalPortAvailable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	port := uint16(l.Addr().(*net.TCPAddr).Port)
+	assert.False(t, localPortAvailable("127.0.0.1", port))
+
+	free, err := freeLocalPort("127.0.0.1")
+	require.NoError(t, err)
+	assert.NotEqual(t, port, free)
+	assert.True(t, localPortAvailable("127.0.0.1", free))
+}
+
+func TestResolveAutoPortRemoteDockerRun(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	s := &state{Command: &Command{DockerRun: true}, autoPort: true}
+	require.NoError(t, s.resolveLocalPort(ctx, true))
+	assert.NotZero(t, s.localPort)
+
+	s = &state{Command: &Command{DockerRun: true}, localPort: 8080}
+	require.NoError(t, s.resolveLocalPort(ctx, true))
+	assert.Equal(t, uint16(8080), s.localPort)
+}
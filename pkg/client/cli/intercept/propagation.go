@@ -0,0 +1,158 @@
+package intercept
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/restapi"
+)
+
+// PropagationLanguages are the languages that --generate-propagation can generate code for.
+var PropagationLanguages = []string{"go", "java", "node"} //nolint:gochecknoglobals // constant
+
+// propagationHeaders returns the canonical names of the headers that must be propagated from incoming to outgoing
+// requests for the given intercept to match them, i.e. the headers of its filter and the intercept ID header.
+func propagationHeaders(ii *manager.InterceptInfo) []string {
+	names := []string{http.CanonicalHeaderKey(restapi.HeaderInterceptID)}
+	for k := range ii.GetHeaders() {
+		if k = http.CanonicalHeaderKey(k); k != names[0] {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+const goPropagation = `// Header propagation for intercept {{ .Name }}, generated by "telepresence intercept --generate-propagation go".
+package propagation
+
+import (
+	"context"
+	"net/http"
+)
+
+// Headers are the headers that are propagated from incoming to outgoing requests.
+var Headers = []string{ {{- range $i, $h := .Headers }}{{ if $i }}, {{ end }}{{ printf "%q" $h }}{{ end -}} }
+
+type headersKey struct{}
+
+// Middleware stores the propagated headers of incoming requests in the request context.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := http.Header{}
+		for _, n := range Headers {
+			if v := r.Header.Values(n); len(v) > 0 {
+				h[n] = v
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), headersKey{}, h)))
+	})
+}
+
+// Transport adds the propagated headers of the request context to outgoing requests.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if h, ok := r.Context().Value(headersKey{}).(http.Header); ok && len(h) > 0 {
+		r = r.Clone(r.Context())
+		for n, v := range h {
+			r.Header[n] = v
+		}
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(r)
+}
+`
+
+const javaPropagation = `// Header propagation for intercept {{ .Name }}, generated by "telepresence intercept --generate-propagation java".
+package propagation;
+
+import java.io.IOException;
+import java.util.HashMap;
+import java.util.List;
+import java.util.Map;
+import javax.servlet.*;
+import javax.servlet.http.HttpServletRequest;
+
+public class TelepresencePropagation implements Filter {
+  public static final List<String> HEADERS = List.of({{ range $i, $h := .Headers }}{{ if $i }}, {{ end }}{{ printf "%q" $h }}{{ end }});
+  private static final ThreadLocal<Map<String, String>> CURRENT = ThreadLocal.withInitial(HashMap::new);
+
+  // Returns the propagated headers of the request that is being served, to be added to outgoing requests.
+  public static Map<String, String> current() {
+    return CURRENT.get();
+  }
+
+  @Override
+  public void doFilter(ServletRequest req, ServletResponse resp, FilterChain chain) throws IOException, ServletException {
+    Map<String, String> h = new HashMap<>();
+    for (String n : HEADERS) {
+      String v = ((HttpServletRequest) req).getHeader(n);
+      if (v != null) {
+        h.put(n, v);
+      }
+    }
+    CURRENT.set(h);
+    try {
+      chain.doFilter(req, resp);
+    } finally {
+      CURRENT.remove();
+    }
+  }
+}
+`
+
+const nodePropagation = `// Header propagation for intercept {{ .Name }}, generated by "telepresence intercept --generate-propagation node".
+const { AsyncLocalStorage } = require('async_hooks');
+
+const HEADERS = [{{ range $i, $h := .Headers }}{{ if $i }}, {{ end }}{{ printf "%q" $h }}{{ end }}];
+const storage = new AsyncLocalStorage();
+
+// Express/Connect middleware that stores the propagated headers of incoming requests.
+function middleware(req, res, next) {
+  const h = {};
+  for (const n of HEADERS) {
+    const v = req.headers[n.toLowerCase()];
+    if (v !== undefined) {
+      h[n] = v;
+    }
+  }
+  storage.run(h, next);
+}
+
+// Returns the propagated headers of the request that is being served, to be added to outgoing requests.
+function current() {
+  return storage.getStore() || {};
+}
+
+module.exports = { HEADERS, middleware, current };
+`
+
+//nolint:gochecknoglobals // constant
+var propagationTemplates = map[string]string{
+	"go":   goPropagation,
+	"java": javaPropagation,
+	"node": nodePropagation,
+}
+
+// WritePropagation writes code in the given language that propagates the headers of the given intercept from
+// incoming to outgoing requests.
+func WritePropagation(w io.Writer, lang string, ii *manager.InterceptInfo) error {
+	t, err := template.New(lang).Parse(propagationTemplates[strings.ToLower(lang)])
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, map[string]any{
+		"Name":    ii.GetSpec().GetName(),
+		"Headers": propagationHeaders(ii),
+	})
+}
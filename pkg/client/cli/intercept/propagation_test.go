@@ -0,0 +1,42 @@
+package intercept_test
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/intercept"
+)
+
+func TestWritePropagation(t *testing.T) {
+	ii := &manager.InterceptInfo{
+		Spec:    &manager.InterceptSpec{Name: "echo"},
+		Headers: map[string]string{"x-user": "jane", "x-telepresence-intercept-id": "abc:echo"},
+	}
+	for _, lang := range intercept.PropagationLanguages {
+		t.Run(lang, func(t *testing.T) {
+			var sb strings.Builder
+			require.NoError(t, intercept.WritePropagation(&sb, lang, ii))
+			code := sb.String()
+			assert.Contains(t, code, `"X-Telepresence-Intercept-Id", "X-User"`)
+			assert.Contains(t, code, "intercept echo")
+			if lang == "go" {
+				_, err := parser.ParseFile(token.NewFileSet(), "propagation.go", code, 0)
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGeneratePropagationFlag(t *testing.T) {
+	ic, cmd, args := parseInterceptCmd(t, "echo", "--generate-propagation", "go")
+	require.NoError(t, ic.Validate(cmd, args))
+
+	ic, cmd, args = parseInterceptCmd(t, "echo", "--generate-propagation", "rust")
+	assert.ErrorContains(t, ic.Validate(cmd, args), "--generate-propagation must be one of go, java, node")
+}
@@ -17,6 +17,7 @@ func Result(r *connector.InterceptResult, err error) error {
 	}
 	msg := ""
 	errCat := errcat.Unknown
+	var details any
 	switch r.Error {
 	case common.InterceptError_UNSPECIFIED:
 		return nil
@@ -52,11 +53,14 @@ func Result(r *connector.InterceptResult, err error) error {
 		}
 		st := &strings.Builder{}
 		fmt.Fprintf(st, "Found more than one possible match:")
+		refs := make([]WorkloadRef, len(matches))
 		for idx := range matches {
 			match := &matches[idx]
 			fmt.Fprintf(st, "\n%4d: %s.%s", idx+1, match.Name, match.Namespace)
+			refs[idx] = WorkloadRef{Name: match.Name, Namespace: match.Namespace}
 		}
 		msg = st.String()
+		details = refs
 	case common.InterceptError_FAILED_TO_ESTABLISH:
 		msg = fmt.Sprintf("Failed to establish intercept: %s", r.ErrorText)
 	case common.InterceptError_UNSUPPORTED_WORKLOAD:
@@ -67,6 +71,12 @@ func Result(r *connector.InterceptResult, err error) error {
 		msg = fmt.Sprintf("Mount point already in use by intercept %q", r.ErrorText)
 	case common.InterceptError_MISCONFIGURED_WORKLOAD:
 		msg = r.ErrorText
+	case common.InterceptError_OBSERVE_ONLY:
+		msg = fmt.Sprintf("Connection %q is observing and cannot intercept. Reconnect without --observe to intercept", r.ErrorText)
+	case common.InterceptError_POLICY_VIOLATION:
+		msg = fmt.Sprintf("Intercept refused by the traffic-manager's intercept policy: %s", r.ErrorText)
+	case common.InterceptError_TAKE_OVER_REFUSED:
+		msg = fmt.Sprintf("Unable to take over the intercept: %s", r.ErrorText)
 	case common.InterceptError_UNKNOWN_FLAG:
 		msg = fmt.Sprintf("Unknown flag: %s", r.ErrorText)
 	default:
@@ -79,5 +89,12 @@ func Result(r *connector.InterceptResult, err error) error {
 	if id := r.GetInterceptInfo().GetId(); id != "" {
 		msg = fmt.Sprintf("%s: id = %q", msg, id)
 	}
-	return errCat.Newf(msg)
+	return errcat.WithCode(errCat.New(msg), errcat.Code("INTERCEPT_"+r.Error.String()), details)
+}
+
+// WorkloadRef identifies a workload. A list of them are the details of an error with the
+// code INTERCEPT_AMBIGUOUS_MATCH.
+type WorkloadRef struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
 }
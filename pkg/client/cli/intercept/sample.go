@@ -0,0 +1,145 @@
+package intercept
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/output"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/ioutil"
+)
+
+const httpHeaderArg = "--http-header="
+
+// filterKeys maps the mechanism arguments that filter on the path of a request to the keys that the
+// traffic-agent uses for them.
+//
+//nolint:gochecknoglobals // constant
+var filterKeys = map[string]string{
+	"--http-path-equal=":  ":path-equal:",
+	"--http-path-prefix=": ":path-prefix:",
+	"--http-path-regex=":  ":path-regex:",
+}
+
+// sampleFilter returns the filter that the traffic-agent uses to sample the requests that an intercept
+// with the given mechanism arguments would match.
+func sampleFilter(mechArgs []string) (map[string]string, error) {
+	filter := make(map[string]string, len(mechArgs))
+	for _, arg := range mechArgs {
+		if strings.HasPrefix(arg, httpHeaderArg) {
+			if n, v, ok := strings.Cut(arg[len(httpHeaderArg):], "="); ok && n != "" {
+				filter[n] = v
+				continue
+			}
+			return nil, errcat.User.Newf("%s is not in the form %sname=value", arg, httpHeaderArg)
+		}
+		found := false
+		for prefix, key := range filterKeys {
+			if strings.HasPrefix(arg, prefix) {
+				filter[key] = arg[len(prefix):]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errcat.User.Newf("the mechanism argument %s cannot be sampled", arg)
+		}
+	}
+	return filter, nil
+}
+
+// sampleResult is the formatted output of --sample.
+type sampleResult struct {
+	Workload               string            `json:"workload" yaml:"workload"`
+	Filter                 map[string]string `json:"filter,omitempty" yaml:"filter,omitempty"`
+	Duration               time.Duration     `json:"duration" yaml:"duration"`
+	Agents                 int32             `json:"agents" yaml:"agents"`
+	Requests               uint64            `json:"requests" yaml:"requests"`
+	Matching               uint64            `json:"matching" yaml:"matching"`
+	UninspectedConnections uint64            `json:"uninspected_connections" yaml:"uninspected_connections"`
+}
+
+func (a *Command) runSample(cmd *cobra.Command) error {
+	filter, err := sampleFilter(a.MechanismArgs)
+	if err != nil {
+		return err
+	}
+	ctx := cmd.Context()
+	ud := daemon.GetUserClient(ctx)
+	_, _, svcPortID, err := parsePort(a.Port, false, ud.Remote())
+	if err != nil {
+		return err
+	}
+	if !output.WantsFormatted(cmd) {
+		fmt.Fprintf(output.Info(ctx), "Sampling the requests of %s during %s\n", a.AgentName, a.Sample)
+	}
+	r, err := ud.SampleIntercept(ctx, &manager.SampleInterceptRequest{
+		Namespace:             a.Namespace,
+		AgentName:             a.AgentName,
+		ServicePortIdentifier: svcPortID,
+		Filter:                filter,
+		Duration:              durationpb.New(a.Sample),
+	})
+	if err != nil {
+		return err
+	}
+	sr := &sampleResult{
+		Workload:               a.AgentName,
+		Filter:                 filter,
+		Duration:               r.Duration.AsDuration(),
+		Agents:                 r.Agents,
+		Requests:               r.Requests,
+		Matching:               r.Matching,
+		UninspectedConnections: r.UninspectedConnections,
+	}
+	if output.WantsFormatted(cmd) {
+		output.Object(ctx, sr, false)
+	} else {
+		sr.print(cmd.OutOrStdout())
+	}
+	return nil
+}
+
+func (sr *sampleResult) perSecond(n uint64) float64 {
+	if sr.Duration <= 0 {
+		return 0
+	}
+	return float64(n) / sr.Duration.Seconds()
+}
+
+func (sr *sampleResult) print(out io.Writer) {
+	kvf := ioutil.DefaultKeyValueFormatter()
+	kvf.Add("Workload", sr.Workload)
+	if len(sr.Filter) == 0 {
+		kvf.Add("Filter", "none")
+	} else {
+		fs := make([]string, 0, len(sr.Filter))
+		for k, v := range sr.Filter {
+			fs = append(fs, k+"="+v)
+		}
+		sort.Strings(fs)
+		kvf.Add("Filter", strings.Join(fs, ", "))
+	}
+	kvf.Add("Agents", fmt.Sprintf("%d", sr.Agents))
+	kvf.Add("Requests", fmt.Sprintf("%d (%.2f/s)", sr.Requests, sr.perSecond(sr.Requests)))
+	matching := fmt.Sprintf("%d (%.2f/s", sr.Matching, sr.perSecond(sr.Matching))
+	if sr.Requests > 0 {
+		matching += fmt.Sprintf(", %.1f%%", 100*float64(sr.Matching)/float64(sr.Requests))
+	}
+	kvf.Add("Matching", matching+")")
+	if sr.UninspectedConnections > 0 {
+		kvf.Add("Uninspected", fmt.Sprintf("%d connections that didn't start with an HTTP/1.x request", sr.UninspectedConnections))
+	}
+	kvf.Println(out)
+	if sr.Requests > 0 && sr.Matching == sr.Requests {
+		fmt.Fprintln(out, "\nWarning: the filter matches all requests, so the intercept would capture everyone's traffic")
+	}
+}
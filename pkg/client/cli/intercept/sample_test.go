@@ -0,0 +1,44 @@
+package intercept
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleFilter(t *testing.T) {
+	f, err := sampleFilter([]string{"--http-header=x-user=alice", "--http-path-prefix=/api"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"x-user": "alice", ":path-prefix:": "/api"}, f)
+
+	_, err = sampleFilter([]string{"--meta=x=y"})
+	assert.ErrorContains(t, err, "cannot be sampled")
+
+	_, err = sampleFilter([]string{"--http-header=alice"})
+	assert.ErrorContains(t, err, "not in the form")
+}
+
+func TestSampleResult_print(t *testing.T) {
+	sr := &sampleResult{
+		Workload: "echo",
+		Filter:   map[string]string{"x-user": "alice"},
+		Duration: 10 * time.Second,
+		Agents:   2,
+		Requests: 200,
+		Matching: 5,
+	}
+	var out bytes.Buffer
+	sr.print(&out)
+	assert.Contains(t, out.String(), "x-user=alice")
+	assert.Contains(t, out.String(), "200 (20.00/s)")
+	assert.Contains(t, out.String(), "5 (0.50/s, 2.5%)")
+	assert.NotContains(t, out.String(), "Warning")
+
+	sr.Matching = sr.Requests
+	out.Reset()
+	sr.print(&out)
+	assert.Contains(t, out.String(), "matches all requests")
+}
@@ -0,0 +1,208 @@
+package intercept
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+// Spec is the serialized form of an intercept, as written by --to-spec and read by --from-spec. The
+// keys are the names of the corresponding intercept command flags, so a spec can be written by hand
+// using the same vocabulary as the command line.
+type Spec struct {
+	Name            string   `json:"name"`
+	Namespace       string   `json:"namespace,omitempty"`
+	Workload        string   `json:"workload,omitempty"`
+	Service         string   `json:"service,omitempty"`
+	Port            string   `json:"port,omitempty"`
+	Address         string   `json:"address,omitempty"`
+	Handler         []string `json:"handler,omitempty"`
+	Active          string   `json:"active,omitempty"`
+	LocalOnly       bool     `json:"local-only,omitempty"`
+	Mechanism       string   `json:"mechanism,omitempty"`
+	MechanismArgs   []string `json:"mechanism-args,omitempty"`
+	Mount           string   `json:"mount,omitempty"`
+	LocalMountPort  uint16   `json:"local-mount-port,omitempty"`
+	ExposeNodePorts string   `json:"expose-node-ports,omitempty"`
+	ToPod           []string `json:"to-pod,omitempty"`
+	EnvFile         string   `json:"env-file,omitempty"`
+	EnvJSON         string   `json:"env-json,omitempty"`
+	DockerRun       bool     `json:"docker-run,omitempty"`
+	DockerBuild     string   `json:"docker-build,omitempty"`
+	DockerBuildOpt  []string `json:"docker-build-opt,omitempty"`
+	DockerWatch     bool     `json:"docker-watch,omitempty"`
+	DockerMount     string   `json:"docker-mount,omitempty"`
+	Duration        string   `json:"duration,omitempty"`
+	Cluster         string   `json:"cluster,omitempty"`
+	Fault           []string `json:"fault,omitempty"`
+	FaultsOnly      bool     `json:"faults-only,omitempty"`
+
+	// Command is the handler, i.e. the command (or the docker run arguments) that follows "--" on the command line.
+	Command []string `json:"command,omitempty"`
+}
+
+// LoadSpec reads a Spec from the given YAML or JSON file.
+func LoadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errcat.User.Newf("unable to read intercept spec: %w", err)
+	}
+	var spec Spec
+	if err = yaml.UnmarshalStrict(data, &spec); err != nil {
+		return nil, errcat.User.Newf("invalid intercept spec %s: %w", path, err)
+	}
+	if spec.Name == "" {
+		return nil, errcat.User.Newf("invalid intercept spec %s: name is required", path)
+	}
+	return &spec, nil
+}
+
+// Save writes the Spec in YAML format to the given file.
+func (s *Spec) Save(path string) error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(path, data, 0o644); err != nil {
+		return errcat.User.Newf("unable to write intercept spec: %w", err)
+	}
+	return nil
+}
+
+// apply assigns the values of the Spec to the flags of the given command. Flags that were given
+// explicitly on the command line take precedence over the values in the Spec.
+func (s *Spec) apply(cmd *cobra.Command, a *Command) error {
+	optBool := func(b bool) string {
+		if b {
+			return "true"
+		}
+		return ""
+	}
+	optUint16 := func(v uint16) string {
+		if v != 0 {
+			return strconv.Itoa(int(v))
+		}
+		return ""
+	}
+	flags := cmd.Flags()
+	for _, f := range []struct {
+		name   string
+		values []string
+	}{
+		{"workload", []string{s.Workload}},
+		{"service", []string{s.Service}},
+		{"port", []string{s.Port}},
+		{"address", []string{s.Address}},
+		{"handler", s.Handler},
+		{"active", []string{s.Active}},
+		{"local-only", []string{optBool(s.LocalOnly)}},
+		{"mechanism", []string{s.Mechanism}},
+		{"mount", []string{s.Mount}},
+		{"local-mount-port", []string{optUint16(s.LocalMountPort)}},
+		{"expose-node-ports", []string{s.ExposeNodePorts}},
+		{"to-pod", s.ToPod},
+		{"env-file", []string{s.EnvFile}},
+		{"env-json", []string{s.EnvJSON}},
+		{"docker-run", []string{optBool(s.DockerRun)}},
+		{"docker-build", []string{s.DockerBuild}},
+		{"docker-build-opt", s.DockerBuildOpt},
+		{"docker-watch", []string{optBool(s.DockerWatch)}},
+		{"docker-mount", []string{s.DockerMount}},
+		{"duration", []string{s.Duration}},
+		{"cluster", []string{s.Cluster}},
+		{"fault", s.Fault},
+		{"faults-only", []string{optBool(s.FaultsOnly)}},
+	} {
+		if flags.Changed(f.name) {
+			continue
+		}
+		for _, v := range f.values {
+			if v == "" {
+				continue
+			}
+			if err := flags.Set(f.name, v); err != nil {
+				return errcat.User.Newf("invalid %s %q in intercept spec: %w", f.name, v, err)
+			}
+		}
+	}
+	if len(a.MechanismArgs) == 0 {
+		a.MechanismArgs = s.MechanismArgs
+	}
+	a.Namespace = s.Namespace
+	return nil
+}
+
+// spec returns the Spec of the intercept created by this state. The values resolved by the
+// traffic-manager, such as the service and its port, are taken from the given InterceptInfo,
+// which is nil for local-only intercepts.
+func (s *state) spec(ii *manager.InterceptInfo) *Spec {
+	spec := &Spec{
+		Name:            s.Name(),
+		Namespace:       s.Namespace,
+		Workload:        s.AgentName,
+		Service:         s.ServiceName,
+		Port:            s.Port,
+		Handler:         s.Handlers,
+		Active:          s.ActiveHandler,
+		LocalOnly:       s.LocalOnly,
+		Mechanism:       s.Mechanism,
+		MechanismArgs:   s.MechanismArgs,
+		LocalMountPort:  s.LocalMountPort,
+		ExposeNodePorts: s.NodePortAddress,
+		ToPod:           s.ToPod,
+		EnvFile:         s.EnvFile,
+		EnvJSON:         s.EnvJSON,
+		DockerRun:       s.DockerRun,
+		DockerBuild:     s.DockerBuild,
+		DockerBuildOpt:  s.DockerBuildOptions,
+		DockerWatch:     s.DockerWatch,
+		DockerMount:     s.DockerMount,
+		Cluster:         s.Cluster,
+		Fault:           s.Faults,
+		FaultsOnly:      s.FaultsOnly,
+		Command:         s.Cmdline,
+	}
+	if s.Address != "127.0.0.1" {
+		spec.Address = s.Address
+	}
+	if s.Duration > 0 {
+		spec.Duration = s.Duration.String()
+	}
+	if s.MountSet {
+		spec.Mount = s.Mount
+	}
+	if ii != nil {
+		is := ii.Spec
+		spec.Namespace = is.Namespace
+		spec.Workload = is.Agent
+		spec.Service = is.ServiceName
+		port := strconv.Itoa(int(s.localPort))
+		if s.autoPort {
+			port = autoPort
+		}
+		if s.dockerPort != 0 {
+			port += ":" + strconv.Itoa(int(s.dockerPort))
+		}
+		switch {
+		case is.ServicePortName != "":
+			port += ":" + is.ServicePortName
+		case is.ServicePort != 0:
+			port += ":" + strconv.Itoa(int(is.ServicePort))
+		}
+		spec.Port = port
+	}
+	return spec
+}
+
+func (s *state) writeSpec(ii *manager.InterceptInfo) error {
+	if err := s.spec(ii).Save(s.ToSpec); err != nil {
+		return fmt.Errorf("intercept was created, but its spec could not be saved: %w", err)
+	}
+	return nil
+}
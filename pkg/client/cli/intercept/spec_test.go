@@ -0,0 +1,140 @@
+package intercept_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/intercept"
+)
+
+func parseInterceptCmd(t *testing.T, args ...string) (*intercept.Command, *cobra.Command, []string) {
+	t.Helper()
+	ic := &intercept.Command{}
+	cmd := &cobra.Command{Use: "intercept", RunE: func(*cobra.Command, []string) error { return nil }}
+	ic.AddFlags(cmd)
+	cmd.SetContext(client.WithConfig(dlog.NewTestContext(t, false), client.GetDefaultConfig()))
+	require.NoError(t, cmd.ParseFlags(args))
+	return ic, cmd, cmd.Flags().Args()
+}
+
+func TestFromSpec(t *testing.T) {
+	spec := &intercept.Spec{
+		Name:     "echo-test",
+		Workload: "echo",
+		Service:  "echo",
+		Port:     "9090:http",
+		Mount:    "false",
+		ToPod:    []string{"8081", "8082/UDP"},
+		EnvFile:  "echo.env",
+		Duration: "45m0s",
+		Cluster:  "eu-west",
+		Command:  []string{"./server", "--verbose"},
+	}
+	file := filepath.Join(t.TempDir(), "echo.yaml")
+	require.NoError(t, spec.Save(file))
+	loaded, err := intercept.LoadSpec(file)
+	require.NoError(t, err)
+	require.Equal(t, spec, loaded)
+
+	t.Run("name from spec", func(t *testing.T) {
+		ic, cmd, args := parseInterceptCmd(t, "--from-spec", file)
+		require.NoError(t, ic.Validate(cmd, args))
+		assert.Equal(t, "echo-test", ic.Name)
+		assert.Equal(t, "echo", ic.AgentName)
+		assert.Equal(t, "echo", ic.ServiceName)
+		assert.Equal(t, "9090:http", ic.Port)
+		assert.Equal(t, []string{"8081", "8082/UDP"}, ic.ToPod)
+		assert.Equal(t, "echo.env", ic.EnvFile)
+		assert.Equal(t, []string{"./server", "--verbose"}, ic.Cmdline)
+		assert.Equal(t, 45*time.Minute, ic.Duration)
+		assert.Equal(t, "eu-west", ic.Cluster)
+		doMount, _ := ic.GetMountPoint()
+		assert.False(t, doMount)
+	})
+
+	t.Run("flags take precedence", func(t *testing.T) {
+		ic, cmd, args := parseInterceptCmd(t, "--from-spec", file, "--port", "7070", "other", "--", "sh")
+		require.NoError(t, ic.Validate(cmd, args))
+		assert.Equal(t, "other", ic.Name)
+		assert.Equal(t, "7070", ic.Port)
+		assert.Equal(t, "echo", ic.AgentName)
+		assert.Equal(t, []string{"sh"}, ic.Cmdline)
+	})
+
+	t.Run("name required without spec", func(t *testing.T) {
+		ic, cmd, args := parseInterceptCmd(t, "--port", "7070")
+		assert.Error(t, ic.Validate(cmd, args))
+	})
+
+	t.Run("local-only in federated cluster", func(t *testing.T) {
+		ic, cmd, args := parseInterceptCmd(t, "--local-only", "--cluster", "eu-west", "echo")
+		assert.ErrorContains(t, ic.Validate(cmd, args), "federated cluster")
+	})
+
+	t.Run("negative duration", func(t *testing.T) {
+		ic, cmd, args := parseInterceptCmd(t, "--duration", "-5m", "echo")
+		assert.Error(t, ic.Validate(cmd, args))
+	})
+}
+
+func TestHTTPHeaderFlag(t *testing.T) {
+	ic, cmd, args := parseInterceptCmd(t, "echo", "--http-header", "x-user=alice", "--sample", "30s")
+	require.NoError(t, ic.Validate(cmd, args))
+	assert.Equal(t, "http", ic.Mechanism)
+	assert.Equal(t, []string{"--http-header=x-user=alice"}, ic.MechanismArgs)
+	assert.Equal(t, 30*time.Second, ic.Sample)
+
+	ic, cmd, args = parseInterceptCmd(t, "echo", "--http-header", "x-user")
+	assert.ErrorContains(t, ic.Validate(cmd, args), "not in the form name=value")
+
+	ic, cmd, args = parseInterceptCmd(t, "echo", "--http-header", "x-user=alice", "--mechanism", "tcp")
+	assert.ErrorContains(t, ic.Validate(cmd, args), "cannot be used with --mechanism tcp")
+
+	ic, cmd, args = parseInterceptCmd(t, "echo", "--sample", "30s", "--", "make", "run")
+	assert.ErrorContains(t, ic.Validate(cmd, args), "cannot run a command")
+}
+
+func TestHandlerFlags(t *testing.T) {
+	ic, cmd, args := parseInterceptCmd(t, "echo", "--handler", "blue=8080", "--handler", "green=127.0.0.2:8081", "--active", "green")
+	require.NoError(t, ic.Validate(cmd, args))
+	assert.Equal(t, "green", ic.ActiveHandler)
+
+	ic, cmd, args = parseInterceptCmd(t, "echo", "--handler", "blue=8080", "--handler", "green=8081")
+	require.NoError(t, ic.Validate(cmd, args))
+	assert.Equal(t, "blue", ic.ActiveHandler)
+
+	for _, tc := range []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"--handler", "8080"}, "not in the form name=[host:]port"},
+		{[]string{"--handler", "blue=http"}, "not in the form name=[host:]port"},
+		{[]string{"--handler", "blue=8080", "--handler", "blue=8081"}, "given more than once"},
+		{[]string{"--handler", "blue=8080", "--active", "green"}, "--active green is not the name of a --handler"},
+		{[]string{"--active", "blue"}, "--active must be used together with --handler"},
+		{[]string{"--handler", "blue=8080", "--docker-run"}, "cannot be used with --docker-run"},
+	} {
+		ic, cmd, args = parseInterceptCmd(t, append([]string{"echo"}, tc.args...)...)
+		assert.ErrorContains(t, ic.Validate(cmd, args), tc.err)
+	}
+}
+
+func TestFaultsOnlyFlag(t *testing.T) {
+	ic, cmd, args := parseInterceptCmd(t, "echo", "--fault", "status=503", "--faults-only")
+	require.NoError(t, ic.Validate(cmd, args))
+	doMount, _ := ic.GetMountPoint()
+	assert.False(t, doMount)
+
+	ic, cmd, args = parseInterceptCmd(t, "echo", "--faults-only")
+	assert.ErrorContains(t, ic.Validate(cmd, args), "must be used together with --fault")
+
+	ic, cmd, args = parseInterceptCmd(t, "echo", "--fault", "status=503", "--faults-only", "--", "make", "run")
+	assert.ErrorContains(t, ic.Validate(cmd, args), "cannot have a handler")
+}
@@ -7,19 +7,23 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	grpcCodes "google.golang.org/grpc/codes"
 	grpcStatus "google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 	empty "google.golang.org/protobuf/types/known/emptypb"
 	core "k8s.io/api/core/v1"
 
 	"github.com/datawire/dlib/dexec"
 	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/common"
 	"github.com/telepresenceio/telepresence/rpc/v2/connector"
 	"github.com/telepresenceio/telepresence/rpc/v2/manager"
 	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
@@ -50,7 +54,9 @@ type state struct {
 	mountDisabled bool
 	mountPoint    string // if non-empty, this the final mount point of a successful mount
 	localPort     uint16 // the parsed <local port>
+	autoPort      bool   // the <local port> is "auto"
 	dockerPort    uint16
+	imageIdx      int // index of the image built by --docker-build in the Cmdline
 	status        *connector.ConnectInfo
 	info          *Info // Info from the created intercept
 
@@ -80,11 +86,13 @@ func (s *state) Cmd() *cobra.Command {
 
 func (s *state) CreateRequest(ctx context.Context) (*connector.CreateInterceptRequest, error) {
 	spec := &manager.InterceptSpec{
-		Name: s.Name(),
+		Name:      s.Name(),
+		Namespace: s.Namespace,
 	}
 	ir := &connector.CreateInterceptRequest{
 		Spec:         spec,
 		ExtendedInfo: s.ExtendedInfo,
+		TakeOver:     s.TakeOver,
 	}
 
 	if s.AgentName == "" {
@@ -99,7 +107,13 @@ func (s *state) CreateRequest(ctx context.Context) (*connector.CreateInterceptRe
 
 	spec.Mechanism = s.Mechanism
 	spec.MechanismArgs = s.MechanismArgs
+	if s.Duration > 0 {
+		spec.MaxDuration = durationpb.New(s.Duration)
+	}
 	spec.Agent = s.AgentName
+	spec.Cluster = s.Cluster
+	spec.FaultRules = s.faultRules
+	spec.FaultsOnly = s.FaultsOnly
 	spec.TargetHost = "127.0.0.1"
 
 	ud := daemon.GetUserClient(ctx)
@@ -110,11 +124,38 @@ func (s *state) CreateRequest(ctx context.Context) (*connector.CreateInterceptRe
 	if err != nil {
 		return nil, err
 	}
-	spec.TargetPort = int32(s.localPort)
+	s.autoPort = s.localPort == 0
 	if iputil.Parse(s.Address) == nil {
 		return nil, fmt.Errorf("--address %s is not a valid IP address", s.Address)
 	}
-	spec.TargetHost = s.Address
+	if len(s.handlers) > 0 {
+		if ud.Remote() {
+			return nil, errcat.User.New("--handler cannot be used when the daemon runs in a container")
+		}
+		// The daemon routes the traffic to the active handler, so the target is assigned by the daemon.
+		ir.Handlers = s.handlers
+		ir.ActiveHandler = s.ActiveHandler
+	} else {
+		if err = s.resolveLocalPort(ctx, ud.Remote()); err != nil {
+			return nil, err
+		}
+		spec.TargetPort = int32(s.localPort)
+		spec.TargetHost = s.Address
+	}
+	ir.NodePortAddress = s.NodePortAddress
+	if !ud.Remote() {
+		// Let the daemon keep the files up to date when ConfigMaps and Secrets change.
+		if s.EnvFile != "" {
+			if ir.EnvFile, err = filepath.Abs(s.EnvFile); err != nil {
+				return nil, err
+			}
+		}
+		if s.EnvJSON != "" {
+			if ir.EnvJson, err = filepath.Abs(s.EnvJSON); err != nil {
+				return nil, err
+			}
+		}
+	}
 
 	mountEnabled, mountPoint := s.GetMountPoint()
 	if !mountEnabled {
@@ -239,6 +280,10 @@ func (s *state) create(ctx context.Context) (acquired bool, err error) {
 		ir.LocalMountPort = int32(lma[0].Port)
 	}
 
+	if ir.TakeOver && !output.WantsFormatted(s.cmd) {
+		fmt.Fprintln(s.cmd.OutOrStdout(), "Waiting for the owners of conflicting intercepts to hand them over...")
+	}
+
 	// Submit the request
 	r, err := ud.CreateIntercept(ctx, ir)
 	if err = Result(r, err); err != nil {
@@ -247,11 +292,18 @@ func (s *state) create(ctx context.Context) (acquired bool, err error) {
 
 	if s.AgentName == "" {
 		// local-only
+		if s.ToSpec != "" {
+			return true, s.writeSpec(nil)
+		}
 		return true, nil
 	}
 	detailedOutput := s.DetailedOutput && output.WantsFormatted(s.cmd)
 	if !detailedOutput {
 		fmt.Fprintf(s.cmd.OutOrStdout(), "Using %s %s\n", r.WorkloadKind, s.AgentName)
+		if len(s.handlers) > 0 {
+			fmt.Fprintf(s.cmd.OutOrStdout(), "Routing to handler %s at %s. Use \"telepresence switch %s <handler>\" to route to another one\n",
+				s.ActiveHandler, s.handlers[s.ActiveHandler], s.Name())
+		}
 	}
 	var intercept *manager.InterceptInfo
 
@@ -272,6 +324,14 @@ func (s *state) create(ctx context.Context) (acquired bool, err error) {
 	}
 	s.env["TELEPRESENCE_INTERCEPT_ID"] = intercept.Id
 	s.env["TELEPRESENCE_ROOT"] = intercept.ClientMountPoint
+	if s.localPort != 0 && len(s.handlers) == 0 {
+		// The port that the intercept handler must listen to.
+		handlerPort := s.localPort
+		if s.dockerPort != 0 {
+			handlerPort = s.dockerPort
+		}
+		s.env["TELEPRESENCE_LOCAL_PORT"] = strconv.Itoa(int(handlerPort))
+	}
 	if s.EnvFile != "" {
 		if err = s.writeEnvFile(); err != nil {
 			return true, err
@@ -298,12 +358,27 @@ func (s *state) create(ctx context.Context) (acquired bool, err error) {
 		mountError = volumeMountProblem.Error()
 	}
 	s.info = NewInfo(ctx, intercept, mountError)
+	if s.ToSpec != "" {
+		if err = s.writeSpec(intercept); err != nil {
+			return true, err
+		}
+	}
+	var propagation strings.Builder
+	if s.GeneratePropagation != "" {
+		if err = WritePropagation(&propagation, s.GeneratePropagation, intercept); err != nil {
+			return true, err
+		}
+	}
 	if detailedOutput {
+		s.info.Propagation = propagation.String()
 		output.Object(ctx, s.info, true)
 	} else {
 		out := s.cmd.OutOrStdout()
 		_, _ = s.info.WriteTo(out)
 		_, _ = fmt.Fprintln(out)
+		if propagation.Len() > 0 {
+			_, _ = fmt.Fprintf(out, "Header propagation (%s):\n\n%s\n", s.GeneratePropagation, propagation.String())
+		}
 	}
 	return true, nil
 }
@@ -314,10 +389,28 @@ func (s *state) leave(ctx context.Context) error {
 		// Deactivation was caused by a disconnect
 		err = nil
 	}
+	if r.GetError() == common.InterceptError_NOT_FOUND && s.expired() {
+		// Already removed by the traffic-manager
+		return nil
+	}
 	return Result(r, err)
 }
 
-func (s *state) runCommand(ctx context.Context) error {
+// expired returns true if the intercept has a duration that has expired. Some clock skew between
+// the workstation and the traffic-manager is allowed for.
+func (s *state) expired() bool {
+	return s.info != nil && s.info.ExpiresAt != nil && time.Until(*s.info.ExpiresAt) < time.Minute
+}
+
+func (s *state) runCommand(ctx context.Context) (err error) {
+	defer func() {
+		if err != nil && s.expired() {
+			// The handler was terminated because the intercept expired.
+			fmt.Fprintf(output.Info(ctx), "Intercept %s expired at %s and was removed by the traffic-manager\n",
+				s.Name(), s.info.ExpiresAt.Format(time.RFC3339))
+			err = nil
+		}
+	}()
 	// start the interceptor process
 	ctx = dos.WithStdio(ctx, s.cmd)
 	ud := daemon.GetUserClient(ctx)
@@ -365,6 +458,9 @@ func (s *state) runCommand(ctx context.Context) error {
 			}
 		}()
 	}
+	if s.DockerWatch {
+		return s.runWatchedDocker(ctx, procCtx, ud.DaemonID, envFile)
+	}
 	dr = s.startInDocker(ctx, ud.DaemonID, envFile, s.Cmdline)
 	if dr.err == nil {
 		dr.err = s.addInterceptorToDaemon(ctx, dr.cmd, dr.name)
@@ -458,8 +554,11 @@ func parsePort(portSpec string, dockerRun, remote bool) (local uint16, docker ui
 		return 0, 0, "", errcat.User.New("port must be of the format --port <local-port>[:<svcPortIdentifier>]")
 	}
 
-	if local, err = agentconfig.ParseNumericPort(portMapping[0]); err != nil {
-		return portError()
+	// A zero local port means that a free port must be picked.
+	if portMapping[0] != autoPort {
+		if local, err = agentconfig.ParseNumericPort(portMapping[0]); err != nil {
+			return portError()
+		}
 	}
 
 	switch len(portMapping) {
@@ -495,6 +594,9 @@ func parsePort(portSpec string, dockerRun, remote bool) (local uint16, docker ui
 		return portError()
 	}
 	if dockerRun && !remote && docker == 0 {
+		if local == 0 {
+			return 0, 0, "", errcat.User.New("--port auto must be combined with a container port when used with --docker-run")
+		}
 		docker = local
 	}
 	return local, docker, svcPortId, nil
@@ -18,6 +18,7 @@ import (
 	"github.com/telepresenceio/telepresence/v2/pkg/client/userd/trafficmgr"
 	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
 	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+	"github.com/telepresenceio/telepresence/v2/pkg/i18n"
 	"github.com/telepresenceio/telepresence/v2/pkg/proc"
 )
 
@@ -55,6 +56,9 @@ func InitContext(ctx context.Context) context.Context {
 }
 
 func Main(ctx context.Context) {
+	if env := client.GetEnv(ctx); env != nil && env.ConfigDir != "" {
+		ctx = filelocation.WithAppUserConfigDir(ctx, env.ConfigDir)
+	}
 	if dir := os.Getenv("DEV_TELEPRESENCE_CONFIG_DIR"); dir != "" {
 		ctx = filelocation.WithAppUserConfigDir(ctx, dir)
 	}
@@ -71,19 +75,23 @@ func Main(ctx context.Context) {
 		}
 	} else {
 		if cmd, fmtOutput, err := output.Execute(cmd.Telepresence(ctx)); err != nil {
+			if code, ok := errcat.GetExitCode(err); ok {
+				os.Exit(code)
+			}
 			if fmtOutput {
 				os.Exit(1)
 			}
-			fmt.Fprintf(cmd.ErrOrStderr(), "%s: error: %v\n", cmd.CommandPath(), err)
+			ctx := cmd.Context()
+			fmt.Fprintln(cmd.ErrOrStderr(), i18n.Sprintf(ctx, "%s: error: %s", cmd.CommandPath(), errcat.Message(ctx, err)))
 			if errcat.GetCategory(err) > errcat.NoDaemonLogs {
 				if summarizeLogs(ctx, cmd) {
 					// If the user gets here, it might be an actual bug that they found, so
 					// point them to the `gather-logs` command in case they want to open an
 					// issue.
-					fmt.Fprintln(cmd.ErrOrStderr(), "If you think you have encountered a bug"+
+					fmt.Fprintln(cmd.ErrOrStderr(), i18n.T(ctx, "If you think you have encountered a bug"+
 						", please run `telepresence gather-logs` and attach the "+
 						"telepresence_logs.zip to your github issue or create a new one: "+
-						"https://github.com/telepresenceio/telepresence/issues/new?template=Bug_report.md .")
+						"https://github.com/telepresenceio/telepresence/issues/new?template=Bug_report.md ."))
 				}
 			}
 			os.Exit(1)
@@ -118,7 +118,7 @@ func Execute(cmd *cobra.Command) (*cobra.Command, bool, error) {
 	}
 
 	var obj any
-	if err == nil && o.override {
+	if _, silent := errcat.GetExitCode(err); (err == nil || silent) && o.override {
 		obj = o.obj
 	} else {
 		response := &object{
@@ -133,7 +133,9 @@ func Execute(cmd *cobra.Command) (*cobra.Command, bool, error) {
 			response.Stderr = buf.String()
 		}
 		if err != nil {
-			response.Err = err.Error()
+			response.Err = errcat.Message(cmd.Context(), err)
+			response.ErrCode = errcat.GetCode(err)
+			response.ErrDetails = errcat.GetDetails(err)
 		}
 		// don't print out the "zero" object
 		if response.hasCmdOnly() {
@@ -232,6 +234,10 @@ type (
 		Stdout any    `json:"stdout,omitempty"`
 		Stderr any    `json:"stderr,omitempty"`
 		Err    string `json:"err,omitempty"`
+
+		// ErrCode and ErrDetails enable tools to act on the error without parsing its message.
+		ErrCode    errcat.Code `json:"err_code,omitempty"`
+		ErrDetails any         `json:"err_details,omitempty"`
 	}
 )
 
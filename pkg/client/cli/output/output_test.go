@@ -13,6 +13,7 @@ import (
 	"sigs.k8s.io/yaml"
 
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/global"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
 )
 
 func TestWithOutput(t *testing.T) {
@@ -86,6 +87,30 @@ func TestWithOutput(t *testing.T) {
 		require.Equal(t, expectedErr, m["err"], "did not get expected err, got: %s", m["err"])
 	})
 
+	t.Run("json output with coded error", func(t *testing.T) {
+		type details struct {
+			Command []string `json:"command"`
+		}
+		cmd, outBuf, _ := newCmdWithBufs()
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			return errcat.WithCode(errcat.User.New("ERROR"), errcat.CodeRootDaemonPermission, &details{Command: []string{"daemon-foreground"}})
+		}
+		cmd.SetArgs([]string{"--output=json"})
+		_, _, err := Execute(cmd)
+		require.Error(t, err)
+
+		stdout := outBuf.String()
+		var m struct {
+			Err        string  `json:"err"`
+			ErrCode    string  `json:"err_code"`
+			ErrDetails details `json:"err_details"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(stdout), &m), "did not get json as stdout, got: %s", stdout)
+		require.Equal(t, "ERROR", m.Err)
+		require.Equal(t, "EPERM_ROOT_DAEMON", m.ErrCode)
+		require.Equal(t, []string{"daemon-foreground"}, m.ErrDetails.Command)
+	})
+
 	t.Run("yaml output with error", func(t *testing.T) {
 		expectedErr := "ERROR"
 		cmd, outBuf, _ := newCmdWithBufs()
@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package selfupdate
+
+import "os"
+
+// replace atomically replaces dst with src.
+func replace(src, dst string) error {
+	return os.Rename(src, dst)
+}
@@ -0,0 +1,18 @@
+package selfupdate
+
+import "os"
+
+// replace replaces dst with src. Windows will not allow a running executable to be overwritten, but
+// it can be renamed, so the old executable is moved aside first. It will be removed on the next upgrade.
+func replace(src, dst string) error {
+	old := dst + ".old"
+	_ = os.Remove(old)
+	if err := os.Rename(dst, old); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		_ = os.Rename(old, dst)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+// Package selfupdate finds, downloads, verifies, and installs new releases of the telepresence binary.
+package selfupdate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/blang/semver"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+// DefaultBaseURL is the base URL of the official download site.
+const DefaultBaseURL = "https://app.getambassador.io/download/tel2"
+
+// Updater finds and installs releases from a channel.
+type Updater struct {
+	// BaseURL is the URL of the download site. Releases are found at <BaseURL>/<os>/<arch>/<version>/.
+	BaseURL string
+
+	// Channel is the name of the file, minus its ".txt" suffix, that contains the version of the
+	// latest release in the channel.
+	Channel string
+
+	// PublicKey, when not nil, is used to verify the signature of the checksum file.
+	PublicKey ed25519.PublicKey
+
+	GOOS   string
+	GOARCH string
+	Client *http.Client
+}
+
+// NewUpdater returns an Updater for the given channel. An empty channel means that the channel
+// configured in the client configuration is used.
+func NewUpdater(ctx context.Context, channel string) (*Updater, error) {
+	cfg := client.GetConfig(ctx).Upgrade()
+	if channel == "" {
+		channel = cfg.Channel
+	}
+	u := &Updater{
+		BaseURL: DefaultBaseURL,
+		Channel: channel,
+		GOOS:    runtime.GOOS,
+		GOARCH:  runtime.GOARCH,
+		Client:  http.DefaultClient,
+	}
+	insecure := false
+	switch channel {
+	case client.UpgradeChannelStable, client.UpgradeChannelLatest:
+	default:
+		mu, err := url.Parse(channel)
+		if err != nil || !(mu.Scheme == "https" || mu.Scheme == "http") || mu.Host == "" {
+			return nil, errcat.User.Newf(`invalid channel %q, must be %q, %q, or the URL of a mirror`,
+				channel, client.UpgradeChannelStable, client.UpgradeChannelLatest)
+		}
+		u.BaseURL = strings.TrimSuffix(channel, "/")
+		u.Channel = client.UpgradeChannelStable
+		insecure = mu.Scheme != "https"
+	}
+	if cfg.PublicKey != "" {
+		key, err := base64.StdEncoding.DecodeString(cfg.PublicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, errcat.Config.Newf("upgrade.publicKey is not a base64 encoded ed25519 public key")
+		}
+		u.PublicKey = key
+	}
+	if insecure && u.PublicKey == nil {
+		// Without TLS, the checksum file can be replaced along with the binary, so only a signature protects it.
+		return nil, errcat.Config.Newf("the mirror %q doesn't use https, so upgrade.publicKey must be configured", channel)
+	}
+	return u, nil
+}
+
+func (u *Updater) binaryName() string {
+	if u.GOOS == "windows" {
+		return "telepresence.exe"
+	}
+	return "telepresence"
+}
+
+func (u *Updater) releaseURL(elems ...string) string {
+	return strings.Join(append([]string{u.BaseURL, u.GOOS, u.GOARCH}, elems...), "/")
+}
+
+func (u *Updater) get(ctx context.Context, addr string) (io.ReadCloser, error) {
+	rq, err := http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := u.Client.Do(rq)
+	if err != nil {
+		return nil, err
+	}
+	if rs.StatusCode != http.StatusOK {
+		rs.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", addr, rs.Status)
+	}
+	return rs.Body, nil
+}
+
+func (u *Updater) getSmall(ctx context.Context, addr string) ([]byte, error) {
+	body, err := u.get(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(io.LimitReader(body, 64*1024))
+}
+
+// Latest returns the version of the latest release in the channel.
+func (u *Updater) Latest(ctx context.Context) (semver.Version, error) {
+	data, err := u.getSmall(ctx, u.releaseURL(u.Channel+".txt"))
+	if err != nil {
+		return semver.Version{}, err
+	}
+	v, err := semver.Parse(strings.TrimPrefix(strings.TrimSpace(string(data)), "v"))
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("channel %q contains an invalid version: %w", u.Channel, err)
+	}
+	return v, nil
+}
+
+// checksum returns the expected sha256 checksum of the binary of the given release. The checksum
+// file's signature is verified when the Updater has a PublicKey.
+func (u *Updater) checksum(ctx context.Context, version string) ([]byte, error) {
+	sumURL := u.releaseURL(version, u.binaryName()+".sha256")
+	data, err := u.getSmall(ctx, sumURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.PublicKey != nil {
+		sigData, err := u.getSmall(ctx, sumURL+".sig")
+		if err != nil {
+			return nil, fmt.Errorf("unable to retrieve signature: %w", err)
+		}
+		sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigData)))
+		if err != nil || !ed25519.Verify(u.PublicKey, data, sig) {
+			return nil, errcat.User.Newf("signature verification of %s failed", sumURL)
+		}
+	}
+
+	// The file is either just the checksum, or in the format produced by sha256sum.
+	sc := bufio.NewScanner(strings.NewReader(string(data)))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 || len(fields) > 1 && strings.TrimPrefix(fields[1], "*") != u.binaryName() {
+			continue
+		}
+		if sum, err := hex.DecodeString(fields[0]); err == nil && len(sum) == sha256.Size {
+			return sum, nil
+		}
+	}
+	return nil, fmt.Errorf("%s contains no checksum for %s", sumURL, u.binaryName())
+}
+
+// Install downloads the given release, verifies it, and atomically replaces the executable at exe with it.
+func (u *Updater) Install(ctx context.Context, version semver.Version, exe string) error {
+	vs := "v" + version.String()
+	sum, err := u.checksum(ctx, vs)
+	if err != nil {
+		return err
+	}
+	body, err := u.get(ctx, u.releaseURL(vs, u.binaryName()))
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	// The temporary file must be in the same directory as the executable for the final rename to be atomic.
+	tmp, err := os.CreateTemp(filepath.Dir(exe), "."+filepath.Base(exe)+"-*")
+	if err != nil {
+		return errcat.User.Newf("unable to replace %s: %v", exe, err)
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		if tmpName != "" {
+			_ = os.Remove(tmpName)
+		}
+	}()
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("download of %s failed: %w", vs, err)
+	}
+	if got := h.Sum(nil); !bytes.Equal(got, sum) {
+		return errcat.User.Newf("checksum mismatch for %s: expected %x, got %x", vs, sum, got)
+	}
+	mode := os.FileMode(0o755)
+	if fi, err := os.Stat(exe); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if err = os.Chmod(tmpName, mode); err != nil {
+		return err
+	}
+	if err = replace(tmpName, exe); err != nil {
+		return errcat.User.Newf("unable to replace %s: %v", exe, err)
+	}
+	tmpName = ""
+	dlog.Debugf(ctx, "replaced %s with version %s", exe, vs)
+	return nil
+}
+
+// Executable returns the path of the running executable with symbolic links resolved.
+func Executable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(exe)
+}
@@ -0,0 +1,136 @@
+package selfupdate_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blang/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/selfupdate"
+)
+
+type mirror struct {
+	files map[string][]byte
+}
+
+func (m *mirror) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if data, ok := m.files[r.URL.Path]; ok {
+		_, _ = w.Write(data)
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func newMirror(t *testing.T, version string, binary []byte, key ed25519.PrivateKey) *httptest.Server {
+	sum := []byte(fmt.Sprintf("%x  telepresence\n", sha256.Sum256(binary)))
+	m := &mirror{files: map[string][]byte{
+		"/tel2/linux/amd64/stable.txt":                          []byte(version + "\n"),
+		"/tel2/linux/amd64/" + version + "/telepresence":        binary,
+		"/tel2/linux/amd64/" + version + "/telepresence.sha256": sum,
+	}}
+	if key != nil {
+		m.files["/tel2/linux/amd64/"+version+"/telepresence.sha256.sig"] = []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, sum)))
+	}
+	srv := httptest.NewTLSServer(m)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func testUpdater(t *testing.T, srv *httptest.Server, publicKey string) *selfupdate.Updater {
+	cfg := client.GetDefaultConfig()
+	cfg.Upgrade().PublicKey = publicKey
+	ctx := client.WithConfig(dlog.NewTestContext(t, false), cfg)
+	u, err := selfupdate.NewUpdater(ctx, srv.URL+"/tel2/")
+	require.NoError(t, err)
+	u.GOOS = "linux"
+	u.GOARCH = "amd64"
+	u.Client = srv.Client()
+	return u
+}
+
+func TestUpdater_Install(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	binary := []byte("#!/bin/sh\necho new\n")
+	srv := newMirror(t, "v2.99.0", binary, key)
+
+	t.Run("checksum", func(t *testing.T) {
+		ctx := dlog.NewTestContext(t, false)
+		u := testUpdater(t, srv, "")
+		v, err := u.Latest(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, semver.MustParse("2.99.0"), v)
+
+		exe := filepath.Join(t.TempDir(), "telepresence")
+		require.NoError(t, os.WriteFile(exe, []byte("old"), 0o755))
+		require.NoError(t, u.Install(ctx, v, exe))
+		data, err := os.ReadFile(exe)
+		require.NoError(t, err)
+		assert.Equal(t, binary, data)
+	})
+
+	t.Run("signature", func(t *testing.T) {
+		ctx := dlog.NewTestContext(t, false)
+		u := testUpdater(t, srv, base64.StdEncoding.EncodeToString(pub))
+		exe := filepath.Join(t.TempDir(), "telepresence")
+		require.NoError(t, u.Install(ctx, semver.MustParse("2.99.0"), exe))
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		ctx := dlog.NewTestContext(t, false)
+		otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		u := testUpdater(t, srv, base64.StdEncoding.EncodeToString(otherPub))
+		exe := filepath.Join(t.TempDir(), "telepresence")
+		require.NoError(t, os.WriteFile(exe, []byte("old"), 0o755))
+		assert.ErrorContains(t, u.Install(ctx, semver.MustParse("2.99.0"), exe), "signature verification")
+		data, err := os.ReadFile(exe)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("old"), data)
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		ctx := dlog.NewTestContext(t, false)
+		bad := newMirror(t, "v2.99.0", binary, nil)
+		bad.Config.Handler.(*mirror).files["/tel2/linux/amd64/v2.99.0/telepresence"] = []byte("tampered")
+		u := testUpdater(t, bad, "")
+		exe := filepath.Join(t.TempDir(), "telepresence")
+		require.NoError(t, os.WriteFile(exe, []byte("old"), 0o755))
+		assert.ErrorContains(t, u.Install(ctx, semver.MustParse("2.99.0"), exe), "checksum mismatch")
+		entries, err := os.ReadDir(filepath.Dir(exe))
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "temporary file was not removed")
+	})
+}
+
+func TestNewUpdater_InvalidChannel(t *testing.T) {
+	ctx := client.WithConfig(dlog.NewTestContext(t, false), client.GetDefaultConfig())
+	_, err := selfupdate.NewUpdater(ctx, "nightly")
+	assert.Error(t, err)
+}
+
+func TestNewUpdater_HTTPMirror(t *testing.T) {
+	cfg := client.GetDefaultConfig()
+	ctx := client.WithConfig(dlog.NewTestContext(t, false), cfg)
+	_, err := selfupdate.NewUpdater(ctx, "http://mirror.example.com/tel2")
+	assert.ErrorContains(t, err, "upgrade.publicKey")
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	cfg.Upgrade().PublicKey = base64.StdEncoding.EncodeToString(pub)
+	u, err := selfupdate.NewUpdater(ctx, "http://mirror.example.com/tel2")
+	require.NoError(t, err)
+	assert.Equal(t, "http://mirror.example.com/tel2", u.BaseURL)
+}
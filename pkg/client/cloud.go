@@ -0,0 +1,79 @@
+package client
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+// CloudProvider identifies the cloud that hosts a cluster.
+type CloudProvider string
+
+const (
+	NoCloudProvider CloudProvider = ""
+	AWS             CloudProvider = "aws"
+	GCP             CloudProvider = "gcp"
+	Azure           CloudProvider = "azure"
+)
+
+// DetectCloudProvider returns the cloud provider of the given cluster, based on the host of its server, the
+// name that the kubeconfig gives it, and the credential plugin that is used to authenticate with it.
+func DetectCloudProvider(clusterName string, cluster *api.Cluster, authInfo *api.AuthInfo) CloudProvider {
+	var host string
+	if u, err := url.Parse(cluster.Server); err == nil {
+		host = strings.ToLower(u.Hostname())
+	}
+	var plugin string
+	if authInfo != nil {
+		if authInfo.Exec != nil {
+			// The kubeconfig may come from another OS, e.g. when the daemon runs in a container.
+			plugin = authInfo.Exec.Command
+			if i := strings.LastIndexAny(plugin, `/\`); i >= 0 {
+				plugin = plugin[i+1:]
+			}
+			plugin = strings.TrimSuffix(plugin, ".exe")
+		} else if authInfo.AuthProvider != nil {
+			plugin = authInfo.AuthProvider.Name
+		}
+	}
+	switch {
+	case strings.HasSuffix(host, ".eks.amazonaws.com") || strings.HasSuffix(host, ".eks.amazonaws.com.cn") ||
+		strings.HasPrefix(clusterName, "arn:aws:eks:") || strings.HasPrefix(clusterName, "arn:aws-cn:eks:") ||
+		plugin == "aws" || plugin == "aws-iam-authenticator":
+		return AWS
+	case strings.HasPrefix(clusterName, "gke_") || plugin == "gke-gcloud-auth-plugin" || plugin == "gcp":
+		return GCP
+	case strings.HasSuffix(host, ".azmk8s.io") || plugin == "kubelogin" || plugin == "azure":
+		return Azure
+	default:
+		return NoCloudProvider
+	}
+}
+
+// MetadataSubnets returns the subnets of the instance metadata and credential endpoints that the cloud SDKs
+// on a developer's machine may use. Routing them to the cluster breaks the SDK's credential chains, so they
+// are added to the never-proxy subnets.
+func (cp CloudProvider) MetadataSubnets() []*net.IPNet {
+	var cidrs []string
+	switch cp {
+	case AWS:
+		// The EC2 instance metadata service, the ECS container credentials endpoint, and the
+		// EKS Pod Identity agent.
+		cidrs = []string{"169.254.169.254/32", "169.254.170.2/32", "169.254.170.23/32", "fd00:ec2::254/128", "fd00:ec2::23/128"}
+	case GCP:
+		// The Compute Engine metadata server, and the GKE metadata server used by Workload Identity.
+		cidrs = []string{"169.254.169.254/32", "169.254.169.252/32"}
+	case Azure:
+		// The Azure Instance Metadata Service, and the WireServer.
+		cidrs = []string{"169.254.169.254/32", "168.63.129.16/32"}
+	default:
+		return nil
+	}
+	subnets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, subnets[i], _ = net.ParseCIDR(cidr)
+	}
+	return subnets
+}
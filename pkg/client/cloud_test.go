@@ -0,0 +1,89 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestDetectCloudProvider(t *testing.T) {
+	tests := []struct {
+		name        string
+		clusterName string
+		server      string
+		authInfo    *api.AuthInfo
+		want        CloudProvider
+	}{
+		{
+			"EKS server",
+			"dev",
+			"https://0123456789ABCDEF.gr7.us-west-2.eks.amazonaws.com",
+			nil,
+			AWS,
+		},
+		{
+			"EKS cluster ARN",
+			"arn:aws:eks:us-west-2:012345678901:cluster/dev",
+			"https://10.0.0.1",
+			nil,
+			AWS,
+		},
+		{
+			"aws-iam-authenticator",
+			"dev",
+			"https://10.0.0.1",
+			&api.AuthInfo{Exec: &api.ExecConfig{Command: "/usr/local/bin/aws-iam-authenticator"}},
+			AWS,
+		},
+		{
+			"GKE cluster name",
+			"gke_project_us-central1_dev",
+			"https://34.1.2.3",
+			nil,
+			GCP,
+		},
+		{
+			"GKE auth plugin",
+			"dev",
+			"https://34.1.2.3",
+			&api.AuthInfo{Exec: &api.ExecConfig{Command: `C:\gcloud\gke-gcloud-auth-plugin.exe`}},
+			GCP,
+		},
+		{
+			"AKS server",
+			"dev",
+			"https://dev-dns-01234567.hcp.westeurope.azmk8s.io:443",
+			nil,
+			Azure,
+		},
+		{
+			"kind",
+			"kind-dev",
+			"https://127.0.0.1:40000",
+			&api.AuthInfo{ClientCertificateData: []byte("cert")},
+			NoCloudProvider,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DetectCloudProvider(tt.clusterName, &api.Cluster{Server: tt.server}, tt.authInfo))
+		})
+	}
+}
+
+func TestCloudProvider_MetadataSubnets(t *testing.T) {
+	for _, cp := range []CloudProvider{AWS, GCP, Azure} {
+		subnets := cp.MetadataSubnets()
+		assert.NotEmpty(t, subnets, cp)
+		var hasIMDS bool
+		for _, sn := range subnets {
+			assert.NotNil(t, sn, cp)
+			if sn.String() == "169.254.169.254/32" {
+				hasIMDS = true
+			}
+		}
+		assert.True(t, hasIMDS, cp)
+	}
+	assert.Empty(t, NoCloudProvider.MetadataSubnets())
+}
@@ -9,6 +9,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -34,11 +35,21 @@ type Config interface {
 	Base() *BaseConfig
 	Timeouts() *Timeouts
 	LogLevels() *LogLevels
+	LogFormat() *LogFormat
+	LogFiles() *LogFiles
 	Images() *Images
 	Grpc() *Grpc
 	TelepresenceAPI() *TelepresenceAPI
 	Intercept() *Intercept
 	Cluster() *Cluster
+	Upgrade() *Upgrade
+	CLI() *CLI
+	Telemetry() *Telemetry
+	VIF() *VIF
+	Docker() *Docker
+	UserDaemon() *UserDaemon
+	Paths() *Paths
+	Retries() *Retries
 	Merge(Config)
 }
 
@@ -47,11 +58,21 @@ type BaseConfig struct {
 	OSSpecificConfig `yaml:",inline"`
 	TimeoutsV        Timeouts        `json:"timeouts,omitempty" yaml:"timeouts,omitempty"`
 	LogLevelsV       LogLevels       `json:"logLevels,omitempty" yaml:"logLevels,omitempty"`
+	LogFormatV       LogFormat       `json:"logFormat,omitempty" yaml:"logFormat,omitempty"`
+	LogFilesV        LogFiles        `json:"logFiles,omitempty" yaml:"logFiles,omitempty"`
 	ImagesV          Images          `json:"images,omitempty" yaml:"images,omitempty"`
 	GrpcV            Grpc            `json:"grpc,omitempty" yaml:"grpc,omitempty"`
 	TelepresenceAPIV TelepresenceAPI `json:"telepresenceAPI,omitempty" yaml:"telepresenceAPI,omitempty"`
 	InterceptV       Intercept       `json:"intercept,omitempty" yaml:"intercept,omitempty"`
 	ClusterV         Cluster         `json:"cluster,omitempty" yaml:"cluster,omitempty"`
+	UpgradeV         Upgrade         `json:"upgrade,omitempty" yaml:"upgrade,omitempty"`
+	CLIV             CLI             `json:"cli,omitempty" yaml:"cli,omitempty"`
+	TelemetryV       Telemetry       `json:"telemetry,omitempty" yaml:"telemetry,omitempty"`
+	VIFV             VIF             `json:"vif,omitempty" yaml:"vif,omitempty"`
+	DockerV          Docker          `json:"docker,omitempty" yaml:"docker,omitempty"`
+	UserDaemonV      UserDaemon      `json:"userDaemon,omitempty" yaml:"userDaemon,omitempty"`
+	PathsV           Paths           `json:"paths,omitempty" yaml:"paths,omitempty"`
+	RetriesV         Retries         `json:"retries,omitempty" yaml:"retries,omitempty"`
 }
 
 func (c *BaseConfig) OSSpecific() *OSSpecificConfig {
@@ -70,6 +91,14 @@ func (c *BaseConfig) LogLevels() *LogLevels {
 	return &c.LogLevelsV
 }
 
+func (c *BaseConfig) LogFormat() *LogFormat {
+	return &c.LogFormatV
+}
+
+func (c *BaseConfig) LogFiles() *LogFiles {
+	return &c.LogFilesV
+}
+
 func (c *BaseConfig) Images() *Images {
 	return &c.ImagesV
 }
@@ -90,6 +119,38 @@ func (c *BaseConfig) Cluster() *Cluster {
 	return &c.ClusterV
 }
 
+func (c *BaseConfig) Upgrade() *Upgrade {
+	return &c.UpgradeV
+}
+
+func (c *BaseConfig) CLI() *CLI {
+	return &c.CLIV
+}
+
+func (c *BaseConfig) Telemetry() *Telemetry {
+	return &c.TelemetryV
+}
+
+func (c *BaseConfig) VIF() *VIF {
+	return &c.VIFV
+}
+
+func (c *BaseConfig) Docker() *Docker {
+	return &c.DockerV
+}
+
+func (c *BaseConfig) UserDaemon() *UserDaemon {
+	return &c.UserDaemonV
+}
+
+func (c *BaseConfig) Paths() *Paths {
+	return &c.PathsV
+}
+
+func (c *BaseConfig) Retries() *Retries {
+	return &c.RetriesV
+}
+
 func ParseConfigYAML(data []byte) (Config, error) {
 	cfg := GetDefaultConfig()
 	if err := yaml.Unmarshal(data, cfg); err != nil {
@@ -103,11 +164,21 @@ func (c *BaseConfig) Merge(lc Config) {
 	c.OSSpecificConfig.Merge(lc.OSSpecific())
 	c.TimeoutsV.merge(lc.Timeouts())
 	c.LogLevelsV.merge(lc.LogLevels())
+	c.LogFormatV.merge(lc.LogFormat())
+	c.LogFilesV.merge(lc.LogFiles())
 	c.ImagesV.merge(lc.Images())
 	c.GrpcV.merge(lc.Grpc())
 	c.TelepresenceAPIV.merge(lc.TelepresenceAPI())
 	c.InterceptV.merge(lc.Intercept())
 	c.ClusterV.merge(lc.Cluster())
+	c.UpgradeV.merge(lc.Upgrade())
+	c.CLIV.merge(lc.CLI())
+	c.TelemetryV.merge(lc.Telemetry())
+	c.VIFV.merge(lc.VIF())
+	c.DockerV.merge(lc.Docker())
+	c.UserDaemonV.merge(lc.UserDaemon())
+	c.PathsV.merge(lc.Paths())
+	c.RetriesV.merge(lc.Retries())
 }
 
 func (c *BaseConfig) String() string {
@@ -365,35 +436,8 @@ func (t *Timeouts) UnmarshalYAML(node *yaml.Node) (err error) {
 		if err != nil {
 			return err
 		}
-		var dp *time.Duration
-		switch kv {
-		case "agentInstall":
-			dp = &t.PrivateAgentInstall
-		case "apply":
-			dp = &t.PrivateApply
-		case "clusterConnect":
-			dp = &t.PrivateClusterConnect
-		case "connectivityCheck":
-			dp = &t.PrivateConnectivityCheck
-		case "endpointDial":
-			dp = &t.PrivateEndpointDial
-		case "helm":
-			dp = &t.PrivateHelm
-		case "intercept":
-			dp = &t.PrivateIntercept
-		case "proxyDial":
-			dp = &t.PrivateProxyDial
-		case "roundtripLatency":
-			dp = &t.PrivateRoundtripLatency
-		case "trafficManagerAPI":
-			dp = &t.PrivateTrafficManagerAPI
-		case "trafficManagerConnect":
-			dp = &t.PrivateTrafficManagerConnect
-		case "ftpReadWrite":
-			dp = &t.PrivateFtpReadWrite
-		case "ftpShutdown":
-			dp = &t.PrivateFtpShutdown
-		default:
+		dp := t.durationPtr(kv)
+		if dp == nil {
 			logrus.Warn(WithLoc(fmt.Sprintf("unknown key %q", kv), ms[i]))
 			continue
 		}
@@ -417,6 +461,84 @@ func (t *Timeouts) UnmarshalYAML(node *yaml.Node) (err error) {
 	return nil
 }
 
+// timeoutNames are the names of the timeouts in the config file, in TimeoutID order.
+var timeoutNames = [...]string{ //nolint:gochecknoglobals // constant names
+	"agentInstall",
+	"apply",
+	"clusterConnect",
+	"connectivityCheck",
+	"endpointDial",
+	"helm",
+	"intercept",
+	"proxyDial",
+	"roundtripLatency",
+	"trafficManagerAPI",
+	"trafficManagerConnect",
+	"ftpReadWrite",
+	"ftpShutdown",
+}
+
+// durationPtr returns a pointer to the timeout with the given config file name, or nil if no such timeout exists.
+func (t *Timeouts) durationPtr(name string) *time.Duration {
+	switch name {
+	case "agentInstall":
+		return &t.PrivateAgentInstall
+	case "apply":
+		return &t.PrivateApply
+	case "clusterConnect":
+		return &t.PrivateClusterConnect
+	case "connectivityCheck":
+		return &t.PrivateConnectivityCheck
+	case "endpointDial":
+		return &t.PrivateEndpointDial
+	case "helm":
+		return &t.PrivateHelm
+	case "intercept":
+		return &t.PrivateIntercept
+	case "proxyDial":
+		return &t.PrivateProxyDial
+	case "roundtripLatency":
+		return &t.PrivateRoundtripLatency
+	case "trafficManagerAPI":
+		return &t.PrivateTrafficManagerAPI
+	case "trafficManagerConnect":
+		return &t.PrivateTrafficManagerConnect
+	case "ftpReadWrite":
+		return &t.PrivateFtpReadWrite
+	case "ftpShutdown":
+		return &t.PrivateFtpShutdown
+	default:
+		return nil
+	}
+}
+
+// Override sets the timeouts named by the keys of the given map to the durations of its values, e.g.
+// "trafficManagerConnect" to "2m". It's used when timeouts are overridden for a single connection.
+func (t *Timeouts) Override(overrides map[string]string) error {
+	for name, v := range overrides {
+		dp := t.durationPtr(name)
+		if dp == nil {
+			return fmt.Errorf("unknown timeout %q, valid names are %s", name, strings.Join(timeoutNames[:], ", "))
+		}
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("%q is not a valid duration for timeout %s", v, name)
+		}
+		*dp = d
+	}
+	return nil
+}
+
+// Active returns the value of every timeout, keyed by its config file name. In contrast to the
+// marshalled form of Timeouts, the default values are included.
+func (t *Timeouts) Active() map[string]string {
+	am := make(map[string]string, len(timeoutNames))
+	for _, name := range timeoutNames {
+		am[name] = t.durationPtr(name).String()
+	}
+	return am
+}
+
 const (
 	defaultTimeoutsAgentInstall          = 120 * time.Second
 	defaultTimeoutsApply                 = 1 * time.Minute
@@ -601,10 +723,144 @@ func (ll *LogLevels) merge(o *LogLevels) {
 	}
 }
 
+// LogFormat is the format of the log files written by the CLI and the daemons. The empty LogFormat
+// means that no format has been configured, and is treated as LogFormatText.
+type LogFormat string
+
+const (
+	// LogFormatText is the default format, where each entry is a line of text.
+	LogFormatText = LogFormat("text")
+
+	// LogFormatJSON is a format where each entry is a JSON object on a line of its own.
+	LogFormatJSON = LogFormat("json")
+)
+
+// UnmarshalYAML parses and validates the log format.
+func (lf *LogFormat) UnmarshalYAML(node *yaml.Node) error {
+	var s string
+	if err := node.Decode(&s); err != nil {
+		return errors.New(WithLoc("logFormat must be a string", node))
+	}
+	switch f := LogFormat(strings.ToLower(s)); f {
+	case LogFormatText, LogFormatJSON:
+		*lf = f
+	default:
+		return errors.New(WithLoc(fmt.Sprintf("invalid logFormat %q, must be %q or %q", s, LogFormatText, LogFormatJSON), node))
+	}
+	return nil
+}
+
+// IsZero controls whether this element will be included in marshalled output.
+func (lf LogFormat) IsZero() bool {
+	return lf == ""
+}
+
+// merge replaces this format with the given format when it has been set, so that an explicit
+// "text" overrides a "json" from a config that was merged earlier.
+func (lf *LogFormat) merge(o *LogFormat) {
+	if *o != "" {
+		*lf = *o
+	}
+}
+
+const defaultLogFilesMaxBackups = 4
+
+// LogFiles controls the rotation of the log files written by the CLI and the daemons. The fields
+// are pointers so that a value that is explicitly set to its default overrides a value from a
+// config that was merged earlier.
+type LogFiles struct {
+	// MaxSize is the size that a log file may reach before it's rotated. A log file is always
+	// rotated daily. Nil or zero means no size limit.
+	MaxSize *resource.Quantity `json:"maxSize,omitempty" yaml:"maxSize,omitempty"`
+
+	// MaxBackups is the maximum number of rotated log files to keep for each log. Nil means
+	// the default, which is 4.
+	MaxBackups *int `json:"maxBackups,omitempty" yaml:"maxBackups,omitempty"`
+}
+
+// MaxSizeBytes returns the MaxSize in bytes, or zero when there's no size limit.
+func (lf *LogFiles) MaxSizeBytes() int64 {
+	if lf.MaxSize != nil && !lf.MaxSize.IsZero() {
+		if ms, ok := lf.MaxSize.AsInt64(); ok {
+			return ms
+		}
+	}
+	return 0
+}
+
+// GetMaxBackups returns the MaxBackups, or the default when it hasn't been set.
+func (lf *LogFiles) GetMaxBackups() int {
+	if lf.MaxBackups != nil {
+		return *lf.MaxBackups
+	}
+	return defaultLogFilesMaxBackups
+}
+
+// UnmarshalYAML parses the logFiles YAML.
+func (lf *LogFiles) UnmarshalYAML(node *yaml.Node) (err error) {
+	if node.Kind != yaml.MappingNode {
+		return errors.New(WithLoc("logFiles must be an object", node))
+	}
+
+	ms := node.Content
+	top := len(ms)
+	for i := 0; i < top; i += 2 {
+		kv, err := StringKey(ms[i])
+		if err != nil {
+			return err
+		}
+		v := ms[i+1]
+		switch kv {
+		case "maxSize":
+			val, err := resource.ParseQuantity(v.Value)
+			if err != nil {
+				return errors.New(WithLoc(fmt.Sprintf("unable to parse quantity %q", v.Value), v))
+			}
+			lf.MaxSize = &val
+		case "maxBackups":
+			var mb int
+			if err := v.Decode(&mb); err != nil || mb < 0 {
+				return errors.New(WithLoc("maxBackups must be a positive integer", v))
+			}
+			lf.MaxBackups = &mb
+		default:
+			logrus.Warn(WithLoc(fmt.Sprintf("unknown key %q", kv), ms[i]))
+		}
+	}
+	return nil
+}
+
+func (lf *LogFiles) merge(o *LogFiles) {
+	if o.MaxSize != nil {
+		lf.MaxSize = o.MaxSize
+	}
+	if o.MaxBackups != nil {
+		lf.MaxBackups = o.MaxBackups
+	}
+}
+
+// IsZero controls whether this element will be included in marshalled output.
+func (lf LogFiles) IsZero() bool {
+	return lf.MaxSize == nil && lf.MaxBackups == nil
+}
+
+// MarshalYAML is not using pointer receiver here, because LogFiles is not pointer in the Config struct.
+func (lf LogFiles) MarshalYAML() (any, error) {
+	lm := make(map[string]any)
+	if lf.MaxSize != nil {
+		lm["maxSize"] = lf.MaxSize.String()
+	}
+	if lf.MaxBackups != nil {
+		lm["maxBackups"] = *lf.MaxBackups
+	}
+	return lm, nil
+}
+
 type Images struct {
 	PrivateRegistry        string `json:"registry,omitempty" yaml:"registry,omitempty"`
 	PrivateAgentImage      string `json:"agentImage,omitempty" yaml:"agentImage,omitempty"`
 	PrivateWebhookRegistry string `json:"webhookRegistry,omitempty" yaml:"webhookRegistry,omitempty"`
+	PrivateVerifyKey       string `json:"verifyKey,omitempty" yaml:"verifyKey,omitempty"`
 }
 
 const (
@@ -636,6 +892,8 @@ func (img *Images) UnmarshalYAML(node *yaml.Node) (err error) {
 			img.PrivateAgentImage = v.Value
 		case "webhookRegistry":
 			img.PrivateWebhookRegistry = v.Value
+		case "verifyKey":
+			img.PrivateVerifyKey = v.Value
 		case "webhookAgentImage":
 			logrus.Warn(WithLoc(fmt.Sprintf(`deprecated key %q, please use "agentImage" instead`, kv), ms[i]))
 			img.PrivateAgentImage = v.Value
@@ -656,6 +914,9 @@ func (img *Images) merge(o *Images) {
 	if o.PrivateWebhookRegistry != "" {
 		img.PrivateWebhookRegistry = o.PrivateWebhookRegistry
 	}
+	if o.PrivateVerifyKey != "" {
+		img.PrivateVerifyKey = o.PrivateVerifyKey
+	}
 }
 
 func (img *Images) Registry(c context.Context) string {
@@ -682,6 +943,12 @@ func (img *Images) AgentImage(c context.Context) string {
 	return GetEnv(c).AgentImage
 }
 
+// VerifyKey returns the path of a file with PEM encoded public keys that the images of the traffic-manager
+// must be signed with, or an empty string if the images aren't verified.
+func (img *Images) VerifyKey() string {
+	return img.PrivateVerifyKey
+}
+
 // IsZero controls whether this element will be included in marshalled output.
 func (img Images) IsZero() bool {
 	return img == defaultImages
@@ -699,6 +966,9 @@ func (img Images) MarshalYAML() (any, error) {
 	if img.PrivateWebhookRegistry != "" {
 		m["webhookRegistry"] = img.PrivateWebhookRegistry
 	}
+	if img.PrivateVerifyKey != "" {
+		m["verifyKey"] = img.PrivateVerifyKey
+	}
 	return m, nil
 }
 
@@ -706,21 +976,148 @@ type Grpc struct {
 	// MaxReceiveSize is the maximum message size in bytes the client can receive in a gRPC call or stream message.
 	// Overrides the gRPC default of 4MB.
 	MaxReceiveSizeV resource.Quantity `json:"maxReceiveSize,omitempty" yaml:"maxReceiveSize,omitempty"`
+
+	// MaxSendSize is the maximum message size in bytes the client can send in a gRPC call or stream message.
+	// Overrides the gRPC default, which is unlimited.
+	MaxSendSizeV resource.Quantity `json:"maxSendSize,omitempty" yaml:"maxSendSize,omitempty"`
+
+	// KeepAliveInterval is the time of inactivity after which the connection to the traffic-manager is
+	// pinged. Zero means that no pings are sent. Intervals shorter than 10 seconds are raised to 10 seconds.
+	KeepAliveInterval time.Duration `json:"keepAliveInterval,omitempty" yaml:"keepAliveInterval,omitempty"`
+
+	// KeepAliveTimeout is the time to wait for a ping acknowledgement before the connection to the
+	// traffic-manager is considered broken. Zero means the gRPC default of 20 seconds.
+	KeepAliveTimeout time.Duration `json:"keepAliveTimeout,omitempty" yaml:"keepAliveTimeout,omitempty"`
+
+	// ConnectorKeepAliveInterval is like KeepAliveInterval, but for the connection from the CLI to the user daemon.
+	ConnectorKeepAliveInterval time.Duration `json:"connectorKeepAliveInterval,omitempty" yaml:"connectorKeepAliveInterval,omitempty"`
+
+	// ConnectorKeepAliveTimeout is like KeepAliveTimeout, but for the connection from the CLI to the user daemon.
+	ConnectorKeepAliveTimeout time.Duration `json:"connectorKeepAliveTimeout,omitempty" yaml:"connectorKeepAliveTimeout,omitempty"`
+
+	// TunnelKeepAliveInterval is like KeepAliveInterval, but for the connection from the root daemon to the user
+	// daemon, which carries the tunnel streams to the traffic-manager.
+	TunnelKeepAliveInterval time.Duration `json:"tunnelKeepAliveInterval,omitempty" yaml:"tunnelKeepAliveInterval,omitempty"`
+
+	// TunnelKeepAliveTimeout is like KeepAliveTimeout, but for the connection from the root daemon to the user
+	// daemon, which carries the tunnel streams to the traffic-manager.
+	TunnelKeepAliveTimeout time.Duration `json:"tunnelKeepAliveTimeout,omitempty" yaml:"tunnelKeepAliveTimeout,omitempty"`
+
+	// HeartbeatInterval is the longest time that a tunnel to the traffic-manager can be idle before a heartbeat is
+	// sent on it. The interval is shortened automatically when idle tunnels are dropped by a NAT or a proxy. Zero
+	// means the default of 30 seconds.
+	HeartbeatInterval time.Duration `json:"heartbeatInterval,omitempty" yaml:"heartbeatInterval,omitempty"`
+
+	// InitialWindowSize is the flow control window size of each stream. Zero means the gRPC default, which
+	// is a window that grows dynamically. Values less than 64Ki are ignored.
+	InitialWindowSizeV resource.Quantity `json:"initialWindowSize,omitempty" yaml:"initialWindowSize,omitempty"`
+
+	// InitialConnWindowSize is the flow control window size of each connection. Zero means the gRPC default,
+	// which is a window that grows dynamically. Values less than 64Ki are ignored.
+	InitialConnWindowSizeV resource.Quantity `json:"initialConnWindowSize,omitempty" yaml:"initialConnWindowSize,omitempty"`
+
+	// MaxRequestRate is the number of requests per second that each client of the user daemon can make before
+	// its requests are rejected. Zero means the default of 20 requests per second, and a negative value means
+	// that the requests are not limited.
+	MaxRequestRate int `json:"maxRequestRate,omitempty" yaml:"maxRequestRate,omitempty"`
 }
 
-func (g *Grpc) MaxReceiveSize() int64 {
-	if !g.MaxReceiveSizeV.IsZero() {
-		if mz, ok := g.MaxReceiveSizeV.AsInt64(); ok {
-			return mz
+func quantityAsInt64(q *resource.Quantity) int64 {
+	if !q.IsZero() {
+		if v, ok := q.AsInt64(); ok {
+			return v
 		}
 	}
 	return 0
 }
 
+func (g *Grpc) MaxReceiveSize() int64 {
+	return quantityAsInt64(&g.MaxReceiveSizeV)
+}
+
+func (g *Grpc) MaxSendSize() int64 {
+	return quantityAsInt64(&g.MaxSendSizeV)
+}
+
+func (g *Grpc) InitialWindowSize() int64 {
+	return quantityAsInt64(&g.InitialWindowSizeV)
+}
+
+func (g *Grpc) InitialConnWindowSize() int64 {
+	return quantityAsInt64(&g.InitialConnWindowSizeV)
+}
+
+func (g *Grpc) quantityPtr(key string) *resource.Quantity {
+	switch key {
+	case "maxReceiveSize":
+		return &g.MaxReceiveSizeV
+	case "maxSendSize":
+		return &g.MaxSendSizeV
+	case "initialWindowSize":
+		return &g.InitialWindowSizeV
+	case "initialConnWindowSize":
+		return &g.InitialConnWindowSizeV
+	}
+	return nil
+}
+
+func (g *Grpc) durationPtr(key string) *time.Duration {
+	switch key {
+	case "keepAliveInterval":
+		return &g.KeepAliveInterval
+	case "keepAliveTimeout":
+		return &g.KeepAliveTimeout
+	case "connectorKeepAliveInterval":
+		return &g.ConnectorKeepAliveInterval
+	case "connectorKeepAliveTimeout":
+		return &g.ConnectorKeepAliveTimeout
+	case "tunnelKeepAliveInterval":
+		return &g.TunnelKeepAliveInterval
+	case "tunnelKeepAliveTimeout":
+		return &g.TunnelKeepAliveTimeout
+	case "heartbeatInterval":
+		return &g.HeartbeatInterval
+	}
+	return nil
+}
+
 func (g *Grpc) merge(o *Grpc) {
 	if !o.MaxReceiveSizeV.IsZero() {
 		g.MaxReceiveSizeV = o.MaxReceiveSizeV
 	}
+	if !o.MaxSendSizeV.IsZero() {
+		g.MaxSendSizeV = o.MaxSendSizeV
+	}
+	if o.KeepAliveInterval != 0 {
+		g.KeepAliveInterval = o.KeepAliveInterval
+	}
+	if o.KeepAliveTimeout != 0 {
+		g.KeepAliveTimeout = o.KeepAliveTimeout
+	}
+	if o.ConnectorKeepAliveInterval != 0 {
+		g.ConnectorKeepAliveInterval = o.ConnectorKeepAliveInterval
+	}
+	if o.ConnectorKeepAliveTimeout != 0 {
+		g.ConnectorKeepAliveTimeout = o.ConnectorKeepAliveTimeout
+	}
+	if o.TunnelKeepAliveInterval != 0 {
+		g.TunnelKeepAliveInterval = o.TunnelKeepAliveInterval
+	}
+	if o.TunnelKeepAliveTimeout != 0 {
+		g.TunnelKeepAliveTimeout = o.TunnelKeepAliveTimeout
+	}
+	if o.HeartbeatInterval != 0 {
+		g.HeartbeatInterval = o.HeartbeatInterval
+	}
+	if !o.InitialWindowSizeV.IsZero() {
+		g.InitialWindowSizeV = o.InitialWindowSizeV
+	}
+	if !o.InitialConnWindowSizeV.IsZero() {
+		g.InitialConnWindowSizeV = o.InitialConnWindowSizeV
+	}
+	if o.MaxRequestRate != 0 {
+		g.MaxRequestRate = o.MaxRequestRate
+	}
 }
 
 // UnmarshalYAML parses the images YAML.
@@ -737,13 +1134,28 @@ func (g *Grpc) UnmarshalYAML(node *yaml.Node) (err error) {
 			return err
 		}
 		v := ms[i+1]
-		switch kv {
-		case "maxReceiveSize":
+		if qp := g.quantityPtr(kv); qp != nil {
 			val, err := resource.ParseQuantity(v.Value)
 			if err != nil {
 				logrus.Warnf("unable to parse quantity %q: %v", v.Value, WithLoc(err.Error(), ms[i]))
 			} else {
-				g.MaxReceiveSizeV = val
+				*qp = val
+			}
+			continue
+		}
+		if dp := g.durationPtr(kv); dp != nil {
+			val, err := time.ParseDuration(v.Value)
+			if err != nil || val < 0 {
+				logrus.Warn(WithLoc(fmt.Sprintf("unable to parse %s %q", kv, v.Value), ms[i]))
+			} else {
+				*dp = val
+			}
+			continue
+		}
+		switch kv {
+		case "maxRequestRate":
+			if err := v.Decode(&g.MaxRequestRate); err != nil {
+				logrus.Warn(WithLoc(fmt.Sprintf("unable to parse maxRequestRate %q", v.Value), ms[i]))
 			}
 		default:
 			logrus.Warn(WithLoc(fmt.Sprintf("unknown key %q", kv), ms[i]))
@@ -754,17 +1166,34 @@ func (g *Grpc) UnmarshalYAML(node *yaml.Node) (err error) {
 
 // IsZero controls whether this element will be included in marshalled output.
 func (g Grpc) IsZero() bool {
-	return g.MaxReceiveSizeV.IsZero()
+	return g.MaxReceiveSizeV.IsZero() && g.MaxSendSizeV.IsZero() && g.KeepAliveInterval == 0 && g.KeepAliveTimeout == 0 &&
+		g.ConnectorKeepAliveInterval == 0 && g.ConnectorKeepAliveTimeout == 0 && g.TunnelKeepAliveInterval == 0 &&
+		g.TunnelKeepAliveTimeout == 0 && g.HeartbeatInterval == 0 && g.InitialWindowSizeV.IsZero() && g.InitialConnWindowSizeV.IsZero() && g.MaxRequestRate == 0
 }
 
 // MarshalYAML is not using pointer receiver here, because Cloud is not pointer in the Config struct.
 func (g Grpc) MarshalYAML() (any, error) {
-	if !g.MaxReceiveSizeV.IsZero() {
-		return map[string]any{
-			"maxReceiveSize": g.MaxReceiveSizeV.String(),
-		}, nil
+	if g.IsZero() {
+		return nil, nil
+	}
+	gm := make(map[string]any)
+	for _, k := range []string{"maxReceiveSize", "maxSendSize", "initialWindowSize", "initialConnWindowSize"} {
+		if qp := g.quantityPtr(k); !qp.IsZero() {
+			gm[k] = qp.String()
+		}
 	}
-	return nil, nil
+	for _, k := range []string{
+		"keepAliveInterval", "keepAliveTimeout", "connectorKeepAliveInterval", "connectorKeepAliveTimeout",
+		"tunnelKeepAliveInterval", "tunnelKeepAliveTimeout", "heartbeatInterval",
+	} {
+		if dp := g.durationPtr(k); *dp != 0 {
+			gm[k] = dp.String()
+		}
+	}
+	if g.MaxRequestRate != 0 {
+		gm["maxRequestRate"] = g.MaxRequestRate
+	}
+	return gm, nil
 }
 
 type TelepresenceAPI struct {
@@ -824,6 +1253,45 @@ func (ic Intercept) MarshalYAML() (any, error) {
 type Cluster struct {
 	DefaultManagerNamespace string   `json:"defaultManagerNamespace,omitempty" yaml:"defaultManagerNamespace,omitempty"`
 	MappedNamespaces        []string `json:"mappedNamespaces,omitempty" yaml:"mappedNamespaces,omitempty"`
+
+	// ManagerAddress is the host:port of a gRPC endpoint, such as a LoadBalancer or an Ingress, that routes
+	// to the traffic-manager. When set, the traffic-manager is dialed at this address instead of through a
+	// port-forward via the Kubernetes API server.
+	ManagerAddress string `json:"managerAddress,omitempty" yaml:"managerAddress,omitempty"`
+
+	// ManagerInsecure disables TLS for the connection to the ManagerAddress. It cannot be combined with a token.
+	ManagerInsecure bool `json:"managerInsecure,omitempty" yaml:"managerInsecure,omitempty"`
+
+	// ManagerCAFile is a file with PEM encoded certificates used to verify the ManagerAddress endpoint.
+	// The system's certificates are used when it is empty.
+	ManagerCAFile string `json:"managerCAFile,omitempty" yaml:"managerCAFile,omitempty"`
+
+	// ManagerTokenFile is a file containing a token that is sent as a bearer token to the ManagerAddress. A
+	// token stored with "telepresence auth token set" is sent when no file is given.
+	ManagerTokenFile string `json:"managerTokenFile,omitempty" yaml:"managerTokenFile,omitempty"`
+
+	// SSHProxy is an SSH server that the Kubernetes API server and the traffic-manager are dialed through,
+	// for clusters that are only reachable via a jump host.
+	SSHProxy SSHProxy `json:"sshProxy,omitempty" yaml:"sshProxy,omitempty"`
+}
+
+// SSHProxy configures an SSH server ("jump host" or "bastion") that all connections to the cluster are
+// tunneled through. The client authenticates using the keys of a running ssh-agent and, when given,
+// the private key in the KeyFile.
+type SSHProxy struct {
+	// Address is the host or host:port of the SSH server. The port defaults to 22.
+	Address string `json:"address,omitempty" yaml:"address,omitempty"`
+
+	// User is the name of the user on the SSH server. Defaults to the name of the current user.
+	User string `json:"user,omitempty" yaml:"user,omitempty"`
+
+	// KeyFile is a file containing an unencrypted private key. Keys protected by a passphrase
+	// must be added to an ssh-agent instead.
+	KeyFile string `json:"keyFile,omitempty" yaml:"keyFile,omitempty"`
+
+	// KnownHostsFile is the file used to verify the host key of the SSH server. Defaults
+	// to ~/.ssh/known_hosts.
+	KnownHostsFile string `json:"knownHostsFile,omitempty" yaml:"knownHostsFile,omitempty"`
 }
 
 // This is used by a different config -- the k8s_config, which needs to be able to tell if it's overridden at a cluster or environment variable level.
@@ -841,11 +1309,20 @@ func (cc *Cluster) merge(o *Cluster) {
 	if len(o.MappedNamespaces) > 0 {
 		cc.MappedNamespaces = o.MappedNamespaces
 	}
+	if o.ManagerAddress != "" {
+		cc.ManagerAddress = o.ManagerAddress
+		cc.ManagerInsecure = o.ManagerInsecure
+		cc.ManagerCAFile = o.ManagerCAFile
+		cc.ManagerTokenFile = o.ManagerTokenFile
+	}
+	if o.SSHProxy.Address != "" {
+		cc.SSHProxy = o.SSHProxy
+	}
 }
 
 // IsZero controls whether this element will be included in marshalled output.
 func (cc Cluster) IsZero() bool {
-	return cc.DefaultManagerNamespace == defaultDefaultManagerNamespace && len(cc.MappedNamespaces) == 0
+	return cc.DefaultManagerNamespace == defaultDefaultManagerNamespace && len(cc.MappedNamespaces) == 0 && cc.ManagerAddress == "" && cc.SSHProxy.Address == ""
 }
 
 // MarshalYAML is not using pointer receiver here, because Cluster is not pointer in the Config struct.
@@ -857,9 +1334,557 @@ func (cc Cluster) MarshalYAML() (any, error) {
 	if len(cc.MappedNamespaces) > 0 {
 		cm["mappedNamespaces"] = cc.MappedNamespaces
 	}
+	if cc.ManagerAddress != "" {
+		cm["managerAddress"] = cc.ManagerAddress
+		if cc.ManagerInsecure {
+			cm["managerInsecure"] = true
+		}
+		if cc.ManagerCAFile != "" {
+			cm["managerCAFile"] = cc.ManagerCAFile
+		}
+		if cc.ManagerTokenFile != "" {
+			cm["managerTokenFile"] = cc.ManagerTokenFile
+		}
+	}
+	if cc.SSHProxy.Address != "" {
+		cm["sshProxy"] = cc.SSHProxy
+	}
 	return cm, nil
 }
 
+const (
+	// UpgradeChannelStable is the channel that contains the latest stable release.
+	UpgradeChannelStable = "stable"
+
+	// UpgradeChannelLatest is the channel that contains the latest release, including pre-releases.
+	UpgradeChannelLatest = "latest"
+)
+
+var defaultUpgrade = Upgrade{ //nolint:gochecknoglobals // constant
+	Channel: UpgradeChannelStable,
+}
+
+// Upgrade controls how "telepresence upgrade self" finds and verifies new releases.
+type Upgrade struct {
+	// Channel is either "stable", "latest", or the URL of a mirror that uses the same layout as the
+	// official download site. A mirror is expected to provide a stable.txt that names the version
+	// to install, which makes it possible to pin the version that an organization uses. A mirror
+	// that doesn't use https requires a PublicKey.
+	Channel string `json:"channel,omitempty" yaml:"channel,omitempty"`
+
+	// PublicKey is a base64 encoded ed25519 public key. When set, the checksum file of a release
+	// must be accompanied by a signature made with the corresponding private key.
+	PublicKey string `json:"publicKey,omitempty" yaml:"publicKey,omitempty"`
+}
+
+func (u *Upgrade) merge(o *Upgrade) {
+	if o.Channel != defaultUpgrade.Channel && o.Channel != "" {
+		u.Channel = o.Channel
+	}
+	if o.PublicKey != "" {
+		u.PublicKey = o.PublicKey
+	}
+}
+
+// IsZero controls whether this element will be included in marshalled output.
+func (u Upgrade) IsZero() bool {
+	return u == defaultUpgrade
+}
+
+// MarshalYAML is not using pointer receiver here, because Upgrade is not pointer in the Config struct.
+func (u Upgrade) MarshalYAML() (any, error) {
+	um := make(map[string]any)
+	if u.Channel != defaultUpgrade.Channel {
+		um["channel"] = u.Channel
+	}
+	if u.PublicKey != "" {
+		um["publicKey"] = u.PublicKey
+	}
+	return um, nil
+}
+
+// CLI contains settings that only affect the telepresence command.
+type CLI struct {
+	// Locale is the locale of the messages that the command prints, e.g. "de" or "pt_BR". The
+	// TELEPRESENCE_LOCALE environment variable takes precedence. The locale of the environment
+	// is used when it is empty.
+	Locale string `json:"locale,omitempty" yaml:"locale,omitempty"`
+}
+
+func (c *CLI) merge(o *CLI) {
+	if o.Locale != "" {
+		c.Locale = o.Locale
+	}
+}
+
+var defaultTelemetry = Telemetry{ //nolint:gochecknoglobals // constant
+	Enabled: true,
+}
+
+// Telemetry controls the anonymous usage reports that telepresence sends. The reports can be
+// inspected using "telepresence telemetry show".
+type Telemetry struct {
+	// Enabled is true when reports are sent. Setting the SCOUT_DISABLE environment variable also
+	// disables them.
+	Enabled bool `json:"enabled" yaml:"enabled"`
+
+	// Endpoint is the URL that the reports are sent to. The default is the Metriton endpoint of
+	// Ambassador Labs.
+	Endpoint string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+
+	// Redact are the names of the report fields whose values are replaced with "<redacted>"
+	// before they are sent.
+	Redact []string `json:"redact,omitempty" yaml:"redact,omitempty"`
+}
+
+func (t *Telemetry) merge(o *Telemetry) {
+	if !o.Enabled {
+		t.Enabled = false
+	}
+	if o.Endpoint != "" {
+		t.Endpoint = o.Endpoint
+	}
+	if len(o.Redact) > 0 {
+		t.Redact = o.Redact
+	}
+}
+
+// IsZero controls whether this element will be included in marshalled output.
+func (t Telemetry) IsZero() bool {
+	return t.Enabled && t.Endpoint == "" && len(t.Redact) == 0
+}
+
+// MarshalYAML is not using pointer receiver here, because Telemetry is not pointer in the Config struct.
+func (t Telemetry) MarshalYAML() (any, error) {
+	tm := make(map[string]any)
+	if !t.Enabled {
+		tm["enabled"] = false
+	}
+	if t.Endpoint != "" {
+		tm["endpoint"] = t.Endpoint
+	}
+	if len(t.Redact) > 0 {
+		tm["redact"] = t.Redact
+	}
+	return tm, nil
+}
+
+const (
+	// DefaultMTU is the MTU of the TUN-device unless configured otherwise.
+	DefaultMTU = 1500
+
+	// minMTU is the smallest MTU that IPv6 permits.
+	minMTU = 1280
+
+	maxMTU = 0xffff
+)
+
+// VIF configures the TUN-device that the root daemon uses to route traffic to the cluster.
+type VIF struct {
+	// MTU is the maximum transmission unit of the TUN-device. Lowering it helps when the cluster is
+	// reached through a VPN, or a VPN inside another VPN, that silently drops large packets.
+	MTU int `json:"mtu,omitempty" yaml:"mtu,omitempty"`
+
+	// NeverProxyCloudMetadata adds the instance metadata and credential endpoints of the cloud provider
+	// that hosts the cluster, if it can be detected, to the never-proxy subnets. Without it, the cloud SDKs
+	// on the developer's machine may reach the endpoints of the cluster's nodes instead of their own, or
+	// nothing at all.
+	NeverProxyCloudMetadata bool `json:"neverProxyCloudMetadata" yaml:"neverProxyCloudMetadata"`
+}
+
+var defaultVIF = VIF{ //nolint:gochecknoglobals // constant
+	NeverProxyCloudMetadata: true,
+}
+
+func (v *VIF) merge(o *VIF) {
+	if o.MTU != 0 {
+		v.MTU = o.MTU
+	}
+	if !o.NeverProxyCloudMetadata {
+		v.NeverProxyCloudMetadata = false
+	}
+}
+
+// IsZero controls whether this element will be included in marshalled output.
+func (v VIF) IsZero() bool {
+	return v.MTU == 0 && v.NeverProxyCloudMetadata
+}
+
+// MarshalYAML is not using pointer receiver here, because VIF is not pointer in the Config struct.
+func (v VIF) MarshalYAML() (any, error) {
+	vm := make(map[string]any)
+	if v.MTU != 0 {
+		vm["mtu"] = v.MTU
+	}
+	if !v.NeverProxyCloudMetadata {
+		vm["neverProxyCloudMetadata"] = false
+	}
+	return vm, nil
+}
+
+// GetMTU returns the configured MTU, or DefaultMTU when it isn't configured.
+func (v *VIF) GetMTU() int {
+	if v.MTU == 0 {
+		return DefaultMTU
+	}
+	return v.MTU
+}
+
+func (v *VIF) UnmarshalYAML(node *yaml.Node) (err error) {
+	if node.Kind != yaml.MappingNode {
+		return errors.New(WithLoc("vif must be an object", node))
+	}
+	ms := node.Content
+	top := len(ms)
+	for i := 0; i < top; i += 2 {
+		kv, err := StringKey(ms[i])
+		if err != nil {
+			return err
+		}
+		switch kv {
+		case "mtu":
+			var mtu int
+			if err := ms[i+1].Decode(&mtu); err != nil || mtu < minMTU || mtu > maxMTU {
+				logrus.Warn(WithLoc(fmt.Sprintf("invalid mtu %q. It must be an integer between %d and %d", ms[i+1].Value, minMTU, maxMTU), ms[i+1]))
+				continue
+			}
+			v.MTU = mtu
+		case "neverProxyCloudMetadata":
+			var b bool
+			if err := ms[i+1].Decode(&b); err != nil {
+				logrus.Warn(WithLoc(fmt.Sprintf("invalid neverProxyCloudMetadata %q. It must be a boolean", ms[i+1].Value), ms[i+1]))
+				continue
+			}
+			v.NeverProxyCloudMetadata = b
+		default:
+			logrus.Warn(WithLoc(fmt.Sprintf("unknown key %q", kv), ms[i]))
+		}
+	}
+	return nil
+}
+
+// Docker configures the daemon container that is used when connecting with --docker.
+type Docker struct {
+	// ServiceAliases makes the services of the mapped namespaces reachable from other containers on the
+	// telepresence network. Each service gets an address of its own on the daemon container, and a DNS
+	// server on the daemon container's address resolves "<service>.<namespace>" to that address. Containers
+	// use it by passing the daemon container's address to docker run --dns.
+	ServiceAliases bool `json:"serviceAliases,omitempty" yaml:"serviceAliases,omitempty"`
+}
+
+func (d *Docker) merge(o *Docker) {
+	if o.ServiceAliases {
+		d.ServiceAliases = true
+	}
+}
+
+// IsZero controls whether this element will be included in marshalled output.
+func (d Docker) IsZero() bool {
+	return !d.ServiceAliases
+}
+
+func (d *Docker) UnmarshalYAML(node *yaml.Node) (err error) {
+	if node.Kind != yaml.MappingNode {
+		return errors.New(WithLoc("docker must be an object", node))
+	}
+	ms := node.Content
+	top := len(ms)
+	for i := 0; i < top; i += 2 {
+		kv, err := StringKey(ms[i])
+		if err != nil {
+			return err
+		}
+		switch kv {
+		case "serviceAliases":
+			var b bool
+			if err := ms[i+1].Decode(&b); err != nil {
+				logrus.Warn(WithLoc(fmt.Sprintf("invalid serviceAliases %q. It must be a boolean", ms[i+1].Value), ms[i+1]))
+				continue
+			}
+			d.ServiceAliases = b
+		default:
+			logrus.Warn(WithLoc(fmt.Sprintf("unknown key %q", kv), ms[i]))
+		}
+	}
+	return nil
+}
+
+// UserDaemon contains the limits that the user daemon imposes on itself, so that a long-lived daemon doesn't grow
+// without bounds when it serves many intercepts. The daemon sheds load when it reaches a limit, and reports that
+// in the output of "telepresence status".
+type UserDaemon struct {
+	// MaxCachedEnvs is the number of intercepts for which the user daemon retains the environment of the
+	// intercepted container. The environments of older intercepts are dropped. Zero means the default of 32,
+	// and a negative value means no limit.
+	MaxCachedEnvs int `json:"maxCachedEnvs,omitempty" yaml:"maxCachedEnvs,omitempty"`
+
+	// MaxTunnels is the number of connections from intercepted containers that the user daemon serves
+	// concurrently. Connections beyond that are rejected. Zero means the default of 512, and a negative value
+	// means no limit.
+	MaxTunnels int `json:"maxTunnels,omitempty" yaml:"maxTunnels,omitempty"`
+
+	// GCPercent is the garbage collection target percentage, i.e. the equivalent of GOGC. Zero means the Go
+	// default of 100.
+	GCPercent int `json:"gcPercent,omitempty" yaml:"gcPercent,omitempty"`
+
+	// MemoryLimit is a soft limit for the memory that the user daemon uses, i.e. the equivalent of GOMEMLIMIT.
+	// The garbage collector works harder as the limit is approached. Zero means no limit.
+	MemoryLimitV resource.Quantity `json:"memoryLimit,omitempty" yaml:"memoryLimit,omitempty"`
+}
+
+// MemoryLimit returns the MemoryLimit in bytes, or zero when there's no limit.
+func (u *UserDaemon) MemoryLimit() int64 {
+	return quantityAsInt64(&u.MemoryLimitV)
+}
+
+func (u *UserDaemon) merge(o *UserDaemon) {
+	if o.MaxCachedEnvs != 0 {
+		u.MaxCachedEnvs = o.MaxCachedEnvs
+	}
+	if o.MaxTunnels != 0 {
+		u.MaxTunnels = o.MaxTunnels
+	}
+	if o.GCPercent != 0 {
+		u.GCPercent = o.GCPercent
+	}
+	if !o.MemoryLimitV.IsZero() {
+		u.MemoryLimitV = o.MemoryLimitV
+	}
+}
+
+// IsZero controls whether this element will be included in marshalled output.
+func (u UserDaemon) IsZero() bool {
+	return u.MaxCachedEnvs == 0 && u.MaxTunnels == 0 && u.GCPercent == 0 && u.MemoryLimitV.IsZero()
+}
+
+// MarshalYAML is not using pointer receiver here, because UserDaemon is not pointer in the Config struct.
+func (u UserDaemon) MarshalYAML() (any, error) {
+	um := make(map[string]any)
+	if u.MaxCachedEnvs != 0 {
+		um["maxCachedEnvs"] = u.MaxCachedEnvs
+	}
+	if u.MaxTunnels != 0 {
+		um["maxTunnels"] = u.MaxTunnels
+	}
+	if u.GCPercent != 0 {
+		um["gcPercent"] = u.GCPercent
+	}
+	if !u.MemoryLimitV.IsZero() {
+		um["memoryLimit"] = u.MemoryLimitV.String()
+	}
+	return um, nil
+}
+
+func (u *UserDaemon) UnmarshalYAML(node *yaml.Node) (err error) {
+	if node.Kind != yaml.MappingNode {
+		return errors.New(WithLoc("userDaemon must be an object", node))
+	}
+	ms := node.Content
+	top := len(ms)
+	for i := 0; i < top; i += 2 {
+		kv, err := StringKey(ms[i])
+		if err != nil {
+			return err
+		}
+		v := ms[i+1]
+		switch kv {
+		case "maxCachedEnvs", "maxTunnels", "gcPercent":
+			var n int
+			if err := v.Decode(&n); err != nil {
+				logrus.Warn(WithLoc(fmt.Sprintf("invalid %s %q. It must be an integer", kv, v.Value), v))
+				continue
+			}
+			switch kv {
+			case "maxCachedEnvs":
+				u.MaxCachedEnvs = n
+			case "maxTunnels":
+				u.MaxTunnels = n
+			default:
+				u.GCPercent = n
+			}
+		case "memoryLimit":
+			val, err := resource.ParseQuantity(v.Value)
+			if err != nil {
+				logrus.Warnf("unable to parse quantity %q: %v", v.Value, WithLoc(err.Error(), ms[i]))
+				continue
+			}
+			u.MemoryLimitV = val
+		default:
+			logrus.Warn(WithLoc(fmt.Sprintf("unknown key %q", kv), ms[i]))
+		}
+	}
+	return nil
+}
+
+// Paths overrides the locations of the directories and sockets that the CLI and the daemons use, e.g. to separate
+// the users of a shared CI machine, or when the home directory is read-only. The TELEPRESENCE_CACHE_DIR,
+// TELEPRESENCE_LOG_DIR, TELEPRESENCE_USER_DAEMON_SOCKET, and TELEPRESENCE_ROOT_DAEMON_SOCKET environment variables
+// take precedence. Environment variables, and a leading "~", are expanded. An empty value means the default.
+type Paths struct {
+	// CacheDir is the directory of the user cache.
+	CacheDir string `json:"cacheDir,omitempty" yaml:"cacheDir,omitempty"`
+
+	// LogDir is the directory of the log files.
+	LogDir string `json:"logDir,omitempty" yaml:"logDir,omitempty"`
+
+	// UserDaemonSocket is the unix socket, or named pipe on Windows, that the user daemon listens to.
+	UserDaemonSocket string `json:"userDaemonSocket,omitempty" yaml:"userDaemonSocket,omitempty"`
+
+	// RootDaemonSocket is the unix socket, or named pipe on Windows, that the root daemon listens to.
+	RootDaemonSocket string `json:"rootDaemonSocket,omitempty" yaml:"rootDaemonSocket,omitempty"`
+}
+
+func (p *Paths) merge(o *Paths) {
+	if o.CacheDir != "" {
+		p.CacheDir = o.CacheDir
+	}
+	if o.LogDir != "" {
+		p.LogDir = o.LogDir
+	}
+	if o.UserDaemonSocket != "" {
+		p.UserDaemonSocket = o.UserDaemonSocket
+	}
+	if o.RootDaemonSocket != "" {
+		p.RootDaemonSocket = o.RootDaemonSocket
+	}
+}
+
+// IsZero controls whether this element will be included in marshalled output.
+func (p Paths) IsZero() bool {
+	return p == Paths{}
+}
+
+const (
+	defaultRetryAttempts = 5
+	defaultRetryJitter   = 0.2
+)
+
+// Retries controls how the user daemon retries operations against the cluster that fail for reasons that are likely
+// to be transient, i.e. the installation of traffic-agents, the dial of the traffic-manager, and the listing of
+// namespaces. The number of retries and failures of each operation is shown by "telepresence status".
+type Retries struct {
+	// Attempts is the maximum number of attempts. Zero means the default of 5.
+	Attempts int `json:"attempts,omitempty" yaml:"attempts,omitempty"`
+
+	// Delay is the delay after the first failed attempt. It's doubled for each consecutive failure, up to MaxDelay.
+	// Zero means the default of 100ms.
+	Delay time.Duration `json:"delay,omitempty" yaml:"delay,omitempty"`
+
+	// MaxDelay is the longest delay between two attempts. Zero means the default of 3s.
+	MaxDelay time.Duration `json:"maxDelay,omitempty" yaml:"maxDelay,omitempty"`
+
+	// Jitter is the fraction, between zero and one, by which each delay is randomly shortened or lengthened. Zero
+	// means the default of 0.2, and a negative value means no jitter.
+	Jitter float64 `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+}
+
+// Policy returns the RetryPolicy of this configuration.
+func (r *Retries) Policy() *RetryPolicy {
+	p := &RetryPolicy{
+		Attempts: r.Attempts,
+		Delay:    r.Delay,
+		MaxDelay: r.MaxDelay,
+		Jitter:   r.Jitter,
+	}
+	if p.Attempts == 0 {
+		p.Attempts = defaultRetryAttempts
+	}
+	if p.Jitter == 0 {
+		p.Jitter = defaultRetryJitter
+	}
+	return p
+}
+
+func (r *Retries) durationPtr(key string) *time.Duration {
+	switch key {
+	case "delay":
+		return &r.Delay
+	case "maxDelay":
+		return &r.MaxDelay
+	}
+	return nil
+}
+
+func (r *Retries) merge(o *Retries) {
+	if o.Attempts != 0 {
+		r.Attempts = o.Attempts
+	}
+	if o.Delay != 0 {
+		r.Delay = o.Delay
+	}
+	if o.MaxDelay != 0 {
+		r.MaxDelay = o.MaxDelay
+	}
+	if o.Jitter != 0 {
+		r.Jitter = o.Jitter
+	}
+}
+
+// IsZero controls whether this element will be included in marshalled output.
+func (r Retries) IsZero() bool {
+	return r == Retries{}
+}
+
+// MarshalYAML is not using pointer receiver here, because Retries is not pointer in the Config struct.
+func (r Retries) MarshalYAML() (any, error) {
+	rm := make(map[string]any)
+	if r.Attempts != 0 {
+		rm["attempts"] = r.Attempts
+	}
+	for _, k := range []string{"delay", "maxDelay"} {
+		if dp := r.durationPtr(k); *dp != 0 {
+			rm[k] = dp.String()
+		}
+	}
+	if r.Jitter != 0 {
+		rm["jitter"] = r.Jitter
+	}
+	return rm, nil
+}
+
+func (r *Retries) UnmarshalYAML(node *yaml.Node) (err error) {
+	if node.Kind != yaml.MappingNode {
+		return errors.New(WithLoc("retries must be an object", node))
+	}
+	ms := node.Content
+	top := len(ms)
+	for i := 0; i < top; i += 2 {
+		kv, err := StringKey(ms[i])
+		if err != nil {
+			return err
+		}
+		v := ms[i+1]
+		if dp := r.durationPtr(kv); dp != nil {
+			val, err := time.ParseDuration(v.Value)
+			if err != nil || val < 0 {
+				logrus.Warn(WithLoc(fmt.Sprintf("unable to parse %s %q", kv, v.Value), ms[i]))
+			} else {
+				*dp = val
+			}
+			continue
+		}
+		switch kv {
+		case "attempts":
+			var n int
+			if err := v.Decode(&n); err != nil || n < 0 {
+				logrus.Warn(WithLoc(fmt.Sprintf("invalid attempts %q. It must be a positive integer", v.Value), v))
+				continue
+			}
+			r.Attempts = n
+		case "jitter":
+			var f float64
+			if err := v.Decode(&f); err != nil || f > 1 {
+				logrus.Warn(WithLoc(fmt.Sprintf("invalid jitter %q. It must be a number that is less than or equal to 1", v.Value), v))
+				continue
+			}
+			r.Jitter = f
+		default:
+			logrus.Warn(WithLoc(fmt.Sprintf("unknown key %q", kv), ms[i]))
+		}
+	}
+	return nil
+}
+
 var (
 	parsedFile string     //nolint:gochecknoglobals // protected by parseLock
 	parseLock  sync.Mutex //nolint:gochecknoglobals // protects parsedFile
@@ -881,10 +1906,18 @@ func WithConfig(ctx context.Context, config Config) context.Context {
 }
 
 func GetConfig(ctx context.Context) Config {
+	if cfg := GetConfigIfSet(ctx); cfg != nil {
+		return cfg
+	}
+	panic("no Config has been set")
+}
+
+// GetConfigIfSet is like GetConfig but returns nil when no Config has been set.
+func GetConfigIfSet(ctx context.Context) Config {
 	if configPtr, ok := ctx.Value(configKey{}).(*unsafe.Pointer); ok {
 		return *(*Config)(atomic.LoadPointer(configPtr))
 	}
-	panic("no Config has been set")
+	return nil
 }
 
 // ReplaceConfig replaces the config last stored using WithConfig with the given Config.
@@ -921,11 +1954,15 @@ func GetDefaultBaseConfig() BaseConfig {
 		OSSpecificConfig: GetDefaultOSSpecificConfig(),
 		TimeoutsV:        defaultTimeouts,
 		LogLevelsV:       defaultLogLevels,
+		LogFilesV:        LogFiles{},
 		ImagesV:          defaultImages,
 		GrpcV:            Grpc{},
 		TelepresenceAPIV: TelepresenceAPI{},
 		InterceptV:       defaultIntercept,
 		ClusterV:         defaultCluster,
+		UpgradeV:         defaultUpgrade,
+		TelemetryV:       defaultTelemetry,
+		VIFV:             defaultVIF,
 	}
 }
 
@@ -1003,6 +2040,7 @@ type DNS struct {
 	Excludes        []string      `json:"excludes,omitempty" yaml:"excludes,omitempty"`
 	Mappings        DNSMappings   `json:"mappings,omitempty" yaml:"mappings,omitempty"`
 	LookupTimeout   time.Duration `json:"lookupTimeout,omitempty" yaml:"lookupTimeout,omitempty"`
+	ClusterDomain   string        `json:"clusterDomain,omitempty" yaml:"clusterDomain,omitempty"`
 }
 
 // DNSSnake is the same as DNS but with snake_case json/yaml names.
@@ -1015,6 +2053,7 @@ type DNSSnake struct {
 	Excludes        []string      `json:"excludes,omitempty" yaml:"excludes,omitempty"`
 	Mappings        DNSMappings   `json:"mappings,omitempty" yaml:"mappings,omitempty"`
 	LookupTimeout   time.Duration `json:"lookup_timeout,omitempty" yaml:"lookup_timeout,omitempty"`
+	ClusterDomain   string        `json:"cluster_domain,omitempty" yaml:"cluster_domain,omitempty"`
 }
 
 type SessionConfig struct {
@@ -1023,6 +2062,10 @@ type SessionConfig struct {
 	DNS              DNS     `json:"dns,omitempty" yaml:"dns,omitempty"`
 	Routing          Routing `json:"routing,omitempty" yaml:"routing,omitempty"`
 	ManagerNamespace string  `json:"managerNamespace,omitempty" yaml:"managerNamespace,omitempty"`
+
+	// ActiveTimeouts are the timeouts in effect, including default values and overrides given
+	// when connecting.
+	ActiveTimeouts map[string]string `json:"activeTimeouts,omitempty" yaml:"activeTimeouts,omitempty"`
 }
 
 func (sc *SessionConfig) UnmarshalJSON(data []byte) error {
@@ -0,0 +1,20 @@
+package client
+
+type OSSpecificConfig struct {
+	Network Network `json:"network,omitempty" yaml:"network,omitempty"`
+	WSL     WSL     `json:"wsl,omitempty" yaml:"wsl,omitempty"`
+}
+
+func GetDefaultOSSpecificConfig() OSSpecificConfig {
+	return OSSpecificConfig{
+		Network: Network{
+			Firewall: defaultFirewall,
+		},
+	}
+}
+
+// Merge merges this instance with the non-zero values of the given argument. The argument values take priority.
+func (c *OSSpecificConfig) Merge(o *OSSpecificConfig) {
+	c.Network.merge(&o.Network)
+	c.WSL.merge(&o.WSL)
+}
@@ -0,0 +1,63 @@
+//go:build linux || freebsd || openbsd
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/firewall"
+	"github.com/telepresenceio/telepresence/v2/pkg/slice"
+)
+
+const defaultFirewall = firewall.ModeAuto
+
+type Network struct {
+	// Firewall is the firewall backend that the root daemon uses to redirect DNS traffic. One of
+	// "auto", "iptables", or "nftables" on Linux, and "auto" or "pf" on FreeBSD and OpenBSD.
+	Firewall string `json:"firewall,omitempty" yaml:"firewall,omitempty"`
+}
+
+func (n *Network) merge(o *Network) {
+	if o.Firewall != defaultFirewall {
+		n.Firewall = o.Firewall
+	}
+}
+
+func (n Network) IsZero() bool {
+	return n.Firewall == defaultFirewall
+}
+
+func (n *Network) UnmarshalYAML(node *yaml.Node) (err error) {
+	if node.Kind != yaml.MappingNode {
+		return errors.New(WithLoc("network must be an object", node))
+	}
+	ms := node.Content
+	top := len(ms)
+	for i := 0; i < top; i += 2 {
+		kv, err := StringKey(ms[i])
+		if err != nil {
+			return err
+		}
+		v := ms[i+1]
+		switch kv {
+		case "firewall":
+			if modes := firewall.Modes(); slice.Contains(modes, v.Value) {
+				n.Firewall = v.Value
+			} else {
+				logrus.Warn(WithLoc(fmt.Sprintf("invalid firewall %q. Valid values are %s",
+					v.Value, strings.Join(modes, ", ")), ms[i+1]))
+			}
+		default:
+			logrus.Warn(WithLoc(fmt.Sprintf("unknown key %q", kv), ms[i]))
+		}
+	}
+	if n.Firewall == "" {
+		n.Firewall = defaultFirewall
+	}
+	return nil
+}
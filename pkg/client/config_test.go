@@ -27,6 +27,22 @@ logLevels:
   rootDaemon: debug
 cluster:
   defaultManagerNamespace: hello
+  sshProxy:
+    address: bastion.example.com
+    user: sys
+upgrade:
+  channel: latest
+telemetry:
+  enabled: false
+grpc:
+  maxSendSize: 8Mi
+  keepAliveInterval: 30s
+vif:
+  mtu: 1400
+logFormat: json
+logFiles:
+  maxSize: 10Mi
+  maxBackups: 2
 `,
 		/* sys2 */ `
 timeouts:
@@ -50,6 +66,28 @@ intercept:
   appProtocolStrategy: portName
   defaultPort: 9080
   useFtp: true
+upgrade:
+  channel: https://mirror.example.com/tel2
+telemetry:
+  redact: [cluster_id]
+grpc:
+  keepAliveInterval: 45s
+  tunnelKeepAliveInterval: 5s
+  initialWindowSize: 1Mi
+vif:
+  mtu: 100
+  neverProxyCloudMetadata: false
+cluster:
+  sshProxy:
+    address: jump.example.com:2222
+    keyFile: /home/user/.ssh/id_ed25519
+userDaemon:
+  maxTunnels: 128
+  memoryLimit: 512Mi
+logFormat: text
+logFiles:
+  maxSize: 0
+  maxBackups: 4
 `,
 	}
 
@@ -91,6 +129,24 @@ intercept:
 	assert.Equal(t, 9080, cfg.Intercept().DefaultPort)                                           // from user
 	assert.True(t, cfg.Intercept().UseFtp)                                                       // from user
 	assert.Equal(t, cfg.Cluster().DefaultManagerNamespace, "hello")                              // from sys1
+	assert.Equal(t, "https://mirror.example.com/tel2", cfg.Upgrade().Channel)                    // from user
+	assert.False(t, cfg.Telemetry().Enabled)                                                     // from sys1
+	assert.Equal(t, []string{"cluster_id"}, cfg.Telemetry().Redact)                              // from user
+	assert.Equal(t, int64(8*1024*1024), cfg.Grpc().MaxSendSize())                                // from sys1
+	assert.Equal(t, 45*time.Second, cfg.Grpc().KeepAliveInterval)                                // from user
+	assert.Equal(t, 5*time.Second, cfg.Grpc().TunnelKeepAliveInterval)                           // from user
+	assert.Equal(t, int64(1024*1024), cfg.Grpc().InitialWindowSize())                            // from user
+	assert.Equal(t, 1400, cfg.VIF().GetMTU())                                                    // from sys1, user value is invalid
+	assert.False(t, cfg.VIF().NeverProxyCloudMetadata)                                           // from user
+	assert.Equal(t, SSHProxy{
+		Address: "jump.example.com:2222",
+		KeyFile: "/home/user/.ssh/id_ed25519",
+	}, cfg.Cluster().SSHProxy) // from user, replacing sys1
+	assert.Equal(t, 128, cfg.UserDaemon().MaxTunnels)                     // from user
+	assert.Equal(t, int64(512*1024*1024), cfg.UserDaemon().MemoryLimit()) // from user
+	assert.Equal(t, LogFormatText, *cfg.LogFormat())                      // from user, resetting sys1
+	assert.Equal(t, int64(0), cfg.LogFiles().MaxSizeBytes())              // from user, resetting sys1
+	assert.Equal(t, 4, cfg.LogFiles().GetMaxBackups())                    // from user, resetting sys1
 }
 
 func Test_ConfigMarshalYAML(t *testing.T) {
@@ -103,10 +159,43 @@ func Test_ConfigMarshalYAML(t *testing.T) {
 	cfg.Timeouts().PrivateTrafficManagerAPI = defaultTimeoutsTrafficManagerAPI + 20*time.Second
 	cfg.LogLevels().UserDaemon = logrus.TraceLevel
 	cfg.Grpc().MaxReceiveSizeV, _ = resource.ParseQuantity("20Mi")
+	cfg.Grpc().MaxRequestRate = 50
+	cfg.Grpc().MaxSendSizeV = resource.MustParse("16Mi")
+	cfg.Grpc().KeepAliveInterval = time.Minute
+	cfg.Grpc().KeepAliveTimeout = 15 * time.Second
+	cfg.Grpc().ConnectorKeepAliveInterval = 20 * time.Second
+	cfg.Grpc().TunnelKeepAliveTimeout = 5 * time.Second
+	cfg.Grpc().HeartbeatInterval = 20 * time.Second
+	cfg.Grpc().InitialWindowSizeV = resource.MustParse("1Mi")
+	cfg.Grpc().InitialConnWindowSizeV = resource.MustParse("4Mi")
 	cfg.TelepresenceAPI().Port = 4567
 	cfg.Intercept().AppProtocolStrategy = k8sapi.PortName
 	cfg.Intercept().DefaultPort = 9080
 	cfg.Cluster().DefaultManagerNamespace = "hello-there"
+	cfg.Cluster().ManagerAddress = "traffic-manager.example.com:443"
+	cfg.Cluster().ManagerCAFile = "/etc/telepresence/ca.pem"
+	cfg.Cluster().ManagerTokenFile = "/etc/telepresence/token"
+	cfg.Cluster().SSHProxy = SSHProxy{Address: "bastion.example.com", User: "jumper", KnownHostsFile: "/etc/ssh/known_hosts"}
+	cfg.Upgrade().Channel = UpgradeChannelLatest
+	cfg.CLI().Locale = "de"
+	cfg.Telemetry().Enabled = false
+	cfg.Telemetry().Endpoint = "https://telemetry.example.com/report"
+	cfg.Telemetry().Redact = []string{"cluster_id", "service_name"}
+	*cfg.LogFormat() = LogFormatJSON
+	maxSize := resource.MustParse("10Mi")
+	maxBackups := 2
+	cfg.LogFiles().MaxSize = &maxSize
+	cfg.LogFiles().MaxBackups = &maxBackups
+	cfg.VIF().MTU = 1380
+	cfg.VIF().NeverProxyCloudMetadata = false
+	cfg.UserDaemon().MaxCachedEnvs = 8
+	cfg.UserDaemon().MaxTunnels = -1
+	cfg.UserDaemon().GCPercent = 50
+	cfg.UserDaemon().MemoryLimitV = resource.MustParse("1Gi")
+	cfg.Retries().Attempts = 8
+	cfg.Retries().Delay = 250 * time.Millisecond
+	cfg.Retries().MaxDelay = 10 * time.Second
+	cfg.Retries().Jitter = 0.5
 	cfgBytes, err := yaml.Marshal(cfg)
 	require.NoError(t, err)
 
@@ -126,3 +215,21 @@ func Test_ConfigMarshalYAMLDefaults(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "{}\n", string(cfgBytes))
 }
+
+func TestTimeoutsOverride(t *testing.T) {
+	cfg := GetDefaultConfig()
+	tos := cfg.Timeouts()
+	require.NoError(t, tos.Override(map[string]string{"trafficManagerConnect": "3m", "helm": "90s"}))
+	assert.Equal(t, 3*time.Minute, tos.Get(TimeoutTrafficManagerConnect))
+	assert.Equal(t, 90*time.Second, tos.Get(TimeoutHelm))
+	assert.Equal(t, defaultTimeoutsIntercept, tos.Get(TimeoutIntercept))
+
+	assert.Error(t, tos.Override(map[string]string{"noSuchTimeout": "1s"}))
+	assert.Error(t, tos.Override(map[string]string{"intercept": "soon"}))
+	assert.Error(t, tos.Override(map[string]string{"intercept": "-1s"}))
+
+	active := tos.Active()
+	assert.Len(t, active, len(timeoutNames))
+	assert.Equal(t, "3m0s", active["trafficManagerConnect"])
+	assert.Equal(t, defaultTimeoutsAgentInstall.String(), active["agentInstall"])
+}
@@ -10,6 +10,8 @@ import (
 
 type OSSpecificConfig struct {
 	Network Network `json:"network,omitempty" yaml:"network,omitempty"`
+	Pipes   Pipes   `json:"pipes,omitempty" yaml:"pipes,omitempty"`
+	WSL     WSL     `json:"wsl,omitempty" yaml:"wsl,omitempty"`
 }
 
 func GetDefaultOSSpecificConfig() OSSpecificConfig {
@@ -23,6 +25,8 @@ func GetDefaultOSSpecificConfig() OSSpecificConfig {
 // Merge merges this instance with the non-zero values of the given argument. The argument values take priority.
 func (c *OSSpecificConfig) Merge(o *OSSpecificConfig) {
 	c.Network.merge(&o.Network)
+	c.Pipes.merge(&o.Pipes)
+	c.WSL.merge(&o.WSL)
 }
 
 type GSCStrategy string
@@ -84,3 +88,48 @@ func (n *Network) UnmarshalYAML(node *yaml.Node) (err error) {
 	}
 	return nil
 }
+
+// Pipes controls the security descriptors of the named pipes that the daemons listen to. Access is
+// always granted to the owning user and to SYSTEM.
+type Pipes struct {
+	// AdminGroups is a list of SIDs, or SDDL SID aliases such as "BA" (BUILTIN\Administrators), that
+	// will be granted access to the pipes in addition to the owning user.
+	AdminGroups []string `json:"adminGroups,omitempty" yaml:"adminGroups,omitempty"`
+}
+
+func (p *Pipes) merge(o *Pipes) {
+	if len(o.AdminGroups) > 0 {
+		p.AdminGroups = o.AdminGroups
+	}
+}
+
+func (p Pipes) IsZero() bool {
+	return len(p.AdminGroups) == 0
+}
+
+func (p *Pipes) UnmarshalYAML(node *yaml.Node) (err error) {
+	if node.Kind != yaml.MappingNode {
+		return errors.New(WithLoc("pipes must be an object", node))
+	}
+	ms := node.Content
+	top := len(ms)
+	for i := 0; i < top; i += 2 {
+		kv, err := StringKey(ms[i])
+		if err != nil {
+			return err
+		}
+		v := ms[i+1]
+		switch kv {
+		case "adminGroups":
+			var groups []string
+			if err := v.Decode(&groups); err != nil {
+				logrus.Warn(WithLoc("adminGroups must be a list of strings", v))
+				continue
+			}
+			p.AdminGroups = groups
+		default:
+			logrus.Warn(WithLoc(fmt.Sprintf("unknown key %q", kv), ms[i]))
+		}
+	}
+	return nil
+}
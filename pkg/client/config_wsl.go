@@ -0,0 +1,76 @@
+//go:build linux || windows
+
+package client
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// WSL configures the bridge that lets a user daemon in a WSL2 distribution use the root daemon on the Windows
+// host. The root daemon on Windows provides the VIF, so a single connect from inside the distribution gives
+// both Windows and the distribution access to the cluster.
+type WSL struct {
+	// BridgePort is the port of the bridge. On Windows, it's the port that the root daemon listens to for user
+	// daemons in WSL2. In a WSL2 distribution, it's the port that the user daemon dials instead of starting a
+	// local root daemon. Zero disables the bridge.
+	BridgePort uint16 `json:"bridgePort,omitempty" yaml:"bridgePort,omitempty"`
+
+	// Host is the address of the Windows host, as seen from the WSL2 distribution. Defaults to the default
+	// gateway, which is the Windows host when WSL2 uses NAT networking. Use 127.0.0.1 with mirrored networking.
+	Host string `json:"host,omitempty" yaml:"host,omitempty"`
+
+	// TokenFile is the file, as seen from the WSL2 distribution, that the root daemon on Windows writes the
+	// token of the bridge to. Defaults to the file found in the local application data of the Windows users.
+	TokenFile string `json:"tokenFile,omitempty" yaml:"tokenFile,omitempty"`
+}
+
+func (w *WSL) merge(o *WSL) {
+	if o.BridgePort != 0 {
+		w.BridgePort = o.BridgePort
+	}
+	if o.Host != "" {
+		w.Host = o.Host
+	}
+	if o.TokenFile != "" {
+		w.TokenFile = o.TokenFile
+	}
+}
+
+func (w WSL) IsZero() bool {
+	return w.BridgePort == 0 && w.Host == "" && w.TokenFile == ""
+}
+
+func (w *WSL) UnmarshalYAML(node *yaml.Node) (err error) {
+	if node.Kind != yaml.MappingNode {
+		return errors.New(WithLoc("wsl must be an object", node))
+	}
+	ms := node.Content
+	top := len(ms)
+	for i := 0; i < top; i += 2 {
+		kv, err := StringKey(ms[i])
+		if err != nil {
+			return err
+		}
+		v := ms[i+1]
+		switch kv {
+		case "bridgePort":
+			var port uint16
+			if err := v.Decode(&port); err != nil {
+				logrus.Warn(WithLoc(fmt.Sprintf("invalid bridgePort %q. It must be a port number", v.Value), v))
+				continue
+			}
+			w.BridgePort = port
+		case "host":
+			w.Host = v.Value
+		case "tokenFile":
+			w.TokenFile = v.Value
+		default:
+			logrus.Warn(WithLoc(fmt.Sprintf("unknown key %q", kv), ms[i]))
+		}
+	}
+	return nil
+}
@@ -3,11 +3,15 @@ package docker
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"os"
 	"strings"
 	"sync"
 
+	"github.com/docker/cli/cli/connhelper"
 	"github.com/docker/docker/client"
 
+	"github.com/datawire/dlib/dlog"
 	"github.com/telepresenceio/telepresence/v2/pkg/proc"
 )
 
@@ -15,7 +19,9 @@ type clientKey struct{}
 
 type clientHandle struct {
 	sync.Mutex
-	cli *client.Client
+	cli    *client.Client
+	host   string
+	engine *Engine
 }
 
 func (h *clientHandle) GetClient(ctx context.Context) (*client.Client, error) {
@@ -28,18 +34,56 @@ func (h *clientHandle) GetClient(ctx context.Context) (*client.Client, error) {
 		if err != nil {
 			return nil, fmt.Errorf("unable to retrieve docker context: %v", err)
 		}
-		if host := strings.TrimSpace(string(stdout)); host != "" {
+		host := strings.TrimSpace(string(stdout))
+		if vh := vmHost(ctx, host); vh != "" {
+			// Let docker commands that are started later use the same engine.
+			dlog.Infof(ctx, "%s doesn't exist, using %s", client.DefaultDockerHost, vh)
+			host = vh
+			_ = os.Setenv(client.EnvOverrideHost, host)
+		}
+		if host != "" {
 			opts = append(opts, client.WithHost(host))
+			helper, err := connhelper.GetConnectionHelper(host)
+			if err != nil {
+				return nil, err
+			}
+			if helper != nil {
+				// The engine is reached using a command, e.g. ssh, and not by dialing its host.
+				opts = append(opts,
+					client.WithHost(helper.Host),
+					client.WithHTTPClient(&http.Client{Transport: &http.Transport{DialContext: helper.Dialer}}),
+					client.WithDialContext(helper.Dialer))
+			}
 		}
 		cli, err := client.NewClientWithOpts(opts...)
 		if err != nil {
 			return nil, err
 		}
+		if host == "" {
+			host = cli.DaemonHost()
+		}
 		h.cli = cli
+		h.host = host
 	}
 	return h.cli, nil
 }
 
+// GetEngine returns a description of the docker engine that the client talks to.
+func (h *clientHandle) GetEngine(ctx context.Context) (*Engine, error) {
+	cli, err := h.GetClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	h.Lock()
+	defer h.Unlock()
+	if h.engine == nil {
+		if h.engine, err = detectEngine(ctx, cli, h.host); err != nil {
+			return nil, err
+		}
+	}
+	return h.engine, nil
+}
+
 func EnableClient(ctx context.Context) context.Context {
 	if ctx.Value(clientKey{}) == nil {
 		ctx = context.WithValue(ctx, clientKey{}, &clientHandle{})
@@ -53,3 +97,11 @@ func GetClient(ctx context.Context) (*client.Client, error) {
 	}
 	panic("docker client not initialized")
 }
+
+// GetEngine returns a description of the docker engine of the client in the given context.
+func GetEngine(ctx context.Context) (*Engine, error) {
+	if h, ok := ctx.Value(clientKey{}).(*clientHandle); ok {
+		return h.GetEngine(ctx)
+	}
+	panic("docker client not initialized")
+}
@@ -8,30 +8,26 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
-	"net/netip"
-	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
-	"runtime"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/filters"
-	dockerClient "github.com/docker/docker/client"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
-	runtime2 "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/tools/clientcmd/api"
 
 	"github.com/datawire/dlib/dlog"
 	"github.com/datawire/dlib/dtime"
+	"github.com/telepresenceio/telepresence/v2/pkg/atrest"
 	"github.com/telepresenceio/telepresence/v2/pkg/authenticator/patcher"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cache"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/docker/kubeauth"
 	"github.com/telepresenceio/telepresence/v2/pkg/dnet"
@@ -71,13 +67,40 @@ func DaemonOptions(ctx context.Context, daemonID *daemon.Identifier) ([]string,
 		"--device", "/dev/net/tun:/dev/net/tun",
 		"-e", fmt.Sprintf("TELEPRESENCE_UID=%d", os.Getuid()),
 		"-e", fmt.Sprintf("TELEPRESENCE_GID=%d", os.Getgid()),
+		"-i", // the cache key is written to stdin when the container starts, see startDaemon
 		"-p", fmt.Sprintf("%s:%d", addr, port),
-		"-v", fmt.Sprintf("%s:%s:ro", filelocation.AppUserConfigDir(ctx), dockerTpConfig),
-		"-v", fmt.Sprintf("%s:%s", filelocation.AppUserCacheDir(ctx), dockerTpCache),
-		"-v", fmt.Sprintf("%s:%s", filelocation.AppUserLogDir(ctx), dockerTpLog),
 	}
-	if runtime.GOOS == "linux" {
-		opts = append(opts, "--add-host", "host.docker.internal:host-gateway")
+	engine, err := GetEngine(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if engine.Remote {
+		// Directories of this machine can't be mounted. The configuration is copied into the container
+		// by LaunchDaemon, and the caches and logs are kept in a volume on the remote host.
+		opts = append(opts, "-v", remoteCacheVolume+":"+dockerTpCache)
+	} else {
+		for _, m := range []struct{ src, dst, mode string }{
+			{filelocation.AppUserConfigDir(ctx), dockerTpConfig, ":ro"},
+			{filelocation.AppUserCacheDir(ctx), dockerTpCache, ""},
+			{filelocation.AppUserLogDir(ctx), dockerTpLog, ""},
+		} {
+			if problem := engine.MountProblem(m.src); problem != "" {
+				dlog.Warn(ctx, problem)
+			}
+			opts = append(opts, "-v", engine.MountSource(m.src)+":"+m.dst+m.mode)
+		}
+	}
+	if hg := engine.HostGateway(); hg != "" {
+		opts = append(opts, "--add-host", "host.docker.internal:"+hg)
+	}
+	if cr := daemon.GetRequest(ctx); cr != nil {
+		for _, expose := range cr.Expose {
+			publish, err := publishOption(expose, port)
+			if err != nil {
+				return nil, nil, err
+			}
+			opts = append(opts, "-p", publish)
+		}
 	}
 	env := client.GetEnv(ctx)
 	if env.ScoutDisable {
@@ -93,6 +116,7 @@ func DaemonArgs(daemonID *daemon.Identifier, port int) []string {
 		"--name", "docker-" + daemonID.String(),
 		"--address", fmt.Sprintf(":%d", port),
 		"--embed-network",
+		"--cache-key-stdin",
 	}
 }
 
@@ -115,35 +139,35 @@ func DiscoverDaemon(ctx context.Context, match *regexp.Regexp) (conn *grpc.Clien
 	if err != nil {
 		return nil, nil, err
 	}
-	var addr string
-	if proc.RunningInContainer() {
-		// Containers use the daemon container DNS name
-		addr = fmt.Sprintf("%s:%d", daemonID.ContainerName(), info.DaemonPort)
-	} else {
-		// The host relies on that the daemon has exposed a port to localhost
-		addr = fmt.Sprintf(":%d", info.DaemonPort)
-	}
-	if conn, err = connectDaemon(ctx, daemonID, addr); err != nil {
+	if conn, err = connectDaemon(ctx, daemonID, info.Address(daemonID), info.DockerHost); err != nil {
 		return nil, nil, err
 	}
 	return conn, daemonID, nil
 }
 
-// connectDaemon connects to a daemon at the given address.
-func connectDaemon(ctx context.Context, daemonID *daemon.Identifier, address string) (conn *grpc.ClientConn, err error) {
-	if err = enableK8SAuthenticator(ctx, daemonID); err != nil {
+// connectDaemon connects to a daemon at the given address. The dockerHost is the host of a remote
+// engine that the daemon runs in, or empty when the daemon's port is published on this machine.
+func connectDaemon(ctx context.Context, daemonID *daemon.Identifier, address, dockerHost string) (conn *grpc.ClientConn, err error) {
+	if err = enableK8SAuthenticator(ctx, daemonID, dockerHost); err != nil {
 		return nil, err
 	}
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithNoProxy(),
+		grpc.WithBlock(),
+		grpc.FailOnNonTempDialError(true),
+	}
+	if dockerHost != "" {
+		opts = append(opts, remoteDialOption(dockerHost))
+	}
+	opts = append(opts, client.GetConfig(ctx).Grpc().DialOptions(client.ChannelConnector)...)
+
 	// Assume that the user daemon is running and connect to it using the given address instead of using a socket.
 	for i := 1; ; i++ {
 		if ctx.Err() != nil {
 			return nil, ctx.Err()
 		}
-		conn, err = grpc.DialContext(ctx, address,
-			grpc.WithTransportCredentials(insecure.NewCredentials()),
-			grpc.WithNoProxy(),
-			grpc.WithBlock(),
-			grpc.FailOnNonTempDialError(true))
+		conn, err = grpc.DialContext(ctx, address, opts...)
 		if err != nil {
 			if i < 10 {
 				// It's likely that we were too quick. Let's take a nap and try again
@@ -253,7 +277,7 @@ func ensureAuthenticatorService(ctx context.Context, kubeFlags map[string]string
 	return startAuthenticatorService(ctx, portFile, kubeFlags, configFiles)
 }
 
-func enableK8SAuthenticator(ctx context.Context, daemonID *daemon.Identifier) error {
+func enableK8SAuthenticator(ctx context.Context, daemonID *daemon.Identifier, dockerHost string) error {
 	cr := daemon.GetRequest(ctx)
 	if cr.Implicit {
 		return nil
@@ -296,6 +320,10 @@ func enableK8SAuthenticator(ctx context.Context, daemonID *daemon.Identifier) er
 	}
 
 	if patcher.NeedsStubbedExec(&config) {
+		if dockerHost != "" {
+			return errcat.User.Newf("the kubeconfig context %q uses an exec credential plugin, which can't be reached "+
+				"from a daemon container on the remote docker host %s", config.CurrentContext, dockerHost)
+		}
 		port, err := ensureAuthenticatorService(ctx, cr.KubeFlags, configFiles)
 		if err != nil {
 			return err
@@ -325,78 +353,23 @@ func enableK8SAuthenticator(ctx context.Context, daemonID *daemon.Identifier) er
 		dlog.Errorf(ctx, "unable to handle local K8s: %v", err)
 	}
 
-	if err = clientcmd.WriteToFile(config, filepath.Join(kubeConfigDir, kubeConfigFile)); err != nil {
-		return err
-	}
-
-	// Concatenate using "/". This will be used in linux
-	cr.KubeFlags["kubeconfig"] = fmt.Sprintf("%s/%s/%s", dockerTpCache, kubeConfigs, kubeConfigFile)
-	return nil
-}
-
-// handleLocalK8s checks if the cluster is using a well known provider (currently minikube or kind)
-// and ensures that the service is modified to access the docker internal address instead of an
-// address available on the host.
-func handleLocalK8s(ctx context.Context, daemonID *daemon.Identifier, clusterName string, cl *api.Cluster) error {
-	isKind := strings.HasPrefix(clusterName, "kind-")
-	isMinikube := false
-	if !isKind {
-		if ex, ok := cl.Extensions["cluster_info"].(*runtime2.Unknown); ok {
-			var data map[string]any
-			isMinikube = json.Unmarshal(ex.Raw, &data) == nil && data["provider"] == "minikube.sigs.k8s.io"
-		}
-	}
-	if !(isKind || isMinikube) {
-		return nil
-	}
-
-	server, err := url.Parse(cl.Server)
+	// The kubeconfig contains credentials, so it is encrypted. The daemon in the container decrypts it.
+	data, err := clientcmd.Write(config)
 	if err != nil {
 		return err
 	}
-	host, portStr, err := net.SplitHostPort(server.Host)
-	if err != nil {
+	if err = cache.WriteSecretFile(ctx, filepath.Join(kubeConfigDir, kubeConfigFile), data, 0o600); err != nil {
 		return err
 	}
-	addr, err := netip.ParseAddr(host)
-	if err != nil {
-		if host == "localhost" {
-			addr = netip.AddrFrom4([4]byte{127, 0, 0, 1})
+	if dockerHost != "" {
+		// The cache of a remote daemon is a volume on the remote host.
+		if err = copyToContainer(ctx, daemonID.ContainerName(), kubeConfigDir, dockerTpCache+"/"+kubeConfigs); err != nil {
+			return fmt.Errorf("unable to copy the kubeconfig to the daemon container: %w", err)
 		}
 	}
-	port, err := strconv.ParseUint(portStr, 10, 16)
-	if err != nil {
-		return err
-	}
-
-	addrPort := netip.AddrPortFrom(addr, uint16(port))
 
-	// Let's check if we have a container with port bindings for the
-	// given addrPort that is a known k8sapi provider
-	cli, err := GetClient(ctx)
-	if err != nil {
-		return err
-	}
-	cjs := runningContainers(ctx, cli)
-
-	var hostPort, network string
-	if isKind {
-		hostPort, network = detectKind(cjs, addrPort)
-	} else if isMinikube {
-		hostPort, network = detectMinikube(cjs, addrPort, clusterName)
-	}
-	if hostPort != "" {
-		server.Host = hostPort
-		cl.Server = server.String()
-	}
-	if network != "" {
-		dcName := daemonID.ContainerName()
-		if err = cli.NetworkConnect(ctx, network, dcName, nil); err != nil {
-			if !strings.Contains(err.Error(), "already exists") {
-				dlog.Debugf(ctx, "failed to connect network %s to container %s: %v", network, dcName, err)
-			}
-		}
-	}
+	// Concatenate using "/". This will be used in linux
+	cr.KubeFlags["kubeconfig"] = fmt.Sprintf("%s/%s/%s", dockerTpCache, kubeConfigs, kubeConfigFile)
 	return nil
 }
 
@@ -420,18 +393,24 @@ func LaunchDaemon(ctx context.Context, daemonID *daemon.Identifier) (conn *grpc.
 		return nil, errcat.NoDaemonLogs.New(err)
 	}
 	args := DaemonArgs(daemonID, addr.Port)
+	engine, err := GetEngine(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var dockerHost string
+	if engine.Remote {
+		dockerHost = engine.Host
+	}
 
-	allArgs := make([]string, 0, len(opts)+len(args)+4)
-	allArgs = append(allArgs,
-		"run",
-		"--rm",
-		"-d",
-	)
+	// The container is created first, so that the configuration can be copied into it and stdin can be
+	// attached before it starts.
+	allArgs := make([]string, 0, len(opts)+len(args)+3)
+	allArgs = append(allArgs, "create", "--rm")
 	allArgs = append(allArgs, opts...)
 	allArgs = append(allArgs, image)
 	allArgs = append(allArgs, args...)
 	for i := 1; ; i++ {
-		_, err = tryLaunch(ctx, daemonID, addr.Port, allArgs)
+		_, err = tryLaunch(ctx, daemonID, addr.Port, dockerHost, allArgs)
 		if err != nil {
 			if i < 6 && strings.Contains(err.Error(), "already in use by container") {
 				// This may happen if the daemon has died (and hence, we never discovered it), but
@@ -444,91 +423,13 @@ func LaunchDaemon(ctx context.Context, daemonID *daemon.Identifier) (conn *grpc.
 		}
 		break
 	}
-	if conn, err = connectDaemon(ctx, daemonID, addr.String()); err != nil {
+	if conn, err = connectDaemon(ctx, daemonID, addr.String(), dockerHost); err != nil {
 		return nil, err
 	}
 	return conn, nil
 }
 
-// containerPort returns the port that the container uses internally to expose the given
-// addrPort on the host. An empty string is returned when the addrPort is not found among
-// the container's port bindings.
-func containerPort(addrPort netip.AddrPort, ns *types.NetworkSettings) string {
-	for port, bindings := range ns.Ports {
-		for _, binding := range bindings {
-			addr, err := netip.ParseAddr(binding.HostIP)
-			if err != nil {
-				continue
-			}
-			pn, err := strconv.ParseUint(binding.HostPort, 10, 16)
-			if err != nil {
-				continue
-			}
-			if netip.AddrPortFrom(addr, uint16(pn)) == addrPort {
-				return port.Port()
-			}
-		}
-	}
-	return ""
-}
-
-// runningContainers returns the inspect data for all containers with status=running.
-func runningContainers(ctx context.Context, cli dockerClient.APIClient) []types.ContainerJSON {
-	cl, err := cli.ContainerList(ctx, types.ContainerListOptions{
-		Filters: filters.NewArgs(filters.KeyValuePair{Key: "status", Value: "running"}),
-	})
-	if err != nil {
-		dlog.Errorf(ctx, "failed to list containers: %v", err)
-		return nil
-	}
-	cjs := make([]types.ContainerJSON, 0, len(cl))
-	for _, cn := range cl {
-		cj, err := cli.ContainerInspect(ctx, cn.ID)
-		if err != nil {
-			dlog.Errorf(ctx, "container inspect on %v failed: %v", cn.Names, err)
-		} else {
-			cjs = append(cjs, cj)
-		}
-	}
-	return cjs
-}
-
-// detectMinikube returns the container IP:port for the given hostAddrPort for a container where the
-// "name.minikube.sigs.k8s.io" label is equal to the given cluster name.
-// Returns the internal IP:port for the given hostAddrPort and the name of a network that makes the
-// IP available.
-func detectMinikube(cns []types.ContainerJSON, hostAddrPort netip.AddrPort, clusterName string) (string, string) {
-	for _, cn := range cns {
-		if cfg, ns := cn.Config, cn.NetworkSettings; cfg != nil && ns != nil && cfg.Labels["name.minikube.sigs.k8s.io"] == clusterName {
-			if port := containerPort(hostAddrPort, ns); port != "" {
-				for networkName, network := range ns.Networks {
-					return net.JoinHostPort(network.IPAddress, port), networkName
-				}
-			}
-		}
-	}
-	return "", ""
-}
-
-// detectKind returns the container hostname:port for the given hostAddrPort for a container where the
-// "io.x-k8s.kind.role" label is equal to "control-plane".
-// Returns the internal hostname:port for the given hostAddrPort and the name of a network that makes the
-// hostname available.
-func detectKind(cns []types.ContainerJSON, hostAddrPort netip.AddrPort) (string, string) {
-	for _, cn := range cns {
-		if cfg, ns := cn.Config, cn.NetworkSettings; cfg != nil && ns != nil && cfg.Labels["io.x-k8s.kind.role"] == "control-plane" {
-			if port := containerPort(hostAddrPort, ns); port != "" {
-				hostPort := net.JoinHostPort(cfg.Hostname, port)
-				for networkName := range ns.Networks {
-					return hostPort, networkName
-				}
-			}
-		}
-	}
-	return "", ""
-}
-
-func tryLaunch(ctx context.Context, daemonID *daemon.Identifier, port int, args []string) (string, error) {
+func tryLaunch(ctx context.Context, daemonID *daemon.Identifier, port int, dockerHost string, args []string) (string, error) {
 	stdErr := bytes.Buffer{}
 	stdOut := bytes.Buffer{}
 	dlog.Debug(ctx, shellquote.ShellString("docker", args))
@@ -544,10 +445,14 @@ func tryLaunch(ctx context.Context, daemonID *daemon.Identifier, port int, args
 		return "", fmt.Errorf("launch of daemon container failed: %s", errStr)
 	}
 	cid := strings.TrimSpace(stdOut.String())
+	if err := startDaemon(ctx, cid, dockerHost != ""); err != nil {
+		return "", err
+	}
 	return cid, daemon.SaveInfo(ctx,
 		&daemon.Info{
 			Options:     map[string]string{"cid": cid},
 			InDocker:    true,
+			DockerHost:  dockerHost,
 			DaemonPort:  port,
 			Name:        daemonID.Name,
 			KubeContext: daemonID.KubeContext,
@@ -555,6 +460,43 @@ func tryLaunch(ctx context.Context, daemonID *daemon.Identifier, port int, args
 		}, daemonID.InfoFileName())
 }
 
+// startDaemon starts the created daemon container with the given ID and writes the key used to encrypt secret
+// cache files to its stdin, so that the key is neither visible in the container's environment nor stored in its
+// filesystem. The configuration is copied into the container first when the docker engine is remote.
+func startDaemon(ctx context.Context, cid string, remote bool) (err error) {
+	cli, err := GetClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			// A container that never started isn't removed by --rm.
+			_ = cli.ContainerRemove(ctx, cid, types.ContainerRemoveOptions{Force: true})
+		}
+	}()
+	if remote {
+		if err = copyToContainer(ctx, cid, filelocation.AppUserConfigDir(ctx), dockerTpConfig); err != nil {
+			return fmt.Errorf("unable to copy the configuration to the daemon container: %w", err)
+		}
+	}
+	key, err := atrest.EncodedDataKey(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get the cache encryption key: %w", err)
+	}
+	hr, err := cli.ContainerAttach(ctx, cid, types.ContainerAttachOptions{Stream: true, Stdin: true})
+	if err != nil {
+		return fmt.Errorf("unable to attach to the daemon container: %w", err)
+	}
+	defer hr.Close()
+	if err = cli.ContainerStart(ctx, cid, types.ContainerStartOptions{}); err != nil {
+		return fmt.Errorf("launch of daemon container failed: %w", err)
+	}
+	if _, err = io.WriteString(hr.Conn, key+"\n"); err != nil {
+		return fmt.Errorf("unable to pass the cache encryption key to the daemon container: %w", err)
+	}
+	return hr.CloseWrite()
+}
+
 // CancelWhenRmFromCache watches for the file to be removed from the cache, then calls cancel.
 func CancelWhenRmFromCache(ctx context.Context, cancel context.CancelFunc, filename string) error {
 	return daemon.WatchInfos(ctx, func(ctx context.Context) error {
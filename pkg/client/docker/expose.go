@@ -0,0 +1,84 @@
+package docker
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+// ExposeAPI is the container port of an --expose value that stands for the port of the connector's API.
+const ExposeAPI = "api"
+
+// publishOption returns the value of the docker run --publish option for the given value of the --expose flag.
+// The value has the form [<host-ip>:][<host-port>:]<container-port>[/<protocol>], where the host port defaults to
+// the container port and the host IP defaults to 127.0.0.1, so that nothing is published beyond the host unless
+// explicitly asked for. The container port ExposeAPI is replaced with the given port of the connector's API.
+func publishOption(expose string, apiPort int) (string, error) {
+	badFormat := func(reason string) error {
+		return errcat.User.Newf("invalid --expose %q: %s. The format is [<host-ip>:][<host-port>:]<container-port>[/tcp|/udp]", expose, reason)
+	}
+	spec, proto, ok := strings.Cut(expose, "/")
+	if ok {
+		if proto != "tcp" && proto != "udp" {
+			return "", badFormat("the protocol must be tcp or udp")
+		}
+	} else {
+		proto = "tcp"
+	}
+
+	hostIP := "127.0.0.1"
+	if strings.HasPrefix(spec, "[") {
+		// Bracketed IPv6 host IP
+		end := strings.Index(spec, "]:")
+		if end < 0 {
+			return "", badFormat("unterminated IPv6 address")
+		}
+		hostIP = spec[1:end]
+		spec = spec[end+2:]
+		if !strings.Contains(spec, ":") {
+			return "", badFormat("a host IP requires a host port")
+		}
+	}
+	parts := strings.Split(spec, ":")
+	switch len(parts) {
+	case 1:
+		parts = []string{parts[0], parts[0]}
+	case 2:
+	case 3:
+		hostIP = parts[0]
+		parts = parts[1:]
+	default:
+		return "", badFormat("too many colons")
+	}
+	ip, err := netip.ParseAddr(hostIP)
+	if err != nil {
+		return "", badFormat(fmt.Sprintf("%q is not an IP address", hostIP))
+	}
+	containerPort := parts[1]
+	if containerPort == ExposeAPI {
+		if proto != "tcp" {
+			return "", badFormat("the API port uses tcp")
+		}
+		if parts[0] == ExposeAPI {
+			return "", badFormat("the API port must be published on an explicit host port")
+		}
+		containerPort = strconv.Itoa(apiPort)
+	} else if err = checkPort(containerPort); err != nil {
+		return "", badFormat("container port " + err.Error())
+	}
+	if err = checkPort(parts[0]); err != nil {
+		return "", badFormat("host port " + err.Error())
+	}
+	return fmt.Sprintf("%s:%s/%s", net.JoinHostPort(ip.String(), parts[0]), containerPort, proto), nil
+}
+
+func checkPort(s string) error {
+	if p, err := strconv.ParseUint(s, 10, 16); err != nil || p == 0 {
+		return fmt.Errorf("%q is not a number between 1 and 65535", s)
+	}
+	return nil
+}
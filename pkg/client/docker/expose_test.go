@@ -0,0 +1,41 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishOption(t *testing.T) {
+	for expose, want := range map[string]string{
+		"8080":                "127.0.0.1:8080:8080/tcp",
+		"9000:8080":           "127.0.0.1:9000:8080/tcp",
+		"9000:8080/udp":       "127.0.0.1:9000:8080/udp",
+		"0.0.0.0:9000:8080":   "0.0.0.0:9000:8080/tcp",
+		"[::1]:9000:8080":     "[::1]:9000:8080/tcp",
+		"9090:api":            "127.0.0.1:9090:5555/tcp",
+		"10.0.0.1:9090:api":   "10.0.0.1:9090:5555/tcp",
+		"[fe80::1]:53:53/udp": "[fe80::1]:53:53/udp",
+	} {
+		got, err := publishOption(expose, 5555)
+		require.NoError(t, err, expose)
+		assert.Equal(t, want, got, expose)
+	}
+	for _, expose := range []string{
+		"",
+		"api",
+		"9090:api/udp",
+		"0",
+		"70000",
+		"x:80",
+		"80/sctp",
+		"localhost:80:80",
+		"1:2:3:4",
+		"[::1]:80",
+		"[::1:80:80",
+	} {
+		_, err := publishOption(expose, 5555)
+		assert.Error(t, err, expose)
+	}
+}
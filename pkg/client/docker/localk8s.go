@@ -0,0 +1,276 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	dockerClient "github.com/docker/docker/client"
+	runtime2 "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
+)
+
+// LocalCluster identifies a provider of clusters that run on the local docker engine, or in the VM of
+// that engine. The API server of such a cluster is exposed on the host using an address that the daemon
+// container can't use, so the address must be rewritten.
+type LocalCluster string
+
+const (
+	NoLocalCluster       LocalCluster = ""
+	Kind                 LocalCluster = "kind"
+	K3d                  LocalCluster = "k3d"
+	Minikube             LocalCluster = "minikube"
+	DockerDesktopCluster LocalCluster = "Docker Desktop"
+)
+
+// dockerDesktopAPIServer is the name that the API server certificate of Docker Desktop's cluster is issued for.
+const dockerDesktopAPIServer = "kubernetes.docker.internal"
+
+// DetectLocalCluster returns the provider of the given cluster, or NoLocalCluster when it isn't a local cluster.
+func DetectLocalCluster(clusterName string, cl *api.Cluster) LocalCluster {
+	switch {
+	case strings.HasPrefix(clusterName, "kind-"):
+		return Kind
+	case strings.HasPrefix(clusterName, "k3d-"):
+		return K3d
+	case clusterName == "docker-desktop" || clusterName == "docker-for-desktop":
+		return DockerDesktopCluster
+	}
+	if ex, ok := cl.Extensions["cluster_info"].(*runtime2.Unknown); ok {
+		var data map[string]any
+		if json.Unmarshal(ex.Raw, &data) == nil && data["provider"] == "minikube.sigs.k8s.io" {
+			return Minikube
+		}
+	}
+	return NoLocalCluster
+}
+
+// isAPIServerNode returns true if a container with the given labels runs the API server of the cluster
+// with the given name.
+func (lc LocalCluster) isAPIServerNode(labels map[string]string, clusterName string) bool {
+	switch lc {
+	case Kind:
+		// Older versions of kind don't label the containers with the cluster name.
+		name, ok := labels["io.x-k8s.kind.cluster"]
+		return labels["io.x-k8s.kind.role"] == "control-plane" && (!ok || name == strings.TrimPrefix(clusterName, "kind-"))
+	case K3d:
+		role := labels["k3d.role"]
+		return (role == "loadbalancer" || role == "server") && labels["k3d.cluster"] == strings.TrimPrefix(clusterName, "k3d-")
+	case Minikube:
+		return labels["name.minikube.sigs.k8s.io"] == clusterName
+	default:
+		return false
+	}
+}
+
+// internalHost returns the host that the daemon container uses to reach the API server in the given node
+// container. It must be a host that the API server certificate is issued for.
+func (lc LocalCluster) internalHost(cn *types.ContainerJSON, network *networkEndpoint) string {
+	switch lc {
+	case Kind:
+		return cn.Config.Hostname
+	case K3d:
+		// The certificate is issued for the name of the load balancer, which is also its hostname.
+		if cn.ContainerJSONBase != nil {
+			return strings.TrimPrefix(cn.Name, "/")
+		}
+		return cn.Config.Hostname
+	default:
+		return network.ip
+	}
+}
+
+type networkEndpoint struct {
+	name string
+	ip   string
+}
+
+// networks returns the networks of the given container, sorted by name so that the choice of network is
+// predictable.
+func networks(ns *types.NetworkSettings) []*networkEndpoint {
+	nws := make([]*networkEndpoint, 0, len(ns.Networks))
+	for name, nw := range ns.Networks {
+		if nw != nil {
+			nws = append(nws, &networkEndpoint{name: name, ip: nw.IPAddress})
+		}
+	}
+	sort.Slice(nws, func(i, j int) bool { return nws[i].name < nws[j].name })
+	return nws
+}
+
+// handleLocalK8s checks if the cluster is using a well known local provider, and if so, ensures that the
+// cluster's server is an address that the daemon container can reach, and that the container is connected
+// to the docker network of the cluster's nodes.
+func handleLocalK8s(ctx context.Context, daemonID *daemon.Identifier, clusterName string, cl *api.Cluster) error {
+	lc := DetectLocalCluster(clusterName, cl)
+	if lc == NoLocalCluster {
+		return nil
+	}
+	server, err := url.Parse(cl.Server)
+	if err != nil {
+		return err
+	}
+	if lc == DockerDesktopCluster {
+		return handleDockerDesktop(ctx, server, cl)
+	}
+	addrPort, err := serverAddrPort(server)
+	if err != nil {
+		return err
+	}
+
+	// Let's check if we have a container with port bindings for the
+	// given addrPort that is a known k8sapi provider
+	cli, err := GetClient(ctx)
+	if err != nil {
+		return err
+	}
+	hostPort, network := detectAPIServer(runningContainers(ctx, cli), lc, clusterName, addrPort)
+	if hostPort != "" {
+		dlog.Infof(ctx, "Using %s to reach the API server of the %s cluster %q", hostPort, lc, clusterName)
+		server.Host = hostPort
+		cl.Server = server.String()
+	}
+	if network != "" {
+		dcName := daemonID.ContainerName()
+		if err = cli.NetworkConnect(ctx, network, dcName, nil); err != nil {
+			if !strings.Contains(err.Error(), "already exists") {
+				dlog.Debugf(ctx, "failed to connect network %s to container %s: %v", network, dcName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// handleDockerDesktop rewrites the server of Docker Desktop's cluster, which is exposed on the host's loopback
+// address, so that the daemon container reaches it using the host.
+func handleDockerDesktop(ctx context.Context, server *url.URL, cl *api.Cluster) error {
+	engine, err := GetEngine(ctx)
+	if err != nil {
+		return err
+	}
+	if engine.VM != DockerDesktop {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(server.Host)
+	if err != nil {
+		return err
+	}
+	if host != dockerDesktopAPIServer && host != "localhost" && !isLoopback(host) {
+		return nil
+	}
+	server.Host = net.JoinHostPort("host.docker.internal", port)
+	cl.Server = server.String()
+	if cl.TLSServerName == "" && !cl.InsecureSkipTLSVerify {
+		cl.TLSServerName = dockerDesktopAPIServer
+	}
+	dlog.Infof(ctx, "Using %s to reach the API server of the %s cluster", server.Host, DockerDesktopCluster)
+	return nil
+}
+
+func isLoopback(host string) bool {
+	addr, err := netip.ParseAddr(host)
+	return err == nil && addr.IsLoopback()
+}
+
+// serverAddrPort returns the address and port of the given server URL. The address "localhost" is
+// returned as 127.0.0.1.
+func serverAddrPort(server *url.URL) (netip.AddrPort, error) {
+	host, portStr, err := net.SplitHostPort(server.Host)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		if host == "localhost" {
+			addr = netip.AddrFrom4([4]byte{127, 0, 0, 1})
+		}
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return netip.AddrPort{}, err
+	}
+	return netip.AddrPortFrom(addr, uint16(port)), nil
+}
+
+// detectAPIServer finds the container that runs the API server of the given cluster. When the given
+// addrPort is the IP of the container in a docker network (e.g. the node IP of minikube on Linux), only
+// that network is returned, because the server can be used as is once the daemon container is connected
+// to it. When the addrPort is bound to a port of the container, the internal host:port of that port is
+// returned together with the name of a network that makes the host available.
+func detectAPIServer(cns []types.ContainerJSON, lc LocalCluster, clusterName string, addrPort netip.AddrPort) (string, string) {
+	for i := range cns {
+		cn := &cns[i]
+		cfg, ns := cn.Config, cn.NetworkSettings
+		if cfg == nil || ns == nil || !lc.isAPIServerNode(cfg.Labels, clusterName) {
+			continue
+		}
+		nws := networks(ns)
+		for _, nw := range nws {
+			if ip, err := netip.ParseAddr(nw.ip); err == nil && ip == addrPort.Addr() {
+				return "", nw.name
+			}
+		}
+		if port := containerPort(addrPort, ns); port != "" && len(nws) > 0 {
+			return net.JoinHostPort(lc.internalHost(cn, nws[0]), port), nws[0].name
+		}
+	}
+	return "", ""
+}
+
+// containerPort returns the port that the container uses internally to expose the given
+// addrPort on the host. An empty string is returned when the addrPort is not found among
+// the container's port bindings. A binding to the unspecified address, which is what k3d
+// uses by default, matches all loopback and unspecified addresses.
+func containerPort(addrPort netip.AddrPort, ns *types.NetworkSettings) string {
+	for port, bindings := range ns.Ports {
+		for _, binding := range bindings {
+			pn, err := strconv.ParseUint(binding.HostPort, 10, 16)
+			if err != nil || uint16(pn) != addrPort.Port() {
+				continue
+			}
+			addr, err := netip.ParseAddr(binding.HostIP)
+			if err != nil {
+				if binding.HostIP != "" {
+					continue
+				}
+				addr = netip.IPv4Unspecified()
+			}
+			if addr == addrPort.Addr() ||
+				addr.IsUnspecified() && (addrPort.Addr().IsLoopback() || addrPort.Addr().IsUnspecified()) {
+				return port.Port()
+			}
+		}
+	}
+	return ""
+}
+
+// runningContainers returns the inspect data for all containers with status=running.
+func runningContainers(ctx context.Context, cli dockerClient.APIClient) []types.ContainerJSON {
+	cl, err := cli.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(filters.KeyValuePair{Key: "status", Value: "running"}),
+	})
+	if err != nil {
+		dlog.Errorf(ctx, "failed to list containers: %v", err)
+		return nil
+	}
+	cjs := make([]types.ContainerJSON, 0, len(cl))
+	for _, cn := range cl {
+		cj, err := cli.ContainerInspect(ctx, cn.ID)
+		if err != nil {
+			dlog.Errorf(ctx, "container inspect on %v failed: %v", cn.Names, err)
+		} else {
+			cjs = append(cjs, cj)
+		}
+	}
+	return cjs
+}
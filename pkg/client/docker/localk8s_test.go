@@ -0,0 +1,93 @@
+package docker
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/assert"
+	runtime2 "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestDetectLocalCluster(t *testing.T) {
+	minikube := &api.Cluster{Extensions: map[string]runtime2.Object{
+		"cluster_info": &runtime2.Unknown{Raw: []byte(`{"provider":"minikube.sigs.k8s.io","version":"v1.30.1"}`)},
+	}}
+	tests := []struct {
+		name    string
+		cluster *api.Cluster
+		want    LocalCluster
+	}{
+		{"kind-dev", &api.Cluster{}, Kind},
+		{"k3d-dev", &api.Cluster{}, K3d},
+		{"docker-desktop", &api.Cluster{}, DockerDesktopCluster},
+		{"dev", minikube, Minikube},
+		{"gke_project_zone_dev", &api.Cluster{}, NoLocalCluster},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, DetectLocalCluster(tt.name, tt.cluster))
+		})
+	}
+}
+
+func nodeContainer(name, hostname string, labels map[string]string, hostIP, hostPort, port string, networks map[string]string) types.ContainerJSON {
+	nws := make(map[string]*network.EndpointSettings, len(networks))
+	for n, ip := range networks {
+		nws[n] = &network.EndpointSettings{IPAddress: ip}
+	}
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{Name: "/" + name},
+		Config:            &container.Config{Hostname: hostname, Labels: labels},
+		NetworkSettings: &types.NetworkSettings{
+			NetworkSettingsBase: types.NetworkSettingsBase{Ports: nat.PortMap{
+				nat.Port(port + "/tcp"): []nat.PortBinding{{HostIP: hostIP, HostPort: hostPort}},
+			}},
+			Networks: nws,
+		},
+	}
+}
+
+func TestDetectAPIServer(t *testing.T) {
+	cns := []types.ContainerJSON{
+		nodeContainer("other-control-plane", "other-control-plane",
+			map[string]string{"io.x-k8s.kind.role": "control-plane", "io.x-k8s.kind.cluster": "other"},
+			"127.0.0.1", "40001", "6443", map[string]string{"kind": "172.18.0.3"}),
+		nodeContainer("dev-control-plane", "dev-control-plane",
+			map[string]string{"io.x-k8s.kind.role": "control-plane", "io.x-k8s.kind.cluster": "dev"},
+			"127.0.0.1", "40000", "6443", map[string]string{"kind": "172.18.0.2"}),
+		nodeContainer("k3d-dev-serverlb", "a1b2c3",
+			map[string]string{"k3d.role": "loadbalancer", "k3d.cluster": "dev"},
+			"0.0.0.0", "40002", "6443", map[string]string{"k3d-dev": "172.19.0.3"}),
+		nodeContainer("minikube", "minikube",
+			map[string]string{"name.minikube.sigs.k8s.io": "minikube"},
+			"127.0.0.1", "40003", "8443", map[string]string{"minikube": "192.168.49.2"}),
+	}
+	tests := []struct {
+		name        string
+		lc          LocalCluster
+		clusterName string
+		server      string
+		wantHost    string
+		wantNetwork string
+	}{
+		{"kind", Kind, "kind-dev", "127.0.0.1:40000", "dev-control-plane:6443", "kind"},
+		{"kind other port", Kind, "kind-dev", "127.0.0.1:40001", "", ""},
+		{"k3d unspecified", K3d, "k3d-dev", "0.0.0.0:40002", "k3d-dev-serverlb:6443", "k3d-dev"},
+		{"k3d loopback", K3d, "k3d-dev", "127.0.0.1:40002", "k3d-dev-serverlb:6443", "k3d-dev"},
+		{"minikube port", Minikube, "minikube", "127.0.0.1:40003", "192.168.49.2:8443", "minikube"},
+		{"minikube node IP", Minikube, "minikube", "192.168.49.2:8443", "", "minikube"},
+		{"unknown", Minikube, "other", "127.0.0.1:40003", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			host, network := detectAPIServer(cns, tt.lc, tt.clusterName, netip.MustParseAddrPort(tt.server))
+			assert.Equal(t, tt.wantHost, host)
+			assert.Equal(t, tt.wantNetwork, network)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/cli/cli/connhelper/commandconn"
+	"github.com/docker/cli/cli/connhelper/ssh"
+	"github.com/docker/docker/api/types"
+	"google.golang.org/grpc"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+// remoteCacheVolume is the docker volume that replaces the mount of the cache directory when the
+// engine is remote. It outlives the daemon container, so the caches survive a restart.
+const remoteCacheVolume = "telepresence-cache"
+
+// isRemoteHost returns true when the given docker host is on another machine, reached using ssh. The
+// containers of such an engine can't mount directories of this machine, and the ports that they
+// publish are not published on this machine.
+func isRemoteHost(host string) bool {
+	return strings.HasPrefix(host, "ssh://")
+}
+
+// sshTunnelArgs returns the arguments to ssh that connect its stdio to the given address on the remote host.
+func sshTunnelArgs(host, address string) ([]string, error) {
+	sp, err := ssh.ParseURL(host)
+	if err != nil {
+		return nil, errcat.User.Newf("invalid docker host %q: %v", host, err)
+	}
+	h, p, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if h == "" {
+		h = "127.0.0.1"
+	}
+	return append([]string{"-W", net.JoinHostPort(h, p)}, sp.Args()...), nil
+}
+
+// remoteDialOption returns a dial option that reaches the daemon's port on the given remote docker host
+// through ssh. The daemon publishes its port on the remote host's loopback interface.
+func remoteDialOption(host string) grpc.DialOption {
+	return grpc.WithContextDialer(func(ctx context.Context, address string) (net.Conn, error) {
+		args, err := sshTunnelArgs(host, address)
+		if err != nil {
+			return nil, err
+		}
+		return commandconn.New(ctx, "ssh", args...)
+	})
+}
+
+// copyToContainer copies the content of the directory src into the directory dst of the given container.
+// Nothing is copied when src doesn't exist.
+func copyToContainer(ctx context.Context, container, src, dst string) error {
+	archive, err := tarDir(src, dst)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	cli, err := GetClient(ctx)
+	if err != nil {
+		return err
+	}
+	return cli.CopyToContainer(ctx, container, "/", archive, types.CopyToContainerOptions{})
+}
+
+// tarDir returns a tar archive with the content of the directory src, with names that place it in the absolute
+// directory dst when extracted at "/". The archive includes the directories of dst.
+func tarDir(src, dst string) (*bytes.Buffer, error) {
+	if _, err := os.Stat(src); err != nil {
+		return nil, err
+	}
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	dst = strings.Trim(path.Clean(dst), "/")
+	parts := strings.Split(dst, "/")
+	for i := range parts {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: path.Join(parts[:i+1]...) + "/", Mode: 0o755}); err != nil {
+			return nil, err
+		}
+	}
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == src {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		name := path.Join(dst, filepath.ToSlash(rel))
+		switch {
+		case d.IsDir():
+			return tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name + "/", Mode: 0o755})
+		case d.Type().IsRegular():
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			if err = tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o600, Size: int64(len(data))}); err != nil {
+				return err
+			}
+			_, err = tw.Write(data)
+			return err
+		default:
+			// Sockets, links, and other special files are of no use in the container.
+			return nil
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
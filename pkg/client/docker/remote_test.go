@@ -0,0 +1,66 @@
+package docker
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSHTunnelArgs(t *testing.T) {
+	assert.True(t, isRemoteHost("ssh://build-box"))
+	assert.False(t, isRemoteHost("unix:///var/run/docker.sock"))
+
+	args, err := sshTunnelArgs("ssh://dev@build-box:2222", ":43210")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-W", "127.0.0.1:43210", "-l", "dev", "-p", "2222", "--", "build-box"}, args)
+
+	args, err = sshTunnelArgs("ssh://build-box", "10.0.0.1:80")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-W", "10.0.0.1:80", "--", "build-box"}, args)
+
+	_, err = sshTunnelArgs("ssh://build-box/path", ":80")
+	assert.Error(t, err)
+}
+
+func TestTarDir(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "config.yml"), []byte("timeouts: {}\n"), 0o600))
+	require.NoError(t, os.Mkdir(filepath.Join(src, "sub"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "sub", "x"), []byte("x"), 0o600))
+
+	buf, err := tarDir(src, "/root/.config/telepresence")
+	require.NoError(t, err)
+	tr := tar.NewReader(buf)
+	var names []string
+	content := make(map[string]string)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, h.Name)
+		if h.Typeflag == tar.TypeReg {
+			data, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			content[h.Name] = string(data)
+		}
+	}
+	assert.Equal(t, []string{
+		"root/",
+		"root/.config/",
+		"root/.config/telepresence/",
+		"root/.config/telepresence/config.yml",
+		"root/.config/telepresence/sub/",
+		"root/.config/telepresence/sub/x",
+	}, names)
+	assert.Equal(t, "timeouts: {}\n", content["root/.config/telepresence/config.yml"])
+
+	_, err = tarDir(filepath.Join(src, "missing"), "/x")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
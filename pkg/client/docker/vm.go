@@ -0,0 +1,201 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/docker/docker/client"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+// EngineVM identifies the virtual machine that a docker engine runs in.
+type EngineVM string
+
+const (
+	NoVM           EngineVM = ""
+	DockerDesktop  EngineVM = "Docker Desktop"
+	Colima         EngineVM = "Colima"
+	Lima           EngineVM = "Lima"
+	RancherDesktop EngineVM = "Rancher Desktop"
+	WSL2           EngineVM = "WSL2"
+)
+
+// limaHostAddress is the address that a Lima VM, and hence a Colima VM, uses to reach the host.
+const limaHostAddress = "192.168.5.2"
+
+// Engine describes the docker engine that the docker client talks to.
+type Engine struct {
+	// VM is the virtual machine that the engine runs in, or NoVM when it runs natively on the host.
+	VM EngineVM
+
+	// Host is the address of the engine's API, e.g. unix:///var/run/docker.sock.
+	Host string
+
+	// Remote is true when the engine runs on another machine, see isRemoteHost.
+	Remote bool
+
+	OperatingSystem string
+	KernelVersion   string
+
+	goos string
+	home string
+}
+
+// String returns a short human-readable description of the engine.
+func (e *Engine) String() string {
+	vm := "native"
+	switch {
+	case e.Remote:
+		vm = "remote"
+	case e.VM != NoVM:
+		vm = string(e.VM)
+	}
+	return fmt.Sprintf("%s (%s, kernel %s) at %s", vm, e.OperatingSystem, e.KernelVersion, e.Host)
+}
+
+func detectEngine(ctx context.Context, cli *client.Client, host string) (*Engine, error) {
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve docker engine info: %w", err)
+	}
+	remote := isRemoteHost(host)
+	vm := NoVM
+	if !remote {
+		// The VM of a remote engine is of no concern, because it doesn't share anything with this machine.
+		vm = detectVM(info.Name, info.OperatingSystem, info.KernelVersion, host)
+	}
+	return &Engine{
+		VM:              vm,
+		Host:            host,
+		Remote:          remote,
+		OperatingSystem: info.OperatingSystem,
+		KernelVersion:   info.KernelVersion,
+		goos:            runtime.GOOS,
+		home:            filelocation.UserHomeDir(ctx),
+	}, nil
+}
+
+// detectVM determines the VM of an engine from the name, operating system, and kernel version that the engine
+// reports, and the host used to reach it.
+func detectVM(name, operatingSystem, kernelVersion, host string) EngineVM {
+	name = strings.ToLower(name)
+	kernelVersion = strings.ToLower(kernelVersion)
+	host = filepath.ToSlash(host)
+	switch {
+	case strings.Contains(operatingSystem, "Docker Desktop"):
+		return DockerDesktop
+	case strings.Contains(operatingSystem, "Rancher Desktop") || strings.Contains(name, "rancher-desktop") || strings.Contains(host, "/.rd/"):
+		return RancherDesktop
+	case name == "colima" || strings.HasPrefix(name, "colima-") || strings.Contains(host, "/.colima/"):
+		return Colima
+	case strings.HasPrefix(name, "lima-") || strings.Contains(host, "/.lima/"):
+		return Lima
+	case strings.Contains(kernelVersion, "microsoft") || strings.Contains(kernelVersion, "wsl2"):
+		return WSL2
+	default:
+		return NoVM
+	}
+}
+
+// vmSockets returns the sockets that engines running in a VM listen to, in order of preference.
+func vmSockets(home string) []string {
+	return []string{
+		filepath.Join(home, ".docker", "run", "docker.sock"),
+		filepath.Join(home, ".docker", "desktop", "docker.sock"),
+		filepath.Join(home, ".colima", "default", "docker.sock"),
+		filepath.Join(home, ".colima", "docker.sock"),
+		filepath.Join(home, ".rd", "docker.sock"),
+		filepath.Join(home, ".lima", "docker", "sock", "docker.sock"),
+	}
+}
+
+// vmHost returns the host of an engine running in a VM when the given host is the default unix socket and
+// that socket doesn't exist. Engines running in a VM don't always create the default socket, and when they
+// do, it might be a stale link that points to an engine that is no longer running.
+func vmHost(ctx context.Context, host string) string {
+	if runtime.GOOS == "windows" || os.Getenv(client.EnvOverrideHost) != "" {
+		return ""
+	}
+	if host != "" && host != client.DefaultDockerHost {
+		return ""
+	}
+	if _, err := os.Stat(strings.TrimPrefix(client.DefaultDockerHost, "unix://")); err == nil {
+		return ""
+	}
+	for _, s := range vmSockets(filelocation.UserHomeDir(ctx)) {
+		if _, err := os.Stat(s); err == nil {
+			return "unix://" + s
+		}
+	}
+	return ""
+}
+
+// HostGateway returns the address that containers use to reach the host. It is empty when the engine
+// provides host.docker.internal by itself.
+func (e *Engine) HostGateway() string {
+	if e.Remote {
+		return "host-gateway"
+	}
+	switch e.VM {
+	case DockerDesktop, RancherDesktop:
+		return ""
+	case Colima, Lima:
+		// The host-gateway of a Lima VM is the VM itself, not the host.
+		return limaHostAddress
+	default:
+		if e.goos == "linux" {
+			return "host-gateway"
+		}
+		return ""
+	}
+}
+
+// sharedDirs returns the directories of the host that the VM shares with the engine by default, or nil when
+// all directories are available.
+func (e *Engine) sharedDirs() []string {
+	switch e.VM {
+	case Colima:
+		return []string{e.home, "/tmp/colima"}
+	case Lima:
+		return []string{e.home, "/tmp/lima"}
+	case RancherDesktop:
+		if e.goos == "darwin" {
+			return []string{e.home, "/Volumes", "/var/folders", "/tmp/rancher-desktop"}
+		}
+	case DockerDesktop:
+		if e.goos == "darwin" {
+			return []string{"/Users", "/Volumes", "/private", "/tmp", "/var/folders"}
+		}
+	}
+	return nil
+}
+
+// MountSource returns the path that the engine uses for the given directory of the host when the directory
+// is mounted into a container. An engine running natively in WSL2 sees the drives of a Windows host under /mnt.
+func (e *Engine) MountSource(dir string) string {
+	if e.VM == WSL2 && e.goos == "windows" && len(dir) >= 2 && dir[1] == ':' {
+		return "/mnt/" + strings.ToLower(dir[:1]) + strings.ReplaceAll(dir[2:], `\`, "/")
+	}
+	return dir
+}
+
+// MountProblem returns a description of why the given directory of the host can't be mounted into a
+// container, or an empty string when it can.
+func (e *Engine) MountProblem(dir string) string {
+	shared := e.sharedDirs()
+	if shared == nil {
+		return ""
+	}
+	for _, s := range shared {
+		if dir == s || strings.HasPrefix(dir, s+"/") {
+			return ""
+		}
+	}
+	return fmt.Sprintf("%s is not shared with the %s VM, so containers will see an empty directory. Add it to the VM's mounts, or use a directory below one of %s",
+		dir, e.VM, strings.Join(shared, ", "))
+}
@@ -0,0 +1,52 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectVM(t *testing.T) {
+	tests := []struct {
+		name, os, kernel, host string
+		want                   EngineVM
+	}{
+		{"docker-desktop", "Docker Desktop", "5.15.49-linuxkit", "unix:///Users/me/.docker/run/docker.sock", DockerDesktop},
+		{"docker-desktop", "Docker Desktop", "5.15.90.1-microsoft-standard-WSL2", "npipe:////./pipe/docker_engine", DockerDesktop},
+		{"colima", "Ubuntu 23.04", "6.2.0-39-generic", "unix:///Users/me/.colima/default/docker.sock", Colima},
+		{"colima-work", "Ubuntu 23.04", "6.2.0-39-generic", "tcp://127.0.0.1:2375", Colima},
+		{"lima-rancher-desktop", "Alpine Linux v3.18", "6.1.64-0-virt", "unix:///Users/me/.rd/docker.sock", RancherDesktop},
+		{"lima-docker", "Ubuntu 22.04", "5.15.0-91-generic", "unix:///Users/me/.lima/docker/sock/docker.sock", Lima},
+		{"desktop", "Ubuntu 22.04", "5.15.133.1-microsoft-standard-WSL2", "unix:///var/run/docker.sock", WSL2},
+		{"server", "Fedora Linux 39", "6.6.8-200.fc39.x86_64", "unix:///var/run/docker.sock", NoVM},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, detectVM(tt.name, tt.os, tt.kernel, tt.host), tt.name)
+	}
+}
+
+func TestEngineHostGateway(t *testing.T) {
+	assert.Equal(t, "host-gateway", (&Engine{goos: "linux"}).HostGateway())
+	assert.Equal(t, "", (&Engine{goos: "darwin"}).HostGateway())
+	assert.Equal(t, "", (&Engine{VM: DockerDesktop, goos: "linux"}).HostGateway())
+	assert.Equal(t, limaHostAddress, (&Engine{VM: Colima, goos: "darwin"}).HostGateway())
+	assert.Equal(t, "host-gateway", (&Engine{VM: WSL2, goos: "linux"}).HostGateway())
+}
+
+func TestEngineMounts(t *testing.T) {
+	e := &Engine{VM: WSL2, goos: "windows"}
+	assert.Equal(t, "/mnt/c/Users/me/AppData/Roaming/telepresence", e.MountSource(`C:\Users\me\AppData\Roaming\telepresence`))
+	e.goos = "linux"
+	assert.Equal(t, "/home/me/.config/telepresence", e.MountSource("/home/me/.config/telepresence"))
+	assert.Empty(t, e.MountProblem("/etc/telepresence"))
+
+	e = &Engine{VM: Colima, goos: "darwin", home: "/Users/me"}
+	assert.Empty(t, e.MountProblem("/Users/me/Library/Caches/telepresence"))
+	assert.Empty(t, e.MountProblem("/Users/me"))
+	assert.Contains(t, e.MountProblem("/Users/meme/cache"), "not shared with the Colima VM")
+	assert.Contains(t, e.MountProblem("/opt/telepresence"), "not shared with the Colima VM")
+
+	e = &Engine{VM: DockerDesktop, goos: "darwin"}
+	assert.Empty(t, e.MountProblem("/Users/me/Library/Caches/telepresence"))
+	assert.NotEmpty(t, e.MountProblem("/opt/telepresence"))
+}
@@ -24,6 +24,15 @@ type Env struct {
 	// The address that the user daemon is listening to (unless it is started by the client and uses a named pipe or unix socket).
 	UserDaemonAddress string `env:"TELEPRESENCE_USER_DAEMON_ADDRESS, parser=possibly-empty-string,default="`
 	ScoutDisable      bool   `env:"SCOUT_DISABLE, parser=strconv.ParseBool, default=0"`
+
+	// The directory of the user configuration.
+	ConfigDir string `env:"TELEPRESENCE_CONFIG_DIR, parser=possibly-empty-string,default="`
+
+	// These environment variables take precedence over the corresponding settings in the "paths" section of the config.
+	CacheDir         string `env:"TELEPRESENCE_CACHE_DIR,          parser=possibly-empty-string,default="`
+	LogDir           string `env:"TELEPRESENCE_LOG_DIR,            parser=possibly-empty-string,default="`
+	UserDaemonSocket string `env:"TELEPRESENCE_USER_DAEMON_SOCKET, parser=possibly-empty-string,default="`
+	RootDaemonSocket string `env:"TELEPRESENCE_ROOT_DAEMON_SOCKET, parser=possibly-empty-string,default="`
 }
 
 type envKey struct{}
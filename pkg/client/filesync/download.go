@@ -0,0 +1,186 @@
+package filesync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Source is the remote file system that files are downloaded from. All paths are absolute and slash separated.
+type Source interface {
+	// List returns the entries below the given directory, keyed by their slash separated path relative to the
+	// directory.
+	List(dir string) (map[string]Entry, error)
+
+	// Stat returns the entry of the given file or directory.
+	Stat(name string) (Entry, error)
+
+	// Glob returns the names of the files and directories that match the given pattern (see path.Match).
+	Glob(pattern string) ([]string, error)
+
+	// Get writes the content of the given file to the given writer.
+	Get(file string, w io.Writer) error
+}
+
+// ErrIsDir is returned by Download when a directory matches, and recursive is false.
+var ErrIsDir = errors.New("is a directory")
+
+// DownloadResult summarizes a download.
+type DownloadResult struct {
+	Files int
+	Bytes int64
+}
+
+// TooLargeError is returned by Download when the files to download are larger than the permitted size.
+type TooLargeError struct {
+	Size    int64
+	MaxSize int64
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("the files to copy are %d bytes, which exceeds the limit of %d bytes", e.Size, e.MaxSize)
+}
+
+type download struct {
+	remote string
+	local  string
+	entry  Entry
+}
+
+// Download copies the remote files and directories that match the given pattern (see path.Match) to the given
+// local path. A single match is copied to the local path, unless that's an existing directory, in which case it's
+// copied into it, and several matches are always copied into the local directory, which is created when necessary.
+// Directories are copied, along with their content, only when recursive is true. Nothing is copied when the total
+// size of the files exceeds maxSize, unless maxSize is zero, and a *TooLargeError is returned.
+func Download(ctx context.Context, src Source, pattern, local string, recursive bool, maxSize int64) (DownloadResult, error) {
+	var r DownloadResult
+	names := []string{pattern}
+	if strings.ContainsAny(pattern, `*?[\`) {
+		var err error
+		if names, err = src.Glob(pattern); err != nil {
+			return r, err
+		}
+		if len(names) == 0 {
+			return r, fmt.Errorf("no files match %s: %w", pattern, fs.ErrNotExist)
+		}
+		sort.Strings(names)
+	}
+
+	into := len(names) > 1
+	if fi, err := os.Stat(local); err == nil {
+		if fi.IsDir() {
+			into = true
+		} else if into {
+			return r, fmt.Errorf("%s is not a directory", local)
+		}
+	}
+
+	var dls []download
+	var size int64
+	for _, name := range names {
+		e, err := src.Stat(name)
+		if err != nil {
+			return r, err
+		}
+		dst := local
+		if into {
+			dst = filepath.Join(local, path.Base(name))
+		}
+		dls = append(dls, download{remote: name, local: dst, entry: e})
+		if !e.Dir {
+			size += e.Size
+			continue
+		}
+		if !recursive {
+			return r, fmt.Errorf("%s %w", name, ErrIsDir)
+		}
+		es, err := src.List(name)
+		if err != nil {
+			return r, err
+		}
+		rels := make([]string, 0, len(es))
+		for rel := range es {
+			rels = append(rels, rel)
+		}
+		// Parents sort before their children, so directories are created before their content.
+		sort.Strings(rels)
+		for _, rel := range rels {
+			ce := es[rel]
+			dls = append(dls, download{remote: path.Join(name, rel), local: filepath.Join(dst, filepath.FromSlash(rel)), entry: ce})
+			if !ce.Dir {
+				size += ce.Size
+			}
+		}
+	}
+	if maxSize > 0 && size > maxSize {
+		return r, &TooLargeError{Size: size, MaxSize: maxSize}
+	}
+
+	for _, dl := range dls {
+		if ctx.Err() != nil {
+			return r, ctx.Err()
+		}
+		if dl.entry.Dir {
+			if err := os.MkdirAll(dl.local, 0o755); err != nil {
+				return r, err
+			}
+			continue
+		}
+		n, err := get(src, dl)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// Removed remotely after the listing.
+				continue
+			}
+			return r, err
+		}
+		r.Files++
+		r.Bytes += n
+	}
+	return r, nil
+}
+
+func get(src Source, dl download) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(dl.local), 0o755); err != nil {
+		return 0, err
+	}
+	mode := dl.entry.Mode
+	if mode == 0 {
+		mode = 0o644
+	}
+	f, err := os.OpenFile(dl.local, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return 0, err
+	}
+	cw := &countingWriter{w: f}
+	err = src.Get(dl.remote, cw)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(dl.local)
+		return 0, err
+	}
+	if !dl.entry.ModTime.IsZero() {
+		_ = os.Chtimes(dl.local, dl.entry.ModTime, dl.entry.ModTime)
+	}
+	return cw.n, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
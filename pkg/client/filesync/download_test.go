@@ -0,0 +1,94 @@
+package filesync
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dirSource is a Source that reads from a local directory.
+type dirSource struct {
+	dirTarget
+}
+
+func (s *dirSource) Stat(name string) (Entry, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Dir: fi.IsDir(), Size: fi.Size(), Mode: fi.Mode().Perm(), ModTime: fi.ModTime()}, nil
+}
+
+func (s *dirSource) Glob(pattern string) ([]string, error) {
+	return filepath.Glob(pattern)
+}
+
+func (s *dirSource) Get(file string, w io.Writer) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func TestDownload(t *testing.T) {
+	ctx := context.Background()
+	remote := filepath.ToSlash(t.TempDir())
+	require.NoError(t, os.MkdirAll(filepath.Join(remote, "config", "sub"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(remote, "config", "a.yaml"), []byte("a: 1\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(remote, "config", "b.yaml"), []byte("b: 2\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(remote, "config", "sub", "c.txt"), []byte("c"), 0o644))
+	src := &dirSource{}
+
+	t.Run("file", func(t *testing.T) {
+		local := filepath.Join(t.TempDir(), "a.yaml")
+		r, err := Download(ctx, src, remote+"/config/a.yaml", local, false, 0)
+		require.NoError(t, err)
+		assert.Equal(t, DownloadResult{Files: 1, Bytes: 5}, r)
+		data, err := os.ReadFile(local)
+		require.NoError(t, err)
+		assert.Equal(t, "a: 1\n", string(data))
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		local := filepath.Join(t.TempDir(), "config")
+		_, err := Download(ctx, src, remote+"/config", local, false, 0)
+		assert.ErrorIs(t, err, ErrIsDir)
+
+		r, err := Download(ctx, src, remote+"/config", local, true, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 3, r.Files)
+		_, err = os.Stat(filepath.Join(local, "sub", "c.txt"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("glob", func(t *testing.T) {
+		local := t.TempDir()
+		r, err := Download(ctx, src, remote+"/config/*.yaml", local, false, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 2, r.Files)
+		_, err = os.Stat(filepath.Join(local, "b.yaml"))
+		assert.NoError(t, err)
+
+		_, err = Download(ctx, src, remote+"/config/*.json", local, false, 0)
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		local := filepath.Join(t.TempDir(), "config")
+		_, err := Download(ctx, src, remote+"/config", local, true, 10)
+		var tl *TooLargeError
+		require.ErrorAs(t, err, &tl)
+		assert.Equal(t, int64(11), tl.Size)
+		_, err = os.Stat(local)
+		assert.True(t, os.IsNotExist(err), "nothing is copied")
+	})
+}
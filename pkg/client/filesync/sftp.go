@@ -0,0 +1,111 @@
+package filesync
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// SFTPTarget is a Target that uses an SFTP connection, such as the one served by the traffic-agent.
+type SFTPTarget struct {
+	client *sftp.Client
+}
+
+// DialSFTP connects to the SFTP server at the given address.
+func DialSFTP(ctx context.Context, address string) (*SFTPTarget, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	client, err := sftp.NewClientPipe(conn, conn)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &SFTPTarget{client: client}, nil
+}
+
+// Close closes the SFTP connection.
+func (t *SFTPTarget) Close() error {
+	return t.client.Close()
+}
+
+func (t *SFTPTarget) List(dir string) (map[string]Entry, error) {
+	entries := make(map[string]Entry)
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	w := t.client.Walk(dir)
+	for w.Step() {
+		if err := w.Err(); err != nil {
+			if w.Path() == dir {
+				return nil, err
+			}
+			continue
+		}
+		rel := strings.TrimPrefix(w.Path(), prefix)
+		if rel == w.Path() {
+			// This is the directory itself.
+			continue
+		}
+		fi := w.Stat()
+		if !(fi.IsDir() || fi.Mode().IsRegular()) {
+			continue
+		}
+		entries[rel] = Entry{Dir: fi.IsDir(), Size: fi.Size(), Mode: fi.Mode().Perm(), ModTime: fi.ModTime()}
+	}
+	return entries, nil
+}
+
+func (t *SFTPTarget) MkdirAll(dir string) error {
+	return t.client.MkdirAll(dir)
+}
+
+func (t *SFTPTarget) Put(file string, r io.Reader, mode fs.FileMode, modTime time.Time) error {
+	f, err := t.client.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	if _, err = f.ReadFrom(r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = t.client.Chmod(file, mode); err != nil {
+		return err
+	}
+	return t.client.Chtimes(file, modTime, modTime)
+}
+
+func (t *SFTPTarget) Remove(name string) error {
+	return t.client.Remove(name)
+}
+
+func (t *SFTPTarget) Stat(name string) (Entry, error) {
+	fi, err := t.client.Stat(name)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Dir: fi.IsDir(), Size: fi.Size(), Mode: fi.Mode().Perm(), ModTime: fi.ModTime()}, nil
+}
+
+func (t *SFTPTarget) Glob(pattern string) ([]string, error) {
+	return t.client.Glob(pattern)
+}
+
+func (t *SFTPTarget) Get(file string, w io.Writer) error {
+	f, err := t.client.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteTo(w)
+	return err
+}
@@ -0,0 +1,271 @@
+// Package filesync keeps a directory in a remote file system in sync with a local directory. The sync is one-way:
+// local changes are copied to the remote directory, and changes made in the remote directory are overwritten
+// when the corresponding local files change. Files can also be downloaded from the remote file system.
+package filesync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Entry describes a file or directory.
+type Entry struct {
+	Dir     bool
+	Size    int64
+	Mode    fs.FileMode
+	ModTime time.Time
+}
+
+// same returns true if the two entries describe the same content. Modification times are compared with a
+// precision of one second, because that's what SFTP supports.
+func (e Entry) same(o Entry) bool {
+	if e.Dir || o.Dir {
+		return e.Dir == o.Dir
+	}
+	return e.Size == o.Size && e.ModTime.Unix() == o.ModTime.Unix()
+}
+
+// Target is the remote file system. All paths are absolute and slash separated.
+type Target interface {
+	// List returns the entries below the given directory, keyed by their slash separated path relative to the
+	// directory. An error satisfying errors.Is(err, fs.ErrNotExist) is returned when the directory doesn't exist.
+	List(dir string) (map[string]Entry, error)
+
+	// MkdirAll creates the given directory along with any missing parents.
+	MkdirAll(dir string) error
+
+	// Put writes the content of the given reader to the given file, and then sets its mode and modification time.
+	Put(file string, r io.Reader, mode fs.FileMode, modTime time.Time) error
+
+	// Remove removes the given file or empty directory.
+	Remove(name string) error
+}
+
+// Result summarizes a sync.
+type Result struct {
+	Uploaded int
+	Removed  int
+}
+
+// Syncer copies the content of a local directory to a directory of a Target.
+type Syncer struct {
+	local   string
+	remote  string
+	target  Target
+	exclude []string
+	delete  bool
+
+	// known are the entries of the remote directory, as of the last sync. It's nil until the first sync.
+	known map[string]Entry
+}
+
+// NewSyncer returns a Syncer that syncs the local directory to the remote directory of the given target. Files
+// and directories whose relative path or name match one of the exclude patterns (see path.Match) are neither
+// copied nor removed. The .git directory is always excluded. Files in the remote directory that are absent
+// from the local directory when the first sync starts are removed only if deleteExtraneous is true. Files
+// that are removed locally after that are always removed from the remote directory.
+func NewSyncer(local, remote string, target Target, exclude []string, deleteExtraneous bool) *Syncer {
+	return &Syncer{
+		local:   local,
+		remote:  remote,
+		target:  target,
+		exclude: append([]string{".git"}, exclude...),
+		delete:  deleteExtraneous,
+	}
+}
+
+func (s *Syncer) excluded(rel string) bool {
+	base := path.Base(rel)
+	for _, p := range s.exclude {
+		if m, _ := path.Match(p, rel); m {
+			return true
+		}
+		if m, _ := path.Match(p, base); m {
+			return true
+		}
+	}
+	return false
+}
+
+// Sync makes the remote directory reflect the current content of the local directory.
+func (s *Syncer) Sync(ctx context.Context) (Result, error) {
+	var r Result
+	first := s.known == nil
+	if first {
+		if err := s.loadRemote(); err != nil {
+			return r, err
+		}
+	}
+	local, err := s.scanLocal()
+	if err != nil {
+		return r, err
+	}
+
+	// Parents sort before their children, so directories are created before their content.
+	keys := make([]string, 0, len(local))
+	for k := range local {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if ctx.Err() != nil {
+			return r, ctx.Err()
+		}
+		le := local[k]
+		ke, ok := s.known[k]
+		if ok && ke.same(le) {
+			continue
+		}
+		if ok && ke.Dir != le.Dir {
+			// A file replaced a directory or vice versa.
+			n, err := s.removeTree(k)
+			r.Removed += n
+			if err != nil {
+				return r, err
+			}
+		}
+		rp := path.Join(s.remote, k)
+		if le.Dir {
+			if err = s.target.MkdirAll(rp); err != nil {
+				return r, err
+			}
+		} else {
+			if err = s.upload(k, rp, le); err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					// Removed locally after the scan. The next sync will take care of it.
+					continue
+				}
+				return r, err
+			}
+			r.Uploaded++
+		}
+		s.known[k] = le
+	}
+
+	// Children sort after their parents, so removing in reverse order empties directories before they're removed.
+	var gone []string
+	for k := range s.known {
+		if _, ok := local[k]; !ok {
+			if first && !s.delete {
+				delete(s.known, k)
+				continue
+			}
+			gone = append(gone, k)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(gone)))
+	for _, k := range gone {
+		if err = s.target.Remove(path.Join(s.remote, k)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return r, err
+		}
+		delete(s.known, k)
+		r.Removed++
+	}
+	return r, nil
+}
+
+func (s *Syncer) loadRemote() error {
+	known, err := s.target.List(s.remote)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("unable to list %s: %w", s.remote, err)
+		}
+		if err = s.target.MkdirAll(s.remote); err != nil {
+			return err
+		}
+		known = make(map[string]Entry)
+	}
+	for k := range known {
+		if s.excluded(k) || s.excludedParent(k) {
+			delete(known, k)
+		}
+	}
+	s.known = known
+	return nil
+}
+
+// excludedParent returns true if a parent directory of the given relative path is excluded.
+func (s *Syncer) excludedParent(rel string) bool {
+	for d := path.Dir(rel); d != "."; d = path.Dir(d) {
+		if s.excluded(d) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Syncer) scanLocal() (map[string]Entry, error) {
+	local := make(map[string]Entry)
+	err := filepath.WalkDir(s.local, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p != s.local {
+				return nil
+			}
+			return err
+		}
+		if p == s.local {
+			return nil
+		}
+		rel, err := filepath.Rel(s.local, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if s.excluded(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !(d.IsDir() || d.Type().IsRegular()) {
+			// Symlinks, sockets, and other special files aren't synced.
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		local[rel] = Entry{Dir: d.IsDir(), Size: fi.Size(), Mode: fi.Mode().Perm(), ModTime: fi.ModTime()}
+		return nil
+	})
+	return local, err
+}
+
+func (s *Syncer) upload(rel, remotePath string, e Entry) error {
+	f, err := os.Open(filepath.Join(s.local, filepath.FromSlash(rel)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.target.Put(remotePath, f, e.Mode, e.ModTime)
+}
+
+// removeTree removes the known entry with the given relative path, and all known entries below it.
+func (s *Syncer) removeTree(rel string) (int, error) {
+	var names []string
+	for k := range s.known {
+		if k == rel || strings.HasPrefix(k, rel+"/") {
+			names = append(names, k)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	for i, k := range names {
+		if err := s.target.Remove(path.Join(s.remote, k)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return i, err
+		}
+		delete(s.known, k)
+	}
+	return len(names), nil
+}
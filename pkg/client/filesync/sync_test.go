@@ -0,0 +1,143 @@
+package filesync
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dirTarget is a Target that writes to a local directory.
+type dirTarget struct {
+	uploads int
+}
+
+func (t *dirTarget) List(dir string) (map[string]Entry, error) {
+	entries := make(map[string]Entry)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		entries[filepath.ToSlash(rel)] = Entry{Dir: d.IsDir(), Size: fi.Size(), Mode: fi.Mode().Perm(), ModTime: fi.ModTime()}
+		return nil
+	})
+	return entries, err
+}
+
+func (t *dirTarget) MkdirAll(dir string) error {
+	return os.MkdirAll(dir, 0o755)
+}
+
+func (t *dirTarget) Put(file string, r io.Reader, mode fs.FileMode, modTime time.Time) error {
+	t.uploads++
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(file, data, mode); err != nil {
+		return err
+	}
+	return os.Chtimes(file, modTime, modTime)
+}
+
+func (t *dirTarget) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func writeFile(t *testing.T, name, content string, modTime time.Time) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(name), 0o755))
+	require.NoError(t, os.WriteFile(name, []byte(content), 0o644))
+	require.NoError(t, os.Chtimes(name, modTime, modTime))
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestSyncer(t *testing.T) {
+	ctx := context.Background()
+	local := t.TempDir()
+	remote := filepath.Join(t.TempDir(), "app", "src")
+	now := time.Now().Truncate(time.Second)
+
+	writeFile(t, filepath.Join(local, "main.go"), "package main", now)
+	writeFile(t, filepath.Join(local, "pkg", "a.go"), "package pkg", now)
+	writeFile(t, filepath.Join(local, ".git", "HEAD"), "ref", now)
+	writeFile(t, filepath.Join(local, "out.log"), "log", now)
+
+	target := &dirTarget{}
+	s := NewSyncer(local, remote, target, []string{"*.log"}, false)
+	r, err := s.Sync(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Uploaded: 2}, r)
+	assert.Equal(t, "package main", readFile(t, filepath.Join(remote, "main.go")))
+	assert.Equal(t, "package pkg", readFile(t, filepath.Join(remote, "pkg", "a.go")))
+	assert.NoFileExists(t, filepath.Join(remote, ".git", "HEAD"))
+	assert.NoFileExists(t, filepath.Join(remote, "out.log"))
+
+	// Nothing changed, so nothing is uploaded.
+	r, err = s.Sync(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Result{}, r)
+	assert.Equal(t, 2, target.uploads)
+
+	// Modified, added, and removed files.
+	writeFile(t, filepath.Join(local, "main.go"), "package main // changed", now.Add(time.Second))
+	writeFile(t, filepath.Join(local, "pkg", "sub", "b.go"), "package sub", now)
+	require.NoError(t, os.Remove(filepath.Join(local, "pkg", "a.go")))
+	r, err = s.Sync(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Uploaded: 2, Removed: 1}, r)
+	assert.Equal(t, "package main // changed", readFile(t, filepath.Join(remote, "main.go")))
+	assert.Equal(t, "package sub", readFile(t, filepath.Join(remote, "pkg", "sub", "b.go")))
+	assert.NoFileExists(t, filepath.Join(remote, "pkg", "a.go"))
+
+	// A directory replaced by a file.
+	require.NoError(t, os.RemoveAll(filepath.Join(local, "pkg")))
+	writeFile(t, filepath.Join(local, "pkg"), "not a dir", now)
+	r, err = s.Sync(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Result{Uploaded: 1, Removed: 3}, r)
+	assert.Equal(t, "not a dir", readFile(t, filepath.Join(remote, "pkg")))
+}
+
+func TestSyncer_extraneous(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	for _, del := range []bool{false, true} {
+		local := t.TempDir()
+		remote := t.TempDir()
+		writeFile(t, filepath.Join(local, "a.txt"), "a", now)
+		writeFile(t, filepath.Join(remote, "a.txt"), "a", now)
+		writeFile(t, filepath.Join(remote, "extra.txt"), "extra", now)
+		writeFile(t, filepath.Join(remote, "cache", "c.log"), "c", now)
+
+		s := NewSyncer(local, remote, &dirTarget{}, []string{"cache"}, del)
+		r, err := s.Sync(ctx)
+		require.NoError(t, err)
+		if del {
+			assert.Equal(t, Result{Removed: 1}, r)
+			assert.NoFileExists(t, filepath.Join(remote, "extra.txt"))
+		} else {
+			assert.Equal(t, Result{}, r)
+			assert.FileExists(t, filepath.Join(remote, "extra.txt"))
+		}
+		// Excluded files are never removed.
+		assert.FileExists(t, filepath.Join(remote, "cache", "c.log"))
+	}
+}
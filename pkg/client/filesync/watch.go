@@ -0,0 +1,82 @@
+package filesync
+
+import (
+	"context"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/datawire/dlib/dlog"
+)
+
+// WatchDir watches the given directory and its subdirectories, and sends on the returned channel when files
+// have been created, modified, removed, or renamed, and then left alone for the given quiet period. The
+// .git directory is not watched. The watcher is closed when the context is cancelled.
+func WatchDir(ctx context.Context, dir string, quiet time.Duration) (<-chan struct{}, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	addDirs := func(root string) error {
+		return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					// Removed while walking.
+					return nil
+				}
+				return err
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return watcher.Add(path)
+		})
+	}
+	if err = addDirs(dir); err != nil {
+		_ = watcher.Close()
+		return nil, err
+	}
+
+	changes := make(chan struct{}, 1)
+	// The delay timer will initially sleep forever. It's reset to the quiet period on each change.
+	delay := time.AfterFunc(time.Duration(math.MaxInt64), func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+			// A change is already pending.
+		}
+	})
+	go func() {
+		defer func() {
+			delay.Stop()
+			_ = watcher.Close()
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-watcher.Errors:
+				dlog.Error(ctx, err)
+			case event := <-watcher.Events:
+				if event.Op&fsnotify.Create != 0 {
+					if st, err := os.Stat(event.Name); err == nil && st.IsDir() && filepath.Base(event.Name) != ".git" {
+						if err = addDirs(event.Name); err != nil {
+							dlog.Errorf(ctx, "unable to watch %s: %v", event.Name, err)
+						}
+					}
+				}
+				if event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename) != 0 {
+					delay.Reset(quiet)
+				}
+			}
+		}
+	}()
+	return changes, nil
+}
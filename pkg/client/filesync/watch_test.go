@@ -0,0 +1,55 @@
+package filesync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+)
+
+func TestWatchDir(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, ".git"), 0o755))
+
+	changes, err := WatchDir(ctx, dir, 50*time.Millisecond)
+	require.NoError(t, err)
+
+	expectChange := func(what string) {
+		t.Helper()
+		select {
+		case <-changes:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no change reported after %s", what)
+		}
+	}
+	expectNoChange := func(what string) {
+		t.Helper()
+		select {
+		case <-changes:
+			t.Fatalf("change reported after %s", what)
+		case <-time.After(300 * time.Millisecond):
+		}
+	}
+
+	// Several writes in quick succession yield one change.
+	for i := 0; i < 3; i++ {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte{byte(i)}, 0o644))
+	}
+	expectChange("write")
+	expectNoChange("the quiet period")
+
+	// New directories are watched.
+	sub := filepath.Join(dir, "pkg")
+	require.NoError(t, os.Mkdir(sub, 0o755))
+	expectChange("mkdir")
+	require.NoError(t, os.WriteFile(filepath.Join(sub, "x.go"), []byte("x"), 0o644))
+	expectChange("write in new directory")
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "index"), []byte("x"), 0o644))
+	expectNoChange("write in .git")
+}
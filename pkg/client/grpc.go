@@ -0,0 +1,129 @@
+package client
+
+import (
+	"math"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
+)
+
+const (
+	// defaultHeartbeatInterval is used when no heartbeatInterval is configured.
+	defaultHeartbeatInterval = 30 * time.Second
+
+	// MinKeepAliveInterval is the shortest keep-alive interval that clients use. Servers must permit pings
+	// at this rate, or they will close the connection with a GOAWAY "too_many_pings".
+	MinKeepAliveInterval = 10 * time.Second
+)
+
+// Channel identifies a gRPC connection that has its own keep-alive settings.
+type Channel int
+
+const (
+	// ChannelNoKeepAlive is a connection that is never pinged.
+	ChannelNoKeepAlive Channel = iota
+
+	// ChannelConnector is the connection from the CLI to the user daemon.
+	ChannelConnector
+
+	// ChannelManager is the connection from the user daemon to the traffic-manager.
+	ChannelManager
+
+	// ChannelTunnel is the connection from the root daemon to the user daemon, which carries the tunnel
+	// streams to the traffic-manager.
+	ChannelTunnel
+)
+
+// KeepAlive returns the configured keep-alive interval and timeout of the given channel. The interval is
+// zero when the channel isn't pinged, and never less than MinKeepAliveInterval otherwise.
+func (g *Grpc) KeepAlive(ch Channel) (interval, timeout time.Duration) {
+	switch ch {
+	case ChannelConnector:
+		interval, timeout = g.ConnectorKeepAliveInterval, g.ConnectorKeepAliveTimeout
+	case ChannelManager:
+		interval, timeout = g.KeepAliveInterval, g.KeepAliveTimeout
+	case ChannelTunnel:
+		interval, timeout = g.TunnelKeepAliveInterval, g.TunnelKeepAliveTimeout
+	}
+	if interval > 0 && interval < MinKeepAliveInterval {
+		interval = MinKeepAliveInterval
+	}
+	return interval, timeout
+}
+
+// KeepAliveEnforcement returns the server option that permits the pings of clients that use the shortest
+// keep-alive interval, also when they have no active streams.
+func KeepAliveEnforcement() grpc.ServerOption {
+	return grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+		MinTime:             MinKeepAliveInterval,
+		PermitWithoutStream: true,
+	})
+}
+
+// DialOptions returns the dial options that apply the configured message sizes and window sizes, and
+// the keep-alive settings of the given channel.
+func (g *Grpc) DialOptions(ch Channel) []grpc.DialOption {
+	var opts []grpc.DialOption
+	var callOpts []grpc.CallOption
+	if mz := g.MaxReceiveSize(); mz > 0 {
+		callOpts = append(callOpts, grpc.MaxCallRecvMsgSize(int(mz)))
+	}
+	if mz := g.MaxSendSize(); mz > 0 {
+		callOpts = append(callOpts, grpc.MaxCallSendMsgSize(int(mz)))
+	}
+	if len(callOpts) > 0 {
+		opts = append(opts, grpc.WithDefaultCallOptions(callOpts...))
+	}
+	if ws := g.InitialWindowSize(); ws > 0 {
+		opts = append(opts, grpc.WithInitialWindowSize(asInt32(ws)))
+	}
+	if ws := g.InitialConnWindowSize(); ws > 0 {
+		opts = append(opts, grpc.WithInitialConnWindowSize(asInt32(ws)))
+	}
+	if iv, to := g.KeepAlive(ch); iv > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                iv,
+			Timeout:             to,
+			PermitWithoutStream: true,
+		}))
+	}
+	return opts
+}
+
+// ServerOptions returns the server options that apply the configured message sizes and window sizes, and
+// that permit the keep-alive pings of the clients.
+func (g *Grpc) ServerOptions() []grpc.ServerOption {
+	opts := []grpc.ServerOption{KeepAliveEnforcement()}
+	if mz := g.MaxReceiveSize(); mz > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(int(mz)))
+	}
+	if mz := g.MaxSendSize(); mz > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(int(mz)))
+	}
+	if ws := g.InitialWindowSize(); ws > 0 {
+		opts = append(opts, grpc.InitialWindowSize(asInt32(ws)))
+	}
+	if ws := g.InitialConnWindowSize(); ws > 0 {
+		opts = append(opts, grpc.InitialConnWindowSize(asInt32(ws)))
+	}
+	return opts
+}
+
+// Heartbeat returns a new Heartbeat that keeps idle tunnels to the traffic-manager alive.
+func (g *Grpc) Heartbeat() *tunnel.Heartbeat {
+	iv := g.HeartbeatInterval
+	if iv == 0 {
+		iv = defaultHeartbeatInterval
+	}
+	return tunnel.NewHeartbeat(iv)
+}
+
+func asInt32(v int64) int32 {
+	if v > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(v)
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrpc_KeepAlive(t *testing.T) {
+	g := &Grpc{
+		KeepAliveInterval:          time.Minute,
+		KeepAliveTimeout:           15 * time.Second,
+		ConnectorKeepAliveInterval: 2 * time.Second,
+		TunnelKeepAliveTimeout:     5 * time.Second,
+	}
+	tests := []struct {
+		name     string
+		ch       Channel
+		interval time.Duration
+		timeout  time.Duration
+	}{
+		{"manager", ChannelManager, time.Minute, 15 * time.Second},
+		{"connector raised to minimum", ChannelConnector, MinKeepAliveInterval, 0},
+		{"tunnel without pings", ChannelTunnel, 0, 5 * time.Second},
+		{"no keep-alive", ChannelNoKeepAlive, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iv, to := g.KeepAlive(tt.ch)
+			assert.Equal(t, tt.interval, iv)
+			assert.Equal(t, tt.timeout, to)
+			if iv > 0 {
+				assert.Len(t, g.DialOptions(tt.ch), 1)
+			} else {
+				assert.Empty(t, g.DialOptions(tt.ch))
+			}
+		})
+	}
+}
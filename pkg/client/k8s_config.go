@@ -4,14 +4,16 @@ import (
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/pflag"
 	"gopkg.in/yaml.v3"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	_ "k8s.io/client-go/plugin/pkg/client/auth" // Important for various cloud provider auth
 	"k8s.io/client-go/rest"
@@ -21,6 +23,8 @@ import (
 	"github.com/datawire/dlib/dlog"
 	"github.com/telepresenceio/telepresence/rpc/v2/connector"
 	rpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/atrest"
+	"github.com/telepresenceio/telepresence/v2/pkg/dnet"
 	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
 	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
 	"github.com/telepresenceio/telepresence/v2/pkg/maps"
@@ -64,8 +68,11 @@ type DnsConfig struct {
 	// to the first line of /etc/resolv.conf
 	LocalIP iputil.IPKey `json:"local-ip,omitempty"`
 
-	// RemoteIP is the address of the cluster's DNS service. It will default
-	// to the IP of the kube-dns.kube-system or the dns-default.openshift-dns service.
+	// RemoteIP is the address that the local DNS server is configured with on the
+	// TUN-device. It defaults to the IP of the traffic-manager's pod, or to an address
+	// in a subnet that is synthesized for the DNS server when no cluster subnets are
+	// routed. The cluster's DNS is always reached through the traffic-manager, so no
+	// access to the DNS services in kube-system or openshift-dns is needed.
 	RemoteIP iputil.IPKey `json:"remote-ip,omitempty"`
 
 	// ExcludeSuffixes are suffixes for which the DNS resolver will always return
@@ -85,12 +92,153 @@ type DnsConfig struct {
 
 	// The maximum time to wait for a cluster side host lookup.
 	LookupTimeout v1.Duration `json:"lookup-timeout,omitempty"`
+
+	// RecursionCheck configures the probe that determines if the cluster's DNS resolver will
+	// call the Telepresence DNS resolver recursively.
+	RecursionCheck *RecursionCheckConfig `json:"recursion-check,omitempty"`
+
+	// Resolvers are the backends that names are resolved with. They are consulted in the given
+	// order, and the first one that knows a name provides the answer. Defaults to the cluster.
+	Resolvers DNSResolvers `json:"resolvers,omitempty"`
+
+	// ClusterDomain is the domain of the cluster, e.g. "corp.internal". It overrides the domain that
+	// the traffic-manager detects, which is useful when the detection fails.
+	ClusterDomain string `json:"cluster-domain,omitempty"`
+}
+
+// DNSResolverType is the type of a DNSResolver. Its JSON form is one of "cluster", "hosts-file",
+// "static", or "plugin".
+type DNSResolverType rpc.DNSResolver_Type
+
+var dnsResolverTypeNames = map[DNSResolverType]string{ //nolint:gochecknoglobals // constant
+	DNSResolverType(rpc.DNSResolver_CLUSTER):    "cluster",
+	DNSResolverType(rpc.DNSResolver_HOSTS_FILE): "hosts-file",
+	DNSResolverType(rpc.DNSResolver_STATIC):     "static",
+	DNSResolverType(rpc.DNSResolver_PLUGIN):     "plugin",
+}
+
+func (t DNSResolverType) String() string {
+	return dnsResolverTypeNames[t]
+}
+
+func (t DNSResolverType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+func (t *DNSResolverType) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	for rt, n := range dnsResolverTypeNames {
+		if n == s {
+			*t = rt
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid DNS resolver type %q", s)
+}
+
+// DNSResolver is a backend that the DNS resolver uses to resolve names.
+type DNSResolver struct {
+	Type DNSResolverType `json:"type"`
+
+	// HostsFile is the file, in the same format as /etc/hosts, that the "hosts-file" resolver uses.
+	HostsFile string `json:"hosts-file,omitempty"`
+
+	// Static maps names to addresses for the "static" resolver.
+	Static map[string][]iputil.IPKey `json:"static,omitempty"`
+
+	// Socket is the unix socket of the gRPC service that the "plugin" resolver calls.
+	Socket string `json:"socket,omitempty"`
+}
+
+func (r *DNSResolver) UnmarshalJSON(data []byte) error {
+	type plain DNSResolver
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+	switch rpc.DNSResolver_Type(r.Type) {
+	case rpc.DNSResolver_HOSTS_FILE:
+		if r.HostsFile == "" {
+			return errors.New(`the "hosts-file" DNS resolver requires a hosts-file`)
+		}
+	case rpc.DNSResolver_PLUGIN:
+		if r.Socket == "" {
+			return errors.New(`the "plugin" DNS resolver requires a socket`)
+		}
+	}
+	return nil
+}
+
+type DNSResolvers []*DNSResolver
+
+func (d DNSResolvers) ToRPC() []*rpc.DNSResolver {
+	rpcResolvers := make([]*rpc.DNSResolver, 0, len(d))
+	for _, r := range d {
+		rr := &rpc.DNSResolver{
+			Type:      rpc.DNSResolver_Type(r.Type),
+			HostsFile: r.HostsFile,
+			Socket:    r.Socket,
+		}
+		for name, ips := range r.Static {
+			se := &rpc.DNSResolver_StaticEntry{Name: name, Ips: make([][]byte, len(ips))}
+			for i, ip := range ips {
+				se.Ips[i] = ip.IP()
+			}
+			rr.Static = append(rr.Static, se)
+		}
+		rpcResolvers = append(rpcResolvers, rr)
+	}
+	return rpcResolvers
+}
+
+// RecursionCheckConfig is part of the DnsConfig struct.
+type RecursionCheckConfig struct {
+	// Skip disables the probe. The resolver then assumes that the cluster's DNS resolver
+	// isn't recursive. Useful when something between the workstation and the cluster, such as
+	// a captive portal, answers the probe.
+	Skip bool `json:"skip,omitempty"`
+
+	// Target is the host name to probe. It must be in a namespace that isn't expected to exist.
+	// Defaults to "tel2-recursion-check.kube-system".
+	Target string `json:"target,omitempty"`
+
+	// Timeout is the time to wait for each attempt of the probe.
+	Timeout v1.Duration `json:"timeout,omitempty"`
 }
 
 // The ManagerConfig is part of the KubeconfigExtension struct. It configures discovery of the traffic manager.
 type ManagerConfig struct {
 	// Namespace is the name of the namespace where the traffic manager is to be found
 	Namespace string `json:"namespace,omitempty"`
+
+	// Address is the host:port of a gRPC endpoint that routes to the traffic-manager. When set, the
+	// traffic-manager is dialed at this address instead of through a port-forward. Takes precedence
+	// over the cluster.managerAddress of the config.
+	Address string `json:"address,omitempty"`
+
+	// Insecure disables TLS for the connection to the Address. It cannot be combined with a token.
+	Insecure bool `json:"insecure,omitempty"`
+
+	// CAFile is a file with PEM encoded certificates used to verify the Address endpoint.
+	CAFile string `json:"ca-file,omitempty"`
+
+	// TokenFile is a file containing a token that is sent as a bearer token to the Address. A token
+	// stored with "telepresence auth token set" is sent when no file is given.
+	TokenFile string `json:"token-file,omitempty"`
+}
+
+// ManagerEndpoint is an address where the traffic-manager can be dialed without a port-forward.
+type ManagerEndpoint struct {
+	Address   string
+	Insecure  bool
+	CAFile    string
+	TokenFile string
+
+	// Dial is used instead of the default dialer when it is not nil, e.g. to reach the endpoint
+	// through an SSH proxy.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
 }
 
 // KubeconfigExtension is an extension read from the selected kubeconfig Cluster.
@@ -103,12 +251,13 @@ type KubeconfigExtension struct {
 
 type Kubeconfig struct {
 	KubeconfigExtension
-	Namespace   string // default cluster namespace.
-	Context     string
-	Server      string
-	FlagMap     map[string]string
-	ConfigFlags *genericclioptions.ConfigFlags
-	RestConfig  *rest.Config
+	Namespace     string // default cluster namespace.
+	Context       string
+	Server        string
+	CloudProvider CloudProvider
+	FlagMap       map[string]string
+	ConfigFlags   *genericclioptions.ConfigFlags
+	RestConfig    *rest.Config
 }
 
 const configExtension = "telepresence.io"
@@ -191,7 +340,10 @@ func DaemonKubeconfig(c context.Context, cr *connector.ConnectRequest) (*Kubecon
 			_ = os.Setenv(k, v)
 		}
 	}
-	flagMap := cr.KubeFlags
+	flagMap, err := decryptedKubeconfig(c, cr.KubeFlags)
+	if err != nil {
+		return nil, err
+	}
 	configFlags, err := ConfigFlags(flagMap)
 	if err != nil {
 		return nil, err
@@ -199,6 +351,35 @@ func DaemonKubeconfig(c context.Context, cr *connector.ConnectRequest) (*Kubecon
 	return newKubeconfig(c, flagMap, cr.ManagerNamespace, configFlags)
 }
 
+// decryptedKubeconfig returns the given flag map, or a copy of it that refers to a decrypted copy of the
+// kubeconfig file when that file is encrypted, which is the case for the kubeconfig that a daemon running
+// in a container gets from the host. The decrypted copy is private to the process's host or container.
+func decryptedKubeconfig(c context.Context, flagMap map[string]string) (map[string]string, error) {
+	kc := flagMap["kubeconfig"]
+	if kc == "" {
+		return flagMap, nil
+	}
+	data, err := os.ReadFile(kc)
+	if err != nil || !atrest.IsEncrypted(data) {
+		// Errors are reported when the kubeconfig is loaded.
+		return flagMap, nil
+	}
+	if data, err = atrest.Decrypt(c, data); err != nil {
+		return nil, errcat.Config.Newf("unable to decrypt kubeconfig %s: %w", kc, err)
+	}
+	dir := filepath.Join(os.TempDir(), "telepresence", "kube")
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	plain := filepath.Join(dir, filepath.Base(kc))
+	if err = os.WriteFile(plain, data, 0o600); err != nil {
+		return nil, err
+	}
+	fm := maps.Copy(flagMap)
+	fm["kubeconfig"] = plain
+	return fm, nil
+}
+
 func newKubeconfig(c context.Context, flagMap map[string]string, managerNamespaceOverride string, configFlags *genericclioptions.ConfigFlags) (*Kubeconfig, error) {
 	configLoader := configFlags.ToRawKubeConfigLoader()
 	config, err := configLoader.RawConfig()
@@ -230,26 +411,39 @@ func newKubeconfig(c context.Context, flagMap map[string]string, managerNamespac
 		return nil, errcat.Config.Newf("the cluster %q declared in context %q does exists in the kubeconfig", ctx.Cluster, ctxName)
 	}
 
-	restConfig, err := configLoader.ClientConfig()
+	if sp := GetConfig(c).Cluster().SSHProxy; sp.Address != "" {
+		sshDialer, err := dnet.NewSSHDialer(sp.Address, sp.User, sp.KeyFile, sp.KnownHostsFile)
+		if err != nil {
+			return nil, errcat.Config.Newf("unable to configure ssh proxy: %w", err)
+		}
+		dlog.Infof(c, "Connecting to the cluster via ssh proxy %s", sp.Address)
+		configFlags.WrapConfigFn = func(rc *rest.Config) *rest.Config {
+			rc.Dial = sshDialer.DialContext
+			return rc
+		}
+	}
+
+	restConfig, err := configFlags.ToRESTConfig()
 	if err != nil {
 		return nil, err
 	}
 
 	dlog.Debugf(c, "using namespace %q", namespace)
 
-	k := &Kubeconfig{
-		Context:     ctxName,
-		Server:      cluster.Server,
-		Namespace:   namespace,
-		FlagMap:     flagMap,
-		ConfigFlags: configFlags,
-		RestConfig:  restConfig,
+	ke, err := parseKubeconfigExtension(ctx.Cluster, cluster)
+	if err != nil {
+		return nil, err
 	}
 
-	if ext, ok := cluster.Extensions[configExtension].(*runtime.Unknown); ok {
-		if err = json.Unmarshal(ext.Raw, &k.KubeconfigExtension); err != nil {
-			return nil, errcat.Config.Newf("unable to parse extension %s in kubeconfig: %w", configExtension, err)
-		}
+	k := &Kubeconfig{
+		KubeconfigExtension: *ke,
+		Context:             ctxName,
+		Server:              cluster.Server,
+		CloudProvider:       DetectCloudProvider(ctx.Cluster, cluster, config.AuthInfos[ctx.AuthInfo]),
+		Namespace:           namespace,
+		FlagMap:             flagMap,
+		ConfigFlags:         configFlags,
+		RestConfig:          restConfig,
 	}
 
 	if k.KubeconfigExtension.Manager == nil {
@@ -328,6 +522,36 @@ func (kf *Kubeconfig) GetManagerNamespace() string {
 	return kf.KubeconfigExtension.Manager.Namespace
 }
 
+// GetManagerEndpoint returns the endpoint where the traffic-manager can be dialed without a port-forward,
+// or nil when no such endpoint has been configured in the kubeconfig extension or in the config.
+func (kf *Kubeconfig) GetManagerEndpoint(ctx context.Context) *ManagerEndpoint {
+	if mgr := kf.KubeconfigExtension.Manager; mgr != nil && mgr.Address != "" {
+		return &ManagerEndpoint{
+			Address:   mgr.Address,
+			Insecure:  mgr.Insecure,
+			CAFile:    mgr.CAFile,
+			TokenFile: mgr.TokenFile,
+			Dial:      kf.RestConfig.Dial,
+		}
+	}
+	if cc := GetConfig(ctx).Cluster(); cc.ManagerAddress != "" {
+		return &ManagerEndpoint{
+			Address:   cc.ManagerAddress,
+			Insecure:  cc.ManagerInsecure,
+			CAFile:    cc.ManagerCAFile,
+			TokenFile: cc.ManagerTokenFile,
+			Dial:      kf.RestConfig.Dial,
+		}
+	}
+	return nil
+}
+
+// TokenName returns the name under which the bearer token for the endpoint is stored, e.g. by
+// "telepresence auth token set". The stored token is used when the endpoint has no token file.
+func (ep *ManagerEndpoint) TokenName() string {
+	return "manager:" + ep.Address
+}
+
 func (kf *Kubeconfig) GetRestConfig() *rest.Config {
 	return kf.RestConfig
 }
@@ -359,6 +583,9 @@ func (kf *Kubeconfig) AddRemoteKubeConfigExtension(ctx context.Context, cfgYaml
 		if kf.DNS.LookupTimeout.Duration == 0 {
 			kf.DNS.LookupTimeout.Duration = dns.LookupTimeout
 		}
+		if kf.DNS.ClusterDomain == "" {
+			kf.DNS.ClusterDomain = dns.ClusterDomain
+		}
 	}
 	if routing := remote.Routing; routing != nil {
 		kf.AlsoProxy = append(kf.AlsoProxy, routing.AlsoProxy...)
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	auth "k8s.io/api/authentication/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport"
+)
+
+// ManagerTokenAudience is the audience of the tokens that clients identify themselves to the traffic-manager with.
+const ManagerTokenAudience = "telepresence-traffic-manager"
+
+// managerTokenExpiration is the lifetime that ManagerToken requests. The API server may extend it to its minimum.
+const managerTokenExpiration = 10 * time.Minute
+
+// errTokenCaptured aborts the request that BearerToken makes once the Authorization header has been seen.
+var errTokenCaptured = errors.New("token captured")
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(rq *http.Request) (*http.Response, error) {
+	return f(rq)
+}
+
+// BearerToken returns the bearer token that the given config authenticates with. Tokens that are provided
+// by exec or auth-provider plugins are obtained from the plugin. An empty string is returned when the config
+// authenticates in some other way, e.g. using a client certificate. No request is sent to the API server.
+func BearerToken(ctx context.Context, config *rest.Config) (string, error) {
+	if config.BearerToken != "" {
+		return config.BearerToken, nil
+	}
+	tc, err := config.TransportConfig()
+	if err != nil {
+		return "", err
+	}
+	var token string
+	rt, err := transport.HTTPWrappersForConfig(tc, roundTripperFunc(func(rq *http.Request) (*http.Response, error) {
+		const bearer = "Bearer "
+		if ah := rq.Header.Get("Authorization"); strings.HasPrefix(ah, bearer) {
+			token = strings.TrimPrefix(ah, bearer)
+		}
+		return nil, errTokenCaptured
+	}))
+	if err != nil {
+		return "", err
+	}
+	rq, err := http.NewRequestWithContext(ctx, http.MethodGet, config.Host, nil)
+	if err != nil {
+		return "", err
+	}
+	if _, err = rt.RoundTrip(rq); err != nil && !errors.Is(err, errTokenCaptured) {
+		return "", err
+	}
+	return token, nil
+}
+
+// ManagerToken returns a short-lived token, bound to the ManagerTokenAudience, that identifies the service account
+// that the given config authenticates as. The Kubernetes API server only issues such tokens for service accounts,
+// so an empty string is returned when the config authenticates in some other way. The credentials of the config
+// are never part of the result.
+func ManagerToken(ctx context.Context, config *rest.Config, ki kubernetes.Interface) (string, error) {
+	token, err := BearerToken(ctx, config)
+	if err != nil || token == "" {
+		return "", err
+	}
+	ns, name, ok := serviceAccount(token)
+	if !ok {
+		return "", nil
+	}
+	exp := int64(managerTokenExpiration / time.Second)
+	tr, err := ki.CoreV1().ServiceAccounts(ns).CreateToken(ctx, name, &auth.TokenRequest{
+		Spec: auth.TokenRequestSpec{
+			Audiences:         []string{ManagerTokenAudience},
+			ExpirationSeconds: &exp,
+		},
+	}, meta.CreateOptions{})
+	if err != nil {
+		return "", err
+	}
+	return tr.Status.Token, nil
+}
+
+// serviceAccount returns the namespace and name of the service account that the given token claims to belong to.
+// The claim isn't verified. It only decides what token to request.
+func serviceAccount(token string) (string, string, bool) {
+	const saPrefix = "system:serviceaccount:"
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", "", false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", "", false
+	}
+	var claims struct {
+		Sub string `json:"sub"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || !strings.HasPrefix(claims.Sub, saPrefix) {
+		return "", "", false
+	}
+	ns, name, ok := strings.Cut(strings.TrimPrefix(claims.Sub, saPrefix), ":")
+	return ns, name, ok && ns != "" && name != ""
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	auth "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestBearerToken(t *testing.T) {
+	ctx := context.Background()
+	token, err := BearerToken(ctx, &rest.Config{Host: "https://127.0.0.1:6443", BearerToken: "static"})
+	require.NoError(t, err)
+	assert.Equal(t, "static", token)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("from-file"), 0o600))
+	token, err = BearerToken(ctx, &rest.Config{Host: "https://127.0.0.1:6443", BearerTokenFile: tokenFile})
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", token)
+
+	token, err = BearerToken(ctx, &rest.Config{Host: "https://127.0.0.1:6443", Username: "user", Password: "secret"})
+	require.NoError(t, err)
+	assert.Empty(t, token)
+}
+
+func jwt(payload string) string {
+	enc := base64.RawURLEncoding.EncodeToString
+	return enc([]byte(`{"alg":"RS256"}`)) + "." + enc([]byte(payload)) + ".signature"
+}
+
+func TestManagerToken(t *testing.T) {
+	ctx := context.Background()
+	ki := fake.NewSimpleClientset()
+	var requested *auth.TokenRequest
+	ki.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		ca := action.(k8stesting.CreateAction)
+		require.Equal(t, "token", ca.GetSubresource())
+		assert.Equal(t, "ci", ca.GetNamespace())
+		requested = ca.GetObject().(*auth.TokenRequest)
+		tr := requested.DeepCopy()
+		tr.Status.Token = "bound"
+		return true, tr, nil
+	})
+
+	saToken := jwt(`{"sub":"system:serviceaccount:ci:deployer"}`)
+	token, err := ManagerToken(ctx, &rest.Config{Host: "https://127.0.0.1:6443", BearerToken: saToken}, ki)
+	require.NoError(t, err)
+	assert.Equal(t, "bound", token)
+	require.NotNil(t, requested)
+	assert.Equal(t, []string{ManagerTokenAudience}, requested.Spec.Audiences)
+	assert.Equal(t, int64(600), *requested.Spec.ExpirationSeconds)
+
+	// Tokens of users can't be exchanged, and are never returned.
+	requested = nil
+	for _, cfg := range []*rest.Config{
+		{Host: "https://127.0.0.1:6443", BearerToken: jwt(`{"sub":"alice@example.com"}`)},
+		{Host: "https://127.0.0.1:6443", BearerToken: "opaque"},
+		{Host: "https://127.0.0.1:6443", Username: "user", Password: "secret"},
+	} {
+		token, err = ManagerToken(ctx, cfg, ki)
+		require.NoError(t, err)
+		assert.Empty(t, token)
+	}
+	assert.Nil(t, requested)
+}
@@ -0,0 +1,336 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd/api"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+// extensionError is an error found at a given path in the kubeconfig extension, e.g. "dns.resolvers[0].type".
+type extensionError struct {
+	path []any // elements are string for object keys and int for array indexes
+	msg  string
+	key  bool // true when the error concerns the key at path rather than its value
+}
+
+func (e *extensionError) pathString() string {
+	sb := strings.Builder{}
+	for _, p := range e.path {
+		switch p := p.(type) {
+		case int:
+			sb.WriteString("[" + strconv.Itoa(p) + "]")
+		case string:
+			if sb.Len() > 0 {
+				sb.WriteByte('.')
+			}
+			sb.WriteString(p)
+		}
+	}
+	return sb.String()
+}
+
+// parseKubeconfigExtension parses the telepresence.io extension of the given cluster. All unknown fields and
+// invalid values are reported in the returned error, with their line and column in the kubeconfig file when
+// the file can be read.
+func parseKubeconfigExtension(clusterName string, cluster *api.Cluster) (*KubeconfigExtension, error) {
+	var ke KubeconfigExtension
+	ext, ok := cluster.Extensions[configExtension].(*runtime.Unknown)
+	if !ok {
+		return &ke, nil
+	}
+	var v any
+	if err := json.Unmarshal(ext.Raw, &v); err == nil {
+		if errs := validateExtensionValue(nil, v, reflect.TypeOf(ke)); len(errs) > 0 {
+			return nil, extensionErrors(clusterName, cluster.LocationOfOrigin, errs)
+		}
+	}
+	if err := json.Unmarshal(ext.Raw, &ke); err != nil {
+		return nil, errcat.Config.Newf("unable to parse extension %s of cluster %q in kubeconfig: %w", configExtension, clusterName, err)
+	}
+	return &ke, nil
+}
+
+// LintKubeconfig validates the telepresence.io extension of the cluster of the current context, or of the context
+// given in the flag map, without connecting to the cluster. It returns the name of the cluster and true if the
+// cluster has the extension.
+func LintKubeconfig(flagMap map[string]string) (string, bool, error) {
+	cld, err := ConfigLoader(flagMap)
+	if err != nil {
+		return "", false, err
+	}
+	config, err := cld.RawConfig()
+	if err != nil {
+		return "", false, err
+	}
+	ctxName := flagMap["context"]
+	if ctxName == "" {
+		ctxName = config.CurrentContext
+	}
+	ctx, ok := config.Contexts[ctxName]
+	if !ok {
+		return "", false, errcat.Config.Newf("context %q does not exist in the kubeconfig", ctxName)
+	}
+	cluster, ok := config.Clusters[ctx.Cluster]
+	if !ok {
+		return "", false, errcat.Config.Newf("the cluster %q declared in context %q does exists in the kubeconfig", ctx.Cluster, ctxName)
+	}
+	_, found := cluster.Extensions[configExtension]
+	_, err = parseKubeconfigExtension(ctx.Cluster, cluster)
+	return ctx.Cluster, found, err
+}
+
+// validateExtensionValue validates the given decoded JSON value against the given type and returns errors for
+// all unknown object keys and all values that cannot be unmarshalled.
+func validateExtensionValue(path []any, v any, t reflect.Type) []*extensionError {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	isUnmarshaler := reflect.PointerTo(t).Implements(reflect.TypeOf((*json.Unmarshaler)(nil)).Elem())
+	switch vv := v.(type) {
+	case map[string]any:
+		switch {
+		case t.Kind() == reflect.Struct:
+			fields := jsonFields(t)
+			var errs []*extensionError
+			for k, fv := range vv {
+				fp := appendPath(path, k)
+				ft, ok := lookupJSONField(fields, k)
+				if !ok {
+					msg := fmt.Sprintf("unknown field %q", k)
+					if s := closestName(k, fields); s != "" {
+						msg += fmt.Sprintf(", did you mean %q?", s)
+					}
+					errs = append(errs, &extensionError{path: fp, msg: msg, key: true})
+					continue
+				}
+				errs = append(errs, validateExtensionValue(fp, fv, ft)...)
+			}
+			if len(errs) > 0 || !isUnmarshaler {
+				return errs
+			}
+		case t.Kind() == reflect.Map && !isUnmarshaler:
+			var errs []*extensionError
+			for k, ev := range vv {
+				errs = append(errs, validateExtensionValue(appendPath(path, k), ev, t.Elem())...)
+			}
+			return errs
+		}
+	case []any:
+		if t.Kind() == reflect.Slice && !isUnmarshaler {
+			var errs []*extensionError
+			for i, ev := range vv {
+				errs = append(errs, validateExtensionValue(appendPath(path, i), ev, t.Elem())...)
+			}
+			return errs
+		}
+	}
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = json.Unmarshal(data, reflect.New(t).Interface())
+	}
+	if err != nil {
+		var te *json.UnmarshalTypeError
+		msg := err.Error()
+		if errors.As(err, &te) {
+			msg = fmt.Sprintf("cannot use %s as %s", te.Value, te.Type)
+		}
+		return []*extensionError{{path: path, msg: msg}}
+	}
+	return nil
+}
+
+func appendPath(path []any, p any) []any {
+	return append(append(make([]any, 0, len(path)+1), path...), p)
+}
+
+// jsonFields returns the JSON names of the fields of the given struct type, including the fields of
+// embedded structs.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				for n, ft := range jsonFields(f.Type) {
+					fields[n] = ft
+				}
+				continue
+			}
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// lookupJSONField finds the field with the given name. The match is case-insensitive, just like in
+// json.Unmarshal.
+func lookupJSONField(fields map[string]reflect.Type, name string) (reflect.Type, bool) {
+	if t, ok := fields[name]; ok {
+		return t, true
+	}
+	for n, t := range fields {
+		if strings.EqualFold(n, name) {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// closestName returns the field name that is closest to the given name, provided that it is close
+// enough to be a likely typo.
+func closestName(name string, fields map[string]reflect.Type) string {
+	best, bestDist := "", 3
+	for n := range fields {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(n)); d < bestDist || (d == bestDist && n < best) {
+			best, bestDist = n, d
+		}
+	}
+	if bestDist > 2 {
+		return ""
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(v int, vs ...int) int {
+	for _, x := range vs {
+		if x < v {
+			v = x
+		}
+	}
+	return v
+}
+
+// extensionErrors returns an error that lists the given errors, each one prefixed with its location in the
+// given kubeconfig file when it can be determined.
+func extensionErrors(clusterName, file string, errs []*extensionError) error {
+	ext := findExtensionNode(clusterName, file)
+	lines := make([]string, len(errs))
+	for i, e := range errs {
+		line := e.pathString() + ": " + e.msg
+		if n := findPathNode(ext, e.path, e.key); n != nil {
+			line = fmt.Sprintf("%s:%d:%d: %s", file, n.Line, n.Column, line)
+		}
+		lines[i] = line
+	}
+	sort.Strings(lines)
+	return errcat.Config.Newf("invalid extension %s of cluster %q in kubeconfig:\n  %s",
+		configExtension, clusterName, strings.Join(lines, "\n  "))
+}
+
+// findExtensionNode returns the YAML node of the telepresence.io extension of the given cluster in the given
+// kubeconfig file, or nil if it cannot be found.
+func findExtensionNode(clusterName, file string) *yaml.Node {
+	if file == "" {
+		return nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return nil
+	}
+	cluster := findNamed(mappingValue(doc.Content[0], "clusters"), clusterName)
+	return mappingValue(findNamed(mappingValue(mappingValue(cluster, "cluster"), "extensions"), configExtension), "extension")
+}
+
+// findPathNode returns the node at the given path, or the key node of the last element of the path when key
+// is true. The closest parent is returned when the path cannot be followed to its end.
+func findPathNode(n *yaml.Node, path []any, key bool) *yaml.Node {
+	if n == nil {
+		return nil
+	}
+	for i, p := range path {
+		var next *yaml.Node
+		switch p := p.(type) {
+		case string:
+			if n.Kind == yaml.MappingNode {
+				for j := 0; j+1 < len(n.Content); j += 2 {
+					if n.Content[j].Value == p {
+						next = n.Content[j+1]
+						if key && i == len(path)-1 {
+							next = n.Content[j]
+						}
+						break
+					}
+				}
+			}
+		case int:
+			if n.Kind == yaml.SequenceNode && p < len(n.Content) {
+				next = n.Content[p]
+			}
+		}
+		if next == nil {
+			break
+		}
+		n = next
+	}
+	return n
+}
+
+// mappingValue returns the value of the given key in the given mapping node.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// findNamed returns the element with the given "name" in the given sequence node.
+func findNamed(n *yaml.Node, name string) *yaml.Node {
+	if n == nil || n.Kind != yaml.SequenceNode {
+		return nil
+	}
+	for _, e := range n.Content {
+		if v := mappingValue(e, "name"); v != nil && v.Value == name {
+			return e
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: good
+contexts:
+- name: good
+  context:
+    cluster: good
+- name: bad
+  context:
+    cluster: bad
+- name: plain
+  context:
+    cluster: plain
+clusters:
+- name: good
+  cluster:
+    server: https://good.example.com
+    extensions:
+    - name: telepresence.io
+      extension:
+        never-proxy:
+        - 10.0.0.0/8
+        dns:
+          lookup-timeout: 2s
+          resolvers:
+          - type: static
+            static:
+              db.example.com: [10.1.2.3]
+        manager:
+          namespace: tel2
+- name: bad
+  cluster:
+    server: https://bad.example.com
+    extensions:
+    - name: telepresence.io
+      extension:
+        never-proxu:
+        - 10.0.0.0/8
+        dns:
+          lookup-timeout: 2
+          resolvers:
+          - type: static
+            statik: {}
+        manager:
+          namespace: [tel2]
+- name: plain
+  cluster:
+    server: https://plain.example.com
+`
+
+func TestLintKubeconfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(file, []byte(testKubeconfig), 0o600))
+
+	cluster, found, err := LintKubeconfig(map[string]string{"kubeconfig": file})
+	require.NoError(t, err)
+	assert.Equal(t, "good", cluster)
+	assert.True(t, found)
+
+	cluster, found, err = LintKubeconfig(map[string]string{"kubeconfig": file, "context": "plain"})
+	require.NoError(t, err)
+	assert.Equal(t, "plain", cluster)
+	assert.False(t, found)
+
+	_, _, err = LintKubeconfig(map[string]string{"kubeconfig": file, "context": "bad"})
+	require.Error(t, err)
+	msg := err.Error()
+	assert.Contains(t, msg, file+`:37:9: never-proxu: unknown field "never-proxu", did you mean "never-proxy"?`)
+	assert.Contains(t, msg, file+`:40:27: dns.lookup-timeout: cannot use number as string`)
+	assert.Contains(t, msg, file+`:43:13: dns.resolvers[0].statik: unknown field "statik", did you mean "static"?`)
+	assert.Contains(t, msg, file+`:45:22: manager.namespace: cannot use array as string`)
+}
@@ -3,6 +3,7 @@ package logging
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -31,18 +32,26 @@ func InitContext(ctx context.Context, name string, strategy RotationStrategy, ca
 	logger.SetLevel(logrus.InfoLevel)
 	logger.ReportCaller = false // turned on when level >= logrus.TraceLevel
 
+	cfg := client.GetConfig(ctx)
 	if captureStd && IsTerminal(int(os.Stdout.Fd())) {
 		logger.Formatter = tlog.NewFormatter("15:04:05.0000")
 	} else {
-		logger.Formatter = tlog.NewFormatter("2006-01-02 15:04:05.0000")
-		maxFiles := uint16(5)
+		const timestampFormat = "2006-01-02 15:04:05.0000"
+		if *cfg.LogFormat() == client.LogFormatJSON {
+			logger.Formatter = tlog.NewJSONFormatter(timestampFormat)
+		} else {
+			logger.Formatter = tlog.NewFormatter(timestampFormat)
+		}
+		lf := cfg.LogFiles()
+		maxFiles := uint16(lf.GetMaxBackups() + 1)
 
-		// TODO: Also make this a configurable setting in config.yml
+		// The environment variable takes precedence over the logFiles.maxBackups setting in config.yml
 		if me := os.Getenv("TELEPRESENCE_MAX_LOGFILES"); me != "" {
 			if mx, err := strconv.Atoi(me); err == nil && mx >= 0 {
 				maxFiles = uint16(mx)
 			}
 		}
+		strategy = RotateOnSize(strategy, lf.MaxSizeBytes())
 		rf, err := OpenRotatingFile(ctx, filepath.Join(filelocation.AppUserLogDir(ctx), name+".log"), "20060102T150405", true, 0o600, strategy, maxFiles)
 		if err != nil {
 			return ctx, err
@@ -66,8 +75,8 @@ func InitContext(ctx context.Context, name string, strategy RotationStrategy, ca
 
 	ctx = dlog.WithLogger(ctx, dlog.WrapLogrus(logger))
 
-	// Read the config and set the configured level.
-	logLevels := client.GetConfig(ctx).LogLevels()
+	// Set the configured level.
+	logLevels := cfg.LogLevels()
 	level := logLevels.UserDaemon
 	if name == "daemon" {
 		level = logLevels.RootDaemon
@@ -93,15 +102,28 @@ func SummarizeLog(ctx context.Context, name string) (string, error) {
 	for scanner.Scan() {
 		// XXX: is there a better way to detect error lines?
 		txt := scanner.Text()
-		parts := strings.Fields(txt)
-		if len(parts) < 3 {
-			continue
+		var level string
+		if strings.HasPrefix(txt, "{") {
+			// Written using the JSON log format
+			var entry struct {
+				Level string `json:"level"`
+			}
+			if json.Unmarshal([]byte(txt), &entry) != nil {
+				continue
+			}
+			level = entry.Level
+		} else {
+			parts := strings.Fields(txt)
+			if len(parts) < 3 {
+				continue
+			}
+			level = parts[2]
 		}
-		switch parts[2] {
+		switch level {
 		case "error":
 			errorCount++
 		case "info":
-			if strings.Contains(txt, "-- Starting new session") {
+			if strings.Contains(txt, sessionStartMarker) {
 				// Start over. No use counting errors from previous sessions
 				errorCount = 0
 			}
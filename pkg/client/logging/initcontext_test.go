@@ -1,7 +1,9 @@
 package logging
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -12,6 +14,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/resource"
 
 	"github.com/datawire/dlib/dlog"
 	"github.com/datawire/dlib/dtime"
@@ -229,6 +232,44 @@ func TestInitContext(t *testing.T) {
 		check.Contains(string(bs), fmt.Sprintf("%s info    %s\n", infoTs, infoMsg2))
 	})
 
+	t.Run("json format and size based rotation", func(t *testing.T) {
+		ctx, logDir, logFile := testSetup(t)
+		check := require.New(t)
+
+		cfg := client.GetConfig(ctx)
+		*cfg.LogFormat() = client.LogFormatJSON
+		maxSize := resource.MustParse("300")
+		cfg.LogFiles().MaxSize = &maxSize
+
+		c, err := InitContext(ctx, logName, RotateNever, true)
+		loggerForTest.AddHook(&dtimeHook{})
+		check.NoError(err)
+		check.NotNil(c)
+		for i := 0; i < 3; i++ {
+			ft.Step(time.Second)
+			dlog.Infof(c, "info message %d with some padding to make it longer", i)
+		}
+		dlog.Error(c, "error message")
+		closeLog(t)
+
+		files, err := os.ReadDir(logDir)
+		check.NoError(err)
+		check.Greater(len(files), 1)
+
+		bs, err := os.ReadFile(logFile)
+		check.NoError(err)
+		var entry map[string]any
+		lines := bytes.Split(bytes.TrimSpace(bs), []byte("\n"))
+		check.NoError(json.Unmarshal(lines[len(lines)-1], &entry))
+		check.Equal("error", entry["level"])
+		check.Equal("error message", entry["msg"])
+
+		ctx = filelocation.WithAppUserLogDir(ctx, logDir)
+		summary, err := SummarizeLog(ctx, logName)
+		check.NoError(err)
+		check.Contains(summary, "1 error found")
+	})
+
 	t.Run("old files are removed", func(t *testing.T) {
 		ctx, logDir, _ := testSetup(t)
 		check := require.New(t)
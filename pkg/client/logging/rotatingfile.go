@@ -63,6 +63,29 @@ func (rotateDaily) RotateNow(rf *RotatingFile, _ int) bool {
 	return dtime.Now().In(bt.Location()).Day() != rf.BirthTime().Day()
 }
 
+type rotateOnSize struct {
+	RotationStrategy
+	maxSize int64
+}
+
+// RotateOnSize returns a strategy that rotates the file when the given strategy says so, and also
+// when a write would make a non-empty file exceed the given size. The given strategy is returned
+// when the size is zero.
+func RotateOnSize(strategy RotationStrategy, maxSize int64) RotationStrategy {
+	if maxSize <= 0 {
+		return strategy
+	}
+	return &rotateOnSize{RotationStrategy: strategy, maxSize: maxSize}
+}
+
+func (r *rotateOnSize) RotateNow(rf *RotatingFile, writeSize int) bool {
+	if r.RotationStrategy.RotateNow(rf, writeSize) {
+		return true
+	}
+	size := rf.Size()
+	return size > 0 && size+int64(writeSize) > r.maxSize
+}
+
 type RotatingFile struct {
 	ctx         context.Context
 	fileMode    fs.FileMode
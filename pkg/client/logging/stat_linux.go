@@ -72,9 +72,9 @@ func oldFStat(file *os.File) (SysInfo, error) {
 		// with the changetime.  I'm not sure why changetime is the
 		// best choice, but it's what Telepresence did before we
 		// added statx support.
-		btime: time.Unix(stat.Ctim.Sec, stat.Ctim.Nsec),
-		mtime: time.Unix(stat.Mtim.Sec, stat.Mtim.Nsec),
-		ctime: time.Unix(stat.Ctim.Sec, stat.Ctim.Nsec),
+		btime: time.Unix(stat.Ctim.Unix()),
+		mtime: time.Unix(stat.Mtim.Unix()),
+		ctime: time.Unix(stat.Ctim.Unix()),
 	}, nil
 }
 
@@ -0,0 +1,63 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/dos"
+)
+
+type fileInfo struct {
+	size  int64
+	uid   int
+	gid   int
+	btime time.Time
+	mtime time.Time
+	ctime time.Time
+}
+
+func osFStat(dfile dos.File) (SysInfo, error) {
+	file, ok := dfile.(*os.File)
+	if !ok {
+		return nil, fmt.Errorf("files of type %T don't support Fstat", dfile)
+	}
+	var stat unix.Stat_t
+	if err := unix.Fstat(int(file.Fd()), &stat); err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", file.Name(), err)
+	}
+	return fileInfo{
+		size: stat.Size,
+		uid:  int(stat.Uid),
+		gid:  int(stat.Gid),
+		// The birthtime isn't exposed by the stat(2) of OpenBSD. Fake it with the changetime, just like
+		// the Linux fallback does when statx(2) is unavailable.
+		btime: time.Unix(stat.Ctim.Unix()),
+		mtime: time.Unix(stat.Mtim.Unix()),
+		ctime: time.Unix(stat.Ctim.Unix()),
+	}, nil
+}
+
+func (u fileInfo) Size() int64 {
+	return u.size
+}
+
+func (u fileInfo) SetOwnerAndGroup(name string) error {
+	return os.Chown(name, u.uid, u.gid)
+}
+
+func (u fileInfo) HaveSameOwnerAndGroup(other SysInfo) bool {
+	ou := other.(fileInfo)
+	return u.uid == ou.uid && u.gid == ou.gid
+}
+
+func (u fileInfo) String() string {
+	return fmt.Sprintf("BTIME %v, MTIME %v, CTIME %v, UID %d, GID %d",
+		u.btime, u.mtime, u.ctime, u.uid, u.gid)
+}
+
+func (u fileInfo) BirthTime() time.Time  { return u.btime }
+func (u fileInfo) ModifyTime() time.Time { return u.mtime }
+func (u fileInfo) ChangeTime() time.Time { return u.ctime }
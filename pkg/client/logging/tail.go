@@ -0,0 +1,137 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/datawire/dlib/dtime"
+)
+
+// sessionStartMarker is the message that the daemons log when a new session starts.
+const sessionStartMarker = "-- Starting new session"
+
+// tailPollInterval is the interval at which Tail checks a followed file for new content.
+const tailPollInterval = 250 * time.Millisecond
+
+// Tail calls the given function with the last lines of the given log file. A negative number of
+// lines means all lines. When sessionOnly is true, lines that were logged before the start of the
+// last session are skipped. When follow is true, Tail then continues to call the function with
+// lines that are appended to the file, also after the file has been rotated, until the context is
+// cancelled.
+func Tail(ctx context.Context, logFile string, lines int, sessionOnly, follow bool, fn func(string) error) error {
+	f, err := os.Open(logFile)
+	if err != nil {
+		if !(follow && os.IsNotExist(err)) {
+			return err
+		}
+	} else {
+		var initial []string
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			line := sc.Text()
+			if sessionOnly && strings.Contains(line, sessionStartMarker) {
+				initial = initial[:0]
+			}
+			initial = append(initial, line)
+		}
+		if err = sc.Err(); err != nil {
+			f.Close()
+			return err
+		}
+		if lines >= 0 && len(initial) > lines {
+			initial = initial[len(initial)-lines:]
+		}
+		for _, line := range initial {
+			if err = fn(line); err != nil {
+				f.Close()
+				return err
+			}
+		}
+	}
+	if !follow {
+		f.Close()
+		return nil
+	}
+	return followFile(ctx, logFile, f, fn)
+}
+
+// followFile calls the given function with each line that is appended to the given file. The file is
+// reopened when it's rotated. The given file is positioned at its end, or nil if it didn't exist.
+func followFile(ctx context.Context, logFile string, f *os.File, fn func(string) error) error {
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+	var partial []byte
+	var rd *bufio.Reader
+	if f != nil {
+		rd = bufio.NewReader(f)
+	}
+	for {
+		if rd != nil {
+			for {
+				data, err := rd.ReadBytes('\n')
+				if err != nil {
+					if !errors.Is(err, io.EOF) {
+						return err
+					}
+					partial = append(partial, data...)
+					break
+				}
+				if len(partial) > 0 {
+					data = append(partial, data...)
+					partial = nil
+				}
+				if err = fn(strings.TrimRight(string(data), "\r\n")); err != nil {
+					return err
+				}
+			}
+		}
+		dtime.SleepWithContext(ctx, tailPollInterval)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if rotated(logFile, f) {
+			if f != nil {
+				f.Close()
+			}
+			var err error
+			if f, err = os.Open(logFile); err != nil {
+				f, rd = nil, nil
+				if !os.IsNotExist(err) {
+					return err
+				}
+				continue
+			}
+			partial = nil
+			rd = bufio.NewReader(f)
+		}
+	}
+}
+
+// rotated returns true if the given file is nil, or no longer is the file found at the given path.
+func rotated(logFile string, f *os.File) bool {
+	st, err := os.Stat(logFile)
+	if err != nil {
+		return false
+	}
+	if f == nil {
+		return true
+	}
+	ft, err := f.Stat()
+	if err != nil {
+		return true
+	}
+	if !os.SameFile(st, ft) {
+		return true
+	}
+	// Truncated in place
+	pos, err := f.Seek(0, io.SeekCurrent)
+	return err == nil && st.Size() < pos
+}
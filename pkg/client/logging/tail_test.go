@@ -0,0 +1,74 @@
+package logging_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/logging"
+)
+
+func TestTail(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	logFile := filepath.Join(t.TempDir(), "test.log")
+	content := "a\nb\n-- Starting new session\nc\nd\n"
+	require.NoError(t, os.WriteFile(logFile, []byte(content), 0o600))
+
+	collect := func(lines int, sessionOnly bool) []string {
+		var got []string
+		require.NoError(t, logging.Tail(ctx, logFile, lines, sessionOnly, false, func(line string) error {
+			got = append(got, line)
+			return nil
+		}))
+		return got
+	}
+	assert.Equal(t, []string{"a", "b", "-- Starting new session", "c", "d"}, collect(-1, false))
+	assert.Equal(t, []string{"c", "d"}, collect(2, false))
+	assert.Equal(t, []string{"-- Starting new session", "c", "d"}, collect(-1, true))
+	assert.Empty(t, collect(0, false))
+
+	t.Run("follow", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var mu sync.Mutex
+		var got []string
+		lines := func() []string {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]string(nil), got...)
+		}
+		done := make(chan error, 1)
+		go func() {
+			done <- logging.Tail(ctx, logFile, 1, false, true, func(line string) error {
+				mu.Lock()
+				got = append(got, line)
+				mu.Unlock()
+				return nil
+			})
+		}()
+		require.Eventually(t, func() bool { return len(lines()) == 1 }, 5*time.Second, 50*time.Millisecond)
+
+		f, err := os.OpenFile(logFile, os.O_APPEND|os.O_WRONLY, 0o600)
+		require.NoError(t, err)
+		_, err = f.WriteString("e\n")
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		require.Eventually(t, func() bool { return len(lines()) == 2 }, 5*time.Second, 50*time.Millisecond)
+
+		// Rotate
+		require.NoError(t, os.Rename(logFile, logFile+".1"))
+		require.NoError(t, os.WriteFile(logFile, []byte("f\n"), 0o600))
+		require.Eventually(t, func() bool { return len(lines()) == 3 }, 5*time.Second, 50*time.Millisecond)
+		assert.Equal(t, []string{"d", "e", "f"}, lines())
+
+		cancel()
+		require.NoError(t, <-done)
+	})
+}
@@ -0,0 +1,91 @@
+// Package paths applies the overrides of the locations of the directories and sockets that the CLI and the daemons
+// use. The overrides are given by the "paths" section of the config, or by environment variables.
+package paths
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/socket"
+	"github.com/telepresenceio/telepresence/v2/pkg/dos"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+const (
+	// UserDaemonSocketFlag is the flag that tells the root daemon where to find the user daemon.
+	UserDaemonSocketFlag = "user-daemon-socket"
+
+	// RootDaemonSocketFlag is the flag that tells the root daemon where to listen.
+	RootDaemonSocketFlag = "root-daemon-socket"
+)
+
+// WithOverrides returns a context in which the filelocation and socket functions return the locations that the
+// environment, or the "paths" section of the config of the given context, override. The environment takes
+// precedence.
+func WithOverrides(ctx context.Context) context.Context {
+	p := client.GetConfig(ctx).Paths()
+	var env client.Env
+	if e := client.GetEnv(ctx); e != nil {
+		env = *e
+	}
+	if dir := pick(ctx, env.CacheDir, p.CacheDir); dir != "" {
+		ctx = filelocation.WithAppUserCacheDir(ctx, dir)
+	}
+	if dir := pick(ctx, env.LogDir, p.LogDir); dir != "" {
+		ctx = filelocation.WithAppUserLogDir(ctx, dir)
+	}
+	if path := pick(ctx, env.UserDaemonSocket, p.UserDaemonSocket); path != "" {
+		ctx = socket.WithUserDaemonPath(ctx, path)
+	}
+	if path := pick(ctx, env.RootDaemonSocket, p.RootDaemonSocket); path != "" {
+		ctx = socket.WithRootDaemonPath(ctx, path)
+	}
+	return ctx
+}
+
+func pick(ctx context.Context, envValue, cfgValue string) string {
+	v := envValue
+	if v == "" {
+		v = cfgValue
+	}
+	if v == "" {
+		return ""
+	}
+	return expand(ctx, v)
+}
+
+// expand expands the environment variables of the given path, and a leading "~" to the user's home directory.
+func expand(ctx context.Context, path string) string {
+	path = dos.ExpandEnv(ctx, path)
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		path = filepath.Join(filelocation.UserHomeDir(ctx), path[1:])
+	}
+	return path
+}
+
+// RootDaemonFlags returns the flags that pass the socket overrides of the given context on to the root daemon, which
+// doesn't inherit the environment when it's started using sudo.
+func RootDaemonFlags(ctx context.Context) []string {
+	var flags []string
+	if path := socket.UserDaemonPath(ctx); path != socket.UserDaemonPath(context.Background()) {
+		flags = append(flags, "--"+UserDaemonSocketFlag, path)
+	}
+	if path := socket.RootDaemonPath(ctx); path != socket.RootDaemonPath(context.Background()) {
+		flags = append(flags, "--"+RootDaemonSocketFlag, path)
+	}
+	return flags
+}
+
+// Effective returns the locations that are in effect in the given context.
+func Effective(ctx context.Context) *connector.DaemonPaths {
+	return &connector.DaemonPaths{
+		ConfigDir:        filelocation.AppUserConfigDir(ctx),
+		CacheDir:         filelocation.AppUserCacheDir(ctx),
+		LogDir:           filelocation.AppUserLogDir(ctx),
+		UserDaemonSocket: socket.UserDaemonPath(ctx),
+		RootDaemonSocket: socket.RootDaemonPath(ctx),
+	}
+}
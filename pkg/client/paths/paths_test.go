@@ -0,0 +1,65 @@
+package paths
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/socket"
+	"github.com/telepresenceio/telepresence/v2/pkg/dos"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+func testContext(t *testing.T, env *client.Env, p client.Paths) context.Context {
+	ctx := dlog.NewTestContext(t, false)
+	ctx = filelocation.WithUserHomeDir(ctx, filepath.FromSlash("/home/tester"))
+	ctx = dos.WithEnv(ctx, dos.MapEnv{"CI_JOB": "42"})
+	cfg := client.GetDefaultConfig()
+	*cfg.Paths() = p
+	ctx = client.WithConfig(ctx, cfg)
+	if env != nil {
+		ctx = client.WithEnv(ctx, env)
+	}
+	return ctx
+}
+
+func TestWithOverrides(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		ctx := testContext(t, nil, client.Paths{})
+		assert.Equal(t, filelocation.AppUserCacheDir(ctx), filelocation.AppUserCacheDir(WithOverrides(ctx)))
+		assert.Empty(t, RootDaemonFlags(WithOverrides(ctx)))
+	})
+
+	t.Run("config", func(t *testing.T) {
+		ctx := WithOverrides(testContext(t, nil, client.Paths{
+			CacheDir:         "~/cache/$CI_JOB",
+			LogDir:           "/var/log/tp",
+			UserDaemonSocket: "/run/tp/${CI_JOB}/connector.socket",
+		}))
+		assert.Equal(t, filepath.Join(filepath.FromSlash("/home/tester/cache"), "42"), filelocation.AppUserCacheDir(ctx))
+		assert.Equal(t, "/var/log/tp", filelocation.AppUserLogDir(ctx))
+		assert.Equal(t, "/run/tp/42/connector.socket", socket.UserDaemonPath(ctx))
+		assert.Equal(t, []string{"--" + UserDaemonSocketFlag, "/run/tp/42/connector.socket"}, RootDaemonFlags(ctx))
+	})
+
+	t.Run("environment takes precedence", func(t *testing.T) {
+		ctx := WithOverrides(testContext(t, &client.Env{
+			LogDir:           "/tmp/logs",
+			RootDaemonSocket: "/tmp/daemon.socket",
+		}, client.Paths{
+			LogDir:           "/var/log/tp",
+			RootDaemonSocket: "/run/tp/daemon.socket",
+		}))
+		assert.Equal(t, "/tmp/logs", filelocation.AppUserLogDir(ctx))
+		assert.Equal(t, "/tmp/daemon.socket", socket.RootDaemonPath(ctx))
+		assert.Equal(t, socket.UserDaemonPath(context.Background()), socket.UserDaemonPath(ctx))
+
+		ps := Effective(ctx)
+		assert.Equal(t, "/tmp/logs", ps.LogDir)
+		assert.Equal(t, "/tmp/daemon.socket", ps.RootDaemonSocket)
+	})
+}
@@ -0,0 +1,14 @@
+package platform
+
+import (
+	"errors"
+	"os"
+)
+
+// fuseAvailable checks that the kernel provides FUSE. Kernels of small boards are often built without it.
+func fuseAvailable() error {
+	if _, err := os.Stat("/dev/fuse"); err != nil {
+		return errors.New("FUSE is not available on your local machine (no /dev/fuse)")
+	}
+	return nil
+}
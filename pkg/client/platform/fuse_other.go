@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package platform
+
+// fuseAvailable returns nil on platforms where FUSE is provided by software that sshfs and fuseftp check for
+// themselves, such as macFUSE and WinFsp.
+func fuseAvailable() error {
+	return nil
+}
@@ -0,0 +1,70 @@
+// Package platform describes the platform that the client runs on, and detects the features that the platform can't
+// support, so that they can be degraded gracefully and reported by "telepresence status".
+package platform
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/datawire/dlib/dexec"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/remotefs"
+	"github.com/telepresenceio/telepresence/v2/pkg/proc"
+)
+
+const (
+	// FeatureFTPMounts is remote mounts that use the fuseftp server that is embedded in the executable.
+	FeatureFTPMounts = "ftp-mounts"
+
+	// FeatureSSHFSMounts is remote mounts that use sshfs.
+	FeatureSSHFSMounts = "sshfs-mounts"
+)
+
+// Name returns the name of the platform that this executable was built for, e.g. "linux/amd64". The name of an
+// arm platform includes the variant, e.g. "linux/arm/v7".
+func Name() string {
+	name := runtime.GOOS + "/" + runtime.GOARCH
+	if runtime.GOARCH == "arm" {
+		if bi, ok := debug.ReadBuildInfo(); ok {
+			for _, s := range bi.Settings {
+				if s.Key == "GOARM" {
+					name += "/v" + s.Value
+					break
+				}
+			}
+		}
+	}
+	return name
+}
+
+// UnavailableFeatures returns the features that can't be used on this platform, mapped to the reason why.
+func UnavailableFeatures() map[string]string {
+	uf := make(map[string]string)
+	if proc.RunningInContainer() {
+		// Mounts use docker volumes and the telemount driver plugin.
+		return uf
+	}
+	fuseErr := fuseAvailable()
+	switch {
+	case !remotefs.FuseFTPEmbedded:
+		uf[FeatureFTPMounts] = fmt.Sprintf("the fuseftp server isn't available for %s", Name())
+	case fuseErr != nil:
+		uf[FeatureFTPMounts] = fuseErr.Error()
+	}
+	sshfs := "sshfs"
+	if runtime.GOOS == "windows" {
+		sshfs = "sshfs-win"
+	}
+	switch {
+	case !hasExecutable(sshfs):
+		uf[FeatureSSHFSMounts] = "sshfs is not installed on your local machine"
+	case fuseErr != nil:
+		uf[FeatureSSHFSMounts] = fuseErr.Error()
+	}
+	return uf
+}
+
+func hasExecutable(name string) bool {
+	_, err := dexec.LookPath(name)
+	return err == nil
+}
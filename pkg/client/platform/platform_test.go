@@ -0,0 +1,34 @@
+package platform
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client/remotefs"
+	"github.com/telepresenceio/telepresence/v2/pkg/proc"
+)
+
+func TestName(t *testing.T) {
+	name := Name()
+	assert.True(t, strings.HasPrefix(name, runtime.GOOS+"/"+runtime.GOARCH), name)
+	if runtime.GOARCH != "arm" {
+		assert.Equal(t, runtime.GOOS+"/"+runtime.GOARCH, name)
+	}
+}
+
+func TestUnavailableFeatures(t *testing.T) {
+	uf := UnavailableFeatures()
+	if proc.RunningInContainer() {
+		assert.Empty(t, uf)
+		return
+	}
+	if !remotefs.FuseFTPEmbedded {
+		assert.Contains(t, uf, FeatureFTPMounts)
+	}
+	for f := range uf {
+		assert.Contains(t, []string{FeatureFTPMounts, FeatureSSHFSMounts}, f)
+	}
+}
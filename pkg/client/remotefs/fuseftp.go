@@ -1,5 +1,5 @@
-//go:build !docker
-// +build !docker
+//go:build !docker && !nofuseftp
+// +build !docker,!nofuseftp
 
 package remotefs
 
@@ -26,6 +26,9 @@ import (
 //go:embed fuseftp.bits
 var fuseftpBits []byte
 
+// FuseFTPEmbedded is true when the fuseftp server is embedded in this executable.
+const FuseFTPEmbedded = true
+
 type fuseFtpMgr struct {
 	startFuseCh chan struct{}
 	fuseFtpCh   chan rpc.FuseFTPClient
@@ -1,5 +1,5 @@
-//go:build docker
-// +build docker
+//go:build docker || nofuseftp
+// +build docker nofuseftp
 
 package remotefs
 
@@ -10,6 +10,10 @@ import (
 	"github.com/datawire/go-fuseftp/rpc"
 )
 
+// FuseFTPEmbedded is false because the fuseftp server isn't embedded in executables that are built for docker, or
+// for platforms that go-fuseftp has no releases for.
+const FuseFTPEmbedded = false
+
 type fuseFtpMgr struct{}
 
 type FuseFTPManager interface {
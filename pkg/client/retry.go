@@ -2,9 +2,18 @@ package client
 
 import (
 	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
 	"time"
 
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+
 	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
 )
 
 const (
@@ -19,41 +28,90 @@ const (
 //	Delay - initial delay, i.e. the delay between the first and the second call.
 //	MaxDelay - maximum delay between calling the functions (delay will never grow beyond this value)
 func Retry(c context.Context, text string, f func(context.Context) error, durations ...time.Duration) error {
-	delay := defaultRetryDelay
-	maxDelay := defaultMaxDelay
-
+	p := RetryPolicy{
+		Retryable: func(error) bool { return true },
+	}
 	switch len(durations) {
 	case 2:
-		maxDelay = durations[1]
-		if maxDelay == 0 {
-			maxDelay = defaultMaxDelay
-		}
+		p.MaxDelay = durations[1]
 		fallthrough
 	case 1:
-		delay = durations[0]
-		if delay == 0 {
-			delay = defaultRetryDelay
-		}
+		p.Delay = durations[0]
 	}
+	return p.Do(c, text, f)
+}
+
+// RetryPolicy controls how an operation is retried. The zero value retries every retryable error
+// until the context is done, without jitter.
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts. Zero means no limit.
+	Attempts int
+
+	// Delay is the delay between the first and the second attempt. It's doubled for each
+	// consecutive failure, up to MaxDelay.
+	Delay    time.Duration
+	MaxDelay time.Duration
 
+	// Jitter is the fraction, between zero and one, by which each delay is randomly shortened or
+	// lengthened, so that clients that fail at the same time don't retry at the same time.
+	Jitter float64
+
+	// Retryable decides if an error is worth another attempt. IsRetryable is used when it's nil.
+	Retryable func(error) bool
+}
+
+// Do calls the given function until it succeeds, until it returns an error that isn't retryable, until
+// the attempts are exhausted, or until the context is done. The error from the last attempt is returned.
+// The attempts and failures are counted under the given operation name, see RetryCounters.
+func (p *RetryPolicy) Do(c context.Context, op string, f func(context.Context) error) error {
+	delay := p.Delay
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+	maxDelay := p.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxDelay
+	}
 	if maxDelay < delay {
 		maxDelay = delay
 	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryable
+	}
 
-	for {
+	rc := retryCountersFor(op)
+	rc.add(func(s *RetryStats) { s.Calls++ })
+	for attempt := 1; ; attempt++ {
 		err := f(c)
 		if err == nil {
-			// success
 			return nil
 		}
+		var pe permanentError
+		if errors.As(err, &pe) {
+			err = pe.error
+		}
+		if pe.error != nil || !retryable(err) || (p.Attempts > 0 && attempt >= p.Attempts) || c.Err() != nil {
+			rc.add(func(s *RetryStats) {
+				s.Failures++
+				s.LastError = err.Error()
+			})
+			return err
+		}
 
-		// Logging at higher log levels should be done in the called function
-		dlog.Debugf(c, "%s waiting %s before retrying after error: %v", text, delay.String(), err)
+		d := p.jitter(delay)
+		rc.add(func(s *RetryStats) {
+			s.Retries++
+			s.LastError = err.Error()
+		})
 
+		// Logging at higher log levels should be done in the called function
+		dlog.Debugf(c, "%s waiting %s before retrying after error: %v", op, d, err)
 		select {
 		case <-c.Done():
+			rc.add(func(s *RetryStats) { s.Failures++ })
 			return err
-		case <-time.After(delay):
+		case <-time.After(d):
 		}
 		delay *= 2
 		if delay > maxDelay {
@@ -61,3 +119,128 @@ func Retry(c context.Context, text string, f func(context.Context) error, durati
 		}
 	}
 }
+
+func (p *RetryPolicy) jitter(d time.Duration) time.Duration {
+	if p.Jitter <= 0 {
+		return d
+	}
+	j := p.Jitter
+	if j > 1 {
+		j = 1
+	}
+	jitterLock.Lock()
+	f := jitterRand.Float64()
+	jitterLock.Unlock()
+	return time.Duration(float64(d) * (1 + j*(2*f-1)))
+}
+
+//nolint:gochecknoglobals // protected by jitterLock
+var (
+	jitterLock sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// permanentError is an error that RetryPolicy.Do doesn't retry.
+type permanentError struct {
+	error
+}
+
+func (e permanentError) Unwrap() error {
+	return e.error
+}
+
+// PermanentError wraps the given error so that RetryPolicy.Do returns it without retrying, regardless
+// of the policy's classification of errors.
+func PermanentError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{error: err}
+}
+
+// IsRetryable is the default classification of errors that RetryPolicy uses. Errors are considered transient
+// unless they stem from a canceled context, are categorized as user or config errors, or are Kubernetes or gRPC
+// errors that won't go away by trying again, such as "not found" or "forbidden".
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	switch errcat.GetCategory(err) {
+	case errcat.User, errcat.Config:
+		return false
+	}
+	switch {
+	case k8sErrors.IsNotFound(err), k8sErrors.IsForbidden(err), k8sErrors.IsUnauthorized(err),
+		k8sErrors.IsInvalid(err), k8sErrors.IsBadRequest(err), k8sErrors.IsAlreadyExists(err),
+		k8sErrors.IsMethodNotSupported(err):
+		return false
+	}
+	if st, ok := status.FromError(err); ok {
+		switch st.Code() {
+		case codes.Canceled, codes.DeadlineExceeded, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+			codes.PermissionDenied, codes.Unauthenticated, codes.FailedPrecondition, codes.OutOfRange, codes.Unimplemented:
+			return false
+		}
+	}
+	return true
+}
+
+// RetryStats are the counters of an operation that is retried using a RetryPolicy.
+type RetryStats struct {
+	Operation string
+
+	// Calls is the number of times that the operation was performed.
+	Calls uint64
+
+	// Retries is the number of failed attempts that were followed by another attempt.
+	Retries uint64
+
+	// Failures is the number of times that the operation failed after its last attempt.
+	Failures uint64
+
+	// LastError is the error of the most recent failed attempt.
+	LastError string
+}
+
+type retryCounters struct {
+	sync.Mutex
+	RetryStats
+}
+
+func (rc *retryCounters) add(f func(*RetryStats)) {
+	rc.Lock()
+	f(&rc.RetryStats)
+	rc.Unlock()
+}
+
+//nolint:gochecknoglobals // protected by retryCountersLock
+var (
+	retryCountersLock sync.Mutex
+	retryCountersMap  = map[string]*retryCounters{}
+)
+
+func retryCountersFor(op string) *retryCounters {
+	retryCountersLock.Lock()
+	defer retryCountersLock.Unlock()
+	rc, ok := retryCountersMap[op]
+	if !ok {
+		rc = &retryCounters{RetryStats: RetryStats{Operation: op}}
+		retryCountersMap[op] = rc
+	}
+	return rc
+}
+
+// RetryCounters returns the counters of all operations that have been performed by this process using a
+// RetryPolicy, sorted by operation.
+func RetryCounters() []RetryStats {
+	retryCountersLock.Lock()
+	rss := make([]RetryStats, 0, len(retryCountersMap))
+	for _, rc := range retryCountersMap {
+		rc.Lock()
+		rss = append(rss, rc.RetryStats)
+		rc.Unlock()
+	}
+	retryCountersLock.Unlock()
+	sort.Slice(rss, func(i, j int) bool { return rss[i].Operation < rss[j].Operation })
+	return rss
+}
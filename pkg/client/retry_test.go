@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+func retryStats(t *testing.T, op string) RetryStats {
+	for _, rs := range RetryCounters() {
+		if rs.Operation == op {
+			return rs
+		}
+	}
+	t.Fatalf("no counters for %s", op)
+	return RetryStats{}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	ctx := context.Background()
+	p := &RetryPolicy{Attempts: 3, Delay: time.Millisecond, MaxDelay: 2 * time.Millisecond, Jitter: 0.5}
+	transient := errors.New("connection reset by peer")
+
+	t.Run("succeeds after retries", func(t *testing.T) {
+		calls := 0
+		err := p.Do(ctx, t.Name(), func(context.Context) error {
+			if calls++; calls < 3 {
+				return transient
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3, calls)
+		assert.Equal(t, RetryStats{Operation: t.Name(), Calls: 1, Retries: 2, LastError: transient.Error()}, retryStats(t, t.Name()))
+	})
+
+	t.Run("attempts exhausted", func(t *testing.T) {
+		calls := 0
+		err := p.Do(ctx, t.Name(), func(context.Context) error {
+			calls++
+			return transient
+		})
+		assert.Equal(t, transient, err)
+		assert.Equal(t, 3, calls)
+		rs := retryStats(t, t.Name())
+		assert.Equal(t, uint64(2), rs.Retries)
+		assert.Equal(t, uint64(1), rs.Failures)
+	})
+
+	t.Run("not retryable", func(t *testing.T) {
+		for _, err := range []error{
+			errcat.User.New("bad flag"),
+			k8sErrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "", errors.New("denied")),
+			status.Error(codes.PermissionDenied, "policy"),
+			context.Canceled,
+		} {
+			calls := 0
+			rErr := p.Do(ctx, t.Name(), func(context.Context) error {
+				calls++
+				return err
+			})
+			assert.Equal(t, err, rErr)
+			assert.Equal(t, 1, calls, err.Error())
+		}
+		assert.True(t, IsRetryable(status.Error(codes.Unavailable, "connection refused")))
+		assert.True(t, IsRetryable(k8sErrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "x", errors.New("modified"))))
+	})
+
+	t.Run("permanent", func(t *testing.T) {
+		calls := 0
+		err := p.Do(ctx, t.Name(), func(context.Context) error {
+			calls++
+			return PermanentError(transient)
+		})
+		assert.Equal(t, transient, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("jitter", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			d := p.jitter(time.Second)
+			assert.GreaterOrEqual(t, d, 500*time.Millisecond)
+			assert.LessOrEqual(t, d, 1500*time.Millisecond)
+		}
+	})
+}
+
+func TestRetriesPolicy(t *testing.T) {
+	p := (&Retries{}).Policy()
+	assert.Equal(t, defaultRetryAttempts, p.Attempts)
+	assert.Equal(t, defaultRetryJitter, p.Jitter)
+
+	p = (&Retries{Attempts: 2, Delay: time.Second, Jitter: -1}).Policy()
+	assert.Equal(t, 2, p.Attempts)
+	assert.Equal(t, time.Second, p.Delay)
+	assert.Equal(t, time.Second, p.jitter(time.Second))
+}
@@ -0,0 +1,106 @@
+package rootd
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/pcap"
+)
+
+// captureQueueLen is the number of captured packets that can be waiting to be sent before packets are
+// dropped. The tap must never block the TUN-device.
+const captureQueueLen = 1024
+
+// capture sends copies of the packets that traverse the TUN-device and match the request until the
+// given context is cancelled or the session ends.
+func (s *Session) capture(ctx, sessionCtx context.Context, req *rpc.CaptureRequest, send func(*rpc.CapturedPacket) error) error {
+	filter, err := pcap.ParseFilter(req.Filter)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	subnets := make([]*net.IPNet, len(req.Subnets))
+	for i, sn := range req.Subnets {
+		subnets[i] = iputil.IPNetFromRPC(sn)
+	}
+	snapLen := int(req.SnapLen)
+	if snapLen <= 0 {
+		snapLen = pcap.DefaultSnapLen
+	}
+	if s.tunVif == nil {
+		return status.Error(codes.Unavailable, "the network is not ready")
+	}
+
+	packets := make(chan *rpc.CapturedPacket, captureQueueLen)
+	var dropped uint32
+	remove := s.tunVif.AddTap(func(packet []byte) {
+		if !(inSubnets(subnets, packet) && filter(packet)) {
+			return
+		}
+		n := len(packet)
+		if n > snapLen {
+			n = snapLen
+		}
+		cp := &rpc.CapturedPacket{
+			Timestamp: timestamppb.New(time.Now()),
+			Data:      make([]byte, n),
+			OrigLen:   int32(len(packet)),
+		}
+		copy(cp.Data, packet)
+
+		// The tap is called from both the goroutine that reads from, and the one that writes to, the
+		// TUN-device, so the count of dropped packets must be updated atomically.
+		cp.Dropped = atomic.SwapUint32(&dropped, 0)
+		select {
+		case packets <- cp:
+		default:
+			atomic.AddUint32(&dropped, cp.Dropped+1)
+		}
+	})
+	defer remove()
+
+	dlog.Debugf(ctx, "Capturing packets using filter %q", req.Filter)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-sessionCtx.Done():
+			return nil
+		case cp := <-packets:
+			if err := send(cp); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// inSubnets returns true if subnets is empty or if the source or destination of the given IP packet is
+// in one of the subnets.
+func inSubnets(subnets []*net.IPNet, packet []byte) bool {
+	if len(subnets) == 0 {
+		return true
+	}
+	var src, dst net.IP
+	switch {
+	case len(packet) >= 20 && packet[0]>>4 == 4:
+		src, dst = packet[12:16], packet[16:20]
+	case len(packet) >= 40 && packet[0]>>4 == 6:
+		src, dst = packet[8:24], packet[24:40]
+	default:
+		return false
+	}
+	for _, sn := range subnets {
+		if sn.Contains(src) || sn.Contains(dst) {
+			return true
+		}
+	}
+	return false
+}
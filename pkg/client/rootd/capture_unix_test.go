@@ -0,0 +1,41 @@
+//go:build !windows
+// +build !windows
+
+package rootd
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client/socket"
+)
+
+func TestAuthorizeCapture(t *testing.T) {
+	switch runtime.GOOS {
+	case "linux", "darwin", "freebsd", "openbsd":
+	default:
+		t.Skipf("peer credentials are not available on %s", runtime.GOOS)
+	}
+	_, sc := unixPair(t)
+	ctx := socket.WithPeerUID(context.Background(), sc)
+	uid := os.Getuid()
+
+	s := &Service{sessionOwner: uid}
+	assert.NoError(t, s.authorizeCapture(ctx))
+
+	s.sessionOwner = uid + 1
+	if uid == 0 {
+		assert.NoError(t, s.authorizeCapture(ctx), "root may always capture")
+	} else {
+		assert.Equal(t, codes.PermissionDenied, status.Code(s.authorizeCapture(ctx)))
+	}
+
+	s.sessionOwner = -1
+	assert.Equal(t, codes.PermissionDenied, status.Code(s.authorizeCapture(context.Background())), "unknown caller")
+}
@@ -0,0 +1,118 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/cache"
+	"github.com/telepresenceio/telepresence/v2/pkg/dnsproxy"
+)
+
+// persistedEntry is the JSON representation of a cacheEntry that is persisted between sessions.
+type persistedEntry struct {
+	Name    string    `json:"name"`
+	QType   uint16    `json:"qtype"`
+	RCode   int       `json:"rcode"`
+	Answer  []string  `json:"answer,omitempty"`
+	Expires time.Time `json:"expires"`
+}
+
+// expires returns the time when the entry must no longer be used. That's when the shortest TTL of the records
+// in its answer has elapsed, counting from when the entry was created. An entry without records expires when
+// the local cache would expire it.
+func (dv *cacheEntry) expires() time.Time {
+	ttl := cacheTTL
+	for i, rr := range dv.answer {
+		if rrTTL := time.Duration(rr.Header().Ttl) * time.Second; i == 0 || rrTTL < ttl {
+			ttl = rrTTL
+		}
+	}
+	return dv.created.Add(ttl)
+}
+
+// SaveCache writes the entries of the local DNS cache that are still valid to the given file in the user cache,
+// so that a later session can use them while its connection to the cluster is established.
+func (s *Server) SaveCache(ctx context.Context, file string) error {
+	now := time.Now()
+	var pes []*persistedEntry
+	s.cache.Range(func(k, v any) bool {
+		dv := v.(*cacheEntry)
+		select {
+		case <-dv.wait:
+		default:
+			// Lookup in progress
+			return true
+		}
+		if dv.rCode != dns.RcodeSuccess || dv.expired() {
+			return true
+		}
+		expires := dv.expires()
+		if !expires.After(now) {
+			return true
+		}
+		key := k.(cacheKey)
+		pe := &persistedEntry{Name: key.name, QType: key.qType, RCode: dv.rCode, Expires: expires}
+		for _, rr := range dv.answer {
+			pe.Answer = append(pe.Answer, rr.String())
+		}
+		pes = append(pes, pe)
+		return true
+	})
+	if len(pes) == 0 {
+		return cache.DeleteFromUserCache(ctx, file)
+	}
+	dlog.Debugf(ctx, "Saving %d DNS cache entries", len(pes))
+	return cache.SaveToUserCache(ctx, pes, file)
+}
+
+// LoadCache adds the entries that a previous session saved to the given file in the user cache to the local
+// DNS cache. Entries that have expired are discarded. The file is removed once it has been loaded.
+func (s *Server) LoadCache(ctx context.Context, file string) error {
+	var pes []*persistedEntry
+	if err := cache.LoadFromUserCache(ctx, &pes, file); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return err
+	}
+	now := time.Now()
+	loaded := 0
+	for _, pe := range pes {
+		if !pe.Expires.After(now) {
+			continue
+		}
+		// The records get the TTL that remains, just like they would in a cache that counts down the TTL. An entry
+		// without records is created such that it expires when the persisted entry does.
+		remaining := uint32(pe.Expires.Sub(now) / time.Second)
+		created := now
+		if len(pe.Answer) == 0 {
+			created = pe.Expires.Add(-cacheTTL)
+		}
+		answer := make(dnsproxy.RRs, 0, len(pe.Answer))
+		for _, rs := range pe.Answer {
+			rr, err := dns.NewRR(rs)
+			if err != nil || rr == nil {
+				answer = nil
+				break
+			}
+			rr.Header().Ttl = remaining
+			answer = append(answer, rr)
+		}
+		if answer == nil {
+			dlog.Debugf(ctx, "Discarding unparsable DNS cache entry for %s", pe.Name)
+			continue
+		}
+		dv := &cacheEntry{wait: make(chan struct{}), created: created, answer: answer, rCode: pe.RCode}
+		dv.close()
+		if _, exists := s.cache.LoadOrStore(cacheKey{name: pe.Name, qType: pe.QType}, dv); !exists {
+			loaded++
+		}
+	}
+	dlog.Debugf(ctx, "Loaded %d DNS cache entries", loaded)
+	return cache.DeleteFromUserCache(ctx, file)
+}
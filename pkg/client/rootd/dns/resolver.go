@@ -0,0 +1,245 @@
+package dns
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/rpc/v2/dnsresolver"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/dnsproxy"
+)
+
+// Resolver resolves DNS questions. A Resolver that doesn't know the name in a question must
+// return dns.RcodeNameError.
+type Resolver interface {
+	Resolve(context.Context, *dns.Question) (dnsproxy.RRs, int, error)
+}
+
+// ResolverFunc is an adapter that allows the use of an ordinary function as a Resolver.
+type ResolverFunc func(context.Context, *dns.Question) (dnsproxy.RRs, int, error)
+
+// Resolve calls f(ctx, q).
+func (f ResolverFunc) Resolve(ctx context.Context, q *dns.Question) (dnsproxy.RRs, int, error) {
+	return f(ctx, q)
+}
+
+// NewResolver returns the Resolver that consults the given backends in order. The cluster resolver
+// is used for the CLUSTER backend, and is the only backend when no backends are given.
+func NewResolver(backends []*rpc.DNSResolver, cluster Resolver) Resolver {
+	if len(backends) == 0 {
+		return cluster
+	}
+	rs := make(resolverChain, len(backends))
+	for i, b := range backends {
+		switch b.Type {
+		case rpc.DNSResolver_HOSTS_FILE:
+			rs[i] = &hostsFileResolver{path: b.HostsFile}
+		case rpc.DNSResolver_STATIC:
+			rs[i] = newStaticResolver(b.Static)
+		case rpc.DNSResolver_PLUGIN:
+			rs[i] = &pluginResolver{socket: b.Socket}
+		default:
+			rs[i] = cluster
+		}
+	}
+	return rs
+}
+
+// closeResolver releases the resources held by the given resolver.
+func closeResolver(r Resolver) {
+	switch r := r.(type) {
+	case resolverChain:
+		for _, cr := range r {
+			closeResolver(cr)
+		}
+	case *pluginResolver:
+		r.close()
+	}
+}
+
+// resolverChain is a Resolver that returns the answer of the first Resolver that knows the name.
+type resolverChain []Resolver
+
+func (rs resolverChain) Resolve(ctx context.Context, q *dns.Question) (dnsproxy.RRs, int, error) {
+	var firstErr error
+	for _, r := range rs {
+		if r == nil {
+			continue
+		}
+		rrs, rCode, err := r.Resolve(ctx, q)
+		switch {
+		case err != nil:
+			if firstErr == nil {
+				firstErr = err
+			}
+			dlog.Debugf(ctx, "DNS resolver %T failed to resolve %s: %v", r, q.Name, err)
+		case rCode != dns.RcodeNameError:
+			return rrs, rCode, nil
+		}
+	}
+	if firstErr != nil {
+		return nil, dns.RcodeServerFailure, firstErr
+	}
+	return nil, dns.RcodeNameError, nil
+}
+
+// addressAnswer returns the A or AAAA records that answers the given question using the given
+// addresses. The answer is empty when the name is known, but no address of the requested type exists.
+func addressAnswer(q *dns.Question, ips []net.IP) dnsproxy.RRs {
+	var rrs dnsproxy.RRs
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			if q.Qtype == dns.TypeA {
+				rrs = append(rrs, &dns.A{Hdr: dnsproxy.NewHeader(q.Name, q.Qtype), A: ip4})
+			}
+		} else if q.Qtype == dns.TypeAAAA {
+			rrs = append(rrs, &dns.AAAA{Hdr: dnsproxy.NewHeader(q.Name, q.Qtype), AAAA: ip})
+		}
+	}
+	return rrs
+}
+
+// staticResolver resolves names using a fixed map of names to addresses.
+type staticResolver map[string][]net.IP
+
+func newStaticResolver(entries []*rpc.DNSResolver_StaticEntry) staticResolver {
+	sr := make(staticResolver, len(entries))
+	for _, e := range entries {
+		name := dns.Fqdn(strings.ToLower(e.Name))
+		for _, ip := range e.Ips {
+			sr[name] = append(sr[name], ip)
+		}
+	}
+	return sr
+}
+
+func (sr staticResolver) Resolve(_ context.Context, q *dns.Question) (dnsproxy.RRs, int, error) {
+	ips, ok := sr[strings.ToLower(q.Name)]
+	if !ok {
+		return nil, dns.RcodeNameError, nil
+	}
+	return addressAnswer(q, ips), dns.RcodeSuccess, nil
+}
+
+// hostsFileResolver resolves names using a file in the /etc/hosts format. The file is
+// parsed again when its modification time changes.
+type hostsFileResolver struct {
+	path string
+
+	sync.Mutex
+	modTime time.Time
+	hosts   staticResolver
+}
+
+func (hr *hostsFileResolver) Resolve(ctx context.Context, q *dns.Question) (dnsproxy.RRs, int, error) {
+	hosts, err := hr.load()
+	if err != nil {
+		return nil, dns.RcodeServerFailure, err
+	}
+	return hosts.Resolve(ctx, q)
+}
+
+func (hr *hostsFileResolver) load() (staticResolver, error) {
+	hr.Lock()
+	defer hr.Unlock()
+	st, err := os.Stat(hr.path)
+	if err != nil {
+		return nil, err
+	}
+	if hr.hosts != nil && st.ModTime().Equal(hr.modTime) {
+		return hr.hosts, nil
+	}
+	data, err := os.ReadFile(hr.path)
+	if err != nil {
+		return nil, err
+	}
+	hr.hosts = parseHostsFile(data)
+	hr.modTime = st.ModTime()
+	return hr.hosts, nil
+}
+
+// parseHostsFile parses lines of the form "<address> <name> [<alias>...]". Comments start
+// with a '#'. Lines that don't start with a valid address are ignored.
+func parseHostsFile(data []byte) staticResolver {
+	hosts := make(staticResolver)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			continue
+		}
+		for _, name := range fields[1:] {
+			name = dns.Fqdn(strings.ToLower(name))
+			hosts[name] = append(hosts[name], ip)
+		}
+	}
+	return hosts
+}
+
+// pluginResolver resolves names by calling a gRPC service that implements the
+// telepresence.dnsresolver.Resolver service on a unix socket.
+type pluginResolver struct {
+	socket string
+
+	sync.Mutex
+	conn   *grpc.ClientConn
+	client dnsresolver.ResolverClient
+}
+
+func (pr *pluginResolver) Resolve(ctx context.Context, q *dns.Question) (dnsproxy.RRs, int, error) {
+	rc, err := pr.resolverClient()
+	if err != nil {
+		return nil, dns.RcodeServerFailure, err
+	}
+	r, err := rc.Resolve(ctx, &manager.DNSRequest{Name: q.Name, Type: uint32(q.Qtype)})
+	if err != nil {
+		return nil, dns.RcodeServerFailure, fmt.Errorf("dns resolver plugin %s: %w", pr.socket, err)
+	}
+	return dnsproxy.FromRPC(r)
+}
+
+func (pr *pluginResolver) resolverClient() (dnsresolver.ResolverClient, error) {
+	pr.Lock()
+	defer pr.Unlock()
+	if pr.client == nil {
+		// The dial is non-blocking, so a plugin that isn't running yet will be connected once it is.
+		conn, err := grpc.Dial("unix:"+pr.socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return nil, err
+		}
+		pr.conn = conn
+		pr.client = dnsresolver.NewResolverClient(conn)
+	}
+	return pr.client, nil
+}
+
+func (pr *pluginResolver) close() {
+	pr.Lock()
+	defer pr.Unlock()
+	if pr.conn != nil {
+		_ = pr.conn.Close()
+		pr.conn = nil
+		pr.client = nil
+	}
+}
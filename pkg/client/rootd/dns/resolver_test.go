@@ -0,0 +1,140 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/rpc/v2/dnsresolver"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/dnsproxy"
+)
+
+type testPlugin struct {
+	dnsresolver.UnimplementedResolverServer
+}
+
+func (testPlugin) Resolve(_ context.Context, r *manager.DNSRequest) (*manager.DNSResponse, error) {
+	if r.Name != "plugin.corp." || r.Type != uint32(dns.TypeA) {
+		return dnsproxy.ToRPC(nil, dns.RcodeNameError)
+	}
+	return dnsproxy.ToRPC(dnsproxy.RRs{&dns.A{Hdr: dnsproxy.NewHeader(r.Name, dns.TypeA), A: net.IP{10, 0, 0, 3}}}, dns.RcodeSuccess)
+}
+
+func TestResolverChain(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	tmp := t.TempDir()
+
+	hostsFile := filepath.Join(tmp, "hosts")
+	require.NoError(t, os.WriteFile(hostsFile, []byte(`
+# A comment
+10.0.0.2   hosts.corp alias.corp   # trailing comment
+fd00::2    hosts.corp
+not-an-ip  ignored.corp
+`), 0o600))
+
+	socket := filepath.Join(tmp, "plugin.sock")
+	l, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	dnsresolver.RegisterResolverServer(srv, testPlugin{})
+	go func() { _ = srv.Serve(l) }()
+	defer srv.Stop()
+
+	var clusterQueries []string
+	cluster := ResolverFunc(func(_ context.Context, q *dns.Question) (dnsproxy.RRs, int, error) {
+		clusterQueries = append(clusterQueries, q.Name)
+		if q.Name == "svc.default." {
+			return dnsproxy.RRs{&dns.A{Hdr: dnsproxy.NewHeader(q.Name, dns.TypeA), A: net.IP{10, 96, 0, 1}}}, dns.RcodeSuccess, nil
+		}
+		return nil, dns.RcodeNameError, nil
+	})
+
+	r := NewResolver([]*rpc.DNSResolver{
+		{
+			Type: rpc.DNSResolver_STATIC,
+			Static: []*rpc.DNSResolver_StaticEntry{
+				{Name: "Static.Corp", Ips: [][]byte{net.IP{10, 0, 0, 1}.To4()}},
+			},
+		},
+		{Type: rpc.DNSResolver_HOSTS_FILE, HostsFile: hostsFile},
+		{Type: rpc.DNSResolver_PLUGIN, Socket: socket},
+		{Type: rpc.DNSResolver_CLUSTER},
+	}, cluster)
+	defer closeResolver(r)
+
+	resolve := func(name string, qType uint16) (dnsproxy.RRs, int) {
+		rrs, rCode, err := r.Resolve(ctx, &dns.Question{Name: name, Qtype: qType, Qclass: dns.ClassINET})
+		require.NoError(t, err)
+		return rrs, rCode
+	}
+	addresses := func(rrs dnsproxy.RRs) []string {
+		var as []string
+		for _, rr := range rrs {
+			switch rr := rr.(type) {
+			case *dns.A:
+				as = append(as, rr.A.String())
+			case *dns.AAAA:
+				as = append(as, rr.AAAA.String())
+			}
+		}
+		return as
+	}
+
+	rrs, rCode := resolve("static.corp.", dns.TypeA)
+	assert.Equal(t, dns.RcodeSuccess, rCode)
+	assert.Equal(t, []string{"10.0.0.1"}, addresses(rrs))
+
+	// The name is known, but has no AAAA record, so the chain ends here.
+	rrs, rCode = resolve("static.corp.", dns.TypeAAAA)
+	assert.Equal(t, dns.RcodeSuccess, rCode)
+	assert.Empty(t, rrs)
+
+	rrs, rCode = resolve("alias.corp.", dns.TypeA)
+	assert.Equal(t, dns.RcodeSuccess, rCode)
+	assert.Equal(t, []string{"10.0.0.2"}, addresses(rrs))
+
+	rrs, rCode = resolve("hosts.corp.", dns.TypeAAAA)
+	assert.Equal(t, dns.RcodeSuccess, rCode)
+	assert.Equal(t, []string{"fd00::2"}, addresses(rrs))
+
+	rrs, rCode = resolve("plugin.corp.", dns.TypeA)
+	assert.Equal(t, dns.RcodeSuccess, rCode)
+	assert.Equal(t, []string{"10.0.0.3"}, addresses(rrs))
+
+	rrs, rCode = resolve("svc.default.", dns.TypeA)
+	assert.Equal(t, dns.RcodeSuccess, rCode)
+	assert.Equal(t, []string{"10.96.0.1"}, addresses(rrs))
+
+	_, rCode = resolve("ignored.corp.", dns.TypeA)
+	assert.Equal(t, dns.RcodeNameError, rCode)
+
+	// Only names that no other resolver knows reach the cluster
+	assert.Equal(t, []string{"svc.default.", "ignored.corp."}, clusterQueries)
+}
+
+func TestResolverChain_failure(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	cluster := ResolverFunc(func(_ context.Context, q *dns.Question) (dnsproxy.RRs, int, error) {
+		return nil, dns.RcodeNameError, nil
+	})
+	r := NewResolver([]*rpc.DNSResolver{
+		{Type: rpc.DNSResolver_HOSTS_FILE, HostsFile: filepath.Join(t.TempDir(), "missing")},
+		{Type: rpc.DNSResolver_CLUSTER},
+	}, cluster)
+
+	// A failing resolver doesn't prevent the next one from answering, but its error is
+	// returned when no other resolver knows the name.
+	_, rCode, err := r.Resolve(ctx, &dns.Question{Name: "unknown.corp.", Qtype: dns.TypeA, Qclass: dns.ClassINET})
+	assert.Error(t, err)
+	assert.Equal(t, dns.RcodeServerFailure, rCode)
+}
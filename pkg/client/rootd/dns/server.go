@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/miekg/dns"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/durationpb"
 
 	"github.com/datawire/dlib/dcontext"
@@ -28,8 +29,6 @@ import (
 	"github.com/telepresenceio/telepresence/v2/pkg/vif"
 )
 
-type Resolver func(context.Context, *dns.Question) (dnsproxy.RRs, int, error)
-
 // recursionCheck is a special host name in a well known namespace that isn't expected to exist. It
 // is used once for determining if the cluster's DNS resolver will call the Telepresence DNS resolver
 // recursively. This is common when the cluster is running on the local host (k3s in docker for instance).
@@ -56,12 +55,15 @@ const (
 type Server struct {
 	ctx          context.Context // necessary to make logging work in ServeDNS function
 	fallbackPool FallbackPool
-	resolve      Resolver
+	resolve      ResolverFunc
 	requestCount int64
+	cacheHits    int64
+	failures     int64
 	cache        sync.Map
-	recursive    int32 // one of the recursionXXX constants declared above (unique type avoided because it just gets messy with the atomic calls)
+	recursive    int32  // one of the recursionXXX constants declared above (unique type avoided because it just gets messy with the atomic calls)
+	rcTarget     string // the host name used by the recursion check, recursionCheck unless configured otherwise
 	cacheResolve func(*dns.Question) (dnsproxy.RRs, int, error)
-	dropSuffixes []string //nolint:unused // only used on linux
+	dropSuffixes []string //nolint:unused // only used by the overriding server
 
 	// Namespaces, accessible using <service-name>.<namespace-name>
 	namespaces map[string]struct{}
@@ -79,12 +81,22 @@ type Server struct {
 	// configLock ensures thread safety for the DNS config for certain fields that can be modified remotely.
 	configLock sync.RWMutex
 
-	// clusterDomain reported by the traffic-manager
+	// rcResult is the outcome of the recursion check. Protected by configLock.
+	rcResult *rpc.RecursionCheckResult
+
+	// clusterDomain reported by the traffic-manager, unless overridden by the DNS config
 	clusterDomain string
 
-	// Function that sends a lookup request to the traffic-manager
+	// Resolver that looks up names in the cluster by sending a lookup request to the traffic-manager
 	clusterLookup Resolver
 
+	// Resolver that consults the configured backends in order. The cluster backend only resolves
+	// the names that are routed to the cluster.
+	resolver Resolver
+
+	// Function that is called with the addresses of A and AAAA records that were resolved for cluster names
+	onClusterAddresses func(ctx context.Context, ips []net.IP, external bool)
+
 	// onlyNames is set to true when using a legacy traffic-manager incapable of
 	// using query types
 	onlyNames bool
@@ -133,6 +145,14 @@ func NewServer(config *rpc.DNSConfig, clusterLookup Resolver, onlyNames bool) *S
 	if config.LookupTimeout.AsDuration() <= 0 {
 		config.LookupTimeout = durationpb.New(8 * time.Second)
 	}
+	rcTarget := recursionCheck
+	if config.RecursionCheckTarget != "" {
+		rcTarget = dns.Fqdn(config.RecursionCheckTarget)
+	}
+	clusterDomain := defaultClusterDomain
+	if config.ClusterDomain != "" {
+		clusterDomain = dns.Fqdn(strings.TrimPrefix(config.ClusterDomain, "."))
+	}
 	s := &Server{
 		config:        config,
 		configLock:    sync.RWMutex{},
@@ -140,12 +160,21 @@ func NewServer(config *rpc.DNSConfig, clusterLookup Resolver, onlyNames bool) *S
 		domains:       make(map[string]struct{}),
 		search:        []string{""},
 		searchPathCh:  make(chan []string, 5),
-		clusterDomain: defaultClusterDomain,
+		clusterDomain: clusterDomain,
 		clusterLookup: clusterLookup,
 		onlyNames:     onlyNames,
 		ready:         make(chan struct{}),
+		rcTarget:      rcTarget,
+		rcResult:      &rpc.RecursionCheckResult{Target: rcTarget},
+	}
+	s.resolver = NewResolver(config.Resolvers, ResolverFunc(s.resolveRoutedToCluster))
+	if config.SkipRecursionCheck {
+		s.recursive = recursionNotDetected
+		s.rcResult.Outcome = rpc.RecursionCheckResult_SKIPPED
+		s.cacheResolve = s.resolveThruCacheWithUnqualifiedHostName
+	} else {
+		s.cacheResolve = s.resolveWithRecursionCheck
 	}
-	s.cacheResolve = s.resolveWithRecursionCheck
 	return s
 }
 
@@ -271,15 +300,11 @@ func (s *Server) resolveInCluster(c context.Context, q *dns.Question) (result dn
 		}
 	}
 
-	if !s.shouldDoClusterLookup(query) {
-		return nil, dns.RcodeNameError, nil
-	}
-
-	// Give the cluster lookup a reasonable timeout.
+	// Give the lookup a reasonable timeout.
 	c, cancel := context.WithTimeout(c, s.config.LookupTimeout.AsDuration())
 	defer cancel()
 
-	result, rCode, err = s.clusterLookup(c, q)
+	result, rCode, err = s.resolver.Resolve(c, q)
 	if err != nil {
 		return nil, rCode, client.CheckTimeout(c, err)
 	}
@@ -297,6 +322,40 @@ func (s *Server) resolveInCluster(c context.Context, q *dns.Question) (result dn
 	return result, rCode, nil
 }
 
+// resolveRoutedToCluster resolves the given question in the cluster, provided that the name is routed
+// to the cluster. The configured backends that precede the cluster backend are consulted before this
+// routing is applied, so that they can resolve any name.
+func (s *Server) resolveRoutedToCluster(c context.Context, q *dns.Question) (dnsproxy.RRs, int, error) {
+	query := q.Name
+	if !s.shouldDoClusterLookup(query) {
+		return nil, dns.RcodeNameError, nil
+	}
+	result, rCode, err := s.clusterLookup.Resolve(c, q)
+	if err != nil {
+		return nil, rCode, err
+	}
+	if s.onClusterAddresses != nil && rCode == dns.RcodeSuccess && s.isClusterName(query) {
+		var ips []net.IP
+		external := false
+		for _, rr := range result {
+			switch rr := rr.(type) {
+			case *dns.A:
+				ips = append(ips, rr.A)
+			case *dns.AAAA:
+				ips = append(ips, rr.AAAA)
+			case *dns.CNAME:
+				if !s.isClusterName(strings.ToLower(rr.Target)) {
+					external = true
+				}
+			}
+		}
+		if len(ips) > 0 {
+			s.onClusterAddresses(c, ips, external)
+		}
+	}
+	return result, rCode, nil
+}
+
 func (s *Server) ResolveMappingAlias(query string) *string {
 	s.configLock.RLock()
 	defer s.configLock.RUnlock()
@@ -312,18 +371,34 @@ func (s *Server) ResolveMappingAlias(query string) *string {
 
 func (s *Server) GetConfig() *rpc.DNSConfig {
 	sc := s.config
+	s.configLock.RLock()
+	var rcResult *rpc.RecursionCheckResult
+	if s.rcResult != nil {
+		rcResult = proto.Clone(s.rcResult).(*rpc.RecursionCheckResult)
+	}
+	s.configLock.RUnlock()
 	return &rpc.DNSConfig{
-		LocalIp:         sc.LocalIp,
-		RemoteIp:        sc.RemoteIp,
-		ExcludeSuffixes: sc.ExcludeSuffixes,
-		IncludeSuffixes: sc.IncludeSuffixes,
-		Excludes:        sc.Excludes,
-		Mappings:        sc.Mappings,
-		LookupTimeout:   sc.LookupTimeout,
-		Error:           sc.Error,
+		LocalIp:               sc.LocalIp,
+		RemoteIp:              sc.RemoteIp,
+		ExcludeSuffixes:       sc.ExcludeSuffixes,
+		IncludeSuffixes:       sc.IncludeSuffixes,
+		Excludes:              sc.Excludes,
+		Mappings:              sc.Mappings,
+		LookupTimeout:         sc.LookupTimeout,
+		Error:                 sc.Error,
+		SkipRecursionCheck:    sc.SkipRecursionCheck,
+		RecursionCheckTarget:  sc.RecursionCheckTarget,
+		RecursionCheckTimeout: sc.RecursionCheckTimeout,
+		RecursionCheckResult:  rcResult,
+		ClusterDomain:         s.clusterDomain,
 	}
 }
 
+// ClusterDomain returns the cluster domain in effect, e.g. "cluster.local.".
+func (s *Server) ClusterDomain() string {
+	return s.clusterDomain
+}
+
 func (s *Server) Ready() <-chan struct{} {
 	return s.ready
 }
@@ -337,11 +412,37 @@ func (s *Server) Stop() {
 	}
 }
 
+// OnClusterAddresses registers a function that is called with the addresses that the cluster resolves for names
+// that are qualified with the cluster domain or a namespace. Such names may resolve to pod addresses, e.g. when the
+// name is a headless service. The external flag is set when the name is an alias for a name outside the cluster,
+// e.g. when the name is an ExternalName service.
+func (s *Server) OnClusterAddresses(f func(ctx context.Context, ips []net.IP, external bool)) {
+	s.onClusterAddresses = f
+}
+
+// isClusterName returns true if the given query is qualified with the cluster domain or with a namespace.
+func (s *Server) isClusterName(query string) bool {
+	if strings.HasSuffix(query, "."+s.clusterDomain) {
+		return true
+	}
+	labels := strings.Split(strings.TrimSuffix(query, "."), ".")
+	s.domainsLock.RLock()
+	defer s.domainsLock.RUnlock()
+	for _, l := range labels[1:] {
+		if _, ok := s.namespaces[l]; ok {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Server) SetClusterDNS(dns *manager.DNS, remoteIP net.IP) {
-	s.clusterDomain = dns.ClusterDomain
 	if s.config == nil {
 		s.config = &rpc.DNSConfig{}
 	}
+	if s.config.ClusterDomain == "" {
+		s.clusterDomain = dns.ClusterDomain
+	}
 	if s.config.RemoteIp == nil {
 		s.config.RemoteIp = remoteIP
 	}
@@ -492,6 +593,15 @@ func (s *Server) RequestCount() int {
 	return int(atomic.LoadInt64(&s.requestCount))
 }
 
+// Stats returns the request, cache hit, and failure counters of this server.
+func (s *Server) Stats() *rpc.DNSStats {
+	return &rpc.DNSStats{
+		Requests:  uint64(atomic.LoadInt64(&s.requestCount)),
+		CacheHits: uint64(atomic.LoadInt64(&s.cacheHits)),
+		Failures:  uint64(atomic.LoadInt64(&s.failures)),
+	}
+}
+
 func copyRRs(rrs dnsproxy.RRs, qTypes []uint16) dnsproxy.RRs {
 	if len(rrs) == 0 {
 		return rrs
@@ -505,6 +615,44 @@ func copyRRs(rrs dnsproxy.RRs, qTypes []uint16) dnsproxy.RRs {
 	return cp
 }
 
+// NameOf returns the shortest name in the local DNS cache that resolved to the given address, or an empty
+// string when no such name is cached.
+func (s *Server) NameOf(ip net.IP) string {
+	name := ""
+	s.cache.Range(func(k, v any) bool {
+		dv := v.(*cacheEntry)
+		select {
+		case <-dv.wait:
+		default:
+			// Lookup in progress
+			return true
+		}
+		if dv.expired() {
+			return true
+		}
+		for _, rr := range dv.answer {
+			var rrIP net.IP
+			switch rr := rr.(type) {
+			case *dns.A:
+				rrIP = rr.A
+			case *dns.AAAA:
+				rrIP = rr.AAAA
+			default:
+				continue
+			}
+			if rrIP.Equal(ip) {
+				n := strings.TrimSuffix(k.(cacheKey).name, ".")
+				if name == "" || len(n) < len(name) {
+					name = n
+				}
+				break
+			}
+		}
+		return true
+	})
+	return name
+}
+
 type cacheKey struct {
 	name  string
 	qType uint16
@@ -524,6 +672,7 @@ func (s *Server) resolveThruCache(q *dns.Question) (dnsproxy.RRs, int, error) {
 		}
 		<-oldDv.wait
 		if !oldDv.expired() {
+			atomic.AddInt64(&s.cacheHits, 1)
 			copyQType := q.Qtype
 			// If answer is a mapping, the copy type should be a CNAME.
 			if len(oldDv.answer) == 1 && oldDv.answer[0].Header().Rrtype == dns.TypeCNAME {
@@ -573,7 +722,7 @@ func (s *Server) resolveWithRecursionCheck(q *dns.Question) (dnsproxy.RRs, int,
 	key := cacheKey{name: q.Name, qType: q.Qtype}
 	if v, loaded := s.cache.LoadOrStore(key, newDv); loaded {
 		oldDv := v.(*cacheEntry)
-		if strings.HasPrefix(q.Name, recursionCheck) {
+		if strings.HasPrefix(q.Name, s.rcTarget) {
 			atomic.StoreInt32(&s.recursive, recursionDetected)
 		}
 		if atomic.LoadInt32(&s.recursive) == recursionDetected {
@@ -587,7 +736,7 @@ func (s *Server) resolveWithRecursionCheck(q *dns.Question) (dnsproxy.RRs, int,
 	}
 
 	answer, rCode, err := s.resolveQuery(q, newDv)
-	if strings.HasPrefix(q.Name, recursionCheck) {
+	if strings.HasPrefix(q.Name, s.rcTarget) {
 		if atomic.LoadInt32(&s.recursive) == recursionDetected {
 			dlog.Debug(s.ctx, "DNS resolver is recursive")
 		} else {
@@ -610,50 +759,99 @@ func (d dfs) String() string {
 
 func (s *Server) performRecursionCheck(c context.Context) {
 	defer close(s.ready)
+	if s.config.SkipRecursionCheck {
+		dlog.Info(c, "Recursion check skipped")
+		return
+	}
 	defer dlog.Debug(c, "Recursion check finished")
 	var rc string
 	if runtime.GOOS != "darwin" {
-		rc = recursionCheck + tel2SubDomain
+		rc = s.rcTarget + tel2SubDomain
 	} else {
-		rc = recursionCheck + s.clusterDomain
+		rc = s.rcTarget + s.clusterDomain
+	}
+	timeout := s.config.RecursionCheckTimeout.AsDuration()
+	if timeout <= 0 {
+		timeout = recursionTestTimeout
 	}
 	dlog.Debugf(c, "Performing initial recursion check with %s", rc)
-	i := 0
+	start := time.Now()
+	attempts := 0
+	var lastErr error
+	defer func() {
+		s.setRecursionCheckResult(attempts, time.Since(start), lastErr)
+	}()
 	atomic.StoreInt32(&s.recursive, recursionTestInProgress)
-	for ; i < maxRecursionTestRetries && atomic.LoadInt32(&s.recursive) == recursionTestInProgress; i++ {
+	for ; attempts < maxRecursionTestRetries && atomic.LoadInt32(&s.recursive) == recursionTestInProgress; attempts++ {
 		// Recursion is typically very fast (all on the same host) so let's
 		// use short timeouts
-		if i > 0 {
+		if attempts > 0 {
 			dlog.Debug(c, "retrying recursion check")
 		}
-		tc, cancel := context.WithTimeout(c, recursionTestTimeout)
+		tc, cancel := context.WithTimeout(c, timeout)
 		_, err := net.DefaultResolver.LookupIP(tc, "ip4", rc)
 		cancel()
 		if err != nil {
 			if derr, ok := err.(*net.DNSError); ok {
 				if atomic.LoadInt32(&s.recursive) != recursionTestInProgress {
 					if derr.IsTimeout || derr.IsNotFound {
+						attempts++
 						return
 					}
 				}
 				if derr.IsTimeout {
+					lastErr = err
 					dtime.SleepWithContext(c, 200*time.Millisecond)
 					continue
 				}
 			}
+			lastErr = err
 			dlog.Errorf(c, "unexpected error during recursion check: %v", err)
+		} else {
+			// Something, e.g. a captive portal, answered the probe.
+			lastErr = fmt.Errorf("%s was resolved without reaching the Telepresence DNS resolver", rc)
 		}
 		if atomic.LoadInt32(&s.recursive) != recursionTestInProgress {
+			attempts++
 			return
 		}
 		// Check didn't hit our resolver. Try again
 		dtime.SleepWithContext(c, 100*time.Millisecond)
 	}
-	if i == maxRecursionTestRetries {
+	if attempts == maxRecursionTestRetries {
 		s.config.Error = "DNS doesn't seem to work properly"
 	}
 }
 
+// setRecursionCheckResult records the outcome of the recursion check so that it can be
+// reported by GetConfig.
+func (s *Server) setRecursionCheckResult(attempts int, duration time.Duration, err error) {
+	r := &rpc.RecursionCheckResult{
+		Target:   s.rcTarget,
+		Attempts: int32(attempts),
+		Duration: durationpb.New(duration),
+	}
+	switch atomic.LoadInt32(&s.recursive) {
+	case recursionDetected:
+		r.Outcome = rpc.RecursionCheckResult_RECURSIVE
+	case recursionNotDetected:
+		r.Outcome = rpc.RecursionCheckResult_NOT_RECURSIVE
+	default:
+		if attempts < maxRecursionTestRetries {
+			// Interrupted before the check completed.
+			r.Outcome = rpc.RecursionCheckResult_PENDING
+		} else {
+			r.Outcome = rpc.RecursionCheckResult_FAILED
+		}
+		if err != nil {
+			r.Error = err.Error()
+		}
+	}
+	s.configLock.Lock()
+	s.rcResult = r
+	s.configLock.Unlock()
+}
+
 // ServeDNS is an implementation of github.com/miekg/dns Handler.ServeDNS.
 func (s *Server) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 	c := s.ctx
@@ -682,6 +880,9 @@ func (s *Server) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 
 	defer func() {
 		dlog.Debugf(c, "%s%5d %-6s %s -> %s %s", pfx, r.Id, qts, q.Name, rct, txt)
+		if msg != nil && msg.Rcode == dns.RcodeServerFailure {
+			atomic.AddInt64(&s.failures, 1)
+		}
 		_ = w.WriteMsg(msg)
 	}()
 
@@ -690,7 +891,7 @@ func (s *Server) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 		case dns.TypeA:
 			answer, rCode, err = s.cacheResolve(q)
 		case dns.TypeAAAA:
-			if atomic.LoadInt32(&s.recursive) == recursionDetected || q.Name == recursionCheck {
+			if atomic.LoadInt32(&s.recursive) == recursionDetected || q.Name == s.rcTarget {
 				rCode = dns.RcodeNameError
 				break
 			}
@@ -731,7 +932,7 @@ func (s *Server) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 
 	// The recursion check query, or queries that end with the cluster domain name, are not dispatched to the
 	// fallback DNS-server.
-	if s.fallbackPool == nil || strings.HasPrefix(q.Name, recursionCheck) || strings.HasSuffix(q.Name, s.clusterDomain) {
+	if s.fallbackPool == nil || strings.HasPrefix(q.Name, s.rcTarget) || strings.HasSuffix(q.Name, s.clusterDomain) {
 		if err == nil {
 			rCode = dns.RcodeNameError
 		} else {
@@ -836,10 +1037,11 @@ func (s *Server) resolveQuery(q *dns.Question, dv *cacheEntry) (dnsproxy.RRs, in
 }
 
 // Run starts the DNS server(s) and waits for them to end.
-func (s *Server) Run(c context.Context, initDone chan<- struct{}, listeners []net.PacketConn, fallbackPool FallbackPool, resolve Resolver) error {
+func (s *Server) Run(c context.Context, initDone chan<- struct{}, listeners []net.PacketConn, fallbackPool FallbackPool, resolve ResolverFunc) error {
 	s.ctx = c
 	s.fallbackPool = fallbackPool
 	s.resolve = resolve
+	defer closeResolver(s.resolver)
 
 	g := dgroup.NewGroup(c, dgroup.GroupConfig{})
 	for _, listener := range listeners {
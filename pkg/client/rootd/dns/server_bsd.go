@@ -0,0 +1,24 @@
+//go:build freebsd || openbsd
+
+package dns
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/datawire/dlib/dgroup"
+	"github.com/telepresenceio/telepresence/v2/pkg/vif"
+)
+
+const (
+	maxRecursionTestRetries = 10
+	recursionTestTimeout    = 500 * time.Millisecond
+)
+
+// Worker runs the overriding server, which redirects the DNS traffic intended for the nameserver in
+// /etc/resolv.conf to the local DNS server using pf rules. FreeBSD and OpenBSD have no equivalent of
+// systemd-resolved or of the /etc/resolver files of macOS that the DNS server could register with.
+func (s *Server) Worker(c context.Context, dev vif.Device, _ func(net.IP, *net.UDPAddr)) error {
+	return s.runOverridingServer(dgroup.WithGoroutineName(c, "/pf"), dev)
+}
@@ -9,8 +9,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/datawire/dlib/dcontext"
 	"github.com/datawire/dlib/dgroup"
 	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/journal"
 	"github.com/telepresenceio/telepresence/v2/pkg/maps"
 	"github.com/telepresenceio/telepresence/v2/pkg/vif"
 )
@@ -31,10 +33,23 @@ func (r *resolveFile) setSearchPaths(paths ...string) {
 	r.search = ps
 }
 
-func (r *resolveFile) write(fileName string) error {
+func (r *resolveFile) write(c context.Context, fileName string) error {
 	var buf bytes.Buffer
 	_, _ = r.WriteTo(&buf)
-	return os.WriteFile(fileName, buf.Bytes(), 0o644)
+	if err := os.WriteFile(fileName, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	journal.Record(c, journal.KindFile, fileName, nil)
+	return nil
+}
+
+// removeResolverFile removes the given resolver file and its journal entry.
+func removeResolverFile(c context.Context, fileName string) error {
+	if err := os.Remove(fileName); err != nil {
+		return err
+	}
+	journal.Forget(c, journal.KindFile, fileName)
+	return nil
 }
 
 // Worker places a file under the /etc/resolver directory so that it is picked up by the
@@ -84,18 +99,19 @@ func (s *Server) Worker(c context.Context, dev vif.Device, configureDNS func(net
 		search:      []string{tel2SubDomainDot + kubernetesZone},
 	}
 
-	if err = rf.write(resolverFileName); err != nil {
+	if err = rf.write(c, resolverFileName); err != nil {
 		return err
 	}
 	dlog.Infof(c, "Generated new %s", resolverFileName)
 
 	defer func() {
 		// Remove the main resolver file
-		_ = os.Remove(resolverFileName)
+		c := dcontext.WithoutCancel(c)
+		_ = removeResolverFile(c, resolverFileName)
 
 		// Remove each namespace resolver file
 		for domain := range s.domains {
-			_ = os.Remove(domainResolverFile(resolverDirName, domain))
+			_ = removeResolverFile(c, domainResolverFile(resolverDirName, domain))
 		}
 		s.flushDNS()
 	}()
@@ -122,7 +138,7 @@ func (s *Server) removeResolverFiles(c context.Context, resolverDirName string)
 		if n := file.Name(); strings.HasPrefix(n, "telepresence.") {
 			fn := filepath.Join(resolverDirName, n)
 			dlog.Debugf(c, "Removing file %q", fn)
-			if err := os.Remove(fn); err != nil {
+			if err := removeResolverFile(c, fn); err != nil {
 				return err
 			}
 		}
@@ -184,7 +200,7 @@ func (s *Server) updateResolverFiles(c context.Context, resolverDirName, resolve
 	for _, domain := range removals {
 		nsFile := domainResolverFile(resolverDirName, domain)
 		dlog.Infof(c, "Removing %s", nsFile)
-		if err = os.Remove(nsFile); err != nil {
+		if err = removeResolverFile(c, nsFile); err != nil {
 			dlog.Error(c, err)
 		}
 	}
@@ -196,7 +212,7 @@ func (s *Server) updateResolverFiles(c context.Context, resolverDirName, resolve
 		}
 		nsFile := domainResolverFile(resolverDirName, domain)
 		dlog.Infof(c, "Generated new %s", nsFile)
-		if err = df.write(nsFile); err != nil {
+		if err = df.write(c, nsFile); err != nil {
 			dlog.Error(c, err)
 		}
 	}
@@ -205,8 +221,8 @@ func (s *Server) updateResolverFiles(c context.Context, resolverDirName, resolve
 
 	// Versions prior to Big Sur will not trigger an update unless the resolver file
 	// is removed and recreated.
-	_ = os.Remove(resolverFileName)
-	if err = rf.write(resolverFileName); err != nil {
+	_ = removeResolverFile(c, resolverFileName)
+	if err = rf.write(c, resolverFileName); err != nil {
 		return err
 	}
 	s.flushDNS()
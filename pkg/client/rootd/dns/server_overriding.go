@@ -0,0 +1,314 @@
+//go:build linux || freebsd || openbsd
+
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/datawire/dlib/dcontext"
+	"github.com/datawire/dlib/dexec"
+	"github.com/datawire/dlib/dgroup"
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/dlib/dtime"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/svcalias"
+	"github.com/telepresenceio/telepresence/v2/pkg/dnsproxy"
+	"github.com/telepresenceio/telepresence/v2/pkg/firewall"
+	"github.com/telepresenceio/telepresence/v2/pkg/forwarder"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/journal"
+	"github.com/telepresenceio/telepresence/v2/pkg/proc"
+	"github.com/telepresenceio/telepresence/v2/pkg/vif"
+)
+
+// shouldApplySearch returns true if search path should be applied.
+func (s *Server) shouldApplySearch(query string) bool {
+	if len(s.search) == 0 {
+		return false
+	}
+
+	if query == "localhost." {
+		return false
+	}
+
+	// Don't apply search paths to the kubernetes zone
+	if strings.HasSuffix(query, "."+s.clusterDomain) {
+		return false
+	}
+
+	// Don't apply search paths if one is already there
+	for _, s := range s.search {
+		if strings.HasSuffix(query, s) {
+			return false
+		}
+	}
+
+	// Don't apply search path to namespaces or "svc".
+	query = query[:len(query)-1]
+	if lastDot := strings.LastIndexByte(query, '.'); lastDot >= 0 {
+		tld := query[lastDot+1:]
+		if _, ok := s.namespaces[tld]; ok || tld == "svc" {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveInSearch is only used by the overriding resolver. It is needed because unlike other resolvers, this
+// resolver does not hook into a DNS system that handles search paths prior to the arrival of the request.
+//
+// TODO: With the DNS lookups now being done in the cluster, there's only one reason left to have a search path,
+// and that's the local-only intercepts which means that using search-paths really should be limited to that
+// use-case.
+func (s *Server) resolveInSearch(c context.Context, q *dns.Question) (dnsproxy.RRs, int, error) {
+	query := strings.ToLower(q.Name)
+
+	// Drop all known search path suffixes before sending the query to the cluster. The
+	// cluster has its own DNS resolver and its own set of search paths.
+	query = strings.TrimSuffix(query, tel2SubDomainDot)
+	for _, sfx := range s.dropSuffixes {
+		query = strings.TrimSuffix(query, sfx)
+	}
+
+	if !s.shouldDoClusterLookup(query) {
+		// The name isn't routed to the cluster, but the configured backends may know it.
+		return s.resolveInCluster(c, q)
+	}
+
+	if s.shouldApplySearch(query) {
+		origQuery := q.Name
+		for _, sp := range s.search {
+			q.Name = query + sp
+			if rrs, rCode, err := s.resolveInCluster(c, q); err != nil || len(rrs) > 0 {
+				q.Name = origQuery
+				return rrs, rCode, err
+			}
+		}
+		q.Name = origQuery
+	}
+	return s.resolveInCluster(c, q)
+}
+
+func (s *Server) runOverridingServer(c context.Context, dev vif.Device) error {
+	if s.config.LocalIp == nil {
+		rf, err := readResolveFile("/etc/resolv.conf")
+		if err != nil {
+			return err
+		}
+		dlog.Debug(c, rf.String())
+		if len(rf.nameservers) > 0 {
+			ip := iputil.Parse(rf.nameservers[0])
+			s.config.LocalIp = ip
+			dlog.Infof(c, "Automatically set -dns=%s", ip)
+		}
+
+		// The search entries in /etc/resolv.conf is not intended for this resolver so
+		// ensure that we strip them off when we send queries to the cluster.
+		for _, sp := range rf.search {
+			s.dropSuffixes = append(s.dropSuffixes, sp+".")
+		}
+	}
+	if s.config.LocalIp == nil {
+		return errors.New("couldn't determine dns ip from /etc/resolv.conf")
+	}
+
+	listeners, err := s.dnsListeners(c)
+	if err != nil {
+		return err
+	}
+	dnsResolverAddr, err := splitToUDPAddr(listeners[0].LocalAddr())
+	if err != nil {
+		return err
+	}
+	dlog.Debugf(c, "Bootstrapping local DNS server on port %d", dnsResolverAddr.Port)
+
+	// Create the connection pool later used for fallback. We need to create this before the firewall
+	// rule because the rule must exclude the local address of this connection in order to
+	// let it reach the original destination and not cause an endless loop.
+	pool, err := NewConnPool(net.IP(s.config.LocalIp).String(), 10)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		pool.Close()
+	}()
+
+	serverStarted := make(chan struct{})
+	serverDone := make(chan struct{})
+	g := dgroup.NewGroup(c, dgroup.GroupConfig{})
+	g.Go("Server", func(c context.Context) error {
+		defer close(serverDone)
+		// Server will close the listener, so no need to close it here.
+		s.processSearchPaths(g, func(c context.Context, paths []string, _ vif.Device) error {
+			namespaces := make(map[string]struct{})
+			search := make([]string, 0)
+			for _, path := range paths {
+				if strings.ContainsRune(path, '.') {
+					search = append(search, path)
+				} else if path != "" {
+					namespaces[path] = struct{}{}
+				}
+			}
+			s.domainsLock.Lock()
+			s.namespaces = namespaces
+			s.search = search
+			s.domainsLock.Unlock()
+			s.flushDNS()
+			return nil
+		}, dev)
+		return s.Run(c, serverStarted, listeners, pool, s.resolveInSearch)
+	})
+
+	if proc.RunningInContainer() {
+		g.Go("Local DNS", func(c context.Context) error {
+			select {
+			case <-c.Done():
+			case <-serverStarted:
+				// Give DNS server time to start before rerouting NAT
+				dtime.SleepWithContext(c, time.Millisecond)
+
+				lc := net.ListenConfig{}
+				pc, err := lc.ListenPacket(c, "udp", ":53")
+				if err != nil {
+					return nil
+				}
+				go func() {
+					if r := svcalias.GetRegistry(c); r != nil {
+						// Other containers on the daemon's network use this server, so it must resolve the aliases
+						// that make the cluster's services reachable from them.
+						err = r.ServeDNS(c, pc, dnsResolverAddr.String())
+					} else {
+						err = forwarder.ForwardUDP(c, pc.(*net.UDPConn), dnsResolverAddr)
+					}
+					if err != nil {
+						dlog.Error(c, err)
+					}
+				}()
+			}
+			return nil
+		})
+	}
+
+	g.Go("NAT-redirect", func(c context.Context) error {
+		select {
+		case <-c.Done():
+		case <-serverStarted:
+			// Give DNS server time to start before rerouting NAT
+			dtime.SleepWithContext(c, time.Millisecond)
+
+			fw, err := firewall.Select(client.GetConfig(c).OSSpecific().Network.Firewall)
+			if err != nil {
+				return err
+			}
+			dlog.Debugf(c, "Using %s to redirect DNS traffic", fw.Name())
+			if err = fw.RedirectDNS(c, s.config.LocalIp, dnsResolverAddr, pool.LocalAddrs()); err != nil {
+				return err
+			}
+			journal.Record(c, journal.KindFirewall, fw.Name(), nil)
+			defer func() {
+				// We specifically don't want to use the cancellation of 'c' here, because we don't ever
+				// want to leave things in a half-cleaned-up state.
+				c := dcontext.WithoutCancel(c)
+				if err := fw.RemoveRules(c); err != nil {
+					dlog.Errorf(c, "failed to remove %s rules: %v", fw.Name(), err)
+				} else {
+					journal.Forget(c, journal.KindFirewall, fw.Name())
+				}
+				s.flushDNS()
+			}()
+			s.flushDNS()
+			<-serverDone // Stay alive until DNS server is done
+		}
+		return nil
+	})
+	return g.Wait()
+}
+
+func (s *Server) dnsListeners(c context.Context) ([]net.PacketConn, error) {
+	listener, err := newLocalUDPListener(c)
+	if err != nil {
+		return nil, err
+	}
+	listeners := []net.PacketConn{listener}
+	if proc.RunningInContainer() {
+		// Inside docker. Don't add docker bridge
+		return listeners, nil
+	}
+
+	// This is the default docker bridge. We need to listen here because the nat logic we use to intercept
+	// dns packets will divert the packet to the interface it originates from, which in the case of
+	// containers is the docker bridge. Without this dns won't work from inside containers.
+	output, err := dexec.CommandContext(c, "docker", "inspect", "bridge",
+		"-f", "{{(index .IPAM.Config 0).Gateway}}").Output()
+	if err != nil {
+		dlog.Info(c, "not listening on docker bridge")
+		return listeners, nil
+	}
+
+	localAddr, err := splitToUDPAddr(listener.LocalAddr())
+	if err != nil {
+		return nil, err
+	}
+
+	dockerGatewayIP := net.ParseIP(strings.TrimSpace(string(output)))
+	if dockerGatewayIP == nil || dockerGatewayIP.Equal(localAddr.IP) {
+		return listeners, nil
+	}
+
+	// Check that the dockerGatewayIP is registered as an interface on this machine. When running WSL2 on
+	// a Windows box, the gateway is managed by Windows and never visible to the Linux host and hence
+	// will not be affected by the nat logic. Also, any attempt to listen to it will fail.
+	found := false
+	ifAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, ifAddr := range ifAddrs {
+		_, network, err := net.ParseCIDR(ifAddr.String())
+		if err != nil {
+			continue
+		}
+		if network.Contains(dockerGatewayIP) {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		dlog.Infof(c, "docker gateway %s is not visible as a network interface", dockerGatewayIP)
+		return listeners, nil
+	}
+
+	for {
+		extraAddr := &net.UDPAddr{IP: dockerGatewayIP, Port: localAddr.Port}
+		ls, err := net.ListenPacket("udp", extraAddr.String())
+		if err == nil {
+			dlog.Infof(c, "listening to docker bridge at %s", dockerGatewayIP)
+			return append(listeners, ls), nil
+		}
+
+		// the extraAddr was busy, try next available port
+		for localAddr.Port++; localAddr.Port <= math.MaxUint16; localAddr.Port++ {
+			if ls, err = net.ListenPacket("udp", localAddr.String()); err == nil {
+				if localAddr, err = splitToUDPAddr(ls.LocalAddr()); err != nil {
+					ls.Close()
+					return nil, err
+				}
+				_ = listeners[0].Close()
+				listeners = []net.PacketConn{ls}
+				break
+			}
+		}
+		if localAddr.Port > math.MaxUint16 {
+			return nil, fmt.Errorf("unable to find a free port for both %s and %s", localAddr.IP, extraAddr.IP)
+		}
+	}
+}
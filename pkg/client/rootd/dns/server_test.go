@@ -1,15 +1,22 @@
 package dns
 
 import (
+	"context"
+	"net"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/miekg/dns"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
+	"github.com/datawire/dlib/dlog"
 	rpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/dnsproxy"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
 )
 
 type suiteServer struct {
@@ -103,6 +110,143 @@ func (s *suiteServer) TestIsExcluded() {
 	assert.False(s.T(), s.server.isExcluded("something-else."))
 }
 
+func (s *suiteServer) TestIsClusterName() {
+	// given
+	s.server.clusterDomain = "cluster.local."
+	s.server.namespaces = map[string]struct{}{"blue": {}}
+
+	// when & then
+	assert.True(s.T(), s.server.isClusterName("echo-headless.green.svc.cluster.local."))
+	assert.True(s.T(), s.server.isClusterName("echo-headless.blue."))
+	assert.True(s.T(), s.server.isClusterName("echo-0.echo-headless.blue."))
+	assert.False(s.T(), s.server.isClusterName("blue."))
+	assert.False(s.T(), s.server.isClusterName("www.example.com."))
+}
+
 func TestServerTestSuite(t *testing.T) {
 	suite.Run(t, new(suiteServer))
 }
+
+func TestRecursionCheckConfig(t *testing.T) {
+	s := NewServer(&rpc.DNSConfig{RecursionCheckTarget: "tel2-probe.default"}, nil, false)
+	assert.Equal(t, "tel2-probe.default.", s.rcTarget)
+	r := s.GetConfig().RecursionCheckResult
+	assert.Equal(t, rpc.RecursionCheckResult_PENDING, r.Outcome)
+	assert.Equal(t, "tel2-probe.default.", r.Target)
+
+	s = NewServer(&rpc.DNSConfig{SkipRecursionCheck: true}, nil, false)
+	assert.Equal(t, recursionCheck, s.rcTarget)
+	s.performRecursionCheck(dlog.NewTestContext(t, false))
+	select {
+	case <-s.Ready():
+	default:
+		t.Fatal("server is not ready after a skipped recursion check")
+	}
+	r = s.GetConfig().RecursionCheckResult
+	assert.Equal(t, rpc.RecursionCheckResult_SKIPPED, r.Outcome)
+	assert.Zero(t, r.Attempts)
+	assert.Equal(t, int32(recursionNotDetected), s.recursive)
+}
+
+func TestClusterDomainOverride(t *testing.T) {
+	s := NewServer(&rpc.DNSConfig{}, nil, false)
+	assert.Equal(t, defaultClusterDomain, s.ClusterDomain())
+	s.SetClusterDNS(&manager.DNS{ClusterDomain: "corp.internal."}, nil)
+	assert.Equal(t, "corp.internal.", s.ClusterDomain())
+	assert.Equal(t, "corp.internal.", s.GetConfig().ClusterDomain)
+
+	s = NewServer(&rpc.DNSConfig{ClusterDomain: "example.internal"}, nil, false)
+	assert.Equal(t, "example.internal.", s.ClusterDomain())
+	s.SetClusterDNS(&manager.DNS{ClusterDomain: "cluster.local."}, nil)
+	assert.Equal(t, "example.internal.", s.ClusterDomain())
+}
+
+func TestPersistedCache(t *testing.T) {
+	ctx := filelocation.WithAppUserCacheDir(dlog.NewTestContext(t, false), t.TempDir())
+	newEntry := func(created time.Time, rrs ...string) *cacheEntry {
+		dv := &cacheEntry{wait: make(chan struct{}), created: created}
+		for _, r := range rrs {
+			rr, err := dns.NewRR(r)
+			require.NoError(t, err)
+			dv.answer = append(dv.answer, rr)
+		}
+		dv.close()
+		return dv
+	}
+	now := time.Now()
+	s := NewServer(&rpc.DNSConfig{}, nil, false)
+	s.cache.Store(cacheKey{name: "long.default.", qType: dns.TypeA}, newEntry(now.Add(-10*time.Second), "long.default. 300 IN A 10.0.0.1"))
+	s.cache.Store(cacheKey{name: "short.default.", qType: dns.TypeA}, newEntry(now.Add(-10*time.Second), "short.default. 5 IN A 10.0.0.2"))
+	s.cache.Store(cacheKey{name: "old.default.", qType: dns.TypeA}, newEntry(now.Add(-2*cacheTTL), "old.default. 300 IN A 10.0.0.3"))
+	s.cache.Store(cacheKey{name: "pending.default.", qType: dns.TypeA}, &cacheEntry{wait: make(chan struct{}), created: now})
+	require.NoError(t, s.SaveCache(ctx, "dns-test.json"))
+
+	s = NewServer(&rpc.DNSConfig{}, nil, false)
+	require.NoError(t, s.LoadCache(ctx, "dns-test.json"))
+	var names []string
+	s.cache.Range(func(k, v any) bool {
+		names = append(names, k.(cacheKey).name)
+		return true
+	})
+	assert.Equal(t, []string{"long.default."}, names)
+	rrs, rCode, err := s.resolveThruCache(&dns.Question{Name: "long.default.", Qtype: dns.TypeA, Qclass: dns.ClassINET})
+	require.NoError(t, err)
+	assert.Equal(t, dns.RcodeSuccess, rCode)
+	require.Len(t, rrs, 1)
+	assert.Equal(t, "10.0.0.1", rrs[0].(*dns.A).A.String())
+	assert.LessOrEqual(t, rrs[0].Header().Ttl, uint32(290))
+	assert.Equal(t, "long.default", s.NameOf(rrs[0].(*dns.A).A))
+
+	// The file is removed once it has been loaded.
+	require.NoError(t, s.LoadCache(ctx, "dns-test.json"))
+}
+
+func TestResolveInCluster_backendsBeforeRouting(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	var clusterQueries []string
+	cluster := ResolverFunc(func(_ context.Context, q *dns.Question) (dnsproxy.RRs, int, error) {
+		clusterQueries = append(clusterQueries, q.Name)
+		return dnsproxy.RRs{&dns.A{Hdr: dnsproxy.NewHeader(q.Name, dns.TypeA), A: net.IP{10, 96, 0, 1}}}, dns.RcodeSuccess, nil
+	})
+	s := NewServer(&rpc.DNSConfig{
+		Excludes: []string{"excluded.default"},
+		Resolvers: []*rpc.DNSResolver{
+			{
+				Type: rpc.DNSResolver_STATIC,
+				Static: []*rpc.DNSResolver_StaticEntry{
+					{Name: "myhost.com", Ips: [][]byte{net.IP{10, 0, 0, 1}.To4()}},
+					{Name: "excluded.default", Ips: [][]byte{net.IP{10, 0, 0, 2}.To4()}},
+				},
+			},
+			{Type: rpc.DNSResolver_CLUSTER},
+		},
+	}, cluster, false)
+
+	resolve := func(name string) (string, int) {
+		rrs, rCode, err := s.resolveInCluster(ctx, &dns.Question{Name: name, Qtype: dns.TypeA, Qclass: dns.ClassINET})
+		require.NoError(t, err)
+		if len(rrs) == 0 {
+			return "", rCode
+		}
+		return rrs[0].(*dns.A).A.String(), rCode
+	}
+
+	// Names that aren't routed to the cluster are still resolved by the backends that precede it.
+	ip, rCode := resolve("myhost.com.")
+	assert.Equal(t, dns.RcodeSuccess, rCode)
+	assert.Equal(t, "10.0.0.1", ip)
+
+	ip, rCode = resolve("excluded.default.")
+	assert.Equal(t, dns.RcodeSuccess, rCode)
+	assert.Equal(t, "10.0.0.2", ip)
+
+	// The cluster backend applies the routing.
+	_, rCode = resolve("other.com.")
+	assert.Equal(t, dns.RcodeNameError, rCode)
+
+	ip, rCode = resolve("svc.default.")
+	assert.Equal(t, dns.RcodeSuccess, rCode)
+	assert.Equal(t, "10.96.0.1", ip)
+
+	assert.Equal(t, []string{"svc.default."}, clusterQueries)
+}
@@ -0,0 +1,111 @@
+package rootd
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/ipproto"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
+)
+
+// maxFlowDestinations is the maximum number of destinations that flowStats keeps track of. The
+// destination that has been inactive for the longest time is evicted to make room for a new one.
+const maxFlowDestinations = 2048
+
+type flowKey struct {
+	proto int
+	ip    iputil.IPKey
+	port  uint16
+}
+
+// destinationStats are the counters for one destination. All fields but name are updated atomically.
+type destinationStats struct {
+	name        string
+	ingress     uint64
+	egress      uint64
+	connections uint64
+	lastActive  int64 // unix nanoseconds
+}
+
+func (ds *destinationStats) touch() {
+	atomic.StoreInt64(&ds.lastActive, time.Now().UnixNano())
+}
+
+// flowStats keeps track of the traffic sent through the tunnel, per destination.
+type flowStats struct {
+	sync.Mutex
+	destinations map[flowKey]*destinationStats
+
+	// nameOf returns a name for the given IP, or an empty string if no name is known.
+	nameOf func(net.IP) string
+}
+
+func newFlowStats(nameOf func(net.IP) string) *flowStats {
+	return &flowStats{destinations: make(map[flowKey]*destinationStats), nameOf: nameOf}
+}
+
+// connectionOpened returns the destinationStats for the destination of the given connection, after
+// incrementing its connection count.
+func (fs *flowStats) connectionOpened(id tunnel.ConnID) *destinationStats {
+	key := flowKey{proto: id.Protocol(), ip: iputil.IPKey(id.Destination()), port: id.DestinationPort()}
+	fs.Lock()
+	ds, ok := fs.destinations[key]
+	fs.Unlock()
+	if !ok {
+		// The name lookup is done before the stats are shared so that the name needs no synchronization,
+		// and outside the lock because it may be slow.
+		nds := &destinationStats{}
+		if fs.nameOf != nil {
+			nds.name = fs.nameOf(id.Destination())
+		}
+		fs.Lock()
+		if ds, ok = fs.destinations[key]; !ok {
+			if len(fs.destinations) >= maxFlowDestinations {
+				fs.evictOldestLocked()
+			}
+			ds = nds
+			fs.destinations[key] = ds
+		}
+		fs.Unlock()
+	}
+	atomic.AddUint64(&ds.connections, 1)
+	ds.touch()
+	return ds
+}
+
+func (fs *flowStats) evictOldestLocked() {
+	var oldestKey flowKey
+	oldest := int64(-1)
+	for k, ds := range fs.destinations {
+		if la := atomic.LoadInt64(&ds.lastActive); oldest < 0 || la < oldest {
+			oldest = la
+			oldestKey = k
+		}
+	}
+	delete(fs.destinations, oldestKey)
+}
+
+func (fs *flowStats) toRPC() *rpc.FlowStats {
+	fs.Lock()
+	defer fs.Unlock()
+	dss := make([]*rpc.DestinationStats, 0, len(fs.destinations))
+	for k, ds := range fs.destinations {
+		dss = append(dss, &rpc.DestinationStats{
+			Protocol:     ipproto.String(k.proto),
+			Ip:           k.ip.IP(),
+			Port:         int32(k.port),
+			Name:         ds.name,
+			IngressBytes: atomic.LoadUint64(&ds.ingress),
+			EgressBytes:  atomic.LoadUint64(&ds.egress),
+			Connections:  atomic.LoadUint64(&ds.connections),
+			LastActive:   timestamppb.New(time.Unix(0, atomic.LoadInt64(&ds.lastActive))),
+		})
+	}
+	return &rpc.FlowStats{Destinations: dss}
+}
@@ -0,0 +1,158 @@
+package rootd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/blang/semver"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+)
+
+// handoverRequest is sent by a newly started root daemon to the root daemon that it wants to replace.
+type handoverRequest struct {
+	Version string `json:"version"`
+}
+
+// handoverReply is sent in response to a handoverRequest. When the handover is accepted, and the old
+// daemon had an active TUN device, the device's file descriptor is passed along with the reply.
+type handoverReply struct {
+	Accepted bool           `json:"accepted"`
+	Reason   string         `json:"reason,omitempty"`
+	State    *handoverState `json:"state,omitempty"`
+}
+
+// handoverState is the session state that a root daemon passes on to its successor.
+type handoverState struct {
+	Version string `json:"version"`
+
+	// OutboundInfo is the protobuf encoded rpc.OutboundInfo that the session was created from.
+	OutboundInfo []byte `json:"outbound_info,omitempty"`
+
+	// DeviceName is the name of the TUN device that is passed along with the state.
+	DeviceName string `json:"device_name,omitempty"`
+
+	// RoutedSubnets are the subnets that are routed to the TUN device.
+	RoutedSubnets []string `json:"routed_subnets,omitempty"`
+
+	// Owner is the user ID of the user that created the session, or -1 when it's unknown.
+	Owner int `json:"owner"`
+}
+
+// adoptedDevice is a TUN device that has been handed over from another root daemon.
+type adoptedDevice struct {
+	fd            int
+	routedSubnets []*net.IPNet
+}
+
+type adoptedDeviceKey struct{}
+
+func withAdoptedDevice(ctx context.Context, ad *adoptedDevice) context.Context {
+	return context.WithValue(ctx, adoptedDeviceKey{}, ad)
+}
+
+func getAdoptedDevice(ctx context.Context) *adoptedDevice {
+	if ad, ok := ctx.Value(adoptedDeviceKey{}).(*adoptedDevice); ok {
+		return ad
+	}
+	return nil
+}
+
+// checkHandoverRequest validates that a root daemon with the version given in the request can
+// take over from this daemon.
+func checkHandoverRequest(rq *handoverRequest) error {
+	to, err := semver.Parse(strings.TrimPrefix(rq.Version, "v"))
+	if err != nil {
+		return fmt.Errorf("invalid version %q: %w", rq.Version, err)
+	}
+	return client.CheckHandoverCompatibility(client.Semver(), to)
+}
+
+// detachForHandover detaches the current session's TUN device and returns the state that must be passed
+// on to the daemon that takes over, along with a file representing the device. The file is nil when there's
+// no active session or when the session has no TUN device.
+func (s *Service) detachForHandover(ctx context.Context) (*handoverState, *os.File, error) {
+	s.sessionLock.RLock()
+	defer s.sessionLock.RUnlock()
+	st := &handoverState{Version: client.Version(), Owner: -1}
+	if s.session == nil {
+		return st, nil, nil
+	}
+	oi, err := proto.Marshal(s.session.getNetworkConfig().OutboundInfo)
+	if err != nil {
+		return nil, nil, err
+	}
+	st.OutboundInfo = oi
+	st.Owner = s.sessionOwner
+	tv := s.session.tunVif
+	if tv == nil {
+		return st, nil, nil
+	}
+	f, subnets, err := tv.Detach(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	st.DeviceName = tv.Device.Name()
+	st.RoutedSubnets = make([]string, len(subnets))
+	for i, sn := range subnets {
+		st.RoutedSubnets[i] = sn.String()
+	}
+	return st, f, nil
+}
+
+// pendingHandover is the session state that was handed over to this daemon. It is used by manageSessions
+// to recreate the session.
+type pendingHandover struct {
+	info   *rpc.OutboundInfo
+	device *adoptedDevice
+	owner  int
+}
+
+// adoptHandover creates a pendingHandover from the state received from another daemon and the file
+// descriptor of its TUN device, or nil if there was no session to hand over. The fd is negative when
+// no TUN device was passed.
+func adoptHandover(ctx context.Context, st *handoverState, fd int) (*pendingHandover, error) {
+	if len(st.OutboundInfo) == 0 {
+		return nil, nil
+	}
+	ph := &pendingHandover{info: new(rpc.OutboundInfo), owner: st.Owner}
+	if err := proto.Unmarshal(st.OutboundInfo, ph.info); err != nil {
+		return nil, err
+	}
+	if fd < 0 {
+		return ph, nil
+	}
+	ad := &adoptedDevice{fd: fd, routedSubnets: make([]*net.IPNet, 0, len(st.RoutedSubnets))}
+	for _, s := range st.RoutedSubnets {
+		_, sn, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		ad.routedSubnets = append(ad.routedSubnets, sn)
+	}
+	dlog.Infof(ctx, "Adopting TUN device %s from root daemon %s", st.DeviceName, st.Version)
+	ph.device = ad
+	return ph, nil
+}
+
+// startHandedOverSession recreates the session that was handed over to this daemon, if any.
+func (s *Service) startHandedOverSession(ctx context.Context, wg *sync.WaitGroup) {
+	ph := s.handover
+	if ph == nil {
+		return
+	}
+	s.handover = nil
+	if ph.device != nil {
+		ctx = withAdoptedDevice(ctx, ph.device)
+	}
+	if reply := s.startSession(ctx, connectRequest{info: ph.info, uid: ph.owner}, wg); reply.err != nil {
+		dlog.Errorf(ctx, "failed to recreate the session that was handed over: %v", reply.err)
+	}
+}
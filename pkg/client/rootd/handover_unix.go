@@ -0,0 +1,172 @@
+//go:build !windows
+// +build !windows
+
+package rootd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/socket"
+)
+
+const maxHandoverMessageSize = 64 * 1024
+
+// handoverSocketPath is the path of the unix socket that a running root daemon listens to for
+// handover requests from a newer root daemon.
+func handoverSocketPath(ctx context.Context) string {
+	return strings.TrimSuffix(socket.RootDaemonPath(ctx), ".socket") + "-handover.socket"
+}
+
+// serveHandover listens for handover requests. When a request is accepted, the session state
+// and the TUN device are passed to the requesting daemon and this daemon quits.
+func (s *Service) serveHandover(ctx context.Context) error {
+	path := handoverSocketPath(ctx)
+	_ = os.Remove(path)
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		// Not fatal. The daemon will just not be able to hand over its session.
+		dlog.Errorf(ctx, "unable to listen for handover requests: %v", err)
+		return nil
+	}
+	// Only root may request a handover, so no other user should be able to connect.
+	if err = os.Chmod(path, 0o600); err != nil {
+		_ = l.Close()
+		dlog.Errorf(ctx, "unable to restrict access to the handover socket: %v", err)
+		return nil
+	}
+	go func() {
+		<-ctx.Done()
+		_ = l.Close()
+	}()
+	for {
+		conn, err := l.AcceptUnix()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err = s.handleHandover(ctx, conn); err != nil {
+			dlog.Error(ctx, err)
+			continue
+		}
+		dlog.Info(ctx, "Session handed over. Quitting")
+		s.cancelSession()
+		s.quit()
+		return nil
+	}
+}
+
+// handleHandover handles one handover request and returns nil if the handover was completed.
+func (s *Service) handleHandover(ctx context.Context, conn *net.UnixConn) error {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if uid, ok := socket.PeerUID(socket.WithPeerUID(ctx, conn)); !ok || uid != 0 {
+		_ = writeHandoverReply(conn, &handoverReply{Reason: "only root can request a handover"}, nil)
+		return fmt.Errorf("handover refused: peer user ID %d is not root", uid)
+	}
+	var rq handoverRequest
+	if err := json.NewDecoder(conn).Decode(&rq); err != nil {
+		return fmt.Errorf("invalid handover request: %w", err)
+	}
+	dlog.Infof(ctx, "Received handover request from root daemon %s", rq.Version)
+	if err := checkHandoverRequest(&rq); err != nil {
+		_ = writeHandoverReply(conn, &handoverReply{Reason: err.Error()}, nil)
+		return fmt.Errorf("handover refused: %w", err)
+	}
+	st, f, err := s.detachForHandover(ctx)
+	if err != nil {
+		_ = writeHandoverReply(conn, &handoverReply{Reason: err.Error()}, nil)
+		return fmt.Errorf("handover failed: %w", err)
+	}
+	if f != nil {
+		defer f.Close()
+	}
+	if err = writeHandoverReply(conn, &handoverReply{Accepted: true, State: st}, f); err != nil {
+		// The device is already detached, so the session can't continue. It's ended, so that the user
+		// daemon notices and a new session can be started by this daemon.
+		s.cancelSession()
+		return fmt.Errorf("failed to send handover reply: %w", err)
+	}
+	return nil
+}
+
+func writeHandoverReply(conn *net.UnixConn, reply *handoverReply, f *os.File) error {
+	data, err := json.Marshal(reply)
+	if err != nil {
+		return err
+	}
+	var oob []byte
+	if f != nil {
+		oob = unix.UnixRights(int(f.Fd()))
+	}
+	_, _, err = conn.WriteMsgUnix(data, oob, nil)
+	return err
+}
+
+// requestHandover asks the running root daemon to hand over its session to this daemon. On success,
+// the returned pendingHandover is non-nil if the session must be recreated.
+func requestHandover(ctx context.Context) (*pendingHandover, error) {
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: handoverSocketPath(ctx), Net: "unix"})
+	if err != nil {
+		return nil, fmt.Errorf("the running root daemon doesn't accept handover requests: %w", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err = json.NewEncoder(conn).Encode(&handoverRequest{Version: client.Version()}); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, maxHandoverMessageSize)
+	oob := make([]byte, unix.CmsgSpace(4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(data, oob)
+	if err != nil {
+		return nil, err
+	}
+	fd := -1
+	if oobn > 0 {
+		scms, err := unix.ParseSocketControlMessage(oob[:oobn])
+		if err != nil {
+			return nil, err
+		}
+		for _, scm := range scms {
+			fds, err := unix.ParseUnixRights(&scm)
+			if err == nil && len(fds) > 0 {
+				fd = fds[0]
+				break
+			}
+		}
+	}
+	var reply handoverReply
+	if err = json.Unmarshal(data[:n], &reply); err != nil {
+		if fd >= 0 {
+			_ = unix.Close(fd)
+		}
+		return nil, err
+	}
+	if !reply.Accepted || reply.State == nil {
+		if fd >= 0 {
+			_ = unix.Close(fd)
+		}
+		if !reply.Accepted {
+			return nil, errors.New(reply.Reason)
+		}
+		return nil, nil
+	}
+	ph, err := adoptHandover(ctx, reply.State, fd)
+	if (err != nil || ph == nil || ph.device == nil) && fd >= 0 {
+		_ = unix.Close(fd)
+	}
+	return ph, err
+}
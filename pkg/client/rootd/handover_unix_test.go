@@ -0,0 +1,139 @@
+//go:build !windows
+// +build !windows
+
+package rootd
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/socket"
+)
+
+// unixPair returns the client and server ends of a unix socket connection.
+func unixPair(t *testing.T) (*net.UnixConn, *net.UnixConn) {
+	path := filepath.Join(t.TempDir(), "handover.socket")
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	require.NoError(t, err)
+	defer l.Close()
+	cc, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: path, Net: "unix"})
+	require.NoError(t, err)
+	sc, err := l.AcceptUnix()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = cc.Close() })
+	return cc, sc
+}
+
+// skipUnlessRoot skips the test unless it runs as root, because only root may request a handover.
+func skipUnlessRoot(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("handover requests are only accepted from root")
+	}
+}
+
+func TestHandleHandover(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+
+	t.Run("accepted", func(t *testing.T) {
+		skipUnlessRoot(t)
+		cc, sc := unixPair(t)
+		require.NoError(t, json.NewEncoder(cc).Encode(&handoverRequest{Version: client.Version()}))
+		require.NoError(t, new(Service).handleHandover(ctx, sc))
+		var reply handoverReply
+		require.NoError(t, json.NewDecoder(cc).Decode(&reply))
+		assert.True(t, reply.Accepted)
+		require.NotNil(t, reply.State)
+		assert.Empty(t, reply.State.OutboundInfo)
+	})
+
+	t.Run("not root", func(t *testing.T) {
+		if os.Getuid() == 0 {
+			t.Skip("the peer is root")
+		}
+		cc, sc := unixPair(t)
+		require.NoError(t, json.NewEncoder(cc).Encode(&handoverRequest{Version: client.Version()}))
+		assert.Error(t, new(Service).handleHandover(ctx, sc))
+		var reply handoverReply
+		require.NoError(t, json.NewDecoder(cc).Decode(&reply))
+		assert.False(t, reply.Accepted)
+		assert.Nil(t, reply.State)
+	})
+
+	t.Run("refused", func(t *testing.T) {
+		cc, sc := unixPair(t)
+		require.NoError(t, json.NewEncoder(cc).Encode(&handoverRequest{Version: "v1.0.0"}))
+		assert.Error(t, new(Service).handleHandover(ctx, sc))
+		var reply handoverReply
+		require.NoError(t, json.NewDecoder(cc).Decode(&reply))
+		assert.False(t, reply.Accepted)
+		assert.NotEmpty(t, reply.Reason)
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		cc, sc := unixPair(t)
+		_, err := cc.Write([]byte("not json\n"))
+		require.NoError(t, err)
+		assert.Error(t, new(Service).handleHandover(ctx, sc))
+	})
+
+	t.Run("reply fails", func(t *testing.T) {
+		skipUnlessRoot(t)
+		cc, sc := unixPair(t)
+		require.NoError(t, json.NewEncoder(cc).Encode(&handoverRequest{Version: client.Version()}))
+		require.NoError(t, cc.Close())
+		assert.Error(t, new(Service).handleHandover(ctx, sc))
+	})
+}
+
+func TestRequestHandover(t *testing.T) {
+	skipUnlessRoot(t)
+	ctx := dlog.NewTestContext(t, false)
+	ctx = socket.WithRootDaemonPath(ctx, filepath.Join(t.TempDir(), "root.socket"))
+
+	_, err := requestHandover(ctx)
+	assert.Error(t, err, "no daemon listens for handover requests")
+
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: handoverSocketPath(ctx), Net: "unix"})
+	require.NoError(t, err)
+	defer l.Close()
+	handled := make(chan error, 1)
+	go func() {
+		conn, err := l.AcceptUnix()
+		if err == nil {
+			err = new(Service).handleHandover(ctx, conn)
+		}
+		handled <- err
+	}()
+	ph, err := requestHandover(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, ph, "there was no session to hand over")
+	assert.NoError(t, <-handled)
+}
+
+func TestAdoptHandoverOwner(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	oi, err := proto.Marshal(&rpc.OutboundInfo{HomeDir: "/home/alice"})
+	require.NoError(t, err)
+
+	// The session keeps the owner that created it, instead of whoever happens to connect first after the handover.
+	ph, err := adoptHandover(ctx, &handoverState{Version: client.Version(), OutboundInfo: oi, Owner: 1001}, -1)
+	require.NoError(t, err)
+	require.NotNil(t, ph)
+	assert.Equal(t, 1001, ph.owner)
+	assert.Equal(t, "/home/alice", ph.info.HomeDir)
+
+	st, f, err := new(Service).detachForHandover(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, f)
+	assert.Equal(t, -1, st.Owner, "no session, so no owner")
+}
@@ -0,0 +1,15 @@
+package rootd
+
+import (
+	"context"
+	"errors"
+)
+
+// serveHandover is a no-op on Windows, where a TUN device cannot be handed over to another process.
+func (s *Service) serveHandover(context.Context) error {
+	return nil
+}
+
+func requestHandover(context.Context) (*pendingHandover, error) {
+	return nil, errors.New("handover between root daemons is not supported on windows")
+}
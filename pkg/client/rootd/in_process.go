@@ -2,10 +2,13 @@ package rootd
 
 import (
 	"context"
+	"io"
+	"net"
 
 	"github.com/blang/semver"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 	empty "google.golang.org/protobuf/types/known/emptypb"
 
@@ -37,6 +40,7 @@ func (m *userdToManagerShortcut) Tunnel(ctx context.Context, opts ...grpc.CallOp
 // in-process from the user daemon, without starting the root daemon gRPC service.
 type InProcSession struct {
 	*Session
+	ctx    context.Context
 	cancel context.CancelFunc
 }
 
@@ -104,6 +108,88 @@ func (rd *InProcSession) WaitForNetwork(ctx context.Context, in *empty.Empty, op
 	return &empty.Empty{}, nil
 }
 
+func (rd *InProcSession) GetStats(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*rpc.SessionStats, error) {
+	return rd.getStats(), nil
+}
+
+func (rd *InProcSession) GetFlowStats(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*rpc.FlowStats, error) {
+	return rd.flowStats.toRPC(), nil
+}
+
+func (rd *InProcSession) GetRoutes(ctx context.Context, in *empty.Empty, opts ...grpc.CallOption) (*rpc.RouteList, error) {
+	return rd.getRoutes(), nil
+}
+
+func (rd *InProcSession) CheckRoute(ctx context.Context, in *rpc.CheckRouteRequest, opts ...grpc.CallOption) (*rpc.CheckRouteResponse, error) {
+	if len(in.Ip) != net.IPv4len && len(in.Ip) != net.IPv6len {
+		return nil, status.Error(codes.InvalidArgument, "invalid IP address")
+	}
+	return rd.checkRoute(ctx, in.Ip), nil
+}
+
+func (rd *InProcSession) Capture(ctx context.Context, in *rpc.CaptureRequest, opts ...grpc.CallOption) (rpc.Daemon_CaptureClient, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	cs := &inProcCaptureClient{ctx: ctx, packets: make(chan *rpc.CapturedPacket), done: make(chan struct{})}
+	go func() {
+		defer cancel()
+		cs.err = rd.capture(ctx, rd.ctx, in, func(cp *rpc.CapturedPacket) error {
+			select {
+			case cs.packets <- cp:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		close(cs.done)
+	}()
+	return cs, nil
+}
+
+// inProcCaptureClient is the rpc.Daemon_CaptureClient returned by InProcSession.Capture. Only Recv and
+// Context are meaningful. The remaining methods of the grpc.ClientStream are no-ops.
+type inProcCaptureClient struct {
+	ctx     context.Context
+	packets chan *rpc.CapturedPacket
+	done    chan struct{}
+	err     error
+}
+
+func (c *inProcCaptureClient) Recv() (*rpc.CapturedPacket, error) {
+	select {
+	case cp := <-c.packets:
+		return cp, nil
+	case <-c.done:
+		if c.err != nil {
+			return nil, c.err
+		}
+		return nil, io.EOF
+	}
+}
+
+func (c *inProcCaptureClient) Header() (metadata.MD, error) {
+	return nil, nil
+}
+
+func (c *inProcCaptureClient) Trailer() metadata.MD {
+	return nil
+}
+
+func (c *inProcCaptureClient) CloseSend() error {
+	return nil
+}
+
+func (c *inProcCaptureClient) Context() context.Context {
+	return c.ctx
+}
+
+func (c *inProcCaptureClient) SendMsg(any) error {
+	return nil
+}
+
+func (c *inProcCaptureClient) RecvMsg(any) error {
+	return status.Error(codes.Unimplemented, "RecvMsg is not implemented by the in-process capture client")
+}
+
 // NewInProcSession returns a root daemon session suitable to use in-process (from the user daemon) and is primarily intended for
 // when the user daemon runs in a docker container with NET_ADMIN capabilities.
 func NewInProcSession(
@@ -113,5 +199,5 @@ func NewInProcSession(
 	ver semver.Version,
 ) *InProcSession {
 	ctx, cancel := context.WithCancel(ctx)
-	return &InProcSession{Session: newSession(ctx, mi, &userdToManagerShortcut{mc}, ver), cancel: cancel}
+	return &InProcSession{Session: newSession(ctx, mi, &userdToManagerShortcut{mc}, ver), ctx: ctx, cancel: cancel}
 }
@@ -0,0 +1,54 @@
+package rootd
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+	"github.com/telepresenceio/telepresence/v2/pkg/firewall"
+	"github.com/telepresenceio/telepresence/v2/pkg/journal"
+	"github.com/telepresenceio/telepresence/v2/pkg/routing"
+	"github.com/telepresenceio/telepresence/v2/pkg/vif"
+)
+
+// journalFile is the name of the file in the user cache that the root daemon records its changes to the system in.
+const journalFile = "root-daemon-journal.json"
+
+//nolint:gochecknoglobals // constant
+var journalReverters = map[journal.Kind]journal.Reverter{
+	journal.KindRoute:        routing.RemoveJournaled,
+	journal.KindRoutingTable: routing.RemoveJournaledTable,
+	journal.KindFile:         journal.RemoveFile,
+	journal.KindFirewall:     firewall.RemoveJournaled,
+	journal.KindTUN:          vif.RemoveJournaled,
+}
+
+func newJournal(ctx context.Context) *journal.Journal {
+	return journal.New(filepath.Join(filelocation.AppUserCacheDir(ctx), journalFile))
+}
+
+// RepairLeftovers reverts the changes to the system that a root daemon which didn't terminate gracefully left
+// behind, and returns a description of each change that was reverted. The changes are found in the journal of the
+// root daemon, and firewall rules tagged as belonging to Telepresence are removed even when they aren't journaled,
+// because older daemons didn't keep a journal. It must only be called when no root daemon is running.
+func RepairLeftovers(ctx context.Context) ([]string, error) {
+	var result error
+	reverted, err := newJournal(ctx).Repair(ctx, journalReverters)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+	descs := make([]string, 0, len(reverted))
+	for _, e := range reverted {
+		descs = append(descs, e.String())
+	}
+	cleaned, err := firewall.RemoveLeftovers(ctx)
+	if err != nil {
+		result = multierror.Append(result, err)
+	}
+	for _, name := range cleaned {
+		descs = append(descs, string(journal.KindFirewall)+" "+name)
+	}
+	return descs, result
+}
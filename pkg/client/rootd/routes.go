@@ -0,0 +1,176 @@
+package rootd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/routing"
+	"github.com/telepresenceio/telepresence/v2/pkg/subnet"
+)
+
+// getRoutes returns the subnets that are routed to the TUN-device, along with the reason why they are
+// routed, and the never-proxy subnets that are routed elsewhere.
+func (s *Session) getRoutes() *rpc.RouteList {
+	s.subnetsLock.Lock()
+	defer s.subnetsLock.Unlock()
+	if s.tunVif == nil {
+		return &rpc.RouteList{}
+	}
+	rt := s.tunVif.Router
+	return routeSet{
+		devName:          s.tunVif.Device.Name(),
+		routed:           rt.GetRoutedSubnets(),
+		neverProxy:       rt.GetNeverProxyRoutes(),
+		staticOverrides:  rt.GetStaticOverrides(),
+		clusterSubnets:   s.clusterSubnets,
+		alsoProxySubnets: s.alsoProxySubnets,
+		resolvedSubnets:  s.resolvedSubnets,
+	}.routeList()
+}
+
+// routeSet is what's needed to tell which routes exist, and why.
+type routeSet struct {
+	devName          string
+	routed           []*net.IPNet
+	neverProxy       []*routing.Route
+	staticOverrides  []*routing.Route
+	clusterSubnets   []*net.IPNet
+	alsoProxySubnets []*net.IPNet
+	resolvedSubnets  []*net.IPNet
+}
+
+func (rs routeSet) routeList() *rpc.RouteList {
+	contains := func(sns []*net.IPNet, sn *net.IPNet) bool {
+		for _, c := range sns {
+			if subnet.Equal(c, sn) {
+				return true
+			}
+		}
+		return false
+	}
+	rl := &rpc.RouteList{}
+	for _, sn := range rs.routed {
+		var src rpc.Route_Source
+		switch {
+		case contains(rs.clusterSubnets, sn):
+			src = rpc.Route_CLUSTER
+		case contains(rs.alsoProxySubnets, sn):
+			src = rpc.Route_ALSO_PROXY
+		case contains(rs.resolvedSubnets, sn):
+			src = rpc.Route_RESOLVED
+		}
+		rl.Routes = append(rl.Routes, &rpc.Route{
+			Subnet:    iputil.IPNetToRPC(sn),
+			Source:    src,
+			Interface: rs.devName,
+			Installed: true,
+		})
+	}
+	for _, r := range rs.neverProxy {
+		installed := false
+		for _, o := range rs.staticOverrides {
+			if subnet.Equal(o.RoutedNet, r.RoutedNet) {
+				installed = true
+				break
+			}
+		}
+		rr := &rpc.Route{
+			Subnet:    iputil.IPNetToRPC(r.RoutedNet),
+			Source:    rpc.Route_NEVER_PROXY,
+			Gateway:   r.Gateway,
+			Installed: installed,
+		}
+		if r.Interface != nil {
+			rr.Interface = r.Interface.Name
+		}
+		rl.Routes = append(rl.Routes, rr)
+	}
+	return rl
+}
+
+// routeSourceName returns the name of the given source as used in the configuration, e.g. "also-proxy".
+func routeSourceName(src rpc.Route_Source) string {
+	return strings.ReplaceAll(strings.ToLower(src.String()), "_", "-")
+}
+
+// checkRoute explains how traffic to the given address is routed. The operating system is asked too,
+// because a route that Telepresence doesn't know about may take precedence.
+func (s *Session) checkRoute(ctx context.Context, ip net.IP) *rpc.CheckRouteResponse {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	rsp := &rpc.CheckRouteResponse{Ip: ip, Route: bestRoute(s.getRoutes().Routes, ip)}
+	bits := len(ip) * 8
+	if osRoute, err := routing.GetRoute(ctx, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}); err == nil {
+		rsp.OsInterface = osRoute.Interface.Name
+		rsp.OsGateway = osRoute.Gateway
+	} else {
+		dlog.Debugf(ctx, "unable to get the route for %s from the operating system: %v", ip, err)
+	}
+
+	devName := ""
+	if s.tunVif != nil {
+		devName = s.tunVif.Device.Name()
+	}
+	explainRoute(rsp, devName)
+	return rsp
+}
+
+// bestRoute returns the route that traffic to the given address uses. The route with the longest prefix
+// is chosen when several routes match, which is what the operating system does too.
+func bestRoute(routes []*rpc.Route, ip net.IP) *rpc.Route {
+	var best *rpc.Route
+	bestOnes := -1
+	for _, r := range routes {
+		sn := iputil.IPNetFromRPC(r.Subnet)
+		if !sn.Contains(ip) {
+			continue
+		}
+		ones, _ := sn.Mask.Size()
+		if ones > bestOnes || ones == bestOnes && r.Installed && !best.Installed {
+			best = r
+			bestOnes = ones
+		}
+	}
+	return best
+}
+
+// explainRoute sets the ToCluster and Explanation of the given response, based on its Route and on the
+// route that the operating system reported.
+func explainRoute(rsp *rpc.CheckRouteResponse, devName string) {
+	ip := net.IP(rsp.Ip)
+	r := rsp.Route
+	if rsp.OsInterface != "" {
+		rsp.ToCluster = devName != "" && rsp.OsInterface == devName
+	} else {
+		rsp.ToCluster = r != nil && r.Installed && r.Source != rpc.Route_NEVER_PROXY
+	}
+
+	var sb strings.Builder
+	switch {
+	case r == nil && rsp.ToCluster:
+		fmt.Fprintf(&sb, "%s is routed to the cluster through %s", ip, devName)
+	case r == nil:
+		fmt.Fprintf(&sb, "%s is not in any subnet that Telepresence routes. Add it to the also-proxy subnets to route it to the cluster", ip)
+	case r.Source == rpc.Route_NEVER_PROXY:
+		fmt.Fprintf(&sb, "%s is in the never-proxy subnet %s", ip, iputil.IPNetFromRPC(r.Subnet))
+	case rsp.ToCluster:
+		fmt.Fprintf(&sb, "%s is routed to the cluster through %s, because it is in the %s subnet %s",
+			ip, devName, routeSourceName(r.Source), iputil.IPNetFromRPC(r.Subnet))
+	default:
+		fmt.Fprintf(&sb, "%s is in the %s subnet %s, but the operating system routes it elsewhere. A more specific route probably takes precedence",
+			ip, routeSourceName(r.Source), iputil.IPNetFromRPC(r.Subnet))
+	}
+	if !rsp.ToCluster && rsp.OsInterface != "" {
+		fmt.Fprintf(&sb, ". Traffic to it is routed to %s", rsp.OsInterface)
+		if len(rsp.OsGateway) > 0 && !net.IP(rsp.OsGateway).IsUnspecified() {
+			fmt.Fprintf(&sb, " via %s", net.IP(rsp.OsGateway))
+		}
+	}
+	rsp.Explanation = sb.String()
+}
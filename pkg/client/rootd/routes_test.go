@@ -0,0 +1,118 @@
+package rootd
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/routing"
+)
+
+func mustParseCIDR(t *testing.T, s string) *net.IPNet {
+	_, sn, err := net.ParseCIDR(s)
+	require.NoError(t, err)
+	return sn
+}
+
+func TestRouteSet_routeList(t *testing.T) {
+	podSubnet := mustParseCIDR(t, "10.244.0.0/16")
+	alsoProxy := mustParseCIDR(t, "192.168.10.0/24")
+	resolved := mustParseCIDR(t, "172.16.0.5/32")
+	neverProxy := mustParseCIDR(t, "10.244.5.0/24")
+	unrelated := mustParseCIDR(t, "10.100.0.0/16")
+
+	rl := routeSet{
+		devName: "tel0",
+		routed:  []*net.IPNet{podSubnet, alsoProxy, resolved},
+		neverProxy: []*routing.Route{
+			{RoutedNet: neverProxy, Interface: &net.Interface{Name: "eth0"}, Gateway: net.IP{10, 0, 0, 1}},
+			{RoutedNet: unrelated, Interface: &net.Interface{Name: "eth1"}},
+		},
+		staticOverrides:  []*routing.Route{{RoutedNet: neverProxy}},
+		clusterSubnets:   []*net.IPNet{podSubnet},
+		alsoProxySubnets: []*net.IPNet{alsoProxy},
+		resolvedSubnets:  []*net.IPNet{resolved},
+	}.routeList()
+
+	type route struct {
+		subnet    string
+		source    rpc.Route_Source
+		iface     string
+		installed bool
+	}
+	var routes []route
+	for _, r := range rl.Routes {
+		routes = append(routes, route{iputil.IPNetFromRPC(r.Subnet).String(), r.Source, r.Interface, r.Installed})
+	}
+	assert.Equal(t, []route{
+		{"10.244.0.0/16", rpc.Route_CLUSTER, "tel0", true},
+		{"192.168.10.0/24", rpc.Route_ALSO_PROXY, "tel0", true},
+		{"172.16.0.5/32", rpc.Route_RESOLVED, "tel0", true},
+		{"10.244.5.0/24", rpc.Route_NEVER_PROXY, "eth0", true},
+		{"10.100.0.0/16", rpc.Route_NEVER_PROXY, "eth1", false},
+	}, routes)
+	assert.Equal(t, net.IP{10, 0, 0, 1}, net.IP(rl.Routes[3].Gateway))
+}
+
+func TestBestRoute(t *testing.T) {
+	routes := []*rpc.Route{
+		{Subnet: iputil.IPNetToRPC(mustParseCIDR(t, "10.244.0.0/16")), Source: rpc.Route_CLUSTER, Installed: true},
+		{Subnet: iputil.IPNetToRPC(mustParseCIDR(t, "10.244.5.0/24")), Source: rpc.Route_NEVER_PROXY},
+		{Subnet: iputil.IPNetToRPC(mustParseCIDR(t, "10.244.5.0/24")), Source: rpc.Route_ALSO_PROXY, Installed: true},
+	}
+	assert.Equal(t, routes[0], bestRoute(routes, net.IP{10, 244, 1, 1}))
+	assert.Equal(t, routes[2], bestRoute(routes, net.IP{10, 244, 5, 1}), "longest prefix, installed route preferred")
+	assert.Nil(t, bestRoute(routes, net.IP{10, 245, 0, 1}))
+}
+
+func TestExplainRoute(t *testing.T) {
+	cluster := &rpc.Route{Subnet: iputil.IPNetToRPC(mustParseCIDR(t, "10.244.0.0/16")), Source: rpc.Route_ALSO_PROXY, Installed: true}
+	never := &rpc.Route{Subnet: iputil.IPNetToRPC(mustParseCIDR(t, "10.244.5.0/24")), Source: rpc.Route_NEVER_PROXY, Installed: true}
+	ip := net.IP{10, 244, 5, 1}
+	tests := []struct {
+		name        string
+		rsp         *rpc.CheckRouteResponse
+		toCluster   bool
+		explanation string
+	}{
+		{
+			name:        "routed",
+			rsp:         &rpc.CheckRouteResponse{Ip: ip, Route: cluster, OsInterface: "tel0"},
+			toCluster:   true,
+			explanation: "10.244.5.1 is routed to the cluster through tel0, because it is in the also-proxy subnet 10.244.0.0/16",
+		},
+		{
+			name:        "routed without OS route",
+			rsp:         &rpc.CheckRouteResponse{Ip: ip, Route: cluster},
+			toCluster:   true,
+			explanation: "10.244.5.1 is routed to the cluster through tel0, because it is in the also-proxy subnet 10.244.0.0/16",
+		},
+		{
+			name:        "never proxied",
+			rsp:         &rpc.CheckRouteResponse{Ip: ip, Route: never, OsInterface: "eth0", OsGateway: net.IP{10, 0, 0, 1}},
+			explanation: "10.244.5.1 is in the never-proxy subnet 10.244.5.0/24. Traffic to it is routed to eth0 via 10.0.0.1",
+		},
+		{
+			name: "shadowed",
+			rsp:  &rpc.CheckRouteResponse{Ip: ip, Route: cluster, OsInterface: "docker0"},
+			explanation: "10.244.5.1 is in the also-proxy subnet 10.244.0.0/16, but the operating system routes it elsewhere. " +
+				"A more specific route probably takes precedence. Traffic to it is routed to docker0",
+		},
+		{
+			name:        "unknown",
+			rsp:         &rpc.CheckRouteResponse{Ip: ip, OsInterface: "eth0"},
+			explanation: "10.244.5.1 is not in any subnet that Telepresence routes. Add it to the also-proxy subnets to route it to the cluster. Traffic to it is routed to eth0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			explainRoute(tt.rsp, "tel0")
+			assert.Equal(t, tt.toCluster, tt.rsp.ToCluster)
+			assert.Equal(t, tt.explanation, tt.rsp.Explanation)
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -28,10 +29,14 @@ import (
 	"github.com/telepresenceio/telepresence/rpc/v2/manager"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/logging"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/paths"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/platform"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/scout"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/socket"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/wsl"
 	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
 	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+	"github.com/telepresenceio/telepresence/v2/pkg/journal"
 	"github.com/telepresenceio/telepresence/v2/pkg/log"
 	"github.com/telepresenceio/telepresence/v2/pkg/pprof"
 	"github.com/telepresenceio/telepresence/v2/pkg/proc"
@@ -82,7 +87,7 @@ type sessionReply struct {
 type Service struct {
 	rpc.UnsafeDaemonServer
 	quit            context.CancelFunc
-	connectCh       chan *rpc.OutboundInfo
+	connectCh       chan connectRequest
 	connectReplyCh  chan sessionReply
 	sessionLock     sync.RWMutex
 	sessionCancel   context.CancelFunc
@@ -90,12 +95,18 @@ type Service struct {
 	sessionQuitting int32 // atomic boolean. True if non-zero.
 	session         *Session
 	timedLogLevel   log.TimedLevel
+
+	// sessionOwner is the user ID of the caller that created the session, or -1 when it's unknown.
+	sessionOwner int
+
+	// handover is set when this daemon took over from another daemon that had an active session.
+	handover *pendingHandover
 }
 
 func NewService(cfg client.Config) *Service {
 	return &Service{
 		timedLogLevel:  log.NewTimedLevel(cfg.LogLevels().RootDaemon.String(), log.SetLevel),
-		connectCh:      make(chan *rpc.OutboundInfo),
+		connectCh:      make(chan connectRequest),
 		connectReplyCh: make(chan sessionReply),
 	}
 }
@@ -120,6 +131,8 @@ func Command() *cobra.Command {
 	}
 	flags := cmd.Flags()
 	flags.Uint16(pprofFlag, 0, "start pprof server on the given port")
+	flags.String(paths.UserDaemonSocketFlag, "", "the socket that the user daemon listens to")
+	flags.String(paths.RootDaemonSocketFlag, "", "the socket to listen to")
 	return cmd
 }
 
@@ -128,6 +141,7 @@ func (s *Service) Version(_ context.Context, _ *empty.Empty) (*common.VersionInf
 		ApiVersion: client.APIVersion,
 		Version:    client.Version(),
 		Name:       client.DisplayName,
+		Platform:   platform.Name(),
 	}, nil
 }
 
@@ -139,6 +153,7 @@ func (s *Service) Status(_ context.Context, _ *empty.Empty) (*rpc.DaemonStatus,
 			ApiVersion: client.APIVersion,
 			Version:    client.Version(),
 			Name:       client.DisplayName,
+			Platform:   platform.Name(),
 		},
 	}
 	if s.session != nil {
@@ -187,12 +202,21 @@ func (s *Service) SetDNSMappings(ctx context.Context, req *rpc.SetDNSMappingsReq
 	return &emptypb.Empty{}, err
 }
 
+// connectRequest is the OutboundInfo of a Connect call, along with the user ID of the caller, or -1 when
+// it's unknown, and the user daemon that called through the WSL bridge, if any.
+type connectRequest struct {
+	info       *rpc.OutboundInfo
+	uid        int
+	userDaemon *wsl.UserDaemon
+}
+
 func (s *Service) Connect(ctx context.Context, info *rpc.OutboundInfo) (*rpc.DaemonStatus, error) {
 	dlog.Debug(ctx, "Received gRPC Connect")
+	uid, _ := socket.PeerUID(ctx)
 	select {
 	case <-ctx.Done():
 		return nil, status.Error(codes.Canceled, ctx.Err().Error())
-	case s.connectCh <- info:
+	case s.connectCh <- connectRequest{info: info, uid: uid, userDaemon: wsl.BridgedUserDaemon(ctx)}:
 	}
 	select {
 	case <-ctx.Done():
@@ -227,6 +251,14 @@ func (s *Service) WaitForNetwork(ctx context.Context, e *empty.Empty) (*empty.Em
 	return &empty.Empty{}, err
 }
 
+func (s *Service) GetStats(ctx context.Context, _ *empty.Empty) (st *rpc.SessionStats, err error) {
+	err = s.WithSession(func(ctx context.Context, session *Session) error {
+		st = session.getStats()
+		return nil
+	})
+	return
+}
+
 func (s *Service) cancelSessionReadLocked() {
 	if s.sessionCancel != nil {
 		s.sessionCancel()
@@ -268,6 +300,61 @@ func (s *Service) GetNetworkConfig(ctx context.Context, e *empty.Empty) (nc *rpc
 	return
 }
 
+func (s *Service) Capture(req *rpc.CaptureRequest, stream rpc.Daemon_CaptureServer) error {
+	return s.WithSession(func(ctx context.Context, session *Session) error {
+		if err := s.authorizeCapture(stream.Context()); err != nil {
+			return err
+		}
+		return session.capture(stream.Context(), ctx, req, stream.Send)
+	})
+}
+
+// authorizeCapture returns an error unless the caller is root or the user that created the session. The
+// socket of the root daemon is accessible to all users, and captured packets may contain anything that
+// traverses the TUN-device. The named pipe on Windows is only accessible to the user and to administrators.
+// Must be called with the sessionLock held.
+func (s *Service) authorizeCapture(ctx context.Context) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	uid, ok := socket.PeerUID(ctx)
+	switch {
+	case !ok:
+		return status.Error(codes.PermissionDenied, "unable to determine the identity of the caller")
+	case uid == 0 || uid == s.sessionOwner:
+		return nil
+	default:
+		return status.Error(codes.PermissionDenied, "only root and the user that connected can capture traffic")
+	}
+}
+
+func (s *Service) GetFlowStats(ctx context.Context, _ *empty.Empty) (fs *rpc.FlowStats, err error) {
+	err = s.WithSession(func(ctx context.Context, session *Session) error {
+		fs = session.flowStats.toRPC()
+		return nil
+	})
+	return
+}
+
+func (s *Service) GetRoutes(ctx context.Context, _ *empty.Empty) (rl *rpc.RouteList, err error) {
+	err = s.WithSession(func(ctx context.Context, session *Session) error {
+		rl = session.getRoutes()
+		return nil
+	})
+	return
+}
+
+func (s *Service) CheckRoute(ctx context.Context, req *rpc.CheckRouteRequest) (rsp *rpc.CheckRouteResponse, err error) {
+	if len(req.Ip) != net.IPv4len && len(req.Ip) != net.IPv6len {
+		return nil, status.Error(codes.InvalidArgument, "invalid IP address")
+	}
+	err = s.WithSession(func(ctx context.Context, session *Session) error {
+		rsp = session.checkRoute(ctx, req.Ip)
+		return nil
+	})
+	return
+}
+
 func (s *Service) SetLogLevel(ctx context.Context, request *manager.LogLevelRequest) (*empty.Empty, error) {
 	duration := time.Duration(0)
 	if request.Duration != nil {
@@ -296,14 +383,15 @@ func (s *Service) manageSessions(c context.Context) error {
 	wg := sync.WaitGroup{}
 	defer wg.Wait()
 	c, s.quit = context.WithCancel(c)
+	s.startHandedOverSession(c, &wg)
 
 	for {
 		// Wait for a connection request
 		select {
 		case <-c.Done():
 			return nil
-		case oi := <-s.connectCh:
-			reply := s.startSession(c, oi, &wg)
+		case cr := <-s.connectCh:
+			reply := s.startSession(c, cr, &wg)
 			select {
 			case <-c.Done():
 				return nil
@@ -317,7 +405,7 @@ func (s *Service) manageSessions(c context.Context) error {
 	}
 }
 
-func (s *Service) startSession(ctx context.Context, oi *rpc.OutboundInfo, wg *sync.WaitGroup) sessionReply {
+func (s *Service) startSession(ctx context.Context, cr connectRequest, wg *sync.WaitGroup) sessionReply {
 	s.sessionLock.Lock() // Locked during creation
 	defer s.sessionLock.Unlock()
 	reply := sessionReply{
@@ -334,7 +422,11 @@ func (s *Service) startSession(ctx context.Context, oi *rpc.OutboundInfo, wg *sy
 	}
 
 	ctx, cancel := context.WithCancel(ctx)
-	session, err := GetNewSessionFunc(ctx)(ctx, oi)
+	if cr.userDaemon != nil {
+		// The user daemon is in a WSL2 distribution, so it can't be dialed using the socket.
+		ctx = wsl.WithUserDaemon(ctx, cr.userDaemon)
+	}
+	session, err := GetNewSessionFunc(ctx)(ctx, cr.info)
 	if ctx.Err() != nil || err != nil {
 		cancel()
 		reply.err = err
@@ -342,6 +434,7 @@ func (s *Service) startSession(ctx context.Context, oi *rpc.OutboundInfo, wg *sy
 	}
 
 	s.session = session
+	s.sessionOwner = cr.uid
 	s.sessionContext = ctx
 	s.sessionCancel = func() {
 		cancel()
@@ -384,7 +477,7 @@ func (s *Service) startSession(ctx context.Context, oi *rpc.OutboundInfo, wg *sy
 	return reply
 }
 
-func (s *Service) serveGrpc(c context.Context, l net.Listener, tracer common.TracingServer) error {
+func (s *Service) serveGrpc(c context.Context, l net.Listener, tracer common.TracingServer, extraOpts ...grpc.ServerOption) error {
 	defer func() {
 		// Error recovery.
 		if perr := derror.PanicToError(recover()); perr != nil {
@@ -397,15 +490,15 @@ func (s *Service) serveGrpc(c context.Context, l net.Listener, tracer common.Tra
 		grpc.StreamInterceptor(otelgrpc.StreamServerInterceptor()),
 	}
 	cfg := client.GetConfig(c)
-	if mz := cfg.Grpc().MaxReceiveSize(); mz > 0 {
-		opts = append(opts, grpc.MaxRecvMsgSize(int(mz)))
-	}
+	opts = append(opts, cfg.Grpc().ServerOptions()...)
+	opts = append(opts, extraOpts...)
 	svc := grpc.NewServer(opts...)
 	rpc.RegisterDaemonServer(svc, s)
 	common.RegisterTracingServer(svc, tracer)
 
 	sc := &dhttp.ServerConfig{
-		Handler: svc,
+		Handler:     svc,
+		ConnContext: socket.WithPeerUID,
 	}
 	dlog.Info(c, "gRPC server started")
 	err := sc.Serve(c, l)
@@ -441,6 +534,12 @@ func run(cmd *cobra.Command, args []string) error {
 	}
 	c = client.WithConfig(c, cfg)
 	flags := cmd.Flags()
+	if path, _ := flags.GetString(paths.UserDaemonSocketFlag); path != "" {
+		c = socket.WithUserDaemonPath(c, path)
+	}
+	if path, _ := flags.GetString(paths.RootDaemonSocketFlag); path != "" {
+		c = socket.WithRootDaemonPath(c, path)
+	}
 	if pprofPort, _ := flags.GetUint16(pprofFlag); pprofPort > 0 {
 		go func() {
 			if err := pprof.PprofServer(c, pprofPort); err != nil {
@@ -465,6 +564,24 @@ func run(cmd *cobra.Command, args []string) error {
 	dlog.Infof(c, "PID is %d", os.Getpid())
 	dlog.Info(c, "")
 
+	// If another root daemon is running, then ask it to hand over its session to us. This enables
+	// an upgrade of the root daemon without forcing the user to reconnect.
+	var handover *pendingHandover
+	if running, _ := socket.IsRunning(c, socket.RootDaemonPath(c)); running {
+		dlog.Info(c, "Requesting handover from the running root daemon")
+		if handover, err = requestHandover(c); err != nil {
+			return fmt.Errorf("unable to take over from the running %s: %w", ProcessName, err)
+		}
+		if err = socket.WaitUntilVanishes(ProcessName, socket.RootDaemonPath(c), 5*time.Second); err != nil {
+			return err
+		}
+	} else if _, err := RepairLeftovers(c); err != nil {
+		// No root daemon is running, so the changes in the journal, and any firewall rules tagged by
+		// Telepresence, were left behind by a daemon that crashed.
+		dlog.Error(c, err)
+	}
+	c = journal.WithJournal(c, newJournal(c))
+
 	// Listen on domain unix domain socket or windows named pipe. The listener must be opened
 	// before other tasks because the CLI client will only wait for a short period of time for
 	// the socket/pipe to appear before it gives up.
@@ -479,6 +596,7 @@ func run(cmd *cobra.Command, args []string) error {
 
 	c = scout.NewReporter(c, ProcessName)
 	d := GetNewServiceFunc(c)(cfg)
+	d.handover = handover
 	if err = logging.LoadTimedLevelFromCache(c, d.timedLogLevel, ProcessName); err != nil {
 		return err
 	}
@@ -494,6 +612,10 @@ func run(cmd *cobra.Command, args []string) error {
 	g.Go("config-reload", d.configReload)
 	g.Go("session", d.manageSessions)
 	g.Go("server-grpc", func(c context.Context) error { return d.serveGrpc(c, grpcListener, tracer) })
+	if err = d.serveWSLBridge(c, g, tracer); err != nil {
+		return err
+	}
+	g.Go("server-handover", d.serveHandover)
 	g.Go("metriton", scout.Run)
 	err = g.Wait()
 	if err != nil {
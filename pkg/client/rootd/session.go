@@ -9,6 +9,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -39,7 +40,7 @@ import (
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/rootd/dns"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/scout"
-	"github.com/telepresenceio/telepresence/v2/pkg/client/socket"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/wsl"
 	"github.com/telepresenceio/telepresence/v2/pkg/dnsproxy"
 	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
 	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
@@ -87,6 +88,10 @@ type Session struct {
 	// The local dns server
 	dnsServer *dns.Server
 
+	// dnsCacheFile is the file in the user cache that the local DNS cache is persisted in between sessions
+	// with the same cluster. Empty when the cluster is unknown.
+	dnsCacheFile string
+
 	// remoteDnsIP is the IP of the DNS server attached to the TUN device. This is currently only
 	// used in conjunction with systemd-resolved. The current macOS and the overriding solution
 	// will dispatch directly to the local DNS Service without going through the TUN device but
@@ -96,9 +101,20 @@ type Session struct {
 	// dnsLocalAddr is address of the local DNS Service.
 	dnsLocalAddr *net.UDPAddr
 
+	// subnetsLock protects the subnets that are routed to the TUN-device
+	subnetsLock sync.Mutex
+
 	// Cluster subnets reported by the traffic-manager
 	clusterSubnets []*net.IPNet
 
+	// The service subnet reported by the traffic-manager, regardless of whether it's proxied or not
+	serviceSubnet *net.IPNet
+
+	// Single address subnets that were resolved using the cluster DNS but that aren't covered by any
+	// other routed subnet. A name of a headless service resolves to the addresses of its pods, and a
+	// name of an ExternalName service may resolve to addresses in the cluster's private network.
+	resolvedSubnets []*net.IPNet
+
 	// Subnets configured by the user
 	alsoProxySubnets []*net.IPNet
 
@@ -124,6 +140,18 @@ type Session struct {
 	dnsLookups  int
 	dnsFailures int
 
+	// Counters for the traffic that passes through the tunnel
+	ingressBytes *tunnel.CounterProbe
+	egressBytes  *tunnel.CounterProbe
+	connections  uint64
+	flowStats    *flowStats
+
+	// tunnelMux multiplexes the streams of all connections over one tunnel to the traffic-manager. It's nil
+	// until the first connection is made, and when tunnelMuxUnsupported is set.
+	tunnelMux            *tunnel.Mux
+	tunnelMuxLock        sync.Mutex
+	tunnelMuxUnsupported bool
+
 	// Whether pods should be proxied by the TUN-device
 	proxyClusterPods bool
 
@@ -178,10 +206,10 @@ func connectToUserDaemon(c context.Context) (*grpc.ClientConn, connector.Manager
 	defer cancel()
 
 	var conn *grpc.ClientConn
-	conn, err := socket.Dial(tc, socket.UserDaemonPath(c),
+	conn, err := wsl.DialUserDaemon(tc, append([]grpc.DialOption{
 		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
 		grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor()),
-	)
+	}, client.GetConfig(c).Grpc().DialOptions(client.ChannelTunnel)...)...)
 	var mgrVer semver.Version
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -243,6 +271,8 @@ func newSession(c context.Context, mi *rpc.OutboundInfo, mc connector.ManagerPro
 	ns := iputil.ConvertSubnets(mi.NeverProxySubnets)
 	s := &Session{
 		handlers:          tunnel.NewPool(),
+		ingressBytes:      tunnel.NewCounterProbe("IngressBytes"),
+		egressBytes:       tunnel.NewCounterProbe("EgressBytes"),
 		rndSource:         rand.NewSource(time.Now().UnixNano()),
 		session:           mi.Session,
 		managerClient:     mc,
@@ -257,10 +287,18 @@ func newSession(c context.Context, mi *rpc.OutboundInfo, mc connector.ManagerPro
 	}
 
 	if dnsproxy.ManagerCanDoDNSQueryTypes(ver) {
-		s.dnsServer = dns.NewServer(mi.Dns, s.clusterLookup, false)
+		s.dnsServer = dns.NewServer(mi.Dns, dns.ResolverFunc(s.clusterLookup), false)
 	} else {
-		s.dnsServer = dns.NewServer(mi.Dns, s.legacyClusterLookup, true)
+		s.dnsServer = dns.NewServer(mi.Dns, dns.ResolverFunc(s.legacyClusterLookup), true)
+	}
+	s.dnsServer.OnClusterAddresses(s.routeClusterAddresses)
+	if cid := mi.Session.GetClusterId(); cid != "" {
+		s.dnsCacheFile = filepath.Join("dns", cid+"-"+mi.ManagerNamespace+".json")
+		if err := s.dnsServer.LoadCache(c, s.dnsCacheFile); err != nil {
+			dlog.Warnf(c, "Failed to load the persisted DNS cache: %v", err)
+		}
 	}
+	s.flowStats = newFlowStats(s.dnsServer.NameOf)
 	s.SetSearchPath(c, nil, nil)
 	dlog.Infof(c, "also-proxy subnets %v", as)
 	dlog.Infof(c, "never-proxy subnets %v", ns)
@@ -316,6 +354,23 @@ func (s *Session) legacyClusterLookup(ctx context.Context, q *dns2.Question) (rr
 	return rrs, dns2.RcodeSuccess, nil
 }
 
+func (s *Session) getStats() *rpc.SessionStats {
+	st := &rpc.SessionStats{
+		IngressBytes: s.ingressBytes.GetValue(),
+		EgressBytes:  s.egressBytes.GetValue(),
+		Connections:  atomic.LoadUint64(&s.connections),
+		Dns:          s.dnsServer.Stats(),
+	}
+	if s.tunVif != nil {
+		vs := s.tunVif.Stats()
+		st.Vif = &rpc.VIFStats{
+			Mtu:       int32(vs.MTU),
+			Fragments: vs.Fragments,
+		}
+	}
+	return st
+}
+
 func (s *Session) getNetworkConfig() *rpc.NetworkConfig {
 	info := rpc.OutboundInfo{
 		Session: s.session,
@@ -355,6 +410,7 @@ func (s *Session) configureDNS(dnsIP net.IP, dnsLocalAddr *net.UDPAddr) {
 	s.dnsLocalAddr = dnsLocalAddr
 }
 
+// refreshSubnets updates the routes of the TUN-device. The subnetsLock must be held when calling this function.
 func (s *Session) refreshSubnets(ctx context.Context) (err error) {
 	if s.tunVif == nil {
 		dlog.Debug(ctx, "no tunnel, not refreshing subnets")
@@ -377,9 +433,10 @@ func (s *Session) refreshSubnets(ctx context.Context) (err error) {
 	}()
 
 	// Create a unique slice of all desired subnets.
-	desired := make([]*net.IPNet, len(s.clusterSubnets)+len(s.alsoProxySubnets))
-	copy(desired, s.clusterSubnets)
-	copy(desired[len(s.clusterSubnets):], s.alsoProxySubnets)
+	desired := make([]*net.IPNet, 0, len(s.clusterSubnets)+len(s.alsoProxySubnets)+len(s.resolvedSubnets))
+	desired = append(desired, s.clusterSubnets...)
+	desired = append(desired, s.alsoProxySubnets...)
+	desired = append(desired, s.resolvedSubnets...)
 	desired = subnet.Unique(desired)
 
 	return s.tunVif.Router.UpdateRoutes(ctx, desired, s.neverProxySubnets)
@@ -511,7 +568,13 @@ func (s *Session) onFirstClusterInfo(ctx context.Context, mgrInfo *manager.Clust
 
 	// Do we need a VIF? A darwin system with full cluster access doesn't.
 	if willProxy || s.dnsServerSubnet != nil {
-		if s.tunVif, err = vif.NewTunnelingDevice(ctx, s.streamCreator()); err != nil {
+		// Heartbeats keep idle connections alive when a NAT or a proxy drops idle tunnels to the traffic-manager.
+		ctx = tunnel.WithHeartbeat(ctx, client.GetConfig(ctx).Grpc().Heartbeat())
+		if ad := getAdoptedDevice(ctx); ad != nil {
+			if s.tunVif, err = vif.AdoptTunnelingDevice(ctx, ad.fd, ad.routedSubnets, s.streamCreator(ctx)); err != nil {
+				return fmt.Errorf("AdoptTunnelVIF: %v", err)
+			}
+		} else if s.tunVif, err = vif.NewTunnelingDevice(ctx, s.streamCreator(ctx)); err != nil {
 			return fmt.Errorf("NewTunnelVIF: %v", err)
 		}
 	}
@@ -528,14 +591,17 @@ func (s *Session) onClusterInfo(ctx context.Context, mgrInfo *manager.ClusterInf
 		}
 	}
 
+	s.subnetsLock.Lock()
+	defer s.subnetsLock.Unlock()
 	s.readAdditionalRouting(ctx, mgrInfo)
 
 	var subnets []*net.IPNet
-	if s.proxyClusterSvcs {
-		if mgrInfo.ServiceSubnet != nil {
-			cidr := iputil.IPNetFromRPC(mgrInfo.ServiceSubnet)
-			dlog.Infof(ctx, "Adding Service subnet %s", cidr)
-			subnets = append(subnets, cidr)
+	s.serviceSubnet = nil
+	if mgrInfo.ServiceSubnet != nil {
+		s.serviceSubnet = iputil.IPNetFromRPC(mgrInfo.ServiceSubnet)
+		if s.proxyClusterSvcs {
+			dlog.Infof(ctx, "Adding Service subnet %s", s.serviceSubnet)
+			subnets = append(subnets, s.serviceSubnet)
 		}
 	}
 
@@ -565,8 +631,8 @@ func (s *Session) onClusterInfo(ctx context.Context, mgrInfo *manager.ClusterInf
 	}
 
 	dlog.Infof(ctx, "Setting cluster DNS to %s", dnsIP)
-	dlog.Infof(ctx, "Setting cluster domain to %q", dns.ClusterDomain)
 	s.dnsServer.SetClusterDNS(dns, dnsIP)
+	dlog.Infof(ctx, "Setting cluster domain to %q", s.dnsServer.ClusterDomain())
 
 	s.clusterSubnets = subnet.Unique(subnets)
 	if err := s.refreshSubnets(ctx); err != nil {
@@ -577,11 +643,57 @@ func (s *Session) onClusterInfo(ctx context.Context, mgrInfo *manager.ClusterInf
 		attribute.Bool("tel2.proxy-svcs", s.proxyClusterSvcs),
 		attribute.Bool("tel2.proxy-pods", s.proxyClusterPods),
 		attribute.Stringer("tel2.cluster-dns", net.IP(dns.KubeIp)),
-		attribute.String("tel2.cluster-domain", dns.ClusterDomain),
+		attribute.String("tel2.cluster-domain", s.dnsServer.ClusterDomain()),
 	)
 	return nil
 }
 
+// routeClusterAddresses ensures that the given addresses, resolved using the cluster DNS, are routed to the
+// TUN-device. This is necessary when the pod subnets of the cluster are unknown, because a name of a headless
+// service resolves to the addresses of its pods. Addresses of external names, such as the target of an
+// ExternalName service, are only routed when they are private, i.e. when they are likely to belong to the
+// cluster's VPC.
+func (s *Session) routeClusterAddresses(ctx context.Context, ips []net.IP, external bool) {
+	s.subnetsLock.Lock()
+	defer s.subnetsLock.Unlock()
+	if s.tunVif == nil || !(external || s.proxyClusterPods) {
+		return
+	}
+	covered := func(ip net.IP) bool {
+		if s.serviceSubnet != nil && s.serviceSubnet.Contains(ip) {
+			return true
+		}
+		for _, sns := range [][]*net.IPNet{s.clusterSubnets, s.alsoProxySubnets, s.neverProxySubnets, s.resolvedSubnets} {
+			for _, sn := range sns {
+				if sn.Contains(ip) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	added := false
+	for _, ip := range ips {
+		if ip.IsLoopback() || ip.IsUnspecified() || external && !ip.IsPrivate() || covered(ip) {
+			continue
+		}
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+			bits = 32
+		}
+		sn := &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		dlog.Infof(ctx, "Adding resolved address subnet %s", sn)
+		s.resolvedSubnets = append(s.resolvedSubnets, sn)
+		added = true
+	}
+	if added {
+		if err := s.refreshSubnets(ctx); err != nil {
+			dlog.Errorf(ctx, "failed to route resolved addresses: %v", err)
+		}
+	}
+}
+
 func (s *Session) readAdditionalRouting(ctx context.Context, mgrInfo *manager.ClusterInfo) {
 	if r := mgrInfo.Routing; r != nil {
 		as := subnet.Unique(append(s.alsoProxySubnets, iputil.ConvertSubnets(r.AlsoProxySubnets)...))
@@ -753,7 +865,13 @@ func (s *Session) Start(c context.Context, g *dgroup.Group) error {
 		if s.tunVif != nil {
 			dev = s.tunVif.Device
 		}
-		return s.dnsServer.Worker(ctx, dev, s.configureDNS)
+		err := s.dnsServer.Worker(ctx, dev, s.configureDNS)
+		if s.dnsCacheFile != "" {
+			if err := s.dnsServer.SaveCache(c, s.dnsCacheFile); err != nil {
+				dlog.Warnf(c, "Failed to persist the DNS cache: %v", err)
+			}
+		}
+		return err
 	})
 
 	if s.tunVif != nil {
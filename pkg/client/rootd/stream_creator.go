@@ -2,7 +2,9 @@ package rootd
 
 import (
 	"context"
+	"errors"
 	"net"
+	"sync/atomic"
 	"time"
 
 	"github.com/datawire/dlib/dlog"
@@ -17,7 +19,9 @@ func (s *Session) isForDNS(ip net.IP, port uint16) bool {
 	return s.remoteDnsIP != nil && port == 53 && s.remoteDnsIP.Equal(ip)
 }
 
-func (s *Session) streamCreator() tunnel.StreamCreator {
+// streamCreator returns a function that creates streams to the traffic-manager. The streams are multiplexed over
+// one tunnel that lives until the given context is cancelled, unless the traffic-manager doesn't support that.
+func (s *Session) streamCreator(muxCtx context.Context) tunnel.StreamCreator {
 	return func(c context.Context, id tunnel.ConnID) (tunnel.Stream, error) {
 		p := id.Protocol()
 		if p == ipproto.UDP && s.isForDNS(id.Destination(), id.DestinationPort()) {
@@ -28,11 +32,84 @@ func (s *Session) streamCreator() tunnel.StreamCreator {
 			return from, nil
 		}
 		dlog.Debugf(c, "Opening tunnel for id %s", id)
-		ct, err := s.managerClient.Tunnel(c)
+		ct, err := s.openMuxFlow(muxCtx, c, id)
 		if err != nil {
 			return nil, err
 		}
+		if ct == nil {
+			if ct, err = s.managerClient.Tunnel(c); err != nil {
+				return nil, err
+			}
+		}
 		tc := client.GetConfig(c).Timeouts()
-		return tunnel.NewClientStream(c, ct, id, s.session.SessionId, tc.Get(client.TimeoutRoundtripLatency), tc.Get(client.TimeoutEndpointDial))
+		st, err := tunnel.NewClientStream(c, ct, id, s.session.SessionId, tc.Get(client.TimeoutRoundtripLatency), tc.Get(client.TimeoutEndpointDial))
+		if err != nil {
+			return nil, err
+		}
+		atomic.AddUint64(&s.connections, 1)
+		return &countingStream{Stream: st, ingress: s.ingressBytes, egress: s.egressBytes, dest: s.flowStats.connectionOpened(id)}, nil
+	}
+}
+
+// openMuxFlow opens a flow for the given connection on the multiplexed tunnel. The tunnel is created when it
+// doesn't exist or has ended. A nil flow is returned when the traffic-manager doesn't support multiplexing.
+func (s *Session) openMuxFlow(muxCtx, c context.Context, id tunnel.ConnID) (tunnel.GRPClientCStream, error) {
+	s.tunnelMuxLock.Lock()
+	defer s.tunnelMuxLock.Unlock()
+	if s.tunnelMuxUnsupported {
+		return nil, nil
+	}
+	if s.tunnelMux != nil {
+		select {
+		case <-s.tunnelMux.Done():
+			s.tunnelMux = nil
+		default:
+		}
+	}
+	if s.tunnelMux == nil {
+		mt, err := s.managerClient.Tunnel(muxCtx)
+		if err != nil {
+			return nil, err
+		}
+		if s.tunnelMux, err = tunnel.NewClientMux(muxCtx, mt); err != nil {
+			if !errors.Is(err, tunnel.ErrMuxUnsupported) {
+				return nil, err
+			}
+			dlog.Info(c, "The traffic-manager doesn't support multiplexed tunnels. Using one tunnel per connection")
+			s.tunnelMuxUnsupported = true
+			return nil, nil
+		}
+	}
+	return s.tunnelMux.Open(c, tunnel.FlowPriority(id))
+}
+
+// countingStream counts the payload bytes of the normal messages that pass through a stream, both in
+// total and for the stream's destination.
+type countingStream struct {
+	tunnel.Stream
+	ingress *tunnel.CounterProbe
+	egress  *tunnel.CounterProbe
+	dest    *destinationStats
+}
+
+func (cs *countingStream) Receive(ctx context.Context) (tunnel.Message, error) {
+	m, err := cs.Stream.Receive(ctx)
+	if err == nil && m.Code() == tunnel.Normal {
+		n := uint64(len(m.Payload()))
+		cs.ingress.Increment(n)
+		atomic.AddUint64(&cs.dest.ingress, n)
+		cs.dest.touch()
+	}
+	return m, err
+}
+
+func (cs *countingStream) Send(ctx context.Context, m tunnel.Message) error {
+	err := cs.Stream.Send(ctx, m)
+	if err == nil && m.Code() == tunnel.Normal {
+		n := uint64(len(m.Payload()))
+		cs.egress.Increment(n)
+		atomic.AddUint64(&cs.dest.egress, n)
+		cs.dest.touch()
 	}
+	return err
 }
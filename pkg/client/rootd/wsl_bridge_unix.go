@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package rootd
+
+import (
+	"context"
+
+	"github.com/datawire/dlib/dgroup"
+	"github.com/telepresenceio/telepresence/rpc/v2/common"
+)
+
+// serveWSLBridge is a no-op, because only the root daemon on Windows serves user daemons in WSL2 distributions.
+func (s *Service) serveWSLBridge(context.Context, *dgroup.Group, common.TracingServer) error {
+	return nil
+}
@@ -0,0 +1,38 @@
+package rootd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/datawire/dlib/dgroup"
+	"github.com/telepresenceio/telepresence/rpc/v2/common"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/wsl"
+)
+
+// serveWSLBridge serves the daemon's gRPC API to user daemons in WSL2 distributions when the bridge is enabled in
+// the configuration. The VIF of this daemon then provides cluster access to both Windows and the distributions.
+// The session of a user daemon that connects through the bridge calls that user daemon through its reverse
+// connections.
+func (s *Service) serveWSLBridge(c context.Context, g *dgroup.Group, tracer common.TracingServer) error {
+	port := client.GetConfig(c).OSSpecific().WSL.BridgePort
+	if port == 0 {
+		return nil
+	}
+	ls, token, err := wsl.Listen(c, port)
+	if err != nil {
+		return err
+	}
+	b := wsl.NewBridge(token)
+	opts := b.ServerOptions()
+	for i, l := range ls {
+		l := b.Listener(c, l)
+		g.Go(fmt.Sprintf("server-wsl-%d", i), func(c context.Context) error { return s.serveGrpc(c, l, tracer, opts...) })
+	}
+	g.Go("wsl-token", func(c context.Context) error {
+		<-c.Done()
+		wsl.RemoveToken(c)
+		return nil
+	})
+	return nil
+}
@@ -0,0 +1,28 @@
+//go:build freebsd || openbsd
+
+package scout
+
+import (
+	"context"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/datawire/dlib/dlog"
+)
+
+func getOsMetadata(ctx context.Context) map[string]any {
+	osMeta := map[string]any{
+		"os_version":       "unknown",
+		"os_build_version": "unknown",
+		"os_name":          "unknown",
+	}
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		dlog.Warnf(ctx, "Could not get os metadata: %v", err)
+		return osMeta
+	}
+	osMeta["os_name"] = unix.ByteSliceToString(uts.Sysname[:])
+	osMeta["os_version"] = unix.ByteSliceToString(uts.Release[:])
+	osMeta["os_build_version"] = unix.ByteSliceToString(uts.Version[:])
+	return osMeta
+}
@@ -3,6 +3,7 @@ package scout
 import (
 	"context"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -17,6 +18,7 @@ import (
 	"github.com/datawire/metriton-go-client/metriton"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+	"github.com/telepresenceio/telepresence/v2/pkg/proc"
 )
 
 // EnvironmentMetadataPrefix is the Environment variable prefix for additional metadata to be reported.
@@ -33,18 +35,75 @@ type ReportAnnotator func(map[string]any)
 type Reporter interface {
 	Close()
 	InstallID() string
+	Preview(action string, entries ...Entry) *metriton.Report
 	Report(ctx context.Context, action string, entries ...Entry)
 	Run(ctx context.Context) error
 	SetMetadatum(ctx context.Context, key string, value any)
+	Spooled() ([]*metriton.Report, error)
 	Start(ctx context.Context)
 }
 
+// Sender sends reports to a telemetry endpoint.
+type Sender interface {
+	Send(ctx context.Context, report *metriton.Report) error
+}
+
+// NewSenderFunc creates the Sender that sends reports to the given endpoint. An empty endpoint
+// means the default Metriton endpoint.
+var NewSenderFunc = func(endpoint string) Sender { //nolint:gochecknoglobals // extension point
+	return &httpSender{endpoint: endpoint}
+}
+
 type reporter struct {
 	index            int
 	buffer           chan bufEntry
 	done             chan struct{}
 	reportAnnotators []ReportAnnotator
 	reporter         *metriton.Reporter
+
+	// sender sends the reports. The Endpoint and Client of the metriton.Reporter are used when
+	// it's nil.
+	sender Sender
+
+	// disabled is true when the telemetry config disables reports, or when the endpoint has
+	// asked for no more reports.
+	disabled bool
+
+	// redact are the names of the fields whose values are redacted before they're sent.
+	redact []string
+
+	// spool retains the reports that couldn't be sent. Reports are discarded when it's nil.
+	spool *spool
+}
+
+// redacted replaces the values of redacted fields.
+const redacted = "<redacted>"
+
+type httpSender struct {
+	client   *http.Client
+	endpoint string
+
+	// disableScout is set when the endpoint asks for no more reports.
+	disableScout bool
+}
+
+func (s *httpSender) Send(ctx context.Context, report *metriton.Report) error {
+	if s.disableScout {
+		return nil
+	}
+	client := s.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	endpoint := s.endpoint
+	if endpoint == "" {
+		endpoint = metriton.DefaultEndpoint
+	}
+	resp, err := report.Send(ctx, client, endpoint)
+	if err == nil && resp != nil && resp.DisableScout {
+		s.disableScout = true
+	}
+	return err
 }
 
 // Entry is a key/value association used when reporting.
@@ -212,8 +271,27 @@ var DefaultReportAnnotators []ReportAnnotator //nolint:gochecknoglobals // exten
 
 // NewReporter creates a new initialized Reporter instance that can be used to
 // send telepresence reports to Metriton and assigns it to the current context.
+// The reporter is configured using the telemetry settings of the client config.
 func NewReporter(ctx context.Context, mode string) context.Context {
-	return WithReporter(ctx, NewReporterForInstallType(ctx, mode, CLI, DefaultReportAnnotators))
+	r := NewReporterForInstallType(ctx, mode, CLI, DefaultReportAnnotators)
+	if sr, ok := r.(*reporter); ok {
+		if cfg := client.GetConfigIfSet(ctx); cfg != nil {
+			sr.configure(ctx, cfg.Telemetry())
+		}
+	}
+	return WithReporter(ctx, r)
+}
+
+// configure applies the given telemetry config. Reports are spooled in the user's cache directory,
+// unless this process runs as an administrator, which would make the spool inaccessible to the
+// user's other processes.
+func (r *reporter) configure(ctx context.Context, cfg *client.Telemetry) {
+	r.disabled = !cfg.Enabled
+	r.redact = cfg.Redact
+	r.sender = NewSenderFunc(cfg.Endpoint)
+	if !proc.IsAdmin() {
+		r.spool = &spool{path: filepath.Join(filelocation.AppUserCacheDir(ctx), spoolFile)}
+	}
 }
 
 func InstallID(ctx context.Context) string {
@@ -259,6 +337,24 @@ func SetMetadatum(ctx context.Context, key string, value any) {
 	}
 }
 
+// Preview returns the report that the Reporter found in the current context would send for the
+// given action and entries, or nil if no report would be sent.
+func Preview(ctx context.Context, action string, entries ...Entry) *metriton.Report {
+	if r := getReporter(ctx); r != nil {
+		return r.Preview(action, entries...)
+	}
+	return nil
+}
+
+// Spooled returns the reports that couldn't be sent and are retained until the endpoint becomes
+// reachable.
+func Spooled(ctx context.Context) ([]*metriton.Report, error) {
+	if r := getReporter(ctx); r != nil {
+		return r.Spooled()
+	}
+	return nil, nil
+}
+
 // initialization broken out or constructor for the benefit of testing.
 func (r *reporter) initialize(ctx context.Context, mode, goos, goarch string) {
 	r.buffer = make(chan bufEntry, bufferSize)
@@ -369,21 +465,105 @@ func (r *reporter) Report(ctx context.Context, action string, entries ...Entry)
 	}
 }
 
-func (r *reporter) doReport(ctx context.Context, be *bufEntry) {
-	r.index++
-	metadata := make(map[string]any, 4+len(be.entries))
-	metadata["action"] = be.action
-	metadata["index"] = r.index
+func (r *reporter) isDisabled() bool {
+	return r.disabled || metriton.IsDisabledByUser()
+}
+
+// newReport creates the report that is sent for the given action and entries, with the fields
+// that are configured to be redacted replaced.
+func (r *reporter) newReport(index int, action string, entries []Entry) *metriton.Report {
+	// InstallID initializes the metriton.Reporter, which may add to its BaseMetadata.
+	installID := r.reporter.InstallID()
+	metadata := make(map[string]any, len(r.reporter.BaseMetadata)+4+len(entries))
+	for k, v := range r.reporter.BaseMetadata {
+		metadata[k] = v
+	}
+	metadata["action"] = action
+	metadata["index"] = index
 	for _, ra := range r.reportAnnotators {
 		ra(metadata)
 	}
-	for _, metaItem := range be.entries {
+	for _, metaItem := range entries {
 		metadata[metaItem.Key] = metaItem.Value
 	}
+	for _, k := range r.redact {
+		if _, ok := metadata[k]; ok {
+			metadata[k] = redacted
+		}
+	}
+	return &metriton.Report{
+		Application: r.reporter.Application,
+		InstallID:   installID,
+		Version:     r.reporter.Version,
+		Metadata:    metadata,
+	}
+}
+
+// Preview returns the report that would be sent for the given action and entries, or nil if
+// reports are disabled.
+func (r *reporter) Preview(action string, entries ...Entry) *metriton.Report {
+	if r.isDisabled() {
+		return nil
+	}
+	return r.newReport(r.index+1, action, entries)
+}
+
+// Spooled returns the reports that couldn't be sent.
+func (r *reporter) Spooled() ([]*metriton.Report, error) {
+	if r.spool == nil {
+		return nil, nil
+	}
+	return r.spool.list()
+}
+
+func (r *reporter) send(ctx context.Context, report *metriton.Report) error {
+	s := r.sender
+	if s == nil {
+		s = &httpSender{client: r.reporter.Client, endpoint: r.reporter.Endpoint}
+		r.sender = s
+	}
+	return s.Send(ctx, report)
+}
+
+func (r *reporter) doReport(ctx context.Context, be *bufEntry) {
+	r.index++
+	if r.isDisabled() {
+		return
+	}
+	report := r.newReport(r.index, be.action, be.entries)
+	if err := r.send(ctx, report); err != nil {
+		if ctx.Err() == nil {
+			dlog.Infof(ctx, "scout report %q failed: %v", be.action, err)
+			if r.spool != nil {
+				if err = r.spool.add(report); err != nil {
+					dlog.Errorf(ctx, "unable to spool scout report %q: %v", be.action, err)
+				}
+			}
+		}
+		return
+	}
+	r.flushSpool(ctx)
+}
 
-	_, err := r.reporter.Report(ctx, metadata)
-	if err != nil && ctx.Err() == nil {
-		dlog.Infof(ctx, "scout report %q failed: %v", be.action, err)
+// flushSpool sends the spooled reports. It's called when a report has been sent successfully,
+// i.e. when the endpoint is reachable again. Reports that fail are spooled again.
+func (r *reporter) flushSpool(ctx context.Context) {
+	if r.spool == nil {
+		return
+	}
+	reports, err := r.spool.take()
+	if err != nil {
+		dlog.Errorf(ctx, "unable to read spooled scout reports: %v", err)
+		return
+	}
+	for i, report := range reports {
+		if err = r.send(ctx, report); err != nil {
+			_ = r.spool.add(reports[i:]...)
+			return
+		}
+	}
+	if len(reports) > 0 {
+		dlog.Debugf(ctx, "sent %d spooled scout reports", len(reports))
 	}
 }
 
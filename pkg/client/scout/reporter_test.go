@@ -538,3 +538,66 @@ func TestReport(t *testing.T) {
 		})
 	}
 }
+
+type fakeSender struct {
+	fail bool
+	sent []*metriton.Report
+}
+
+func (s *fakeSender) Send(_ context.Context, report *metriton.Report) error {
+	if s.fail {
+		return fmt.Errorf("endpoint unreachable")
+	}
+	s.sent = append(s.sent, report)
+	return nil
+}
+
+func TestRedactAndSpool(t *testing.T) {
+	ctx := dlog.NewTestContext(t, true)
+	os.Unsetenv("SCOUT_DISABLE")
+	sender := &fakeSender{fail: true}
+	scout := &reporter{
+		reporter: &metriton.Reporter{
+			Application: "telepresence2",
+			Version:     "v2.4.5-test",
+			GetInstallID: func(r *metriton.Reporter) (string, error) {
+				return "00000000-1111-2222-3333-444444444444", nil
+			},
+		},
+		sender: sender,
+		redact: []string{"cluster_id"},
+		spool:  &spool{path: filepath.Join(t.TempDir(), spoolFile)},
+	}
+	scout.initialize(ctx, "test-mode", "linux", "amd64")
+
+	preview := scout.Preview("first", Entry{Key: "cluster_id", Value: "secret"})
+	require.NotNil(t, preview)
+	assert.Equal(t, redacted, preview.Metadata["cluster_id"])
+	assert.Equal(t, 1, preview.Metadata["index"])
+
+	// Reports that can't be sent are spooled.
+	scout.doReport(ctx, &bufEntry{action: "first", entries: []Entry{{Key: "cluster_id", Value: "secret"}}})
+	scout.doReport(ctx, &bufEntry{action: "second"})
+	spooled, err := scout.Spooled()
+	require.NoError(t, err)
+	require.Len(t, spooled, 2)
+	assert.Equal(t, redacted, spooled[0].Metadata["cluster_id"])
+	assert.Empty(t, sender.sent)
+
+	// The spool is flushed when a report has been sent.
+	sender.fail = false
+	scout.doReport(ctx, &bufEntry{action: "third"})
+	require.Len(t, sender.sent, 3)
+	assert.Equal(t, "third", sender.sent[0].Metadata["action"])
+	assert.Equal(t, "first", sender.sent[1].Metadata["action"])
+	assert.Equal(t, "second", sender.sent[2].Metadata["action"])
+	spooled, err = scout.Spooled()
+	require.NoError(t, err)
+	assert.Empty(t, spooled)
+
+	// Nothing is sent or previewed when disabled.
+	scout.disabled = true
+	assert.Nil(t, scout.Preview("fourth"))
+	scout.doReport(ctx, &bufEntry{action: "fourth"})
+	assert.Len(t, sender.sent, 3)
+}
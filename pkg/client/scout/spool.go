@@ -0,0 +1,94 @@
+package scout
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/datawire/metriton-go-client/metriton"
+)
+
+// spoolFile is the name of the file, in the user's cache directory, that holds the reports that
+// couldn't be sent because the endpoint was unreachable.
+const spoolFile = "telemetry-spool.jsonl"
+
+// maxSpooled is the max number of reports that are retained in the spool. The oldest reports are
+// discarded when it's exceeded.
+const maxSpooled = 100
+
+// spool retains reports that couldn't be sent, so that they can be sent when the endpoint becomes
+// reachable again. Each report is stored as one line of JSON.
+type spool struct {
+	path string
+}
+
+func (s *spool) list() ([]*metriton.Report, error) {
+	return readSpool(s.path)
+}
+
+// add appends the given reports to the spool.
+func (s *spool) add(added ...*metriton.Report) error {
+	reports, err := s.list()
+	if err != nil {
+		return err
+	}
+	reports = append(reports, added...)
+	if len(reports) > maxSpooled {
+		reports = reports[len(reports)-maxSpooled:]
+	}
+	return s.write(reports)
+}
+
+// take removes all reports from the spool and returns them. The spool is renamed before it's read,
+// so that concurrent adds end up in a new spool.
+func (s *spool) take() ([]*metriton.Report, error) {
+	taken := fmt.Sprintf("%s.%d", s.path, os.Getpid())
+	if err := os.Rename(s.path, taken); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	defer os.Remove(taken)
+	return readSpool(taken)
+}
+
+func (s *spool) write(reports []*metriton.Report) error {
+	buf := bytes.Buffer{}
+	enc := json.NewEncoder(&buf)
+	for _, r := range reports {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, buf.Bytes(), 0o600)
+}
+
+func readSpool(path string) ([]*metriton.Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var reports []*metriton.Report
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var r metriton.Report
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			// A partially written line is of no use. Skip it.
+			continue
+		}
+		reports = append(reports, &r)
+	}
+	return reports, sc.Err()
+}
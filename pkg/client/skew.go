@@ -0,0 +1,186 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/blang/semver"
+)
+
+// Component identifies a part of a Telepresence installation that has a version.
+type Component string
+
+const (
+	ComponentClient         Component = "Client"
+	ComponentRootDaemon     Component = "Root Daemon"
+	ComponentUserDaemon     Component = "User Daemon"
+	ComponentTrafficManager Component = "Traffic Manager"
+	ComponentTrafficAgent   Component = "Traffic Agent"
+)
+
+// SkewPolicy describes how far the version of a component may deviate from the version of the component
+// that it is compared with.
+type SkewPolicy struct {
+	Component Component
+
+	// ComparedTo is the component whose version is used as the reference.
+	ComparedTo Component
+
+	// MaxMinorBehind and MaxMinorAhead are the number of minor versions that the component may be older or
+	// newer than the reference. Only the major, minor, and patch numbers are considered, and a different
+	// major version is never compatible.
+	MaxMinorBehind uint64
+	MaxMinorAhead  uint64
+
+	// ExactMatch requires that the versions are equal. The max minor settings are ignored.
+	ExactMatch bool
+
+	// RemedyBehind and RemedyAhead describe what the user should do when the component is too old or too new.
+	RemedyBehind string
+	RemedyAhead  string
+}
+
+// SkewPolicies is the compatibility policy table used by the version skew advisor.
+var SkewPolicies = []SkewPolicy{ //nolint:gochecknoglobals // extension point
+	{
+		Component:    ComponentRootDaemon,
+		ComparedTo:   ComponentClient,
+		ExactMatch:   true,
+		RemedyBehind: `Restart the daemons using "telepresence quit -s"`,
+		RemedyAhead:  `Restart the daemons using "telepresence quit -s"`,
+	},
+	{
+		Component:    ComponentUserDaemon,
+		ComparedTo:   ComponentClient,
+		ExactMatch:   true,
+		RemedyBehind: `Restart the daemons using "telepresence quit -s"`,
+		RemedyAhead:  `Restart the daemons using "telepresence quit -s"`,
+	},
+	{
+		Component:      ComponentTrafficManager,
+		ComparedTo:     ComponentClient,
+		MaxMinorBehind: 2,
+		MaxMinorAhead:  0,
+		RemedyBehind:   `Upgrade the traffic manager using "telepresence helm upgrade"`,
+		RemedyAhead:    `Upgrade the client using "telepresence upgrade self"`,
+	},
+	{
+		Component:    ComponentTrafficAgent,
+		ComparedTo:   ComponentTrafficManager,
+		ExactMatch:   true,
+		RemedyBehind: `Remove the agent using "telepresence uninstall --agent <workload>" so that a new agent is injected`,
+		RemedyAhead:  `Upgrade the traffic manager using "telepresence helm upgrade"`,
+	},
+}
+
+// SkewStatus is the result of comparing a version with a reference version.
+type SkewStatus int
+
+const (
+	SkewOK SkewStatus = iota
+	SkewBehind
+	SkewAhead
+	SkewIncompatible
+)
+
+func (s SkewStatus) String() string {
+	switch s {
+	case SkewOK:
+		return "ok"
+	case SkewBehind:
+		return "too old"
+	case SkewAhead:
+		return "too new"
+	default:
+		return "incompatible"
+	}
+}
+
+// Check compares the version v of the policy's component with the version ref of the component that it
+// is compared to. The returned remedy is empty when the status is SkewOK.
+func (p *SkewPolicy) Check(v, ref semver.Version) (SkewStatus, string) {
+	if v.Major != ref.Major {
+		if v.Major < ref.Major {
+			return SkewIncompatible, p.RemedyBehind
+		}
+		return SkewIncompatible, p.RemedyAhead
+	}
+	vc := semver.Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch}
+	rc := semver.Version{Major: ref.Major, Minor: ref.Minor, Patch: ref.Patch}
+	switch {
+	case p.ExactMatch && vc.LT(rc):
+		return SkewBehind, p.RemedyBehind
+	case p.ExactMatch && vc.GT(rc):
+		return SkewAhead, p.RemedyAhead
+	case v.Minor+p.MaxMinorBehind < ref.Minor:
+		return SkewBehind, p.RemedyBehind
+	case v.Minor > ref.Minor+p.MaxMinorAhead:
+		return SkewAhead, p.RemedyAhead
+	}
+	return SkewOK, ""
+}
+
+// SkewPolicyFor returns the policy for the given component, or nil if no such policy exists.
+func SkewPolicyFor(c Component) *SkewPolicy {
+	for i := range SkewPolicies {
+		if SkewPolicies[i].Component == c {
+			return &SkewPolicies[i]
+		}
+	}
+	return nil
+}
+
+// ComponentVersion is the version of one component. Name distinguishes between several instances of the
+// same component, such as the traffic agents of different workloads.
+type ComponentVersion struct {
+	Component Component
+	Name      string
+	Version   semver.Version
+}
+
+// SkewAdvice is the result of checking one ComponentVersion against the policy table.
+type SkewAdvice struct {
+	ComponentVersion
+	ComparedTo Component
+	Reference  semver.Version
+	Status     SkewStatus
+	Remedy     string
+}
+
+func (a *SkewAdvice) Warning() string {
+	name := string(a.Component)
+	if a.Name != "" {
+		name += " " + a.Name
+	}
+	return fmt.Sprintf("%s version %s is %s compared to %s version %s", name, a.Version, a.Status, a.ComparedTo, a.Reference)
+}
+
+// EvaluateSkew checks each of the given versions against the policy table. Components that have no
+// policy, or whose reference component isn't present among the given versions, are omitted from the result.
+func EvaluateSkew(cvs []ComponentVersion) []SkewAdvice {
+	refs := make(map[Component]semver.Version, len(cvs))
+	for _, cv := range cvs {
+		if cv.Name == "" {
+			refs[cv.Component] = cv.Version
+		}
+	}
+	var advice []SkewAdvice
+	for _, cv := range cvs {
+		p := SkewPolicyFor(cv.Component)
+		if p == nil {
+			continue
+		}
+		ref, ok := refs[p.ComparedTo]
+		if !ok {
+			continue
+		}
+		status, remedy := p.Check(cv.Version, ref)
+		advice = append(advice, SkewAdvice{
+			ComponentVersion: cv,
+			ComparedTo:       p.ComparedTo,
+			Reference:        ref,
+			Status:           status,
+			Remedy:           remedy,
+		})
+	}
+	return advice
+}
@@ -0,0 +1,62 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/blang/semver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+)
+
+func TestEvaluateSkew(t *testing.T) {
+	v := semver.MustParse
+	advice := client.EvaluateSkew([]client.ComponentVersion{
+		{Component: client.ComponentClient, Version: v("2.16.0")},
+		{Component: client.ComponentRootDaemon, Version: v("2.15.1")},
+		{Component: client.ComponentUserDaemon, Version: v("2.16.0")},
+		{Component: client.ComponentTrafficManager, Version: v("2.13.3")},
+		{Component: client.ComponentTrafficAgent, Name: "echo", Version: v("2.13.3")},
+		{Component: client.ComponentTrafficAgent, Name: "hello", Version: v("2.12.0")},
+	})
+	require.Len(t, advice, 5)
+	statuses := make(map[string]client.SkewStatus, len(advice))
+	for _, a := range advice {
+		statuses[string(a.Component)+"/"+a.Name] = a.Status
+		if a.Status == client.SkewOK {
+			assert.Empty(t, a.Remedy)
+		} else {
+			assert.NotEmpty(t, a.Remedy)
+		}
+	}
+	assert.Equal(t, map[string]client.SkewStatus{
+		"Root Daemon/":        client.SkewBehind,
+		"User Daemon/":        client.SkewOK,
+		"Traffic Manager/":    client.SkewBehind,
+		"Traffic Agent/echo":  client.SkewOK,
+		"Traffic Agent/hello": client.SkewBehind,
+	}, statuses)
+}
+
+func TestSkewPolicy_Check(t *testing.T) {
+	v := semver.MustParse
+	p := client.SkewPolicyFor(client.ComponentTrafficManager)
+	require.NotNil(t, p)
+	tests := []struct {
+		manager string
+		client  string
+		status  client.SkewStatus
+	}{
+		{"2.16.0", "2.16.0", client.SkewOK},
+		{"2.14.0", "2.16.1", client.SkewOK},
+		{"2.16.0-rc.1", "2.16.0", client.SkewOK},
+		{"2.13.0", "2.16.0", client.SkewBehind},
+		{"2.17.0", "2.16.0", client.SkewAhead},
+		{"3.0.0", "2.16.0", client.SkewIncompatible},
+	}
+	for _, tt := range tests {
+		status, _ := p.Check(v(tt.manager), v(tt.client))
+		assert.Equal(t, tt.status, status, "manager %s, client %s", tt.manager, tt.client)
+	}
+}
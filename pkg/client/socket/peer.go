@@ -0,0 +1,29 @@
+package socket
+
+import (
+	"context"
+	"net"
+)
+
+type peerUIDKey struct{}
+
+// WithPeerUID returns a context that carries the user ID of the process at the other end of the given
+// connection. The context is returned unchanged when the connection isn't a unix socket, or when the
+// platform can't tell who the peer is. The function is suitable as the ConnContext of an http.Server.
+func WithPeerUID(ctx context.Context, conn net.Conn) context.Context {
+	if uc, ok := conn.(*net.UnixConn); ok {
+		if uid, err := peerUID(uc); err == nil {
+			return context.WithValue(ctx, peerUIDKey{}, uid)
+		}
+	}
+	return ctx
+}
+
+// PeerUID returns the user ID that was stored in the given context by WithPeerUID, and true, or -1 and false
+// when the user ID is unknown.
+func PeerUID(ctx context.Context) (int, bool) {
+	if uid, ok := ctx.Value(peerUIDKey{}).(int); ok {
+		return uid, true
+	}
+	return -1, false
+}
@@ -0,0 +1,29 @@
+package socket
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// peerUID returns the user ID of the process at the other end of the given connection.
+func peerUID(conn *net.UnixConn) (int, error) {
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return -1, err
+	}
+	var uid int
+	var credErr error
+	if err = rc.Control(func(fd uintptr) {
+		// SO_PEERCRED yields a struct sockpeercred, and golang.org/x/sys has no getter for it on OpenBSD.
+		// The kernel truncates the option to the size of the given buffer, and the uid is the struct's
+		// first field, so reading the option as an int yields the uid.
+		uid, credErr = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return -1, err
+	}
+	if credErr != nil {
+		return -1, credErr
+	}
+	return uid, nil
+}
@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd && !openbsd
+// +build !linux,!darwin,!freebsd,!openbsd
+
+package socket
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+)
+
+// peerUID always returns an error, because the credentials of a unix socket's peer can't be obtained on
+// this platform.
+func peerUID(*net.UnixConn) (int, error) {
+	return -1, fmt.Errorf("the credentials of a socket peer are not available on %s", runtime.GOOS)
+}
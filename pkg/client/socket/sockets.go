@@ -11,13 +11,34 @@ import (
 	"google.golang.org/grpc"
 )
 
+type (
+	userDaemonPathKey struct{}
+	rootDaemonPathKey struct{}
+)
+
+// WithUserDaemonPath returns a context in which UserDaemonPath returns the given path.
+func WithUserDaemonPath(ctx context.Context, path string) context.Context {
+	return context.WithValue(ctx, userDaemonPathKey{}, path)
+}
+
+// WithRootDaemonPath returns a context in which RootDaemonPath returns the given path.
+func WithRootDaemonPath(ctx context.Context, path string) context.Context {
+	return context.WithValue(ctx, rootDaemonPathKey{}, path)
+}
+
 // UserDaemonPath is the path used when communicating to the user daemon process.
 func UserDaemonPath(ctx context.Context) string {
+	if path, ok := ctx.Value(userDaemonPathKey{}).(string); ok {
+		return path
+	}
 	return userDaemonPath(ctx)
 }
 
 // RootDaemonPath is the path used when communicating to the root daemon process.
 func RootDaemonPath(ctx context.Context) string {
+	if path, ok := ctx.Value(rootDaemonPathKey{}).(string); ok {
+		return path
+	}
 	return rootDaemonPath(ctx)
 }
 
@@ -33,7 +54,7 @@ func Listen(ctx context.Context, processName, socketName string) (net.Listener,
 
 // Remove removes any representation of the socket from the filesystem.
 func Remove(listener net.Listener) error {
-	return os.Remove(listener.Addr().String())
+	return remove(listener)
 }
 
 // Exists returns true if a socket is found with the given name.
@@ -1,3 +1,6 @@
+//go:build !windows
+// +build !windows
+
 package socket_test
 
 import (
@@ -5,11 +8,13 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
 
 	"github.com/datawire/dlib/dgroup"
 	"github.com/datawire/dlib/dhttp"
@@ -98,3 +103,54 @@ func TestDialSocket(t *testing.T) {
 		assert.Contains(t, err.Error(), "this usually means that the process is not running")
 	})
 }
+
+func TestWithPeerUID(t *testing.T) {
+	switch runtime.GOOS {
+	case "linux", "darwin", "freebsd", "openbsd":
+	default:
+		t.Skipf("peer credentials are not available on %s", runtime.GOOS)
+	}
+	sockname := filepath.Join(t.TempDir(), "peer.sock")
+	listener, err := net.Listen("unix", sockname)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	ctx := dlog.NewTestContext(t, false)
+	grp := dgroup.NewGroup(ctx, dgroup.GroupConfig{
+		EnableWithSoftness: true,
+		ShutdownOnNonError: true,
+		DisableLogging:     true,
+	})
+
+	uids := make(chan int, 1)
+	grp.Go("server", func(ctx context.Context) error {
+		sc := &dhttp.ServerConfig{
+			Handler: grpc.NewServer(grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+				uid, ok := socket.PeerUID(stream.Context())
+				assert.True(t, ok)
+				uids <- uid
+				return nil
+			})),
+			ConnContext: socket.WithPeerUID,
+		}
+		return sc.Serve(ctx, listener)
+	})
+
+	grp.Go("client", func(ctx context.Context) error {
+		conn, err := socket.Dial(ctx, sockname)
+		require.NoError(t, err)
+		defer conn.Close()
+		_ = conn.Invoke(ctx, "/test.Peer/Check", &emptypb.Empty{}, &emptypb.Empty{})
+		select {
+		case uid := <-uids:
+			assert.Equal(t, os.Getuid(), uid)
+		case <-ctx.Done():
+			t.Error("the server was never called")
+		}
+		return nil
+	})
+	assert.NoError(t, grp.Wait())
+
+	_, ok := socket.PeerUID(ctx)
+	assert.False(t, ok)
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"path/filepath"
 	"time"
 
 	"golang.org/x/sys/unix"
@@ -84,6 +85,10 @@ func listen(_ context.Context, processName, socketName string) (net.Listener, er
 		origUmask := unix.Umask(0)
 		defer unix.Umask(origUmask)
 	}
+	// The directory of an overridden socket path might not exist yet.
+	if err := os.MkdirAll(filepath.Dir(socketName), 0o755); err != nil {
+		return nil, err
+	}
 	listener, err := net.Listen("unix", socketName)
 	if err != nil {
 		if errors.Is(err, unix.EADDRINUSE) {
@@ -97,6 +102,10 @@ func listen(_ context.Context, processName, socketName string) (net.Listener, er
 	return listener, nil
 }
 
+func remove(listener net.Listener) error {
+	return os.Remove(listener.Addr().String())
+}
+
 // exists returns true if a socket is found at the given path.
 func exists(path string) (bool, error) {
 	s, err := os.Stat(path)
@@ -6,161 +6,183 @@ import (
 	"fmt"
 	"io/fs"
 	"net"
-	"os"
-	"path/filepath"
+	"strings"
 	"time"
-	"unsafe"
 
+	"github.com/Microsoft/go-winio"
 	"golang.org/x/sys/windows"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 
 	"github.com/datawire/dlib/dlog"
-	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/proc"
 )
 
+// pipePrefix is the prefix used by all Windows named pipes.
+const pipePrefix = `\\.\pipe\`
+
 // userDaemonPath is the path used when communicating to the user daemon process.
 func userDaemonPath(ctx context.Context) string {
-	return filepath.Join(filelocation.AppUserCacheDir(ctx), "userd.socket")
+	return pipeName("telepresence-connector")
 }
 
 // rootDaemonPath is the path used when communicating to the root daemon process.
 func rootDaemonPath(ctx context.Context) string {
-	return filepath.Join(filelocation.AppUserCacheDir(ctx), "rootd.socket")
+	return pipeName("telepresence-daemon")
 }
 
-func dial(ctx context.Context, socketName string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
-	ctx, cancel := context.WithTimeout(ctx, 3*time.Second) // FIXME(lukeshu): Make this configurable
-	defer cancel()
-	for firstTry := true; ; firstTry = false {
-		// Windows will give us a WSAECONNREFUSED if the socket does not exist. That's not
-		// what we want.
-		found, err := exists(socketName)
-		if err != nil {
-			return nil, err
-		}
-		if !found {
-			err = &net.OpError{
-				Op:  "dial",
-				Net: "unix",
-				Addr: &net.UnixAddr{
-					Name: socketName,
-					Net:  "unix",
-				},
-				Err: fs.ErrNotExist,
-			}
-		}
-		if err == nil {
-			conn, dialErr := grpc.DialContext(ctx, "unix:"+socketName, append([]grpc.DialOption{
-				grpc.WithTransportCredentials(insecure.NewCredentials()),
-				grpc.WithNoProxy(),
-				grpc.WithBlock(),
-				grpc.FailOnNonTempDialError(true),
-			}, opts...)...)
-			if dialErr == nil {
-				return conn, nil
-			}
-			err = dialErr
-		}
+// pipeName returns a pipe name that is unique to the current user. The root daemon runs elevated but
+// on behalf of the same user, so it will see the same SID.
+func pipeName(name string) string {
+	if sid, err := currentUserSID(); err == nil {
+		name += "-" + sid
+	}
+	return pipePrefix + name
+}
 
-		// Remove the gRPC internal transport.Connection error wrapper. It messes up the message by
-		// quoting it so that backslashes in the path get doubled.
-		var opErr *net.OpError
-		if errors.As(err, &opErr) {
-			err = opErr
-		}
+func currentUserSID() (string, error) {
+	tu, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return "", err
+	}
+	return tu.User.Sid.String(), nil
+}
 
-		// Windows will give us a WSAECONNREFUSED if the socket does not exist. That's not
-		// what we want.
-		if errors.Is(err, windows.WSAECONNREFUSED) {
-			found, exErr := exists(socketName)
-			if exErr != nil {
-				return nil, exErr
-			}
-			if !found {
-				err = &net.OpError{
-					Op:  "dial",
-					Net: "unix",
-					Addr: &net.UnixAddr{
-						Name: socketName,
-						Net:  "unix",
-					},
-					Err: fs.ErrNotExist,
-				}
-			}
+// securityDescriptor returns an SDDL string that grants full access to SYSTEM, the current user, and
+// the admin groups configured in the client configuration. All other access is denied.
+func securityDescriptor(ctx context.Context) (string, error) {
+	sid, err := currentUserSID()
+	if err != nil {
+		return "", fmt.Errorf("unable to determine the SID of the current user: %w", err)
+	}
+	sb := strings.Builder{}
+	sb.WriteString("D:P(A;;GA;;;SY)(A;;GA;;;")
+	sb.WriteString(sid)
+	sb.WriteByte(')')
+	if cfg := client.GetConfigIfSet(ctx); cfg != nil {
+		for _, group := range cfg.OSSpecific().Pipes.AdminGroups {
+			sb.WriteString("(A;;GA;;;")
+			sb.WriteString(group)
+			sb.WriteByte(')')
 		}
+	}
+	if proc.IsAdmin() {
+		// An elevated process will label the pipe with a high integrity level unless told
+		// otherwise, and that would prevent the non-elevated clients from writing to it.
+		sb.WriteString("S:(ML;;NW;;;ME)")
+	}
+	return sb.String(), nil
+}
 
-		if firstTry && errors.Is(err, windows.WSAECONNREFUSED) {
-			// Socket exists but doesn't accept connections. This usually means that the process
-			// terminated ungracefully. To remedy this, we make an attempt to remove the socket
-			// and dial again.
-			dlog.Errorf(ctx, "Dial unix:%s failed: %v", socketName, err)
-			if rmErr := os.Remove(socketName); rmErr != nil && !errors.Is(err, os.ErrNotExist) {
-				err = fmt.Errorf("%w (socket rm failed with %v)", err, rmErr)
-			} else {
-				continue
-			}
-		}
+func pipeError(pipeName string, err error) error {
+	return &net.OpError{
+		Op:   "dial",
+		Net:  "pipe",
+		Addr: pipeAddr(pipeName),
+		Err:  err,
+	}
+}
 
-		if err == context.DeadlineExceeded {
-			// grpc.DialContext doesn't wrap context.DeadlineExceeded with any useful
-			// information at all.  Fix that.
-			err = &net.OpError{
-				Op:  "dial",
-				Net: "unix",
-				Addr: &net.UnixAddr{
-					Name: socketName,
-					Net:  "unix",
-				},
-				Err: fmt.Errorf("socket exists but is not responding: %w", err),
-			}
-		}
+type pipeAddr string
 
-		// Add some Telepresence-specific commentary on what specific common errors mean.
-		switch {
-		case errors.Is(err, context.DeadlineExceeded):
-			err = fmt.Errorf("%w; this usually means that the process has locked up", err)
-		case errors.Is(err, windows.WSAECONNREFUSED):
-			err = fmt.Errorf("%w; this usually means that the process has terminated ungracefully", err)
-		case errors.Is(err, os.ErrNotExist):
-			err = fmt.Errorf("%w; this usually means that the process is not running", err)
-		}
+func (pipeAddr) Network() string {
+	return "pipe"
+}
+
+func (a pipeAddr) String() string {
+	return string(a)
+}
+
+func dial(ctx context.Context, pipeName string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second) // FIXME(lukeshu): Make this configurable
+	defer cancel()
+
+	found, err := exists(pipeName)
+	if err != nil {
 		return nil, err
 	}
+	if !found {
+		err = pipeError(pipeName, fs.ErrNotExist)
+	} else {
+		var conn *grpc.ClientConn
+		conn, err = grpc.DialContext(ctx, "passthrough:///"+strings.TrimPrefix(pipeName, pipePrefix), append([]grpc.DialOption{
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return winio.DialPipeContext(ctx, pipeName)
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithNoProxy(),
+			grpc.WithBlock(),
+			grpc.FailOnNonTempDialError(true),
+		}, opts...)...)
+		if err == nil {
+			return conn, nil
+		}
+	}
+
+	// Remove the gRPC internal transport.Connection error wrapper. It messes up the message by
+	// quoting it so that backslashes in the path get doubled.
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		err = pipeError(pipeName, pathErr.Err)
+	}
+
+	if err == context.DeadlineExceeded {
+		// grpc.DialContext doesn't wrap context.DeadlineExceeded with any useful
+		// information at all.  Fix that.
+		err = pipeError(pipeName, fmt.Errorf("pipe exists but is not responding: %w", err))
+	}
+
+	// Add some Telepresence-specific commentary on what specific common errors mean.
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		err = fmt.Errorf("%w; this usually means that the process has locked up", err)
+	case errors.Is(err, windows.ERROR_ACCESS_DENIED):
+		err = fmt.Errorf("%w; this usually means that the process is owned by another user", err)
+	case errors.Is(err, fs.ErrNotExist):
+		err = fmt.Errorf("%w; this usually means that the process is not running", err)
+	}
+	dlog.Debugf(ctx, "Dial %s failed: %v", pipeName, err)
+	return nil, err
 }
 
-// listen returns a listener for the given socket and returns the resulting connection.
-func listen(ctx context.Context, processName, socketName string) (net.Listener, error) {
-	listener, err := net.Listen("unix", socketName)
+// listen returns a listener for the given named pipe. The pipe is created with a security descriptor
+// that limits access to the current user.
+func listen(ctx context.Context, processName, pipeName string) (net.Listener, error) {
+	sd, err := securityDescriptor(ctx)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := winio.ListenPipe(pipeName, &winio.PipeConfig{SecurityDescriptor: sd})
 	if err != nil {
-		if err != nil {
-			err = fmt.Errorf("socket %q exists so the %s is either already running or terminated ungracefully: %T, %w", socketName, processName, err, err)
+		if errors.Is(err, windows.ERROR_ACCESS_DENIED) || errors.Is(err, windows.ERROR_PIPE_BUSY) {
+			err = fmt.Errorf("pipe %q exists so the %s is already running: %w", pipeName, processName, err)
 		}
 		return nil, err
 	}
-	// Don't have dhttp.ServerConfig.Serve unlink the socket; defer unlinking the socket
-	// until the process exits.
-	listener.(*net.UnixListener).SetUnlinkOnClose(false)
 	return listener, nil
 }
 
-// socketAttributes is the combination that Windows uses for Unix socket FileAttributes.
-const socketAttributes = windows.FILE_ATTRIBUTE_REPARSE_POINT | windows.FILE_ATTRIBUTE_ARCHIVE
+// remove is a no-op on Windows. A named pipe ceases to exist when the last handle to it is closed.
+func remove(net.Listener) error {
+	return nil
+}
 
-// exists returns true if a socket is found at the given path.
-func exists(path string) (bool, error) {
-	namep, err := windows.UTF16PtrFromString(path)
+// exists returns true if a named pipe is found with the given name. The pipe directory is searched
+// rather than opening the pipe, because opening it would consume a pipe instance.
+func exists(pipeName string) (bool, error) {
+	namep, err := windows.UTF16PtrFromString(pipeName)
 	if err != nil {
 		return false, err
 	}
-
-	var fa windows.Win32FileAttributeData
-	err = windows.GetFileAttributesEx(namep, windows.GetFileExInfoStandard, (*byte)(unsafe.Pointer(&fa)))
+	var fd windows.Win32finddata
+	h, err := windows.FindFirstFile(namep, &fd)
 	if err != nil {
-		return false, nil
-	}
-	if fa.FileAttributes&socketAttributes != socketAttributes {
-		return false, fmt.Errorf("%q is not a socket", path)
+		if errors.Is(err, windows.ERROR_FILE_NOT_FOUND) || errors.Is(err, windows.ERROR_PATH_NOT_FOUND) {
+			return false, nil
+		}
+		return false, err
 	}
+	_ = windows.FindClose(h)
 	return true, nil
 }
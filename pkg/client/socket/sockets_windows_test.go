@@ -0,0 +1,81 @@
+package socket_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/datawire/dlib/dgroup"
+	"github.com/datawire/dlib/dhttp"
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/socket"
+)
+
+func testPipeName(name string) string {
+	return fmt.Sprintf(`\\.\pipe\telepresence-test-%s-%d`, name, time.Now().UnixNano())
+}
+
+func TestDialPipe(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		ctx := dlog.NewTestContext(t, false)
+		pipeName := testPipeName("ok")
+		listener, err := socket.Listen(ctx, "test", pipeName)
+		require.NoError(t, err)
+		defer listener.Close()
+
+		exists, err := socket.Exists(pipeName)
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		grp := dgroup.NewGroup(ctx, dgroup.GroupConfig{
+			EnableWithSoftness: true,
+			ShutdownOnNonError: true,
+			DisableLogging:     true,
+		})
+
+		grp.Go("server", func(ctx context.Context) error {
+			sc := &dhttp.ServerConfig{
+				Handler: grpc.NewServer(),
+			}
+			return sc.Serve(ctx, listener)
+		})
+
+		grp.Go("client", func(ctx context.Context) error {
+			conn, err := socket.Dial(ctx, pipeName)
+			assert.NoError(t, err)
+			if assert.NotNil(t, conn) {
+				assert.NoError(t, conn.Close())
+			}
+			return nil
+		})
+
+		assert.NoError(t, grp.Wait())
+	})
+	t.Run("AlreadyRunning", func(t *testing.T) {
+		ctx := dlog.NewTestContext(t, false)
+		pipeName := testPipeName("busy")
+		listener, err := socket.Listen(ctx, "test", pipeName)
+		require.NoError(t, err)
+		defer listener.Close()
+
+		_, err = socket.Listen(ctx, "test", pipeName)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "is already running")
+	})
+	t.Run("NotExist", func(t *testing.T) {
+		ctx := dlog.NewTestContext(t, false)
+		pipeName := testPipeName("not-exist")
+		conn, err := socket.Dial(ctx, pipeName)
+		assert.Nil(t, conn)
+		require.Error(t, err)
+		t.Log(err)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		assert.Contains(t, err.Error(), "this usually means that the process is not running")
+	})
+}
@@ -0,0 +1,59 @@
+package svcalias
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+
+	"github.com/vishvananda/netlink"
+)
+
+// primaryNetwork returns the name of the link that has the default route, and its first IPv4 address
+// together with the network that the address belongs to.
+func primaryNetwork() (string, netip.Prefix, error) {
+	routes, err := netlink.RouteList(nil, netlink.FAMILY_V4)
+	if err != nil {
+		return "", netip.Prefix{}, err
+	}
+	for _, route := range routes {
+		if route.Dst != nil && route.Dst.IP != nil && !route.Dst.IP.IsUnspecified() {
+			continue
+		}
+		link, err := netlink.LinkByIndex(route.LinkIndex)
+		if err != nil {
+			return "", netip.Prefix{}, err
+		}
+		addrs, err := netlink.AddrList(link, netlink.FAMILY_V4)
+		if err != nil {
+			return "", netip.Prefix{}, err
+		}
+		for _, a := range addrs {
+			if ip, ok := netip.AddrFromSlice(a.IP.To4()); ok {
+				ones, _ := a.Mask.Size()
+				return link.Attrs().Name, netip.PrefixFrom(ip, ones), nil
+			}
+		}
+	}
+	return "", netip.Prefix{}, errors.New("found no interface with a default route and an IPv4 address")
+}
+
+func addAddress(linkName string, addr netip.Addr) error {
+	link, err := netlink.LinkByName(linkName)
+	if err != nil {
+		return err
+	}
+	return netlink.AddrAdd(link, hostAddr(addr))
+}
+
+func deleteAddress(linkName string, addr netip.Addr) error {
+	link, err := netlink.LinkByName(linkName)
+	if err != nil {
+		return err
+	}
+	return netlink.AddrDel(link, hostAddr(addr))
+}
+
+// hostAddr returns the given address with a host mask, so that no route is added for it.
+func hostAddr(addr netip.Addr) *netlink.Addr {
+	return &netlink.Addr{IPNet: &net.IPNet{IP: addr.AsSlice(), Mask: net.CIDRMask(addr.BitLen(), addr.BitLen())}}
+}
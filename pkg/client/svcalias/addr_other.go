@@ -0,0 +1,24 @@
+//go:build !linux
+// +build !linux
+
+package svcalias
+
+import (
+	"errors"
+	"net/netip"
+)
+
+// errUnsupported is returned on platforms where the containerized daemon doesn't run.
+var errUnsupported = errors.New("service aliases are only supported in a Linux container")
+
+func primaryNetwork() (string, netip.Prefix, error) {
+	return "", netip.Prefix{}, errUnsupported
+}
+
+func addAddress(string, netip.Addr) error {
+	return errUnsupported
+}
+
+func deleteAddress(string, netip.Addr) error {
+	return errUnsupported
+}
@@ -0,0 +1,58 @@
+package svcalias
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"github.com/datawire/dlib/dcontext"
+	"github.com/datawire/dlib/dlog"
+)
+
+// aliasTTL is the TTL of the answers for aliased services. It's short because the alias is removed when the
+// session ends.
+const aliasTTL = 5
+
+// ServeDNS serves DNS on the given connection. Names of aliased services are resolved to their aliases. All other
+// queries are forwarded to the given upstream server.
+func (r *Registry) ServeDNS(ctx context.Context, pc net.PacketConn, upstream string) error {
+	client := &dns.Client{Net: "udp", Timeout: 5 * time.Second}
+	srv := &dns.Server{PacketConn: pc, ReadTimeout: time.Second, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, q *dns.Msg) {
+		_ = w.WriteMsg(r.answer(ctx, client, q, upstream))
+	})}
+	go func() {
+		<-ctx.Done()
+		_ = srv.ShutdownContext(dcontext.HardContext(ctx))
+	}()
+	if err := srv.ActivateAndServe(); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+func (r *Registry) answer(ctx context.Context, client *dns.Client, q *dns.Msg, upstream string) *dns.Msg {
+	if len(q.Question) == 1 {
+		qn := q.Question[0]
+		if addr, ok := r.Lookup(qn.Name); ok {
+			m := new(dns.Msg)
+			m.SetReply(q)
+			m.Authoritative = true
+			if qn.Qtype == dns.TypeA || qn.Qtype == dns.TypeANY {
+				m.Answer = append(m.Answer, &dns.A{
+					Hdr: dns.RR_Header{Name: qn.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: aliasTTL},
+					A:   addr.AsSlice(),
+				})
+			}
+			return m
+		}
+	}
+	rsp, _, err := client.ExchangeContext(ctx, q, upstream)
+	if err != nil {
+		dlog.Debugf(ctx, "unable to forward DNS query to %s: %v", upstream, err)
+		m := new(dns.Msg)
+		return m.SetRcode(q, dns.RcodeServerFailure)
+	}
+	return rsp
+}
@@ -0,0 +1,263 @@
+// Package svcalias makes the services of a cluster reachable from containers that share a docker network with
+// the containerized daemon. Each service gets an address of its own on the daemon container's network interface,
+// where the daemon listens on the service's ports and forwards to the service's cluster IP through the TUN-device.
+// A DNS server on the daemon container resolves "<service>.<namespace>" to that address.
+package svcalias
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/forwarder"
+)
+
+// Port is a port of a service.
+type Port struct {
+	// Protocol is "TCP" or "UDP".
+	Protocol string
+	Port     uint16
+}
+
+// Service is a service that is given an alias.
+type Service struct {
+	Name      string
+	Namespace string
+	ClusterIP netip.Addr
+	Ports     []Port
+}
+
+func (s *Service) key() string {
+	return s.Name + "." + s.Namespace
+}
+
+type alias struct {
+	addr   netip.Addr
+	svc    Service
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// stop cancels the forwarders of the alias and waits for them to close their listeners.
+func (a *alias) stop() {
+	if a.cancel != nil {
+		a.cancel()
+		a.wg.Wait()
+	}
+}
+
+// Registry assigns addresses to services and forwards traffic that arrives at those addresses to the services.
+type Registry struct {
+	sync.RWMutex
+	link    string
+	network netip.Prefix
+	aliases map[string]*alias
+	used    map[netip.Addr]struct{}
+
+	// Functions that are replaced in tests.
+	addAddr func(link string, addr netip.Addr) error
+	delAddr func(link string, addr netip.Addr) error
+	forward func(ctx context.Context, listen net.Addr, targetHost string, targetPort uint16) error
+}
+
+type registryKey struct{}
+
+// WithRegistry returns a context with the given Registry.
+func WithRegistry(ctx context.Context, r *Registry) context.Context {
+	return context.WithValue(ctx, registryKey{}, r)
+}
+
+// GetRegistry returns the Registry of the given context, or nil when service aliases aren't enabled.
+func GetRegistry(ctx context.Context) *Registry {
+	r, _ := ctx.Value(registryKey{}).(*Registry)
+	return r
+}
+
+// NewRegistry returns a Registry that assigns addresses from the network of the interface that has the default
+// route, which in a container is the interface of the container's first docker network.
+func NewRegistry() (*Registry, error) {
+	link, network, err := primaryNetwork()
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine the network of the container: %w", err)
+	}
+	return newRegistry(link, network), nil
+}
+
+func newRegistry(link string, network netip.Prefix) *Registry {
+	return &Registry{
+		link:    link,
+		network: network,
+		aliases: make(map[string]*alias),
+		used:    map[netip.Addr]struct{}{network.Addr(): {}},
+		addAddr: addAddress,
+		delAddr: deleteAddress,
+		forward: func(ctx context.Context, listen net.Addr, targetHost string, targetPort uint16) error {
+			return forwarder.NewInterceptor(listen, targetHost, targetPort).Serve(ctx, nil)
+		},
+	}
+}
+
+// Address returns the address of the container on its network. This is the address that other containers
+// use as their DNS server.
+func (r *Registry) Address() netip.Addr {
+	return r.network.Addr()
+}
+
+// Lookup returns the address assigned to the service with the given name. The name is
+// "<service>.<namespace>", optionally followed by ".svc" and the cluster domain, and an optional trailing dot.
+func (r *Registry) Lookup(name string) (netip.Addr, bool) {
+	parts := strings.Split(strings.ToLower(strings.TrimSuffix(name, ".")), ".")
+	if len(parts) < 2 || len(parts) > 2 && parts[2] != "svc" {
+		return netip.Addr{}, false
+	}
+	r.RLock()
+	a, ok := r.aliases[parts[0]+"."+parts[1]]
+	r.RUnlock()
+	if !ok {
+		return netip.Addr{}, false
+	}
+	return a.addr, true
+}
+
+// Update makes the aliases reflect the given services. Services that keep their cluster IP and ports keep
+// their address and forwarders. Aliases of services that are no longer present are removed.
+func (r *Registry) Update(ctx context.Context, svcs []*Service) {
+	r.Lock()
+	defer r.Unlock()
+	desired := make(map[string]*Service, len(svcs))
+	for _, svc := range svcs {
+		if svc.ClusterIP.IsValid() && len(svc.Ports) > 0 {
+			desired[svc.key()] = svc
+		}
+	}
+	for key, a := range r.aliases {
+		if svc, ok := desired[key]; !ok || !sameTarget(&a.svc, svc) {
+			a.stop()
+			if !ok {
+				r.removeLocked(ctx, key, a)
+			}
+		}
+	}
+
+	// Assign addresses in a predictable order.
+	keys := make([]string, 0, len(desired))
+	for key := range desired {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		svc := desired[key]
+		a, ok := r.aliases[key]
+		if ok && sameTarget(&a.svc, svc) {
+			continue
+		}
+		if !ok {
+			addr, err := r.allocateLocked()
+			if err != nil {
+				dlog.Errorf(ctx, "unable to create alias for service %s: %v", key, err)
+				return
+			}
+			if err = r.addAddr(r.link, addr); err != nil {
+				dlog.Errorf(ctx, "unable to add address %s for service %s: %v", addr, key, err)
+				delete(r.used, addr)
+				continue
+			}
+			a = &alias{addr: addr}
+			r.aliases[key] = a
+			dlog.Debugf(ctx, "service %s is aliased as %s", key, addr)
+		}
+		a.svc = *svc
+		r.startForwarders(ctx, a)
+	}
+}
+
+// Close removes all aliases.
+func (r *Registry) Close(ctx context.Context) {
+	r.Lock()
+	defer r.Unlock()
+	for key, a := range r.aliases {
+		a.stop()
+		r.removeLocked(ctx, key, a)
+	}
+}
+
+func (r *Registry) removeLocked(ctx context.Context, key string, a *alias) {
+	if err := r.delAddr(r.link, a.addr); err != nil {
+		dlog.Errorf(ctx, "unable to remove address %s of service %s: %v", a.addr, key, err)
+	}
+	delete(r.aliases, key)
+	delete(r.used, a.addr)
+}
+
+func (r *Registry) startForwarders(ctx context.Context, a *alias) {
+	ctx, a.cancel = context.WithCancel(ctx)
+	ip := a.addr.AsSlice()
+	target := a.svc.ClusterIP.String()
+	for _, p := range a.svc.Ports {
+		var listen net.Addr
+		switch p.Protocol {
+		case "", "TCP":
+			listen = &net.TCPAddr{IP: ip, Port: int(p.Port)}
+		case "UDP":
+			listen = &net.UDPAddr{IP: ip, Port: int(p.Port)}
+		default:
+			continue
+		}
+		a.wg.Add(1)
+		go func(port uint16) {
+			defer a.wg.Done()
+			if err := r.forward(ctx, listen, target, port); err != nil && ctx.Err() == nil {
+				dlog.Errorf(ctx, "unable to forward %s to %s.%s: %v", listen, a.svc.Name, a.svc.Namespace, err)
+			}
+		}(p.Port)
+	}
+}
+
+// allocateLocked returns a free address in the network. Docker assigns the addresses of containers from the
+// start of the network, so the aliases are assigned from the end to avoid collisions.
+func (r *Registry) allocateLocked() (netip.Addr, error) {
+	last := lastAddr(r.network)
+	for a := last.Prev(); a.IsValid() && r.network.Contains(a); a = a.Prev() {
+		if a == r.network.Masked().Addr() {
+			break
+		}
+		if _, ok := r.used[a]; !ok {
+			r.used[a] = struct{}{}
+			return a, nil
+		}
+	}
+	return netip.Addr{}, errors.New("no free addresses in " + r.network.Masked().String())
+}
+
+// lastAddr returns the last address, i.e. the broadcast address, of the given network.
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Masked().Addr().AsSlice()
+	bits := p.Bits()
+	for i := range b {
+		for j := 0; j < 8; j++ {
+			if i*8+j >= bits {
+				b[i] |= 0x80 >> j
+			}
+		}
+	}
+	a, _ := netip.AddrFromSlice(b)
+	return a
+}
+
+func sameTarget(a, b *Service) bool {
+	if a.ClusterIP != b.ClusterIP || len(a.Ports) != len(b.Ports) {
+		return false
+	}
+	for i := range a.Ports {
+		if a.Ports[i] != b.Ports[i] {
+			return false
+		}
+	}
+	return true
+}
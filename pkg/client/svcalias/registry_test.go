@@ -0,0 +1,180 @@
+package svcalias
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+)
+
+type fakeNet struct {
+	sync.Mutex
+	addrs    map[netip.Addr]struct{}
+	forwards map[string]string
+}
+
+func newTestRegistry(network string) (*Registry, *fakeNet) {
+	fn := &fakeNet{addrs: make(map[netip.Addr]struct{}), forwards: make(map[string]string)}
+	r := newRegistry("eth0", netip.MustParsePrefix(network))
+	r.addAddr = func(_ string, a netip.Addr) error {
+		fn.Lock()
+		fn.addrs[a] = struct{}{}
+		fn.Unlock()
+		return nil
+	}
+	r.delAddr = func(_ string, a netip.Addr) error {
+		fn.Lock()
+		delete(fn.addrs, a)
+		fn.Unlock()
+		return nil
+	}
+	r.forward = func(ctx context.Context, listen net.Addr, host string, _ uint16) error {
+		key := listen.Network() + " " + listen.String()
+		fn.Lock()
+		fn.forwards[key] = host
+		fn.Unlock()
+		<-ctx.Done()
+		fn.Lock()
+		delete(fn.forwards, key)
+		fn.Unlock()
+		return nil
+	}
+	return r, fn
+}
+
+func (fn *fakeNet) addrCount() int {
+	fn.Lock()
+	defer fn.Unlock()
+	return len(fn.addrs)
+}
+
+func TestLastAddr(t *testing.T) {
+	assert.Equal(t, netip.MustParseAddr("172.18.255.255"), lastAddr(netip.MustParsePrefix("172.18.0.2/16")))
+	assert.Equal(t, netip.MustParseAddr("10.0.0.7"), lastAddr(netip.MustParsePrefix("10.0.0.3/29")))
+}
+
+func TestRegistry(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	r, fn := newTestRegistry("10.0.0.2/29") // 10.0.0.0 - 10.0.0.7
+	assert.Equal(t, netip.MustParseAddr("10.0.0.2"), r.Address())
+
+	echo := &Service{Name: "echo", Namespace: "default", ClusterIP: netip.MustParseAddr("10.96.0.10"), Ports: []Port{{"TCP", 80}, {"UDP", 53}}}
+	web := &Service{Name: "web", Namespace: "prod", ClusterIP: netip.MustParseAddr("10.96.0.11"), Ports: []Port{{"TCP", 80}}}
+	headless := &Service{Name: "db", Namespace: "prod"}
+	r.Update(ctx, []*Service{web, echo, headless})
+
+	// Addresses are assigned from the end of the network, in order of the service keys.
+	a, ok := r.Lookup("echo.default")
+	require.True(t, ok)
+	assert.Equal(t, netip.MustParseAddr("10.0.0.6"), a)
+	for _, n := range []string{"ECHO.default.", "echo.default.svc", "echo.default.svc.cluster.local."} {
+		b, ok := r.Lookup(n)
+		assert.True(t, ok, n)
+		assert.Equal(t, a, b, n)
+	}
+	_, ok = r.Lookup("echo")
+	assert.False(t, ok)
+	_, ok = r.Lookup("echo.default.com")
+	assert.False(t, ok)
+	_, ok = r.Lookup("db.prod")
+	assert.False(t, ok)
+	w, ok := r.Lookup("web.prod")
+	require.True(t, ok)
+	assert.Equal(t, netip.MustParseAddr("10.0.0.5"), w)
+	assert.Equal(t, 2, fn.addrCount())
+
+	assert.Eventually(t, func() bool {
+		fn.Lock()
+		defer fn.Unlock()
+		return fn.forwards["tcp 10.0.0.6:80"] == "10.96.0.10" &&
+			fn.forwards["udp 10.0.0.6:53"] == "10.96.0.10" &&
+			fn.forwards["tcp 10.0.0.5:80"] == "10.96.0.11"
+	}, time.Second, 10*time.Millisecond)
+
+	// Removing a service releases its address, and a changed service keeps its address.
+	echo2 := *echo
+	echo2.ClusterIP = netip.MustParseAddr("10.96.0.20")
+	r.Update(ctx, []*Service{&echo2})
+	_, ok = r.Lookup("web.prod")
+	assert.False(t, ok)
+	a, _ = r.Lookup("echo.default")
+	assert.Equal(t, netip.MustParseAddr("10.0.0.6"), a)
+	assert.Equal(t, 1, fn.addrCount())
+	assert.Eventually(t, func() bool {
+		fn.Lock()
+		defer fn.Unlock()
+		_, webFwd := fn.forwards["tcp 10.0.0.5:80"]
+		return fn.forwards["tcp 10.0.0.6:80"] == "10.96.0.20" && !webFwd
+	}, time.Second, 10*time.Millisecond)
+
+	// The network has room for five aliases, 10.0.0.6 down to 10.0.0.1, minus the container's own address.
+	var many []*Service
+	for _, n := range []string{"a", "b", "c", "d", "e", "f"} {
+		many = append(many, &Service{Name: n, Namespace: "x", ClusterIP: netip.MustParseAddr("10.96.1.1"), Ports: []Port{{"TCP", 80}}})
+	}
+	r.Update(ctx, many)
+	assert.Equal(t, 5, fn.addrCount())
+	_, ok = r.Lookup("e.x")
+	assert.True(t, ok)
+	_, ok = r.Lookup("f.x")
+	assert.False(t, ok)
+
+	r.Close(ctx)
+	assert.Equal(t, 0, fn.addrCount())
+	_, ok = r.Lookup("a.x")
+	assert.False(t, ok)
+}
+
+func TestServeDNS(t *testing.T) {
+	ctx, cancel := context.WithCancel(dlog.NewTestContext(t, false))
+	defer cancel()
+
+	// An upstream server that answers everything with 192.0.2.1.
+	upstream, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	us := &dns.Server{PacketConn: upstream, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, q *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(q)
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: q.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP("192.0.2.1"),
+		})
+		_ = w.WriteMsg(m)
+	})}
+	go func() { _ = us.ActivateAndServe() }()
+	defer func() { _ = us.Shutdown() }()
+
+	r, _ := newTestRegistry("10.0.0.2/24")
+	r.Update(ctx, []*Service{{Name: "echo", Namespace: "default", ClusterIP: netip.MustParseAddr("10.96.0.10"), Ports: []Port{{"TCP", 80}}}})
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = r.ServeDNS(ctx, pc, upstream.LocalAddr().String()) }()
+
+	query := func(name string, qt uint16) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion(name, qt)
+		rsp, _, err := (&dns.Client{Net: "udp"}).Exchange(m, pc.LocalAddr().String())
+		require.NoError(t, err)
+		return rsp
+	}
+	rsp := query("echo.default.svc.cluster.local.", dns.TypeA)
+	require.Len(t, rsp.Answer, 1)
+	assert.Equal(t, "10.0.0.254", rsp.Answer[0].(*dns.A).A.String())
+
+	rsp = query("echo.default.", dns.TypeAAAA)
+	assert.Equal(t, dns.RcodeSuccess, rsp.Rcode)
+	assert.Empty(t, rsp.Answer)
+
+	rsp = query("example.com.", dns.TypeA)
+	require.Len(t, rsp.Answer, 1)
+	assert.Equal(t, "192.0.2.1", rsp.Answer[0].(*dns.A).A.String())
+}
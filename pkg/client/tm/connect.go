@@ -2,33 +2,120 @@ package tm
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net"
+	"os"
+	"strings"
+	"time"
 
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	empty "google.golang.org/protobuf/types/known/emptypb"
 
 	"github.com/datawire/dlib/dlog"
 	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/atrest"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/dnet"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
 )
 
-func ConnectToManager(ctx context.Context, namespace string, grpcDialer dnet.DialerFunc) (*grpc.ClientConn, manager.ManagerClient, *manager.VersionInfo2, error) {
-	grpcAddr := net.JoinHostPort("svc/traffic-manager."+namespace, "api")
+// reconnectBackoff is used when the connection to the traffic-manager is lost. The gRPC default
+// lets the delay between attempts grow to two minutes, which is far longer than it takes for
+// another replica to take over, or for a restarted traffic-manager to become ready.
+var reconnectBackoff = backoff.Config{
+	BaseDelay:  100 * time.Millisecond,
+	Multiplier: 1.6,
+	Jitter:     0.2,
+	MaxDelay:   3 * time.Second,
+}
 
-	// First check. Establish connection
-	opts := []grpc.DialOption{
-		grpc.WithContextDialer(grpcDialer),
+// ConnectToManager connects to the traffic-manager in the given namespace using the given dialer,
+// which is normally a port-forward dialer. When the traffic-manager has several ready replicas, they
+// are dialed concurrently and the connection fails over to another replica when it is lost.
+func ConnectToManager(ctx context.Context, namespace string, grpcDialer dnet.DialerFunc) (*grpc.ClientConn, manager.ManagerClient, *manager.VersionInfo2, error) {
+	grpcAddr := net.JoinHostPort("svc/"+managerName+"."+namespace, managerAPIPort)
+	return connectToManager(ctx, grpcAddr,
+		grpc.WithContextDialer(failoverDialer(ctx, namespace, grpcDialer)),
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
 		grpc.WithNoProxy(),
+	)
+}
+
+// ConnectToManagerEndpoint connects to the traffic-manager at the given endpoint, e.g. a LoadBalancer or an
+// Ingress, instead of through a port-forward.
+func ConnectToManagerEndpoint(ctx context.Context, ep *client.ManagerEndpoint) (*grpc.ClientConn, manager.ManagerClient, *manager.VersionInfo2, error) {
+	var opts []grpc.DialOption
+	if ep.Insecure {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	} else {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if ep.CAFile != "" {
+			pem, err := os.ReadFile(ep.CAFile)
+			if err != nil {
+				return nil, nil, nil, errcat.Config.Newf("unable to read manager CA file: %w", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, nil, nil, errcat.Config.Newf("no certificates found in manager CA file %s", ep.CAFile)
+			}
+		}
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	}
+	var token string
+	if ep.TokenFile != "" {
+		tb, err := os.ReadFile(ep.TokenFile)
+		if err != nil {
+			return nil, nil, nil, errcat.Config.Newf("unable to read manager token file: %w", err)
+		}
+		token = strings.TrimSpace(string(tb))
+	} else {
+		var err error
+		if token, err = atrest.LoadToken(ctx, ep.TokenName()); err != nil {
+			return nil, nil, nil, errcat.Config.Newf("unable to load the stored manager token: %w", err)
+		}
+	}
+	if token != "" {
+		if ep.Insecure {
+			return nil, nil, nil, errcat.Config.New("the manager token cannot be sent over an insecure connection")
+		}
+		opts = append(opts, grpc.WithPerRPCCredentials(bearerToken(token)))
+	}
+	if ep.Dial != nil {
+		opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return ep.Dial(ctx, "tcp", addr)
+		}))
+	}
+	dlog.Infof(ctx, "Connecting to traffic-manager at %s", ep.Address)
+	return connectToManager(ctx, ep.Address, opts...)
+}
+
+// bearerToken is a credentials.PerRPCCredentials that sends a token in the authorization header.
+type bearerToken string
+
+func (t bearerToken) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+func (t bearerToken) RequireTransportSecurity() bool {
+	return true
+}
+
+func connectToManager(ctx context.Context, grpcAddr string, extraOpts ...grpc.DialOption) (*grpc.ClientConn, manager.ManagerClient, *manager.VersionInfo2, error) {
+	// First check. Establish connection
+	opts := append([]grpc.DialOption{
 		grpc.WithBlock(),
 		grpc.WithReturnConnectionError(),
+		grpc.WithConnectParams(grpc.ConnectParams{Backoff: reconnectBackoff, MinConnectTimeout: 5 * time.Second}),
 		grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
 		grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor()),
-	}
+	}, extraOpts...)
+	opts = append(opts, client.GetConfig(ctx).Grpc().DialOptions(client.ChannelManager)...)
 
 	conn, err := grpc.DialContext(ctx, grpcAddr, opts...)
 	if err != nil {
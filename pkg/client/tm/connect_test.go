@@ -0,0 +1,29 @@
+package tm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+func TestConnectToManagerEndpoint_insecureToken(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("s3cret\n"), 0o600))
+
+	_, _, _, err := ConnectToManagerEndpoint(ctx, &client.ManagerEndpoint{
+		Address:   "traffic-manager.example.com:443",
+		Insecure:  true,
+		TokenFile: tokenFile,
+	})
+	require.Error(t, err)
+	assert.Equal(t, errcat.Config, errcat.GetCategory(err))
+	assert.Contains(t, err.Error(), "insecure connection")
+}
@@ -0,0 +1,152 @@
+package tm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/v2/pkg/dnet"
+)
+
+// attemptDelay is the time to wait for a connection attempt before the next attempt is started
+// concurrently. This is the "Connection Attempt Delay" recommended by RFC 8305.
+const attemptDelay = 250 * time.Millisecond
+
+const (
+	managerName    = "traffic-manager"
+	managerAPIPort = "api"
+)
+
+// failoverDialer returns a dialer that dials all ready traffic-manager pods concurrently each time the
+// gRPC connection is (re-)established, and uses the first connection that succeeds. This makes the
+// connection fail over to another replica as soon as the pod that it's connected to goes away. The
+// dialer falls back to the given address when no ready pods are found.
+//
+// The context that gRPC passes to the dialer carries no values, so the Kubernetes interface and the
+// logger are taken from the given context.
+func failoverDialer(ctx context.Context, namespace string, dial dnet.DialerFunc) dnet.DialerFunc {
+	ki := k8sapi.GetK8sInterface(ctx)
+	return func(dc context.Context, addr string) (net.Conn, error) {
+		addrs, err := managerPodAddresses(dc, ki, namespace)
+		if err != nil {
+			dlog.Debugf(ctx, "unable to list %s pods: %v", managerName, err)
+		}
+		if len(addrs) == 0 {
+			return dial(dc, addr)
+		}
+		conn, err := happyEyeballs(dc, addrs, dial)
+		if err != nil {
+			return nil, err
+		}
+		dlog.Debugf(ctx, "connected to %s", conn.RemoteAddr())
+		return conn, nil
+	}
+}
+
+// managerPodAddresses returns the port-forward addresses of the ready endpoints of the traffic-manager
+// service in the given namespace.
+func managerPodAddresses(ctx context.Context, ki kubernetes.Interface, namespace string) ([]string, error) {
+	if ki == nil {
+		return nil, nil
+	}
+	ep, err := ki.CoreV1().Endpoints(namespace).Get(ctx, managerName, meta.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var addrs []string
+	for _, ss := range ep.Subsets {
+		for _, ea := range ss.Addresses {
+			if ref := ea.TargetRef; ref != nil && ref.Kind == "Pod" {
+				addrs = append(addrs, podAddress(ref))
+			}
+		}
+	}
+	return addrs, nil
+}
+
+func podAddress(ref *core.ObjectReference) string {
+	return net.JoinHostPort(fmt.Sprintf("pods/%s.%s", ref.Name, ref.Namespace), managerAPIPort)
+}
+
+// happyEyeballs dials the given addresses concurrently and returns the first connection that is
+// established. The attempts are started in order. Each attempt starts when the previous attempt
+// fails or after attemptDelay, whichever comes first. Connections of attempts that are still in
+// progress when the first connection is established are closed.
+func happyEyeballs(ctx context.Context, addrs []string, dial dnet.DialerFunc) (net.Conn, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("no addresses to dial")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result)
+	pending := 0
+	drain := func() {
+		go func(n int) {
+			for ; n > 0; n-- {
+				if r := <-results; r.conn != nil {
+					_ = r.conn.Close()
+				}
+			}
+		}(pending)
+	}
+
+	var errs error
+	next := 0
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for next < len(addrs) || pending > 0 {
+		var startNext <-chan time.Time
+		if next < len(addrs) {
+			startNext = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			drain()
+			return nil, ctx.Err()
+		case <-startNext:
+			addr := addrs[next]
+			next++
+			pending++
+			go func() {
+				conn, err := dial(ctx, addr)
+				if err != nil {
+					err = fmt.Errorf("%s: %w", addr, err)
+				}
+				results <- result{conn: conn, err: err}
+			}()
+			timer.Reset(attemptDelay)
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				drain()
+				return r.conn, nil
+			}
+			errs = multierror.Append(errs, r.err)
+			if next < len(addrs) {
+				// Start the next attempt right away.
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(0)
+			}
+		}
+	}
+	return nil, errs
+}
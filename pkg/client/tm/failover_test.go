@@ -0,0 +1,149 @@
+package tm
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/datawire/dlib/dlog"
+)
+
+type fakeAddr string
+
+func (a fakeAddr) Network() string { return "fake" }
+func (a fakeAddr) String() string  { return string(a) }
+
+type fakeConn struct {
+	net.Conn
+	addr   string
+	closed chan struct{}
+}
+
+func (c *fakeConn) RemoteAddr() net.Addr {
+	return fakeAddr(c.addr)
+}
+
+func (c *fakeConn) Close() error {
+	close(c.closed)
+	return nil
+}
+
+// fakeDialer dials addresses with the given delays. Addresses without a delay fail immediately.
+type fakeDialer struct {
+	sync.Mutex
+	delays map[string]time.Duration
+	conns  []*fakeConn
+}
+
+func (d *fakeDialer) dial(ctx context.Context, addr string) (net.Conn, error) {
+	delay, ok := d.delays[addr]
+	if !ok {
+		return nil, errors.New("connection refused")
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(delay):
+	}
+	c := &fakeConn{addr: addr, closed: make(chan struct{})}
+	d.Lock()
+	d.conns = append(d.conns, c)
+	d.Unlock()
+	return c, nil
+}
+
+func TestHappyEyeballs(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+
+	t.Run("first wins", func(t *testing.T) {
+		d := &fakeDialer{delays: map[string]time.Duration{"a": 0, "b": 0}}
+		conn, err := happyEyeballs(ctx, []string{"a", "b"}, d.dial)
+		require.NoError(t, err)
+		assert.Equal(t, "a", conn.RemoteAddr().String())
+		assert.Len(t, d.conns, 1, "second attempt must not start before attemptDelay")
+	})
+
+	t.Run("failed attempt starts next immediately", func(t *testing.T) {
+		d := &fakeDialer{delays: map[string]time.Duration{"b": 0}}
+		start := time.Now()
+		conn, err := happyEyeballs(ctx, []string{"a", "b"}, d.dial)
+		require.NoError(t, err)
+		assert.Equal(t, "b", conn.RemoteAddr().String())
+		assert.Less(t, time.Since(start), attemptDelay)
+	})
+
+	t.Run("slow attempt is overtaken", func(t *testing.T) {
+		d := &fakeDialer{delays: map[string]time.Duration{"a": 2 * time.Second, "b": 0}}
+		start := time.Now()
+		conn, err := happyEyeballs(ctx, []string{"a", "b"}, d.dial)
+		require.NoError(t, err)
+		assert.Equal(t, "b", conn.RemoteAddr().String())
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("late connection is closed", func(t *testing.T) {
+		d := &fakeDialer{delays: map[string]time.Duration{"a": attemptDelay + 50*time.Millisecond, "b": 0}}
+		// Attempt "b" starts after attemptDelay and succeeds right away, while "a" completes shortly
+		// after and is ignored, because the dial context of "a" is cancelled.
+		conn, err := happyEyeballs(ctx, []string{"a", "b"}, d.dial)
+		require.NoError(t, err)
+		assert.Equal(t, "b", conn.RemoteAddr().String())
+		time.Sleep(2 * attemptDelay)
+		d.Lock()
+		defer d.Unlock()
+		for _, c := range d.conns {
+			if c != conn {
+				select {
+				case <-c.closed:
+				default:
+					t.Errorf("connection to %s was not closed", c.addr)
+				}
+			}
+		}
+	})
+
+	t.Run("all fail", func(t *testing.T) {
+		d := &fakeDialer{}
+		_, err := happyEyeballs(ctx, []string{"a", "b"}, d.dial)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "a: connection refused")
+		assert.Contains(t, err.Error(), "b: connection refused")
+	})
+}
+
+func TestManagerPodAddresses(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	podRef := func(name string) *core.ObjectReference {
+		return &core.ObjectReference{Kind: "Pod", Name: name, Namespace: "ambassador"}
+	}
+	ki := fake.NewSimpleClientset(&core.Endpoints{
+		ObjectMeta: meta.ObjectMeta{Name: managerName, Namespace: "ambassador"},
+		Subsets: []core.EndpointSubset{{
+			Addresses: []core.EndpointAddress{
+				{IP: "10.1.0.1", TargetRef: podRef("traffic-manager-1")},
+				{IP: "10.1.0.2", TargetRef: podRef("traffic-manager-2")},
+			},
+			NotReadyAddresses: []core.EndpointAddress{
+				{IP: "10.1.0.3", TargetRef: podRef("traffic-manager-3")},
+			},
+		}},
+	})
+	addrs, err := managerPodAddresses(ctx, ki, "ambassador")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"pods/traffic-manager-1.ambassador:api",
+		"pods/traffic-manager-2.ambassador:api",
+	}, addrs)
+
+	_, err = managerPodAddresses(ctx, ki, "other")
+	assert.Error(t, err)
+}
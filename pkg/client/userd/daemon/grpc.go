@@ -7,8 +7,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -29,11 +32,15 @@ import (
 	"github.com/telepresenceio/telepresence/rpc/v2/manager"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/logging"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/paths"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/platform"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/scout"
-	"github.com/telepresenceio/telepresence/v2/pkg/client/socket"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/userd"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/userd/k8s"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/userd/trafficmgr"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/wsl"
 	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
 	"github.com/telepresenceio/telepresence/v2/pkg/install/helm"
 	"github.com/telepresenceio/telepresence/v2/pkg/proc"
 	"github.com/telepresenceio/telepresence/v2/pkg/tracing"
@@ -111,10 +118,12 @@ func (s *service) Version(_ context.Context, _ *empty.Empty) (*common.VersionInf
 		return &common.VersionInfo{}, err
 	}
 	return &common.VersionInfo{
-		ApiVersion: client.APIVersion,
-		Version:    client.Version(),
-		Executable: executable,
-		Name:       client.DisplayName,
+		ApiVersion:          client.APIVersion,
+		Version:             client.Version(),
+		Executable:          executable,
+		Name:                client.DisplayName,
+		Platform:            platform.Name(),
+		UnavailableFeatures: platform.UnavailableFeatures(),
 	}, nil
 }
 
@@ -139,6 +148,7 @@ func (s *service) Connect(ctx context.Context, cr *rpc.ConnectRequest) (result *
 func (s *service) Disconnect(ctx context.Context, ex *empty.Empty) (*empty.Empty, error) {
 	s.logCall(ctx, "Disconnect", func(ctx context.Context) {
 		s.cancelSession()
+		s.leases.clear()
 		_ = s.withRootDaemon(ctx, func(ctx context.Context, rd daemon.DaemonClient) error {
 			_, err := rd.Disconnect(ctx, ex)
 			return err
@@ -273,6 +283,9 @@ func (s *service) CreateIntercept(c context.Context, ir *rpc.CreateInterceptRequ
 		result = session.AddIntercept(c, ir)
 		if result != nil && result.InterceptInfo != nil {
 			tracing.RecordInterceptInfo(span, result.InterceptInfo)
+			if ir.IsPodDaemon && result.Error == common.InterceptError_UNSPECIFIED {
+				s.retainPodIntercept(ir)
+			}
 		}
 		entries, ok = s.scoutInterceptEntries(c, ir.GetSpec(), result)
 		return nil
@@ -300,6 +313,7 @@ func (s *service) RemoveIntercept(c context.Context, rr *manager.RemoveIntercept
 			result.ServiceUid = spec.ServiceUid
 			result.WorkloadKind = spec.WorkloadKind
 		}
+		s.forgetPodIntercept(rr.Name)
 		if err := session.RemoveIntercept(c, rr.Name); err != nil {
 			if status.Code(err) == codes.NotFound {
 				result.Error = common.InterceptError_NOT_FOUND
@@ -325,6 +339,12 @@ func (s *service) UpdateIntercept(c context.Context, rr *manager.UpdateIntercept
 	return
 }
 
+func (s *service) SwitchInterceptHandler(ctx context.Context, rr *rpc.SwitchInterceptHandlerRequest) (*empty.Empty, error) {
+	return &empty.Empty{}, s.WithSession(ctx, "SwitchInterceptHandler", func(c context.Context, session userd.Session) error {
+		return session.SwitchInterceptHandler(c, rr.Name, rr.Handler)
+	})
+}
+
 func (s *service) AddInterceptor(ctx context.Context, interceptor *rpc.Interceptor) (*empty.Empty, error) {
 	return &empty.Empty{}, s.WithSession(ctx, "AddInterceptor", func(_ context.Context, session userd.Session) error {
 		return session.AddInterceptor(interceptor.InterceptId, interceptor)
@@ -337,9 +357,72 @@ func (s *service) RemoveInterceptor(ctx context.Context, interceptor *rpc.Interc
 	})
 }
 
+func (s *service) ListTakeOverRequests(c context.Context, _ *empty.Empty) (result *rpc.TakeOverRequestList, err error) {
+	err = s.WithSession(c, "ListTakeOverRequests", func(_ context.Context, session userd.Session) error {
+		result = &rpc.TakeOverRequestList{Requests: session.TakeOverRequests()}
+		return nil
+	})
+	return
+}
+
+func (s *service) AnswerTakeOver(c context.Context, ta *rpc.TakeOverAnswer) (*empty.Empty, error) {
+	return &empty.Empty{}, s.WithSession(c, "AnswerTakeOver", func(c context.Context, session userd.Session) error {
+		return session.AnswerTakeOver(c, ta.Id, ta.Accept)
+	})
+}
+
+func (s *service) GetUsageReport(c context.Context, ur *manager.UsageRequest) (result *manager.UsageReport, err error) {
+	err = s.WithSession(c, "GetUsageReport", func(c context.Context, session userd.Session) error {
+		result, err = session.ManagerClient().GetUsageReport(c, ur)
+		return err
+	})
+	return
+}
+
+func (s *service) GetManagerProfile(c context.Context, pr *manager.ProfileRequest) (result *manager.ProfileResponse, err error) {
+	err = s.WithSession(c, "GetManagerProfile", func(c context.Context, session userd.Session) error {
+		result, err = session.ManagerClient().GetProfile(c, pr)
+		return err
+	})
+	return
+}
+
+func (s *service) GetInterceptMetrics(c context.Context, request *manager.GetInterceptRequest) (result *manager.InterceptMetrics, err error) {
+	err = s.WithSession(c, "GetInterceptMetrics", func(c context.Context, session userd.Session) error {
+		if session.GetInterceptInfo(request.Name) == nil {
+			return status.Errorf(codes.NotFound, "found no intercept named %s", request.Name)
+		}
+		result, err = session.ManagerClient().GetInterceptMetrics(c, &manager.GetInterceptRequest{
+			Session: session.SessionInfo(),
+			Name:    request.Name,
+		})
+		return err
+	})
+	return
+}
+
+func (s *service) SampleIntercept(c context.Context, request *manager.SampleInterceptRequest) (result *manager.SampleResult, err error) {
+	err = s.WithSession(c, "SampleIntercept", func(c context.Context, session userd.Session) error {
+		ns := session.ActualNamespace(request.Namespace)
+		if ns == "" {
+			return errcat.User.Newf("namespace %s is not mapped", request.Namespace)
+		}
+		result, err = session.ManagerClient().SampleIntercept(c, &manager.SampleInterceptRequest{
+			Session:               session.SessionInfo(),
+			Namespace:             ns,
+			AgentName:             request.AgentName,
+			ServicePortIdentifier: request.ServicePortIdentifier,
+			Filter:                request.Filter,
+			Duration:              request.Duration,
+		})
+		return err
+	})
+	return
+}
+
 func (s *service) List(c context.Context, lr *rpc.ListRequest) (result *rpc.WorkloadInfoSnapshot, err error) {
 	err = s.WithSession(c, "List", func(c context.Context, session userd.Session) error {
-		result, err = session.WorkloadInfoSnapshot(c, []string{lr.Namespace}, lr.Filter)
+		result, err = session.WorkloadInfoSnapshot(c, []string{lr.Namespace}, lr.Filter, lr.IncludeRoutes)
 		return err
 	})
 	return
@@ -384,6 +467,10 @@ func (s *service) GetConfig(ctx context.Context, empty *empty.Empty) (cfg *rpc.C
 	return
 }
 
+func (s *service) Paths(ctx context.Context, _ *empty.Empty) (*rpc.DaemonPaths, error) {
+	return paths.Effective(ctx), nil
+}
+
 func (s *service) GatherLogs(ctx context.Context, request *rpc.LogsRequest) (result *rpc.LogsResponse, err error) {
 	err = s.WithSession(ctx, "GatherLogs", func(c context.Context, session userd.Session) error {
 		result, err = session.GatherLogs(c, request)
@@ -392,6 +479,86 @@ func (s *service) GatherLogs(ctx context.Context, request *rpc.LogsRequest) (res
 	return
 }
 
+// Names of the log sources that StreamLogs reads from the local log files.
+const (
+	logSourceRootd     = "rootd"
+	logSourceConnector = "connector"
+)
+
+func (s *service) StreamLogs(rq *rpc.StreamLogsRequest, stream rpc.Connector_StreamLogsServer) error {
+	var local []string
+	var remote bool
+	if len(rq.Sources) == 0 {
+		local = []string{logSourceRootd, logSourceConnector}
+	}
+	for _, source := range rq.Sources {
+		switch source {
+		case logSourceRootd, logSourceConnector:
+			local = append(local, source)
+		case trafficmgr.LogSourceManager, trafficmgr.LogSourceAgent:
+			remote = true
+		default:
+			return status.Errorf(codes.InvalidArgument, "invalid log source %q, valid sources are %s, %s, %s, and %s",
+				source, logSourceRootd, logSourceConnector, trafficmgr.LogSourceManager, trafficmgr.LogSourceAgent)
+		}
+	}
+
+	var sessionCtx context.Context
+	var session userd.Session
+	if remote || len(rq.Sources) == 0 {
+		err := s.WithSession(stream.Context(), "StreamLogs", func(c context.Context, s userd.Session) error {
+			session, sessionCtx = s, c
+			return nil
+		})
+		if err != nil && remote {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	var sendLock sync.Mutex
+	send := func(le *rpc.LogEntry) error {
+		sendLock.Lock()
+		defer sendLock.Unlock()
+		return stream.Send(le)
+	}
+
+	g := dgroup.NewGroup(ctx, dgroup.GroupConfig{})
+	logDir := filelocation.AppUserLogDir(ctx)
+	for _, source := range local {
+		source := source
+		logFile := filepath.Join(logDir, userd.ProcessName+".log")
+		if source == logSourceRootd {
+			logFile = filepath.Join(logDir, "daemon.log")
+		}
+		g.Go(source, func(ctx context.Context) error {
+			err := logging.Tail(ctx, logFile, int(rq.Tail), rq.CurrentSession, rq.Follow, func(line string) error {
+				return send(&rpc.LogEntry{Source: source, Text: line})
+			})
+			if os.IsNotExist(err) && len(rq.Sources) == 0 {
+				// Only an error when explicitly requested
+				err = nil
+			}
+			return err
+		})
+	}
+	if session != nil {
+		g.Go("cluster", func(ctx context.Context) error {
+			// The stream must end when the session ends.
+			go func() {
+				select {
+				case <-ctx.Done():
+				case <-sessionCtx.Done():
+					cancel()
+				}
+			}()
+			return session.StreamPodLogs(ctx, rq, send)
+		})
+	}
+	return g.Wait()
+}
+
 func (s *service) SetLogLevel(ctx context.Context, request *rpc.LogLevelRequest) (result *empty.Empty, err error) {
 	s.logCall(ctx, "SetLogLevel", func(c context.Context) {
 		mrq := &manager.LogLevelRequest{
@@ -438,6 +605,7 @@ func (s *service) Quit(ctx context.Context, ex *empty.Empty) (*empty.Empty, erro
 		s.sessionLock.RLock()
 		defer s.sessionLock.RUnlock()
 		s.cancelSessionReadLocked()
+		s.leases.clear()
 		s.quit()
 		_ = s.withRootDaemon(ctx, func(ctx context.Context, rd daemon.DaemonClient) error {
 			_, err := rd.Quit(ctx, ex)
@@ -450,6 +618,12 @@ func (s *service) Quit(ctx context.Context, ex *empty.Empty) (*empty.Empty, erro
 func (s *service) Helm(ctx context.Context, req *rpc.HelmRequest) (*common.Result, error) {
 	result := &common.Result{}
 	s.logCall(ctx, "Helm", func(c context.Context) {
+		if req.Preview {
+			// A preview leaves the traffic-manager intact, so the session can stay alive.
+			result = s.helmPreview(ctx, req)
+			return
+		}
+
 		// Temporarily disable quit so that session cancel doesn't cancel everything
 		s.quitDisable = true
 		if s.rootSessionInProc {
@@ -522,12 +696,45 @@ func (s *service) Helm(ctx context.Context, req *rpc.HelmRequest) (*common.Resul
 	return result, nil
 }
 
+// helmPreview returns a result with the diff that a helm upgrade with the given request would apply to the
+// installed release.
+func (s *service) helmPreview(ctx context.Context, req *rpc.HelmRequest) *common.Result {
+	if req.Type != rpc.HelmRequest_UPGRADE {
+		return errcat.ToResult(errcat.User.New("only an upgrade can be previewed"))
+	}
+	config, err := client.DaemonKubeconfig(ctx, req.ConnectRequest)
+	if err != nil {
+		return errcat.ToResult(err)
+	}
+	cr := req.GetConnectRequest()
+	if cr == nil {
+		cr = &rpc.ConnectRequest{}
+	}
+	cluster, err := k8s.ConnectCluster(ctx, cr, config)
+	if err != nil {
+		return errcat.ToResult(err)
+	}
+	diff, err := helm.PreviewUpgrade(cluster.WithK8sInterface(ctx), cluster.ConfigFlags, cluster.GetManagerNamespace(), req)
+	if err != nil {
+		return errcat.ToResult(err)
+	}
+	return &common.Result{Data: []byte(diff)}
+}
+
 func (s *service) RemoteMountAvailability(ctx context.Context, _ *empty.Empty) (*common.Result, error) {
 	if proc.RunningInContainer() {
 		// We mount using docker volumes and the telemount driver plugin.
 		return errcat.ToResult(nil), nil
 	}
-	if client.GetConfig(ctx).Intercept().UseFtp {
+	feature := platform.FeatureSSHFSMounts
+	useFtp := client.GetConfig(ctx).Intercept().UseFtp
+	if useFtp {
+		feature = platform.FeatureFTPMounts
+	}
+	if reason, ok := platform.UnavailableFeatures()[feature]; ok {
+		return errcat.ToResult(errors.New(reason)), nil
+	}
+	if useFtp {
 		return errcat.ToResult(s.FuseFTPError()), nil
 	}
 
@@ -580,6 +787,18 @@ func (s *service) GetNamespaces(ctx context.Context, req *rpc.GetNamespacesReque
 	return &resp, nil
 }
 
+func (s *service) MapNamespaces(ctx context.Context, req *rpc.MapNamespacesRequest) (*rpc.GetNamespacesResponse, error) {
+	var resp rpc.GetNamespacesResponse
+	err := s.WithSession(ctx, "MapNamespaces", func(ctx context.Context, session userd.Session) (err error) {
+		resp.Namespaces, err = session.MapNamespaces(ctx, req.Namespaces, req.Unmap)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
 func (s *service) GatherTraces(ctx context.Context, request *rpc.TracesRequest) (result *common.Result, err error) {
 	err = s.WithSession(ctx, "GatherTraces", func(ctx context.Context, session userd.Session) error {
 		result = session.GatherTraces(ctx, request)
@@ -647,19 +866,174 @@ func (s *service) GetIntercept(ctx context.Context, request *manager.GetIntercep
 	return ii, err
 }
 
-func (s *service) SetDNSExcludes(ctx context.Context, req *daemon.SetDNSExcludesRequest) (*emptypb.Empty, error) {
-	return s.session.RootDaemon().SetDNSExcludes(ctx, req)
+func (s *service) SetDNSExcludes(ctx context.Context, req *daemon.SetDNSExcludesRequest) (result *emptypb.Empty, err error) {
+	err = s.WithSession(ctx, "SetDNSExcludes", func(c context.Context, session userd.Session) error {
+		rd := session.RootDaemon()
+		if rd == nil {
+			return status.Error(codes.Unavailable, "root daemon is not running")
+		}
+		result, err = rd.SetDNSExcludes(c, req)
+		return err
+	})
+	return
+}
+
+func (s *service) SetDNSMappings(ctx context.Context, req *daemon.SetDNSMappingsRequest) (result *emptypb.Empty, err error) {
+	err = s.WithSession(ctx, "SetDNSMappings", func(c context.Context, session userd.Session) error {
+		rd := session.RootDaemon()
+		if rd == nil {
+			return status.Error(codes.Unavailable, "root daemon is not running")
+		}
+		result, err = rd.SetDNSMappings(c, req)
+		return err
+	})
+	return
+}
+
+func (s *service) GetRoutes(ctx context.Context, req *emptypb.Empty) (result *daemon.RouteList, err error) {
+	err = s.WithSession(ctx, "GetRoutes", func(c context.Context, session userd.Session) error {
+		rd := session.RootDaemon()
+		if rd == nil {
+			return status.Error(codes.Unavailable, "root daemon is not running")
+		}
+		result, err = rd.GetRoutes(c, req)
+		return err
+	})
+	return
+}
+
+func (s *service) CheckRoute(ctx context.Context, req *daemon.CheckRouteRequest) (result *daemon.CheckRouteResponse, err error) {
+	err = s.WithSession(ctx, "CheckRoute", func(c context.Context, session userd.Session) error {
+		rd := session.RootDaemon()
+		if rd == nil {
+			return status.Error(codes.Unavailable, "root daemon is not running")
+		}
+		result, err = rd.CheckRoute(c, req)
+		return err
+	})
+	return
+}
+
+func (s *service) GetFlowStats(ctx context.Context, _ *empty.Empty) (result *daemon.FlowStats, err error) {
+	err = s.WithSession(ctx, "GetFlowStats", func(c context.Context, session userd.Session) error {
+		rd := session.RootDaemon()
+		if rd == nil {
+			return status.Error(codes.Unavailable, "root daemon is not running")
+		}
+		result, err = rd.GetFlowStats(c, &empty.Empty{})
+		return err
+	})
+	return
+}
+
+func (s *service) Capture(req *daemon.CaptureRequest, stream rpc.Connector_CaptureServer) error {
+	var sessionCtx context.Context
+	var rd daemon.DaemonClient
+	err := s.WithSession(stream.Context(), "Capture", func(c context.Context, session userd.Session) error {
+		if rd = session.RootDaemon(); rd == nil {
+			return status.Error(codes.Unavailable, "root daemon is not running")
+		}
+		sessionCtx = c
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The capture ends when the caller cancels the stream or when the session ends.
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-sessionCtx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	cs, err := rd.Capture(ctx, req)
+	if err != nil {
+		return err
+	}
+	for {
+		cp, err := cs.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if err = stream.Send(cp); err != nil {
+			return err
+		}
+	}
 }
 
-func (s *service) SetDNSMappings(ctx context.Context, req *daemon.SetDNSMappingsRequest) (*emptypb.Empty, error) {
-	return s.session.RootDaemon().SetDNSMappings(ctx, req)
+// Exec runs a command in a workload's container through the traffic-manager. The session info is added to
+// the first request, and the requests and responses are then relayed until the command ends.
+func (s *service) Exec(stream rpc.Connector_ExecServer) error {
+	var sessionCtx context.Context
+	var mc manager.ManagerClient
+	var si *manager.SessionInfo
+	err := s.WithSession(stream.Context(), "Exec", func(c context.Context, session userd.Session) error {
+		mc = session.ManagerClient()
+		si = session.SessionInfo()
+		sessionCtx = c
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The exec ends when the caller cancels the stream or when the session ends.
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	go func() {
+		select {
+		case <-sessionCtx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	ms, err := mc.Exec(ctx)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for {
+			rq, err := stream.Recv()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					_ = ms.CloseSend()
+				} else {
+					cancel()
+				}
+				return
+			}
+			rq.Session = si
+			if err = ms.Send(rq); err != nil {
+				return
+			}
+		}
+	}()
+	for {
+		rs, err := ms.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err = stream.Send(rs); err != nil {
+			return err
+		}
+	}
 }
 
 func (s *service) withRootDaemon(ctx context.Context, f func(ctx context.Context, daemonClient daemon.DaemonClient) error) error {
 	if s.rootSessionInProc {
 		return status.Error(codes.Unavailable, "root daemon is embedded")
 	}
-	conn, err := socket.Dial(ctx, socket.RootDaemonPath(ctx))
+	conn, err := wsl.DialRootDaemon(ctx)
 	if err == nil {
 		defer conn.Close()
 		err = f(ctx, daemon.NewDaemonClient(conn))
@@ -0,0 +1,95 @@
+package daemon
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	empty "google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/userd"
+	"github.com/telepresenceio/telepresence/v2/pkg/proc"
+)
+
+// leases tracks the processes, such as shells and IDE plugins, that use the current connection, so
+// that a quit from one of them can warn about the others.
+type leases struct {
+	sync.Mutex
+	byPID map[int32]*rpc.Lease
+}
+
+func (l *leases) acquire(lease *rpc.Lease) {
+	l.Lock()
+	defer l.Unlock()
+	if l.byPID == nil {
+		l.byPID = make(map[int32]*rpc.Lease)
+	}
+	if _, ok := l.byPID[lease.Pid]; !ok {
+		l.byPID[lease.Pid] = &rpc.Lease{Pid: lease.Pid, Holder: lease.Holder, Acquired: timestamppb.Now()}
+	}
+}
+
+func (l *leases) release(pid int32) {
+	l.Lock()
+	delete(l.byPID, pid)
+	l.Unlock()
+}
+
+func (l *leases) clear() {
+	l.Lock()
+	l.byPID = nil
+	l.Unlock()
+}
+
+// list returns the leases sorted by the time they were acquired. Leases held by processes that have
+// terminated are removed first, unless this daemon runs in a container where the processes of the
+// host aren't visible.
+func (l *leases) list(ctx context.Context) []*rpc.Lease {
+	checkAlive := !proc.RunningInContainer()
+	l.Lock()
+	ls := make([]*rpc.Lease, 0, len(l.byPID))
+	for pid, lease := range l.byPID {
+		if checkAlive && !proc.IsAlive(int(pid)) {
+			dlog.Debugf(ctx, "releasing lease of terminated %s with pid %d", lease.Holder, pid)
+			delete(l.byPID, pid)
+			continue
+		}
+		ls = append(ls, lease)
+	}
+	l.Unlock()
+	sort.Slice(ls, func(i, j int) bool { return ls[i].Acquired.AsTime().Before(ls[j].Acquired.AsTime()) })
+	return ls
+}
+
+func (s *service) AcquireLease(ctx context.Context, lease *rpc.Lease) (*empty.Empty, error) {
+	if lease.Pid <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "a lease requires the pid of its holder")
+	}
+	err := s.WithSession(ctx, "AcquireLease", func(_ context.Context, _ userd.Session) error {
+		s.leases.acquire(lease)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &empty.Empty{}, nil
+}
+
+func (s *service) ReleaseLease(ctx context.Context, lease *rpc.Lease) (*empty.Empty, error) {
+	s.logCall(ctx, "ReleaseLease", func(_ context.Context) {
+		s.leases.release(lease.Pid)
+	})
+	return &empty.Empty{}, nil
+}
+
+func (s *service) ListLeases(ctx context.Context, _ *empty.Empty) (result *rpc.LeaseList, err error) {
+	s.logCall(ctx, "ListLeases", func(c context.Context) {
+		result = &rpc.LeaseList{Leases: s.leases.list(c)}
+	})
+	return
+}
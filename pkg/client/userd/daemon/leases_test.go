@@ -0,0 +1,40 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/proc"
+)
+
+func TestLeases(t *testing.T) {
+	if proc.RunningInContainer() {
+		t.Skip("terminated processes are not pruned in a container")
+	}
+	ctx := context.Background()
+	self := int32(os.Getpid())
+	const gone = int32(1 << 30) // above any pid_max
+
+	var l leases
+	l.acquire(&rpc.Lease{Pid: self, Holder: "shell"})
+	l.acquire(&rpc.Lease{Pid: gone, Holder: "ide"})
+	l.acquire(&rpc.Lease{Pid: self, Holder: "other"})
+
+	ls := l.list(ctx)
+	require.Len(t, ls, 1)
+	assert.Equal(t, self, ls[0].Pid)
+	assert.Equal(t, "shell", ls[0].Holder, "the first lease of a pid is retained")
+	assert.NotNil(t, ls[0].Acquired)
+
+	l.release(self)
+	assert.Empty(t, l.list(ctx))
+
+	l.acquire(&rpc.Lease{Pid: self, Holder: "shell"})
+	l.clear()
+	assert.Empty(t, l.list(ctx))
+}
@@ -0,0 +1,142 @@
+package daemon
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/dlib/dtime"
+	"github.com/telepresenceio/telepresence/rpc/v2/common"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+// Exit codes used by a pod daemon, so that a CI pipeline can tell why it terminated.
+const (
+	// ExitCodeConnectFailed means that the pod daemon was unable to connect to the traffic-manager
+	// within the bounds of its retryPolicy.
+	ExitCodeConnectFailed = errcat.ExitCode(3)
+
+	// ExitCodeInterceptFailed means that the pod daemon was unable to create, or re-create, an
+	// intercept within the bounds of its retryPolicy.
+	ExitCodeInterceptFailed = errcat.ExitCode(4)
+)
+
+// retryPolicy bounds the attempts that a pod daemon makes to reconnect to the traffic-manager and
+// to re-create its intercepts, so that a CI pipeline fails fast instead of hanging.
+type retryPolicy struct {
+	// attempts is the maximum number of attempts. Zero means one attempt.
+	attempts int
+
+	// backoff is the time to wait after the first failed attempt. It's doubled for each
+	// consecutive failure, up to maxBackoff.
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// defaultRetryPolicy is the retryPolicy used by a pod daemon.
+var defaultRetryPolicy = retryPolicy{ //nolint:gochecknoglobals // constant
+	attempts:   6,
+	backoff:    time.Second,
+	maxBackoff: 10 * time.Second,
+}
+
+// do calls the given function until it succeeds or until the attempts of the policy are exhausted. The
+// error from the last attempt is returned.
+func (rp retryPolicy) do(ctx context.Context, what string, f func(context.Context) error) (err error) {
+	backoff := rp.backoff
+	for attempt := 1; ; attempt++ {
+		if err = f(ctx); err == nil || attempt >= rp.attempts || ctx.Err() != nil {
+			return err
+		}
+		dlog.Warnf(ctx, "%s failed (attempt %d of %d), retrying in %s: %v", what, attempt, rp.attempts, backoff, err)
+		dtime.SleepWithContext(ctx, backoff)
+		if backoff *= 2; backoff > rp.maxBackoff {
+			backoff = rp.maxBackoff
+		}
+	}
+}
+
+// createPodIntercept creates an intercept for a pod daemon, retrying according to the retry policy. An
+// ExitCodeInterceptFailed error is returned when all attempts fail.
+func (s *service) createPodIntercept(ctx context.Context, ir *rpc.CreateInterceptRequest) error {
+	name := ir.Spec.Name
+	err := s.podRetryPolicy.do(ctx, "intercept "+name, func(ctx context.Context) error {
+		r, err := s.CreateIntercept(ctx, ir)
+		if err != nil {
+			return err
+		}
+		if r.Error != common.InterceptError_UNSPECIFIED {
+			return errcat.Category(r.ErrorCategory).Newf("%s: %s", r.Error, r.ErrorText)
+		}
+		return nil
+	})
+	if err != nil {
+		dlog.Errorf(ctx, "unable to create intercept %s: %v", name, err)
+		return ExitCodeInterceptFailed
+	}
+	return nil
+}
+
+// retainPodIntercept remembers a successfully created pod daemon intercept, so that it can be re-created
+// when the session is replaced.
+func (s *service) retainPodIntercept(ir *rpc.CreateInterceptRequest) {
+	s.podInterceptsLock.Lock()
+	s.podIntercepts[ir.Spec.Name] = ir
+	s.podInterceptsLock.Unlock()
+}
+
+// forgetPodIntercept is the reverse of retainPodIntercept.
+func (s *service) forgetPodIntercept(name string) {
+	s.podInterceptsLock.Lock()
+	delete(s.podIntercepts, name)
+	s.podInterceptsLock.Unlock()
+}
+
+func (s *service) retainedPodIntercepts() []*rpc.CreateInterceptRequest {
+	s.podInterceptsLock.Lock()
+	irs := make([]*rpc.CreateInterceptRequest, 0, len(s.podIntercepts))
+	for _, ir := range s.podIntercepts {
+		irs = append(irs, ir)
+	}
+	s.podInterceptsLock.Unlock()
+	return irs
+}
+
+// refreshSession replaces a session that has expired with a new one. A pod daemon retries according to
+// its retry policy, and then re-creates its intercepts. The returned error is non-nil only when a pod
+// daemon fails to do so.
+func (s *service) refreshSession(ctx context.Context, cr *rpc.ConnectRequest, wg *sync.WaitGroup) error {
+	if !cr.IsPodDaemon {
+		if err := connectInfoError(s.startSession(ctx, cr, wg)); err != nil {
+			dlog.Errorf(ctx, "unable to refresh session: %v", err)
+		}
+		return nil
+	}
+	err := s.podRetryPolicy.do(ctx, "refresh session", func(ctx context.Context) error {
+		return connectInfoError(s.startSession(ctx, cr, wg))
+	})
+	if err != nil {
+		dlog.Errorf(ctx, "unable to reconnect to the traffic-manager: %v", err)
+		return ExitCodeConnectFailed
+	}
+	for _, ir := range s.retainedPodIntercepts() {
+		dlog.Infof(ctx, "re-creating intercept %s", ir.Spec.Name)
+		if err = s.createPodIntercept(ctx, ir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// connectInfoError returns an error that describes the given ConnectInfo, or nil if it represents a
+// successful connect.
+func connectInfoError(ci *rpc.ConnectInfo) error {
+	switch ci.Error {
+	case rpc.ConnectInfo_UNSPECIFIED, rpc.ConnectInfo_ALREADY_CONNECTED:
+		return nil
+	default:
+		return errcat.Category(ci.ErrorCategory).Newf("%s: %s", ci.Error, ci.ErrorText)
+	}
+}
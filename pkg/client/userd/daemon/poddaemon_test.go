@@ -0,0 +1,71 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	rp := retryPolicy{attempts: 3, backoff: time.Millisecond, maxBackoff: 2 * time.Millisecond}
+
+	calls := 0
+	err := rp.do(ctx, "test", func(context.Context) error {
+		if calls++; calls < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = rp.do(ctx, "test", func(context.Context) error {
+		calls++
+		return errors.New("never")
+	})
+	assert.EqualError(t, err, "never")
+	assert.Equal(t, 3, calls, "attempts are bounded")
+
+	cctx, cancel := context.WithCancel(ctx)
+	calls = 0
+	err = rp.do(cctx, "test", func(context.Context) error {
+		calls++
+		cancel()
+		return errors.New("cancelled")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls, "no retries after the context is cancelled")
+}
+
+func TestRetainedPodIntercepts(t *testing.T) {
+	s := &service{podIntercepts: make(map[string]*rpc.CreateInterceptRequest)}
+	ir := func(name string) *rpc.CreateInterceptRequest {
+		return &rpc.CreateInterceptRequest{Spec: &manager.InterceptSpec{Name: name}, IsPodDaemon: true}
+	}
+	s.retainPodIntercept(ir("a"))
+	s.retainPodIntercept(ir("b"))
+	s.retainPodIntercept(ir("a"))
+	require.Len(t, s.retainedPodIntercepts(), 2)
+	s.forgetPodIntercept("a")
+	irs := s.retainedPodIntercepts()
+	require.Len(t, irs, 1)
+	assert.Equal(t, "b", irs[0].Spec.Name)
+}
+
+func TestConnectInfoError(t *testing.T) {
+	assert.NoError(t, connectInfoError(&rpc.ConnectInfo{}))
+	assert.NoError(t, connectInfoError(&rpc.ConnectInfo{Error: rpc.ConnectInfo_ALREADY_CONNECTED}))
+	err := connectInfoError(&rpc.ConnectInfo{Error: rpc.ConnectInfo_TRAFFIC_MANAGER_FAILED, ErrorText: "no manager"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no manager")
+}
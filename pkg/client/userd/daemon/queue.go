@@ -0,0 +1,71 @@
+package daemon
+
+import (
+	"context"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/connector"
+)
+
+// queuedMethods change the state of the session, and are therefore served one at a time. Disconnect and Quit are
+// not queued, so that a client can always end a session that is stuck.
+var queuedMethods = map[string]struct{}{ //nolint:gochecknoglobals // constant
+	rpc.Connector_Connect_FullMethodName:         {},
+	rpc.Connector_CreateIntercept_FullMethodName: {},
+	rpc.Connector_RemoveIntercept_FullMethodName: {},
+	rpc.Connector_UpdateIntercept_FullMethodName: {},
+}
+
+// requestQueue serializes the requests that change the state of the session, so that the requests of concurrent
+// clients, e.g. scripts that run several telepresence commands in parallel, don't interleave. The requests are
+// served in the order that they arrive.
+type requestQueue struct {
+	// turn is a semaphore. Goroutines that block on a send to a channel are woken in FIFO order.
+	turn chan struct{}
+
+	// pending is the number of requests that are served or waiting to be served.
+	pending int32
+}
+
+func newRequestQueue() *requestQueue {
+	return &requestQueue{turn: make(chan struct{}, 1)}
+}
+
+// serverOptions returns the option that installs the queue's interceptor.
+func (q *requestQueue) serverOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{grpc.ChainUnaryInterceptor(q.unaryInterceptor)}
+}
+
+// enter waits until it's the turn of the request of the given context, or until the context is cancelled.
+func (q *requestQueue) enter(ctx context.Context, method string) error {
+	if n := atomic.AddInt32(&q.pending, 1); n > 1 {
+		dlog.Debugf(ctx, "%s waits for %d queued requests", method, n-1)
+	}
+	select {
+	case q.turn <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		atomic.AddInt32(&q.pending, -1)
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (q *requestQueue) leave() {
+	<-q.turn
+	atomic.AddInt32(&q.pending, -1)
+}
+
+func (q *requestQueue) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if _, ok := queuedMethods[info.FullMethod]; !ok {
+		return handler(ctx, req)
+	}
+	if err := q.enter(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	defer q.leave()
+	return handler(ctx, req)
+}
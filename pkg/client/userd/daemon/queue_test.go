@@ -0,0 +1,83 @@
+package daemon
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/connector"
+)
+
+func callQueued(ctx context.Context, q *requestQueue, method string, handler grpc.UnaryHandler) error {
+	_, err := q.unaryInterceptor(ctx, &emptypb.Empty{}, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+	return err
+}
+
+func TestRequestQueue_serializes(t *testing.T) {
+	q := newRequestQueue()
+	ctx := context.Background()
+
+	var lock sync.Mutex
+	var order []string
+	record := func(name string, d time.Duration) grpc.UnaryHandler {
+		return func(context.Context, any) (any, error) {
+			lock.Lock()
+			order = append(order, name+"-start")
+			lock.Unlock()
+			time.Sleep(d)
+			lock.Lock()
+			order = append(order, name+"-end")
+			lock.Unlock()
+			return &rpc.InterceptResult{}, nil
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, callQueued(ctx, q, rpc.Connector_CreateIntercept_FullMethodName, record("intercept", 100*time.Millisecond)))
+	}()
+	time.Sleep(20 * time.Millisecond)
+	go func() {
+		defer wg.Done()
+		assert.NoError(t, callQueued(ctx, q, rpc.Connector_RemoveIntercept_FullMethodName, record("leave", 0)))
+	}()
+
+	// Methods that aren't queued are served immediately.
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, callQueued(ctx, q, rpc.Connector_Quit_FullMethodName, record("quit", 0)))
+	wg.Wait()
+	assert.Equal(t, []string{"intercept-start", "quit-start", "quit-end", "intercept-end", "leave-start", "leave-end"}, order)
+}
+
+func TestRequestQueue_cancel(t *testing.T) {
+	q := newRequestQueue()
+	release := make(chan struct{})
+	go func() {
+		_ = callQueued(context.Background(), q, rpc.Connector_Connect_FullMethodName, func(context.Context, any) (any, error) {
+			<-release
+			return &rpc.ConnectInfo{}, nil
+		})
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := callQueued(ctx, q, rpc.Connector_CreateIntercept_FullMethodName, noopHandler)
+	require.Error(t, err)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+
+	close(release)
+	assert.Eventually(t, func() bool {
+		return callQueued(context.Background(), q, rpc.Connector_CreateIntercept_FullMethodName, noopHandler) == nil
+	}, time.Second, 10*time.Millisecond)
+}
@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"runtime/debug"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -22,9 +23,11 @@ import (
 	"github.com/telepresenceio/telepresence/rpc/v2/common"
 	rpc "github.com/telepresenceio/telepresence/rpc/v2/connector"
 	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/atrest"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/cli/daemon"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/logging"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/paths"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/remotefs"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/scout"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/socket"
@@ -75,9 +78,21 @@ type service struct {
 	sessionQuitting int32 // atomic boolean. True if non-zero.
 	sessionLock     sync.RWMutex
 
+	// leases are held by the processes that use the current session.
+	leases leases
+
 	// These are used to communicate between the various goroutines.
 	connectRequest  chan *rpc.ConnectRequest // server-grpc.connect() -> connectWorker
 	connectResponse chan *rpc.ConnectInfo    // connectWorker -> server-grpc.connect()
+	refreshRequest  chan *rpc.ConnectRequest // expired session -> connectWorker
+
+	// podIntercepts are the intercepts created by a pod daemon, keyed by name. They are re-created
+	// when an expired session is replaced, e.g. after a restart of the traffic-manager.
+	podIntercepts     map[string]*rpc.CreateInterceptRequest
+	podInterceptsLock sync.Mutex
+
+	// podRetryPolicy bounds the attempts that a pod daemon makes to reconnect and to re-create intercepts.
+	podRetryPolicy retryPolicy
 
 	fuseFtpMgr remotefs.FuseFTPManager
 
@@ -96,6 +111,9 @@ func NewService(ctx context.Context, _ *dgroup.Group, cfg client.Config, srv *gr
 		srv:             srv,
 		connectRequest:  make(chan *rpc.ConnectRequest),
 		connectResponse: make(chan *rpc.ConnectInfo),
+		refreshRequest:  make(chan *rpc.ConnectRequest),
+		podIntercepts:   make(map[string]*rpc.CreateInterceptRequest),
+		podRetryPolicy:  defaultRetryPolicy,
 		managerProxy:    &mgrProxy{},
 		timedLogLevel:   log.NewTimedLevel(cfg.LogLevels().UserDaemon.String(), log.SetLevel),
 		fuseFtpMgr:      remotefs.NewFuseFTPManager(),
@@ -150,6 +168,7 @@ const (
 	addressFlag      = "address"
 	embedNetworkFlag = "embed-network"
 	pprofFlag        = "pprof"
+	cacheKeyFlag     = "cache-key-stdin"
 )
 
 // Command returns the CLI sub-command for "connector-foreground".
@@ -167,6 +186,7 @@ func Command() *cobra.Command {
 	flags.String(addressFlag, "", "Address to listen to. Defaults to "+socket.UserDaemonPath(context.Background()))
 	flags.Bool(embedNetworkFlag, false, "Embed network functionality in the user daemon. Requires capability NET_ADMIN")
 	flags.Uint16(pprofFlag, 0, "start pprof server on the given port")
+	flags.Bool(cacheKeyFlag, false, "Read the key used to encrypt secret cache files from stdin")
 	return c
 }
 
@@ -206,6 +226,10 @@ func (s *service) ManageSessions(c context.Context) error {
 				// whoever wanted to start the session terminated early.
 				s.cancelSession()
 			}
+		case cr := <-s.refreshRequest:
+			if err := s.refreshSession(c, cr, &wg); err != nil {
+				return err
+			}
 		}
 	}
 }
@@ -234,6 +258,7 @@ func (s *service) startSession(ctx context.Context, cr *rpc.ConnectRequest, wg *
 		}
 	}
 
+	parentCtx := ctx
 	ctx, cancel := context.WithCancel(ctx)
 	ctx = userd.WithService(ctx, s.self)
 
@@ -270,6 +295,7 @@ func (s *service) startSession(ctx context.Context, cr *rpc.ConnectRequest, wg *
 	// the session is running. The s.sessionCancel is called from Disconnect
 	wg.Add(1)
 	go func(cr *rpc.ConnectRequest) {
+		expired := false
 		defer func() {
 			s.sessionLock.Lock()
 			s.self.SetManagerClient(nil)
@@ -280,16 +306,21 @@ func (s *service) startSession(ctx context.Context, cr *rpc.ConnectRequest, wg *
 			}
 			s.sessionLock.Unlock()
 			wg.Done()
+			if expired {
+				// Request a new session. This must be done after the state of this session has been
+				// cleared, or the new session will be cleared too.
+				select {
+				case <-parentCtx.Done():
+				case s.refreshRequest <- cr:
+				}
+			}
 		}()
 		if err := session.RunSession(s.sessionContext); err != nil {
 			if errors.Is(err, trafficmgr.ErrSessionExpired) {
 				// Session has expired. We need to cancel the owner session and reconnect
 				dlog.Info(ctx, "refreshing session")
 				s.cancelSession()
-				select {
-				case <-ctx.Done():
-				case s.connectRequest <- cr:
-				}
+				expired = true
 				return
 			}
 
@@ -352,6 +383,16 @@ func (s *service) cancelSession() {
 	s.sessionLock.Unlock()
 }
 
+// applyGCSettings applies the garbage collection settings of the given configuration to the current process.
+func applyGCSettings(cfg *client.UserDaemon) {
+	if cfg.GCPercent != 0 {
+		debug.SetGCPercent(cfg.GCPercent)
+	}
+	if ml := cfg.MemoryLimit(); ml > 0 {
+		debug.SetMemoryLimit(ml)
+	}
+}
+
 // run is the main function when executing as the connector.
 func run(cmd *cobra.Command, _ []string) error {
 	c := cmd.Context()
@@ -360,6 +401,8 @@ func run(cmd *cobra.Command, _ []string) error {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 	c = client.WithConfig(c, cfg)
+	c = paths.WithOverrides(c)
+	applyGCSettings(cfg.UserDaemon())
 
 	// Listen on domain unix domain socket or windows named pipe. The listener must be opened
 	// before other tasks because the CLI client will only wait for a short period of time for
@@ -385,6 +428,11 @@ func run(cmd *cobra.Command, _ []string) error {
 	if err != nil {
 		return err
 	}
+	if readKey, _ := flags.GetBool(cacheKeyFlag); readKey {
+		if err = atrest.ReadDataKey(os.Stdin); err != nil {
+			return err
+		}
+	}
 	rootSessionInProc, _ := flags.GetBool(embedNetworkFlag)
 	var daemonAddress *net.TCPAddr
 	if addr, _ := flags.GetString(addressFlag); addr != "" {
@@ -432,9 +480,9 @@ func run(cmd *cobra.Command, _ []string) error {
 			grpc.UnaryInterceptor(otelgrpc.UnaryServerInterceptor()),
 			grpc.StreamInterceptor(otelgrpc.StreamServerInterceptor()),
 		}
-		if mz := cfg.Grpc().MaxReceiveSize(); mz > 0 {
-			opts = append(opts, grpc.MaxRecvMsgSize(int(mz)))
-		}
+		opts = append(opts, cfg.Grpc().ServerOptions()...)
+		opts = append(opts, newRequestGuard(cfg.Grpc().MaxRequestRate).serverOptions()...)
+		opts = append(opts, newRequestQueue().serverOptions()...)
 		si, err := userd.GetNewServiceFunc(c)(c, g, cfg, grpc.NewServer(opts...))
 		if err != nil {
 			close(siCh)
@@ -0,0 +1,184 @@
+package daemon
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/connector"
+)
+
+const (
+	// defaultMaxRequestRate is the number of requests per second that a client can make when no rate is configured.
+	defaultMaxRequestRate = 20
+
+	// clientIdleTimeout is the time after which the limiter of a client that makes no requests is discarded.
+	clientIdleTimeout = time.Minute
+)
+
+// unthrottledMethods are never throttled, so that a client can always end its session.
+var unthrottledMethods = map[string]struct{}{ //nolint:gochecknoglobals // constant
+	rpc.Connector_Disconnect_FullMethodName: {},
+	rpc.Connector_Quit_FullMethodName:       {},
+}
+
+// requestGuard protects the gRPC server from clients that make requests at a high frequency, such as IDE plugins
+// that poll the status. Such clients would otherwise starve the goroutines that manage the session.
+//
+// Each client gets its own rate limit, so a misbehaving client can't consume the quota of another. Concurrent
+// unary requests that are identical and come from the same client are coalesced into one call, so that e.g. a
+// connect or an intercept request that is retried while the first attempt is still in progress is executed once.
+type requestGuard struct {
+	limit     rate.Limit
+	burst     int
+	lock      sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+	calls     singleflight.Group
+	lastConn  uint64
+}
+
+type clientLimiter struct {
+	*rate.Limiter
+	lastSeen time.Time
+}
+
+// newRequestGuard returns a guard that allows each client the given number of requests per second. Zero means
+// defaultMaxRequestRate, and a negative number means that the number of requests isn't limited.
+func newRequestGuard(maxRate int) *requestGuard {
+	var limit rate.Limit
+	switch {
+	case maxRate < 0:
+		limit = rate.Inf
+	case maxRate == 0:
+		maxRate = defaultMaxRequestRate
+		fallthrough
+	default:
+		limit = rate.Limit(maxRate)
+	}
+	return &requestGuard{
+		limit:     limit,
+		burst:     2 * maxRate,
+		clients:   make(map[string]*clientLimiter),
+		lastSweep: time.Now(),
+	}
+}
+
+// serverOptions returns the options that install the guard's interceptors and its stats handler. The
+// interceptors are chained after any interceptors given in the server options that precede them.
+func (g *requestGuard) serverOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.StatsHandler(g),
+		grpc.ChainUnaryInterceptor(g.unaryInterceptor),
+		grpc.ChainStreamInterceptor(g.streamInterceptor),
+	}
+}
+
+type connIDKey struct{}
+
+// TagConn implements stats.Handler. It gives each connection a unique ID, because the peer address of
+// clients that connect using a unix socket is the same for all of them.
+func (g *requestGuard) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return context.WithValue(ctx, connIDKey{}, atomic.AddUint64(&g.lastConn, 1))
+}
+
+// HandleConn implements stats.Handler.
+func (g *requestGuard) HandleConn(context.Context, stats.ConnStats) {}
+
+// TagRPC implements stats.Handler.
+func (g *requestGuard) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+// HandleRPC implements stats.Handler.
+func (g *requestGuard) HandleRPC(context.Context, stats.RPCStats) {}
+
+// clientID identifies the client of a request using the user-agent that it declares and the ID of the
+// connection that the request arrived on, so that each client process gets its own limit.
+func clientID(ctx context.Context) string {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			id = ua[0]
+		}
+	}
+	if connID, ok := ctx.Value(connIDKey{}).(uint64); ok {
+		id += "#" + strconv.FormatUint(connID, 10)
+	}
+	return id
+}
+
+// allow returns a ResourceExhausted error if the client has exceeded its rate limit.
+func (g *requestGuard) allow(ctx context.Context, method string) error {
+	if g.limit == rate.Inf {
+		return nil
+	}
+	if _, ok := unthrottledMethods[method]; ok {
+		return nil
+	}
+	id := clientID(ctx)
+	now := time.Now()
+	g.lock.Lock()
+	if now.Sub(g.lastSweep) > clientIdleTimeout {
+		for cid, cl := range g.clients {
+			if now.Sub(cl.lastSeen) > clientIdleTimeout {
+				delete(g.clients, cid)
+			}
+		}
+		g.lastSweep = now
+	}
+	cl, ok := g.clients[id]
+	if !ok {
+		cl = &clientLimiter{Limiter: rate.NewLimiter(g.limit, g.burst)}
+		g.clients[id] = cl
+	}
+	cl.lastSeen = now
+	g.lock.Unlock()
+
+	if !cl.AllowN(now, 1) {
+		return status.Errorf(codes.ResourceExhausted, "too many requests, the limit is %d requests per second", int(g.limit))
+	}
+	return nil
+}
+
+func (g *requestGuard) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if err := g.allow(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return handler(ctx, req)
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+	if err != nil {
+		return handler(ctx, req)
+	}
+
+	// The call is made using the context of the request that initiated it. Requests that join the call
+	// will therefore get the cancellation error of that request, should it be cancelled.
+	key := clientID(ctx) + "\x00" + info.FullMethod + "\x00" + string(data)
+	select {
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	case r := <-g.calls.DoChan(key, func() (any, error) { return handler(ctx, req) }):
+		return r.Val, r.Err
+	}
+}
+
+func (g *requestGuard) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := g.allow(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
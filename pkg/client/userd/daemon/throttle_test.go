@@ -0,0 +1,191 @@
+package daemon
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/connector"
+)
+
+func clientContext(ctx context.Context, userAgent string) context.Context {
+	return metadata.NewIncomingContext(ctx, metadata.Pairs("user-agent", userAgent))
+}
+
+func callStatus(ctx context.Context, g *requestGuard, handler grpc.UnaryHandler) error {
+	_, err := g.unaryInterceptor(ctx, &emptypb.Empty{}, &grpc.UnaryServerInfo{FullMethod: rpc.Connector_Status_FullMethodName}, handler)
+	return err
+}
+
+func noopHandler(context.Context, any) (any, error) {
+	return &rpc.ConnectInfo{}, nil
+}
+
+func TestRequestGuard_throttle(t *testing.T) {
+	g := newRequestGuard(5)
+	ide := clientContext(context.Background(), "ide-plugin")
+	for i := 0; i < 10; i++ {
+		require.NoError(t, callStatus(ide, g, noopHandler))
+	}
+	err := callStatus(ide, g, noopHandler)
+	require.Error(t, err)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// Other clients are not affected
+	assert.NoError(t, callStatus(clientContext(context.Background(), "cli"), g, noopHandler))
+
+	// Quit is never throttled
+	_, err = g.unaryInterceptor(ide, &emptypb.Empty{}, &grpc.UnaryServerInfo{FullMethod: rpc.Connector_Quit_FullMethodName}, noopHandler)
+	assert.NoError(t, err)
+
+	// No limit
+	g = newRequestGuard(-1)
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, callStatus(ide, g, noopHandler))
+	}
+}
+
+func TestRequestGuard_connections(t *testing.T) {
+	g := newRequestGuard(5)
+	// Two CLI processes connected using the unix socket declare the same user-agent and have no peer address.
+	conn1 := clientContext(g.TagConn(context.Background(), &stats.ConnTagInfo{}), "cli")
+	conn2 := clientContext(g.TagConn(context.Background(), &stats.ConnTagInfo{}), "cli")
+	for i := 0; i < 10; i++ {
+		require.NoError(t, callStatus(conn1, g, noopHandler))
+	}
+	assert.Equal(t, codes.ResourceExhausted, status.Code(callStatus(conn1, g, noopHandler)))
+	assert.NoError(t, callStatus(conn2, g, noopHandler))
+}
+
+func TestRequestGuard_coalesce(t *testing.T) {
+	g := newRequestGuard(-1)
+	ctx := clientContext(context.Background(), "ide-plugin")
+	var calls int32
+	release := make(chan struct{})
+	handler := func(context.Context, any) (any, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return &rpc.ConnectInfo{}, nil
+	}
+	call := func(ns string) (any, error) {
+		return g.unaryInterceptor(ctx, &rpc.ListRequest{Namespace: ns}, &grpc.UnaryServerInfo{FullMethod: rpc.Connector_List_FullMethodName}, handler)
+	}
+
+	const n = 10
+	results := make([]any, n+1)
+	wg := sync.WaitGroup{}
+	wg.Add(n + 1)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = call("default")
+		}(i)
+	}
+	go func() {
+		defer wg.Done()
+		results[n], _ = call("other")
+	}()
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 2 }, 5*time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// All identical requests got the result of one call.
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	for i := 1; i < n; i++ {
+		assert.Same(t, results[0], results[i])
+	}
+	assert.NotSame(t, results[0], results[n])
+}
+
+// BenchmarkRequestGuard measures the overhead that the guard adds to each request.
+func BenchmarkRequestGuard(b *testing.B) {
+	g := newRequestGuard(-1)
+	ctx := clientContext(context.Background(), "cli")
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := callStatus(ctx, g, noopHandler); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkPolling100RPS measures the time that a session management loop must wait for the session lock
+// while a client polls the status at 100 requests per second. Each status request holds the read lock for
+// a short while, just like the real Status call does.
+func BenchmarkPolling100RPS(b *testing.B) {
+	for _, bc := range []struct {
+		name    string
+		maxRate int
+	}{
+		{"unlimited", -1},
+		{"default", 0},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var sessionLock sync.RWMutex
+			handler := func(context.Context, any) (any, error) {
+				sessionLock.RLock()
+				time.Sleep(5 * time.Millisecond)
+				sessionLock.RUnlock()
+				return &rpc.ConnectInfo{}, nil
+			}
+
+			g := newRequestGuard(bc.maxRate)
+			ctx, cancel := context.WithCancel(context.Background())
+			var polls, rejected int64
+			pollers := sync.WaitGroup{}
+			pollers.Add(1)
+			go func() {
+				defer pollers.Done()
+				ticker := time.NewTicker(10 * time.Millisecond)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+					}
+					atomic.AddInt64(&polls, 1)
+					pollers.Add(1)
+					go func() {
+						defer pollers.Done()
+						// A distinct request for each poll prevents coalescing, so the limit is what's measured.
+						_, err := g.unaryInterceptor(clientContext(ctx, "ide-plugin"), &rpc.ListRequest{Namespace: time.Now().String()},
+							&grpc.UnaryServerInfo{FullMethod: rpc.Connector_List_FullMethodName}, handler)
+						if status.Code(err) == codes.ResourceExhausted {
+							atomic.AddInt64(&rejected, 1)
+						}
+					}()
+				}
+			}()
+
+			// Let the polling reach a steady state
+			time.Sleep(200 * time.Millisecond)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				sessionLock.Lock()
+				sessionLock.Unlock() //nolint:staticcheck // measuring the time it takes to acquire the lock
+				time.Sleep(time.Millisecond)
+			}
+			b.StopTimer()
+			cancel()
+			pollers.Wait()
+			if p := atomic.LoadInt64(&polls); p > 0 {
+				b.ReportMetric(float64(atomic.LoadInt64(&rejected))/float64(p), "rejected/poll")
+			}
+		})
+	}
+}
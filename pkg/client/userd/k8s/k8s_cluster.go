@@ -9,11 +9,11 @@ import (
 	"time"
 
 	"github.com/blang/semver"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/datawire/dlib/dlog"
-	"github.com/datawire/dlib/dtime"
 	"github.com/datawire/k8sapi/pkg/k8sapi"
 	rpc "github.com/telepresenceio/telepresence/rpc/v2/connector"
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
@@ -31,6 +31,10 @@ type Cluster struct {
 	*client.Kubeconfig
 	MappedNamespaces []string
 
+	// NamespaceSelector, when set, maps the namespaces that match it in addition to the
+	// MappedNamespaces. The namespaces watcher only watches matching namespaces.
+	NamespaceSelector labels.Selector
+
 	// Main
 	ki kubernetes.Interface
 
@@ -65,17 +69,18 @@ func (kc *Cluster) check(c context.Context) error {
 	go func() {
 		defer close(errCh)
 		var info *version.Info
-		var err error
-		for attempts := 0; attempts < 4; attempts++ {
-			if info, err = k8sapi.GetK8sInterface(c).Discovery().ServerVersion(); err != nil {
-				if strings.Contains(err.Error(), "connection refused") {
-					dlog.Warnf(c, "Connection to connect failed, retry %d", attempts+1)
-					dtime.SleepWithContext(c, 400*time.Millisecond)
-					continue
-				}
-			}
-			break
+		rp := client.RetryPolicy{
+			Attempts: 4,
+			Delay:    400 * time.Millisecond,
+			MaxDelay: 400 * time.Millisecond,
+			Retryable: func(err error) bool {
+				return strings.Contains(err.Error(), "connection refused")
+			},
 		}
+		err := rp.Do(c, "cluster check", func(c context.Context) (err error) {
+			info, err = k8sapi.GetK8sInterface(c).Discovery().ServerVersion()
+			return err
+		})
 		if err != nil {
 			errCh <- err
 			return
@@ -120,6 +125,10 @@ func (kc *Cluster) namespaceAccessible(namespace string) (exists bool) {
 }
 
 func NewCluster(c context.Context, kubeFlags *client.Kubeconfig, namespaces []string) (*Cluster, error) {
+	return newCluster(c, kubeFlags, namespaces, nil)
+}
+
+func newCluster(c context.Context, kubeFlags *client.Kubeconfig, namespaces []string, selector labels.Selector) (*Cluster, error) {
 	rs, err := kubeFlags.ConfigFlags.ToRESTConfig()
 	if err != nil {
 		return nil, err
@@ -131,8 +140,9 @@ func NewCluster(c context.Context, kubeFlags *client.Kubeconfig, namespaces []st
 	c = k8sapi.WithK8sInterface(c, cs)
 
 	ret := &Cluster{
-		Kubeconfig: kubeFlags,
-		ki:         cs,
+		Kubeconfig:        kubeFlags,
+		NamespaceSelector: selector,
+		ki:                cs,
 	}
 
 	cfg := client.GetConfig(c)
@@ -151,11 +161,19 @@ func NewCluster(c context.Context, kubeFlags *client.Kubeconfig, namespaces []st
 	if len(namespaces) == 0 {
 		namespaces = cfg.Cluster().MappedNamespaces
 	}
-	if len(namespaces) == 0 {
+	switch {
+	case selector != nil:
+		if !ret.CanWatchNamespaces(c) {
+			return nil, errcat.User.New("--mapped-namespace-selector requires permission to watch namespaces")
+		}
+		sort.Strings(namespaces)
+		ret.MappedNamespaces = namespaces
+		ret.StartNamespaceWatcher(c)
+	case len(namespaces) == 0:
 		if ret.CanWatchNamespaces(c) {
 			ret.StartNamespaceWatcher(c)
 		}
-	} else {
+	default:
 		ret.SetMappedNamespaces(c, namespaces)
 	}
 	if ret.GetManagerNamespace() == "" {
@@ -176,7 +194,15 @@ func ConnectCluster(c context.Context, cr *rpc.ConnectRequest, config *client.Ku
 		sort.Strings(mappedNamespaces)
 	}
 
-	cluster, err := NewCluster(c, config, mappedNamespaces)
+	var selector labels.Selector
+	if s := cr.MappedNamespaceSelector; s != "" {
+		var err error
+		if selector, err = labels.Parse(s); err != nil {
+			return nil, errcat.User.Newf("invalid --mapped-namespace-selector %q: %w", s, err)
+		}
+	}
+
+	cluster, err := newCluster(c, config, mappedNamespaces, selector)
 	if err != nil {
 		return nil, err
 	}
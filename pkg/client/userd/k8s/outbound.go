@@ -13,6 +13,7 @@ import (
 	"k8s.io/apimachinery/pkg/watch"
 
 	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/userd"
 	"github.com/telepresenceio/telepresence/v2/pkg/slice"
 )
@@ -25,7 +26,15 @@ func (kc *Cluster) StartNamespaceWatcher(ctx context.Context) {
 	go func() {
 		api := kc.ki.CoreV1()
 		for ctx.Err() == nil {
-			w, err := api.Namespaces().Watch(ctx, meta.ListOptions{})
+			opts := meta.ListOptions{}
+			if kc.NamespaceSelector != nil {
+				opts.LabelSelector = kc.NamespaceSelector.String()
+			}
+			var w watch.Interface
+			err := client.GetConfig(ctx).Retries().Policy().Do(ctx, "namespace listing", func(ctx context.Context) (err error) {
+				w, err = api.Namespaces().Watch(ctx, opts)
+				return err
+			})
 			if err != nil {
 				dlog.Errorf(ctx, "unable to create service watcher: %v", err)
 				return
@@ -234,10 +243,14 @@ func (kc *Cluster) refreshNamespaces(c context.Context) {
 			nss[i] = ns
 			i++
 		}
+		if kc.NamespaceSelector != nil {
+			// The snapshot only contains namespaces that match the selector.
+			nss = slice.AppendUnique(nss, kc.MappedNamespaces...)
+		}
 	}
 	namespaces := make(map[string]bool, len(nss))
 	for _, ns := range nss {
-		if kc.shouldBeWatched(ns) {
+		if kc.NamespaceSelector != nil || kc.shouldBeWatched(ns) {
 			accessOk, ok := kc.currentMappedNamespaces[ns]
 			if !ok {
 				accessOk = kc.canAccessNS(c, ns)
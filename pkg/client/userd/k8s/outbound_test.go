@@ -0,0 +1,69 @@
+package k8s
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	auth "k8s.io/api/authorization/v1"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+)
+
+func TestNamespaceSelector(t *testing.T) {
+	ctx, cancel := context.WithCancel(client.WithConfig(dlog.NewTestContext(t, false), client.GetDefaultConfig()))
+	defer cancel()
+
+	cs := fake.NewSimpleClientset()
+	cs.PrependReactor("create", "selfsubjectrulesreviews", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, &auth.SelfSubjectRulesReview{Status: auth.SubjectRulesReviewStatus{
+			ResourceRules: []auth.ResourceRule{{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}}},
+		}}, nil
+	})
+	fw := watch.NewFakeWithChanSize(10, false)
+	var selectorLock sync.Mutex
+	var selector string
+	cs.PrependWatchReactor("namespaces", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		selectorLock.Lock()
+		selector = action.(k8stesting.WatchActionImpl).GetWatchRestrictions().Labels.String()
+		selectorLock.Unlock()
+		return true, fw, nil
+	})
+
+	kc := &Cluster{
+		MappedNamespaces:  []string{"explicit"},
+		NamespaceSelector: labels.SelectorFromSet(labels.Set{"team": "payments"}),
+		ki:                cs,
+	}
+	ns := func(name string) *core.Namespace {
+		return &core.Namespace{ObjectMeta: meta.ObjectMeta{Name: name, Labels: map[string]string{"team": "payments"}}}
+	}
+
+	fw.Add(ns("pr-1"))
+	kc.StartNamespaceWatcher(ctx)
+	selectorLock.Lock()
+	assert.Equal(t, "team=payments", selector)
+	selectorLock.Unlock()
+
+	// Explicitly mapped namespaces are mapped in addition to the selected ones.
+	assert.Equal(t, []string{"explicit", "pr-1"}, kc.GetCurrentNamespaces(true))
+
+	// Selected namespaces are mapped and unmapped as they come and go.
+	fw.Delete(ns("pr-1"))
+	fw.Add(ns("pr-2"))
+	require.Eventually(t, func() bool {
+		nss := kc.GetCurrentNamespaces(true)
+		return len(nss) == 2 && nss[0] == "explicit" && nss[1] == "pr-2"
+	}, 5*time.Second, 10*time.Millisecond)
+}
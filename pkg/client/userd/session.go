@@ -48,6 +48,7 @@ type Session interface {
 	InterceptProlog(context.Context, *manager.CreateInterceptRequest) *rpc.InterceptResult
 	InterceptEpilog(context.Context, *rpc.CreateInterceptRequest, *rpc.InterceptResult) *rpc.InterceptResult
 	RemoveIntercept(context.Context, string) error
+	SwitchInterceptHandler(context.Context, string, string) error
 	NewCreateInterceptRequest(*manager.InterceptSpec) *manager.CreateInterceptRequest
 
 	AddInterceptor(string, *rpc.Interceptor) error
@@ -58,6 +59,9 @@ type Session interface {
 	GetInterceptSpec(string) *manager.InterceptSpec
 	InterceptsForWorkload(string, string) []*manager.InterceptSpec
 
+	TakeOverRequests() []*manager.TakeOverRequest
+	AnswerTakeOver(context.Context, string, bool) error
+
 	ManagerClient() manager.ManagerClient
 	ManagerConn() *grpc.ClientConn
 	ManagerName() string
@@ -70,9 +74,10 @@ type Session interface {
 	Uninstall(context.Context, *rpc.UninstallRequest) (*common.Result, error)
 
 	WatchWorkloads(context.Context, *rpc.WatchWorkloadsRequest, WatchWorkloadsStream) error
-	WorkloadInfoSnapshot(context.Context, []string, rpc.ListRequest_Filter) (*rpc.WorkloadInfoSnapshot, error)
+	WorkloadInfoSnapshot(context.Context, []string, rpc.ListRequest_Filter, bool) (*rpc.WorkloadInfoSnapshot, error)
 
 	GetCurrentNamespaces(forClientAccess bool) []string
+	MapNamespaces(context.Context, []string, bool) ([]string, error)
 	ActualNamespace(string) string
 	AddNamespaceListener(context.Context, NamespaceListener)
 
@@ -80,6 +85,7 @@ type Session interface {
 	ForeachAgentPod(ctx context.Context, fn func(context.Context, typed.PodInterface, *core.Pod), filter func(*core.Pod) bool) error
 
 	GatherLogs(context.Context, *connector.LogsRequest) (*connector.LogsResponse, error)
+	StreamPodLogs(context.Context, *connector.StreamLogsRequest, func(*connector.LogEntry) error) error
 	GatherTraces(ctx context.Context, tr *connector.TracesRequest) *common.Result
 
 	SessionInfo() *manager.SessionInfo
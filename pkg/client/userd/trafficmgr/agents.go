@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -44,6 +45,20 @@ func (s *session) getCurrentAgentsInNamespace(ns string) map[string]*manager.Age
 	return agents
 }
 
+// addAgentInfos adds the agents of all replicas of each workload, as found in the current agent snapshot, to
+// the given workload infos.
+func (s *session) addAgentInfos(wis []*rpc.WorkloadInfo) {
+	s.currentAgentsLock.Lock()
+	defer s.currentAgentsLock.Unlock()
+	for _, wi := range wis {
+		for _, ai := range s.currentAgents {
+			if ai.Name == wi.Name && ai.Namespace == wi.Namespace {
+				wi.AgentInfos = append(wi.AgentInfos, proto.Clone(ai).(*manager.AgentInfo))
+			}
+		}
+	}
+}
+
 func (s *session) getCurrentSidecarsInNamespace(ctx context.Context, ns string) map[string]*agentconfig.Sidecar {
 	// Load configmap entry from the telepresence-agents configmap
 	cm, err := k8sapi.GetK8sInterface(ctx).CoreV1().ConfigMaps(ns).Get(ctx, agentconfig.ConfigMap, meta.GetOptions{})
@@ -211,7 +226,13 @@ func (s *session) addAgent(
 	workload := svcProps.workload
 	agentName := workload.GetName()
 	namespace := workload.GetNamespace()
-	_, kind, err := legacyEnsureAgent(c, s.Cluster, workload, svcProps, agentImageName, telepresenceAPIPort)
+	var kind string
+	err := client.GetConfig(c).Retries().Policy().Do(c, "agent install", func(c context.Context) (err error) {
+		if _, kind, err = legacyEnsureAgent(c, s.Cluster, workload, svcProps, agentImageName, telepresenceAPIPort); err == errAgentNotFound {
+			err = client.PermanentError(err)
+		}
+		return err
+	})
 	if err != nil {
 		if err == errAgentNotFound {
 			return nil, &rpc.InterceptResult{
@@ -254,12 +275,14 @@ func (s *session) waitForAgent(ctx context.Context, name, namespace string) (*ma
 		}
 	}
 
+	start := time.Now()
 	ctx, cancel := client.GetConfig(ctx).Timeouts().TimeoutContext(ctx, client.TimeoutAgentInstall) // installing a new agent can take some time
 	defer cancel()
 
 	select {
 	case <-ctx.Done():
-		return nil, client.CheckTimeout(ctx, fmt.Errorf("waiting for agent %q to be present", fullName))
+		err := client.CheckTimeout(ctx, fmt.Errorf("waiting for agent %q to be present", fullName))
+		return nil, withWorkloadProblems(ctx, err, name, namespace, start)
 	case agent := <-waitCh:
 		return agent, nil
 	}
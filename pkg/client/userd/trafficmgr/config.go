@@ -35,6 +35,7 @@ func (s *session) GetConfig(ctx context.Context) (*client.SessionConfig, error)
 			IncludeSuffixes: dns.IncludeSuffixes,
 			ExcludeSuffixes: dns.ExcludeSuffixes,
 			LookupTimeout:   dns.LookupTimeout.AsDuration(),
+			ClusterDomain:   dns.ClusterDomain,
 		},
 		Routing: client.Routing{
 			Subnets:    subnets(nc.Subnets),
@@ -42,5 +43,6 @@ func (s *session) GetConfig(ctx context.Context) (*client.SessionConfig, error)
 			NeverProxy: subnets(oi.NeverProxySubnets),
 		},
 		ManagerNamespace: s.GetManagerNamespace(),
+		ActiveTimeouts:   s.sessionConfig.Timeouts().Active(),
 	}, nil
 }
@@ -0,0 +1,418 @@
+package trafficmgr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	core "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+	"github.com/telepresenceio/telepresence/v2/pkg/maps"
+)
+
+// configRetryDelay is how long a watch of a ConfigMap or Secret waits before it's restarted after a failure.
+const configRetryDelay = 5 * time.Second
+
+// configRef is a ConfigMap or Secret that the environment or the volumes of an intercepted container are
+// taken from.
+type configRef struct {
+	secret bool
+	name   string
+}
+
+func (r configRef) String() string {
+	if r.secret {
+		return "Secret " + r.name
+	}
+	return "ConfigMap " + r.name
+}
+
+type envFromRef struct {
+	ref    configRef
+	prefix string
+}
+
+type envKeyRef struct {
+	name string
+	ref  configRef
+	key  string
+}
+
+// containerConfig describes how the environment and the volumes of a container are taken from ConfigMaps and
+// Secrets.
+type containerConfig struct {
+	envFrom []envFromRef
+	envKeys []envKeyRef
+
+	// literals are the names of the variables that are declared with a literal value. They take precedence over
+	// the ones declared by envFrom.
+	literals map[string]struct{}
+
+	// volumes are the mount paths of the volumes that are taken from each ConfigMap or Secret.
+	volumes map[configRef][]string
+}
+
+func newContainerConfig(pod *core.PodSpec, cn *core.Container) *containerConfig {
+	cc := &containerConfig{literals: make(map[string]struct{}), volumes: make(map[configRef][]string)}
+	for _, ef := range cn.EnvFrom {
+		switch {
+		case ef.ConfigMapRef != nil:
+			cc.envFrom = append(cc.envFrom, envFromRef{ref: configRef{name: ef.ConfigMapRef.Name}, prefix: ef.Prefix})
+		case ef.SecretRef != nil:
+			cc.envFrom = append(cc.envFrom, envFromRef{ref: configRef{secret: true, name: ef.SecretRef.Name}, prefix: ef.Prefix})
+		}
+	}
+	for _, e := range cn.Env {
+		vf := e.ValueFrom
+		switch {
+		case vf == nil:
+			cc.literals[e.Name] = struct{}{}
+		case vf.ConfigMapKeyRef != nil:
+			cc.envKeys = append(cc.envKeys, envKeyRef{name: e.Name, ref: configRef{name: vf.ConfigMapKeyRef.Name}, key: vf.ConfigMapKeyRef.Key})
+		case vf.SecretKeyRef != nil:
+			cc.envKeys = append(cc.envKeys, envKeyRef{name: e.Name, ref: configRef{secret: true, name: vf.SecretKeyRef.Name}, key: vf.SecretKeyRef.Key})
+		}
+	}
+
+	volumeRefs := make(map[string][]configRef)
+	for _, v := range pod.Volumes {
+		switch {
+		case v.ConfigMap != nil:
+			volumeRefs[v.Name] = []configRef{{name: v.ConfigMap.Name}}
+		case v.Secret != nil:
+			volumeRefs[v.Name] = []configRef{{secret: true, name: v.Secret.SecretName}}
+		case v.Projected != nil:
+			for _, ps := range v.Projected.Sources {
+				switch {
+				case ps.ConfigMap != nil:
+					volumeRefs[v.Name] = append(volumeRefs[v.Name], configRef{name: ps.ConfigMap.Name})
+				case ps.Secret != nil:
+					volumeRefs[v.Name] = append(volumeRefs[v.Name], configRef{secret: true, name: ps.Secret.Name})
+				}
+			}
+		}
+	}
+	for _, vm := range cn.VolumeMounts {
+		for _, ref := range volumeRefs[vm.Name] {
+			cc.volumes[ref] = append(cc.volumes[ref], vm.MountPath)
+		}
+	}
+	return cc
+}
+
+// refs returns the ConfigMaps and Secrets that the container uses.
+func (cc *containerConfig) refs() []configRef {
+	set := make(map[configRef]struct{})
+	for _, ef := range cc.envFrom {
+		set[ef.ref] = struct{}{}
+	}
+	for _, ek := range cc.envKeys {
+		set[ek.ref] = struct{}{}
+	}
+	for ref := range cc.volumes {
+		set[ref] = struct{}{}
+	}
+	refs := make([]configRef, 0, len(set))
+	for ref := range set {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].String() < refs[j].String() })
+	return refs
+}
+
+// environment returns the variables that are taken from the given data of the ConfigMaps and Secrets, in the
+// same order of precedence as the kubelet uses.
+func (cc *containerConfig) environment(data map[configRef]map[string]string) map[string]string {
+	env := make(map[string]string)
+	for _, ef := range cc.envFrom {
+		for k, v := range data[ef.ref] {
+			if _, ok := cc.literals[ef.prefix+k]; !ok {
+				env[ef.prefix+k] = v
+			}
+		}
+	}
+	for _, ek := range cc.envKeys {
+		if v, ok := data[ek.ref][ek.key]; ok {
+			env[ek.name] = v
+		} else {
+			delete(env, ek.name)
+		}
+	}
+	return env
+}
+
+// envChanges returns the variables of the next environment that differ from the previous one. A nil value means
+// that the variable was removed.
+func envChanges(prev, next map[string]string) map[string]*string {
+	changes := make(map[string]*string)
+	for k, v := range next {
+		if pv, ok := prev[k]; !ok || pv != v {
+			v := v
+			changes[k] = &v
+		}
+	}
+	for k := range prev {
+		if _, ok := next[k]; !ok {
+			changes[k] = nil
+		}
+	}
+	return changes
+}
+
+// watchInterceptConfig watches the ConfigMaps and Secrets that the environment and the volumes of the intercepted
+// container are taken from, and updates the variables in the intercept's env files when they change. Changes to
+// volumes are only reported, because the kubelet updates the files, and the remote mount reflects that.
+func (s *session) watchInterceptConfig(ctx context.Context, ic *intercept) {
+	ic.wg.Add(1)
+	go func() {
+		defer ic.wg.Done()
+		spec := ic.Spec
+		cc, err := s.interceptContainerConfig(ctx, ic)
+		if err != nil {
+			dlog.Errorf(ctx, "unable to watch the configuration of intercept %s: %v", spec.Name, err)
+			return
+		}
+		refs := cc.refs()
+		if len(refs) == 0 {
+			return
+		}
+		changedCh := make(chan struct{}, 1)
+		for _, ref := range refs {
+			ic.wg.Add(1)
+			go func(ref configRef) {
+				defer ic.wg.Done()
+				watchConfigRef(ctx, spec.Namespace, ref, changedCh)
+			}(ref)
+		}
+
+		data := loadConfigRefs(ctx, spec.Namespace, refs)
+		env := cc.environment(data)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changedCh:
+			}
+			nextData := loadConfigRefs(ctx, spec.Namespace, refs)
+			if ctx.Err() != nil {
+				return
+			}
+			for _, ref := range refs {
+				if mps, ok := cc.volumes[ref]; ok && !maps.Equal(data[ref], nextData[ref]) {
+					dlog.Infof(ctx, "%s of intercept %s changed. The files mounted at %s are updated by the kubelet",
+						ref, spec.Name, strings.Join(mps, ", "))
+				}
+			}
+			data = nextData
+			nextEnv := cc.environment(data)
+			changes := envChanges(env, nextEnv)
+			env = nextEnv
+			if len(changes) == 0 {
+				continue
+			}
+			names := make([]string, 0, len(changes))
+			for k := range changes {
+				names = append(names, k)
+			}
+			sort.Strings(names)
+			dlog.Infof(ctx, "The environment of intercept %s changed: %s", spec.Name, strings.Join(names, ", "))
+			if ic.envFile != "" {
+				if err := patchEnvFile(ic.envFile, changes); err != nil {
+					dlog.Errorf(ctx, "failed to update %s: %v", ic.envFile, err)
+				}
+			}
+			if ic.envJSON != "" {
+				if err := patchEnvJSON(ic.envJSON, changes); err != nil {
+					dlog.Errorf(ctx, "failed to update %s: %v", ic.envJSON, err)
+				}
+			}
+		}
+	}()
+}
+
+// interceptContainerConfig returns the containerConfig of the intercepted container of the given intercept.
+func (s *session) interceptContainerConfig(ctx context.Context, ic *intercept) (*containerConfig, error) {
+	spec := ic.Spec
+	wl, err := k8sapi.GetWorkload(ctx, spec.Agent, spec.Namespace, spec.WorkloadKind)
+	if err != nil {
+		return nil, err
+	}
+	pod := wl.GetPodTemplate()
+
+	// The agent config tells which container the intercepted service port targets.
+	cnName := ""
+	if sc, ok := s.getCurrentSidecarsInNamespace(ctx, spec.Namespace)[spec.Agent]; ok {
+	outer:
+		for _, cn := range sc.Containers {
+			for _, ii := range cn.Intercepts {
+				if ii.ServiceUID == types.UID(spec.ServiceUid) && (spec.ServicePortName == "" || ii.ServicePortName == spec.ServicePortName) {
+					cnName = cn.Name
+					break outer
+				}
+			}
+		}
+	}
+	for i := range pod.Spec.Containers {
+		cn := &pod.Spec.Containers[i]
+		if cn.Name == cnName || cnName == "" && cn.Name != agentconfig.ContainerName {
+			return newContainerConfig(&pod.Spec, cn), nil
+		}
+	}
+	return nil, errors.New("unable to find the intercepted container")
+}
+
+// watchConfigRef sends to the given channel each time the given ConfigMap or Secret is added, modified, or
+// deleted, until the context is cancelled.
+func watchConfigRef(ctx context.Context, namespace string, ref configRef, changedCh chan<- struct{}) {
+	opts := meta.ListOptions{FieldSelector: "metadata.name=" + ref.name}
+	api := k8sapi.GetK8sInterface(ctx).CoreV1()
+	for ctx.Err() == nil {
+		var w watch.Interface
+		var err error
+		if ref.secret {
+			w, err = api.Secrets(namespace).Watch(ctx, opts)
+		} else {
+			w, err = api.ConfigMaps(namespace).Watch(ctx, opts)
+		}
+		if err != nil {
+			dlog.Errorf(ctx, "unable to watch %s: %v", ref, err)
+		} else {
+			for ev := range w.ResultChan() {
+				if ev.Type == watch.Error {
+					break
+				}
+				select {
+				case changedCh <- struct{}{}:
+				default:
+				}
+			}
+			w.Stop()
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(configRetryDelay):
+		}
+	}
+}
+
+// loadConfigRefs returns the data of the given ConfigMaps and Secrets. The data of the ones that don't exist, or
+// that can't be loaded, is nil.
+func loadConfigRefs(ctx context.Context, namespace string, refs []configRef) map[configRef]map[string]string {
+	api := k8sapi.GetK8sInterface(ctx).CoreV1()
+	data := make(map[configRef]map[string]string, len(refs))
+	for _, ref := range refs {
+		if ref.secret {
+			sc, err := api.Secrets(namespace).Get(ctx, ref.name, meta.GetOptions{})
+			if err != nil {
+				logLoadError(ctx, ref, err)
+				continue
+			}
+			d := make(map[string]string, len(sc.Data))
+			for k, v := range sc.Data {
+				d[k] = string(v)
+			}
+			data[ref] = d
+		} else {
+			cm, err := api.ConfigMaps(namespace).Get(ctx, ref.name, meta.GetOptions{})
+			if err != nil {
+				logLoadError(ctx, ref, err)
+				continue
+			}
+			data[ref] = cm.Data
+		}
+	}
+	return data
+}
+
+func logLoadError(ctx context.Context, ref configRef, err error) {
+	if !k8sErrors.IsNotFound(err) && ctx.Err() == nil {
+		dlog.Errorf(ctx, "unable to load %s: %v", ref, err)
+	}
+}
+
+// envFileVarRx matches the first line of a variable in an env file written by "telepresence intercept --env-file".
+// Other lines continue the value of the previous variable.
+var envFileVarRx = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*=`)
+
+// patchEnvFile applies the given changes to the env file written by "telepresence intercept --env-file".
+func patchEnvFile(path string, changes map[string]*string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	env := make(map[string]string)
+	prev := ""
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, len(data)+1)
+	for sc.Scan() {
+		line := sc.Text()
+		if envFileVarRx.MatchString(line) {
+			k, v, _ := strings.Cut(line, "=")
+			env[k] = v
+			prev = k
+		} else if prev != "" {
+			env[prev] += "\n" + line
+		}
+	}
+	if err = sc.Err(); err != nil {
+		return err
+	}
+	applyEnvChanges(env, changes)
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	for _, k := range keys {
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		buf.WriteString(env[k])
+		buf.WriteByte('\n')
+	}
+	return writeFileAtomic(path, buf.Bytes())
+}
+
+// patchEnvJSON applies the given changes to the JSON file written by "telepresence intercept --env-json".
+func patchEnvJSON(path string, changes map[string]*string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var env map[string]string
+	if err = json.Unmarshal(data, &env); err != nil {
+		return err
+	}
+	if env == nil {
+		env = make(map[string]string)
+	}
+	applyEnvChanges(env, changes)
+	if data, err = json.MarshalIndent(env, "", "  "); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+func applyEnvChanges(env map[string]string, changes map[string]*string) {
+	for k, v := range changes {
+		if v == nil {
+			delete(env, k)
+		} else {
+			env[k] = *v
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package trafficmgr
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+)
+
+func TestContainerConfig(t *testing.T) {
+	cm := configRef{name: "app-config"}
+	sc := configRef{secret: true, name: "app-secret"}
+	pod := &core.PodSpec{
+		Volumes: []core.Volume{
+			{Name: "config", VolumeSource: core.VolumeSource{ConfigMap: &core.ConfigMapVolumeSource{
+				LocalObjectReference: core.LocalObjectReference{Name: "app-config"},
+			}}},
+			{Name: "data", VolumeSource: core.VolumeSource{EmptyDir: &core.EmptyDirVolumeSource{}}},
+		},
+	}
+	cn := &core.Container{
+		EnvFrom: []core.EnvFromSource{
+			{ConfigMapRef: &core.ConfigMapEnvSource{LocalObjectReference: core.LocalObjectReference{Name: "app-config"}}, Prefix: "APP_"},
+		},
+		Env: []core.EnvVar{
+			{Name: "APP_MODE", Value: "literal"},
+			{Name: "DB_PASSWORD", ValueFrom: &core.EnvVarSource{SecretKeyRef: &core.SecretKeySelector{
+				LocalObjectReference: core.LocalObjectReference{Name: "app-secret"}, Key: "password",
+			}}},
+		},
+		VolumeMounts: []core.VolumeMount{{Name: "config", MountPath: "/etc/config"}, {Name: "data", MountPath: "/data"}},
+	}
+	cc := newContainerConfig(pod, cn)
+	assert.Equal(t, []configRef{cm, sc}, cc.refs())
+	assert.Equal(t, map[configRef][]string{cm: {"/etc/config"}}, cc.volumes)
+
+	env := cc.environment(map[configRef]map[string]string{
+		cm: {"LEVEL": "debug", "MODE": "shadowed"},
+		sc: {"password": "secret"},
+	})
+	assert.Equal(t, map[string]string{"APP_LEVEL": "debug", "DB_PASSWORD": "secret"}, env)
+
+	next := cc.environment(map[configRef]map[string]string{cm: {"LEVEL": "info"}})
+	changes := envChanges(env, next)
+	require.Len(t, changes, 2)
+	assert.Equal(t, "info", *changes["APP_LEVEL"])
+	assert.Nil(t, changes["DB_PASSWORD"])
+}
+
+func TestPatchEnvFiles(t *testing.T) {
+	dir := t.TempDir()
+	level := "info"
+	changes := map[string]*string{"APP_LEVEL": &level, "DB_PASSWORD": nil}
+
+	envFile := filepath.Join(dir, "intercept.env")
+	require.NoError(t, os.WriteFile(envFile, []byte("APP_LEVEL=debug\nCERT=line1\nline2\nDB_PASSWORD=secret\n"), 0o644))
+	require.NoError(t, patchEnvFile(envFile, changes))
+	data, err := os.ReadFile(envFile)
+	require.NoError(t, err)
+	assert.Equal(t, "APP_LEVEL=info\nCERT=line1\nline2\n", string(data))
+
+	envJSON := filepath.Join(dir, "intercept.json")
+	require.NoError(t, os.WriteFile(envJSON, []byte(`{"APP_LEVEL": "debug", "DB_PASSWORD": "secret", "HOME": "/root"}`), 0o644))
+	require.NoError(t, patchEnvJSON(envJSON, changes))
+	data, err = os.ReadFile(envJSON)
+	require.NoError(t, err)
+	var env map[string]string
+	require.NoError(t, json.Unmarshal(data, &env))
+	assert.Equal(t, map[string]string{"APP_LEVEL": "info", "HOME": "/root"}, env)
+}
@@ -16,5 +16,6 @@ func (s *session) _dialRequestWatcher(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	return tunnel.DialWaitLoop(ctx, s.managerClient, dialerStream, s.sessionInfo.SessionId)
+	ctx = tunnel.WithHeartbeat(ctx, s.heartbeat)
+	return tunnel.LimitedDialWaitLoop(ctx, s.managerClient, dialerStream, s.sessionInfo.SessionId, s.resources.acquireTunnel)
 }
@@ -0,0 +1,145 @@
+package trafficmgr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	empty "google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+)
+
+// envJSONRefreshInterval is how often the connection environment is refreshed when nothing
+// triggers a refresh. It catches changes that the session isn't notified about, such as new
+// subnets routed by the root daemon.
+const envJSONRefreshInterval = 10 * time.Second
+
+// setEnvJSON changes the file that the connection environment is written to and triggers a refresh.
+func (s *session) setEnvJSON(path string) {
+	s.envJSONLock.Lock()
+	s.envJSON = path
+	s.envJSONLock.Unlock()
+	s.refreshEnvJSON()
+}
+
+// refreshEnvJSON triggers a refresh of the connection environment file.
+func (s *session) refreshEnvJSON() {
+	select {
+	case s.envJSONCh <- struct{}{}:
+	default:
+	}
+}
+
+// envJSONWriter writes the connection environment to the file given with "telepresence connect --env-json"
+// each time it changes. The file is removed when the session ends.
+func (s *session) envJSONWriter(ctx context.Context) error {
+	ticker := time.NewTicker(envJSONRefreshInterval)
+	defer ticker.Stop()
+
+	var path string
+	var written []byte
+	defer func() {
+		if path != "" {
+			_ = os.Remove(path)
+		}
+	}()
+	for {
+		s.envJSONLock.Lock()
+		p := s.envJSON
+		s.envJSONLock.Unlock()
+		if p != path {
+			if path != "" {
+				_ = os.Remove(path)
+			}
+			path = p
+			written = nil
+		}
+		if path != "" {
+			data, err := json.MarshalIndent(s.connectionEnv(ctx), "", "  ")
+			if err != nil {
+				// Creating JSON from a map[string]string should never fail
+				panic(err)
+			}
+			if !bytes.Equal(data, written) {
+				if err = writeFileAtomic(path, data); err != nil {
+					dlog.Errorf(ctx, "failed to write connection environment to %s: %v", path, err)
+				} else {
+					written = data
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-s.envJSONCh:
+		}
+	}
+}
+
+// connectionEnv returns the facts about the connection that are written to the environment file.
+func (s *session) connectionEnv(ctx context.Context) map[string]string {
+	env := map[string]string{
+		"TELEPRESENCE_CONNECTION_NAME":   s.daemonID.Name,
+		"TELEPRESENCE_CLUSTER_ID":        s.GetClusterId(ctx),
+		"TELEPRESENCE_MANAGER_NAMESPACE": s.GetManagerNamespace(),
+		"TELEPRESENCE_NAMESPACE":         s.Namespace,
+		"TELEPRESENCE_MAPPED_NAMESPACES": strings.Join(s.GetCurrentNamespaces(true), ","),
+	}
+
+	apiPort := client.GetConfig(ctx).TelepresenceAPI().Port
+	if apiPort == 0 {
+		// Default to the API port declared by the traffic-manager
+		if apiInfo, err := s.managerClient.GetTelepresenceAPI(ctx, &empty.Empty{}); err == nil {
+			apiPort = int(apiInfo.Port)
+		}
+	}
+	if apiPort != 0 {
+		env[agentconfig.EnvAPIPort] = strconv.Itoa(apiPort)
+		env[agentconfig.EnvAPIURL] = agentconfig.APIURL(apiPort)
+	}
+
+	if s.rootDaemon != nil {
+		if nc, err := s.rootDaemon.GetNetworkConfig(ctx, &empty.Empty{}); err == nil {
+			subnets := make([]string, len(nc.Subnets))
+			for i, sn := range nc.Subnets {
+				subnets[i] = iputil.IPNetFromRPC(sn).String()
+			}
+			env["TELEPRESENCE_SUBNETS"] = strings.Join(subnets, ",")
+		}
+	}
+	return env
+}
+
+// writeFileAtomic writes the data to a temporary file which is then renamed to path, so that
+// readers never see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp, 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
+}
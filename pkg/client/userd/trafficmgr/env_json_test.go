@@ -0,0 +1,25 @@
+package trafficmgr
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "env.json")
+	require.NoError(t, writeFileAtomic(path, []byte(`{"A": "1"}`)))
+	require.NoError(t, writeFileAtomic(path, []byte(`{"A": "2"}`)))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, `{"A": "2"}`, string(data))
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+}
@@ -0,0 +1,192 @@
+package trafficmgr
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+
+	"github.com/datawire/dlib/dcontext"
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+)
+
+// eventsHeader introduces the list of events in an error message. The traffic-manager uses the same
+// header, so its presence means that the events are listed already.
+const eventsHeader = "Events that may be relevant:"
+
+// eventsFetchTimeout is how long the retrieval of the events and pods of a failing workload may take.
+const eventsFetchTimeout = 5 * time.Second
+
+// failingContainerReasons are the reasons for a waiting container that won't resolve by waiting longer.
+var failingContainerReasons = map[string]struct{}{ //nolint:gochecknoglobals // constant
+	"CreateContainerConfigError": {},
+	"CrashLoopBackOff":           {},
+	"ErrImagePull":               {},
+	"ImagePullBackOff":           {},
+	"InvalidImageName":           {},
+	"RunContainerError":          {},
+}
+
+// withWorkloadProblems appends the problems that workloadProblems finds to the given error. The given
+// context is typically done already, so the problems are retrieved using a time limited context that
+// isn't canceled with it.
+func withWorkloadProblems(ctx context.Context, err error, name, namespace string, since time.Time) error {
+	if err == nil || strings.Contains(err.Error(), eventsHeader) {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(dcontext.WithoutCancel(ctx), eventsFetchTimeout)
+	defer cancel()
+	if ps := workloadProblems(ctx, name, namespace, since); ps != "" {
+		err = &problemsError{error: err, problems: ps}
+	}
+	return err
+}
+
+// problemsError adds a description of the problems of a workload to an error.
+type problemsError struct {
+	error
+	problems string
+}
+
+func (e *problemsError) Error() string {
+	return e.error.Error() + "\n" + e.problems
+}
+
+func (e *problemsError) Unwrap() error {
+	return e.error
+}
+
+// workloadProblems returns a description of the warning events that were emitted after the given time for the
+// given workload, its replica sets, and its pods, followed by the containers of those pods that fail to start or
+// were OOMKilled. An empty string is returned when no problems are found or when they can't be retrieved, e.g.
+// because the user isn't permitted to list events.
+func workloadProblems(ctx context.Context, name, namespace string, since time.Time) string {
+	api := k8sapi.GetK8sInterface(ctx).CoreV1()
+	bf := &strings.Builder{}
+	el, err := api.Events(namespace).List(ctx, meta.ListOptions{
+		FieldSelector: fields.OneTermNotEqualSelector("type", core.EventTypeNormal).String(),
+	})
+	if err != nil {
+		dlog.Debugf(ctx, "unable to list events in namespace %s: %v", namespace, err)
+	} else if es := relevantEvents(el.Items, name, since); len(es) > 0 {
+		bf.WriteString(eventsHeader)
+		bf.WriteByte('\n')
+		writeEventList(bf, es, time.Now())
+	}
+
+	pl, err := api.Pods(namespace).List(ctx, meta.ListOptions{})
+	if err != nil {
+		dlog.Debugf(ctx, "unable to list pods in namespace %s: %v", namespace, err)
+	} else if cs := failingContainers(pl.Items, name); len(cs) > 0 {
+		bf.WriteString("Containers that fail:\n")
+		for _, c := range cs {
+			fmt.Fprintf(bf, "  %s\n", c)
+		}
+	}
+	return strings.TrimSuffix(bf.String(), "\n")
+}
+
+// isWorkloadObject returns true if the object with the given name is the workload, or an object that the
+// workload owns, such as its replica sets and pods.
+func isWorkloadObject(objName, name string) bool {
+	return objName == name || strings.HasPrefix(objName, name+"-")
+}
+
+func eventTime(e *core.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	default:
+		return e.CreationTimestamp.Time
+	}
+}
+
+// relevantEvents returns the warning events of the given workload that were emitted after the given time, oldest
+// first.
+func relevantEvents(events []core.Event, name string, since time.Time) []*core.Event {
+	var es []*core.Event
+	for i := range events {
+		e := &events[i]
+		if e.Type != core.EventTypeNormal && isWorkloadObject(e.InvolvedObject.Name, name) && !eventTime(e).Before(since) {
+			es = append(es, e)
+		}
+	}
+	sort.SliceStable(es, func(i, j int) bool {
+		return eventTime(es[i]).Before(eventTime(es[j]))
+	})
+	return es
+}
+
+// failingContainers returns a description of each container in the pods of the given workload that fails to
+// start, or that was OOMKilled.
+func failingContainers(pods []core.Pod, name string) []string {
+	var cs []string
+	for i := range pods {
+		pod := &pods[i]
+		if !isWorkloadObject(pod.Name, name) {
+			continue
+		}
+		statuses := append(append([]core.ContainerStatus(nil), pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for j := range statuses {
+			st := &statuses[j]
+			prefix := fmt.Sprintf("pod/%s container %s", pod.Name, st.Name)
+			if w := st.State.Waiting; w != nil {
+				if _, ok := failingContainerReasons[w.Reason]; ok {
+					if w.Message != "" {
+						cs = append(cs, fmt.Sprintf("%s: %s: %s", prefix, w.Reason, w.Message))
+					} else {
+						cs = append(cs, fmt.Sprintf("%s: %s", prefix, w.Reason))
+					}
+					continue
+				}
+			}
+			if t := st.State.Terminated; t != nil && t.Reason == "OOMKilled" {
+				cs = append(cs, prefix+" was OOMKilled")
+			} else if t = st.LastTerminationState.Terminated; t != nil && t.Reason == "OOMKilled" {
+				cs = append(cs, fmt.Sprintf("%s was OOMKilled %s ago", prefix, time.Since(t.FinishedAt.Time).Truncate(time.Second)))
+			}
+		}
+	}
+	return cs
+}
+
+func writeEventList(bf *strings.Builder, es []*core.Event, now time.Time) {
+	age := func(e *core.Event) string {
+		return now.Sub(eventTime(e)).Truncate(time.Second).String()
+	}
+	object := func(e *core.Event) string {
+		or := e.InvolvedObject
+		return strings.ToLower(or.Kind) + "/" + or.Name
+	}
+	ageLen, typeLen, reasonLen, objectLen := len("AGE"), len("TYPE"), len("REASON"), len("OBJECT")
+	for _, e := range es {
+		if l := len(age(e)); l > ageLen {
+			ageLen = l
+		}
+		if l := len(e.Type); l > typeLen {
+			typeLen = l
+		}
+		if l := len(e.Reason); l > reasonLen {
+			reasonLen = l
+		}
+		if l := len(object(e)); l > objectLen {
+			objectLen = l
+		}
+	}
+	ageLen += 3
+	typeLen += 3
+	reasonLen += 3
+	objectLen += 3
+	fmt.Fprintf(bf, "%-*s%-*s%-*s%-*s%s\n", ageLen, "AGE", typeLen, "TYPE", reasonLen, "REASON", objectLen, "OBJECT", "MESSAGE")
+	for _, e := range es {
+		fmt.Fprintf(bf, "%-*s%-*s%-*s%-*s%s\n", ageLen, age(e), typeLen, e.Type, reasonLen, e.Reason, objectLen, object(e), e.Message)
+	}
+}
@@ -0,0 +1,85 @@
+package trafficmgr
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+func TestWithWorkloadProblems(t *testing.T) {
+	now := time.Now()
+	event := func(name, kind, obj, tp, reason, msg string, age time.Duration) *core.Event {
+		return &core.Event{
+			ObjectMeta:     meta.ObjectMeta{Name: name, Namespace: "default"},
+			InvolvedObject: core.ObjectReference{Kind: kind, Name: obj, Namespace: "default"},
+			Type:           tp,
+			Reason:         reason,
+			Message:        msg,
+			LastTimestamp:  meta.NewTime(now.Add(-age)),
+		}
+	}
+	pod := &core.Pod{
+		ObjectMeta: meta.ObjectMeta{Name: "echo-7d9c-x2kqp", Namespace: "default"},
+		Status: core.PodStatus{
+			InitContainerStatuses: []core.ContainerStatus{{
+				Name:  "tel-agent-init",
+				State: core.ContainerState{Waiting: &core.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "Back-off pulling image"}},
+			}},
+			ContainerStatuses: []core.ContainerStatus{
+				{Name: "echo", State: core.ContainerState{Waiting: &core.ContainerStateWaiting{Reason: "PodInitializing"}}},
+				{
+					Name:                 "traffic-agent",
+					LastTerminationState: core.ContainerState{Terminated: &core.ContainerStateTerminated{Reason: "OOMKilled", FinishedAt: meta.NewTime(now)}},
+				},
+			},
+		},
+	}
+	other := &core.Pod{
+		ObjectMeta: meta.ObjectMeta{Name: "echoserver-1", Namespace: "default"},
+		Status: core.PodStatus{ContainerStatuses: []core.ContainerStatus{{
+			Name:  "echoserver",
+			State: core.ContainerState{Waiting: &core.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+		}}},
+	}
+	ki := fake.NewSimpleClientset(
+		event("e1", "Pod", "echo-7d9c-x2kqp", core.EventTypeWarning, "Failed", "Failed to pull image", time.Second),
+		event("e2", "ReplicaSet", "echo-7d9c", core.EventTypeWarning, "FailedCreate", "admission webhook denied the request", 3*time.Second),
+		event("e3", "Pod", "echo-7d9c-x2kqp", core.EventTypeNormal, "Pulling", "Pulling image", time.Second),
+		event("e4", "Pod", "echo-7d9c-old", core.EventTypeWarning, "BackOff", "Back-off restarting", time.Hour),
+		event("e5", "Pod", "echoserver-1", core.EventTypeWarning, "BackOff", "Back-off restarting", time.Second),
+		pod, other,
+	)
+	ctx := k8sapi.WithK8sInterface(context.Background(), ki)
+
+	err := withWorkloadProblems(ctx, errcat.User.New("request timed out"), "echo", "default", now.Add(-time.Minute))
+	assert.Equal(t, errcat.User, errcat.GetCategory(err))
+	lines := strings.Split(err.Error(), "\n")
+	require.Len(t, lines, 8)
+	assert.Equal(t, "request timed out", lines[0])
+	assert.Equal(t, eventsHeader, lines[1])
+	assert.Regexp(t, `^AGE\s+TYPE\s+REASON\s+OBJECT\s+MESSAGE$`, lines[2])
+	assert.Regexp(t, `^[34]s\s+Warning\s+FailedCreate\s+replicaset/echo-7d9c\s+admission webhook denied the request$`, lines[3])
+	assert.Regexp(t, `^[12]s\s+Warning\s+Failed\s+pod/echo-7d9c-x2kqp\s+Failed to pull image$`, lines[4])
+	assert.Equal(t, "Containers that fail:", lines[5])
+	assert.Equal(t, "  pod/echo-7d9c-x2kqp container tel-agent-init: ImagePullBackOff: Back-off pulling image", lines[6])
+	assert.Regexp(t, `^  pod/echo-7d9c-x2kqp container traffic-agent was OOMKilled \d+s ago$`, lines[7])
+
+	// Errors that list the events already are left alone.
+	listed := errors.New("timed out: " + eventsHeader + "\n...")
+	assert.Equal(t, listed, withWorkloadProblems(ctx, listed, "echo", "default", now))
+
+	// So are errors for workloads without problems.
+	plain := errors.New("timed out")
+	assert.Equal(t, plain, withWorkloadProblems(ctx, plain, "hello", "default", now))
+}
@@ -85,35 +85,54 @@ func (s *session) ForeachAgentPod(ctx context.Context, fn func(context.Context,
 		return false
 	}
 
+	// All pods are collected before fn is called, so that fn can be called concurrently for all of them.
 	coreAPI := k8sapi.GetK8sInterface(ctx).CoreV1()
+	var podsWithContainer []*core.Pod
 	for _, ns := range s.GetCurrentNamespaces(true) {
-		podsAPI := coreAPI.Pods(ns)
-		podList, err := podsAPI.List(ctx, meta.ListOptions{})
+		podList, err := coreAPI.Pods(ns).List(ctx, meta.ListOptions{})
 		if err != nil {
 			return err
 		}
 		pods := podList.Items
-		podsWithContainer := make([]*core.Pod, 0, len(pods))
 		for i := range pods {
 			pod := &pods[i]
 			if hasContainer(pod) {
 				podsWithContainer = append(podsWithContainer, pod)
 			}
 		}
-		wg := sync.WaitGroup{}
-		wg.Add(len(podsWithContainer))
-		for _, pod := range podsWithContainer {
-			go func(pod *core.Pod) {
-				defer wg.Done()
-				fn(ctx, podsAPI, pod)
-			}(pod)
-		}
-		wg.Wait()
 	}
-
+	wg := sync.WaitGroup{}
+	wg.Add(len(podsWithContainer))
+	for _, pod := range podsWithContainer {
+		go func(pod *core.Pod) {
+			defer wg.Done()
+			fn(ctx, coreAPI.Pods(pod.Namespace), pod)
+		}(pod)
+	}
+	wg.Wait()
 	return nil
 }
 
+// trafficManagerPod returns the pod of the traffic-manager.
+func (s *session) trafficManagerPod(ctx context.Context) (*core.Pod, error) {
+	ns := s.GetManagerNamespace()
+	selector := labels.SelectorFromSet(labels.Set{
+		"app":          "traffic-manager",
+		"telepresence": "manager",
+	})
+	podList, err := k8sapi.GetK8sInterface(ctx).CoreV1().Pods(ns).List(ctx, meta.ListOptions{LabelSelector: selector.String()})
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("failed to gather logs for traffic manager in namespace %s: %w", ns, err)
+	case len(podList.Items) == 1:
+		return &podList.Items[0], nil
+	case len(podList.Items) > 1:
+		return nil, fmt.Errorf("multiple traffic managers found in namespace %s using selector %s", ns, selector.String())
+	default:
+		return nil, fmt.Errorf("no traffic manager found in namespace %s using selector %s", ns, selector.String())
+	}
+}
+
 // GatherLogs acquires the logs for the traffic-manager and/or traffic-agents specified by the
 // connector.LogsRequest and returns them to the caller.
 func (s *session) GatherLogs(ctx context.Context, request *connector.LogsRequest) (*connector.LogsResponse, error) {
@@ -140,31 +159,14 @@ func (s *session) GatherLogs(ctx context.Context, request *connector.LogsRequest
 	// any errors in the traffic-manager getting the traffic-agent pods, we
 	// want those logs to appear in what we export
 	if request.TrafficManager {
-		ns := s.GetManagerNamespace()
-		podsAPI := coreAPI.Pods(ns)
-		selector := labels.SelectorFromSet(labels.Set{
-			"app":          "traffic-manager",
-			"telepresence": "manager",
-		})
-		podList, err := podsAPI.List(ctx, meta.ListOptions{LabelSelector: selector.String()})
-		switch {
-		case err != nil:
-			err = fmt.Errorf("failed to gather logs for traffic manager in namespace %s: %w", ns, err)
+		pod, err := s.trafficManagerPod(ctx)
+		if err != nil {
 			dlog.Error(ctx, err)
 			resp.Error = err.Error()
-		case len(podList.Items) == 1:
-			pod := &podList.Items[0]
-			podAndNs := fmt.Sprintf("%s.%s", pod.Name, ns)
+		} else {
+			podAndNs := fmt.Sprintf("%s.%s", pod.Name, pod.Namespace)
 			dlog.Debugf(ctx, "gathering logs for %s, yaml = %t", podAndNs, request.GetPodYaml)
-			getPodLog(ctx, exportDir, &result, podsAPI, pod, "traffic-manager", request.GetPodYaml)
-		case len(podList.Items) > 1:
-			err = fmt.Errorf("multiple traffic managers found in namespace %s using selector %s", ns, selector.String())
-			dlog.Error(ctx, err)
-			resp.Error = err.Error()
-		default:
-			err := fmt.Errorf("no traffic manager found in namespace %s using selector %s", ns, selector.String())
-			dlog.Error(ctx, err)
-			resp.Error = err.Error()
+			getPodLog(ctx, exportDir, &result, coreAPI.Pods(pod.Namespace), pod, "traffic-manager", request.GetPodYaml)
 		}
 	}
 	pi := make(map[string]string)
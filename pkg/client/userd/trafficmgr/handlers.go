@@ -0,0 +1,99 @@
+package trafficmgr
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/forwarder"
+)
+
+// handlerSwitch routes the traffic of an intercept that has several local handlers to the active one. It's a local
+// forwarder that is the target of the intercept, so the handler can be switched without changing the intercept.
+type handlerSwitch struct {
+	sync.Mutex
+	fw       forwarder.Interceptor
+	cancel   context.CancelFunc
+	addr     *net.TCPAddr
+	handlers map[string]string // handler name -> host:port
+	active   string
+}
+
+// startHandlerSwitch starts a handlerSwitch that listens to a free port on localhost and forwards to the given
+// active handler. It serves until its close method is called.
+func startHandlerSwitch(ctx context.Context, handlers map[string]string, active string) (*handlerSwitch, error) {
+	host, port, err := handlerAddr(handlers, active)
+	if err != nil {
+		return nil, err
+	}
+	for name := range handlers {
+		if _, _, err = handlerAddr(handlers, name); err != nil {
+			return nil, err
+		}
+	}
+	hs := &handlerSwitch{
+		fw:       forwarder.NewInterceptor(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}, host, port),
+		handlers: handlers,
+		active:   active,
+	}
+	ctx, hs.cancel = context.WithCancel(ctx)
+	initCh := make(chan net.Addr)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- hs.fw.Serve(ctx, initCh)
+	}()
+	select {
+	case addr := <-initCh:
+		hs.addr = addr.(*net.TCPAddr)
+		dlog.Infof(ctx, "Routing intercepted traffic from %s to handler %s at %s:%d", hs.addr, active, host, port)
+		return hs, nil
+	case err = <-errCh:
+		hs.cancel()
+		return nil, fmt.Errorf("unable to listen for intercepted traffic: %w", err)
+	}
+}
+
+// handlerAddr returns the host and port of the given handler.
+func handlerAddr(handlers map[string]string, name string) (string, uint16, error) {
+	hp, ok := handlers[name]
+	if !ok {
+		names := make([]string, 0, len(handlers))
+		for n := range handlers {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return "", 0, errcat.User.Newf("unknown handler %q, the handlers are %s", name, strings.Join(names, ", "))
+	}
+	host, ps, err := net.SplitHostPort(hp)
+	if err == nil {
+		var p uint64
+		if p, err = strconv.ParseUint(ps, 10, 16); err == nil && p > 0 {
+			return host, uint16(p), nil
+		}
+	}
+	return "", 0, errcat.User.Newf("the address %q of handler %s is not in the form host:port", hp, name)
+}
+
+// switchTo makes the given handler the active one. Connections to the previously active handler are dropped, so
+// that clients reconnect to the new one.
+func (hs *handlerSwitch) switchTo(name string) error {
+	host, port, err := handlerAddr(hs.handlers, name)
+	if err != nil {
+		return err
+	}
+	hs.Lock()
+	hs.active = name
+	hs.fw.SetTarget(host, port)
+	hs.Unlock()
+	return nil
+}
+
+func (hs *handlerSwitch) close() {
+	hs.cancel()
+}
@@ -0,0 +1,62 @@
+package trafficmgr
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+)
+
+// greeter accepts connections and writes the given greeting to each one of them.
+func greeter(t *testing.T, greeting string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = fmt.Fprintln(conn, greeting)
+			_ = conn.Close()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestHandlerSwitch(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	handlers := map[string]string{
+		"blue":  greeter(t, "blue"),
+		"green": greeter(t, "green"),
+	}
+	greeting := func(hs *handlerSwitch) string {
+		conn, err := net.Dial("tcp", hs.addr.String())
+		require.NoError(t, err)
+		defer conn.Close()
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		require.NoError(t, err)
+		return line
+	}
+
+	hs, err := startHandlerSwitch(ctx, handlers, "blue")
+	require.NoError(t, err)
+	defer hs.close()
+	assert.True(t, hs.addr.IP.IsLoopback())
+	assert.Equal(t, "blue\n", greeting(hs))
+
+	require.NoError(t, hs.switchTo("green"))
+	assert.Equal(t, "green\n", greeting(hs))
+
+	assert.ErrorContains(t, hs.switchTo("red"), `unknown handler "red", the handlers are blue, green`)
+	assert.Equal(t, "green\n", greeting(hs))
+
+	_, err = startHandlerSwitch(ctx, map[string]string{"blue": "localhost"}, "blue")
+	assert.ErrorContains(t, err, "not in the form host:port")
+}
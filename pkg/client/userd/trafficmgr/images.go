@@ -0,0 +1,57 @@
+package trafficmgr
+
+import (
+	"context"
+	"strings"
+
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/imageverify"
+)
+
+// verifyTrafficManagerImages verifies that the images of the traffic-manager pods in the given namespace are
+// signed with one of the public keys in the given file. The digest of each image is taken from the status of
+// the container, so the verified image is the one that actually runs, even when the pod refers to a tag.
+func verifyTrafficManagerImages(ctx context.Context, namespace, keyFile string) error {
+	v, err := imageverify.LoadVerifier(keyFile)
+	if err != nil {
+		return errcat.Config.Newf("unable to load images.verifyKey: %v", err)
+	}
+	pods, err := k8sapi.GetK8sInterface(ctx).CoreV1().Pods(namespace).List(ctx, meta.ListOptions{
+		LabelSelector: "app=traffic-manager",
+	})
+	if err != nil {
+		return err
+	}
+	verified := 0
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		for j := range pod.Status.ContainerStatuses {
+			img := runningImage(&pod.Status.ContainerStatuses[j])
+			if _, err = v.Verify(ctx, img); err != nil {
+				return errcat.User.Newf("traffic-manager pod %s.%s: %v", pod.Name, namespace, err)
+			}
+			verified++
+		}
+	}
+	if verified == 0 {
+		return errcat.User.Newf("unable to verify the traffic-manager images, because no traffic-manager pod is running in namespace %s", namespace)
+	}
+	dlog.Debugf(ctx, "verified the signatures of %d traffic-manager container images", verified)
+	return nil
+}
+
+// runningImage returns the image of the given container status, pinned to the digest found in its image ID when
+// the ID has one, as in "docker.io/datawire/tel2@sha256:0123...".
+func runningImage(cs *core.ContainerStatus) string {
+	if i := strings.Index(cs.ImageID, "@sha256:"); i >= 0 {
+		if ref, err := imageverify.ParseReference(cs.Image); err == nil {
+			return ref.WithDigest(cs.ImageID[i+1:]).String()
+		}
+	}
+	return cs.Image
+}
@@ -0,0 +1,38 @@
+package trafficmgr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	core "k8s.io/api/core/v1"
+)
+
+func Test_runningImage(t *testing.T) {
+	const dg = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name string
+		cs   core.ContainerStatus
+		want string
+	}{
+		{
+			"docker",
+			core.ContainerStatus{Image: "datawire/tel2:2.14.0", ImageID: "docker-pullable://datawire/tel2@" + dg},
+			"docker.io/datawire/tel2:2.14.0@" + dg,
+		},
+		{
+			"containerd",
+			core.ContainerStatus{Image: "ghcr.io/org/tel2:2.14.0", ImageID: "ghcr.io/org/tel2@" + dg},
+			"ghcr.io/org/tel2:2.14.0@" + dg,
+		},
+		{
+			"no digest",
+			core.ContainerStatus{Image: "ghcr.io/org/tel2:2.14.0", ImageID: dg},
+			"ghcr.io/org/tel2:2.14.0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, runningImage(&tt.cs))
+		})
+	}
+}
@@ -40,6 +40,7 @@ import (
 	"github.com/telepresenceio/telepresence/v2/pkg/matcher"
 	"github.com/telepresenceio/telepresence/v2/pkg/proc"
 	"github.com/telepresenceio/telepresence/v2/pkg/restapi"
+	"github.com/telepresenceio/telepresence/v2/pkg/slice"
 	"github.com/telepresenceio/telepresence/v2/pkg/tracing"
 )
 
@@ -49,6 +50,9 @@ type intercept struct {
 	sync.Mutex
 	*manager.InterceptInfo
 
+	// seq is the order in which the intercept was added to the session's current intercepts.
+	seq uint64
+
 	// ctx is a context cancelled by the cancel attribute. It must be used by
 	// services that should be cancelled when the intercept ends
 	ctx context.Context
@@ -75,6 +79,17 @@ type intercept struct {
 
 	// Use bridged ftp/sftp mount through this local port
 	localMountPort int32
+
+	// Loopback address on which the intercepted service's NodePort and LoadBalancer ports are exposed
+	nodePortAddress string
+
+	// Routes the intercepted traffic to the active one of several local handlers
+	handlers *handlerSwitch
+
+	// Files that the client wrote the intercept's environment to, and that are updated when the
+	// ConfigMaps and Secrets that the environment is taken from change
+	envFile string
+	envJSON string
 }
 
 // interceptResult is what gets written to the awaitIntercept's waitCh channel when the
@@ -95,6 +110,18 @@ type awaitIntercept struct {
 	// the mount to take place in a host
 	mountPort int32
 
+	// nodePortAddress is optional and indicates that the NodePort and LoadBalancer ports of the
+	// intercepted service should be exposed on this local address
+	nodePortAddress string
+
+	// handlers is optional and is the switch that is the target of an intercept with several local handlers
+	handlers *handlerSwitch
+
+	// envFile and envJSON are optional, and are the files that the client writes the intercept's
+	// environment to
+	envFile string
+	envJSON string
+
 	waitCh chan<- interceptResult
 }
 
@@ -357,12 +384,29 @@ func (s *session) setCurrentIntercepts(ctx context.Context, iis []*manager.Inter
 			ii.ClientMountPoint = ic.ClientMountPoint
 			ic.InterceptInfo = ii
 		} else {
-			ic = &intercept{InterceptInfo: ii}
+			s.interceptSeq++
+			ic = &intercept{InterceptInfo: ii, seq: s.interceptSeq}
 			ic.ctx, ic.cancel = context.WithCancel(ctx)
 			dlog.Debugf(ctx, "Received new intercept %s", ic.Spec.Name)
 			if aw, ok := s.interceptWaiters[ii.Spec.Name]; ok {
 				ic.ClientMountPoint = aw.mountPoint
 				ic.localMountPort = aw.mountPort
+				if aw.nodePortAddress != "" {
+					ic.nodePortAddress = aw.nodePortAddress
+					ic.exposeNodePorts(ic.ctx)
+				}
+				if aw.handlers != nil {
+					ic.handlers = aw.handlers
+					go func() {
+						<-ic.ctx.Done()
+						ic.handlers.close()
+					}()
+				}
+				if aw.envFile != "" || aw.envJSON != "" {
+					ic.envFile = aw.envFile
+					ic.envJSON = aw.envJSON
+					s.watchInterceptConfig(ic.ctx, ic)
+				}
 			}
 		}
 		intercepts[ii.Id] = ic
@@ -375,14 +419,28 @@ func (s *session) setCurrentIntercepts(ctx context.Context, iis []*manager.Inter
 	}
 	sb.WriteByte(']')
 	dlog.Debugf(ctx, "setCurrentIntercepts(%s)", sb.String())
+	ics := make([]*intercept, 0, len(intercepts))
+	for _, ic := range intercepts {
+		ics = append(ics, ic)
+	}
+	s.resources.shedEnvs(ics)
 
 	// Cancel those that no longer exists
 	for id, ic := range s.currentIntercepts {
 		if _, ok := intercepts[id]; !ok {
 			dlog.Debugf(ctx, "Cancelling context for intercept %s", ic.Spec.Name)
 			ic.cancel()
+			if ea := ic.ExpiresAt; ea != nil && time.Until(ea.AsTime()) < time.Minute {
+				// The traffic-manager removed the intercept because it expired (allowing for some
+				// clock skew). Terminate its handler, so that the CLI that runs it learns about it.
+				dlog.Infof(ctx, "Intercept %s expired at %s", ic.Spec.Name, ea.AsTime().Local().Format(time.RFC3339))
+				go terminateInterceptor(ctx, ic.Spec.Name, ic.pid, ic.containerName)
+			}
 		}
 	}
+	if len(intercepts) != len(s.currentIntercepts) {
+		s.savePromptInfo(ctx, len(intercepts))
+	}
 	s.currentIntercepts = intercepts
 	s.reconcileAPIServers(ctx)
 }
@@ -395,6 +453,16 @@ func InterceptError(tp common.InterceptError, err error) *rpc.InterceptResult {
 	}
 }
 
+// managerInterceptError converts an error returned by the traffic-manager into an InterceptResult.
+// A PermissionDenied error means that the intercept violates the policy of the traffic-manager, and
+// its message tells the user how to remedy that.
+func managerInterceptError(err error) *rpc.InterceptResult {
+	if st, ok := grpcStatus.FromError(err); ok && st.Code() == grpcCodes.PermissionDenied {
+		return InterceptError(common.InterceptError_POLICY_VIOLATION, errcat.User.New(st.Message()))
+	}
+	return InterceptError(common.InterceptError_TRAFFIC_MANAGER_ERROR, err)
+}
+
 type interceptInfo struct {
 	// Information provided by the traffic manager as response to the PrepareIntercept call
 	preparedIntercept *manager.PreparedIntercept
@@ -478,6 +546,10 @@ func (s *session) CanIntercept(c context.Context, ir *rpc.CreateInterceptRequest
 		return nil, InterceptError(common.InterceptError_NAMESPACE_AMBIGUITY, errcat.User.Newf("%s,%s", s.Namespace, spec.Namespace))
 	}
 
+	if s.observe {
+		return nil, InterceptError(common.InterceptError_OBSERVE_ONLY, errcat.User.New(s.daemonID.Name))
+	}
+
 	self := s.self
 	if er := s.ensureNoInterceptConflict(ir); er != nil {
 		return nil, er
@@ -498,12 +570,18 @@ func (s *session) CanIntercept(c context.Context, ir *rpc.CreateInterceptRequest
 	if er := self.InterceptProlog(c, mgrIr); er != nil {
 		return nil, er
 	}
-	pi, err := s.managerClient.PrepareIntercept(c, mgrIr)
+	start := time.Now()
+	var pi *manager.PreparedIntercept
+	err := client.GetConfig(c).Retries().Policy().Do(c, "agent install", func(c context.Context) (err error) {
+		pi, err = s.managerClient.PrepareIntercept(c, mgrIr)
+		return err
+	})
 	if err != nil {
-		return nil, InterceptError(common.InterceptError_TRAFFIC_MANAGER_ERROR, err)
+		return nil, managerInterceptError(err)
 	}
 	if pi.Error != "" {
-		return nil, InterceptError(common.InterceptError_TRAFFIC_MANAGER_ERROR, errcat.Category(pi.ErrorCategory).Newf(pi.Error))
+		err = withWorkloadProblems(c, errcat.Category(pi.ErrorCategory).Newf(pi.Error), spec.Agent, spec.Namespace, start)
+		return nil, InterceptError(common.InterceptError_TRAFFIC_MANAGER_ERROR, err)
 	}
 
 	iInfo := &interceptInfo{preparedIntercept: pi}
@@ -564,8 +642,26 @@ func (s *session) NewCreateInterceptRequest(spec *manager.InterceptSpec) *manage
 	}
 }
 
-// AddIntercept adds one intercept.
+// AddIntercept adds one intercept. An intercept with handlers targets a handlerSwitch that routes its traffic to
+// the active handler.
 func (s *session) AddIntercept(c context.Context, ir *rpc.CreateInterceptRequest) *rpc.InterceptResult {
+	if len(ir.Handlers) == 0 {
+		return s.addIntercept(c, ir, nil)
+	}
+	hs, err := startHandlerSwitch(dcontext.WithoutCancel(c), ir.Handlers, ir.ActiveHandler)
+	if err != nil {
+		return InterceptError(common.InterceptError_INTERNAL, err)
+	}
+	ir.Spec.TargetHost = hs.addr.IP.String()
+	ir.Spec.TargetPort = int32(hs.addr.Port)
+	result := s.addIntercept(c, ir, hs)
+	if result.Error != common.InterceptError_UNSPECIFIED || result.InterceptInfo == nil {
+		hs.close()
+	}
+	return result
+}
+
+func (s *session) addIntercept(c context.Context, ir *rpc.CreateInterceptRequest, hs *handlerSwitch) *rpc.InterceptResult {
 	self := s.self
 	iInfo, result := self.CanIntercept(c, ir)
 	if result != nil {
@@ -622,10 +718,18 @@ func (s *session) AddIntercept(c context.Context, ir *rpc.CreateInterceptRequest
 	spec.ServiceUid = result.ServiceUid
 	spec.WorkloadKind = result.WorkloadKind
 
+	if ir.TakeOver {
+		// Waiting for the owners of conflicting intercepts to answer isn't part of the intercept timeout.
+		if result := s.takeOver(c, spec); result != nil {
+			return result
+		}
+	}
+
 	dlog.Debugf(c, "creating intercept %s", spec.Name)
 	tos := client.GetConfig(c).Timeouts()
 	spec.RoundtripLatency = int64(tos.Get(client.TimeoutRoundtripLatency)) * 2 // Account for extra hop
 	spec.DialTimeout = int64(tos.Get(client.TimeoutEndpointDial))
+	start := time.Now()
 	c, cancel := tos.TimeoutContext(c, client.TimeoutIntercept)
 	defer cancel()
 
@@ -634,9 +738,13 @@ func (s *session) AddIntercept(c context.Context, ir *rpc.CreateInterceptRequest
 	waitCh := make(chan interceptResult, 2) // Need a buffer because reply can come before we're reading the channel,
 	s.currentInterceptsLock.Lock()
 	s.interceptWaiters[spec.Name] = &awaitIntercept{
-		mountPoint: ir.MountPoint,
-		mountPort:  ir.LocalMountPort,
-		waitCh:     waitCh,
+		mountPoint:      ir.MountPoint,
+		mountPort:       ir.LocalMountPort,
+		nodePortAddress: ir.NodePortAddress,
+		handlers:        hs,
+		envFile:         ir.EnvFile,
+		envJSON:         ir.EnvJson,
+		waitCh:          waitCh,
 	}
 	s.currentInterceptsLock.Unlock()
 	defer func() {
@@ -651,7 +759,7 @@ func (s *session) AddIntercept(c context.Context, ir *rpc.CreateInterceptRequest
 	ii, err := mgrClient.CreateIntercept(c, self.NewCreateInterceptRequest(spec))
 	if err != nil {
 		dlog.Debugf(c, "manager responded to CreateIntercept with error %v", err)
-		return InterceptError(common.InterceptError_TRAFFIC_MANAGER_ERROR, err)
+		return managerInterceptError(err)
 	}
 
 	dlog.Debugf(c, "created intercept %s", ii.Spec.Name)
@@ -676,10 +784,11 @@ func (s *session) AddIntercept(c context.Context, ir *rpc.CreateInterceptRequest
 	for {
 		select {
 		case <-c.Done():
-			return InterceptError(common.InterceptError_FAILED_TO_ESTABLISH, client.CheckTimeout(c, c.Err()))
+			err = withWorkloadProblems(c, client.CheckTimeout(c, c.Err()), spec.Agent, spec.Namespace, start)
+			return InterceptError(common.InterceptError_FAILED_TO_ESTABLISH, err)
 		case wr := <-waitCh:
 			if wr.err != nil {
-				return InterceptError(common.InterceptError_FAILED_TO_ESTABLISH, wr.err)
+				return InterceptError(common.InterceptError_FAILED_TO_ESTABLISH, withWorkloadProblems(c, wr.err, spec.Agent, spec.Namespace, start))
 			}
 			ic := wr.intercept
 			ii = ic.InterceptInfo
@@ -691,7 +800,8 @@ func (s *session) AddIntercept(c context.Context, ir *rpc.CreateInterceptRequest
 				ii.Environment = agentEnv
 			}
 			result.InterceptInfo = ii
-			if !waitForDNS(c, spec.ServiceName) {
+			// The name of a service in a federated cluster is only known by the federated traffic-manager.
+			if spec.Cluster == "" && !waitForDNS(c, spec.ServiceName) {
 				dlog.Warningf(c, "DNS cannot resolve name of intercepted %q service", spec.ServiceName)
 			}
 			if er := self.InterceptEpilog(c, ir, result); er != nil {
@@ -739,26 +849,7 @@ func (s *session) RemoveIntercept(c context.Context, name string) error {
 
 func (s *session) removeIntercept(c context.Context, ic *intercept) error {
 	name := ic.Spec.Name
-
-	// No use trying to kill processes when using a container based daemon, unless
-	// that container based daemon runs as a normal user daemon with separate root daemon.
-	// Some users run a standard telepresence client together with intercepts in one
-	// single container.
-	if !(proc.RunningInContainer() && userd.GetService(c).RootSessionInProcess()) {
-		if ic.containerName != "" {
-			if err := docker.StopContainer(docker.EnableClient(c), ic.containerName); err != nil {
-				dlog.Error(c, err)
-			}
-		} else if ic.pid != 0 {
-			p, err := os.FindProcess(ic.pid)
-			if err != nil {
-				dlog.Errorf(c, "unable to find interceptor for intercept %s with pid %d", name, ic.pid)
-			} else {
-				dlog.Debugf(c, "terminating interceptor for intercept %s with pid %d", name, ic.pid)
-				_ = proc.Terminate(p)
-			}
-		}
-	}
+	terminateInterceptor(c, name, ic.pid, ic.containerName)
 
 	// Unmount filesystems before telling the manager to remove the intercept
 	ic.cancel()
@@ -774,6 +865,30 @@ func (s *session) removeIntercept(c context.Context, ic *intercept) error {
 	return err
 }
 
+// terminateInterceptor terminates the process or container that handles the given intercept.
+func terminateInterceptor(c context.Context, name string, pid int, containerName string) {
+	// No use trying to kill processes when using a container based daemon, unless
+	// that container based daemon runs as a normal user daemon with separate root daemon.
+	// Some users run a standard telepresence client together with intercepts in one
+	// single container.
+	if proc.RunningInContainer() && userd.GetService(c).RootSessionInProcess() {
+		return
+	}
+	if containerName != "" {
+		if err := docker.StopContainer(docker.EnableClient(c), containerName); err != nil {
+			dlog.Error(c, err)
+		}
+	} else if pid != 0 {
+		p, err := os.FindProcess(pid)
+		if err != nil {
+			dlog.Errorf(c, "unable to find interceptor for intercept %s with pid %d", name, pid)
+		} else {
+			dlog.Debugf(c, "terminating interceptor for intercept %s with pid %d", name, pid)
+			_ = proc.Terminate(p)
+		}
+	}
+}
+
 // AddInterceptor associates the given intercept with a running process. This ensures that
 // the running process will be signalled when the intercept is removed.
 func (s *session) AddInterceptor(id string, ih *rpc.Interceptor) error {
@@ -796,6 +911,22 @@ func (s *session) RemoveInterceptor(id string) error {
 	return nil
 }
 
+// SwitchInterceptHandler routes the traffic of the intercept with the given name to another one of its handlers.
+func (s *session) SwitchInterceptHandler(c context.Context, name, handler string) error {
+	ic := s.getInterceptByName(name)
+	if ic == nil {
+		return errcat.User.Newf("intercept named %q not found", name)
+	}
+	if ic.handlers == nil {
+		return errcat.User.Newf("intercept %s was not created with handlers", name)
+	}
+	if err := ic.handlers.switchTo(handler); err != nil {
+		return err
+	}
+	dlog.Infof(c, "Intercept %s now routes to handler %s", name, handler)
+	return nil
+}
+
 // GetInterceptSpec returns the InterceptSpec for the given name, or nil if no such spec exists.
 func (s *session) GetInterceptSpec(name string) *manager.InterceptSpec {
 	if ic := s.getInterceptByName(name); ic != nil {
@@ -936,6 +1067,21 @@ func (s *session) newMatcher(ctx context.Context, ic *manager.InterceptInfo) {
 	}
 }
 
+// PropagatedHeaderNames implements restapi.HeaderPropagator.
+func (s *session) PropagatedHeaderNames(context.Context) []string {
+	s.currentInterceptsLock.Lock()
+	defer s.currentInterceptsLock.Unlock()
+	var names []string
+	for _, ic := range s.currentIntercepts {
+		for n := range ic.Headers {
+			if !slice.Contains(names, n) {
+				names = append(names, n)
+			}
+		}
+	}
+	return names
+}
+
 func (s *session) InterceptInfo(ctx context.Context, callerID, path string, _ uint16, headers http.Header) (*restapi.InterceptInfo, error) {
 	s.currentInterceptsLock.Lock()
 	defer s.currentInterceptsLock.Unlock()
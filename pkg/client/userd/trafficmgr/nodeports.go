@@ -0,0 +1,102 @@
+package trafficmgr
+
+import (
+	"context"
+	"net"
+
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+	"github.com/telepresenceio/telepresence/v2/pkg/forwarder"
+)
+
+// exposeNodePorts makes the local target of the intercept reachable on the intercept's nodePortAddress, using the
+// NodePort and LoadBalancer port numbers that the intercepted service port has in the cluster. Clients that are
+// configured with those port numbers will then reach the local handler unchanged. The listeners are closed when
+// the intercept ends.
+func (ic *intercept) exposeNodePorts(ctx context.Context) {
+	spec := ic.Spec
+	ic.wg.Add(1)
+	go func() {
+		defer ic.wg.Done()
+		svc, err := k8sapi.GetK8sInterface(ctx).CoreV1().Services(spec.Namespace).Get(ctx, spec.ServiceName, meta.GetOptions{})
+		if err != nil {
+			dlog.Errorf(ctx, "unable to expose node ports for intercept %s: %v", spec.Name, err)
+			return
+		}
+		ports := nodePorts(svc, spec)
+		if len(ports) == 0 {
+			dlog.Warnf(ctx, "service %s.%s has no NodePort or LoadBalancer port to expose for intercept %s", svc.Name, svc.Namespace, spec.Name)
+			return
+		}
+		for _, port := range ports {
+			if port == uint16(spec.TargetPort) && sameIP(ic.nodePortAddress, spec.TargetHost) {
+				// The local handler is already listening to this address and port.
+				continue
+			}
+			addr := &net.TCPAddr{IP: net.ParseIP(ic.nodePortAddress), Port: int(port)}
+			fw := forwarder.NewInterceptor(addr, spec.TargetHost, uint16(spec.TargetPort))
+			ic.wg.Add(1)
+			go func() {
+				defer ic.wg.Done()
+				dlog.Infof(ctx, "Exposing %s for intercept %s", addr, spec.Name)
+				if err := fw.Serve(ctx, nil); err != nil {
+					dlog.Errorf(ctx, "unable to expose %s for intercept %s: %v", addr, spec.Name, err)
+				}
+			}()
+		}
+	}()
+}
+
+// nodePorts returns the NodePort and LoadBalancer port numbers of the service port that is targeted by the
+// given intercept spec.
+func nodePorts(svc *core.Service, spec *manager.InterceptSpec) []uint16 {
+	if svc.Spec.Type != core.ServiceTypeNodePort && svc.Spec.Type != core.ServiceTypeLoadBalancer {
+		return nil
+	}
+	var ports []uint16
+	for _, sp := range svc.Spec.Ports {
+		if sp.Protocol != "" && sp.Protocol != core.ProtocolTCP {
+			continue
+		}
+		if !isInterceptedServicePort(&sp, spec) {
+			continue
+		}
+		if sp.NodePort != 0 {
+			ports = append(ports, uint16(sp.NodePort))
+		}
+		if svc.Spec.Type == core.ServiceTypeLoadBalancer && sp.Port != sp.NodePort {
+			ports = append(ports, uint16(sp.Port))
+		}
+	}
+	return ports
+}
+
+// isInterceptedServicePort returns true if the given service port is the one targeted by the intercept spec. The
+// resolved service port name or number is used when present. Otherwise, the service port identifier is resolved,
+// and it may then also refer to the port by the name or number of its targetPort.
+func isInterceptedServicePort(sp *core.ServicePort, spec *manager.InterceptSpec) bool {
+	switch {
+	case spec.ServicePortName != "":
+		return sp.Name == spec.ServicePortName
+	case spec.ServicePort != 0:
+		return sp.Port == spec.ServicePort
+	}
+	_, name, num := agentconfig.PortIdentifier(spec.ServicePortIdentifier).ProtoAndNameOrNumber()
+	tp := sp.TargetPort
+	if name != "" {
+		return sp.Name == name || tp.Type == intstr.String && tp.StrVal == name
+	}
+	return sp.Port == int32(num) || tp.Type == intstr.Int && tp.IntVal == int32(num)
+}
+
+// sameIP returns true if the given addresses are equal IP addresses.
+func sameIP(a, b string) bool {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	return ipA != nil && ipA.Equal(ipB)
+}
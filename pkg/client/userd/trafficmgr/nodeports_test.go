@@ -0,0 +1,50 @@
+package trafficmgr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+func TestNodePorts(t *testing.T) {
+	svc := func(st core.ServiceType) *core.Service {
+		return &core.Service{Spec: core.ServiceSpec{
+			Type: st,
+			Ports: []core.ServicePort{
+				{Name: "http", Protocol: core.ProtocolTCP, Port: 80, NodePort: 30080},
+				{Name: "dns", Protocol: core.ProtocolUDP, Port: 53, NodePort: 30053},
+			},
+		}}
+	}
+	byName := &manager.InterceptSpec{ServicePortName: "http"}
+	byNumber := &manager.InterceptSpec{ServicePort: 80}
+	assert.Empty(t, nodePorts(svc(core.ServiceTypeClusterIP), byName))
+	assert.Equal(t, []uint16{30080}, nodePorts(svc(core.ServiceTypeNodePort), byName))
+	assert.Equal(t, []uint16{30080, 80}, nodePorts(svc(core.ServiceTypeLoadBalancer), byNumber))
+	assert.Empty(t, nodePorts(svc(core.ServiceTypeNodePort), &manager.InterceptSpec{ServicePortName: "dns"}))
+}
+
+func TestNodePortsTargetPortAlias(t *testing.T) {
+	svc := &core.Service{Spec: core.ServiceSpec{
+		Type: core.ServiceTypeNodePort,
+		Ports: []core.ServicePort{
+			{Name: "web", Protocol: core.ProtocolTCP, Port: 80, NodePort: 30080, TargetPort: intstr.FromString("http")},
+			{Name: "admin", Protocol: core.ProtocolTCP, Port: 9000, NodePort: 30090, TargetPort: intstr.FromInt(9090)},
+		},
+	}}
+	assert.Equal(t, []uint16{30080}, nodePorts(svc, &manager.InterceptSpec{ServicePortIdentifier: "http"}))
+	assert.Equal(t, []uint16{30080}, nodePorts(svc, &manager.InterceptSpec{ServicePortIdentifier: "web"}))
+	assert.Equal(t, []uint16{30090}, nodePorts(svc, &manager.InterceptSpec{ServicePortIdentifier: "9090"}))
+	assert.Equal(t, []uint16{30090}, nodePorts(svc, &manager.InterceptSpec{ServicePortIdentifier: "9000/TCP"}))
+	assert.Empty(t, nodePorts(svc, &manager.InterceptSpec{ServicePortIdentifier: "grpc"}))
+}
+
+func TestSameIP(t *testing.T) {
+	assert.True(t, sameIP("127.0.0.1", "127.0.0.1"))
+	assert.False(t, sameIP("127.0.0.2", "127.0.0.1"))
+	assert.False(t, sameIP("", ""))
+}
@@ -0,0 +1,104 @@
+package trafficmgr
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"sync/atomic"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+)
+
+const (
+	// defaultMaxCachedEnvs is the number of intercepts that retain their environment when no limit is configured.
+	defaultMaxCachedEnvs = 32
+
+	// defaultMaxTunnels is the number of concurrent connections from intercepted containers when no limit is
+	// configured.
+	defaultMaxTunnels = 512
+
+	// memoryPressureRatio is how close to the memory limit the daemon's memory usage can get before it's reported
+	// as a warning.
+	memoryPressureRatio = 0.9
+)
+
+// resourceLimits keeps track of the limits that the user daemon imposes on itself, and of the load that it sheds
+// when it reaches them.
+type resourceLimits struct {
+	maxCachedEnvs int
+	maxTunnels    int32
+
+	tunnels         atomic.Int32
+	rejectedTunnels atomic.Uint64
+	droppedEnvs     atomic.Int32
+}
+
+// newResourceLimits returns the limits of the given configuration. Zero means the default, and a negative value
+// means no limit.
+func newResourceLimits(cfg *client.UserDaemon) *resourceLimits {
+	rl := &resourceLimits{
+		maxCachedEnvs: cfg.MaxCachedEnvs,
+		maxTunnels:    int32(cfg.MaxTunnels),
+	}
+	if rl.maxCachedEnvs == 0 {
+		rl.maxCachedEnvs = defaultMaxCachedEnvs
+	}
+	if rl.maxTunnels == 0 {
+		rl.maxTunnels = defaultMaxTunnels
+	}
+	return rl
+}
+
+// acquireTunnel returns false when the maximum number of concurrent tunnels has been reached. Otherwise, the
+// returned function must be called when the tunnel ends.
+func (rl *resourceLimits) acquireTunnel() (func(), bool) {
+	if n := rl.tunnels.Add(1); rl.maxTunnels > 0 && n > rl.maxTunnels {
+		rl.tunnels.Add(-1)
+		rl.rejectedTunnels.Add(1)
+		return nil, false
+	}
+	return func() { rl.tunnels.Add(-1) }, true
+}
+
+// shedEnvs drops the environments of all but the maxCachedEnvs most recent of the given intercepts. The port of
+// the Telepresence API is retained, because the API servers depend on it.
+func (rl *resourceLimits) shedEnvs(ics []*intercept) {
+	dropped := 0
+	if rl.maxCachedEnvs >= 0 && len(ics) > rl.maxCachedEnvs {
+		sort.Slice(ics, func(i, j int) bool { return ics[i].seq > ics[j].seq })
+		for _, ic := range ics[rl.maxCachedEnvs:] {
+			env := ic.Environment
+			if len(env) == 0 {
+				continue
+			}
+			ic.Environment = nil
+			if port, ok := env[agentconfig.EnvAPIPort]; ok {
+				ic.Environment = map[string]string{agentconfig.EnvAPIPort: port}
+			}
+			dropped++
+		}
+	}
+	rl.droppedEnvs.Store(int32(dropped))
+}
+
+// warnings returns a warning for each limit that has been reached.
+func (rl *resourceLimits) warnings() []string {
+	var ws []string
+	if n := rl.droppedEnvs.Load(); n > 0 {
+		ws = append(ws, fmt.Sprintf("the environments of %d intercepts are dropped, because only %d are retained", n, rl.maxCachedEnvs))
+	}
+	if n := rl.rejectedTunnels.Load(); n > 0 {
+		ws = append(ws, fmt.Sprintf("%d connections from intercepted containers were rejected, because only %d can be open at a time", n, rl.maxTunnels))
+	}
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		if used := ms.Sys - ms.HeapReleased; float64(used) > memoryPressureRatio*float64(limit) {
+			ws = append(ws, fmt.Sprintf("memory usage of %d MiB is close to the limit of %d MiB", used>>20, limit>>20))
+		}
+	}
+	return ws
+}
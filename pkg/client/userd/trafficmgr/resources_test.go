@@ -0,0 +1,56 @@
+package trafficmgr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+)
+
+func TestResourceLimits_acquireTunnel(t *testing.T) {
+	rl := newResourceLimits(&client.UserDaemon{MaxTunnels: 2})
+	r1, ok := rl.acquireTunnel()
+	require.True(t, ok)
+	_, ok = rl.acquireTunnel()
+	require.True(t, ok)
+	_, ok = rl.acquireTunnel()
+	assert.False(t, ok)
+	r1()
+	_, ok = rl.acquireTunnel()
+	assert.True(t, ok)
+	require.Len(t, rl.warnings(), 1)
+	assert.Contains(t, rl.warnings()[0], "1 connections from intercepted containers were rejected")
+
+	rl = newResourceLimits(&client.UserDaemon{MaxTunnels: -1})
+	for i := 0; i < defaultMaxTunnels+1; i++ {
+		_, ok = rl.acquireTunnel()
+		require.True(t, ok)
+	}
+	assert.Empty(t, rl.warnings())
+}
+
+func TestResourceLimits_shedEnvs(t *testing.T) {
+	newIntercept := func(seq uint64, env map[string]string) *intercept {
+		return &intercept{InterceptInfo: &manager.InterceptInfo{Environment: env}, seq: seq}
+	}
+	ics := []*intercept{
+		newIntercept(1, map[string]string{"A": "1", agentconfig.EnvAPIPort: "9980"}),
+		newIntercept(3, map[string]string{"A": "3"}),
+		newIntercept(2, map[string]string{"A": "2"}),
+	}
+	rl := newResourceLimits(&client.UserDaemon{MaxCachedEnvs: 1})
+	rl.shedEnvs(ics)
+	assert.Equal(t, map[string]string{"A": "3"}, ics[0].Environment)
+	assert.Nil(t, ics[1].Environment)
+	assert.Equal(t, map[string]string{agentconfig.EnvAPIPort: "9980"}, ics[2].Environment)
+	require.Len(t, rl.warnings(), 1)
+	assert.Contains(t, rl.warnings()[0], "the environments of 2 intercepts are dropped")
+
+	// The warning goes away when the intercepts do.
+	rl.shedEnvs(ics[:1])
+	assert.Empty(t, rl.warnings())
+}
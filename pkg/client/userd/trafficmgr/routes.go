@@ -0,0 +1,291 @@
+package trafficmgr
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+
+	networking "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/connector"
+)
+
+//nolint:gochecknoglobals // constant
+var (
+	httpRouteResource = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1beta1", Resource: "httproutes"}
+	gatewayResource   = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1beta1", Resource: "gateways"}
+)
+
+// httpRoute contains the parts of a Gateway API HTTPRoute that are needed to compute its URLs.
+type httpRoute struct {
+	meta.ObjectMeta `json:"metadata"`
+	Spec            struct {
+		ParentRefs []struct {
+			Name        string `json:"name"`
+			Namespace   string `json:"namespace"`
+			SectionName string `json:"sectionName"`
+		} `json:"parentRefs"`
+		Hostnames []string `json:"hostnames"`
+		Rules     []struct {
+			Matches []struct {
+				Path *struct {
+					Value string `json:"value"`
+				} `json:"path"`
+			} `json:"matches"`
+			BackendRefs []struct {
+				Kind      string `json:"kind"`
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"backendRefs"`
+		} `json:"rules"`
+	} `json:"spec"`
+}
+
+// gateway contains the parts of a Gateway API Gateway that are needed to compute the URLs of its routes.
+type gateway struct {
+	meta.ObjectMeta `json:"metadata"`
+	Spec            struct {
+		Listeners []struct {
+			Name     string `json:"name"`
+			Hostname string `json:"hostname"`
+			Port     int32  `json:"port"`
+			Protocol string `json:"protocol"`
+		} `json:"listeners"`
+	} `json:"spec"`
+	Status struct {
+		Addresses []struct {
+			Value string `json:"value"`
+		} `json:"addresses"`
+	} `json:"status"`
+}
+
+// serviceKey identifies a service by namespace and name.
+type serviceKey struct {
+	namespace string
+	name      string
+}
+
+type routeMap map[serviceKey][]*rpc.WorkloadInfo_ServiceReference_Route
+
+func (rm routeMap) add(namespace, name, kind, routeName, url string) {
+	k := serviceKey{namespace: namespace, name: name}
+	for _, r := range rm[k] {
+		if r.Url == url {
+			return
+		}
+	}
+	rm[k] = append(rm[k], &rpc.WorkloadInfo_ServiceReference_Route{Kind: kind, Name: routeName, Url: url})
+}
+
+// addRoutes assigns the Ingress and HTTPRoute routes that lead to each service referenced by the given
+// workloads. Routing resources that can't be listed, e.g. because the Gateway API CRDs aren't installed or
+// because the user lacks permissions, are silently ignored.
+func (s *session) addRoutes(ctx context.Context, wis []*rpc.WorkloadInfo) {
+	nss := make(map[string]struct{})
+	for _, wi := range wis {
+		for _, svc := range wi.Services {
+			nss[svc.Namespace] = struct{}{}
+		}
+	}
+	if len(nss) == 0 {
+		return
+	}
+	dc, err := dynamic.NewForConfig(s.GetRestConfig())
+	if err != nil {
+		dlog.Debugf(ctx, "unable to create dynamic client: %v", err)
+	}
+	rm := make(routeMap)
+	for ns := range nss {
+		s.addIngressRoutes(ctx, ns, rm)
+		if dc != nil {
+			addHTTPRoutes(ctx, dc, ns, rm)
+		}
+	}
+	for _, wi := range wis {
+		for _, svc := range wi.Services {
+			svc.Routes = rm[serviceKey{namespace: svc.Namespace, name: svc.Name}]
+		}
+	}
+}
+
+func (s *session) addIngressRoutes(ctx context.Context, ns string, rm routeMap) {
+	il, err := k8sapi.GetK8sInterface(ctx).NetworkingV1().Ingresses(ns).List(ctx, meta.ListOptions{})
+	if err != nil {
+		dlog.Debugf(ctx, "unable to list ingresses in namespace %s: %v", ns, err)
+		return
+	}
+	for i := range il.Items {
+		ingressRoutes(&il.Items[i], rm)
+	}
+}
+
+// ingressRoutes adds the URLs of each backend service of the given Ingress to the given map.
+func ingressRoutes(ing *networking.Ingress, rm routeMap) {
+	tlsHosts := make(map[string]bool)
+	tlsAll := false
+	for _, tls := range ing.Spec.TLS {
+		if len(tls.Hosts) == 0 {
+			// A TLS entry without hosts applies to the address of the load balancer
+			tlsAll = true
+		}
+		for _, h := range tls.Hosts {
+			tlsHosts[h] = true
+		}
+	}
+	address := ""
+	for _, lb := range ing.Status.LoadBalancer.Ingress {
+		if lb.Hostname != "" {
+			address = lb.Hostname
+			break
+		}
+		if lb.IP != "" {
+			address = lb.IP
+			break
+		}
+	}
+	url := func(host, path string) string {
+		scheme := "http"
+		if tlsAll || tlsHosts[host] {
+			scheme = "https"
+		}
+		if host == "" {
+			if host = address; host == "" {
+				host = "<pending>"
+			}
+		}
+		if path == "" {
+			path = "/"
+		}
+		return scheme + "://" + host + path
+	}
+	if db := ing.Spec.DefaultBackend; db != nil && db.Service != nil {
+		rm.add(ing.Namespace, db.Service.Name, "Ingress", ing.Name, url("", "/"))
+	}
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, p := range rule.HTTP.Paths {
+			if p.Backend.Service != nil {
+				rm.add(ing.Namespace, p.Backend.Service.Name, "Ingress", ing.Name, url(rule.Host, p.Path))
+			}
+		}
+	}
+}
+
+func addHTTPRoutes(ctx context.Context, dc dynamic.Interface, ns string, rm routeMap) {
+	ul, err := dc.Resource(httpRouteResource).Namespace(ns).List(ctx, meta.ListOptions{})
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			dlog.Debugf(ctx, "unable to list httproutes in namespace %s: %v", ns, err)
+		}
+		return
+	}
+	gateways := make(map[serviceKey]*gateway)
+	getGateway := func(namespace, name string) *gateway {
+		k := serviceKey{namespace: namespace, name: name}
+		if gw, ok := gateways[k]; ok {
+			return gw
+		}
+		var gw *gateway
+		if u, err := dc.Resource(gatewayResource).Namespace(namespace).Get(ctx, name, meta.GetOptions{}); err == nil {
+			gw = &gateway{}
+			if err = runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, gw); err != nil {
+				gw = nil
+			}
+		}
+		gateways[k] = gw
+		return gw
+	}
+	for i := range ul.Items {
+		var hr httpRoute
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(ul.Items[i].Object, &hr); err != nil {
+			dlog.Debugf(ctx, "unable to parse httproute %s.%s: %v", ul.Items[i].GetName(), ns, err)
+			continue
+		}
+		httpRouteRoutes(&hr, getGateway, rm)
+	}
+}
+
+// httpRouteRoutes adds the URLs of each backend service of the given HTTPRoute to the given map. The
+// getGateway function returns the parent Gateway with the given namespace and name, or nil if it can't be found.
+func httpRouteRoutes(hr *httpRoute, getGateway func(namespace, name string) *gateway, rm routeMap) {
+	var bases []string
+	for _, pr := range hr.Spec.ParentRefs {
+		ns := pr.Namespace
+		if ns == "" {
+			ns = hr.Namespace
+		}
+		gw := getGateway(ns, pr.Name)
+		if gw == nil {
+			continue
+		}
+		address := "<pending>"
+		if len(gw.Status.Addresses) > 0 {
+			address = gw.Status.Addresses[0].Value
+		}
+		for _, l := range gw.Spec.Listeners {
+			if pr.SectionName != "" && pr.SectionName != l.Name {
+				continue
+			}
+			scheme, defaultPort := "http", int32(80)
+			switch l.Protocol {
+			case "HTTPS":
+				scheme, defaultPort = "https", 443
+			case "HTTP":
+			default:
+				continue
+			}
+			hosts := hr.Spec.Hostnames
+			switch {
+			case len(hosts) > 0:
+			case l.Hostname != "":
+				hosts = []string{l.Hostname}
+			default:
+				hosts = []string{address}
+			}
+			for _, host := range hosts {
+				if l.Port != 0 && l.Port != defaultPort {
+					host = net.JoinHostPort(host, strconv.Itoa(int(l.Port)))
+				}
+				bases = append(bases, scheme+"://"+host)
+			}
+		}
+	}
+	sort.Strings(bases)
+	for _, rule := range hr.Spec.Rules {
+		paths := []string{"/"}
+		if len(rule.Matches) > 0 {
+			paths = paths[:0]
+			for _, m := range rule.Matches {
+				if m.Path != nil && m.Path.Value != "" {
+					paths = append(paths, m.Path.Value)
+				} else {
+					paths = append(paths, "/")
+				}
+			}
+		}
+		for _, br := range rule.BackendRefs {
+			if br.Kind != "" && br.Kind != "Service" {
+				continue
+			}
+			ns := br.Namespace
+			if ns == "" {
+				ns = hr.Namespace
+			}
+			for _, base := range bases {
+				for _, path := range paths {
+					rm.add(ns, br.Name, "HTTPRoute", hr.Name, base+path)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package trafficmgr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	networking "k8s.io/api/networking/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func routeURLs(rm routeMap, ns, svc string) []string {
+	var urls []string
+	for _, r := range rm[serviceKey{namespace: ns, name: svc}] {
+		urls = append(urls, r.Kind+" "+r.Url)
+	}
+	return urls
+}
+
+func TestIngressRoutes(t *testing.T) {
+	backend := func(svc string) networking.IngressBackend {
+		return networking.IngressBackend{Service: &networking.IngressServiceBackend{Name: svc}}
+	}
+	ing := &networking.Ingress{
+		ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networking.IngressSpec{
+			DefaultBackend: &networking.IngressBackend{Service: &networking.IngressServiceBackend{Name: "fallback"}},
+			TLS:            []networking.IngressTLS{{Hosts: []string{"secure.example.com"}}},
+			Rules: []networking.IngressRule{
+				{
+					Host: "echo.example.com",
+					IngressRuleValue: networking.IngressRuleValue{HTTP: &networking.HTTPIngressRuleValue{
+						Paths: []networking.HTTPIngressPath{{Path: "/api", Backend: backend("echo")}},
+					}},
+				},
+				{
+					Host: "secure.example.com",
+					IngressRuleValue: networking.IngressRuleValue{HTTP: &networking.HTTPIngressRuleValue{
+						Paths: []networking.HTTPIngressPath{{Backend: backend("echo")}},
+					}},
+				},
+			},
+		},
+		Status: networking.IngressStatus{LoadBalancer: networking.IngressLoadBalancerStatus{
+			Ingress: []networking.IngressLoadBalancerIngress{{IP: "10.1.2.3"}},
+		}},
+	}
+	rm := make(routeMap)
+	ingressRoutes(ing, rm)
+	assert.Equal(t, []string{"Ingress http://echo.example.com/api", "Ingress https://secure.example.com/"}, routeURLs(rm, "default", "echo"))
+	assert.Equal(t, []string{"Ingress http://10.1.2.3/"}, routeURLs(rm, "default", "fallback"))
+}
+
+func TestHTTPRouteRoutes(t *testing.T) {
+	var hr httpRoute
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+metadata:
+  name: echo
+  namespace: default
+spec:
+  parentRefs:
+    - name: gw
+      namespace: infra
+  rules:
+    - matches:
+        - path:
+            value: /echo
+      backendRefs:
+        - name: echo
+          port: 8080
+`), &hr))
+	var gw gateway
+	assert.NoError(t, yaml.Unmarshal([]byte(`
+metadata:
+  name: gw
+  namespace: infra
+spec:
+  listeners:
+    - name: web
+      port: 8080
+      protocol: HTTP
+    - name: tls
+      hostname: secure.example.com
+      port: 443
+      protocol: HTTPS
+    - name: raw
+      port: 9000
+      protocol: TCP
+status:
+  addresses:
+    - value: 10.1.2.3
+`), &gw))
+	rm := make(routeMap)
+	httpRouteRoutes(&hr, func(ns, name string) *gateway {
+		if ns == "infra" && name == "gw" {
+			return &gw
+		}
+		return nil
+	}, rm)
+	assert.Equal(t, []string{"HTTPRoute http://10.1.2.3:8080/echo", "HTTPRoute https://secure.example.com/echo"}, routeURLs(rm, "default", "echo"))
+}
@@ -0,0 +1,76 @@
+package trafficmgr
+
+import (
+	"context"
+	"net/netip"
+
+	core "k8s.io/api/core/v1"
+
+	"github.com/datawire/dlib/dcontext"
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/svcalias"
+	"github.com/telepresenceio/telepresence/v2/pkg/proc"
+)
+
+// withServiceAliases returns a context with a svcalias.Registry when the daemon runs in a container and service
+// aliases are enabled. The registry must be in the context before the root session is started in-process, because
+// the root session's DNS server uses it to resolve the aliases.
+func withServiceAliases(ctx context.Context) context.Context {
+	if !(proc.RunningInContainer() && client.GetConfig(ctx).Docker().ServiceAliases) {
+		return ctx
+	}
+	r, err := svcalias.NewRegistry()
+	if err != nil {
+		dlog.Errorf(ctx, "service aliases are disabled: %v", err)
+		return ctx
+	}
+	dlog.Infof(ctx, "Containers on the daemon's network can reach the cluster's services using --dns %s", r.Address())
+	return svcalias.WithRegistry(ctx, r)
+}
+
+// serviceAliasesHandler keeps the aliases of the registry in sync with the services of the mapped namespaces
+// and removes them when the session ends.
+func (s *session) serviceAliasesHandler(ctx context.Context) error {
+	r := svcalias.GetRegistry(ctx)
+	if r == nil {
+		return nil
+	}
+	defer r.Close(dcontext.WithoutCancel(ctx))
+
+	s.waitForSync(ctx)
+	snapshotAvailable := s.wlWatcher.subscribe(ctx)
+	for {
+		var svcs []*svcalias.Service
+		s.wlWatcher.eachService(ctx, s.GetManagerNamespace(), s.GetCurrentNamespaces(true), func(svc *core.Service) {
+			if as := aliasService(svc); as != nil {
+				svcs = append(svcs, as)
+			}
+		})
+		r.Update(ctx, svcs)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-snapshotAvailable:
+		}
+	}
+}
+
+// aliasService returns the alias description of the given service, or nil when the service has no cluster IP.
+func aliasService(svc *core.Service) *svcalias.Service {
+	ip, err := netip.ParseAddr(svc.Spec.ClusterIP)
+	if err != nil {
+		// Headless, or of type ExternalName.
+		return nil
+	}
+	as := &svcalias.Service{
+		Name:      svc.Name,
+		Namespace: svc.Namespace,
+		ClusterIP: ip,
+		Ports:     make([]svcalias.Port, 0, len(svc.Spec.Ports)),
+	}
+	for _, p := range svc.Spec.Ports {
+		as.Ports = append(as.Ports, svcalias.Port{Protocol: string(p.Protocol), Port: uint16(p.Port)})
+	}
+	return as
+}
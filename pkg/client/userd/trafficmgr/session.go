@@ -19,7 +19,9 @@ import (
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/durationpb"
 	empty "google.golang.org/protobuf/types/known/emptypb"
 	"gopkg.in/yaml.v3"
@@ -48,11 +50,16 @@ import (
 	"github.com/telepresenceio/telepresence/v2/pkg/client/tm"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/userd"
 	"github.com/telepresenceio/telepresence/v2/pkg/client/userd/k8s"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/wsl"
 	"github.com/telepresenceio/telepresence/v2/pkg/dnet"
 	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/install"
 	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
 	"github.com/telepresenceio/telepresence/v2/pkg/matcher"
+	"github.com/telepresenceio/telepresence/v2/pkg/proc"
 	"github.com/telepresenceio/telepresence/v2/pkg/restapi"
+	"github.com/telepresenceio/telepresence/v2/pkg/slice"
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
 )
 
 type apiServer struct {
@@ -104,6 +111,9 @@ type session struct {
 	// is keyeed by the intercept ID
 	currentIntercepts map[string]*intercept
 
+	// interceptSeq is the sequence number of the last intercept that was added to currentIntercepts.
+	interceptSeq uint64
+
 	// currentMatches hold the matchers used when using the APIServer.
 	currentMatchers map[string]*apiMatcher
 
@@ -131,8 +141,40 @@ type session struct {
 
 	isPodDaemon bool
 
+	// observe is true when the traffic-manager knows this session as observing, i.e. it refuses
+	// all intercepts from it.
+	observe bool
+
+	// managerInsecure is true when the connection to the traffic-manager is neither TLS nor a port-forward.
+	managerInsecure bool
+
+	// noRouting is true when the session was established without the root daemon, so that no
+	// outbound routing to the cluster takes place.
+	noRouting bool
+
+	// takeOverRequests are the pending requests from other clients to take over intercepts of
+	// this session, keyed by request id.
+	takeOverRequests map[string]*manager.TakeOverRequest
+	takeOverLock     sync.Mutex
+
+	// envJSON is the file that the connection environment is written to, if any. The envJSONCh
+	// triggers a rewrite of that file.
+	envJSON     string
+	envJSONLock sync.Mutex
+	envJSONCh   chan struct{}
+
 	sessionConfig client.Config
 
+	// resources are the limits that the daemon imposes on itself, and the load that it has shed.
+	resources *resourceLimits
+
+	// heartbeat keeps the tunnels of intercepted connections alive while they are idle.
+	heartbeat *tunnel.Heartbeat
+
+	// timeoutOverrides are the timeouts given with the connect request. They take precedence over
+	// the timeouts of the sessionConfig.
+	timeoutOverrides map[string]string
+
 	// done is closed when the session ends
 	done chan struct{}
 
@@ -151,8 +193,24 @@ func NewSession(
 	dlog.Info(ctx, "-- Starting new session")
 	scout.Report(ctx, "connect")
 
+	if len(cr.Timeouts) > 0 {
+		// Apply the timeout overrides to a copy of the current config, so that they are in effect
+		// while the session is established.
+		cfg := client.GetDefaultConfig()
+		cfg.Merge(client.GetConfig(ctx))
+		if err := cfg.Timeouts().Override(cr.Timeouts); err != nil {
+			return ctx, nil, connectError(rpc.ConnectInfo_CLUSTER_FAILED, errcat.User.New(err))
+		}
+		client.ReplaceConfig(ctx, cfg)
+	}
+
 	defer func() {
 		if cErr != nil {
+			if len(cr.Timeouts) > 0 {
+				if err := client.RestoreDefaults(ctx, false); err != nil {
+					dlog.Warn(ctx, err)
+				}
+			}
 			scout.Report(ctx, "connect_error", scout.Entry{
 				Key:   "error",
 				Value: cErr.ErrorText,
@@ -193,6 +251,7 @@ func NewSession(
 		return ctx, nil, connectError(rpc.ConnectInfo_TRAFFIC_MANAGER_FAILED, err)
 	}
 
+	tmgr.timeoutOverrides = cr.Timeouts
 	tmgr.sessionConfig = client.GetDefaultConfig()
 	cliCfg, err := tmgr.managerClient.GetClientConfig(ctx, &empty.Empty{})
 	if err != nil {
@@ -210,9 +269,16 @@ func NewSession(
 			dlog.Warnf(ctx, "Failed to set remote kubeconfig values: %v", err)
 		}
 	}
+	_ = tmgr.sessionConfig.Timeouts().Override(tmgr.timeoutOverrides) // validated above
 
 	rdRunning := userd.GetService(ctx).RootSessionInProcess()
-	if !rdRunning {
+	if cr.NoRouting {
+		dlog.Info(ctx, "Connecting without outbound routing")
+		rdRunning = false
+	} else if wsl.Bridged(ctx) {
+		// The root daemon on the Windows host provides the VIF.
+		rdRunning = true
+	} else if !rdRunning {
 		// Connect to the root daemon if it is running. It's the CLI that starts it initially
 		rdRunning, err = socket.IsRunning(ctx, socket.RootDaemonPath(ctx))
 		if err != nil {
@@ -222,6 +288,7 @@ func NewSession(
 
 	var daemonStatus *rootdRpc.DaemonStatus
 	if rdRunning {
+		ctx = withServiceAliases(ctx)
 		tmgr.rootDaemon, err = tmgr.connectRootDaemon(ctx, tmgr.getOutboundInfo(ctx))
 		if err != nil {
 			tmgr.managerConn.Close()
@@ -254,6 +321,7 @@ func NewSession(
 		Intercepts:       &manager.InterceptInfoSnapshot{Intercepts: tmgr.getCurrentInterceptInfos()},
 		ManagerNamespace: cluster.Kubeconfig.GetManagerNamespace(),
 		DaemonStatus:     daemonStatus,
+		Observe:          tmgr.observe,
 	}
 	return ctx, tmgr, ret
 }
@@ -276,6 +344,7 @@ func (s *session) RunSession(c context.Context) error {
 	defer func() {
 		self.Epilog(c)
 	}()
+	s.savePromptInfo(c, 0)
 	self.StartServices(g)
 	return g.Wait()
 }
@@ -330,12 +399,38 @@ func connectMgr(
 		return nil, err
 	}
 
+	if kf := client.GetConfig(ctx).Images().VerifyKey(); kf != "" {
+		if err = verifyTrafficManagerImages(ctx, cluster.GetManagerNamespace(), kf); err != nil {
+			return nil, err
+		}
+	}
+
 	dlog.Debug(ctx, "creating port-forward")
 	pfDialer, err := dnet.NewK8sPortForwardDialer(ctx, cluster.Kubeconfig.RestConfig, k8sapi.GetK8sInterface(ctx))
 	if err != nil {
 		return nil, err
 	}
-	conn, mClient, vi, err := tm.ConnectToManager(ctx, cluster.GetManagerNamespace(), pfDialer.Dial)
+	var conn *grpc.ClientConn
+	var mClient manager.ManagerClient
+	var vi *manager.VersionInfo2
+	managerInsecure := false
+	if ep := cluster.GetManagerEndpoint(ctx); ep != nil {
+		managerInsecure = ep.Insecure
+		err = client.GetConfig(ctx).Retries().Policy().Do(ctx, "manager dial", func(ctx context.Context) (err error) {
+			conn, mClient, vi, err = tm.ConnectToManagerEndpoint(ctx, ep)
+			return err
+		})
+	} else {
+		err = client.GetConfig(ctx).Retries().Policy().Do(ctx, "manager dial", func(ctx context.Context) (err error) {
+			conn, mClient, vi, err = tm.ConnectToManager(ctx, cluster.GetManagerNamespace(), pfDialer.Dial)
+			return err
+		})
+		if err != nil {
+			// The traffic-manager is usually unreachable because its pod fails to start, and the reason is
+			// then found in its events, which may be older than this connect attempt.
+			err = withWorkloadProblems(ctx, err, install.ManagerAppName, cluster.GetManagerNamespace(), time.Time{})
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -350,10 +445,22 @@ func connectMgr(
 	if err != nil {
 		return nil, err
 	}
-	si, err := LoadSessionInfoFromUserCache(ctx, daemonID)
+	ss, err := loadSavedSession(ctx, daemonID)
 	if err != nil {
 		return nil, err
 	}
+	var si *manager.SessionInfo
+	if ss != nil {
+		if ss.Observe == cr.Observe {
+			si = ss.Session
+		} else {
+			// The traffic-manager knows the cached session in another mode, so it can't be reused.
+			dlog.Debugf(ctx, "not reusing cached session %s, because its observe mode differs", ss.Session.GetSessionId())
+			if _, err := mClient.Depart(ctx, ss.Session); err != nil {
+				dlog.Debugf(ctx, "unable to depart session %s: %v", ss.Session.GetSessionId(), err)
+			}
+		}
+	}
 
 	svc := userd.GetService(ctx)
 	if si != nil {
@@ -378,11 +485,13 @@ func connectMgr(
 			InstallId: installID,
 			Product:   "telepresence",
 			Version:   client.Version(),
+			Observe:   cr.Observe,
+			KubeToken: managerToken(ctx, cluster.Kubeconfig, managerInsecure),
 		})
 		if err != nil {
 			return nil, client.CheckTimeout(ctx, fmt.Errorf("manager.ArriveAsClient: %w", err))
 		}
-		if err = SaveSessionInfoToUserCache(ctx, daemonID, si); err != nil {
+		if err = saveSession(ctx, daemonID, si, cr.Observe); err != nil {
 			return nil, err
 		}
 	}
@@ -427,6 +536,14 @@ func connectMgr(
 		interceptWaiters: make(map[string]*awaitIntercept),
 		wlWatcher:        newWASWatcher(),
 		isPodDaemon:      cr.IsPodDaemon,
+		observe:          cr.Observe,
+		managerInsecure:  managerInsecure,
+		noRouting:        cr.NoRouting,
+		takeOverRequests: make(map[string]*manager.TakeOverRequest),
+		envJSON:          cr.EnvJson,
+		envJSONCh:        make(chan struct{}, 1),
+		resources:        newResourceLimits(client.GetConfig(ctx).UserDaemon()),
+		heartbeat:        client.GetConfig(ctx).Grpc().Heartbeat(),
 		done:             make(chan struct{}),
 	}
 	sess.self = sess
@@ -444,6 +561,9 @@ func (s *session) Remain(ctx context.Context) error {
 	_, err := self.ManagerClient().Remain(ctx, self.NewRemainRequest())
 	if err != nil {
 		if status.Code(err) == codes.NotFound {
+			if s.resumeSession(ctx) {
+				return nil
+			}
 			// Session has expired. We need to cancel the owner session and reconnect
 			return ErrSessionExpired
 		}
@@ -452,6 +572,60 @@ func (s *session) Remain(ctx context.Context) error {
 	return nil
 }
 
+// managerToken returns the token that the traffic-manager authenticates the Kubernetes user of the client with.
+// It's a short-lived token that only the traffic-manager accepts, never the credentials of the kubeconfig, and it's
+// never sent over an insecure connection. Not getting one isn't fatal. The user is then only permitted what the
+// policies of the traffic-manager permit all users.
+func managerToken(ctx context.Context, kc *client.Kubeconfig, insecure bool) string {
+	if insecure {
+		dlog.Debug(ctx, "not identifying the Kubernetes user to a traffic-manager that is connected insecurely")
+		return ""
+	}
+	token, err := client.ManagerToken(ctx, kc.RestConfig, k8sapi.GetK8sInterface(ctx))
+	if err != nil {
+		dlog.Warnf(ctx, "unable to get a token for the traffic-manager: %v", err)
+	}
+	return token
+}
+
+// resumeSession resumes the session when the traffic-manager no longer knows it, which happens when the session
+// has expired, or when the connection has failed over to a traffic-manager that lost its state. The intercepts of
+// the session are recreated. False is returned when the session can't be resumed.
+func (s *session) resumeSession(ctx context.Context) bool {
+	si := s.sessionInfo
+	if si.ResumptionToken == "" {
+		// Older traffic-managers don't hand out resumption tokens.
+		return false
+	}
+	rsi, err := s.managerClient.ArriveAsClient(ctx, &manager.ClientInfo{
+		Name:      s.userAndHost,
+		Namespace: s.Namespace,
+		InstallId: s.installID,
+		Product:   "telepresence",
+		Version:   client.Version(),
+		Observe:   s.observe,
+		Resume:    si,
+		KubeToken: managerToken(ctx, s.Kubeconfig, s.managerInsecure),
+	})
+	if err != nil {
+		dlog.Errorf(ctx, "unable to resume session %s: %v", si.SessionId, client.CheckTimeout(ctx, err))
+		return false
+	}
+	if rsi.SessionId != si.SessionId {
+		// The traffic-manager created a new session instead.
+		_, _ = s.managerClient.Depart(ctx, rsi)
+		return false
+	}
+	dlog.Infof(ctx, "session %s resumed", si.SessionId)
+	for _, ic := range s.getCurrentIntercepts() {
+		_, err = s.managerClient.CreateIntercept(ctx, s.NewCreateInterceptRequest(ic.Spec))
+		if err != nil && status.Code(err) != codes.AlreadyExists {
+			dlog.Errorf(ctx, "unable to recreate intercept %s: %v", ic.Spec.Name, err)
+		}
+	}
+	return true
+}
+
 func parseCIDR(cidr []string) ([]*iputil.Subnet, error) {
 	result := make([]*iputil.Subnet, 0)
 
@@ -534,15 +708,57 @@ func (s *session) Epilog(ctx context.Context) {
 		_, _ = s.rootDaemon.Disconnect(ctx, &empty.Empty{})
 	}
 	_ = s.pfDialer.Close()
+	if !s.isPodDaemon {
+		if err := daemon.DeletePromptInfo(ctx, s.daemonID); err != nil {
+			dlog.Errorf(ctx, "failed to delete prompt info from user cache: %v", err)
+		}
+	}
 	dlog.Info(ctx, "-- Session ended")
 	close(s.done)
 }
 
+// savePromptInfo updates the summary of this session that shell prompts read using "telepresence status --porcelain".
+func (s *session) savePromptInfo(ctx context.Context, intercepts int) {
+	if s.isPodDaemon {
+		return
+	}
+	pi := &daemon.PromptInfo{
+		Name:        s.daemonID.Name,
+		KubeContext: s.daemonID.KubeContext,
+		Namespace:   s.daemonID.Namespace,
+		Intercepts:  intercepts,
+		PID:         os.Getpid(),
+		InDocker:    proc.RunningInContainer(),
+	}
+	if err := daemon.SavePromptInfo(ctx, s.daemonID, pi); err != nil {
+		dlog.Errorf(ctx, "failed to save prompt info to user cache: %v", err)
+	}
+}
+
 func (s *session) StartServices(g *dgroup.Group) {
 	g.Go("remain", s.remainLoop)
 	g.Go("intercept-port-forward", s.watchInterceptsHandler)
 	g.Go("agent-watcher", s.agentInfoWatcher)
 	g.Go("dial-request-watcher", s.dialRequestWatcher)
+	g.Go("take-over-request-watcher", s.takeOverRequestWatcher)
+	g.Go("env-json-writer", s.envJSONWriter)
+	g.Go("service-aliases", s.serviceAliasesHandler)
+}
+
+// retryCounters returns the counters of the operations that this process has retried.
+func retryCounters() []*rpc.RetryCounters {
+	rss := client.RetryCounters()
+	rcs := make([]*rpc.RetryCounters, len(rss))
+	for i, rs := range rss {
+		rcs[i] = &rpc.RetryCounters{
+			Operation: rs.Operation,
+			Calls:     rs.Calls,
+			Retries:   rs.Retries,
+			Failures:  rs.Failures,
+			LastError: rs.LastError,
+		}
+	}
+	return rcs
 }
 
 func runWithRetry(ctx context.Context, f func(context.Context) error) error {
@@ -577,6 +793,9 @@ func (s *session) ApplyConfig(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	if err = s.sessionConfig.Timeouts().Override(s.timeoutOverrides); err != nil {
+		return err
+	}
 	if len(s.MappedNamespaces) == 0 {
 		mns := client.GetConfig(ctx).Cluster().MappedNamespaces
 		if len(mns) > 0 {
@@ -683,6 +902,7 @@ func (s *session) WatchWorkloads(c context.Context, wr *rpc.WatchWorkloadsReques
 	// Otherwise, the goroutine that writes to the channel will leak.
 	defer sCancel()
 	snapshotAvailable := s.wlWatcher.subscribe(sCtx)
+	var sent map[string]*rpc.WorkloadInfo
 	for {
 		select {
 		case <-c.Done(): // if context is done (usually the session's context).
@@ -694,6 +914,15 @@ func (s *session) WatchWorkloads(c context.Context, wr *rpc.WatchWorkloadsReques
 			if err != nil {
 				return status.Errorf(codes.Unavailable, "failed to create WorkloadInfoSnapshot: %v", err)
 			}
+			if sent == nil {
+				sent = make(map[string]*rpc.WorkloadInfo, len(snapshot.Workloads))
+				for _, wi := range snapshot.Workloads {
+					sent[wi.Uid] = wi
+				}
+			} else if snapshot = diffSnapshot(sent, snapshot, wr.Incremental); snapshot == nil {
+				// Nothing of interest to the client has changed
+				continue
+			}
 			if err := stream.Send(snapshot); err != nil {
 				dlog.Errorf(c, "WatchWorkloads.Send() failed: %v", err)
 				return err
@@ -702,13 +931,49 @@ func (s *session) WatchWorkloads(c context.Context, wr *rpc.WatchWorkloadsReques
 	}
 }
 
+// diffSnapshot compares the given snapshot with the workloads that have been sent, keyed by UID, and updates
+// the sent workloads. It returns nil when nothing has changed. Otherwise, it returns the given snapshot, or when
+// incremental is true, a snapshot with only the workloads that were added or changed, and the UIDs of the
+// workloads that were removed.
+func diffSnapshot(sent map[string]*rpc.WorkloadInfo, snapshot *rpc.WorkloadInfoSnapshot, incremental bool) *rpc.WorkloadInfoSnapshot {
+	var changed []*rpc.WorkloadInfo
+	current := make(map[string]struct{}, len(snapshot.Workloads))
+	for _, wi := range snapshot.Workloads {
+		current[wi.Uid] = struct{}{}
+		if old, ok := sent[wi.Uid]; !ok || !proto.Equal(old, wi) {
+			changed = append(changed, wi)
+			sent[wi.Uid] = wi
+		}
+	}
+	var removed []string
+	for uid := range sent {
+		if _, ok := current[uid]; !ok {
+			removed = append(removed, uid)
+			delete(sent, uid)
+		}
+	}
+	if len(changed) == 0 && len(removed) == 0 {
+		return nil
+	}
+	if !incremental {
+		return snapshot
+	}
+	sort.Strings(removed)
+	return &rpc.WorkloadInfoSnapshot{Workloads: changed, RemovedUids: removed}
+}
+
 func (s *session) WorkloadInfoSnapshot(
 	ctx context.Context,
 	namespaces []string,
 	filter rpc.ListRequest_Filter,
+	includeRoutes bool,
 ) (*rpc.WorkloadInfoSnapshot, error) {
 	s.waitForSync(ctx)
-	return s.workloadInfoSnapshot(ctx, namespaces, filter)
+	snapshot, err := s.workloadInfoSnapshot(ctx, namespaces, filter)
+	if err == nil && includeRoutes {
+		s.addRoutes(ctx, snapshot.Workloads)
+	}
+	return snapshot, err
 }
 
 func (s *session) ensureWatchers(ctx context.Context,
@@ -808,6 +1073,7 @@ nextIs:
 	}
 
 	workloadInfos := s.getInfosForWorkloads(ctx, nss, iMap, sMap, filter)
+	s.addAgentInfos(workloadInfos)
 	return &rpc.WorkloadInfoSnapshot{Workloads: workloadInfos}, nil
 }
 
@@ -831,10 +1097,20 @@ func (s *session) remainLoop(c context.Context) error {
 		s.managerConn.Close()
 	}()
 
+	reconnected := make(chan struct{}, 1)
+	if s.managerConn != nil {
+		go watchManagerConn(c, s.managerConn, reconnected)
+	}
 	for {
 		select {
 		case <-c.Done():
 			return nil
+		case <-reconnected:
+			// The connection may have failed over to another traffic-manager replica. Call Remain right
+			// away, so that the session is re-established without delay if that replica doesn't know it.
+			if err := s.Remain(c); err != nil {
+				return err
+			}
 		case <-ticker.C:
 			if err := s.Remain(c); err != nil {
 				return err
@@ -843,6 +1119,33 @@ func (s *session) remainLoop(c context.Context) error {
 	}
 }
 
+// watchManagerConn sends to the reconnected channel each time the connection to the traffic-manager
+// becomes ready after having been lost.
+func watchManagerConn(ctx context.Context, conn *grpc.ClientConn, reconnected chan<- struct{}) {
+	lost := false
+	for state := conn.GetState(); conn.WaitForStateChange(ctx, state); {
+		state = conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			if lost {
+				dlog.Info(ctx, "connection to traffic-manager re-established")
+				lost = false
+				select {
+				case reconnected <- struct{}{}:
+				default:
+				}
+			}
+		case connectivity.Shutdown:
+			return
+		default:
+			if !lost {
+				dlog.Infof(ctx, "connection to traffic-manager lost (%s), reconnecting", state)
+				lost = true
+			}
+		}
+	}
+}
+
 func (s *session) UpdateStatus(c context.Context, cr *rpc.ConnectRequest) *rpc.ConnectInfo {
 	config, err := client.DaemonKubeconfig(c, cr)
 	if err != nil {
@@ -864,7 +1167,9 @@ func (s *session) UpdateStatus(c context.Context, cr *rpc.ConnectRequest) *rpc.C
 				}
 			}
 		}
-		if !(envEQ && s.Kubeconfig.ContextServiceAndFlagsEqual(config)) {
+		// A request to observe can't be satisfied by a session that may intercept. The reverse is
+		// fine, because commands other than connect never ask to observe.
+		if !(envEQ && s.Kubeconfig.ContextServiceAndFlagsEqual(config)) || cr.Observe && !s.observe {
 			return &rpc.ConnectInfo{
 				Error:          rpc.ConnectInfo_MUST_RESTART,
 				ClusterContext: s.Kubeconfig.Context,
@@ -882,15 +1187,66 @@ func (s *session) UpdateStatus(c context.Context, cr *rpc.ConnectRequest) *rpc.C
 		namespaces = client.GetConfig(c).Cluster().MappedNamespaces
 	}
 
+	s.setMappedNamespaces(c, namespaces)
+	if cr.EnvJson != "" {
+		s.setEnvJSON(cr.EnvJson)
+	}
+	return s.Status(c)
+}
+
+// setMappedNamespaces updates the mapped namespaces, and the state that depends on them. An empty list maps
+// all namespaces, unless a namespace selector is in effect.
+func (s *session) setMappedNamespaces(c context.Context, namespaces []string) {
 	if s.SetMappedNamespaces(c, namespaces) {
-		if len(namespaces) == 0 && s.CanWatchNamespaces(c) {
+		if len(namespaces) == 0 && s.NamespaceSelector == nil && s.CanWatchNamespaces(c) {
 			s.StartNamespaceWatcher(c)
 		}
 		s.currentInterceptsLock.Lock()
 		s.ingressInfo = nil
 		s.currentInterceptsLock.Unlock()
+		s.refreshEnvJSON()
 	}
-	return s.Status(c)
+}
+
+// MapNamespaces adds the given namespaces to the mapped namespaces, or removes them when unmap is true,
+// and returns the namespaces that are mapped after the update. The namespace listeners propagate the
+// change to the root daemon's DNS resolver and to the workload watchers. Namespaces that match the
+// namespace selector remain mapped for as long as they match it.
+func (s *session) MapNamespaces(c context.Context, namespaces []string, unmap bool) ([]string, error) {
+	current := s.MappedNamespaces
+	if len(current) == 0 && s.NamespaceSelector == nil {
+		if !unmap {
+			// All namespaces are already mapped.
+			return s.GetCurrentNamespaces(false), nil
+		}
+		// Unmapping from "all" requires an explicit list of the namespaces that remain.
+		current = s.GetCurrentNamespaces(false)
+	}
+	nss, err := mapNamespaces(current, namespaces, unmap, s.NamespaceSelector != nil)
+	if err != nil {
+		return nil, err
+	}
+	s.setMappedNamespaces(c, nss)
+	return s.GetCurrentNamespaces(false), nil
+}
+
+// mapNamespaces returns the current namespaces with the given namespaces added, or removed when unmap is true.
+// Removing all namespaces is an error unless a namespace selector maps namespaces too, because an empty list
+// would otherwise map all namespaces.
+func mapNamespaces(current, namespaces []string, unmap, hasSelector bool) ([]string, error) {
+	if !unmap {
+		return slice.AppendUnique(append(make([]string, 0, len(current)+len(namespaces)), current...), namespaces...), nil
+	}
+	var nss []string
+	for _, ns := range current {
+		if !slice.Contains(namespaces, ns) {
+			nss = append(nss, ns)
+		}
+	}
+	if len(nss) == 0 && !hasSelector {
+		return nil, errcat.User.New("at least one namespace must remain mapped")
+	}
+	return nss, nil
 }
 
 func (s *session) Status(c context.Context) *rpc.ConnectInfo {
@@ -912,10 +1268,18 @@ func (s *session) Status(c context.Context) *rpc.ConnectInfo {
 			Name:       client.DisplayName,
 		},
 		ManagerNamespace: cfg.GetManagerNamespace(),
+		Observe:          s.observe,
+		TakeOverRequests: s.TakeOverRequests(),
+		ResourceWarnings: s.resources.warnings(),
+		RetryCounters:    retryCounters(),
+		NoRouting:        s.noRouting,
 	}
-	if len(s.MappedNamespaces) > 0 || len(s.sessionConfig.Cluster().MappedNamespaces) > 0 {
+	if len(s.MappedNamespaces) > 0 || len(s.sessionConfig.Cluster().MappedNamespaces) > 0 || s.NamespaceSelector != nil {
 		ret.MappedNamespaces = s.GetCurrentNamespaces(true)
 	}
+	if s.NamespaceSelector != nil {
+		ret.MappedNamespaceSelector = s.NamespaceSelector.String()
+	}
 	if s.rootDaemon != nil {
 		var err error
 		ret.DaemonStatus, err = s.rootDaemon.Status(c, &empty.Empty{})
@@ -1034,6 +1398,25 @@ func (s *session) Uninstall(ctx context.Context, ur *rpc.UninstallRequest) (*com
 	return errcat.ToResult(nil), nil
 }
 
+// cloudMetadataSubnets returns the metadata subnets of the cluster's cloud provider, except those that the
+// user has asked to proxy explicitly.
+func (s *session) cloudMetadataSubnets(ctx context.Context) []*manager.IPNet {
+	var subnets []*manager.IPNet
+nextSubnet:
+	for _, sn := range s.CloudProvider.MetadataSubnets() {
+		for _, ap := range s.AlsoProxy {
+			if (*net.IPNet)(ap).Contains(sn.IP) {
+				continue nextSubnet
+			}
+		}
+		subnets = append(subnets, iputil.IPNetToRPC(sn))
+	}
+	if len(subnets) > 0 {
+		dlog.Infof(ctx, "Never proxying the metadata endpoints of cloud provider %s", s.CloudProvider)
+	}
+	return subnets
+}
+
 func (s *session) getOutboundInfo(ctx context.Context) *rootdRpc.OutboundInfo {
 	// We'll figure out the IP address of the API server(s) so that we can tell the daemon never to proxy them.
 	// This is because in some setups the API server will be in the same CIDR range as the pods, and the
@@ -1070,6 +1453,9 @@ func (s *session) getOutboundInfo(ctx context.Context) *rootdRpc.OutboundInfo {
 	for _, np := range s.NeverProxy {
 		neverProxy = append(neverProxy, iputil.IPNetToRPC((*net.IPNet)(np)))
 	}
+	if client.GetConfig(ctx).VIF().NeverProxyCloudMetadata {
+		neverProxy = append(neverProxy, s.cloudMetadataSubnets(ctx)...)
+	}
 	info := &rootdRpc.OutboundInfo{
 		Session:           s.sessionInfo,
 		NeverProxySubnets: neverProxy,
@@ -1084,6 +1470,8 @@ func (s *session) getOutboundInfo(ctx context.Context) *rootdRpc.OutboundInfo {
 			Excludes:        s.DNS.Excludes,
 			Mappings:        s.DNS.Mappings.ToRPC(),
 			LookupTimeout:   durationpb.New(s.DNS.LookupTimeout.Duration),
+			Resolvers:       s.DNS.Resolvers.ToRPC(),
+			ClusterDomain:   s.DNS.ClusterDomain,
 		}
 		if len(s.DNS.LocalIP) > 0 {
 			info.Dns.LocalIp = s.DNS.LocalIP.IP()
@@ -1091,6 +1479,13 @@ func (s *session) getOutboundInfo(ctx context.Context) *rootdRpc.OutboundInfo {
 		if len(s.DNS.RemoteIP) > 0 {
 			info.Dns.RemoteIp = s.DNS.RemoteIP.IP()
 		}
+		if rc := s.DNS.RecursionCheck; rc != nil {
+			info.Dns.SkipRecursionCheck = rc.Skip
+			info.Dns.RecursionCheckTarget = rc.Target
+			if rc.Timeout.Duration > 0 {
+				info.Dns.RecursionCheckTimeout = durationpb.New(rc.Timeout.Duration)
+			}
+		}
 	}
 
 	if len(s.AlsoProxy) > 0 {
@@ -1114,11 +1509,20 @@ func (s *session) connectRootDaemon(ctx context.Context, oi *rootdRpc.OutboundIn
 		}
 		rd = rootSession
 	} else {
+		if l := wsl.ListenForRootDaemon(ctx); l != nil {
+			// The root daemon on the Windows host can't dial this user daemon, so it calls it using the connections
+			// that this listener dials to it.
+			go func() {
+				if err := svc.Server().Serve(l); err != nil {
+					dlog.Debugf(ctx, "stopped serving the root daemon on the Windows host: %v", err)
+				}
+			}()
+		}
 		var conn *grpc.ClientConn
-		conn, err = socket.Dial(ctx, socket.RootDaemonPath(ctx),
+		conn, err = wsl.DialRootDaemon(ctx, append([]grpc.DialOption{
 			grpc.WithUnaryInterceptor(otelgrpc.UnaryClientInterceptor()),
 			grpc.WithStreamInterceptor(otelgrpc.StreamClientInterceptor()),
-		)
+		}, client.GetConfig(ctx).Grpc().DialOptions(client.ChannelNoKeepAlive)...)...)
 		if err != nil {
 			return nil, fmt.Errorf("unable open root daemon socket: %w", err)
 		}
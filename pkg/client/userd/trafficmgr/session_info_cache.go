@@ -18,25 +18,39 @@ type SavedSession struct {
 	KubeContext string               `json:"kubeContext"`
 	Namespace   string               `json:"namespace"`
 	Session     *manager.SessionInfo `json:"session"`
+	Observe     bool                 `json:"observe,omitempty"`
 }
 
 // SaveSessionInfoToUserCache saves the provided SessionInfo to user cache and returns an error if
 // something goes wrong while marshalling or persisting.
 func SaveSessionInfoToUserCache(ctx context.Context, daemonID *daemon.Identifier, session *manager.SessionInfo) error {
-	return cache.SaveToUserCache(ctx, &SavedSession{
+	return saveSession(ctx, daemonID, session, false)
+}
+
+func saveSession(ctx context.Context, daemonID *daemon.Identifier, session *manager.SessionInfo, observe bool) error {
+	return cache.SaveSecretToUserCache(ctx, &SavedSession{
 		KubeContext: daemonID.KubeContext,
 		Namespace:   daemonID.Namespace,
 		Session:     session,
+		Observe:     observe,
 	}, sessionInfoFile(daemonID))
 }
 
 // LoadSessionInfoFromUserCache gets the SessionInfo from cache or returns an error if something goes
 // wrong while loading or unmarshalling.
 func LoadSessionInfoFromUserCache(ctx context.Context, daemonID *daemon.Identifier) (*manager.SessionInfo, error) {
+	ss, err := loadSavedSession(ctx, daemonID)
+	if ss == nil {
+		return nil, err
+	}
+	return ss.Session, nil
+}
+
+func loadSavedSession(ctx context.Context, daemonID *daemon.Identifier) (*SavedSession, error) {
 	var ss *SavedSession
-	err := cache.LoadFromUserCache(ctx, &ss, sessionInfoFile(daemonID))
+	err := cache.LoadSecretFromUserCache(ctx, &ss, sessionInfoFile(daemonID))
 	if err == nil && ss.KubeContext == daemonID.KubeContext && ss.Namespace == daemonID.Namespace {
-		return ss.Session, nil
+		return ss, nil
 	}
 	if err != nil && os.IsNotExist(err) {
 		err = nil
@@ -0,0 +1,31 @@
+package trafficmgr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapNamespaces(t *testing.T) {
+	nss, err := mapNamespaces([]string{"a", "b"}, []string{"b", "c"}, false, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, nss)
+
+	nss, err = mapNamespaces(nil, []string{"a"}, false, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, nss)
+
+	nss, err = mapNamespaces([]string{"a", "b", "c"}, []string{"b", "d"}, true, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "c"}, nss)
+
+	// An empty list maps all namespaces, so the last one can't be unmapped.
+	_, err = mapNamespaces([]string{"a", "b"}, []string{"a", "b"}, true, false)
+	assert.Error(t, err)
+
+	// Unless a selector maps namespaces too.
+	nss, err = mapNamespaces([]string{"a", "b"}, []string{"a", "b"}, true, true)
+	require.NoError(t, err)
+	assert.Empty(t, nss)
+}
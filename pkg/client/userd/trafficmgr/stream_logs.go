@@ -0,0 +1,130 @@
+package trafficmgr
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	core "k8s.io/api/core/v1"
+	typed "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+)
+
+// Log sources that are found in the cluster.
+const (
+	LogSourceManager = "manager"
+	LogSourceAgent   = "agent"
+)
+
+// StreamPodLogs calls the given function with the log entries of the traffic-manager and the traffic-agents
+// selected by the given request. The function is never called concurrently. When the request follows the logs,
+// StreamPodLogs returns when the context is cancelled.
+func (s *session) StreamPodLogs(ctx context.Context, rq *connector.StreamLogsRequest, fn func(*connector.LogEntry) error) error {
+	wantSource := func(source string) bool {
+		if len(rq.Sources) == 0 {
+			return true
+		}
+		for _, s := range rq.Sources {
+			if s == source {
+				return true
+			}
+		}
+		return false
+	}
+	sessionID := ""
+	if rq.CurrentSession {
+		sessionID = s.SessionInfo().SessionId
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var fnLock sync.Mutex
+	var fnErr error
+	send := func(le *connector.LogEntry) error {
+		fnLock.Lock()
+		defer fnLock.Unlock()
+		if fnErr == nil {
+			if fnErr = fn(le); fnErr != nil {
+				cancel()
+			}
+		}
+		return fnErr
+	}
+
+	wg := sync.WaitGroup{}
+	if wantSource(LogSourceManager) {
+		pod, err := s.trafficManagerPod(ctx)
+		if err != nil {
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			streamPodLog(ctx, rq, k8sapi.GetK8sInterface(ctx).CoreV1().Pods(pod.Namespace), pod, "traffic-manager", "traffic-manager", sessionID, send)
+		}()
+	}
+	if wantSource(LogSourceAgent) {
+		err := s.ForeachAgentPod(ctx, func(ctx context.Context, podsAPI typed.PodInterface, pod *core.Pod) {
+			streamPodLog(ctx, rq, podsAPI, pod, agentconfig.ContainerName, agentLogSource(pod), sessionID, send)
+		}, func(pod *core.Pod) bool {
+			return rq.Agents == "" || strings.Contains(pod.Name, rq.Agents)
+		})
+		if err != nil {
+			cancel()
+			wg.Wait()
+			return err
+		}
+	}
+	wg.Wait()
+	return fnErr
+}
+
+// agentLogSource returns the source of the log entries of the traffic-agent in the given pod. It identifies
+// the pod and the container, because the entries of all traffic-agents are interleaved.
+func agentLogSource(pod *core.Pod) string {
+	return pod.Name + "." + pod.Namespace + "/" + agentconfig.ContainerName
+}
+
+// streamPodLog sends the log entries of the given container to the send function. Entries that don't
+// mention the given session ID are skipped unless the session ID is empty.
+func streamPodLog(
+	ctx context.Context,
+	rq *connector.StreamLogsRequest,
+	podsAPI typed.PodInterface,
+	pod *core.Pod,
+	container, source, sessionID string,
+	send func(*connector.LogEntry) error,
+) {
+	opts := core.PodLogOptions{Container: container, Follow: rq.Follow}
+	if rq.Tail >= 0 {
+		tail := int64(rq.Tail)
+		opts.TailLines = &tail
+	}
+	rc, err := podsAPI.GetLogs(pod.Name, &opts).Stream(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			_ = send(&connector.LogEntry{Source: source, Text: fmt.Sprintf("failed to get log: %v", err)})
+		}
+		return
+	}
+	defer rc.Close()
+	sc := bufio.NewScanner(rc)
+	for sc.Scan() {
+		line := sc.Text()
+		if sessionID != "" && !strings.Contains(line, sessionID) {
+			continue
+		}
+		if send(&connector.LogEntry{Source: source, Text: line}) != nil {
+			return
+		}
+	}
+	if err = sc.Err(); err != nil && ctx.Err() == nil {
+		dlog.Errorf(ctx, "failed to read log of %s: %v", source, err)
+	}
+}
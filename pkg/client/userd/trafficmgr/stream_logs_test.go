@@ -0,0 +1,36 @@
+package trafficmgr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	core "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentconfig"
+)
+
+func TestStreamPodLog(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	pod := &core.Pod{ObjectMeta: meta.ObjectMeta{Name: "echo-1", Namespace: "default"}}
+	podsAPI := fake.NewSimpleClientset(pod).CoreV1().Pods("default")
+
+	var entries []*connector.LogEntry
+	send := func(le *connector.LogEntry) error {
+		entries = append(entries, le)
+		return nil
+	}
+	streamPodLog(ctx, &connector.StreamLogsRequest{Tail: -1}, podsAPI, pod, agentconfig.ContainerName, agentLogSource(pod), "", send)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "echo-1.default/traffic-agent", entries[0].Source)
+	assert.Equal(t, "fake logs", entries[0].Text)
+
+	// Lines that don't mention the session are skipped.
+	entries = nil
+	streamPodLog(ctx, &connector.StreamLogsRequest{Tail: -1}, podsAPI, pod, agentconfig.ContainerName, agentLogSource(pod), "session-1", send)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,118 @@
+package trafficmgr
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/common"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+func (s *session) takeOverRequestWatcher(ctx context.Context) error {
+	return runWithRetry(ctx, s._takeOverRequestWatcher)
+}
+
+func (s *session) _takeOverRequestWatcher(ctx context.Context) error {
+	stream, err := s.managerClient.WatchTakeOverRequests(ctx, s.sessionInfo)
+	if err != nil {
+		return err
+	}
+	for {
+		rq, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil || status.Code(err) == codes.Unimplemented {
+				// Older traffic-managers don't hand over intercepts.
+				return nil
+			}
+			return err
+		}
+		dlog.Infof(ctx, "%s asks to take over intercept %s. Use \"telepresence handoff accept %s\" to hand it over",
+			rq.Claimant, interceptNameFromID(rq.InterceptId), interceptNameFromID(rq.InterceptId))
+		s.takeOverLock.Lock()
+		s.pruneTakeOverRequests()
+		s.takeOverRequests[rq.Id] = rq
+		s.takeOverLock.Unlock()
+	}
+}
+
+// takeOver asks the traffic-manager to hand over the intercepts of other clients that conflict
+// with the given spec. It returns nil when no conflicting intercepts remain.
+func (s *session) takeOver(ctx context.Context, spec *manager.InterceptSpec) *rpc.InterceptResult {
+	dlog.Debugf(ctx, "asking the owners of intercepts conflicting with %s to hand them over", spec.Name)
+	tr, err := s.managerClient.TakeOverIntercept(ctx, &manager.TakeOverRequest{
+		Session:       s.sessionInfo,
+		InterceptSpec: spec,
+	})
+	if err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			return InterceptError(common.InterceptError_TRAFFIC_MANAGER_ERROR,
+				errcat.User.New("the traffic-manager is too old to hand over intercepts"))
+		}
+		return managerInterceptError(err)
+	}
+	if !tr.Accepted {
+		return InterceptError(common.InterceptError_TAKE_OVER_REFUSED, errcat.User.New(tr.Message))
+	}
+	return nil
+}
+
+// interceptNameFromID returns the name part of an intercept ID of the form <session ID>:<name>.
+func interceptNameFromID(id string) string {
+	return id[strings.IndexByte(id, ':')+1:]
+}
+
+// pruneTakeOverRequests removes expired requests. Must be called with the takeOverLock held.
+func (s *session) pruneTakeOverRequests() {
+	now := time.Now()
+	for id, rq := range s.takeOverRequests {
+		if rq.ExpiresAt != nil && rq.ExpiresAt.AsTime().Before(now) {
+			delete(s.takeOverRequests, id)
+		}
+	}
+}
+
+// TakeOverRequests returns the pending requests from other clients to take over intercepts of
+// this session.
+func (s *session) TakeOverRequests() []*manager.TakeOverRequest {
+	s.takeOverLock.Lock()
+	defer s.takeOverLock.Unlock()
+	s.pruneTakeOverRequests()
+	rqs := make([]*manager.TakeOverRequest, 0, len(s.takeOverRequests))
+	for _, rq := range s.takeOverRequests {
+		rqs = append(rqs, rq)
+	}
+	return rqs
+}
+
+// AnswerTakeOver accepts or rejects the pending take-over request with the given id. The intercept
+// is removed before the acceptance is sent to the traffic-manager.
+func (s *session) AnswerTakeOver(ctx context.Context, id string, accept bool) error {
+	s.takeOverLock.Lock()
+	s.pruneTakeOverRequests()
+	rq, ok := s.takeOverRequests[id]
+	delete(s.takeOverRequests, id)
+	s.takeOverLock.Unlock()
+	if !ok {
+		return errcat.User.Newf("there is no pending request %q to take over an intercept", id)
+	}
+	if accept {
+		name := interceptNameFromID(rq.InterceptId)
+		if err := s.self.RemoveIntercept(ctx, name); err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		dlog.Infof(ctx, "Intercept %s handed over to %s", name, rq.Claimant)
+	}
+	_, err := s.managerClient.AnswerTakeOver(ctx, &manager.TakeOverAnswer{
+		Session: s.sessionInfo,
+		Id:      id,
+		Accept:  accept,
+	})
+	return err
+}
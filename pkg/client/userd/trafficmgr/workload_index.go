@@ -0,0 +1,107 @@
+package trafficmgr
+
+import (
+	"context"
+	"sync"
+
+	apps "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+)
+
+// workloadIndex indexes the workloads of a namespace on their labels, so that the workloads that match a
+// service selector can be found without matching the selector against every workload in the namespace.
+type workloadIndex struct {
+	byLabel map[string][]k8sapi.Workload
+}
+
+func labelKey(k, v string) string {
+	return k + "=" + v
+}
+
+// forwardWorkloadEvents discards the index each time the workload watchers report a change to their caches,
+// and then broadcasts the change on the given cond. The index is therefore never stale when a subscriber of
+// that cond looks up workloads. It returns when the context is cancelled.
+func (nw *namespacedWASWatcher) forwardWorkloadEvents(c context.Context, cond *sync.Cond) {
+	nw.wlCond.L.Lock()
+	defer nw.wlCond.L.Unlock()
+	for c.Err() == nil {
+		nw.wlCond.Wait()
+		if c.Err() != nil {
+			return
+		}
+		nw.indexLock.Lock()
+		nw.index = nil
+		nw.indexLock.Unlock()
+		cond.Broadcast()
+	}
+}
+
+// workloadIndex returns the index of the workloads in the caches of the workload watchers. The index is built
+// from the caches when it's first needed after a change.
+func (nw *namespacedWASWatcher) workloadIndex(c context.Context) (*workloadIndex, error) {
+	nw.indexLock.Lock()
+	defer nw.indexLock.Unlock()
+	if nw.index != nil {
+		return nw.index, nil
+	}
+	var wls []k8sapi.Workload
+	for i, wlw := range nw.wlWatchers {
+		os, err := wlw.List(c)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range os {
+			switch i {
+			case deployments:
+				wls = append(wls, k8sapi.Deployment(o.(*apps.Deployment)))
+			case replicasets:
+				wls = append(wls, k8sapi.ReplicaSet(o.(*apps.ReplicaSet)))
+			case statefulsets:
+				wls = append(wls, k8sapi.StatefulSet(o.(*apps.StatefulSet)))
+			}
+		}
+	}
+	nw.index = newWorkloadIndex(wls)
+	return nw.index, nil
+}
+
+func newWorkloadIndex(wls []k8sapi.Workload) *workloadIndex {
+	idx := &workloadIndex{
+		byLabel: make(map[string][]k8sapi.Workload),
+	}
+	for _, wl := range wls {
+		for k, v := range wl.GetLabels() {
+			lk := labelKey(k, v)
+			idx.byLabel[lk] = append(idx.byLabel[lk], wl)
+		}
+	}
+	return idx
+}
+
+// matching returns the workloads with labels that match the given selector. The workloads are returned in the
+// order that they were added to the index.
+func (idx *workloadIndex) matching(sm map[string]string) []k8sapi.Workload {
+	// Only the workloads that have the least common label of the selector need to be considered.
+	var candidates []k8sapi.Workload
+	first := true
+	for k, v := range sm {
+		wls := idx.byLabel[labelKey(k, v)]
+		if len(wls) == 0 {
+			return nil
+		}
+		if first || len(wls) < len(candidates) {
+			candidates = wls
+			first = false
+		}
+	}
+	selector := labels.SelectorFromSet(sm)
+	var matching []k8sapi.Workload
+	for _, wl := range candidates {
+		if selector.Matches(labels.Set(wl.GetLabels())) {
+			matching = append(matching, wl)
+		}
+	}
+	return matching
+}
@@ -0,0 +1,137 @@
+package trafficmgr
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apps "k8s.io/api/apps/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/datawire/k8sapi/pkg/k8sapi"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/connector"
+)
+
+func deployment(name string, lbs map[string]string) k8sapi.Workload {
+	return k8sapi.Deployment(&apps.Deployment{ObjectMeta: meta.ObjectMeta{
+		Name:      name,
+		Namespace: "default",
+		UID:       types.UID(name),
+		Labels:    lbs,
+	}})
+}
+
+func workloadNames(wls []k8sapi.Workload) []string {
+	names := make([]string, len(wls))
+	for i, wl := range wls {
+		names[i] = wl.GetName()
+	}
+	return names
+}
+
+func TestWorkloadIndex_matching(t *testing.T) {
+	wls := []k8sapi.Workload{
+		deployment("echo", map[string]string{"app": "echo", "tier": "backend"}),
+		deployment("echo-canary", map[string]string{"app": "echo", "tier": "backend", "track": "canary"}),
+		deployment("web", map[string]string{"app": "web", "tier": "frontend"}),
+	}
+	for i := 0; i < 100; i++ {
+		wls = append(wls, deployment(fmt.Sprintf("other-%d", i), map[string]string{"app": fmt.Sprintf("other-%d", i), "tier": "backend"}))
+	}
+	idx := newWorkloadIndex(wls)
+	assert.Equal(t, []string{"echo", "echo-canary"}, workloadNames(idx.matching(map[string]string{"app": "echo"})))
+	assert.Equal(t, []string{"echo", "echo-canary"}, workloadNames(idx.matching(map[string]string{"app": "echo", "tier": "backend"})))
+	assert.Equal(t, []string{"echo-canary"}, workloadNames(idx.matching(map[string]string{"tier": "backend", "track": "canary"})))
+	assert.Empty(t, idx.matching(map[string]string{"app": "web", "tier": "backend"}))
+	assert.Empty(t, idx.matching(map[string]string{"app": "nope"}))
+	assert.Len(t, idx.matching(map[string]string{"tier": "backend"}), 102)
+}
+
+func TestWorkloadIndex_forwardWorkloadEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(dlog.NewTestContext(t, false))
+	nw := &namespacedWASWatcher{index: newWorkloadIndex(nil)}
+	nw.wlCond.L = &sync.Mutex{}
+	var mu sync.Mutex
+	cond := sync.NewCond(&mu)
+	forwarded := k8sapi.Subscribe(ctx, cond)
+	done := make(chan struct{})
+	go func() {
+		nw.forwardWorkloadEvents(ctx, cond)
+		close(done)
+	}()
+
+	// A change reported by the workload watchers discards the index before it's forwarded.
+	require.Eventually(t, func() bool {
+		nw.wlCond.Broadcast()
+		select {
+		case <-forwarded:
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, time.Millisecond)
+	nw.indexLock.Lock()
+	assert.Nil(t, nw.index)
+	nw.indexLock.Unlock()
+
+	cancel()
+	nw.wlCond.L.Lock()
+	nw.wlCond.Broadcast()
+	nw.wlCond.L.Unlock()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("forwardWorkloadEvents didn't return when its context was cancelled")
+	}
+}
+
+func TestDiffSnapshot(t *testing.T) {
+	wi := func(name, state string) *rpc.WorkloadInfo {
+		return &rpc.WorkloadInfo{Name: name, Uid: name, NotInterceptableReason: state}
+	}
+	sent := map[string]*rpc.WorkloadInfo{"a": wi("a", ""), "b": wi("b", ""), "c": wi("c", "")}
+
+	// No change
+	assert.Nil(t, diffSnapshot(sent, &rpc.WorkloadInfoSnapshot{Workloads: []*rpc.WorkloadInfo{wi("a", ""), wi("b", ""), wi("c", "")}}, true))
+
+	// One changed, one added, and one removed
+	full := &rpc.WorkloadInfoSnapshot{Workloads: []*rpc.WorkloadInfo{wi("a", ""), wi("b", "changed"), wi("d", "")}}
+	inc := diffSnapshot(sent, full, true)
+	require.NotNil(t, inc)
+	assert.Equal(t, []string{"b", "d"}, []string{inc.Workloads[0].Name, inc.Workloads[1].Name})
+	assert.Equal(t, []string{"c"}, inc.RemovedUids)
+	assert.Len(t, sent, 3)
+	assert.Equal(t, "changed", sent["b"].NotInterceptableReason)
+
+	// Non-incremental returns the complete snapshot
+	full = &rpc.WorkloadInfoSnapshot{Workloads: []*rpc.WorkloadInfo{wi("a", "")}}
+	assert.Same(t, full, diffSnapshot(sent, full, false))
+	assert.Len(t, sent, 1)
+}
+
+func BenchmarkWorkloadIndex_matching(b *testing.B) {
+	// A namespace with 5000 workloads, each targeted by its own service.
+	const n = 5000
+	wls := make([]k8sapi.Workload, n)
+	selectors := make([]map[string]string, n)
+	for i := range wls {
+		app := fmt.Sprintf("app-%d", i)
+		wls[i] = deployment(app, map[string]string{"app": app, "tier": "backend"})
+		selectors[i] = map[string]string{"app": app}
+	}
+	idx := newWorkloadIndex(wls)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, sm := range selectors {
+			if len(idx.matching(sm)) != 1 {
+				b.Fatal("expected one match")
+			}
+		}
+	}
+}
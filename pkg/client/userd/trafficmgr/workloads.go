@@ -9,6 +9,7 @@ import (
 
 	apps "k8s.io/api/apps/v1"
 	core "k8s.io/api/core/v1"
+	k8sErrors "k8s.io/apimachinery/pkg/api/errors"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -17,6 +18,7 @@ import (
 
 	"github.com/datawire/dlib/dlog"
 	"github.com/datawire/k8sapi/pkg/k8sapi"
+	"github.com/telepresenceio/telepresence/v2/pkg/agentmap"
 )
 
 type workloadsAndServicesWatcher struct {
@@ -36,6 +38,13 @@ const (
 type namespacedWASWatcher struct {
 	svcWatcher *k8sapi.Watcher[*core.Service]
 	wlWatchers [3]*k8sapi.Watcher[runtime.Object]
+
+	// wlCond is broadcast by the workload watchers when their caches change. The index is then discarded
+	// before the change is broadcast to the subscribers of the workloadsAndServicesWatcher.
+	wlCond    sync.Cond
+	stop      context.CancelFunc
+	indexLock sync.Mutex
+	index     *workloadIndex
 }
 
 // svcEquals compare only the Service fields that are of interest to Telepresence. They are
@@ -145,12 +154,15 @@ func newNamespaceWatcher(c context.Context, namespace string, cond *sync.Cond) *
 	appsGetter := ki.AppsV1().RESTClient()
 	w := &namespacedWASWatcher{
 		svcWatcher: k8sapi.NewWatcher("services", ki.CoreV1().RESTClient(), cond, k8sapi.WithEquals(svcEquals), k8sapi.WithNamespace[*core.Service](namespace)),
-		wlWatchers: [3]*k8sapi.Watcher[runtime.Object]{
-			k8sapi.NewWatcher("deployments", appsGetter, cond, k8sapi.WithEquals(workloadEquals), k8sapi.WithNamespace[runtime.Object](namespace)),
-			k8sapi.NewWatcher("replicasets", appsGetter, cond, k8sapi.WithEquals(workloadEquals), k8sapi.WithNamespace[runtime.Object](namespace)),
-			k8sapi.NewWatcher("statefulsets", appsGetter, cond, k8sapi.WithEquals(workloadEquals), k8sapi.WithNamespace[runtime.Object](namespace)),
-		},
 	}
+	w.wlCond.L = &sync.Mutex{}
+	w.wlWatchers = [3]*k8sapi.Watcher[runtime.Object]{
+		k8sapi.NewWatcher("deployments", appsGetter, &w.wlCond, k8sapi.WithEquals(workloadEquals), k8sapi.WithNamespace[runtime.Object](namespace)),
+		k8sapi.NewWatcher("replicasets", appsGetter, &w.wlCond, k8sapi.WithEquals(workloadEquals), k8sapi.WithNamespace[runtime.Object](namespace)),
+		k8sapi.NewWatcher("statefulsets", appsGetter, &w.wlCond, k8sapi.WithEquals(workloadEquals), k8sapi.WithNamespace[runtime.Object](namespace)),
+	}
+	c, w.stop = context.WithCancel(c)
+	go w.forwardWorkloadEvents(c, cond)
 	return w
 }
 
@@ -159,6 +171,11 @@ func (nw *namespacedWASWatcher) cancel() {
 	for _, w := range nw.wlWatchers {
 		w.Cancel()
 	}
+	nw.stop()
+	// The lock ensures that forwardWorkloadEvents is either waiting or about to check the context.
+	nw.wlCond.L.Lock()
+	nw.wlCond.Broadcast()
+	nw.wlCond.L.Unlock()
 }
 
 func (nw *namespacedWASWatcher) hasSynced() bool {
@@ -344,38 +361,23 @@ func (nw *namespacedWASWatcher) findMatchingWorkloads(c context.Context, svc *co
 		}
 	}
 
-	var selector labels.Selector
-	if sm := svc.Spec.Selector; len(sm) > 0 {
-		selector = labels.SelectorFromSet(sm)
-	} else {
-		// There will be no matching workloads for this service
-		return nil, nil
+	sm := svc.Spec.Selector
+	if len(sm) == 0 {
+		// A service without a selector may still target pods using manually maintained Endpoints.
+		return findEndpointsWorkloads(c, svc)
 	}
 
+	idx, err := nw.workloadIndex(c)
+	if err != nil {
+		return nil, err
+	}
 	var allWls []k8sapi.Workload
-	for i, wlw := range nw.wlWatchers {
-		wls, err := wlw.List(c)
+	for _, wl := range idx.matching(sm) {
+		owl, err := nw.maybeReplaceWithOwner(c, wl)
 		if err != nil {
 			return nil, err
 		}
-		for _, o := range wls {
-			var wl k8sapi.Workload
-			switch i {
-			case deployments:
-				wl = k8sapi.Deployment(o.(*apps.Deployment))
-			case replicasets:
-				wl = k8sapi.ReplicaSet(o.(*apps.ReplicaSet))
-			case statefulsets:
-				wl = k8sapi.StatefulSet(o.(*apps.StatefulSet))
-			}
-			if selector.Matches(labels.Set(wl.GetLabels())) {
-				owl, err := nw.maybeReplaceWithOwner(c, wl)
-				if err != nil {
-					return nil, err
-				}
-				allWls = append(allWls, owl)
-			}
-		}
+		allWls = append(allWls, owl)
 	}
 
 	// Prefer entries with matching ports. I.e. strip all non-matching if matching entries
@@ -386,6 +388,22 @@ func (nw *namespacedWASWatcher) findMatchingWorkloads(c context.Context, svc *co
 	return allWls, nil
 }
 
+// findEndpointsWorkloads returns the workloads of the pods that are targeted by the Endpoints of the given service.
+func findEndpointsWorkloads(c context.Context, svc *core.Service) ([]k8sapi.Workload, error) {
+	if svc.Spec.Type == core.ServiceTypeExternalName {
+		// There will be no matching workloads for this service
+		return nil, nil
+	}
+	ep, err := k8sapi.GetK8sInterface(c).CoreV1().Endpoints(svc.Namespace).Get(c, svc.Name, meta.GetOptions{})
+	if err != nil {
+		if k8sErrors.IsNotFound(err) || k8sErrors.IsForbidden(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return agentmap.EndpointsWorkloads(c, make(map[string]k8sapi.Workload), ep)
+}
+
 func (nw *namespacedWASWatcher) maybeReplaceWithOwner(c context.Context, wl k8sapi.Workload) (k8sapi.Workload, error) {
 	var err error
 	for _, or := range wl.GetOwnerReferences() {
@@ -23,6 +23,27 @@ func Semver() semver.Version {
 	return version.Structured
 }
 
+// CheckHandoverCompatibility returns an error unless a root daemon of version "from" can hand over
+// its network state to a root daemon of version "to". A handover is only permitted between releases
+// that share the same major version, and never to an older release.
+func CheckHandoverCompatibility(from, to semver.Version) error {
+	if from.Major != to.Major {
+		return fmt.Errorf("cannot hand over from major version %d to major version %d", from.Major, to.Major)
+	}
+	if to.LT(from) {
+		return fmt.Errorf("cannot hand over from version %s to the older version %s", from, to)
+	}
+	return nil
+}
+
+// firstHandoverVersion is the first release of the root daemon that accepts handover requests.
+var firstHandoverVersion = semver.MustParse("2.16.0-0") //nolint:gochecknoglobals // constant
+
+// SupportsHandover returns true if a root daemon of the given version listens for handover requests.
+func SupportsHandover(v semver.Version) bool {
+	return v.GE(firstHandoverVersion)
+}
+
 func Executable() (string, error) {
 	return version.GetExecutable()
 }
@@ -7,6 +7,7 @@ import (
 	"runtime"
 	"testing"
 
+	"github.com/blang/semver"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/telepresenceio/telepresence/v2/pkg/client"
@@ -133,3 +134,35 @@ func TestGetInstallMechanism(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckHandoverCompatibility(t *testing.T) {
+	tests := []struct {
+		from string
+		to   string
+		ok   bool
+	}{
+		{"2.15.1", "2.16.0", true},
+		{"2.16.0", "2.16.0", true},
+		{"2.16.0-rc.1", "2.16.0", true},
+		{"2.16.0", "2.15.1", false},
+		{"2.16.0", "3.0.0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+"->"+tt.to, func(t *testing.T) {
+			err := client.CheckHandoverCompatibility(semver.MustParse(tt.from), semver.MustParse(tt.to))
+			if tt.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestSupportsHandover(t *testing.T) {
+	assert.False(t, client.SupportsHandover(semver.MustParse("2.15.1")))
+	assert.False(t, client.SupportsHandover(semver.MustParse("0.0.0-devel")))
+	assert.True(t, client.SupportsHandover(semver.MustParse("2.16.0-rc.1")))
+	assert.True(t, client.SupportsHandover(semver.MustParse("2.16.0")))
+	assert.True(t, client.SupportsHandover(semver.MustParse("3.0.0")))
+}
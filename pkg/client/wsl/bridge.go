@@ -0,0 +1,84 @@
+// Package wsl implements the bridge that lets a user daemon in a WSL2 distribution use the root daemon on the
+// Windows host. The root daemon listens to a TCP port in addition to its named pipe, and the user daemon dials
+// that port instead of starting a local root daemon. The bridge is protected by a token that the root daemon
+// writes to a file that only the Windows user, and thereby the distributions of that user, can read.
+package wsl
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// tokenFileName is the name of the file in the user cache of the Windows user that holds the token.
+	tokenFileName = "wsl-bridge.token"
+
+	// tokenKey is the gRPC metadata key that the token is sent with.
+	tokenKey = "x-telepresence-wsl-token"
+)
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func checkToken(ctx context.Context, token string) error {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, t := range md.Get(tokenKey) {
+			if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+				return nil
+			}
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid or missing WSL bridge token")
+}
+
+// ServerOptions returns the options of a gRPC server that only serves calls that carry the given token.
+func ServerOptions(token string) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := checkToken(ctx, token); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := checkToken(ss.Context(), token); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	}
+}
+
+// tokenCredentials sends the token with each call.
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{tokenKey: string(t)}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// dialOptions returns the options of a gRPC client that sends the given token with each call.
+func dialOptions(token string) []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithPerRPCCredentials(tokenCredentials(strings.TrimSpace(token))),
+		grpc.WithNoProxy(),
+	}
+}
@@ -0,0 +1,111 @@
+package wsl
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+)
+
+// tokenFileGlob matches the token files that the root daemon writes to the local application data of the
+// Windows users, as seen from a WSL2 distribution.
+const tokenFileGlob = "/mnt/*/Users/*/AppData/Local/telepresence/" + tokenFileName
+
+var (
+	inWSL     bool      //nolint:gochecknoglobals // constant once determined
+	inWSLOnce sync.Once //nolint:gochecknoglobals // guards inWSL
+)
+
+// runningInWSL returns true when this process runs in a WSL2 distribution.
+func runningInWSL() bool {
+	inWSLOnce.Do(func() {
+		if v, err := os.ReadFile("/proc/version"); err == nil {
+			inWSL = strings.Contains(string(v), "microsoft") || strings.Contains(string(v), "WSL")
+		}
+	})
+	return inWSL
+}
+
+// bridge returns the address of the root daemon on the Windows host, and the file with its token, when this
+// process runs in a WSL2 distribution and the bridge is enabled.
+func bridge(ctx context.Context) (string, string, bool) {
+	cfg := client.GetConfig(ctx).OSSpecific().WSL
+	if cfg.BridgePort == 0 || !runningInWSL() {
+		return "", "", false
+	}
+	host := cfg.Host
+	if host == "" {
+		gw, err := readDefaultGateway()
+		if err != nil {
+			dlog.Errorf(ctx, "unable to determine the address of the Windows host: %v", err)
+			return "", "", false
+		}
+		host = gw.String()
+	}
+	tokenFile := cfg.TokenFile
+	if tokenFile == "" {
+		tokenFile = findTokenFile()
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(cfg.BridgePort))), tokenFile, true
+}
+
+// findTokenFile returns the most recently written token file, or the first candidate when no token file exists.
+func findTokenFile() string {
+	files, _ := filepath.Glob(tokenFileGlob)
+	if len(files) == 0 {
+		return strings.Replace(strings.Replace(tokenFileGlob, "*", "c", 1), "*", os.Getenv("USER"), 1)
+	}
+	newest := files[0]
+	var newestInfo os.FileInfo
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil && (newestInfo == nil || fi.ModTime().After(newestInfo.ModTime())) {
+			newest, newestInfo = f, fi
+		}
+	}
+	return newest
+}
+
+func readDefaultGateway() (net.IP, error) {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return defaultGateway(f)
+}
+
+// defaultGateway returns the gateway of the default route in the given routing table, which is in the format of
+// /proc/net/route.
+func defaultGateway(r io.Reader) (net.IP, error) {
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		b, err := hex.DecodeString(fields[2])
+		if err != nil || len(b) != 4 {
+			continue
+		}
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(b))
+		if !ip.IsUnspecified() {
+			return ip, nil
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("no default route found")
+}
@@ -0,0 +1,23 @@
+package wsl
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultGateway(t *testing.T) {
+	const routes = `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	0010A8C0	00000000	0001	0	0	0	00F0FFFF	0	0	0
+eth0	00000000	0110A8C0	0003	0	0	0	00000000	0	0	0
+`
+	ip, err := defaultGateway(strings.NewReader(routes))
+	require.NoError(t, err)
+	assert.Equal(t, net.IPv4(192, 168, 16, 1).To4(), ip)
+
+	_, err = defaultGateway(strings.NewReader(strings.SplitN(routes, "\n", 3)[0]))
+	assert.Error(t, err)
+}
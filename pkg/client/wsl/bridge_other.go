@@ -0,0 +1,12 @@
+//go:build !linux
+
+package wsl
+
+import (
+	"context"
+)
+
+// bridge returns false, because only a process in a WSL2 distribution uses the bridge.
+func bridge(context.Context) (string, string, bool) {
+	return "", "", false
+}
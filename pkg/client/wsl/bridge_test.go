@@ -0,0 +1,128 @@
+package wsl
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/common"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/daemon"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+func TestToken(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	token, err := newToken()
+	require.NoError(t, err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer(ServerOptions(token)...)
+	grpc_health_v1.RegisterHealthServer(srv, health.NewServer())
+	go func() { _ = srv.Serve(l) }()
+	defer srv.Stop()
+
+	check := func(token string) error {
+		conn, err := grpc.DialContext(ctx, l.Addr().String(), dialOptions(token)...)
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+		return err
+	}
+	assert.NoError(t, check(token))
+	assert.NoError(t, check(token+"\n"), "trailing whitespace of the token file is ignored")
+	assert.Equal(t, codes.Unauthenticated, status.Code(check("invalid")))
+	assert.Equal(t, codes.Unauthenticated, status.Code(checkToken(context.Background(), token)))
+}
+
+// rootDaemon calls the ManagerProxy of the user daemon that connects, the way a session does.
+type rootDaemon struct {
+	rpc.UnimplementedDaemonServer
+}
+
+func (rootDaemon) Connect(ctx context.Context, _ *rpc.OutboundInfo) (*rpc.DaemonStatus, error) {
+	ud := BridgedUserDaemon(ctx)
+	if ud == nil {
+		return nil, status.Error(codes.FailedPrecondition, "the call didn't arrive through the bridge")
+	}
+	ctx, cancel := context.WithTimeout(WithUserDaemon(context.Background(), ud), 10*time.Second)
+	defer cancel()
+	conn, err := DialUserDaemon(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	v, err := connector.NewManagerProxyClient(conn).Version(ctx, &emptypb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	return &rpc.DaemonStatus{Version: &common.VersionInfo{Version: v.Version}}, nil
+}
+
+type managerProxy struct {
+	connector.UnimplementedManagerProxyServer
+}
+
+func (managerProxy) Version(context.Context, *emptypb.Empty) (*manager.VersionInfo2, error) {
+	return &manager.VersionInfo2{Name: "traffic-manager", Version: "2.99.0"}, nil
+}
+
+func TestReverseConnections(t *testing.T) {
+	ctx, cancel := context.WithCancel(dlog.NewTestContext(t, false))
+	defer cancel()
+	token, err := newToken()
+	require.NoError(t, err)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte(token+"\n"), 0o600))
+
+	// The root daemon on the Windows host.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	b := NewBridge(token)
+	rootSrv := grpc.NewServer(b.ServerOptions()...)
+	rpc.RegisterDaemonServer(rootSrv, rootDaemon{})
+	go func() { _ = rootSrv.Serve(b.Listener(ctx, l)) }()
+	defer rootSrv.Stop()
+	addr := l.Addr().String()
+
+	// The user daemon in the WSL2 distribution, which only dials the root daemon.
+	userSrv := grpc.NewServer()
+	connector.RegisterManagerProxyServer(userSrv, managerProxy{})
+	go func() { _ = userSrv.Serve(newReverseListener(ctx, addr, tokenFile, "ud-1")) }()
+	defer userSrv.Stop()
+
+	conn, err := grpc.DialContext(ctx, addr, append(dialOptions(token), grpc.WithPerRPCCredentials(idCredentials("ud-1")))...)
+	require.NoError(t, err)
+	defer conn.Close()
+	for i := 0; i < 2; i++ {
+		st, err := rpc.NewDaemonClient(conn).Connect(ctx, &rpc.OutboundInfo{})
+		require.NoError(t, err)
+		assert.Equal(t, "2.99.0", st.Version.Version, fmt.Sprintf("call %d", i))
+	}
+
+	// A reverse connection with an invalid token is closed by the bridge.
+	rc, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer rc.Close()
+	_, err = fmt.Fprintf(rc, "%sinvalid ud-2\n", reversePreamble)
+	require.NoError(t, err)
+	_ = rc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = rc.Read(make([]byte, 1))
+	assert.Error(t, err)
+	assert.False(t, os.IsTimeout(err), "the connection is closed rather than kept")
+}
@@ -0,0 +1,45 @@
+package wsl
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/client/socket"
+)
+
+// Bridged returns true when the root daemon on the Windows host is used instead of a local root daemon. That's the
+// case when this process runs in a WSL2 distribution and the bridge is enabled in the configuration.
+func Bridged(ctx context.Context) bool {
+	_, _, ok := bridge(ctx)
+	return ok
+}
+
+// DialRootDaemon dials the root daemon. That's the root daemon on the Windows host when the bridge is used, and
+// the root daemon that listens to the socket otherwise. Calls to the root daemon on the Windows host carry the
+// ID of this user daemon, so that the root daemon can call back using the connections of ListenForRootDaemon.
+func DialRootDaemon(ctx context.Context, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	addr, tokenFile, ok := bridge(ctx)
+	if !ok {
+		return socket.Dial(ctx, socket.RootDaemonPath(ctx), opts...)
+	}
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the WSL bridge token: %w; this usually means that the root daemon on the Windows host "+
+			"isn't running or that the bridge isn't enabled there", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, addr, append(append(dialOptions(string(token)),
+		grpc.WithPerRPCCredentials(idCredentials(getUserDaemonID())),
+		grpc.WithBlock(),
+		grpc.FailOnNonTempDialError(true),
+	), opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to dial the root daemon on the Windows host at %s: %w", addr, err)
+	}
+	return conn, nil
+}
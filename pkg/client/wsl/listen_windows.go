@@ -0,0 +1,77 @@
+package wsl
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/filelocation"
+)
+
+func tokenFile(ctx context.Context) string {
+	return filepath.Join(filelocation.AppUserCacheDir(ctx), tokenFileName)
+}
+
+// Listen writes a new token to the token file, and returns it together with listeners on the given port of the
+// loopback interface and of the virtual interfaces that WSL2 distributions reach the Windows host through.
+func Listen(ctx context.Context, port uint16) ([]net.Listener, string, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, "", err
+	}
+	tf := tokenFile(ctx)
+	if err = os.MkdirAll(filepath.Dir(tf), 0o700); err != nil {
+		return nil, "", err
+	}
+	if err = os.WriteFile(tf, []byte(token), 0o600); err != nil {
+		return nil, "", err
+	}
+
+	addrs := []string{"127.0.0.1"}
+	ifs, err := net.Interfaces()
+	if err != nil {
+		return nil, "", err
+	}
+	for _, ifc := range ifs {
+		if ifc.Flags&net.FlagUp == 0 || !strings.Contains(ifc.Name, "WSL") {
+			continue
+		}
+		ias, err := ifc.Addrs()
+		if err != nil {
+			dlog.Errorf(ctx, "unable to get the addresses of interface %q: %v", ifc.Name, err)
+			continue
+		}
+		for _, ia := range ias {
+			if ipn, ok := ia.(*net.IPNet); ok && ipn.IP.To4() != nil {
+				addrs = append(addrs, ipn.IP.String())
+			}
+		}
+	}
+
+	ls := make([]net.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		l, err := net.Listen("tcp", net.JoinHostPort(addr, strconv.Itoa(int(port))))
+		if err != nil {
+			for _, l := range ls {
+				_ = l.Close()
+			}
+			RemoveToken(ctx)
+			return nil, "", fmt.Errorf("unable to listen for the WSL bridge: %w", err)
+		}
+		dlog.Infof(ctx, "WSL bridge listening on %s", l.Addr())
+		ls = append(ls, l)
+	}
+	return ls, token, nil
+}
+
+// RemoveToken removes the token file, so that user daemons in WSL2 no longer find it.
+func RemoveToken(ctx context.Context) {
+	if err := os.Remove(tokenFile(ctx)); err != nil && !os.IsNotExist(err) {
+		dlog.Errorf(ctx, "unable to remove the WSL bridge token: %v", err)
+	}
+}
@@ -0,0 +1,366 @@
+package wsl
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client/socket"
+)
+
+// The root daemon on the Windows host can't dial a user daemon in a WSL2 distribution, so the user daemon dials
+// reverse connections to the bridge instead. A reverse connection starts with a preamble that carries the token
+// and the ID of the user daemon. The root daemon keeps it until it needs to call that user daemon, and then sends
+// reverseGo and uses it as the transport of a gRPC client connection. The user daemon serves its gRPC API on the
+// connection once it has received reverseGo.
+const (
+	// reversePreamble starts a reverse connection. It's followed by "<token> <user daemon ID>\n".
+	reversePreamble = "TPWSL/1 "
+
+	// reverseGo is sent by the root daemon when it starts to use a reverse connection.
+	reverseGo = byte(1)
+
+	// preambleTimeout is the time that the bridge waits for the first bytes of a connection.
+	preambleTimeout = 10 * time.Second
+
+	// userDaemonIDKey is the gRPC metadata key that the ID of the user daemon is sent with.
+	userDaemonIDKey = "x-telepresence-wsl-user-daemon"
+)
+
+// Bridge is the root daemon's end of the bridge. It keeps the reverse connections of the user daemons.
+type Bridge struct {
+	token string
+	mu    sync.Mutex
+	idle  map[string]chan net.Conn
+}
+
+// NewBridge returns a bridge that only serves calls and reverse connections that carry the given token.
+func NewBridge(token string) *Bridge {
+	return &Bridge{token: token, idle: make(map[string]chan net.Conn)}
+}
+
+// UserDaemon is a user daemon that called the root daemon through the bridge.
+type UserDaemon struct {
+	bridge *Bridge
+	id     string
+}
+
+type userDaemonKey struct{}
+
+// WithUserDaemon returns a context that makes DialUserDaemon dial the given user daemon.
+func WithUserDaemon(ctx context.Context, ud *UserDaemon) context.Context {
+	return context.WithValue(ctx, userDaemonKey{}, ud)
+}
+
+// BridgedUserDaemon returns the user daemon that made the call that the given context belongs to, or nil when
+// the call didn't arrive through the bridge.
+func BridgedUserDaemon(ctx context.Context) *UserDaemon {
+	ud, _ := ctx.Value(userDaemonKey{}).(*UserDaemon)
+	return ud
+}
+
+// ServerOptions returns the options of a gRPC server that only serves calls that carry the token of the bridge.
+// The context of a unary call from a user daemon that serves reverse connections has that user daemon.
+func (b *Bridge) ServerOptions() []grpc.ServerOption {
+	return append(ServerOptions(b.token),
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if md, ok := metadata.FromIncomingContext(ctx); ok {
+				if ids := md.Get(userDaemonIDKey); len(ids) > 0 && ids[0] != "" {
+					ctx = WithUserDaemon(ctx, &UserDaemon{bridge: b, id: ids[0]})
+				}
+			}
+			return handler(ctx, req)
+		}))
+}
+
+// Listener returns a listener that accepts the gRPC connections of the given listener, and hands its reverse
+// connections over to the bridge.
+func (b *Bridge) Listener(ctx context.Context, l net.Listener) net.Listener {
+	bl := &bridgeListener{Listener: l, bridge: b, conns: make(chan net.Conn), done: make(chan struct{})}
+	go bl.acceptLoop(ctx)
+	return bl
+}
+
+func (b *Bridge) idleConns(id string) chan net.Conn {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch, ok := b.idle[id]
+	if !ok {
+		ch = make(chan net.Conn, 1)
+		b.idle[id] = ch
+	}
+	return ch
+}
+
+// addReverse keeps the given reverse connection of a user daemon. It replaces an idle connection of that
+// user daemon, because the user daemon dials a new one when its previous one is in use.
+func (b *Bridge) addReverse(id string, conn net.Conn) {
+	ch := b.idleConns(id)
+	for {
+		select {
+		case ch <- conn:
+			return
+		default:
+		}
+		select {
+		case old := <-ch:
+			_ = old.Close()
+		default:
+		}
+	}
+}
+
+// dialUserDaemon returns a reverse connection of the given user daemon, waiting for one if necessary.
+func (b *Bridge) dialUserDaemon(ctx context.Context, id string) (net.Conn, error) {
+	ch := b.idleConns(id)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("no connection from the user daemon in WSL: %w", ctx.Err())
+		case conn := <-ch:
+			if _, err := conn.Write([]byte{reverseGo}); err != nil {
+				// The user daemon has closed it. It dials a new one.
+				_ = conn.Close()
+				continue
+			}
+			return conn, nil
+		}
+	}
+}
+
+// DialUserDaemon dials the user daemon. That's the user daemon in a WSL2 distribution, through its reverse
+// connections, when the context has one, and the user daemon that listens to the socket otherwise.
+func DialUserDaemon(ctx context.Context, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	ud := BridgedUserDaemon(ctx)
+	if ud == nil {
+		return socket.Dial(ctx, socket.UserDaemonPath(ctx), opts...)
+	}
+	return grpc.DialContext(ctx, "passthrough:///wsl-user-daemon", append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ud.bridge.dialUserDaemon(ctx, ud.id)
+		}),
+		grpc.WithBlock(),
+	}, opts...)...)
+}
+
+// bridgeListener sorts the connections of a listener into gRPC connections, which it accepts, and reverse
+// connections, which it hands over to the bridge.
+type bridgeListener struct {
+	net.Listener
+	bridge    *Bridge
+	conns     chan net.Conn
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+func (bl *bridgeListener) acceptLoop(ctx context.Context) {
+	for {
+		conn, err := bl.Listener.Accept()
+		if err != nil {
+			bl.close(err)
+			return
+		}
+		go bl.sort(ctx, conn)
+	}
+}
+
+func (bl *bridgeListener) sort(ctx context.Context, conn net.Conn) {
+	_ = conn.SetReadDeadline(time.Now().Add(preambleTimeout))
+	br := bufio.NewReader(conn)
+	head, err := br.Peek(len(reversePreamble))
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	if string(head) == reversePreamble {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			_ = conn.Close()
+			return
+		}
+		token, id, _ := strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, reversePreamble)), " ")
+		if id == "" || subtle.ConstantTimeCompare([]byte(token), []byte(bl.bridge.token)) != 1 {
+			dlog.Errorf(ctx, "rejected a WSL bridge connection from %s with an invalid token", conn.RemoteAddr())
+			_ = conn.Close()
+			return
+		}
+		_ = conn.SetReadDeadline(time.Time{})
+		bl.bridge.addReverse(id, &bufferedConn{Conn: conn, r: br})
+		return
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	select {
+	case bl.conns <- &bufferedConn{Conn: conn, r: br}:
+	case <-bl.done:
+		_ = conn.Close()
+	}
+}
+
+func (bl *bridgeListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-bl.conns:
+		return conn, nil
+	case <-bl.done:
+		return nil, bl.err
+	}
+}
+
+func (bl *bridgeListener) Close() error {
+	err := bl.Listener.Close()
+	bl.close(net.ErrClosed)
+	return err
+}
+
+func (bl *bridgeListener) close(err error) {
+	bl.closeOnce.Do(func() {
+		bl.err = err
+		close(bl.done)
+	})
+}
+
+// bufferedConn is a connection whose first bytes have been read into a buffer.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+var (
+	userDaemonID     string    //nolint:gochecknoglobals // constant once determined
+	userDaemonIDOnce sync.Once //nolint:gochecknoglobals // guards userDaemonID
+)
+
+// getUserDaemonID returns the ID that this process identifies itself with when it's a user daemon.
+func getUserDaemonID() string {
+	userDaemonIDOnce.Do(func() {
+		b := make([]byte, 16)
+		_, _ = rand.Read(b)
+		userDaemonID = hex.EncodeToString(b)
+	})
+	return userDaemonID
+}
+
+// idCredentials sends the ID of the user daemon with each call.
+type idCredentials string
+
+func (id idCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{userDaemonIDKey: string(id)}, nil
+}
+
+func (id idCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// ListenForRootDaemon returns a listener that accepts the reverse connections that it dials to the root daemon
+// on the Windows host, so that the root daemon can call the gRPC server that serves the listener. Nil is
+// returned when the bridge isn't used. The listener is closed when the context is cancelled.
+func ListenForRootDaemon(ctx context.Context) net.Listener {
+	addr, tokenFile, ok := bridge(ctx)
+	if !ok {
+		return nil
+	}
+	return newReverseListener(ctx, addr, tokenFile, getUserDaemonID())
+}
+
+// reverseListener dials a reverse connection when it's asked to accept one, and returns it once the root
+// daemon starts to use it. A failed dial is retried until the listener is closed.
+type reverseListener struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	addr      string
+	tokenFile string
+	id        string
+}
+
+func newReverseListener(ctx context.Context, addr, tokenFile, id string) *reverseListener {
+	ctx, cancel := context.WithCancel(ctx)
+	return &reverseListener{ctx: ctx, cancel: cancel, addr: addr, tokenFile: tokenFile, id: id}
+}
+
+func (l *reverseListener) Accept() (net.Conn, error) {
+	delay := 100 * time.Millisecond
+	for {
+		conn, err := l.dial()
+		if err == nil {
+			return conn, nil
+		}
+		if l.ctx.Err() != nil {
+			return nil, net.ErrClosed
+		}
+		dlog.Debugf(l.ctx, "unable to offer a connection to the root daemon on the Windows host: %v", err)
+		select {
+		case <-l.ctx.Done():
+			return nil, net.ErrClosed
+		case <-time.After(delay):
+		}
+		if delay < 5*time.Second {
+			delay *= 2
+		}
+	}
+}
+
+func (l *reverseListener) dial() (net.Conn, error) {
+	token, err := os.ReadFile(l.tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(l.ctx, "tcp", l.addr)
+	if err != nil {
+		return nil, err
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-l.ctx.Done():
+			_ = conn.Close()
+		case <-stop:
+		}
+	}()
+	if _, err = fmt.Fprintf(conn, "%s%s %s\n", reversePreamble, strings.TrimSpace(string(token)), l.id); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	b := make([]byte, 1)
+	if _, err = conn.Read(b); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if b[0] != reverseGo {
+		_ = conn.Close()
+		return nil, errors.New("unexpected reply from the root daemon")
+	}
+	if l.ctx.Err() != nil {
+		return nil, l.ctx.Err()
+	}
+	return conn, nil
+}
+
+func (l *reverseListener) Close() error {
+	l.cancel()
+	return nil
+}
+
+func (l *reverseListener) Addr() net.Addr {
+	return &net.TCPAddr{}
+}
@@ -56,7 +56,7 @@ func NewK8sPortForwardDialer(logCtx context.Context, kubeConfig *rest.Config, k8
 	if err := setKubernetesDefaults(kubeConfig); err != nil {
 		return nil, err
 	}
-	spdyTransport, spdyUpgrader, err := spdy.RoundTripperFor(kubeConfig)
+	spdyTransport, spdyUpgrader, err := spdyRoundTripperFor(kubeConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,124 @@
+package dnet
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	spdystream "k8s.io/apimachinery/pkg/util/httpstream/spdy"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// spdyPingPeriod is the ping period that spdy.RoundTripperFor uses.
+const spdyPingPeriod = 5 * time.Second
+
+// spdyRoundTripperFor is like spdy.RoundTripperFor, but it honors the Dial function of the config, which
+// spdy.RoundTripperFor ignores. Port-forwards would otherwise bypass a dialer such as the SSHDialer.
+func spdyRoundTripperFor(config *rest.Config) (http.RoundTripper, spdy.Upgrader, error) {
+	if config.Dial == nil {
+		return spdy.RoundTripperFor(config)
+	}
+	tlsConfig, err := rest.TLSConfigFor(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	upgrader := &dialingUpgrader{dial: config.Dial, tlsConfig: tlsConfig}
+	wrapper, err := rest.HTTPWrappersForConfig(config, upgrader)
+	if err != nil {
+		return nil, nil, err
+	}
+	return wrapper, upgrader, nil
+}
+
+// dialingUpgrader is an upgrading round tripper that dials using the given dial function. Like the
+// SpdyRoundTripper, it retains the connection of the last round trip, so it must not be used for
+// concurrent upgrades.
+type dialingUpgrader struct {
+	dial      func(ctx context.Context, network, address string) (net.Conn, error)
+	tlsConfig *tls.Config
+	conn      net.Conn
+}
+
+func (u *dialingUpgrader) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = utilnet.CloneRequest(req)
+	req.Header.Add(httpstream.HeaderConnection, httpstream.HeaderUpgrade)
+	req.Header.Add(httpstream.HeaderUpgrade, spdystream.HeaderSpdy31)
+
+	ctx := req.Context()
+	conn, err := u.dial(ctx, "tcp", canonicalAddr(req))
+	if err != nil {
+		return nil, err
+	}
+	if req.URL.Scheme == "https" {
+		var tc *tls.Config
+		if u.tlsConfig != nil {
+			tc = u.tlsConfig.Clone()
+		} else {
+			tc = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		if tc.ServerName == "" {
+			tc.ServerName = req.URL.Hostname()
+		}
+		tlsConn := tls.Client(conn, tc)
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	if err = req.Write(conn); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	u.conn = conn
+	return resp, nil
+}
+
+// NewConnection validates the upgrade response and creates a SPDY connection on the connection of the
+// last round trip.
+func (u *dialingUpgrader) NewConnection(resp *http.Response) (httpstream.Connection, error) {
+	connectionHeader := strings.ToLower(resp.Header.Get(httpstream.HeaderConnection))
+	upgradeHeader := strings.ToLower(resp.Header.Get(httpstream.HeaderUpgrade))
+	if resp.StatusCode != http.StatusSwitchingProtocols ||
+		!strings.Contains(connectionHeader, strings.ToLower(httpstream.HeaderUpgrade)) ||
+		!strings.Contains(upgradeHeader, strings.ToLower(spdystream.HeaderSpdy31)) {
+		defer resp.Body.Close()
+		if u.conn != nil {
+			_ = u.conn.Close()
+		}
+		msg, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("unable to upgrade connection: %s", resp.Status)
+		}
+		return nil, fmt.Errorf("unable to upgrade connection: %s", strings.TrimSpace(string(msg)))
+	}
+	return spdystream.NewClientConnectionWithPings(u.conn, spdyPingPeriod)
+}
+
+// canonicalAddr returns the host:port of the request's URL, using the default port of the scheme when the
+// URL has no port.
+func canonicalAddr(req *http.Request) string {
+	port := req.URL.Port()
+	if port == "" {
+		if req.URL.Scheme == "https" {
+			port = "443"
+		} else {
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(req.URL.Hostname(), port)
+}
@@ -0,0 +1,58 @@
+package dnet
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	spdystream "k8s.io/apimachinery/pkg/util/httpstream/spdy"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/transport/spdy"
+)
+
+func TestSpdyRoundTripperFor_dial(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/portforward") {
+			http.NotFound(w, r)
+			return
+		}
+		conn := spdystream.NewResponseUpgrader().UpgradeResponse(w, r, func(httpstream.Stream, <-chan struct{}) error {
+			return nil
+		})
+		if conn != nil {
+			<-conn.CloseChan()
+		}
+	}))
+	defer srv.Close()
+
+	var dials int32
+	var nd net.Dialer
+	cfg := &rest.Config{
+		Host: srv.URL,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return nd.DialContext(ctx, network, address)
+		},
+	}
+	rt, upgrader, err := spdyRoundTripperFor(cfg)
+	require.NoError(t, err)
+	u, err := url.Parse(srv.URL + "/api/v1/namespaces/default/pods/echo/portforward")
+	require.NoError(t, err)
+	conn, _, err := spdy.NewDialer(upgrader, &http.Client{Transport: rt}, http.MethodPost, u).Dial("portforward.k8s.io")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&dials))
+	require.NoError(t, conn.Close())
+
+	// Requests that aren't upgraded are refused.
+	u.Path = "/"
+	_, _, err = spdy.NewDialer(upgrader, &http.Client{Transport: rt}, http.MethodPost, u).Dial("portforward.k8s.io")
+	assert.ErrorContains(t, err, "unable to upgrade connection")
+}
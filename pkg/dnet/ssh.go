@@ -0,0 +1,186 @@
+package dnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHDialer dials connections through an SSH server, in the same way as "ssh -J" does. A single SSH
+// connection is shared by all dialed connections. It is established on first use and re-established
+// when it is lost.
+type SSHDialer struct {
+	addr   string
+	config *ssh.ClientConfig
+
+	// agentSock is the socket of the ssh-agent. The agent is only used during the handshake, so
+	// it is dialed for each handshake.
+	agentSock string
+
+	lock   sync.Mutex
+	client *ssh.Client
+}
+
+// NewSSHDialer creates a dialer that tunnels connections through the SSH server at addr. The port
+// defaults to 22 and the user to the current user. The client authenticates with the keys of the
+// ssh-agent found using SSH_AUTH_SOCK, and with the private key in keyFile when it is not empty. The
+// host key of the server is verified using knownHostsFile, which defaults to ~/.ssh/known_hosts.
+func NewSSHDialer(addr, userName, keyFile, knownHostsFile string) (*SSHDialer, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+	if userName == "" {
+		cu, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		userName = cu.Username
+	}
+
+	var auths []ssh.AuthMethod
+	if keyFile != "" {
+		pem, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(pem)
+		if err != nil {
+			var ppe *ssh.PassphraseMissingError
+			if errors.As(err, &ppe) {
+				return nil, fmt.Errorf("the private key in %s is protected by a passphrase, add it to an ssh-agent instead", keyFile)
+			}
+			return nil, fmt.Errorf("unable to parse private key in %s: %w", keyFile, err)
+		}
+		auths = append(auths, ssh.PublicKeys(signer))
+	}
+	var agentSock string
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			_ = conn.Close()
+			agentSock = sock
+		}
+	}
+	if len(auths) == 0 && agentSock == "" {
+		return nil, errors.New("no ssh-agent is available and no key file has been configured")
+	}
+
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read known hosts: %w", err)
+	}
+
+	return &SSHDialer{
+		addr: addr,
+		config: &ssh.ClientConfig{
+			User:            userName,
+			Auth:            auths,
+			HostKeyCallback: hostKeyCallback,
+		},
+		agentSock: agentSock,
+	}, nil
+}
+
+// DialContext dials the given address from the SSH server. Only the "tcp" networks are supported.
+func (d *SSHDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	sc, err := d.sshClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	rc := make(chan result, 1)
+	go func() {
+		conn, err := sc.Dial(network, addr)
+		rc <- result{conn: conn, err: err}
+	}()
+	select {
+	case <-ctx.Done():
+		go func() {
+			// Close the connection if it is established after the context was cancelled.
+			if r := <-rc; r.conn != nil {
+				_ = r.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	case r := <-rc:
+		if r.err != nil {
+			return nil, fmt.Errorf("dial %s via ssh proxy %s: %w", addr, d.addr, r.err)
+		}
+		return r.conn, nil
+	}
+}
+
+// Close closes the SSH connection, if one has been established.
+func (d *SSHDialer) Close() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.client == nil {
+		return nil
+	}
+	err := d.client.Close()
+	d.client = nil
+	return err
+}
+
+func (d *SSHDialer) sshClient(ctx context.Context) (*ssh.Client, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.client != nil {
+		return d.client, nil
+	}
+	var nd net.Dialer
+	conn, err := nd.DialContext(ctx, "tcp", d.addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial ssh proxy: %w", err)
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+	config := d.config
+	if d.agentSock != "" {
+		if ac, err := net.Dial("unix", d.agentSock); err == nil {
+			defer ac.Close()
+			cfg := *d.config
+			cfg.Auth = append(append([]ssh.AuthMethod(nil), d.config.Auth...), ssh.PublicKeysCallback(agent.NewClient(ac).Signers))
+			config = &cfg
+		}
+	}
+	cc, chans, reqs, err := ssh.NewClientConn(conn, d.addr, config)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("ssh proxy handshake: %w", err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	sc := ssh.NewClient(cc, chans, reqs)
+	d.client = sc
+	go func() {
+		// Forget the client when the connection is lost, so that the next dial reconnects.
+		_ = sc.Wait()
+		d.lock.Lock()
+		if d.client == sc {
+			d.client = nil
+		}
+		d.lock.Unlock()
+	}()
+	return sc, nil
+}
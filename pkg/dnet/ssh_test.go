@@ -0,0 +1,212 @@
+package dnet
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// startSSHServer starts an SSH server that accepts the given client key and forwards "direct-tcpip"
+// channels. It returns the address of the server and its host key.
+func startSSHServer(t *testing.T, clientKey ssh.PublicKey) (string, ssh.PublicKey) {
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	require.NoError(t, err)
+
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, io.ErrUnexpectedEOF
+		},
+	}
+	cfg.AddHostKey(hostSigner)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for nc := range chans {
+					var fwd struct {
+						Host     string
+						Port     uint32
+						OrigHost string
+						OrigPort uint32
+					}
+					if nc.ChannelType() != "direct-tcpip" || ssh.Unmarshal(nc.ExtraData(), &fwd) != nil {
+						_ = nc.Reject(ssh.UnknownChannelType, "unsupported")
+						continue
+					}
+					tc, err := net.Dial("tcp", net.JoinHostPort(fwd.Host, strconv.FormatUint(uint64(fwd.Port), 10)))
+					if err != nil {
+						_ = nc.Reject(ssh.ConnectionFailed, err.Error())
+						continue
+					}
+					ch, creqs, err := nc.Accept()
+					if err != nil {
+						_ = tc.Close()
+						continue
+					}
+					go ssh.DiscardRequests(creqs)
+					go func() {
+						_, _ = io.Copy(ch, tc)
+						_ = ch.CloseWrite()
+					}()
+					go func() {
+						_, _ = io.Copy(tc, ch)
+						_ = tc.Close()
+					}()
+				}
+			}()
+		}
+	}()
+	return l.Addr().String(), hostSigner.PublicKey()
+}
+
+func TestSSHDialer(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	tmp := t.TempDir()
+
+	clientPub, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(clientPriv)
+	require.NoError(t, err)
+	keyFile := filepath.Join(tmp, "id_ed25519")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600))
+	sshPub, err := ssh.NewPublicKey(clientPub)
+	require.NoError(t, err)
+
+	sshAddr, hostKey := startSSHServer(t, sshPub)
+	knownHosts := filepath.Join(tmp, "known_hosts")
+	require.NoError(t, os.WriteFile(knownHosts, []byte(knownhosts.Line([]string{sshAddr}, hostKey)+"\n"), 0o600))
+
+	// An echo server that is dialed through the SSH server
+	el, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer el.Close()
+	go func() {
+		for {
+			conn, err := el.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, _ = io.Copy(conn, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	t.Run("dial", func(t *testing.T) {
+		d, err := NewSSHDialer(sshAddr, "tester", keyFile, knownHosts)
+		require.NoError(t, err)
+		defer d.Close()
+		for i := 0; i < 2; i++ {
+			conn, err := d.DialContext(ctx, "tcp", el.Addr().String())
+			require.NoError(t, err)
+			_, err = conn.Write([]byte("hello"))
+			require.NoError(t, err)
+			buf := make([]byte, 5)
+			_, err = io.ReadFull(conn, buf)
+			require.NoError(t, err)
+			assert.Equal(t, "hello", string(buf))
+			require.NoError(t, conn.Close())
+		}
+	})
+
+	t.Run("reconnect", func(t *testing.T) {
+		d, err := NewSSHDialer(sshAddr, "tester", keyFile, knownHosts)
+		require.NoError(t, err)
+		defer d.Close()
+		conn, err := d.DialContext(ctx, "tcp", el.Addr().String())
+		require.NoError(t, err)
+		_ = conn.Close()
+		require.NoError(t, d.Close())
+		conn, err = d.DialContext(ctx, "tcp", el.Addr().String())
+		require.NoError(t, err)
+		_ = conn.Close()
+	})
+
+	t.Run("unknown host key", func(t *testing.T) {
+		otherKnownHosts := filepath.Join(tmp, "other_known_hosts")
+		require.NoError(t, os.WriteFile(otherKnownHosts, []byte(knownhosts.Line([]string{sshAddr}, sshPub)+"\n"), 0o600))
+		d, err := NewSSHDialer(sshAddr, "tester", keyFile, otherKnownHosts)
+		require.NoError(t, err)
+		defer d.Close()
+		_, err = d.DialContext(ctx, "tcp", el.Addr().String())
+		assert.ErrorContains(t, err, "ssh proxy handshake")
+	})
+
+	t.Run("agent", func(t *testing.T) {
+		keyring := agent.NewKeyring()
+		require.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: clientPriv}))
+		sock := filepath.Join(tmp, "agent.sock")
+		al, err := net.Listen("unix", sock)
+		require.NoError(t, err)
+		defer al.Close()
+		var open int32
+		go func() {
+			for {
+				conn, err := al.Accept()
+				if err != nil {
+					return
+				}
+				atomic.AddInt32(&open, 1)
+				go func() {
+					_ = agent.ServeAgent(keyring, conn)
+					_ = conn.Close()
+					atomic.AddInt32(&open, -1)
+				}()
+			}
+		}()
+		t.Setenv("SSH_AUTH_SOCK", sock)
+
+		d, err := NewSSHDialer(sshAddr, "tester", "", knownHosts)
+		require.NoError(t, err)
+		defer d.Close()
+		conn, err := d.DialContext(ctx, "tcp", el.Addr().String())
+		require.NoError(t, err)
+		_ = conn.Close()
+
+		// The connection to the agent is closed once the handshake is done.
+		assert.Eventually(t, func() bool { return atomic.LoadInt32(&open) == 0 }, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("no auth", func(t *testing.T) {
+		_, err := NewSSHDialer(sshAddr, "tester", "", knownHosts)
+		assert.Error(t, err)
+	})
+}
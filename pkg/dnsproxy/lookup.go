@@ -173,6 +173,20 @@ func lookupIP(ctx context.Context, network, qName string, r *net.Resolver) ([]ne
 	return ips, err
 }
 
+// lookupAlias returns the canonical name of the given name, or an empty string if the name isn't an alias. A
+// canonical name that is the result of applying the search path to the given name isn't considered an alias.
+func lookupAlias(ctx context.Context, qName string, r *net.Resolver) string {
+	name, final := useLookupName(qName)
+	target, err := r.LookupCNAME(ctx, name)
+	if err != nil && !final {
+		target, err = r.LookupCNAME(ctx, qName)
+	}
+	if err != nil || strings.HasPrefix(strings.ToLower(target), strings.ToLower(qName)) {
+		return ""
+	}
+	return target
+}
+
 func makeError(err error) (RRs, int, error) {
 	var dnsErr *net.DNSError
 	if errors.As(err, &dnsErr) {
@@ -212,6 +226,16 @@ func Lookup(ctx context.Context, qType uint16, qName string) (RRs, int, error) {
 				})
 			}
 		}
+		if len(answer) > 0 {
+			// Declare the canonical name of aliases, such as ExternalName services, so that the caller can tell
+			// that the addresses belong to a name outside the cluster.
+			if target := lookupAlias(ctx, qName, r); target != "" {
+				answer = append(RRs{&dns.CNAME{
+					Hdr:    NewHeader(qName, dns.TypeCNAME),
+					Target: target,
+				}}, answer...)
+			}
+		}
 	case dns.TypePTR:
 		var names []string
 		ip, err := PtrAddress(qName)
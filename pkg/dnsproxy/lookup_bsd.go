@@ -0,0 +1,38 @@
+//go:build freebsd || openbsd
+
+package dnsproxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/proc"
+)
+
+func externalLookup(ctx context.Context, host string, timeout time.Duration) iputil.IPs {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := proc.CommandContext(ctx, "getent", "hosts", host)
+	cmd.DisableLogging = true
+	out, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	// Each line is an address followed by the names of the host
+	//   <ip> <host> [aliases]
+	var ips iputil.IPs
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		if fs := strings.Fields(sc.Text()); len(fs) > 1 {
+			if ip := iputil.Parse(fs[0]); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
@@ -1,6 +1,7 @@
 package dos
 
 import (
+	"bytes"
 	"context"
 	"io/fs"
 	"os"
@@ -53,3 +54,16 @@ func (fs *lockedFs) OpenFile(name string, flag int, perm fs.FileMode) (File, err
 	}
 	return f, nil
 }
+
+func (*lockedFs) ReadFile(name string) ([]byte, error) {
+	return lockedfile.Read(name)
+}
+
+func (fs *lockedFs) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if fs.mustChown() {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			return fs.chown(lockedfile.Write(name, bytes.NewReader(data), perm), name)
+		}
+	}
+	return lockedfile.Write(name, bytes.NewReader(data), perm)
+}
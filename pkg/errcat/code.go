@@ -0,0 +1,94 @@
+package errcat
+
+import (
+	"errors"
+)
+
+// A Code is a stable identifier of an error condition. In contrast to the error message, a code
+// never changes between releases, so it can be used by tools, such as IDE plugins, to map an error
+// to an action.
+type Code string
+
+// Codes of the error categories. An error that has no explicit code gets the code of its category.
+const (
+	CodeOK           = Code("")
+	CodeUser         = Code("USER")
+	CodeConfig       = Code("CONFIG")
+	CodeNoDaemonLogs = Code("NO_DAEMON_LOGS")
+	CodeUnknown      = Code("UNKNOWN")
+)
+
+// Codes of errors that are produced by the CLI. Errors that originate from an error enum of the
+// gRPC API use the name of the enum value with a prefix, e.g. "INTERCEPT_ALREADY_EXISTS" or
+// "CONNECT_CLUSTER_FAILED".
+const (
+	// CodeRootDaemonPermission means that the root daemon couldn't be started, typically because the
+	// user didn't grant the required elevated privileges. The details contain the command that must
+	// be run with elevated privileges.
+	CodeRootDaemonPermission = Code("EPERM_ROOT_DAEMON")
+
+	// CodeNoUserDaemon means that the user daemon isn't running.
+	CodeNoUserDaemon = Code("NO_USER_DAEMON")
+
+	// CodeNoRootDaemon means that the root daemon isn't running.
+	CodeNoRootDaemon = Code("NO_ROOT_DAEMON")
+
+	// CodeNoTrafficManager means that there's no connection to the traffic-manager.
+	CodeNoTrafficManager = Code("NO_TRAFFIC_MANAGER")
+)
+
+// Code returns the code of the category.
+func (c Category) Code() Code {
+	switch c {
+	case OK:
+		return CodeOK
+	case User:
+		return CodeUser
+	case Config:
+		return CodeConfig
+	case NoDaemonLogs:
+		return CodeNoDaemonLogs
+	default:
+		return CodeUnknown
+	}
+}
+
+type coded struct {
+	error
+	code    Code
+	details any
+}
+
+// WithCode returns an error that wraps the given error and carries the given code and details.
+// The details are optional. When present, they must be serializable to JSON.
+func WithCode(err error, code Code, details any) error {
+	if err == nil {
+		return nil
+	}
+	return &coded{error: err, code: code, details: details}
+}
+
+// Unwrap this coded error.
+func (ce *coded) Unwrap() error {
+	return ce.error
+}
+
+// GetCode returns the code of the outermost coded error in the chain of the given error. The code
+// of the error's category is returned when no such error exists.
+func GetCode(err error) Code {
+	var ce *coded
+	if errors.As(err, &ce) {
+		return ce.code
+	}
+	return GetCategory(err).Code()
+}
+
+// GetDetails returns the details of the outermost coded error in the chain of the given error, or
+// nil if no such error exists.
+func GetDetails(err error) any {
+	var ce *coded
+	if errors.As(err, &ce) {
+		return ce.details
+	}
+	return nil
+}
@@ -1,10 +1,12 @@
 package errcat
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
 	"github.com/telepresenceio/telepresence/rpc/v2/common"
+	"github.com/telepresenceio/telepresence/v2/pkg/i18n"
 )
 
 // The Category is used for categorizing errors so that we can know when
@@ -14,6 +16,11 @@ type Category int
 type categorized struct {
 	error
 	category Category
+
+	// msg, or format and args, are retained so that Message can translate the error.
+	msg    string
+	format string
+	args   []any
 }
 
 const (
@@ -29,6 +36,7 @@ const (
 // using its '%v' formatter.
 func (c Category) New(untypedErr any) error {
 	var err error
+	var msg string
 	switch untypedErr := untypedErr.(type) {
 	case nil:
 		return nil
@@ -36,16 +44,17 @@ func (c Category) New(untypedErr any) error {
 		err = untypedErr
 	case string:
 		err = errors.New(untypedErr)
+		msg = untypedErr
 	default:
 		err = fmt.Errorf("%v", untypedErr)
 	}
-	return &categorized{error: err, category: c}
+	return &categorized{error: err, category: c, msg: msg}
 }
 
 // Newf creates a new categorized error based on a format string with arguments. The
 // error is created using fmt.Errorf() so using '%w' is relevant for error arguments.
 func (c Category) Newf(format string, a ...any) error {
-	return &categorized{error: fmt.Errorf(format, a...), category: c}
+	return &categorized{error: fmt.Errorf(format, a...), category: c, format: format, args: a}
 }
 
 // Unwrap this categorized error.
@@ -53,6 +62,36 @@ func (ce *categorized) Unwrap() error {
 	return ce.error
 }
 
+// Message returns the message of the given error, translated to the locale of the given context.
+// Only the messages of errors created by this package are translated. Other errors retain their
+// message.
+func Message(ctx context.Context, err error) string {
+	switch e := err.(type) {
+	case nil:
+		return ""
+	case *coded:
+		return Message(ctx, e.error)
+	case *categorized:
+		switch {
+		case e.format != "":
+			args := make([]any, len(e.args))
+			for i, a := range e.args {
+				if ae, ok := a.(error); ok {
+					a = Message(ctx, ae)
+				}
+				args[i] = a
+			}
+			return i18n.Sprintf(ctx, e.format, args...)
+		case e.msg != "":
+			return i18n.T(ctx, e.msg)
+		default:
+			return Message(ctx, e.error)
+		}
+	default:
+		return err.Error()
+	}
+}
+
 // GetCategory returns the error category for a categorized error, OK for nil, and
 // Unknown for other errors.
 func GetCategory(err error) Category {
@@ -78,14 +117,22 @@ func FromResult(r *common.Result) error {
 	if c == OK {
 		return nil
 	}
-	return &categorized{error: errors.New(string(r.Data)), category: c}
+	err := error(&categorized{error: errors.New(string(r.Data)), category: c})
+	if r.ErrorCode != "" {
+		err = WithCode(err, Code(r.ErrorCode), nil)
+	}
+	return err
 }
 
 func ToResult(err error) *common.Result {
 	r := &common.Result{}
 	if err != nil {
 		r.Data = []byte(err.Error())
-		r.ErrorCategory = common.Result_ErrorCategory(GetCategory(err))
+		cat := GetCategory(err)
+		r.ErrorCategory = common.Result_ErrorCategory(cat)
+		if code := GetCode(err); code != cat.Code() {
+			r.ErrorCode = string(code)
+		}
 	}
 	return r
 }
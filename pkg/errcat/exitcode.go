@@ -0,0 +1,24 @@
+package errcat
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ExitCode is an error that tells the CLI to exit with the given code without printing an error
+// message. It is used by commands that report their outcome using the exit code.
+type ExitCode int
+
+func (e ExitCode) Error() string {
+	return fmt.Sprintf("exit code %d", int(e))
+}
+
+// GetExitCode returns the exit code carried by the given error, and true, if the error is or
+// wraps an ExitCode.
+func GetExitCode(err error) (int, bool) {
+	var ec ExitCode
+	if errors.As(err, &ec) {
+		return int(ec), true
+	}
+	return 0, false
+}
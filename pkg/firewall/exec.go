@@ -0,0 +1,24 @@
+//go:build linux || freebsd || openbsd
+
+package firewall
+
+import (
+	"context"
+
+	"github.com/datawire/dlib/dexec"
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/shellquote"
+)
+
+// runCmd runs the given command without logging its output.
+func runCmd(ctx context.Context, exe string, args ...string) error {
+	cmd := dexec.CommandContext(ctx, exe, args...)
+	cmd.DisableLogging = true
+	dlog.Debug(ctx, shellquote.ShellString(exe, args))
+	return cmd.Run()
+}
+
+func available(exe string) bool {
+	_, err := dexec.LookPath(exe)
+	return err == nil
+}
@@ -0,0 +1,115 @@
+// Package firewall manages the firewall rules that the root daemon uses to redirect DNS traffic to
+// its local DNS server. All rules are tagged as belonging to Telepresence, so that rules left behind
+// by a daemon that didn't terminate gracefully can be found and removed.
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/journal"
+)
+
+const (
+	// ModeAuto selects the first available backend.
+	ModeAuto = "auto"
+
+	// ModeIptables selects the iptables backend.
+	ModeIptables = "iptables"
+
+	// ModeNftables selects the nftables backend.
+	ModeNftables = "nftables"
+
+	// ModePf selects the pf backend.
+	ModePf = "pf"
+)
+
+// Backend is a firewall implementation.
+type Backend interface {
+	// Name returns the name of the backend, which is also the mode that selects it.
+	Name() string
+
+	// Available returns true if the backend can be used on this host.
+	Available() bool
+
+	// RedirectDNS redirects UDP packets sent to port 53 of dnsIP to the given address. Packets
+	// sent from the exempt addresses are not redirected, so that the local DNS server can use
+	// the original DNS server as a fallback.
+	RedirectDNS(ctx context.Context, dnsIP net.IP, to *net.UDPAddr, exempt []*net.UDPAddr) error
+
+	// HasRules returns true if rules tagged as belonging to Telepresence exist.
+	HasRules(ctx context.Context) bool
+
+	// RemoveRules removes all rules tagged as belonging to Telepresence.
+	RemoveRules(ctx context.Context) error
+}
+
+// Modes returns the modes that are valid on this platform, starting with ModeAuto.
+func Modes() []string {
+	bes := backends()
+	modes := make([]string, 0, len(bes)+1)
+	modes = append(modes, ModeAuto)
+	for _, be := range bes {
+		modes = append(modes, be.Name())
+	}
+	return modes
+}
+
+// Select returns the backend for the given mode. The ModeAuto, or an empty mode, selects the first
+// available backend.
+func Select(mode string) (Backend, error) {
+	bes := backends()
+	if mode == "" || mode == ModeAuto {
+		for _, be := range bes {
+			if be.Available() {
+				return be, nil
+			}
+		}
+		return nil, fmt.Errorf("no firewall backend is available")
+	}
+	for _, be := range bes {
+		if be.Name() == mode {
+			if !be.Available() {
+				return nil, fmt.Errorf("firewall backend %s is not available", mode)
+			}
+			return be, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown firewall backend %q", mode)
+}
+
+// RemoveLeftovers removes the rules tagged as belonging to Telepresence from all available backends,
+// and returns the names of the backends that had such rules. It must only be called when no root
+// daemon is running, because it would otherwise remove the rules that the daemon uses.
+func RemoveLeftovers(ctx context.Context) (cleaned []string, err error) {
+	for _, be := range backends() {
+		if !be.Available() || !be.HasRules(ctx) {
+			continue
+		}
+		dlog.Infof(ctx, "Removing leftover %s rules", be.Name())
+		if rerr := be.RemoveRules(ctx); rerr != nil {
+			if err == nil {
+				err = fmt.Errorf("failed to remove %s rules: %w", be.Name(), rerr)
+			}
+			continue
+		}
+		cleaned = append(cleaned, be.Name())
+	}
+	return cleaned, err
+}
+
+// RemoveJournaled is the journal.Reverter of journal.KindFirewall entries. It removes the rules of the backend that
+// the entry names.
+func RemoveJournaled(ctx context.Context, e *journal.Entry) error {
+	for _, be := range backends() {
+		if be.Name() == e.ID {
+			if !be.Available() || !be.HasRules(ctx) {
+				return nil
+			}
+			return be.RemoveRules(ctx)
+		}
+	}
+	return fmt.Errorf("unknown firewall backend %q", e.ID)
+}
@@ -0,0 +1,29 @@
+package firewall
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// pfAnchorRefs are the references to the anchor that the main ruleset must contain. The translation rules of an
+// anchor are only evaluated when the main ruleset has an rdr-anchor for it.
+var pfAnchorRefs = []pfAnchorRef{ //nolint:gochecknoglobals // constant
+	{modifier: "nat", ref: `rdr-anchor "` + tpPfAnchor + `"`},
+	{modifier: "rules", ref: `anchor "` + tpPfAnchor + `"`},
+}
+
+// pfRules returns the rules that redirect DNS. The pf of FreeBSD only translates inbound packets, so the packets
+// sent to the DNS server are first routed to the loopback interface, where they arrive and are redirected.
+func pfRules(dnsIP net.IP, to *net.UDPAddr, exempt []*net.UDPAddr) string {
+	var sb strings.Builder
+	for _, ex := range exempt {
+		fmt.Fprintf(&sb, "no rdr on lo0 inet proto udp from %s port %d to %s port 53\n", ex.IP, ex.Port, dnsIP)
+	}
+	fmt.Fprintf(&sb, "rdr pass on lo0 inet proto udp from any to %s port 53 -> %s port %d\n", dnsIP, to.IP, to.Port)
+	for _, ex := range exempt {
+		fmt.Fprintf(&sb, "pass out quick inet proto udp from %s port %d to %s port 53\n", ex.IP, ex.Port, dnsIP)
+	}
+	fmt.Fprintf(&sb, "pass out quick on ! lo0 route-to lo0 inet proto udp from any to %s port 53\n", dnsIP)
+	return sb.String()
+}
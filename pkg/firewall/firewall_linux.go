@@ -0,0 +1,130 @@
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// backends returns the backends in the order that ModeAuto tries them. The iptables backend comes
+// first because it also works with the nftables kernel API when iptables is the iptables-nft variant.
+func backends() []Backend {
+	return []Backend{iptables{}, nftables{}}
+}
+
+// iptables uses a chain named TELEPRESENCE_DNS in the "nat" table, and a rule in the OUTPUT chain
+// that jumps to it.
+type iptables struct{}
+
+const tpDNSChain = "TELEPRESENCE_DNS"
+
+func (iptables) Name() string {
+	return ModeIptables
+}
+
+func (iptables) Available() bool {
+	return available("iptables")
+}
+
+// runNatTableCmd runs "iptables -t nat ...".
+func (iptables) runNatTableCmd(ctx context.Context, args ...string) error {
+	return runCmd(ctx, "iptables", append([]string{"-t", "nat"}, args...)...)
+}
+
+func (t iptables) RedirectDNS(ctx context.Context, dnsIP net.IP, to *net.UDPAddr, exempt []*net.UDPAddr) (err error) {
+	_ = t.RemoveRules(ctx)
+
+	// Create the TELEPRESENCE_DNS chain
+	if err = t.runNatTableCmd(ctx, "-N", tpDNSChain); err != nil {
+		return err
+	}
+
+	// This rule prevents that any rules in this table applies to the localDNS address when
+	// used as a source. I.e. we let the local DNS server reach the original DNS server
+	for _, localDNS := range exempt {
+		if err = t.runNatTableCmd(ctx, "-A", tpDNSChain,
+			"-p", "udp",
+			"--source", localDNS.IP.String(),
+			"--sport", strconv.Itoa(localDNS.Port),
+			"-j", "RETURN",
+		); err != nil {
+			return err
+		}
+	}
+	// This rule redirects all packets intended for the DNS service to our local DNS service
+	if err = t.runNatTableCmd(ctx, "-A", tpDNSChain,
+		"-p", "udp",
+		"--dest", dnsIP.String()+"/32",
+		"--dport", "53",
+		"-j", "DNAT",
+		"--to-destination", to.String(),
+	); err != nil {
+		return err
+	}
+
+	// Alter locally generated packets before routing
+	return t.runNatTableCmd(ctx, "-I", "OUTPUT", "1", "-j", tpDNSChain)
+}
+
+func (t iptables) HasRules(ctx context.Context) bool {
+	return t.runNatTableCmd(ctx, "-n", "-L", tpDNSChain) == nil
+}
+
+func (t iptables) RemoveRules(ctx context.Context) error {
+	// The jump may have been added more than once if a daemon crashed between adding it and
+	// creating the chain, so remove it until there are no more.
+	for i := 0; i < 10 && t.runNatTableCmd(ctx, "-D", "OUTPUT", "-j", tpDNSChain) == nil; i++ {
+	}
+	_ = t.runNatTableCmd(ctx, "-F", tpDNSChain)
+	if t.HasRules(ctx) {
+		return t.runNatTableCmd(ctx, "-X", tpDNSChain)
+	}
+	return nil
+}
+
+// nftables uses a table named "telepresence" that contains all the rules.
+type nftables struct{}
+
+const tpNftTable = "telepresence"
+
+func (nftables) Name() string {
+	return ModeNftables
+}
+
+func (nftables) Available() bool {
+	return available("nft")
+}
+
+func (n nftables) RedirectDNS(ctx context.Context, dnsIP net.IP, to *net.UDPAddr, exempt []*net.UDPAddr) (err error) {
+	_ = n.RemoveRules(ctx)
+	if dnsIP.To4() == nil {
+		return fmt.Errorf("nftables DNS redirect requires an IPv4 address, got %s", dnsIP)
+	}
+	if err = runCmd(ctx, "nft", "add", "table", "ip", tpNftTable); err != nil {
+		return err
+	}
+	if err = runCmd(ctx, "nft", "add", "chain", "ip", tpNftTable, "output",
+		"{ type nat hook output priority -100 ; }"); err != nil {
+		return err
+	}
+	for _, localDNS := range exempt {
+		if err = runCmd(ctx, "nft", "add", "rule", "ip", tpNftTable, "output",
+			"ip", "saddr", localDNS.IP.String(), "udp", "sport", strconv.Itoa(localDNS.Port), "return"); err != nil {
+			return err
+		}
+	}
+	return runCmd(ctx, "nft", "add", "rule", "ip", tpNftTable, "output",
+		"ip", "daddr", dnsIP.String(), "udp", "dport", "53", "dnat", "to", to.String())
+}
+
+func (nftables) HasRules(ctx context.Context) bool {
+	return runCmd(ctx, "nft", "list", "table", "ip", tpNftTable) == nil
+}
+
+func (n nftables) RemoveRules(ctx context.Context) error {
+	if !n.HasRules(ctx) {
+		return nil
+	}
+	return runCmd(ctx, "nft", "delete", "table", "ip", tpNftTable)
+}
@@ -0,0 +1,24 @@
+package firewall
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// pfAnchorRefs are the references to the anchor that the main ruleset must contain.
+var pfAnchorRefs = []pfAnchorRef{ //nolint:gochecknoglobals // constant
+	{modifier: "rules", ref: `anchor "` + tpPfAnchor + `"`},
+}
+
+// pfRules returns the rules that redirect DNS. The rdr-to of OpenBSD only applies to inbound packets, so the
+// packets sent to the DNS server are first routed to the loopback interface, where they arrive and are redirected.
+func pfRules(dnsIP net.IP, to *net.UDPAddr, exempt []*net.UDPAddr) string {
+	var sb strings.Builder
+	for _, ex := range exempt {
+		fmt.Fprintf(&sb, "pass quick inet proto udp from %s port %d to %s port 53\n", ex.IP, ex.Port, dnsIP)
+	}
+	fmt.Fprintf(&sb, "pass out quick on ! lo0 inet proto udp to %s port 53 route-to 127.0.0.1\n", dnsIP)
+	fmt.Fprintf(&sb, "pass in quick on lo0 inet proto udp to %s port 53 rdr-to %s port %d\n", dnsIP, to.IP, to.Port)
+	return sb.String()
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !freebsd && !openbsd
+
+package firewall
+
+// backends returns no backends, because the root daemon configures DNS without firewall rules on
+// this platform. The macOS daemon uses resolver files, and the Windows daemon uses the DNS client
+// settings of the TUN-device.
+func backends() []Backend {
+	return nil
+}
@@ -0,0 +1,90 @@
+//go:build freebsd || openbsd
+
+package firewall
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/datawire/dlib/dexec"
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/shellquote"
+)
+
+// backends returns the backends in the order that ModeAuto tries them.
+func backends() []Backend {
+	return []Backend{pf{}}
+}
+
+// pf loads its rules into an anchor named "telepresence". The anchor is only evaluated when the main ruleset
+// refers to it, so /etc/pf.conf must contain the references that pfAnchorRefs lists.
+type pf struct{}
+
+const tpPfAnchor = "telepresence"
+
+// pfAnchorRef is a reference to the anchor that the main ruleset must contain. The modifier is used both to show
+// and to flush the rules of that kind, e.g. "rules" or "nat".
+type pfAnchorRef struct {
+	modifier string
+	ref      string
+}
+
+func (pf) Name() string {
+	return ModePf
+}
+
+func (pf) Available() bool {
+	return available("pfctl")
+}
+
+// pfctl runs pfctl with the given arguments and input, and returns its output.
+func pfctl(ctx context.Context, input string, args ...string) (string, error) {
+	cmd := dexec.CommandContext(ctx, "pfctl", args...)
+	cmd.DisableLogging = true
+	if input != "" {
+		cmd.Stdin = strings.NewReader(input)
+	}
+	dlog.Debug(ctx, shellquote.ShellString("pfctl", args))
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+func (p pf) RedirectDNS(ctx context.Context, dnsIP net.IP, to *net.UDPAddr, exempt []*net.UDPAddr) (err error) {
+	_ = p.RemoveRules(ctx)
+	if dnsIP.To4() == nil {
+		return fmt.Errorf("pf DNS redirect requires an IPv4 address, got %s", dnsIP)
+	}
+	for _, ar := range pfAnchorRefs {
+		main, err := pfctl(ctx, "", "-s", ar.modifier)
+		if err != nil {
+			return err
+		}
+		if !strings.Contains(main, ar.ref) {
+			return fmt.Errorf("the pf ruleset doesn't refer to the %s anchor. Add the line '%s' to /etc/pf.conf and reload it",
+				tpPfAnchor, ar.ref)
+		}
+	}
+	_, err = pfctl(ctx, pfRules(dnsIP, to, exempt), "-a", tpPfAnchor, "-f", "-")
+	return err
+}
+
+func (pf) HasRules(ctx context.Context) bool {
+	for _, ar := range pfAnchorRefs {
+		if out, err := pfctl(ctx, "", "-a", tpPfAnchor, "-s", ar.modifier); err == nil && strings.TrimSpace(out) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (pf) RemoveRules(ctx context.Context) (err error) {
+	// Only the rules of the anchor are flushed. Flushing "all" would also flush the states of the whole host.
+	for _, ar := range pfAnchorRefs {
+		if _, ferr := pfctl(ctx, "", "-a", tpPfAnchor, "-F", ar.modifier); ferr != nil && err == nil {
+			err = ferr
+		}
+	}
+	return err
+}
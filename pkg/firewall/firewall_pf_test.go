@@ -0,0 +1,31 @@
+//go:build freebsd || openbsd
+
+package firewall
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPfRules(t *testing.T) {
+	dnsIP := net.IP{192, 168, 1, 1}
+	to := &net.UDPAddr{IP: net.IP{127, 0, 0, 1}, Port: 5353}
+	exempt := []*net.UDPAddr{{IP: net.IP{192, 168, 1, 10}, Port: 4711}}
+	rules := strings.Split(strings.TrimSpace(pfRules(dnsIP, to, exempt)), "\n")
+
+	// The exemption must precede the redirect, and the redirect must target the local server.
+	exIdx, rdrIdx := -1, -1
+	for i, r := range rules {
+		if strings.Contains(r, "from 192.168.1.10 port 4711 to 192.168.1.1 port 53") && exIdx < 0 {
+			exIdx = i
+		}
+		if strings.Contains(r, "127.0.0.1 port 5353") {
+			rdrIdx = i
+		}
+	}
+	assert.GreaterOrEqual(t, exIdx, 0)
+	assert.Greater(t, rdrIdx, exIdx)
+}
@@ -0,0 +1,32 @@
+package firewall
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelect(t *testing.T) {
+	_, err := Select("ipfw")
+	require.Error(t, err)
+
+	for _, be := range backends() {
+		sel, err := Select(be.Name())
+		if be.Available() {
+			require.NoError(t, err)
+			assert.Equal(t, be.Name(), sel.Name())
+		} else {
+			assert.Error(t, err)
+		}
+	}
+
+	sel, err := Select(ModeAuto)
+	if err == nil {
+		assert.True(t, sel.Available())
+	} else {
+		for _, be := range backends() {
+			assert.False(t, be.Available())
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package forwarder
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/matcher"
+)
+
+// faultRand returns a random number in [0.0,1.0) that decides if a fault rule applies to a request.
+var faultRand = rand.Float64 //nolint:gochecknoglobals // replaced in tests
+
+// faultRule is the compiled form of a manager.FaultRule.
+type faultRule struct {
+	matcher         matcher.Request
+	percent         float64
+	delay           time.Duration
+	abortStatus     int
+	responseHeaders map[string]string
+}
+
+// newFaultRules compiles the given fault rules.
+func newFaultRules(rules []*manager.FaultRule) ([]*faultRule, error) {
+	frs := make([]*faultRule, len(rules))
+	for i, r := range rules {
+		m, err := matcher.NewRequestFromMap(r.Match)
+		if err != nil {
+			return nil, fmt.Errorf("fault rule %d: %w", i+1, err)
+		}
+		frs[i] = &faultRule{
+			matcher:         m,
+			percent:         r.Percent,
+			delay:           r.Delay.AsDuration(),
+			abortStatus:     int(r.AbortStatus),
+			responseHeaders: r.ResponseHeaders,
+		}
+	}
+	return frs, nil
+}
+
+// faultFor returns the first rule that matches the given request and that is selected by its percentage, or nil.
+func faultFor(rules []*faultRule, rq *http.Request) *faultRule {
+	for _, r := range rules {
+		if r.matcher.Matches(rq.URL.Path, rq.Header) && faultRand()*100 < r.percent {
+			return r
+		}
+	}
+	return nil
+}
+
+// rewrite sets the response headers of the rule in the given header.
+func (r *faultRule) rewrite(h http.Header) {
+	for k, v := range r.responseHeaders {
+		if v == "" {
+			h.Del(k)
+		} else {
+			h.Set(k, v)
+		}
+	}
+}
+
+// faultRequest is a request that was read from a faultConn, in the order that the responses must be written.
+type faultRequest struct {
+	rq    *http.Request
+	fault *faultRule
+	raw   bool // the remainder of the connection isn't HTTP/1.x, so it's passed through unchanged
+}
+
+// faultConn is a connection that injects the faults of the matching rules into the HTTP/1.x requests that are
+// read from it, and into the responses that are written to it. A connection that doesn't start with an HTTP/1.x
+// request, e.g. because it uses TLS or HTTP/2, is passed through unchanged.
+type faultConn struct {
+	net.Conn
+	rules []*faultRule
+
+	rqReader *io.PipeReader // the requests to route
+	rsWriter *io.PipeWriter // the responses to parse
+	pending  chan *faultRequest
+
+	closeOnce   sync.Once
+	closed      chan struct{}
+	rsCloseOnce sync.Once
+	rsClosed    chan struct{} // closed by closeResponses
+	written     chan struct{} // closed when writeResponses is done
+}
+
+func newFaultConn(conn net.Conn, rules []*faultRule) *faultConn {
+	rqReader, rqWriter := io.Pipe()
+	rsReader, rsWriter := io.Pipe()
+	c := &faultConn{
+		Conn:     conn,
+		rules:    rules,
+		rqReader: rqReader,
+		rsWriter: rsWriter,
+		pending:  make(chan *faultRequest, 32),
+		closed:   make(chan struct{}),
+		rsClosed: make(chan struct{}),
+		written:  make(chan struct{}),
+	}
+	go c.readRequests(rqWriter)
+	go c.writeResponses(rsReader)
+	return c
+}
+
+func (c *faultConn) Read(b []byte) (int, error) {
+	return c.rqReader.Read(b)
+}
+
+func (c *faultConn) Write(b []byte) (int, error) {
+	return c.rsWriter.Write(b)
+}
+
+// faultWriteTimeout is how long Close waits for pending responses, such as the ones of aborted requests, to be
+// written to the connection.
+const faultWriteTimeout = time.Second
+
+// closeResponses is called when no more responses will be written to the connection. It waits until the
+// responses that are being parsed, and the responses of requests that are already aborted, have been written.
+func (c *faultConn) closeResponses() {
+	c.rsCloseOnce.Do(func() {
+		close(c.rsClosed)
+		_ = c.rsWriter.Close()
+	})
+	<-c.written
+}
+
+func (c *faultConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		_ = c.rqReader.Close()
+		_ = c.rsWriter.Close()
+		select {
+		case <-c.written:
+		case <-time.After(faultWriteTimeout):
+		}
+	})
+	return c.Conn.Close()
+}
+
+// readRequests parses the requests read from the connection, delays or aborts them according to the fault rules,
+// and writes the ones that are routed to the given pipe.
+func (c *faultConn) readRequests(pw *io.PipeWriter) {
+	defer close(c.pending)
+	br := bufio.NewReader(c.Conn)
+	if !startsWithHTTPMethod(br) {
+		c.pending <- &faultRequest{raw: true}
+		_, err := io.Copy(pw, br)
+		_ = pw.CloseWithError(err)
+		return
+	}
+	for {
+		rq, err := http.ReadRequest(br)
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			_ = pw.CloseWithError(err)
+			return
+		}
+		fr := faultFor(c.rules, rq)
+		if fr != nil && fr.delay > 0 {
+			select {
+			case <-c.closed:
+				return
+			case <-time.After(fr.delay):
+			}
+		}
+		select {
+		case <-c.closed:
+			return
+		case c.pending <- &faultRequest{rq: rq, fault: fr}:
+		}
+		if fr != nil && fr.abortStatus != 0 {
+			_, _ = io.Copy(io.Discard, rq.Body)
+			continue
+		}
+		if _, ok := rq.Header["User-Agent"]; !ok {
+			// Prevent that Write adds Go's default User-Agent.
+			rq.Header["User-Agent"] = []string{""}
+		}
+		if err = rq.Write(pw); err != nil {
+			_ = pw.CloseWithError(err)
+			return
+		}
+		if rq.Header.Get("Upgrade") != "" {
+			_, err = io.Copy(pw, br)
+			_ = pw.CloseWithError(err)
+			return
+		}
+	}
+}
+
+// writeResponses parses the responses written to the connection, rewrites their headers according to the fault
+// rules of the corresponding requests, and writes them, or the responses of aborted requests, to the connection.
+func (c *faultConn) writeResponses(pr *io.PipeReader) {
+	defer close(c.written)
+	br := bufio.NewReader(pr)
+	for {
+		p, ok := c.nextPending()
+		if !ok {
+			break
+		}
+		if p.raw {
+			_, err := io.Copy(c.Conn, br)
+			_ = pr.CloseWithError(err)
+			return
+		}
+		var rs *http.Response
+		if p.fault != nil && p.fault.abortStatus != 0 {
+			rs = abortResponse(p.rq, p.fault.abortStatus)
+		} else {
+			var err error
+			for {
+				if rs, err = http.ReadResponse(br, p.rq); err != nil {
+					_ = pr.CloseWithError(err)
+					return
+				}
+				if rs.StatusCode >= 200 || rs.StatusCode == http.StatusSwitchingProtocols {
+					break
+				}
+				// Informational responses, such as 100 Continue, precede the final response.
+				if err = rs.Write(c.Conn); err != nil {
+					_ = pr.CloseWithError(err)
+					return
+				}
+			}
+		}
+		if p.fault != nil {
+			p.fault.rewrite(rs.Header)
+		}
+		err := rs.Write(c.Conn)
+		_ = rs.Body.Close()
+		if err == nil && rs.StatusCode == http.StatusSwitchingProtocols {
+			_, err = io.Copy(c.Conn, br)
+		}
+		if err != nil {
+			_ = pr.CloseWithError(err)
+			return
+		}
+	}
+	_ = pr.Close()
+}
+
+// nextPending returns the next pending request. Requests that are already pending are returned even when the
+// connection or its responses are closed, so that their responses can be written.
+func (c *faultConn) nextPending() (*faultRequest, bool) {
+	select {
+	case p, ok := <-c.pending:
+		return p, ok
+	default:
+	}
+	select {
+	case p, ok := <-c.pending:
+		return p, ok
+	case <-c.closed:
+		return nil, false
+	case <-c.rsClosed:
+		return nil, false
+	}
+}
+
+// abortResponse returns the response to a request that is aborted by a fault rule.
+func abortResponse(rq *http.Request, status int) *http.Response {
+	body := fmt.Sprintf("%d %s (injected by telepresence)\n", status, http.StatusText(status))
+	return &http.Response{
+		StatusCode:    status,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Request:       rq,
+		Header:        http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+}
+
+// startsWithHTTPMethod returns true if the given reader starts with an HTTP method, followed by a space. It peeks
+// no further than the first byte that can't be part of a method, so it never waits for more data than the peer
+// has sent.
+func startsWithHTTPMethod(br *bufio.Reader) bool {
+	for n := 1; n <= len(http.MethodOptions)+1; n++ {
+		b, err := br.Peek(n)
+		if err != nil {
+			return false
+		}
+		switch c := b[n-1]; {
+		case c == ' ':
+			switch string(b[:n-1]) {
+			case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+				http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+				return true
+			}
+			return false
+		case c < 'A' || c > 'Z':
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package forwarder
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+func TestFaultConn(t *testing.T) {
+	// serve responds to the requests read from the conn with a 200 that echoes the path in an X-Path header.
+	serve := func(conn net.Conn) {
+		br := bufio.NewReader(conn)
+		for {
+			rq, err := http.ReadRequest(br)
+			if err != nil {
+				return
+			}
+			_, _ = io.Copy(io.Discard, rq.Body)
+			rs := &http.Response{
+				StatusCode: http.StatusOK,
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Header:     http.Header{"X-Path": {rq.URL.Path}, "Server": {"echo"}},
+				Body:       http.NoBody,
+			}
+			if err = rs.Write(conn); err != nil {
+				return
+			}
+		}
+	}
+
+	// roundtrip sends GET requests for the given paths through a faultConn with the given rules, and returns
+	// the responses.
+	roundtrip := func(t *testing.T, rules []*manager.FaultRule, paths ...string) []*http.Response {
+		frs, err := newFaultRules(rules)
+		require.NoError(t, err)
+		client, server := net.Pipe()
+		fc := newFaultConn(server, frs)
+		go serve(fc)
+		defer client.Close()
+		defer fc.Close()
+
+		br := bufio.NewReader(client)
+		rss := make([]*http.Response, len(paths))
+		for i, p := range paths {
+			rq, err := http.NewRequest(http.MethodGet, "http://echo"+p, nil)
+			require.NoError(t, err)
+			go func() { _ = rq.Write(client) }()
+			rs, err := http.ReadResponse(br, rq)
+			require.NoError(t, err)
+			_, _ = io.Copy(io.Discard, rs.Body)
+			rss[i] = rs
+		}
+		return rss
+	}
+
+	t.Run("no match", func(t *testing.T) {
+		rss := roundtrip(t, []*manager.FaultRule{{Match: map[string]string{":path-prefix:": "/api"}, Percent: 100, AbortStatus: 503}}, "/a")
+		assert.Equal(t, http.StatusOK, rss[0].StatusCode)
+		assert.Equal(t, "/a", rss[0].Header.Get("X-Path"))
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		rss := roundtrip(t, []*manager.FaultRule{{
+			Match:           map[string]string{":path-prefix:": "/api"},
+			Percent:         100,
+			AbortStatus:     503,
+			ResponseHeaders: map[string]string{"Retry-After": "30"},
+		}}, "/a", "/api/b", "/c")
+		assert.Equal(t, http.StatusOK, rss[0].StatusCode)
+		assert.Equal(t, http.StatusServiceUnavailable, rss[1].StatusCode)
+		assert.Equal(t, "30", rss[1].Header.Get("Retry-After"))
+		assert.Empty(t, rss[1].Header.Get("X-Path"))
+		assert.Equal(t, http.StatusOK, rss[2].StatusCode)
+		assert.Equal(t, "/c", rss[2].Header.Get("X-Path"))
+	})
+
+	t.Run("delay and rewrite", func(t *testing.T) {
+		start := time.Now()
+		rss := roundtrip(t, []*manager.FaultRule{{
+			Percent:         100,
+			Delay:           durationpb.New(100 * time.Millisecond),
+			ResponseHeaders: map[string]string{"Server": "", "X-Fault": "yes"},
+		}}, "/a")
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+		assert.Equal(t, http.StatusOK, rss[0].StatusCode)
+		assert.Equal(t, "/a", rss[0].Header.Get("X-Path"))
+		assert.Equal(t, "yes", rss[0].Header.Get("X-Fault"))
+		assert.Empty(t, rss[0].Header.Values("Server"))
+	})
+
+	t.Run("percent", func(t *testing.T) {
+		defer func(r func() float64) { faultRand = r }(faultRand)
+		rands := []float64{0.3, 0.1}
+		faultRand = func() float64 {
+			r := rands[0]
+			rands = rands[1:]
+			return r
+		}
+		rss := roundtrip(t, []*manager.FaultRule{{Percent: 20, AbortStatus: 500}}, "/a", "/b")
+		assert.Equal(t, http.StatusOK, rss[0].StatusCode)
+		assert.Equal(t, http.StatusInternalServerError, rss[1].StatusCode)
+	})
+
+	t.Run("not http", func(t *testing.T) {
+		frs, err := newFaultRules([]*manager.FaultRule{{Percent: 100, AbortStatus: 500}})
+		require.NoError(t, err)
+		client, server := net.Pipe()
+		fc := newFaultConn(server, frs)
+		defer client.Close()
+		defer fc.Close()
+		go func() { _, _ = io.Copy(fc, fc) }()
+
+		data := []byte("PING\r\n")
+		go func() { _, _ = client.Write(data) }()
+		echo := make([]byte, len(data))
+		_, err = io.ReadFull(client, echo)
+		require.NoError(t, err)
+		assert.Equal(t, data, echo)
+	})
+}
+
+func TestForwardFaultsOnly(t *testing.T) {
+	// The app container, which responds with a 200 to all requests.
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "app")
+	}))
+	defer app.Close()
+	appAddr := app.Listener.Addr().(*net.TCPAddr)
+
+	ctx, cancel := context.WithCancel(dlog.NewTestContext(t, false))
+	defer cancel()
+	f := NewInterceptor(&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}, "127.0.0.1", uint16(appAddr.Port))
+	initCh := make(chan net.Addr)
+	go func() { _ = f.Serve(ctx, initCh) }()
+	addr := <-initCh
+
+	f.SetIntercepting(&manager.InterceptInfo{
+		Id: "faults",
+		Spec: &manager.InterceptSpec{
+			Name:       "faults",
+			FaultsOnly: true,
+			FaultRules: []*manager.FaultRule{{
+				Match:           map[string]string{":path-prefix:": "/abort"},
+				Percent:         100,
+				AbortStatus:     http.StatusServiceUnavailable,
+				ResponseHeaders: map[string]string{"Retry-After": "30"},
+			}},
+		},
+	})
+	assert.False(t, f.InterceptInfo().Intercepted)
+
+	get := func(path string) *http.Response {
+		t.Helper()
+		rs, err := http.Get("http://" + addr.String() + path)
+		require.NoError(t, err)
+		_, _ = io.Copy(io.Discard, rs.Body)
+		_ = rs.Body.Close()
+		return rs
+	}
+
+	// Requests that match are aborted, and the others reach the app container.
+	rs := get("/abort/me")
+	assert.Equal(t, http.StatusServiceUnavailable, rs.StatusCode)
+	assert.Equal(t, "30", rs.Header.Get("Retry-After"))
+	rs = get("/other")
+	assert.Equal(t, http.StatusOK, rs.StatusCode)
+	assert.Equal(t, "app", rs.Header.Get("Server"))
+}
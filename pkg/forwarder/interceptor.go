@@ -16,11 +16,15 @@ import (
 
 type Interceptor interface {
 	io.Closer
+	AddSampler(*Sampler)
 	InterceptId() string
 	InterceptInfo() *restapi.InterceptInfo
+	InterceptMetrics() *manager.InterceptMetrics
+	RemoveSampler(*Sampler)
 	Serve(context.Context, chan<- net.Addr) error
 	SetIntercepting(*manager.InterceptInfo)
 	SetManager(*manager.SessionInfo, manager.ManagerClient, semver.Version)
+	SetTarget(string, uint16)
 	Target() (string, uint16)
 }
 
@@ -40,6 +44,9 @@ type interceptor struct {
 	sessionInfo *manager.SessionInfo
 
 	intercept  *manager.InterceptInfo
+	metrics    *interceptMetrics
+	faults     []*faultRule
+	samplers   []*Sampler
 	mgrVersion semver.Version
 }
 
@@ -74,10 +81,27 @@ func (f *interceptor) Target() (string, uint16) {
 	return f.targetHost, f.targetPort
 }
 
+// SetTarget changes the target that connections are forwarded to when there's no intercept. Connections to
+// the previous target are dropped.
+func (f *interceptor) SetTarget(targetHost string, targetPort uint16) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.targetHost == targetHost && f.targetPort == targetPort {
+		return
+	}
+	if f.intercept == nil && f.tCancel != nil {
+		dlog.Debugf(f.lCtx, "Forward target changed from %s:%d to %s:%d", f.targetHost, f.targetPort, targetHost, targetPort)
+		f.tCancel()
+		f.tCtx, f.tCancel = context.WithCancel(f.lCtx)
+	}
+	f.targetHost = targetHost
+	f.targetPort = targetPort
+}
+
 func (f *interceptor) InterceptInfo() *restapi.InterceptInfo {
 	ii := &restapi.InterceptInfo{}
 	f.mu.Lock()
-	if f.intercept != nil {
+	if f.intercept != nil && !f.intercept.Spec.FaultsOnly {
 		ii.Intercepted = true
 		ii.Metadata = f.intercept.Metadata
 	}
@@ -94,6 +118,17 @@ func (f *interceptor) InterceptId() (id string) {
 	return id
 }
 
+// InterceptMetrics returns the metrics of the current intercept, or nil when there is no intercept.
+func (f *interceptor) InterceptMetrics() *manager.InterceptMetrics {
+	f.mu.Lock()
+	m := f.metrics
+	f.mu.Unlock()
+	if m == nil {
+		return nil
+	}
+	return m.toRPC()
+}
+
 func (f *interceptor) SetIntercepting(intercept *manager.InterceptInfo) {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -124,4 +159,15 @@ func (f *interceptor) SetIntercepting(intercept *manager.InterceptInfo) {
 	// Set up new target and lifetime
 	f.tCtx, f.tCancel = context.WithCancel(f.lCtx)
 	f.intercept = intercept
+	f.metrics = nil
+	f.faults = nil
+	if intercept != nil {
+		f.metrics = newInterceptMetrics(intercept.Id)
+		if frs := intercept.Spec.FaultRules; len(frs) > 0 {
+			var err error
+			if f.faults, err = newFaultRules(frs); err != nil {
+				dlog.Errorf(f.lCtx, "Faults of intercept %s will not be injected: %v", iceptInfo(intercept), err)
+			}
+		}
+	}
 }
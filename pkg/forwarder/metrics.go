@@ -0,0 +1,142 @@
+package forwarder
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+// latencyBounds are the upper bounds of the buckets in the latency histogram of an intercept.
+var latencyBounds = []time.Duration{ //nolint:gochecknoglobals // constant
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// interceptMetrics counts the requests that an interceptor routes to the client of one intercept.
+type interceptMetrics struct {
+	sync.Mutex
+	interceptID   string
+	requests      uint64
+	errors        uint64
+	latencyCounts []uint64
+	latencySum    time.Duration
+	lastRequest   time.Time
+}
+
+func newInterceptMetrics(interceptID string) *interceptMetrics {
+	return &interceptMetrics{interceptID: interceptID, latencyCounts: make([]uint64, len(latencyBounds)+1)}
+}
+
+func (m *interceptMetrics) requestStarted(now time.Time) {
+	m.Lock()
+	m.requests++
+	m.lastRequest = now
+	m.Unlock()
+}
+
+func (m *interceptMetrics) requestFailed() {
+	m.Lock()
+	m.errors++
+	m.Unlock()
+}
+
+func (m *interceptMetrics) responded(latency time.Duration) {
+	i := 0
+	for i < len(latencyBounds) && latency > latencyBounds[i] {
+		i++
+	}
+	m.Lock()
+	m.latencyCounts[i]++
+	m.latencySum += latency
+	m.Unlock()
+}
+
+func (m *interceptMetrics) toRPC() *manager.InterceptMetrics {
+	bounds := make([]*durationpb.Duration, len(latencyBounds))
+	for i, b := range latencyBounds {
+		bounds[i] = durationpb.New(b)
+	}
+	m.Lock()
+	defer m.Unlock()
+	im := &manager.InterceptMetrics{
+		InterceptId:   m.interceptID,
+		Requests:      m.requests,
+		Errors:        m.errors,
+		LatencyBounds: bounds,
+		LatencyCounts: append([]uint64(nil), m.latencyCounts...),
+		LatencySum:    durationpb.New(m.latencySum),
+	}
+	if !m.lastRequest.IsZero() {
+		im.LastRequest = timestamppb.New(m.lastRequest)
+	}
+	return im
+}
+
+// timedConn is a connection that records the latency of the response to the request that it reads
+// in the interceptMetrics.
+type timedConn struct {
+	net.Conn
+	metrics *interceptMetrics
+
+	mu        sync.Mutex
+	accepted  time.Time
+	firstRead time.Time
+	responded bool
+}
+
+func newTimedConn(conn net.Conn, metrics *interceptMetrics) *timedConn {
+	now := time.Now()
+	metrics.requestStarted(now)
+	return &timedConn{Conn: conn, metrics: metrics, accepted: now}
+}
+
+func (c *timedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.mu.Lock()
+		if c.firstRead.IsZero() {
+			c.firstRead = time.Now()
+		}
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+func (c *timedConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	if !c.responded {
+		c.responded = true
+		start := c.firstRead
+		if start.IsZero() {
+			// The response came before any request, e.g. a server greeting.
+			start = c.accepted
+		}
+		c.metrics.responded(time.Since(start))
+	}
+	c.mu.Unlock()
+	return c.Conn.Write(b)
+}
+
+// done records an error when a request was read but no response was written. Connections that
+// are closed without any data, such as the ones from TCP probes, are not errors.
+func (c *timedConn) done() {
+	c.mu.Lock()
+	if !c.firstRead.IsZero() && !c.responded {
+		c.metrics.requestFailed()
+	}
+	c.mu.Unlock()
+}
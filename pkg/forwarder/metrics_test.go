@@ -0,0 +1,81 @@
+package forwarder
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterceptMetrics(t *testing.T) {
+	m := newInterceptMetrics("a:echo")
+	now := time.Now()
+	m.requestStarted(now.Add(-time.Second))
+	m.requestStarted(now)
+	m.requestFailed()
+	m.responded(3 * time.Millisecond)
+	m.responded(5 * time.Millisecond)
+	m.responded(time.Minute)
+
+	im := m.toRPC()
+	assert.Equal(t, "a:echo", im.InterceptId)
+	assert.Equal(t, uint64(2), im.Requests)
+	assert.Equal(t, uint64(1), im.Errors)
+	assert.True(t, now.Equal(im.LastRequest.AsTime()))
+	require.Len(t, im.LatencyBounds, len(latencyBounds))
+	require.Len(t, im.LatencyCounts, len(latencyBounds)+1)
+	assert.Equal(t, uint64(2), im.LatencyCounts[0])
+	assert.Equal(t, uint64(1), im.LatencyCounts[len(latencyBounds)])
+	assert.Equal(t, time.Minute+8*time.Millisecond, im.LatencySum.AsDuration())
+}
+
+func TestTimedConn(t *testing.T) {
+	exchange := func(t *testing.T, respond bool) *interceptMetrics {
+		m := newInterceptMetrics("a:echo")
+		client, server := net.Pipe()
+		defer client.Close()
+		tc := newTimedConn(server, m)
+		go func() {
+			_, _ = client.Write([]byte("ping"))
+			if respond {
+				_, _ = io.ReadFull(client, make([]byte, 4))
+			}
+		}()
+		_, err := io.ReadFull(tc, make([]byte, 4))
+		require.NoError(t, err)
+		if respond {
+			_, err = tc.Write([]byte("pong"))
+			require.NoError(t, err)
+		}
+		_ = tc.Close()
+		tc.done()
+		return m
+	}
+
+	im := exchange(t, true).toRPC()
+	assert.Equal(t, uint64(1), im.Requests)
+	assert.Equal(t, uint64(0), im.Errors)
+	n := uint64(0)
+	for _, c := range im.LatencyCounts {
+		n += c
+	}
+	assert.Equal(t, uint64(1), n)
+
+	im = exchange(t, false).toRPC()
+	assert.Equal(t, uint64(1), im.Requests)
+	assert.Equal(t, uint64(1), im.Errors)
+
+	// A connection that is closed without data, such as a TCP probe, is not an error.
+	m := newInterceptMetrics("a:echo")
+	client, server := net.Pipe()
+	_ = client.Close()
+	tc := newTimedConn(server, m)
+	_ = tc.Close()
+	tc.done()
+	im = m.toRPC()
+	assert.Equal(t, uint64(1), im.Requests)
+	assert.Equal(t, uint64(0), im.Errors)
+}
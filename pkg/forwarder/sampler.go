@@ -0,0 +1,180 @@
+package forwarder
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/matcher"
+)
+
+// Sampler counts the HTTP requests that pass through interceptors during a limited time, and how many of them
+// match a filter. Only connections that are accepted while the sampler is active are inspected.
+type Sampler struct {
+	matcher  matcher.Request
+	duration time.Duration
+	until    time.Time
+
+	mu          sync.Mutex
+	requests    uint64
+	matching    uint64
+	uninspected uint64
+}
+
+// NewSampler creates a Sampler that is active during the given duration. The filter is in the form
+// understood by matcher.NewRequestFromMap.
+func NewSampler(filter map[string]string, duration time.Duration) (*Sampler, error) {
+	m, err := matcher.NewRequestFromMap(filter)
+	if err != nil {
+		return nil, err
+	}
+	return &Sampler{matcher: m, duration: duration, until: time.Now().Add(duration)}, nil
+}
+
+func (s *Sampler) active(now time.Time) bool {
+	return now.Before(s.until)
+}
+
+func (s *Sampler) sample(rq *http.Request) {
+	if !s.active(time.Now()) {
+		return
+	}
+	matches := s.matcher.Matches(rq.URL.Path, rq.Header)
+	s.mu.Lock()
+	s.requests++
+	if matches {
+		s.matching++
+	}
+	s.mu.Unlock()
+}
+
+func (s *Sampler) notInspected() {
+	if !s.active(time.Now()) {
+		return
+	}
+	s.mu.Lock()
+	s.uninspected++
+	s.mu.Unlock()
+}
+
+// Result returns the counters of the sampler.
+func (s *Sampler) Result() *manager.SampleResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &manager.SampleResult{
+		Requests:               s.requests,
+		Matching:               s.matching,
+		UninspectedConnections: s.uninspected,
+		Duration:               durationpb.New(s.duration),
+	}
+}
+
+// AddSampler makes the interceptor inspect the requests of the connections that it accepts until the sampler is
+// removed or no longer active.
+func (f *interceptor) AddSampler(s *Sampler) {
+	f.mu.Lock()
+	f.samplers = append(f.samplers, s)
+	f.mu.Unlock()
+}
+
+// RemoveSampler removes a sampler that was added using AddSampler.
+func (f *interceptor) RemoveSampler(s *Sampler) {
+	f.mu.Lock()
+	for i, x := range f.samplers {
+		if x == s {
+			f.samplers = append(f.samplers[:i:i], f.samplers[i+1:]...)
+			break
+		}
+	}
+	f.mu.Unlock()
+}
+
+// activeSamplers returns the samplers that are active. Must be called with the lock held.
+func (f *interceptor) activeSamplers() []*Sampler {
+	var ss []*Sampler
+	now := time.Now()
+	for _, s := range f.samplers {
+		if s.active(now) {
+			ss = append(ss, s)
+		}
+	}
+	return ss
+}
+
+// sampledConn is a connection that passes what's read from it to a goroutine that parses it as HTTP/1.x
+// requests, and hands the requests to samplers.
+type sampledConn struct {
+	net.Conn
+	tee io.Reader
+	pw  *io.PipeWriter
+}
+
+func newSampledConn(conn net.Conn, ss []*Sampler) *sampledConn {
+	pr, pw := io.Pipe()
+	go sampleRequests(pr, ss)
+	return &sampledConn{Conn: conn, tee: io.TeeReader(conn, &sampleWriter{pw: pw}), pw: pw}
+}
+
+func (c *sampledConn) Read(b []byte) (int, error) {
+	return c.tee.Read(b)
+}
+
+// done ends the parsing of requests.
+func (c *sampledConn) done() {
+	_ = c.pw.Close()
+}
+
+// sampleWriter writes to the pipe of the parser until the parser gives up. It never returns an error, because
+// the sampling must not affect the connection.
+type sampleWriter struct {
+	pw     *io.PipeWriter
+	failed bool
+}
+
+func (w *sampleWriter) Write(b []byte) (int, error) {
+	if !w.failed {
+		if _, err := w.pw.Write(b); err != nil {
+			w.failed = true
+		}
+	}
+	return len(b), nil
+}
+
+// sampleRequests parses the requests read from the given pipe and hands them to the samplers. A connection
+// that doesn't start with an HTTP/1.x request, e.g. because it uses TLS or HTTP/2, is counted as not inspected.
+func sampleRequests(pr *io.PipeReader, ss []*Sampler) {
+	br := bufio.NewReader(pr)
+	for first := true; ; first = false {
+		rq, err := http.ReadRequest(br)
+		if err == nil && rq.ProtoMajor != 1 {
+			err = errors.New("not HTTP/1.x")
+		}
+		if err != nil {
+			if first && !errors.Is(err, io.EOF) {
+				for _, s := range ss {
+					s.notInspected()
+				}
+			}
+			_ = pr.CloseWithError(err)
+			return
+		}
+		active := false
+		for _, s := range ss {
+			s.sample(rq)
+			active = active || s.active(time.Now())
+		}
+		if !active {
+			_ = pr.Close()
+			return
+		}
+		_, _ = io.Copy(io.Discard, rq.Body)
+		_ = rq.Body.Close()
+	}
+}
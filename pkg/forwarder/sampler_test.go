@@ -0,0 +1,58 @@
+package forwarder
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampledConn(t *testing.T) {
+	sample := func(t *testing.T, s *Sampler, data string) {
+		client, server := net.Pipe()
+		sc := newSampledConn(server, []*Sampler{s})
+		copied := make(chan struct{})
+		go func() {
+			_, _ = io.Copy(io.Discard, sc)
+			close(copied)
+		}()
+		_, err := client.Write([]byte(data))
+		require.NoError(t, err)
+		client.Close()
+		<-copied
+		sc.done()
+	}
+
+	t.Run("http", func(t *testing.T) {
+		s, err := NewSampler(map[string]string{"x-user": "alice"}, time.Minute)
+		require.NoError(t, err)
+		sample(t, s, "GET /a HTTP/1.1\r\nHost: echo\r\nX-User: alice\r\n\r\n"+
+			"POST /b HTTP/1.1\r\nHost: echo\r\nContent-Length: 5\r\n\r\nhello"+
+			"GET /c HTTP/1.1\r\nHost: echo\r\nX-User: bob\r\n\r\n")
+		assert.Eventually(t, func() bool { return s.Result().Requests == 3 }, 5*time.Second, 10*time.Millisecond)
+		r := s.Result()
+		assert.Equal(t, uint64(1), r.Matching)
+		assert.Equal(t, uint64(0), r.UninspectedConnections)
+		assert.Equal(t, time.Minute, r.Duration.AsDuration())
+	})
+
+	t.Run("not http", func(t *testing.T) {
+		s, err := NewSampler(nil, time.Minute)
+		require.NoError(t, err)
+		sample(t, s, "\x16\x03\x01\x02\x00\x01\x00\x01\xfc\x03\x03")
+		assert.Eventually(t, func() bool { return s.Result().UninspectedConnections == 1 }, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, uint64(0), s.Result().Requests)
+	})
+
+	t.Run("inactive", func(t *testing.T) {
+		s, err := NewSampler(nil, time.Minute)
+		require.NoError(t, err)
+		s.until = time.Now()
+		sample(t, s, "GET /a HTTP/1.1\r\nHost: echo\r\n\r\n")
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, uint64(0), s.Result().Requests)
+	})
+}
@@ -98,9 +98,38 @@ func (f *tcp) forwardConn(clientConn *net.TCPConn) error {
 	targetHost := f.targetHost
 	targetPort := f.targetPort
 	intercept := f.intercept
+	metrics := f.metrics
+	faults := f.faults
+	samplers := f.activeSamplers()
 	f.mu.Unlock()
-	if intercept != nil {
-		return f.interceptConn(ctx, clientConn, intercept)
+
+	var conn net.Conn = clientConn
+	if len(samplers) > 0 {
+		sc := newSampledConn(clientConn, samplers)
+		defer sc.done()
+		conn = sc
+	}
+	// Responses from the target are written to out, which parses them when faults are injected.
+	var out io.Writer = clientConn
+	closeWrite := clientConn.CloseWrite
+	if len(faults) > 0 {
+		fc := newFaultConn(conn, faults)
+		defer fc.Close()
+		conn = fc
+		out = fc
+		closeWrite = func() error {
+			fc.closeResponses()
+			return clientConn.CloseWrite()
+		}
+	}
+	if intercept != nil && !intercept.Spec.FaultsOnly {
+		tc := newTimedConn(conn, metrics)
+		defer tc.done()
+		if err := f.interceptConn(ctx, tc, intercept); err != nil {
+			metrics.requestFailed()
+			return err
+		}
+		return nil
 	}
 
 	targetAddr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("%s:%d", targetHost, targetPort))
@@ -129,17 +158,17 @@ func (f *tcp) forwardConn(clientConn *net.TCPConn) error {
 	done := make(chan struct{})
 
 	go func() {
-		if _, err := io.Copy(targetConn, clientConn); err != nil {
+		if _, err := io.Copy(targetConn, conn); err != nil {
 			dlog.Debugf(ctx, "Error clientConn->targetConn: %+v", err)
 		}
 		_ = targetConn.CloseWrite()
 		done <- struct{}{}
 	}()
 	go func() {
-		if _, err := io.Copy(clientConn, targetConn); err != nil {
+		if _, err := io.Copy(out, targetConn); err != nil {
 			dlog.Debugf(ctx, "Error targetConn->clientConn: %+v", err)
 		}
-		_ = clientConn.CloseWrite()
+		_ = closeWrite()
 		done <- struct{}{}
 	}()
 
@@ -53,6 +53,7 @@ func (f *udp) Serve(ctx context.Context, initCh chan<- net.Addr) error {
 		f.mu.Lock()
 		ctx = f.tCtx
 		intercept := f.intercept
+		metrics := f.metrics
 		f.mu.Unlock()
 		if ctx.Err() != nil {
 			return nil
@@ -74,16 +75,16 @@ func (f *udp) Serve(ctx context.Context, initCh chan<- net.Addr) error {
 				initCh = nil
 			}
 		}
-		if err := f.forward(ctx, pc.(*net.UDPConn), intercept); err != nil {
+		if err := f.forward(ctx, pc.(*net.UDPConn), intercept, metrics); err != nil {
 			return err
 		}
 	}
 }
 
-func (f *udp) forward(ctx context.Context, conn *net.UDPConn, intercept *manager.InterceptInfo) error {
+func (f *udp) forward(ctx context.Context, conn *net.UDPConn, intercept *manager.InterceptInfo, metrics *interceptMetrics) error {
 	defer conn.Close()
 	if intercept != nil {
-		f.interceptConn(ctx, conn, intercept)
+		f.interceptConn(ctx, conn, intercept, metrics)
 		return nil
 	}
 	return f.forwardConn(ctx, conn)
@@ -201,7 +202,10 @@ func (u *udpHandler) forward(ctx context.Context) {
 	}
 }
 
-func (f *udp) interceptConn(ctx context.Context, conn *net.UDPConn, iCept *manager.InterceptInfo) {
+// interceptConn routes the packets read from the given connection to the client of the intercept. Each new
+// flow of packets counts as a request in the given metrics. UDP has no notion of a response, so no latencies
+// are recorded.
+func (f *udp) interceptConn(ctx context.Context, conn *net.UDPConn, iCept *manager.InterceptInfo, metrics *interceptMetrics) {
 	ctx, span := otel.Tracer("").Start(ctx, "interceptConn")
 	defer span.End()
 	tracing.RecordInterceptInfo(span, iCept)
@@ -211,12 +215,18 @@ func (f *udp) interceptConn(ctx context.Context, conn *net.UDPConn, iCept *manag
 
 	dlog.Infof(ctx, "Forwarding udp from %s to %s %s", conn.LocalAddr(), spec.Client, dest)
 	defer dlog.Infof(ctx, "Done forwarding udp from %s to %s %s", conn.LocalAddr(), spec.Client, dest)
-	d := tunnel.NewUDPListener(conn, dest, func(ctx context.Context, id tunnel.ConnID) (tunnel.Stream, error) {
+	d := tunnel.NewUDPListener(conn, dest, func(ctx context.Context, id tunnel.ConnID) (s tunnel.Stream, err error) {
+		metrics.requestStarted(time.Now())
+		defer func() {
+			if err != nil {
+				metrics.requestFailed()
+			}
+		}()
 		ms, err := f.manager.Tunnel(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("call to manager.Tunnel() failed. Id %s: %v", id, err)
 		}
-		s, err := tunnel.NewClientStream(ctx, ms, id, f.sessionInfo.SessionId, time.Duration(spec.RoundtripLatency), time.Duration(spec.DialTimeout))
+		s, err = tunnel.NewClientStream(ctx, ms, id, f.sessionInfo.SessionId, time.Duration(spec.RoundtripLatency), time.Duration(spec.DialTimeout))
 		if err != nil {
 			return nil, err
 		}
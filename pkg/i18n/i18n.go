@@ -0,0 +1,141 @@
+// Package i18n provides translations of user-facing messages. A message is identified by its English
+// text, which is also used when no translation exists for the selected locale.
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Catalog maps the English text of messages to their translations.
+type Catalog map[string]string
+
+var (
+	catalogs     = map[string]Catalog{} //nolint:gochecknoglobals // protected by catalogsLock
+	catalogsLock sync.RWMutex           //nolint:gochecknoglobals // protects catalogs
+)
+
+// Register adds the messages of the given catalog to the catalog of the given language. The language
+// is a language tag such as "de" or "pt_BR".
+func Register(lang string, c Catalog) {
+	lang = normalize(lang)
+	catalogsLock.Lock()
+	defer catalogsLock.Unlock()
+	rc, ok := catalogs[lang]
+	if !ok {
+		rc = make(Catalog, len(c))
+		catalogs[lang] = rc
+	}
+	for k, v := range c {
+		rc[k] = v
+	}
+}
+
+// LoadDir registers the catalogs found in the given directory. Each catalog is a YAML file that maps
+// the English text of messages to their translations. The name of the file, minus its ".yml" or ".yaml"
+// extension, is the language of the catalog. It is not an error if the directory doesn't exist.
+func LoadDir(dir string) error {
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, de := range des {
+		name := de.Name()
+		ext := filepath.Ext(name)
+		if de.IsDir() || !(ext == ".yml" || ext == ".yaml") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		var c Catalog
+		if err = yaml.Unmarshal(data, &c); err != nil {
+			return fmt.Errorf("unable to parse message catalog %s: %w", filepath.Join(dir, name), err)
+		}
+		Register(strings.TrimSuffix(name, ext), c)
+	}
+	return nil
+}
+
+// SelectLocale returns the locale to use. The TELEPRESENCE_LOCALE environment variable takes precedence
+// over the given configured locale, which in turn takes precedence over the LC_ALL, LC_MESSAGES, and LANG
+// environment variables.
+func SelectLocale(configured string) string {
+	if l := os.Getenv("TELEPRESENCE_LOCALE"); l != "" {
+		return l
+	}
+	if configured != "" {
+		return configured
+	}
+	for _, ev := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if l := os.Getenv(ev); l != "" {
+			return l
+		}
+	}
+	return ""
+}
+
+type localeKey struct{}
+
+// WithLocale returns a context that carries the given locale.
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// GetLocale returns the locale of the given context, or the locale given by SelectLocale if
+// the context has none.
+func GetLocale(ctx context.Context) string {
+	if l, ok := ctx.Value(localeKey{}).(string); ok {
+		return l
+	}
+	return SelectLocale("")
+}
+
+// T returns the translation of the given message for the locale of the given context, or
+// the message itself when no translation exists.
+func T(ctx context.Context, msg string) string {
+	locale := normalize(GetLocale(ctx))
+	if locale == "" {
+		return msg
+	}
+	catalogsLock.RLock()
+	defer catalogsLock.RUnlock()
+	for {
+		if t, ok := catalogs[locale][msg]; ok {
+			return t
+		}
+		// Fall back from a regional variant, such as "pt_BR", to its language.
+		i := strings.LastIndexByte(locale, '_')
+		if i < 0 {
+			return msg
+		}
+		locale = locale[:i]
+	}
+}
+
+// Sprintf formats the translation of the given format. The %w verb is treated as %v.
+func Sprintf(ctx context.Context, format string, a ...any) string {
+	return fmt.Sprintf(strings.ReplaceAll(T(ctx, format), "%w", "%v"), a...)
+}
+
+// normalize strips the encoding and modifier from a POSIX locale such as "de_DE.UTF-8@euro", and
+// uses an underscore as the separator between the language and the region.
+func normalize(locale string) string {
+	if i := strings.IndexAny(locale, ".@"); i >= 0 {
+		locale = locale[:i]
+	}
+	if locale == "C" || locale == "POSIX" {
+		return ""
+	}
+	return strings.ReplaceAll(locale, "-", "_")
+}
@@ -0,0 +1,63 @@
+package i18n_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+	"github.com/telepresenceio/telepresence/v2/pkg/i18n"
+)
+
+func TestTranslate(t *testing.T) {
+	i18n.Register("sv", i18n.Catalog{
+		"invalid output format %q": "ogiltigt utdataformat %q",
+		"not connected":            "inte ansluten",
+	})
+	i18n.Register("sv_FI", i18n.Catalog{
+		"not connected": "inte uppkopplad",
+	})
+	ctx := context.Background()
+
+	sv := i18n.WithLocale(ctx, "sv_SE.UTF-8")
+	assert.Equal(t, "inte ansluten", i18n.T(sv, "not connected"))
+	assert.Equal(t, "no translation", i18n.T(sv, "no translation"))
+	assert.Equal(t, `ogiltigt utdataformat "xml"`, i18n.Sprintf(sv, "invalid output format %q", "xml"))
+
+	fi := i18n.WithLocale(ctx, "sv-FI")
+	assert.Equal(t, "inte uppkopplad", i18n.T(fi, "not connected"))
+
+	c := i18n.WithLocale(ctx, "C")
+	assert.Equal(t, "not connected", i18n.T(c, "not connected"))
+
+	err := errcat.User.Newf("invalid output format %q", "xml")
+	assert.Equal(t, `ogiltigt utdataformat "xml"`, errcat.Message(sv, err))
+	assert.Equal(t, `invalid output format "xml"`, errcat.Message(c, err))
+	assert.Equal(t, "inte ansluten", errcat.Message(sv, errcat.WithCode(errcat.User.New("not connected"), errcat.CodeUser, nil)))
+	assert.Equal(t, "not connected", errcat.Message(sv, errors.New("not connected")))
+}
+
+func TestSelectLocale(t *testing.T) {
+	t.Setenv("TELEPRESENCE_LOCALE", "")
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "de_DE.UTF-8")
+	assert.Equal(t, "de_DE.UTF-8", i18n.SelectLocale(""))
+	assert.Equal(t, "fr", i18n.SelectLocale("fr"))
+	t.Setenv("TELEPRESENCE_LOCALE", "ja")
+	assert.Equal(t, "ja", i18n.SelectLocale("fr"))
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "nb.yml"), []byte(`"not connected": "ikke tilkoblet"`+"\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.txt"), []byte("ignored"), 0o600))
+	require.NoError(t, i18n.LoadDir(dir))
+	require.NoError(t, i18n.LoadDir(filepath.Join(dir, "missing")))
+	assert.Equal(t, "ikke tilkoblet", i18n.T(i18n.WithLocale(context.Background(), "nb_NO"), "not connected"))
+}
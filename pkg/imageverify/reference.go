@@ -0,0 +1,107 @@
+package imageverify
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	dockerHub     = "docker.io"
+	dockerHubHost = "registry-1.docker.io"
+)
+
+var digestRx = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`) //nolint:gochecknoglobals // constant
+
+// Reference is a parsed image reference such as "docker.io/datawire/tel2:2.14.0" or
+// "docker.io/datawire/tel2@sha256:0123...".
+type Reference struct {
+	// Registry is the registry domain, e.g. "docker.io" or "ghcr.io:443".
+	Registry string
+
+	// Repository is the repository path within the registry, e.g. "datawire/tel2".
+	Repository string
+
+	// Tag is the tag, or empty if the reference is pinned by digest only.
+	Tag string
+
+	// Digest is the manifest digest, e.g. "sha256:0123...", or empty if the reference isn't pinned.
+	Digest string
+}
+
+// ParseReference parses the given image reference. An image without a registry is assumed to be on
+// Docker Hub, and an image without tag or digest is assumed to use the "latest" tag.
+func ParseReference(s string) (*Reference, error) {
+	ref := &Reference{}
+	name := s
+	if i := strings.IndexByte(name, '@'); i >= 0 {
+		ref.Digest = name[i+1:]
+		name = name[:i]
+		if !digestRx.MatchString(ref.Digest) {
+			return nil, fmt.Errorf("invalid image reference %q: digest must be sha256:<64 hex digits>", s)
+		}
+	}
+	if i := strings.LastIndexByte(name, ':'); i > strings.LastIndexByte(name, '/') {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+		if ref.Tag == "" {
+			return nil, fmt.Errorf("invalid image reference %q: empty tag", s)
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("invalid image reference %q", s)
+	}
+	if registry, repo, ok := strings.Cut(name, "/"); ok && (strings.ContainsAny(registry, ".:") || registry == "localhost") {
+		ref.Registry = registry
+		ref.Repository = repo
+	} else {
+		ref.Registry = dockerHub
+		ref.Repository = name
+	}
+	if ref.Registry == dockerHub && !strings.ContainsRune(ref.Repository, '/') {
+		ref.Repository = "library/" + ref.Repository
+	}
+	if ref.Repository == "" {
+		return nil, fmt.Errorf("invalid image reference %q", s)
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref, nil
+}
+
+// Pinned returns true if the reference is pinned by digest.
+func (r *Reference) Pinned() bool {
+	return r.Digest != ""
+}
+
+// Name returns the registry and repository of the reference.
+func (r *Reference) Name() string {
+	return r.Registry + "/" + r.Repository
+}
+
+// WithDigest returns a copy of this reference that is pinned to the given digest.
+func (r *Reference) WithDigest(digest string) *Reference {
+	c := *r
+	c.Digest = digest
+	return &c
+}
+
+func (r *Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// host returns the host that serves the registry API.
+func (r *Reference) host() string {
+	if r.Registry == dockerHub {
+		return dockerHubHost
+	}
+	return r.Registry
+}
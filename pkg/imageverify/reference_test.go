@@ -0,0 +1,35 @@
+package imageverify
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReference(t *testing.T) {
+	const dg = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		in   string
+		want Reference
+	}{
+		{"tel2", Reference{Registry: "docker.io", Repository: "library/tel2", Tag: "latest"}},
+		{"datawire/tel2:2.14.0", Reference{Registry: "docker.io", Repository: "datawire/tel2", Tag: "2.14.0"}},
+		{"docker.io/datawire/tel2:2.14.0@" + dg, Reference{Registry: "docker.io", Repository: "datawire/tel2", Tag: "2.14.0", Digest: dg}},
+		{"ghcr.io/org/tel2@" + dg, Reference{Registry: "ghcr.io", Repository: "org/tel2", Digest: dg}},
+		{"localhost:5000/tel2:x", Reference{Registry: "localhost:5000", Repository: "tel2", Tag: "x"}},
+		{"localhost/tel2", Reference{Registry: "localhost", Repository: "tel2", Tag: "latest"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			ref, err := ParseReference(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, *ref)
+		})
+	}
+
+	for _, bad := range []string{"", ":tag", "tel2:", "tel2@sha256:123", "localhost/"} {
+		_, err := ParseReference(bad)
+		assert.Error(t, err, bad)
+	}
+}
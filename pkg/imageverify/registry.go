@@ -0,0 +1,193 @@
+package imageverify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// manifestMediaTypes are the media types accepted when fetching a manifest. A digest must be computed from
+// the manifest exactly as stored in the registry, so index and list types are accepted too.
+var manifestMediaTypes = strings.Join([]string{ //nolint:gochecknoglobals // constant
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+}, ", ")
+
+// errNotFound is returned by registry calls when the requested manifest or blob doesn't exist.
+var errNotFound = errors.New("not found")
+
+// maxBodySize limits the size of manifests and signature payloads read from a registry.
+const maxBodySize = 4 * 1024 * 1024
+
+// registry is a minimal client for the OCI distribution API. It uses anonymous bearer tokens when the
+// registry demands authentication.
+type registry struct {
+	client *http.Client
+}
+
+// manifest is the subset of an OCI image manifest that is needed to find cosign signatures.
+type manifest struct {
+	Layers []descriptor `json:"layers"`
+}
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// resolve returns the digest of the manifest that the given reference points to.
+func (r *registry) resolve(ctx context.Context, ref *Reference) (string, error) {
+	tag := ref.Tag
+	if ref.Digest != "" {
+		tag = ref.Digest
+	}
+	data, err := r.get(ctx, ref, "manifests/"+tag, manifestMediaTypes)
+	if err != nil {
+		return "", err
+	}
+	return digestOf(data), nil
+}
+
+// manifest returns the manifest with the given tag.
+func (r *registry) manifest(ctx context.Context, ref *Reference, tag string) (*manifest, error) {
+	data, err := r.get(ctx, ref, "manifests/"+tag, manifestMediaTypes)
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err = json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("unable to parse manifest %s:%s: %w", ref.Name(), tag, err)
+	}
+	return &m, nil
+}
+
+// blob returns the blob with the given digest, after verifying that its content matches the digest.
+func (r *registry) blob(ctx context.Context, ref *Reference, digest string) ([]byte, error) {
+	data, err := r.get(ctx, ref, "blobs/"+digest, "")
+	if err != nil {
+		return nil, err
+	}
+	if dg := digestOf(data); dg != digest {
+		return nil, fmt.Errorf("blob %s in %s has digest %s", digest, ref.Name(), dg)
+	}
+	return data, nil
+}
+
+func (r *registry) get(ctx context.Context, ref *Reference, path, accept string) ([]byte, error) {
+	u := fmt.Sprintf("https://%s/v2/%s/%s", ref.host(), ref.Repository, path)
+	rs, err := r.do(ctx, u, accept, "")
+	if err != nil {
+		return nil, err
+	}
+	if rs.StatusCode == http.StatusUnauthorized {
+		challenge := rs.Header.Get("WWW-Authenticate")
+		_ = rs.Body.Close()
+		token, err := r.token(ctx, challenge)
+		if err != nil {
+			return nil, fmt.Errorf("unable to authenticate with %s: %w", ref.Registry, err)
+		}
+		if rs, err = r.do(ctx, u, accept, token); err != nil {
+			return nil, err
+		}
+	}
+	defer rs.Body.Close()
+	switch rs.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(io.LimitReader(rs.Body, maxBodySize))
+	case http.StatusNotFound:
+		return nil, errNotFound
+	default:
+		return nil, fmt.Errorf("GET %s: %s", u, rs.Status)
+	}
+}
+
+func (r *registry) do(ctx context.Context, u, accept, token string) (*http.Response, error) {
+	rq, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		rq.Header.Set("Accept", accept)
+	}
+	if token != "" {
+		rq.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r.client.Do(rq)
+}
+
+// token obtains an anonymous bearer token using the given WWW-Authenticate challenge.
+func (r *registry) token(ctx context.Context, challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("unsupported authentication scheme %q", scheme)
+	}
+	ps := parseChallenge(params)
+	realm := ps["realm"]
+	if realm == "" {
+		return "", errors.New("no realm in authentication challenge")
+	}
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	for _, k := range []string{"service", "scope"} {
+		if v, ok := ps[k]; ok {
+			q.Set(k, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+	rs, err := r.do(ctx, u.String(), "application/json", "")
+	if err != nil {
+		return "", err
+	}
+	defer rs.Body.Close()
+	if rs.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", realm, rs.Status)
+	}
+	var tr struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(io.LimitReader(rs.Body, maxBodySize)).Decode(&tr); err != nil {
+		return "", err
+	}
+	if tr.Token != "" {
+		return tr.Token, nil
+	}
+	return tr.AccessToken, nil
+}
+
+// parseChallenge parses the comma separated key="value" pairs of a WWW-Authenticate challenge.
+func parseChallenge(s string) map[string]string {
+	ps := make(map[string]string)
+	for s != "" {
+		var k, v string
+		k, s, _ = strings.Cut(s, "=")
+		k = strings.ToLower(strings.TrimSpace(k))
+		if strings.HasPrefix(s, `"`) {
+			v, s, _ = strings.Cut(s[1:], `"`)
+			_, s, _ = strings.Cut(s, ",")
+		} else {
+			v, s, _ = strings.Cut(s, ",")
+		}
+		ps[k] = strings.TrimSpace(v)
+	}
+	return ps
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,207 @@
+// Package imageverify verifies container images against cosign signatures stored in their registry.
+//
+// Signatures are expected in the layout produced by "cosign sign --key": an OCI manifest tagged
+// "sha256-<hex digest>.sig" in the image's repository, with one layer per signature. Each layer holds a
+// simple signing payload, and its "dev.cosignproject.cosign/signature" annotation holds the base64 encoded
+// signature of that payload. Keyless (Fulcio certificate) signatures are not supported.
+package imageverify
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/datawire/dlib/dlog"
+)
+
+const (
+	// SignatureAnnotation is the layer annotation that holds the base64 encoded signature.
+	SignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+	// SimpleSigningMediaType is the media type of a cosign signature payload layer.
+	SimpleSigningMediaType = "application/vnd.dev.cosign.simplesigning.v1+json"
+)
+
+// Verifier verifies that images are signed by one of a set of public keys.
+type Verifier struct {
+	keys     []crypto.PublicKey
+	registry registry
+
+	// verified contains the names and digests of images that have been verified, e.g.
+	// "docker.io/datawire/tel2@sha256:0123...". Failures are not cached since they might be transient.
+	mu       sync.Mutex
+	verified map[string]struct{}
+}
+
+// payload is the cosign simple signing payload.
+type payload struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+// NewVerifier returns a Verifier that accepts signatures made with any of the PEM encoded public keys
+// in the given data.
+func NewVerifier(pemData []byte, client *http.Client) (*Verifier, error) {
+	var keys []crypto.PublicKey
+	for {
+		var b *pem.Block
+		if b, pemData = pem.Decode(pemData); b == nil {
+			break
+		}
+		if b.Type != "PUBLIC KEY" {
+			continue
+		}
+		key, err := x509.ParsePKIXPublicKey(b.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse public key: %w", err)
+		}
+		switch key.(type) {
+		case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+			keys = append(keys, key)
+		default:
+			return nil, fmt.Errorf("unsupported public key type %T", key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no PEM encoded public key found")
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Verifier{
+		keys:     keys,
+		registry: registry{client: client},
+		verified: make(map[string]struct{}),
+	}, nil
+}
+
+// LoadVerifier returns a Verifier for the PEM encoded public keys in the given file.
+func LoadVerifier(file string) (*Verifier, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	v, err := NewVerifier(data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return v, nil
+}
+
+// Verify verifies that the given image is signed by one of the verifier's keys, and returns the reference
+// pinned to the digest that was verified. An image that isn't pinned by digest is resolved using its tag.
+func (v *Verifier) Verify(ctx context.Context, image string) (*Reference, error) {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return nil, err
+	}
+	digest := ref.Digest
+	if digest == "" {
+		if digest, err = v.registry.resolve(ctx, ref); err != nil {
+			return nil, fmt.Errorf("unable to resolve digest of image %s: %w", image, err)
+		}
+	}
+	ref = ref.WithDigest(digest)
+	key := ref.Name() + "@" + digest
+	v.mu.Lock()
+	_, ok := v.verified[key]
+	v.mu.Unlock()
+	if ok {
+		return ref, nil
+	}
+
+	if err = v.verifyDigest(ctx, ref); err != nil {
+		return nil, fmt.Errorf("image %s failed signature verification: %w", image, err)
+	}
+	dlog.Debugf(ctx, "image %s has a valid signature", key)
+	v.mu.Lock()
+	v.verified[key] = struct{}{}
+	v.mu.Unlock()
+	return ref, nil
+}
+
+func (v *Verifier) verifyDigest(ctx context.Context, ref *Reference) error {
+	sigTag := strings.Replace(ref.Digest, ":", "-", 1) + ".sig"
+	m, err := v.registry.manifest(ctx, ref, sigTag)
+	if err != nil {
+		if errors.Is(err, errNotFound) {
+			return errors.New("no signature found")
+		}
+		return err
+	}
+	var lastErr error
+	for i := range m.Layers {
+		l := &m.Layers[i]
+		sig, ok := l.Annotations[SignatureAnnotation]
+		if !ok || l.MediaType != SimpleSigningMediaType {
+			continue
+		}
+		if lastErr = v.verifyLayer(ctx, ref, l, sig); lastErr == nil {
+			return nil
+		}
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no signature found")
+	}
+	return lastErr
+}
+
+func (v *Verifier) verifyLayer(ctx context.Context, ref *Reference, l *descriptor, sig string) error {
+	sigBytes, err := base64.StdEncoding.DecodeString(sig)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	data, err := v.registry.blob(ctx, ref, l.Digest)
+	if err != nil {
+		return err
+	}
+	if !v.verifySignature(data, sigBytes) {
+		return errors.New("signature does not match any of the trusted keys")
+	}
+	var p payload
+	if err = json.Unmarshal(data, &p); err != nil {
+		return fmt.Errorf("invalid signature payload: %w", err)
+	}
+	if p.Critical.Image.DockerManifestDigest != ref.Digest {
+		return fmt.Errorf("signature is for digest %s", p.Critical.Image.DockerManifestDigest)
+	}
+	return nil
+}
+
+func (v *Verifier) verifySignature(data, sig []byte) bool {
+	hash := sha256.Sum256(data)
+	for _, key := range v.keys {
+		switch key := key.(type) {
+		case *ecdsa.PublicKey:
+			if ecdsa.VerifyASN1(key, hash[:], sig) {
+				return true
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], sig) == nil {
+				return true
+			}
+		case ed25519.PublicKey:
+			if ed25519.Verify(key, data, sig) {
+				return true
+			}
+		}
+	}
+	return false
+}
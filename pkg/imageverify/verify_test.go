@@ -0,0 +1,132 @@
+package imageverify
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+)
+
+// testRegistry is a registry that serves the content of a map keyed by manifest tag or blob digest. It
+// requires an anonymous bearer token, just like Docker Hub.
+type testRegistry struct {
+	manifests map[string][]byte
+	blobs     map[string][]byte
+}
+
+func (r *testRegistry) ServeHTTP(w http.ResponseWriter, rq *http.Request) {
+	if rq.URL.Path == "/token" {
+		_, _ = w.Write([]byte(`{"token":"anonymous"}`))
+		return
+	}
+	if rq.Header.Get("Authorization") != "Bearer anonymous" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="https://%s/token",service="test",scope="repository:org/tel2:pull"`, rq.Host))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var data []byte
+	var ok bool
+	if tag, found := strings.CutPrefix(rq.URL.Path, "/v2/org/tel2/manifests/"); found {
+		data, ok = r.manifests[tag]
+	} else if dg, found := strings.CutPrefix(rq.URL.Path, "/v2/org/tel2/blobs/"); found {
+		data, ok = r.blobs[dg]
+	}
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	_, _ = w.Write(data)
+}
+
+func (r *testRegistry) sign(t *testing.T, key *ecdsa.PrivateKey, digest string) {
+	pl := []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"org/tel2"},"image":{"docker-manifest-digest":%q},"type":"cosign container image signature"}}`, digest))
+	hash := sha256.Sum256(pl)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+	require.NoError(t, err)
+	plDigest := digestOf(pl)
+	r.blobs[plDigest] = pl
+	m, err := json.Marshal(manifest{Layers: []descriptor{{
+		MediaType:   SimpleSigningMediaType,
+		Digest:      plDigest,
+		Size:        int64(len(pl)),
+		Annotations: map[string]string{SignatureAnnotation: base64.StdEncoding.EncodeToString(sig)},
+	}}})
+	require.NoError(t, err)
+	r.manifests[strings.Replace(digest, ":", "-", 1)+".sig"] = m
+}
+
+func publicKeyPEM(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestVerify(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	trusted, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	untrusted, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	signed := []byte(`{"schemaVersion":2,"layers":[]}`)
+	unsigned := []byte(`{"schemaVersion":2,"layers":[{}]}`)
+	foreign := []byte(`{"schemaVersion":2,"layers":[{},{}]}`)
+	reg := &testRegistry{
+		manifests: map[string][]byte{
+			"signed":           signed,
+			"unsigned":         unsigned,
+			"foreign":          foreign,
+			digestOf(signed):   signed,
+			digestOf(unsigned): unsigned,
+		},
+		blobs: map[string][]byte{},
+	}
+	reg.sign(t, trusted, digestOf(signed))
+	reg.sign(t, untrusted, digestOf(foreign))
+
+	srv := httptest.NewTLSServer(reg)
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "https://")
+
+	v, err := NewVerifier(publicKeyPEM(t, trusted), srv.Client())
+	require.NoError(t, err)
+
+	ref, err := v.Verify(ctx, host+"/org/tel2:signed")
+	require.NoError(t, err)
+	assert.Equal(t, digestOf(signed), ref.Digest)
+	assert.Equal(t, host+"/org/tel2:signed@"+digestOf(signed), ref.String())
+
+	_, err = v.Verify(ctx, host+"/org/tel2@"+digestOf(signed))
+	assert.NoError(t, err)
+
+	_, err = v.Verify(ctx, host+"/org/tel2:unsigned")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no signature found")
+
+	_, err = v.Verify(ctx, host+"/org/tel2:foreign")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match any of the trusted keys")
+
+	// A signature for another digest must not be accepted.
+	reg.manifests[strings.Replace(digestOf(unsigned), ":", "-", 1)+".sig"] = reg.manifests[strings.Replace(digestOf(signed), ":", "-", 1)+".sig"]
+	_, err = v.Verify(ctx, host+"/org/tel2:unsigned")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "signature is for digest")
+
+	_, err = NewVerifier([]byte("not a key"), nil)
+	assert.Error(t, err)
+}
@@ -12,4 +12,18 @@ const (
 	ManagerAppName            = "traffic-manager"
 	MutatorWebhookTLSName     = "mutator-webhook-tls"
 	TelAppMountPoint          = "/tel_app_mounts"
+
+	// EphemeralNamespaceLabel is set to "true" on namespaces created by "telepresence namespace create".
+	EphemeralNamespaceLabel = DomainPrefix + "ephemeral"
+
+	// ExpiresAtAnnotation is the RFC3339 time when the traffic-manager deletes an ephemeral namespace.
+	ExpiresAtAnnotation = DomainPrefix + "expires-at"
+
+	// CreatedByAnnotation is the Kubernetes user that created an ephemeral namespace, as seen by the
+	// traffic-manager's webhook.
+	CreatedByAnnotation = DomainPrefix + "created-by"
+
+	// EphemeralClaimAnnotation is set by the traffic-manager's webhook when an ephemeral namespace is created.
+	// The traffic-manager only deletes ephemeral namespaces with a valid claim.
+	EphemeralClaimAnnotation = DomainPrefix + "ephemeral-claim"
 )
@@ -172,12 +172,15 @@ func agentVolumeMounts(mounts []core.VolumeMount) []core.VolumeMount {
 func appEnvironment(appContainer *core.Container, apiPort int) []core.EnvVar {
 	appEnv := appContainer.Env
 	envCount := len(appEnv)
-	envCopy := make([]core.EnvVar, envCount, envCount+2)
+	envCopy := make([]core.EnvVar, envCount, envCount+3)
 	copy(envCopy, appEnv)
 	if apiPort != 0 {
 		envCopy = append(envCopy, core.EnvVar{
 			Name:  agentconfig.EnvAPIPort,
 			Value: strconv.Itoa(apiPort),
+		}, core.EnvVar{
+			Name:  agentconfig.EnvAPIURL,
+			Value: agentconfig.APIURL(apiPort),
 		})
 	}
 	envCopy = append(envCopy, core.EnvVar{
@@ -2,11 +2,14 @@ package helm
 
 import (
 	"bytes"
+	"strings"
 
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
 
 	telcharts "github.com/telepresenceio/telepresence/v2/charts"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
 	"github.com/telepresenceio/telepresence/v2/pkg/version"
 )
 
@@ -25,3 +28,27 @@ func loadCRDChart() (*chart.Chart, error) {
 	}
 	return loader.LoadArchive(&buf)
 }
+
+// ValidateValues validates the given values, merged with the defaults of the chart, against the values
+// schema of the traffic-manager chart, or of the CRD chart when crds is true. The error lists the path
+// of each value that doesn't meet the schema.
+func ValidateValues(values map[string]any, crds bool) error {
+	load := loadCoreChart
+	if crds {
+		load = loadCRDChart
+	}
+	chrt, err := load()
+	if err != nil {
+		return err
+	}
+	vals, err := chartutil.CoalesceValues(chrt, values)
+	if err != nil {
+		return errcat.User.New(err)
+	}
+	if err = chartutil.ValidateAgainstSchema(chrt, vals); err != nil {
+		// The error lists the violations per chart, and there's only one chart.
+		violations := strings.TrimPrefix(err.Error(), chrt.Name()+":\n")
+		return errcat.User.Newf("the Helm values don't meet the schema of the %s chart:\n%s", chrt.Name(), strings.TrimSpace(violations))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package helm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateValues(t *testing.T) {
+	require.NoError(t, ValidateValues(nil, false))
+	require.NoError(t, ValidateValues(map[string]any{
+		"logLevel":        "debug",
+		"podCIDRStrategy": "NodePodCIDRs",
+		"agent": map[string]any{
+			"logLevel": "trace",
+			"port":     9901,
+		},
+		"timeouts": map[string]any{"agentArrival": "1m30s"},
+	}, false))
+	require.NoError(t, ValidateValues(map[string]any{"anything": true}, true))
+
+	err := ValidateValues(map[string]any{
+		"agent":            map[string]any{"port": "9901"},
+		"agentInjector":    map[string]any{"webhook": map[string]any{"failurePolicy": "Sometimes"}},
+		"timeouts":         map[string]any{"agentArrival": "30"},
+		"externalEndpoint": map[string]any{"port": 70000},
+	}, false)
+	require.Error(t, err)
+	msg := err.Error()
+	assert.Contains(t, msg, "- agent.port: Invalid type. Expected: integer, given: string")
+	assert.Contains(t, msg, "- agentInjector.webhook.failurePolicy: agentInjector.webhook.failurePolicy must be one of the following")
+	assert.Contains(t, msg, "- timeouts.agentArrival: Does not match pattern")
+	assert.Contains(t, msg, "- externalEndpoint.port: Must be less than or equal to 65535")
+
+	// The external endpoint requires a token.
+	require.NoError(t, ValidateValues(map[string]any{
+		"externalEndpoint": map[string]any{"enabled": true, "tokenSecret": "tm-token"},
+	}, false))
+	require.Error(t, ValidateValues(map[string]any{
+		"externalEndpoint": map[string]any{"enabled": true},
+	}, false))
+	require.Error(t, ValidateValues(map[string]any{
+		"externalEndpoint": map[string]any{"enabled": true, "tokenSecret": ""},
+	}, false))
+
+	// Exec requires a policy with rules.
+	require.NoError(t, ValidateValues(map[string]any{
+		"exec": map[string]any{"enabled": true, "policy": map[string]any{"rules": []any{map[string]any{"groups": []any{"devs"}}}}},
+	}, false))
+	require.Error(t, ValidateValues(map[string]any{
+		"exec": map[string]any{"enabled": true},
+	}, false))
+	require.Error(t, ValidateValues(map[string]any{
+		"exec": map[string]any{"enabled": true, "policy": map[string]any{"rules": []any{}}},
+	}, false))
+}
+
+func TestKeepGeneratedCertificates(t *testing.T) {
+	live := `kind: MutatingWebhookConfiguration
+webhooks:
+- clientConfig:
+    caBundle: TElWRQ==
+---
+kind: Secret
+data:
+  ca.crt: TElWRQ==
+  tls.crt: TElWRS1DRVJU
+  tls.key: TElWRS1LRVk=
+`
+	preview := `kind: MutatingWebhookConfiguration
+webhooks:
+- clientConfig:
+    caBundle: TkVX
+  timeoutSeconds: 10
+---
+kind: Secret
+data:
+  ca.crt: TkVX
+  tls.crt: TkVXLUNFUlQ=
+  tls.key: TkVXLUtFWQ==
+`
+	assert.Equal(t, `kind: MutatingWebhookConfiguration
+webhooks:
+- clientConfig:
+    caBundle: TElWRQ==
+  timeoutSeconds: 10
+---
+kind: Secret
+data:
+  ca.crt: TElWRQ==
+  tls.crt: TElWRS1DRVJU
+  tls.key: TElWRS1LRVk=
+`, keepGeneratedCertificates(live, preview))
+}
@@ -141,29 +141,44 @@ func upgradeExisting(
 	values map[string]any,
 ) error {
 	dlog.Infof(ctx, "Existing Traffic Manager %s found in namespace %s, upgrading to %s...", existingVer, ns, client.Version())
-	if req.ReuseValues && len(values) > 0 {
+	upgrade, values, err := upgradeAction(helmConfig, releaseName, ns, req, values)
+	if err != nil {
+		return err
+	}
+	upgrade.Atomic = true
+	return timedRun(ctx, func(timeout time.Duration) error {
+		upgrade.Timeout = timeout
+		_, err := upgrade.Run(releaseName, chrt, values)
+		return err
+	})
+}
+
+// upgradeAction returns the action that upgrades the given release, together with the values to use.
+func upgradeAction(
+	helmConfig *action.Configuration,
+	releaseName, ns string,
+	req *connector.HelmRequest,
+	values map[string]any,
+) (*action.Upgrade, map[string]any, error) {
+	reuseValues := req.ReuseValues
+	if reuseValues && len(values) > 0 {
 		// We want the values applied even though ReuseValues is set.
 		getValues := action.NewGetValues(helmConfig)
 		oldValues, err := getValues.Run(releaseName)
 		if err != nil {
-			return fmt.Errorf("failed to get values for %s: %w", releaseName, err)
+			return nil, nil, fmt.Errorf("failed to get values for %s: %w", releaseName, err)
 		}
 		if len(oldValues) > 0 {
 			values = chartutil.CoalesceTables(values, oldValues)
 		}
-		req.ReuseValues = false
+		reuseValues = false
 	}
 	upgrade := action.NewUpgrade(helmConfig)
-	upgrade.Atomic = true
 	upgrade.Namespace = ns
 	upgrade.ResetValues = req.ResetValues
-	upgrade.ReuseValues = req.ReuseValues
+	upgrade.ReuseValues = reuseValues
 	upgrade.DisableHooks = req.NoHooks
-	return timedRun(ctx, func(timeout time.Duration) error {
-		upgrade.Timeout = timeout
-		_, err := upgrade.Run(releaseName, chrt, values)
-		return err
-	})
+	return upgrade, values, nil
 }
 
 func uninstallExisting(ctx context.Context, helmConfig *action.Configuration, releaseName, namespace string, req *connector.HelmRequest) error {
@@ -276,14 +291,9 @@ func ensureIsInstalled(
 	}
 
 	// OK, now install things.
-	var vals map[string]any
-	if len(req.ValuesJson) > 0 {
-		if err := json.Unmarshal(req.ValuesJson, &vals); err != nil {
-			return fmt.Errorf("unable to parse values JSON: %w", err)
-		}
-		vals = chartutil.CoalesceTables(vals, GetValuesFunc(ctx))
-	} else {
-		vals = GetValuesFunc(ctx)
+	vals, err := requestValues(ctx, req)
+	if err != nil {
+		return err
 	}
 
 	switch {
@@ -314,6 +324,18 @@ func ensureIsInstalled(
 	return err
 }
 
+// requestValues returns the values of the given request, merged with the values that the client's config imposes.
+func requestValues(ctx context.Context, req *connector.HelmRequest) (map[string]any, error) {
+	if len(req.ValuesJson) == 0 {
+		return GetValuesFunc(ctx), nil
+	}
+	var vals map[string]any
+	if err := json.Unmarshal(req.ValuesJson, &vals); err != nil {
+		return nil, fmt.Errorf("unable to parse values JSON: %w", err)
+	}
+	return chartutil.CoalesceTables(vals, GetValuesFunc(ctx)), nil
+}
+
 // DeleteTrafficManager deletes the traffic manager.
 func DeleteTrafficManager(
 	ctx context.Context, configFlags *genericclioptions.ConfigFlags, namespace string, errOnFail bool, req *connector.HelmRequest,
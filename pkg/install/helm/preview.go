@@ -0,0 +1,104 @@
+package helm
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/rpc/v2/connector"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/errcat"
+)
+
+// PreviewUpgrade returns a unified diff between the manifest of the installed release and the manifest that
+// an upgrade using the given request would produce. The diff is empty when the upgrade changes nothing. The
+// cluster is not modified.
+func PreviewUpgrade(ctx context.Context, configFlags *genericclioptions.ConfigFlags, namespace string, req *connector.HelmRequest) (string, error) {
+	releaseName := trafficManagerReleaseName
+	load := loadCoreChart
+	if req.Crds {
+		releaseName = crdReleaseName
+		load = loadCRDChart
+	}
+	chrt, err := load()
+	if err != nil {
+		return "", fmt.Errorf("unable to load built-in helm chart: %w", err)
+	}
+	existing, helmConfig, err := isInstalled(ctx, configFlags, releaseName, namespace)
+	if err != nil {
+		return "", fmt.Errorf("err detecting %s: %w", releaseName, err)
+	}
+	if existing == nil {
+		return "", errcat.User.Newf("%s is not installed in namespace %s, so there's no upgrade to preview", releaseName, namespace)
+	}
+	vals, err := requestValues(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	upgrade, vals, err := upgradeAction(helmConfig, releaseName, namespace, req, vals)
+	if err != nil {
+		return "", err
+	}
+	upgrade.DryRun = true
+	dlog.Infof(ctx, "Previewing upgrade of %s in namespace %s from %s to %s", releaseName, namespace, releaseVer(existing), client.Version())
+	rel, err := upgrade.Run(releaseName, chrt, vals)
+	if err != nil {
+		return "", err
+	}
+	manifest := rel.Manifest
+	if !regeneratesCertificate(chrt, rel.Config) {
+		manifest = keepGeneratedCertificates(existing.Manifest, manifest)
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(existing.Manifest),
+		B:        difflib.SplitLines(manifest),
+		FromFile: fmt.Sprintf("%s %s (live)", releaseName, releaseVer(existing)),
+		ToFile:   fmt.Sprintf("%s %s (upgrade)", releaseName, strings.TrimPrefix(client.Version(), "v")),
+		Context:  3,
+	})
+}
+
+// generatedCertificateRx matches the lines of the agent-injector's certificate that the chart generates
+// when it can't look up the existing ones, which it never can in a dry-run.
+var generatedCertificateRx = regexp.MustCompile(`^(\s*(?:caBundle|ca\.crt|tls\.crt|tls\.key):\s*)\S+\s*$`) //nolint:gochecknoglobals // constant
+
+// keepGeneratedCertificates replaces the certificates in the preview manifest with the ones of the live
+// manifest, because a real upgrade retains them.
+func keepGeneratedCertificates(live, preview string) string {
+	liveCerts := make(map[string]string)
+	for _, line := range strings.Split(live, "\n") {
+		if m := generatedCertificateRx.FindStringSubmatch(line); m != nil {
+			if _, ok := liveCerts[m[1]]; !ok {
+				liveCerts[m[1]] = line
+			}
+		}
+	}
+	lines := strings.Split(preview, "\n")
+	for i, line := range lines {
+		if m := generatedCertificateRx.FindStringSubmatch(line); m != nil {
+			if liveLine, ok := liveCerts[m[1]]; ok {
+				lines[i] = liveLine
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// regeneratesCertificate returns true if the given values, merged with the chart's defaults, make the chart
+// generate a new certificate for the agent-injector.
+func regeneratesCertificate(chrt *chart.Chart, vals map[string]any) bool {
+	cvs, err := chartutil.CoalesceValues(chrt, vals)
+	if err != nil {
+		return false
+	}
+	regenerate, _ := cvs.PathValue("agentInjector.certificate.regenerate")
+	b, _ := regenerate.(bool)
+	return b
+}
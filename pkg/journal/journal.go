@@ -0,0 +1,195 @@
+// Package journal records the changes that the root daemon makes to the system, such as routes, resolver files,
+// firewall rules, and TUN devices, in a file. A change is recorded when it's made and forgotten when it's reverted,
+// so the entries that remain when no root daemon is running were left behind by a daemon that didn't terminate
+// gracefully, and can be reverted by Repair.
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/datawire/dlib/dlog"
+)
+
+// Kind is the kind of change that an Entry records.
+type Kind string
+
+const (
+	// KindRoute is a static route. The ID is the routed subnet.
+	KindRoute = Kind("route")
+
+	// KindRoutingTable is a routing table and the rule that selects it. The ID is the index of the table.
+	KindRoutingTable = Kind("routing-table")
+
+	// KindFile is a file that was created, such as a resolver file. The ID is the path of the file.
+	KindFile = Kind("file")
+
+	// KindFirewall is a set of firewall rules. The ID is the name of the firewall backend.
+	KindFirewall = Kind("firewall")
+
+	// KindTUN is a TUN device. The ID is the name of the device.
+	KindTUN = Kind("tun")
+)
+
+// Entry is a change that was made to the system.
+type Entry struct {
+	Kind Kind              `json:"kind"`
+	ID   string            `json:"id"`
+	Data map[string]string `json:"data,omitempty"`
+	PID  int               `json:"pid"`
+	Time time.Time         `json:"time"`
+}
+
+func (e *Entry) String() string {
+	return fmt.Sprintf("%s %s", e.Kind, e.ID)
+}
+
+// Reverter reverts the change that the given entry records. It must return nil when there's nothing to revert,
+// e.g. when the device of a route no longer exists.
+type Reverter func(ctx context.Context, e *Entry) error
+
+// Journal is the file that records the changes.
+type Journal struct {
+	lock sync.Mutex
+	file string
+}
+
+// New returns a Journal that records its entries in the given file.
+func New(file string) *Journal {
+	return &Journal{file: file}
+}
+
+type journalKey struct{}
+
+// WithJournal returns a context that Record and Forget use to find the given journal.
+func WithJournal(ctx context.Context, j *Journal) context.Context {
+	return context.WithValue(ctx, journalKey{}, j)
+}
+
+// Record records the given change in the journal of the context. It's a no-op when the context has no journal.
+// An entry with the same kind and ID as an existing entry replaces that entry.
+func Record(ctx context.Context, kind Kind, id string, data map[string]string) {
+	if j, ok := ctx.Value(journalKey{}).(*Journal); ok {
+		e := &Entry{Kind: kind, ID: id, Data: data, PID: os.Getpid(), Time: time.Now()}
+		if err := j.update(func(es []*Entry) []*Entry { return append(remove(es, kind, id), e) }); err != nil {
+			dlog.Errorf(ctx, "failed to record %s in journal: %v", e, err)
+		}
+	}
+}
+
+// Forget removes the entry with the given kind and ID from the journal of the context, because the change that it
+// records has been reverted. It's a no-op when the context has no journal.
+func Forget(ctx context.Context, kind Kind, id string) {
+	if j, ok := ctx.Value(journalKey{}).(*Journal); ok {
+		if err := j.update(func(es []*Entry) []*Entry { return remove(es, kind, id) }); err != nil {
+			dlog.Errorf(ctx, "failed to forget %s %s in journal: %v", kind, id, err)
+		}
+	}
+}
+
+func remove(es []*Entry, kind Kind, id string) []*Entry {
+	for i, e := range es {
+		if e.Kind == kind && e.ID == id {
+			return append(es[:i], es[i+1:]...)
+		}
+	}
+	return es
+}
+
+// Entries returns the entries of the journal, oldest first.
+func (j *Journal) Entries() ([]*Entry, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.load()
+}
+
+// Repair reverts the changes that the entries of the journal record, newest first, and returns the entries that
+// were reverted. It must only be called when no root daemon is running, because it would otherwise revert changes
+// that the daemon depends on. The journal is empty when Repair returns. Entries that couldn't be reverted, or that
+// have no reverter, are dropped, and the errors are returned, so that one broken entry doesn't prevent all
+// future repairs.
+func (j *Journal) Repair(ctx context.Context, reverters map[Kind]Reverter) (reverted []*Entry, err error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	es, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+	var result *multierror.Error
+	for i := len(es) - 1; i >= 0; i-- {
+		e := es[i]
+		revert, ok := reverters[e.Kind]
+		if !ok {
+			result = multierror.Append(result, fmt.Errorf("unable to revert %s: unknown kind", e))
+			continue
+		}
+		dlog.Infof(ctx, "Reverting %s, recorded by process %d at %s", e, e.PID, e.Time.Format(time.RFC3339))
+		if rerr := revert(ctx, e); rerr != nil {
+			result = multierror.Append(result, fmt.Errorf("unable to revert %s: %w", e, rerr))
+			continue
+		}
+		reverted = append(reverted, e)
+	}
+	if err = j.save(nil); err != nil {
+		result = multierror.Append(result, err)
+	}
+	return reverted, result.ErrorOrNil()
+}
+
+func (j *Journal) update(f func([]*Entry) []*Entry) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	es, err := j.load()
+	if err != nil {
+		return err
+	}
+	return j.save(f(es))
+}
+
+func (j *Journal) load() ([]*Entry, error) {
+	data, err := os.ReadFile(j.file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return nil, err
+	}
+	var es []*Entry
+	if err = json.Unmarshal(data, &es); err != nil {
+		return nil, fmt.Errorf("failed to parse journal %s: %w", j.file, err)
+	}
+	return es, nil
+}
+
+func (j *Journal) save(es []*Entry) error {
+	if len(es) == 0 {
+		if err := os.Remove(j.file); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(es, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(j.file), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(j.file, data, 0o600)
+}
+
+// RemoveFile is the Reverter of KindFile entries.
+func RemoveFile(_ context.Context, e *Entry) error {
+	if err := os.Remove(e.ID); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package journal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/datawire/dlib/dlog"
+)
+
+func TestRecordAndForget(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	file := filepath.Join(t.TempDir(), "journal.json")
+	j := New(file)
+
+	// No journal in the context
+	Record(ctx, KindTUN, "tun0", nil)
+	_, err := os.Stat(file)
+	require.True(t, os.IsNotExist(err))
+
+	ctx = WithJournal(ctx, j)
+	Record(ctx, KindTUN, "tun0", nil)
+	Record(ctx, KindRoute, "10.0.0.0/8", map[string]string{"gateway": "192.168.0.1"})
+	Record(ctx, KindRoute, "10.0.0.0/8", map[string]string{"gateway": "192.168.0.2"})
+	es, err := j.Entries()
+	require.NoError(t, err)
+	require.Len(t, es, 2)
+	assert.Equal(t, "tun tun0", es[0].String())
+	assert.Equal(t, "192.168.0.2", es[1].Data["gateway"])
+	assert.Equal(t, os.Getpid(), es[1].PID)
+
+	Forget(ctx, KindTUN, "tun0")
+	Forget(ctx, KindRoute, "10.0.0.0/8")
+	_, err = os.Stat(file)
+	assert.True(t, os.IsNotExist(err), "an empty journal is removed")
+}
+
+func TestRepair(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	j := New(filepath.Join(t.TempDir(), "journal.json"))
+	ctx = WithJournal(ctx, j)
+	Record(ctx, KindTUN, "tun0", nil)
+	Record(ctx, KindFirewall, "nftables", nil)
+	Record(ctx, KindRoute, "10.0.0.0/8", nil)
+	Record(ctx, KindFile, "/etc/resolver/telepresence.local", nil)
+
+	var order []string
+	reverted, err := j.Repair(ctx, map[Kind]Reverter{
+		KindTUN: func(_ context.Context, e *Entry) error {
+			order = append(order, e.String())
+			return nil
+		},
+		KindRoute: func(_ context.Context, e *Entry) error {
+			order = append(order, e.String())
+			return nil
+		},
+		KindFirewall: func(_ context.Context, e *Entry) error {
+			return errors.New("boom")
+		},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to revert firewall nftables: boom")
+	assert.Contains(t, err.Error(), "unable to revert file /etc/resolver/telepresence.local: unknown kind")
+	assert.Equal(t, []string{"route 10.0.0.0/8", "tun tun0"}, order, "entries are reverted newest first")
+	assert.Len(t, reverted, 2)
+
+	es, err := j.Entries()
+	require.NoError(t, err)
+	assert.Empty(t, es)
+}
+
+func TestRemoveFile(t *testing.T) {
+	ctx := dlog.NewTestContext(t, false)
+	file := filepath.Join(t.TempDir(), "telepresence.local")
+	require.NoError(t, os.WriteFile(file, []byte("nameserver 127.0.0.1\n"), 0o644))
+	e := &Entry{Kind: KindFile, ID: file}
+	require.NoError(t, RemoveFile(ctx, e))
+	_, err := os.Stat(file)
+	assert.True(t, os.IsNotExist(err))
+	assert.NoError(t, RemoveFile(ctx, e), "removing a file that doesn't exist is not an error")
+}
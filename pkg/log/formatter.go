@@ -81,3 +81,25 @@ func (f *Formatter) Format(entry *logrus.Entry) ([]byte, error) {
 
 	return b.Bytes(), nil
 }
+
+// JSONFormatter formats log messages for Telepresence as JSON objects, one per line.
+type JSONFormatter struct {
+	logrus.JSONFormatter
+}
+
+func NewJSONFormatter(timestampFormat string) *JSONFormatter {
+	return &JSONFormatter{JSONFormatter: logrus.JSONFormatter{TimestampFormat: timestampFormat}}
+}
+
+// Format implements logrus.Formatter.
+func (f *JSONFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	if goroutine, ok := entry.Data["THREAD"].(string); ok {
+		data := maps.Copy(entry.Data)
+		delete(data, "THREAD")
+		data["goroutine"] = strings.TrimPrefix(goroutine, "/")
+		ec := *entry
+		ec.Data = data
+		entry = &ec
+	}
+	return f.JSONFormatter.Format(entry)
+}
@@ -0,0 +1,359 @@
+package pcap
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/ipproto"
+)
+
+// Filter reports whether a raw IP packet matches a filter expression.
+type Filter func(packet []byte) bool
+
+// MatchAll is the Filter of an empty expression.
+func MatchAll([]byte) bool {
+	return true
+}
+
+// ParseFilter compiles a filter expression that uses a subset of the tcpdump filter syntax. The supported
+// primitives are:
+//
+//	ip, ip6, tcp, udp, icmp, icmp6
+//	[src|dst] host <address>
+//	[src|dst] net <cidr>
+//	[src|dst] port <number>
+//	[src|dst] portrange <number>-<number>
+//	less <length>, greater <length>
+//
+// A protocol may qualify a port primitive, as in "tcp port 80". Primitives are combined using "and" or
+// "&&", "or" or "||", "not" or "!", and parentheses. An empty expression matches all packets.
+func ParseFilter(expr string) (Filter, error) {
+	p := &filterParser{tokens: tokenizeFilter(expr)}
+	if len(p.tokens) == 0 {
+		return MatchAll, nil
+	}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in filter expression", p.tokens[p.pos])
+	}
+	return func(packet []byte) bool {
+		pi, ok := decodePacket(packet)
+		return ok && f(&pi)
+	}, nil
+}
+
+// packetInfo holds the parts of a packet that a filter can match.
+type packetInfo struct {
+	length  int
+	version int
+	proto   int
+	src     net.IP
+	dst     net.IP
+	hasPort bool
+	srcPort uint16
+	dstPort uint16
+}
+
+func decodePacket(packet []byte) (pi packetInfo, ok bool) {
+	var payload []byte
+	pi.length = len(packet)
+	switch header.IPVersion(packet) {
+	case header.IPv4Version:
+		if len(packet) < header.IPv4MinimumSize {
+			return pi, false
+		}
+		ip := header.IPv4(packet)
+		hl := int(ip.HeaderLength())
+		if hl < header.IPv4MinimumSize || hl > len(packet) {
+			return pi, false
+		}
+		pi.version = 4
+		pi.proto = int(ip.Protocol())
+		pi.src = net.IP(packet[12:16])
+		pi.dst = net.IP(packet[16:20])
+		if ip.FragmentOffset() == 0 {
+			payload = packet[hl:]
+		}
+	case header.IPv6Version:
+		if len(packet) < header.IPv6MinimumSize {
+			return pi, false
+		}
+		ip := header.IPv6(packet)
+		pi.version = 6
+		pi.proto = int(ip.NextHeader())
+		pi.src = net.IP(packet[8:24])
+		pi.dst = net.IP(packet[24:40])
+		payload = packet[header.IPv6MinimumSize:]
+	default:
+		return pi, false
+	}
+	if (pi.proto == ipproto.TCP || pi.proto == ipproto.UDP) && len(payload) >= 4 {
+		pi.hasPort = true
+		pi.srcPort = uint16(payload[0])<<8 | uint16(payload[1])
+		pi.dstPort = uint16(payload[2])<<8 | uint16(payload[3])
+	}
+	return pi, true
+}
+
+type packetMatcher func(*packetInfo) bool
+
+func tokenizeFilter(expr string) []string {
+	var tokens []string
+	var sb strings.Builder
+	flush := func() {
+		if sb.Len() > 0 {
+			tokens = append(tokens, sb.String())
+			sb.Reset()
+		}
+	}
+	rs := []rune(expr)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case r == '(' || r == ')' || r == '!':
+			flush()
+			tokens = append(tokens, string(r))
+		case (r == '&' || r == '|') && i+1 < len(rs) && rs[i+1] == r:
+			flush()
+			tokens = append(tokens, string([]rune{r, r}))
+			i++
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	flush()
+	return tokens
+}
+
+type filterParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *filterParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *filterParser) next() (string, error) {
+	if p.pos < len(p.tokens) {
+		t := p.tokens[p.pos]
+		p.pos++
+		return t, nil
+	}
+	return "", fmt.Errorf("unexpected end of filter expression")
+}
+
+func (p *filterParser) parseOr() (packetMatcher, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t == "or" || t == "||"; t = p.peek() {
+		p.pos++
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		ll := l
+		l = func(pi *packetInfo) bool { return ll(pi) || r(pi) }
+	}
+	return l, nil
+}
+
+func (p *filterParser) parseAnd() (packetMatcher, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for t := p.peek(); t == "and" || t == "&&"; t = p.peek() {
+		p.pos++
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		ll := l
+		l = func(pi *packetInfo) bool { return ll(pi) && r(pi) }
+	}
+	return l, nil
+}
+
+func (p *filterParser) parseNot() (packetMatcher, error) {
+	if t := p.peek(); t == "not" || t == "!" {
+		p.pos++
+		m, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(pi *packetInfo) bool { return !m(pi) }, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *filterParser) parsePrimary() (packetMatcher, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch t {
+	case "(":
+		m, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t, err = p.next(); err != nil || t != ")" {
+			return nil, fmt.Errorf("missing ')' in filter expression")
+		}
+		return m, nil
+	case "ip":
+		return func(pi *packetInfo) bool { return pi.version == 4 }, nil
+	case "ip6":
+		return func(pi *packetInfo) bool { return pi.version == 6 }, nil
+	case "icmp":
+		return protoMatcher(ipproto.ICMP), nil
+	case "icmp6":
+		return protoMatcher(ipproto.ICMPV6), nil
+	case "tcp", "udp":
+		m := protoMatcher(ipproto.Parse(t))
+		if n := p.peek(); n == "port" || n == "portrange" || n == "src" || n == "dst" {
+			pm, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			return func(pi *packetInfo) bool { return m(pi) && pm(pi) }, nil
+		}
+		return m, nil
+	case "less", "greater":
+		v, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid length %q in filter expression", v)
+		}
+		if t == "less" {
+			return func(pi *packetInfo) bool { return pi.length <= n }, nil
+		}
+		return func(pi *packetInfo) bool { return pi.length >= n }, nil
+	case "src", "dst":
+		return p.parseQualified(t)
+	default:
+		return p.parseQualified("")
+	}
+}
+
+// parseQualified parses a host, net, port, or portrange primitive. The dir is "src", "dst", or empty when
+// the primitive matches either direction. When dir is empty, the current token is the primitive's name.
+func (p *filterParser) parseQualified(dir string) (packetMatcher, error) {
+	if dir == "" {
+		p.pos--
+	}
+	kind, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case "host", "net":
+		v, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		var ipNet *net.IPNet
+		if kind == "host" {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid host address %q in filter expression", v)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+				bits = 8 * net.IPv4len
+			}
+			ipNet = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		} else if _, ipNet, err = net.ParseCIDR(v); err != nil {
+			return nil, fmt.Errorf("invalid net %q in filter expression", v)
+		}
+		return dirMatcher(dir, func(ip net.IP) bool { return ipNet.Contains(ip) }, nil), nil
+	case "port", "portrange":
+		v, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		lo, hi, err := parsePortRange(v, kind == "portrange")
+		if err != nil {
+			return nil, err
+		}
+		return dirMatcher(dir, nil, func(port uint16) bool { return port >= lo && port <= hi }), nil
+	}
+	return nil, fmt.Errorf("unknown filter primitive %q", kind)
+}
+
+func parsePortRange(v string, isRange bool) (lo, hi uint16, err error) {
+	parsePort := func(s string) (uint16, error) {
+		n, err := strconv.ParseUint(s, 10, 16)
+		if err != nil {
+			return 0, fmt.Errorf("invalid port %q in filter expression", s)
+		}
+		return uint16(n), nil
+	}
+	if !isRange {
+		lo, err = parsePort(v)
+		return lo, lo, err
+	}
+	ls, hs, ok := strings.Cut(v, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid port range %q in filter expression", v)
+	}
+	if lo, err = parsePort(ls); err != nil {
+		return 0, 0, err
+	}
+	if hi, err = parsePort(hs); err != nil {
+		return 0, 0, err
+	}
+	if lo > hi {
+		lo, hi = hi, lo
+	}
+	return lo, hi, nil
+}
+
+func protoMatcher(proto int) packetMatcher {
+	return func(pi *packetInfo) bool { return pi.proto == proto }
+}
+
+// dirMatcher returns a matcher that applies either ipMatch or portMatch to the source, the destination,
+// or both, depending on dir.
+func dirMatcher(dir string, ipMatch func(net.IP) bool, portMatch func(uint16) bool) packetMatcher {
+	src := func(pi *packetInfo) bool {
+		if ipMatch != nil {
+			return ipMatch(pi.src)
+		}
+		return pi.hasPort && portMatch(pi.srcPort)
+	}
+	dst := func(pi *packetInfo) bool {
+		if ipMatch != nil {
+			return ipMatch(pi.dst)
+		}
+		return pi.hasPort && portMatch(pi.dstPort)
+	}
+	switch dir {
+	case "src":
+		return src
+	case "dst":
+		return dst
+	default:
+		return func(pi *packetInfo) bool { return src(pi) || dst(pi) }
+	}
+}
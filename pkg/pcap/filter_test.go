@@ -0,0 +1,94 @@
+package pcap
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/ipproto"
+)
+
+// ipv4Packet returns an IPv4 packet with the given protocol, addresses, and ports, and a 20 byte payload.
+func ipv4Packet(proto byte, src, dst string, srcPort, dstPort uint16) []byte {
+	p := make([]byte, 40)
+	p[0] = 0x45
+	p[3] = byte(len(p))
+	p[8] = 64
+	p[9] = proto
+	copy(p[12:16], net.ParseIP(src).To4())
+	copy(p[16:20], net.ParseIP(dst).To4())
+	p[20], p[21] = byte(srcPort>>8), byte(srcPort)
+	p[22], p[23] = byte(dstPort>>8), byte(dstPort)
+	return p
+}
+
+func TestParseFilter(t *testing.T) {
+	tcp := ipv4Packet(ipproto.TCP, "10.1.2.3", "192.168.0.5", 43210, 80)
+	udp := ipv4Packet(ipproto.UDP, "192.168.0.5", "10.1.2.3", 53, 43210)
+	ipv6 := make([]byte, 48)
+	ipv6[0] = 0x60
+	ipv6[6] = ipproto.UDP
+	copy(ipv6[8:24], net.ParseIP("fd00::1"))
+	copy(ipv6[24:40], net.ParseIP("fd00::2"))
+
+	tests := []struct {
+		filter string
+		tcp    bool
+		udp    bool
+		ipv6   bool
+	}{
+		{"", true, true, true},
+		{"tcp", true, false, false},
+		{"udp", false, true, true},
+		{"ip", true, true, false},
+		{"ip6", false, false, true},
+		{"port 80", true, false, false},
+		{"tcp port 53", false, false, false},
+		{"src port 53", false, true, false},
+		{"dst port 53", false, false, false},
+		{"portrange 40000-50000", true, true, false},
+		{"host 10.1.2.3", true, true, false},
+		{"src host 10.1.2.3", true, false, false},
+		{"dst net 10.0.0.0/8", false, true, false},
+		{"net fd00::/64", false, false, true},
+		{"not tcp", false, true, true},
+		{"!tcp && !ip6", false, true, false},
+		{"tcp or ip6", true, false, true},
+		{"host 10.1.2.3 and (port 80 || port 8080)", true, false, false},
+		{"less 40", true, true, false},
+		{"greater 41", false, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			f, err := ParseFilter(tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.tcp, f(tcp), "tcp packet")
+			assert.Equal(t, tt.udp, f(udp), "udp packet")
+			assert.Equal(t, tt.ipv6, f(ipv6), "ipv6 packet")
+		})
+	}
+
+	f, err := ParseFilter("tcp")
+	require.NoError(t, err)
+	assert.False(t, f([]byte{0x45, 0}), "truncated packets never match")
+}
+
+func TestParseFilter_errors(t *testing.T) {
+	for _, filter := range []string{
+		"tcp and",
+		"(tcp",
+		"tcp)",
+		"host",
+		"host 10.1.2",
+		"net 10.0.0.0",
+		"port 70000",
+		"portrange 80",
+		"less x",
+		"ether host 01:02:03:04:05:06",
+	} {
+		_, err := ParseFilter(filter)
+		assert.Error(t, err, filter)
+	}
+}
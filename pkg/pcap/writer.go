@@ -0,0 +1,83 @@
+// Package pcap writes IP packets in the libpcap file format that is understood by tools like Wireshark and
+// tcpdump, and filters packets using a subset of the tcpdump filter syntax.
+package pcap
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+const (
+	magicMicroseconds = 0xa1b2c3d4
+	versionMajor      = 2
+	versionMinor      = 4
+
+	// LinkTypeRaw is the link-layer header type of packets that start with an IPv4 or IPv6 header.
+	LinkTypeRaw = 101
+
+	// DefaultSnapLen is the snapshot length used when no other length is given.
+	DefaultSnapLen = 0x40000
+
+	fileHeaderLen = 24
+
+	// PacketHeaderLen is the length of the header that precedes each packet in the file.
+	PacketHeaderLen = 16
+)
+
+// Writer writes packets to an io.Writer in the libpcap file format.
+type Writer struct {
+	w       io.Writer
+	snapLen int
+	written int64
+	buf     [PacketHeaderLen]byte
+}
+
+// NewWriter writes the file header to the given io.Writer and returns a Writer that writes raw IP packets
+// to it. A snapLen <= 0 means DefaultSnapLen.
+func NewWriter(w io.Writer, snapLen int) (*Writer, error) {
+	if snapLen <= 0 {
+		snapLen = DefaultSnapLen
+	}
+	var hdr [fileHeaderLen]byte
+	binary.LittleEndian.PutUint32(hdr[0:], magicMicroseconds)
+	binary.LittleEndian.PutUint16(hdr[4:], versionMajor)
+	binary.LittleEndian.PutUint16(hdr[6:], versionMinor)
+	// hdr[8:16] are the time zone correction and the timestamp accuracy, which are always zero.
+	binary.LittleEndian.PutUint32(hdr[16:], uint32(snapLen))
+	binary.LittleEndian.PutUint32(hdr[20:], LinkTypeRaw)
+	if _, err := w.Write(hdr[:]); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, snapLen: snapLen, written: fileHeaderLen}, nil
+}
+
+// WritePacket writes a packet that was captured at the given time. The data is truncated to the snapLen of
+// the Writer. The origLen is the length of the packet before it was captured, and is set to the length of
+// data when it is less than that.
+func (pw *Writer) WritePacket(ts time.Time, data []byte, origLen int) error {
+	if len(data) > pw.snapLen {
+		data = data[:pw.snapLen]
+	}
+	if origLen < len(data) {
+		origLen = len(data)
+	}
+	hdr := pw.buf[:]
+	binary.LittleEndian.PutUint32(hdr[0:], uint32(ts.Unix()))
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(ts.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(hdr[8:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(hdr[12:], uint32(origLen))
+	if _, err := pw.w.Write(hdr); err != nil {
+		return err
+	}
+	if _, err := pw.w.Write(data); err != nil {
+		return err
+	}
+	pw.written += int64(PacketHeaderLen + len(data))
+	return nil
+}
+
+// Written returns the number of bytes written so far, including the file header.
+func (pw *Writer) Written() int64 {
+	return pw.written
+}
@@ -0,0 +1,42 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf, 30)
+	require.NoError(t, err)
+
+	ts := time.Unix(1700000000, 123456789)
+	packet := ipv4Packet(6, "10.1.2.3", "10.1.2.4", 1234, 80)
+	require.NoError(t, w.WritePacket(ts, packet, len(packet)))
+	require.NoError(t, w.WritePacket(ts, packet[:10], 0))
+
+	data := buf.Bytes()
+	assert.Equal(t, int64(len(data)), w.Written())
+	require.Len(t, data, fileHeaderLen+PacketHeaderLen+30+PacketHeaderLen+10)
+
+	le := binary.LittleEndian
+	assert.Equal(t, uint32(magicMicroseconds), le.Uint32(data[0:]))
+	assert.Equal(t, uint32(30), le.Uint32(data[16:]))
+	assert.Equal(t, uint32(LinkTypeRaw), le.Uint32(data[20:]))
+
+	ph := data[fileHeaderLen:]
+	assert.Equal(t, uint32(1700000000), le.Uint32(ph[0:]))
+	assert.Equal(t, uint32(123456), le.Uint32(ph[4:]))
+	assert.Equal(t, uint32(30), le.Uint32(ph[8:]), "captured length is truncated to the snap length")
+	assert.Equal(t, uint32(len(packet)), le.Uint32(ph[12:]))
+	assert.Equal(t, packet[:30], ph[PacketHeaderLen:PacketHeaderLen+30])
+
+	ph = ph[PacketHeaderLen+30:]
+	assert.Equal(t, uint32(10), le.Uint32(ph[8:]))
+	assert.Equal(t, uint32(10), le.Uint32(ph[12:]), "original length is never less than the captured length")
+}
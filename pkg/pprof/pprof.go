@@ -2,14 +2,51 @@ package pprof
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	_ "net/http/pprof"
+	"runtime/pprof"
+	"runtime/trace"
+	"time"
 
 	"github.com/datawire/dlib/dhttp"
+	"github.com/datawire/dlib/dtime"
 )
 
+// ErrUnknownProfile is returned by WriteProfile when there's no profile with the given name.
+var ErrUnknownProfile = errors.New("unknown profile")
+
 func PprofServer(ctx context.Context, port uint16) error {
 	sc := dhttp.ServerConfig{Handler: http.DefaultServeMux}
 	return sc.ListenAndServe(ctx, fmt.Sprintf("localhost:%d", port))
 }
+
+// WriteProfile writes the profile with the given name to the given writer. The name is either "cpu" or "trace",
+// which are collected during the given duration, or the name of a runtime/pprof profile such as "heap" or
+// "goroutine", which is written using the given debug parameter. A canceled context ends the collection of a
+// "cpu" or "trace" profile early.
+func WriteProfile(ctx context.Context, w io.Writer, name string, duration time.Duration, debug int) error {
+	switch name {
+	case "cpu":
+		if err := pprof.StartCPUProfile(w); err != nil {
+			return err
+		}
+		dtime.SleepWithContext(ctx, duration)
+		pprof.StopCPUProfile()
+	case "trace":
+		if err := trace.Start(w); err != nil {
+			return err
+		}
+		dtime.SleepWithContext(ctx, duration)
+		trace.Stop()
+	default:
+		p := pprof.Lookup(name)
+		if p == nil {
+			return fmt.Errorf("%w %q", ErrUnknownProfile, name)
+		}
+		return p.WriteTo(w, debug)
+	}
+	return nil
+}
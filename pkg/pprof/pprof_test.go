@@ -0,0 +1,28 @@
+package pprof
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteProfile(t *testing.T) {
+	ctx := context.Background()
+	bf := &bytes.Buffer{}
+	require.NoError(t, WriteProfile(ctx, bf, "goroutine", 0, 1))
+	assert.Contains(t, bf.String(), "TestWriteProfile")
+
+	bf.Reset()
+	require.NoError(t, WriteProfile(ctx, bf, "heap", 0, 0))
+	assert.Equal(t, []byte{0x1f, 0x8b}, bf.Bytes()[:2], "gzipped protobuf")
+
+	bf.Reset()
+	require.NoError(t, WriteProfile(ctx, bf, "cpu", 10*time.Millisecond, 0))
+	assert.NotZero(t, bf.Len())
+
+	assert.ErrorIs(t, WriteProfile(ctx, bf, "memory", 0, 0), ErrUnknownProfile)
+}
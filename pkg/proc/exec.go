@@ -116,6 +116,11 @@ func Terminate(p *os.Process) error {
 	return terminate(p)
 }
 
+// IsAlive returns true if a process with the given pid is running.
+func IsAlive(pid int) bool {
+	return isAlive(pid)
+}
+
 // CacheAdmin will ensure that the current process is able to invoke subprocesses with admin rights
 // without having to ask for the password again. This is needed among other things to make sure the
 // integration tests can see that a password is being asked for.
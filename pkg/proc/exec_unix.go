@@ -87,6 +87,13 @@ func startInBackgroundAsRoot(ctx context.Context, args ...string) error {
 	return startInBackground(false, args...)
 }
 
+func isAlive(pid int) bool {
+	// Signal 0 performs the existence and permission checks without sending a signal. A process
+	// that we're not permitted to signal still exists.
+	err := unix.Kill(pid, 0)
+	return err == nil || err == unix.EPERM
+}
+
 func terminate(p *os.Process) error {
 	// SIGTERM makes it through a PTY, SIGINT doesn't. Not sure why that is.
 	// thallgren
@@ -175,6 +175,11 @@ func terminateChildrenOf(ctx context.Context, pid uint32, sig os.Signal, pes []*
 }
 
 // processIsAlive checks if the given pid exists in the current process snapshot.
+func isAlive(pid int) bool {
+	alive, err := processIsAlive(uint32(pid))
+	return err == nil && alive
+}
+
 func processIsAlive(pid uint32) (bool, error) {
 	found := false
 	err := eachProcess(func(pe *windows.ProcessEntry32) bool {
@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"sort"
 	"strconv"
 
 	"github.com/datawire/dlib/dhttp"
 	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/slice"
 )
 
 const (
@@ -17,6 +19,11 @@ const (
 	HeaderInterceptID       = "x-telepresence-intercept-id"
 	EndPointConsumeHere     = "/consume-here"
 	EndPointInterceptInfo   = "/intercept-info"
+
+	// EndPointPropagationHeaders returns the PropagationHeaders of the request. An application calls it with the
+	// headers of an incoming request, and adds the returned headers to the outgoing requests that it makes on behalf
+	// of that request, so that personal intercepts of the services that it calls match those requests too.
+	EndPointPropagationHeaders = "/propagation-headers"
 )
 
 type InterceptInfo struct {
@@ -30,6 +37,21 @@ type InterceptInfo struct {
 	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
+// PropagationHeaders is the response of the EndPointPropagationHeaders.
+type PropagationHeaders struct {
+	// Names are the canonical names of the headers that must be propagated.
+	Names []string `json:"names"`
+
+	// Headers are the headers of the request that must be propagated.
+	Headers map[string]string `json:"headers"`
+}
+
+// HeaderPropagator is implemented by an AgentState that knows the names of the headers that intercepts match.
+type HeaderPropagator interface {
+	// PropagatedHeaderNames returns the names of the headers that the intercepts match.
+	PropagatedHeaderNames(ctx context.Context) []string
+}
+
 type AgentState interface {
 	// InterceptInfo returns information about an ongoing intercept that matches
 	// the given arguments.
@@ -68,6 +90,26 @@ func (s *server) interceptInfo(c context.Context, p string, cp uint16, h http.He
 	return s.agent.InterceptInfo(c, h.Get(HeaderCallerInterceptID), p, cp, h)
 }
 
+func (s *server) propagationHeaders(c context.Context, h http.Header) *PropagationHeaders {
+	names := []string{http.CanonicalHeaderKey(HeaderInterceptID)}
+	if hp, ok := s.agent.(HeaderPropagator); ok {
+		for _, n := range hp.PropagatedHeaderNames(c) {
+			n = http.CanonicalHeaderKey(n)
+			if !slice.Contains(names, n) {
+				names = append(names, n)
+			}
+		}
+	}
+	sort.Strings(names)
+	ph := &PropagationHeaders{Names: names, Headers: make(map[string]string)}
+	for _, n := range names {
+		if v := h.Get(n); v != "" {
+			ph.Headers[n] = v
+		}
+	}
+	return ph
+}
+
 // Serve starts the API server. It terminates when the given context is done.
 func (s *server) Serve(c context.Context, ln net.Listener) error {
 	mux := http.NewServeMux()
@@ -123,6 +165,14 @@ func (s *server) Serve(c context.Context, ln net.Listener) error {
 			dlog.Errorf(c, "error %v when responding with %v", err, ii)
 		}
 	})
+	mux.HandleFunc(EndPointPropagationHeaders, func(w http.ResponseWriter, r *http.Request) {
+		dlog.Debugf(c, "Received %s", EndPointPropagationHeaders)
+		w.Header().Set("Content-Type", "application/json")
+		ph := s.propagationHeaders(c, r.Header)
+		if err := json.NewEncoder(w).Encode(ph); err != nil {
+			dlog.Errorf(c, "error %v when responding with %v", err, ph)
+		}
+	})
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
@@ -52,6 +52,15 @@ func (t textMatcherCluster) InterceptInfo(_ context.Context, _, _ string, _ uint
 	return &restapi.InterceptInfo{Intercepted: textMatcher(t).intercepted(headers), ClientSide: false}, nil
 }
 
+type propagatingMatcher struct {
+	yesNoCluster
+	names []string
+}
+
+func (p propagatingMatcher) PropagatedHeaderNames(context.Context) []string {
+	return p.names
+}
+
 type matcherWithMetadata struct {
 	textMatcherCluster
 	meta map[string]string
@@ -240,6 +249,32 @@ func Test_server_intercepts(t *testing.T) {
 				Metadata:    nil,
 			},
 		},
+		{
+			"propagation headers - no header names",
+			yesNoCluster(false),
+			map[string]string{
+				restapi.HeaderInterceptID: "abc:123",
+				"x-dev-user":              "bob",
+			},
+			restapi.EndPointPropagationHeaders,
+			&restapi.PropagationHeaders{
+				Names:   []string{"X-Telepresence-Intercept-Id"},
+				Headers: map[string]string{"X-Telepresence-Intercept-Id": "abc:123"},
+			},
+		},
+		{
+			"propagation headers - header names",
+			propagatingMatcher{yesNoCluster(false), []string{"x-dev-user", "X-Dev-Team"}},
+			map[string]string{
+				"x-dev-user": "bob",
+				"x-other":    "other",
+			},
+			restapi.EndPointPropagationHeaders,
+			&restapi.PropagationHeaders{
+				Names:   []string{"X-Dev-Team", "X-Dev-User", "X-Telepresence-Intercept-Id"},
+				Headers: map[string]string{"X-Dev-User": "bob"},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -263,11 +298,16 @@ func Test_server_intercepts(t *testing.T) {
 			require.NoError(t, err)
 			defer r.Body.Close()
 			assert.Equal(t, r.StatusCode, http.StatusOK)
-			if _, ok := tt.want.(bool); ok {
+			switch tt.want.(type) {
+			case bool:
 				var rpl bool
 				require.NoError(t, json.NewDecoder(r.Body).Decode(&rpl))
 				assert.Equal(t, tt.want, rpl)
-			} else {
+			case *restapi.PropagationHeaders:
+				var rpl restapi.PropagationHeaders
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&rpl))
+				assert.Equal(t, tt.want, &rpl)
+			default:
 				var rpl restapi.InterceptInfo
 				require.NoError(t, json.NewDecoder(r.Body).Decode(&rpl))
 				assert.Equal(t, tt.want, &rpl)
@@ -0,0 +1,42 @@
+package routing
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/telepresenceio/telepresence/v2/pkg/journal"
+)
+
+// RecordInJournal records this route as a journal.KindRoute entry in the journal of the context.
+func (r *Route) RecordInJournal(ctx context.Context) {
+	data := map[string]string{"gateway": r.Gateway.String()}
+	if r.Interface != nil {
+		data["interface"] = r.Interface.Name
+	}
+	journal.Record(ctx, journal.KindRoute, r.RoutedNet.String(), data)
+}
+
+// ForgetInJournal removes the journal.KindRoute entry of this route from the journal of the context.
+func (r *Route) ForgetInJournal(ctx context.Context) {
+	journal.Forget(ctx, journal.KindRoute, r.RoutedNet.String())
+}
+
+// RemoveJournaled is the journal.Reverter of journal.KindRoute entries.
+func RemoveJournaled(ctx context.Context, e *journal.Entry) error {
+	_, routedNet, err := net.ParseCIDR(e.ID)
+	if err != nil {
+		return fmt.Errorf("invalid subnet: %w", err)
+	}
+	iface, err := net.InterfaceByName(e.Data["interface"])
+	if err != nil {
+		// The route was removed along with its interface.
+		return nil
+	}
+	return removeJournaled(ctx, &Route{RoutedNet: routedNet, Interface: iface, Gateway: net.ParseIP(e.Data["gateway"])})
+}
+
+// RemoveJournaledTable is the journal.Reverter of journal.KindRoutingTable entries.
+func RemoveJournaledTable(ctx context.Context, e *journal.Entry) error {
+	return removeJournaledTable(ctx, e)
+}
@@ -0,0 +1,4 @@
+package routing
+
+// cloningFlag is added to the flags of the static routes. Route cloning isn't used for gateway routes on this platform.
+const cloningFlag = 0
@@ -6,6 +6,7 @@ import (
 	"net"
 	"regexp"
 	"sort"
+	"strconv"
 	"syscall" //nolint:depguard // sys/unix does not have NetlinkRIB
 	"unsafe"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/datawire/dlib/dexec"
 	"github.com/datawire/dlib/dlog"
 	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/journal"
 )
 
 const findInterfaceRegex = `^(local\s|broadcast\s)?[0-9.]+(\s+via\s+(?P<gw>[0-9.]+))?\s+dev\s+(?P<dev>[a-z0-9-]+)\s+(table\s+[a-z0-9]+\s+)?src\s+(?P<src>[0-9.]+)`
@@ -206,6 +208,7 @@ func openTable(ctx context.Context) (Table, error) {
 	if err := netlink.RuleAdd(rule); err != nil {
 		return nil, fmt.Errorf("netlink.RuleAdd: %w", err)
 	}
+	journal.Record(ctx, journal.KindRoutingTable, strconv.Itoa(index), map[string]string{"priority": strconv.Itoa(priority)})
 	return &table{
 		index: index,
 		rule:  rule,
@@ -223,7 +226,11 @@ func (t *table) routeToNetlink(route *Route) *netlink.Route {
 }
 
 func (t *table) Close(ctx context.Context) error {
-	return netlink.RuleDel(t.rule)
+	if err := netlink.RuleDel(t.rule); err != nil {
+		return err
+	}
+	journal.Forget(ctx, journal.KindRoutingTable, strconv.Itoa(t.index))
+	return nil
 }
 
 func (t *table) Add(ctx context.Context, r *Route) error {
@@ -250,6 +257,41 @@ func (r *Route) removeStatic(ctx context.Context) error {
 	return dexec.CommandContext(ctx, "ip", "route", "del", r.RoutedNet.String(), "via", r.Gateway.String(), "dev", r.Interface.Name).Run()
 }
 
+// removeJournaled does nothing, because the route is in the routing table that removeJournaledTable removes.
+func removeJournaled(context.Context, *Route) error {
+	return nil
+}
+
+// removeJournaledTable removes the routes of the routing table with the index of the given entry, and the rules
+// that select the table.
+func removeJournaledTable(_ context.Context, e *journal.Entry) error {
+	index, err := strconv.Atoi(e.ID)
+	if err != nil {
+		return fmt.Errorf("invalid table index: %w", err)
+	}
+	routes, err := netlink.RouteListFiltered(netlink.FAMILY_ALL, &netlink.Route{Table: index}, netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return fmt.Errorf("netlink.RouteListFiltered: %w", err)
+	}
+	for i := range routes {
+		if err = netlink.RouteDel(&routes[i]); err != nil {
+			return fmt.Errorf("netlink.RouteDel: %w", err)
+		}
+	}
+	rules, err := netlink.RuleList(netlink.FAMILY_ALL)
+	if err != nil {
+		return fmt.Errorf("netlink.RuleList: %w", err)
+	}
+	for i := range rules {
+		if rules[i].Table == index {
+			if err = netlink.RuleDel(&rules[i]); err != nil {
+				return fmt.Errorf("netlink.RuleDel: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
 func osCompareRoutes(ctx context.Context, osRoute, tableRoute *Route) (bool, error) {
 	// On Linux, when we ask about an IP address assigned to the machine, the OS will give us a loopback route
 	if osRoute.LocalIP.Equal(osRoute.RoutedNet.IP) && osRoute.Interface.Flags&net.FlagLoopback != 0 {
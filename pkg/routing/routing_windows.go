@@ -12,6 +12,7 @@ import (
 
 	"github.com/datawire/dlib/dlog"
 	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+	"github.com/telepresenceio/telepresence/v2/pkg/journal"
 	"github.com/telepresenceio/telepresence/v2/pkg/proc"
 	"github.com/telepresenceio/telepresence/v2/pkg/subnet"
 )
@@ -163,6 +164,15 @@ func (r *Route) removeStatic(ctx context.Context) error {
 	return nil
 }
 
+func removeJournaled(ctx context.Context, r *Route) error {
+	return r.removeStatic(ctx)
+}
+
+// removeJournaledTable does nothing, because no routing tables are created on this platform.
+func removeJournaledTable(context.Context, *journal.Entry) error {
+	return nil
+}
+
 func openTable(ctx context.Context) (Table, error) {
 	return &table{}, nil
 }
@@ -16,3 +16,19 @@ func GetPool(ctx context.Context) *Pool {
 	}
 	return pool
 }
+
+type heartbeatKey struct{}
+
+// WithHeartbeat returns a context with the given Heartbeat. Dialers that are started with this context send
+// KeepAlive messages on their streams when they are idle.
+func WithHeartbeat(ctx context.Context, hb *Heartbeat) context.Context {
+	return context.WithValue(ctx, heartbeatKey{}, hb)
+}
+
+func GetHeartbeat(ctx context.Context) *Heartbeat {
+	hb, ok := ctx.Value(heartbeatKey{}).(*Heartbeat)
+	if !ok {
+		return nil
+	}
+	return hb
+}
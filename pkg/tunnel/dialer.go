@@ -41,6 +41,7 @@ type streamReader interface {
 	getStream() Stream
 	reply([]byte) (int, error)
 	startDisconnect(context.Context, string)
+	streamBroken(context.Context)
 }
 
 // The dialer takes care of dispatching messages between gRPC and UDP connections.
@@ -52,6 +53,9 @@ type dialer struct {
 	connected int32
 	done      chan struct{}
 
+	// lastActivity is the time, in unix nanoseconds, when a message was last sent or received on the stream.
+	lastActivity atomic.Int64
+
 	ingressBytesProbe *CounterProbe
 	egressBytesProbe  *CounterProbe
 }
@@ -154,6 +158,7 @@ func (h *dialer) Start(ctx context.Context) {
 		// Set up the idle timer to close and release this endpoint when it's been idle for a while.
 		h.TimedHandler.Start(ctx)
 		h.connected = connected
+		h.touch()
 
 		wg := sync.WaitGroup{}
 		wg.Add(2)
@@ -191,7 +196,9 @@ func (h *dialer) connToStreamLoop(ctx context.Context, wg *sync.WaitGroup) {
 	id := h.stream.ID()
 
 	outgoing := make(chan Message, 50)
+	stopHeartbeat := func() {}
 	defer func() {
+		stopHeartbeat()
 		if !h.ResetIdle() {
 			// Hard close of peer. We don't want any more data
 			select {
@@ -206,6 +213,7 @@ func (h *dialer) connToStreamLoop(ctx context.Context, wg *sync.WaitGroup) {
 
 	wg.Add(1)
 	WriteLoop(ctx, h.stream, outgoing, wg, h.egressBytesProbe)
+	stopHeartbeat = h.startHeartbeat(ctx, outgoing)
 
 	buf := make([]byte, 0x100000)
 	dlog.Tracef(ctx, "   CONN %s conn-to-stream loop started", id)
@@ -218,6 +226,7 @@ func (h *dialer) connToStreamLoop(ctx context.Context, wg *sync.WaitGroup) {
 				endReason = ctx.Err().Error()
 				return
 			case outgoing <- NewMessage(Normal, buf[:n]):
+				h.touch()
 			}
 		}
 
@@ -247,9 +256,75 @@ func (h *dialer) getStream() Stream {
 }
 
 func (h *dialer) reply(data []byte) (int, error) {
+	h.touch()
 	return h.conn.Write(data)
 }
 
+func (h *dialer) touch() {
+	h.lastActivity.Store(time.Now().UnixNano())
+}
+
+// idleTime returns the time that has passed since a message was last sent or received on the stream.
+func (h *dialer) idleTime() time.Duration {
+	return time.Since(time.Unix(0, h.lastActivity.Load()))
+}
+
+// startHeartbeat sends a KeepAlive message to the outgoing channel each time the stream has been idle for the
+// interval of the Heartbeat found in the context. The returned function stops the heartbeats. It must be called
+// before the outgoing channel is closed.
+func (h *dialer) startHeartbeat(ctx context.Context, outgoing chan<- Message) func() {
+	hb := GetHeartbeat(ctx)
+	if hb == nil {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		timer := time.NewTimer(hb.Interval())
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-done:
+				return
+			case <-timer.C:
+			}
+			iv := hb.Interval()
+			if idle := h.idleTime(); idle < iv {
+				timer.Reset(iv - idle)
+				continue
+			}
+			select {
+			case outgoing <- NewMessage(KeepAlive, nil):
+				h.touch()
+				dlog.Tracef(ctx, "-> CONN %s, heartbeat", h.stream.ID())
+			default:
+				// The outgoing channel is full, so the stream isn't idle.
+			}
+			timer.Reset(iv)
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// streamBroken is called when the stream fails. A stream that fails after having been idle for a while was
+// probably dropped by a NAT or a proxy, so the Heartbeat is told to shorten its interval.
+func (h *dialer) streamBroken(ctx context.Context) {
+	hb := GetHeartbeat(ctx)
+	if hb == nil {
+		return
+	}
+	if idle := h.idleTime(); idle >= MinHeartbeatInterval {
+		dlog.Debugf(ctx, "   CONN %s, stream broke after being idle for %s", h.stream.ID(), idle)
+		hb.Broken(idle)
+	}
+}
+
 func (h *dialer) streamToConnLoop(ctx context.Context, wg *sync.WaitGroup) {
 	defer func() {
 		wg.Done()
@@ -298,6 +373,7 @@ func readLoop(ctx context.Context, h streamReader, trafficProbe *CounterProbe) {
 		case err, ok := <-errCh:
 			if ok {
 				dlog.Error(ctx, err)
+				h.streamBroken(ctx)
 			}
 		case dg, ok := <-incoming:
 			if !ok {
@@ -337,6 +413,19 @@ func DialWaitLoop(
 	manager rpc.ManagerClient,
 	dialStream rpc.Manager_WatchDialClient,
 	sessionID string,
+) error {
+	return LimitedDialWaitLoop(ctx, manager, dialStream, sessionID, nil)
+}
+
+// LimitedDialWaitLoop is like DialWaitLoop, but calls the given acquire function before a request is served. The
+// request is rejected when acquire returns false. Otherwise, the returned release function is called when the
+// connection ends. A nil acquire function means that no request is rejected.
+func LimitedDialWaitLoop(
+	ctx context.Context,
+	manager rpc.ManagerClient,
+	dialStream rpc.Manager_WatchDialClient,
+	sessionID string,
+	acquire func() (release func(), ok bool),
 ) error {
 	// create ctx to cleanup leftover dialRespond if waitloop dies
 	ctx, cancel := context.WithCancel(ctx)
@@ -349,11 +438,47 @@ func DialWaitLoop(
 			}
 			return nil
 		}
-		go dialRespond(ctx, manager, dr, sessionID)
+		if acquire == nil {
+			go dialRespond(ctx, manager, dr, sessionID)
+			continue
+		}
+		release, ok := acquire()
+		if !ok {
+			go dialReject(ctx, manager, dr, sessionID)
+			continue
+		}
+		go func() {
+			defer release()
+			dialRespond(ctx, manager, dr, sessionID)
+		}()
 	}
 	return nil
 }
 
+// dialReject creates a Tunnel to the manager and answers the given request with a DialReject.
+func dialReject(ctx context.Context, manager rpc.ManagerClient, dr *rpc.DialRequest, sessionID string) {
+	id := ConnID(dr.ConnId)
+	dlog.Debugf(ctx, "   CONN %s, rejecting dial request", id)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	mt, err := manager.Tunnel(ctx)
+	if err != nil {
+		dlog.Errorf(ctx, "!! CONN %s, call to manager Tunnel failed: %v", id, err)
+		return
+	}
+	s, err := NewClientStream(ctx, mt, id, sessionID, time.Duration(dr.RoundtripLatency), time.Duration(dr.DialTimeout))
+	if err != nil {
+		dlog.Error(ctx, err)
+		return
+	}
+	if err = s.Send(ctx, NewMessage(DialReject, nil)); err != nil {
+		dlog.Errorf(ctx, "!! CONN %s, failed to send DialReject: %v", id, err)
+	}
+	if err = s.CloseSend(ctx); err != nil {
+		dlog.Errorf(ctx, "!! CONN %s, stream.CloseSend failed: %v", id, err)
+	}
+}
+
 func dialRespond(ctx context.Context, manager rpc.ManagerClient, dr *rpc.DialRequest, sessionID string) {
 	if tc := dr.GetTraceContext(); tc != nil {
 		carrier := propagation.MapCarrier(tc)
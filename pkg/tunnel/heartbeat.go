@@ -0,0 +1,71 @@
+package tunnel
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// MinHeartbeatInterval is the shortest interval that a Heartbeat adapts to.
+	MinHeartbeatInterval = 5 * time.Second
+
+	// heartbeatRelaxPeriod is how long the interval of a Heartbeat must remain unchanged before it's relaxed.
+	heartbeatRelaxPeriod = 10 * time.Minute
+)
+
+// Heartbeat decides how long a stream can be idle before a KeepAlive message is sent on it. The interval
+// adapts to the network. It's shortened each time a stream that has been idle breaks, which is what happens
+// when a NAT or a proxy drops idle connections, and it's relaxed towards the maximum interval when no streams
+// have broken for a while.
+type Heartbeat struct {
+	sync.Mutex
+	min      time.Duration
+	max      time.Duration
+	interval time.Duration
+	changed  time.Time
+	now      func() time.Time
+}
+
+// NewHeartbeat returns a Heartbeat with an interval that adapts between the MinHeartbeatInterval and the given
+// maximum interval.
+func NewHeartbeat(maxInterval time.Duration) *Heartbeat {
+	return newHeartbeat(maxInterval, time.Now)
+}
+
+func newHeartbeat(maxInterval time.Duration, now func() time.Time) *Heartbeat {
+	if maxInterval < MinHeartbeatInterval {
+		maxInterval = MinHeartbeatInterval
+	}
+	return &Heartbeat{min: MinHeartbeatInterval, max: maxInterval, interval: maxInterval, changed: now(), now: now}
+}
+
+// Interval returns the current interval.
+func (hb *Heartbeat) Interval() time.Duration {
+	hb.Lock()
+	defer hb.Unlock()
+	if hb.interval < hb.max {
+		if now := hb.now(); now.Sub(hb.changed) >= heartbeatRelaxPeriod {
+			hb.interval += hb.interval / 4
+			if hb.interval > hb.max {
+				hb.interval = hb.max
+			}
+			hb.changed = now
+		}
+	}
+	return hb.interval
+}
+
+// Broken tells the Heartbeat that a stream broke after being idle for the given duration. The interval is
+// shortened so that future streams send a KeepAlive well before they have been idle for that long.
+func (hb *Heartbeat) Broken(idle time.Duration) {
+	hb.Lock()
+	defer hb.Unlock()
+	iv := idle / 2
+	if iv < hb.min {
+		iv = hb.min
+	}
+	if iv < hb.interval {
+		hb.interval = iv
+		hb.changed = hb.now()
+	}
+}
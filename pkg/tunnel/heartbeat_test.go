@@ -0,0 +1,33 @@
+package tunnel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeartbeat(t *testing.T) {
+	now := time.Date(2023, time.October, 1, 12, 0, 0, 0, time.UTC)
+	hb := newHeartbeat(time.Minute, func() time.Time { return now })
+	assert.Equal(t, time.Minute, hb.Interval())
+
+	// A stream that breaks while it's idle for less than the interval shortens it.
+	hb.Broken(50 * time.Second)
+	assert.Equal(t, 25*time.Second, hb.Interval())
+
+	// The interval never goes below the minimum, and never grows because of a broken stream.
+	hb.Broken(2 * time.Second)
+	assert.Equal(t, MinHeartbeatInterval, hb.Interval())
+	hb.Broken(time.Hour)
+	assert.Equal(t, MinHeartbeatInterval, hb.Interval())
+
+	// The interval relaxes towards the maximum when no stream has broken for a while.
+	now = now.Add(heartbeatRelaxPeriod)
+	assert.Equal(t, MinHeartbeatInterval+MinHeartbeatInterval/4, hb.Interval())
+	for i := 0; i < 20; i++ {
+		now = now.Add(heartbeatRelaxPeriod)
+		hb.Interval()
+	}
+	assert.Equal(t, time.Minute, hb.Interval())
+}
@@ -57,6 +57,10 @@ const (
 
 	KeepAlive
 	Session
+
+	// muxInfo is the first message on a tunnel that multiplexes many streams. The peer answers with a muxInfo
+	// when it supports multiplexing. A peer that doesn't will fail the tunnel, because it expects a streamInfo.
+	muxInfo
 )
 
 func (c MessageCode) String() string {
@@ -77,6 +81,8 @@ func (c MessageCode) String() string {
 		return "KEEP_ALIVE"
 	case Session:
 		return "SESSION"
+	case muxInfo:
+		return "MUX_INFO"
 	default:
 		return fmt.Sprintf("** unknown control code: %d **", c)
 	}
@@ -0,0 +1,562 @@
+package tunnel
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/datawire/dlib/dlog"
+	rpc "github.com/telepresenceio/telepresence/rpc/v2/manager"
+)
+
+// Priority is the priority of a flow in a Mux. Frames of a flow are never sent while frames of a flow with a
+// higher priority are waiting to be sent.
+type Priority byte
+
+const (
+	// PriorityDNS is the priority of DNS lookups.
+	PriorityDNS = Priority(iota)
+
+	// PriorityInteractive is the priority of new flows. A flow is demoted to PriorityBulk once it has sent
+	// muxBulkThreshold bytes, so that an interactive session, e.g. SSH, isn't slowed down by large transfers.
+	PriorityInteractive
+
+	// PriorityBulk is the priority of flows that transfer large amounts of data.
+	PriorityBulk
+
+	numPriorities
+)
+
+const (
+	// muxVersion is the version of the multiplexing protocol.
+	muxVersion = 1
+
+	// muxFrameSize is the maximum size of the data in a frame. Larger messages are fragmented, so that the frames
+	// of other flows can be interleaved.
+	muxFrameSize = 16 * 1024
+
+	// muxWindowSize is the number of bytes that a flow can send before the peer has consumed them.
+	muxWindowSize = 256 * 1024
+
+	// muxBulkThreshold is the number of bytes that an interactive flow can send before it's demoted to bulk.
+	muxBulkThreshold = 1024 * 1024
+)
+
+type frameType byte
+
+const (
+	// frameOpen opens a flow. The data is the priority of the flow.
+	frameOpen = frameType(iota)
+
+	// frameData carries the last fragment of a message.
+	frameData
+
+	// frameDataMore carries a fragment of a message that is followed by more fragments.
+	frameDataMore
+
+	// frameWindow grants the peer the right to send more bytes. The data is the number of bytes.
+	frameWindow
+
+	// frameClose is sent when the sender of a flow is done sending.
+	frameClose
+
+	// frameReset is sent when a flow is aborted. The data is the reason.
+	frameReset
+)
+
+// ErrMuxUnsupported is returned by NewClientMux when the peer doesn't support multiplexing.
+var ErrMuxUnsupported = errors.New("peer doesn't support multiplexed tunnels")
+
+var (
+	// errWindowExceeded resets a flow whose peer sends more bytes than it has been granted.
+	errWindowExceeded = errors.New("flow control window exceeded")
+
+	// errDuplicateOpen resets a flow whose ID the peer opens again.
+	errDuplicateOpen = errors.New("flow opened twice")
+)
+
+// A Mux multiplexes many flows over one gRPC tunnel. Each flow behaves like a gRPC tunnel of its own, so a
+// Stream can be created on top of it. A flow can only send a limited number of bytes before the peer has
+// consumed them, so a slow flow never blocks the other flows, and flows with a higher priority are served first.
+type Mux struct {
+	stream   GRPCStream
+	client   bool
+	mu       sync.Mutex
+	sendCond *sync.Cond // signaled when a frame is queued or the Mux is closed
+	flowCond *sync.Cond // broadcast when the state of a flow changes
+	queues   [numPriorities][]*rpc.TunnelMessage
+	flows    map[uint64]*muxFlow
+	nextID   uint64 // the ID of the last flow that was opened, by this Mux when it's a client, and by the peer otherwise
+	accepted chan *muxFlow
+	done     chan struct{}
+	err      error
+}
+
+// NewClientMux sends a muxInfo on the given tunnel and waits for the peer to answer with a muxInfo. The tunnel
+// is then used by the returned Mux until the context is cancelled. ErrMuxUnsupported is returned when the peer
+// refuses the muxInfo. Other errors, e.g. a broken connection, are returned as is, because they say nothing
+// about what the peer supports.
+func NewClientMux(ctx context.Context, stream GRPClientCStream) (*Mux, error) {
+	if err := stream.Send(muxInfoMessage().TunnelMessage()); err != nil {
+		return nil, err
+	}
+	tm, err := stream.Recv()
+	if err != nil {
+		_ = stream.CloseSend()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if muxRefused(err) {
+			return nil, ErrMuxUnsupported
+		}
+		return nil, err
+	}
+	if msg(tm.Payload).Code() != muxInfo {
+		_ = stream.CloseSend()
+		return nil, ErrMuxUnsupported
+	}
+	m := newMux(stream, true)
+	go func() {
+		select {
+		case <-ctx.Done():
+			m.close(ctx.Err())
+		case <-m.done:
+		}
+		_ = stream.CloseSend()
+	}()
+	m.start(ctx)
+	return m, nil
+}
+
+// AcceptStreams serves the given tunnel. The handler is called with the tunnel unless its first message is a
+// muxInfo, in which case the handler is called once for each flow that the peer opens. A flow is reset with the
+// error that the handler returns. AcceptStreams returns when the tunnel ends.
+func AcceptStreams(ctx context.Context, stream GRPCStream, handler func(context.Context, GRPCStream) error) error {
+	tm, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if msg(tm.Payload).Code() != muxInfo {
+		return handler(ctx, &peekedStream{GRPCStream: stream, first: tm})
+	}
+	if err = stream.Send(muxInfoMessage().TunnelMessage()); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	m := newMux(stream, false)
+	m.start(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			m.close(ctx.Err())
+			return nil
+		case <-m.done:
+			if errors.Is(m.err, io.EOF) {
+				return nil
+			}
+			return m.err
+		case f := <-m.accepted:
+			go func() {
+				err := handler(f.ctx, f)
+				if err != nil {
+					dlog.Debugf(ctx, "   MUX flow %d ended: %v", f.id, err)
+				}
+				f.finish(err)
+			}()
+		}
+	}
+}
+
+// muxRefused returns true if the given error is how a peer refuses a muxInfo. A peer that doesn't know the
+// Tunnel call answers with Unimplemented, and a peer that doesn't know multiplexing fails the tunnel with
+// FailedPrecondition, because it only accepts a streamInfo as the first message.
+func muxRefused(err error) bool {
+	switch status.Code(err) {
+	case codes.Unimplemented, codes.FailedPrecondition:
+		return true
+	default:
+		return false
+	}
+}
+
+func muxInfoMessage() Message {
+	m := makeMessage(muxInfo, 4)
+	n := binary.PutUvarint(m.Payload(), muxVersion)
+	return m[:n+1]
+}
+
+func newMux(stream GRPCStream, client bool) *Mux {
+	m := &Mux{
+		stream:   stream,
+		client:   client,
+		flows:    make(map[uint64]*muxFlow),
+		accepted: make(chan *muxFlow, 16),
+		done:     make(chan struct{}),
+	}
+	m.sendCond = sync.NewCond(&m.mu)
+	m.flowCond = sync.NewCond(&m.mu)
+	return m
+}
+
+func (m *Mux) start(ctx context.Context) {
+	go m.readLoop(ctx)
+	go m.writeLoop(ctx)
+}
+
+// Done returns a channel that is closed when the Mux is closed.
+func (m *Mux) Done() <-chan struct{} {
+	return m.done
+}
+
+// Open opens a new flow with the given priority. The returned stream is used in the same way as a tunnel that is
+// returned by the manager's Tunnel call. The flow is reset when the given context is cancelled.
+func (m *Mux) Open(ctx context.Context, priority Priority) (GRPClientCStream, error) {
+	m.mu.Lock()
+	if m.err != nil {
+		err := m.err
+		m.mu.Unlock()
+		return nil, err
+	}
+	m.nextID++
+	f := m.newFlow(ctx, m.nextID, priority)
+	m.enqueue(PriorityDNS, newFrame(frameOpen, f.id, []byte{byte(priority)}))
+	m.mu.Unlock()
+	go func() {
+		// The flow's context is done when the flow is removed, or when the given context is cancelled. The
+		// finish is a no-op in the former case.
+		<-f.ctx.Done()
+		f.finish(ctx.Err())
+	}()
+	return f, nil
+}
+
+// FlowPriority returns the priority that a flow for the given connection starts with.
+func FlowPriority(id ConnID) Priority {
+	if id.DestinationPort() == 53 {
+		return PriorityDNS
+	}
+	return PriorityInteractive
+}
+
+// close closes the Mux and all of its flows. Must not be called with the lock held.
+func (m *Mux) close(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return
+	}
+	if err == nil {
+		err = io.EOF
+	}
+	m.err = err
+	for _, f := range m.flows {
+		f.remove(err)
+	}
+	m.sendCond.Broadcast()
+	m.flowCond.Broadcast()
+	close(m.done)
+}
+
+// enqueue queues a frame for sending. Must be called with the lock held.
+func (m *Mux) enqueue(p Priority, frame *rpc.TunnelMessage) {
+	m.queues[p] = append(m.queues[p], frame)
+	m.sendCond.Signal()
+}
+
+// dequeue returns the next frame to send, or nil when the Mux is closed.
+func (m *Mux) dequeue() *rpc.TunnelMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for m.err == nil {
+		for p := range m.queues {
+			if q := m.queues[p]; len(q) > 0 {
+				m.queues[p] = q[1:]
+				return q[0]
+			}
+		}
+		m.sendCond.Wait()
+	}
+	return nil
+}
+
+func (m *Mux) writeLoop(ctx context.Context) {
+	for {
+		frame := m.dequeue()
+		if frame == nil {
+			return
+		}
+		if err := m.stream.Send(frame); err != nil {
+			if ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				dlog.Errorf(ctx, "!! MUX send failed: %v", err)
+			}
+			m.close(err)
+			return
+		}
+	}
+}
+
+func (m *Mux) readLoop(ctx context.Context) {
+	for {
+		tm, err := m.stream.Recv()
+		if err != nil {
+			m.close(err)
+			return
+		}
+		ft, id, data, err := parseFrame(tm.Payload)
+		if err != nil {
+			dlog.Errorf(ctx, "!! MUX %v", err)
+			m.close(err)
+			return
+		}
+		if ft == frameOpen {
+			if m.client || len(data) != 1 || Priority(data[0]) >= numPriorities {
+				dlog.Errorf(ctx, "!! MUX invalid open of flow %d", id)
+				continue
+			}
+			m.mu.Lock()
+			if id <= m.nextID {
+				// A client never reuses an ID, so the flow is either open or has already ended.
+				dlog.Errorf(ctx, "!! MUX duplicate open of flow %d", id)
+				if f, ok := m.flows[id]; ok {
+					f.reset(errDuplicateOpen)
+				} else {
+					m.enqueue(PriorityDNS, newFrame(frameReset, id, []byte(errDuplicateOpen.Error())))
+				}
+				m.mu.Unlock()
+				continue
+			}
+			m.nextID = id
+			f := m.newFlow(ctx, id, Priority(data[0]))
+			m.mu.Unlock()
+			select {
+			case <-m.done:
+				return
+			case m.accepted <- f:
+			}
+			continue
+		}
+		m.mu.Lock()
+		if f, ok := m.flows[id]; ok {
+			f.onFrame(ft, data)
+		}
+		m.mu.Unlock()
+	}
+}
+
+func newFrame(ft frameType, id uint64, data []byte) *rpc.TunnelMessage {
+	b := make([]byte, 1+binary.MaxVarintLen64+len(data))
+	b[0] = byte(ft)
+	n := 1 + binary.PutUvarint(b[1:], id)
+	n += copy(b[n:], data)
+	return &rpc.TunnelMessage{Payload: b[:n]}
+}
+
+func parseFrame(b []byte) (frameType, uint64, []byte, error) {
+	if len(b) < 2 {
+		return 0, 0, nil, errors.New("short frame")
+	}
+	ft := frameType(b[0])
+	if ft > frameReset {
+		return 0, 0, nil, fmt.Errorf("invalid frame type %d", ft)
+	}
+	id, n := binary.Uvarint(b[1:])
+	if n <= 0 {
+		return 0, 0, nil, errors.New("invalid flow id")
+	}
+	return ft, id, b[1+n:], nil
+}
+
+// muxFlow is one flow of a Mux. All fields, except the immutable ones, are guarded by the lock of the Mux.
+type muxFlow struct {
+	mux    *Mux
+	id     uint64
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	priority   Priority
+	sent       int
+	window     int // bytes that can be sent before the peer grants more
+	recvWindow int // bytes that the peer can send before it's granted more
+	consumed   int // bytes that have been received but not yet granted back to the peer
+	fragment   []byte
+	incoming   []*rpc.TunnelMessage
+	recvErr    error // returned by Recv when incoming is empty
+	sendClosed bool
+	peerClosed bool
+	removed    bool
+}
+
+// newFlow creates a flow and adds it to the Mux. Must be called with the lock held.
+func (m *Mux) newFlow(ctx context.Context, id uint64, priority Priority) *muxFlow {
+	f := &muxFlow{mux: m, id: id, priority: priority, window: muxWindowSize, recvWindow: muxWindowSize}
+	f.ctx, f.cancel = context.WithCancel(ctx)
+	m.flows[id] = f
+	return f
+}
+
+// onFrame handles a frame that the peer sent on this flow. Must be called with the lock held.
+func (f *muxFlow) onFrame(ft frameType, data []byte) {
+	switch ft {
+	case frameData, frameDataMore:
+		if len(data) > f.recvWindow {
+			// The peer ignores flow control. Its data would otherwise be buffered without limit.
+			f.reset(errWindowExceeded)
+			return
+		}
+		f.recvWindow -= len(data)
+		f.fragment = append(f.fragment, data...)
+		if ft == frameData {
+			f.incoming = append(f.incoming, &rpc.TunnelMessage{Payload: f.fragment})
+			f.fragment = nil
+		}
+	case frameWindow:
+		if v, n := binary.Uvarint(data); n > 0 {
+			f.window += int(v)
+		}
+	case frameClose:
+		// A flow ends when the server closes it, just like a gRPC tunnel ends when its server returns.
+		f.peerClosed = true
+		f.recvErr = io.EOF
+		if f.sendClosed || f.mux.client {
+			f.remove(io.EOF)
+		}
+	case frameReset:
+		f.remove(errors.New(string(data)))
+	}
+	f.mux.flowCond.Broadcast()
+}
+
+// remove removes the flow from the Mux. Must be called with the lock held.
+func (f *muxFlow) remove(err error) {
+	if f.removed {
+		return
+	}
+	f.removed = true
+	if f.recvErr == nil {
+		f.recvErr = err
+	}
+	delete(f.mux.flows, f.id)
+	f.cancel()
+	f.mux.flowCond.Broadcast()
+}
+
+// reset tells the peer that the flow is aborted, and removes it. Must be called with the lock held.
+func (f *muxFlow) reset(err error) {
+	if f.removed {
+		return
+	}
+	f.mux.enqueue(f.priority, newFrame(frameReset, f.id, []byte(err.Error())))
+	f.sendClosed = true
+	f.remove(err)
+}
+
+// finish closes the flow when err is nil, and resets it otherwise.
+func (f *muxFlow) finish(err error) {
+	m := f.mux
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f.removed {
+		return
+	}
+	if err != nil {
+		f.reset(err)
+		return
+	}
+	if !f.sendClosed {
+		m.enqueue(f.priority, newFrame(frameClose, f.id, nil))
+	}
+	f.sendClosed = true
+	f.remove(net.ErrClosed)
+}
+
+func (f *muxFlow) Send(tm *rpc.TunnelMessage) error {
+	m := f.mux
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data := tm.Payload
+	for first := true; first || len(data) > 0; first = false {
+		n := len(data)
+		if n > muxFrameSize {
+			n = muxFrameSize
+		}
+		for f.window < n && !f.removed {
+			m.flowCond.Wait()
+		}
+		if f.removed || f.sendClosed {
+			return net.ErrClosed
+		}
+		f.window -= n
+		f.sent += n
+		if f.priority == PriorityInteractive && f.sent > muxBulkThreshold {
+			f.priority = PriorityBulk
+		}
+		ft := frameData
+		if n < len(data) {
+			ft = frameDataMore
+		}
+		m.enqueue(f.priority, newFrame(ft, f.id, data[:n]))
+		data = data[n:]
+	}
+	return nil
+}
+
+func (f *muxFlow) Recv() (*rpc.TunnelMessage, error) {
+	m := f.mux
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for len(f.incoming) == 0 {
+		if f.recvErr != nil {
+			return nil, f.recvErr
+		}
+		m.flowCond.Wait()
+	}
+	tm := f.incoming[0]
+	f.incoming = f.incoming[1:]
+	if !f.removed {
+		f.consumed += len(tm.Payload)
+		if f.consumed >= muxWindowSize/2 {
+			b := make([]byte, binary.MaxVarintLen64)
+			m.enqueue(PriorityDNS, newFrame(frameWindow, f.id, b[:binary.PutUvarint(b, uint64(f.consumed))]))
+			f.recvWindow += f.consumed
+			f.consumed = 0
+		}
+	}
+	return tm, nil
+}
+
+func (f *muxFlow) CloseSend() error {
+	m := f.mux
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if f.sendClosed || f.removed {
+		return nil
+	}
+	f.sendClosed = true
+	m.enqueue(f.priority, newFrame(frameClose, f.id, nil))
+	if f.peerClosed {
+		f.remove(io.EOF)
+	}
+	return nil
+}
+
+// peekedStream is a GRPCStream whose first message has already been received.
+type peekedStream struct {
+	GRPCStream
+	first *rpc.TunnelMessage
+}
+
+func (p *peekedStream) Recv() (*rpc.TunnelMessage, error) {
+	if tm := p.first; tm != nil {
+		p.first = nil
+		return tm, nil
+	}
+	return p.GRPCStream.Recv()
+}
@@ -0,0 +1,255 @@
+package tunnel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/telepresenceio/telepresence/rpc/v2/manager"
+	"github.com/telepresenceio/telepresence/v2/pkg/ipproto"
+	"github.com/telepresenceio/telepresence/v2/pkg/iputil"
+)
+
+// echo is a handler for AcceptStreams that sends back all messages that it receives.
+func echo(ctx context.Context, gs GRPCStream) error {
+	s, err := NewServerStream(ctx, gs)
+	if err != nil {
+		return err
+	}
+	for {
+		m, err := s.Receive(ctx)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err = s.Send(ctx, m); err != nil {
+			return err
+		}
+	}
+}
+
+func TestMux_Streams(t *testing.T) {
+	ctx, cancel := testContext(t, 10*time.Second)
+	defer cancel()
+
+	tunnel := newBidi(10, ctx.Done())
+	go func() {
+		assert.NoError(t, AcceptStreams(ctx, tunnel.serverSide(), echo))
+	}()
+	mux, err := NewClientMux(ctx, tunnel.clientSide())
+	require.NoError(t, err)
+
+	// A payload that is fragmented into several frames.
+	large := make([]byte, 3*muxFrameSize+100)
+	for i := range large {
+		large[i] = byte(i)
+	}
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := NewConnID(ipproto.TCP, iputil.Parse("127.0.0.1"), iputil.Parse("192.168.0.1"), uint16(1001+i), 8080)
+			flow, err := mux.Open(ctx, FlowPriority(id))
+			require.NoError(t, err)
+			s, err := NewClientStream(ctx, flow, id, "session", 0, 0)
+			require.NoError(t, err)
+			for _, pl := range [][]byte{[]byte("hello"), large} {
+				require.NoError(t, s.Send(ctx, NewMessage(Normal, pl)))
+				m, err := s.Receive(ctx)
+				require.NoError(t, err)
+				assert.True(t, bytes.Equal(pl, m.Payload()))
+			}
+			require.NoError(t, s.CloseSend(ctx))
+			_, err = s.Receive(ctx)
+			assert.ErrorIs(t, err, io.EOF)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// answeringStream is a tunnel that answers the first message with the given message or error.
+type answeringStream struct {
+	reply *manager.TunnelMessage
+	err   error
+}
+
+func (s *answeringStream) Recv() (*manager.TunnelMessage, error) { return s.reply, s.err }
+func (s *answeringStream) Send(*manager.TunnelMessage) error     { return nil }
+func (s *answeringStream) CloseSend() error                      { return nil }
+
+func TestMux_Unsupported(t *testing.T) {
+	ctx, cancel := testContext(t, time.Second)
+	defer cancel()
+
+	// A peer that doesn't support multiplexing fails the tunnel when the first message isn't a streamInfo, the
+	// way the traffic-manager's Tunnel call does.
+	s := &answeringStream{err: status.Error(codes.FailedPrecondition, "failed to connect stream: initial message was not StreamInfo")}
+	_, err := NewClientMux(ctx, s)
+	assert.ErrorIs(t, err, ErrMuxUnsupported)
+
+	_, err = NewClientMux(ctx, &answeringStream{err: status.Error(codes.Unimplemented, "unknown method Tunnel")})
+	assert.ErrorIs(t, err, ErrMuxUnsupported)
+
+	_, err = NewClientMux(ctx, &answeringStream{reply: StreamOKMessage().TunnelMessage()})
+	assert.ErrorIs(t, err, ErrMuxUnsupported)
+
+	// Errors that say nothing about the peer don't make multiplexing unsupported.
+	for _, err := range []error{io.EOF, status.Error(codes.Unavailable, "connection reset"), status.Error(codes.Internal, "oops")} {
+		_, muxErr := NewClientMux(ctx, &answeringStream{err: err})
+		assert.ErrorIs(t, muxErr, err)
+		assert.NotErrorIs(t, muxErr, ErrMuxUnsupported)
+	}
+}
+
+func TestMux_Priority(t *testing.T) {
+	ctx, cancel := testContext(t, time.Second)
+	defer cancel()
+
+	m := newMux(nil, true)
+	m.mu.Lock()
+	bulk := m.newFlow(ctx, 1, PriorityInteractive)
+	bulk.window = 2 * muxBulkThreshold
+	dns := m.newFlow(ctx, 2, PriorityDNS)
+	m.mu.Unlock()
+
+	// The flow is demoted to bulk once it has sent more than the threshold.
+	require.NoError(t, bulk.Send(&manager.TunnelMessage{Payload: make([]byte, muxBulkThreshold+1)}))
+	assert.Equal(t, PriorityBulk, bulk.priority)
+	require.NoError(t, dns.Send(&manager.TunnelMessage{Payload: []byte("lookup")}))
+
+	// The frame of the DNS flow is sent before the frames of the other flow, although it was queued last.
+	_, id, data, err := parseFrame(m.dequeue().Payload)
+	require.NoError(t, err)
+	assert.Equal(t, dns.id, id)
+	assert.Equal(t, []byte("lookup"), data)
+	m.mu.Lock()
+	assert.Len(t, m.queues[PriorityInteractive], muxBulkThreshold/muxFrameSize)
+	assert.Len(t, m.queues[PriorityBulk], 1)
+	m.mu.Unlock()
+}
+
+func TestMux_FlowControl(t *testing.T) {
+	ctx, cancel := testContext(t, time.Second)
+	defer cancel()
+
+	m := newMux(nil, true)
+	m.mu.Lock()
+	f := m.newFlow(ctx, 1, PriorityInteractive)
+	f.window = 10
+	m.mu.Unlock()
+
+	sent := make(chan error, 1)
+	go func() {
+		sent <- f.Send(&manager.TunnelMessage{Payload: make([]byte, 20)})
+	}()
+	select {
+	case <-sent:
+		t.Fatal("send must block until the peer grants more bytes")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	m.mu.Lock()
+	f.onFrame(frameWindow, []byte{10})
+	m.mu.Unlock()
+	select {
+	case err := <-sent:
+		assert.NoError(t, err)
+	case <-ctx.Done():
+		t.Fatal("send wasn't unblocked by the window update")
+	}
+	assert.Equal(t, 0, f.window)
+}
+
+func TestMux_WindowExceeded(t *testing.T) {
+	ctx, cancel := testContext(t, time.Second)
+	defer cancel()
+
+	m := newMux(nil, false)
+	m.mu.Lock()
+	f := m.newFlow(ctx, 1, PriorityInteractive)
+
+	// The peer may send what it has been granted, and more once the bytes have been consumed.
+	f.onFrame(frameDataMore, make([]byte, muxWindowSize-10))
+	f.onFrame(frameData, make([]byte, 10))
+	m.mu.Unlock()
+	_, err := f.Recv()
+	require.NoError(t, err)
+	m.mu.Lock()
+	f.onFrame(frameData, make([]byte, muxWindowSize))
+	assert.False(t, f.removed)
+
+	// A peer that ignores the window has its flow reset instead of having its data buffered.
+	f.onFrame(frameDataMore, []byte{0})
+	assert.True(t, f.removed)
+	assert.Empty(t, f.fragment)
+	m.mu.Unlock()
+	m.dequeue() // the window update
+	ft, id, data, err := parseFrame(m.dequeue().Payload)
+	require.NoError(t, err)
+	assert.Equal(t, frameReset, ft)
+	assert.Equal(t, uint64(1), id)
+	assert.Equal(t, errWindowExceeded.Error(), string(data))
+}
+
+func TestMux_DuplicateOpen(t *testing.T) {
+	ctx, cancel := testContext(t, 5*time.Second)
+	defer cancel()
+
+	tunnel := newBidi(10, ctx.Done())
+	opened := make(chan context.Context, 1)
+	go func() {
+		_ = AcceptStreams(ctx, tunnel.serverSide(), func(ctx context.Context, _ GRPCStream) error {
+			opened <- ctx
+			<-ctx.Done()
+			return nil
+		})
+	}()
+	cs := tunnel.clientSide()
+	require.NoError(t, cs.Send(muxInfoMessage().TunnelMessage()))
+	_, err := cs.Recv()
+	require.NoError(t, err)
+
+	open := newFrame(frameOpen, 1, []byte{byte(PriorityInteractive)})
+	require.NoError(t, cs.Send(open))
+	var flowCtx context.Context
+	select {
+	case flowCtx = <-opened:
+	case <-ctx.Done():
+		t.Fatal("flow wasn't accepted")
+	}
+
+	// Opening the ID again resets the flow, both when it's open and when it has ended.
+	for i := 0; i < 2; i++ {
+		require.NoError(t, cs.Send(open))
+		tm, err := cs.Recv()
+		require.NoError(t, err)
+		ft, id, data, err := parseFrame(tm.Payload)
+		require.NoError(t, err)
+		assert.Equal(t, frameReset, ft)
+		assert.Equal(t, uint64(1), id)
+		assert.Equal(t, errDuplicateOpen.Error(), string(data))
+		select {
+		case <-flowCtx.Done():
+		case <-ctx.Done():
+			t.Fatal("the open flow wasn't reset")
+		}
+	}
+	select {
+	case <-opened:
+		t.Fatal("a flow with a duplicate ID was accepted")
+	default:
+	}
+}
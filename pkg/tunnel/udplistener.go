@@ -113,6 +113,9 @@ func (p *udpStream) reply(data []byte) (int, error) {
 func (p *udpStream) startDisconnect(ctx context.Context, s string) {
 }
 
+func (p *udpStream) streamBroken(ctx context.Context) {
+}
+
 func (p *udpStream) Stop(ctx context.Context) {
 	_ = p.stream.CloseSend(ctx)
 }
@@ -4,7 +4,9 @@ import (
 	"context"
 	"io"
 	"net"
+	"os"
 	"sync"
+	"sync/atomic"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
@@ -16,6 +18,8 @@ import (
 	"gvisor.dev/gvisor/pkg/tcpip/stack"
 
 	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/client"
+	"github.com/telepresenceio/telepresence/v2/pkg/journal"
 	"github.com/telepresenceio/telepresence/v2/pkg/routing"
 	"github.com/telepresenceio/telepresence/v2/pkg/tracing"
 	"github.com/telepresenceio/telepresence/v2/pkg/vif/buffer"
@@ -27,6 +31,11 @@ type device struct {
 	wg    sync.WaitGroup
 	dev   *nativeDevice
 	table routing.Table
+	taps  taps
+	mtu   int
+
+	// fragments is updated atomically.
+	fragments uint64
 }
 
 type Device interface {
@@ -39,8 +48,6 @@ type Device interface {
 	SetDNS(context.Context, string, net.IP, []string) (err error)
 }
 
-const defaultDevMtu = 1500
-
 // Queue length for outbound packet, arriving at fd side for read. Overflow
 // causes packet drops. gVisor implementation-specific.
 const defaultDevOutQueueLen = 1024
@@ -53,13 +60,37 @@ func OpenTun(ctx context.Context, routingTable routing.Table) (Device, error) {
 	if err != nil {
 		return nil, err
 	}
+	journal.Record(ctx, journal.KindTUN, dev.name, nil)
+	return newDevice(ctx, dev, routingTable), nil
+}
+
+// AdoptTun creates a Device from the file descriptor of a TUN device that is already up and running. This
+// is used when one daemon hands over its device to another.
+func AdoptTun(ctx context.Context, fd int, routingTable routing.Table) (Device, error) {
+	dev, err := adoptTun(ctx, fd)
+	if err != nil {
+		return nil, err
+	}
+	journal.Record(ctx, journal.KindTUN, dev.name, nil)
+	return newDevice(ctx, dev, routingTable), nil
+}
 
+// newDevice creates a device that uses the MTU from the client configuration. The network stack uses
+// the same MTU, so the TCP connections that it terminates negotiate segments that fit the device.
+func newDevice(ctx context.Context, dev *nativeDevice, routingTable routing.Table) *device {
+	mtu := client.GetConfig(ctx).VIF().GetMTU()
+	if mtu != client.DefaultMTU {
+		if err := dev.setMTU(mtu); err != nil {
+			dlog.Warnf(ctx, "unable to set MTU %d on device %s: %v", mtu, dev.name, err)
+		}
+	}
 	return &device{
-		Endpoint: channel.New(defaultDevOutQueueLen, defaultDevMtu, ""),
+		Endpoint: channel.New(defaultDevOutQueueLen, uint32(mtu), ""),
 		ctx:      ctx,
 		dev:      dev,
 		table:    routingTable,
-	}, nil
+		mtu:      mtu,
+	}
 }
 
 func (d *device) Attach(dp stack.NetworkDispatcher) {
@@ -108,8 +139,36 @@ func (d *device) AddSubnet(ctx context.Context, subnet *net.IPNet) (err error) {
 	return d.table.Add(ctx, route)
 }
 
+// adoptSubnet adds the route for a subnet that has already been added to this device by another
+// process.
+func (d *device) adoptSubnet(ctx context.Context, subnet *net.IPNet) error {
+	route, err := d.subnetToRoute(subnet)
+	if err != nil {
+		return err
+	}
+	return d.table.Add(ctx, route)
+}
+
 func (d *device) Close() error {
-	return d.dev.Close()
+	if err := d.dev.Close(); err != nil {
+		return err
+	}
+	journal.Forget(d.ctx, journal.KindTUN, d.dev.name)
+	return nil
+}
+
+// RemoveJournaled is the journal.Reverter of journal.KindTUN entries.
+func RemoveJournaled(ctx context.Context, e *journal.Entry) error {
+	if _, err := net.InterfaceByName(e.ID); err != nil {
+		// The device is gone.
+		return nil
+	}
+	return removeTun(ctx, e.ID)
+}
+
+// dup returns a duplicate of the file that represents this device.
+func (d *device) dup() (*os.File, error) {
+	return d.dev.dup()
 }
 
 // Index returns the index of this device.
@@ -131,6 +190,14 @@ func (d *device) SetMTU(mtu int) error {
 	return d.dev.setMTU(mtu)
 }
 
+// stats returns the MTU of this device and the number of fragments that it has read.
+func (d *device) stats() Stats {
+	return Stats{
+		MTU:       d.mtu,
+		Fragments: atomic.LoadUint64(&d.fragments),
+	}
+}
+
 // RemoveSubnet removes a subnet from this TUN device and also removes the route for that subnet which
 // is associated with the device.
 func (d *device) RemoveSubnet(ctx context.Context, subnet *net.IPNet) (err error) {
@@ -182,6 +249,11 @@ func (d *device) tunToDispatch(cancel context.CancelFunc) {
 			continue
 		}
 
+		packet := data[:n]
+		d.taps.call(packet)
+		if isFragment(packet) {
+			atomic.AddUint64(&d.fragments, 1)
+		}
 		pb := stack.NewPacketBuffer(stack.PacketBufferOptions{
 			Payload: bufferv2.MakeWithData(data[:n]),
 		})
@@ -206,6 +278,7 @@ func (d *device) dispatchToTun(ctx context.Context) {
 			b = b[len(s):]
 		}
 		pb.DecRef()
+		d.taps.call(buf.Buf())
 		if _, err := d.dev.writePacket(buf, 0); err != nil {
 			dlog.Errorf(ctx, "WritePacket failed: %v", err)
 		}
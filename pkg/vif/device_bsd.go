@@ -0,0 +1,213 @@
+//go:build freebsd || openbsd
+
+package vif
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"golang.org/x/sys/unix"
+
+	"github.com/datawire/dlib/dexec"
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/routing"
+	"github.com/telepresenceio/telepresence/v2/pkg/shellquote"
+	"github.com/telepresenceio/telepresence/v2/pkg/vif/buffer"
+)
+
+// tunDevicePattern matches the character devices of the TUN interfaces that exist.
+const tunDevicePattern = "/dev/tun[0-9]*"
+
+type nativeDevice struct {
+	*os.File
+	name string
+}
+
+func openTun(ctx context.Context) (*nativeDevice, error) {
+	// Reuse a TUN interface that isn't in use before creating a new one. Interfaces aren't
+	// destroyed when the device is closed, because it might have been handed over to another daemon.
+	paths, _ := filepath.Glob(tunDevicePattern)
+	for _, path := range paths {
+		fd, err := unix.Open(path, unix.O_RDWR|unix.O_CLOEXEC, 0)
+		if err != nil {
+			if errors.Is(err, unix.EBUSY) || errors.Is(err, unix.EPERM) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		return newNativeDevice(ctx, fd, filepath.Base(path))
+	}
+	fd, name, err := createTun()
+	if err != nil {
+		return nil, err
+	}
+	return newNativeDevice(ctx, fd, name)
+}
+
+func newNativeDevice(ctx context.Context, fd int, name string) (*nativeDevice, error) {
+	if err := initTun(fd); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("failed to initialize %s: %w", name, err)
+	}
+	if err := unix.SetNonblock(fd, true); err != nil {
+		_ = unix.Close(fd)
+		return nil, err
+	}
+	dlog.Debugf(ctx, "Using TUN device %s", name)
+	return &nativeDevice{
+		File: os.NewFile(uintptr(fd), "/dev/"+name),
+		name: name,
+	}, nil
+}
+
+// adoptTun creates a nativeDevice from the file descriptor of a TUN device that was opened by
+// another process.
+func adoptTun(_ context.Context, fd int) (*nativeDevice, error) {
+	name, err := tunName(fd)
+	if err != nil {
+		return nil, err
+	}
+	unix.CloseOnExec(fd)
+	if err = unix.SetNonblock(fd, true); err != nil {
+		return nil, err
+	}
+	return &nativeDevice{
+		File: os.NewFile(uintptr(fd), "/dev/"+name),
+		name: name,
+	}, nil
+}
+
+// tunName returns the name of the interface of the TUN device that the given file descriptor refers to. It's
+// the name of the character device in /dev that has the same device number.
+func tunName(fd int) (string, error) {
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return "", err
+	}
+	paths, _ := filepath.Glob(tunDevicePattern)
+	for _, path := range paths {
+		var pst unix.Stat_t
+		if unix.Stat(path, &pst) == nil && pst.Rdev == st.Rdev {
+			return filepath.Base(path), nil
+		}
+	}
+	return "", fmt.Errorf("unable to find the TUN device of file descriptor %d", fd)
+}
+
+// ifconfig runs ifconfig for this device with the given arguments.
+func (t *nativeDevice) ifconfig(ctx context.Context, args ...string) error {
+	args = append([]string{t.name}, args...)
+	cmd := dexec.CommandContext(ctx, "ifconfig", args...)
+	cmd.DisableLogging = true
+	dlog.Debug(ctx, shellquote.ShellString("ifconfig", args))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", shellquote.ShellString("ifconfig", args), err, out)
+	}
+	return nil
+}
+
+// removeTun destroys the interface with the given name, unless its device is in use. Interfaces aren't destroyed
+// when their device is closed, so the interface of a daemon that didn't terminate gracefully remains along with
+// its addresses and routes.
+func removeTun(ctx context.Context, name string) error {
+	fd, err := unix.Open(filepath.Join("/dev", name), unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		if errors.Is(err, unix.EBUSY) {
+			return nil
+		}
+		return fmt.Errorf("failed to open /dev/%s: %w", name, err)
+	}
+	_ = unix.Close(fd)
+	return (&nativeDevice{name: name}).ifconfig(ctx, "destroy")
+}
+
+func (t *nativeDevice) addSubnet(ctx context.Context, subnet *net.IPNet) error {
+	to := make(net.IP, len(subnet.IP))
+	copy(to, subnet.IP)
+	to[len(to)-1] = 1
+	if err := t.setAddr(ctx, subnet, to); err != nil {
+		return err
+	}
+	return routing.Add(1, subnet, to)
+}
+
+func (t *nativeDevice) index() int32 {
+	panic("not implemented")
+}
+
+func (t *nativeDevice) removeSubnet(ctx context.Context, subnet *net.IPNet) error {
+	to := make(net.IP, len(subnet.IP))
+	copy(to, subnet.IP)
+	to[len(to)-1] = 1
+	if err := t.removeAddr(ctx, subnet); err != nil {
+		return err
+	}
+	return routing.Clear(1, subnet, to)
+}
+
+func (t *nativeDevice) setMTU(mtu int) error {
+	return t.ifconfig(context.Background(), "mtu", strconv.Itoa(mtu))
+}
+
+func (t *nativeDevice) setAddr(ctx context.Context, subnet *net.IPNet, to net.IP) error {
+	if sub4 := subnet.IP.To4(); sub4 != nil {
+		return t.ifconfig(ctx, "inet", sub4.String(), to.String(), "netmask", net.IP(subnet.Mask).String(), "alias")
+	}
+	ones, _ := subnet.Mask.Size()
+	return t.ifconfig(ctx, "inet6", subnet.IP.String(), "prefixlen", strconv.Itoa(ones), "alias")
+}
+
+func (t *nativeDevice) removeAddr(ctx context.Context, subnet *net.IPNet) error {
+	if sub4 := subnet.IP.To4(); sub4 != nil {
+		return t.ifconfig(ctx, "inet", sub4.String(), "-alias")
+	}
+	return t.ifconfig(ctx, "inet6", subnet.IP.String(), "-alias")
+}
+
+func (t *nativeDevice) readPacket(into *buffer.Data) (int, error) {
+	n, err := t.File.Read(into.Raw())
+	if n >= buffer.PrefixLen {
+		n -= buffer.PrefixLen
+	}
+	return n, err
+}
+
+// writePacket writes the packet prefixed with the 4-byte address family header, in network byte order,
+// that the TUN device expects.
+func (t *nativeDevice) writePacket(from *buffer.Data, offset int) (n int, err error) {
+	raw := from.Raw()
+	if len(raw) <= buffer.PrefixLen {
+		return 0, unix.EIO
+	}
+
+	ipVer := raw[buffer.PrefixLen] >> 4
+	var af byte
+	switch ipVer {
+	case ipv4.Version:
+		af = unix.AF_INET
+	case ipv6.Version:
+		af = unix.AF_INET6
+	default:
+		return 0, errors.New("unable to determine IP version from packet")
+	}
+
+	if offset > 0 {
+		raw = raw[offset:]
+		// Temporarily move AF_INET/AF_INET6 into the offset position.
+		r3 := raw[3]
+		raw[3] = af
+		n, err = t.File.Write(raw)
+		raw[3] = r3
+	} else {
+		raw[3] = af
+		n, err = t.File.Write(raw)
+	}
+	return n - buffer.PrefixLen, err
+}
@@ -64,6 +64,23 @@ func openTun(_ context.Context) (*nativeDevice, error) {
 	}, nil
 }
 
+// adoptTun creates a nativeDevice from the file descriptor of a utun socket that was opened by
+// another process.
+func adoptTun(_ context.Context, fd int) (*nativeDevice, error) {
+	name, err := unix.GetsockoptString(fd, sysProtoControl, uTunOptIfName)
+	if err != nil {
+		return nil, err
+	}
+	unix.CloseOnExec(fd)
+	if err = unix.SetNonblock(fd, true); err != nil {
+		return nil, err
+	}
+	return &nativeDevice{
+		File: os.NewFile(uintptr(fd), ""),
+		name: name,
+	}, nil
+}
+
 func (t *nativeDevice) addSubnet(_ context.Context, subnet *net.IPNet) error {
 	to := make(net.IP, len(subnet.IP))
 	copy(to, subnet.IP)
@@ -263,3 +280,9 @@ func (t *nativeDevice) removeAddr(subnet *net.IPNet, to net.IP) error {
 		})
 	}
 }
+
+// removeTun does nothing, because the device is removed when the process that created it terminates. A device
+// with the given name belongs to another process.
+func removeTun(context.Context, string) error {
+	return nil
+}
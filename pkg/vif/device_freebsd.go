@@ -0,0 +1,30 @@
+package vif
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// tunSIFHEAD is the _IOW('t', 96, int) request that makes the TUN device prefix each packet with the
+// address family.
+const tunSIFHEAD = 0x80047460
+
+// createTun creates a new TUN interface by opening the cloning device.
+func createTun() (int, string, error) {
+	fd, err := unix.Open("/dev/tun", unix.O_RDWR|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return -1, "", fmt.Errorf("failed to open /dev/tun: %w", err)
+	}
+	name, err := tunName(fd)
+	if err != nil {
+		_ = unix.Close(fd)
+		return -1, "", err
+	}
+	return fd, name, nil
+}
+
+// initTun enables the address family header, which is disabled by default on FreeBSD.
+func initTun(fd int) error {
+	return unix.IoctlSetPointerInt(fd, tunSIFHEAD, 1)
+}
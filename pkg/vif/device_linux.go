@@ -91,6 +91,31 @@ func openTun(_ context.Context) (*nativeDevice, error) {
 	return &nativeDevice{File: os.NewFile(uintptr(fd), devicePath), name: name, interfaceIndex: index}, nil
 }
 
+// adoptTun creates a nativeDevice from the file descriptor of a TUN device that was opened by
+// another process.
+func adoptTun(_ context.Context, fd int) (*nativeDevice, error) {
+	var flagsRequest struct {
+		name  [unix.IFNAMSIZ]byte
+		flags int16
+	}
+	if err := ioctl(fd, unix.TUNGETIFF, unsafe.Pointer(&flagsRequest)); err != nil {
+		return nil, fmt.Errorf("failed to get TUN device flags: %w", err)
+	}
+	name := unix.ByteSliceToString(flagsRequest.name[:])
+	unix.CloseOnExec(fd)
+	_ = unix.SetNonblock(fd, true)
+
+	var index int32
+	err := withSocket(unix.AF_INET, func(s int) (err error) {
+		index, err = getInterfaceIndex(s, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &nativeDevice{File: os.NewFile(uintptr(fd), devicePath), name: name, interfaceIndex: index}, nil
+}
+
 func (t *nativeDevice) Close() error {
 	err := t.File.Close()
 	if err != nil {
@@ -160,3 +185,9 @@ func getInterfaceIndex(fd int, name string) (int32, error) {
 	}
 	return indexRequest.index, nil
 }
+
+// removeTun does nothing, because the device is removed when the process that created it terminates. A device
+// with the given name belongs to another process.
+func removeTun(context.Context, string) error {
+	return nil
+}
@@ -0,0 +1,15 @@
+package vif
+
+import (
+	"errors"
+)
+
+// createTun returns an error. OpenBSD has no cloning device, so only the TUN devices in /dev can be used.
+func createTun() (int, string, error) {
+	return -1, "", errors.New("no TUN device is available. Create one using \"cd /dev && sh MAKEDEV tun<N>\"")
+}
+
+// initTun does nothing, because the TUN device of OpenBSD always prefixes each packet with the address family.
+func initTun(int) error {
+	return nil
+}
@@ -6,6 +6,7 @@ package vif
 import (
 	"context"
 	"net"
+	"os"
 	"unsafe"
 
 	"golang.org/x/sys/unix"
@@ -16,6 +17,27 @@ func (t *nativeDevice) setDNS(context.Context, string, net.IP, []string) (err er
 	return nil
 }
 
+// dup returns a duplicate of the file descriptor of this device. The duplicate keeps the device
+// alive after the original has been closed.
+func (t *nativeDevice) dup() (*os.File, error) {
+	rc, err := t.File.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var nfd int
+	var dupErr error
+	if err = rc.Control(func(fd uintptr) {
+		nfd, dupErr = unix.Dup(int(fd))
+	}); err != nil {
+		return nil, err
+	}
+	if dupErr != nil {
+		return nil, dupErr
+	}
+	unix.CloseOnExec(nfd)
+	return os.NewFile(uintptr(nfd), t.name), nil
+}
+
 func withSocket(domain int, f func(fd int) error) error {
 	fd, err := unix.Socket(domain, unix.SOCK_DGRAM, 0)
 	if err != nil {
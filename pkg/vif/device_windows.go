@@ -34,6 +34,14 @@ type nativeDevice struct {
 	interfaceIndex int32
 }
 
+func adoptTun(context.Context, int) (*nativeDevice, error) {
+	return nil, errors.New("adopting a TUN device is not supported on windows")
+}
+
+func (t *nativeDevice) dup() (*os.File, error) {
+	return nil, errors.New("duplicating a TUN device is not supported on windows")
+}
+
 func openTun(ctx context.Context) (td *nativeDevice, err error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -339,3 +347,9 @@ func (t *nativeDevice) writePacket(from *buffer.Data, offset int) (int, error) {
 	}
 	return len(from.Raw()), nil
 }
+
+// removeTun does nothing, because the device is removed when the process that created it terminates. A device
+// with the given name belongs to another process.
+func removeTun(context.Context, string) error {
+	return nil
+}
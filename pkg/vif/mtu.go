@@ -0,0 +1,31 @@
+package vif
+
+import (
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+)
+
+const ipv6FragmentHeader = 44
+
+// Stats describe the MTU of the device and how many fragmented packets it has read.
+type Stats struct {
+	// MTU is the maximum transmission unit of the device.
+	MTU int
+
+	// Fragments is the number of IP fragments that were read from the device.
+	Fragments uint64
+}
+
+// isFragment returns true if the given IP packet is a fragment of a larger packet.
+func isFragment(packet []byte) bool {
+	switch header.IPVersion(packet) {
+	case header.IPv4Version:
+		if len(packet) < header.IPv4MinimumSize {
+			return false
+		}
+		ip := header.IPv4(packet)
+		return ip.More() || ip.FragmentOffset() != 0
+	case header.IPv6Version:
+		return len(packet) >= header.IPv6MinimumSize && header.IPv6(packet).NextHeader() == ipv6FragmentHeader
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package vif
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gvisor.dev/gvisor/pkg/bufferv2"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/channel"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv6"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+
+	"github.com/datawire/dlib/dlog"
+	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
+)
+
+func TestIsFragment(t *testing.T) {
+	packet := make([]byte, header.IPv4MinimumSize+header.TCPMinimumSize)
+	header.IPv4(packet).Encode(&header.IPv4Fields{
+		TotalLength: uint16(len(packet)),
+		TTL:         64,
+		Protocol:    uint8(header.TCPProtocolNumber),
+		SrcAddr:     tcpip.Address(net.IPv4(192, 168, 1, 2).To4()),
+		DstAddr:     tcpip.Address(net.IPv4(10, 0, 0, 1).To4()),
+	})
+	assert.False(t, isFragment(packet))
+	header.IPv4(packet).SetFlagsFragmentOffset(header.IPv4FlagMoreFragments, 0)
+	assert.True(t, isFragment(packet))
+	header.IPv4(packet).SetFlagsFragmentOffset(0, 1480)
+	assert.True(t, isFragment(packet))
+
+	packet = make([]byte, header.IPv6MinimumSize+header.TCPMinimumSize)
+	header.IPv6(packet).Encode(&header.IPv6Fields{
+		PayloadLength:     header.TCPMinimumSize,
+		TransportProtocol: header.TCPProtocolNumber,
+		HopLimit:          64,
+		SrcAddr:           tcpip.Address(net.ParseIP("fd00::2")),
+		DstAddr:           tcpip.Address(net.ParseIP("fd01::1")),
+	})
+	assert.False(t, isFragment(packet))
+	packet[6] = ipv6FragmentHeader
+	assert.True(t, isFragment(packet))
+}
+
+// TestStackMSS verifies that the maximum segment size that the stack advertises in the SYN-ACK of the TCP
+// connections that it terminates reflects the MTU of the device, even when the SYN advertises a larger one.
+// This is what makes a lowered vif.mtu effective without clamping the MSS of the packets.
+func TestStackMSS(t *testing.T) {
+	const mtu = 1280 // the smallest MTU that vif.mtu accepts
+	tests := []struct {
+		name     string
+		proto    tcpip.NetworkProtocolNumber
+		src, dst net.IP
+		wantMSS  uint16
+	}{
+		{"IPv4", ipv4.ProtocolNumber, net.IPv4(192, 168, 1, 2).To4(), net.IPv4(10, 0, 0, 1).To4(), mtu - header.IPv4MinimumSize - header.TCPMinimumSize},
+		{"IPv6", ipv6.ProtocolNumber, net.ParseIP("fd00::2"), net.ParseIP("fd01::1"), mtu - header.IPv6MinimumSize - header.TCPMinimumSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := dlog.NewTestContext(t, false)
+			ep := channel.New(16, mtu, "")
+			s, err := NewStack(ctx, ep, func(context.Context, tunnel.ConnID) (tunnel.Stream, error) {
+				return nil, errors.New("no streams in this test")
+			})
+			require.NoError(t, err)
+			defer s.Close()
+
+			src, dst := tcpip.Address(tt.src), tcpip.Address(tt.dst)
+			tcpLen := header.TCPMinimumSize + 4
+			seg := header.TCP(make([]byte, tcpLen))
+			seg.Encode(&header.TCPFields{
+				SrcPort:    40000,
+				DstPort:    80,
+				SeqNum:     1000,
+				DataOffset: uint8(tcpLen),
+				Flags:      header.TCPFlagSyn,
+				WindowSize: 65535,
+			})
+			header.EncodeMSSOption(1460, seg[header.TCPMinimumSize:])
+			seg.SetChecksum(^seg.CalculateChecksum(header.PseudoHeaderChecksum(header.TCPProtocolNumber, src, dst, uint16(tcpLen))))
+
+			var packet []byte
+			if tt.proto == ipv4.ProtocolNumber {
+				packet = make([]byte, header.IPv4MinimumSize+tcpLen)
+				ip := header.IPv4(packet)
+				ip.Encode(&header.IPv4Fields{
+					TotalLength: uint16(len(packet)),
+					TTL:         64,
+					Protocol:    uint8(header.TCPProtocolNumber),
+					SrcAddr:     src,
+					DstAddr:     dst,
+				})
+				ip.SetChecksum(^ip.CalculateChecksum())
+				copy(packet[header.IPv4MinimumSize:], seg)
+			} else {
+				packet = make([]byte, header.IPv6MinimumSize+tcpLen)
+				header.IPv6(packet).Encode(&header.IPv6Fields{
+					PayloadLength:     uint16(tcpLen),
+					TransportProtocol: header.TCPProtocolNumber,
+					HopLimit:          64,
+					SrcAddr:           src,
+					DstAddr:           dst,
+				})
+				copy(packet[header.IPv6MinimumSize:], seg)
+			}
+			ep.InjectInbound(tt.proto, stack.NewPacketBuffer(stack.PacketBufferOptions{
+				Payload: bufferv2.MakeWithData(packet),
+			}))
+
+			rctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			defer cancel()
+			pb := ep.ReadContext(rctx)
+			require.False(t, pb.IsNil(), "no SYN-ACK was sent")
+			defer pb.DecRef()
+			reply := pb.ToView().AsSlice()
+			var synAck header.TCP
+			if tt.proto == ipv4.ProtocolNumber {
+				synAck = header.IPv4(reply).Payload()
+			} else {
+				synAck = header.IPv6(reply).Payload()
+			}
+			require.Equal(t, header.TCPFlagSyn|header.TCPFlagAck, synAck.Flags())
+			assert.Equal(t, tt.wantMSS, header.ParseSynOptions(synAck.Options(), true).MSS)
+		})
+	}
+}
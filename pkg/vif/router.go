@@ -58,6 +58,18 @@ func (rt *Router) GetRoutedSubnets() []*net.IPNet {
 	return rt.routedSubnets
 }
 
+// GetNeverProxyRoutes returns the routes that subnets configured not to be proxied had
+// before they were routed to the device.
+func (rt *Router) GetNeverProxyRoutes() []*routing.Route {
+	return rt.neverProxyRoutes
+}
+
+// GetStaticOverrides returns the never proxied routes that have been added to the routing
+// table, because they overlap a routed subnet.
+func (rt *Router) GetStaticOverrides() []*routing.Route {
+	return rt.staticOverrides
+}
+
 func (rt *Router) UpdateWhitelist(whitelist []*net.IPNet) {
 	rt.whitelistedSubnets = whitelist
 }
@@ -183,6 +195,8 @@ adding:
 		}
 		if err := rt.routingTable.Add(ctx, r); err != nil {
 			dlog.Errorf(ctx, "failed to add static route %s: %v", r, err)
+		} else {
+			r.RecordInJournal(ctx)
 		}
 	}
 
@@ -195,6 +209,8 @@ removing:
 		}
 		if err := rt.routingTable.Remove(ctx, c); err != nil {
 			dlog.Errorf(ctx, "failed to remove static route %s: %v", c, err)
+		} else {
+			c.ForgetInJournal(ctx)
 		}
 	}
 	rt.staticOverrides = desired
@@ -211,6 +227,8 @@ func (rt *Router) Close(ctx context.Context) {
 	for _, r := range rt.staticOverrides {
 		if err := rt.routingTable.Remove(ctx, r); err != nil {
 			dlog.Errorf(ctx, "failed to remove static route %s: %v", r, err)
+		} else {
+			r.ForgetInJournal(ctx)
 		}
 	}
 }
@@ -0,0 +1,71 @@
+package vif
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// PacketTap receives each IP packet that traverses the TUN-device. It is called from the goroutines that
+// move packets between the TUN-device and the network stack, so it must return quickly. The packet is
+// only valid during the call.
+type PacketTap func(packet []byte)
+
+type tapEntry struct {
+	tap PacketTap
+}
+
+// taps is a copy-on-write list of PacketTaps, so that calling them requires no locking.
+type taps struct {
+	lock    sync.Mutex
+	entries atomic.Pointer[[]*tapEntry]
+}
+
+// add adds a tap and returns a function that removes it.
+func (t *taps) add(tap PacketTap) (remove func()) {
+	e := &tapEntry{tap: tap}
+	t.lock.Lock()
+	t.set(append(t.get(), e))
+	t.lock.Unlock()
+	return func() {
+		t.lock.Lock()
+		defer t.lock.Unlock()
+		old := t.get()
+		es := make([]*tapEntry, 0, len(old))
+		for _, oe := range old {
+			if oe != e {
+				es = append(es, oe)
+			}
+		}
+		t.set(es)
+	}
+}
+
+func (t *taps) get() []*tapEntry {
+	if es := t.entries.Load(); es != nil {
+		return *es
+	}
+	return nil
+}
+
+func (t *taps) set(es []*tapEntry) {
+	cp := make([]*tapEntry, len(es))
+	copy(cp, es)
+	t.entries.Store(&cp)
+}
+
+// call passes the packet to all taps.
+func (t *taps) call(packet []byte) {
+	for _, e := range t.get() {
+		e.tap(packet)
+	}
+}
+
+// AddTap adds a tap that receives each packet that traverses the TUN-device, and returns a function
+// that removes it.
+func (vif *TunnelingDevice) AddTap(tap PacketTap) (remove func()) {
+	dev, ok := vif.Device.(*device)
+	if !ok {
+		return func() {}
+	}
+	return dev.taps.add(tap)
+}
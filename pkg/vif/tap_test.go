@@ -0,0 +1,25 @@
+package vif
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaps(t *testing.T) {
+	var ts taps
+	ts.call([]byte{1})
+
+	var a, b [][]byte
+	removeA := ts.add(func(p []byte) { a = append(a, p) })
+	removeB := ts.add(func(p []byte) { b = append(b, p) })
+	ts.call([]byte{2})
+	removeA()
+	ts.call([]byte{3})
+	removeB()
+	removeB()
+	ts.call([]byte{4})
+
+	assert.Equal(t, [][]byte{{2}}, a)
+	assert.Equal(t, [][]byte{{2}, {3}}, b)
+}
@@ -2,19 +2,26 @@ package vif
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
 
 	"github.com/hashicorp/go-multierror"
 	"gvisor.dev/gvisor/pkg/tcpip/stack"
 
+	"github.com/datawire/dlib/dlog"
 	"github.com/telepresenceio/telepresence/v2/pkg/routing"
 	"github.com/telepresenceio/telepresence/v2/pkg/tunnel"
 )
 
 type TunnelingDevice struct {
-	stack  *stack.Stack
-	Device Device
-	Router *Router
-	table  routing.Table
+	stack    *stack.Stack
+	Device   Device
+	Router   *Router
+	table    routing.Table
+	detached int32
 }
 
 func NewTunnelingDevice(ctx context.Context, tunnelStreamCreator tunnel.StreamCreator) (*TunnelingDevice, error) {
@@ -39,7 +46,81 @@ func NewTunnelingDevice(ctx context.Context, tunnelStreamCreator tunnel.StreamCr
 	}, nil
 }
 
+// AdoptTunnelingDevice creates a TunnelingDevice from the file descriptor of a TUN device that has been handed
+// over from another process. The given subnets are already routed to the device by that process. They are
+// added to the routing table but not to the device.
+func AdoptTunnelingDevice(
+	ctx context.Context,
+	fd int,
+	routedSubnets []*net.IPNet,
+	tunnelStreamCreator tunnel.StreamCreator,
+) (*TunnelingDevice, error) {
+	routingTable, err := routing.OpenTable(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dev, err := AdoptTun(ctx, fd, routingTable)
+	if err != nil {
+		return nil, err
+	}
+	stack, err := NewStack(ctx, dev, tunnelStreamCreator)
+	if err != nil {
+		return nil, err
+	}
+	for _, sn := range routedSubnets {
+		if err := dev.(*device).adoptSubnet(ctx, sn); err != nil {
+			dlog.Debugf(ctx, "failed to add route for adopted subnet %s: %v", sn, err)
+		}
+	}
+	router := NewRouter(dev, routingTable)
+	router.routedSubnets = routedSubnets
+	return &TunnelingDevice{
+		stack:  stack,
+		Device: dev,
+		Router: router,
+		table:  routingTable,
+	}, nil
+}
+
+// Detach stops this TunnelingDevice from processing packets and returns a duplicate of the TUN device's file
+// together with the subnets that are currently routed to it. Unlike Close, Detach leaves the device and its
+// subnets intact so that another process can adopt them. A subsequent call to Close is a no-op.
+func (vif *TunnelingDevice) Detach(ctx context.Context) (*os.File, []*net.IPNet, error) {
+	dev, ok := vif.Device.(*device)
+	if !ok {
+		return nil, nil, fmt.Errorf("device %T cannot be detached", vif.Device)
+	}
+	f, err := dev.dup()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !atomic.CompareAndSwapInt32(&vif.detached, 0, 1) {
+		_ = f.Close()
+		return nil, nil, errors.New("device is already detached")
+	}
+	vif.stack.Close()
+	if err := vif.Device.Close(); err != nil {
+		dlog.Errorf(ctx, "failed to close detached device %s: %v", vif.Device.Name(), err)
+	}
+	// The routes in our table must go. The adopting process will add them to its own table.
+	if err := vif.table.Close(ctx); err != nil {
+		dlog.Errorf(ctx, "failed to close routing table: %v", err)
+	}
+	return f, vif.Router.GetRoutedSubnets(), nil
+}
+
+// Stats returns the MTU of the device and the number of fragments that it has read.
+func (vif *TunnelingDevice) Stats() Stats {
+	if dev, ok := vif.Device.(*device); ok {
+		return dev.stats()
+	}
+	return Stats{}
+}
+
 func (vif *TunnelingDevice) Close(ctx context.Context) error {
+	if atomic.LoadInt32(&vif.detached) != 0 {
+		return nil
+	}
 	var result error
 	vif.stack.Close()
 	vif.Router.Close(ctx)
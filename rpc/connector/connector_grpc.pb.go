@@ -29,12 +29,16 @@ const (
 	Connector_GetIntercept_FullMethodName            = "/telepresence.connector.Connector/GetIntercept"
 	Connector_Connect_FullMethodName                 = "/telepresence.connector.Connector/Connect"
 	Connector_Disconnect_FullMethodName              = "/telepresence.connector.Connector/Disconnect"
+	Connector_AcquireLease_FullMethodName            = "/telepresence.connector.Connector/AcquireLease"
+	Connector_ReleaseLease_FullMethodName            = "/telepresence.connector.Connector/ReleaseLease"
+	Connector_ListLeases_FullMethodName              = "/telepresence.connector.Connector/ListLeases"
 	Connector_GetClusterSubnets_FullMethodName       = "/telepresence.connector.Connector/GetClusterSubnets"
 	Connector_Status_FullMethodName                  = "/telepresence.connector.Connector/Status"
 	Connector_CanIntercept_FullMethodName            = "/telepresence.connector.Connector/CanIntercept"
 	Connector_CreateIntercept_FullMethodName         = "/telepresence.connector.Connector/CreateIntercept"
 	Connector_RemoveIntercept_FullMethodName         = "/telepresence.connector.Connector/RemoveIntercept"
 	Connector_UpdateIntercept_FullMethodName         = "/telepresence.connector.Connector/UpdateIntercept"
+	Connector_SwitchInterceptHandler_FullMethodName  = "/telepresence.connector.Connector/SwitchInterceptHandler"
 	Connector_Helm_FullMethodName                    = "/telepresence.connector.Connector/Helm"
 	Connector_Uninstall_FullMethodName               = "/telepresence.connector.Connector/Uninstall"
 	Connector_List_FullMethodName                    = "/telepresence.connector.Connector/List"
@@ -42,14 +46,28 @@ const (
 	Connector_SetLogLevel_FullMethodName             = "/telepresence.connector.Connector/SetLogLevel"
 	Connector_Quit_FullMethodName                    = "/telepresence.connector.Connector/Quit"
 	Connector_GatherLogs_FullMethodName              = "/telepresence.connector.Connector/GatherLogs"
+	Connector_StreamLogs_FullMethodName              = "/telepresence.connector.Connector/StreamLogs"
+	Connector_ListTakeOverRequests_FullMethodName    = "/telepresence.connector.Connector/ListTakeOverRequests"
+	Connector_AnswerTakeOver_FullMethodName          = "/telepresence.connector.Connector/AnswerTakeOver"
+	Connector_GetUsageReport_FullMethodName          = "/telepresence.connector.Connector/GetUsageReport"
+	Connector_GetManagerProfile_FullMethodName       = "/telepresence.connector.Connector/GetManagerProfile"
+	Connector_GetInterceptMetrics_FullMethodName     = "/telepresence.connector.Connector/GetInterceptMetrics"
+	Connector_SampleIntercept_FullMethodName         = "/telepresence.connector.Connector/SampleIntercept"
 	Connector_GatherTraces_FullMethodName            = "/telepresence.connector.Connector/GatherTraces"
 	Connector_AddInterceptor_FullMethodName          = "/telepresence.connector.Connector/AddInterceptor"
 	Connector_RemoveInterceptor_FullMethodName       = "/telepresence.connector.Connector/RemoveInterceptor"
 	Connector_GetNamespaces_FullMethodName           = "/telepresence.connector.Connector/GetNamespaces"
+	Connector_MapNamespaces_FullMethodName           = "/telepresence.connector.Connector/MapNamespaces"
 	Connector_RemoteMountAvailability_FullMethodName = "/telepresence.connector.Connector/RemoteMountAvailability"
 	Connector_GetConfig_FullMethodName               = "/telepresence.connector.Connector/GetConfig"
+	Connector_Paths_FullMethodName                   = "/telepresence.connector.Connector/Paths"
 	Connector_SetDNSExcludes_FullMethodName          = "/telepresence.connector.Connector/SetDNSExcludes"
 	Connector_SetDNSMappings_FullMethodName          = "/telepresence.connector.Connector/SetDNSMappings"
+	Connector_GetRoutes_FullMethodName               = "/telepresence.connector.Connector/GetRoutes"
+	Connector_CheckRoute_FullMethodName              = "/telepresence.connector.Connector/CheckRoute"
+	Connector_Capture_FullMethodName                 = "/telepresence.connector.Connector/Capture"
+	Connector_GetFlowStats_FullMethodName            = "/telepresence.connector.Connector/GetFlowStats"
+	Connector_Exec_FullMethodName                    = "/telepresence.connector.Connector/Exec"
 )
 
 // ConnectorClient is the client API for Connector service.
@@ -73,6 +91,13 @@ type ConnectorClient interface {
 	Connect(ctx context.Context, in *ConnectRequest, opts ...grpc.CallOption) (*ConnectInfo, error)
 	// Disconnects the cluster
 	Disconnect(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// AcquireLease registers a holder of the current connection, such as a shell or an IDE plugin.
+	// A lease is released when its holder terminates, or when the connection ends.
+	AcquireLease(ctx context.Context, in *Lease, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// ReleaseLease removes the lease of the given holder.
+	ReleaseLease(ctx context.Context, in *Lease, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// ListLeases returns the leases of the current connection.
+	ListLeases(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*LeaseList, error)
 	// GetClusterSubnets gets the outbound info that has been set on daemon
 	GetClusterSubnets(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ClusterSubnets, error)
 	// Status returns the status of the current connection or DISCONNECTED
@@ -87,6 +112,9 @@ type ConnectorClient interface {
 	// Requires having already called Connect.
 	RemoveIntercept(ctx context.Context, in *manager.RemoveInterceptRequest2, opts ...grpc.CallOption) (*InterceptResult, error)
 	UpdateIntercept(ctx context.Context, in *manager.UpdateInterceptRequest, opts ...grpc.CallOption) (*manager.InterceptInfo, error)
+	// Routes the traffic of an intercept that was created with handlers to
+	// another one of its handlers. Requires having already called Connect.
+	SwitchInterceptHandler(ctx context.Context, in *SwitchInterceptHandlerRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	// Installs, Upgrades, or Uninstalls the traffic-manager in the cluster.
 	Helm(ctx context.Context, in *HelmRequest, opts ...grpc.CallOption) (*common.Result, error)
 	// Uninstalls traffic-agents from the cluster.
@@ -104,6 +132,25 @@ type ConnectorClient interface {
 	// GatherLogs will acquire logs for the various Telepresence components in kubernetes
 	// (pending the request) and return them to the caller
 	GatherLogs(ctx context.Context, in *LogsRequest, opts ...grpc.CallOption) (*LogsResponse, error)
+	// StreamLogs streams the logs of the root and user daemons, the traffic-manager, and the
+	// traffic-agents. The logs of the traffic-manager and the traffic-agents require a session.
+	StreamLogs(ctx context.Context, in *StreamLogsRequest, opts ...grpc.CallOption) (Connector_StreamLogsClient, error)
+	// ListTakeOverRequests returns the pending requests from other clients to take over the
+	// intercepts of this client.
+	ListTakeOverRequests(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*TakeOverRequestList, error)
+	// AnswerTakeOver accepts or rejects a request to take over an intercept. The intercept
+	// is removed when the request is accepted.
+	AnswerTakeOver(ctx context.Context, in *TakeOverAnswer, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// GetUsageReport returns the usage per user that the traffic-manager has collected.
+	GetUsageReport(ctx context.Context, in *manager.UsageRequest, opts ...grpc.CallOption) (*manager.UsageReport, error)
+	// GetManagerProfile returns a pprof profile of the traffic-manager.
+	GetManagerProfile(ctx context.Context, in *manager.ProfileRequest, opts ...grpc.CallOption) (*manager.ProfileResponse, error)
+	// GetInterceptMetrics returns the request counts, errors, and latencies that the
+	// traffic-agents have recorded for an intercept of this session.
+	GetInterceptMetrics(ctx context.Context, in *manager.GetInterceptRequest, opts ...grpc.CallOption) (*manager.InterceptMetrics, error)
+	// SampleIntercept counts the requests that a proposed intercept would capture,
+	// without redirecting anything.
+	SampleIntercept(ctx context.Context, in *manager.SampleInterceptRequest, opts ...grpc.CallOption) (*manager.SampleResult, error)
 	// GatherTraces will acquire traces for the various Telepresence components in kubernetes
 	// (pending the request) and save them in a file.
 	GatherTraces(ctx context.Context, in *TracesRequest, opts ...grpc.CallOption) (*common.Result, error)
@@ -114,15 +161,31 @@ type ConnectorClient interface {
 	RemoveInterceptor(ctx context.Context, in *Interceptor, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	// GetNamespaces gets the mapped namespaces with an optional prefix
 	GetNamespaces(ctx context.Context, in *GetNamespacesRequest, opts ...grpc.CallOption) (*GetNamespacesResponse, error)
+	// MapNamespaces adds namespaces to, or removes them from, the mapped namespaces of the
+	// current session. DNS and routing are updated without a reconnect. The response contains
+	// the namespaces that are mapped after the update.
+	MapNamespaces(ctx context.Context, in *MapNamespacesRequest, opts ...grpc.CallOption) (*GetNamespacesResponse, error)
 	// RemoteMountAvailability checks if remote mounts are possible using the given
 	// mount type and returns an error if its not.
 	RemoteMountAvailability(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*common.Result, error)
 	// GetConfig returns the current configuration
 	GetConfig(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ClientConfig, error)
+	// Paths returns the directories and sockets that the daemon uses.
+	Paths(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*DaemonPaths, error)
 	// SetDNSExcludes sets the excludes field of DNSConfig.
 	SetDNSExcludes(ctx context.Context, in *daemon.SetDNSExcludesRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	// SetDNSMappings sets the Mappings field of DNSConfig.
 	SetDNSMappings(ctx context.Context, in *daemon.SetDNSMappingsRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// GetRoutes returns the routes that the root daemon maintains.
+	GetRoutes(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*daemon.RouteList, error)
+	// CheckRoute explains how the root daemon routes traffic to an address.
+	CheckRoute(ctx context.Context, in *daemon.CheckRouteRequest, opts ...grpc.CallOption) (*daemon.CheckRouteResponse, error)
+	// Capture streams copies of the packets that traverse the root daemon's TUN-device.
+	Capture(ctx context.Context, in *daemon.CaptureRequest, opts ...grpc.CallOption) (Connector_CaptureClient, error)
+	// GetFlowStats returns the root daemon's traffic statistics per destination.
+	GetFlowStats(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*daemon.FlowStats, error)
+	// Exec runs a command in the app container of a workload through the traffic-manager.
+	Exec(ctx context.Context, opts ...grpc.CallOption) (Connector_ExecClient, error)
 }
 
 type connectorClient struct {
@@ -187,6 +250,33 @@ func (c *connectorClient) Disconnect(ctx context.Context, in *emptypb.Empty, opt
 	return out, nil
 }
 
+func (c *connectorClient) AcquireLease(ctx context.Context, in *Lease, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Connector_AcquireLease_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *connectorClient) ReleaseLease(ctx context.Context, in *Lease, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Connector_ReleaseLease_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *connectorClient) ListLeases(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*LeaseList, error) {
+	out := new(LeaseList)
+	err := c.cc.Invoke(ctx, Connector_ListLeases_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *connectorClient) GetClusterSubnets(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ClusterSubnets, error) {
 	out := new(ClusterSubnets)
 	err := c.cc.Invoke(ctx, Connector_GetClusterSubnets_FullMethodName, in, out, opts...)
@@ -241,6 +331,15 @@ func (c *connectorClient) UpdateIntercept(ctx context.Context, in *manager.Updat
 	return out, nil
 }
 
+func (c *connectorClient) SwitchInterceptHandler(ctx context.Context, in *SwitchInterceptHandlerRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Connector_SwitchInterceptHandler_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *connectorClient) Helm(ctx context.Context, in *HelmRequest, opts ...grpc.CallOption) (*common.Result, error) {
 	out := new(common.Result)
 	err := c.cc.Invoke(ctx, Connector_Helm_FullMethodName, in, out, opts...)
@@ -327,6 +426,92 @@ func (c *connectorClient) GatherLogs(ctx context.Context, in *LogsRequest, opts
 	return out, nil
 }
 
+func (c *connectorClient) StreamLogs(ctx context.Context, in *StreamLogsRequest, opts ...grpc.CallOption) (Connector_StreamLogsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Connector_ServiceDesc.Streams[1], Connector_StreamLogs_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &connectorStreamLogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Connector_StreamLogsClient interface {
+	Recv() (*LogEntry, error)
+	grpc.ClientStream
+}
+
+type connectorStreamLogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *connectorStreamLogsClient) Recv() (*LogEntry, error) {
+	m := new(LogEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *connectorClient) ListTakeOverRequests(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*TakeOverRequestList, error) {
+	out := new(TakeOverRequestList)
+	err := c.cc.Invoke(ctx, Connector_ListTakeOverRequests_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *connectorClient) AnswerTakeOver(ctx context.Context, in *TakeOverAnswer, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Connector_AnswerTakeOver_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *connectorClient) GetUsageReport(ctx context.Context, in *manager.UsageRequest, opts ...grpc.CallOption) (*manager.UsageReport, error) {
+	out := new(manager.UsageReport)
+	err := c.cc.Invoke(ctx, Connector_GetUsageReport_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *connectorClient) GetManagerProfile(ctx context.Context, in *manager.ProfileRequest, opts ...grpc.CallOption) (*manager.ProfileResponse, error) {
+	out := new(manager.ProfileResponse)
+	err := c.cc.Invoke(ctx, Connector_GetManagerProfile_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *connectorClient) GetInterceptMetrics(ctx context.Context, in *manager.GetInterceptRequest, opts ...grpc.CallOption) (*manager.InterceptMetrics, error) {
+	out := new(manager.InterceptMetrics)
+	err := c.cc.Invoke(ctx, Connector_GetInterceptMetrics_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *connectorClient) SampleIntercept(ctx context.Context, in *manager.SampleInterceptRequest, opts ...grpc.CallOption) (*manager.SampleResult, error) {
+	out := new(manager.SampleResult)
+	err := c.cc.Invoke(ctx, Connector_SampleIntercept_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *connectorClient) GatherTraces(ctx context.Context, in *TracesRequest, opts ...grpc.CallOption) (*common.Result, error) {
 	out := new(common.Result)
 	err := c.cc.Invoke(ctx, Connector_GatherTraces_FullMethodName, in, out, opts...)
@@ -363,6 +548,15 @@ func (c *connectorClient) GetNamespaces(ctx context.Context, in *GetNamespacesRe
 	return out, nil
 }
 
+func (c *connectorClient) MapNamespaces(ctx context.Context, in *MapNamespacesRequest, opts ...grpc.CallOption) (*GetNamespacesResponse, error) {
+	out := new(GetNamespacesResponse)
+	err := c.cc.Invoke(ctx, Connector_MapNamespaces_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *connectorClient) RemoteMountAvailability(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*common.Result, error) {
 	out := new(common.Result)
 	err := c.cc.Invoke(ctx, Connector_RemoteMountAvailability_FullMethodName, in, out, opts...)
@@ -381,6 +575,15 @@ func (c *connectorClient) GetConfig(ctx context.Context, in *emptypb.Empty, opts
 	return out, nil
 }
 
+func (c *connectorClient) Paths(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*DaemonPaths, error) {
+	out := new(DaemonPaths)
+	err := c.cc.Invoke(ctx, Connector_Paths_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *connectorClient) SetDNSExcludes(ctx context.Context, in *daemon.SetDNSExcludesRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
 	out := new(emptypb.Empty)
 	err := c.cc.Invoke(ctx, Connector_SetDNSExcludes_FullMethodName, in, out, opts...)
@@ -399,6 +602,96 @@ func (c *connectorClient) SetDNSMappings(ctx context.Context, in *daemon.SetDNSM
 	return out, nil
 }
 
+func (c *connectorClient) GetRoutes(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*daemon.RouteList, error) {
+	out := new(daemon.RouteList)
+	err := c.cc.Invoke(ctx, Connector_GetRoutes_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *connectorClient) CheckRoute(ctx context.Context, in *daemon.CheckRouteRequest, opts ...grpc.CallOption) (*daemon.CheckRouteResponse, error) {
+	out := new(daemon.CheckRouteResponse)
+	err := c.cc.Invoke(ctx, Connector_CheckRoute_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *connectorClient) Capture(ctx context.Context, in *daemon.CaptureRequest, opts ...grpc.CallOption) (Connector_CaptureClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Connector_ServiceDesc.Streams[2], Connector_Capture_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &connectorCaptureClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Connector_CaptureClient interface {
+	Recv() (*daemon.CapturedPacket, error)
+	grpc.ClientStream
+}
+
+type connectorCaptureClient struct {
+	grpc.ClientStream
+}
+
+func (x *connectorCaptureClient) Recv() (*daemon.CapturedPacket, error) {
+	m := new(daemon.CapturedPacket)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *connectorClient) GetFlowStats(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*daemon.FlowStats, error) {
+	out := new(daemon.FlowStats)
+	err := c.cc.Invoke(ctx, Connector_GetFlowStats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *connectorClient) Exec(ctx context.Context, opts ...grpc.CallOption) (Connector_ExecClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Connector_ServiceDesc.Streams[3], Connector_Exec_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &connectorExecClient{stream}
+	return x, nil
+}
+
+type Connector_ExecClient interface {
+	Send(*manager.ExecRequest) error
+	Recv() (*manager.ExecResponse, error)
+	grpc.ClientStream
+}
+
+type connectorExecClient struct {
+	grpc.ClientStream
+}
+
+func (x *connectorExecClient) Send(m *manager.ExecRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *connectorExecClient) Recv() (*manager.ExecResponse, error) {
+	m := new(manager.ExecResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // ConnectorServer is the server API for Connector service.
 // All implementations must embed UnimplementedConnectorServer
 // for forward compatibility
@@ -420,6 +713,13 @@ type ConnectorServer interface {
 	Connect(context.Context, *ConnectRequest) (*ConnectInfo, error)
 	// Disconnects the cluster
 	Disconnect(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
+	// AcquireLease registers a holder of the current connection, such as a shell or an IDE plugin.
+	// A lease is released when its holder terminates, or when the connection ends.
+	AcquireLease(context.Context, *Lease) (*emptypb.Empty, error)
+	// ReleaseLease removes the lease of the given holder.
+	ReleaseLease(context.Context, *Lease) (*emptypb.Empty, error)
+	// ListLeases returns the leases of the current connection.
+	ListLeases(context.Context, *emptypb.Empty) (*LeaseList, error)
 	// GetClusterSubnets gets the outbound info that has been set on daemon
 	GetClusterSubnets(context.Context, *emptypb.Empty) (*ClusterSubnets, error)
 	// Status returns the status of the current connection or DISCONNECTED
@@ -434,6 +734,9 @@ type ConnectorServer interface {
 	// Requires having already called Connect.
 	RemoveIntercept(context.Context, *manager.RemoveInterceptRequest2) (*InterceptResult, error)
 	UpdateIntercept(context.Context, *manager.UpdateInterceptRequest) (*manager.InterceptInfo, error)
+	// Routes the traffic of an intercept that was created with handlers to
+	// another one of its handlers. Requires having already called Connect.
+	SwitchInterceptHandler(context.Context, *SwitchInterceptHandlerRequest) (*emptypb.Empty, error)
 	// Installs, Upgrades, or Uninstalls the traffic-manager in the cluster.
 	Helm(context.Context, *HelmRequest) (*common.Result, error)
 	// Uninstalls traffic-agents from the cluster.
@@ -451,6 +754,25 @@ type ConnectorServer interface {
 	// GatherLogs will acquire logs for the various Telepresence components in kubernetes
 	// (pending the request) and return them to the caller
 	GatherLogs(context.Context, *LogsRequest) (*LogsResponse, error)
+	// StreamLogs streams the logs of the root and user daemons, the traffic-manager, and the
+	// traffic-agents. The logs of the traffic-manager and the traffic-agents require a session.
+	StreamLogs(*StreamLogsRequest, Connector_StreamLogsServer) error
+	// ListTakeOverRequests returns the pending requests from other clients to take over the
+	// intercepts of this client.
+	ListTakeOverRequests(context.Context, *emptypb.Empty) (*TakeOverRequestList, error)
+	// AnswerTakeOver accepts or rejects a request to take over an intercept. The intercept
+	// is removed when the request is accepted.
+	AnswerTakeOver(context.Context, *TakeOverAnswer) (*emptypb.Empty, error)
+	// GetUsageReport returns the usage per user that the traffic-manager has collected.
+	GetUsageReport(context.Context, *manager.UsageRequest) (*manager.UsageReport, error)
+	// GetManagerProfile returns a pprof profile of the traffic-manager.
+	GetManagerProfile(context.Context, *manager.ProfileRequest) (*manager.ProfileResponse, error)
+	// GetInterceptMetrics returns the request counts, errors, and latencies that the
+	// traffic-agents have recorded for an intercept of this session.
+	GetInterceptMetrics(context.Context, *manager.GetInterceptRequest) (*manager.InterceptMetrics, error)
+	// SampleIntercept counts the requests that a proposed intercept would capture,
+	// without redirecting anything.
+	SampleIntercept(context.Context, *manager.SampleInterceptRequest) (*manager.SampleResult, error)
 	// GatherTraces will acquire traces for the various Telepresence components in kubernetes
 	// (pending the request) and save them in a file.
 	GatherTraces(context.Context, *TracesRequest) (*common.Result, error)
@@ -461,15 +783,31 @@ type ConnectorServer interface {
 	RemoveInterceptor(context.Context, *Interceptor) (*emptypb.Empty, error)
 	// GetNamespaces gets the mapped namespaces with an optional prefix
 	GetNamespaces(context.Context, *GetNamespacesRequest) (*GetNamespacesResponse, error)
+	// MapNamespaces adds namespaces to, or removes them from, the mapped namespaces of the
+	// current session. DNS and routing are updated without a reconnect. The response contains
+	// the namespaces that are mapped after the update.
+	MapNamespaces(context.Context, *MapNamespacesRequest) (*GetNamespacesResponse, error)
 	// RemoteMountAvailability checks if remote mounts are possible using the given
 	// mount type and returns an error if its not.
 	RemoteMountAvailability(context.Context, *emptypb.Empty) (*common.Result, error)
 	// GetConfig returns the current configuration
 	GetConfig(context.Context, *emptypb.Empty) (*ClientConfig, error)
+	// Paths returns the directories and sockets that the daemon uses.
+	Paths(context.Context, *emptypb.Empty) (*DaemonPaths, error)
 	// SetDNSExcludes sets the excludes field of DNSConfig.
 	SetDNSExcludes(context.Context, *daemon.SetDNSExcludesRequest) (*emptypb.Empty, error)
 	// SetDNSMappings sets the Mappings field of DNSConfig.
 	SetDNSMappings(context.Context, *daemon.SetDNSMappingsRequest) (*emptypb.Empty, error)
+	// GetRoutes returns the routes that the root daemon maintains.
+	GetRoutes(context.Context, *emptypb.Empty) (*daemon.RouteList, error)
+	// CheckRoute explains how the root daemon routes traffic to an address.
+	CheckRoute(context.Context, *daemon.CheckRouteRequest) (*daemon.CheckRouteResponse, error)
+	// Capture streams copies of the packets that traverse the root daemon's TUN-device.
+	Capture(*daemon.CaptureRequest, Connector_CaptureServer) error
+	// GetFlowStats returns the root daemon's traffic statistics per destination.
+	GetFlowStats(context.Context, *emptypb.Empty) (*daemon.FlowStats, error)
+	// Exec runs a command in the app container of a workload through the traffic-manager.
+	Exec(Connector_ExecServer) error
 	mustEmbedUnimplementedConnectorServer()
 }
 
@@ -495,6 +833,15 @@ func (UnimplementedConnectorServer) Connect(context.Context, *ConnectRequest) (*
 func (UnimplementedConnectorServer) Disconnect(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Disconnect not implemented")
 }
+func (UnimplementedConnectorServer) AcquireLease(context.Context, *Lease) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AcquireLease not implemented")
+}
+func (UnimplementedConnectorServer) ReleaseLease(context.Context, *Lease) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReleaseLease not implemented")
+}
+func (UnimplementedConnectorServer) ListLeases(context.Context, *emptypb.Empty) (*LeaseList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListLeases not implemented")
+}
 func (UnimplementedConnectorServer) GetClusterSubnets(context.Context, *emptypb.Empty) (*ClusterSubnets, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetClusterSubnets not implemented")
 }
@@ -513,6 +860,9 @@ func (UnimplementedConnectorServer) RemoveIntercept(context.Context, *manager.Re
 func (UnimplementedConnectorServer) UpdateIntercept(context.Context, *manager.UpdateInterceptRequest) (*manager.InterceptInfo, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateIntercept not implemented")
 }
+func (UnimplementedConnectorServer) SwitchInterceptHandler(context.Context, *SwitchInterceptHandlerRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SwitchInterceptHandler not implemented")
+}
 func (UnimplementedConnectorServer) Helm(context.Context, *HelmRequest) (*common.Result, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Helm not implemented")
 }
@@ -534,6 +884,27 @@ func (UnimplementedConnectorServer) Quit(context.Context, *emptypb.Empty) (*empt
 func (UnimplementedConnectorServer) GatherLogs(context.Context, *LogsRequest) (*LogsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GatherLogs not implemented")
 }
+func (UnimplementedConnectorServer) StreamLogs(*StreamLogsRequest, Connector_StreamLogsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamLogs not implemented")
+}
+func (UnimplementedConnectorServer) ListTakeOverRequests(context.Context, *emptypb.Empty) (*TakeOverRequestList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTakeOverRequests not implemented")
+}
+func (UnimplementedConnectorServer) AnswerTakeOver(context.Context, *TakeOverAnswer) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AnswerTakeOver not implemented")
+}
+func (UnimplementedConnectorServer) GetUsageReport(context.Context, *manager.UsageRequest) (*manager.UsageReport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUsageReport not implemented")
+}
+func (UnimplementedConnectorServer) GetManagerProfile(context.Context, *manager.ProfileRequest) (*manager.ProfileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetManagerProfile not implemented")
+}
+func (UnimplementedConnectorServer) GetInterceptMetrics(context.Context, *manager.GetInterceptRequest) (*manager.InterceptMetrics, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetInterceptMetrics not implemented")
+}
+func (UnimplementedConnectorServer) SampleIntercept(context.Context, *manager.SampleInterceptRequest) (*manager.SampleResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SampleIntercept not implemented")
+}
 func (UnimplementedConnectorServer) GatherTraces(context.Context, *TracesRequest) (*common.Result, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GatherTraces not implemented")
 }
@@ -546,18 +917,39 @@ func (UnimplementedConnectorServer) RemoveInterceptor(context.Context, *Intercep
 func (UnimplementedConnectorServer) GetNamespaces(context.Context, *GetNamespacesRequest) (*GetNamespacesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetNamespaces not implemented")
 }
+func (UnimplementedConnectorServer) MapNamespaces(context.Context, *MapNamespacesRequest) (*GetNamespacesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MapNamespaces not implemented")
+}
 func (UnimplementedConnectorServer) RemoteMountAvailability(context.Context, *emptypb.Empty) (*common.Result, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RemoteMountAvailability not implemented")
 }
 func (UnimplementedConnectorServer) GetConfig(context.Context, *emptypb.Empty) (*ClientConfig, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetConfig not implemented")
 }
+func (UnimplementedConnectorServer) Paths(context.Context, *emptypb.Empty) (*DaemonPaths, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Paths not implemented")
+}
 func (UnimplementedConnectorServer) SetDNSExcludes(context.Context, *daemon.SetDNSExcludesRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SetDNSExcludes not implemented")
 }
 func (UnimplementedConnectorServer) SetDNSMappings(context.Context, *daemon.SetDNSMappingsRequest) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SetDNSMappings not implemented")
 }
+func (UnimplementedConnectorServer) GetRoutes(context.Context, *emptypb.Empty) (*daemon.RouteList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRoutes not implemented")
+}
+func (UnimplementedConnectorServer) CheckRoute(context.Context, *daemon.CheckRouteRequest) (*daemon.CheckRouteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckRoute not implemented")
+}
+func (UnimplementedConnectorServer) Capture(*daemon.CaptureRequest, Connector_CaptureServer) error {
+	return status.Errorf(codes.Unimplemented, "method Capture not implemented")
+}
+func (UnimplementedConnectorServer) GetFlowStats(context.Context, *emptypb.Empty) (*daemon.FlowStats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFlowStats not implemented")
+}
+func (UnimplementedConnectorServer) Exec(Connector_ExecServer) error {
+	return status.Errorf(codes.Unimplemented, "method Exec not implemented")
+}
 func (UnimplementedConnectorServer) mustEmbedUnimplementedConnectorServer() {}
 
 // UnsafeConnectorServer may be embedded to opt out of forward compatibility for this service.
@@ -679,6 +1071,60 @@ func _Connector_Disconnect_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Connector_AcquireLease_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Lease)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConnectorServer).AcquireLease(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Connector_AcquireLease_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConnectorServer).AcquireLease(ctx, req.(*Lease))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Connector_ReleaseLease_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Lease)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConnectorServer).ReleaseLease(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Connector_ReleaseLease_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConnectorServer).ReleaseLease(ctx, req.(*Lease))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Connector_ListLeases_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConnectorServer).ListLeases(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Connector_ListLeases_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConnectorServer).ListLeases(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Connector_GetClusterSubnets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(emptypb.Empty)
 	if err := dec(in); err != nil {
@@ -787,6 +1233,24 @@ func _Connector_UpdateIntercept_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Connector_SwitchInterceptHandler_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SwitchInterceptHandlerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConnectorServer).SwitchInterceptHandler(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Connector_SwitchInterceptHandler_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConnectorServer).SwitchInterceptHandler(ctx, req.(*SwitchInterceptHandlerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Connector_Helm_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(HelmRequest)
 	if err := dec(in); err != nil {
@@ -916,6 +1380,135 @@ func _Connector_GatherLogs_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Connector_StreamLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamLogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ConnectorServer).StreamLogs(m, &connectorStreamLogsServer{stream})
+}
+
+type Connector_StreamLogsServer interface {
+	Send(*LogEntry) error
+	grpc.ServerStream
+}
+
+type connectorStreamLogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *connectorStreamLogsServer) Send(m *LogEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Connector_ListTakeOverRequests_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConnectorServer).ListTakeOverRequests(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Connector_ListTakeOverRequests_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConnectorServer).ListTakeOverRequests(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Connector_AnswerTakeOver_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TakeOverAnswer)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConnectorServer).AnswerTakeOver(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Connector_AnswerTakeOver_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConnectorServer).AnswerTakeOver(ctx, req.(*TakeOverAnswer))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Connector_GetUsageReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(manager.UsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConnectorServer).GetUsageReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Connector_GetUsageReport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConnectorServer).GetUsageReport(ctx, req.(*manager.UsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Connector_GetManagerProfile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(manager.ProfileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConnectorServer).GetManagerProfile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Connector_GetManagerProfile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConnectorServer).GetManagerProfile(ctx, req.(*manager.ProfileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Connector_GetInterceptMetrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(manager.GetInterceptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConnectorServer).GetInterceptMetrics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Connector_GetInterceptMetrics_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConnectorServer).GetInterceptMetrics(ctx, req.(*manager.GetInterceptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Connector_SampleIntercept_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(manager.SampleInterceptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConnectorServer).SampleIntercept(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Connector_SampleIntercept_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConnectorServer).SampleIntercept(ctx, req.(*manager.SampleInterceptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Connector_GatherTraces_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(TracesRequest)
 	if err := dec(in); err != nil {
@@ -988,6 +1581,24 @@ func _Connector_GetNamespaces_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Connector_MapNamespaces_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MapNamespacesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConnectorServer).MapNamespaces(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Connector_MapNamespaces_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConnectorServer).MapNamespaces(ctx, req.(*MapNamespacesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Connector_RemoteMountAvailability_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(emptypb.Empty)
 	if err := dec(in); err != nil {
@@ -1024,6 +1635,24 @@ func _Connector_GetConfig_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Connector_Paths_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConnectorServer).Paths(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Connector_Paths_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConnectorServer).Paths(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Connector_SetDNSExcludes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(daemon.SetDNSExcludesRequest)
 	if err := dec(in); err != nil {
@@ -1060,6 +1689,107 @@ func _Connector_SetDNSMappings_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Connector_GetRoutes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConnectorServer).GetRoutes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Connector_GetRoutes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConnectorServer).GetRoutes(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Connector_CheckRoute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(daemon.CheckRouteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConnectorServer).CheckRoute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Connector_CheckRoute_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConnectorServer).CheckRoute(ctx, req.(*daemon.CheckRouteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Connector_Capture_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(daemon.CaptureRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ConnectorServer).Capture(m, &connectorCaptureServer{stream})
+}
+
+type Connector_CaptureServer interface {
+	Send(*daemon.CapturedPacket) error
+	grpc.ServerStream
+}
+
+type connectorCaptureServer struct {
+	grpc.ServerStream
+}
+
+func (x *connectorCaptureServer) Send(m *daemon.CapturedPacket) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Connector_GetFlowStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ConnectorServer).GetFlowStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Connector_GetFlowStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ConnectorServer).GetFlowStats(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Connector_Exec_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ConnectorServer).Exec(&connectorExecServer{stream})
+}
+
+type Connector_ExecServer interface {
+	Send(*manager.ExecResponse) error
+	Recv() (*manager.ExecRequest, error)
+	grpc.ServerStream
+}
+
+type connectorExecServer struct {
+	grpc.ServerStream
+}
+
+func (x *connectorExecServer) Send(m *manager.ExecResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *connectorExecServer) Recv() (*manager.ExecRequest, error) {
+	m := new(manager.ExecRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Connector_ServiceDesc is the grpc.ServiceDesc for Connector service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -1091,6 +1821,18 @@ var Connector_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Disconnect",
 			Handler:    _Connector_Disconnect_Handler,
 		},
+		{
+			MethodName: "AcquireLease",
+			Handler:    _Connector_AcquireLease_Handler,
+		},
+		{
+			MethodName: "ReleaseLease",
+			Handler:    _Connector_ReleaseLease_Handler,
+		},
+		{
+			MethodName: "ListLeases",
+			Handler:    _Connector_ListLeases_Handler,
+		},
 		{
 			MethodName: "GetClusterSubnets",
 			Handler:    _Connector_GetClusterSubnets_Handler,
@@ -1115,6 +1857,10 @@ var Connector_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UpdateIntercept",
 			Handler:    _Connector_UpdateIntercept_Handler,
 		},
+		{
+			MethodName: "SwitchInterceptHandler",
+			Handler:    _Connector_SwitchInterceptHandler_Handler,
+		},
 		{
 			MethodName: "Helm",
 			Handler:    _Connector_Helm_Handler,
@@ -1139,6 +1885,30 @@ var Connector_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GatherLogs",
 			Handler:    _Connector_GatherLogs_Handler,
 		},
+		{
+			MethodName: "ListTakeOverRequests",
+			Handler:    _Connector_ListTakeOverRequests_Handler,
+		},
+		{
+			MethodName: "AnswerTakeOver",
+			Handler:    _Connector_AnswerTakeOver_Handler,
+		},
+		{
+			MethodName: "GetUsageReport",
+			Handler:    _Connector_GetUsageReport_Handler,
+		},
+		{
+			MethodName: "GetManagerProfile",
+			Handler:    _Connector_GetManagerProfile_Handler,
+		},
+		{
+			MethodName: "GetInterceptMetrics",
+			Handler:    _Connector_GetInterceptMetrics_Handler,
+		},
+		{
+			MethodName: "SampleIntercept",
+			Handler:    _Connector_SampleIntercept_Handler,
+		},
 		{
 			MethodName: "GatherTraces",
 			Handler:    _Connector_GatherTraces_Handler,
@@ -1155,6 +1925,10 @@ var Connector_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetNamespaces",
 			Handler:    _Connector_GetNamespaces_Handler,
 		},
+		{
+			MethodName: "MapNamespaces",
+			Handler:    _Connector_MapNamespaces_Handler,
+		},
 		{
 			MethodName: "RemoteMountAvailability",
 			Handler:    _Connector_RemoteMountAvailability_Handler,
@@ -1163,6 +1937,10 @@ var Connector_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetConfig",
 			Handler:    _Connector_GetConfig_Handler,
 		},
+		{
+			MethodName: "Paths",
+			Handler:    _Connector_Paths_Handler,
+		},
 		{
 			MethodName: "SetDNSExcludes",
 			Handler:    _Connector_SetDNSExcludes_Handler,
@@ -1171,6 +1949,18 @@ var Connector_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "SetDNSMappings",
 			Handler:    _Connector_SetDNSMappings_Handler,
 		},
+		{
+			MethodName: "GetRoutes",
+			Handler:    _Connector_GetRoutes_Handler,
+		},
+		{
+			MethodName: "CheckRoute",
+			Handler:    _Connector_CheckRoute_Handler,
+		},
+		{
+			MethodName: "GetFlowStats",
+			Handler:    _Connector_GetFlowStats_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -1178,6 +1968,22 @@ var Connector_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _Connector_WatchWorkloads_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "StreamLogs",
+			Handler:       _Connector_StreamLogs_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Capture",
+			Handler:       _Connector_Capture_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Exec",
+			Handler:       _Connector_Exec_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "connector/connector.proto",
 }
@@ -33,6 +33,11 @@ const (
 	Daemon_SetDNSMappings_FullMethodName   = "/telepresence.daemon.Daemon/SetDNSMappings"
 	Daemon_SetLogLevel_FullMethodName      = "/telepresence.daemon.Daemon/SetLogLevel"
 	Daemon_WaitForNetwork_FullMethodName   = "/telepresence.daemon.Daemon/WaitForNetwork"
+	Daemon_GetStats_FullMethodName         = "/telepresence.daemon.Daemon/GetStats"
+	Daemon_GetRoutes_FullMethodName        = "/telepresence.daemon.Daemon/GetRoutes"
+	Daemon_CheckRoute_FullMethodName       = "/telepresence.daemon.Daemon/CheckRoute"
+	Daemon_Capture_FullMethodName          = "/telepresence.daemon.Daemon/Capture"
+	Daemon_GetFlowStats_FullMethodName     = "/telepresence.daemon.Daemon/GetFlowStats"
 )
 
 // DaemonClient is the client API for Daemon service.
@@ -61,6 +66,18 @@ type DaemonClient interface {
 	SetLogLevel(ctx context.Context, in *manager.LogLevelRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	// WaitForNetwork waits for the network of the currently connected session to become ready.
 	WaitForNetwork(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// GetStats returns traffic and DNS statistics for the currently connected session.
+	GetStats(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*SessionStats, error)
+	// GetRoutes returns the routes that the daemon maintains for the currently connected session.
+	GetRoutes(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*RouteList, error)
+	// CheckRoute explains how traffic to an address is routed.
+	CheckRoute(ctx context.Context, in *CheckRouteRequest, opts ...grpc.CallOption) (*CheckRouteResponse, error)
+	// Capture streams copies of the packets that traverse the TUN-device until the
+	// call is cancelled or the session ends.
+	Capture(ctx context.Context, in *CaptureRequest, opts ...grpc.CallOption) (Daemon_CaptureClient, error)
+	// GetFlowStats returns traffic statistics per destination for the currently
+	// connected session.
+	GetFlowStats(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*FlowStats, error)
 }
 
 type daemonClient struct {
@@ -170,6 +187,74 @@ func (c *daemonClient) WaitForNetwork(ctx context.Context, in *emptypb.Empty, op
 	return out, nil
 }
 
+func (c *daemonClient) GetStats(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*SessionStats, error) {
+	out := new(SessionStats)
+	err := c.cc.Invoke(ctx, Daemon_GetStats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *daemonClient) GetRoutes(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*RouteList, error) {
+	out := new(RouteList)
+	err := c.cc.Invoke(ctx, Daemon_GetRoutes_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *daemonClient) CheckRoute(ctx context.Context, in *CheckRouteRequest, opts ...grpc.CallOption) (*CheckRouteResponse, error) {
+	out := new(CheckRouteResponse)
+	err := c.cc.Invoke(ctx, Daemon_CheckRoute_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *daemonClient) Capture(ctx context.Context, in *CaptureRequest, opts ...grpc.CallOption) (Daemon_CaptureClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Daemon_ServiceDesc.Streams[0], Daemon_Capture_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &daemonCaptureClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Daemon_CaptureClient interface {
+	Recv() (*CapturedPacket, error)
+	grpc.ClientStream
+}
+
+type daemonCaptureClient struct {
+	grpc.ClientStream
+}
+
+func (x *daemonCaptureClient) Recv() (*CapturedPacket, error) {
+	m := new(CapturedPacket)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *daemonClient) GetFlowStats(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*FlowStats, error) {
+	out := new(FlowStats)
+	err := c.cc.Invoke(ctx, Daemon_GetFlowStats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // DaemonServer is the server API for Daemon service.
 // All implementations must embed UnimplementedDaemonServer
 // for forward compatibility
@@ -196,6 +281,18 @@ type DaemonServer interface {
 	SetLogLevel(context.Context, *manager.LogLevelRequest) (*emptypb.Empty, error)
 	// WaitForNetwork waits for the network of the currently connected session to become ready.
 	WaitForNetwork(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
+	// GetStats returns traffic and DNS statistics for the currently connected session.
+	GetStats(context.Context, *emptypb.Empty) (*SessionStats, error)
+	// GetRoutes returns the routes that the daemon maintains for the currently connected session.
+	GetRoutes(context.Context, *emptypb.Empty) (*RouteList, error)
+	// CheckRoute explains how traffic to an address is routed.
+	CheckRoute(context.Context, *CheckRouteRequest) (*CheckRouteResponse, error)
+	// Capture streams copies of the packets that traverse the TUN-device until the
+	// call is cancelled or the session ends.
+	Capture(*CaptureRequest, Daemon_CaptureServer) error
+	// GetFlowStats returns traffic statistics per destination for the currently
+	// connected session.
+	GetFlowStats(context.Context, *emptypb.Empty) (*FlowStats, error)
 	mustEmbedUnimplementedDaemonServer()
 }
 
@@ -236,6 +333,21 @@ func (UnimplementedDaemonServer) SetLogLevel(context.Context, *manager.LogLevelR
 func (UnimplementedDaemonServer) WaitForNetwork(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method WaitForNetwork not implemented")
 }
+func (UnimplementedDaemonServer) GetStats(context.Context, *emptypb.Empty) (*SessionStats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
+}
+func (UnimplementedDaemonServer) GetRoutes(context.Context, *emptypb.Empty) (*RouteList, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRoutes not implemented")
+}
+func (UnimplementedDaemonServer) CheckRoute(context.Context, *CheckRouteRequest) (*CheckRouteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckRoute not implemented")
+}
+func (UnimplementedDaemonServer) Capture(*CaptureRequest, Daemon_CaptureServer) error {
+	return status.Errorf(codes.Unimplemented, "method Capture not implemented")
+}
+func (UnimplementedDaemonServer) GetFlowStats(context.Context, *emptypb.Empty) (*FlowStats, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFlowStats not implemented")
+}
 func (UnimplementedDaemonServer) mustEmbedUnimplementedDaemonServer() {}
 
 // UnsafeDaemonServer may be embedded to opt out of forward compatibility for this service.
@@ -447,6 +559,99 @@ func _Daemon_WaitForNetwork_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Daemon_GetStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DaemonServer).GetStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Daemon_GetStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DaemonServer).GetStats(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Daemon_GetRoutes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DaemonServer).GetRoutes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Daemon_GetRoutes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DaemonServer).GetRoutes(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Daemon_CheckRoute_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckRouteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DaemonServer).CheckRoute(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Daemon_CheckRoute_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DaemonServer).CheckRoute(ctx, req.(*CheckRouteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Daemon_Capture_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(CaptureRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DaemonServer).Capture(m, &daemonCaptureServer{stream})
+}
+
+type Daemon_CaptureServer interface {
+	Send(*CapturedPacket) error
+	grpc.ServerStream
+}
+
+type daemonCaptureServer struct {
+	grpc.ServerStream
+}
+
+func (x *daemonCaptureServer) Send(m *CapturedPacket) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Daemon_GetFlowStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DaemonServer).GetFlowStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Daemon_GetFlowStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DaemonServer).GetFlowStats(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Daemon_ServiceDesc is the grpc.ServiceDesc for Daemon service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -498,7 +703,29 @@ var Daemon_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "WaitForNetwork",
 			Handler:    _Daemon_WaitForNetwork_Handler,
 		},
+		{
+			MethodName: "GetStats",
+			Handler:    _Daemon_GetStats_Handler,
+		},
+		{
+			MethodName: "GetRoutes",
+			Handler:    _Daemon_GetRoutes_Handler,
+		},
+		{
+			MethodName: "CheckRoute",
+			Handler:    _Daemon_CheckRoute_Handler,
+		},
+		{
+			MethodName: "GetFlowStats",
+			Handler:    _Daemon_GetFlowStats_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Capture",
+			Handler:       _Daemon_Capture_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "daemon/daemon.proto",
 }
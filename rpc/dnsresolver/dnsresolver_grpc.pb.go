@@ -0,0 +1,118 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v3.21.9
+// source: dnsresolver/dnsresolver.proto
+
+package dnsresolver
+
+import (
+	context "context"
+	manager "github.com/telepresenceio/telepresence/rpc/v2/manager"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Resolver_Resolve_FullMethodName = "/telepresence.dnsresolver.Resolver/Resolve"
+)
+
+// ResolverClient is the client API for Resolver service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ResolverClient interface {
+	// Resolve a DNS question. The request and the response are the same as the ones
+	// used by the traffic-manager's LookupDNS call, although the request has no session.
+	// A plugin that doesn't know a name must respond with an NXDOMAIN r_code, so that the
+	// next resolver gets to answer it.
+	Resolve(ctx context.Context, in *manager.DNSRequest, opts ...grpc.CallOption) (*manager.DNSResponse, error)
+}
+
+type resolverClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewResolverClient(cc grpc.ClientConnInterface) ResolverClient {
+	return &resolverClient{cc}
+}
+
+func (c *resolverClient) Resolve(ctx context.Context, in *manager.DNSRequest, opts ...grpc.CallOption) (*manager.DNSResponse, error) {
+	out := new(manager.DNSResponse)
+	err := c.cc.Invoke(ctx, Resolver_Resolve_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ResolverServer is the server API for Resolver service.
+// All implementations must embed UnimplementedResolverServer
+// for forward compatibility
+type ResolverServer interface {
+	// Resolve a DNS question. The request and the response are the same as the ones
+	// used by the traffic-manager's LookupDNS call, although the request has no session.
+	// A plugin that doesn't know a name must respond with an NXDOMAIN r_code, so that the
+	// next resolver gets to answer it.
+	Resolve(context.Context, *manager.DNSRequest) (*manager.DNSResponse, error)
+	mustEmbedUnimplementedResolverServer()
+}
+
+// UnimplementedResolverServer must be embedded to have forward compatible implementations.
+type UnimplementedResolverServer struct {
+}
+
+func (UnimplementedResolverServer) Resolve(context.Context, *manager.DNSRequest) (*manager.DNSResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Resolve not implemented")
+}
+func (UnimplementedResolverServer) mustEmbedUnimplementedResolverServer() {}
+
+// UnsafeResolverServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ResolverServer will
+// result in compilation errors.
+type UnsafeResolverServer interface {
+	mustEmbedUnimplementedResolverServer()
+}
+
+func RegisterResolverServer(s grpc.ServiceRegistrar, srv ResolverServer) {
+	s.RegisterService(&Resolver_ServiceDesc, srv)
+}
+
+func _Resolver_Resolve_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(manager.DNSRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ResolverServer).Resolve(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Resolver_Resolve_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ResolverServer).Resolve(ctx, req.(*manager.DNSRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Resolver_ServiceDesc is the grpc.ServiceDesc for Resolver service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Resolver_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "telepresence.dnsresolver.Resolver",
+	HandlerType: (*ResolverServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Resolve",
+			Handler:    _Resolver_Resolve_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "dnsresolver/dnsresolver.proto",
+}
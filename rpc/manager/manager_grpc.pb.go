@@ -43,6 +43,9 @@ const (
 	Manager_PrepareIntercept_FullMethodName          = "/telepresence.manager.Manager/PrepareIntercept"
 	Manager_CreateIntercept_FullMethodName           = "/telepresence.manager.Manager/CreateIntercept"
 	Manager_RemoveIntercept_FullMethodName           = "/telepresence.manager.Manager/RemoveIntercept"
+	Manager_TakeOverIntercept_FullMethodName         = "/telepresence.manager.Manager/TakeOverIntercept"
+	Manager_WatchTakeOverRequests_FullMethodName     = "/telepresence.manager.Manager/WatchTakeOverRequests"
+	Manager_AnswerTakeOver_FullMethodName            = "/telepresence.manager.Manager/AnswerTakeOver"
 	Manager_UpdateIntercept_FullMethodName           = "/telepresence.manager.Manager/UpdateIntercept"
 	Manager_GetIntercept_FullMethodName              = "/telepresence.manager.Manager/GetIntercept"
 	Manager_ReviewIntercept_FullMethodName           = "/telepresence.manager.Manager/ReviewIntercept"
@@ -57,6 +60,14 @@ const (
 	Manager_WatchLogLevel_FullMethodName             = "/telepresence.manager.Manager/WatchLogLevel"
 	Manager_Tunnel_FullMethodName                    = "/telepresence.manager.Manager/Tunnel"
 	Manager_WatchDial_FullMethodName                 = "/telepresence.manager.Manager/WatchDial"
+	Manager_GetUsageReport_FullMethodName            = "/telepresence.manager.Manager/GetUsageReport"
+	Manager_GetProfile_FullMethodName                = "/telepresence.manager.Manager/GetProfile"
+	Manager_ReportInterceptMetrics_FullMethodName    = "/telepresence.manager.Manager/ReportInterceptMetrics"
+	Manager_GetInterceptMetrics_FullMethodName       = "/telepresence.manager.Manager/GetInterceptMetrics"
+	Manager_SampleIntercept_FullMethodName           = "/telepresence.manager.Manager/SampleIntercept"
+	Manager_WatchSampleRequests_FullMethodName       = "/telepresence.manager.Manager/WatchSampleRequests"
+	Manager_AgentSampleResult_FullMethodName         = "/telepresence.manager.Manager/AgentSampleResult"
+	Manager_Exec_FullMethodName                      = "/telepresence.manager.Manager/Exec"
 )
 
 // ManagerClient is the client API for Manager service.
@@ -122,6 +133,15 @@ type ManagerClient interface {
 	CreateIntercept(ctx context.Context, in *CreateInterceptRequest, opts ...grpc.CallOption) (*InterceptInfo, error)
 	// RemoveIntercept lets a client remove an intercept.
 	RemoveIntercept(ctx context.Context, in *RemoveInterceptRequest2, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// TakeOverIntercept asks the clients that own intercepts that conflict with the
+	// given intercept spec to hand them over. The call blocks until the owners have
+	// answered, or until the request expires. The intercepts of owners that are absent,
+	// i.e. no longer send heartbeats, are taken over without confirmation.
+	TakeOverIntercept(ctx context.Context, in *TakeOverRequest, opts ...grpc.CallOption) (*TakeOverResult, error)
+	// WatchTakeOverRequests lets a client receive the requests to take over its intercepts.
+	WatchTakeOverRequests(ctx context.Context, in *SessionInfo, opts ...grpc.CallOption) (Manager_WatchTakeOverRequestsClient, error)
+	// AnswerTakeOver lets a client accept or reject a request to take over its intercept.
+	AnswerTakeOver(ctx context.Context, in *TakeOverAnswer, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	UpdateIntercept(ctx context.Context, in *UpdateInterceptRequest, opts ...grpc.CallOption) (*InterceptInfo, error)
 	// GetIntercept gets info from intercept name
 	GetIntercept(ctx context.Context, in *GetInterceptRequest, opts ...grpc.CallOption) (*InterceptInfo, error)
@@ -172,6 +192,27 @@ type ManagerClient interface {
 	// connection and responds with a Tunnel. The manager then connects the
 	// two tunnels.
 	WatchDial(ctx context.Context, in *SessionInfo, opts ...grpc.CallOption) (Manager_WatchDialClient, error)
+	// GetUsageReport returns the number of connects, the intercept durations, and the
+	// namespaces touched, per user, since the given time.
+	GetUsageReport(ctx context.Context, in *UsageRequest, opts ...grpc.CallOption) (*UsageReport, error)
+	// GetProfile returns a pprof profile of the traffic-manager.
+	GetProfile(ctx context.Context, in *ProfileRequest, opts ...grpc.CallOption) (*ProfileResponse, error)
+	// ReportInterceptMetrics lets an agent report the metrics of the intercepts that it serves.
+	ReportInterceptMetrics(ctx context.Context, in *InterceptMetricsReport, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// GetInterceptMetrics returns the metrics of an intercept, summed over all agents
+	// that serve it.
+	GetInterceptMetrics(ctx context.Context, in *GetInterceptRequest, opts ...grpc.CallOption) (*InterceptMetrics, error)
+	// SampleIntercept counts the requests that the traffic-agents of a workload receive,
+	// and how many of them match a filter, during the given duration.
+	SampleIntercept(ctx context.Context, in *SampleInterceptRequest, opts ...grpc.CallOption) (*SampleResult, error)
+	// WatchSampleRequests lets a traffic-agent receive the requests to sample traffic.
+	WatchSampleRequests(ctx context.Context, in *SessionInfo, opts ...grpc.CallOption) (Manager_WatchSampleRequestsClient, error)
+	// AgentSampleResult lets a traffic-agent report the result of a SampleRequest.
+	AgentSampleResult(ctx context.Context, in *SampleAgentResult, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Exec runs a command in the app container of a workload on behalf of the client,
+	// using the traffic-manager's access to the cluster. The exec policy of the
+	// traffic-manager decides who may exec into what.
+	Exec(ctx context.Context, opts ...grpc.CallOption) (Manager_ExecClient, error)
 }
 
 type managerClient struct {
@@ -445,6 +486,56 @@ func (c *managerClient) RemoveIntercept(ctx context.Context, in *RemoveIntercept
 	return out, nil
 }
 
+func (c *managerClient) TakeOverIntercept(ctx context.Context, in *TakeOverRequest, opts ...grpc.CallOption) (*TakeOverResult, error) {
+	out := new(TakeOverResult)
+	err := c.cc.Invoke(ctx, Manager_TakeOverIntercept_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managerClient) WatchTakeOverRequests(ctx context.Context, in *SessionInfo, opts ...grpc.CallOption) (Manager_WatchTakeOverRequestsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[4], Manager_WatchTakeOverRequests_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &managerWatchTakeOverRequestsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Manager_WatchTakeOverRequestsClient interface {
+	Recv() (*TakeOverRequest, error)
+	grpc.ClientStream
+}
+
+type managerWatchTakeOverRequestsClient struct {
+	grpc.ClientStream
+}
+
+func (x *managerWatchTakeOverRequestsClient) Recv() (*TakeOverRequest, error) {
+	m := new(TakeOverRequest)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *managerClient) AnswerTakeOver(ctx context.Context, in *TakeOverAnswer, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Manager_AnswerTakeOver_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *managerClient) UpdateIntercept(ctx context.Context, in *UpdateInterceptRequest, opts ...grpc.CallOption) (*InterceptInfo, error) {
 	out := new(InterceptInfo)
 	err := c.cc.Invoke(ctx, Manager_UpdateIntercept_FullMethodName, in, out, opts...)
@@ -473,7 +564,7 @@ func (c *managerClient) ReviewIntercept(ctx context.Context, in *ReviewIntercept
 }
 
 func (c *managerClient) ClientTunnel(ctx context.Context, opts ...grpc.CallOption) (Manager_ClientTunnelClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[4], Manager_ClientTunnel_FullMethodName, opts...)
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[5], Manager_ClientTunnel_FullMethodName, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -504,7 +595,7 @@ func (x *managerClientTunnelClient) Recv() (*ConnMessage, error) {
 }
 
 func (c *managerClient) AgentTunnel(ctx context.Context, opts ...grpc.CallOption) (Manager_AgentTunnelClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[5], Manager_AgentTunnel_FullMethodName, opts...)
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[6], Manager_AgentTunnel_FullMethodName, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -553,7 +644,7 @@ func (c *managerClient) AgentLookupHostResponse(ctx context.Context, in *LookupH
 }
 
 func (c *managerClient) WatchLookupHost(ctx context.Context, in *SessionInfo, opts ...grpc.CallOption) (Manager_WatchLookupHostClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[6], Manager_WatchLookupHost_FullMethodName, opts...)
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[7], Manager_WatchLookupHost_FullMethodName, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -603,7 +694,7 @@ func (c *managerClient) AgentLookupDNSResponse(ctx context.Context, in *DNSAgent
 }
 
 func (c *managerClient) WatchLookupDNS(ctx context.Context, in *SessionInfo, opts ...grpc.CallOption) (Manager_WatchLookupDNSClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[7], Manager_WatchLookupDNS_FullMethodName, opts...)
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[8], Manager_WatchLookupDNS_FullMethodName, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -635,7 +726,7 @@ func (x *managerWatchLookupDNSClient) Recv() (*DNSRequest, error) {
 }
 
 func (c *managerClient) WatchLogLevel(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (Manager_WatchLogLevelClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[8], Manager_WatchLogLevel_FullMethodName, opts...)
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[9], Manager_WatchLogLevel_FullMethodName, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -667,7 +758,7 @@ func (x *managerWatchLogLevelClient) Recv() (*LogLevelRequest, error) {
 }
 
 func (c *managerClient) Tunnel(ctx context.Context, opts ...grpc.CallOption) (Manager_TunnelClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[9], Manager_Tunnel_FullMethodName, opts...)
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[10], Manager_Tunnel_FullMethodName, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -698,7 +789,7 @@ func (x *managerTunnelClient) Recv() (*TunnelMessage, error) {
 }
 
 func (c *managerClient) WatchDial(ctx context.Context, in *SessionInfo, opts ...grpc.CallOption) (Manager_WatchDialClient, error) {
-	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[10], Manager_WatchDial_FullMethodName, opts...)
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[11], Manager_WatchDial_FullMethodName, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -729,6 +820,123 @@ func (x *managerWatchDialClient) Recv() (*DialRequest, error) {
 	return m, nil
 }
 
+func (c *managerClient) GetUsageReport(ctx context.Context, in *UsageRequest, opts ...grpc.CallOption) (*UsageReport, error) {
+	out := new(UsageReport)
+	err := c.cc.Invoke(ctx, Manager_GetUsageReport_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managerClient) GetProfile(ctx context.Context, in *ProfileRequest, opts ...grpc.CallOption) (*ProfileResponse, error) {
+	out := new(ProfileResponse)
+	err := c.cc.Invoke(ctx, Manager_GetProfile_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managerClient) ReportInterceptMetrics(ctx context.Context, in *InterceptMetricsReport, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Manager_ReportInterceptMetrics_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managerClient) GetInterceptMetrics(ctx context.Context, in *GetInterceptRequest, opts ...grpc.CallOption) (*InterceptMetrics, error) {
+	out := new(InterceptMetrics)
+	err := c.cc.Invoke(ctx, Manager_GetInterceptMetrics_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managerClient) SampleIntercept(ctx context.Context, in *SampleInterceptRequest, opts ...grpc.CallOption) (*SampleResult, error) {
+	out := new(SampleResult)
+	err := c.cc.Invoke(ctx, Manager_SampleIntercept_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managerClient) WatchSampleRequests(ctx context.Context, in *SessionInfo, opts ...grpc.CallOption) (Manager_WatchSampleRequestsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[12], Manager_WatchSampleRequests_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &managerWatchSampleRequestsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Manager_WatchSampleRequestsClient interface {
+	Recv() (*SampleRequest, error)
+	grpc.ClientStream
+}
+
+type managerWatchSampleRequestsClient struct {
+	grpc.ClientStream
+}
+
+func (x *managerWatchSampleRequestsClient) Recv() (*SampleRequest, error) {
+	m := new(SampleRequest)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *managerClient) AgentSampleResult(ctx context.Context, in *SampleAgentResult, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Manager_AgentSampleResult_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managerClient) Exec(ctx context.Context, opts ...grpc.CallOption) (Manager_ExecClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Manager_ServiceDesc.Streams[13], Manager_Exec_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &managerExecClient{stream}
+	return x, nil
+}
+
+type Manager_ExecClient interface {
+	Send(*ExecRequest) error
+	Recv() (*ExecResponse, error)
+	grpc.ClientStream
+}
+
+type managerExecClient struct {
+	grpc.ClientStream
+}
+
+func (x *managerExecClient) Send(m *ExecRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *managerExecClient) Recv() (*ExecResponse, error) {
+	m := new(ExecResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // ManagerServer is the server API for Manager service.
 // All implementations must embed UnimplementedManagerServer
 // for forward compatibility
@@ -792,6 +1000,15 @@ type ManagerServer interface {
 	CreateIntercept(context.Context, *CreateInterceptRequest) (*InterceptInfo, error)
 	// RemoveIntercept lets a client remove an intercept.
 	RemoveIntercept(context.Context, *RemoveInterceptRequest2) (*emptypb.Empty, error)
+	// TakeOverIntercept asks the clients that own intercepts that conflict with the
+	// given intercept spec to hand them over. The call blocks until the owners have
+	// answered, or until the request expires. The intercepts of owners that are absent,
+	// i.e. no longer send heartbeats, are taken over without confirmation.
+	TakeOverIntercept(context.Context, *TakeOverRequest) (*TakeOverResult, error)
+	// WatchTakeOverRequests lets a client receive the requests to take over its intercepts.
+	WatchTakeOverRequests(*SessionInfo, Manager_WatchTakeOverRequestsServer) error
+	// AnswerTakeOver lets a client accept or reject a request to take over its intercept.
+	AnswerTakeOver(context.Context, *TakeOverAnswer) (*emptypb.Empty, error)
 	UpdateIntercept(context.Context, *UpdateInterceptRequest) (*InterceptInfo, error)
 	// GetIntercept gets info from intercept name
 	GetIntercept(context.Context, *GetInterceptRequest) (*InterceptInfo, error)
@@ -842,6 +1059,27 @@ type ManagerServer interface {
 	// connection and responds with a Tunnel. The manager then connects the
 	// two tunnels.
 	WatchDial(*SessionInfo, Manager_WatchDialServer) error
+	// GetUsageReport returns the number of connects, the intercept durations, and the
+	// namespaces touched, per user, since the given time.
+	GetUsageReport(context.Context, *UsageRequest) (*UsageReport, error)
+	// GetProfile returns a pprof profile of the traffic-manager.
+	GetProfile(context.Context, *ProfileRequest) (*ProfileResponse, error)
+	// ReportInterceptMetrics lets an agent report the metrics of the intercepts that it serves.
+	ReportInterceptMetrics(context.Context, *InterceptMetricsReport) (*emptypb.Empty, error)
+	// GetInterceptMetrics returns the metrics of an intercept, summed over all agents
+	// that serve it.
+	GetInterceptMetrics(context.Context, *GetInterceptRequest) (*InterceptMetrics, error)
+	// SampleIntercept counts the requests that the traffic-agents of a workload receive,
+	// and how many of them match a filter, during the given duration.
+	SampleIntercept(context.Context, *SampleInterceptRequest) (*SampleResult, error)
+	// WatchSampleRequests lets a traffic-agent receive the requests to sample traffic.
+	WatchSampleRequests(*SessionInfo, Manager_WatchSampleRequestsServer) error
+	// AgentSampleResult lets a traffic-agent report the result of a SampleRequest.
+	AgentSampleResult(context.Context, *SampleAgentResult) (*emptypb.Empty, error)
+	// Exec runs a command in the app container of a workload on behalf of the client,
+	// using the traffic-manager's access to the cluster. The exec policy of the
+	// traffic-manager decides who may exec into what.
+	Exec(Manager_ExecServer) error
 	mustEmbedUnimplementedManagerServer()
 }
 
@@ -906,6 +1144,15 @@ func (UnimplementedManagerServer) CreateIntercept(context.Context, *CreateInterc
 func (UnimplementedManagerServer) RemoveIntercept(context.Context, *RemoveInterceptRequest2) (*emptypb.Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RemoveIntercept not implemented")
 }
+func (UnimplementedManagerServer) TakeOverIntercept(context.Context, *TakeOverRequest) (*TakeOverResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TakeOverIntercept not implemented")
+}
+func (UnimplementedManagerServer) WatchTakeOverRequests(*SessionInfo, Manager_WatchTakeOverRequestsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchTakeOverRequests not implemented")
+}
+func (UnimplementedManagerServer) AnswerTakeOver(context.Context, *TakeOverAnswer) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AnswerTakeOver not implemented")
+}
 func (UnimplementedManagerServer) UpdateIntercept(context.Context, *UpdateInterceptRequest) (*InterceptInfo, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateIntercept not implemented")
 }
@@ -948,6 +1195,30 @@ func (UnimplementedManagerServer) Tunnel(Manager_TunnelServer) error {
 func (UnimplementedManagerServer) WatchDial(*SessionInfo, Manager_WatchDialServer) error {
 	return status.Errorf(codes.Unimplemented, "method WatchDial not implemented")
 }
+func (UnimplementedManagerServer) GetUsageReport(context.Context, *UsageRequest) (*UsageReport, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUsageReport not implemented")
+}
+func (UnimplementedManagerServer) GetProfile(context.Context, *ProfileRequest) (*ProfileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProfile not implemented")
+}
+func (UnimplementedManagerServer) ReportInterceptMetrics(context.Context, *InterceptMetricsReport) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportInterceptMetrics not implemented")
+}
+func (UnimplementedManagerServer) GetInterceptMetrics(context.Context, *GetInterceptRequest) (*InterceptMetrics, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetInterceptMetrics not implemented")
+}
+func (UnimplementedManagerServer) SampleIntercept(context.Context, *SampleInterceptRequest) (*SampleResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SampleIntercept not implemented")
+}
+func (UnimplementedManagerServer) WatchSampleRequests(*SessionInfo, Manager_WatchSampleRequestsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchSampleRequests not implemented")
+}
+func (UnimplementedManagerServer) AgentSampleResult(context.Context, *SampleAgentResult) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AgentSampleResult not implemented")
+}
+func (UnimplementedManagerServer) Exec(Manager_ExecServer) error {
+	return status.Errorf(codes.Unimplemented, "method Exec not implemented")
+}
 func (UnimplementedManagerServer) mustEmbedUnimplementedManagerServer() {}
 
 // UnsafeManagerServer may be embedded to opt out of forward compatibility for this service.
@@ -1315,6 +1586,63 @@ func _Manager_RemoveIntercept_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Manager_TakeOverIntercept_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TakeOverRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).TakeOverIntercept(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Manager_TakeOverIntercept_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).TakeOverIntercept(ctx, req.(*TakeOverRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Manager_WatchTakeOverRequests_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SessionInfo)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ManagerServer).WatchTakeOverRequests(m, &managerWatchTakeOverRequestsServer{stream})
+}
+
+type Manager_WatchTakeOverRequestsServer interface {
+	Send(*TakeOverRequest) error
+	grpc.ServerStream
+}
+
+type managerWatchTakeOverRequestsServer struct {
+	grpc.ServerStream
+}
+
+func (x *managerWatchTakeOverRequestsServer) Send(m *TakeOverRequest) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Manager_AnswerTakeOver_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TakeOverAnswer)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).AnswerTakeOver(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Manager_AnswerTakeOver_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).AnswerTakeOver(ctx, req.(*TakeOverAnswer))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Manager_UpdateIntercept_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(UpdateInterceptRequest)
 	if err := dec(in); err != nil {
@@ -1603,6 +1931,161 @@ func (x *managerWatchDialServer) Send(m *DialRequest) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _Manager_GetUsageReport_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UsageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).GetUsageReport(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Manager_GetUsageReport_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).GetUsageReport(ctx, req.(*UsageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Manager_GetProfile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ProfileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).GetProfile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Manager_GetProfile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).GetProfile(ctx, req.(*ProfileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Manager_ReportInterceptMetrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InterceptMetricsReport)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).ReportInterceptMetrics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Manager_ReportInterceptMetrics_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).ReportInterceptMetrics(ctx, req.(*InterceptMetricsReport))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Manager_GetInterceptMetrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetInterceptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).GetInterceptMetrics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Manager_GetInterceptMetrics_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).GetInterceptMetrics(ctx, req.(*GetInterceptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Manager_SampleIntercept_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SampleInterceptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).SampleIntercept(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Manager_SampleIntercept_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).SampleIntercept(ctx, req.(*SampleInterceptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Manager_WatchSampleRequests_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SessionInfo)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ManagerServer).WatchSampleRequests(m, &managerWatchSampleRequestsServer{stream})
+}
+
+type Manager_WatchSampleRequestsServer interface {
+	Send(*SampleRequest) error
+	grpc.ServerStream
+}
+
+type managerWatchSampleRequestsServer struct {
+	grpc.ServerStream
+}
+
+func (x *managerWatchSampleRequestsServer) Send(m *SampleRequest) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Manager_AgentSampleResult_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SampleAgentResult)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagerServer).AgentSampleResult(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Manager_AgentSampleResult_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagerServer).AgentSampleResult(ctx, req.(*SampleAgentResult))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Manager_Exec_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ManagerServer).Exec(&managerExecServer{stream})
+}
+
+type Manager_ExecServer interface {
+	Send(*ExecResponse) error
+	Recv() (*ExecRequest, error)
+	grpc.ServerStream
+}
+
+type managerExecServer struct {
+	grpc.ServerStream
+}
+
+func (x *managerExecServer) Send(m *ExecResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *managerExecServer) Recv() (*ExecRequest, error) {
+	m := new(ExecRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // Manager_ServiceDesc is the grpc.ServiceDesc for Manager service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -1670,6 +2153,14 @@ var Manager_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RemoveIntercept",
 			Handler:    _Manager_RemoveIntercept_Handler,
 		},
+		{
+			MethodName: "TakeOverIntercept",
+			Handler:    _Manager_TakeOverIntercept_Handler,
+		},
+		{
+			MethodName: "AnswerTakeOver",
+			Handler:    _Manager_AnswerTakeOver_Handler,
+		},
 		{
 			MethodName: "UpdateIntercept",
 			Handler:    _Manager_UpdateIntercept_Handler,
@@ -1698,6 +2189,30 @@ var Manager_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "AgentLookupDNSResponse",
 			Handler:    _Manager_AgentLookupDNSResponse_Handler,
 		},
+		{
+			MethodName: "GetUsageReport",
+			Handler:    _Manager_GetUsageReport_Handler,
+		},
+		{
+			MethodName: "GetProfile",
+			Handler:    _Manager_GetProfile_Handler,
+		},
+		{
+			MethodName: "ReportInterceptMetrics",
+			Handler:    _Manager_ReportInterceptMetrics_Handler,
+		},
+		{
+			MethodName: "GetInterceptMetrics",
+			Handler:    _Manager_GetInterceptMetrics_Handler,
+		},
+		{
+			MethodName: "SampleIntercept",
+			Handler:    _Manager_SampleIntercept_Handler,
+		},
+		{
+			MethodName: "AgentSampleResult",
+			Handler:    _Manager_AgentSampleResult_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -1720,6 +2235,11 @@ var Manager_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _Manager_WatchClusterInfo_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "WatchTakeOverRequests",
+			Handler:       _Manager_WatchTakeOverRequests_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "ClientTunnel",
 			Handler:       _Manager_ClientTunnel_Handler,
@@ -1758,6 +2278,17 @@ var Manager_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _Manager_WatchDial_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "WatchSampleRequests",
+			Handler:       _Manager_WatchSampleRequests_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Exec",
+			Handler:       _Manager_Exec_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "manager/manager.proto",
 }